@@ -11,15 +11,20 @@ var Commands = []*Command{
 	cmdBenchmark,
 	cmdBackup,
 	cmdCompact,
+	cmdConfig,
 	cmdCopy,
+	cmdDataKey,
 	cmdDownload,
 	cmdExport,
 	cmdFiler,
 	cmdFilerBackup,
 	cmdFilerCat,
+	cmdFilerIndex,
 	cmdFilerMetaBackup,
 	cmdFilerMetaTail,
+	cmdFilerProcess,
 	cmdFilerReplicate,
+	cmdFilerSearch,
 	cmdFilerSynchronize,
 	cmdFix,
 	cmdGateway,
@@ -31,6 +36,7 @@ var Commands = []*Command{
 	cmdScaffold,
 	cmdServer,
 	cmdShell,
+	cmdSwift,
 	cmdUpload,
 	cmdVersion,
 	cmdVolume,
@@ -0,0 +1,145 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/viper"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+type ConfigOptions struct {
+	master       *string
+	volumeServer *string
+	filer        *string
+	configs      *string
+}
+
+var (
+	configOptions ConfigOptions
+)
+
+func init() {
+	cmdConfig.Run = runConfig // break init cycle
+	configOptions.master = cmdConfig.Flag.String("master", "", "print the effective configuration of this running master server <host>:<port>")
+	configOptions.volumeServer = cmdConfig.Flag.String("volumeServer", "", "print the effective configuration of this running volume server <host>:<port>")
+	configOptions.filer = cmdConfig.Flag.String("filer", "", "print the effective configuration of this running filer <host>:<port>")
+	configOptions.configs = cmdConfig.Flag.String("config", "security,master,filer", "if no server is specified, comma separated [filer|notification|replication|process|security|master|shell] configuration files to load locally")
+}
+
+var cmdConfig = &Command{
+	UsageLine: "config [-master=<host>:<port>|-volumeServer=<host>:<port>|-filer=<host>:<port>] [-config=security,filer]",
+	Short:     "print the effective configuration, after the environment variable overrides",
+	Long: `print the effective configuration, after the environment variable overrides
+
+	Each option in the *.toml files can be overridden by an environment variable,
+	e.g. the filer.toml mysql password by the environment variable WEED_MYSQL_PASSWORD.
+	See "weed help scaffold" for the precedence.
+
+	With "-master", "-volumeServer" or "-filer", it prints the configuration of the running server.
+	The volume server only serves it without the jwt signing key, or with "access.ui" enabled in security.toml.
+	Otherwise, it loads the "-config" files from the current directory, or $HOME/.seaweedfs/, or /etc/seaweedfs/,
+	applies the environment variables in the current shell, and prints the result.
+
+	Each option is printed with its source, one of "env", "file" or "default".
+	The passwords, secrets, keys and tokens are redacted.
+	The "WEED_" environment variables not matching any option, or with a wrong type, are also reported.
+
+`,
+}
+
+func runConfig(cmd *Command, args []string) bool {
+
+	var options []util.ConfigOption
+	var err error
+	switch {
+	case *configOptions.master != "":
+		options, err = fetchEffectiveConfiguration(fmt.Sprintf("http://%s/stats/config", *configOptions.master))
+	case *configOptions.volumeServer != "":
+		options, err = fetchEffectiveConfiguration(fmt.Sprintf("http://%s/stats/config", *configOptions.volumeServer))
+	case *configOptions.filer != "":
+		options, err = fetchEffectiveConfiguration(fmt.Sprintf("http://%s/?config", *configOptions.filer))
+	default:
+		for _, name := range strings.Split(*configOptions.configs, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				util.LoadConfiguration(name, false)
+			}
+		}
+		registerConfigKeys()
+		options = util.GetViper().EffectiveConfiguration()
+		for _, problem := range util.CheckEnvOverrides(os.Environ()) {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", problem)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return true
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, option := range options {
+		value := fmt.Sprintf("%v", option.Value)
+		if s, ok := option.Value.(string); ok {
+			value = fmt.Sprintf("%q", s)
+		}
+		source := option.Source
+		if option.Env != "" {
+			source += " " + option.Env
+		}
+		fmt.Fprintf(w, "%s = %s\t# %s\n", option.Key, value, source)
+	}
+	w.Flush()
+	return true
+}
+
+func fetchEffectiveConfiguration(url string) ([]util.ConfigOption, error) {
+	data, _, err := util.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("get %s: %v", url, err)
+	}
+	var ret struct {
+		Configuration []util.ConfigOption
+	}
+	if err = json.Unmarshal(data, &ret); err != nil {
+		return nil, fmt.Errorf("parse %s: %v", url, err)
+	}
+	return ret.Configuration, nil
+}
+
+// registerConfigKeys registers the options in the example *.toml files.
+func registerConfigKeys() {
+	for _, example := range []string{
+		FILER_TOML_EXAMPLE,
+		NOTIFICATION_TOML_EXAMPLE,
+		REPLICATION_TOML_EXAMPLE,
+		SECURITY_TOML_EXAMPLE,
+		MASTER_TOML_EXAMPLE,
+		PROCESS_TOML_EXAMPLE,
+		SHELL_TOML_EXAMPLE,
+	} {
+		v := viper.New()
+		v.SetConfigType("toml")
+		if err := v.ReadConfig(strings.NewReader(example)); err != nil {
+			glog.Errorf("parse example configuration: %v", err)
+			continue
+		}
+		keys := make(map[string]interface{})
+		for _, key := range v.AllKeys() {
+			keys[key] = v.Get(key)
+		}
+		util.AddKnownConfigKeys(keys)
+	}
+}
+
+// checkEnvOverrides warns about the "WEED_" environment variables not matching any *.toml option, or with a wrong type.
+func checkEnvOverrides() {
+	registerConfigKeys()
+	for _, problem := range util.CheckEnvOverrides(os.Environ()) {
+		glog.Warningf("environment variable %s", problem)
+	}
+}
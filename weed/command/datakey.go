@@ -0,0 +1,57 @@
+package command
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/chrislusf/seaweedfs/weed/kms"
+	_ "github.com/chrislusf/seaweedfs/weed/kms/aws_kms"
+	_ "github.com/chrislusf/seaweedfs/weed/kms/static"
+	_ "github.com/chrislusf/seaweedfs/weed/kms/vault"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func init() {
+	cmdDataKey.Run = runDataKey // break init cycle
+}
+
+var cmdDataKey = &Command{
+	UsageLine: "datakey",
+	Short:     "generate a data key for volume at rest encryption",
+	Long: `generate a data key wrapped by the kms configured in security.toml
+
+	The output is the wrapped data key, to be added to the [volume.encryption.collections] section in security.toml:
+
+		[volume.encryption.collections]
+		"*" = "<wrapped data key>"
+
+	The same security.toml should be used by all volume servers.
+	Losing the data key or the kms key means losing all data encrypted by it.
+
+  `,
+}
+
+func runDataKey(cmd *Command, args []string) bool {
+
+	util.LoadConfiguration("security", true)
+
+	km, err := kms.LoadKeyManager(util.GetViper())
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return false
+	}
+	if km == nil {
+		fmt.Printf("no kms is enabled in security.toml\n")
+		return false
+	}
+
+	_, wrappedKey, err := km.GenerateDataKey()
+	if err != nil {
+		fmt.Printf("generate data key: %v\n", err)
+		return false
+	}
+
+	fmt.Println(base64.StdEncoding.EncodeToString(wrappedKey))
+
+	return true
+}
@@ -50,6 +50,7 @@ type FilerOptions struct {
 	saveToFilerLimit        *int
 	defaultLevelDbDirectory *string
 	concurrentUploadLimitMB *int
+	whiteList               *string
 }
 
 func init() {
@@ -73,15 +74,19 @@ func init() {
 	f.saveToFilerLimit = cmdFiler.Flag.Int("saveToFilerLimit", 0, "files smaller than this limit will be saved in filer store")
 	f.defaultLevelDbDirectory = cmdFiler.Flag.String("defaultStoreDir", ".", "if filer.toml is empty, use an embedded filer store in the directory")
 	f.concurrentUploadLimitMB = cmdFiler.Flag.Int("concurrentUploadLimitMB", 128, "limit total concurrent upload size")
+	f.whiteList = cmdFiler.Flag.String("whiteList", "", "comma separated Ip addresses having admin permission, e.g. to read the configuration. No limit if empty.")
 
 	// start s3 on filer
 	filerStartS3 = cmdFiler.Flag.Bool("s3", false, "whether to start S3 gateway")
 	filerS3Options.port = cmdFiler.Flag.Int("s3.port", 8333, "s3 server http listen port")
 	filerS3Options.domainName = cmdFiler.Flag.String("s3.domainName", "", "suffix of the host name in comma separated list, {bucket}.{domainName}")
+	filerS3Options.websiteDomainName = cmdFiler.Flag.String("s3.websiteDomainName", "", "suffix of the host name of the static websites in comma separated list, {bucket}.{websiteDomainName}")
 	filerS3Options.tlsPrivateKey = cmdFiler.Flag.String("s3.key.file", "", "path to the TLS private key file")
 	filerS3Options.tlsCertificate = cmdFiler.Flag.String("s3.cert.file", "", "path to the TLS certificate file")
 	filerS3Options.config = cmdFiler.Flag.String("s3.config", "", "path to the config file")
 	filerS3Options.allowEmptyFolder = cmdFiler.Flag.Bool("s3.allowEmptyFolder", false, "allow empty folders")
+	filerS3Options.prefetch = cmdFiler.Flag.Bool("s3.prefetch", false, "load the buckets and identities from the filer before serving, and subscribe to their changes")
+	filerS3Options.blockPublic = cmdFiler.Flag.Bool("s3.blockPublicAccess", false, "deny the anonymous access granted by the public buckets and the bucket policies")
 
 	// start webdav on filer
 	filerStartWebDav = cmdFiler.Flag.Bool("webdav", false, "whether to start webdav gateway")
@@ -124,6 +129,7 @@ var cmdFiler = &Command{
 func runFiler(cmd *Command, args []string) bool {
 
 	util.LoadConfiguration("security", false)
+	checkEnvOverrides()
 
 	go stats_collect.StartMetricsServer(*f.metricsHttpPort)
 
@@ -177,6 +183,11 @@ func (fo *FilerOptions) startFiler() {
 		peers = strings.Split(*fo.peers, ",")
 	}
 
+	var whiteList []string
+	if *fo.whiteList != "" {
+		whiteList = strings.Split(*fo.whiteList, ",")
+	}
+
 	fs, nfs_err := weed_server.NewFilerServer(defaultMux, publicVolumeMux, &weed_server.FilerOption{
 		Masters:               strings.Split(*fo.masters, ","),
 		Collection:            *fo.collection,
@@ -194,6 +205,7 @@ func (fo *FilerOptions) startFiler() {
 		SaveToFilerLimit:      int64(*fo.saveToFilerLimit),
 		Filers:                peers,
 		ConcurrentUploadLimit: int64(*fo.concurrentUploadLimitMB) * 1024 * 1024,
+		WhiteList:             whiteList,
 	})
 	if nfs_err != nil {
 		glog.Fatalf("Filer startup error: %v", nfs_err)
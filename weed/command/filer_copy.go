@@ -2,6 +2,7 @@ package command
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -12,6 +13,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"google.golang.org/grpc"
@@ -24,6 +26,7 @@ import (
 	"github.com/chrislusf/seaweedfs/weed/security"
 	"github.com/chrislusf/seaweedfs/weed/storage/needle"
 	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/chrislusf/seaweedfs/weed/util/chunker"
 	"github.com/chrislusf/seaweedfs/weed/wdclient"
 )
 
@@ -46,6 +49,11 @@ type CopyOptions struct {
 	masters           []string
 	cipher            bool
 	ttlSec            int32
+	dedup             *bool
+	batchEntries      *int
+	filerGrpcAddress  string
+	entryBatcher      *filer_pb.EntryBatcher
+	failedFiles       int64 // the files failed to copy, set with atomic
 }
 
 func init() {
@@ -59,6 +67,8 @@ func init() {
 	copy.maxMB = cmdCopy.Flag.Int("maxMB", 4, "split files larger than the limit")
 	copy.concurrenctFiles = cmdCopy.Flag.Int("c", 8, "concurrent file copy goroutines")
 	copy.concurrenctChunks = cmdCopy.Flag.Int("concurrentChunks", 8, "concurrent chunk copy goroutines for each file")
+	copy.dedup = cmdCopy.Flag.Bool("dedup", false, "split the files by content, and only upload the chunks not stored yet")
+	copy.batchEntries = cmdCopy.Flag.Int("batchEntries", 100, "create the file entries in batches of this size, at most 1024. 1 to create each entry after its upload")
 }
 
 var cmdCopy = &Command{
@@ -74,6 +84,11 @@ var cmdCopy = &Command{
 
   If "maxMB" is set to a positive number, files larger than it would be split into chunks.
 
+  If "dedup" is set, files are split by content into chunks of "maxMB"/4 on average, and the chunks
+  already stored in the same collection are referenced instead of uploaded again. The files with ttl are not deduped.
+
+  The file entries are created on the filer in batches of "batchEntries", to copy many small files faster.
+
 `,
 }
 
@@ -141,6 +156,10 @@ func runCopy(cmd *Command, args []string) bool {
 	}
 	copy.masters = masters
 	copy.cipher = cipher
+	copy.filerGrpcAddress = filerGrpcAddress
+	if *copy.batchEntries > 1 {
+		copy.entryBatcher = filer_pb.NewEntryBatcher(&copy, *copy.batchEntries, time.Second)
+	}
 
 	ttl, err := needle.ReadTTL(*copy.ttl)
 	if err != nil {
@@ -174,12 +193,21 @@ func runCopy(cmd *Command, args []string) bool {
 				filerGrpcAddress: filerGrpcAddress,
 			}
 			if err := worker.copyFiles(fileCopyTaskChan); err != nil {
+				atomic.AddInt64(&copy.failedFiles, 1)
 				fmt.Fprintf(os.Stderr, "copy file error: %v\n", err)
 				return
 			}
 		}()
 	}
 	waitGroup.Wait()
+	if copy.entryBatcher != nil {
+		copy.entryBatcher.Flush()
+	}
+
+	if failedFiles := atomic.LoadInt64(&copy.failedFiles); failedFiles > 0 {
+		fmt.Fprintf(os.Stderr, "failed to copy %d files\n", failedFiles)
+		os.Exit(1)
+	}
 
 	return true
 }
@@ -193,6 +221,7 @@ func readFilerConfiguration(grpcDialOption grpc.DialOption, filerGrpcAddress str
 		masters, collection, replication, maxMB = resp.Masters, resp.Collection, resp.Replication, resp.MaxMb
 		dirBuckets = resp.DirBuckets
 		cipher = resp.Cipher
+		util.SetCompressionPolicy(resp.CompressionPolicy.ToCompressionPolicy())
 		return nil
 	})
 	return
@@ -282,6 +311,10 @@ func (worker *FileCopyWorker) doEachCopy(task FileCopyTask) error {
 		chunkCount = int(task.fileSize/chunkSize) + 1
 	}
 
+	if *worker.options.dedup && worker.options.ttlSec == 0 && task.fileSize > 0 {
+		return worker.uploadFileWithDedup(task, f)
+	}
+
 	if chunkCount == 1 {
 		return worker.uploadFileAsOne(task, f)
 	}
@@ -342,40 +375,29 @@ func (worker *FileCopyWorker) uploadFileAsOne(task FileCopyTask, f *os.File) err
 		fmt.Printf("uploaded %s to %s\n", fileName, targetUrl)
 
 		chunks = append(chunks, uploadResult.ToPbFileChunk(assignResult.FileId, 0))
-
-		fmt.Printf("copied %s => http://%s%s%s\n", fileName, worker.filerHost, task.destinationUrlPath, fileName)
 	}
 
-	if err := pb.WithGrpcFilerClient(worker.filerGrpcAddress, worker.options.grpcDialOption, func(client filer_pb.SeaweedFilerClient) error {
-		request := &filer_pb.CreateEntryRequest{
-			Directory: task.destinationUrlPath,
-			Entry: &filer_pb.Entry{
-				Name: fileName,
-				Attributes: &filer_pb.FuseAttributes{
-					Crtime:      time.Now().Unix(),
-					Mtime:       time.Now().Unix(),
-					Gid:         task.gid,
-					Uid:         task.uid,
-					FileSize:    uint64(task.fileSize),
-					FileMode:    uint32(task.fileMode),
-					Mime:        mimeType,
-					Replication: *worker.options.replication,
-					Collection:  *worker.options.collection,
-					TtlSec:      worker.options.ttlSec,
-				},
-				Chunks: chunks,
+	request := &filer_pb.CreateEntryRequest{
+		Directory: task.destinationUrlPath,
+		Entry: &filer_pb.Entry{
+			Name: fileName,
+			Attributes: &filer_pb.FuseAttributes{
+				Crtime:      time.Now().Unix(),
+				Mtime:       time.Now().Unix(),
+				Gid:         task.gid,
+				Uid:         task.uid,
+				FileSize:    uint64(task.fileSize),
+				FileMode:    uint32(task.fileMode),
+				Mime:        mimeType,
+				Replication: *worker.options.replication,
+				Collection:  *worker.options.collection,
+				TtlSec:      worker.options.ttlSec,
 			},
-		}
-
-		if err := filer_pb.CreateEntry(client, request); err != nil {
-			return fmt.Errorf("update fh: %v", err)
-		}
-		return nil
-	}); err != nil {
-		return fmt.Errorf("upload data %v to http://%s%s%s: %v\n", fileName, worker.filerHost, task.destinationUrlPath, fileName, err)
+			Chunks: chunks,
+		},
 	}
 
-	return nil
+	return worker.createEntry(task, fileName, request)
 }
 
 func (worker *FileCopyWorker) uploadFileInChunks(task FileCopyTask, f *os.File, chunkCount int, chunkSize int64) error {
@@ -469,40 +491,193 @@ func (worker *FileCopyWorker) uploadFileInChunks(task FileCopyTask, f *os.File,
 		return uploadError
 	}
 
-	if err := pb.WithGrpcFilerClient(worker.filerGrpcAddress, worker.options.grpcDialOption, func(client filer_pb.SeaweedFilerClient) error {
-		request := &filer_pb.CreateEntryRequest{
-			Directory: task.destinationUrlPath,
-			Entry: &filer_pb.Entry{
-				Name: fileName,
-				Attributes: &filer_pb.FuseAttributes{
-					Crtime:      time.Now().Unix(),
-					Mtime:       time.Now().Unix(),
-					Gid:         task.gid,
-					Uid:         task.uid,
-					FileSize:    uint64(task.fileSize),
-					FileMode:    uint32(task.fileMode),
-					Mime:        mimeType,
-					Replication: replication,
-					Collection:  collection,
-					TtlSec:      worker.options.ttlSec,
-				},
-				Chunks: chunks,
+	request := &filer_pb.CreateEntryRequest{
+		Directory: task.destinationUrlPath,
+		Entry: &filer_pb.Entry{
+			Name: fileName,
+			Attributes: &filer_pb.FuseAttributes{
+				Crtime:      time.Now().Unix(),
+				Mtime:       time.Now().Unix(),
+				Gid:         task.gid,
+				Uid:         task.uid,
+				FileSize:    uint64(task.fileSize),
+				FileMode:    uint32(task.fileMode),
+				Mime:        mimeType,
+				Replication: replication,
+				Collection:  collection,
+				TtlSec:      worker.options.ttlSec,
 			},
+			Chunks: chunks,
+		},
+	}
+
+	return worker.createEntry(task, fileName, request)
+}
+
+// uploadFileWithDedup splits the file by content, and only uploads the chunks not stored yet.
+// On failures, the chunks already referenced are kept, since they may be used by other files.
+func (worker *FileCopyWorker) uploadFileWithDedup(task FileCopyTask, f *os.File) error {
+
+	fileName := filepath.Base(f.Name())
+	mimeType := detectMimeType(f)
+	destinationPath := task.destinationUrlPath + fileName
+
+	var chunks []*filer_pb.FileChunk
+	var dedupedCount int
+	collection, replication := *worker.options.collection, *worker.options.replication
+
+	contentChunker := chunker.New(f, chunker.DefaultOption(*worker.options.maxMB*1024*1024))
+	err := pb.WithGrpcFilerClient(worker.filerGrpcAddress, worker.options.grpcDialOption, func(client filer_pb.SeaweedFilerClient) error {
+		var offset int64
+		for {
+			data, err := contentChunker.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("read %s: %v", f.Name(), err)
+			}
+			contentSha256 := sha256.Sum256(data)
+
+			chunk, err := worker.dedupChunk(client, destinationPath, contentSha256[:], nil)
+			if err != nil {
+				return err
+			}
+			if chunk != nil {
+				dedupedCount++
+			} else {
+				assignResult, err := worker.assignChunk(client, destinationPath)
+				if err != nil {
+					return err
+				}
+				if collection == "" {
+					collection = assignResult.Collection
+				}
+				if replication == "" {
+					replication = assignResult.Replication
+				}
+				targetUrl := "http://" + assignResult.Url + "/" + assignResult.FileId
+				uploadResult, err := operation.UploadData(targetUrl, fileName+"-"+strconv.Itoa(len(chunks)+1), worker.options.cipher, data, false, "", nil, security.EncodedJwt(assignResult.Auth))
+				if err != nil {
+					return fmt.Errorf("upload data %v to %s: %v", fileName, targetUrl, err)
+				}
+				if uploadResult.Error != "" {
+					return fmt.Errorf("upload %v to %s result: %v", fileName, targetUrl, uploadResult.Error)
+				}
+				uploaded := uploadResult.ToPbFileChunk(assignResult.FileId, offset)
+				if chunk, err = worker.dedupChunk(client, destinationPath, contentSha256[:], uploaded); err != nil {
+					return err
+				}
+				if chunk.GetFileIdString() != uploaded.GetFileIdString() {
+					// the same content was uploaded concurrently
+					operation.DeleteFiles(func() string {
+						return copy.masters[0]
+					}, false, worker.options.grpcDialOption, []string{assignResult.FileId})
+				}
+			}
+			chunk.Offset = offset
+			chunks = append(chunks, chunk)
+			offset += int64(len(data))
 		}
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("uploaded %s in %d chunks, %d deduped\n", fileName, len(chunks), dedupedCount)
+
+	request := &filer_pb.CreateEntryRequest{
+		Directory: task.destinationUrlPath,
+		Entry: &filer_pb.Entry{
+			Name: fileName,
+			Attributes: &filer_pb.FuseAttributes{
+				Crtime:      time.Now().Unix(),
+				Mtime:       time.Now().Unix(),
+				Gid:         task.gid,
+				Uid:         task.uid,
+				FileSize:    uint64(task.fileSize),
+				FileMode:    uint32(task.fileMode),
+				Mime:        mimeType,
+				Replication: replication,
+				Collection:  collection,
+			},
+			Chunks: chunks,
+		},
+	}
 
-		if err := filer_pb.CreateEntry(client, request); err != nil {
-			return fmt.Errorf("update fh: %v", err)
+	return worker.createEntry(task, fileName, request)
+}
+
+// createEntry creates the file entry, in batches with the entries of other files if batchEntries is more than 1.
+// The batched entries are reported after being created, since the next files are already being copied,
+// and their failures are counted in failedFiles.
+func (worker *FileCopyWorker) createEntry(task FileCopyTask, fileName string, request *filer_pb.CreateEntryRequest) error {
+	copied := func(err error) error {
+		if err != nil {
+			return fmt.Errorf("upload data %v to http://%s%s%s: %v", fileName, worker.filerHost, task.destinationUrlPath, fileName, err)
 		}
+		fmt.Printf("copied %s => http://%s%s%s\n", fileName, worker.filerHost, task.destinationUrlPath, fileName)
 		return nil
-	}); err != nil {
-		return fmt.Errorf("upload data %v to http://%s%s%s: %v\n", fileName, worker.filerHost, task.destinationUrlPath, fileName, err)
 	}
 
-	fmt.Printf("copied %s => http://%s%s%s\n", fileName, worker.filerHost, task.destinationUrlPath, fileName)
+	if worker.options.entryBatcher == nil {
+		return copied(worker.options.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+			return filer_pb.CreateEntry(client, request)
+		}))
+	}
 
+	worker.options.entryBatcher.Add(request, func(err error) {
+		if err = copied(err); err != nil {
+			atomic.AddInt64(&worker.options.failedFiles, 1)
+			fmt.Fprintf(os.Stderr, "copy file error: %v\n", err)
+		}
+	})
 	return nil
 }
 
+// dedupChunk references the stored chunk with the content sha256, or registers the uploaded chunk
+func (worker *FileCopyWorker) dedupChunk(client filer_pb.SeaweedFilerClient, path string, contentSha256 []byte, uploaded *filer_pb.FileChunk) (*filer_pb.FileChunk, error) {
+	request := &filer_pb.DedupChunksRequest{
+		Path:        path,
+		Collection:  *worker.options.collection,
+		Replication: *worker.options.replication,
+		DiskType:    *worker.options.diskType,
+		Chunks: []*filer_pb.DedupChunk{{
+			ContentSha256: contentSha256,
+			Chunk:         uploaded,
+		}},
+	}
+	resp, err := client.DedupChunks(context.Background(), request)
+	if err != nil {
+		return nil, fmt.Errorf("dedup chunks of %s: %v", path, err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("dedup chunks of %s: %v", path, resp.Error)
+	}
+	if len(resp.Chunks) != 1 {
+		return nil, fmt.Errorf("dedup chunks of %s: unexpected %d chunks", path, len(resp.Chunks))
+	}
+	return resp.Chunks[0].Chunk, nil
+}
+
+func (worker *FileCopyWorker) assignChunk(client filer_pb.SeaweedFilerClient, path string) (*filer_pb.AssignVolumeResponse, error) {
+	request := &filer_pb.AssignVolumeRequest{
+		Count:       1,
+		Replication: *worker.options.replication,
+		Collection:  *worker.options.collection,
+		DiskType:    *worker.options.diskType,
+		Path:        path,
+	}
+	assignResult, err := client.AssignVolume(context.Background(), request)
+	if err != nil {
+		return nil, fmt.Errorf("assign volume failure %v: %v", request, err)
+	}
+	if assignResult.Error != "" {
+		return nil, fmt.Errorf("assign volume failure %v: %v", request, assignResult.Error)
+	}
+	return assignResult, nil
+}
+
 func detectMimeType(f *os.File) string {
 	head := make([]byte, 512)
 	f.Seek(0, io.SeekStart)
@@ -521,3 +696,13 @@ func detectMimeType(f *os.File) string {
 	}
 	return mimeType
 }
+
+var _ = filer_pb.FilerClient(&CopyOptions{})
+
+func (option *CopyOptions) WithFilerClient(fn func(filer_pb.SeaweedFilerClient) error) error {
+	return pb.WithGrpcFilerClient(option.filerGrpcAddress, option.grpcDialOption, fn)
+}
+
+func (option *CopyOptions) AdjustedUrl(location *filer_pb.Location) string {
+	return location.Url
+}
@@ -0,0 +1,256 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+	"google.golang.org/grpc"
+
+	"github.com/chrislusf/seaweedfs/weed/filer/search"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/security"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/chrislusf/seaweedfs/weed/util/grace"
+)
+
+type FilerIndexOptions struct {
+	grpcDialOption grpc.DialOption
+	filer          *string
+	filerDir       *string
+	esServers      *string
+	esIndex        *string
+	esUsername     *string
+	esPassword     *string
+	port           *int
+	whiteList      *string
+	restart        *bool
+
+	// the progress to save on shutdown
+	progressLock sync.Mutex
+	indexer      *search.Indexer
+	indexId      int32
+	lastTsNs     int64
+}
+
+var (
+	filerIndexOptions FilerIndexOptions
+)
+
+func init() {
+	cmdFilerIndex.Run = runFilerIndex // break init cycle
+	filerIndexOptions.filer = cmdFilerIndex.Flag.String("filer", "localhost:8888", "filer hostname:port")
+	filerIndexOptions.filerDir = cmdFilerIndex.Flag.String("filerDir", "/", "a folder on the filer to index")
+	filerIndexOptions.esServers = cmdFilerIndex.Flag.String("es", "http://localhost:9200", "comma-separated elastic servers http://<host:port>")
+	filerIndexOptions.esIndex = cmdFilerIndex.Flag.String("es.index", "seaweedfs_files", "ES index name")
+	filerIndexOptions.esUsername = cmdFilerIndex.Flag.String("es.username", "", "ES basic auth user name")
+	filerIndexOptions.esPassword = cmdFilerIndex.Flag.String("es.password", "", "ES basic auth password")
+	filerIndexOptions.port = cmdFilerIndex.Flag.Int("port", 0, "serve the search http endpoint on this port, if not 0")
+	filerIndexOptions.whiteList = cmdFilerIndex.Flag.String("whiteList", "", "comma separated Ip addresses allowed to search. No limit if empty.")
+	filerIndexOptions.restart = cmdFilerIndex.Flag.Bool("restart", false, "index all the entries again before indexing the changes")
+}
+
+var cmdFilerIndex = &Command{
+	UsageLine: "filer.index [-filer=localhost:8888] [-filerDir=/] [-es=http://localhost:9200] [-es.index=seaweedfs_files] [-port=8890]",
+	Short:     "continuously index the filer meta data into Elasticsearch or OpenSearch, to search the files",
+	Long: `continuously index the filer meta data into Elasticsearch or OpenSearch, to search the files.
+
+	The name, path, size, mtime, mime type, and extended attributes of each entry are indexed.
+	At the first run, or with -restart, all the entries are indexed, then the changes are indexed as they happen.
+	The progress is saved on the filer every few seconds and on shutdown, and a restart resumes from there.
+
+	The index is created with the mapping for the search if it does not exist.
+	Please use a new index, not the one written by "weed filer.meta.tail -es".
+
+	With -port, the search http endpoint is also served, only to the -whiteList addresses if set:
+
+	curl "http://localhost:8890/search?path=/home&name=*.pdf&minSize=1048576&modifiedAfter=2021-06-01T00:00:00Z"
+	curl "http://localhost:8890/search?mime=image/&xattr=project=apollo&type=file&limit=100&after=/home/a.jpg"
+
+	The results are sorted by path. To get the next page, set "after" to the last path.
+	See also "weed filer.search".
+
+`,
+}
+
+func runFilerIndex(cmd *Command, args []string) bool {
+
+	filerIndexOptions.grpcDialOption = security.LoadClientTLS(util.GetViper(), "grpc.client")
+
+	client, err := search.NewClient(*filerIndexOptions.esServers, *filerIndexOptions.esUsername, *filerIndexOptions.esPassword)
+	if err != nil {
+		glog.Errorf("create elastic search client to %s: %v", *filerIndexOptions.esServers, err)
+		return true
+	}
+
+	grace.OnInterrupt(filerIndexOptions.saveProgress)
+
+	if *filerIndexOptions.port != 0 {
+		var whiteList []string
+		if *filerIndexOptions.whiteList != "" {
+			whiteList = strings.Split(*filerIndexOptions.whiteList, ",")
+		}
+		guard := security.NewGuard(whiteList, "", 0, "", 0)
+		http.HandleFunc("/search", guard.WhiteList(search.NewSearchHandler(client, *filerIndexOptions.esIndex)))
+		go func() {
+			listenAddress := fmt.Sprintf(":%d", *filerIndexOptions.port)
+			glog.V(0).Infof("Start the search http endpoint at %s", listenAddress)
+			if err := http.ListenAndServe(listenAddress, nil); err != nil {
+				glog.Fatalf("search http endpoint on %s: %v", listenAddress, err)
+			}
+		}()
+	}
+
+	for {
+		err := filerIndexOptions.doFilerIndex(client)
+		if err != nil {
+			glog.Errorf("index %s: %v", *filerIndexOptions.filer, err)
+			time.Sleep(1747 * time.Millisecond)
+		}
+	}
+}
+
+const (
+	IndexKeyPrefix = "index."
+)
+
+func (option *FilerIndexOptions) doFilerIndex(client *elastic.Client) error {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	indexer, err := search.NewIndexer(ctx, client, *option.esIndex)
+	if err != nil {
+		return err
+	}
+	indexId := int32(util.HashStringToLong(*option.esServers + *option.esIndex + *option.filerDir))
+	option.setProgress(indexer, indexId, 0)
+	defer func() {
+		option.setProgress(nil, 0, 0)
+		if closeErr := indexer.Close(); closeErr != nil {
+			glog.Errorf("close indexer: %v", closeErr)
+		}
+	}()
+
+	lastOffsetTsNs, err := getOffset(option.grpcDialOption, *option.filer, IndexKeyPrefix, indexId)
+	if err != nil {
+		return fmt.Errorf("read offset: %v", err)
+	}
+
+	if lastOffsetTsNs == 0 || *option.restart {
+		startTime := time.Now()
+		glog.V(0).Infof("indexing all entries under %s ...", *option.filerDir)
+		if err = option.indexAll(indexer); err != nil {
+			return err
+		}
+		if err = setOffset(option.grpcDialOption, *option.filer, IndexKeyPrefix, indexId, startTime.UnixNano()); err != nil {
+			return fmt.Errorf("setOffset: %v", err)
+		}
+		lastOffsetTsNs = startTime.UnixNano()
+		*option.restart = false
+	}
+	glog.V(0).Infof("indexing changes since %v", time.Unix(0, lastOffsetTsNs))
+
+	return pb.WithFilerClient(*option.filer, option.grpcDialOption, func(client filer_pb.SeaweedFilerClient) error {
+
+		stream, err := client.SubscribeMetadata(ctx, &filer_pb.SubscribeMetadataRequest{
+			ClientName: "index",
+			PathPrefix: *option.filerDir,
+			SinceNs:    lastOffsetTsNs,
+		})
+		if err != nil {
+			return fmt.Errorf("listen: %v", err)
+		}
+
+		var counter int64
+		var lastWriteTime time.Time
+		for {
+			resp, listenErr := stream.Recv()
+			if listenErr == io.EOF {
+				return nil
+			}
+			if listenErr != nil {
+				return listenErr
+			}
+
+			if err := indexer.ApplyEvent(ctx, resp); err != nil {
+				return err
+			}
+			option.setProgress(indexer, indexId, resp.TsNs)
+
+			counter++
+			if lastWriteTime.Add(3 * time.Second).Before(time.Now()) {
+				// only the changes already indexed are skipped after a restart
+				if err := indexer.Flush(); err != nil {
+					return err
+				}
+				glog.V(0).Infof("index %s progressed to %v %0.2f/sec", *option.filer, time.Unix(0, resp.TsNs), float64(counter)/float64(3))
+				counter = 0
+				lastWriteTime = time.Now()
+				if err := setOffset(option.grpcDialOption, *option.filer, IndexKeyPrefix, indexId, resp.TsNs); err != nil {
+					return fmt.Errorf("setOffset: %v", err)
+				}
+			}
+		}
+
+	})
+}
+
+func (option *FilerIndexOptions) setProgress(indexer *search.Indexer, indexId int32, lastTsNs int64) {
+	option.progressLock.Lock()
+	defer option.progressLock.Unlock()
+	option.indexer, option.indexId, option.lastTsNs = indexer, indexId, lastTsNs
+}
+
+// saveProgress writes the pending documents, and saves the offset of the last indexed change.
+func (option *FilerIndexOptions) saveProgress() {
+	option.progressLock.Lock()
+	defer option.progressLock.Unlock()
+	if option.indexer == nil {
+		return
+	}
+	if err := option.indexer.Flush(); err != nil {
+		glog.Errorf("flush index: %v", err)
+		return
+	}
+	if option.lastTsNs == 0 {
+		return
+	}
+	if err := setOffset(option.grpcDialOption, *option.filer, IndexKeyPrefix, option.indexId, option.lastTsNs); err != nil {
+		glog.Errorf("setOffset: %v", err)
+	}
+}
+
+func (option *FilerIndexOptions) indexAll(indexer *search.Indexer) error {
+	var entryCount int64
+	traverseErr := filer_pb.TraverseBfs(option, util.FullPath(*option.filerDir), func(parentPath util.FullPath, entry *filer_pb.Entry) {
+		indexer.IndexEntry(string(parentPath), entry)
+		atomic.AddInt64(&entryCount, 1)
+	})
+	if traverseErr != nil {
+		return fmt.Errorf("traverse: %v", traverseErr)
+	}
+	if err := indexer.Flush(); err != nil {
+		return err
+	}
+	glog.V(0).Infof("indexed %d entries under %s", entryCount, *option.filerDir)
+	return nil
+}
+
+var _ = filer_pb.FilerClient(&FilerIndexOptions{})
+
+func (option *FilerIndexOptions) WithFilerClient(fn func(filer_pb.SeaweedFilerClient) error) error {
+	return pb.WithFilerClient(*option.filer, option.grpcDialOption, fn)
+}
+
+func (option *FilerIndexOptions) AdjustedUrl(location *filer_pb.Location) string {
+	return location.Url
+}
@@ -4,14 +4,13 @@ import (
 	"context"
 	"fmt"
 	"github.com/golang/protobuf/jsonpb"
-	jsoniter "github.com/json-iterator/go"
-	"github.com/olivere/elastic/v7"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/chrislusf/seaweedfs/weed/filer/search"
 	"github.com/chrislusf/seaweedfs/weed/pb"
 	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
 	"github.com/chrislusf/seaweedfs/weed/security"
@@ -30,6 +29,7 @@ var cmdFilerMetaTail = &Command{
 	weed filer.meta.tail -timeAgo=30h | grep truncate
 	weed filer.meta.tail -timeAgo=30h | jq .
 	weed filer.meta.tail -timeAgo=30h | jq .eventNotification.newEntry.name
+	weed filer.meta.tail -eventTypes=create,rename -minSize=1048576
 
   `,
 }
@@ -39,6 +39,8 @@ var (
 	tailTarget  = cmdFilerMetaTail.Flag.String("pathPrefix", "/", "path to a folder or common prefix for the folders or files on filer")
 	tailStart   = cmdFilerMetaTail.Flag.Duration("timeAgo", 0, "start time before now. \"300ms\", \"1.5h\" or \"2h45m\". Valid time units are \"ns\", \"us\" (or \"µs\"), \"ms\", \"s\", \"m\", \"h\"")
 	tailPattern = cmdFilerMetaTail.Flag.String("pattern", "", "full path or just filename pattern, ex: \"/home/?opher\", \"*.pdf\", see https://golang.org/pkg/path/filepath/#Match ")
+	tailEvents  = cmdFilerMetaTail.Flag.String("eventTypes", "", "comma-separated event types to see, of create, update, delete, rename, all if empty")
+	tailMinSize = cmdFilerMetaTail.Flag.Int64("minSize", 0, "only see the changes of the directories and the files at least this size in bytes")
 	esServers   = cmdFilerMetaTail.Flag.String("es", "", "comma-separated elastic servers http://<host:port>")
 	esIndex     = cmdFilerMetaTail.Flag.String("es.index", "seaweedfs", "ES index name")
 )
@@ -108,10 +110,16 @@ func runFilerMetaTail(cmd *Command, args []string) bool {
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
+		var eventTypes []string
+		if *tailEvents != "" {
+			eventTypes = strings.Split(*tailEvents, ",")
+		}
 		stream, err := client.SubscribeMetadata(ctx, &filer_pb.SubscribeMetadataRequest{
-			ClientName: "tail",
-			PathPrefix: *tailTarget,
-			SinceNs:    time.Now().Add(-*tailStart).UnixNano(),
+			ClientName:  "tail",
+			PathPrefix:  *tailTarget,
+			SinceNs:     time.Now().Add(-*tailStart).UnixNano(),
+			EventTypes:  eventTypes,
+			MinFileSize: *tailMinSize,
 		})
 		if err != nil {
 			return fmt.Errorf("listen: %v", err)
@@ -141,71 +149,16 @@ func runFilerMetaTail(cmd *Command, args []string) bool {
 	return true
 }
 
-type EsDocument struct {
-	Dir         string `json:"dir,omitempty"`
-	Name        string `json:"name,omitempty"`
-	IsDirectory bool   `json:"isDir,omitempty"`
-	Size        uint64 `json:"size,omitempty"`
-	Uid         uint32 `json:"uid,omitempty"`
-	Gid         uint32 `json:"gid,omitempty"`
-	UserName    string `json:"userName,omitempty"`
-	Collection  string `json:"collection,omitempty"`
-	Crtime      int64  `json:"crtime,omitempty"`
-	Mtime       int64  `json:"mtime,omitempty"`
-	Mime        string `json:"mime,omitempty"`
-}
-
-func toEsEntry(event *filer_pb.EventNotification) (*EsDocument, string) {
-	entry := event.NewEntry
-	dir, name := event.NewParentPath, entry.Name
-	id := util.Md5String([]byte(util.NewFullPath(dir, name)))
-	esEntry := &EsDocument{
-		Dir:         dir,
-		Name:        name,
-		IsDirectory: entry.IsDirectory,
-		Size:        entry.Attributes.FileSize,
-		Uid:         entry.Attributes.Uid,
-		Gid:         entry.Attributes.Gid,
-		UserName:    entry.Attributes.UserName,
-		Collection:  entry.Attributes.Collection,
-		Crtime:      entry.Attributes.Crtime,
-		Mtime:       entry.Attributes.Mtime,
-		Mime:        entry.Attributes.Mime,
-	}
-	return esEntry, id
-}
-
 func sendToElasticSearchFunc(servers string, esIndex string) (func(resp *filer_pb.SubscribeMetadataResponse) error, error) {
-	options := []elastic.ClientOptionFunc{}
-	options = append(options, elastic.SetURL(strings.Split(servers, ",")...))
-	options = append(options, elastic.SetSniff(false))
-	options = append(options, elastic.SetHealthcheck(false))
-	client, err := elastic.NewClient(options...)
+	client, err := search.NewClient(servers, "", "")
+	if err != nil {
+		return nil, err
+	}
+	indexer, err := search.NewIndexer(context.Background(), client, esIndex)
 	if err != nil {
 		return nil, err
 	}
 	return func(resp *filer_pb.SubscribeMetadataResponse) error {
-		event := resp.EventNotification
-		if event.OldEntry != nil &&
-			(event.NewEntry == nil || resp.Directory != event.NewParentPath || event.OldEntry.Name != event.NewEntry.Name) {
-			// delete or not update the same file
-			dir, name := resp.Directory, event.OldEntry.Name
-			id := util.Md5String([]byte(util.NewFullPath(dir, name)))
-			println("delete", id)
-			_, err := client.Delete().Index(esIndex).Id(id).Do(context.Background())
-			return err
-		}
-		if event.NewEntry != nil {
-			// add a new file or update the same file
-			esEntry, id := toEsEntry(event)
-			value, err := jsoniter.Marshal(esEntry)
-			if err != nil {
-				return err
-			}
-			println(string(value))
-			_, err = client.Index().Index(esIndex).Id(id).BodyJson(string(value)).Do(context.Background())
-			return err
-		}
-		return nil
+		return indexer.ApplyEvent(context.Background(), resp)
 	}, nil
 }
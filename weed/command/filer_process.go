@@ -0,0 +1,253 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/processing"
+	"github.com/chrislusf/seaweedfs/weed/security"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+type FilerProcessOptions struct {
+	filer       *string
+	path        *string
+	processors  *string
+	concurrency *int
+	timeAgo     *time.Duration
+}
+
+var (
+	filerProcessOptions FilerProcessOptions
+)
+
+func init() {
+	cmdFilerProcess.Run = runFilerProcess // break init cycle
+	filerProcessOptions.filer = cmdFilerProcess.Flag.String("filer", "localhost:8888", "filer of one SeaweedFS cluster")
+	filerProcessOptions.path = cmdFilerProcess.Flag.String("filerPath", "/", "directory to process the new files")
+	filerProcessOptions.processors = cmdFilerProcess.Flag.String("processors", "", "comma separated processor names in process.toml to run, empty for all enabled processors")
+	filerProcessOptions.concurrency = cmdFilerProcess.Flag.Int("concurrency", 4, "number of files processed at the same time")
+	filerProcessOptions.timeAgo = cmdFilerProcess.Flag.Duration("timeAgo", 0, "start time before now. \"300ms\", \"1.5h\" or \"2h45m\". Valid time units are \"ns\", \"us\" (or \"µs\"), \"ms\", \"s\", \"m\", \"h\"")
+}
+
+var cmdFilerProcess = &Command{
+	UsageLine: "filer.process -filer=<filerHost>:<filerPort>",
+	Short:     "resume-able continuously run the processors defined in process.toml on new files",
+	Long: `resume-able continuously run the processors defined in process.toml on new files
+
+	filer.process listens on filer notifications. For each new or changed file, it runs the matching processors,
+	e.g. thumbnailing, video transcoding, or OCR, and attaches the results to the file,
+	either as a sibling entry or as an extended attribute.
+
+	The result of each processor is recorded in the "x-seaweedfs-processed-<processor>" extended attribute of the file,
+	as "ok <time>" or "error <time>: <reason>". It can be checked with "fs.meta.cat" in "weed shell".
+
+	Multiple filer.process can run different processors by "-processors", each with its own checkpoints.
+	If restarted and "-timeAgo" is not set, the processing will resume from the previous checkpoints, persisted every few seconds.
+	To process the existing files again, just set "-timeAgo" to a high value.
+
+`,
+}
+
+func runFilerProcess(cmd *Command, args []string) bool {
+
+	util.LoadConfiguration("security", false)
+	util.LoadConfiguration("process", true)
+
+	grpcDialOption := security.LoadClientTLS(util.GetViper(), "grpc.client")
+
+	processors, err := processing.LoadProcessors(util.GetViper())
+	if err != nil {
+		glog.Fatalf("load processors: %v", err)
+	}
+	if *filerProcessOptions.processors != "" {
+		selected := make(map[string]bool)
+		for _, name := range strings.Split(*filerProcessOptions.processors, ",") {
+			selected[strings.TrimSpace(name)] = true
+		}
+		var selectedProcessors []*processing.Processor
+		for _, p := range processors {
+			if selected[p.Name] {
+				selectedProcessors = append(selectedProcessors, p)
+			}
+		}
+		processors = selectedProcessors
+	}
+	if len(processors) == 0 {
+		glog.Fatalf("no enabled processors in process.toml")
+	}
+
+	for {
+		err := doFilerProcess(grpcDialOption, &filerProcessOptions, processors)
+		if err != nil {
+			glog.Errorf("process from %s: %v", *filerProcessOptions.filer, err)
+			time.Sleep(1747 * time.Millisecond)
+		}
+	}
+}
+
+const (
+	ProcessKeyPrefix = "process."
+)
+
+func doFilerProcess(grpcDialOption grpc.DialOption, processOption *FilerProcessOptions, processors []*processing.Processor) error {
+
+	sourceFiler := *processOption.filer
+	sourcePath := *processOption.path
+	timeAgo := *processOption.timeAgo
+
+	var names []string
+	for _, p := range processors {
+		names = append(names, p.Name)
+	}
+	processorId := int32(util.HashStringToLong(strings.Join(names, ",") + sourcePath))
+
+	// get start time for the processors
+	startFrom := time.Unix(0, 0)
+	if timeAgo.Milliseconds() == 0 {
+		lastOffsetTsNs, err := getOffset(grpcDialOption, sourceFiler, ProcessKeyPrefix, processorId)
+		if err != nil {
+			glog.V(0).Infof("starting from %v", startFrom)
+		} else {
+			startFrom = time.Unix(0, lastOffsetTsNs)
+			glog.V(0).Infof("resuming from %v", startFrom)
+		}
+	} else {
+		startFrom = time.Now().Add(-timeAgo)
+		glog.V(0).Infof("start time is set to %v", startFrom)
+	}
+
+	pipeline := processing.NewPipeline(sourceFiler, grpcDialOption, processors)
+
+	return pb.WithFilerClient(sourceFiler, grpcDialOption, func(client filer_pb.SeaweedFilerClient) error {
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		stream, err := client.SubscribeMetadata(ctx, &filer_pb.SubscribeMetadataRequest{
+			ClientName: "process_" + strings.Join(names, "_"),
+			PathPrefix: sourcePath,
+			SinceNs:    startFrom.UnixNano(),
+		})
+		if err != nil {
+			return fmt.Errorf("listen: %v", err)
+		}
+
+		offsets := newProcessingOffsets(startFrom.UnixNano())
+		var wg sync.WaitGroup
+		defer wg.Wait()
+		limit := make(chan struct{}, *processOption.concurrency)
+		errs := make(chan error, 1)
+
+		var counter int64
+		var lastWriteTime time.Time
+		for {
+			resp, listenErr := stream.Recv()
+
+			if listenErr == io.EOF {
+				return nil
+			}
+			if listenErr != nil {
+				return listenErr
+			}
+
+			if fullpath, entry := processing.NewFile(resp); entry != nil {
+				offsets.start(resp.TsNs)
+				limit <- struct{}{}
+				wg.Add(1)
+				go func(tsNs int64) {
+					defer func() {
+						offsets.done(tsNs)
+						<-limit
+						wg.Done()
+					}()
+					if err := pipeline.Process(ctx, fullpath, entry); err != nil {
+						select {
+						case errs <- err:
+						default:
+						}
+					}
+				}(resp.TsNs)
+			} else {
+				offsets.skip(resp.TsNs)
+			}
+
+			select {
+			case err := <-errs:
+				return err
+			default:
+			}
+
+			counter++
+			if lastWriteTime.Add(3 * time.Second).Before(time.Now()) {
+				offsetTsNs := offsets.checkpoint()
+				glog.V(0).Infof("process %s progressed to %v %0.2f/sec", sourceFiler, time.Unix(0, offsetTsNs), float64(counter)/float64(3))
+				counter = 0
+				lastWriteTime = time.Now()
+				if err := setOffset(grpcDialOption, sourceFiler, ProcessKeyPrefix, processorId, offsetTsNs); err != nil {
+					return fmt.Errorf("setOffset: %v", err)
+				}
+			}
+
+		}
+
+	})
+
+}
+
+// processingOffsets tracks the events processed out of order, so the checkpoint never passes an unfinished event.
+type processingOffsets struct {
+	sync.Mutex
+	inFlight map[int64]int
+	lastTsNs int64
+}
+
+func newProcessingOffsets(startTsNs int64) *processingOffsets {
+	return &processingOffsets{
+		inFlight: make(map[int64]int),
+		lastTsNs: startTsNs,
+	}
+}
+
+func (o *processingOffsets) start(tsNs int64) {
+	o.Lock()
+	defer o.Unlock()
+	o.inFlight[tsNs]++
+	o.lastTsNs = tsNs
+}
+
+func (o *processingOffsets) done(tsNs int64) {
+	o.Lock()
+	defer o.Unlock()
+	if o.inFlight[tsNs]--; o.inFlight[tsNs] <= 0 {
+		delete(o.inFlight, tsNs)
+	}
+}
+
+func (o *processingOffsets) skip(tsNs int64) {
+	o.Lock()
+	defer o.Unlock()
+	o.lastTsNs = tsNs
+}
+
+// checkpoint is the time to resume from, before the earliest unfinished event
+func (o *processingOffsets) checkpoint() int64 {
+	o.Lock()
+	defer o.Unlock()
+	offsetTsNs := o.lastTsNs
+	for tsNs := range o.inFlight {
+		if tsNs-1 < offsetTsNs {
+			offsetTsNs = tsNs - 1
+		}
+	}
+	return offsetTsNs
+}
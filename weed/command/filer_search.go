@@ -0,0 +1,126 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer/search"
+)
+
+type FilerSearchOptions struct {
+	esServers      *string
+	esIndex        *string
+	esUsername     *string
+	esPassword     *string
+	path           *string
+	name           *string
+	mime           *string
+	minSize        *int64
+	maxSize        *int64
+	modifiedAfter  *string
+	modifiedBefore *string
+	xattrs         *string
+	entryType      *string
+	limit          *int
+	after          *string
+}
+
+var (
+	filerSearchOptions FilerSearchOptions
+)
+
+func init() {
+	cmdFilerSearch.Run = runFilerSearch // break init cycle
+	filerSearchOptions.esServers = cmdFilerSearch.Flag.String("es", "http://localhost:9200", "comma-separated elastic servers http://<host:port>")
+	filerSearchOptions.esIndex = cmdFilerSearch.Flag.String("es.index", "seaweedfs_files", "ES index name, written by filer.index")
+	filerSearchOptions.esUsername = cmdFilerSearch.Flag.String("es.username", "", "ES basic auth user name")
+	filerSearchOptions.esPassword = cmdFilerSearch.Flag.String("es.password", "", "ES basic auth password")
+	filerSearchOptions.path = cmdFilerSearch.Flag.String("path", "/", "search under this folder")
+	filerSearchOptions.name = cmdFilerSearch.Flag.String("name", "", "file name pattern, with * and ?, e.g. \"*.pdf\"")
+	filerSearchOptions.mime = cmdFilerSearch.Flag.String("mime", "", "mime type prefix, e.g. \"image/\"")
+	filerSearchOptions.minSize = cmdFilerSearch.Flag.Int64("minSize", 0, "minimum file size in bytes")
+	filerSearchOptions.maxSize = cmdFilerSearch.Flag.Int64("maxSize", 0, "maximum file size in bytes")
+	filerSearchOptions.modifiedAfter = cmdFilerSearch.Flag.String("modifiedAfter", "", "modified since this RFC3339 time, e.g. 2021-06-01T00:00:00Z")
+	filerSearchOptions.modifiedBefore = cmdFilerSearch.Flag.String("modifiedBefore", "", "modified before this RFC3339 time")
+	filerSearchOptions.xattrs = cmdFilerSearch.Flag.String("xattr", "", "comma-separated extended attributes, as key=value or key")
+	filerSearchOptions.entryType = cmdFilerSearch.Flag.String("type", "", "\"file\" or \"dir\", or empty for both")
+	filerSearchOptions.limit = cmdFilerSearch.Flag.Int("limit", search.DefaultLimit, "maximum number of results")
+	filerSearchOptions.after = cmdFilerSearch.Flag.String("after", "", "only the results after this path, to get the next page")
+}
+
+var cmdFilerSearch = &Command{
+	UsageLine: "filer.search [-es=http://localhost:9200] [-es.index=seaweedfs_files] [-path=/] [-name=*.pdf] [-minSize=1048576]",
+	Short:     "search the files indexed by filer.index",
+	Long: `search the files indexed by "weed filer.index", by name, path, size, mtime, mime type, and extended attributes.
+
+	weed filer.search -path=/home -name="*.pdf" -minSize=1048576
+	weed filer.search -mime=image/ -modifiedAfter=2021-06-01T00:00:00Z
+	weed filer.search -xattr=project=apollo -type=file -limit=1000
+
+	Each result is printed as the path, the size, and the modification time, sorted by path.
+	To get the next page, run again with -after=<the last path>.
+
+`,
+}
+
+func runFilerSearch(cmd *Command, args []string) bool {
+
+	q := &search.Query{
+		PathPrefix: *filerSearchOptions.path,
+		Name:       *filerSearchOptions.name,
+		Mime:       *filerSearchOptions.mime,
+		MinSize:    *filerSearchOptions.minSize,
+		MaxSize:    *filerSearchOptions.maxSize,
+		Type:       *filerSearchOptions.entryType,
+		Limit:      *filerSearchOptions.limit,
+		After:      *filerSearchOptions.after,
+	}
+	if *filerSearchOptions.xattrs != "" {
+		q.Xattrs = strings.Split(*filerSearchOptions.xattrs, ",")
+	}
+	var err error
+	if q.ModifiedAfter, err = parseSearchTime("modifiedAfter", *filerSearchOptions.modifiedAfter); err != nil {
+		fmt.Println(err)
+		return false
+	}
+	if q.ModifiedBefore, err = parseSearchTime("modifiedBefore", *filerSearchOptions.modifiedBefore); err != nil {
+		fmt.Println(err)
+		return false
+	}
+
+	client, err := search.NewClient(*filerSearchOptions.esServers, *filerSearchOptions.esUsername, *filerSearchOptions.esPassword)
+	if err != nil {
+		fmt.Printf("create elastic search client to %s: %v\n", *filerSearchOptions.esServers, err)
+		return true
+	}
+
+	result, err := search.Search(context.Background(), client, *filerSearchOptions.esIndex, q)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return true
+	}
+
+	for _, doc := range result.Entries {
+		path := doc.Path
+		if doc.IsDirectory {
+			path += "/"
+		}
+		fmt.Printf("%s\t%d\t%s\n", path, doc.Size, time.Unix(doc.Mtime, 0).Format(time.RFC3339))
+	}
+	fmt.Printf("%d of %d matched entries\n", len(result.Entries), result.Total)
+
+	return true
+}
+
+func parseSearchTime(name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse -%s=%s: %v", name, value, err)
+	}
+	return t, nil
+}
@@ -26,6 +26,8 @@ type SyncOptions struct {
 	filerB          *string
 	aPath           *string
 	bPath           *string
+	aExcludePaths   *string
+	bExcludePaths   *string
 	aReplication    *string
 	bReplication    *string
 	aCollection     *string
@@ -38,6 +40,7 @@ type SyncOptions struct {
 	bDebug          *bool
 	aProxyByFiler   *bool
 	bProxyByFiler   *bool
+	conflictPolicy  *string
 }
 
 var (
@@ -53,6 +56,8 @@ func init() {
 	syncOptions.filerB = cmdFilerSynchronize.Flag.String("b", "", "filer B in the other SeaweedFS cluster")
 	syncOptions.aPath = cmdFilerSynchronize.Flag.String("a.path", "/", "directory to sync on filer A")
 	syncOptions.bPath = cmdFilerSynchronize.Flag.String("b.path", "/", "directory to sync on filer B")
+	syncOptions.aExcludePaths = cmdFilerSynchronize.Flag.String("a.excludePaths", "", "comma-separated directories not to sync on filer A")
+	syncOptions.bExcludePaths = cmdFilerSynchronize.Flag.String("b.excludePaths", "", "comma-separated directories not to sync on filer B")
 	syncOptions.aReplication = cmdFilerSynchronize.Flag.String("a.replication", "", "replication on filer A")
 	syncOptions.bReplication = cmdFilerSynchronize.Flag.String("b.replication", "", "replication on filer B")
 	syncOptions.aCollection = cmdFilerSynchronize.Flag.String("a.collection", "", "collection on filer A")
@@ -65,6 +70,7 @@ func init() {
 	syncOptions.bProxyByFiler = cmdFilerSynchronize.Flag.Bool("b.filerProxy", false, "read and write file chunks by filer B instead of volume servers")
 	syncOptions.aDebug = cmdFilerSynchronize.Flag.Bool("a.debug", false, "debug mode to print out filer A received files")
 	syncOptions.bDebug = cmdFilerSynchronize.Flag.Bool("b.debug", false, "debug mode to print out filer B received files")
+	syncOptions.conflictPolicy = cmdFilerSynchronize.Flag.String("conflictPolicy", filersink.ConflictLastWriteWins, "for the files changed on both filers: "+filersink.ConflictLastWriteWins+", "+filersink.ConflictRenameLoserAside+" or "+filersink.ConflictReject)
 	syncCpuProfile = cmdFilerSynchronize.Flag.String("cpuprofile", "", "cpu profile output file")
 	syncMemProfile = cmdFilerSynchronize.Flag.String("memprofile", "", "memory profile output file")
 }
@@ -84,6 +90,14 @@ var cmdFilerSynchronize = &Command{
 	If restarted, the synchronization will resume from the previous checkpoints, persisted every minute.
 	A fresh sync will start from the earliest metadata logs.
 
+	In active-active mode, a file changed on both filers before being synced is a conflict, resolved by -conflictPolicy:
+
+	* lastWriteWins: the file with the newer mtime wins on both filers.
+	* renameLoserAside: the file with the newer mtime wins, and the other is kept as "<name>.conflict-<mtime><ext>".
+	* reject: both filers keep their own files.
+
+	A file deleted on one filer and changed on the other is kept. The conflicts are shown by "fs.sync.conflicts" in weed shell.
+
 `,
 }
 
@@ -93,10 +107,15 @@ func runFilerSynchronize(cmd *Command, args []string) bool {
 
 	grace.SetupProfiling(*syncCpuProfile, *syncMemProfile)
 
+	if err := filersink.CheckConflictPolicy(*syncOptions.conflictPolicy); err != nil {
+		glog.Errorf("filer.sync: %v", err)
+		return false
+	}
+
 	go func() {
 		for {
-			err := doSubscribeFilerMetaChanges(grpcDialOption, *syncOptions.filerA, *syncOptions.aPath, *syncOptions.aProxyByFiler, *syncOptions.filerB,
-				*syncOptions.bPath, *syncOptions.bReplication, *syncOptions.bCollection, *syncOptions.bTtlSec, *syncOptions.bProxyByFiler, *syncOptions.bDiskType, *syncOptions.bDebug)
+			err := doSubscribeFilerMetaChanges(grpcDialOption, *syncOptions.filerA, *syncOptions.aPath, splitExcludePaths(*syncOptions.aExcludePaths), *syncOptions.aProxyByFiler, *syncOptions.filerB,
+				*syncOptions.bPath, *syncOptions.bReplication, *syncOptions.bCollection, *syncOptions.bTtlSec, *syncOptions.bProxyByFiler, *syncOptions.bDiskType, *syncOptions.conflictPolicy, *syncOptions.bDebug)
 			if err != nil {
 				glog.Errorf("sync from %s to %s: %v", *syncOptions.filerA, *syncOptions.filerB, err)
 				time.Sleep(1747 * time.Millisecond)
@@ -107,8 +126,8 @@ func runFilerSynchronize(cmd *Command, args []string) bool {
 	if !*syncOptions.isActivePassive {
 		go func() {
 			for {
-				err := doSubscribeFilerMetaChanges(grpcDialOption, *syncOptions.filerB, *syncOptions.bPath, *syncOptions.bProxyByFiler, *syncOptions.filerA,
-					*syncOptions.aPath, *syncOptions.aReplication, *syncOptions.aCollection, *syncOptions.aTtlSec, *syncOptions.aProxyByFiler, *syncOptions.aDiskType, *syncOptions.aDebug)
+				err := doSubscribeFilerMetaChanges(grpcDialOption, *syncOptions.filerB, *syncOptions.bPath, splitExcludePaths(*syncOptions.bExcludePaths), *syncOptions.bProxyByFiler, *syncOptions.filerA,
+					*syncOptions.aPath, *syncOptions.aReplication, *syncOptions.aCollection, *syncOptions.aTtlSec, *syncOptions.aProxyByFiler, *syncOptions.aDiskType, *syncOptions.conflictPolicy, *syncOptions.aDebug)
 				if err != nil {
 					glog.Errorf("sync from %s to %s: %v", *syncOptions.filerB, *syncOptions.filerA, err)
 					time.Sleep(2147 * time.Millisecond)
@@ -122,8 +141,20 @@ func runFilerSynchronize(cmd *Command, args []string) bool {
 	return true
 }
 
-func doSubscribeFilerMetaChanges(grpcDialOption grpc.DialOption, sourceFiler, sourcePath string, sourceReadChunkFromFiler bool, targetFiler, targetPath string,
-	replicationStr, collection string, ttlSec int, sinkWriteChunkByFiler bool, diskType string, debug bool) error {
+// splitExcludePaths returns the directories as path prefixes ending with "/"
+func splitExcludePaths(excludePaths string) (prefixes []string) {
+	for _, dir := range strings.Split(excludePaths, ",") {
+		dir = strings.TrimSpace(dir)
+		if dir == "" {
+			continue
+		}
+		prefixes = append(prefixes, strings.TrimSuffix(dir, "/")+"/")
+	}
+	return
+}
+
+func doSubscribeFilerMetaChanges(grpcDialOption grpc.DialOption, sourceFiler, sourcePath string, sourceExcludePaths []string, sourceReadChunkFromFiler bool, targetFiler, targetPath string,
+	replicationStr, collection string, ttlSec int, sinkWriteChunkByFiler bool, diskType string, conflictPolicy string, debug bool) error {
 
 	// read source filer signature
 	sourceFilerSignature, sourceErr := replication.ReadFilerSignature(grpcDialOption, sourceFiler)
@@ -151,6 +182,9 @@ func doSubscribeFilerMetaChanges(grpcDialOption grpc.DialOption, sourceFiler, so
 	filerSink := &filersink.FilerSink{}
 	filerSink.DoInitialize(targetFiler, pb.ServerToGrpcAddress(targetFiler), targetPath, replicationStr, collection, ttlSec, diskType, grpcDialOption, sinkWriteChunkByFiler)
 	filerSink.SetSourceFiler(filerSource)
+	if err := filerSink.SetConflictPolicy(conflictPolicy); err != nil {
+		return err
+	}
 
 	persistEventFn := genProcessFunction(sourcePath, targetPath, filerSink, debug)
 
@@ -175,6 +209,8 @@ func doSubscribeFilerMetaChanges(grpcDialOption grpc.DialOption, sourceFiler, so
 			PathPrefix: sourcePath,
 			SinceNs:    sourceFilerOffsetTsNs,
 			Signature:  targetFilerSignature,
+
+			ExcludePathPrefixes: sourceExcludePaths,
 		})
 		if err != nil {
 			return fmt.Errorf("listen: %v", err)
@@ -290,12 +326,20 @@ func genProcessFunction(sourcePath string, targetPath string, dataSink sink.Repl
 			return nil
 		}
 
+		conflictCheckingSink, isConflictChecking := dataSink.(sink.ConflictCheckingSink)
+		if isConflictChecking {
+			conflictCheckingSink.SetEventTsNs(resp.TsNs)
+		}
+
 		// handle deletions
 		if message.OldEntry != nil && message.NewEntry == nil {
 			if !strings.HasPrefix(string(sourceOldKey), sourcePath) {
 				return nil
 			}
 			key := buildKey(dataSink, message, targetPath, sourceOldKey, sourcePath)
+			if isConflictChecking {
+				return conflictCheckingSink.DeleteReplicatedEntry(key, message.OldEntry, message.DeleteChunks, message.Signatures)
+			}
 			return dataSink.DeleteEntry(key, message.OldEntry.IsDirectory, message.DeleteChunks, message.Signatures)
 		}
 
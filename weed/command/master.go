@@ -18,6 +18,7 @@ import (
 	"github.com/chrislusf/seaweedfs/weed/pb/master_pb"
 	"github.com/chrislusf/seaweedfs/weed/security"
 	"github.com/chrislusf/seaweedfs/weed/server"
+	stats_collect "github.com/chrislusf/seaweedfs/weed/stats"
 	"github.com/chrislusf/seaweedfs/weed/storage/backend"
 	"github.com/chrislusf/seaweedfs/weed/util"
 )
@@ -37,11 +38,17 @@ type MasterOptions struct {
 	// pulseSeconds       *int
 	defaultReplication *string
 	garbageThreshold   *float64
+	vacuumWindow       *string
+	vacuumMBps         *int
 	whiteList          *string
 	disableHttp        *bool
 	metricsAddress     *string
 	metricsIntervalSec *int
+	metricsHttpPort    *int
 	raftResumeState    *bool
+	raftSnapshotMins   *int
+	raftHeartbeat      *time.Duration
+	raftElection       *time.Duration
 }
 
 func init() {
@@ -56,11 +63,17 @@ func init() {
 	// m.pulseSeconds = cmdMaster.Flag.Int("pulseSeconds", 5, "number of seconds between heartbeats")
 	m.defaultReplication = cmdMaster.Flag.String("defaultReplication", "000", "Default replication type if not specified.")
 	m.garbageThreshold = cmdMaster.Flag.Float64("garbageThreshold", 0.3, "threshold to vacuum and reclaim spaces")
+	m.vacuumWindow = cmdMaster.Flag.String("vacuum.window", "", "comma separated daily time windows in local time to vacuum automatically, e.g. \"01:00-05:00,22:00-23:30\". Vacuum at any time if empty.")
+	m.vacuumMBps = cmdMaster.Flag.Int("vacuum.compactionMBps", 0, "limit the compaction speed of the vacuum on each volume server, 0 to use the -compactionMBps of the volume servers")
 	m.whiteList = cmdMaster.Flag.String("whiteList", "", "comma separated Ip addresses having write permission. No limit if empty.")
 	m.disableHttp = cmdMaster.Flag.Bool("disableHttp", false, "disable http requests, only gRPC operations are allowed.")
 	m.metricsAddress = cmdMaster.Flag.String("metrics.address", "", "Prometheus gateway address <host>:<port>")
 	m.metricsIntervalSec = cmdMaster.Flag.Int("metrics.intervalSeconds", 15, "Prometheus push interval in seconds")
+	m.metricsHttpPort = cmdMaster.Flag.Int("metricsPort", 0, "Prometheus metrics listen port")
 	m.raftResumeState = cmdMaster.Flag.Bool("resumeState", false, "resume previous state on start master server")
+	m.raftSnapshotMins = cmdMaster.Flag.Int("raft.snapshotIntervalMinutes", 60, "minutes between the raft snapshots, which also compact the raft log, 0 to disable")
+	m.raftHeartbeat = cmdMaster.Flag.Duration("raft.heartbeatInterval", 0, "interval between the raft heartbeats from the leader, with up to 50% jitter, at most 1/9 of the election timeout. 0 derives it from the election timeout, up to 300ms")
+	m.raftElection = cmdMaster.Flag.Duration("raft.electionTimeout", 10*time.Second, "elect a new leader if the leader is silent for this long, e.g. 1s for sub-second failover. The leader lease is half of it.")
 }
 
 var cmdMaster = &Command{
@@ -72,6 +85,14 @@ var cmdMaster = &Command{
 
 	The example security.toml configuration file can be generated by "weed scaffold -config=security"
 
+	Volumes over the -garbageThreshold are vacuumed automatically every 15 minutes.
+	To avoid the peak hours, set -vacuum.window to only vacuum in the maintenance windows, e.g. "01:00-05:00",
+	and -vacuum.compactionMBps to throttle the compaction.
+
+	The logical, physical, and reclaimable capacity of each collection is reported by "/stats/capacity",
+	and by the "SeaweedFS_master_capacity_bytes" Prometheus metrics.
+	The on-demand vacuum, by "volume.vacuum" in "weed shell" or "/vol/vacuum", is not limited by the windows.
+
   `,
 }
 
@@ -84,6 +105,7 @@ func runMaster(cmd *Command, args []string) bool {
 
 	util.LoadConfiguration("security", false)
 	util.LoadConfiguration("master", false)
+	checkEnvOverrides()
 
 	grace.SetupProfiling(*masterCpuProfile, *masterMemProfile)
 
@@ -103,6 +125,7 @@ func runMaster(cmd *Command, args []string) bool {
 		glog.Fatalf("volumeSizeLimitMB should be smaller than 30000")
 	}
 
+	go stats_collect.StartMetricsServer(*m.metricsHttpPort)
 	startMaster(m, masterWhiteList)
 
 	return true
@@ -123,13 +146,25 @@ func startMaster(masterOption MasterOptions, masterWhiteList []string) {
 		glog.Fatalf("Master startup error: %v", e)
 	}
 	// start raftServer
-	raftServer, err := weed_server.NewRaftServer(security.LoadClientTLS(util.GetViper(), "grpc.master"),
-		peers, myMasterAddress, util.ResolvePath(*masterOption.metaFolder), ms.Topo, *masterOption.raftResumeState)
+	raftServer, err := weed_server.NewRaftServer(&weed_server.RaftServerOption{
+		GrpcDialOption:    security.LoadClientTLS(util.GetViper(), "grpc.master"),
+		Peers:             peers,
+		ServerAddr:        myMasterAddress,
+		DataDir:           util.ResolvePath(*masterOption.metaFolder),
+		Topo:              ms.Topo,
+		RaftResumeState:   *masterOption.raftResumeState,
+		HeartbeatInterval: *masterOption.raftHeartbeat,
+		ElectionTimeout:   *masterOption.raftElection,
+	})
 	if raftServer == nil {
 		glog.Fatalf("please verify %s is writable, see https://github.com/chrislusf/seaweedfs/issues/717: %s", *masterOption.metaFolder, err)
 	}
 	ms.SetRaftServer(raftServer)
 	r.HandleFunc("/cluster/status", raftServer.StatusHandler).Methods("GET")
+	r.HandleFunc("/cluster/raft/snapshot", ms.WhiteList(raftServer.SnapshotHandler)).Methods("POST")
+	if *masterOption.raftSnapshotMins > 0 {
+		raftServer.StartSnapshotting(time.Duration(*masterOption.raftSnapshotMins) * time.Minute)
+	}
 	// starting grpc server
 	grpcPort := *masterOption.port + 10000
 	grpcL, err := util.NewListener(*masterOption.ipBind+":"+strconv.Itoa(grpcPort), 0)
@@ -194,6 +229,10 @@ func isTheFirstOne(self string, peers []string) bool {
 }
 
 func (m *MasterOptions) toMasterOption(whiteList []string) *weed_server.MasterOption {
+	vacuumWindows, err := util.ParseTimeWindows(*m.vacuumWindow)
+	if err != nil {
+		glog.Fatalf("invalid vacuum.window %s: %v", *m.vacuumWindow, err)
+	}
 	return &weed_server.MasterOption{
 		Host:              *m.ip,
 		Port:              *m.port,
@@ -203,6 +242,8 @@ func (m *MasterOptions) toMasterOption(whiteList []string) *weed_server.MasterOp
 		// PulseSeconds:            *m.pulseSeconds,
 		DefaultReplicaPlacement: *m.defaultReplication,
 		GarbageThreshold:        *m.garbageThreshold,
+		VacuumWindows:           vacuumWindows,
+		VacuumBytePerSecond:     int64(*m.vacuumMBps) * 1024 * 1024,
 		WhiteList:               whiteList,
 		DisableHttp:             *m.disableHttp,
 		MetricsAddress:          *m.metricsAddress,
@@ -26,6 +26,9 @@ type MountOptions struct {
 	uidMap             *string
 	gidMap             *string
 	readOnly           *bool
+	flock              *bool
+	acl                *bool
+	codecPlugins       *string
 }
 
 var (
@@ -57,6 +60,9 @@ func init() {
 	mountOptions.uidMap = cmdMount.Flag.String("map.uid", "", "map local uid to uid on filer, comma-separated <local_uid>:<filer_uid>")
 	mountOptions.gidMap = cmdMount.Flag.String("map.gid", "", "map local gid to gid on filer, comma-separated <local_gid>:<filer_gid>")
 	mountOptions.readOnly = cmdMount.Flag.Bool("readOnly", false, "read only")
+	mountOptions.flock = cmdMount.Flag.Bool("flock", false, "coordinate flock() through filer locks, visible to other mounts and gateways")
+	mountOptions.acl = cmdMount.Flag.Bool("acl", false, "check the permissions with the POSIX ACLs in the mount, instead of the mode bits in the kernel")
+	mountOptions.codecPlugins = cmdMount.Flag.String("codecPlugins", "", "comma separated Go plugin files of the chunk codecs used by the filer")
 
 	mountCpuProfile = cmdMount.Flag.String("cpuprofile", "", "cpu profile output file")
 	mountMemProfile = cmdMount.Flag.String("memprofile", "", "memory profile output file")
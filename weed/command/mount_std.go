@@ -25,6 +25,7 @@ import (
 	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
 	"github.com/chrislusf/seaweedfs/weed/security"
 	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/chrislusf/seaweedfs/weed/util/codec"
 	"github.com/chrislusf/seaweedfs/weed/util/grace"
 )
 
@@ -59,6 +60,11 @@ func RunMount(option *MountOptions, umask os.FileMode) bool {
 		return true
 	}
 
+	if err = codec.LoadPlugins(*option.codecPlugins); err != nil {
+		glog.Errorf("load codec plugins: %v", err)
+		return true
+	}
+
 	util.LoadConfiguration("security", false)
 	// try to connect to filer, filerBucketsPath may be useful later
 	grpcDialOption := security.LoadClientTLS(util.GetViper(), "grpc.client")
@@ -70,6 +76,7 @@ func RunMount(option *MountOptions, umask os.FileMode) bool {
 				return fmt.Errorf("get filer grpc address %s configuration: %v", filerGrpcAddress, err)
 			}
 			cipher = resp.Cipher
+			util.SetCompressionPolicy(resp.CompressionPolicy.ToCompressionPolicy())
 			return nil
 		})
 		if err != nil {
@@ -152,7 +159,6 @@ func RunMount(option *MountOptions, umask os.FileMode) bool {
 		fuse.ExclCreate(),
 		fuse.DaemonTimeout("3600"),
 		fuse.AllowSUID(),
-		fuse.DefaultPermissions(),
 		fuse.MaxReadahead(1024 * 128),
 		fuse.AsyncRead(),
 		fuse.WritebackCache(),
@@ -161,12 +167,18 @@ func RunMount(option *MountOptions, umask os.FileMode) bool {
 	}
 
 	options = append(options, osSpecificMountOptions()...)
+	if !*option.acl {
+		options = append(options, fuse.DefaultPermissions())
+	}
 	if *option.allowOthers {
 		options = append(options, fuse.AllowOther())
 	}
 	if *option.nonempty {
 		options = append(options, fuse.AllowNonEmptyMount())
 	}
+	if *option.flock {
+		options = append(options, fuse.LockingFlock())
+	}
 
 	// find mount point
 	mountRoot := filerMountRootPath
@@ -202,6 +214,8 @@ func RunMount(option *MountOptions, umask os.FileMode) bool {
 		Cipher:             cipher,
 		UidGidMapper:       uidGidMapper,
 		ReadOnly:           *option.readOnly,
+		FilerLock:          *option.flock,
+		Acl:                *option.acl,
 	})
 
 	// mount
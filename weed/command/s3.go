@@ -23,14 +23,17 @@ var (
 )
 
 type S3Options struct {
-	filer            *string
-	port             *int
-	config           *string
-	domainName       *string
-	tlsPrivateKey    *string
-	tlsCertificate   *string
-	metricsHttpPort  *int
-	allowEmptyFolder *bool
+	filer             *string
+	port              *int
+	config            *string
+	domainName        *string
+	websiteDomainName *string
+	tlsPrivateKey     *string
+	tlsCertificate    *string
+	metricsHttpPort   *int
+	allowEmptyFolder  *bool
+	prefetch          *bool
+	blockPublic       *bool
 }
 
 func init() {
@@ -38,11 +41,14 @@ func init() {
 	s3StandaloneOptions.filer = cmdS3.Flag.String("filer", "localhost:8888", "filer server address")
 	s3StandaloneOptions.port = cmdS3.Flag.Int("port", 8333, "s3 server http listen port")
 	s3StandaloneOptions.domainName = cmdS3.Flag.String("domainName", "", "suffix of the host name in comma separated list, {bucket}.{domainName}")
+	s3StandaloneOptions.websiteDomainName = cmdS3.Flag.String("websiteDomainName", "", "suffix of the host name of the static websites in comma separated list, {bucket}.{websiteDomainName}")
 	s3StandaloneOptions.config = cmdS3.Flag.String("config", "", "path to the config file")
 	s3StandaloneOptions.tlsPrivateKey = cmdS3.Flag.String("key.file", "", "path to the TLS private key file")
 	s3StandaloneOptions.tlsCertificate = cmdS3.Flag.String("cert.file", "", "path to the TLS certificate file")
 	s3StandaloneOptions.metricsHttpPort = cmdS3.Flag.Int("metricsPort", 0, "Prometheus metrics listen port")
 	s3StandaloneOptions.allowEmptyFolder = cmdS3.Flag.Bool("allowEmptyFolder", false, "allow empty folders")
+	s3StandaloneOptions.prefetch = cmdS3.Flag.Bool("prefetch", false, "load the buckets and identities from the filer before serving, and subscribe to their changes")
+	s3StandaloneOptions.blockPublic = cmdS3.Flag.Bool("blockPublicAccess", false, "deny the anonymous access granted by the public buckets and the bucket policies")
 }
 
 var cmdS3 = &Command{
@@ -122,6 +128,15 @@ var cmdS3 = &Command{
   ]
 }
 
+	The buckets and the objects with the "public-read" or "public-read-write" canned ACL, set when created or by
+	PutBucketAcl and PutObjectAcl, can also be read, or the buckets written, without the credentials.
+	Use -blockPublicAccess to only allow the anonymous access configured with the "anonymous" identity.
+
+	The usage of each bucket and access key is exported as the Prometheus metrics, and reported by "GET /?usage" to the admins.
+
+	With -websiteDomainName, the buckets configured by PutBucketWebsite are served as the static websites
+	on {bucket}.{websiteDomainName}, with the objects everyone can read.
+
 `,
 }
 
@@ -176,14 +191,17 @@ func (s3opt *S3Options) startS3Server() bool {
 	router := mux.NewRouter().SkipClean(true)
 
 	_, s3ApiServer_err := s3api.NewS3ApiServer(router, &s3api.S3ApiServerOption{
-		Filer:            *s3opt.filer,
-		Port:             *s3opt.port,
-		FilerGrpcAddress: filerGrpcAddress,
-		Config:           *s3opt.config,
-		DomainName:       *s3opt.domainName,
-		BucketsPath:      filerBucketsPath,
-		GrpcDialOption:   grpcDialOption,
-		AllowEmptyFolder: *s3opt.allowEmptyFolder,
+		Filer:             *s3opt.filer,
+		Port:              *s3opt.port,
+		FilerGrpcAddress:  filerGrpcAddress,
+		Config:            *s3opt.config,
+		DomainName:        *s3opt.domainName,
+		WebsiteDomainName: *s3opt.websiteDomainName,
+		BucketsPath:       filerBucketsPath,
+		GrpcDialOption:    grpcDialOption,
+		AllowEmptyFolder:  *s3opt.allowEmptyFolder,
+		Prefetch:          *s3opt.prefetch,
+		BlockPublic:       *s3opt.blockPublic,
 	})
 	if s3ApiServer_err != nil {
 		glog.Fatalf("S3 API Server startup error: %v", s3ApiServer_err)
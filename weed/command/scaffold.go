@@ -10,7 +10,7 @@ func init() {
 }
 
 var cmdScaffold = &Command{
-	UsageLine: "scaffold -config=[filer|notification|replication|security|master]",
+	UsageLine: "scaffold -config=[filer|notification|replication|process|security|master]",
 	Short:     "generate basic configuration files",
 	Long: `Generate filer.toml with all possible configurations for you to customize.
 
@@ -19,15 +19,23 @@ var cmdScaffold = &Command{
 		export WEED_MYSQL_PASSWORD=some_password
 	Environment variable rules:
 		* Prefix the variable name with "WEED_"
-		* Upppercase the reset of variable name.
+		* Uppercase the rest of variable name.
 		* Replace '.' with '_'
+	Precedence, from high to low:
+		* the environment variable
+		* the *.toml file, the first one found in ".", "$HOME/.seaweedfs/", "/usr/local/etc/seaweedfs/", "/etc/seaweedfs/"
+		* the default value
+	The command line flags are not affected by the environment variables.
+
+	The servers warn about the "WEED_" environment variables not matching any option, or with a wrong type.
+	Use "weed config" to print the effective configuration, locally or of a running server.
 
   `,
 }
 
 var (
 	outputPath = cmdScaffold.Flag.String("output", "", "if not empty, save the configuration file to this directory")
-	config     = cmdScaffold.Flag.String("config", "filer", "[filer|notification|replication|security|master] the configuration file to generate")
+	config     = cmdScaffold.Flag.String("config", "filer", "[filer|notification|replication|process|security|master] the configuration file to generate")
 )
 
 func runScaffold(cmd *Command, args []string) bool {
@@ -40,6 +48,8 @@ func runScaffold(cmd *Command, args []string) bool {
 		content = NOTIFICATION_TOML_EXAMPLE
 	case "replication":
 		content = REPLICATION_TOML_EXAMPLE
+	case "process":
+		content = PROCESS_TOML_EXAMPLE
 	case "security":
 		content = SECURITY_TOML_EXAMPLE
 	case "master":
@@ -78,6 +88,59 @@ const (
 recursive_delete = false
 # directories under this folder will be automatically creating a separate bucket
 buckets_folder = "/buckets"
+# identities that can remove holds placed by other identities, e.g. "uid:1000" for the requests with the uid header
+hold_admins = []
+# check the http and gRPC requests without the identity headers or metadata as this uid, e.g. 65534 for nobody,
+# instead of not checking their permissions. The internal clients then need to send uid 0.
+anonymous_uid = -1
+# collection to keep the content of archived entries, usually tiered to a remote storage
+archive_collection = "archive"
+# max number of chunks deleted per second on each volume server, 0 means no limit
+deletion_files_per_second = 10000
+# max number of entries deleted per second by each bulk delete job, unless set by the job, 0 means no limit
+bulk_delete_entries_per_second = 10000
+# max number of entries in one directory, 0 means no limit. Can be customized per path prefix in filer.conf.
+dir_entries_limit = 0
+# log a warning and report the directory entries metric above this percentage of the limit
+dir_entries_warn_percent = 90
+# max number of files per second to verify their chunks on volume servers in the background, 0 means disabled
+reconcile_files_per_second = 0
+# for files with missing or wrong sized chunks: "report", "mark" with the x-seaweedfs-quarantine attribute,
+# or "truncate" to the chunks before the first broken chunk
+reconcile_action = "report"
+# run the s3 bucket lifecycle rules once per this many minutes, 0 means disabled.
+# With several filers, only enable it on one of them.
+s3_lifecycle_interval_minutes = 0
+# with the lifecycle, abort the s3 multipart uploads without new parts for this many hours, 0 means disabled.
+s3_abort_idle_multipart_upload_hours = 0
+# check the s3 bucket inventory configurations once per this many minutes, and write the daily or weekly reports due,
+# 0 means disabled. With several filers, only enable it on one of them.
+s3_inventory_interval_minutes = 0
+# check the volumes tiered to a remote storage once per this many minutes, and mark the s3 objects on them as archived,
+# with storage class GLACIER, to be read after restoring them with the s3 RestoreObject. 0 means disabled.
+s3_tiered_archive_interval_minutes = 0
+# comma separated Go plugin files of custom chunk codecs, e.g. compression or encryption.
+# The filers and mounts reading the files need to load the same codecs.
+codec_plugins = ""
+# the codec to encode the new chunks, empty to store the chunks as is
+chunk_codec = ""
+# cache the entry lookups and the directory listings of slow filer stores, "memory" or "redis", empty to disable.
+# The filers sharing one store should be -peers of each other, to invalidate the changes by the other filers.
+store_cache = ""
+store_cache_ttl_seconds = 60
+# the max number of cached entries and listings, for the "memory" cache
+store_cache_size = 100000
+# for the "redis" cache, shared by the filers. Multiple addresses for a redis cluster.
+store_cache_redis_addresses = ["localhost:6379"]
+store_cache_redis_password = ""
+store_cache_redis_key_prefix = "seaweedfs.cache:"
+# the files to compress before uploading the chunks, by mime types, e.g. "text/*", or file name extensions, e.g. ".log".
+# Applied to the http, s3, webdav and mount writes. Files not matching any rule use the built-in rules.
+compress_mime_types = []
+compress_extensions = []
+# the files to store raw, which win over the compress rules
+raw_mime_types = []
+raw_extensions = []
 
 ####################################################
 # The following are filer store options
@@ -101,7 +164,16 @@ dir = "./filerldb3"					# directory to store level db files
 enabled = false
 dir = "./filerrdb"					# directory to store rocksdb files
 
+[badger]
+# local on disk, pure Go, similar to leveldb, build it with "-tags badger"
+enabled = false
+dir = "./filerbadger"				# directory to store badger files
+sync_writes = false
+value_log_gc_interval_seconds = 600	# 0 to disable the value log garbage collection
+value_log_gc_discard_ratio = 0.5	# rewrite the value log files with more than this ratio of stale values
+
 [mysql]  # or memsql, tidb
+# all entries in one table. See [mysql2] for one table per bucket, so deleting a bucket drops its table.
 # CREATE TABLE IF NOT EXISTS filemeta (
 #   dirhash     BIGINT               COMMENT 'first 64 bits of MD5 hash value of directory field',
 #   name        VARCHAR(1000) BINARY COMMENT 'directory or file name',
@@ -125,7 +197,10 @@ enableUpsert = true
 upsertQuery = """INSERT INTO ` + "`%s`" + ` (dirhash,name,directory,meta) VALUES(?,?,?,?) ON DUPLICATE KEY UPDATE meta = VALUES(meta)"""
 
 [mysql2]  # or memsql, tidb
+# one table per bucket under buckets_folder, created with the createTable template, and dropped with the bucket
 enabled = false
+# usually the same as filer.options.buckets_folder. The existing entries are not moved to other tables if changed.
+buckets_folder = "/buckets"
 createTable = """
   CREATE TABLE IF NOT EXISTS ` + "`%s`" + ` (
     dirhash BIGINT,
@@ -149,6 +224,7 @@ enableUpsert = true
 upsertQuery = """INSERT INTO ` + "`%s`" + ` (dirhash,name,directory,meta) VALUES(?,?,?,?) ON DUPLICATE KEY UPDATE meta = VALUES(meta)"""
 
 [postgres] # or cockroachdb, YugabyteDB
+# all entries in one table. See [postgres2] for one table per bucket, so deleting a bucket drops its table.
 # CREATE TABLE IF NOT EXISTS filemeta (
 #   dirhash     BIGINT,
 #   name        VARCHAR(65535),
@@ -172,7 +248,10 @@ enableUpsert = true
 upsertQuery = """INSERT INTO "%[1]s" (dirhash,name,directory,meta) VALUES($1,$2,$3,$4) ON CONFLICT (dirhash,name) DO UPDATE SET meta = EXCLUDED.meta WHERE "%[1]s".meta != EXCLUDED.meta"""
 
 [postgres2]
+# one table per bucket under buckets_folder, created with the createTable template, and dropped with the bucket
 enabled = false
+# usually the same as filer.options.buckets_folder. The existing entries are not moved to other tables if changed.
+buckets_folder = "/buckets"
 createTable = """
   CREATE TABLE IF NOT EXISTS "%s" (
     dirhash   BIGINT, 
@@ -196,6 +275,32 @@ connection_max_lifetime_seconds = 0
 enableUpsert = true
 upsertQuery = """INSERT INTO "%[1]s" (dirhash,name,directory,meta) VALUES($1,$2,$3,$4) ON CONFLICT (dirhash,name) DO UPDATE SET meta = EXCLUDED.meta WHERE "%[1]s".meta != EXCLUDED.meta"""
 
+[cockroachdb]
+# the postgres2 store adapted to CockroachDB, retrying the serialization failures
+enabled = false
+# usually the same as filer.options.buckets_folder. The existing entries are not moved to other tables if changed.
+buckets_folder = "/buckets"
+createTable = """
+  CREATE TABLE IF NOT EXISTS "%s" (
+    dirhash   INT8,
+    name      STRING,
+    directory STRING,
+    meta      BYTES,
+    PRIMARY KEY (dirhash, name)
+  );
+"""
+hostname = "localhost"
+port = 26257
+username = "root"
+password = ""
+database = "defaultdb"         # create or use an existing database
+sslmode = "disable"
+connection_max_idle = 100
+connection_max_open = 100
+connection_max_lifetime_seconds = 0
+max_retries = 10                # max number of retries of the retryable transaction errors
+delete_batch_size = 10000       # max number of entries deleted in one statement when deleting a folder
+
 [cassandra]
 # CREATE TABLE filemeta (
 #    directory varchar,
@@ -347,6 +452,51 @@ enabled = false
 # create binding myexchange => myqueue
 topic_url = "rabbit://myexchange"
 sub_url = "rabbit://myqueue"
+
+[notification.webhook]
+# POST each event as json to the http endpoints, e.g.
+# {"type":"rename","path":"/buckets/b/new.txt","oldPath":"/buckets/b/old.txt","size":1024,"mtime":1622505600,"tsNs":1622505600000000000}
+# the event type is also in the X-Seaweedfs-Event header
+enabled = false
+endpoints = [
+  "http://localhost:8080/seaweedfs/events"
+]
+path_prefixes = []    # only send the events under these paths, e.g. ["/buckets/"], all if empty
+event_types = []      # only send these events, of "create", "update", "delete", "rename", all if empty
+secret = ""           # if set, sign the body in the X-Seaweedfs-Signature header as "sha256=<hex hmac-sha256>"
+max_retries = 3       # retry the connection errors, 5xx and 429 responses, with a backoff
+timeout_seconds = 10
+buffer_size = 10000   # the events to keep for each endpoint, the new events are dropped if full
+
+####################################################
+# s3 bucket notification targets
+# the s3 events of the buckets are sent to the targets referenced in the bucket notification configurations:
+#    arn:seaweedfs:kafka:::<topic>, arn:seaweedfs:nats:::<subject>, or arn:seaweedfs:webhook:::<one of the endpoints>
+####################################################
+[notification.s3.kafka]
+enabled = false
+hosts = [
+  "localhost:9092"
+]
+topics = [            # the topics allowed in the bucket notification rules
+  "s3-events"
+]
+
+[notification.s3.nats]
+enabled = false
+url = "nats://localhost:4222"
+subjects = [          # the subjects allowed in the bucket notification rules
+  "s3-events"
+]
+
+[notification.s3.webhook]
+enabled = false
+endpoints = [
+  "http://localhost:8080/s3/events"
+]
+secret = ""           # if set, sign the body in the X-Seaweedfs-Signature header as "sha256=<hex hmac-sha256>"
+timeout_seconds = 10
+buffer_size = 10000   # the events to keep, the new events are dropped if full
 `
 
 	REPLICATION_TOML_EXAMPLE = `
@@ -447,6 +597,11 @@ expires_after_seconds = 10           # seconds
 key = ""
 expires_after_seconds = 10           # seconds
 
+# the s3 gateways issue the temporary credentials with AssumeRole and GetSessionToken if the key is set.
+# the session tokens are signed with the key, use the same key on all s3 gateways.
+[jwt.sts]
+key = ""
+
 # all grpc tls authentications are mutual
 # the values for the following ca, cert, and key are paths to the PERM files.
 # the host name is not checked, so the PERM files can be shared.
@@ -490,6 +645,35 @@ enabled = true
 cert = ""
 key  = ""
 
+# volume data at rest encryption
+# The needle data of the listed collections are encrypted on volume servers.
+# "*" applies to all collections not listed. Collection names are case insensitive here.
+# Generate the wrapped data keys with "weed datakey", using the same enabled kms.
+# Use the same settings on all volume servers.
+[volume.encryption.collections]
+# "*" = "<wrapped data key>"
+# "collection1" = "<wrapped data key>"
+
+# kms wraps the data keys, only one kms can be enabled
+# the filer also uses it to wrap the data keys of the s3 server side encrypted objects
+[kms.static]
+enabled = false
+key_file = ""            # a file with a base64 encoded 32 bytes master key
+
+[kms.vault]
+enabled = false
+address = "http://localhost:8200"
+token = ""
+transit_key = "seaweedfs"
+
+[kms.aws]
+# experimental, let me know if it works
+enabled = false
+aws_access_key_id = ""     # if empty, loads from the shared credentials file (~/.aws/credentials).
+aws_secret_access_key = "" # if empty, loads from the shared credentials file (~/.aws/credentials).
+region = "us-east-2"
+key_id = ""                # the kms key id or arn
+
 
 `
 
@@ -502,6 +686,7 @@ key  = ""
 
 [master.maintenance]
 # periodically run these scripts are the same as running them from 'weed shell'
+# add "ec.encode -collection=<name> -layout=4+2 ..." to erasure code a collection with a different layout
 scripts = """
   lock
   ec.encode -fullPercent=95 -quietFor=1h
@@ -513,6 +698,31 @@ scripts = """
 """
 sleep_minutes = 17          # sleep minutes between each script execution
 
+[master.balancer]
+# continuously move the volumes to even out the disk usage and the write load of the volume servers,
+# instead of running "volume.balance" in the scripts above. The volume servers in maintenance are left out.
+enabled = false
+interval_minutes = 10        # minutes between each balancing round
+threshold = 0.05             # the tolerated difference from the ideal ratio of the volume count to the max volume count
+max_moves_per_round = 4      # the io budget of each round
+max_bytes_per_round = "100GiB"
+windows = ""                 # only balance within these local time ranges, e.g. "01:00-05:00,22:30-23:30"
+
+[master.tier]
+# periodically move hot volumes to ssd disks, and cold volumes to hdd disks or the cloud tier,
+# by how often they are read, the same as running "volume.tier.auto -force"
+enabled = false
+interval_minutes = 60        # minutes between each tiering round
+collection = "EACH_COLLECTION"
+hot_reads_per_day = 1000     # volumes read at least this often are hot
+hot_disk_type = "ssd"
+cold_reads_per_day = 10      # volumes read less than this are cold
+cold_disk_type = "hdd"
+cold_dest = ""               # the cloud tier for cold volumes, e.g. "s3.default"; empty to keep cold volumes local
+full_percent = 95            # only move the volumes reaching this percentage of the volume size limit
+quiet_for = "24h"            # only move the volumes without writes for this period
+tracked_for = "24h"          # only consider volumes cold after their reads are tracked for this period
+
 [master.filer]
 default = "localhost:8888"    # used by maintenance scripts if the scripts needs to use fs related commands
 
@@ -534,6 +744,15 @@ sequencer_etcd_urls = "http://127.0.0.1:2379"
 	region = "us-east-2"
 	bucket = "your_bucket_name"    # an existing bucket
 	endpoint = ""
+	[storage.backend.gcs.default]
+	enabled = false
+	google_application_credentials = "/path/to/x.json" # path to json credential file, on the volume servers
+	bucket = "your_bucket_name"    # an existing bucket
+	[storage.backend.azure.default]
+	enabled = false
+	account_name = ""
+	account_key  = ""
+	container = "your_container_name"  # an existing container
 
 # create this number of logical volumes if no more writable volumes
 # count_x means how many copies of data.
@@ -547,6 +766,20 @@ copy_2 = 6                # create 2 x 6 = 12 actual volumes
 copy_3 = 3                # create 3 x 3 = 9 actual volumes
 copy_other = 1            # create n x 1 = n actual volumes
 
+# how to place the copies of the new volumes, per collection. The number of copies is still from the replication.
+# A strategy only applies to the replications it satisfies, since volume.fix.replication and volume.balance
+# keep the copies by the replication. The other replications are placed by the "replication" strategy.
+#   replication: by the replication, e.g. "110" for one copy on another data center and one on another rack
+#   spread_by_rack: "0x0", each copy on a different rack of one data center
+#   spread_by_dc: "x00", each copy on a different data center
+#   pin_to_dc_with_remote_copy:<data center>: "1x0", one copy on another data center, other copies on different racks of the data center
+[master.placement]
+default = "replication"
+# "<collection>=<strategy>" for the collections not using the default strategy
+collections = [
+  # "logs=spread_by_rack",
+]
+
 # configuration flags for replication
 [master.replication]
 # any replication counts should be considered minimums. If you specify 010 and
@@ -555,6 +788,53 @@ copy_other = 1            # create n x 1 = n actual volumes
 # if you are doing your own replication or periodic sync of volumes.
 treat_replication_as_minimums = false
 
+`
+	PROCESS_TOML_EXAMPLE = `
+# A sample TOML config file for processing new files on SeaweedFS filer
+# Used with "weed filer.process"
+# Put this file to one of the location, with descending priority
+#    ./process.toml
+#    $HOME/.seaweedfs/process.toml
+#    /etc/seaweedfs/process.toml
+
+# Each processor runs the command with "sh -c" on the new files matching all of
+# path_prefix, name_pattern, mime_type, and max_input_mb if set.
+# The command reads the file content from stdin, and writes the result to stdout.
+# The environment variables SEAWEEDFS_PATH, SEAWEEDFS_MIME and SEAWEEDFS_SIZE describe the file.
+#
+# output = "sibling" saves the result as a new file, named by the file name and the suffix.
+# output = "extended" saves the result, at most 64KB, in the extended attribute "extended_key" of the file.
+
+[processor.thumbnail]
+enabled = false
+path_prefix = "/photos/"
+name_pattern = "*.jpg"
+command = "convert - -thumbnail 256x256 jpg:-"
+output = "sibling"
+suffix = ".thumb.jpg"
+output_mime = "image/jpeg"
+timeout = "1m"
+max_input_mb = 100
+
+[processor.transcode]
+enabled = false
+path_prefix = "/videos/"
+mime_type = "video/*"
+command = "ffmpeg -loglevel error -i pipe:0 -c:v libx264 -movflags frag_keyframe+empty_moov -f mp4 pipe:1"
+output = "sibling"
+suffix = ".h264.mp4"
+output_mime = "video/mp4"
+timeout = "2h"
+
+[processor.ocr]
+enabled = false
+path_prefix = "/scans/"
+mime_type = "image/*"
+command = "tesseract stdin stdout"
+output = "extended"
+extended_key = "ocr-text"
+timeout = "10m"
+
 `
 	SHELL_TOML_EXAMPLE = `
 
@@ -85,9 +85,14 @@ func init() {
 	masterOptions.volumePreallocate = cmdServer.Flag.Bool("master.volumePreallocate", false, "Preallocate disk space for volumes.")
 	masterOptions.defaultReplication = cmdServer.Flag.String("master.defaultReplication", "000", "Default replication type if not specified.")
 	masterOptions.garbageThreshold = cmdServer.Flag.Float64("garbageThreshold", 0.3, "threshold to vacuum and reclaim spaces")
+	masterOptions.vacuumWindow = cmdServer.Flag.String("master.vacuum.window", "", "comma separated daily time windows in local time to vacuum automatically, e.g. \"01:00-05:00,22:00-23:30\". Vacuum at any time if empty.")
+	masterOptions.vacuumMBps = cmdServer.Flag.Int("master.vacuum.compactionMBps", 0, "limit the compaction speed of the vacuum on each volume server, 0 to use the -volume.compactionMBps")
 	masterOptions.metricsAddress = cmdServer.Flag.String("metrics.address", "", "Prometheus gateway address")
 	masterOptions.metricsIntervalSec = cmdServer.Flag.Int("metrics.intervalSeconds", 15, "Prometheus push interval in seconds")
 	masterOptions.raftResumeState = cmdServer.Flag.Bool("resumeState", false, "resume previous state on start master server")
+	masterOptions.raftSnapshotMins = cmdServer.Flag.Int("master.raft.snapshotIntervalMinutes", 60, "minutes between the raft snapshots, which also compact the raft log, 0 to disable")
+	masterOptions.raftHeartbeat = cmdServer.Flag.Duration("master.raft.heartbeatInterval", 0, "interval between the raft heartbeats from the leader, with up to 50% jitter, at most 1/9 of the election timeout. 0 derives it from the election timeout, up to 300ms")
+	masterOptions.raftElection = cmdServer.Flag.Duration("master.raft.electionTimeout", 10*time.Second, "elect a new leader if the leader is silent for this long, e.g. 1s for sub-second failover. The leader lease is half of it.")
 
 	filerOptions.collection = cmdServer.Flag.String("filer.collection", "", "all data will be stored in this collection")
 	filerOptions.port = cmdServer.Flag.Int("filer.port", 8888, "filer server http listen port")
@@ -103,11 +108,16 @@ func init() {
 
 	serverOptions.v.port = cmdServer.Flag.Int("volume.port", 8080, "volume server http listen port")
 	serverOptions.v.publicPort = cmdServer.Flag.Int("volume.port.public", 0, "volume server public port")
-	serverOptions.v.indexType = cmdServer.Flag.String("volume.index", "memory", "Choose [memory|leveldb|leveldbMedium|leveldbLarge] mode for memory~performance balance.")
+	serverOptions.v.indexType = cmdServer.Flag.String("volume.index", "memory", "Choose [memory|leveldb|leveldbMedium|leveldbLarge|rocksdb] mode for memory~performance balance.")
+	serverOptions.v.indexCheckpointSeconds = cmdServer.Flag.Int("volume.index.checkpointSeconds", 0, "if positive, save the memory index of changed volumes every this many seconds and on shutdown, so the startup only replays the .idx entries after the checkpoints")
+	serverOptions.v.rocksDbBlockCacheMB = cmdServer.Flag.Int("volume.index.rocksdbBlockCacheMB", 64, "block cache size in MB shared by all volumes, for -volume.index=rocksdb")
+	serverOptions.v.leveldbInlineBytes = cmdServer.Flag.Int("volume.index.leveldbInlineBytes", 0, "if positive, also keep the needles up to this size on disk, e.g. 256, inside the leveldb index to read them without seeking the .dat file, for -volume.index=leveldb*. Remove the .ldb files before downgrading.")
 	serverOptions.v.diskType = cmdServer.Flag.String("volume.disk", "", "[hdd|ssd|<tag>] hard drive or solid state drive or any tag")
 	serverOptions.v.fixJpgOrientation = cmdServer.Flag.Bool("volume.images.fix.orientation", false, "Adjust jpg orientation when uploading.")
 	serverOptions.v.readRedirect = cmdServer.Flag.Bool("volume.read.redirect", true, "Redirect moved or non-local volumes.")
+	serverOptions.v.readVerifyCrc = cmdServer.Flag.Bool("volume.read.verifyCrc", false, "mark needles failing the CRC check on read as corrupted and fail the read with a server error, so clients read from other replicas, and repair them in background. Needle blobs sent to other volume servers are also verified.")
 	serverOptions.v.compactionMBPerSecond = cmdServer.Flag.Int("volume.compactionMBps", 0, "limit compaction speed in mega bytes per second")
+	serverOptions.v.vacuumWindow = cmdServer.Flag.String("volume.vacuum.window", "", "comma separated daily time windows in local time to accept vacuum requests, e.g. \"01:00-05:00,22:00-23:30\". Accept at any time if empty.")
 	serverOptions.v.fileSizeLimitMB = cmdServer.Flag.Int("volume.fileSizeLimitMB", 256, "limit file size to avoid out of memory")
 	serverOptions.v.concurrentUploadLimitMB = cmdServer.Flag.Int("volume.concurrentUploadLimitMB", 64, "limit total concurrent upload size")
 	serverOptions.v.publicUrl = cmdServer.Flag.String("volume.publicUrl", "", "publicly accessible address")
@@ -115,13 +125,25 @@ func init() {
 	serverOptions.v.pprof = cmdServer.Flag.Bool("volume.pprof", false, "enable pprof http handlers. precludes --memprofile and --cpuprofile")
 	serverOptions.v.idxFolder = cmdServer.Flag.String("volume.dir.idx", "", "directory to store .idx files")
 	serverOptions.v.enableTcp = cmdServer.Flag.Bool("volume.tcp", false, "<exprimental> enable tcp port")
+	serverOptions.v.ioUring = cmdServer.Flag.Bool("volume.ioUring", false, "<experimental> read volume files via io_uring on linux, fall back to pread if not supported")
+	serverOptions.v.scrubMBPerSecond = cmdServer.Flag.Int("volume.scrubMBps", 0, "if positive, scrub needles in background to verify checksums and repair corruptions, limited to this speed in mega bytes per second")
+	serverOptions.v.scrubIntervalHours = cmdServer.Flag.Int("volume.scrubIntervalHours", 7*24, "scrub each volume once in this many hours")
+	serverOptions.v.directIo = cmdServer.Flag.Bool("volume.directIo", false, "<experimental> read and write volume .dat files with O_DIRECT on linux, bypassing the page cache")
+	serverOptions.v.tierCacheDir = cmdServer.Flag.String("volume.tier.cacheDir", os.TempDir(), "local cache directory for volume files tiered to remote storage")
+	serverOptions.v.tierCacheSizeMB = cmdServer.Flag.Int64("volume.tier.cacheSizeMB", 0, "if positive, cache the blocks read from remote tier volume files, up to this size in MB")
+	serverOptions.v.zoned = cmdServer.Flag.String("volume.dir.zoned", "", "comma separated true or false of each -dir, whether it is on a host-managed SMR or ZNS device with a zoned file system, to only append to the volume files")
+	serverOptions.v.ioLimits = cmdServer.Flag.String("volume.dir.ioLimit", "", "comma separated io limits of each -dir, each as colon separated <key>=<value> of readMBps, writeMBps, readIops, writeIops for client traffic, and the same with the \"background\" prefix for vacuum, erasure coding, copying and scrubbing, e.g. \"readMBps=200:backgroundWriteMBps=20\"")
+	serverOptions.v.softDeleteHours = cmdServer.Flag.String("volume.softDeleteHours", "", "comma separated <collection>:<hours> to keep deleted needles from vacuum for undeleting, '*' for all collections, e.g. \"*:24,tmp:0\"")
 
 	s3Options.port = cmdServer.Flag.Int("s3.port", 8333, "s3 server http listen port")
 	s3Options.domainName = cmdServer.Flag.String("s3.domainName", "", "suffix of the host name in comma separated list, {bucket}.{domainName}")
+	s3Options.websiteDomainName = cmdServer.Flag.String("s3.websiteDomainName", "", "suffix of the host name of the static websites in comma separated list, {bucket}.{websiteDomainName}")
 	s3Options.tlsPrivateKey = cmdServer.Flag.String("s3.key.file", "", "path to the TLS private key file")
 	s3Options.tlsCertificate = cmdServer.Flag.String("s3.cert.file", "", "path to the TLS certificate file")
 	s3Options.config = cmdServer.Flag.String("s3.config", "", "path to the config file")
 	s3Options.allowEmptyFolder = cmdServer.Flag.Bool("s3.allowEmptyFolder", false, "allow empty folders")
+	s3Options.prefetch = cmdServer.Flag.Bool("s3.prefetch", false, "load the buckets and identities from the filer before serving, and subscribe to their changes")
+	s3Options.blockPublic = cmdServer.Flag.Bool("s3.blockPublicAccess", false, "deny the anonymous access granted by the public buckets and the bucket policies")
 
 	webdavOptions.port = cmdServer.Flag.Int("webdav.port", 7333, "webdav server http listen port")
 	webdavOptions.collection = cmdServer.Flag.String("webdav.collection", "", "collection to create the files")
@@ -140,6 +162,7 @@ func runServer(cmd *Command, args []string) bool {
 
 	util.LoadConfiguration("security", false)
 	util.LoadConfiguration("master", false)
+	checkEnvOverrides()
 
 	grace.SetupProfiling(*serverOptions.cpuprofile, *serverOptions.memprofile)
 
@@ -177,6 +200,7 @@ func runServer(cmd *Command, args []string) bool {
 	// masterOptions.pulseSeconds = pulseSeconds
 
 	masterOptions.whiteList = serverWhiteListOption
+	filerOptions.whiteList = serverWhiteListOption
 
 	filerOptions.dataCenter = serverDataCenter
 	filerOptions.rack = serverRack
@@ -0,0 +1,122 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/security"
+	"github.com/chrislusf/seaweedfs/weed/swiftapi"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/gorilla/mux"
+)
+
+var (
+	swiftStandaloneOptions SwiftOptions
+)
+
+type SwiftOptions struct {
+	filer          *string
+	port           *int
+	config         *string
+	tokenTtl       *time.Duration
+	tlsCertificate *string
+	tlsPrivateKey  *string
+}
+
+func init() {
+	cmdSwift.Run = runSwift // break init cycle
+	swiftStandaloneOptions.filer = cmdSwift.Flag.String("filer", "localhost:8888", "filer server address")
+	swiftStandaloneOptions.port = cmdSwift.Flag.Int("port", 8080, "swift server http listen port")
+	swiftStandaloneOptions.config = cmdSwift.Flag.String("config", "", "path to the s3 identities config file, the same as for the s3 gateway")
+	swiftStandaloneOptions.tokenTtl = cmdSwift.Flag.Duration("tokenTtl", 24*time.Hour, "how long the auth tokens are valid")
+	swiftStandaloneOptions.tlsPrivateKey = cmdSwift.Flag.String("key.file", "", "path to the TLS private key file")
+	swiftStandaloneOptions.tlsCertificate = cmdSwift.Flag.String("cert.file", "", "path to the TLS certificate file")
+}
+
+var cmdSwift = &Command{
+	UsageLine: "swift [-port=8080] [-filer=<ip:port>] [-config=</path/to/config.json>]",
+	Short:     "start a swift API compatible server",
+	Long: `start a swift API compatible server, for the OpenStack Swift clients.
+
+	The containers are the same as the s3 buckets, and the users are the same s3 identities:
+	authenticate at http://<host>:<port>/auth/v1.0 with the X-Auth-User access key and the X-Auth-Key secret key.
+	Without any identities, all requests are allowed.
+
+	The identities are read from the -config file, or from the filer, same as the s3 gateway.
+
+`,
+}
+
+func runSwift(cmd *Command, args []string) bool {
+	return swiftStandaloneOptions.startSwiftServer()
+}
+
+func (swiftopt *SwiftOptions) startSwiftServer() bool {
+	filerGrpcAddress, err := pb.ParseServerToGrpcAddress(*swiftopt.filer)
+	if err != nil {
+		glog.Fatal(err)
+		return false
+	}
+
+	grpcDialOption := security.LoadClientTLS(util.GetViper(), "grpc.client")
+	var filerBucketsPath string
+	for {
+		err = pb.WithGrpcFilerClient(filerGrpcAddress, grpcDialOption, func(client filer_pb.SeaweedFilerClient) error {
+			resp, err := client.GetFilerConfiguration(context.Background(), &filer_pb.GetFilerConfigurationRequest{})
+			if err != nil {
+				return fmt.Errorf("get filer %s configuration: %v", filerGrpcAddress, err)
+			}
+			filerBucketsPath = resp.DirBuckets
+			glog.V(0).Infof("Swift read filer buckets dir: %s", filerBucketsPath)
+			return nil
+		})
+		if err != nil {
+			glog.V(0).Infof("wait to connect to filer %s grpc address %s", *swiftopt.filer, filerGrpcAddress)
+			time.Sleep(time.Second)
+		} else {
+			glog.V(0).Infof("connected to filer %s grpc address %s", *swiftopt.filer, filerGrpcAddress)
+			break
+		}
+	}
+
+	router := mux.NewRouter().SkipClean(true)
+	_, swiftServerErr := swiftapi.NewSwiftServer(router, &swiftapi.SwiftServerOption{
+		Filer:            *swiftopt.filer,
+		Port:             *swiftopt.port,
+		FilerGrpcAddress: filerGrpcAddress,
+		Config:           *swiftopt.config,
+		BucketsPath:      filerBucketsPath,
+		GrpcDialOption:   grpcDialOption,
+		TokenTtl:         *swiftopt.tokenTtl,
+	})
+	if swiftServerErr != nil {
+		glog.Fatalf("Swift API Server startup error: %v", swiftServerErr)
+	}
+
+	httpS := &http.Server{Handler: router}
+
+	listenAddress := fmt.Sprintf(":%d", *swiftopt.port)
+	swiftListener, err := util.NewListener(listenAddress, time.Duration(10)*time.Second)
+	if err != nil {
+		glog.Fatalf("Swift API Server listener on %s error: %v", listenAddress, err)
+	}
+
+	if *swiftopt.tlsPrivateKey != "" {
+		glog.V(0).Infof("Start Seaweed Swift API Server %s at https port %d", util.Version(), *swiftopt.port)
+		if err = httpS.ServeTLS(swiftListener, *swiftopt.tlsCertificate, *swiftopt.tlsPrivateKey); err != nil {
+			glog.Fatalf("Swift API Server Fail to serve: %v", err)
+		}
+	} else {
+		glog.V(0).Infof("Start Seaweed Swift API Server %s at http port %d", util.Version(), *swiftopt.port)
+		if err = httpS.Serve(swiftListener); err != nil {
+			glog.Fatalf("Swift API Server Fail to serve: %v", err)
+		}
+	}
+
+	return true
+}
@@ -27,7 +27,9 @@ import (
 	"github.com/chrislusf/seaweedfs/weed/server"
 	stats_collect "github.com/chrislusf/seaweedfs/weed/stats"
 	"github.com/chrislusf/seaweedfs/weed/storage"
+	"github.com/chrislusf/seaweedfs/weed/storage/backend"
 	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/chrislusf/seaweedfs/weed/util/chunk_cache"
 )
 
 var (
@@ -49,12 +51,17 @@ type VolumeServerOptions struct {
 	rack                    *string
 	whiteList               []string
 	indexType               *string
+	indexCheckpointSeconds  *int
+	rocksDbBlockCacheMB     *int
+	leveldbInlineBytes      *int
 	diskType                *string
 	fixJpgOrientation       *bool
 	readRedirect            *bool
+	readVerifyCrc           *bool
 	cpuProfile              *string
 	memProfile              *string
 	compactionMBPerSecond   *int
+	vacuumWindow            *string
 	fileSizeLimitMB         *int
 	concurrentUploadLimitMB *int
 	minFreeSpacePercents    []float32
@@ -62,7 +69,16 @@ type VolumeServerOptions struct {
 	preStopSeconds          *int
 	metricsHttpPort         *int
 	// pulseSeconds          *int
-	enableTcp *bool
+	enableTcp          *bool
+	ioUring            *bool
+	directIo           *bool
+	scrubMBPerSecond   *int
+	scrubIntervalHours *int
+	tierCacheDir       *string
+	tierCacheSizeMB    *int64
+	softDeleteHours    *string
+	ioLimits           *string
+	zoned              *string
 }
 
 func init() {
@@ -78,19 +94,33 @@ func init() {
 	v.idleConnectionTimeout = cmdVolume.Flag.Int("idleTimeout", 30, "connection idle seconds")
 	v.dataCenter = cmdVolume.Flag.String("dataCenter", "", "current volume server's data center name")
 	v.rack = cmdVolume.Flag.String("rack", "", "current volume server's rack name")
-	v.indexType = cmdVolume.Flag.String("index", "memory", "Choose [memory|leveldb|leveldbMedium|leveldbLarge] mode for memory~performance balance.")
+	v.indexType = cmdVolume.Flag.String("index", "memory", "Choose [memory|leveldb|leveldbMedium|leveldbLarge|rocksdb] mode for memory~performance balance.")
+	v.indexCheckpointSeconds = cmdVolume.Flag.Int("index.checkpointSeconds", 0, "if positive, save the memory index of changed volumes every this many seconds and on shutdown, so the startup only replays the .idx entries after the checkpoints")
+	v.rocksDbBlockCacheMB = cmdVolume.Flag.Int("index.rocksdbBlockCacheMB", 64, "block cache size in MB shared by all volumes, for -index=rocksdb")
+	v.leveldbInlineBytes = cmdVolume.Flag.Int("index.leveldbInlineBytes", 0, "if positive, also keep the needles up to this size on disk, e.g. 256, inside the leveldb index to read them without seeking the .dat file, for -index=leveldb*. Remove the .ldb files before downgrading.")
 	v.diskType = cmdVolume.Flag.String("disk", "", "[hdd|ssd|<tag>] hard drive or solid state drive or any tag")
 	v.fixJpgOrientation = cmdVolume.Flag.Bool("images.fix.orientation", false, "Adjust jpg orientation when uploading.")
 	v.readRedirect = cmdVolume.Flag.Bool("read.redirect", true, "Redirect moved or non-local volumes.")
+	v.readVerifyCrc = cmdVolume.Flag.Bool("read.verifyCrc", false, "mark needles failing the CRC check on read as corrupted and fail the read with a server error, so clients read from other replicas, and repair them in background. Needle blobs sent to other volume servers are also verified.")
 	v.cpuProfile = cmdVolume.Flag.String("cpuprofile", "", "cpu profile output file")
 	v.memProfile = cmdVolume.Flag.String("memprofile", "", "memory profile output file")
 	v.compactionMBPerSecond = cmdVolume.Flag.Int("compactionMBps", 0, "limit background compaction or copying speed in mega bytes per second")
+	v.vacuumWindow = cmdVolume.Flag.String("vacuum.window", "", "comma separated daily time windows in local time to accept vacuum requests, e.g. \"01:00-05:00,22:00-23:30\". Accept at any time if empty.")
 	v.fileSizeLimitMB = cmdVolume.Flag.Int("fileSizeLimitMB", 256, "limit file size to avoid out of memory")
 	v.concurrentUploadLimitMB = cmdVolume.Flag.Int("concurrentUploadLimitMB", 128, "limit total concurrent upload size")
 	v.pprof = cmdVolume.Flag.Bool("pprof", false, "enable pprof http handlers. precludes --memprofile and --cpuprofile")
 	v.metricsHttpPort = cmdVolume.Flag.Int("metricsPort", 0, "Prometheus metrics listen port")
 	v.idxFolder = cmdVolume.Flag.String("dir.idx", "", "directory to store .idx files")
 	v.enableTcp = cmdVolume.Flag.Bool("tcp", false, "<exprimental> enable tcp port")
+	v.ioUring = cmdVolume.Flag.Bool("ioUring", false, "<experimental> read volume files via io_uring on linux, fall back to pread if not supported")
+	v.scrubMBPerSecond = cmdVolume.Flag.Int("scrubMBps", 0, "if positive, scrub needles in background to verify checksums and repair corruptions, limited to this speed in mega bytes per second")
+	v.scrubIntervalHours = cmdVolume.Flag.Int("scrubIntervalHours", 7*24, "scrub each volume once in this many hours")
+	v.directIo = cmdVolume.Flag.Bool("directIo", false, "<experimental> read and write volume .dat files with O_DIRECT on linux, bypassing the page cache")
+	v.tierCacheDir = cmdVolume.Flag.String("tier.cacheDir", os.TempDir(), "local cache directory for volume files tiered to remote storage")
+	v.tierCacheSizeMB = cmdVolume.Flag.Int64("tier.cacheSizeMB", 0, "if positive, cache the blocks read from remote tier volume files, up to this size in MB")
+	v.zoned = cmdVolume.Flag.String("dir.zoned", "", "comma separated true or false of each -dir, whether it is on a host-managed SMR or ZNS device with a zoned file system, to only append to the volume files")
+	v.ioLimits = cmdVolume.Flag.String("dir.ioLimit", "", "comma separated io limits of each -dir, each as colon separated <key>=<value> of readMBps, writeMBps, readIops, writeIops for client traffic, and the same with the \"background\" prefix for vacuum, erasure coding, copying and scrubbing, e.g. \"readMBps=200:backgroundWriteMBps=20\"")
+	v.softDeleteHours = cmdVolume.Flag.String("softDeleteHours", "", "comma separated <collection>:<hours> to keep deleted needles from vacuum for undeleting, '*' for all collections, e.g. \"*:24,tmp:0\"")
 }
 
 var cmdVolume = &Command{
@@ -111,6 +141,7 @@ var (
 func runVolume(cmd *Command, args []string) bool {
 
 	util.LoadConfiguration("security", false)
+	checkEnvOverrides()
 
 	// If --pprof is set we assume the caller wants to be able to collect
 	// cpu and memory profiles via go tool pprof
@@ -186,6 +217,46 @@ func (v VolumeServerOptions) startVolumeServer(volumeFolders, maxVolumeCounts, v
 		glog.Fatalf("%d directories by -dir, but only %d disk types is set by -disk", len(v.folders), len(diskTypes))
 	}
 
+	// set io limits
+	var ioLimits []*storage.DiskIoLimits
+	for _, ioLimitString := range strings.Split(*v.ioLimits, ",") {
+		ioLimit, err := storage.ParseDiskIoLimits(ioLimitString)
+		if err != nil {
+			glog.Fatalf("The value specified in -dir.ioLimit not valid %s: %v", ioLimitString, err)
+		}
+		ioLimits = append(ioLimits, ioLimit)
+	}
+	if len(ioLimits) == 1 && len(v.folders) > 1 {
+		for i := 0; i < len(v.folders)-1; i++ {
+			ioLimits = append(ioLimits, ioLimits[0])
+		}
+	}
+	if len(v.folders) != len(ioLimits) {
+		glog.Fatalf("%d directories by -dir, but only %d io limits is set by -dir.ioLimit", len(v.folders), len(ioLimits))
+	}
+
+	// set zoned directories
+	if *v.zoned != "" {
+		zonedStrings := strings.Split(*v.zoned, ",")
+		if len(zonedStrings) == 1 && len(v.folders) > 1 {
+			for i := 0; i < len(v.folders)-1; i++ {
+				zonedStrings = append(zonedStrings, zonedStrings[0])
+			}
+		}
+		if len(v.folders) != len(zonedStrings) {
+			glog.Fatalf("%d directories by -dir, but only %d values is set by -dir.zoned", len(v.folders), len(zonedStrings))
+		}
+		for i, zonedString := range zonedStrings {
+			zoned, err := strconv.ParseBool(strings.TrimSpace(zonedString))
+			if err != nil {
+				glog.Fatalf("The value specified in -dir.zoned not valid %s: %v", zonedString, err)
+			}
+			if zoned {
+				backend.SetZonedDirectory(util.ResolvePath(v.folders[i]))
+			}
+		}
+	}
+
 	// security related white list configuration
 	if volumeWhiteListOption != "" {
 		v.whiteList = strings.Split(volumeWhiteListOption, ",")
@@ -225,21 +296,65 @@ func (v VolumeServerOptions) startVolumeServer(volumeFolders, maxVolumeCounts, v
 		volumeNeedleMapKind = storage.NeedleMapLevelDbMedium
 	case "leveldbLarge":
 		volumeNeedleMapKind = storage.NeedleMapLevelDbLarge
+	case "rocksdb":
+		if !storage.IsRocksDbNeedleMapSupported() {
+			glog.Fatalf("-index=rocksdb requires building with the rocksdb tag")
+		}
+		volumeNeedleMapKind = storage.NeedleMapRocksDb
+		storage.RocksDbNeedleMapBlockCacheMB = *v.rocksDbBlockCacheMB
+	}
+	if *v.indexCheckpointSeconds > 0 && volumeNeedleMapKind == storage.NeedleMapInMemory {
+		storage.SetIndexCheckpointInterval(time.Duration(*v.indexCheckpointSeconds) * time.Second)
+	}
+	if *v.leveldbInlineBytes > 0 {
+		storage.SetInlineNeedleSizeLimit(int64(*v.leveldbInlineBytes))
+	}
+
+	if *v.ioUring {
+		if err := backend.EnableIoUring(256); err != nil {
+			glog.Warningf("io_uring is not available, using pread: %v", err)
+		}
+	}
+	if *v.directIo {
+		if err := backend.EnableDirectIo(); err != nil {
+			glog.Warningf("direct io is not available, using buffered io: %v", err)
+		}
+	}
+
+	if *v.tierCacheSizeMB > 0 {
+		glog.V(0).Infof("cache remote tier volume files in %s, up to %d MB", *v.tierCacheDir, *v.tierCacheSizeMB)
+		storage.SetRemoteTierCache(chunk_cache.NewTieredChunkCache(256, *v.tierCacheDir, *v.tierCacheSizeMB, storage.RemoteTierCacheBlockSize))
+	}
+
+	if *v.softDeleteHours != "" {
+		gracePeriods, err := storage.ParseSoftDeleteGracePeriods(*v.softDeleteHours)
+		if err != nil {
+			glog.Fatalf("-softDeleteHours: %v", err)
+		}
+		storage.SetSoftDeleteGracePeriods(gracePeriods)
+	}
+
+	vacuumWindows, err := util.ParseTimeWindows(*v.vacuumWindow)
+	if err != nil {
+		glog.Fatalf("-vacuum.window %s: %v", *v.vacuumWindow, err)
 	}
 
 	masters := *v.masters
 
 	volumeServer := weed_server.NewVolumeServer(volumeMux, publicVolumeMux,
 		*v.ip, *v.port, *v.publicUrl,
-		v.folders, v.folderMaxLimits, v.minFreeSpacePercents, diskTypes,
+		v.folders, v.folderMaxLimits, v.minFreeSpacePercents, diskTypes, ioLimits,
 		*v.idxFolder,
 		volumeNeedleMapKind,
 		strings.Split(masters, ","), 5, *v.dataCenter, *v.rack,
 		v.whiteList,
-		*v.fixJpgOrientation, *v.readRedirect,
+		*v.fixJpgOrientation, *v.readRedirect, *v.readVerifyCrc,
 		*v.compactionMBPerSecond,
+		vacuumWindows,
 		*v.fileSizeLimitMB,
 		int64(*v.concurrentUploadLimitMB)*1024*1024,
+		*v.scrubMBPerSecond,
+		time.Duration(*v.scrubIntervalHours)*time.Hour,
 	)
 	// starting grpc server
 	grpcS := v.startGrpcService(volumeServer)
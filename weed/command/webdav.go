@@ -95,6 +95,7 @@ func (wo *WebDavOption) startWebDav() bool {
 				return fmt.Errorf("get filer %s configuration: %v", filerGrpcAddress, err)
 			}
 			cipher = resp.Cipher
+			util.SetCompressionPolicy(resp.CompressionPolicy.ToCompressionPolicy())
 			return nil
 		})
 		if err != nil {
@@ -123,7 +124,7 @@ func (wo *WebDavOption) startWebDav() bool {
 		glog.Fatalf("WebDav Server startup error: %v", webdavServer_err)
 	}
 
-	httpS := &http.Server{Handler: ws.Handler}
+	httpS := &http.Server{Handler: ws}
 
 	listenAddress := fmt.Sprintf(":%d", *wo.port)
 	webDavListener, err := util.NewListener(listenAddress, time.Duration(10)*time.Second)
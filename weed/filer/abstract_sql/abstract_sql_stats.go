@@ -0,0 +1,197 @@
+package abstract_sql
+
+import (
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/karlseguin/ccache/v2"
+)
+
+/*
+The store keeps lightweight statistics of the directories, to choose how to list a directory with a name prefix:
+
+	index scan   reads the directory entries in name order from the start file name, and filters them
+	             by the prefix, which is simple and fast for small directories
+	prefix scan  reads only the entries in the name range of the prefix, which avoids reading through
+	             the large directories, or the directories with large entries
+
+The prefix scan is only used by the sql generators ordering the names in binary, see SqlRangeGenerator.
+
+The statistics are the entry count, and a histogram of the encoded entry sizes. They are kept in memory,
+updated by the inserts and deletes on this filer, and sampled from the full listings, so they are approximate,
+and only kept for an hour for the recently used directories, since other filers may change the same store. The directories without statistics are listed by prefix scan.
+*/
+
+const (
+	dirStatsCacheSize       = 10000
+	dirStatsTtl             = time.Hour
+	dirStatsMaxSizeSamples  = 1024
+	indexScanMaxEntries     = 1000
+	indexScanMaxScannedSize = 4 * 1024 * 1024
+)
+
+// the upper bounds of the entry size histogram buckets, the last bucket is for larger entries
+var entrySizeBuckets = []int{256, 1024, 4 * 1024, 16 * 1024, 64 * 1024}
+
+// SqlRangeGenerator is implemented by the sql generators ordering the names in binary,
+// to list the names in a range, with the arguments of dirhash, start name, end name(exclusive), directory, name pattern, limit.
+type SqlRangeGenerator interface {
+	GetSqlListRangeExclusive(tableName string) string
+	GetSqlListRangeInclusive(tableName string) string
+}
+
+type listStrategy int
+
+const (
+	listByIndexScan listStrategy = iota
+	listByPrefixScan
+)
+
+type dirStats struct {
+	entryCount    int64
+	sizeHistogram []int64
+	sync.Mutex
+}
+
+func newDirStats() *dirStats {
+	return &dirStats{
+		sizeHistogram: make([]int64, len(entrySizeBuckets)+1),
+	}
+}
+
+func (ds *dirStats) addSizeSample(size int) {
+	bucket := len(entrySizeBuckets)
+	for i, upperBound := range entrySizeBuckets {
+		if size <= upperBound {
+			bucket = i
+			break
+		}
+	}
+	var total int64
+	for _, count := range ds.sizeHistogram {
+		total += count
+	}
+	if total >= dirStatsMaxSizeSamples {
+		// decay the old samples
+		for i := range ds.sizeHistogram {
+			ds.sizeHistogram[i] /= 2
+		}
+	}
+	ds.sizeHistogram[bucket]++
+}
+
+// estimatedScannedSize assumes each entry is at the upper bound of its size bucket.
+func (ds *dirStats) estimatedScannedSize() int64 {
+	var total, weighted int64
+	for i, count := range ds.sizeHistogram {
+		upperBound := 2 * entrySizeBuckets[len(entrySizeBuckets)-1]
+		if i < len(entrySizeBuckets) {
+			upperBound = entrySizeBuckets[i]
+		}
+		total += count
+		weighted += count * int64(upperBound)
+	}
+	if total == 0 {
+		return 0
+	}
+	return ds.entryCount * weighted / total
+}
+
+func (store *AbstractSqlStore) getDirStats(dir string, create bool) *dirStats {
+	store.dirStatsOnce.Do(func() {
+		store.dirStats = ccache.New(ccache.Configure().MaxSize(dirStatsCacheSize))
+	})
+	if item := store.dirStats.Get(dir); item != nil {
+		return item.Value().(*dirStats)
+	}
+	if !create {
+		return nil
+	}
+	ds := newDirStats()
+	store.dirStats.Set(dir, ds, dirStatsTtl)
+	return ds
+}
+
+// onInserted counts a new entry, only in the directories with statistics, since the total count is unknown otherwise.
+func (store *AbstractSqlStore) onInserted(dir string, size int) {
+	if ds := store.getDirStats(dir, false); ds != nil {
+		ds.Lock()
+		ds.entryCount++
+		ds.addSizeSample(size)
+		ds.Unlock()
+	}
+}
+
+func (store *AbstractSqlStore) onDeleted(dir string) {
+	if ds := store.getDirStats(dir, false); ds != nil {
+		ds.Lock()
+		if ds.entryCount > 0 {
+			ds.entryCount--
+		}
+		ds.Unlock()
+	}
+}
+
+func (store *AbstractSqlStore) onFolderChildrenDeleted(dir string) {
+	if ds := store.getDirStats(dir, false); ds != nil {
+		ds.Lock()
+		ds.entryCount = 0
+		ds.Unlock()
+	}
+}
+
+// onListed samples a listing from the first entry without a prefix.
+// The entry count is exact if the listing reached the end, or at least the listed count otherwise.
+func (store *AbstractSqlStore) onListed(dir string, entrySizes []int, reachedEnd bool) {
+	ds := store.getDirStats(dir, true)
+	ds.Lock()
+	defer ds.Unlock()
+	listedCount := int64(len(entrySizes))
+	if reachedEnd || ds.entryCount < listedCount {
+		ds.entryCount = listedCount
+	}
+	for _, size := range entrySizes {
+		ds.addSizeSample(size)
+	}
+}
+
+func (store *AbstractSqlStore) chooseListStrategy(dir, prefix string) listStrategy {
+	if prefix == "" {
+		return listByIndexScan
+	}
+	if _, ok := store.SqlGenerator.(SqlRangeGenerator); !ok {
+		return listByIndexScan
+	}
+	if _, ok := prefixUpperBound(prefix); !ok {
+		return listByIndexScan
+	}
+	ds := store.getDirStats(dir, false)
+	if ds == nil {
+		return listByPrefixScan
+	}
+	ds.Lock()
+	defer ds.Unlock()
+	if ds.entryCount <= indexScanMaxEntries && ds.estimatedScannedSize() <= indexScanMaxScannedSize {
+		return listByIndexScan
+	}
+	return listByPrefixScan
+}
+
+// prefixUpperBound is the smallest name larger than all the names with the prefix, in binary order.
+func prefixUpperBound(prefix string) (upperBound string, ok bool) {
+	for prefix != "" {
+		r, size := utf8.DecodeLastRuneInString(prefix)
+		prefix = prefix[:len(prefix)-size]
+		if r == utf8.RuneError || r == utf8.MaxRune {
+			continue
+		}
+		r++
+		if 0xD800 <= r && r <= 0xDFFF {
+			// skip the surrogates, which are not valid in utf8
+			r = 0xE000
+		}
+		return prefix + string(r), true
+	}
+	return "", false
+}
@@ -0,0 +1,124 @@
+package abstract_sql
+
+import (
+	"testing"
+)
+
+type fakeRangeGenerator struct {
+	SqlGenerator
+}
+
+func (gen *fakeRangeGenerator) GetSqlListRangeExclusive(tableName string) string {
+	return ""
+}
+
+func (gen *fakeRangeGenerator) GetSqlListRangeInclusive(tableName string) string {
+	return ""
+}
+
+func TestPrefixUpperBound(t *testing.T) {
+	tests := []struct {
+		prefix     string
+		upperBound string
+		ok         bool
+	}{
+		{"", "", false},
+		{"abc", "abd", true},
+		{"a\U0010FFFF", "b", true},
+		{"\U0010FFFF", "", false},
+		{"a\uD7FF", "a\uE000", true},
+		{"ab\xff", "ac", true},
+	}
+	for _, tt := range tests {
+		upperBound, ok := prefixUpperBound(tt.prefix)
+		if upperBound != tt.upperBound || ok != tt.ok {
+			t.Errorf("prefixUpperBound(%q) = %q, %v, expected %q, %v", tt.prefix, upperBound, ok, tt.upperBound, tt.ok)
+		}
+	}
+}
+
+func TestChooseListStrategy(t *testing.T) {
+	store := &AbstractSqlStore{}
+	if s := store.chooseListStrategy("/dir", "a"); s != listByIndexScan {
+		t.Errorf("without range generator: %v", s)
+	}
+
+	store.SqlGenerator = &fakeRangeGenerator{}
+	if s := store.chooseListStrategy("/dir", ""); s != listByIndexScan {
+		t.Errorf("without prefix: %v", s)
+	}
+	if s := store.chooseListStrategy("/dir", "a"); s != listByPrefixScan {
+		t.Errorf("without stats: %v", s)
+	}
+
+	// a small directory
+	store.onListed("/dir", []int{100, 200, 300}, true)
+	if s := store.chooseListStrategy("/dir", "a"); s != listByIndexScan {
+		t.Errorf("small directory: %v", s)
+	}
+
+	// grows to a large directory
+	for i := 0; i < indexScanMaxEntries; i++ {
+		store.onInserted("/dir", 100)
+	}
+	if s := store.chooseListStrategy("/dir", "a"); s != listByPrefixScan {
+		t.Errorf("large directory: %v", s)
+	}
+
+	store.onFolderChildrenDeleted("/dir")
+	if s := store.chooseListStrategy("/dir", "a"); s != listByIndexScan {
+		t.Errorf("emptied directory: %v", s)
+	}
+
+	// few but large entries
+	var sizes []int
+	for i := 0; i < 100; i++ {
+		sizes = append(sizes, 100*1024)
+	}
+	store.onListed("/big", sizes, true)
+	if s := store.chooseListStrategy("/big", "a"); s != listByPrefixScan {
+		t.Errorf("large entries: %v", s)
+	}
+}
+
+func TestDirStatsUpdates(t *testing.T) {
+	store := &AbstractSqlStore{}
+
+	// not counted without stats
+	store.onInserted("/dir", 100)
+	if ds := store.getDirStats("/dir", false); ds != nil {
+		t.Fatalf("unexpected stats %+v", ds)
+	}
+
+	// a partial listing counts at least the listed entries
+	store.onListed("/dir", []int{100, 100}, false)
+	store.onListed("/dir", []int{100}, false)
+	ds := store.getDirStats("/dir", false)
+	if ds.entryCount != 2 {
+		t.Errorf("entry count %d, expected 2", ds.entryCount)
+	}
+
+	store.onInserted("/dir", 100)
+	store.onDeleted("/dir")
+	store.onDeleted("/dir")
+	if ds.entryCount != 1 {
+		t.Errorf("entry count %d, expected 1", ds.entryCount)
+	}
+
+	// a complete listing is exact
+	store.onListed("/dir", []int{100, 100, 100, 100}, true)
+	if ds.entryCount != 4 {
+		t.Errorf("entry count %d, expected 4", ds.entryCount)
+	}
+
+	for i := 0; i < 10*dirStatsMaxSizeSamples; i++ {
+		ds.addSizeSample(100)
+	}
+	var total int64
+	for _, count := range ds.sizeHistogram {
+		total += count
+	}
+	if total > dirStatsMaxSizeSamples {
+		t.Errorf("size samples %d exceeds %d", total, dirStatsMaxSizeSamples)
+	}
+}
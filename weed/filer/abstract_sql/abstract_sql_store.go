@@ -8,6 +8,7 @@ import (
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
 	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/karlseguin/ccache/v2"
 	"strings"
 	"sync"
 )
@@ -24,15 +25,28 @@ type SqlGenerator interface {
 	GetSqlDropTable(tableName string) string
 }
 
+// SqlBatchDeleteGenerator is implemented by the sql generators deleting the folder children
+// with a LIMIT of the batch size, to avoid too large transactions. The deletes are repeated until fewer rows are deleted.
+type SqlBatchDeleteGenerator interface {
+	GetDeleteFolderChildrenBatchSize() int64
+}
+
 type AbstractSqlStore struct {
 	SqlGenerator
 	DB                 *sql.DB
 	SupportBucketTable bool
+	BucketsFolder      string // the folder of the bucket tables, default to /buckets
 	dbs                map[string]bool
 	dbsLock            sync.Mutex
+	dirStats           *ccache.Cache
+	dirStatsOnce       sync.Once
 }
 
 func (store *AbstractSqlStore) OnBucketCreation(bucket string) {
+	if !isValidBucket(bucket) {
+		return
+	}
+
 	store.dbsLock.Lock()
 	defer store.dbsLock.Unlock()
 
@@ -44,6 +58,10 @@ func (store *AbstractSqlStore) OnBucketCreation(bucket string) {
 	store.dbs[bucket] = true
 }
 func (store *AbstractSqlStore) OnBucketDeletion(bucket string) {
+	if !isValidBucket(bucket) {
+		return
+	}
+
 	store.dbsLock.Lock()
 	defer store.dbsLock.Unlock()
 
@@ -56,7 +74,11 @@ func (store *AbstractSqlStore) OnBucketDeletion(bucket string) {
 }
 
 const (
-	DEFAULT_TABLE = "filemeta"
+	DEFAULT_TABLE          = "filemeta"
+	DEFAULT_BUCKETS_FOLDER = "/buckets"
+
+	// MySQL allows 64 bytes and PostgreSQL 63, the same as the longest S3 bucket name
+	maxBucketTableNameLength = 63
 )
 
 type TxOrDB interface {
@@ -104,40 +126,56 @@ func (store *AbstractSqlStore) getTxOrDB(ctx context.Context, fullpath util.Full
 		return
 	}
 
-	if !strings.HasPrefix(string(fullpath), "/buckets/") {
+	tableBucket, bucketPath, found := store.splitBucketPath(fullpath, isForChildren)
+	if !found {
 		return
 	}
+	bucket, shortPath = tableBucket, bucketPath
 
-	// detect bucket
-	bucketAndObjectKey := string(fullpath)[len("/buckets/"):]
+	store.dbsLock.Lock()
+	defer store.dbsLock.Unlock()
+
+	if store.dbs == nil {
+		store.dbs = make(map[string]bool)
+	}
+
+	if _, found := store.dbs[bucket]; !found {
+		if err = store.CreateTable(ctx, bucket); err == nil {
+			store.dbs[bucket] = true
+		}
+	}
+
+	return
+}
+
+// splitBucketPath returns the bucket table and the path inside the bucket, for the entries in the buckets folder.
+// The bucket folder itself is kept in the default table, except when listing or deleting its children.
+func (store *AbstractSqlStore) splitBucketPath(fullpath util.FullPath, isForChildren bool) (bucket string, shortPath util.FullPath, found bool) {
+	bucketsFolder := strings.TrimSuffix(store.BucketsFolder, "/")
+	if bucketsFolder == "" {
+		bucketsFolder = DEFAULT_BUCKETS_FOLDER
+	}
+	if !strings.HasPrefix(string(fullpath), bucketsFolder+"/") {
+		return
+	}
+
+	bucketAndObjectKey := string(fullpath)[len(bucketsFolder)+1:]
 	t := strings.Index(bucketAndObjectKey, "/")
 	if t < 0 && !isForChildren {
 		return
 	}
 	bucket = bucketAndObjectKey
 	shortPath = "/"
-	if t > 0 {
+	if t >= 0 {
 		bucket = bucketAndObjectKey[:t]
 		shortPath = util.FullPath(bucketAndObjectKey[t:])
 	}
 
-	if isValidBucket(bucket) {
-		store.dbsLock.Lock()
-		defer store.dbsLock.Unlock()
-
-		if store.dbs == nil {
-			store.dbs = make(map[string]bool)
-		}
-
-		if _, found := store.dbs[bucket]; !found {
-			if err = store.CreateTable(ctx, bucket); err == nil {
-				store.dbs[bucket] = true
-			}
-		}
-
+	// the other names stay in the default table with their full paths
+	if !isValidBucket(bucket) {
+		return "", "", false
 	}
-
-	return
+	return bucket, shortPath, true
 }
 
 func (store *AbstractSqlStore) InsertEntry(ctx context.Context, entry *filer.Entry) (err error) {
@@ -159,6 +197,11 @@ func (store *AbstractSqlStore) InsertEntry(ctx context.Context, entry *filer.Ent
 
 	res, err := db.ExecContext(ctx, store.GetSqlInsert(bucket), util.HashStringToLong(dir), name, dir, meta)
 	if err == nil {
+		// an upsert may also count the updates as inserted
+		if affected, _ := res.RowsAffected(); affected == 1 {
+			entryDir, _ := entry.FullPath.DirAndName()
+			store.onInserted(entryDir, len(meta))
+		}
 		return
 	}
 
@@ -250,10 +293,14 @@ func (store *AbstractSqlStore) DeleteEntry(ctx context.Context, fullpath util.Fu
 		return fmt.Errorf("delete %s: %s", fullpath, err)
 	}
 
-	_, err = res.RowsAffected()
+	affected, err := res.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("delete %s but no rows affected: %s", fullpath, err)
 	}
+	if affected == 1 {
+		entryDir, _ := fullpath.DirAndName()
+		store.onDeleted(entryDir)
+	}
 
 	return nil
 }
@@ -276,15 +323,26 @@ func (store *AbstractSqlStore) DeleteFolderChildren(ctx context.Context, fullpat
 		}
 	}
 
-	res, err := db.ExecContext(ctx, store.GetSqlDeleteFolderChildren(bucket), util.HashStringToLong(string(shortPath)), fullpath)
-	if err != nil {
-		return fmt.Errorf("deleteFolderChildren %s: %s", fullpath, err)
+	var batchSize int64
+	if batchDeleteGenerator, ok := store.SqlGenerator.(SqlBatchDeleteGenerator); ok {
+		batchSize = batchDeleteGenerator.GetDeleteFolderChildrenBatchSize()
 	}
 
-	_, err = res.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("deleteFolderChildren %s but no rows affected: %s", fullpath, err)
+	for {
+		res, err := db.ExecContext(ctx, store.GetSqlDeleteFolderChildren(bucket), util.HashStringToLong(string(shortPath)), fullpath)
+		if err != nil {
+			return fmt.Errorf("deleteFolderChildren %s: %s", fullpath, err)
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("deleteFolderChildren %s but no rows affected: %s", fullpath, err)
+		}
+		if batchSize <= 0 || affected < batchSize {
+			break
+		}
 	}
+	store.onFolderChildrenDeleted(string(fullpath))
 
 	return nil
 }
@@ -296,17 +354,34 @@ func (store *AbstractSqlStore) ListDirectoryPrefixedEntries(ctx context.Context,
 		return lastFileName, fmt.Errorf("findDB %s : %v", dirPath, err)
 	}
 
-	sqlText := store.GetSqlListExclusive(bucket)
-	if includeStartFile {
-		sqlText = store.GetSqlListInclusive(bucket)
+	var rows *sql.Rows
+	if store.chooseListStrategy(string(dirPath), prefix) == listByPrefixScan {
+		rangeGenerator := store.SqlGenerator.(SqlRangeGenerator)
+		upperBound, _ := prefixUpperBound(prefix)
+		sqlText := rangeGenerator.GetSqlListRangeExclusive(bucket)
+		if startFileName < prefix {
+			startFileName, includeStartFile = prefix, true
+		}
+		if includeStartFile {
+			sqlText = rangeGenerator.GetSqlListRangeInclusive(bucket)
+		}
+		rows, err = db.QueryContext(ctx, sqlText, util.HashStringToLong(string(shortPath)), startFileName, upperBound, string(shortPath), prefix+"%", limit+1)
+	} else {
+		sqlText := store.GetSqlListExclusive(bucket)
+		if includeStartFile {
+			sqlText = store.GetSqlListInclusive(bucket)
+		}
+		rows, err = db.QueryContext(ctx, sqlText, util.HashStringToLong(string(shortPath)), startFileName, string(shortPath), prefix+"%", limit+1)
 	}
-
-	rows, err := db.QueryContext(ctx, sqlText, util.HashStringToLong(string(shortPath)), startFileName, string(shortPath), prefix+"%", limit+1)
 	if err != nil {
 		return lastFileName, fmt.Errorf("list %s : %v", dirPath, err)
 	}
 	defer rows.Close()
 
+	// sample the statistics from the full listings
+	isSampling := startFileName == "" && prefix == ""
+	var entrySizes []int
+
 	for rows.Next() {
 		var name string
 		var data []byte
@@ -315,6 +390,9 @@ func (store *AbstractSqlStore) ListDirectoryPrefixedEntries(ctx context.Context,
 			return lastFileName, fmt.Errorf("scan %s: %v", dirPath, err)
 		}
 		lastFileName = name
+		if isSampling {
+			entrySizes = append(entrySizes, len(data))
+		}
 
 		entry := &filer.Entry{
 			FullPath: util.NewFullPath(string(dirPath), name),
@@ -325,24 +403,33 @@ func (store *AbstractSqlStore) ListDirectoryPrefixedEntries(ctx context.Context,
 		}
 
 		if !eachEntryFunc(entry) {
+			isSampling = false
 			break
 		}
 
 	}
 
+	if isSampling && rows.Err() == nil {
+		store.onListed(string(dirPath), entrySizes, int64(len(entrySizes)) <= limit)
+	}
+
 	return lastFileName, nil
 }
 
 func (store *AbstractSqlStore) ListDirectoryEntries(ctx context.Context, dirPath util.FullPath, startFileName string, includeStartFile bool, limit int64, eachEntryFunc filer.ListEachEntryFunc) (lastFileName string, err error) {
-	return store.ListDirectoryPrefixedEntries(ctx, dirPath, startFileName, includeStartFile, limit, "", nil)
+	return store.ListDirectoryPrefixedEntries(ctx, dirPath, startFileName, includeStartFile, limit, "", eachEntryFunc)
 }
 
 func (store *AbstractSqlStore) Shutdown() {
 	store.DB.Close()
 }
 
+// isValidBucket checks the bucket can be a table name, quoted by the sql generators.
 func isValidBucket(bucket string) bool {
-	return bucket != DEFAULT_TABLE && bucket != ""
+	if bucket == DEFAULT_TABLE || bucket == "" || len(bucket) > maxBucketTableNameLength {
+		return false
+	}
+	return !strings.ContainsAny(bucket, "`\"'\\\x00")
 }
 
 func (store *AbstractSqlStore) CreateTable(ctx context.Context, bucket string) error {
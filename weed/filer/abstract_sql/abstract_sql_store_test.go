@@ -0,0 +1,37 @@
+package abstract_sql
+
+import (
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func TestSplitBucketPath(t *testing.T) {
+	tests := []struct {
+		bucketsFolder string
+		fullpath      util.FullPath
+		isForChildren bool
+		bucket        string
+		shortPath     util.FullPath
+		found         bool
+	}{
+		{"", "/buckets/b1/dir/file", false, "b1", "/dir/file", true},
+		{"", "/buckets/b1/file", false, "b1", "/file", true},
+		{"", "/buckets/b1", false, "", "", false},
+		{"", "/buckets/b1", true, "b1", "/", true},
+		{"", "/buckets", true, "", "", false},
+		{"", "/bucketsx/b1/file", false, "", "", false},
+		{"", "/buckets/filemeta/file", false, "", "", false},
+		{"", "/buckets/a`b/file", false, "", "", false},
+		{"/s3/", "/s3/b1/file", false, "b1", "/file", true},
+		{"/s3", "/buckets/b1/file", false, "", "", false},
+	}
+	for _, test := range tests {
+		store := &AbstractSqlStore{BucketsFolder: test.bucketsFolder}
+		bucket, shortPath, found := store.splitBucketPath(test.fullpath, test.isForChildren)
+		if bucket != test.bucket || shortPath != test.shortPath || found != test.found {
+			t.Errorf("splitBucketPath(%q, %v) in %q = %q, %q, %v, expected %q, %q, %v", test.fullpath, test.isForChildren, test.bucketsFolder,
+				bucket, shortPath, found, test.bucket, test.shortPath, test.found)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+//go:build badger
+// +build badger
+
+package badger
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	weed_util "github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+The badger store is a pure Go embedded store for single node filers, with the same key layout as rocksdb:
+<md5 of the directory><file name>, so the children of a directory are one key range.
+
+Badger keeps the values in a value log, separated from the sorted keys, so the directory scans
+only read the small keys until the values are needed, and deleting a folder iterates the keys only.
+The value log space of the updated and deleted entries is reclaimed by the periodic value log gc.
+*/
+
+const deleteBatchSize = 10000
+
+func init() {
+	filer.Stores = append(filer.Stores, &BadgerStore{})
+}
+
+type BadgerStore struct {
+	dir    string
+	db     *badger.DB
+	stopGc chan struct{}
+}
+
+func (store *BadgerStore) GetName() string {
+	return "badger"
+}
+
+func (store *BadgerStore) Initialize(configuration weed_util.Configuration, prefix string) (err error) {
+	configuration.SetDefault(prefix+"value_log_gc_interval_seconds", 600)
+	configuration.SetDefault(prefix+"value_log_gc_discard_ratio", "0.5")
+	dir := configuration.GetString(prefix + "dir")
+	var discardRatio float64
+	if _, err = fmt.Sscanf(configuration.GetString(prefix+"value_log_gc_discard_ratio"), "%g", &discardRatio); err != nil {
+		return fmt.Errorf("invalid value_log_gc_discard_ratio: %v", err)
+	}
+	return store.initialize(dir, configuration.GetBool(prefix+"sync_writes"),
+		time.Duration(configuration.GetInt(prefix+"value_log_gc_interval_seconds"))*time.Second, discardRatio)
+}
+
+func (store *BadgerStore) initialize(dir string, syncWrites bool, gcInterval time.Duration, discardRatio float64) (err error) {
+	glog.Infof("filer store badger dir: %s", dir)
+	os.MkdirAll(dir, 0755)
+	if err := weed_util.TestFolderWritable(dir); err != nil {
+		return fmt.Errorf("Check Badger Folder %s Writable: %s", dir, err)
+	}
+
+	opts := badger.DefaultOptions(dir).
+		WithSyncWrites(syncWrites).
+		WithLogger(nil)
+	if store.db, err = badger.Open(opts); err != nil {
+		return fmt.Errorf("open badger %s: %v", dir, err)
+	}
+	store.dir = dir
+
+	store.stopGc = make(chan struct{})
+	if gcInterval > 0 {
+		go store.loopValueLogGc(gcInterval, discardRatio)
+	}
+	return nil
+}
+
+// loopValueLogGc rewrites the value log files with more than the discard ratio of stale values,
+// running repeatedly since each run rewrites at most one file.
+func (store *BadgerStore) loopValueLogGc(interval time.Duration, discardRatio float64) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-store.stopGc:
+			return
+		case <-ticker.C:
+		}
+		for {
+			if err := store.db.RunValueLogGC(discardRatio); err != nil {
+				if err != badger.ErrNoRewrite {
+					glog.V(0).Infof("badger value log gc: %v", err)
+				}
+				break
+			}
+			glog.V(1).Infof("badger value log gc rewrote a value log file")
+		}
+	}
+}
+
+func (store *BadgerStore) BeginTransaction(ctx context.Context) (context.Context, error) {
+	return ctx, nil
+}
+func (store *BadgerStore) CommitTransaction(ctx context.Context) error {
+	return nil
+}
+func (store *BadgerStore) RollbackTransaction(ctx context.Context) error {
+	return nil
+}
+
+func (store *BadgerStore) InsertEntry(ctx context.Context, entry *filer.Entry) (err error) {
+	dir, name := entry.DirAndName()
+	key := genKey(dir, name)
+
+	value, err := entry.EncodeAttributesAndChunks()
+	if err != nil {
+		return fmt.Errorf("encoding %s %+v: %v", entry.FullPath, entry.Attr, err)
+	}
+
+	if len(entry.Chunks) > 50 {
+		value = weed_util.MaybeGzipData(value)
+	}
+
+	err = store.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(key, value)
+	})
+
+	if err != nil {
+		return fmt.Errorf("persisting %s : %v", entry.FullPath, err)
+	}
+
+	return nil
+}
+
+func (store *BadgerStore) UpdateEntry(ctx context.Context, entry *filer.Entry) (err error) {
+
+	return store.InsertEntry(ctx, entry)
+}
+
+func (store *BadgerStore) FindEntry(ctx context.Context, fullpath weed_util.FullPath) (entry *filer.Entry, err error) {
+	dir, name := fullpath.DirAndName()
+	key := genKey(dir, name)
+
+	var data []byte
+	err = store.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err != nil {
+			return err
+		}
+		data, err = item.ValueCopy(nil)
+		return err
+	})
+
+	if err == badger.ErrKeyNotFound {
+		return nil, filer_pb.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get %s : %v", fullpath, err)
+	}
+
+	entry = &filer.Entry{
+		FullPath: fullpath,
+	}
+	err = entry.DecodeAttributesAndChunks(weed_util.MaybeDecompressData(data))
+	if err != nil {
+		return entry, fmt.Errorf("decode %s : %v", entry.FullPath, err)
+	}
+
+	return entry, nil
+}
+
+func (store *BadgerStore) DeleteEntry(ctx context.Context, fullpath weed_util.FullPath) (err error) {
+	dir, name := fullpath.DirAndName()
+	key := genKey(dir, name)
+
+	err = store.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(key)
+	})
+	if err != nil {
+		return fmt.Errorf("delete %s : %v", fullpath, err)
+	}
+
+	return nil
+}
+
+// DeleteFolderChildren iterates the keys only, and deletes them in write batches.
+func (store *BadgerStore) DeleteFolderChildren(ctx context.Context, fullpath weed_util.FullPath) (err error) {
+	directoryPrefix := genDirectoryKeyPrefix(fullpath, "")
+
+	batch := store.db.NewWriteBatch()
+	defer batch.Cancel()
+
+	err = store.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = directoryPrefix
+		iter := txn.NewIterator(opts)
+		defer iter.Close()
+		for iter.Seek(directoryPrefix); iter.ValidForPrefix(directoryPrefix); iter.Next() {
+			if err := batch.Delete(iter.Item().KeyCopy(nil)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		err = batch.Flush()
+	}
+	if err != nil {
+		return fmt.Errorf("delete %s : %v", fullpath, err)
+	}
+
+	return nil
+}
+
+func (store *BadgerStore) ListDirectoryEntries(ctx context.Context, dirPath weed_util.FullPath, startFileName string, includeStartFile bool, limit int64, eachEntryFunc filer.ListEachEntryFunc) (lastFileName string, err error) {
+	return store.ListDirectoryPrefixedEntries(ctx, dirPath, startFileName, includeStartFile, limit, "", eachEntryFunc)
+}
+
+func (store *BadgerStore) ListDirectoryPrefixedEntries(ctx context.Context, dirPath weed_util.FullPath, startFileName string, includeStartFile bool, limit int64, prefix string, eachEntryFunc filer.ListEachEntryFunc) (lastFileName string, err error) {
+
+	directoryPrefix := genDirectoryKeyPrefix(dirPath, prefix)
+	lastFileStart := directoryPrefix
+	if startFileName != "" {
+		lastFileStart = genDirectoryKeyPrefix(dirPath, startFileName)
+	}
+
+	err = store.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = directoryPrefix
+		if limit > 0 && limit < int64(opts.PrefetchSize) {
+			opts.PrefetchSize = int(limit)
+		}
+		iter := txn.NewIterator(opts)
+		defer iter.Close()
+
+		i := int64(0)
+		for iter.Seek(lastFileStart); iter.ValidForPrefix(directoryPrefix); iter.Next() {
+			item := iter.Item()
+			key := item.Key()
+			if !includeStartFile && startFileName != "" && string(key) == string(lastFileStart) {
+				continue
+			}
+			if limit > 0 {
+				i++
+				if i > limit {
+					break
+				}
+			}
+
+			fileName := getNameFromKey(key)
+			if fileName == "" {
+				continue
+			}
+			entry := &filer.Entry{
+				FullPath: weed_util.NewFullPath(string(dirPath), fileName),
+			}
+			lastFileName = fileName
+
+			if err := item.Value(func(value []byte) error {
+				return entry.DecodeAttributesAndChunks(weed_util.MaybeDecompressData(value))
+			}); err != nil {
+				glog.V(0).Infof("list %s : %v", entry.FullPath, err)
+				return err
+			}
+			if !eachEntryFunc(entry) {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return lastFileName, fmt.Errorf("prefix list %s : %v", dirPath, err)
+	}
+
+	return lastFileName, err
+}
+
+func genKey(dirPath, fileName string) (key []byte) {
+	key = hashToBytes(dirPath)
+	key = append(key, []byte(fileName)...)
+	return key
+}
+
+func genDirectoryKeyPrefix(fullpath weed_util.FullPath, startFileName string) (keyPrefix []byte) {
+	keyPrefix = hashToBytes(string(fullpath))
+	if len(startFileName) > 0 {
+		keyPrefix = append(keyPrefix, []byte(startFileName)...)
+	}
+	return keyPrefix
+}
+
+func getNameFromKey(key []byte) string {
+
+	return string(key[md5.Size:])
+
+}
+
+func hashToBytes(dir string) []byte {
+	h := md5.New()
+	io.WriteString(h, dir)
+
+	b := h.Sum(nil)
+
+	return b
+}
+
+func (store *BadgerStore) Shutdown() {
+	close(store.stopGc)
+	if err := store.db.Close(); err != nil {
+		glog.Errorf("close badger %s: %v", store.dir, err)
+	}
+}
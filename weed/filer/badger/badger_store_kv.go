@@ -0,0 +1,61 @@
+//go:build badger
+// +build badger
+
+package badger
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+)
+
+func (store *BadgerStore) KvPut(ctx context.Context, key []byte, value []byte) (err error) {
+
+	err = store.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(key, value)
+	})
+
+	if err != nil {
+		return fmt.Errorf("kv put: %v", err)
+	}
+
+	return nil
+}
+
+func (store *BadgerStore) KvGet(ctx context.Context, key []byte) (value []byte, err error) {
+
+	err = store.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err != nil {
+			return err
+		}
+		value, err = item.ValueCopy(nil)
+		return err
+	})
+
+	if err == badger.ErrKeyNotFound {
+		return nil, filer.ErrKvNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("kv get: %v", err)
+	}
+
+	return
+}
+
+func (store *BadgerStore) KvDelete(ctx context.Context, key []byte) (err error) {
+
+	err = store.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(key)
+	})
+
+	if err != nil {
+		return fmt.Errorf("kv delete: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+//go:build badger
+// +build badger
+
+package badger
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func TestCreateAndFind(t *testing.T) {
+	testFiler := filer.NewFiler(nil, nil, "", 0, "", "", "", nil)
+	dir, _ := ioutil.TempDir("", "seaweedfs_filer_test")
+	defer os.RemoveAll(dir)
+	store := &BadgerStore{}
+	store.initialize(dir, false, 0, 0.5)
+	defer store.Shutdown()
+	testFiler.SetStore(store)
+
+	fullpath := util.FullPath("/home/chris/this/is/one/file1.jpg")
+
+	ctx := context.Background()
+
+	entry1 := &filer.Entry{
+		FullPath: fullpath,
+		Attr: filer.Attr{
+			Mode: 0440,
+			Uid:  1234,
+			Gid:  5678,
+		},
+	}
+
+	if err := testFiler.CreateEntry(ctx, entry1, false, false, nil); err != nil {
+		t.Errorf("create entry %v: %v", entry1.FullPath, err)
+		return
+	}
+
+	entry, err := testFiler.FindEntry(ctx, fullpath)
+
+	if err != nil {
+		t.Errorf("find entry: %v", err)
+		return
+	}
+
+	if entry.FullPath != entry1.FullPath {
+		t.Errorf("find wrong entry: %v", entry.FullPath)
+		return
+	}
+
+	// checking one upper directory
+	entries, _, _ := testFiler.ListDirectoryEntries(ctx, util.FullPath("/home/chris/this/is/one"), "", false, 100, "", "")
+	if len(entries) != 1 {
+		t.Errorf("list entries count: %v", len(entries))
+		return
+	}
+
+	// checking one upper directory
+	entries, _, _ = testFiler.ListDirectoryEntries(ctx, util.FullPath("/"), "", false, 100, "", "")
+	if len(entries) != 1 {
+		t.Errorf("list entries count: %v", len(entries))
+		return
+	}
+
+}
+
+func TestEmptyRoot(t *testing.T) {
+	testFiler := filer.NewFiler(nil, nil, "", 0, "", "", "", nil)
+	dir, _ := ioutil.TempDir("", "seaweedfs_filer_test2")
+	defer os.RemoveAll(dir)
+	store := &BadgerStore{}
+	store.initialize(dir, false, 0, 0.5)
+	defer store.Shutdown()
+	testFiler.SetStore(store)
+
+	ctx := context.Background()
+
+	// checking one upper directory
+	entries, _, err := testFiler.ListDirectoryEntries(ctx, util.FullPath("/"), "", false, 100, "", "")
+	if err != nil {
+		t.Errorf("list entries: %v", err)
+		return
+	}
+	if len(entries) != 0 {
+		t.Errorf("list entries count: %v", len(entries))
+		return
+	}
+
+}
+
+func TestDeleteFolderChildren(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "seaweedfs_filer_test3")
+	defer os.RemoveAll(dir)
+	store := &BadgerStore{}
+	store.initialize(dir, false, 0, 0.5)
+	defer store.Shutdown()
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		for _, d := range []string{"/a", "/b"} {
+			if err := store.InsertEntry(ctx, &filer.Entry{FullPath: util.NewFullPath(d, fmt.Sprintf("file%d", i))}); err != nil {
+				t.Fatalf("insert: %v", err)
+			}
+		}
+	}
+
+	if err := store.DeleteFolderChildren(ctx, "/a"); err != nil {
+		t.Fatalf("delete folder children: %v", err)
+	}
+
+	count := func(d string) (n int) {
+		store.ListDirectoryEntries(ctx, util.FullPath(d), "", false, 100, func(entry *filer.Entry) bool {
+			n++
+			return true
+		})
+		return
+	}
+	if n := count("/a"); n != 0 {
+		t.Errorf("/a has %d entries after deleting", n)
+	}
+	if n := count("/b"); n != 3 {
+		t.Errorf("/b has %d entries, expected 3", n)
+	}
+
+	var names []string
+	store.ListDirectoryEntries(ctx, "/b", "file0", false, 100, func(entry *filer.Entry) bool {
+		names = append(names, entry.FullPath.Name())
+		return true
+	})
+	if len(names) != 2 || names[0] != "file1" {
+		t.Errorf("list after file0: %v", names)
+	}
+}
+
+func BenchmarkInsertEntry(b *testing.B) {
+	testFiler := filer.NewFiler(nil, nil, "", 0, "", "", "", nil)
+	dir, _ := ioutil.TempDir("", "seaweedfs_filer_bench")
+	defer os.RemoveAll(dir)
+	store := &BadgerStore{}
+	store.initialize(dir, false, 0, 0.5)
+	defer store.Shutdown()
+	testFiler.SetStore(store)
+
+	ctx := context.Background()
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		entry := &filer.Entry{
+			FullPath: util.FullPath(fmt.Sprintf("/file%d.txt", i)),
+			Attr: filer.Attr{
+				Crtime: time.Now(),
+				Mtime:  time.Now(),
+				Mode:   os.FileMode(0644),
+			},
+		}
+		store.InsertEntry(ctx, entry)
+	}
+}
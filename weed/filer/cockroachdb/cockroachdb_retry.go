@@ -0,0 +1,61 @@
+package cockroachdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/lib/pq"
+)
+
+// the sql state of the serialization failures, which CockroachDB asks the clients to retry
+const retryableSqlState = "40001"
+
+// nonRetryableError marks a retryable error which can not be retried, e.g. after some results are returned.
+type nonRetryableError struct {
+	error
+}
+
+// isRetryable checks for the retryable transaction errors, also after they are formatted into other errors.
+func isRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var nonRetryable nonRetryableError
+	if errors.As(err, &nonRetryable) {
+		return false
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == retryableSqlState
+	}
+	message := err.Error()
+	return strings.Contains(message, "restart transaction") || strings.Contains(message, "SQLSTATE "+retryableSqlState)
+}
+
+// retry runs fn again on the retryable errors, with a growing backoff.
+// The statements in a transaction of the context are not retried, since the whole transaction needs to be restarted.
+func (store *CockroachStore) retry(ctx context.Context, name string, fn func() error) (err error) {
+	if _, ok := ctx.Value("tx").(*sql.Tx); ok {
+		return fn()
+	}
+	backoff := store.retryBackoff
+	for i := 0; ; i++ {
+		err = fn()
+		if !isRetryable(err) || i >= store.maxRetries {
+			return err
+		}
+		glog.V(1).Infof("retry %s after %v: %v", name, backoff, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > time.Second {
+			backoff = time.Second
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package cockroachdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestIsRetryable(t *testing.T) {
+	retryErr := &pq.Error{Code: "40001", Message: "restart transaction: TransactionRetryWithProtoRefreshError: WriteTooOldError"}
+
+	tests := []struct {
+		err       error
+		retryable bool
+	}{
+		{nil, false},
+		{retryErr, true},
+		{fmt.Errorf("insert /a: %w", retryErr), true},
+		{fmt.Errorf("insert /a: %s", retryErr), true},
+		{&pq.Error{Code: "23505", Message: "duplicate key value violates unique constraint"}, false},
+		{errors.New("connection refused"), false},
+		{nonRetryableError{retryErr}, false},
+		{fmt.Errorf("list: %w", nonRetryableError{retryErr}), false},
+	}
+	for i, tt := range tests {
+		if got := isRetryable(tt.err); got != tt.retryable {
+			t.Errorf("%d %v: retryable %v, expected %v", i, tt.err, got, tt.retryable)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	store := &CockroachStore{maxRetries: 3, retryBackoff: time.Millisecond}
+	retryErr := &pq.Error{Code: "40001", Message: "restart transaction"}
+
+	attempts := 0
+	err := store.retry(context.Background(), "test", func() error {
+		attempts++
+		if attempts < 3 {
+			return retryErr
+		}
+		return nil
+	})
+	if err != nil || attempts != 3 {
+		t.Errorf("succeed after retries: %v, %d attempts", err, attempts)
+	}
+
+	attempts = 0
+	err = store.retry(context.Background(), "test", func() error {
+		attempts++
+		return retryErr
+	})
+	if err != retryErr || attempts != 4 {
+		t.Errorf("give up after max retries: %v, %d attempts", err, attempts)
+	}
+
+	attempts = 0
+	otherErr := errors.New("other")
+	if err = store.retry(context.Background(), "test", func() error {
+		attempts++
+		return otherErr
+	}); err != otherErr || attempts != 1 {
+		t.Errorf("not retry other errors: %v, %d attempts", err, attempts)
+	}
+
+	// the statements in a transaction are not retried
+	attempts = 0
+	txCtx := context.WithValue(context.Background(), "tx", &sql.Tx{})
+	if err = store.retry(txCtx, "test", func() error {
+		attempts++
+		return retryErr
+	}); err != retryErr || attempts != 1 {
+		t.Errorf("not retry in transaction: %v, %d attempts", err, attempts)
+	}
+}
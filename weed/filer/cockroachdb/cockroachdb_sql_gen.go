@@ -0,0 +1,40 @@
+package cockroachdb
+
+import (
+	"fmt"
+
+	"github.com/chrislusf/seaweedfs/weed/filer/abstract_sql"
+	"github.com/chrislusf/seaweedfs/weed/filer/postgres"
+)
+
+type SqlGenCockroach struct {
+	postgres.SqlGenPostgres
+	DeleteBatchSize int64
+}
+
+var (
+	_ = abstract_sql.SqlGenerator(&SqlGenCockroach{})
+	_ = abstract_sql.SqlRangeGenerator(&SqlGenCockroach{})
+	_ = abstract_sql.SqlBatchDeleteGenerator(&SqlGenCockroach{})
+)
+
+func (gen *SqlGenCockroach) GetSqlInsert(tableName string) string {
+	return fmt.Sprintf(`INSERT INTO "%s" (dirhash,name,directory,meta) VALUES($1,$2,$3,$4) ON CONFLICT (dirhash,name) DO UPDATE SET meta = EXCLUDED.meta`, tableName)
+}
+
+func (gen *SqlGenCockroach) GetSqlDeleteFolderChildren(tableName string) string {
+	return fmt.Sprintf(`DELETE FROM "%s" WHERE dirhash=$1 AND directory=$2 LIMIT %d`, tableName, gen.DeleteBatchSize)
+}
+
+func (gen *SqlGenCockroach) GetDeleteFolderChildrenBatchSize() int64 {
+	return gen.DeleteBatchSize
+}
+
+// the STRING names are ordered in binary
+func (gen *SqlGenCockroach) GetSqlListRangeExclusive(tableName string) string {
+	return fmt.Sprintf(`SELECT NAME, meta FROM "%s" WHERE dirhash=$1 AND name>$2 AND name<$3 AND directory=$4 AND name like $5 ORDER BY NAME ASC LIMIT $6`, tableName)
+}
+
+func (gen *SqlGenCockroach) GetSqlListRangeInclusive(tableName string) string {
+	return fmt.Sprintf(`SELECT NAME, meta FROM "%s" WHERE dirhash=$1 AND name>=$2 AND name<$3 AND directory=$4 AND name like $5 ORDER BY NAME ASC LIMIT $6`, tableName)
+}
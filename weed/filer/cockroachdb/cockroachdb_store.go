@@ -0,0 +1,207 @@
+package cockroachdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/filer/abstract_sql"
+	"github.com/chrislusf/seaweedfs/weed/filer/postgres"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	_ "github.com/lib/pq"
+)
+
+/*
+The CockroachDB store is the postgres2 store adapted to CockroachDB:
+
+	retries    the serialization failures, SQLSTATE 40001, are retried with backoff, since CockroachDB
+	           runs all transactions serializable and asks the clients to retry them
+	upserts    INSERT ... ON CONFLICT, instead of falling back to update on the duplicate key errors
+	key design the primary key (dirhash, name) spreads the directories over the ranges by the hash,
+	           while the entries of one directory are contiguous, so a large directory is split
+	           into the ranges by name, and listed or prefix scanned as a range scan
+	deletes    the folder children are deleted in batches, to avoid too large transactions
+*/
+
+const (
+	CONNECTION_URL_PATTERN = "host=%s port=%d sslmode=%s connect_timeout=30"
+	defaultDeleteBatchSize = 10000
+)
+
+func init() {
+	filer.Stores = append(filer.Stores, &CockroachStore{})
+}
+
+type CockroachStore struct {
+	abstract_sql.AbstractSqlStore
+	maxRetries   int
+	retryBackoff time.Duration
+}
+
+func (store *CockroachStore) GetName() string {
+	return "cockroachdb"
+}
+
+func (store *CockroachStore) Initialize(configuration util.Configuration, prefix string) (err error) {
+	store.BucketsFolder = configuration.GetString(prefix + "buckets_folder")
+	configuration.SetDefault(prefix+"port", 26257)
+	configuration.SetDefault(prefix+"max_retries", 10)
+	configuration.SetDefault(prefix+"delete_batch_size", defaultDeleteBatchSize)
+	return store.initialize(
+		configuration.GetString(prefix+"createTable"),
+		configuration.GetString(prefix+"username"),
+		configuration.GetString(prefix+"password"),
+		configuration.GetString(prefix+"hostname"),
+		configuration.GetInt(prefix+"port"),
+		configuration.GetString(prefix+"database"),
+		configuration.GetString(prefix+"sslmode"),
+		configuration.GetInt(prefix+"connection_max_idle"),
+		configuration.GetInt(prefix+"connection_max_open"),
+		configuration.GetInt(prefix+"connection_max_lifetime_seconds"),
+		configuration.GetInt(prefix+"max_retries"),
+		configuration.GetInt(prefix+"delete_batch_size"),
+	)
+}
+
+func (store *CockroachStore) initialize(createTable, user, password, hostname string, port int, database, sslmode string, maxIdle, maxOpen, maxLifetimeSeconds, maxRetries, deleteBatchSize int) (err error) {
+
+	if deleteBatchSize <= 0 {
+		// a LIMIT 0 would never delete any children
+		deleteBatchSize = defaultDeleteBatchSize
+	}
+
+	store.SupportBucketTable = true
+	store.maxRetries = maxRetries
+	store.retryBackoff = 10 * time.Millisecond
+	store.SqlGenerator = &SqlGenCockroach{
+		SqlGenPostgres: postgres.SqlGenPostgres{
+			CreateTableSqlTemplate: createTable,
+			DropTableSqlTemplate:   `drop table "%s"`,
+		},
+		DeleteBatchSize: int64(deleteBatchSize),
+	}
+
+	sqlUrl := fmt.Sprintf(CONNECTION_URL_PATTERN, hostname, port, sslmode)
+	if user != "" {
+		sqlUrl += " user=" + user
+	}
+	adaptedSqlUrl := sqlUrl
+	if password != "" {
+		sqlUrl += " password=" + password
+		adaptedSqlUrl += " password=ADAPTED"
+	}
+	if database != "" {
+		sqlUrl += " dbname=" + database
+		adaptedSqlUrl += " dbname=" + database
+	}
+	var dbErr error
+	store.DB, dbErr = sql.Open("postgres", sqlUrl)
+	if dbErr != nil {
+		store.DB.Close()
+		store.DB = nil
+		return fmt.Errorf("can not connect to %s error:%v", adaptedSqlUrl, dbErr)
+	}
+
+	store.DB.SetMaxIdleConns(maxIdle)
+	store.DB.SetMaxOpenConns(maxOpen)
+	store.DB.SetConnMaxLifetime(time.Duration(maxLifetimeSeconds) * time.Second)
+
+	if err = store.DB.Ping(); err != nil {
+		return fmt.Errorf("connect to %s error:%v", adaptedSqlUrl, err)
+	}
+
+	if err = store.retry(context.Background(), "create table", func() error {
+		return store.CreateTable(context.Background(), abstract_sql.DEFAULT_TABLE)
+	}); err != nil {
+		return fmt.Errorf("init table %s: %v", abstract_sql.DEFAULT_TABLE, err)
+	}
+
+	return nil
+}
+
+// BeginTransaction starts a serializable transaction, the only isolation of CockroachDB.
+func (store *CockroachStore) BeginTransaction(ctx context.Context) (context.Context, error) {
+	tx, err := store.DB.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelSerializable,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return ctx, err
+	}
+
+	return context.WithValue(ctx, "tx", tx), nil
+}
+
+func (store *CockroachStore) InsertEntry(ctx context.Context, entry *filer.Entry) error {
+	return store.retry(ctx, "insert "+string(entry.FullPath), func() error {
+		return store.AbstractSqlStore.InsertEntry(ctx, entry)
+	})
+}
+
+func (store *CockroachStore) UpdateEntry(ctx context.Context, entry *filer.Entry) error {
+	return store.retry(ctx, "update "+string(entry.FullPath), func() error {
+		return store.AbstractSqlStore.UpdateEntry(ctx, entry)
+	})
+}
+
+func (store *CockroachStore) FindEntry(ctx context.Context, fullpath util.FullPath) (entry *filer.Entry, err error) {
+	err = store.retry(ctx, "find "+string(fullpath), func() (findErr error) {
+		entry, findErr = store.AbstractSqlStore.FindEntry(ctx, fullpath)
+		return
+	})
+	return
+}
+
+func (store *CockroachStore) DeleteEntry(ctx context.Context, fullpath util.FullPath) error {
+	return store.retry(ctx, "delete "+string(fullpath), func() error {
+		return store.AbstractSqlStore.DeleteEntry(ctx, fullpath)
+	})
+}
+
+func (store *CockroachStore) DeleteFolderChildren(ctx context.Context, fullpath util.FullPath) error {
+	return store.retry(ctx, "delete children of "+string(fullpath), func() error {
+		return store.AbstractSqlStore.DeleteFolderChildren(ctx, fullpath)
+	})
+}
+
+// ListDirectoryPrefixedEntries only retries before any entry is listed, since the entries can not be listed again.
+func (store *CockroachStore) ListDirectoryPrefixedEntries(ctx context.Context, dirPath util.FullPath, startFileName string, includeStartFile bool, limit int64, prefix string, eachEntryFunc filer.ListEachEntryFunc) (lastFileName string, err error) {
+	err = store.retry(ctx, "list "+string(dirPath), func() (listErr error) {
+		hasListed := false
+		lastFileName, listErr = store.AbstractSqlStore.ListDirectoryPrefixedEntries(ctx, dirPath, startFileName, includeStartFile, limit, prefix, func(entry *filer.Entry) bool {
+			hasListed = true
+			return eachEntryFunc(entry)
+		})
+		if hasListed && listErr != nil {
+			return nonRetryableError{listErr}
+		}
+		return
+	})
+	return
+}
+
+func (store *CockroachStore) ListDirectoryEntries(ctx context.Context, dirPath util.FullPath, startFileName string, includeStartFile bool, limit int64, eachEntryFunc filer.ListEachEntryFunc) (lastFileName string, err error) {
+	return store.ListDirectoryPrefixedEntries(ctx, dirPath, startFileName, includeStartFile, limit, "", eachEntryFunc)
+}
+
+func (store *CockroachStore) KvPut(ctx context.Context, key []byte, value []byte) error {
+	return store.retry(ctx, "kv put", func() error {
+		return store.AbstractSqlStore.KvPut(ctx, key, value)
+	})
+}
+
+func (store *CockroachStore) KvGet(ctx context.Context, key []byte) (value []byte, err error) {
+	err = store.retry(ctx, "kv get", func() (getErr error) {
+		value, getErr = store.AbstractSqlStore.KvGet(ctx, key)
+		return
+	})
+	return
+}
+
+func (store *CockroachStore) KvDelete(ctx context.Context, key []byte) error {
+	return store.retry(ctx, "kv delete", func() error {
+		return store.AbstractSqlStore.KvDelete(ctx, key)
+	})
+}
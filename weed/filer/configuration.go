@@ -1,6 +1,7 @@
 package filer
 
 import (
+	"fmt"
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/util"
 	"os"
@@ -91,3 +92,21 @@ func validateOneEnabledStore(config *util.ViperProxy) {
 		}
 	}
 }
+
+// LoadStore initializes the only enabled store in the configuration, e.g. the target store of a migration.
+func LoadStore(config util.Configuration) (FilerStore, error) {
+	var enabled []FilerStore
+	for _, store := range Stores {
+		if config.GetBool(store.GetName() + ".enabled") {
+			enabled = append(enabled, store)
+		}
+	}
+	if len(enabled) != 1 {
+		return nil, fmt.Errorf("%d filer stores are enabled, expecting one", len(enabled))
+	}
+	store := reflect.New(reflect.ValueOf(enabled[0]).Elem().Type()).Interface().(FilerStore)
+	if err := store.Initialize(config, store.GetName()+"."); err != nil {
+		return nil, fmt.Errorf("initialize store %s: %v", store.GetName(), err)
+	}
+	return store, nil
+}
@@ -24,6 +24,7 @@ type Attr struct {
 	SymlinkTarget string
 	Md5           []byte
 	FileSize      uint64
+	Sha256        []byte // of the whole file content, see ChecksumAlgorithm
 }
 
 func (attr Attr) IsDirectory() bool {
@@ -102,6 +103,7 @@ func FromPbEntry(dir string, entry *filer_pb.Entry) *Entry {
 		HardLinkId:      HardLinkId(entry.HardLinkId),
 		HardLinkCounter: entry.HardLinkCounter,
 		Content:         entry.Content,
+		Extended:        entry.Extended,
 	}
 }
 
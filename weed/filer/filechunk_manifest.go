@@ -13,6 +13,7 @@ import (
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
 	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/chrislusf/seaweedfs/weed/util/codec"
 )
 
 const (
@@ -70,7 +71,7 @@ func ResolveOneChunkManifest(lookupFileIdFn wdclient.LookupFileIdFunctionType, c
 	}
 
 	// IsChunkManifest
-	data, err := fetchChunk(lookupFileIdFn, chunk.GetFileIdString(), chunk.CipherKey, chunk.IsCompressed)
+	data, err := fetchChunk(lookupFileIdFn, chunk.GetFileIdString(), chunk.CipherKey, chunk.IsCompressed, chunk.Codec)
 	if err != nil {
 		return nil, fmt.Errorf("fail to read manifest %s: %v", chunk.GetFileIdString(), err)
 	}
@@ -85,13 +86,35 @@ func ResolveOneChunkManifest(lookupFileIdFn wdclient.LookupFileIdFunctionType, c
 }
 
 // TODO fetch from cache for weed mount?
-func fetchChunk(lookupFileIdFn wdclient.LookupFileIdFunctionType, fileId string, cipherKey []byte, isGzipped bool) ([]byte, error) {
+func fetchChunk(lookupFileIdFn wdclient.LookupFileIdFunctionType, fileId string, cipherKey []byte, isGzipped bool, codecName string) ([]byte, error) {
 	urlStrings, err := lookupFileIdFn(fileId)
 	if err != nil {
 		glog.Errorf("operation LookupFileId %s failed, err: %v", fileId, err)
 		return nil, err
 	}
-	return retriedFetchChunkData(urlStrings, cipherKey, isGzipped, true, 0, 0)
+	data, err := retriedFetchChunkData(urlStrings, cipherKey, isGzipped, true, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	return codec.Decode(codecName, data)
+}
+
+// retriedFetchChunkViewData reads the whole chunk to decode it, if the chunk is encoded by a codec
+func retriedFetchChunkViewData(urlStrings []string, chunkView *ChunkView) ([]byte, error) {
+	if chunkView.Codec == "" {
+		return retriedFetchChunkData(urlStrings, chunkView.CipherKey, chunkView.IsGzipped, chunkView.IsFullChunk(), chunkView.Offset, int(chunkView.Size))
+	}
+	data, err := retriedFetchChunkData(urlStrings, chunkView.CipherKey, chunkView.IsGzipped, true, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = codec.Decode(chunkView.Codec, data); err != nil {
+		return nil, err
+	}
+	if chunkView.Offset+int64(chunkView.Size) > int64(len(data)) {
+		return nil, fmt.Errorf("decoded chunk %s has %d bytes, expecting [%d,%d)", chunkView.FileId, len(data), chunkView.Offset, chunkView.Offset+int64(chunkView.Size))
+	}
+	return data[chunkView.Offset : chunkView.Offset+int64(chunkView.Size)], nil
 }
 
 func retriedFetchChunkData(urlStrings []string, cipherKey []byte, isGzipped bool, isFullChunk bool, offset int64, size int) ([]byte, error) {
@@ -0,0 +1,88 @@
+package filer
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/chrislusf/seaweedfs/weed/wdclient"
+)
+
+// PartsHashAlgorithm is the hash of the object parts and of the whole object.
+// It is always the md5 of the original content, before compression or encryption,
+// so the hashes stay the same when the chunks are moved, compressed or tiered.
+const PartsHashAlgorithm = "md5"
+
+type FilePart struct {
+	Offset int64  `json:"offset"`
+	Size   uint64 `json:"size"`
+	Md5    string `json:"md5,omitempty"` // hex encoded, empty if the part can not be verified by its hash
+}
+
+// FileParts lists the visible content ranges of the entry, in the order of the offsets.
+// A part is one whole chunk, or its visible range if the chunk is partially overwritten.
+// Only the whole chunks, which are not encrypted, have a md5 hash.
+func FileParts(lookupFileIdFn wdclient.LookupFileIdFunctionType, entry *Entry) (parts []FilePart, err error) {
+
+	if len(entry.Content) > 0 {
+		return []FilePart{{
+			Offset: 0,
+			Size:   uint64(len(entry.Content)),
+			Md5:    fmt.Sprintf("%x", md5.Sum(entry.Content)),
+		}}, nil
+	}
+
+	dataChunks, _, err := ResolveChunkManifest(lookupFileIdFn, entry.Chunks)
+	if err != nil {
+		return nil, fmt.Errorf("resolve chunk manifest: %v", err)
+	}
+	etags := make(map[string]string)
+	for _, chunk := range dataChunks {
+		if len(chunk.CipherKey) == 0 && chunk.Codec == "" && isMd5Hex(chunk.ETag) {
+			etags[chunk.GetFileIdString()] = chunk.ETag
+		}
+	}
+
+	visibles, err := NonOverlappingVisibleIntervals(lookupFileIdFn, dataChunks)
+	if err != nil {
+		return nil, err
+	}
+	for _, visible := range visibles {
+		part := FilePart{
+			Offset: visible.start,
+			Size:   uint64(visible.stop - visible.start),
+		}
+		if visible.chunkOffset == 0 && part.Size == visible.chunkSize {
+			part.Md5 = etags[visible.fileId]
+		}
+		parts = append(parts, part)
+	}
+	return
+}
+
+// EntryMd5 is the md5 of the whole entry content, or nil if not known
+func EntryMd5(entry *Entry) []byte {
+	if len(entry.Attr.Md5) > 0 {
+		return entry.Attr.Md5
+	}
+	if len(entry.Content) > 0 {
+		sum := md5.Sum(entry.Content)
+		return sum[:]
+	}
+	if len(entry.Chunks) == 1 {
+		chunk := entry.Chunks[0]
+		if !chunk.IsChunkManifest && len(chunk.CipherKey) == 0 && chunk.Codec == "" && chunk.Offset == 0 && chunk.Size == entry.Size() && isMd5Hex(chunk.ETag) {
+			sum, _ := hex.DecodeString(chunk.ETag)
+			return sum
+		}
+	}
+	return nil
+}
+
+func isMd5Hex(s string) bool {
+	if len(s) != 2*md5.Size {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,75 @@
+package filer
+
+import (
+	"crypto/md5"
+	"fmt"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+)
+
+func TestFileParts(t *testing.T) {
+
+	md5a := fmt.Sprintf("%x", md5.Sum([]byte("a")))
+	md5b := fmt.Sprintf("%x", md5.Sum([]byte("b")))
+	md5c := fmt.Sprintf("%x", md5.Sum([]byte("c")))
+
+	entry := &Entry{
+		FullPath: "/a/b",
+		Chunks: []*filer_pb.FileChunk{
+			{Offset: 0, Size: 100, FileId: "1,01", Mtime: 100, ETag: md5a},
+			{Offset: 100, Size: 100, FileId: "1,02", Mtime: 100, ETag: md5b},
+			{Offset: 150, Size: 100, FileId: "1,03", Mtime: 200, ETag: md5c},
+			{Offset: 250, Size: 100, FileId: "1,04", Mtime: 100, ETag: md5c, CipherKey: []byte("key")},
+		},
+	}
+
+	parts, err := FileParts(nil, entry)
+	if err != nil {
+		t.Fatalf("file parts: %v", err)
+	}
+
+	expected := []FilePart{
+		{Offset: 0, Size: 100, Md5: md5a},
+		{Offset: 100, Size: 50},
+		{Offset: 150, Size: 100, Md5: md5c},
+		{Offset: 250, Size: 100},
+	}
+	if len(parts) != len(expected) {
+		t.Fatalf("unexpected parts %+v", parts)
+	}
+	for i, part := range parts {
+		if part != expected[i] {
+			t.Errorf("part %d: expected %+v actual %+v", i, expected[i], part)
+		}
+	}
+
+	if EntryMd5(entry) != nil {
+		t.Errorf("unexpected md5 for multiple chunks")
+	}
+}
+
+func TestEntryMd5(t *testing.T) {
+
+	md5a := md5.Sum([]byte("a"))
+
+	entry := &Entry{
+		FullPath: "/a/b",
+		Attr:     Attr{FileSize: 100},
+		Chunks: []*filer_pb.FileChunk{
+			{Offset: 0, Size: 100, FileId: "1,01", ETag: fmt.Sprintf("%x", md5a)},
+		},
+	}
+	if md5 := EntryMd5(entry); string(md5) != string(md5a[:]) {
+		t.Errorf("unexpected single chunk md5 %x", md5)
+	}
+
+	entry = &Entry{FullPath: "/a/c", Content: []byte("a")}
+	if md5 := EntryMd5(entry); string(md5) != string(md5a[:]) {
+		t.Errorf("unexpected content md5 %x", md5)
+	}
+	parts, _ := FileParts(nil, entry)
+	if len(parts) != 1 || parts[0].Md5 != fmt.Sprintf("%x", md5a) {
+		t.Errorf("unexpected content parts %+v", parts)
+	}
+}
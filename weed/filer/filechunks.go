@@ -111,6 +111,7 @@ type ChunkView struct {
 	ChunkSize   uint64
 	CipherKey   []byte
 	IsGzipped   bool
+	Codec       string
 }
 
 func (cv *ChunkView) IsFullChunk() bool {
@@ -148,6 +149,7 @@ func ViewFromVisibleIntervals(visibles []VisibleInterval, offset int64, size int
 				ChunkSize:   chunk.chunkSize,
 				CipherKey:   chunk.cipherKey,
 				IsGzipped:   chunk.isGzipped,
+				Codec:       chunk.codec,
 			})
 		}
 	}
@@ -174,7 +176,7 @@ var bufPool = sync.Pool{
 
 func MergeIntoVisibles(visibles []VisibleInterval, chunk *filer_pb.FileChunk) (newVisibles []VisibleInterval) {
 
-	newV := newVisibleInterval(chunk.Offset, chunk.Offset+int64(chunk.Size), chunk.GetFileIdString(), chunk.Mtime, 0, chunk.Size, chunk.CipherKey, chunk.IsCompressed)
+	newV := newVisibleInterval(chunk.Offset, chunk.Offset+int64(chunk.Size), chunk.GetFileIdString(), chunk.Mtime, 0, chunk.Size, chunk.CipherKey, chunk.IsCompressed, chunk.Codec)
 
 	length := len(visibles)
 	if length == 0 {
@@ -190,12 +192,12 @@ func MergeIntoVisibles(visibles []VisibleInterval, chunk *filer_pb.FileChunk) (n
 	chunkStop := chunk.Offset + int64(chunk.Size)
 	for _, v := range visibles {
 		if v.start < chunk.Offset && chunk.Offset < v.stop {
-			t := newVisibleInterval(v.start, chunk.Offset, v.fileId, v.modifiedTime, v.chunkOffset, v.chunkSize, v.cipherKey, v.isGzipped)
+			t := newVisibleInterval(v.start, chunk.Offset, v.fileId, v.modifiedTime, v.chunkOffset, v.chunkSize, v.cipherKey, v.isGzipped, v.codec)
 			newVisibles = append(newVisibles, t)
 			// glog.V(0).Infof("visible %d [%d,%d) =1> [%d,%d)", i, v.start, v.stop, t.start, t.stop)
 		}
 		if v.start < chunkStop && chunkStop < v.stop {
-			t := newVisibleInterval(chunkStop, v.stop, v.fileId, v.modifiedTime, v.chunkOffset+(chunkStop-v.start), v.chunkSize, v.cipherKey, v.isGzipped)
+			t := newVisibleInterval(chunkStop, v.stop, v.fileId, v.modifiedTime, v.chunkOffset+(chunkStop-v.start), v.chunkSize, v.cipherKey, v.isGzipped, v.codec)
 			newVisibles = append(newVisibles, t)
 			// glog.V(0).Infof("visible %d [%d,%d) =2> [%d,%d)", i, v.start, v.stop, t.start, t.stop)
 		}
@@ -263,9 +265,10 @@ type VisibleInterval struct {
 	chunkSize    uint64
 	cipherKey    []byte
 	isGzipped    bool
+	codec        string
 }
 
-func newVisibleInterval(start, stop int64, fileId string, modifiedTime int64, chunkOffset int64, chunkSize uint64, cipherKey []byte, isGzipped bool) VisibleInterval {
+func newVisibleInterval(start, stop int64, fileId string, modifiedTime int64, chunkOffset int64, chunkSize uint64, cipherKey []byte, isGzipped bool, codec string) VisibleInterval {
 	return VisibleInterval{
 		start:        start,
 		stop:         stop,
@@ -275,6 +278,7 @@ func newVisibleInterval(start, stop int64, fileId string, modifiedTime int64, ch
 		chunkSize:    chunkSize,
 		cipherKey:    cipherKey,
 		isGzipped:    isGzipped,
+		codec:        codec,
 	}
 }
 
@@ -5,11 +5,14 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"google.golang.org/grpc"
 
 	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/kms"
 	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
 	"github.com/chrislusf/seaweedfs/weed/util"
 	"github.com/chrislusf/seaweedfs/weed/util/log_buffer"
@@ -31,6 +34,8 @@ type Filer struct {
 	Store               VirtualFilerStore
 	MasterClient        *wdclient.MasterClient
 	fileIdDeletionQueue *util.UnboundedQueue
+	checksumQueue       *util.UnboundedQueue
+	s3Events            chan *s3EventChange
 	GrpcDialOption      grpc.DialOption
 	DirBucketsPath      string
 	FsyncBuckets        []string
@@ -42,6 +47,26 @@ type Filer struct {
 	MetaAggregator      *MetaAggregator
 	Signature           int32
 	FilerConf           *FilerConf
+	LockManager         *LockManager
+	Holds               *FilerHolds
+	restores            pathIndex
+	RemoteStorage       *FilerRemoteStorage
+	DeleteJobs          *DeleteJobs
+	DirEntries          *DirEntriesLimit
+	DirQuotas           *DirQuotas
+	reconciler          *reconciler
+	dedup               dedupIndex
+	kvKeys              pathIndex
+	registeredKvKeys    sync.Map
+	keyManager          kms.KeyManager
+	sseDataKeys         sseDataKeys
+	clusterReadOnly     clusterReadOnly
+	lockFiler           lockFiler
+	isStopping          int32
+
+	DeletionFilesPerSecond         int64
+	volumeServerDeletionQueues     map[string]*util.UnboundedQueue
+	volumeServerDeletionQueuesLock sync.Mutex
 }
 
 func NewFiler(masters []string, grpcDialOption grpc.DialOption,
@@ -49,14 +74,29 @@ func NewFiler(masters []string, grpcDialOption grpc.DialOption,
 	f := &Filer{
 		MasterClient:        wdclient.NewMasterClient(grpcDialOption, "filer", filerHost, filerGrpcPort, dataCenter, masters),
 		fileIdDeletionQueue: util.NewUnboundedQueue(),
+		checksumQueue:       util.NewUnboundedQueue(),
+		s3Events:            make(chan *s3EventChange, s3EventQueueSize),
 		GrpcDialOption:      grpcDialOption,
 		FilerConf:           NewFilerConf(),
+		LockManager:         NewLockManager(),
+		Holds:               &FilerHolds{index: pathIndex{name: holdIndexName}},
+		restores:            pathIndex{name: restoreIndexName},
+		kvKeys:              pathIndex{name: kvKeyIndexName},
+		RemoteStorage:       NewFilerRemoteStorage(),
+		DeleteJobs:          NewDeleteJobs(),
+		DirEntries:          NewDirEntriesLimit(),
+		DirQuotas:           NewDirQuotas(),
+
+		DeletionFilesPerSecond:     DefaultDeletionFilesPerSecond,
+		volumeServerDeletionQueues: make(map[string]*util.UnboundedQueue),
 	}
 	f.LocalMetaLogBuffer = log_buffer.NewLogBuffer(LogFlushInterval, f.logFlushFunc, notifyFn)
 	f.metaLogCollection = collection
 	f.metaLogReplication = replication
 
 	go f.loopProcessingDeletion()
+	go f.loopComputingChecksums()
+	go f.loopSendingS3Events()
 
 	return f
 }
@@ -80,7 +120,10 @@ func (f *Filer) AggregateFromPeers(self string, filers []string) {
 }
 
 func (f *Filer) SetStore(store FilerStore) {
-	f.Store = NewFilerStoreWrapper(store)
+	storeWrapper := NewFilerStoreWrapper(store)
+	storeWrapper.dirShardCount = f.dirShardCount
+	storeWrapper.writableCheck = f.checkClusterWritableFor
+	f.Store = storeWrapper
 
 	f.setOrLoadFilerStoreSignature(store)
 
@@ -134,6 +177,10 @@ func (f *Filer) CreateEntry(ctx context.Context, entry *Entry, o_excl bool, isFr
 		return nil
 	}
 
+	if err := f.checkClusterWritableFor(entry.FullPath); err != nil {
+		return err
+	}
+
 	oldEntry, _ := f.FindEntry(ctx, entry.FullPath)
 
 	/*
@@ -144,13 +191,28 @@ func (f *Filer) CreateEntry(ctx context.Context, entry *Entry, o_excl bool, isFr
 		}
 	*/
 
+	isVersionKept := false
 	if oldEntry == nil {
 
+		f.stripWorm(entry, isFromOtherCluster)
+		if !isFromOtherCluster {
+			f.inheritTtl(ctx, entry)
+			f.inheritAcl(ctx, entry)
+			f.markWorm(entry)
+			if err := f.checkDirEntriesLimit(ctx, entry.FullPath); err != nil {
+				return err
+			}
+			if err := f.CheckQuotas(ctx, nil, entry); err != nil {
+				return err
+			}
+		}
+
 		dirParts := strings.Split(string(entry.FullPath), "/")
 		if err := f.ensureParentDirecotryEntry(ctx, entry, dirParts, len(dirParts)-1, isFromOtherCluster); err != nil {
 			return err
 		}
 
+		f.stripObjectLock(ctx, entry)
 		glog.V(4).Infof("InsertEntry %s: new entry: %v", entry.FullPath, entry.Name())
 		if err := f.Store.InsertEntry(ctx, entry); err != nil {
 			glog.Errorf("insert entry %s: %v", entry.FullPath, err)
@@ -162,6 +224,18 @@ func (f *Filer) CreateEntry(ctx context.Context, entry *Entry, o_excl bool, isFr
 			return fmt.Errorf("EEXIST: entry %s already exists", entry.FullPath)
 		}
 		glog.V(4).Infof("UpdateEntry %s: old entry: %v", entry.FullPath, oldEntry.Name())
+		if !isFromOtherCluster {
+			if err := f.CheckQuotas(ctx, oldEntry, entry); err != nil {
+				return err
+			}
+			if err := f.CheckUpdateAllowed(ctx, oldEntry, entry); err != nil {
+				return err
+			}
+			var err error
+			if isVersionKept, err = f.KeepVersion(ctx, oldEntry, entry); err != nil {
+				return err
+			}
+		}
 		if err := f.UpdateEntry(ctx, oldEntry, entry); err != nil {
 			glog.Errorf("update entry %s: %v", entry.FullPath, err)
 			return fmt.Errorf("update entry %s: %v", entry.FullPath, err)
@@ -171,7 +245,11 @@ func (f *Filer) CreateEntry(ctx context.Context, entry *Entry, o_excl bool, isFr
 	f.maybeAddBucket(entry)
 	f.NotifyUpdateEvent(ctx, oldEntry, entry, true, isFromOtherCluster, signatures)
 
-	f.deleteChunksIfNotNew(oldEntry, entry)
+	if isVersionKept {
+		f.PruneVersions(ctx, entry.FullPath, entry)
+	} else {
+		f.deleteChunksIfNotNew(oldEntry, entry)
+	}
 
 	glog.V(4).Infof("CreateEntry %s: created", entry.FullPath)
 
@@ -238,8 +316,21 @@ func (f *Filer) ensureParentDirecotryEntry(ctx context.Context, entry *Entry, di
 }
 
 func (f *Filer) UpdateEntry(ctx context.Context, oldEntry, entry *Entry) (err error) {
-	if oldEntry != nil {
+	if err = f.checkClusterWritableFor(entry.FullPath); err != nil {
+		return err
+	}
+	if oldEntry == nil {
+		f.stripObjectLock(ctx, entry)
+		f.stripWorm(entry, false)
+	} else {
+		if err = f.CheckUpdateAllowed(ctx, oldEntry, entry); err != nil {
+			return err
+		}
+		syncAclWithMode(oldEntry, entry)
 		entry.Attr.Crtime = oldEntry.Attr.Crtime
+		if entry.TtlSec == 0 && !isTtlSet(ctx) {
+			entry.TtlSec = oldEntry.TtlSec
+		}
 		if oldEntry.IsDirectory() && !entry.IsDirectory() {
 			glog.Errorf("existing %s is a directory", oldEntry.FullPath)
 			return fmt.Errorf("existing %s is a directory", oldEntry.FullPath)
@@ -252,6 +343,17 @@ func (f *Filer) UpdateEntry(ctx context.Context, oldEntry, entry *Entry) (err er
 	return f.Store.UpdateEntry(ctx, entry)
 }
 
+// CheckUpdateAllowed returns an error if the old entry is held or retained, and the update is more than metadata.
+// The object lock retention is only kept in the buckets with object lock enabled.
+func (f *Filer) CheckUpdateAllowed(ctx context.Context, oldEntry, entry *Entry) error {
+	f.stripObjectLock(ctx, oldEntry)
+	f.stripObjectLock(ctx, entry)
+	if err := checkUpdateNotHeld(oldEntry, entry); err != nil {
+		return err
+	}
+	return checkUpdateNotRetained(oldEntry, entry)
+}
+
 var (
 	Root = &Entry{
 		FullPath: "/",
@@ -271,24 +373,27 @@ func (f *Filer) FindEntry(ctx context.Context, p util.FullPath) (entry *Entry, e
 		return Root, nil
 	}
 	entry, err = f.Store.FindEntry(ctx, p)
-	if entry != nil && entry.TtlSec > 0 {
-		if entry.Crtime.Add(time.Duration(entry.TtlSec) * time.Second).Before(time.Now()) {
-			f.Store.DeleteOneEntry(ctx, entry)
-			return nil, filer_pb.ErrNotFound
+	if err == filer_pb.ErrNotFound {
+		if mount, _ := f.RemoteStorage.FindMount(p); mount != nil && string(p) != mount.Dir {
+			dir, _ := p.DirAndName()
+			f.maybeListRemoteDirectory(ctx, util.FullPath(dir))
+			entry, err = f.Store.FindEntry(ctx, p)
 		}
 	}
+	if entry != nil && f.isTtlExpired(ctx, entry) {
+		f.Store.DeleteOneEntry(ctx, entry)
+		return nil, filer_pb.ErrNotFound
+	}
 	return
 
 }
 
 func (f *Filer) doListDirectoryEntries(ctx context.Context, p util.FullPath, startFileName string, inclusive bool, limit int64, prefix string, eachEntryFunc ListEachEntryFunc) (expiredCount int64, lastFileName string, err error) {
 	lastFileName, err = f.Store.ListDirectoryPrefixedEntries(ctx, p, startFileName, inclusive, limit, prefix, func(entry *Entry) bool {
-		if entry.TtlSec > 0 {
-			if entry.Crtime.Add(time.Duration(entry.TtlSec) * time.Second).Before(time.Now()) {
-				f.Store.DeleteOneEntry(ctx, entry)
-				expiredCount++
-				return true
-			}
+		if f.isTtlExpired(ctx, entry) {
+			f.Store.DeleteOneEntry(ctx, entry)
+			expiredCount++
+			return true
 		}
 		return eachEntryFunc(entry)
 	})
@@ -298,7 +403,16 @@ func (f *Filer) doListDirectoryEntries(ctx context.Context, p util.FullPath, sta
 	return
 }
 
+// isTtlExpired checks whether the entry is over its TTL, and not retained by worm or object lock
+func (f *Filer) isTtlExpired(ctx context.Context, entry *Entry) bool {
+	if entry.TtlSec <= 0 || !entry.Crtime.Add(time.Duration(entry.TtlSec)*time.Second).Before(time.Now()) {
+		return false
+	}
+	return !f.isRetained(ctx, entry, false)
+}
+
 func (f *Filer) Shutdown() {
+	atomic.StoreInt32(&f.isStopping, 1)
 	f.LocalMetaLogBuffer.Shutdown()
 	f.Store.Shutdown()
 }
@@ -0,0 +1,354 @@
+package filer
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+An entry can have a POSIX access ACL, and a directory can have a default ACL.
+
+The ACLs are kept in the entry extended attributes, with the same names and the same binary format
+as the Linux xattrs, and are set by "fs.acl". The user and group ids in the ACLs are the ids on the filer,
+i.e. after the uid and gid mapping of "weed mount".
+
+A new entry inherits the default ACL of its parent directory as its access ACL, masked by the mode of
+the new entry. A new sub directory also inherits the default ACL. The permission bits of the entry mode
+follow the access ACL, with the group bits being the ACL mask, and chmod updates the ACL in the same way.
+
+The permissions are checked by HasPermission, with the mode bits if the entry has no ACL.
+*/
+
+const (
+	ExtPosixAclAccessKey  = "system.posix_acl_access"
+	ExtPosixAclDefaultKey = "system.posix_acl_default"
+
+	aclXattrVersion = 2
+	aclUndefinedId  = ^uint32(0)
+)
+
+const (
+	AclTagUserObj  uint16 = 0x01
+	AclTagUser     uint16 = 0x02
+	AclTagGroupObj uint16 = 0x04
+	AclTagGroup    uint16 = 0x08
+	AclTagMask     uint16 = 0x10
+	AclTagOther    uint16 = 0x20
+)
+
+var ErrInvalidAcl = errors.New("invalid acl")
+
+type AclEntry struct {
+	Tag  uint16
+	Perm uint16
+	Id   uint32
+}
+
+// Acl is a POSIX ACL, sorted by the tag and then the id, as required by the Linux kernel
+type Acl []AclEntry
+
+// DecodeAcl decodes the ACL in the Linux xattr format, returning nil for empty data
+func DecodeAcl(data []byte) (Acl, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if len(data) < 4 || (len(data)-4)%8 != 0 || binary.LittleEndian.Uint32(data) != aclXattrVersion {
+		return nil, fmt.Errorf("%v: unexpected xattr format", ErrInvalidAcl)
+	}
+	var acl Acl
+	for i := 4; i < len(data); i += 8 {
+		acl = append(acl, AclEntry{
+			Tag:  binary.LittleEndian.Uint16(data[i:]),
+			Perm: binary.LittleEndian.Uint16(data[i+2:]),
+			Id:   binary.LittleEndian.Uint32(data[i+4:]),
+		})
+	}
+	if err := acl.validate(); err != nil {
+		return nil, err
+	}
+	return acl, nil
+}
+
+// Encode encodes the ACL in the Linux xattr format
+func (acl Acl) Encode() []byte {
+	data := make([]byte, 4+8*len(acl))
+	binary.LittleEndian.PutUint32(data, aclXattrVersion)
+	for i, e := range acl {
+		id := e.Id
+		if !e.isNamed() {
+			id = aclUndefinedId
+		}
+		binary.LittleEndian.PutUint16(data[4+8*i:], e.Tag)
+		binary.LittleEndian.PutUint16(data[4+8*i+2:], e.Perm)
+		binary.LittleEndian.PutUint32(data[4+8*i+4:], id)
+	}
+	return data
+}
+
+// ParseAcl parses the ACL in the short text form of "setfacl", with numeric ids, e.g.
+// "user::rwx,user:1000:r-x,group::r-x,mask::r-x,other::---"
+// A missing mask is computed from the group class entries.
+func ParseAcl(text string) (Acl, error) {
+	var acl Acl
+	for _, field := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == '\n' || r == ' ' }) {
+		parts := strings.Split(field, ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("%v: %s", ErrInvalidAcl, field)
+		}
+		e := AclEntry{}
+		switch parts[0] {
+		case "u", "user":
+			e.Tag = AclTagUserObj
+			if parts[1] != "" {
+				e.Tag = AclTagUser
+			}
+		case "g", "group":
+			e.Tag = AclTagGroupObj
+			if parts[1] != "" {
+				e.Tag = AclTagGroup
+			}
+		case "m", "mask":
+			e.Tag = AclTagMask
+		case "o", "other":
+			e.Tag = AclTagOther
+		default:
+			return nil, fmt.Errorf("%v: unknown tag in %s", ErrInvalidAcl, field)
+		}
+		if e.isNamed() {
+			id, err := strconv.ParseUint(parts[1], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("%v: id in %s should be numeric", ErrInvalidAcl, field)
+			}
+			e.Id = uint32(id)
+		} else if parts[1] != "" {
+			return nil, fmt.Errorf("%v: unexpected id in %s", ErrInvalidAcl, field)
+		}
+		perm, err := parseAclPerm(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("%v: %s", err, field)
+		}
+		e.Perm = perm
+		acl = append(acl, e)
+	}
+	if acl.entry(AclTagMask) == nil && acl.hasNamedEntries() {
+		var mask uint16
+		for _, e := range acl {
+			if e.Tag == AclTagUser || e.Tag == AclTagGroupObj || e.Tag == AclTagGroup {
+				mask |= e.Perm
+			}
+		}
+		acl = append(acl, AclEntry{Tag: AclTagMask, Perm: mask})
+	}
+	sort.Slice(acl, func(i, j int) bool {
+		return acl[i].Tag < acl[j].Tag || acl[i].Tag == acl[j].Tag && acl[i].Id < acl[j].Id
+	})
+	if err := acl.validate(); err != nil {
+		return nil, err
+	}
+	return acl, nil
+}
+
+func parseAclPerm(s string) (perm uint16, err error) {
+	if n, parseErr := strconv.ParseUint(s, 8, 16); parseErr == nil && n <= 7 {
+		return uint16(n), nil
+	}
+	for _, c := range s {
+		switch c {
+		case 'r':
+			perm |= AclRead
+		case 'w':
+			perm |= AclWrite
+		case 'x':
+			perm |= AclExecute
+		case '-':
+		default:
+			return 0, fmt.Errorf("%v: unknown permission %q", ErrInvalidAcl, c)
+		}
+	}
+	return perm, nil
+}
+
+// String formats the ACL in the short text form, the same as ParseAcl takes
+func (acl Acl) String() string {
+	var fields []string
+	for _, e := range acl {
+		var tag, id string
+		switch e.Tag {
+		case AclTagUserObj:
+			tag = "user"
+		case AclTagUser:
+			tag, id = "user", strconv.FormatUint(uint64(e.Id), 10)
+		case AclTagGroupObj:
+			tag = "group"
+		case AclTagGroup:
+			tag, id = "group", strconv.FormatUint(uint64(e.Id), 10)
+		case AclTagMask:
+			tag = "mask"
+		case AclTagOther:
+			tag = "other"
+		}
+		fields = append(fields, fmt.Sprintf("%s:%s:%s", tag, id, formatAclPerm(e.Perm)))
+	}
+	return strings.Join(fields, ",")
+}
+
+func formatAclPerm(perm uint16) string {
+	s := []byte("---")
+	if perm&AclRead != 0 {
+		s[0] = 'r'
+	}
+	if perm&AclWrite != 0 {
+		s[1] = 'w'
+	}
+	if perm&AclExecute != 0 {
+		s[2] = 'x'
+	}
+	return string(s)
+}
+
+// validate checks the ACL has exactly one owner, owning group and other entry,
+// a mask if it has named entries, and no duplicated named entries
+func (acl Acl) validate() error {
+	counts := make(map[uint16]int)
+	for i, e := range acl {
+		if e.Perm&^7 != 0 {
+			return fmt.Errorf("%v: unexpected permission %o", ErrInvalidAcl, e.Perm)
+		}
+		if i > 0 && (acl[i-1].Tag > e.Tag || acl[i-1].Tag == e.Tag && acl[i-1].Id >= e.Id && e.isNamed()) {
+			return fmt.Errorf("%v: entries not sorted or duplicated", ErrInvalidAcl)
+		}
+		counts[e.Tag]++
+	}
+	for _, tag := range []uint16{AclTagUserObj, AclTagGroupObj, AclTagOther} {
+		if counts[tag] != 1 {
+			return fmt.Errorf("%v: needs exactly one user::, group:: and other:: entry", ErrInvalidAcl)
+		}
+	}
+	if counts[AclTagMask] > 1 || acl.hasNamedEntries() && counts[AclTagMask] == 0 {
+		return fmt.Errorf("%v: needs one mask:: entry with the named entries", ErrInvalidAcl)
+	}
+	return nil
+}
+
+func (e AclEntry) isNamed() bool {
+	return e.Tag == AclTagUser || e.Tag == AclTagGroup
+}
+
+func (acl Acl) hasNamedEntries() bool {
+	for _, e := range acl {
+		if e.isNamed() {
+			return true
+		}
+	}
+	return false
+}
+
+func (acl Acl) entry(tag uint16) *AclEntry {
+	for i := range acl {
+		if acl[i].Tag == tag {
+			return &acl[i]
+		}
+	}
+	return nil
+}
+
+// groupClassEntry is the mask, or the owning group if no mask
+func (acl Acl) groupClassEntry() *AclEntry {
+	if mask := acl.entry(AclTagMask); mask != nil {
+		return mask
+	}
+	return acl.entry(AclTagGroupObj)
+}
+
+// Mode returns the mode with the permission bits from the ACL
+func (acl Acl) Mode(mode os.FileMode) os.FileMode {
+	if len(acl) == 0 {
+		return mode
+	}
+	perm := os.FileMode(acl.entry(AclTagUserObj).Perm)<<6 | os.FileMode(acl.groupClassEntry().Perm)<<3 | os.FileMode(acl.entry(AclTagOther).Perm)
+	return mode&^os.ModePerm | perm
+}
+
+// WithMode returns a copy of the ACL with the owner, group class and other permissions from the mode
+func (acl Acl) WithMode(mode os.FileMode) Acl {
+	if len(acl) == 0 {
+		return nil
+	}
+	updated := append(Acl(nil), acl...)
+	updated.entry(AclTagUserObj).Perm = uint16(mode>>6) & 7
+	updated.groupClassEntry().Perm = uint16(mode>>3) & 7
+	updated.entry(AclTagOther).Perm = uint16(mode) & 7
+	return updated
+}
+
+// InheritDefaultAcl sets the ACLs of a new entry from the default ACL of its parent directory,
+// and returns the extended attributes and the mode masked by the ACL.
+// The new entry is not changed if it already has an access ACL, the parent has no default ACL, or it is a symlink.
+func InheritDefaultAcl(parentExtended map[string][]byte, extended map[string][]byte, mode os.FileMode, isDirectory bool) (map[string][]byte, os.FileMode) {
+	if len(extended[ExtPosixAclAccessKey]) > 0 || mode&os.ModeSymlink != 0 {
+		return extended, mode
+	}
+	defaultAcl, err := DecodeAcl(parentExtended[ExtPosixAclDefaultKey])
+	if err != nil || len(defaultAcl) == 0 {
+		return extended, mode
+	}
+	// the requested permissions are masked by the default acl
+	inherited := defaultAcl.WithMode(defaultAcl.Mode(0) & mode & os.ModePerm)
+	if extended == nil {
+		extended = make(map[string][]byte)
+	}
+	extended[ExtPosixAclAccessKey] = inherited.Encode()
+	if isDirectory {
+		extended[ExtPosixAclDefaultKey] = defaultAcl.Encode()
+	}
+	return extended, inherited.Mode(mode)
+}
+
+// syncAclWithMode keeps the access ACL and the permission bits of the updated entry consistent,
+// by the mode following a changed ACL, or otherwise the ACL following a changed mode.
+func syncAclWithMode(oldEntry, entry *Entry) {
+	acl, err := DecodeAcl(entry.Extended[ExtPosixAclAccessKey])
+	if err != nil || len(acl) == 0 {
+		return
+	}
+	if string(oldEntry.Extended[ExtPosixAclAccessKey]) != string(entry.Extended[ExtPosixAclAccessKey]) {
+		entry.Mode = acl.Mode(entry.Mode)
+		return
+	}
+	if oldEntry.Mode&os.ModePerm != entry.Mode&os.ModePerm {
+		ChmodAcl(entry.Extended, entry.Mode)
+	}
+}
+
+// ChmodAcl updates the access ACL in the extended attributes for the new mode
+func ChmodAcl(extended map[string][]byte, mode os.FileMode) {
+	acl, err := DecodeAcl(extended[ExtPosixAclAccessKey])
+	if err != nil || len(acl) == 0 {
+		return
+	}
+	extended[ExtPosixAclAccessKey] = acl.WithMode(mode).Encode()
+}
+
+// inheritAcl sets the ACLs of a new entry from the default ACL of its parent directory, except for the moved entries
+func (f *Filer) inheritAcl(ctx context.Context, entry *Entry) {
+	if len(entry.Extended[ExtPosixAclAccessKey]) > 0 {
+		return
+	}
+	if movingFrom, _ := ctx.Value(movingFromKey{}).(util.FullPath); movingFrom != "" {
+		return
+	}
+	dir, _ := entry.FullPath.DirAndName()
+	parent, err := f.FindEntry(ctx, util.FullPath(dir))
+	if err != nil || len(parent.Extended[ExtPosixAclDefaultKey]) == 0 {
+		return
+	}
+	entry.Extended, entry.Mode = InheritDefaultAcl(parent.Extended, entry.Extended, entry.Mode, entry.IsDirectory())
+}
@@ -0,0 +1,107 @@
+package filer
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAcl(t *testing.T) {
+
+	acl, err := ParseAcl("u::rwx,g::r-x,o::---,u:1000:rw-,g:100:r--")
+	assert.Nil(t, err)
+	assert.Equal(t, "user::rwx,user:1000:rw-,group::r-x,group:100:r--,mask::rwx,other::---", acl.String())
+
+	decoded, err := DecodeAcl(acl.Encode())
+	assert.Nil(t, err)
+	assert.Equal(t, acl.String(), decoded.String())
+	assert.Equal(t, os.FileMode(0770), acl.Mode(0644))
+
+	_, err = ParseAcl("user::rwx,user:1000:rw-,user:1000:r--,group::r-x,other::---")
+	assert.NotNil(t, err, "duplicated entries")
+	_, err = ParseAcl("user::rwx,other::---")
+	assert.NotNil(t, err, "missing owning group")
+	_, err = DecodeAcl([]byte{1, 2, 3})
+	assert.NotNil(t, err, "bad format")
+}
+
+func TestHasPermission(t *testing.T) {
+
+	acl, _ := ParseAcl("user::rw-,user:1000:rw-,group::r--,group:100:rwx,mask::r-x,other::---")
+	entry := &Entry{
+		FullPath: "/data/file",
+		Attr:     Attr{Mode: acl.Mode(0), Uid: 500, Gid: 50},
+		Extended: map[string][]byte{ExtPosixAclAccessKey: acl.Encode()},
+	}
+
+	assert.True(t, HasPermission(entry, &Identity{Uid: 500}, AclRead|AclWrite), "owner")
+	assert.False(t, HasPermission(entry, &Identity{Uid: 500}, AclExecute), "owner")
+	assert.True(t, HasPermission(entry, &Identity{Uid: 1000}, AclRead), "named user")
+	assert.False(t, HasPermission(entry, &Identity{Uid: 1000}, AclWrite), "named user masked")
+	assert.True(t, HasPermission(entry, &Identity{Uid: 2000, Gids: []uint32{7, 100}}, AclRead|AclExecute), "named group")
+	assert.False(t, HasPermission(entry, &Identity{Uid: 2000, Gids: []uint32{50}}, AclWrite), "owning group")
+	assert.False(t, HasPermission(entry, &Identity{Uid: 2000}, AclRead), "other")
+	assert.True(t, HasPermission(entry, &Identity{Uid: 0}, AclRead|AclWrite), "root")
+
+	plain := &Entry{FullPath: "/data/plain", Attr: Attr{Mode: 0640, Uid: 500, Gid: 50}}
+	assert.True(t, HasPermission(plain, &Identity{Uid: 600, Gids: []uint32{50}}, AclRead), "mode group")
+	assert.False(t, HasPermission(plain, &Identity{Uid: 600}, AclRead), "mode other")
+	assert.False(t, HasPermission(plain, &Identity{Uid: 0}, AclExecute), "root executing")
+}
+
+func TestInheritDefaultAcl(t *testing.T) {
+
+	defaultAcl, _ := ParseAcl("user::rwx,user:1000:rwx,group::r-x,other::---")
+	parentExtended := map[string][]byte{ExtPosixAclDefaultKey: defaultAcl.Encode()}
+
+	extended, mode := InheritDefaultAcl(parentExtended, nil, 0644, false)
+	acl, _ := DecodeAcl(extended[ExtPosixAclAccessKey])
+	assert.Equal(t, "user::rw-,user:1000:rwx,group::r-x,mask::r--,other::---", acl.String())
+	assert.Equal(t, os.FileMode(0640), mode)
+	assert.Nil(t, extended[ExtPosixAclDefaultKey])
+
+	extended, mode = InheritDefaultAcl(parentExtended, nil, os.ModeDir|0777, true)
+	assert.Equal(t, os.ModeDir|0770, mode)
+	assert.Equal(t, defaultAcl.Encode(), extended[ExtPosixAclDefaultKey])
+
+	_, mode = InheritDefaultAcl(nil, nil, 0644, false)
+	assert.Equal(t, os.FileMode(0644), mode)
+
+	// chmod updates the acl
+	oldEntry := &Entry{Attr: Attr{Mode: 0640}, Extended: map[string][]byte{ExtPosixAclAccessKey: acl.Encode()}}
+	entry := &Entry{Attr: Attr{Mode: 0660}, Extended: map[string][]byte{ExtPosixAclAccessKey: acl.Encode()}}
+	syncAclWithMode(oldEntry, entry)
+	acl, _ = DecodeAcl(entry.Extended[ExtPosixAclAccessKey])
+	assert.Equal(t, "user::rw-,user:1000:rwx,group::r-x,mask::rw-,other::---", acl.String())
+}
+
+func TestCheckAccess(t *testing.T) {
+
+	entries := map[util.FullPath]*Entry{
+		"/":               {FullPath: "/", Attr: Attr{Mode: os.ModeDir | 0755}},
+		"/shared":         {FullPath: "/shared", Attr: Attr{Mode: os.ModeDir | os.ModeSticky | 0777}},
+		"/shared/file":    {FullPath: "/shared/file", Attr: Attr{Mode: 0666, Uid: 1000}},
+		"/private":        {FullPath: "/private", Attr: Attr{Mode: os.ModeDir | 0700, Uid: 1000}},
+		"/private/secret": {FullPath: "/private/secret", Attr: Attr{Mode: 0666, Uid: 1000}},
+	}
+	findEntry := func(ctx context.Context, p util.FullPath) (*Entry, error) {
+		if entry, found := entries[p]; found {
+			return entry, nil
+		}
+		return nil, filer_pb.ErrNotFound
+	}
+	ctx := context.Background()
+	owner, other := &Identity{Uid: 1000}, &Identity{Uid: 2000}
+
+	assert.Nil(t, CheckAccess(ctx, findEntry, "/private/secret", owner, AclRead))
+	assert.NotNil(t, CheckAccess(ctx, findEntry, "/private/secret", other, AclRead), "searching the directory")
+	assert.Nil(t, CheckCreateAccess(ctx, findEntry, "/shared/new/dir/file", other))
+	assert.NotNil(t, CheckCreateAccess(ctx, findEntry, "/private/new", other))
+	assert.NotNil(t, CheckDeleteAccess(ctx, findEntry, "/shared/file", other), "sticky directory")
+	assert.Nil(t, CheckDeleteAccess(ctx, findEntry, "/shared/file", owner))
+	assert.Equal(t, filer_pb.ErrNotFound, CheckAccess(ctx, findEntry, "/shared/missing", other, AclRead))
+}
@@ -0,0 +1,260 @@
+package filer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+Archived entries work like the S3 Glacier storage class.
+
+An archived entry has its chunks copied to the archive collection, usually with its volumes
+moved to a slow and cheap remote tier by "volume.tier.upload". The entry is marked with
+storage class GLACIER, and its content can not be read.
+
+A restore request copies the archived chunks back to a normal collection, and the entry
+can be read until the restore expires. The archived chunks are kept in the entry extended
+attributes meanwhile. After the restore expires, the restored chunks are deleted,
+and the entry points to the archived chunks again.
+
+The s3 objects already on the tiered volumes can also be archived in place, see filer_tiered.go.
+*/
+
+const (
+	ArchiveStorageClass      = "GLACIER"
+	DefaultArchiveCollection = "archive"
+	ExtStorageClassKey       = "x-amz-storage-class"
+	ExtRestoreKey            = "x-amz-restore"
+	ExtArchivedChunksKey     = "x-seaweedfs-archived-chunks"
+	restoreIndexName         = "restores"
+)
+
+var (
+	ErrEntryArchived    = errors.New("entry is archived, restore it first")
+	ErrEntryNotArchived = errors.New("entry is not archived")
+	restoreExpiryRegexp = regexp.MustCompile(`expiry-date="([^"]+)"`)
+)
+
+func IsArchived(entry *Entry) bool {
+	if entry == nil || entry.Extended == nil {
+		return false
+	}
+	return string(entry.Extended[ExtStorageClassKey]) == ArchiveStorageClass
+}
+
+// IsRestoring returns true if the restore is requested but not completed yet.
+func IsRestoring(entry *Entry) bool {
+	return IsArchived(entry) && string(entry.Extended[ExtRestoreKey]) == restoreOngoing()
+}
+
+// IsRestored returns true if the archived entry has an unexpired restored copy.
+func IsRestored(entry *Entry, now time.Time) bool {
+	if !IsArchived(entry) || len(entry.Extended[ExtArchivedChunksKey]) == 0 {
+		return false
+	}
+	expiry, found := restoreExpiry(entry)
+	return found && now.Before(expiry)
+}
+
+// IsReadable returns false for archived entries without a restored copy.
+func IsReadable(entry *Entry) bool {
+	return !IsArchived(entry) || IsRestored(entry, time.Now())
+}
+
+// ArchivedChunks returns the archived chunks kept aside while the entry is restored.
+func ArchivedChunks(entry *Entry) []*filer_pb.FileChunk {
+	if entry == nil || len(entry.Extended[ExtArchivedChunksKey]) == 0 {
+		return nil
+	}
+	manifest := &filer_pb.FileChunkManifest{}
+	if err := proto.Unmarshal(entry.Extended[ExtArchivedChunksKey], manifest); err != nil {
+		glog.Errorf("unmarshal archived chunks of %s: %v", entry.FullPath, err)
+		return nil
+	}
+	return manifest.Chunks
+}
+
+func restoreOngoing() string {
+	return `ongoing-request="true"`
+}
+
+func restoreCompleted(expiry time.Time) string {
+	return fmt.Sprintf(`ongoing-request="false", expiry-date="%s"`, expiry.UTC().Format(http.TimeFormat))
+}
+
+func restoreExpiry(entry *Entry) (expiry time.Time, found bool) {
+	matches := restoreExpiryRegexp.FindStringSubmatch(string(entry.Extended[ExtRestoreKey]))
+	if len(matches) < 2 {
+		return
+	}
+	expiry, err := time.Parse(http.TimeFormat, matches[1])
+	return expiry, err == nil
+}
+
+// RestoreExpiryAfterDays returns the expiration time of a restore, rounded up to the next midnight UTC as S3 does.
+func RestoreExpiryAfterDays(now time.Time, days int) time.Time {
+	t := now.UTC().Add(time.Duration(days) * 24 * time.Hour)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Add(24 * time.Hour)
+}
+
+func cloneEntryExtended(entry *Entry) *Entry {
+	cloned := entry.Clone()
+	cloned.Content = entry.Content
+	cloned.Extended = make(map[string][]byte)
+	for k, v := range entry.Extended {
+		cloned.Extended[k] = v
+	}
+	return cloned
+}
+
+// ArchivedEntry returns a copy of the entry marked as archived, with the chunks in the archive collection.
+func ArchivedEntry(entry *Entry, archivedChunks []*filer_pb.FileChunk) *Entry {
+	archived := cloneEntryExtended(entry)
+	archived.Chunks = archivedChunks
+	archived.Content = nil
+	archived.Extended[ExtStorageClassKey] = []byte(ArchiveStorageClass)
+	delete(archived.Extended, ExtRestoreKey)
+	delete(archived.Extended, ExtArchivedChunksKey)
+	return archived
+}
+
+// CompleteArchive marks the entry as archived with the chunks copied to the archive collection.
+// It fails if the entry changed since its content is copied.
+func (f *Filer) CompleteArchive(ctx context.Context, entry *Entry, archivedChunks []*filer_pb.FileChunk) error {
+	current, err := f.Store.FindEntry(ctx, entry.FullPath)
+	if err != nil {
+		return fmt.Errorf("archive %s: %v", entry.FullPath, err)
+	}
+	if IsArchived(current) || !sameChunks(current.Chunks, entry.Chunks) || !bytes.Equal(current.Content, entry.Content) {
+		return fmt.Errorf("archive %s: entry changed during archive", entry.FullPath)
+	}
+	if err = f.CreateEntry(ctx, ArchivedEntry(current, archivedChunks), false, false, nil); err != nil {
+		return fmt.Errorf("archive %s: %v", entry.FullPath, err)
+	}
+	return nil
+}
+
+// MarkRestoring marks the archived entry as being restored.
+func (f *Filer) MarkRestoring(ctx context.Context, entry *Entry) error {
+	restoring := cloneEntryExtended(entry)
+	restoring.Extended[ExtRestoreKey] = []byte(restoreOngoing())
+	if err := f.Store.UpdateEntry(ctx, restoring); err != nil {
+		return fmt.Errorf("mark %s as restoring: %v", entry.FullPath, err)
+	}
+	f.NotifyUpdateEvent(ctx, entry, restoring, false, false, nil)
+	return nil
+}
+
+// ExtendRestore updates the expiration time of an already restored entry.
+func (f *Filer) ExtendRestore(ctx context.Context, entry *Entry, expiry time.Time) error {
+	restored := cloneEntryExtended(entry)
+	restored.Extended[ExtRestoreKey] = []byte(restoreCompleted(expiry))
+	if err := f.Store.UpdateEntry(ctx, restored); err != nil {
+		return fmt.Errorf("extend restore %s: %v", entry.FullPath, err)
+	}
+	f.NotifyUpdateEvent(ctx, entry, restored, false, false, nil)
+	return nil
+}
+
+// CompleteRestore points the archived entry to the restored chunks until the expiry.
+// The archived chunks are kept in the extended attributes.
+// It fails if the entry changed since the restore started from the archived chunks.
+func (f *Filer) CompleteRestore(ctx context.Context, entry *Entry, archivedChunks, restoredChunks []*filer_pb.FileChunk, expiry time.Time) error {
+	if !IsRestoring(entry) || !sameChunks(entry.Chunks, archivedChunks) {
+		return fmt.Errorf("complete restore %s: entry changed during restore", entry.FullPath)
+	}
+	archivedChunksData, err := proto.Marshal(&filer_pb.FileChunkManifest{Chunks: entry.Chunks})
+	if err != nil {
+		return fmt.Errorf("marshal archived chunks: %v", err)
+	}
+	restored := cloneEntryExtended(entry)
+	restored.Chunks = restoredChunks
+	restored.Extended[ExtArchivedChunksKey] = archivedChunksData
+	restored.Extended[ExtRestoreKey] = []byte(restoreCompleted(expiry))
+
+	if err = f.updatePathIndex(ctx, &f.restores, entry.FullPath, true); err != nil {
+		return err
+	}
+	if err = f.Store.UpdateEntry(ctx, restored); err != nil {
+		return fmt.Errorf("complete restore %s: %v", entry.FullPath, err)
+	}
+	f.NotifyUpdateEvent(ctx, entry, restored, false, false, nil)
+	return nil
+}
+
+// CancelRestore clears the ongoing restore mark, if the restore failed.
+func (f *Filer) CancelRestore(ctx context.Context, entry *Entry) error {
+	if !IsRestoring(entry) {
+		return nil
+	}
+	cancelled := cloneEntryExtended(entry)
+	delete(cancelled.Extended, ExtRestoreKey)
+	if err := f.Store.UpdateEntry(ctx, cancelled); err != nil {
+		return fmt.Errorf("cancel restore %s: %v", entry.FullPath, err)
+	}
+	f.NotifyUpdateEvent(ctx, entry, cancelled, false, false, nil)
+	return nil
+}
+
+// expireRestore points the entry back to the archived chunks, and deletes the restored chunks.
+func (f *Filer) expireRestore(ctx context.Context, entry *Entry) error {
+	archivedChunks := ArchivedChunks(entry)
+	if len(archivedChunks) == 0 {
+		return fmt.Errorf("missing archived chunks of %s", entry.FullPath)
+	}
+	archived := cloneEntryExtended(entry)
+	archived.Chunks = archivedChunks
+	delete(archived.Extended, ExtArchivedChunksKey)
+	delete(archived.Extended, ExtRestoreKey)
+
+	if err := f.Store.UpdateEntry(ctx, archived); err != nil {
+		return fmt.Errorf("expire restore %s: %v", entry.FullPath, err)
+	}
+	f.NotifyUpdateEvent(ctx, entry, archived, false, false, nil)
+	f.DeleteChunks(entry.Chunks)
+	return nil
+}
+
+// LoopExpiringRestores periodically reverts restored entries after their restore expires.
+func (f *Filer) LoopExpiringRestores() {
+	for {
+		time.Sleep(time.Hour)
+		f.expireRestores(context.Background(), time.Now())
+	}
+}
+
+func (f *Filer) expireRestores(ctx context.Context, now time.Time) {
+	paths, err := f.loadPathIndex(ctx, &f.restores)
+	if err != nil {
+		glog.Errorf("load restored entries: %v", err)
+		return
+	}
+	for _, p := range paths {
+		entry, findErr := f.FindEntry(ctx, util.FullPath(p))
+		if findErr != nil || len(entry.Extended[ExtArchivedChunksKey]) == 0 {
+			// deleted, or overwritten with a normal entry
+			f.updatePathIndex(ctx, &f.restores, util.FullPath(p), false)
+			continue
+		}
+		if IsRestored(entry, now) {
+			continue
+		}
+		if err = f.expireRestore(ctx, entry); err != nil {
+			glog.Errorf("%v", err)
+			continue
+		}
+		glog.V(1).Infof("restored copy of %s expired", p)
+		f.updatePathIndex(ctx, &f.restores, util.FullPath(p), false)
+	}
+}
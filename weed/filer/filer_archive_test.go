@@ -0,0 +1,49 @@
+package filer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+)
+
+func TestRestoreExpiryAfterDays(t *testing.T) {
+	now := time.Date(2021, 3, 4, 15, 16, 17, 0, time.UTC)
+	expiry := RestoreExpiryAfterDays(now, 2)
+	if !expiry.Equal(time.Date(2021, 3, 7, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected expiry %v", expiry)
+	}
+}
+
+func TestArchiveStates(t *testing.T) {
+	entry := &Entry{
+		FullPath: "/a/b",
+		Chunks:   []*filer_pb.FileChunk{{FileId: "1,01", Size: 5}},
+	}
+	if IsArchived(entry) || !IsReadable(entry) {
+		t.Fatalf("normal entry should be readable")
+	}
+
+	archived := ArchivedEntry(entry, []*filer_pb.FileChunk{{FileId: "2,02", Size: 5}})
+	if !IsArchived(archived) || IsReadable(archived) || IsRestoring(archived) {
+		t.Fatalf("archived entry should not be readable")
+	}
+	if entry.Extended != nil && len(entry.Extended) > 0 {
+		t.Fatalf("original entry should not be changed")
+	}
+
+	archived.Extended[ExtRestoreKey] = []byte(restoreOngoing())
+	if !IsRestoring(archived) || IsReadable(archived) {
+		t.Fatalf("restoring entry should not be readable")
+	}
+
+	now := time.Now()
+	archived.Extended[ExtRestoreKey] = []byte(restoreCompleted(RestoreExpiryAfterDays(now, 1)))
+	archived.Extended[ExtArchivedChunksKey] = []byte{0}
+	if !IsRestored(archived, now) {
+		t.Fatalf("restored entry should be readable")
+	}
+	if IsRestored(archived, now.Add(48*time.Hour)) {
+		t.Fatalf("restore should expire")
+	}
+}
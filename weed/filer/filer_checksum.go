@@ -0,0 +1,200 @@
+package filer
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/chrislusf/seaweedfs/weed/wdclient"
+)
+
+/*
+Each file has a checksum of its whole content, kept in the entry attributes.
+
+The checksum is computed while uploading through the filer http api, which is also used by the s3 api.
+Files written otherwise, e.g. by mount, webdav, or assembled from s3 multipart uploads, are queued
+and their checksums are computed in the background, after the files are not modified for a while.
+
+The checksum is cleared whenever the content changes without a new checksum.
+The checksum is never taken from the clients. A client can send the expected checksum in the
+Seaweed-Checksum-Sha256 header when uploading, and the upload fails if it does not match.
+
+The md5 of the whole content is also kept when uploading through the filer http api. Both can be verified
+against the content on the volume servers with "fs.verify", or with "GET /path/to/file?verify" on the filer.
+*/
+
+const (
+	ChecksumAlgorithm     = "sha256"
+	ChecksumHeader        = "Seaweed-Checksum-Sha256"
+	ChecksumXattrName     = "user.seaweedfs.sha256"
+	checksumQuietDuration = 30 * time.Second
+)
+
+func NewChecksumHash() hash.Hash {
+	return sha256.New()
+}
+
+// ContentChecksum computes the checksum of the entry content, reading the chunks from the volume servers.
+// The server side encrypted entries should be decrypted first.
+func ContentChecksum(masterClient *wdclient.MasterClient, entry *Entry) ([]byte, error) {
+	h := NewChecksumHash()
+	if err := copyContent(masterClient, h, entry); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+func copyContent(masterClient *wdclient.MasterClient, w io.Writer, entry *Entry) error {
+	if len(entry.Content) > 0 || len(entry.Chunks) == 0 {
+		_, err := w.Write(entry.Content)
+		return err
+	}
+	reader := NewChunkStreamReaderFromFiler(masterClient, entry.Chunks)
+	defer reader.Close()
+	_, err := io.Copy(w, reader)
+	return err
+}
+
+// ChecksumVerification is the result of recomputing the checksums of a file, and comparing them with
+// the sha256 checksum and the md5 kept in the entry attributes at upload time.
+type ChecksumVerification struct {
+	Path           string   `json:"path"`
+	StoredSha256   string   `json:"storedSha256,omitempty"`
+	StoredMd5      string   `json:"storedMd5,omitempty"`
+	ComputedSha256 string   `json:"computedSha256"`
+	ComputedMd5    string   `json:"computedMd5"`
+	IsVerified     bool     `json:"isVerified"` // all the stored checksums match
+	Mismatches     []string `json:"mismatches,omitempty"`
+}
+
+// HasStoredChecksum checks whether the file has any stored checksum to verify against
+func (v *ChecksumVerification) HasStoredChecksum() bool {
+	return v.StoredSha256 != "" || v.StoredMd5 != ""
+}
+
+// VerifyChecksums reads the file content from the volume servers, and compares its checksums with the stored ones.
+// The server side encrypted entries should be decrypted first.
+func VerifyChecksums(masterClient *wdclient.MasterClient, entry *Entry) (*ChecksumVerification, error) {
+	sha256Hash, md5Hash := NewChecksumHash(), md5.New()
+	if err := copyContent(masterClient, io.MultiWriter(sha256Hash, md5Hash), entry); err != nil {
+		return nil, err
+	}
+	v := &ChecksumVerification{
+		Path:           string(entry.FullPath),
+		StoredSha256:   ChecksumHex(entry),
+		ComputedSha256: fmt.Sprintf("%x", sha256Hash.Sum(nil)),
+		ComputedMd5:    fmt.Sprintf("%x", md5Hash.Sum(nil)),
+	}
+	if len(entry.Md5) > 0 {
+		v.StoredMd5 = fmt.Sprintf("%x", entry.Md5)
+	}
+	if v.StoredSha256 != "" && v.StoredSha256 != v.ComputedSha256 {
+		v.Mismatches = append(v.Mismatches, ChecksumAlgorithm)
+	}
+	if v.StoredMd5 != "" && v.StoredMd5 != v.ComputedMd5 {
+		v.Mismatches = append(v.Mismatches, "md5")
+	}
+	v.IsVerified = v.HasStoredChecksum() && len(v.Mismatches) == 0
+	return v, nil
+}
+
+// ChecksumHex is the hex encoded checksum, or empty if not known.
+func ChecksumHex(entry *Entry) string {
+	if len(entry.Sha256) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%x", entry.Sha256)
+}
+
+// MaybeClearStaleChecksum clears the checksum copied from the old entry, if the content is changed.
+func MaybeClearStaleChecksum(oldEntry, entry *Entry) {
+	if oldEntry == nil || len(entry.Sha256) == 0 || !bytes.Equal(oldEntry.Sha256, entry.Sha256) {
+		return
+	}
+	if oldEntry.Size() != entry.Size() || !sameChunks(oldEntry.Chunks, entry.Chunks) || !bytes.Equal(oldEntry.Content, entry.Content) {
+		entry.Sha256 = nil
+	}
+}
+
+// EnsureChecksum sets the checksum of a file written without one, before the entry is saved.
+// The checksum of the inline content is computed right away, otherwise the file is queued.
+func (f *Filer) EnsureChecksum(entry *Entry) {
+	if entry.IsDirectory() || entry.SymlinkTarget != "" || len(entry.Sha256) > 0 {
+		return
+	}
+	if len(entry.Chunks) == 0 {
+		if entry.Size() != uint64(len(entry.Content)) {
+			// sparse, or the content is elsewhere
+			return
+		}
+		sum := sha256.Sum256(entry.Content)
+		entry.Sha256 = sum[:]
+		return
+	}
+	f.checksumQueue.EnQueue(string(entry.FullPath))
+}
+
+func (f *Filer) loopComputingChecksums() {
+	for {
+		time.Sleep(checksumQuietDuration)
+		f.checksumQueue.Consume(func(paths []string) {
+			computed := make(map[string]bool)
+			for _, p := range paths {
+				if computed[p] {
+					continue
+				}
+				computed[p] = true
+				if err := f.computeChecksum(context.Background(), util.FullPath(p), time.Now()); err != nil {
+					glog.V(1).Infof("compute checksum of %s: %v", p, err)
+				}
+			}
+		})
+	}
+}
+
+func (f *Filer) computeChecksum(ctx context.Context, p util.FullPath, now time.Time) error {
+	entry, err := f.FindEntry(ctx, p)
+	if err != nil {
+		return err
+	}
+	if entry.IsDirectory() || len(entry.Sha256) > 0 || len(entry.Chunks) == 0 || !IsReadable(entry) || IsSseCustomerEntry(entry) {
+		// the content encrypted with the customer key can not be read without the key
+		return nil
+	}
+	if now.Sub(entry.Mtime) < checksumQuietDuration {
+		// still being written
+		f.checksumQueue.EnQueue(string(p))
+		return nil
+	}
+
+	decrypted, err := f.DecryptSseEntry(entry)
+	if err != nil {
+		return err
+	}
+	checksum, err := ContentChecksum(f.MasterClient, decrypted)
+	if err != nil {
+		return err
+	}
+
+	current, err := f.Store.FindEntry(ctx, p)
+	if err != nil {
+		return err
+	}
+	if !sameChunks(current.Chunks, entry.Chunks) || len(current.Sha256) > 0 {
+		return nil
+	}
+	updated := cloneEntryExtended(current)
+	updated.Sha256 = checksum
+	if err = f.Store.UpdateEntry(ctx, updated); err != nil {
+		return err
+	}
+	f.NotifyUpdateEvent(ctx, current, updated, false, false, nil)
+	return nil
+}
@@ -0,0 +1,93 @@
+package filer
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"os"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func TestEnsureChecksum(t *testing.T) {
+	f := &Filer{checksumQueue: util.NewUnboundedQueue()}
+
+	small := &Entry{FullPath: "/a/small", Content: []byte("hello")}
+	f.EnsureChecksum(small)
+	if expected := sha256.Sum256([]byte("hello")); !bytes.Equal(small.Sha256, expected[:]) {
+		t.Errorf("unexpected checksum %s of inline content", ChecksumHex(small))
+	}
+
+	sparse := &Entry{FullPath: "/a/sparse", Attr: Attr{FileSize: 1024}}
+	f.EnsureChecksum(sparse)
+	if len(sparse.Sha256) > 0 {
+		t.Errorf("sparse file should not have the checksum of empty content")
+	}
+
+	dir := &Entry{FullPath: "/a/dir", Attr: Attr{Mode: os.ModeDir}}
+	f.EnsureChecksum(dir)
+	chunked := &Entry{FullPath: "/a/chunked", Chunks: []*filer_pb.FileChunk{{FileId: "1,01", Size: 5}}}
+	f.EnsureChecksum(chunked)
+
+	var queued []string
+	f.checksumQueue.Consume(func(paths []string) {
+		queued = append(queued, paths...)
+	})
+	if len(queued) != 1 || queued[0] != "/a/chunked" {
+		t.Errorf("unexpected queued files %v", queued)
+	}
+}
+
+func TestMaybeClearStaleChecksum(t *testing.T) {
+	oldEntry := &Entry{
+		FullPath: "/a/b",
+		Attr:     Attr{Sha256: []byte{1, 2, 3}},
+		Chunks:   []*filer_pb.FileChunk{{FileId: "1,01", Size: 5}},
+	}
+
+	unchanged := &Entry{FullPath: "/a/b", Attr: oldEntry.Attr, Chunks: oldEntry.Chunks}
+	MaybeClearStaleChecksum(oldEntry, unchanged)
+	if len(unchanged.Sha256) == 0 {
+		t.Errorf("checksum of unchanged content should be kept")
+	}
+
+	rewritten := &Entry{FullPath: "/a/b", Attr: oldEntry.Attr, Chunks: []*filer_pb.FileChunk{{FileId: "2,02", Size: 5}}}
+	MaybeClearStaleChecksum(oldEntry, rewritten)
+	if len(rewritten.Sha256) > 0 {
+		t.Errorf("checksum of changed content should be cleared")
+	}
+
+	recomputed := &Entry{FullPath: "/a/b", Attr: Attr{Sha256: []byte{4, 5, 6}}, Chunks: []*filer_pb.FileChunk{{FileId: "2,02", Size: 5}}}
+	MaybeClearStaleChecksum(oldEntry, recomputed)
+	if len(recomputed.Sha256) == 0 {
+		t.Errorf("new checksum should be kept")
+	}
+}
+
+func TestVerifyChecksums(t *testing.T) {
+	content := []byte("hello")
+	sha256Sum, md5Sum := sha256.Sum256(content), md5.Sum(content)
+
+	entry := &Entry{FullPath: "/a/small", Content: content, Attr: Attr{Md5: md5Sum[:], Sha256: sha256Sum[:]}}
+	if v, err := VerifyChecksums(nil, entry); err != nil || !v.IsVerified {
+		t.Errorf("unexpected verification %+v: %v", v, err)
+	}
+
+	entry.Content = []byte("hellO")
+	v, err := VerifyChecksums(nil, entry)
+	if err != nil || v.IsVerified || len(v.Mismatches) != 2 {
+		t.Errorf("unexpected verification %+v of changed content: %v", v, err)
+	}
+
+	md5Only := &Entry{FullPath: "/a/md5", Content: content, Attr: Attr{Md5: md5Sum[:]}}
+	if v, err := VerifyChecksums(nil, md5Only); err != nil || !v.IsVerified || v.StoredSha256 != "" {
+		t.Errorf("unexpected verification %+v of md5 only: %v", v, err)
+	}
+
+	none := &Entry{FullPath: "/a/none", Content: content}
+	if v, _ := VerifyChecksums(nil, none); v.IsVerified || v.HasStoredChecksum() {
+		t.Errorf("file without checksums should not be verified")
+	}
+}
@@ -0,0 +1,97 @@
+package filer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+The master can switch the whole cluster to read only, e.g. with "cluster.readonly".
+The filer polls the master for the switch, and rejects the writes with ErrClusterReadOnly,
+so the writes from S3 and mount also fail. Until the next poll, the writes fail when assigning the file ids.
+
+The entry writes are checked in the filer store wrapper, so the background jobs, e.g. the bulk deletes,
+the reconciler and the restores, also stop. The filer's own meta logs under SystemLogDir are not checked,
+and are flushed once the volumes are writable again. The kv writes for the internal state are not checked.
+*/
+
+const (
+	clusterReadOnlyPollInterval = 5 * time.Second
+)
+
+var ErrClusterReadOnly = errors.New("cluster is read only")
+
+type clusterReadOnly struct {
+	readOnly bool
+	reason   string
+	sync.RWMutex
+}
+
+// CheckClusterWritable returns ErrClusterReadOnly with the reason if the master has switched the cluster to read only
+func (f *Filer) CheckClusterWritable() error {
+	f.clusterReadOnly.RLock()
+	defer f.clusterReadOnly.RUnlock()
+	if !f.clusterReadOnly.readOnly {
+		return nil
+	}
+	if f.clusterReadOnly.reason == "" {
+		return ErrClusterReadOnly
+	}
+	return fmt.Errorf("%v: %s", ErrClusterReadOnly, f.clusterReadOnly.reason)
+}
+
+// checkClusterWritableFor checks the switch for the entry writes, except for the filer's own meta logs.
+func (f *Filer) checkClusterWritableFor(p util.FullPath) error {
+	if p == SystemLogDir || strings.HasPrefix(string(p), SystemLogDir+"/") {
+		return nil
+	}
+	return f.CheckClusterWritable()
+}
+
+func (f *Filer) setClusterReadOnly(readOnly bool, reason string) {
+	f.clusterReadOnly.Lock()
+	defer f.clusterReadOnly.Unlock()
+	if f.clusterReadOnly.readOnly != readOnly {
+		glog.V(0).Infof("cluster read only: %v %s", readOnly, reason)
+	}
+	f.clusterReadOnly.readOnly, f.clusterReadOnly.reason = readOnly, reason
+}
+
+// LoopCheckingClusterReadOnly periodically polls the current master for the cluster read only switch.
+func (f *Filer) LoopCheckingClusterReadOnly() {
+	for {
+		time.Sleep(clusterReadOnlyPollInterval)
+		master := f.GetMaster()
+		if master == "" {
+			continue
+		}
+		if err := f.checkClusterReadOnly(master); err != nil {
+			glog.V(1).Infof("check cluster read only on master %s: %v", master, err)
+		}
+	}
+}
+
+func (f *Filer) checkClusterReadOnly(master string) error {
+	data, _, err := util.Get(fmt.Sprintf("http://%s/cluster/readonly", master))
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		ReadOnly struct {
+			ReadOnly bool   `json:"readOnly"`
+			Reason   string `json:"reason"`
+		}
+	}
+	if err = json.Unmarshal(data, &resp); err != nil {
+		return err
+	}
+	f.setClusterReadOnly(resp.ReadOnly.ReadOnly, resp.ReadOnly.Reason)
+	return nil
+}
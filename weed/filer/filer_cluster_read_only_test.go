@@ -0,0 +1,78 @@
+package filer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterReadOnly(t *testing.T) {
+	response := `{"Version":"test","ReadOnly":{"readOnly":true,"reason":"migrating"}}`
+	master := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/cluster/readonly", r.URL.Path)
+		w.Write([]byte(response))
+	}))
+	defer master.Close()
+
+	f := &Filer{}
+	assert.Nil(t, f.CheckClusterWritable())
+
+	assert.Nil(t, f.checkClusterReadOnly(strings.TrimPrefix(master.URL, "http://")))
+	assert.EqualError(t, f.CheckClusterWritable(), "cluster is read only: migrating")
+
+	ctx := context.Background()
+	assert.Equal(t, f.CheckClusterWritable(), f.CreateEntry(ctx, &Entry{FullPath: "/a/b"}, false, false, nil))
+	assert.Equal(t, f.CheckClusterWritable(), f.UpdateEntry(ctx, nil, &Entry{FullPath: "/a/b"}))
+	assert.Equal(t, f.CheckClusterWritable(), f.DeleteEntryMetaAndData(ctx, "/a/b", false, false, true, false, nil))
+
+	response = `{"Version":"test","ReadOnly":{"readOnly":false}}`
+	assert.Nil(t, f.checkClusterReadOnly(strings.TrimPrefix(master.URL, "http://")))
+	assert.Nil(t, f.CheckClusterWritable())
+}
+
+// insertedStore only records the inserted paths, without any kv
+type insertedStore struct {
+	VirtualFilerStore
+	inserted []util.FullPath
+}
+
+func (store *insertedStore) GetName() string {
+	return "inserted"
+}
+
+func (store *insertedStore) KvGet(ctx context.Context, key []byte) ([]byte, error) {
+	return nil, ErrKvNotFound
+}
+
+func (store *insertedStore) KvPut(ctx context.Context, key []byte, value []byte) error {
+	return nil
+}
+
+func (store *insertedStore) InsertEntry(ctx context.Context, entry *Entry) error {
+	store.inserted = append(store.inserted, entry.FullPath)
+	return nil
+}
+
+func TestClusterReadOnlyStoreWrites(t *testing.T) {
+	store := &insertedStore{}
+	f := &Filer{}
+	f.SetStore(NewFilerStoreWrapper(store))
+	ctx := context.Background()
+
+	f.setClusterReadOnly(true, "maintenance")
+	err := f.Store.InsertEntry(ctx, &Entry{FullPath: "/home/a"})
+	assert.Contains(t, err.Error(), ErrClusterReadOnly.Error())
+	assert.Contains(t, err.Error(), "maintenance")
+
+	// the meta logs can still be written
+	assert.Nil(t, f.Store.InsertEntry(ctx, &Entry{FullPath: SystemLogDir + "/2021-01-01/00-00.segment"}))
+
+	f.setClusterReadOnly(false, "")
+	assert.Nil(t, f.Store.InsertEntry(ctx, &Entry{FullPath: "/home/a"}))
+	assert.Equal(t, []util.FullPath{SystemLogDir + "/2021-01-01/00-00.segment", "/home/a"}, store.inserted)
+}
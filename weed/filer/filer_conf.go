@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"io"
+	"strings"
 
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
 	"github.com/chrislusf/seaweedfs/weed/util"
 	"github.com/golang/protobuf/jsonpb"
 	"github.com/viant/ptrie"
@@ -22,7 +24,8 @@ const (
 )
 
 type FilerConf struct {
-	rules ptrie.Trie
+	rules  ptrie.Trie
+	routes []*filer_pb.FilerConf_RouteRule
 }
 
 func NewFilerConf() (fc *FilerConf) {
@@ -80,6 +83,9 @@ func (fc *FilerConf) doLoadConf(conf *filer_pb.FilerConf) (err error) {
 			return nil
 		}
 	}
+	for _, route := range conf.Routes {
+		fc.AddRouteRule(route)
+	}
 	return nil
 }
 
@@ -114,6 +120,84 @@ func (fc *FilerConf) MatchStorageRule(path string) (pathConf *filer_pb.FilerConf
 	return pathConf
 }
 
+// TtlSec returns the ttl in seconds of the new files under the path, from the longest location prefix with a ttl.
+// A ttl of "0" stops inheriting the ttl from the parent directories.
+func (fc *FilerConf) TtlSec(path string) int32 {
+	rule := fc.MatchStorageRule(path)
+	ttl, err := needle.ReadTTL(rule.Ttl)
+	if err != nil {
+		glog.Errorf("fail to parse %s ttl setting %s: %v", path, rule.Ttl, err)
+		return 0
+	}
+	return int32(ttl.Minutes()) * 60
+}
+
+// DirShardCount is the number of shards of the directory configured exactly for it, 0 if not sharded.
+// Unlike the other options, the sub directories are not sharded.
+func (fc *FilerConf) DirShardCount(dir string) int {
+	value, found := fc.rules.Get([]byte(strings.TrimSuffix(dir, "/") + "/"))
+	if !found {
+		return 0
+	}
+	return int(value.(*filer_pb.FilerConf_PathConf).DirectoryShards)
+}
+
+// GetLocationConf returns the configuration exactly for the location prefix
+func (fc *FilerConf) GetLocationConf(locationPrefix string) (pathConf *filer_pb.FilerConf_PathConf, found bool) {
+	value, found := fc.rules.Get([]byte(locationPrefix))
+	if !found {
+		return nil, false
+	}
+	return value.(*filer_pb.FilerConf_PathConf), true
+}
+
+// MatchQuotas returns the configurations with quotas of the directories containing the path.
+// A quota only applies to a location prefix ending with "/".
+func (fc *FilerConf) MatchQuotas(path string) (quotas []*filer_pb.FilerConf_PathConf) {
+	fc.rules.MatchPrefix([]byte(path), func(key []byte, value interface{}) bool {
+		if t := value.(*filer_pb.FilerConf_PathConf); hasQuota(t) {
+			quotas = append(quotas, t)
+		}
+		return true
+	})
+	return
+}
+
+// Quotas returns all the configurations with quotas
+func (fc *FilerConf) Quotas() (quotas []*filer_pb.FilerConf_PathConf) {
+	fc.rules.Walk(func(key []byte, value interface{}) bool {
+		if t := value.(*filer_pb.FilerConf_PathConf); hasQuota(t) {
+			quotas = append(quotas, t)
+		}
+		return true
+	})
+	return
+}
+
+// WormLocations returns all the configurations with worm
+func (fc *FilerConf) WormLocations() (locations []*filer_pb.FilerConf_PathConf) {
+	fc.rules.Walk(func(key []byte, value interface{}) bool {
+		if t := value.(*filer_pb.FilerConf_PathConf); t.Worm {
+			locations = append(locations, t)
+		}
+		return true
+	})
+	return
+}
+
+// HasLocationUnder checks whether any location prefix starts with the path, which would change by renaming the path
+func (fc *FilerConf) HasLocationUnder(path string) (found bool) {
+	fc.rules.Walk(func(key []byte, value interface{}) bool {
+		found = strings.HasPrefix(string(key), path)
+		return !found
+	})
+	return
+}
+
+func hasQuota(pathConf *filer_pb.FilerConf_PathConf) bool {
+	return (pathConf.QuotaBytes > 0 || pathConf.QuotaEntries > 0) && strings.HasSuffix(pathConf.LocationPrefix, "/")
+}
+
 // merge if values in b is not empty, merge them into a
 func mergePathConf(a, b *filer_pb.FilerConf_PathConf) {
 	a.Collection = util.Nvl(b.Collection, a.Collection)
@@ -126,6 +210,24 @@ func mergePathConf(a, b *filer_pb.FilerConf_PathConf) {
 	if b.VolumeGrowthCount > 0 {
 		a.VolumeGrowthCount = b.VolumeGrowthCount
 	}
+	if b.DirectoryEntriesLimit > 0 {
+		a.DirectoryEntriesLimit = b.DirectoryEntriesLimit
+	}
+	a.Versioning = b.Versioning || a.Versioning
+	if b.MaxVersions > 0 {
+		a.MaxVersions = b.MaxVersions
+	}
+	if b.VersionRetentionDays > 0 {
+		a.VersionRetentionDays = b.VersionRetentionDays
+	}
+	a.Worm = b.Worm || a.Worm
+	if b.WormGracePeriodSeconds > 0 {
+		a.WormGracePeriodSeconds = b.WormGracePeriodSeconds
+	}
+	if b.WormRetentionDays > 0 {
+		a.WormRetentionDays = b.WormRetentionDays
+	}
+	a.Dedup = b.Dedup || a.Dedup
 }
 
 func (fc *FilerConf) ToProto() *filer_pb.FilerConf {
@@ -135,6 +237,7 @@ func (fc *FilerConf) ToProto() *filer_pb.FilerConf {
 		m.Locations = append(m.Locations, pathConf)
 		return true
 	})
+	m.Routes = fc.routes
 	return m
 }
 
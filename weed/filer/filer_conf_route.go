@@ -0,0 +1,86 @@
+package filer
+
+import (
+	"mime"
+	"path"
+	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+)
+
+// AddRouteRule appends the routing rule, or replaces the rule with the same conditions in place.
+func (fc *FilerConf) AddRouteRule(route *filer_pb.FilerConf_RouteRule) {
+	for i, existing := range fc.routes {
+		if isSameRouteCondition(existing, route) {
+			fc.routes[i] = route
+			return
+		}
+	}
+	fc.routes = append(fc.routes, route)
+}
+
+// DeleteRouteRule deletes the routing rule with the same conditions.
+func (fc *FilerConf) DeleteRouteRule(route *filer_pb.FilerConf_RouteRule) {
+	var routes []*filer_pb.FilerConf_RouteRule
+	for _, existing := range fc.routes {
+		if !isSameRouteCondition(existing, route) {
+			routes = append(routes, existing)
+		}
+	}
+	fc.routes = routes
+}
+
+// MatchRouteRule returns the first routing rule matching the new file, or nil if none matches.
+// A negative size means the size is not known yet, which does not match the rules with a size condition.
+func (fc *FilerConf) MatchRouteRule(fullpath string, contentType string, size int64) *filer_pb.FilerConf_RouteRule {
+	for _, route := range fc.routes {
+		if isRouteMatched(route, fullpath, contentType, size) {
+			return route
+		}
+	}
+	return nil
+}
+
+func isSameRouteCondition(a, b *filer_pb.FilerConf_RouteRule) bool {
+	return a.LocationPrefix == b.LocationPrefix &&
+		a.NamePattern == b.NamePattern &&
+		a.ContentType == b.ContentType &&
+		a.MinSize == b.MinSize &&
+		a.MaxSize == b.MaxSize
+}
+
+func isRouteMatched(route *filer_pb.FilerConf_RouteRule, fullpath string, contentType string, size int64) bool {
+	if !strings.HasPrefix(fullpath, route.LocationPrefix) {
+		return false
+	}
+	if route.NamePattern != "" {
+		if matched, _ := path.Match(route.NamePattern, path.Base(fullpath)); !matched {
+			return false
+		}
+	}
+	if route.ContentType != "" && !isContentTypeMatched(route.ContentType, contentType) {
+		return false
+	}
+	if route.MinSize > 0 || route.MaxSize > 0 {
+		if size < 0 || uint64(size) < route.MinSize {
+			return false
+		}
+		if route.MaxSize > 0 && uint64(size) >= route.MaxSize {
+			return false
+		}
+	}
+	return true
+}
+
+// isContentTypeMatched matches the media type ignoring the parameters, with "type/*" matching any subtype.
+func isContentTypeMatched(pattern, contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	pattern = strings.ToLower(pattern)
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*"))
+	}
+	return mediaType == pattern
+}
@@ -32,3 +32,46 @@ func TestFilerConf(t *testing.T) {
 	assert.Equal(t, "001", fc.MatchStorageRule("/buckets/abc/jasdf").Replication)
 
 }
+
+func TestFilerConfRoutes(t *testing.T) {
+
+	fc := NewFilerConf()
+
+	conf := &filer_pb.FilerConf{Routes: []*filer_pb.FilerConf_RouteRule{
+		{
+			LocationPrefix: "/",
+			NamePattern:    "*.tmp",
+			Collection:     "tmp",
+			Ttl:            "1d",
+		},
+		{
+			LocationPrefix: "/data/",
+			ContentType:    "video/*",
+			Collection:     "videos",
+		},
+		{
+			LocationPrefix: "/data/",
+			MinSize:        1024,
+			MaxSize:        4096,
+			DiskType:       "hdd",
+		},
+	}}
+	fc.doLoadConf(conf)
+
+	assert.Equal(t, "tmp", fc.MatchRouteRule("/data/a.tmp", "video/mp4", 2048).Collection)
+	assert.Equal(t, "videos", fc.MatchRouteRule("/data/a.mp4", "video/mp4; codecs=avc1", 2048).Collection)
+	assert.Equal(t, "hdd", fc.MatchRouteRule("/data/a.txt", "text/plain", 2048).DiskType)
+	assert.Nil(t, fc.MatchRouteRule("/data/a.txt", "text/plain", 4096))
+	assert.Nil(t, fc.MatchRouteRule("/data/a.txt", "", -1))
+	assert.Nil(t, fc.MatchRouteRule("/other/a.mp4", "video/mp4", 2048))
+
+	// replace the route with the same conditions
+	fc.AddRouteRule(&filer_pb.FilerConf_RouteRule{LocationPrefix: "/", NamePattern: "*.tmp", Collection: "scratch"})
+	assert.Equal(t, "scratch", fc.MatchRouteRule("/a.tmp", "", -1).Collection)
+	assert.Equal(t, 3, len(fc.ToProto().Routes))
+
+	fc.DeleteRouteRule(&filer_pb.FilerConf_RouteRule{LocationPrefix: "/", NamePattern: "*.tmp"})
+	assert.Nil(t, fc.MatchRouteRule("/a.tmp", "", -1))
+	assert.Equal(t, 2, len(fc.ToProto().Routes))
+
+}
@@ -0,0 +1,209 @@
+package filer
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+)
+
+/*
+The files uploaded with dedup, by "dedup" in filer.conf, "dedup=true" in the upload request, or "filer.copy -dedup",
+are split by their content with a rolling hash, and each distinct chunk is stored only once.
+
+The dedup index is kept in the filer kv, with one record per chunk, keyed by the chunk fingerprint:
+the sha256 of the chunk content, together with the collection, replication and disk type the chunk is stored with.
+The record has the stored chunk and the number of the references to it.
+
+	upload      a chunk found in the index gets one more reference, instead of being uploaded again
+	deletion    removes one reference, and the chunk is deleted from the volume servers with the last reference
+
+A deduped chunk keeps its fingerprint, and each reference has its own chunk mtime, so overwriting a file
+releases the references of the old file, even for the same content, while the metadata updates keep them.
+
+The files with ttl are not deduped, since their chunks expire. The filer stores have no compare-and-set,
+so the references are only counted on the lock filer, elected by the filers sharing the filer store:
+the http uploads with dedup to the other filers are refused, the DedupChunks requests are forwarded to the lock filer,
+and the other filers send the deduped chunks to delete to the lock filer, which releases and deletes them.
+The chunks replicated by filer.sync are not deduped on the target cluster.
+*/
+
+const dedupKvKeyPrefix = "dedup."
+
+var ErrNotDedupFiler = errors.New("the dedup references are only counted on the lock filer")
+
+// DedupFingerprint is the key of the chunk with the content sha256, stored with the collection, replication and disk type.
+func DedupFingerprint(contentSha256 []byte, collection, replication, diskType string) []byte {
+	h := sha256.New()
+	h.Write(contentSha256)
+	fmt.Fprintf(h, "\x00%s\x00%s\x00%s", collection, replication, diskType)
+	return h.Sum(nil)
+}
+
+type dedupIndex struct {
+	lock sync.Mutex
+}
+
+func dedupKvKey(fingerprint []byte) []byte {
+	return []byte(fmt.Sprintf("%s%x", dedupKvKeyPrefix, fingerprint))
+}
+
+func (f *Filer) loadDedupChunk(ctx context.Context, fingerprint []byte) (*filer_pb.DedupChunk, error) {
+	data, err := f.Store.KvGet(ctx, dedupKvKey(fingerprint))
+	if err == ErrKvNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read dedup chunk %x: %v", fingerprint, err)
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	record := &filer_pb.DedupChunk{}
+	if err = proto.Unmarshal(data, record); err != nil {
+		return nil, fmt.Errorf("decode dedup chunk %x: %v", fingerprint, err)
+	}
+	return record, nil
+}
+
+func (f *Filer) saveDedupChunk(ctx context.Context, fingerprint []byte, record *filer_pb.DedupChunk) error {
+	if record.RefCount <= 0 {
+		if err := f.Store.KvDelete(ctx, dedupKvKey(fingerprint)); err != nil && err != ErrKvNotFound {
+			return fmt.Errorf("delete dedup chunk %x: %v", fingerprint, err)
+		}
+		return nil
+	}
+	data, err := proto.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err = f.Store.KvPut(ctx, dedupKvKey(fingerprint), data); err != nil {
+		return fmt.Errorf("save dedup chunk %x: %v", fingerprint, err)
+	}
+	return nil
+}
+
+// ReferenceDedupChunk adds one reference to the chunk with the fingerprint, and returns the chunk for the offset.
+// If not found, the uploaded chunk is registered with one reference, or nil is returned if there is no uploaded chunk.
+// The returned chunk may be a different one registered concurrently, and then the uploaded chunk is not used.
+func (f *Filer) ReferenceDedupChunk(ctx context.Context, fingerprint, contentSha256 []byte, uploaded *filer_pb.FileChunk, offset int64) (*filer_pb.FileChunk, error) {
+	if !f.IsLockFiler() {
+		return nil, ErrNotDedupFiler
+	}
+	f.dedup.lock.Lock()
+	defer f.dedup.lock.Unlock()
+
+	record, err := f.loadDedupChunk(ctx, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		if uploaded == nil {
+			return nil, nil
+		}
+		record = &filer_pb.DedupChunk{
+			ContentSha256: contentSha256,
+			Chunk:         proto.Clone(uploaded).(*filer_pb.FileChunk),
+		}
+		record.Chunk.Fingerprint = fingerprint
+	}
+	record.RefCount++
+	if err = f.saveDedupChunk(ctx, fingerprint, record); err != nil {
+		return nil, err
+	}
+
+	chunk := proto.Clone(record.Chunk).(*filer_pb.FileChunk)
+	chunk.Offset, chunk.Mtime = offset, time.Now().UnixNano()
+	return chunk, nil
+}
+
+// releaseDedupChunks removes one reference to each deduped chunk, and returns the chunks to delete.
+// On the other filers, the deduped chunks are sent to the lock filer, which releases and deletes them.
+func (f *Filer) releaseDedupChunks(chunks []*filer_pb.FileChunk) (toDelete []*filer_pb.FileChunk) {
+	var toSend []*filer_pb.FileChunk
+	isLockFiler := f.IsLockFiler()
+	for _, chunk := range chunks {
+		if len(chunk.Fingerprint) == 0 {
+			toDelete = append(toDelete, chunk)
+		} else if !isLockFiler {
+			toSend = append(toSend, chunk)
+		} else if !f.releaseDedupChunk(chunk) {
+			toDelete = append(toDelete, chunk)
+		}
+	}
+	if len(toSend) > 0 {
+		if err := f.sendDedupChunksToRelease(toSend); err != nil {
+			// keep the chunks, since they may still be referenced
+			glog.Errorf("release %d dedup chunks on the lock filer: %v", len(toSend), err)
+		}
+	}
+	return
+}
+
+// ReleaseDedupChunks releases the deduped chunks sent by the other filers, and deletes the ones not referenced any more.
+func (f *Filer) ReleaseDedupChunks(chunks []*filer_pb.FileChunk) error {
+	if !f.IsLockFiler() {
+		return ErrNotDedupFiler
+	}
+	f.DeleteChunks(chunks)
+	return nil
+}
+
+func (f *Filer) sendDedupChunksToRelease(chunks []*filer_pb.FileChunk) error {
+	lockFiler, err := f.FindLockFiler(context.Background())
+	if err != nil {
+		return err
+	}
+	return pb.WithFilerClient(lockFiler, f.GrpcDialOption, func(client filer_pb.SeaweedFilerClient) error {
+		resp, err := client.ReleaseDedupChunks(context.Background(), &filer_pb.ReleaseDedupChunksRequest{Chunks: chunks})
+		if err != nil {
+			return err
+		}
+		if resp.Error != "" {
+			return errors.New(resp.Error)
+		}
+		return nil
+	})
+}
+
+// releaseDedupChunk removes one reference to the deduped chunk, and returns whether the chunk is still referenced.
+// The deduped chunks missing in the dedup index, e.g. with the index lost in a filer store migration,
+// are kept, since the other references can not be counted.
+func (f *Filer) releaseDedupChunk(chunk *filer_pb.FileChunk) (isReferenced bool) {
+	f.dedup.lock.Lock()
+	defer f.dedup.lock.Unlock()
+
+	ctx := context.Background()
+	record, err := f.loadDedupChunk(ctx, chunk.Fingerprint)
+	if err != nil {
+		// keep the chunk, since it may still be referenced
+		glog.Errorf("release dedup chunk %s: %v", chunk.GetFileIdString(), err)
+		return true
+	}
+	if record == nil || record.Chunk.GetFileIdString() != chunk.GetFileIdString() {
+		glog.Warningf("release dedup chunk %s: not in the dedup index, kept", chunk.GetFileIdString())
+		return true
+	}
+	record.RefCount--
+	if err = f.saveDedupChunk(ctx, chunk.Fingerprint, record); err != nil {
+		glog.Errorf("release dedup chunk %s: %v", chunk.GetFileIdString(), err)
+		return true
+	}
+	return record.RefCount > 0
+}
+
+// chunkReferenceKey tells apart the references to the same deduped chunk by their chunk mtime
+func chunkReferenceKey(chunk *filer_pb.FileChunk) string {
+	if len(chunk.Fingerprint) == 0 {
+		return chunk.GetFileIdString()
+	}
+	return fmt.Sprintf("%s@%d", chunk.GetFileIdString(), chunk.Mtime)
+}
@@ -0,0 +1,109 @@
+package filer
+
+import (
+	"context"
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/stretchr/testify/assert"
+)
+
+type kvOnlyStore struct {
+	VirtualFilerStore
+	kv map[string][]byte
+}
+
+func (store *kvOnlyStore) KvPut(ctx context.Context, key []byte, value []byte) error {
+	store.kv[string(key)] = value
+	return nil
+}
+
+func (store *kvOnlyStore) KvGet(ctx context.Context, key []byte) ([]byte, error) {
+	if value, found := store.kv[string(key)]; found {
+		return value, nil
+	}
+	return nil, ErrKvNotFound
+}
+
+func (store *kvOnlyStore) KvDelete(ctx context.Context, key []byte) error {
+	delete(store.kv, string(key))
+	return nil
+}
+
+func TestDedupChunkReferences(t *testing.T) {
+
+	store := &kvOnlyStore{kv: make(map[string][]byte)}
+	f := &Filer{Store: store, fileIdDeletionQueue: util.NewUnboundedQueue()}
+	f.lockFiler.leasedAtNs = time.Now().UnixNano()
+	ctx := context.Background()
+
+	contentSha256 := sha256.Sum256([]byte("chunk content"))
+	fingerprint := DedupFingerprint(contentSha256[:], "c1", "000", "")
+	assert.NotEqual(t, fingerprint, DedupFingerprint(contentSha256[:], "c2", "000", ""), "scoped by collection")
+
+	chunk, err := f.ReferenceDedupChunk(ctx, fingerprint, contentSha256[:], nil, 0)
+	assert.Nil(t, err)
+	assert.Nil(t, chunk, "not stored yet")
+
+	uploaded := &filer_pb.FileChunk{FileId: "1,0101", Size: 13, Mtime: 1}
+	first, err := f.ReferenceDedupChunk(ctx, fingerprint, contentSha256[:], uploaded, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "1,0101", first.FileId)
+	assert.Equal(t, fingerprint, first.Fingerprint)
+
+	second, err := f.ReferenceDedupChunk(ctx, fingerprint, contentSha256[:], nil, 26)
+	assert.Nil(t, err)
+	assert.Equal(t, "1,0101", second.FileId)
+	assert.Equal(t, int64(26), second.Offset)
+	second.Mtime = first.Mtime + 1
+
+	deletedFileIds := func() (fileIds []string) {
+		f.fileIdDeletionQueue.Consume(func(queued []string) {
+			fileIds = append(fileIds, queued...)
+		})
+		return
+	}
+
+	// the metadata updates keep the references
+	oldEntry := &Entry{FullPath: "/a", Chunks: []*filer_pb.FileChunk{first}}
+	f.deleteChunksIfNotNew(oldEntry, &Entry{FullPath: "/a", Chunks: []*filer_pb.FileChunk{first}})
+	assert.Nil(t, deletedFileIds())
+
+	// overwriting with the same content releases the old reference
+	f.deleteChunksIfNotNew(oldEntry, &Entry{FullPath: "/a", Chunks: []*filer_pb.FileChunk{second}})
+	assert.Nil(t, deletedFileIds(), "still referenced")
+
+	f.DeleteChunks([]*filer_pb.FileChunk{second, {FileId: "2,0202"}})
+	assert.Equal(t, []string{"1,0101", "2,0202"}, deletedFileIds())
+	assert.Equal(t, 0, len(store.kv))
+
+	// the deduped chunks missing in the dedup index may still be referenced
+	f.DeleteChunks([]*filer_pb.FileChunk{{FileId: "3,0303", Fingerprint: fingerprint}})
+	assert.Nil(t, deletedFileIds(), "not in the dedup index")
+}
+
+func TestDedupOnlyOnLockFiler(t *testing.T) {
+
+	store := &kvOnlyStore{kv: make(map[string][]byte)}
+	f := &Filer{Store: store, fileIdDeletionQueue: util.NewUnboundedQueue()}
+	ctx := context.Background()
+
+	contentSha256 := sha256.Sum256([]byte("chunk content"))
+	fingerprint := DedupFingerprint(contentSha256[:], "c1", "000", "")
+	uploaded := &filer_pb.FileChunk{FileId: "1,0101", Size: 13, Mtime: 1}
+
+	_, err := f.ReferenceDedupChunk(ctx, fingerprint, contentSha256[:], uploaded, 0)
+	assert.Equal(t, ErrNotDedupFiler, err)
+	assert.Equal(t, ErrNotDedupFiler, f.ReleaseDedupChunks([]*filer_pb.FileChunk{uploaded}))
+
+	// the deduped chunks are kept if the lock filer can not release them
+	f.DeleteChunks([]*filer_pb.FileChunk{{FileId: "1,0101", Fingerprint: fingerprint}, {FileId: "2,0202"}})
+	var fileIds []string
+	f.fileIdDeletionQueue.Consume(func(queued []string) {
+		fileIds = append(fileIds, queued...)
+	})
+	assert.Equal(t, []string{"2,0202"}, fileIds)
+}
@@ -20,16 +20,38 @@ func (f *Filer) DeleteEntryMetaAndData(ctx context.Context, p util.FullPath, isR
 		return nil
 	}
 
+	if err = f.checkClusterWritableFor(p); err != nil {
+		return err
+	}
+
 	entry, findErr := f.FindEntry(ctx, p)
 	if findErr != nil {
 		return findErr
 	}
 
+	if err = f.CheckNotHeld(ctx, entry); err != nil {
+		return err
+	}
+	if err = f.CheckNotRetained(ctx, entry); err != nil {
+		return err
+	}
+
 	isDeleteCollection := f.isBucket(entry)
 
+	isVersionKept := false
+	if !isFromOtherCluster {
+		if isVersionKept, err = f.KeepVersion(ctx, entry, nil); err != nil {
+			return err
+		}
+	}
+
 	var chunks []*filer_pb.FileChunk
 	var hardLinkIds []HardLinkId
-	chunks = append(chunks, entry.Chunks...)
+	if !isVersionKept && len(entry.HardLinkId) == 0 {
+		// hard link chunk data are deleted separately
+		chunks = append(chunks, entry.Chunks...)
+		chunks = append(chunks, ArchivedChunks(entry)...)
+	}
 	if entry.IsDirectory() {
 		// delete the folder children, not including the folder itself
 		var dirChunks []*filer_pb.FileChunk
@@ -44,10 +66,13 @@ func (f *Filer) DeleteEntryMetaAndData(ctx context.Context, p util.FullPath, isR
 	}
 
 	// delete the file or folder
-	err = f.doDeleteEntryMetaAndData(ctx, entry, shouldDeleteChunks, isFromOtherCluster, signatures)
+	err = f.doDeleteEntryMetaAndData(ctx, entry, shouldDeleteChunks && !isVersionKept, isFromOtherCluster, signatures)
 	if err != nil {
 		return fmt.Errorf("delete file %s: %v", p, err)
 	}
+	if isVersionKept {
+		f.PruneVersions(ctx, p, nil)
+	}
 
 	if shouldDeleteChunks && !isDeleteCollection {
 		f.DirectDeleteChunks(chunks)
@@ -94,12 +119,22 @@ func (f *Filer) doBatchDeleteFolderMetaAndData(ctx context.Context, entry *Entry
 					chunks = append(chunks, dirChunks...)
 					hardlinkIds = append(hardlinkIds, dirHardLinkIds...)
 				} else {
-					f.NotifyUpdateEvent(ctx, sub, nil, shouldDeleteChunks, isFromOtherCluster, nil)
-					if len(sub.HardLinkId) != 0 {
+					isVersionKept := false
+					if !isFromOtherCluster {
+						if isVersionKept, err = f.KeepVersion(ctx, sub, nil); err != nil && !ignoreRecursiveError {
+							return nil, nil, err
+						}
+					}
+					f.NotifyUpdateEvent(ctx, sub, nil, shouldDeleteChunks && !isVersionKept, isFromOtherCluster, nil)
+					if isVersionKept {
+						// the chunks are kept by the version
+						f.PruneVersions(ctx, sub.FullPath, nil)
+					} else if len(sub.HardLinkId) != 0 {
 						// hard link chunk data are deleted separately
 						hardlinkIds = append(hardlinkIds, sub.HardLinkId)
 					} else {
 						chunks = append(chunks, sub.Chunks...)
+						chunks = append(chunks, ArchivedChunks(sub)...)
 					}
 				}
 				if err != nil && !ignoreRecursiveError {
@@ -118,6 +153,9 @@ func (f *Filer) doBatchDeleteFolderMetaAndData(ctx context.Context, entry *Entry
 	if storeDeletionErr := f.Store.DeleteFolderChildren(ctx, entry.FullPath); storeDeletionErr != nil {
 		return nil, nil, fmt.Errorf("filer store delete: %v", storeDeletionErr)
 	}
+	if isDeletingBucket {
+		f.invalidateStoreCacheTree(ctx, entry.FullPath)
+	}
 
 	f.NotifyUpdateEvent(ctx, entry, nil, shouldDeleteChunks, isFromOtherCluster, signatures)
 
@@ -0,0 +1,351 @@
+package filer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/uuid"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+A bulk delete job deletes many entries on the filer side, instead of clients deleting them one by one.
+
+The entries are listed and deleted page by page, so the memory usage does not grow with the number of entries.
+The chunks are queued to the filer deletion queue, which deletes them in batches on volume servers.
+
+The deletion is throttled to the entries per second of the job, or EntriesPerSecond of the filer,
+so deleting a huge directory does not slow down the other requests to the filer store.
+
+The progress of the running jobs is saved in the filer store kv every deleteJobCheckpointInterval,
+and the jobs are resumed after the filer restarts. Since the deleted entries are gone,
+a resumed job just lists the remaining entries again. The completed jobs are forgotten after deleteJobRetention.
+*/
+
+const (
+	deleteJobRetention          = time.Hour
+	maxDeleteJobFailures        = 1000
+	deleteJobCheckpointInterval = 5 * time.Second
+	deleteJobIndexName          = "delete.jobs"
+	deleteJobKvKeyPrefix        = "bulkDeleteJob."
+
+	DefaultDeleteJobEntriesPerSecond = 10000
+)
+
+type DeleteJobs struct {
+	sync.Mutex
+	jobs             map[string]*DeleteJob
+	index            pathIndex
+	EntriesPerSecond int64
+}
+
+type DeleteJob struct {
+	paths          []util.FullPath
+	limiter        *util.RateLimiter
+	checkpointedAt time.Time
+	done           chan struct{}
+
+	sync.RWMutex
+	status *filer_pb.BulkDeleteJob
+}
+
+func NewDeleteJobs() *DeleteJobs {
+	return &DeleteJobs{
+		jobs:             make(map[string]*DeleteJob),
+		index:            pathIndex{name: deleteJobIndexName},
+		EntriesPerSecond: DefaultDeleteJobEntriesPerSecond,
+	}
+}
+
+// StartDeleteJob deletes the entries under the directory with the name prefix, or the listed paths, in the background.
+// The deletion is throttled to entriesPerSecond, or the filer default if not positive.
+func (f *Filer) StartDeleteJob(dir util.FullPath, namePrefix string, paths []util.FullPath, isRecursive, isDeleteData bool, entriesPerSecond int64) (*DeleteJob, error) {
+
+	if len(paths) == 0 && dir == "" {
+		return nil, fmt.Errorf("missing directory or paths to delete")
+	}
+	if len(paths) > 0 && dir != "" {
+		return nil, fmt.Errorf("can not delete both a directory and paths")
+	}
+	if dir == "/" && namePrefix == "" {
+		return nil, fmt.Errorf("can not delete everything under /")
+	}
+
+	if entriesPerSecond <= 0 {
+		entriesPerSecond = f.DeleteJobs.EntriesPerSecond
+	}
+
+	job := newDeleteJob(&filer_pb.BulkDeleteJob{
+		JobId:            uuid.New().String(),
+		Directory:        string(dir),
+		NamePrefix:       namePrefix,
+		PathCount:        int64(len(paths)),
+		StartedAtNs:      time.Now().UnixNano(),
+		IsRecursive:      isRecursive,
+		IsDeleteData:     isDeleteData,
+		EntriesPerSecond: entriesPerSecond,
+	}, paths)
+
+	ctx := context.Background()
+	f.DeleteJobs.add(job)
+	if err := f.updatePathIndex(ctx, &f.DeleteJobs.index, util.FullPath(job.status.JobId), true); err != nil {
+		glog.Warningf("bulk delete job %s will not be resumed: %v", job.status.JobId, err)
+	}
+	f.checkpointDeleteJob(ctx, job)
+
+	go f.runDeleteJob(job)
+
+	return job, nil
+}
+
+func newDeleteJob(status *filer_pb.BulkDeleteJob, paths []util.FullPath) *DeleteJob {
+	return &DeleteJob{
+		paths:   paths,
+		limiter: util.NewRateLimiter(status.EntriesPerSecond),
+		done:    make(chan struct{}),
+		status:  status,
+	}
+}
+
+// ResumeDeleteJobs resumes the delete jobs started by this filer before it restarts.
+// The jobs are saved per filer address, so the filers sharing a filer store only resume their own jobs.
+func (f *Filer) ResumeDeleteJobs(self string) {
+	ctx := context.Background()
+	f.DeleteJobs.index.name = deleteJobIndexName + "." + self
+
+	jobIds, err := f.loadPathIndex(ctx, &f.DeleteJobs.index)
+	if err != nil {
+		glog.Errorf("load bulk delete jobs: %v", err)
+		return
+	}
+	for _, jobId := range jobIds {
+		checkpoint, err := f.loadDeleteJob(ctx, jobId)
+		if err != nil || checkpoint.Job == nil || checkpoint.Job.CompletedAtNs != 0 {
+			if err != nil && err != ErrKvNotFound {
+				glog.Errorf("load bulk delete job %s: %v", jobId, err)
+			}
+			f.updatePathIndex(ctx, &f.DeleteJobs.index, util.FullPath(jobId), false)
+			continue
+		}
+		var paths []util.FullPath
+		for _, p := range checkpoint.Paths {
+			paths = append(paths, util.FullPath(p))
+		}
+		checkpoint.Job.ResumedCount++
+		job := newDeleteJob(checkpoint.Job, paths)
+		f.DeleteJobs.add(job)
+		glog.V(0).Infof("resume bulk delete job %s: %s prefix %s, %d paths, %d deleted", jobId, checkpoint.Job.Directory, checkpoint.Job.NamePrefix, len(paths), checkpoint.Job.DeletedEntryCount)
+		go f.runDeleteJob(job)
+	}
+}
+
+// ListDeleteJobs returns the status of the running and the recently completed jobs, the latest first.
+func (f *Filer) ListDeleteJobs() (jobs []*filer_pb.BulkDeleteJob) {
+	f.DeleteJobs.Lock()
+	for _, job := range f.DeleteJobs.jobs {
+		jobs = append(jobs, job.Status())
+	}
+	f.DeleteJobs.Unlock()
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].StartedAtNs > jobs[j].StartedAtNs
+	})
+	return
+}
+
+func (f *Filer) FindDeleteJob(jobId string) (*DeleteJob, bool) {
+	f.DeleteJobs.Lock()
+	defer f.DeleteJobs.Unlock()
+	job, found := f.DeleteJobs.jobs[jobId]
+	return job, found
+}
+
+func (jobs *DeleteJobs) add(job *DeleteJob) {
+	jobs.Lock()
+	defer jobs.Unlock()
+	cutoff := time.Now().Add(-deleteJobRetention).UnixNano()
+	for id, j := range jobs.jobs {
+		if completedAtNs := j.Status().CompletedAtNs; completedAtNs != 0 && completedAtNs < cutoff {
+			delete(jobs.jobs, id)
+		}
+	}
+	jobs.jobs[job.status.JobId] = job
+}
+
+// Status returns a snapshot of the job progress.
+func (job *DeleteJob) Status() *filer_pb.BulkDeleteJob {
+	job.RLock()
+	defer job.RUnlock()
+	return proto.Clone(job.status).(*filer_pb.BulkDeleteJob)
+}
+
+// Wait returns true if the job completed within the timeout.
+func (job *DeleteJob) Wait(timeout time.Duration) bool {
+	select {
+	case <-job.done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func (f *Filer) loadDeleteJob(ctx context.Context, jobId string) (*filer_pb.BulkDeleteJobCheckpoint, error) {
+	data, err := f.Store.KvGet(ctx, []byte(deleteJobKvKeyPrefix+jobId))
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := &filer_pb.BulkDeleteJobCheckpoint{}
+	if err = proto.Unmarshal(data, checkpoint); err != nil {
+		return nil, err
+	}
+	return checkpoint, nil
+}
+
+// checkpointDeleteJob saves the job progress, or removes the saved job after it completes.
+func (f *Filer) checkpointDeleteJob(ctx context.Context, job *DeleteJob) {
+	status := job.Status()
+	key := []byte(deleteJobKvKeyPrefix + status.JobId)
+	job.checkpointedAt = time.Now()
+
+	if status.CompletedAtNs != 0 {
+		if err := f.Store.KvDelete(ctx, key); err != nil && err != ErrKvNotImplemented {
+			glog.Warningf("remove bulk delete job %s: %v", status.JobId, err)
+		}
+		f.updatePathIndex(ctx, &f.DeleteJobs.index, util.FullPath(status.JobId), false)
+		return
+	}
+
+	checkpoint := &filer_pb.BulkDeleteJobCheckpoint{Job: status}
+	for _, p := range job.paths {
+		checkpoint.Paths = append(checkpoint.Paths, string(p))
+	}
+	data, err := proto.Marshal(checkpoint)
+	if err != nil {
+		glog.Errorf("marshal bulk delete job %s: %v", status.JobId, err)
+		return
+	}
+	if err = f.Store.KvPut(ctx, key, data); err != nil && err != ErrKvNotImplemented {
+		glog.Warningf("save bulk delete job %s: %v", status.JobId, err)
+	}
+}
+
+func (f *Filer) maybeCheckpointDeleteJob(ctx context.Context, job *DeleteJob) {
+	if job.checkpointedAt.Add(deleteJobCheckpointInterval).Before(time.Now()) {
+		f.checkpointDeleteJob(ctx, job)
+	}
+}
+
+func (job *DeleteJob) deleted(chunkCount int) {
+	job.Lock()
+	job.status.DeletedEntryCount++
+	job.status.DeletedChunkCount += int64(chunkCount)
+	job.Unlock()
+}
+
+func (job *DeleteJob) failed(p util.FullPath, err error) {
+	glog.V(1).Infof("bulk delete job %s: delete %s: %v", job.status.JobId, p, err)
+	job.Lock()
+	job.status.FailedEntryCount++
+	if len(job.status.Failures) < maxDeleteJobFailures {
+		job.status.Failures = append(job.status.Failures, &filer_pb.BulkDeleteJob_Failure{
+			Path:  string(p),
+			Error: err.Error(),
+		})
+	}
+	job.Unlock()
+}
+
+func (f *Filer) runDeleteJob(job *DeleteJob) {
+
+	ctx := context.Background()
+
+	if len(job.paths) > 0 {
+		for _, p := range job.paths {
+			entry, err := f.FindEntry(ctx, p)
+			if err == filer_pb.ErrNotFound {
+				continue
+			}
+			if err != nil {
+				job.failed(p, err)
+				continue
+			}
+			f.bulkDeleteEntry(ctx, job, entry)
+			f.maybeCheckpointDeleteJob(ctx, job)
+		}
+	} else {
+		f.bulkDeleteChildren(ctx, job, util.FullPath(job.status.Directory), job.status.NamePrefix)
+	}
+
+	job.Lock()
+	job.status.CompletedAtNs = time.Now().UnixNano()
+	job.Unlock()
+	f.checkpointDeleteJob(ctx, job)
+	close(job.done)
+
+	status := job.Status()
+	glog.V(0).Infof("bulk delete job %s completed: %d deleted, %d failed", status.JobId, status.DeletedEntryCount, status.FailedEntryCount)
+}
+
+// bulkDeleteChildren returns true if all the children with the name prefix are deleted.
+func (f *Filer) bulkDeleteChildren(ctx context.Context, job *DeleteJob, dir util.FullPath, namePrefix string) (allDeleted bool) {
+
+	allDeleted = true
+	lastFileName := ""
+	for {
+		entries, _, err := f.ListDirectoryEntries(ctx, dir, lastFileName, false, PaginationSize, namePrefix, "")
+		if err != nil {
+			job.failed(dir, fmt.Errorf("list folder: %v", err))
+			return false
+		}
+		for _, sub := range entries {
+			lastFileName = sub.Name()
+			if !f.bulkDeleteEntry(ctx, job, sub) {
+				allDeleted = false
+			}
+		}
+		f.maybeCheckpointDeleteJob(ctx, job)
+		if len(entries) < PaginationSize {
+			break
+		}
+	}
+	return
+}
+
+func (f *Filer) bulkDeleteEntry(ctx context.Context, job *DeleteJob, entry *Entry) bool {
+
+	isBucket := entry.IsDirectory() && f.isBucket(entry)
+	if entry.IsDirectory() && !isBucket && job.status.IsRecursive {
+		// buckets are deleted together with the bucket collection, other folders child by child
+		if !f.bulkDeleteChildren(ctx, job, entry.FullPath, "") {
+			return false
+		}
+	}
+
+	job.limiter.Wait(1)
+
+	chunkCount := 0
+	if job.status.IsDeleteData && !entry.IsDirectory() && len(entry.HardLinkId) == 0 {
+		if _, isVersioned := f.versioningConf(entry.FullPath); !isVersioned || !needsVersion(entry, nil) {
+			chunkCount = len(entry.Chunks) + len(ArchivedChunks(entry))
+		}
+	}
+
+	// the folders are not deleted recursively here, so the children created meanwhile are kept
+	err := f.DeleteEntryMetaAndData(ctx, entry.FullPath, isBucket && job.status.IsRecursive, false, job.status.IsDeleteData, false, nil)
+	if err == filer_pb.ErrNotFound {
+		err = nil
+	}
+	if err != nil {
+		job.failed(entry.FullPath, err)
+		return false
+	}
+	job.deleted(chunkCount)
+
+	return true
+}
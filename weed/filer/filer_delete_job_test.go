@@ -0,0 +1,101 @@
+package filer_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	leveldb2 "github.com/chrislusf/seaweedfs/weed/filer/leveldb2"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func TestBulkDelete(t *testing.T) {
+	testFiler := newTestFiler(t, &leveldb2.LevelDB2Store{})
+
+	ctx := context.Background()
+
+	for _, p := range []string{"/logs/2020-01/a", "/logs/2020-01/b", "/logs/2020-02", "/logs/2021-01/c", "/other/d"} {
+		entry := &filer.Entry{
+			FullPath: util.FullPath(p),
+			Attr:     filer.Attr{Mode: 0644},
+		}
+		if err := testFiler.CreateEntry(ctx, entry, false, false, nil); err != nil {
+			t.Fatalf("create entry %v: %v", entry.FullPath, err)
+		}
+	}
+
+	job, err := testFiler.StartDeleteJob("/logs", "2020-", nil, true, true, 0)
+	if err != nil {
+		t.Fatalf("start delete job: %v", err)
+	}
+	if !job.Wait(time.Minute) {
+		t.Fatalf("delete job not completed")
+	}
+	if status := job.Status(); status.DeletedEntryCount != 4 || status.FailedEntryCount != 0 {
+		t.Errorf("unexpected job status: %+v", status)
+	}
+
+	entries, _, _ := testFiler.ListDirectoryEntries(ctx, util.FullPath("/logs"), "", false, 100, "", "")
+	if len(entries) != 1 || entries[0].Name() != "2021-01" {
+		t.Errorf("unexpected entries after bulk delete: %v", entries)
+	}
+
+	// non recursive delete can only delete files and empty folders
+	job, _ = testFiler.StartDeleteJob("", "", []util.FullPath{"/logs/2021-01", "/other/d", "/not/exist"}, false, true, 0)
+	job.Wait(time.Minute)
+	if status := job.Status(); status.DeletedEntryCount != 1 || status.FailedEntryCount != 1 || status.Failures[0].Path != "/logs/2021-01" {
+		t.Errorf("unexpected job status: %+v", status)
+	}
+}
+
+func TestResumeBulkDeleteJob(t *testing.T) {
+	store := &leveldb2.LevelDB2Store{}
+	testFiler := newTestFiler(t, store)
+	testFiler.ResumeDeleteJobs("localhost:8888")
+
+	ctx := context.Background()
+	for _, p := range []string{"/logs/a", "/logs/b", "/logs/c"} {
+		if err := testFiler.CreateEntry(ctx, &filer.Entry{
+			FullPath: util.FullPath(p),
+			Attr:     filer.Attr{Mode: 0644},
+		}, false, false, nil); err != nil {
+			t.Fatalf("create entry %v: %v", p, err)
+		}
+	}
+
+	job, err := testFiler.StartDeleteJob("/logs", "", nil, true, true, 1)
+	if err != nil {
+		t.Fatalf("start delete job: %v", err)
+	}
+	jobId := job.Status().JobId
+
+	// another filer on the same store, as if this filer restarted
+	restartedFiler := filer.NewFiler(nil, nil, "", 0, "", "", "", nil)
+	restartedFiler.SetStore(store)
+	restartedFiler.ResumeDeleteJobs("localhost:8888")
+	resumed, found := restartedFiler.FindDeleteJob(jobId)
+	if !found {
+		t.Fatalf("job %s is not resumed", jobId)
+	}
+	if !resumed.Wait(time.Minute) {
+		t.Fatalf("resumed job not completed")
+	}
+	if status := resumed.Status(); status.ResumedCount != 1 || status.EntriesPerSecond != 1 || !status.IsRecursive {
+		t.Errorf("unexpected resumed job status: %+v", status)
+	}
+	job.Wait(time.Minute)
+
+	entries, _, _ := restartedFiler.ListDirectoryEntries(ctx, util.FullPath("/logs"), "", false, 100, "", "")
+	if len(entries) != 0 {
+		t.Errorf("unexpected entries after resumed delete: %v", entries)
+	}
+
+	// the completed jobs are not resumed again
+	otherFiler := filer.NewFiler(nil, nil, "", 0, "", "", "", nil)
+	otherFiler.SetStore(store)
+	otherFiler.ResumeDeleteJobs("localhost:8888")
+	if jobs := otherFiler.ListDeleteJobs(); len(jobs) != 0 {
+		t.Errorf("unexpected resumed jobs: %+v", jobs)
+	}
+}
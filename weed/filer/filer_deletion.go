@@ -7,6 +7,7 @@ import (
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/operation"
 	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
 	"github.com/chrislusf/seaweedfs/weed/wdclient"
 )
 
@@ -31,35 +32,25 @@ func LookupByMasterClientFn(masterClient *wdclient.MasterClient) func(vids []str
 	}
 }
 
-func (f *Filer) loopProcessingDeletion() {
+/*
+All chunk deletions go through one queue per volume server.
+Each queue deletes its file ids in batches with the volume server BatchDeleteStream API,
+throttled to DeletionFilesPerSecond, so mass deletions do not slow down the volume servers.
+*/
 
-	lookupFunc := LookupByMasterClientFn(f.MasterClient)
+const (
+	DefaultDeletionFilesPerSecond = 10000
+	deletionBatchSize             = 10000 // file ids per BatchDeleteStream request
+)
 
-	DeletionBatchSize := 100000 // roughly 20 bytes cost per file id.
+func (f *Filer) loopProcessingDeletion() {
 
 	var deletionCount int
 	for {
 		deletionCount = 0
 		f.fileIdDeletionQueue.Consume(func(fileIds []string) {
-			for len(fileIds) > 0 {
-				var toDeleteFileIds []string
-				if len(fileIds) > DeletionBatchSize {
-					toDeleteFileIds = fileIds[:DeletionBatchSize]
-					fileIds = fileIds[DeletionBatchSize:]
-				} else {
-					toDeleteFileIds = fileIds
-					fileIds = fileIds[:0]
-				}
-				deletionCount = len(toDeleteFileIds)
-				_, err := operation.DeleteFilesWithLookupVolumeId(f.GrpcDialOption, toDeleteFileIds, lookupFunc)
-				if err != nil {
-					if !strings.Contains(err.Error(), "already deleted") {
-						glog.V(0).Infof("deleting fileIds len=%d error: %v", deletionCount, err)
-					}
-				} else {
-					glog.V(1).Infof("deleting fileIds len=%d", deletionCount)
-				}
-			}
+			deletionCount = len(fileIds)
+			f.doDeleteFileIds(fileIds)
 		})
 
 		if deletionCount == 0 {
@@ -68,44 +59,103 @@ func (f *Filer) loopProcessingDeletion() {
 	}
 }
 
+// doDeleteFileIds queues the file ids to the volume servers of their volumes.
 func (f *Filer) doDeleteFileIds(fileIds []string) {
 
-	lookupFunc := LookupByMasterClientFn(f.MasterClient)
-	DeletionBatchSize := 100000 // roughly 20 bytes cost per file id.
-
-	for len(fileIds) > 0 {
-		var toDeleteFileIds []string
-		if len(fileIds) > DeletionBatchSize {
-			toDeleteFileIds = fileIds[:DeletionBatchSize]
-			fileIds = fileIds[DeletionBatchSize:]
-		} else {
-			toDeleteFileIds = fileIds
-			fileIds = fileIds[:0]
-		}
-		deletionCount := len(toDeleteFileIds)
-		_, err := operation.DeleteFilesWithLookupVolumeId(f.GrpcDialOption, toDeleteFileIds, lookupFunc)
+	vidToFileIds := make(map[string][]string)
+	var vids []string
+	for _, fileId := range fileIds {
+		vid, _, err := operation.ParseFileId(fileId)
 		if err != nil {
-			if !strings.Contains(err.Error(), "already deleted") {
-				glog.V(0).Infof("deleting fileIds len=%d error: %v", deletionCount, err)
+			glog.V(0).Infof("delete fileId %s: %v", fileId, err)
+			continue
+		}
+		if _, found := vidToFileIds[vid]; !found {
+			vids = append(vids, vid)
+		}
+		vidToFileIds[vid] = append(vidToFileIds[vid], fileId)
+	}
+
+	lookupResults, err := LookupByMasterClientFn(f.MasterClient)(vids)
+	if err != nil {
+		glog.V(0).Infof("deleting fileIds len=%d: lookup volumes: %v", len(fileIds), err)
+		return
+	}
+	for _, vid := range vids {
+		locations := lookupResults[vid].Locations
+		if len(locations) == 0 {
+			glog.V(0).Infof("deleting fileIds len=%d: volume %s not found", len(vidToFileIds[vid]), vid)
+			continue
+		}
+		for _, location := range locations {
+			f.volumeServerDeletionQueue(location.Url).EnQueue(vidToFileIds[vid]...)
+		}
+	}
+}
+
+func (f *Filer) volumeServerDeletionQueue(server string) *util.UnboundedQueue {
+	f.volumeServerDeletionQueuesLock.Lock()
+	defer f.volumeServerDeletionQueuesLock.Unlock()
+
+	queue, found := f.volumeServerDeletionQueues[server]
+	if !found {
+		queue = util.NewUnboundedQueue()
+		f.volumeServerDeletionQueues[server] = queue
+		go f.loopDeletingOnVolumeServer(server, queue, f.DeletionFilesPerSecond)
+	}
+	return queue
+}
+
+func (f *Filer) loopDeletingOnVolumeServer(server string, queue *util.UnboundedQueue, filesPerSecond int64) {
+
+	throttler := util.NewWriteThrottler(filesPerSecond)
+
+	var deletionCount int
+	for {
+		deletionCount = 0
+		queue.Consume(func(fileIds []string) {
+			for len(fileIds) > 0 {
+				toDeleteFileIds := fileIds
+				if len(toDeleteFileIds) > deletionBatchSize {
+					toDeleteFileIds = fileIds[:deletionBatchSize]
+				}
+				fileIds = fileIds[len(toDeleteFileIds):]
+				deletionCount += len(toDeleteFileIds)
+
+				throttler.MaybeSlowdown(int64(len(toDeleteFileIds)))
+				_, err := operation.DeleteFilesAtOneVolumeServer(server, f.GrpcDialOption, toDeleteFileIds, true)
+				if err != nil {
+					if !strings.Contains(err.Error(), "already deleted") {
+						glog.V(0).Infof("deleting fileIds len=%d on %s error: %v", len(toDeleteFileIds), server, err)
+					}
+				} else {
+					glog.V(1).Infof("deleting fileIds len=%d on %s", len(toDeleteFileIds), server)
+				}
 			}
+		})
+
+		if deletionCount == 0 {
+			time.Sleep(1123 * time.Millisecond)
 		}
 	}
 }
 
 func (f *Filer) DirectDeleteChunks(chunks []*filer_pb.FileChunk) {
 	var fildIdsToDelete []string
+	var dataChunks []*filer_pb.FileChunk
 	for _, chunk := range chunks {
 		if !chunk.IsChunkManifest {
-			fildIdsToDelete = append(fildIdsToDelete, chunk.GetFileIdString())
+			dataChunks = append(dataChunks, chunk)
 			continue
 		}
-		dataChunks, manifestResolveErr := ResolveOneChunkManifest(f.MasterClient.LookupFileId, chunk)
+		resolvedChunks, manifestResolveErr := ResolveOneChunkManifest(f.MasterClient.LookupFileId, chunk)
 		if manifestResolveErr != nil {
 			glog.V(0).Infof("failed to resolve manifest %s: %v", chunk.FileId, manifestResolveErr)
 		}
-		for _, dChunk := range dataChunks {
-			fildIdsToDelete = append(fildIdsToDelete, dChunk.GetFileIdString())
-		}
+		dataChunks = append(dataChunks, resolvedChunks...)
+		fildIdsToDelete = append(fildIdsToDelete, chunk.GetFileIdString())
+	}
+	for _, chunk := range f.releaseDedupChunks(dataChunks) {
 		fildIdsToDelete = append(fildIdsToDelete, chunk.GetFileIdString())
 	}
 
@@ -113,18 +163,20 @@ func (f *Filer) DirectDeleteChunks(chunks []*filer_pb.FileChunk) {
 }
 
 func (f *Filer) DeleteChunks(chunks []*filer_pb.FileChunk) {
+	var dataChunks []*filer_pb.FileChunk
 	for _, chunk := range chunks {
 		if !chunk.IsChunkManifest {
-			f.fileIdDeletionQueue.EnQueue(chunk.GetFileIdString())
+			dataChunks = append(dataChunks, chunk)
 			continue
 		}
-		dataChunks, manifestResolveErr := ResolveOneChunkManifest(f.MasterClient.LookupFileId, chunk)
+		resolvedChunks, manifestResolveErr := ResolveOneChunkManifest(f.MasterClient.LookupFileId, chunk)
 		if manifestResolveErr != nil {
 			glog.V(0).Infof("failed to resolve manifest %s: %v", chunk.FileId, manifestResolveErr)
 		}
-		for _, dChunk := range dataChunks {
-			f.fileIdDeletionQueue.EnQueue(dChunk.GetFileIdString())
-		}
+		dataChunks = append(dataChunks, resolvedChunks...)
+		f.fileIdDeletionQueue.EnQueue(chunk.GetFileIdString())
+	}
+	for _, chunk := range f.releaseDedupChunks(dataChunks) {
 		f.fileIdDeletionQueue.EnQueue(chunk.GetFileIdString())
 	}
 }
@@ -141,11 +193,17 @@ func (f *Filer) deleteChunksIfNotNew(oldEntry, newEntry *Entry) {
 	var toDelete []*filer_pb.FileChunk
 	newChunkIds := make(map[string]bool)
 	for _, newChunk := range newEntry.Chunks {
-		newChunkIds[newChunk.GetFileIdString()] = true
+		newChunkIds[chunkReferenceKey(newChunk)] = true
+	}
+	for _, newChunk := range ArchivedChunks(newEntry) {
+		newChunkIds[chunkReferenceKey(newChunk)] = true
 	}
 
-	for _, oldChunk := range oldEntry.Chunks {
-		if _, found := newChunkIds[oldChunk.GetFileIdString()]; !found {
+	oldChunks := make([]*filer_pb.FileChunk, 0, len(oldEntry.Chunks))
+	oldChunks = append(oldChunks, oldEntry.Chunks...)
+	oldChunks = append(oldChunks, ArchivedChunks(oldEntry)...)
+	for _, oldChunk := range oldChunks {
+		if _, found := newChunkIds[chunkReferenceKey(oldChunk)]; !found {
 			toDelete = append(toDelete, oldChunk)
 		}
 	}
@@ -0,0 +1,230 @@
+package filer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/karlseguin/ccache/v2"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/stats"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+The number of entries in one directory can be limited, to protect the filer stores and the listings
+from accidentally creating, e.g., 100M files in one flat directory.
+
+The limit is set by "dir_entries_limit" in filer.toml, or by "directory_entries_limit" in filer.conf
+for the directories under a path prefix. New entries are rejected when the directory is full.
+A warning is logged, and the directory entry count is exported as a metric, when the directory
+is close to the limit.
+
+The entries are counted by listing the directory once, and the count is kept in memory and updated
+by the changes on this filer. The count is listed again after dirEntriesCountTtl, to pick up
+the changes from other filers. So the limit is approximate.
+
+Only the first page of a directory is listed while creating an entry. Larger directories are counted
+by a background job, shown by "GET /?dirEntriesCountJobs", and meanwhile the last count is used,
+or the first page if not counted yet.
+*/
+
+const (
+	DefaultDirEntriesWarnPercent = 90
+	dirEntriesCountTtl           = 10 * time.Minute
+	dirEntriesCountCacheSize     = 10000
+)
+
+var ErrDirectoryFull = errors.New("too many directory entries")
+
+type DirEntriesLimit struct {
+	Limit       int64 // 0 means unlimited
+	WarnPercent int64
+	counts      *ccache.Cache
+	jobs        map[string]*DirEntriesCountJob
+	jobsLock    sync.Mutex
+}
+
+// DirEntriesCountJob is a directory being counted in the background.
+type DirEntriesCountJob struct {
+	Directory string    `json:"directory"`
+	StartedAt time.Time `json:"startedAt"`
+	Counted   int64     `json:"counted"` // the entries counted so far
+}
+
+func NewDirEntriesLimit() *DirEntriesLimit {
+	return &DirEntriesLimit{
+		WarnPercent: DefaultDirEntriesWarnPercent,
+		counts:      ccache.New(ccache.Configure().MaxSize(dirEntriesCountCacheSize)),
+		jobs:        make(map[string]*DirEntriesCountJob),
+	}
+}
+
+// CountJobs lists the directories being counted in the background.
+func (l *DirEntriesLimit) CountJobs() (jobs []DirEntriesCountJob) {
+	l.jobsLock.Lock()
+	defer l.jobsLock.Unlock()
+	for _, job := range l.jobs {
+		jobs = append(jobs, DirEntriesCountJob{
+			Directory: job.Directory,
+			StartedAt: job.StartedAt,
+			Counted:   atomic.LoadInt64(&job.Counted),
+		})
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].StartedAt.Before(jobs[j].StartedAt)
+	})
+	return
+}
+
+// dirEntriesLimit is the limit in filer.conf for the directory, or the default limit in filer.toml
+func (f *Filer) dirEntriesLimit(dir string) int64 {
+	if f.FilerConf != nil {
+		if rule := f.FilerConf.MatchStorageRule(strings.TrimSuffix(dir, "/") + "/"); rule.DirectoryEntriesLimit > 0 {
+			return int64(rule.DirectoryEntriesLimit)
+		}
+	}
+	return f.DirEntries.Limit
+}
+
+// checkDirEntriesLimit returns ErrDirectoryFull if no more entries can be created in the parent directory.
+func (f *Filer) checkDirEntriesLimit(ctx context.Context, p util.FullPath) error {
+
+	dir, _ := p.DirAndName()
+	limit := f.dirEntriesLimit(dir)
+	if limit <= 0 {
+		return nil
+	}
+
+	count, err := f.countDirEntries(ctx, util.FullPath(dir), limit)
+	if err != nil {
+		// do not fail the writes if the entries can not be counted
+		glog.Warningf("count entries in %s: %v", dir, err)
+		return nil
+	}
+
+	if count >= limit {
+		stats.FilerRequestCounter.WithLabelValues("create.dirFull").Inc()
+		glog.V(1).Infof("directory %s has %d entries, reaching the limit %d", dir, count, limit)
+		return fmt.Errorf("%s: %v, limit %d", dir, ErrDirectoryFull, limit)
+	}
+
+	if warnAt := limit * f.DirEntries.WarnPercent / 100; count >= warnAt {
+		if count == warnAt {
+			glog.Warningf("directory %s has %d entries, close to the limit %d", dir, count, limit)
+		}
+		stats.FilerDirectoryEntriesGauge.WithLabelValues(dir).Set(float64(count + 1))
+	}
+
+	return nil
+}
+
+// countDirEntries returns the entries in the directory, up to the limit.
+// The directories larger than one page are counted in the background, and the last count,
+// or the first page if not counted yet, is returned meanwhile.
+func (f *Filer) countDirEntries(ctx context.Context, dir util.FullPath, limit int64) (int64, error) {
+
+	if item := f.DirEntries.counts.Get(string(dir)); item != nil {
+		if item.Expired() {
+			f.startCountingDirEntries(dir, limit)
+		}
+		return atomic.LoadInt64(item.Value().(*int64)), nil
+	}
+
+	var count int64
+	if _, _, err := f.listDirEntriesPage(ctx, dir, "", &count); err != nil {
+		return 0, err
+	}
+	if count < PaginationSize || count >= limit {
+		f.DirEntries.counts.Set(string(dir), &count, dirEntriesCountTtl)
+		return count, nil
+	}
+
+	f.startCountingDirEntries(dir, limit)
+	return count, nil
+}
+
+// startCountingDirEntries counts the entries in the directory in the background, up to the limit,
+// unless the directory is being counted already.
+func (f *Filer) startCountingDirEntries(dir util.FullPath, limit int64) {
+
+	l := f.DirEntries
+	l.jobsLock.Lock()
+	defer l.jobsLock.Unlock()
+	if _, found := l.jobs[string(dir)]; found {
+		return
+	}
+	job := &DirEntriesCountJob{Directory: string(dir), StartedAt: time.Now()}
+	l.jobs[string(dir)] = job
+
+	go func() {
+		defer func() {
+			l.jobsLock.Lock()
+			delete(l.jobs, string(dir))
+			l.jobsLock.Unlock()
+		}()
+		lastFileName := ""
+		for atomic.LoadInt64(&job.Counted) < limit {
+			listed, last, err := f.listDirEntriesPage(context.Background(), dir, lastFileName, &job.Counted)
+			if err != nil {
+				glog.Warningf("count entries in %s: %v", dir, err)
+				return
+			}
+			if listed < PaginationSize {
+				break
+			}
+			lastFileName = last
+		}
+		count := atomic.LoadInt64(&job.Counted)
+		f.DirEntries.counts.Set(string(dir), &count, dirEntriesCountTtl)
+		glog.V(1).Infof("counted %d entries in %s in %v", count, dir, time.Since(job.StartedAt))
+	}()
+}
+
+// listDirEntriesPage lists one page of the directory after the start file name, adding the listed entries to the count.
+func (f *Filer) listDirEntriesPage(ctx context.Context, dir util.FullPath, startFileName string, count *int64) (listed int64, lastFileName string, err error) {
+	lastFileName, err = f.Store.ListDirectoryEntries(ctx, dir, startFileName, false, PaginationSize, func(entry *Entry) bool {
+		listed++
+		atomic.AddInt64(count, 1)
+		return true
+	})
+	return
+}
+
+// updateDirEntriesCount adjusts the counted directories for the created, deleted or renamed entries.
+func (f *Filer) updateDirEntriesCount(oldEntry, newEntry *Entry) {
+
+	var oldDir, newDir string
+	if oldEntry != nil {
+		oldDir, _ = oldEntry.FullPath.DirAndName()
+	}
+	if newEntry != nil {
+		newDir, _ = newEntry.FullPath.DirAndName()
+	}
+	if oldEntry != nil && newEntry != nil && oldEntry.FullPath == newEntry.FullPath {
+		return
+	}
+
+	if oldEntry != nil {
+		f.DirEntries.add(oldDir, -1)
+		if oldEntry.IsDirectory() {
+			// the children may be deleted or moved without notifications
+			f.DirEntries.counts.DeletePrefix(string(oldEntry.FullPath))
+		}
+	}
+	if newEntry != nil {
+		f.DirEntries.add(newDir, 1)
+	}
+}
+
+func (l *DirEntriesLimit) add(dir string, delta int64) {
+	if item := l.counts.Get(dir); item != nil {
+		atomic.AddInt64(item.Value().(*int64), delta)
+	}
+}
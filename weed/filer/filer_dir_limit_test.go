@@ -0,0 +1,56 @@
+package filer_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	leveldb2 "github.com/chrislusf/seaweedfs/weed/filer/leveldb2"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func TestDirEntriesLimit(t *testing.T) {
+	testFiler := newTestFiler(t, &leveldb2.LevelDB2Store{})
+	testFiler.DirEntries.Limit = 3
+
+	ctx := context.Background()
+
+	create := func(p string) error {
+		return testFiler.CreateEntry(ctx, &filer.Entry{
+			FullPath: util.FullPath(p),
+			Attr:     filer.Attr{Mode: 0644},
+		}, false, false, nil)
+	}
+
+	for _, p := range []string{"/flat/a", "/flat/b", "/flat/c"} {
+		if err := create(p); err != nil {
+			t.Fatalf("create entry %v: %v", p, err)
+		}
+	}
+	if err := create("/flat/d"); err == nil || !strings.Contains(err.Error(), filer.ErrDirectoryFull.Error()) {
+		t.Errorf("expecting directory full, got %v", err)
+	}
+
+	// overwriting is not limited
+	if err := create("/flat/a"); err != nil {
+		t.Errorf("overwrite entry: %v", err)
+	}
+
+	if err := testFiler.DeleteEntryMetaAndData(ctx, "/flat/a", false, false, false, false, nil); err != nil {
+		t.Fatalf("delete entry: %v", err)
+	}
+	if err := create("/flat/d"); err != nil {
+		t.Errorf("create entry after delete: %v", err)
+	}
+
+	// the directory is recounted after being deleted
+	if err := testFiler.DeleteEntryMetaAndData(ctx, "/flat", true, false, false, false, nil); err != nil {
+		t.Fatalf("delete directory: %v", err)
+	}
+	for _, p := range []string{"/flat/a", "/flat/b", "/flat/c"} {
+		if err := create(p); err != nil {
+			t.Errorf("create entry %v in the new directory: %v", p, err)
+		}
+	}
+}
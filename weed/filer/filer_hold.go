@@ -0,0 +1,165 @@
+package filer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+A hold on an entry blocks its deletion, overwrite, and rename, until the hold is removed.
+
+The holder identity is kept in the entry extended attributes.
+All held paths are also indexed, see filer_path_index.go, so deleting or renaming a directory
+can find held entries under it without walking the whole tree. Without the index, the hold is not placed,
+and the directory is not deleted or renamed.
+*/
+
+const (
+	ExtHoldKey    = "x-seaweedfs-hold"
+	holdIndexName = "holds"
+)
+
+var (
+	ErrEntryHeld         = errors.New("entry is under hold")
+	ErrHoldNotAuthorized = errors.New("not authorized to remove the hold")
+)
+
+type FilerHolds struct {
+	// identities allowed to remove holds placed by others
+	AdminIdentities []string
+	index           pathIndex
+}
+
+func IsHeld(entry *Entry) bool {
+	if entry == nil || entry.Extended == nil {
+		return false
+	}
+	return len(entry.Extended[ExtHoldKey]) > 0
+}
+
+// SetHold places or removes the hold on the entry.
+// Only the identity placing the hold, or an admin identity, can remove it.
+func (f *Filer) SetHold(ctx context.Context, p util.FullPath, identity string, isHold bool) error {
+
+	if isHold && identity == "" {
+		return fmt.Errorf("hold on %s requires an identity", p)
+	}
+
+	oldEntry, err := f.FindEntry(ctx, p)
+	if err != nil {
+		return err
+	}
+
+	holder := ""
+	if oldEntry.Extended != nil {
+		holder = string(oldEntry.Extended[ExtHoldKey])
+	}
+
+	entry := cloneEntryExtended(oldEntry)
+
+	if isHold {
+		if holder == identity {
+			return nil
+		}
+		if holder != "" && !f.Holds.isAdmin(identity) {
+			return ErrHoldNotAuthorized
+		}
+		entry.Extended[ExtHoldKey] = []byte(identity)
+	} else {
+		if holder == "" {
+			return nil
+		}
+		if holder != identity && !f.Holds.isAdmin(identity) {
+			return ErrHoldNotAuthorized
+		}
+		delete(entry.Extended, ExtHoldKey)
+	}
+
+	if err = f.Store.UpdateEntry(ctx, entry); err != nil {
+		return fmt.Errorf("update hold on %s: %v", p, err)
+	}
+	// the index is only changed after the store write, so a failed write does not leave a stale hold in the index
+	if err = f.updatePathIndex(ctx, &f.Holds.index, p, isHold); err != nil {
+		if isHold {
+			// a held entry missing in the index could be deleted with its directory
+			if revertErr := f.Store.UpdateEntry(ctx, oldEntry); revertErr != nil {
+				glog.Errorf("revert hold on %s: %v", p, revertErr)
+			}
+		}
+		return err
+	}
+	f.NotifyUpdateEvent(ctx, oldEntry, entry, false, false, nil)
+
+	glog.V(1).Infof("hold %s by %s: %v", p, identity, isHold)
+
+	return nil
+}
+
+// CheckNotHeld returns ErrEntryHeld if the entry, or for directories any entry under it, is held.
+func (f *Filer) CheckNotHeld(ctx context.Context, entry *Entry) error {
+	if IsHeld(entry) {
+		return fmt.Errorf("%s: %v", entry.FullPath, ErrEntryHeld)
+	}
+	if !entry.IsDirectory() {
+		return nil
+	}
+	dirPrefix := string(entry.FullPath) + "/"
+	if entry.FullPath == "/" {
+		dirPrefix = "/"
+	}
+	heldPath := ""
+	if err := f.listPathIndex(ctx, &f.Holds.index, dirPrefix, func(p string) bool {
+		heldPath = p
+		return false
+	}); err != nil {
+		return err
+	}
+	if heldPath != "" {
+		return fmt.Errorf("%s: %v", heldPath, ErrEntryHeld)
+	}
+	return nil
+}
+
+// checkUpdateNotHeld allows metadata-only updates to a held entry, keeping the hold unchanged
+func checkUpdateNotHeld(oldEntry, newEntry *Entry) error {
+	if !IsHeld(oldEntry) {
+		return nil
+	}
+	if newEntry.Extended == nil || !bytes.Equal(oldEntry.Extended[ExtHoldKey], newEntry.Extended[ExtHoldKey]) {
+		return fmt.Errorf("%s: %v", oldEntry.FullPath, ErrEntryHeld)
+	}
+	if !bytes.Equal(oldEntry.Content, newEntry.Content) || !sameChunks(oldEntry.Chunks, newEntry.Chunks) {
+		return fmt.Errorf("%s: %v", oldEntry.FullPath, ErrEntryHeld)
+	}
+	return nil
+}
+
+func sameChunks(a, b []*filer_pb.FileChunk) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].GetFileIdString() != b[i].GetFileIdString() || a[i].Offset != b[i].Offset || a[i].Size != b[i].Size {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *FilerHolds) isAdmin(identity string) bool {
+	if identity == "" {
+		return false
+	}
+	for _, admin := range h.AdminIdentities {
+		if admin == identity {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package filer_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	leveldb2 "github.com/chrislusf/seaweedfs/weed/filer/leveldb2"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func TestHoldIndexSharedByFilers(t *testing.T) {
+	store := &leveldb2.LevelDB2Store{}
+	filer1 := newTestFiler(t, store)
+	filer2 := filer.NewFiler(nil, nil, "", 0, "", "", "", nil)
+	filer2.SetStore(store)
+
+	ctx := context.Background()
+	for _, p := range []string{"/dir/a/held1", "/dir/b/held2", "/dir/bc/file"} {
+		if err := filer1.CreateEntry(ctx, &filer.Entry{
+			FullPath: util.FullPath(p),
+			Attr:     filer.Attr{Mode: 0644, Crtime: time.Now(), Mtime: time.Now()},
+		}, false, false, nil); err != nil {
+			t.Fatalf("create %s: %v", p, err)
+		}
+	}
+	checkHeld := func(f *filer.Filer, dir string, expected bool) {
+		entry, err := f.FindEntry(ctx, util.FullPath(dir))
+		if err != nil {
+			t.Fatalf("find %s: %v", dir, err)
+		}
+		err = f.CheckNotHeld(ctx, entry)
+		if isHeld := err != nil && strings.Contains(err.Error(), filer.ErrEntryHeld.Error()); isHeld != expected || !isHeld && err != nil {
+			t.Errorf("%s: expecting held %v, but got %v", dir, expected, err)
+		}
+	}
+
+	// the holds placed by both filers are kept
+	if err := filer1.SetHold(ctx, "/dir/a/held1", "alice", true); err != nil {
+		t.Fatalf("hold: %v", err)
+	}
+	if err := filer2.SetHold(ctx, "/dir/b/held2", "bob", true); err != nil {
+		t.Fatalf("hold: %v", err)
+	}
+	for _, f := range []*filer.Filer{filer1, filer2} {
+		checkHeld(f, "/dir", true)
+		checkHeld(f, "/dir/a", true)
+		checkHeld(f, "/dir/b", true)
+		checkHeld(f, "/dir/bc", false)
+	}
+
+	if err := filer1.SetHold(ctx, "/dir/b/held2", "bob", false); err != nil {
+		t.Fatalf("remove hold: %v", err)
+	}
+	checkHeld(filer2, "/dir/b", false)
+	checkHeld(filer2, "/dir", true)
+}
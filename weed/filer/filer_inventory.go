@@ -0,0 +1,564 @@
+package filer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	xhttp "github.com/chrislusf/seaweedfs/weed/s3api/http"
+	"github.com/chrislusf/seaweedfs/weed/stats"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+The s3 bucket inventory configurations are set by PutBucketInventoryConfiguration, and kept in the ExtS3InventoryKey
+extended attribute of the bucket directory. Once per interval, the filer checks the enabled configurations, and
+for the ones without the report of the current daily or weekly period, lists the current objects with the prefix
+into gzipped CSV files in the destination bucket, the same layout as AWS S3:
+
+	{prefix}/{source bucket}/{id}/data/{uuid}.csv.gz             the objects, up to inventoryRowsPerFile rows per file
+	{prefix}/{source bucket}/{id}/{YYYY-MM-DDTHH-MMZ}/manifest.json  the data files of the report, written last
+	{prefix}/{source bucket}/{id}/{YYYY-MM-DDTHH-MMZ}/manifest.checksum
+
+The daily reports are for the UTC days, and the weekly reports for the weeks starting on Sundays. Besides the
+AWS S3 optional fields, "Tags" lists the object tags as a url encoded query.
+
+All the filers with the interval write the reports, so with several filers it is usually only set on one of them.
+*/
+
+const (
+	ExtS3InventoryKey = "x-seaweedfs-s3-inventory"
+
+	InventoryFormatCSV     = "CSV"
+	InventoryFormatParquet = "Parquet"
+	InventoryFormatORC     = "ORC"
+
+	InventoryFrequencyDaily  = "Daily"
+	InventoryFrequencyWeekly = "Weekly"
+
+	InventoryVersionsCurrent = "Current"
+	InventoryVersionsAll     = "All"
+
+	inventoryRowsPerFile     = 100000
+	inventoryManifestFormat  = "2016-11-30"
+	inventoryBucketArnPrefix = "arn:aws:s3:::"
+)
+
+// ErrInventoryNotImplemented is for the valid inventory configurations not supported yet, e.g. the Parquet format
+var ErrInventoryNotImplemented = errors.New("not implemented")
+
+// the optional fields in the order of the report columns
+var inventoryOptionalFields = []string{
+	"Size",
+	"LastModifiedDate",
+	"ETag",
+	"StorageClass",
+	"IsMultipartUploaded",
+	"EncryptionStatus",
+	"ObjectLockRetainUntilDate",
+	"ObjectLockMode",
+	"ObjectLockLegalHoldStatus",
+	"Tags",
+}
+
+var inventoryUnsupportedFields = map[string]bool{
+	"ReplicationStatus":            true,
+	"IntelligentTieringAccessTier": true,
+	"BucketKeyStatus":              true,
+	"ChecksumAlgorithm":            true,
+}
+
+type InventoryConfiguration struct {
+	XMLName                xml.Name             `xml:"http://s3.amazonaws.com/doc/2006-03-01/ InventoryConfiguration"`
+	Id                     string               `xml:"Id"`
+	IsEnabled              bool                 `xml:"IsEnabled"`
+	Destination            InventoryDestination `xml:"Destination"`
+	Filter                 *InventoryFilter     `xml:"Filter,omitempty"`
+	IncludedObjectVersions string               `xml:"IncludedObjectVersions"`
+	OptionalFields         []string             `xml:"OptionalFields>Field,omitempty"`
+	Schedule               InventorySchedule    `xml:"Schedule"`
+}
+
+type InventoryDestination struct {
+	S3BucketDestination InventoryS3BucketDestination `xml:"S3BucketDestination"`
+}
+
+type InventoryS3BucketDestination struct {
+	AccountId string `xml:"AccountId,omitempty"`
+	Bucket    string `xml:"Bucket"`
+	Format    string `xml:"Format"`
+	Prefix    string `xml:"Prefix,omitempty"`
+}
+
+type InventoryFilter struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type InventorySchedule struct {
+	Frequency string `xml:"Frequency"`
+}
+
+// InventoryConfigurations are all the inventory configurations of a bucket, also the ListBucketInventoryConfigurations result
+type InventoryConfigurations struct {
+	XMLName        xml.Name                  `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListInventoryConfigurationsResult"`
+	Configurations []*InventoryConfiguration `xml:"InventoryConfiguration"`
+	IsTruncated    bool                      `xml:"IsTruncated"`
+}
+
+type InventoryManifest struct {
+	SourceBucket      string                   `json:"sourceBucket"`
+	DestinationBucket string                   `json:"destinationBucket"`
+	Version           string                   `json:"version"`
+	CreationTimestamp string                   `json:"creationTimestamp"`
+	FileFormat        string                   `json:"fileFormat"`
+	FileSchema        string                   `json:"fileSchema"`
+	Files             []*InventoryManifestFile `json:"files"`
+}
+
+type InventoryManifestFile struct {
+	Key         string `json:"key"`
+	Size        int64  `json:"size"`
+	MD5checksum string `json:"MD5checksum"`
+}
+
+// LoadBucketInventories returns the inventory configurations of the bucket, or nil if not set.
+func LoadBucketInventories(extended map[string][]byte) (*InventoryConfigurations, error) {
+	data := extended[ExtS3InventoryKey]
+	if len(data) == 0 {
+		return nil, nil
+	}
+	inventories := &InventoryConfigurations{}
+	if err := xml.Unmarshal(data, inventories); err != nil {
+		return nil, fmt.Errorf("unmarshal inventory: %v", err)
+	}
+	return inventories, nil
+}
+
+// Validate returns ErrInventoryNotImplemented for the configurations not supported yet
+func (c *InventoryConfiguration) Validate() error {
+	if c.Id == "" || len(c.Id) > 64 {
+		return fmt.Errorf("invalid id %q", c.Id)
+	}
+	if c.DestinationBucket() == "" {
+		return fmt.Errorf("missing destination bucket")
+	}
+	switch c.Destination.S3BucketDestination.Format {
+	case InventoryFormatCSV:
+	case InventoryFormatParquet, InventoryFormatORC:
+		return ErrInventoryNotImplemented
+	default:
+		return fmt.Errorf("unknown format %q", c.Destination.S3BucketDestination.Format)
+	}
+	switch c.IncludedObjectVersions {
+	case InventoryVersionsCurrent:
+	case InventoryVersionsAll:
+		return ErrInventoryNotImplemented
+	default:
+		return fmt.Errorf("unknown included object versions %q", c.IncludedObjectVersions)
+	}
+	switch c.Schedule.Frequency {
+	case InventoryFrequencyDaily, InventoryFrequencyWeekly:
+	default:
+		return fmt.Errorf("unknown frequency %q", c.Schedule.Frequency)
+	}
+	for _, field := range c.OptionalFields {
+		if inventoryUnsupportedFields[field] {
+			return ErrInventoryNotImplemented
+		}
+		if indexOf(inventoryOptionalFields, field) < 0 {
+			return fmt.Errorf("unknown optional field %q", field)
+		}
+	}
+	return nil
+}
+
+// DestinationBucket returns the destination bucket name, from the bucket arn or the name
+func (c *InventoryConfiguration) DestinationBucket() string {
+	return strings.TrimPrefix(c.Destination.S3BucketDestination.Bucket, inventoryBucketArnPrefix)
+}
+
+func (c *InventoryConfiguration) prefix() string {
+	if c.Filter == nil {
+		return ""
+	}
+	return c.Filter.Prefix
+}
+
+// columns returns the report columns, with the optional fields in the fixed order
+func (c *InventoryConfiguration) columns() []string {
+	columns := []string{"Bucket", "Key"}
+	for _, field := range inventoryOptionalFields {
+		if indexOf(c.OptionalFields, field) >= 0 {
+			columns = append(columns, field)
+		}
+	}
+	return columns
+}
+
+// reportDir returns the key of the report directory in the destination bucket
+func (c *InventoryConfiguration) reportDir(sourceBucket string) string {
+	dir := sourceBucket + "/" + c.Id
+	if prefix := strings.Trim(c.Destination.S3BucketDestination.Prefix, "/"); prefix != "" {
+		dir = prefix + "/" + dir
+	}
+	return dir
+}
+
+// inventorySlot returns the start of the current report period, the UTC day or the week starting on Sunday
+func inventorySlot(frequency string, now time.Time) time.Time {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if frequency == InventoryFrequencyWeekly {
+		return day.AddDate(0, 0, -int(day.Weekday()))
+	}
+	return day
+}
+
+type bucketInventory struct {
+	filer    *Filer
+	interval time.Duration
+}
+
+// StartBucketInventory starts writing the s3 bucket inventory reports due once per interval.
+func (f *Filer) StartBucketInventory(interval time.Duration) {
+	b := &bucketInventory{
+		filer:    f,
+		interval: interval,
+	}
+	go b.loop()
+}
+
+func (b *bucketInventory) loop() {
+	// also wait for the master connection on start
+	time.Sleep(time.Minute)
+	b.filer.MasterClient.WaitUntilConnected()
+
+	for {
+		startedAt := time.Now()
+		b.runBuckets(context.Background(), startedAt)
+		time.Sleep(time.Until(startedAt.Add(b.interval)))
+	}
+}
+
+func (b *bucketInventory) runBuckets(ctx context.Context, now time.Time) {
+	lastFileName := ""
+	for {
+		entries, _, err := b.filer.ListDirectoryEntries(ctx, util.FullPath(b.filer.DirBucketsPath), lastFileName, false, PaginationSize, "", "")
+		if err != nil {
+			glog.V(1).Infof("inventory list buckets: %v", err)
+			return
+		}
+		for _, entry := range entries {
+			lastFileName = entry.Name()
+			if !entry.IsDirectory() {
+				continue
+			}
+			inventories, err := LoadBucketInventories(entry.Extended)
+			if err != nil {
+				glog.Errorf("inventory of bucket %s: %v", entry.Name(), err)
+				continue
+			}
+			if inventories == nil {
+				continue
+			}
+			for _, c := range inventories.Configurations {
+				if !c.IsEnabled {
+					continue
+				}
+				if err := b.run(ctx, entry.FullPath, c, now); err != nil {
+					glog.Errorf("inventory %s of bucket %s: %v", c.Id, entry.Name(), err)
+					stats.FilerInventoryCounter.WithLabelValues("error").Inc()
+				}
+			}
+		}
+		if len(entries) < PaginationSize {
+			break
+		}
+	}
+}
+
+// run writes the report of the current period, if not written yet
+func (b *bucketInventory) run(ctx context.Context, bucketDir util.FullPath, c *InventoryConfiguration, now time.Time) error {
+	sourceBucket := bucketDir.Name()
+	destinationBucket := c.DestinationBucket()
+	destinationDir := util.FullPath(b.filer.DirBucketsPath).Child(destinationBucket)
+	reportDir := destinationDir.Child(c.reportDir(sourceBucket))
+	manifestDir := reportDir.Child(inventorySlot(c.Schedule.Frequency, now).Format("2006-01-02T15-04Z"))
+
+	if _, err := b.filer.FindEntry(ctx, destinationDir); err != nil {
+		return fmt.Errorf("destination bucket %s: %v", destinationBucket, err)
+	}
+	if _, err := b.filer.FindEntry(ctx, manifestDir.Child("manifest.json")); err == nil {
+		return nil
+	} else if err != filer_pb.ErrNotFound {
+		return err
+	}
+
+	columns := c.columns()
+	w := &inventoryWriter{
+		filer:          b.filer,
+		ctx:            ctx,
+		bucket:         destinationBucket,
+		destinationDir: destinationDir,
+		dataDir:        reportDir.Child("data"),
+	}
+
+	// walk from the directory of the prefix, except the reports written into the bucket itself
+	prefix := c.prefix()
+	dir := bucketDir
+	if i := strings.LastIndex(prefix, "/"); i > 0 {
+		dir = bucketDir.Child(prefix[:i])
+	}
+	var writeErr error
+	b.filer.walkFiles(ctx, dir, func(files []*Entry) {
+		for _, entry := range files {
+			key := objectKeyOf(bucketDir, entry.FullPath)
+			if writeErr != nil || !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			writeErr = w.write(inventoryRow(sourceBucket, key, entry, columns))
+		}
+	}, bucketDir.Child(s3MultipartUploadsDir), reportDir)
+	if writeErr == nil {
+		writeErr = w.flush()
+	}
+	if writeErr != nil {
+		w.abort()
+		return writeErr
+	}
+
+	manifest := &InventoryManifest{
+		SourceBucket:      sourceBucket,
+		DestinationBucket: inventoryBucketArnPrefix + destinationBucket,
+		Version:           inventoryManifestFormat,
+		CreationTimestamp: strconv.FormatInt(now.UnixNano()/int64(time.Millisecond), 10),
+		FileFormat:        InventoryFormatCSV,
+		FileSchema:        strings.Join(columns, ", "),
+		Files:             w.files,
+	}
+	if manifest.Files == nil {
+		manifest.Files = []*InventoryManifestFile{}
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	// the checksum is written first, so the reports with the manifest are complete
+	if _, err = w.save(manifestDir.Child("manifest.checksum"), []byte(fmt.Sprintf("%x\n", md5.Sum(data))), "text/plain"); err != nil {
+		return err
+	}
+	if _, err = w.save(manifestDir.Child("manifest.json"), data, "application/json"); err != nil {
+		return err
+	}
+	stats.FilerInventoryCounter.WithLabelValues("report").Inc()
+	stats.FilerInventoryCounter.WithLabelValues("object").Add(float64(w.count))
+	glog.V(1).Infof("inventory %s of bucket %s: %d objects in %d files", c.Id, sourceBucket, w.count, len(w.files))
+	return nil
+}
+
+// inventoryRow returns the report row of the object
+func inventoryRow(bucket, key string, entry *Entry, columns []string) []string {
+	row := make([]string, 0, len(columns))
+	for _, column := range columns {
+		var value string
+		switch column {
+		case "Bucket":
+			value = bucket
+		case "Key":
+			value = url.QueryEscape(key)
+		case "Size":
+			value = strconv.FormatUint(entry.Size(), 10)
+		case "LastModifiedDate":
+			value = entry.Mtime.UTC().Format("2006-01-02T15:04:05.000Z")
+		case "ETag":
+			value = ETagEntry(entry)
+		case "StorageClass":
+			value = "STANDARD"
+			if v, ok := entry.Extended[xhttp.AmzStorageClass]; ok {
+				value = string(v)
+			}
+		case "IsMultipartUploaded":
+			// assembled from the parts, without the md5 of the whole content
+			value = strconv.FormatBool(entry.Md5 == nil && len(entry.Chunks) > 1)
+		case "EncryptionStatus":
+			value = inventoryEncryptionStatus(entry)
+		case "ObjectLockRetainUntilDate":
+			if mode, retainUntil := ObjectRetentionOf(entry); mode != "" {
+				value = retainUntil.UTC().Format("2006-01-02T15:04:05.000Z")
+			}
+		case "ObjectLockMode":
+			value, _ = ObjectRetentionOf(entry)
+		case "ObjectLockLegalHoldStatus":
+			value = "OFF"
+			if string(entry.Extended[ExtHoldKey]) == ObjectLockLegalHoldIdentity {
+				value = "ON"
+			}
+		case "Tags":
+			tags := url.Values{}
+			for k, v := range entry.Extended {
+				if strings.HasPrefix(k, xhttp.AmzObjectTagging+"-") {
+					tags.Set(strings.TrimPrefix(k, xhttp.AmzObjectTagging+"-"), string(v))
+				}
+			}
+			value = tags.Encode()
+		}
+		row = append(row, value)
+	}
+	return row
+}
+
+func inventoryEncryptionStatus(entry *Entry) string {
+	if len(entry.Extended[SseCustomerKeyMD5Header]) > 0 {
+		return "SSE-C"
+	}
+	switch string(entry.Extended[SseAlgorithmHeader]) {
+	case "AES256":
+		return "SSE-S3"
+	case "aws:kms":
+		return "SSE-KMS"
+	}
+	return "NOT-SSE"
+}
+
+// inventoryWriter writes the rows into the gzipped CSV data files in the destination bucket
+type inventoryWriter struct {
+	filer          *Filer
+	ctx            context.Context
+	bucket         string
+	destinationDir util.FullPath
+	dataDir        util.FullPath
+
+	buf   bytes.Buffer
+	gz    *gzip.Writer
+	csv   *csv.Writer
+	rows  int
+	count int
+	files []*InventoryManifestFile
+}
+
+func (w *inventoryWriter) write(row []string) error {
+	if w.gz == nil {
+		w.buf.Reset()
+		w.gz = gzip.NewWriter(&w.buf)
+		w.csv = csv.NewWriter(w.gz)
+	}
+	if err := w.csv.Write(row); err != nil {
+		return err
+	}
+	w.rows++
+	w.count++
+	if w.rows >= inventoryRowsPerFile {
+		return w.flush()
+	}
+	return nil
+}
+
+func (w *inventoryWriter) flush() error {
+	if w.gz == nil {
+		return nil
+	}
+	w.csv.Flush()
+	if err := w.csv.Error(); err != nil {
+		return err
+	}
+	if err := w.gz.Close(); err != nil {
+		return err
+	}
+	w.gz, w.csv, w.rows = nil, nil, 0
+
+	p := w.dataDir.Child(uuid.New().String() + ".csv.gz")
+	md5sum, err := w.save(p, w.buf.Bytes(), "application/gzip")
+	if err != nil {
+		return err
+	}
+	w.files = append(w.files, &InventoryManifestFile{
+		Key:         objectKeyOf(w.destinationDir, p),
+		Size:        int64(w.buf.Len()),
+		MD5checksum: fmt.Sprintf("%x", md5sum),
+	})
+	return nil
+}
+
+// abort deletes the data files of the failed report
+func (w *inventoryWriter) abort() {
+	for _, file := range w.files {
+		p := w.destinationDir.Child(file.Key)
+		if err := w.filer.DeleteEntryMetaAndData(w.ctx, p, false, false, true, false, nil); err != nil {
+			glog.V(1).Infof("inventory delete %s: %v", p, err)
+		}
+	}
+	w.files = nil
+}
+
+// save uploads the file into the destination bucket collection, and returns its md5
+func (w *inventoryWriter) save(p util.FullPath, data []byte, mime string) ([]byte, error) {
+	rule := w.filer.FilerConf.MatchStorageRule(string(p))
+	replication, _ := w.filer.ReadBucketOption(w.bucket)
+	assignRequest := &operation.VolumeAssignRequest{
+		Count:               1,
+		Collection:          util.Nvl(w.bucket, rule.Collection),
+		Replication:         util.Nvl(replication, rule.Replication),
+		DiskType:            rule.DiskType,
+		WritableVolumeCount: rule.VolumeGrowthCount,
+	}
+	assignResult, err := operation.Assign(w.filer.GetMaster, w.filer.GrpcDialOption, assignRequest)
+	if err != nil {
+		return nil, fmt.Errorf("AssignVolume: %v", err)
+	}
+	if assignResult.Error != "" {
+		return nil, fmt.Errorf("AssignVolume error: %v", assignResult.Error)
+	}
+	targetUrl := "http://" + assignResult.Url + "/" + assignResult.Fid
+	uploadResult, err := operation.UploadData(targetUrl, "", w.filer.Cipher, data, false, mime, nil, assignResult.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("upload data %s: %v", targetUrl, err)
+	}
+
+	md5sum := md5.Sum(data)
+	now := time.Now()
+	entry := &Entry{
+		FullPath: p,
+		Attr: Attr{
+			Crtime:      now,
+			Mtime:       now,
+			Mode:        os.FileMode(0644),
+			Uid:         OS_UID,
+			Gid:         OS_GID,
+			Mime:        mime,
+			Collection:  assignRequest.Collection,
+			Replication: assignRequest.Replication,
+			Md5:         md5sum[:],
+			FileSize:    uint64(len(data)),
+		},
+		Chunks: []*filer_pb.FileChunk{uploadResult.ToPbFileChunk(assignResult.Fid, 0)},
+	}
+	if err := w.filer.CreateEntry(w.ctx, entry, false, false, nil); err != nil {
+		return nil, fmt.Errorf("create %s: %v", p, err)
+	}
+	return md5sum[:], nil
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,73 @@
+package filer
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+)
+
+func TestInventoryConfigurationValidate(t *testing.T) {
+	for input, expected := range map[string]string{
+		`<InventoryConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Id>daily</Id><IsEnabled>true</IsEnabled><Destination><S3BucketDestination><Bucket>arn:aws:s3:::reports</Bucket><Format>CSV</Format></S3BucketDestination></Destination><IncludedObjectVersions>Current</IncludedObjectVersions><OptionalFields><Field>Size</Field><Field>Tags</Field></OptionalFields><Schedule><Frequency>Daily</Frequency></Schedule></InventoryConfiguration>`: "",
+		`<InventoryConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Id>daily</Id><Destination><S3BucketDestination><Bucket>reports</Bucket><Format>Parquet</Format></S3BucketDestination></Destination><IncludedObjectVersions>Current</IncludedObjectVersions><Schedule><Frequency>Daily</Frequency></Schedule></InventoryConfiguration>`:                                                                                                            ErrInventoryNotImplemented.Error(),
+		`<InventoryConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Id>daily</Id><Destination><S3BucketDestination><Bucket>reports</Bucket><Format>CSV</Format></S3BucketDestination></Destination><IncludedObjectVersions>All</IncludedObjectVersions><Schedule><Frequency>Daily</Frequency></Schedule></InventoryConfiguration>`:                                                                                                                    ErrInventoryNotImplemented.Error(),
+		`<InventoryConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Id>daily</Id><Destination><S3BucketDestination><Bucket>reports</Bucket><Format>CSV</Format></S3BucketDestination></Destination><IncludedObjectVersions>Current</IncludedObjectVersions><Schedule><Frequency>Hourly</Frequency></Schedule></InventoryConfiguration>`:                                                                                                               `unknown frequency "Hourly"`,
+		`<InventoryConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Id>daily</Id><Destination><S3BucketDestination><Bucket>reports</Bucket><Format>CSV</Format></S3BucketDestination></Destination><IncludedObjectVersions>Current</IncludedObjectVersions><OptionalFields><Field>Color</Field></OptionalFields><Schedule><Frequency>Weekly</Frequency></Schedule></InventoryConfiguration>`:                                                          `unknown optional field "Color"`,
+		`<InventoryConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Id>daily</Id><Destination><S3BucketDestination><Format>CSV</Format></S3BucketDestination></Destination><IncludedObjectVersions>Current</IncludedObjectVersions><Schedule><Frequency>Weekly</Frequency></Schedule></InventoryConfiguration>`:                                                                                                                                       "missing destination bucket",
+	} {
+		c := &InventoryConfiguration{}
+		if !assert.NoError(t, xml.Unmarshal([]byte(input), c), input) {
+			continue
+		}
+		if err := c.Validate(); expected == "" {
+			assert.NoError(t, err, input)
+		} else if assert.Error(t, err, input) {
+			assert.Equal(t, expected, err.Error(), input)
+		}
+	}
+}
+
+func TestInventorySlot(t *testing.T) {
+	// a Wednesday
+	now := time.Date(2021, 3, 17, 15, 4, 5, 0, time.UTC)
+	assert.Equal(t, time.Date(2021, 3, 17, 0, 0, 0, 0, time.UTC), inventorySlot(InventoryFrequencyDaily, now))
+	assert.Equal(t, time.Date(2021, 3, 14, 0, 0, 0, 0, time.UTC), inventorySlot(InventoryFrequencyWeekly, now))
+	assert.Equal(t, time.Date(2021, 3, 14, 0, 0, 0, 0, time.UTC), inventorySlot(InventoryFrequencyWeekly, time.Date(2021, 3, 14, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, time.Date(2021, 3, 17, 0, 0, 0, 0, time.UTC), inventorySlot(InventoryFrequencyDaily, now.In(time.FixedZone("east", 10*3600))))
+}
+
+func TestInventoryRow(t *testing.T) {
+	c := &InventoryConfiguration{
+		Id:             "daily",
+		Destination:    InventoryDestination{S3BucketDestination: InventoryS3BucketDestination{Bucket: "reports", Prefix: "/inventory/"}},
+		OptionalFields: []string{"Tags", "ObjectLockLegalHoldStatus", "EncryptionStatus", "StorageClass", "ETag", "LastModifiedDate", "Size", "IsMultipartUploaded"},
+	}
+	columns := c.columns()
+	assert.Equal(t, []string{"Bucket", "Key", "Size", "LastModifiedDate", "ETag", "StorageClass", "IsMultipartUploaded", "EncryptionStatus", "ObjectLockLegalHoldStatus", "Tags"}, columns)
+	assert.Equal(t, "inventory/photos/daily", c.reportDir("photos"))
+
+	entry := &Entry{
+		FullPath: "/buckets/photos/2021/a b.jpg",
+		Attr:     Attr{Mtime: time.Date(2021, 3, 17, 15, 4, 5, 0, time.UTC), FileSize: 3, Md5: []byte{1, 2, 3}},
+		Extended: map[string][]byte{
+			"X-Amz-Tagging-tier":  []byte("hot"),
+			"X-Amz-Tagging-owner": []byte("a&b"),
+			SseAlgorithmHeader:    []byte("aws:kms"),
+			ExtHoldKey:            []byte(ObjectLockLegalHoldIdentity),
+		},
+	}
+	assert.Equal(t, []string{"photos", "2021%2Fa+b.jpg", "3", "2021-03-17T15:04:05.000Z", "010203", "STANDARD", "false", "SSE-KMS", "ON", "owner=a%26b&tier=hot"},
+		inventoryRow("photos", "2021/a b.jpg", entry, columns))
+
+	multipart := &Entry{
+		FullPath: "/buckets/photos/big",
+		Chunks:   []*filer_pb.FileChunk{{FileId: "1,01", Size: 1}, {FileId: "1,02", Offset: 1, Size: 1}},
+		Extended: map[string][]byte{SseCustomerKeyMD5Header: []byte("md5")},
+	}
+	row := inventoryRow("photos", "big", multipart, columns)
+	assert.Equal(t, []string{"true", "SSE-C", "OFF", ""}, row[6:])
+}
@@ -0,0 +1,77 @@
+package filer
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+The filer store kv can not be listed, so the store migration copies the keys it knows of:
+
+	the fixed key        the filer store id
+	the keys of jobs     the saved bulk delete jobs in the delete job index
+	the entry keys       the hard links and the dedup index records of the chunks
+	the registered keys  the keys set by the clients with KvPut, e.g. the filer.sync offsets, or the peer offsets
+
+The registered keys are kept hex encoded in the kv key path index, when first set by each filer.
+The path indexes are entries in the tree, and are copied with the other entries.
+*/
+
+const (
+	kvKeyIndexName = "kv.keys"
+)
+
+// KvPutRegistered registers the key in the kv key index, and puts the value
+func (f *Filer) KvPutRegistered(ctx context.Context, key []byte, value []byte) error {
+	hexKey := hex.EncodeToString(key)
+	if _, found := f.registeredKvKeys.Load(hexKey); !found {
+		if err := f.updatePathIndex(ctx, &f.kvKeys, util.FullPath(hexKey), true); err != nil {
+			return err
+		}
+		f.registeredKvKeys.Store(hexKey, true)
+	}
+	return f.Store.KvPut(ctx, key, value)
+}
+
+// storeMigrationKvKeys returns the kv keys to migrate, except the keys of the entries
+func (f *Filer) storeMigrationKvKeys(ctx context.Context) (keys [][]byte, err error) {
+	jobIds, err := f.loadPathIndex(ctx, &f.DeleteJobs.index)
+	if err != nil {
+		return nil, err
+	}
+	for _, jobId := range jobIds {
+		keys = append(keys, []byte(deleteJobKvKeyPrefix+jobId))
+	}
+
+	registered, err := f.loadPathIndex(ctx, &f.kvKeys)
+	if err != nil {
+		return nil, err
+	}
+	for _, hexKey := range registered {
+		key, decodeErr := hex.DecodeString(hexKey)
+		if decodeErr != nil {
+			return nil, fmt.Errorf("registered kv key %s: %v", hexKey, decodeErr)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// entryKvKeys returns the dedup index keys of the entry chunks, also in the chunk manifests
+func (f *Filer) entryKvKeys(ctx context.Context, entry *Entry) (keys [][]byte, err error) {
+	chunks := entry.Chunks
+	if HasChunkManifest(chunks) {
+		if chunks, _, err = ResolveChunkManifest(f.MasterClient.LookupFileId, chunks); err != nil {
+			return nil, err
+		}
+	}
+	for _, chunk := range chunks {
+		if len(chunk.Fingerprint) > 0 {
+			keys = append(keys, dedupKvKey(chunk.Fingerprint))
+		}
+	}
+	return keys, nil
+}
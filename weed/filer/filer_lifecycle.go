@@ -0,0 +1,358 @@
+package filer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	xhttp "github.com/chrislusf/seaweedfs/weed/s3api/http"
+	"github.com/chrislusf/seaweedfs/weed/stats"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+The s3 bucket lifecycle rules are set by PutBucketLifecycleConfiguration, and kept in the ExtS3LifecycleKey
+extended attribute of the bucket directory. The filer walks through the buckets with the rules once per interval,
+and for the enabled rules matching the object key prefix and the object tags
+
+	expiration                       deletes the objects last modified more than the days ago, or all of them after the date
+	noncurrent version expiration    deletes the versions superseded more than the days ago, see filer_versions.go
+	abort incomplete multipart       deletes the multipart uploads under .uploads initiated more than the days ago
+
+The objects are deleted the same way as the filer clients delete them, so the held and retained objects are kept,
+and a version is kept for the objects in the versioned directories.
+
+Besides the rules, the multipart uploads in all the buckets without any new part for "s3_abort_idle_multipart_upload_hours"
+are aborted, deleting the chunks of their parts, so the abandoned uploads do not keep the space forever.
+
+All the filers with the interval run the rules, so with several filers it is usually only set on one of them.
+*/
+
+const (
+	ExtS3LifecycleKey     = "x-seaweedfs-s3-lifecycle"
+	s3MultipartUploadsDir = ".uploads"
+)
+
+type bucketLifecycle struct {
+	filer    *Filer
+	interval time.Duration
+	// the multipart uploads idle for longer are aborted, 0 to keep them
+	maxUploadIdle time.Duration
+}
+
+// StartBucketLifecycle starts running the s3 bucket lifecycle rules once per interval,
+// also aborting the multipart uploads idle for longer than maxUploadIdle if not 0.
+func (f *Filer) StartBucketLifecycle(interval, maxUploadIdle time.Duration) {
+	l := &bucketLifecycle{
+		filer:         f,
+		interval:      interval,
+		maxUploadIdle: maxUploadIdle,
+	}
+	go l.loop()
+}
+
+// LoadBucketLifecycle returns the lifecycle rules of the bucket, or nil if not set.
+func LoadBucketLifecycle(extended map[string][]byte) (*filer_pb.BucketLifecycle, error) {
+	data := extended[ExtS3LifecycleKey]
+	if len(data) == 0 {
+		return nil, nil
+	}
+	lifecycle := &filer_pb.BucketLifecycle{}
+	if err := proto.Unmarshal(data, lifecycle); err != nil {
+		return nil, fmt.Errorf("unmarshal lifecycle: %v", err)
+	}
+	return lifecycle, nil
+}
+
+func (l *bucketLifecycle) loop() {
+	// also wait for the master connection on start
+	time.Sleep(time.Minute)
+	l.filer.MasterClient.WaitUntilConnected()
+
+	for {
+		startedAt := time.Now()
+		l.runBuckets(context.Background(), startedAt)
+		time.Sleep(time.Until(startedAt.Add(l.interval)))
+	}
+}
+
+func (l *bucketLifecycle) runBuckets(ctx context.Context, now time.Time) {
+	lastFileName := ""
+	for {
+		entries, _, err := l.filer.ListDirectoryEntries(ctx, util.FullPath(l.filer.DirBucketsPath), lastFileName, false, PaginationSize, "", "")
+		if err != nil {
+			glog.V(1).Infof("lifecycle list buckets: %v", err)
+			return
+		}
+		for _, entry := range entries {
+			lastFileName = entry.Name()
+			if !entry.IsDirectory() {
+				continue
+			}
+			lifecycle, err := LoadBucketLifecycle(entry.Extended)
+			if err != nil {
+				glog.Errorf("lifecycle of bucket %s: %v", entry.Name(), err)
+				continue
+			}
+			if lifecycle != nil {
+				l.runBucket(ctx, entry.FullPath, lifecycle.Rules, now)
+			}
+			if l.maxUploadIdle > 0 {
+				l.abortIdleUploads(ctx, entry.FullPath.Child(s3MultipartUploadsDir), now)
+			}
+		}
+		if len(entries) < PaginationSize {
+			break
+		}
+	}
+}
+
+func (l *bucketLifecycle) runBucket(ctx context.Context, bucketDir util.FullPath, rules []*filer_pb.BucketLifecycle_Rule, now time.Time) {
+	var hasExpiration, hasNoncurrent, hasAbort bool
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		hasExpiration = hasExpiration || rule.ExpirationDays > 0 || rule.ExpirationDate > 0
+		hasNoncurrent = hasNoncurrent || rule.NoncurrentVersionExpirationDays > 0
+		hasAbort = hasAbort || rule.AbortIncompleteMultipartUploadDays > 0
+	}
+	uploadsDir := bucketDir.Child(s3MultipartUploadsDir)
+
+	if hasExpiration {
+		l.filer.walkFiles(ctx, bucketDir, func(files []*Entry) {
+			for _, entry := range files {
+				key := objectKeyOf(bucketDir, entry.FullPath)
+				for _, rule := range rules {
+					if !rule.Enabled || !isLifecycleRuleMatched(rule, key, entry.Extended) || !isObjectExpired(rule, entry, now) {
+						continue
+					}
+					l.delete(ctx, entry.FullPath, "expired", rule.Id)
+					break
+				}
+			}
+		}, uploadsDir)
+	}
+
+	if hasNoncurrent {
+		lastPath := util.FullPath("")
+		l.filer.walkFiles(ctx, versionsDir(bucketDir), func(files []*Entry) {
+			p, _ := files[0].FullPath.DirAndName()
+			if filePath := util.FullPath(strings.TrimPrefix(p, DirectoryVersions)); filePath != lastPath {
+				lastPath = filePath
+				l.expireVersions(ctx, bucketDir, filePath, rules, now)
+			}
+		})
+	}
+
+	if hasAbort {
+		l.walkUploads(ctx, uploadsDir, rules, now)
+	}
+}
+
+// walkFiles visits the files under the directory page by page, except the files under the skipped directories
+func (f *Filer) walkFiles(ctx context.Context, dir util.FullPath, fn func(files []*Entry), skipped ...util.FullPath) {
+	lastFileName := ""
+	for {
+		entries, _, err := f.ListDirectoryEntries(ctx, dir, lastFileName, false, PaginationSize, "", "")
+		if err != nil {
+			if err != filer_pb.ErrNotFound {
+				glog.V(1).Infof("walk %s: %v", dir, err)
+			}
+			return
+		}
+		var files []*Entry
+		for _, entry := range entries {
+			lastFileName = entry.Name()
+			if entry.IsDirectory() {
+				if !isSkippedDir(entry.FullPath, skipped) {
+					f.walkFiles(ctx, entry.FullPath, fn, skipped...)
+				}
+				continue
+			}
+			files = append(files, entry)
+		}
+		if len(files) > 0 {
+			fn(files)
+		}
+		if len(entries) < PaginationSize {
+			break
+		}
+	}
+}
+
+func isSkippedDir(dir util.FullPath, skipped []util.FullPath) bool {
+	for _, p := range skipped {
+		if dir == p {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *bucketLifecycle) expireVersions(ctx context.Context, bucketDir, p util.FullPath, rules []*filer_pb.BucketLifecycle_Rule, now time.Time) {
+	versions, err := l.filer.ListVersions(ctx, p)
+	if err != nil {
+		glog.V(1).Infof("lifecycle: %v", err)
+		return
+	}
+	key := objectKeyOf(bucketDir, p)
+	var kept, pruned []*Entry
+	for _, version := range versions {
+		isExpired := false
+		for _, rule := range rules {
+			if rule.Enabled && rule.NoncurrentVersionExpirationDays > 0 && isLifecycleRuleMatched(rule, key, version.Extended) &&
+				VersionSupersededAt(version).Add(days(rule.NoncurrentVersionExpirationDays)).Before(now) {
+				isExpired = true
+				break
+			}
+		}
+		if isExpired {
+			pruned = append(pruned, version)
+		} else {
+			kept = append(kept, version)
+		}
+	}
+	if len(pruned) == 0 {
+		return
+	}
+	if current, findErr := l.filer.FindEntry(ctx, p); findErr == nil {
+		kept = append(kept, current)
+	}
+	l.filer.deleteVersions(ctx, kept, pruned)
+	stats.FilerLifecycleCounter.WithLabelValues("noncurrent").Add(float64(len(pruned)))
+	glog.V(2).Infof("lifecycle expired %d versions of %s", len(pruned), p)
+}
+
+func (l *bucketLifecycle) walkUploads(ctx context.Context, uploadsDir util.FullPath, rules []*filer_pb.BucketLifecycle_Rule, now time.Time) {
+	lastFileName := ""
+	for {
+		entries, _, err := l.filer.ListDirectoryEntries(ctx, uploadsDir, lastFileName, false, PaginationSize, "", "")
+		if err != nil {
+			if err != filer_pb.ErrNotFound {
+				glog.V(1).Infof("lifecycle list %s: %v", uploadsDir, err)
+			}
+			return
+		}
+		for _, entry := range entries {
+			lastFileName = entry.Name()
+			if !entry.IsDirectory() {
+				continue
+			}
+			key := strings.TrimPrefix(string(entry.Extended["key"]), "/")
+			for _, rule := range rules {
+				if rule.Enabled && rule.AbortIncompleteMultipartUploadDays > 0 && strings.HasPrefix(key, rule.Prefix) &&
+					entry.Crtime.Add(days(rule.AbortIncompleteMultipartUploadDays)).Before(now) {
+					l.delete(ctx, entry.FullPath, "aborted", rule.Id)
+					break
+				}
+			}
+		}
+		if len(entries) < PaginationSize {
+			break
+		}
+	}
+}
+
+// abortIdleUploads deletes the multipart uploads, with the chunks of the parts, idle for longer than maxUploadIdle
+func (l *bucketLifecycle) abortIdleUploads(ctx context.Context, uploadsDir util.FullPath, now time.Time) {
+	lastFileName := ""
+	for {
+		entries, _, err := l.filer.ListDirectoryEntries(ctx, uploadsDir, lastFileName, false, PaginationSize, "", "")
+		if err != nil {
+			if err != filer_pb.ErrNotFound {
+				glog.V(1).Infof("lifecycle list %s: %v", uploadsDir, err)
+			}
+			return
+		}
+		for _, entry := range entries {
+			lastFileName = entry.Name()
+			if !entry.IsDirectory() || now.Sub(entry.Crtime) < l.maxUploadIdle {
+				continue
+			}
+			lastActiveAt, err := l.uploadLastActiveAt(ctx, entry)
+			if err != nil {
+				glog.V(1).Infof("lifecycle list %s: %v", entry.FullPath, err)
+				continue
+			}
+			if now.Sub(lastActiveAt) >= l.maxUploadIdle {
+				l.delete(ctx, entry.FullPath, "abandoned", "idle-uploads")
+			}
+		}
+		if len(entries) < PaginationSize {
+			break
+		}
+	}
+}
+
+func (l *bucketLifecycle) uploadLastActiveAt(ctx context.Context, upload *Entry) (time.Time, error) {
+	lastActiveAt := upload.Crtime
+	lastFileName := ""
+	for {
+		parts, _, err := l.filer.ListDirectoryEntries(ctx, upload.FullPath, lastFileName, false, PaginationSize, "", "")
+		if err != nil && err != filer_pb.ErrNotFound {
+			return lastActiveAt, err
+		}
+		if latest := latestActivity(parts); latest.After(lastActiveAt) {
+			lastActiveAt = latest
+		}
+		if len(parts) < PaginationSize {
+			return lastActiveAt, nil
+		}
+		lastFileName = parts[len(parts)-1].Name()
+	}
+}
+
+// latestActivity returns the latest creation or modification time of the entries
+func latestActivity(entries []*Entry) (latest time.Time) {
+	for _, entry := range entries {
+		for _, t := range []time.Time{entry.Crtime, entry.Mtime} {
+			if t.After(latest) {
+				latest = t
+			}
+		}
+	}
+	return
+}
+
+func (l *bucketLifecycle) delete(ctx context.Context, p util.FullPath, action, ruleId string) {
+	if err := l.filer.DeleteEntryMetaAndData(ctx, p, true, true, true, false, nil); err != nil {
+		glog.V(1).Infof("lifecycle rule %s: delete %s: %v", ruleId, p, err)
+		stats.FilerLifecycleCounter.WithLabelValues("error").Inc()
+		return
+	}
+	stats.FilerLifecycleCounter.WithLabelValues(action).Inc()
+	glog.V(2).Infof("lifecycle rule %s: %s %s", ruleId, action, p)
+}
+
+func isLifecycleRuleMatched(rule *filer_pb.BucketLifecycle_Rule, key string, extended map[string][]byte) bool {
+	if !strings.HasPrefix(key, rule.Prefix) {
+		return false
+	}
+	for k, v := range rule.Tags {
+		if value, found := extended[xhttp.AmzObjectTagging+"-"+k]; !found || string(value) != v {
+			return false
+		}
+	}
+	return true
+}
+
+func isObjectExpired(rule *filer_pb.BucketLifecycle_Rule, entry *Entry, now time.Time) bool {
+	if rule.ExpirationDays > 0 && entry.Mtime.Add(days(rule.ExpirationDays)).Before(now) {
+		return true
+	}
+	return rule.ExpirationDate > 0 && now.Unix() >= rule.ExpirationDate
+}
+
+func objectKeyOf(bucketDir, p util.FullPath) string {
+	return strings.TrimPrefix(string(p), string(bucketDir)+"/")
+}
+
+func days(n int32) time.Duration {
+	return time.Duration(n) * 24 * time.Hour
+}
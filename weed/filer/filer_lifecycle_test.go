@@ -0,0 +1,51 @@
+package filer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func TestLifecycleRuleMatched(t *testing.T) {
+
+	rule := &filer_pb.BucketLifecycle_Rule{
+		Prefix: "logs/",
+		Tags:   map[string]string{"tier": "temp"},
+	}
+	tagged := map[string][]byte{"X-Amz-Tagging-tier": []byte("temp")}
+
+	assert.True(t, isLifecycleRuleMatched(rule, "logs/a.log", tagged))
+	assert.False(t, isLifecycleRuleMatched(rule, "data/a.log", tagged), "prefix")
+	assert.False(t, isLifecycleRuleMatched(rule, "logs/a.log", nil), "missing tag")
+	assert.False(t, isLifecycleRuleMatched(rule, "logs/a.log", map[string][]byte{"X-Amz-Tagging-tier": []byte("hot")}), "tag value")
+	assert.True(t, isLifecycleRuleMatched(&filer_pb.BucketLifecycle_Rule{}, "any", nil), "empty filter")
+}
+
+func TestObjectExpired(t *testing.T) {
+
+	now := time.Now()
+	entry := &Entry{FullPath: "/buckets/b/logs/a.log", Attr: Attr{Mtime: now.Add(-3 * 24 * time.Hour)}}
+
+	assert.True(t, isObjectExpired(&filer_pb.BucketLifecycle_Rule{ExpirationDays: 2}, entry, now))
+	assert.False(t, isObjectExpired(&filer_pb.BucketLifecycle_Rule{ExpirationDays: 4}, entry, now))
+	assert.True(t, isObjectExpired(&filer_pb.BucketLifecycle_Rule{ExpirationDate: now.Unix()}, entry, now))
+	assert.False(t, isObjectExpired(&filer_pb.BucketLifecycle_Rule{ExpirationDate: now.Add(time.Hour).Unix()}, entry, now))
+
+	assert.Equal(t, "logs/a.log", objectKeyOf(util.FullPath("/buckets/b"), entry.FullPath))
+}
+
+func TestLatestActivity(t *testing.T) {
+
+	now := time.Now()
+	parts := []*Entry{
+		{FullPath: "/buckets/b/.uploads/u/0001.part", Attr: Attr{Crtime: now.Add(-3 * time.Hour), Mtime: now.Add(-2 * time.Hour)}},
+		{FullPath: "/buckets/b/.uploads/u/0002.part", Attr: Attr{Crtime: now.Add(-time.Hour), Mtime: now.Add(-time.Hour)}},
+	}
+
+	assert.Equal(t, now.Add(-time.Hour), latestActivity(parts))
+	assert.True(t, latestActivity(nil).IsZero())
+}
@@ -0,0 +1,250 @@
+package filer
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+How path-scoped locks work?
+-----------
+
+Gateways (mount, webdav, s3, applications) lock a path on the filer they connect to:
+  * a lock is on a full path, and has an owner and an expiration time
+  * a successful lock returns a renew token
+  * the lock holder must renew the lock with the renew token before it expires
+  * renewing an expired lock fails, since others may have taken it in between
+  * unlocking also requires the renew token
+
+A lock is exclusive, or shared with other shared locks on the same path, as flock(LOCK_SH).
+A recursive lock on a directory also locks the entries under it, as the webdav locks with infinite depth.
+A lock that is not renewed in time expires, and can be taken by other owners.
+The owner is only informational. Converting between shared and exclusive is a renewal, and also requires the renew token.
+
+The locks are kept in the memory of one lock filer, which the filers sharing the filer store forward the locks to,
+see filer_lock_filer.go.
+
+*/
+
+const (
+	MaxLockDuration     = time.Hour
+	DefaultLockDuration = 30 * time.Second
+)
+
+var (
+	ErrLockedByOthers      = fmt.Errorf("locked by others")
+	ErrLockTokenInvalid    = fmt.Errorf("invalid lock token")
+	ErrLockDurationTooLong = fmt.Errorf("lock duration is longer than %v", MaxLockDuration)
+)
+
+type FileLock struct {
+	Path        util.FullPath
+	Owner       string
+	Token       string
+	ExpireAtNs  int64
+	IsShared    bool
+	IsRecursive bool
+}
+
+func (l *FileLock) isExpired(now time.Time) bool {
+	return l.ExpireAtNs <= now.UnixNano()
+}
+
+// conflictsWith tells whether the lock can not be held together with a shared or exclusive lock
+func (l *FileLock) conflictsWith(isShared bool) bool {
+	return !isShared || !l.IsShared
+}
+
+type LockManager struct {
+	locks     map[util.FullPath][]*FileLock // one exclusive lock, or the shared locks
+	locksLock sync.RWMutex
+}
+
+func NewLockManager() *LockManager {
+	lm := &LockManager{
+		locks: make(map[util.FullPath][]*FileLock),
+	}
+	go lm.loopCleanExpiredLocks()
+	return lm
+}
+
+// Lock acquires, or with the renew token renews or converts, the lock on the path.
+// If locked by others, one of the conflicting locks is returned with ErrLockedByOthers.
+func (lm *LockManager) Lock(path util.FullPath, owner string, renewToken string, duration time.Duration, isShared bool, isRecursive bool) (lock *FileLock, err error) {
+	if duration <= 0 {
+		duration = DefaultLockDuration
+	}
+	if duration > MaxLockDuration {
+		return nil, ErrLockDurationTooLong
+	}
+
+	lm.locksLock.Lock()
+	defer lm.locksLock.Unlock()
+
+	now := time.Now()
+	holders := lm.liveLocks(path, now)
+
+	if renewToken != "" {
+		for _, existing := range holders {
+			if existing.Token == renewToken {
+				// renew, keeping the token and whether recursive, and converting between shared and exclusive
+				if conflict := lm.findConflict(path, isShared, existing.IsRecursive, existing, now); conflict != nil {
+					return copyFileLock(conflict), ErrLockedByOthers
+				}
+				existing.IsShared = isShared
+				existing.ExpireAtNs = now.Add(duration).UnixNano()
+				return copyFileLock(existing), nil
+			}
+		}
+		// the lock has expired or been released, and may be taken by others already
+		if len(holders) > 0 {
+			return copyFileLock(holders[0]), ErrLockTokenInvalid
+		}
+		return nil, ErrLockTokenInvalid
+	}
+
+	if conflict := lm.findConflict(path, isShared, isRecursive, nil, now); conflict != nil {
+		return copyFileLock(conflict), ErrLockedByOthers
+	}
+
+	lock = &FileLock{
+		Path:        path,
+		Owner:       owner,
+		Token:       fmt.Sprintf("%x", util.RandomBytes(16)),
+		ExpireAtNs:  now.Add(duration).UnixNano(),
+		IsShared:    isShared,
+		IsRecursive: isRecursive,
+	}
+	lm.locks[path] = append(holders, lock)
+
+	return copyFileLock(lock), nil
+}
+
+// findConflict returns a live lock, other than the exception, conflicting with the shared or exclusive lock on the path:
+// a lock on the path, a recursive lock on its parent directories, or for a recursive lock, a lock under the path.
+func (lm *LockManager) findConflict(path util.FullPath, isShared bool, isRecursive bool, exception *FileLock, now time.Time) *FileLock {
+	for _, existing := range lm.locks[path] {
+		if existing != exception && !existing.isExpired(now) && existing.conflictsWith(isShared) {
+			return existing
+		}
+	}
+	if existing := lm.findParentLock(path, now, isShared, true); existing != nil {
+		return existing
+	}
+	if !isRecursive {
+		return nil
+	}
+	dirPrefix := string(path) + "/"
+	if path == "/" {
+		dirPrefix = "/"
+	}
+	for p, holders := range lm.locks {
+		if p == path || !strings.HasPrefix(string(p), dirPrefix) {
+			continue
+		}
+		for _, existing := range holders {
+			if !existing.isExpired(now) && existing.conflictsWith(isShared) {
+				return existing
+			}
+		}
+	}
+	return nil
+}
+
+// Unlock releases the lock if the renew token matches.
+// Unlocking a path that is not locked is not an error.
+func (lm *LockManager) Unlock(path util.FullPath, renewToken string) error {
+	lm.locksLock.Lock()
+	defer lm.locksLock.Unlock()
+
+	holders := lm.liveLocks(path, time.Now())
+	if len(holders) == 0 {
+		return nil
+	}
+	for i, existing := range holders {
+		if existing.Token == renewToken {
+			lm.setLocks(path, append(holders[:i:i], holders[i+1:]...))
+			return nil
+		}
+	}
+	return ErrLockTokenInvalid
+}
+
+// FindLock returns one of the unexpired locks on the path, or the recursive locks on its parent directories, if any.
+func (lm *LockManager) FindLock(path util.FullPath) (lock *FileLock, found bool) {
+	lm.locksLock.RLock()
+	defer lm.locksLock.RUnlock()
+
+	now := time.Now()
+	for _, existing := range lm.locks[path] {
+		if !existing.isExpired(now) {
+			return copyFileLock(existing), true
+		}
+	}
+	if existing := lm.findParentLock(path, now, true, false); existing != nil {
+		return copyFileLock(existing), true
+	}
+	return nil, false
+}
+
+// findParentLock returns a live recursive lock on the parent directories, only the conflicting ones if isConflictOnly
+func (lm *LockManager) findParentLock(path util.FullPath, now time.Time, isShared bool, isConflictOnly bool) *FileLock {
+	for p := path; p != "/"; {
+		dir, _ := p.DirAndName()
+		p = util.FullPath(dir)
+		for _, existing := range lm.locks[p] {
+			if existing.IsRecursive && !existing.isExpired(now) && (!isConflictOnly || existing.conflictsWith(isShared)) {
+				return existing
+			}
+		}
+	}
+	return nil
+}
+
+// Reset drops all the locks, when this filer is no longer the lock filer
+func (lm *LockManager) Reset() {
+	lm.locksLock.Lock()
+	defer lm.locksLock.Unlock()
+
+	lm.locks = make(map[util.FullPath][]*FileLock)
+}
+
+// liveLocks drops the expired locks on the path, and returns the rest.
+func (lm *LockManager) liveLocks(path util.FullPath, now time.Time) (holders []*FileLock) {
+	for _, existing := range lm.locks[path] {
+		if !existing.isExpired(now) {
+			holders = append(holders, existing)
+		}
+	}
+	lm.setLocks(path, holders)
+	return
+}
+
+func (lm *LockManager) setLocks(path util.FullPath, holders []*FileLock) {
+	if len(holders) == 0 {
+		delete(lm.locks, path)
+		return
+	}
+	lm.locks[path] = holders
+}
+
+func (lm *LockManager) loopCleanExpiredLocks() {
+	for {
+		time.Sleep(time.Minute)
+		now := time.Now()
+		lm.locksLock.Lock()
+		for path := range lm.locks {
+			lm.liveLocks(path, now)
+		}
+		lm.locksLock.Unlock()
+	}
+}
+
+func copyFileLock(l *FileLock) *FileLock {
+	t := *l
+	return &t
+}
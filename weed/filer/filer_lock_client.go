@@ -0,0 +1,94 @@
+package filer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+)
+
+const (
+	LiveLockDuration      = 10 * time.Second
+	LiveLockRenewInterval = 4 * time.Second
+)
+
+// LiveLock is a filer lock that is renewed in the background until released.
+type LiveLock struct {
+	filerClient filer_pb.FilerClient
+	path        string
+	owner       string
+	renewToken  string
+	isShared    bool
+	renewLock   sync.Mutex // the renewals send isShared, so they do not interleave with the conversions
+	stopCh      chan struct{}
+	stopOnce    sync.Once
+}
+
+// TryLiveLock acquires the shared or exclusive lock on the path, returning filer_pb.ErrLockHeldByOthers if not available.
+func TryLiveLock(filerClient filer_pb.FilerClient, path string, owner string, isShared bool) (*LiveLock, error) {
+	resp, err := filer_pb.Lock(filerClient, path, owner, "", LiveLockDuration, isShared, false)
+	if err != nil {
+		return nil, err
+	}
+	lock := &LiveLock{
+		filerClient: filerClient,
+		path:        path,
+		owner:       owner,
+		renewToken:  resp.RenewToken,
+		isShared:    isShared,
+		stopCh:      make(chan struct{}),
+	}
+	go lock.loopRenew()
+	return lock, nil
+}
+
+func (lock *LiveLock) loopRenew() {
+	ticker := time.NewTicker(LiveLockRenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-lock.stopCh:
+			return
+		case <-ticker.C:
+			lock.renewLock.Lock()
+			err := lock.renew(lock.isShared)
+			lock.renewLock.Unlock()
+			if err != nil {
+				glog.Warningf("renew lock %s by %s: %v", lock.path, lock.owner, err)
+			}
+		}
+	}
+}
+
+// IsShared tells whether the lock is shared with other shared locks.
+func (lock *LiveLock) IsShared() bool {
+	lock.renewLock.Lock()
+	defer lock.renewLock.Unlock()
+	return lock.isShared
+}
+
+// Convert changes the lock between shared and exclusive, renewing it with the renew token.
+func (lock *LiveLock) Convert(isShared bool) error {
+	lock.renewLock.Lock()
+	defer lock.renewLock.Unlock()
+	return lock.renew(isShared)
+}
+
+// renew renews the lock as shared or exclusive, with the renewLock held
+func (lock *LiveLock) renew(isShared bool) error {
+	if _, err := filer_pb.Lock(lock.filerClient, lock.path, lock.owner, lock.renewToken, LiveLockDuration, isShared, false); err != nil {
+		return err
+	}
+	lock.isShared = isShared
+	return nil
+}
+
+// Release stops renewing and unlocks the path.
+func (lock *LiveLock) Release() (err error) {
+	lock.stopOnce.Do(func() {
+		close(lock.stopCh)
+		err = filer_pb.Unlock(lock.filerClient, lock.path, lock.renewToken)
+	})
+	return
+}
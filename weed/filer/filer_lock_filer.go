@@ -0,0 +1,98 @@
+package filer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/master_pb"
+)
+
+/*
+The filers sharing one filer store elect one lock filer to keep the path-scoped locks,
+so a lock taken on one filer is seen on all of them. The lock filer also counts the dedup references.
+
+Each filer leases the admin lock of the filer store from the master, named by the filer store signature.
+The filer holding the lease is the lock filer, and saves its address in the filer store kv.
+The other filers read the address, and forward the lock requests to the lock filer.
+
+The lock filer stops serving the locks if it can not renew the lease in time, before the master gives it to another filer.
+The locks are kept in memory, so when the lock filer changes, the locks are lost,
+and the holders get ErrLockTokenInvalid on their next renewal.
+*/
+
+const (
+	lockFilerKvKey         = "filer.lock.filer"
+	lockFilerLeaseInterval = 3 * time.Second
+	// shorter than the admin lock duration on the master
+	lockFilerLeaseDuration = 8 * time.Second
+)
+
+var ErrNoLockFiler = errors.New("no lock filer elected yet")
+
+type lockFiler struct {
+	leasedAtNs int64
+}
+
+// IsLockFiler tells whether this filer holds the lease to keep the locks
+func (f *Filer) IsLockFiler() bool {
+	leasedAtNs := atomic.LoadInt64(&f.lockFiler.leasedAtNs)
+	return leasedAtNs != 0 && time.Since(time.Unix(0, leasedAtNs)) < lockFilerLeaseDuration
+}
+
+// FindLockFiler returns the address of the lock filer saved in the filer store kv
+func (f *Filer) FindLockFiler(ctx context.Context) (address string, err error) {
+	data, err := f.Store.KvGet(ctx, []byte(lockFilerKvKey))
+	if err == ErrKvNotFound || err == nil && len(data) == 0 {
+		return "", ErrNoLockFiler
+	}
+	if err != nil {
+		return "", fmt.Errorf("find lock filer: %v", err)
+	}
+	return string(data), nil
+}
+
+// LoopElectingLockFiler leases the admin lock of the filer store from the master, to become or stay the lock filer.
+func (f *Filer) LoopElectingLockFiler(self string) {
+	lockName := fmt.Sprintf("filer.locks.%d", f.Signature)
+	var token, lockTsNs int64
+	for {
+		startedAt := time.Now()
+		err := f.MasterClient.WithClient(func(client master_pb.SeaweedClient) error {
+			resp, err := client.LeaseAdminToken(context.Background(), &master_pb.LeaseAdminTokenRequest{
+				PreviousToken:    token,
+				PreviousLockTime: lockTsNs,
+				LockName:         lockName,
+			})
+			if err != nil {
+				return err
+			}
+			token, lockTsNs = resp.Token, resp.LockTsNs
+			return nil
+		})
+		if err == nil && !f.IsLockFiler() {
+			// the locks of an earlier lease may be taken by others in between
+			f.LockManager.Reset()
+			if err = f.Store.KvPut(context.Background(), []byte(lockFilerKvKey), []byte(self)); err != nil {
+				// keep the lease, and save the address again on the next renewal
+				glog.Errorf("save lock filer %s: %v", self, err)
+				time.Sleep(lockFilerLeaseInterval)
+				continue
+			}
+			glog.V(0).Infof("filer %s is the lock filer", self)
+		}
+		if err == nil {
+			atomic.StoreInt64(&f.lockFiler.leasedAtNs, startedAt.UnixNano())
+		} else {
+			if f.IsLockFiler() {
+				glog.Warningf("filer %s is no longer the lock filer: %v", self, err)
+			}
+			atomic.StoreInt64(&f.lockFiler.leasedAtNs, 0)
+			token, lockTsNs = 0, 0
+		}
+		time.Sleep(lockFilerLeaseInterval)
+	}
+}
@@ -0,0 +1,141 @@
+package filer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockManager(t *testing.T) {
+
+	lm := NewLockManager()
+
+	lock, err := lm.Lock("/a/b", "owner1", "", time.Minute, false, false)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, lock.Token)
+
+	// others can not take it
+	existing, err := lm.Lock("/a/b", "owner2", "", time.Minute, false, false)
+	assert.Equal(t, ErrLockedByOthers, err)
+	assert.Equal(t, "owner1", existing.Owner)
+
+	// renew keeps the token
+	renewed, err := lm.Lock("/a/b", "owner1", lock.Token, time.Minute, false, false)
+	assert.Nil(t, err)
+	assert.Equal(t, lock.Token, renewed.Token)
+
+	_, err = lm.Lock("/a/b", "owner2", "bad token", time.Minute, false, false)
+	assert.Equal(t, ErrLockTokenInvalid, err)
+
+	assert.Equal(t, ErrLockTokenInvalid, lm.Unlock("/a/b", "bad token"))
+	assert.Nil(t, lm.Unlock("/a/b", lock.Token))
+
+	_, found := lm.FindLock("/a/b")
+	assert.False(t, found)
+
+	// renewing a released lock fails
+	_, err = lm.Lock("/a/b", "owner1", lock.Token, time.Minute, false, false)
+	assert.Equal(t, ErrLockTokenInvalid, err)
+
+	_, err = lm.Lock("/a/b", "owner2", "", time.Minute, false, false)
+	assert.Nil(t, err)
+}
+
+func TestLockManagerExpiration(t *testing.T) {
+
+	lm := NewLockManager()
+
+	_, err := lm.Lock("/a", "owner1", "", time.Millisecond, false, false)
+	assert.Nil(t, err)
+
+	time.Sleep(5 * time.Millisecond)
+
+	_, found := lm.FindLock("/a")
+	assert.False(t, found)
+
+	lock, err := lm.Lock("/a", "owner2", "", time.Minute, false, false)
+	assert.Nil(t, err)
+	assert.Equal(t, "owner2", lock.Owner)
+}
+
+func TestLockManagerShared(t *testing.T) {
+
+	lm := NewLockManager()
+
+	lock1, err := lm.Lock("/a", "owner1", "", time.Minute, true, false)
+	assert.Nil(t, err)
+	lock2, err := lm.Lock("/a", "owner2", "", time.Minute, true, false)
+	assert.Nil(t, err)
+	assert.NotEqual(t, lock1.Token, lock2.Token)
+
+	// no exclusive lock, nor converting to exclusive, while shared by others
+	_, err = lm.Lock("/a", "owner3", "", time.Minute, false, false)
+	assert.Equal(t, ErrLockedByOthers, err)
+	_, err = lm.Lock("/a", "owner1", lock1.Token, time.Minute, false, false)
+	assert.Equal(t, ErrLockedByOthers, err)
+
+	renewed, err := lm.Lock("/a", "owner2", lock2.Token, time.Minute, true, false)
+	assert.Nil(t, err)
+	assert.True(t, renewed.IsShared)
+
+	// the owner alone can not convert the lock, without the renew token
+	assert.Nil(t, lm.Unlock("/a", lock2.Token))
+	_, err = lm.Lock("/a", "owner1", "", time.Minute, false, false)
+	assert.Equal(t, ErrLockedByOthers, err)
+	converted, err := lm.Lock("/a", "owner1", lock1.Token, time.Minute, false, false)
+	assert.Nil(t, err)
+	assert.False(t, converted.IsShared)
+	assert.Equal(t, lock1.Token, converted.Token)
+
+	// no shared lock while locked exclusively
+	_, err = lm.Lock("/a", "owner2", "", time.Minute, true, false)
+	assert.Equal(t, ErrLockedByOthers, err)
+}
+
+func TestLockManagerDuration(t *testing.T) {
+
+	lm := NewLockManager()
+
+	_, err := lm.Lock("/a", "owner1", "", MaxLockDuration+time.Second, false, false)
+	assert.Equal(t, ErrLockDurationTooLong, err)
+	_, found := lm.FindLock("/a")
+	assert.False(t, found)
+
+	lock, err := lm.Lock("/a", "owner1", "", MaxLockDuration, false, false)
+	assert.Nil(t, err)
+	_, err = lm.Lock("/a", "owner1", lock.Token, MaxLockDuration+time.Second, false, false)
+	assert.Equal(t, ErrLockDurationTooLong, err)
+}
+
+func TestLockManagerRecursive(t *testing.T) {
+
+	lm := NewLockManager()
+
+	lock, err := lm.Lock("/a/b", "owner1", "", time.Minute, false, true)
+	assert.Nil(t, err)
+
+	// the entries under the directory are locked too
+	_, err = lm.Lock("/a/b/c/d", "owner2", "", time.Minute, false, false)
+	assert.Equal(t, ErrLockedByOthers, err)
+	existing, found := lm.FindLock("/a/b/c")
+	assert.True(t, found)
+	assert.Equal(t, "owner1", existing.Owner)
+	_, err = lm.Lock("/a/bc", "owner2", "", time.Minute, false, false)
+	assert.Nil(t, err)
+
+	// a recursive lock conflicts with the locks under the directory
+	_, err = lm.Lock("/a", "owner3", "", time.Minute, false, true)
+	assert.Equal(t, ErrLockedByOthers, err)
+	assert.Nil(t, lm.Unlock("/a/b", lock.Token))
+	_, err = lm.Lock("/a", "owner3", "", time.Minute, false, true)
+	assert.Equal(t, ErrLockedByOthers, err)
+
+	// the shared recursive locks, and the shared locks under them, are shared
+	_, err = lm.Lock("/x", "owner1", "", time.Minute, true, true)
+	assert.Nil(t, err)
+	_, err = lm.Lock("/x/y", "owner2", "", time.Minute, true, false)
+	assert.Nil(t, err)
+	_, err = lm.Lock("/x/z", "owner2", "", time.Minute, false, false)
+	assert.Equal(t, ErrLockedByOthers, err)
+}
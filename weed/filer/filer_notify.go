@@ -5,12 +5,14 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang/protobuf/proto"
 
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/notification"
+	"github.com/chrislusf/seaweedfs/weed/notification/s3event"
 	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
 	"github.com/chrislusf/seaweedfs/weed/util"
 )
@@ -27,6 +29,9 @@ func (f *Filer) NotifyUpdateEvent(ctx context.Context, oldEntry, newEntry *Entry
 
 	// println("fullpath:", fullpath)
 
+	f.updateDirEntriesCount(oldEntry, newEntry)
+	f.updateQuotaUsages(oldEntry, newEntry)
+
 	if strings.HasPrefix(fullpath, SystemLogDir) {
 		return
 	}
@@ -61,6 +66,10 @@ func (f *Filer) NotifyUpdateEvent(ctx context.Context, oldEntry, newEntry *Entry
 		}
 	}
 
+	if s3event.IsEnabled() && !isFromOtherCluster {
+		f.notifyS3Event(oldEntry, newEntry)
+	}
+
 	f.logMetaEvent(ctx, fullpath, eventNotification)
 
 }
@@ -100,6 +109,11 @@ func (f *Filer) logFlushFunc(startTime, stopTime time.Time, buf []byte) {
 	for {
 		if err := f.appendToFile(targetFile, buf); err != nil {
 			glog.V(1).Infof("log write failed %s: %v", targetFile, err)
+			if atomic.LoadInt32(&f.isStopping) == 1 && f.CheckClusterWritable() != nil {
+				// the volumes can not be written until the cluster is writable again, do not block the shutdown
+				glog.Errorf("drop the meta log %s on shutdown: %v", targetFile, err)
+				break
+			}
 			time.Sleep(737 * time.Millisecond)
 		} else {
 			break
@@ -0,0 +1,109 @@
+package filer
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/notification/s3event"
+	xhttp "github.com/chrislusf/seaweedfs/weed/s3api/http"
+	"github.com/chrislusf/seaweedfs/weed/stats"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+const (
+	s3EventQueueSize = 10000
+)
+
+type s3EventChange struct {
+	oldEntry *Entry
+	newEntry *Entry
+}
+
+// notifyS3Event queues the object change to send its s3 events in the background, see s3event.go.
+// The change is dropped if the queue is full, so the slow targets do not block the writes.
+func (f *Filer) notifyS3Event(oldEntry, newEntry *Entry) {
+	select {
+	case f.s3Events <- &s3EventChange{oldEntry: oldEntry, newEntry: newEntry}:
+	default:
+		stats.FilerS3EventCounter.WithLabelValues("dropped").Inc()
+		glog.V(1).Infof("s3 event queue is full, dropped the change of %s", changedPath(oldEntry, newEntry))
+	}
+}
+
+func changedPath(oldEntry, newEntry *Entry) util.FullPath {
+	if newEntry != nil {
+		return newEntry.FullPath
+	}
+	return oldEntry.FullPath
+}
+
+func (f *Filer) loopSendingS3Events() {
+	for change := range f.s3Events {
+		f.sendS3Events(context.Background(), change.oldEntry, change.newEntry)
+	}
+}
+
+// sendS3Events sends the s3 events of the object changes under the buckets
+func (f *Filer) sendS3Events(ctx context.Context, oldEntry, newEntry *Entry) {
+	if oldEntry != nil && newEntry != nil && oldEntry.FullPath == newEntry.FullPath {
+		// the metadata only updates
+		if ETagEntry(oldEntry) == ETagEntry(newEntry) && oldEntry.Size() == newEntry.Size() {
+			return
+		}
+		f.sendS3Event(ctx, newEntry, s3event.EventObjectCreatedPut)
+		return
+	}
+	if oldEntry != nil {
+		f.sendS3Event(ctx, oldEntry, s3event.EventObjectRemovedDelete)
+	}
+	if newEntry != nil {
+		f.sendS3Event(ctx, newEntry, s3event.EventObjectCreatedPut)
+	}
+}
+
+func (f *Filer) sendS3Event(ctx context.Context, entry *Entry, eventName string) {
+	if entry.IsDirectory() || !strings.HasPrefix(string(entry.FullPath), f.DirBucketsPath+"/") {
+		return
+	}
+	bucketAndKey := strings.TrimPrefix(string(entry.FullPath), f.DirBucketsPath+"/")
+	t := strings.SplitN(bucketAndKey, "/", 2)
+	if len(t) != 2 || t[1] == "" || strings.HasPrefix(t[1], s3MultipartUploadsDir+"/") {
+		return
+	}
+	bucket, key := t[0], t[1]
+
+	bucketEntry, err := f.FindEntry(ctx, util.NewFullPath(f.DirBucketsPath, bucket))
+	if err != nil || len(bucketEntry.Extended[s3event.ExtS3NotificationKey]) == 0 {
+		return
+	}
+	configuration, err := s3event.ParseNotificationConfiguration(bucketEntry.Extended[s3event.ExtS3NotificationKey])
+	if err != nil {
+		glog.Errorf("bucket %s notification: %v", bucket, err)
+		return
+	}
+
+	object := &s3event.ObjectInfo{
+		Bucket:      bucket,
+		Key:         key,
+		ETag:        ETagEntry(entry),
+		PrincipalId: string(entry.Extended[xhttp.AmzIdentityId]),
+		Time:        time.Now(),
+	}
+	if eventName != s3event.EventObjectRemovedDelete {
+		object.Size = entry.Size()
+	}
+	for _, rule := range configuration.Rules() {
+		if !rule.IsMatched(eventName, key) {
+			continue
+		}
+		event := &s3event.Event{Records: []*s3event.Record{s3event.NewRecord(rule, eventName, object)}}
+		if err := s3event.Send(rule.Arn, bucketAndKey, event); err != nil {
+			glog.Errorf("s3 event %s of %s: %v", eventName, entry.FullPath, err)
+			stats.FilerS3EventCounter.WithLabelValues("error").Inc()
+			continue
+		}
+		stats.FilerS3EventCounter.WithLabelValues("sent").Inc()
+	}
+}
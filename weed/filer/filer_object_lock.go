@@ -0,0 +1,200 @@
+package filer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+S3 object lock keeps the retention mode and the retain until date of an object in its extended attributes.
+
+During the retention, the object can not be overwritten, renamed, or deleted, through any client.
+The retention in COMPLIANCE mode can only be extended. The retention in GOVERNANCE mode can also be
+shortened or removed, and the object deleted, when the request bypasses the governance retention.
+
+The retention is only honored, and kept, in the buckets with object lock enabled. Elsewhere the object lock
+extended attributes are removed when the entries are written, so the clients can not retain arbitrary files.
+The entries with TTL are not expired during the retention.
+
+The legal hold is a hold by the ObjectLockLegalHoldIdentity, see filer_hold.go.
+*/
+
+const (
+	ExtObjectLockModeKey        = "X-Amz-Object-Lock-Mode"
+	ExtObjectLockRetainUntilKey = "X-Amz-Object-Lock-Retain-Until-Date"
+	// the bucket object lock configuration, set when object lock is enabled on the bucket
+	ExtS3ObjectLockKey = "x-seaweedfs-s3-object-lock"
+
+	ObjectLockGovernance = "GOVERNANCE"
+	ObjectLockCompliance = "COMPLIANCE"
+
+	ObjectLockLegalHoldIdentity = "s3.legal-hold"
+)
+
+var ErrObjectLockNotEnabled = errors.New("object lock is not enabled on the bucket")
+
+type governanceBypassKey struct{}
+
+// WithGovernanceBypass allows the operations with the context to bypass the GOVERNANCE retention
+func WithGovernanceBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, governanceBypassKey{}, true)
+}
+
+func IsGovernanceBypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(governanceBypassKey{}).(bool)
+	return bypass
+}
+
+// ObjectRetentionOf returns the object lock mode and the retain until date, with an empty mode if not set
+func ObjectRetentionOf(entry *Entry) (mode string, retainUntil time.Time) {
+	if entry == nil || len(entry.Extended[ExtObjectLockModeKey]) == 0 {
+		return
+	}
+	retainUntil, err := time.Parse(time.RFC3339, string(entry.Extended[ExtObjectLockRetainUntilKey]))
+	if err != nil {
+		glog.Warningf("%s object lock retain until date %q: %v", entry.FullPath, entry.Extended[ExtObjectLockRetainUntilKey], err)
+		return "", time.Time{}
+	}
+	return string(entry.Extended[ExtObjectLockModeKey]), retainUntil
+}
+
+// isObjectLockRetained checks the retention of the files, e.g. not the multipart upload folders keeping the requested retention
+func isObjectLockRetained(entry *Entry, bypassGovernance bool) bool {
+	if entry == nil || entry.IsDirectory() {
+		return false
+	}
+	mode, retainUntil := ObjectRetentionOf(entry)
+	if mode == "" || !time.Now().Before(retainUntil) {
+		return false
+	}
+	return mode == ObjectLockCompliance || !bypassGovernance
+}
+
+// SetObjectRetention sets the object lock retention on the file, or removes it with an empty mode.
+func (f *Filer) SetObjectRetention(ctx context.Context, p util.FullPath, mode string, retainUntil time.Time, bypassGovernance bool) error {
+
+	if mode != "" && mode != ObjectLockGovernance && mode != ObjectLockCompliance {
+		return fmt.Errorf("unknown object lock mode %q", mode)
+	}
+	if mode != "" && !time.Now().Before(retainUntil) {
+		return fmt.Errorf("retain until date %v is not in the future", retainUntil)
+	}
+	if !f.isObjectLockEnabled(ctx, p) {
+		return fmt.Errorf("%s: %v", p, ErrObjectLockNotEnabled)
+	}
+
+	oldEntry, err := f.FindEntry(ctx, p)
+	if err != nil {
+		return err
+	}
+	if oldEntry.IsDirectory() {
+		return fmt.Errorf("%s is a directory", p)
+	}
+
+	if isObjectLockRetained(oldEntry, false) {
+		oldMode, oldRetainUntil := ObjectRetentionOf(oldEntry)
+		shortened := mode == "" || retainUntil.Before(oldRetainUntil)
+		if oldMode == ObjectLockCompliance && (shortened || mode != ObjectLockCompliance) ||
+			oldMode == ObjectLockGovernance && shortened && !bypassGovernance {
+			return fmt.Errorf("%s: %v", p, ErrEntryRetained)
+		}
+	}
+
+	entry := cloneEntryExtended(oldEntry)
+	if mode == "" {
+		delete(entry.Extended, ExtObjectLockModeKey)
+		delete(entry.Extended, ExtObjectLockRetainUntilKey)
+	} else {
+		entry.Extended[ExtObjectLockModeKey] = []byte(mode)
+		entry.Extended[ExtObjectLockRetainUntilKey] = []byte(retainUntil.UTC().Format(time.RFC3339))
+	}
+
+	if err = f.Store.UpdateEntry(ctx, entry); err != nil {
+		return fmt.Errorf("update retention on %s: %v", p, err)
+	}
+	f.NotifyUpdateEvent(ctx, oldEntry, entry, false, false, nil)
+
+	glog.V(1).Infof("retention %s: %s until %v", p, mode, retainUntil)
+
+	return nil
+}
+
+// isObjectLockEnabled checks whether the path is in a bucket with object lock enabled
+func (f *Filer) isObjectLockEnabled(ctx context.Context, p util.FullPath) bool {
+	if f.DirBucketsPath == "" || !strings.HasPrefix(string(p), f.DirBucketsPath+"/") {
+		return false
+	}
+	bucket := strings.SplitN(strings.TrimPrefix(string(p), f.DirBucketsPath+"/"), "/", 2)[0]
+	bucketEntry, err := f.Store.FindEntry(ctx, util.NewFullPath(f.DirBucketsPath, bucket))
+	return err == nil && len(bucketEntry.Extended[ExtS3ObjectLockKey]) > 0
+}
+
+// stripObjectLock removes the object lock retention of the entry outside the buckets with object lock enabled
+func (f *Filer) stripObjectLock(ctx context.Context, entry *Entry) {
+	if entry == nil || len(entry.Extended[ExtObjectLockModeKey]) == 0 && len(entry.Extended[ExtObjectLockRetainUntilKey]) == 0 {
+		return
+	}
+	if f.isObjectLockEnabled(ctx, entry.FullPath) {
+		return
+	}
+	delete(entry.Extended, ExtObjectLockModeKey)
+	delete(entry.Extended, ExtObjectLockRetainUntilKey)
+}
+
+// hasObjectLockUnder checks whether the directory is in, or contains, a bucket with object lock enabled
+func (f *Filer) hasObjectLockUnder(ctx context.Context, dir util.FullPath) bool {
+	if f.DirBucketsPath == "" {
+		return false
+	}
+	bucketsPrefix := f.DirBucketsPath + "/"
+	if strings.HasPrefix(string(dir), bucketsPrefix) {
+		return f.isObjectLockEnabled(ctx, dir)
+	}
+	if !strings.HasPrefix(bucketsPrefix, strings.TrimSuffix(string(dir), "/")+"/") {
+		return false
+	}
+	found := false
+	lastFileName := ""
+	for !found {
+		entries, _, err := f.ListDirectoryEntries(ctx, util.FullPath(f.DirBucketsPath), lastFileName, false, PaginationSize, "", "")
+		if err != nil {
+			// check the files under it to be safe
+			return true
+		}
+		for _, entry := range entries {
+			lastFileName = entry.Name()
+			if len(entry.Extended[ExtS3ObjectLockKey]) > 0 {
+				found = true
+			}
+		}
+		if len(entries) < PaginationSize {
+			break
+		}
+	}
+	return found
+}
+
+// checkUpdateNotObjectLocked keeps the retention of the locked old entry, and only allows metadata updates to it
+func checkUpdateNotObjectLocked(oldEntry, newEntry *Entry) error {
+	if !isObjectLockRetained(oldEntry, false) {
+		return nil
+	}
+	if newEntry.Extended == nil {
+		newEntry.Extended = make(map[string][]byte)
+	}
+	for _, key := range []string{ExtObjectLockModeKey, ExtObjectLockRetainUntilKey} {
+		newEntry.Extended[key] = oldEntry.Extended[key]
+	}
+	if !bytes.Equal(oldEntry.Content, newEntry.Content) || !sameChunks(oldEntry.Chunks, newEntry.Chunks) || oldEntry.FileSize != newEntry.FileSize ||
+		newEntry.IsDirectory() || !bytes.Equal(oldEntry.HardLinkId, newEntry.HardLinkId) {
+		return fmt.Errorf("%s: %v", oldEntry.FullPath, ErrEntryRetained)
+	}
+	return nil
+}
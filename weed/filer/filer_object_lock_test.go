@@ -0,0 +1,121 @@
+package filer
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// bucketsStore only has the buckets, with object lock enabled on the bucket "b"
+type bucketsStore struct {
+	VirtualFilerStore
+}
+
+func (store *bucketsStore) FindEntry(ctx context.Context, p util.FullPath) (*Entry, error) {
+	switch p {
+	case "/buckets/b":
+		return &Entry{FullPath: p, Attr: Attr{Mode: os.ModeDir}, Extended: map[string][]byte{ExtS3ObjectLockKey: []byte("enabled")}}, nil
+	case "/buckets/c":
+		return &Entry{FullPath: p, Attr: Attr{Mode: os.ModeDir}}, nil
+	}
+	return nil, filer_pb.ErrNotFound
+}
+
+func newObjectLockFiler() *Filer {
+	return &Filer{Store: &bucketsStore{}, DirBucketsPath: "/buckets"}
+}
+
+func lockedEntry(mode string, retainUntil time.Time) *Entry {
+	return &Entry{
+		FullPath: "/buckets/b/a",
+		Chunks:   []*filer_pb.FileChunk{{FileId: "1,abc", Offset: 0, Size: 10}},
+		Extended: map[string][]byte{
+			ExtObjectLockModeKey:        []byte(mode),
+			ExtObjectLockRetainUntilKey: []byte(retainUntil.UTC().Format(time.RFC3339)),
+		},
+	}
+}
+
+func TestObjectLockRetained(t *testing.T) {
+
+	future := time.Now().Add(time.Hour)
+
+	assert.True(t, IsRetained(lockedEntry(ObjectLockCompliance, future)))
+	assert.True(t, IsRetained(lockedEntry(ObjectLockGovernance, future)))
+	assert.False(t, IsRetained(lockedEntry(ObjectLockGovernance, time.Now().Add(-time.Hour))))
+	assert.False(t, IsRetained(&Entry{FullPath: "/buckets/b/a"}))
+
+	// only the governance retention can be bypassed
+	assert.True(t, isObjectLockRetained(lockedEntry(ObjectLockCompliance, future), true))
+	assert.False(t, isObjectLockRetained(lockedEntry(ObjectLockGovernance, future), true))
+
+	// the multipart upload folders only keep the retention of the objects to complete
+	folder := lockedEntry(ObjectLockCompliance, future)
+	folder.Attr.Mode = os.ModeDir
+	assert.False(t, IsRetained(folder))
+
+	f := newObjectLockFiler()
+	ctx := context.Background()
+	assert.NotNil(t, f.CheckNotRetained(ctx, lockedEntry(ObjectLockGovernance, future)))
+	assert.Nil(t, f.CheckNotRetained(WithGovernanceBypass(ctx), lockedEntry(ObjectLockGovernance, future)))
+	assert.NotNil(t, f.CheckNotRetained(WithGovernanceBypass(ctx), lockedEntry(ObjectLockCompliance, future)))
+
+	// the retention is only honored in the buckets with object lock enabled
+	for _, p := range []util.FullPath{"/buckets/c/a", "/buckets/d/a", "/home/a"} {
+		entry := lockedEntry(ObjectLockCompliance, future)
+		entry.FullPath = p
+		assert.Nil(t, f.CheckNotRetained(ctx, entry), string(p))
+	}
+
+	// the retained entries are not expired by TTL
+	expiring := lockedEntry(ObjectLockCompliance, future)
+	expiring.TtlSec, expiring.Crtime = 1, time.Now().Add(-time.Hour)
+	assert.False(t, f.isTtlExpired(ctx, expiring))
+	expiring.FullPath = "/buckets/c/a"
+	assert.True(t, f.isTtlExpired(ctx, expiring))
+}
+
+func TestCheckUpdateNotObjectLocked(t *testing.T) {
+
+	f := newObjectLockFiler()
+	ctx := context.Background()
+	oldEntry := lockedEntry(ObjectLockCompliance, time.Now().Add(time.Hour))
+
+	// metadata only update, dropping the retention
+	newEntry := &Entry{
+		FullPath: "/buckets/b/a",
+		Chunks:   []*filer_pb.FileChunk{{FileId: "1,abc", Offset: 0, Size: 10}},
+		Extended: map[string][]byte{"Seaweed-x": []byte("y")},
+	}
+	assert.Nil(t, f.CheckUpdateAllowed(ctx, oldEntry, newEntry))
+	assert.Equal(t, oldEntry.Extended[ExtObjectLockModeKey], newEntry.Extended[ExtObjectLockModeKey])
+	assert.Equal(t, oldEntry.Extended[ExtObjectLockRetainUntilKey], newEntry.Extended[ExtObjectLockRetainUntilKey])
+
+	// overwrite content
+	assert.NotNil(t, f.CheckUpdateAllowed(ctx, oldEntry, &Entry{
+		FullPath: "/buckets/b/a",
+		Chunks:   []*filer_pb.FileChunk{{FileId: "2,def", Offset: 0, Size: 10}},
+	}))
+
+	// expired retention
+	expired := lockedEntry(ObjectLockCompliance, time.Now().Add(-time.Hour))
+	assert.Nil(t, f.CheckUpdateAllowed(ctx, expired, &Entry{
+		FullPath: "/buckets/b/a",
+		Chunks:   []*filer_pb.FileChunk{{FileId: "2,def", Offset: 0, Size: 10}},
+	}))
+
+	// the client supplied retention is removed outside the buckets with object lock enabled
+	outside := lockedEntry(ObjectLockCompliance, time.Now().Add(time.Hour))
+	outside.FullPath = "/buckets/c/a"
+	overwrite := lockedEntry(ObjectLockCompliance, time.Now().Add(time.Hour))
+	overwrite.FullPath, overwrite.Chunks = "/buckets/c/a", []*filer_pb.FileChunk{{FileId: "2,def", Offset: 0, Size: 10}}
+	assert.Nil(t, f.CheckUpdateAllowed(ctx, outside, overwrite))
+	assert.Empty(t, overwrite.Extended[ExtObjectLockModeKey])
+	assert.Empty(t, overwrite.Extended[ExtObjectLockRetainUntilKey])
+	assert.NotNil(t, f.SetObjectRetention(ctx, "/buckets/c/a", ObjectLockCompliance, time.Now().Add(time.Hour), false))
+}
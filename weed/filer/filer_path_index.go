@@ -0,0 +1,139 @@
+package filer
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+A path index lists the entries needing attention, e.g. the held entries or the cached remote entries,
+without walking the whole tree.
+
+Each indexed path is one entry in the index directory under /etc/seaweedfs/index, named by the escaped path,
+so the filers sharing the filer store add and remove the paths without overwriting each other's changes.
+The escaping keeps the path prefixes, so the indexed paths under a directory are found by a prefixed listing in the store.
+
+The index entries are written to the filer store directly, without metadata events, and are not cached by the store cache.
+*/
+
+const (
+	pathIndexDir       = DirectoryEtcSeaweedFS + "/index"
+	pathIndexListLimit = 1024
+)
+
+type pathIndex struct {
+	name      string
+	isDirMade int32
+}
+
+func (index *pathIndex) dir() util.FullPath {
+	return util.NewFullPath(pathIndexDir, index.name)
+}
+
+func isPathIndexPath(p util.FullPath) bool {
+	return string(p) == pathIndexDir || strings.HasPrefix(string(p), pathIndexDir+"/")
+}
+
+func (f *Filer) loadPathIndex(ctx context.Context, index *pathIndex) (paths []string, err error) {
+	err = f.listPathIndex(ctx, index, "", func(p string) bool {
+		paths = append(paths, p)
+		return true
+	})
+	return
+}
+
+// listPathIndex visits the indexed paths starting with the prefix, until eachPathFn returns false
+func (f *Filer) listPathIndex(ctx context.Context, index *pathIndex, prefix string, eachPathFn func(p string) bool) error {
+	namePrefix := url.PathEscape(prefix)
+	lastFileName := ""
+	for {
+		count := 0
+		isStopped := false
+		var unescapeErr error
+		_, err := f.Store.ListDirectoryPrefixedEntries(ctx, index.dir(), lastFileName, false, pathIndexListLimit, namePrefix, func(entry *Entry) bool {
+			count++
+			lastFileName = entry.Name()
+			p, err := url.PathUnescape(entry.Name())
+			if err != nil {
+				unescapeErr = fmt.Errorf("index entry %s: %v", entry.FullPath, err)
+				return false
+			}
+			if !eachPathFn(p) {
+				isStopped = true
+				return false
+			}
+			return true
+		})
+		if err == filer_pb.ErrNotFound {
+			return nil
+		}
+		if err == nil {
+			err = unescapeErr
+		}
+		if err != nil {
+			return fmt.Errorf("list %s: %v", index.dir(), err)
+		}
+		if isStopped || count < pathIndexListLimit {
+			return nil
+		}
+	}
+}
+
+func (f *Filer) updatePathIndex(ctx context.Context, index *pathIndex, p util.FullPath, isAdd bool) error {
+	indexPath := index.dir().Child(url.PathEscape(string(p)))
+	if !isAdd {
+		if err := f.Store.DeleteEntry(ctx, indexPath); err != nil && err != filer_pb.ErrNotFound {
+			return fmt.Errorf("remove %s from %s: %v", p, index.dir(), err)
+		}
+		return nil
+	}
+
+	if err := f.makePathIndexDir(ctx, index); err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := f.Store.InsertEntry(ctx, &Entry{
+		FullPath: indexPath,
+		Attr: Attr{
+			Mtime:  now,
+			Crtime: now,
+			Mode:   0644,
+		},
+	}); err != nil {
+		return fmt.Errorf("add %s to %s: %v", p, index.dir(), err)
+	}
+	return nil
+}
+
+// makePathIndexDir creates the index directory with its parents, so the store migration walking the tree copies the index
+func (f *Filer) makePathIndexDir(ctx context.Context, index *pathIndex) error {
+	if atomic.LoadInt32(&index.isDirMade) == 1 {
+		return nil
+	}
+	dir := index.dir()
+	if _, err := f.Store.FindEntry(ctx, dir); err == filer_pb.ErrNotFound {
+		now := time.Now()
+		if err = f.CreateEntry(ctx, &Entry{
+			FullPath: dir,
+			Attr: Attr{
+				Mtime:  now,
+				Crtime: now,
+				Mode:   os.ModeDir | 0755,
+			},
+		}, false, false, nil); err != nil {
+			return fmt.Errorf("create %s: %v", dir, err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("find %s: %v", dir, err)
+	}
+	atomic.StoreInt32(&index.isDirMade, 1)
+	return nil
+}
@@ -0,0 +1,257 @@
+package filer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/stats"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+A directory can have quotas of the total file size and the number of entries under it, recursively.
+The quotas are set by "quota_bytes" and "quota_entries" in filer.conf for the location prefix "/path/to/dir/",
+e.g. by "fs.quota". The nested quotas are all enforced.
+
+New entries and larger files are rejected with ErrQuotaExceeded when they would exceed a quota,
+and no more file ids are assigned under a directory using up its bytes quota.
+Moving the entries inside a directory is not limited by its quotas.
+
+The usage is counted by walking the directory tree in the background, and kept in memory,
+updated by the changes on this filer. The usage is counted again after dirQuotaUsageTtl,
+to pick up the changes from other filers. So the quotas are approximate.
+The writes are not limited until the usage is counted.
+*/
+
+const (
+	dirQuotaUsageTtl = time.Hour
+)
+
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+type DirQuotas struct {
+	usages map[string]*dirUsage
+	sync.Mutex
+}
+
+type dirUsage struct {
+	bytes      int64
+	entries    int64
+	countedAt  time.Time
+	isCounting bool
+}
+
+type DirQuotaUsage struct {
+	Directory    string
+	QuotaBytes   uint64
+	QuotaEntries uint64
+	UsedBytes    int64
+	UsedEntries  int64
+	IsCounted    bool
+}
+
+func NewDirQuotas() *DirQuotas {
+	return &DirQuotas{
+		usages: make(map[string]*dirUsage),
+	}
+}
+
+type movingFromKey struct{}
+
+// WithMovingFrom marks the writes in the context as moving the entry from the path,
+// so the quotas of the directories containing both the old and the new path are not checked.
+func WithMovingFrom(ctx context.Context, p util.FullPath) context.Context {
+	return context.WithValue(ctx, movingFromKey{}, p)
+}
+
+// matchQuotas returns the configured quotas of the directories containing the path
+func (f *Filer) matchQuotas(p util.FullPath) (quotas []*filer_pb.FilerConf_PathConf) {
+	if f.FilerConf == nil {
+		return nil
+	}
+	return f.FilerConf.MatchQuotas(string(p))
+}
+
+// CheckQuotas returns ErrQuotaExceeded if writing the entry over the old entry would exceed any quota.
+func (f *Filer) CheckQuotas(ctx context.Context, oldEntry, entry *Entry) error {
+	quotas := f.matchQuotas(entry.FullPath)
+	if len(quotas) == 0 {
+		return nil
+	}
+
+	addedBytes, addedEntries := int64(entry.Size()), int64(1)
+	if oldEntry != nil {
+		addedBytes -= int64(oldEntry.Size())
+		addedEntries = 0
+	}
+	if addedBytes <= 0 && addedEntries == 0 {
+		return nil
+	}
+	movingFrom, _ := ctx.Value(movingFromKey{}).(util.FullPath)
+
+	for _, quota := range quotas {
+		if movingFrom != "" && strings.HasPrefix(string(movingFrom), quota.LocationPrefix) {
+			continue
+		}
+		usage, isCounted := f.DirQuotas.usage(f, quota.LocationPrefix)
+		if !isCounted {
+			continue
+		}
+		if quota.QuotaEntries > 0 && addedEntries > 0 && usage.entries+addedEntries > int64(quota.QuotaEntries) {
+			return f.quotaExceeded(quota.LocationPrefix, "entries", usage.entries, quota.QuotaEntries)
+		}
+		if quota.QuotaBytes > 0 && addedBytes > 0 && usage.bytes+addedBytes > int64(quota.QuotaBytes) {
+			return f.quotaExceeded(quota.LocationPrefix, "bytes", usage.bytes, quota.QuotaBytes)
+		}
+	}
+	return nil
+}
+
+// CheckQuotasForWrite returns ErrQuotaExceeded if any directory containing the path has used up its bytes quota.
+func (f *Filer) CheckQuotasForWrite(p util.FullPath) error {
+	for _, quota := range f.matchQuotas(p) {
+		if quota.QuotaBytes == 0 {
+			continue
+		}
+		usage, isCounted := f.DirQuotas.usage(f, quota.LocationPrefix)
+		if isCounted && usage.bytes >= int64(quota.QuotaBytes) {
+			return f.quotaExceeded(quota.LocationPrefix, "bytes", usage.bytes, quota.QuotaBytes)
+		}
+	}
+	return nil
+}
+
+func (f *Filer) quotaExceeded(dir, unit string, used int64, quota uint64) error {
+	stats.FilerRequestCounter.WithLabelValues("create.quotaExceeded").Inc()
+	glog.V(1).Infof("directory %s used %d %s, reaching the quota %d", dir, used, unit, quota)
+	return fmt.Errorf("%s: %v, used %d of %d %s", dir, ErrQuotaExceeded, used, quota, unit)
+}
+
+// updateQuotaUsages adjusts the counted usages for the created, updated, deleted or moved entries.
+func (f *Filer) updateQuotaUsages(oldEntry, newEntry *Entry) {
+	if oldEntry != nil {
+		for _, quota := range f.matchQuotas(oldEntry.FullPath) {
+			f.DirQuotas.add(quota.LocationPrefix, -int64(oldEntry.Size()), -1)
+		}
+	}
+	if newEntry != nil {
+		for _, quota := range f.matchQuotas(newEntry.FullPath) {
+			f.DirQuotas.add(quota.LocationPrefix, int64(newEntry.Size()), 1)
+		}
+	}
+}
+
+// GetDirQuotaUsages returns the quotas and the usages of the directories under the directory, or of all directories if empty.
+func (f *Filer) GetDirQuotaUsages(dir string) (usages []*DirQuotaUsage) {
+	if f.FilerConf == nil {
+		return nil
+	}
+	prefix := ""
+	if dir != "" {
+		prefix = strings.TrimSuffix(dir, "/") + "/"
+	}
+	for _, quota := range f.FilerConf.Quotas() {
+		if !strings.HasPrefix(quota.LocationPrefix, prefix) {
+			continue
+		}
+		usage, isCounted := f.DirQuotas.usage(f, quota.LocationPrefix)
+		usages = append(usages, &DirQuotaUsage{
+			Directory:    quota.LocationPrefix,
+			QuotaBytes:   quota.QuotaBytes,
+			QuotaEntries: quota.QuotaEntries,
+			UsedBytes:    usage.bytes,
+			UsedEntries:  usage.entries,
+			IsCounted:    isCounted,
+		})
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		return usages[i].Directory < usages[j].Directory
+	})
+	return
+}
+
+// usage returns a copy of the usage of the quota directory, and starts counting it if not counted or expired.
+func (q *DirQuotas) usage(f *Filer, dir string) (usage dirUsage, isCounted bool) {
+	q.Lock()
+	defer q.Unlock()
+
+	u, found := q.usages[dir]
+	if !found {
+		u = &dirUsage{}
+		q.usages[dir] = u
+	}
+	isCounted = !u.countedAt.IsZero()
+	if !u.isCounting && (!isCounted || u.countedAt.Add(dirQuotaUsageTtl).Before(time.Now())) {
+		u.isCounting = true
+		go q.count(f, dir)
+	}
+	return *u, isCounted
+}
+
+func (q *DirQuotas) add(dir string, bytes, entries int64) {
+	q.Lock()
+	defer q.Unlock()
+	if u, found := q.usages[dir]; found {
+		u.bytes += bytes
+		u.entries += entries
+	}
+}
+
+// count walks the directory tree to count the usage of the quota directory
+func (q *DirQuotas) count(f *Filer, dir string) {
+	var bytes, entries int64
+	startTime := time.Now()
+	err := f.walkDirectoryTree(context.Background(), util.FullPath(strings.TrimSuffix(dir, "/")), func(entry *Entry) {
+		bytes += int64(entry.Size())
+		entries++
+	})
+
+	q.Lock()
+	defer q.Unlock()
+	u, found := q.usages[dir]
+	if !found {
+		return
+	}
+	u.isCounting = false
+	if err != nil {
+		glog.Warningf("count usage of %s: %v", dir, err)
+		return
+	}
+	u.bytes, u.entries, u.countedAt = bytes, entries, time.Now()
+	glog.V(1).Infof("counted usage of %s: %d bytes, %d entries in %v", dir, bytes, entries, time.Since(startTime))
+}
+
+func (f *Filer) walkDirectoryTree(ctx context.Context, dir util.FullPath, fn func(entry *Entry)) error {
+	lastFileName := ""
+	for {
+		var subDirs []util.FullPath
+		listed := 0
+		var err error
+		lastFileName, err = f.StreamListDirectoryEntries(ctx, dir, lastFileName, false, PaginationSize, "", "", func(entry *Entry) bool {
+			listed++
+			fn(entry)
+			if entry.IsDirectory() {
+				subDirs = append(subDirs, entry.FullPath)
+			}
+			return true
+		})
+		if err != nil && err != filer_pb.ErrNotFound {
+			return fmt.Errorf("list %s: %v", dir, err)
+		}
+		for _, subDir := range subDirs {
+			if err = f.walkDirectoryTree(ctx, subDir, fn); err != nil {
+				return err
+			}
+		}
+		if listed < PaginationSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package filer_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	leveldb2 "github.com/chrislusf/seaweedfs/weed/filer/leveldb2"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func TestDirQuotas(t *testing.T) {
+	testFiler := newTestFiler(t, &leveldb2.LevelDB2Store{})
+	testFiler.FilerConf.AddLocationConf(&filer_pb.FilerConf_PathConf{
+		LocationPrefix: "/q/",
+		QuotaBytes:     100,
+		QuotaEntries:   3,
+	})
+
+	ctx := context.Background()
+
+	create := func(p string, size int) error {
+		return testFiler.CreateEntry(ctx, &filer.Entry{
+			FullPath: util.FullPath(p),
+			Attr:     filer.Attr{Mode: 0644},
+			Content:  make([]byte, size),
+		}, false, false, nil)
+	}
+
+	if err := create("/q/a", 40); err != nil {
+		t.Fatalf("create entry: %v", err)
+	}
+
+	// the quotas are enforced after counting the usage
+	for i := 0; ; i++ {
+		usages := testFiler.GetDirQuotaUsages("")
+		if len(usages) != 1 {
+			t.Fatalf("quota usages: %+v", usages)
+		}
+		if usages[0].IsCounted {
+			if usages[0].UsedBytes != 40 || usages[0].UsedEntries != 1 {
+				t.Fatalf("counted usage: %+v", usages[0])
+			}
+			break
+		}
+		if i > 100 {
+			t.Fatalf("usage is not counted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := create("/q/b", 70); err == nil || !strings.Contains(err.Error(), filer.ErrQuotaExceeded.Error()) {
+		t.Errorf("expecting bytes quota exceeded, got %v", err)
+	}
+	if err := create("/q/b", 50); err != nil {
+		t.Errorf("create entry within quota: %v", err)
+	}
+	if err := create("/q/a", 60); err == nil || !strings.Contains(err.Error(), filer.ErrQuotaExceeded.Error()) {
+		t.Errorf("expecting bytes quota exceeded for the larger file, got %v", err)
+	}
+	if err := create("/q/c", 0); err != nil {
+		t.Errorf("create entry within quota: %v", err)
+	}
+	if err := create("/q/d", 0); err == nil || !strings.Contains(err.Error(), filer.ErrQuotaExceeded.Error()) {
+		t.Errorf("expecting entries quota exceeded, got %v", err)
+	}
+	if err := create("/other/d", 1000); err != nil {
+		t.Errorf("create entry outside the quota directory: %v", err)
+	}
+
+	if err := testFiler.DeleteEntryMetaAndData(ctx, "/q/a", false, false, false, false, nil); err != nil {
+		t.Fatalf("delete entry: %v", err)
+	}
+	if err := create("/q/d", 40); err != nil {
+		t.Errorf("create entry after delete: %v", err)
+	}
+	if err := testFiler.CheckQuotasForWrite("/q/e"); err != nil {
+		t.Errorf("assign file ids within quota: %v", err)
+	}
+	if err := create("/q/d", 50); err != nil {
+		t.Errorf("grow file within quota: %v", err)
+	}
+	if err := testFiler.CheckQuotasForWrite("/q/e"); err == nil {
+		t.Errorf("expecting no file ids assigned after using up the bytes quota")
+	}
+}
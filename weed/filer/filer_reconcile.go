@@ -0,0 +1,426 @@
+package filer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/chrislusf/seaweedfs/weed/stats"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+The reconciler slowly walks through all the filer entries, and verifies their chunks still exist
+on the volume servers with the expected sizes, so silent data loss, e.g. a lost volume or a wrongly
+deleted needle, is found before the files are read.
+
+Each chunk is checked with the VolumeNeedleStatus API on the volume servers of its volume.
+A chunk is broken only if none of the replicas has it. An unreachable volume server is counted
+as an error instead, and the file is checked again in the next round.
+The chunk size is only compared for the uncompressed and unencrypted chunks.
+
+A broken file is added to the quarantine report, which keeps the latest maxQuarantinedFiles files.
+Depending on the action, the file is also
+
+	mark      marked with the ExtQuarantineKey extended attribute, which is removed once the file is good again
+	truncate  truncated to the chunks before the first broken chunk, and marked
+
+The walk is throttled to the files per second, and each round starts at most once per reconcileRoundInterval.
+*/
+
+const (
+	ReconcileActionReport   = "report"
+	ReconcileActionMark     = "mark"
+	ReconcileActionTruncate = "truncate"
+	ExtQuarantineKey        = "x-seaweedfs-quarantine"
+	reconcileRoundInterval  = time.Hour
+	maxQuarantinedFiles     = 1000
+)
+
+type reconciler struct {
+	filer     *Filer
+	action    string
+	throttler *util.WriteThrottler
+
+	sync.RWMutex
+	status *filer_pb.ReconcileStatus
+}
+
+type brokenChunk struct {
+	chunk *filer_pb.FileChunk
+	err   error
+}
+
+// StartReconciling starts the background reconciler, checking at most filesPerSecond files.
+func (f *Filer) StartReconciling(filesPerSecond int64, action string) error {
+	switch action {
+	case ReconcileActionReport, ReconcileActionMark, ReconcileActionTruncate:
+	default:
+		return fmt.Errorf("unknown reconcile action %q, expecting %s, %s or %s", action, ReconcileActionReport, ReconcileActionMark, ReconcileActionTruncate)
+	}
+	f.reconciler = &reconciler{
+		filer:     f,
+		action:    action,
+		throttler: util.NewWriteThrottler(filesPerSecond),
+		status: &filer_pb.ReconcileStatus{
+			Action:         action,
+			FilesPerSecond: filesPerSecond,
+		},
+	}
+	go f.reconciler.loop()
+	return nil
+}
+
+// ReconcileStatus returns a snapshot of the reconcile progress, or nil if the reconciler is not started.
+func (f *Filer) ReconcileStatus() *filer_pb.ReconcileStatus {
+	if f.reconciler == nil {
+		return nil
+	}
+	f.reconciler.RLock()
+	defer f.reconciler.RUnlock()
+	return proto.Clone(f.reconciler.status).(*filer_pb.ReconcileStatus)
+}
+
+func (r *reconciler) update(fn func(status *filer_pb.ReconcileStatus)) {
+	r.Lock()
+	fn(r.status)
+	r.Unlock()
+}
+
+func (r *reconciler) failed(err error) {
+	glog.V(1).Infof("reconcile: %v", err)
+	stats.FilerReconcileCounter.WithLabelValues("error").Inc()
+	r.update(func(status *filer_pb.ReconcileStatus) {
+		status.ErrorCount++
+		status.LastError = err.Error()
+	})
+}
+
+func (r *reconciler) loop() {
+	// also wait for the master connection on start
+	time.Sleep(time.Minute)
+	r.filer.MasterClient.WaitUntilConnected()
+
+	for {
+		startedAt := time.Now()
+		r.update(func(status *filer_pb.ReconcileStatus) {
+			status.RoundCount++
+			status.StartedAtNs = startedAt.UnixNano()
+			status.ScannedFileCount = 0
+			status.ScannedChunkCount = 0
+			status.BrokenFileCount = 0
+			status.ErrorCount = 0
+		})
+
+		r.reconcileDirectory(context.Background(), "/")
+
+		status := r.filer.ReconcileStatus()
+		glog.V(0).Infof("reconcile round %d completed: %d files, %d chunks, %d broken, %d errors",
+			status.RoundCount, status.ScannedFileCount, status.ScannedChunkCount, status.BrokenFileCount, status.ErrorCount)
+		r.update(func(status *filer_pb.ReconcileStatus) {
+			status.CompletedAtNs = time.Now().UnixNano()
+			status.CurrentDirectory = ""
+		})
+
+		time.Sleep(time.Until(startedAt.Add(reconcileRoundInterval)))
+	}
+}
+
+func (r *reconciler) reconcileDirectory(ctx context.Context, dir util.FullPath) {
+
+	if dir == SystemLogDir {
+		return
+	}
+
+	lastFileName := ""
+	for {
+		r.update(func(status *filer_pb.ReconcileStatus) {
+			status.CurrentDirectory = string(dir)
+		})
+		entries, _, err := r.filer.ListDirectoryEntries(ctx, dir, lastFileName, false, PaginationSize, "", "")
+		if err != nil {
+			r.failed(fmt.Errorf("list %s: %v", dir, err))
+			return
+		}
+		for _, entry := range entries {
+			lastFileName = entry.Name()
+			if entry.IsDirectory() {
+				r.reconcileDirectory(ctx, entry.FullPath)
+				continue
+			}
+			r.reconcileEntry(ctx, entry)
+			r.throttler.MaybeSlowdown(1)
+		}
+		if len(entries) < PaginationSize {
+			break
+		}
+	}
+}
+
+func (r *reconciler) reconcileEntry(ctx context.Context, entry *Entry) {
+
+	if RemoteEntryOf(entry) != nil {
+		// the chunks only cache the remote object, which is the source of truth
+		return
+	}
+
+	v := &chunkVerification{}
+	r.verifyChunks(entry.Chunks, v)
+	broken, err := v.broken, v.err
+	stats.FilerReconcileCounter.WithLabelValues("file").Inc()
+	r.update(func(status *filer_pb.ReconcileStatus) {
+		status.ScannedFileCount++
+		status.ScannedChunkCount += int64(v.chunkCount)
+	})
+	if err != nil {
+		r.failed(fmt.Errorf("%s: %v", entry.FullPath, err))
+	}
+
+	if len(broken) == 0 {
+		if err == nil && len(entry.Extended[ExtQuarantineKey]) > 0 {
+			r.unmark(ctx, entry)
+		}
+		return
+	}
+
+	sort.Slice(broken, func(i, j int) bool {
+		return broken[i].chunk.Offset < broken[j].chunk.Offset
+	})
+	first := broken[0]
+	glog.Warningf("reconcile %s: %d broken chunks, first %s at offset %d: %v", entry.FullPath, len(broken), first.chunk.GetFileIdString(), first.chunk.Offset, first.err)
+	stats.FilerReconcileCounter.WithLabelValues("broken").Inc()
+
+	action := ReconcileActionReport
+	var actionErr error
+	switch {
+	case r.action == ReconcileActionTruncate && err == nil:
+		// only truncate if all the chunks are resolved
+		action, actionErr = ReconcileActionTruncate, r.truncate(ctx, entry, v, first)
+	case r.action == ReconcileActionMark || r.action == ReconcileActionTruncate:
+		action, actionErr = ReconcileActionMark, r.mark(ctx, entry, first)
+	}
+	if actionErr != nil {
+		r.failed(fmt.Errorf("%s %s: %v", action, entry.FullPath, actionErr))
+		action = ReconcileActionReport
+	}
+
+	r.update(func(status *filer_pb.ReconcileStatus) {
+		status.BrokenFileCount++
+		status.QuarantinedFiles = addQuarantinedFile(status.QuarantinedFiles, &filer_pb.ReconcileStatus_QuarantinedFile{
+			Path:         string(entry.FullPath),
+			FileId:       first.chunk.GetFileIdString(),
+			Offset:       first.chunk.Offset,
+			Error:        first.err.Error(),
+			Action:       action,
+			DetectedAtNs: time.Now().UnixNano(),
+		})
+	})
+}
+
+// addQuarantinedFile replaces the earlier report of the same file, and drops the oldest reports over maxQuarantinedFiles.
+func addQuarantinedFile(files []*filer_pb.ReconcileStatus_QuarantinedFile, file *filer_pb.ReconcileStatus_QuarantinedFile) []*filer_pb.ReconcileStatus_QuarantinedFile {
+	for i, f := range files {
+		if f.Path == file.Path {
+			files = append(files[:i], files[i+1:]...)
+			break
+		}
+	}
+	files = append(files, file)
+	if len(files) > maxQuarantinedFiles {
+		files = files[len(files)-maxQuarantinedFiles:]
+	}
+	return files
+}
+
+// chunkVerification is the result of checking the chunks of one entry
+type chunkVerification struct {
+	dataChunks     []*filer_pb.FileChunk
+	manifestChunks []*filer_pb.FileChunk
+	broken         []*brokenChunk
+	chunkCount     int
+	err            error
+}
+
+// verifyChunks checks the chunks, and the chunks resolved from the manifest chunks.
+func (r *reconciler) verifyChunks(chunks []*filer_pb.FileChunk, v *chunkVerification) {
+	for _, chunk := range chunks {
+		v.chunkCount++
+		brokenErr, checkErr := r.checkChunk(chunk)
+		if checkErr != nil {
+			v.err = checkErr
+			continue
+		}
+		if brokenErr != nil {
+			v.broken = append(v.broken, &brokenChunk{chunk: chunk, err: brokenErr})
+			continue
+		}
+		if !chunk.IsChunkManifest {
+			v.dataChunks = append(v.dataChunks, chunk)
+			continue
+		}
+		v.manifestChunks = append(v.manifestChunks, chunk)
+		resolvedChunks, resolveErr := ResolveOneChunkManifest(r.filer.MasterClient.LookupFileId, chunk)
+		if resolveErr != nil {
+			// the manifest chunk exists, but can not be read now
+			v.err = fmt.Errorf("resolve manifest %s: %v", chunk.GetFileIdString(), resolveErr)
+			continue
+		}
+		r.verifyChunks(resolvedChunks, v)
+	}
+}
+
+// checkChunk returns brokenErr if no replica has the chunk, or err if it can not be checked now.
+func (r *reconciler) checkChunk(chunk *filer_pb.FileChunk) (brokenErr error, err error) {
+
+	fid, parseErr := needle.ParseFileIdFromString(chunk.GetFileIdString())
+	if parseErr != nil {
+		return parseErr, nil
+	}
+	locations, found := r.filer.MasterClient.GetLocations(uint32(fid.VolumeId))
+	if !found || len(locations) == 0 {
+		return fmt.Errorf("volume %d not found", fid.VolumeId), nil
+	}
+
+	for _, loc := range locations {
+		var resp *volume_server_pb.VolumeNeedleStatusResponse
+		statusErr := operation.WithVolumeServerClient(loc.Url, r.filer.GrpcDialOption, func(client volume_server_pb.VolumeServerClient) (err error) {
+			resp, err = client.VolumeNeedleStatus(context.Background(), &volume_server_pb.VolumeNeedleStatusRequest{
+				VolumeId: uint32(fid.VolumeId),
+				NeedleId: uint64(fid.Key),
+			})
+			return err
+		})
+		if statusErr != nil {
+			if isNeedleMissing(statusErr) {
+				brokenErr = fmt.Errorf("%s on %s", statusErr, loc.Url)
+			} else {
+				err = fmt.Errorf("check %s on %s: %v", chunk.GetFileIdString(), loc.Url, statusErr)
+			}
+			continue
+		}
+		if brokenErr = checkNeedleStatus(chunk, fid, resp); brokenErr == nil {
+			return nil, nil
+		}
+		brokenErr = fmt.Errorf("%v on %s", brokenErr, loc.Url)
+	}
+
+	if err != nil {
+		// some replicas may still have the chunk
+		return nil, err
+	}
+	return brokenErr, nil
+}
+
+func isNeedleMissing(err error) bool {
+	return strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "already deleted")
+}
+
+// checkNeedleStatus verifies the needle is the chunk, with the chunk size if it can be compared.
+func checkNeedleStatus(chunk *filer_pb.FileChunk, fid *needle.FileId, resp *volume_server_pb.VolumeNeedleStatusResponse) error {
+	if resp.Cookie != uint32(fid.Cookie) {
+		return fmt.Errorf("needle %s cookie %x mismatch", chunk.GetFileIdString(), resp.Cookie)
+	}
+	if chunk.IsChunkManifest || chunk.IsCompressed || len(chunk.CipherKey) > 0 || chunk.Codec != "" || resp.IsCompressed {
+		// the chunk size is the size before compression, encryption or encoding
+		return nil
+	}
+	if uint64(resp.DataSize) != chunk.Size {
+		return fmt.Errorf("needle %s size %d, expected %d", chunk.GetFileIdString(), resp.DataSize, chunk.Size)
+	}
+	return nil
+}
+
+func (r *reconciler) mark(ctx context.Context, entry *Entry, first *brokenChunk) error {
+	reason := fmt.Sprintf("chunk %s at offset %d: %v", first.chunk.GetFileIdString(), first.chunk.Offset, first.err)
+	if string(entry.Extended[ExtQuarantineKey]) == reason {
+		return nil
+	}
+	_, err := r.updateEntry(ctx, entry, func(marked *Entry) {
+		marked.Extended[ExtQuarantineKey] = []byte(reason)
+	})
+	return err
+}
+
+func (r *reconciler) unmark(ctx context.Context, entry *Entry) {
+	updated, err := r.updateEntry(ctx, entry, func(unmarked *Entry) {
+		delete(unmarked.Extended, ExtQuarantineKey)
+	})
+	if err != nil {
+		r.failed(fmt.Errorf("unmark %s: %v", entry.FullPath, err))
+		return
+	}
+	if updated {
+		glog.V(0).Infof("reconcile %s: all chunks are good again", entry.FullPath)
+	}
+}
+
+// truncate keeps the data chunks before the first broken chunk, and deletes the other chunks.
+func (r *reconciler) truncate(ctx context.Context, entry *Entry, v *chunkVerification, first *brokenChunk) error {
+	if len(entry.HardLinkId) > 0 {
+		return fmt.Errorf("can not truncate hard link")
+	}
+	kept, dropped := truncateChunks(v.dataChunks, first.chunk.Offset)
+
+	var fileSize uint64
+	updated, err := r.updateEntry(ctx, entry, func(truncated *Entry) {
+		truncated.Chunks = kept
+		truncated.Attr.FileSize = TotalSize(kept)
+		truncated.Attr.Md5 = nil
+		truncated.Extended[ExtQuarantineKey] = []byte(fmt.Sprintf("truncated to %d bytes, chunk %s at offset %d: %v",
+			truncated.Attr.FileSize, first.chunk.GetFileIdString(), first.chunk.Offset, first.err))
+		fileSize = truncated.Attr.FileSize
+	})
+	if err != nil || !updated {
+		return err
+	}
+
+	// the kept data chunks are not in the manifest chunks any more
+	r.filer.DeleteChunks(append(dropped, v.manifestChunks...))
+	glog.V(0).Infof("reconcile %s: truncated to %d bytes", entry.FullPath, fileSize)
+	return nil
+}
+
+// truncateChunks keeps the chunks ending before the offset of the broken chunk.
+func truncateChunks(chunks []*filer_pb.FileChunk, brokenOffset int64) (kept, dropped []*filer_pb.FileChunk) {
+	for _, chunk := range chunks {
+		if chunk.Offset+int64(chunk.Size) <= brokenOffset {
+			kept = append(kept, chunk)
+		} else {
+			dropped = append(dropped, chunk)
+		}
+	}
+	return
+}
+
+// updateEntry applies the change to the entry read again from the store,
+// and skips it if the entry is deleted, or its chunks are changed since it is verified.
+func (r *reconciler) updateEntry(ctx context.Context, entry *Entry, change func(updated *Entry)) (updated bool, err error) {
+	current, err := r.filer.Store.FindEntry(ctx, entry.FullPath)
+	if err == filer_pb.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !sameChunks(current.Chunks, entry.Chunks) {
+		glog.V(1).Infof("reconcile %s: changed since verified", entry.FullPath)
+		return false, nil
+	}
+	changed := cloneEntryExtended(current)
+	change(changed)
+	if err = r.filer.UpdateEntry(ctx, current, changed); err != nil {
+		return false, err
+	}
+	r.filer.NotifyUpdateEvent(ctx, current, changed, false, false, nil)
+	return true, nil
+}
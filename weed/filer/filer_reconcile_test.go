@@ -0,0 +1,69 @@
+package filer
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+)
+
+func TestCheckNeedleStatus(t *testing.T) {
+
+	chunk := &filer_pb.FileChunk{FileId: "3,01637037d6", Size: 100}
+	fid, err := needle.ParseFileIdFromString(chunk.FileId)
+	assert.Nil(t, err)
+
+	assert.Nil(t, checkNeedleStatus(chunk, fid, &volume_server_pb.VolumeNeedleStatusResponse{Cookie: uint32(fid.Cookie), DataSize: 100}))
+	assert.NotNil(t, checkNeedleStatus(chunk, fid, &volume_server_pb.VolumeNeedleStatusResponse{Cookie: uint32(fid.Cookie) + 1, DataSize: 100}), "overwritten needle")
+	assert.NotNil(t, checkNeedleStatus(chunk, fid, &volume_server_pb.VolumeNeedleStatusResponse{Cookie: uint32(fid.Cookie), DataSize: 99}), "wrong size")
+
+	// the stored size is not comparable
+	assert.Nil(t, checkNeedleStatus(chunk, fid, &volume_server_pb.VolumeNeedleStatusResponse{Cookie: uint32(fid.Cookie), DataSize: 60, IsCompressed: true}))
+	encrypted := &filer_pb.FileChunk{FileId: chunk.FileId, Size: 100, CipherKey: []byte("key")}
+	assert.Nil(t, checkNeedleStatus(encrypted, fid, &volume_server_pb.VolumeNeedleStatusResponse{Cookie: uint32(fid.Cookie), DataSize: 128}))
+	encoded := &filer_pb.FileChunk{FileId: chunk.FileId, Size: 100, Codec: "zstd"}
+	assert.Nil(t, checkNeedleStatus(encoded, fid, &volume_server_pb.VolumeNeedleStatusResponse{Cookie: uint32(fid.Cookie), DataSize: 40}))
+
+	assert.True(t, isNeedleMissing(fmt.Errorf("rpc error: code = Unknown desc = not found")))
+	assert.True(t, isNeedleMissing(fmt.Errorf("rpc error: code = Unknown desc = already deleted")))
+	assert.False(t, isNeedleMissing(fmt.Errorf("rpc error: code = Unavailable desc = connection refused")))
+}
+
+func TestTruncateChunks(t *testing.T) {
+
+	chunks := []*filer_pb.FileChunk{
+		{FileId: "1,01", Offset: 0, Size: 100},
+		{FileId: "1,02", Offset: 100, Size: 100},
+		{FileId: "1,03", Offset: 150, Size: 100},
+		{FileId: "1,04", Offset: 300, Size: 100},
+	}
+
+	kept, dropped := truncateChunks(chunks, 200)
+	assert.Equal(t, 2, len(kept))
+	assert.Equal(t, uint64(200), TotalSize(kept))
+	assert.Equal(t, []*filer_pb.FileChunk{chunks[2], chunks[3]}, dropped)
+
+	kept, dropped = truncateChunks(chunks, 0)
+	assert.Equal(t, 0, len(kept))
+	assert.Equal(t, 4, len(dropped))
+}
+
+func TestAddQuarantinedFile(t *testing.T) {
+
+	var files []*filer_pb.ReconcileStatus_QuarantinedFile
+	for i := 0; i < maxQuarantinedFiles+10; i++ {
+		files = addQuarantinedFile(files, &filer_pb.ReconcileStatus_QuarantinedFile{Path: fmt.Sprintf("/dir/file%d", i)})
+	}
+	assert.Equal(t, maxQuarantinedFiles, len(files))
+	assert.Equal(t, "/dir/file10", files[0].Path)
+
+	// reported again
+	files = addQuarantinedFile(files, &filer_pb.ReconcileStatus_QuarantinedFile{Path: "/dir/file10", Action: ReconcileActionMark})
+	assert.Equal(t, maxQuarantinedFiles, len(files))
+	assert.Equal(t, "/dir/file11", files[0].Path)
+	assert.Equal(t, ReconcileActionMark, files[len(files)-1].Action)
+}
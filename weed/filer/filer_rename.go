@@ -1,9 +1,14 @@
 package filer
 
 import (
+	"context"
 	"fmt"
-	"github.com/chrislusf/seaweedfs/weed/util"
 	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/golang/protobuf/proto"
 )
 
 func (f *Filer) CanRename(source, target util.FullPath) error {
@@ -28,3 +33,89 @@ func (f *Filer) DetectBucket(source util.FullPath) (bucket string) {
 	}
 	return bucket
 }
+
+/*
+A directory can be renamed in the filer store, with all the entries under it, if the store implements DirectoryRenamer.
+It takes one atomic store operation instead of moving every entry. The rename events are still sent for the directory
+and every entry under it, parents first, so the metadata subscribers, e.g. "weed mount", "weed filer.sync",
+"weed filer.meta.backup" and the search indexer, see each entry moved, the same as moving the entries one by one.
+
+The entries are still moved one by one if the filer.conf options or the quotas differ between the old and
+the new path, or any filer.conf location, path specific store, or remote storage mount is under them,
+since the new entries would need the options of the new path.
+*/
+
+// MoveDirectoryInStore renames the directory in the filer store to the new path, which should not exist.
+// It returns ErrUnsupportedDirectoryRename to fall back to moving the entries one by one.
+func (f *Filer) MoveDirectoryInStore(ctx context.Context, entry *Entry, newPath util.FullPath, signatures []int32) error {
+	renamer, ok := f.Store.(DirectoryRenamer)
+	if !ok || !entry.IsDirectory() || entry.FullPath == "/" || strings.HasPrefix(string(newPath), string(entry.FullPath)+"/") {
+		return ErrUnsupportedDirectoryRename
+	}
+	if !f.isSameDirectoryOptions(entry.FullPath, newPath) {
+		return ErrUnsupportedDirectoryRename
+	}
+	if _, err := f.FindEntry(ctx, newPath); err == nil {
+		return ErrUnsupportedDirectoryRename
+	} else if err != filer_pb.ErrNotFound {
+		return err
+	}
+
+	if err := f.checkDirEntriesLimit(ctx, newPath); err != nil {
+		return err
+	}
+	newEntry := *entry
+	newEntry.FullPath = newPath
+	dirParts := strings.Split(string(newPath), "/")
+	if err := f.ensureParentDirecotryEntry(ctx, &newEntry, dirParts, len(dirParts)-1, false); err != nil {
+		return err
+	}
+
+	if err := renamer.RenameDirectory(ctx, entry.FullPath, newPath); err != nil {
+		return err
+	}
+	glog.V(1).Infof("renamed directory %s => %s in the filer store", entry.FullPath, newPath)
+
+	f.NotifyUpdateEvent(ctx, entry, &newEntry, false, false, signatures)
+	if err := f.walkDirectoryTree(ctx, newPath, func(sub *Entry) {
+		oldSub := *sub
+		oldSub.FullPath = entry.FullPath + sub.FullPath[len(newPath):]
+		f.NotifyUpdateEvent(ctx, &oldSub, sub, false, false, signatures)
+	}); err != nil {
+		// already renamed in the store
+		glog.Errorf("notify renaming the entries under %s => %s: %v", entry.FullPath, newPath, err)
+	}
+	return nil
+}
+
+func (f *Filer) isSameDirectoryOptions(oldPath, newPath util.FullPath) bool {
+	if f.FilerConf != nil {
+		if f.FilerConf.HasLocationUnder(string(oldPath)) || f.FilerConf.HasLocationUnder(string(newPath)) {
+			return false
+		}
+		if !proto.Equal(f.FilerConf.MatchStorageRule(string(oldPath)+"/"), f.FilerConf.MatchStorageRule(string(newPath)+"/")) {
+			return false
+		}
+		oldQuotas, newQuotas := f.matchQuotas(oldPath), f.matchQuotas(newPath)
+		if len(oldQuotas) != len(newQuotas) {
+			return false
+		}
+		for i := range oldQuotas {
+			if oldQuotas[i].LocationPrefix != newQuotas[i].LocationPrefix {
+				return false
+			}
+		}
+	}
+	if f.RemoteStorage != nil {
+		if mount, _ := f.RemoteStorage.FindMount(oldPath); mount != nil {
+			return false
+		}
+		if mount, _ := f.RemoteStorage.FindMount(newPath); mount != nil {
+			return false
+		}
+		if f.RemoteStorage.hasMountUnder(oldPath) || f.RemoteStorage.hasMountUnder(newPath) {
+			return false
+		}
+	}
+	return true
+}
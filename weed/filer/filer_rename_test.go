@@ -0,0 +1,51 @@
+package filer_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/filer/leveldb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func TestMoveDirectoryInStore(t *testing.T) {
+	testFiler := newTestFiler(t, &leveldb.LevelDBStore{})
+
+	ctx := context.Background()
+
+	for _, p := range []string{"/a/b/c/file1", "/a/b/file2", "/a/bc/file3"} {
+		if err := testFiler.CreateEntry(ctx, &filer.Entry{
+			FullPath: util.FullPath(p),
+			Attr:     filer.Attr{Mode: 0644},
+		}, false, false, nil); err != nil {
+			t.Fatalf("create entry %s: %v", p, err)
+		}
+	}
+
+	entry, _ := testFiler.FindEntry(ctx, "/a/b")
+	if err := testFiler.MoveDirectoryInStore(ctx, entry, "/x/y", nil); err != nil {
+		t.Fatalf("move directory: %v", err)
+	}
+
+	for _, p := range []string{"/x/y", "/x/y/c", "/x/y/c/file1", "/x/y/file2", "/a/bc/file3"} {
+		if _, err := testFiler.FindEntry(ctx, util.FullPath(p)); err != nil {
+			t.Errorf("find %s after moving: %v", p, err)
+		}
+	}
+	for _, p := range []string{"/a/b", "/a/b/c/file1", "/a/b/file2"} {
+		if _, err := testFiler.FindEntry(ctx, util.FullPath(p)); err == nil {
+			t.Errorf("found %s after moving", p)
+		}
+	}
+	entries, _, _ := testFiler.ListDirectoryEntries(ctx, "/x/y", "", false, 100, "", "")
+	if len(entries) != 2 {
+		t.Errorf("list moved directory: %d entries", len(entries))
+	}
+
+	// the existing target is moved one by one
+	entry, _ = testFiler.FindEntry(ctx, "/a/bc")
+	if err := testFiler.MoveDirectoryInStore(ctx, entry, "/x/y/c", nil); err != filer.ErrUnsupportedDirectoryRename {
+		t.Errorf("move to existing directory: %v", err)
+	}
+}
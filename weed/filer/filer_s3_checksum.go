@@ -0,0 +1,210 @@
+package filer
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"net/http"
+	"strings"
+)
+
+/*
+The s3 additional checksums of the objects, besides the md5 etag and the sha256 checksum of the whole content.
+
+The checksum requested with one "x-amz-checksum-{algorithm}" header is verified while uploading
+through the filer http api, and kept in the entry extended attributes with the same header name,
+as the base64 encoded value. The upload fails if the content does not match.
+The checksum can also be sent in the http trailer, declared by the "x-amz-trailer" header.
+
+The checksum of an object assembled from s3 multipart uploads is the checksum of the concatenated
+part checksums, followed by "-" and the number of the parts, which is also kept in the extended attributes.
+*/
+
+const (
+	S3ChecksumCRC32  = "CRC32"
+	S3ChecksumCRC32C = "CRC32C"
+	S3ChecksumSHA1   = "SHA1"
+	S3ChecksumSHA256 = "SHA256"
+
+	s3ChecksumHeaderPrefix  = "X-Amz-Checksum-"
+	s3ChecksumTrailerHeader = "X-Amz-Trailer"
+
+	ExtS3PartsCountKey = "x-seaweedfs-s3-parts-count"
+)
+
+var (
+	S3ChecksumAlgorithms = []string{S3ChecksumCRC32, S3ChecksumCRC32C, S3ChecksumSHA1, S3ChecksumSHA256}
+
+	ErrS3ChecksumMismatch = errors.New("the checksum does not match the content")
+	ErrInvalidS3Checksum  = errors.New("invalid checksum")
+)
+
+// S3Checksum is the checksum of the content, with one of the S3ChecksumAlgorithms
+type S3Checksum struct {
+	Algorithm string
+	Value     []byte
+}
+
+// S3ChecksumHeader is the header name of the algorithm, also the key in the entry extended attributes
+func S3ChecksumHeader(algorithm string) string {
+	return http.CanonicalHeaderKey(s3ChecksumHeaderPrefix + algorithm)
+}
+
+// NewS3ChecksumHash returns the hash of the algorithm, or nil if not supported
+func NewS3ChecksumHash(algorithm string) hash.Hash {
+	switch strings.ToUpper(algorithm) {
+	case S3ChecksumCRC32:
+		return crc32.NewIEEE()
+	case S3ChecksumCRC32C:
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	case S3ChecksumSHA1:
+		return sha1.New()
+	case S3ChecksumSHA256:
+		return sha256.New()
+	}
+	return nil
+}
+
+// IsS3ChecksumAlgorithm checks whether the algorithm is supported
+func IsS3ChecksumAlgorithm(algorithm string) bool {
+	return NewS3ChecksumHash(algorithm) != nil
+}
+
+// String is the base64 encoded value, as in the headers
+func (c *S3Checksum) String() string {
+	return base64.StdEncoding.EncodeToString(c.Value)
+}
+
+// RequestedS3Checksum parses the checksum header of the upload request, nil if not requested.
+func RequestedS3Checksum(header http.Header) (*S3Checksum, error) {
+	var checksum *S3Checksum
+	for _, algorithm := range S3ChecksumAlgorithms {
+		value := header.Get(S3ChecksumHeader(algorithm))
+		if value == "" {
+			continue
+		}
+		if checksum != nil {
+			return nil, fmt.Errorf("%v: more than one checksum header", ErrInvalidS3Checksum)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(decoded) != NewS3ChecksumHash(algorithm).Size() {
+			return nil, fmt.Errorf("%v: %s %s", ErrInvalidS3Checksum, S3ChecksumHeader(algorithm), value)
+		}
+		checksum = &S3Checksum{Algorithm: algorithm, Value: decoded}
+	}
+	return checksum, nil
+}
+
+// S3ChecksumTrailerAlgorithm is the algorithm of the checksum declared to be in the trailer, empty if none.
+func S3ChecksumTrailerAlgorithm(header http.Header) string {
+	name := http.CanonicalHeaderKey(strings.TrimSpace(header.Get(s3ChecksumTrailerHeader)))
+	for _, algorithm := range S3ChecksumAlgorithms {
+		if name == S3ChecksumHeader(algorithm) {
+			return algorithm
+		}
+	}
+	return ""
+}
+
+// S3ChecksumVerifier computes the checksum of the content written to it, and compares with the requested checksum.
+// Without the requested checksum, the content is discarded.
+type S3ChecksumVerifier struct {
+	Requested        *S3Checksum
+	hash             hash.Hash
+	trailerAlgorithm string
+}
+
+func NewS3ChecksumVerifier(header http.Header) (*S3ChecksumVerifier, error) {
+	requested, err := RequestedS3Checksum(header)
+	if err != nil {
+		return nil, err
+	}
+	v := &S3ChecksumVerifier{Requested: requested}
+	if requested != nil {
+		v.hash = NewS3ChecksumHash(requested.Algorithm)
+	} else if v.trailerAlgorithm = S3ChecksumTrailerAlgorithm(header); v.trailerAlgorithm != "" {
+		v.hash = NewS3ChecksumHash(v.trailerAlgorithm)
+	}
+	return v, nil
+}
+
+// SetTrailer takes the checksum declared to be in the trailer, after the content is read.
+func (v *S3ChecksumVerifier) SetTrailer(trailer http.Header) error {
+	if v.trailerAlgorithm == "" {
+		return nil
+	}
+	requested, err := RequestedS3Checksum(trailer)
+	if err != nil {
+		return err
+	}
+	if requested == nil || requested.Algorithm != v.trailerAlgorithm {
+		return fmt.Errorf("%v: missing %s in the trailer", ErrInvalidS3Checksum, S3ChecksumHeader(v.trailerAlgorithm))
+	}
+	v.Requested = requested
+	return nil
+}
+
+func (v *S3ChecksumVerifier) Write(p []byte) (int, error) {
+	if v.hash == nil {
+		return len(p), nil
+	}
+	return v.hash.Write(p)
+}
+
+// Verify compares the checksum of the written content with the requested checksum
+func (v *S3ChecksumVerifier) Verify() error {
+	if v.Requested == nil {
+		return nil
+	}
+	if computed := v.hash.Sum(nil); !bytes.Equal(computed, v.Requested.Value) {
+		return fmt.Errorf("%v: %s %s, computed %s", ErrS3ChecksumMismatch, S3ChecksumHeader(v.Requested.Algorithm),
+			v.Requested.String(), base64.StdEncoding.EncodeToString(computed))
+	}
+	return nil
+}
+
+// SaveTo keeps the verified checksum in the entry extended attributes
+func (v *S3ChecksumVerifier) SaveTo(extended map[string][]byte) {
+	ClearS3Checksums(extended)
+	if v.Requested != nil {
+		extended[S3ChecksumHeader(v.Requested.Algorithm)] = []byte(v.Requested.String())
+	}
+}
+
+// ClearS3Checksums removes the checksums, when the content is changed
+func ClearS3Checksums(extended map[string][]byte) {
+	for _, algorithm := range S3ChecksumAlgorithms {
+		delete(extended, S3ChecksumHeader(algorithm))
+	}
+}
+
+// StoredS3Checksum returns the algorithm and the base64 encoded checksum kept in the entry, empty if not known
+func StoredS3Checksum(extended map[string][]byte) (algorithm, checksum string) {
+	for _, algorithm := range S3ChecksumAlgorithms {
+		if value, found := extended[S3ChecksumHeader(algorithm)]; found {
+			return algorithm, string(value)
+		}
+	}
+	return "", ""
+}
+
+// CompositeS3Checksum is the checksum of a multipart object, from the base64 encoded checksums of the parts in order
+func CompositeS3Checksum(algorithm string, partChecksums []string) (string, error) {
+	h := NewS3ChecksumHash(algorithm)
+	if h == nil {
+		return "", fmt.Errorf("%v: algorithm %s", ErrInvalidS3Checksum, algorithm)
+	}
+	for i, partChecksum := range partChecksums {
+		decoded, err := base64.StdEncoding.DecodeString(partChecksum)
+		if err != nil || len(decoded) != h.Size() {
+			return "", fmt.Errorf("%v: part %d %s", ErrInvalidS3Checksum, i+1, partChecksum)
+		}
+		h.Write(decoded)
+	}
+	return fmt.Sprintf("%s-%d", base64.StdEncoding.EncodeToString(h.Sum(nil)), len(partChecksums)), nil
+}
@@ -0,0 +1,111 @@
+package filer
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash/crc32"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestedS3Checksum(t *testing.T) {
+	content := "some content"
+	sum := sha256.Sum256([]byte(content))
+
+	header := http.Header{}
+	checksum, err := RequestedS3Checksum(header)
+	assert.NoError(t, err)
+	assert.Nil(t, checksum)
+
+	header.Set("x-amz-checksum-sha256", base64.StdEncoding.EncodeToString(sum[:]))
+	checksum, err = RequestedS3Checksum(header)
+	if assert.NoError(t, err) && assert.NotNil(t, checksum) {
+		assert.Equal(t, S3ChecksumSHA256, checksum.Algorithm)
+		assert.Equal(t, sum[:], checksum.Value)
+	}
+
+	header.Set("x-amz-checksum-crc32", "AAAAAA==")
+	_, err = RequestedS3Checksum(header)
+	assert.Error(t, err)
+
+	// the length of the checksum does not match the algorithm
+	_, err = RequestedS3Checksum(http.Header{"X-Amz-Checksum-Crc32c": []string{base64.StdEncoding.EncodeToString(sum[:])}})
+	assert.Error(t, err)
+	_, err = RequestedS3Checksum(http.Header{"X-Amz-Checksum-Sha1": []string{"not base64"}})
+	assert.True(t, err != nil && strings.Contains(err.Error(), ErrInvalidS3Checksum.Error()))
+}
+
+func TestS3ChecksumVerifier(t *testing.T) {
+	crc := crc32.Checksum([]byte("some content"), crc32.MakeTable(crc32.Castagnoli))
+	value := base64.StdEncoding.EncodeToString([]byte{byte(crc >> 24), byte(crc >> 16), byte(crc >> 8), byte(crc)})
+
+	v, err := NewS3ChecksumVerifier(http.Header{"X-Amz-Checksum-Crc32c": []string{value}})
+	if assert.NoError(t, err) {
+		v.Write([]byte("some "))
+		v.Write([]byte("content"))
+		assert.NoError(t, v.Verify())
+		extended := map[string][]byte{"X-Amz-Checksum-Sha256": []byte("stale")}
+		v.SaveTo(extended)
+		assert.Equal(t, map[string][]byte{"X-Amz-Checksum-Crc32c": []byte(value)}, extended)
+		algorithm, checksum := StoredS3Checksum(extended)
+		assert.Equal(t, S3ChecksumCRC32C, algorithm)
+		assert.Equal(t, value, checksum)
+	}
+
+	v, _ = NewS3ChecksumVerifier(http.Header{"X-Amz-Checksum-Crc32c": []string{value}})
+	v.Write([]byte("other content"))
+	err = v.Verify()
+	assert.True(t, err != nil && strings.Contains(err.Error(), ErrS3ChecksumMismatch.Error()))
+
+	// nothing to verify
+	v, _ = NewS3ChecksumVerifier(http.Header{})
+	v.Write([]byte("any content"))
+	assert.NoError(t, v.Verify())
+	extended := map[string][]byte{}
+	v.SaveTo(extended)
+	assert.Empty(t, extended)
+}
+
+func TestS3ChecksumVerifierWithTrailer(t *testing.T) {
+	crc := crc32.Checksum([]byte("some content"), crc32.MakeTable(crc32.Castagnoli))
+	value := base64.StdEncoding.EncodeToString([]byte{byte(crc >> 24), byte(crc >> 16), byte(crc >> 8), byte(crc)})
+
+	v, err := NewS3ChecksumVerifier(http.Header{"X-Amz-Trailer": []string{"x-amz-checksum-crc32c"}})
+	if assert.NoError(t, err) {
+		v.Write([]byte("some content"))
+		assert.NoError(t, v.SetTrailer(http.Header{"X-Amz-Checksum-Crc32c": []string{value}}))
+		assert.NoError(t, v.Verify())
+	}
+
+	v, _ = NewS3ChecksumVerifier(http.Header{"X-Amz-Trailer": []string{"x-amz-checksum-crc32c"}})
+	v.Write([]byte("other content"))
+	assert.NoError(t, v.SetTrailer(http.Header{"X-Amz-Checksum-Crc32c": []string{value}}))
+	err = v.Verify()
+	assert.True(t, err != nil && strings.Contains(err.Error(), ErrS3ChecksumMismatch.Error()))
+
+	// the declared trailer is missing
+	v, _ = NewS3ChecksumVerifier(http.Header{"X-Amz-Trailer": []string{"x-amz-checksum-crc32c"}})
+	err = v.SetTrailer(http.Header{})
+	assert.True(t, err != nil && strings.Contains(err.Error(), ErrInvalidS3Checksum.Error()))
+}
+
+func TestCompositeS3Checksum(t *testing.T) {
+	part1, part2 := sha256.Sum256([]byte("part 1")), sha256.Sum256([]byte("part 2"))
+	expected := sha256.Sum256(append(part1[:], part2[:]...))
+
+	composite, err := CompositeS3Checksum(S3ChecksumSHA256, []string{
+		base64.StdEncoding.EncodeToString(part1[:]),
+		base64.StdEncoding.EncodeToString(part2[:]),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("%s-2", base64.StdEncoding.EncodeToString(expected[:])), composite)
+
+	_, err = CompositeS3Checksum(S3ChecksumCRC32, []string{base64.StdEncoding.EncodeToString(part1[:])})
+	assert.Error(t, err)
+	_, err = CompositeS3Checksum("MD5", nil)
+	assert.Error(t, err)
+}
@@ -41,6 +41,8 @@ func (f *Filer) StreamListDirectoryEntries(ctx context.Context, p util.FullPath,
 		p = p[0 : len(p)-1]
 	}
 
+	f.maybeListRemoteDirectory(ctx, p)
+
 	prefixInNamePattern, restNamePattern := splitPattern(namePattern)
 	if prefixInNamePattern != "" {
 		prefix = prefixInNamePattern
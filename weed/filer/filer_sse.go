@@ -0,0 +1,258 @@
+package filer
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/kms"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+The s3 server side encrypted objects, with SSE-S3 or SSE-KMS, are kept with the envelope encryption.
+Each chunk is encrypted with its own random cipher key, the same as the filer -encryptVolumeData option,
+and the chunk cipher keys are encrypted with a data key, which is wrapped by the kms in security.toml:
+
+	chunk data         encrypted by the chunk cipher key, on the volume servers
+	chunk cipher key   encrypted by the data key, in the chunk metadata
+	data key           wrapped by the kms, in the ExtSseDataKeyKey of the object
+
+The objects in one bucket share the data key kept in the bucket directory, so the parts of a multipart upload
+can be combined, and the kms is not called for each object. The unwrapped data keys are cached in memory.
+The kms key id of SSE-KMS is only kept and returned, the data keys are always wrapped by the enabled kms.
+
+The filer decrypts the chunk cipher keys when reading the objects, so the objects are read as usual over the filer
+http and the s3 gateway. The clients reading the chunks directly, e.g. the mount, can not read these objects.
+The filer also decrypts them to archive, restore, and verify the objects, and to compute their checksums,
+while the copied chunks are encrypted again with the same data key. The objects are not replicated by filer.sync
+and filer.backup, and are skipped by fs.verify, which read the chunks directly.
+
+With SSE-C the chunk cipher keys are encrypted with the customer key in the request headers instead of a data key.
+The customer key is never kept, only its md5 for checking the key provided to read the object.
+*/
+
+const (
+	SseAlgorithmHeader = "X-Amz-Server-Side-Encryption"
+	SseKmsKeyIdHeader  = "X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"
+	SseAlgorithmAES256 = "AES256"
+	SseAlgorithmKms    = "aws:kms"
+	ExtSseDataKeyKey   = "x-seaweedfs-sse-data-key"
+
+	SseCustomerAlgorithmHeader = "X-Amz-Server-Side-Encryption-Customer-Algorithm"
+	SseCustomerKeyHeader       = "X-Amz-Server-Side-Encryption-Customer-Key"
+	SseCustomerKeyMD5Header    = "X-Amz-Server-Side-Encryption-Customer-Key-Md5"
+)
+
+var (
+	ErrSseNotConfigured       = errors.New("server side encryption requires a kms in security.toml")
+	ErrSseCustomerKeyInvalid  = errors.New("invalid server side encryption customer key")
+	ErrSseCustomerKeyRequired = errors.New("the object is encrypted with a customer key")
+	ErrSseCustomerKeyMismatch = errors.New("the customer key does not match the object")
+)
+
+type sseDataKeys struct {
+	sync.Mutex
+	keys map[string]util.CipherKey // by the wrapped key
+}
+
+// SetKeyManager sets the kms to wrap the data keys of the server side encrypted objects
+func (f *Filer) SetKeyManager(km kms.KeyManager) {
+	f.keyManager = km
+	f.sseDataKeys.keys = make(map[string]util.CipherKey)
+}
+
+func (f *Filer) IsSseEnabled() bool {
+	return f.keyManager != nil
+}
+
+func IsSseEntry(entry *Entry) bool {
+	return len(entry.Extended[ExtSseDataKeyKey]) > 0
+}
+
+// IsSseEncrypted checks whether the chunk cipher keys are encrypted with either the data key or the customer key,
+// by the extended attributes of the entry.
+func IsSseEncrypted(extended map[string][]byte) bool {
+	return len(extended[ExtSseDataKeyKey]) > 0 || len(extended[SseCustomerKeyMD5Header]) > 0
+}
+
+// SseDataKey returns the data key for the object, shared by the objects in the same bucket.
+func (f *Filer) SseDataKey(ctx context.Context, p util.FullPath) (dataKey util.CipherKey, wrappedKey []byte, err error) {
+	if f.keyManager == nil {
+		return nil, nil, ErrSseNotConfigured
+	}
+
+	bucketsPrefix := f.DirBucketsPath + "/"
+	if !strings.HasPrefix(string(p), bucketsPrefix) {
+		plainKey, wrappedKey, err := f.keyManager.GenerateDataKey()
+		if err != nil {
+			return nil, nil, fmt.Errorf("generate data key: %v", err)
+		}
+		f.cacheSseDataKey(wrappedKey, plainKey)
+		return plainKey, wrappedKey, nil
+	}
+	bucket := strings.SplitN(strings.TrimPrefix(string(p), bucketsPrefix), "/", 2)[0]
+	bucketPath := util.FullPath(bucketsPrefix + bucket)
+
+	// one data key per bucket
+	f.sseDataKeys.Lock()
+	defer f.sseDataKeys.Unlock()
+
+	bucketEntry, err := f.FindEntry(ctx, bucketPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("find bucket %s: %v", bucketPath, err)
+	}
+	if wrappedKey = bucketEntry.Extended[ExtSseDataKeyKey]; len(wrappedKey) > 0 {
+		if dataKey = f.sseDataKeys.keys[string(wrappedKey)]; dataKey != nil {
+			return dataKey, wrappedKey, nil
+		}
+		if dataKey, err = f.keyManager.DecryptDataKey(wrappedKey); err != nil {
+			return nil, nil, fmt.Errorf("decrypt data key of bucket %s: %v", bucket, err)
+		}
+		f.sseDataKeys.keys[string(wrappedKey)] = dataKey
+		return dataKey, wrappedKey, nil
+	}
+
+	if dataKey, wrappedKey, err = f.keyManager.GenerateDataKey(); err != nil {
+		return nil, nil, fmt.Errorf("generate data key: %v", err)
+	}
+	updated := cloneEntryExtended(bucketEntry)
+	updated.Extended[ExtSseDataKeyKey] = wrappedKey
+	if err = f.UpdateEntry(ctx, bucketEntry, updated); err != nil {
+		return nil, nil, fmt.Errorf("save data key of bucket %s: %v", bucket, err)
+	}
+	f.NotifyUpdateEvent(ctx, bucketEntry, updated, false, false, nil)
+	f.sseDataKeys.keys[string(wrappedKey)] = dataKey
+	glog.V(0).Infof("generated server side encryption data key for bucket %s", bucket)
+	return dataKey, wrappedKey, nil
+}
+
+func (f *Filer) cacheSseDataKey(wrappedKey []byte, dataKey util.CipherKey) {
+	f.sseDataKeys.Lock()
+	f.sseDataKeys.keys[string(wrappedKey)] = dataKey
+	f.sseDataKeys.Unlock()
+}
+
+func (f *Filer) unwrapSseDataKey(wrappedKey []byte) (util.CipherKey, error) {
+	if f.keyManager == nil {
+		return nil, ErrSseNotConfigured
+	}
+	f.sseDataKeys.Lock()
+	dataKey := f.sseDataKeys.keys[string(wrappedKey)]
+	f.sseDataKeys.Unlock()
+	if dataKey != nil {
+		return dataKey, nil
+	}
+	dataKey, err := f.keyManager.DecryptDataKey(wrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt data key: %v", err)
+	}
+	f.cacheSseDataKey(wrappedKey, dataKey)
+	return dataKey, nil
+}
+
+// EncryptSseChunks encrypts the cipher keys of the chunks with the data key.
+// The chunks should be uploaded with the cipher.
+func EncryptSseChunks(chunks []*filer_pb.FileChunk, dataKey util.CipherKey) error {
+	for _, chunk := range chunks {
+		if len(chunk.CipherKey) == 0 {
+			return fmt.Errorf("chunk %s is not encrypted", chunk.GetFileIdString())
+		}
+		encrypted, err := util.Encrypt(chunk.CipherKey, dataKey)
+		if err != nil {
+			return fmt.Errorf("encrypt cipher key of chunk %s: %v", chunk.GetFileIdString(), err)
+		}
+		chunk.CipherKey = encrypted
+	}
+	return nil
+}
+
+func decryptSseChunks(chunks []*filer_pb.FileChunk, dataKey util.CipherKey) (decrypted []*filer_pb.FileChunk, err error) {
+	for _, chunk := range chunks {
+		plainKey, err := util.Decrypt(chunk.CipherKey, dataKey)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt cipher key of chunk %s: %v", chunk.GetFileIdString(), err)
+		}
+		c := proto.Clone(chunk).(*filer_pb.FileChunk)
+		c.CipherKey = plainKey
+		decrypted = append(decrypted, c)
+	}
+	return decrypted, nil
+}
+
+// DecryptSseEntry returns a copy of the server side encrypted entry with the chunk cipher keys decrypted,
+// or the entry itself if not encrypted.
+func (f *Filer) DecryptSseEntry(entry *Entry) (*Entry, error) {
+	if !IsSseEntry(entry) {
+		return entry, nil
+	}
+	dataKey, err := f.unwrapSseDataKey(entry.Extended[ExtSseDataKeyKey])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", entry.FullPath, err)
+	}
+	chunks, err := decryptSseChunks(entry.Chunks, dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", entry.FullPath, err)
+	}
+	decrypted := *entry
+	decrypted.Chunks = chunks
+	return &decrypted, nil
+}
+
+// EncryptSseEntryChunks encrypts the cipher keys of the chunks copied from the server side encrypted entry,
+// with the same data key, so the chunks can replace the entry chunks.
+func (f *Filer) EncryptSseEntryChunks(entry *Entry, chunks []*filer_pb.FileChunk) error {
+	dataKey, err := f.unwrapSseDataKey(entry.Extended[ExtSseDataKeyKey])
+	if err != nil {
+		return fmt.Errorf("%s: %v", entry.FullPath, err)
+	}
+	return EncryptSseChunks(chunks, dataKey)
+}
+
+func IsSseCustomerEntry(entry *Entry) bool {
+	return len(entry.Extended[SseCustomerKeyMD5Header]) > 0
+}
+
+// ParseSseCustomerKey returns the customer key and the base64 encoded md5 of it, or nil if no customer key is provided.
+func ParseSseCustomerKey(algorithm, encodedKey, keyMD5 string) (util.CipherKey, string, error) {
+	if algorithm == "" && encodedKey == "" && keyMD5 == "" {
+		return nil, "", nil
+	}
+	if algorithm != SseAlgorithmAES256 {
+		return nil, "", ErrSseCustomerKeyInvalid
+	}
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil || len(key) != 32 {
+		return nil, "", ErrSseCustomerKeyInvalid
+	}
+	sum := md5.Sum(key)
+	if expected := base64.StdEncoding.EncodeToString(sum[:]); keyMD5 != expected {
+		return nil, "", ErrSseCustomerKeyInvalid
+	}
+	return key, keyMD5, nil
+}
+
+// DecryptSseCustomerEntry returns a copy of the entry encrypted with the customer key, with the chunk cipher keys decrypted.
+func DecryptSseCustomerEntry(entry *Entry, key util.CipherKey, keyMD5 string) (*Entry, error) {
+	if key == nil {
+		return nil, ErrSseCustomerKeyRequired
+	}
+	if string(entry.Extended[SseCustomerKeyMD5Header]) != keyMD5 {
+		return nil, ErrSseCustomerKeyMismatch
+	}
+	chunks, err := decryptSseChunks(entry.Chunks, key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", entry.FullPath, err)
+	}
+	decrypted := *entry
+	decrypted.Chunks = chunks
+	return &decrypted, nil
+}
@@ -0,0 +1,130 @@
+package filer
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func TestSseChunks(t *testing.T) {
+
+	dataKey := util.GenCipherKey()
+	cipherKey := util.GenCipherKey()
+	chunks := []*filer_pb.FileChunk{{FileId: "1,2", CipherKey: append([]byte{}, cipherKey...)}}
+
+	if err := EncryptSseChunks(chunks, dataKey); err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if bytes.Equal(chunks[0].CipherKey, cipherKey) {
+		t.Fatalf("cipher key is not encrypted")
+	}
+
+	decrypted, err := decryptSseChunks(chunks, dataKey)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if !bytes.Equal(decrypted[0].CipherKey, cipherKey) {
+		t.Errorf("unexpected cipher key %x", decrypted[0].CipherKey)
+	}
+	if bytes.Equal(chunks[0].CipherKey, cipherKey) {
+		t.Errorf("the encrypted chunks are changed")
+	}
+
+	if _, err = decryptSseChunks(chunks, util.GenCipherKey()); err == nil {
+		t.Errorf("decrypted with a wrong data key")
+	}
+
+	if err = EncryptSseChunks([]*filer_pb.FileChunk{{FileId: "1,3"}}, dataKey); err == nil {
+		t.Errorf("encrypted a chunk without cipher key")
+	}
+}
+
+type testKeyManager struct {
+	masterKey util.CipherKey
+}
+
+func (km *testKeyManager) GetName() string { return "test" }
+func (km *testKeyManager) Initialize(configuration util.Configuration, prefix string) error {
+	return nil
+}
+func (km *testKeyManager) GenerateDataKey() (plainKey, wrappedKey []byte, err error) {
+	plainKey = util.GenCipherKey()
+	wrappedKey, err = util.Encrypt(plainKey, km.masterKey)
+	return
+}
+func (km *testKeyManager) DecryptDataKey(wrappedKey []byte) ([]byte, error) {
+	return util.Decrypt(wrappedKey, km.masterKey)
+}
+
+func TestSseEntryChunks(t *testing.T) {
+
+	km := &testKeyManager{masterKey: util.GenCipherKey()}
+	f := &Filer{}
+	f.SetKeyManager(km)
+	_, wrappedKey, _ := km.GenerateDataKey()
+
+	// the copied chunks are encrypted with the data key of the entry, and decrypted when reading the entry
+	cipherKey := util.GenCipherKey()
+	entry := &Entry{
+		FullPath: "/buckets/b/o",
+		Chunks:   []*filer_pb.FileChunk{{FileId: "1,2", CipherKey: append([]byte{}, cipherKey...)}},
+		Extended: map[string][]byte{ExtSseDataKeyKey: wrappedKey},
+	}
+	if !IsSseEncrypted(entry.Extended) || IsSseEncrypted(nil) {
+		t.Errorf("server side encrypted %v", entry.Extended)
+	}
+	if err := f.EncryptSseEntryChunks(entry, entry.Chunks); err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	decrypted, err := f.DecryptSseEntry(entry)
+	if err != nil || !bytes.Equal(decrypted.Chunks[0].CipherKey, cipherKey) {
+		t.Errorf("decrypt the copied chunks: %v", err)
+	}
+}
+
+func TestSseCustomerKey(t *testing.T) {
+
+	key := util.GenCipherKey()
+	sum := md5.Sum(key)
+	encodedKey, keyMD5 := base64.StdEncoding.EncodeToString(key), base64.StdEncoding.EncodeToString(sum[:])
+
+	parsed, parsedMD5, err := ParseSseCustomerKey(SseAlgorithmAES256, encodedKey, keyMD5)
+	if err != nil || !bytes.Equal(parsed, key) || parsedMD5 != keyMD5 {
+		t.Fatalf("parse customer key: %v", err)
+	}
+	if parsed, _, err = ParseSseCustomerKey("", "", ""); parsed != nil || err != nil {
+		t.Errorf("no customer key: %v", err)
+	}
+	if _, _, err = ParseSseCustomerKey(SseAlgorithmAES256, encodedKey, "bad"); err != ErrSseCustomerKeyInvalid {
+		t.Errorf("expecting invalid key md5, got %v", err)
+	}
+	if _, _, err = ParseSseCustomerKey(SseAlgorithmKms, encodedKey, keyMD5); err != ErrSseCustomerKeyInvalid {
+		t.Errorf("expecting invalid algorithm, got %v", err)
+	}
+
+	cipherKey := util.GenCipherKey()
+	entry := &Entry{
+		FullPath: "/buckets/b/o",
+		Chunks:   []*filer_pb.FileChunk{{FileId: "1,2", CipherKey: append([]byte{}, cipherKey...)}},
+		Extended: map[string][]byte{SseCustomerKeyMD5Header: []byte(keyMD5)},
+	}
+	if err = EncryptSseChunks(entry.Chunks, key); err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if _, err = DecryptSseCustomerEntry(entry, nil, ""); err != ErrSseCustomerKeyRequired {
+		t.Errorf("expecting key required, got %v", err)
+	}
+	otherKey := util.GenCipherKey()
+	otherSum := md5.Sum(otherKey)
+	if _, err = DecryptSseCustomerEntry(entry, otherKey, base64.StdEncoding.EncodeToString(otherSum[:])); err != ErrSseCustomerKeyMismatch {
+		t.Errorf("expecting key mismatch, got %v", err)
+	}
+	decrypted, err := DecryptSseCustomerEntry(entry, key, keyMD5)
+	if err != nil || !bytes.Equal(decrypted.Chunks[0].CipherKey, cipherKey) {
+		t.Errorf("decrypt with the customer key: %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package filer_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+)
+
+// the tests of the filer on a real store are in package filer_test, since the stores import the filer
+
+// initTestStore initializes the store in a temporary directory removed after the test
+func initTestStore(t *testing.T, store filer.FilerStore) {
+	dir, err := ioutil.TempDir("", "seaweedfs_filer_test")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	configuration := viper.New()
+	configuration.Set("dir", dir)
+	if err = store.Initialize(configuration, ""); err != nil {
+		t.Fatalf("initialize store %s: %v", store.GetName(), err)
+	}
+	t.Cleanup(store.Shutdown)
+}
+
+// newTestFiler returns a filer on the store initialized in a temporary directory
+func newTestFiler(t *testing.T, store filer.FilerStore) *filer.Filer {
+	initTestStore(t, store)
+	testFiler := filer.NewFiler(nil, nil, "", 0, "", "", "", nil)
+	testFiler.SetStore(store)
+	return testFiler
+}
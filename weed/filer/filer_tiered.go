@@ -0,0 +1,208 @@
+package filer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/pb/master_pb"
+	"github.com/chrislusf/seaweedfs/weed/stats"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+The s3 objects on the volumes moved to a remote storage by "volume.tier.upload" are slow to read.
+With "s3_tiered_archive_interval_minutes", such objects work like the archived entries, see filer_archive.go.
+
+Once per interval, the filer gets the tiered volumes from the master. If any volume is newly tiered, the objects
+in the buckets with all their chunks on the tiered volumes are marked as archived in place, with storage class
+GLACIER and the ExtTieredKey attribute, keeping their chunks. The clients then restore an object with the
+s3 RestoreObject to read it, which copies its content back to the local volumes until the restore expires.
+
+If any volume is moved back by "volume.tier.download", the objects marked in place and not on the tiered
+volumes any more are marked as normal objects again.
+*/
+
+const (
+	ExtTieredKey = "x-seaweedfs-tiered"
+)
+
+type tieredArchive struct {
+	filer    *Filer
+	interval time.Duration
+	// the tiered volumes when the objects were last marked
+	marked map[needle.VolumeId]bool
+}
+
+// StartArchivingTieredObjects starts marking the s3 objects on the tiered volumes as archived, once per interval.
+func (f *Filer) StartArchivingTieredObjects(interval time.Duration) {
+	t := &tieredArchive{
+		filer:    f,
+		interval: interval,
+	}
+	go t.loop()
+}
+
+func (t *tieredArchive) loop() {
+	// also wait for the master connection on start
+	time.Sleep(time.Minute)
+	t.filer.MasterClient.WaitUntilConnected()
+
+	for {
+		startedAt := time.Now()
+		if tiered, err := t.tieredVolumes(); err != nil {
+			glog.V(1).Infof("tiered volumes: %v", err)
+		} else if t.marked == nil || !sameVolumeIds(tiered, t.marked) {
+			t.runBuckets(context.Background(), tiered)
+			t.marked = tiered
+		}
+		time.Sleep(time.Until(startedAt.Add(t.interval)))
+	}
+}
+
+func (t *tieredArchive) tieredVolumes() (map[needle.VolumeId]bool, error) {
+	var resp *master_pb.VolumeListResponse
+	err := t.filer.MasterClient.WithClient(func(client master_pb.SeaweedClient) (err error) {
+		resp, err = client.VolumeList(context.Background(), &master_pb.VolumeListRequest{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return collectTieredVolumes(resp.TopologyInfo), nil
+}
+
+func collectTieredVolumes(topologyInfo *master_pb.TopologyInfo) map[needle.VolumeId]bool {
+	tiered := make(map[needle.VolumeId]bool)
+	for _, dc := range topologyInfo.GetDataCenterInfos() {
+		for _, rack := range dc.RackInfos {
+			for _, dn := range rack.DataNodeInfos {
+				for _, diskInfo := range dn.DiskInfos {
+					for _, v := range diskInfo.VolumeInfos {
+						if v.RemoteStorageName != "" {
+							tiered[needle.VolumeId(v.Id)] = true
+						}
+					}
+				}
+			}
+		}
+	}
+	return tiered
+}
+
+func sameVolumeIds(a, b map[needle.VolumeId]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for vid := range a {
+		if !b[vid] {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *tieredArchive) runBuckets(ctx context.Context, tiered map[needle.VolumeId]bool) {
+	lastFileName := ""
+	for {
+		entries, _, err := t.filer.ListDirectoryEntries(ctx, util.FullPath(t.filer.DirBucketsPath), lastFileName, false, PaginationSize, "", "")
+		if err != nil {
+			glog.V(1).Infof("tiered archive list buckets: %v", err)
+			return
+		}
+		for _, entry := range entries {
+			lastFileName = entry.Name()
+			if !entry.IsDirectory() {
+				continue
+			}
+			t.filer.walkFiles(ctx, entry.FullPath, func(files []*Entry) {
+				for _, file := range files {
+					if err := t.update(ctx, file, tiered); err != nil {
+						glog.V(1).Infof("tiered archive: %v", err)
+						stats.FilerTieredArchiveCounter.WithLabelValues("error").Inc()
+					}
+				}
+			}, entry.FullPath.Child(s3MultipartUploadsDir))
+		}
+		if len(entries) < PaginationSize {
+			break
+		}
+	}
+}
+
+// update marks the object as archived in place if all its chunks are on the tiered volumes,
+// or back as a normal object if it was marked in place and any chunk is not on the tiered volumes.
+func (t *tieredArchive) update(ctx context.Context, entry *Entry, tiered map[needle.VolumeId]bool) error {
+	isMarked := string(entry.Extended[ExtTieredKey]) == "true"
+	if IsArchived(entry) && !isMarked || IsRestoring(entry) || len(entry.Extended[ExtArchivedChunksKey]) > 0 {
+		// archived to the archive collection, or being restored
+		return nil
+	}
+	if len(entry.Chunks) == 0 {
+		return nil
+	}
+	isTiered, err := t.isOnTieredVolumes(entry, tiered)
+	if err != nil {
+		return err
+	}
+	if isTiered == isMarked {
+		return nil
+	}
+
+	current, err := t.filer.Store.FindEntry(ctx, entry.FullPath)
+	if err != nil {
+		return err
+	}
+	if !sameChunks(current.Chunks, entry.Chunks) {
+		return nil
+	}
+	updated := cloneEntryExtended(current)
+	if isTiered {
+		updated.Extended[ExtStorageClassKey] = []byte(ArchiveStorageClass)
+		updated.Extended[ExtTieredKey] = []byte("true")
+	} else {
+		delete(updated.Extended, ExtStorageClassKey)
+		delete(updated.Extended, ExtTieredKey)
+	}
+	if err = t.filer.Store.UpdateEntry(ctx, updated); err != nil {
+		return fmt.Errorf("update %s: %v", entry.FullPath, err)
+	}
+	t.filer.NotifyUpdateEvent(ctx, current, updated, false, false, nil)
+
+	if isTiered {
+		glog.V(2).Infof("tiered archive: %s is archived", entry.FullPath)
+		stats.FilerTieredArchiveCounter.WithLabelValues("archived").Inc()
+	} else {
+		glog.V(2).Infof("tiered archive: %s is not tiered any more", entry.FullPath)
+		stats.FilerTieredArchiveCounter.WithLabelValues("unarchived").Inc()
+	}
+	return nil
+}
+
+func (t *tieredArchive) isOnTieredVolumes(entry *Entry, tiered map[needle.VolumeId]bool) (bool, error) {
+	if len(tiered) == 0 {
+		return false, nil
+	}
+	chunks := entry.Chunks
+	if HasChunkManifest(chunks) {
+		dataChunks, _, err := ResolveChunkManifest(t.filer.MasterClient.LookupFileId, chunks)
+		if err != nil {
+			return false, fmt.Errorf("resolve chunk manifest of %s: %v", entry.FullPath, err)
+		}
+		chunks = dataChunks
+	}
+	return isOnTieredVolumes(chunks, tiered), nil
+}
+
+func isOnTieredVolumes(chunks []*filer_pb.FileChunk, tiered map[needle.VolumeId]bool) bool {
+	for _, chunk := range chunks {
+		vid, err := needle.NewVolumeId(VolumeId(chunk.GetFileIdString()))
+		if err != nil || !tiered[vid] {
+			return false
+		}
+	}
+	return len(chunks) > 0
+}
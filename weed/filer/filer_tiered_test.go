@@ -0,0 +1,50 @@
+package filer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/pb/master_pb"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+)
+
+func TestCollectTieredVolumes(t *testing.T) {
+
+	topologyInfo := &master_pb.TopologyInfo{
+		DataCenterInfos: []*master_pb.DataCenterInfo{{
+			RackInfos: []*master_pb.RackInfo{{
+				DataNodeInfos: []*master_pb.DataNodeInfo{{
+					DiskInfos: map[string]*master_pb.DiskInfo{
+						"": {VolumeInfos: []*master_pb.VolumeInformationMessage{
+							{Id: 1},
+							{Id: 2, RemoteStorageName: "s3.default", RemoteStorageKey: "2.dat"},
+						}},
+						"ssd": {VolumeInfos: []*master_pb.VolumeInformationMessage{
+							{Id: 3, RemoteStorageName: "s3.default", RemoteStorageKey: "3.dat"},
+						}},
+					},
+				}},
+			}},
+		}},
+	}
+
+	tiered := collectTieredVolumes(topologyInfo)
+	assert.Equal(t, map[needle.VolumeId]bool{2: true, 3: true}, tiered)
+	assert.True(t, sameVolumeIds(tiered, map[needle.VolumeId]bool{3: true, 2: true}))
+	assert.False(t, sameVolumeIds(tiered, map[needle.VolumeId]bool{2: true}))
+	assert.False(t, sameVolumeIds(tiered, map[needle.VolumeId]bool{2: true, 4: true}))
+	assert.Empty(t, collectTieredVolumes(nil))
+}
+
+func TestIsOnTieredVolumes(t *testing.T) {
+
+	tiered := map[needle.VolumeId]bool{2: true, 3: true}
+
+	assert.True(t, isOnTieredVolumes([]*filer_pb.FileChunk{{FileId: "2,01637037d6"}, {FileId: "3,01637037d7"}}, tiered))
+	assert.False(t, isOnTieredVolumes([]*filer_pb.FileChunk{{FileId: "2,01637037d6"}, {FileId: "1,01637037d7"}}, tiered), "partly tiered")
+	assert.False(t, isOnTieredVolumes([]*filer_pb.FileChunk{{Fid: &filer_pb.FileId{VolumeId: 1, FileKey: 1}}}, tiered))
+	assert.True(t, isOnTieredVolumes([]*filer_pb.FileChunk{{Fid: &filer_pb.FileId{VolumeId: 2, FileKey: 1}}}, tiered))
+	assert.False(t, isOnTieredVolumes(nil, tiered))
+}
@@ -0,0 +1,133 @@
+package filer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+The ttl of an entry counts from its creation time, and the ttl of its chunks from their last write.
+To keep a hot entry alive, e.g. in cache-like workloads, its ttl can be extended:
+the chunks are appended again in their ttl volumes, so their ttl counts from now on,
+and the entry ttl is increased, so the entry expires the same time from now on.
+
+The new files without a ttl inherit the ttl configured for their directories in filer.conf,
+so the files in cache or staging directories expire without the clients asking for it.
+An update keeps the ttl of the entry, since the clients usually do not send the ttl back,
+unless the caller sets the ttl explicitly with WithTtlSet, also to 0 to keep the entry forever.
+*/
+
+var ErrNoTtl = errors.New("no ttl")
+
+type ttlSetKey struct{}
+
+// WithTtlSet marks the entry ttl in the writes with the context as set by the caller,
+// so the ttl of the old entry is not kept.
+func WithTtlSet(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ttlSetKey{}, true)
+}
+
+func isTtlSet(ctx context.Context) bool {
+	isSet, _ := ctx.Value(ttlSetKey{}).(bool)
+	return isSet
+}
+
+// ExtendTtl keeps the entry until ttlSec from now on, or, if ttlSec is 0, the ttl of its chunks.
+func (f *Filer) ExtendTtl(ctx context.Context, entry *Entry, ttlSec int32) (expireAt time.Time, err error) {
+
+	chunksTtlSec, err := f.extendChunksTtl(entry.Chunks)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("extend ttl %s: %v", entry.FullPath, err)
+	}
+	if entry.TtlSec <= 0 && chunksTtlSec == 0 {
+		return time.Time{}, fmt.Errorf("extend ttl %s: %v", entry.FullPath, ErrNoTtl)
+	}
+	if ttlSec <= 0 {
+		ttlSec = chunksTtlSec
+	}
+	if ttlSec <= 0 {
+		return time.Time{}, fmt.Errorf("extend ttl %s: need the ttl for the entry without chunks", entry.FullPath)
+	}
+	if chunksTtlSec > 0 && ttlSec > chunksTtlSec {
+		return time.Time{}, fmt.Errorf("extend ttl %s: %ds exceeds the ttl %ds of the chunks", entry.FullPath, ttlSec, chunksTtlSec)
+	}
+
+	now := time.Now()
+	if entry.TtlSec <= 0 {
+		// only the chunks expire
+		return now.Add(time.Duration(ttlSec) * time.Second), nil
+	}
+
+	// the entry may be changed while extending the ttl of its chunks
+	current, err := f.Store.FindEntry(ctx, entry.FullPath)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("extend ttl %s: %v", entry.FullPath, err)
+	}
+	if !sameChunks(current.Chunks, entry.Chunks) || current.TtlSec <= 0 {
+		return time.Time{}, fmt.Errorf("extend ttl %s: entry changed during extending", entry.FullPath)
+	}
+	extended := cloneEntryExtended(current)
+	extended.TtlSec = extendedTtlSec(current.Crtime, now, ttlSec)
+	if err = f.Store.UpdateEntry(ctx, extended); err != nil {
+		return time.Time{}, fmt.Errorf("extend ttl %s: %v", entry.FullPath, err)
+	}
+	f.NotifyUpdateEvent(ctx, current, extended, false, false, nil)
+
+	return extended.Crtime.Add(time.Duration(extended.TtlSec) * time.Second), nil
+}
+
+// extendedTtlSec is the entry ttl counting from crtime, to expire ttlSec after now.
+func extendedTtlSec(crtime, now time.Time, ttlSec int32) int32 {
+	elapsed := now.Sub(crtime)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return int32((elapsed+time.Second-1)/time.Second) + ttlSec
+}
+
+// extendChunksTtl restarts the ttl of the chunks on all the replicas, returning the shortest chunk ttl, or 0 if no chunk expires.
+func (f *Filer) extendChunksTtl(chunks []*filer_pb.FileChunk) (ttlSec int32, err error) {
+	if len(chunks) == 0 {
+		return 0, nil
+	}
+
+	dataChunks, manifestChunks, err := ResolveChunkManifest(f.MasterClient.LookupFileId, chunks)
+	if err != nil {
+		return 0, err
+	}
+	var fileIds []string
+	for _, chunk := range append(dataChunks, manifestChunks...) {
+		fileIds = append(fileIds, chunk.GetFileIdString())
+	}
+
+	results, err := operation.ExtendFilesTtlWithLookupVolumeId(f.GrpcDialOption, fileIds, LookupByMasterClientFn(f.MasterClient))
+	if err != nil {
+		return 0, err
+	}
+	for _, result := range results {
+		if result.Error != "" {
+			return 0, fmt.Errorf("chunk %s: %s", result.FileId, result.Error)
+		}
+		if result.TtlSec > 0 && (ttlSec == 0 || int32(result.TtlSec) < ttlSec) {
+			ttlSec = int32(result.TtlSec)
+		}
+	}
+	return ttlSec, nil
+}
+
+// inheritTtl sets the ttl of a new file from filer.conf, except when the file is moved from another directory.
+func (f *Filer) inheritTtl(ctx context.Context, entry *Entry) {
+	if entry.IsDirectory() || entry.TtlSec != 0 || f.FilerConf == nil {
+		return
+	}
+	if movingFrom, _ := ctx.Value(movingFromKey{}).(util.FullPath); movingFrom != "" {
+		return
+	}
+	entry.TtlSec = f.FilerConf.TtlSec(string(entry.FullPath))
+}
@@ -0,0 +1,77 @@
+package filer_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	leveldb2 "github.com/chrislusf/seaweedfs/weed/filer/leveldb2"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func TestInheritedTtl(t *testing.T) {
+	testFiler := newTestFiler(t, &leveldb2.LevelDB2Store{})
+	testFiler.FilerConf.AddLocationConf(&filer_pb.FilerConf_PathConf{
+		LocationPrefix: "/cache/",
+		Ttl:            "1d",
+	})
+	testFiler.FilerConf.AddLocationConf(&filer_pb.FilerConf_PathConf{
+		LocationPrefix: "/cache/hot/",
+		Ttl:            "2h",
+	})
+	testFiler.FilerConf.AddLocationConf(&filer_pb.FilerConf_PathConf{
+		LocationPrefix: "/cache/keep/",
+		Ttl:            "0",
+	})
+
+	ctx := context.Background()
+
+	create := func(p string, ttlSec int32) int32 {
+		if err := testFiler.CreateEntry(ctx, &filer.Entry{
+			FullPath: util.FullPath(p),
+			Attr:     filer.Attr{Mode: 0644, Crtime: time.Now(), TtlSec: ttlSec},
+		}, false, false, nil); err != nil {
+			t.Fatalf("create entry %s: %v", p, err)
+		}
+		entry, err := testFiler.FindEntry(ctx, util.FullPath(p))
+		if err != nil {
+			t.Fatalf("find entry %s: %v", p, err)
+		}
+		return entry.TtlSec
+	}
+
+	for _, c := range []struct {
+		path   string
+		ttlSec int32
+		want   int32
+	}{
+		{"/cache/a", 0, 24 * 3600},
+		{"/cache/x/y/b", 0, 24 * 3600},
+		{"/cache/hot/c", 0, 2 * 3600},
+		{"/cache/keep/d", 0, 0},
+		{"/cache/e", 60, 60},
+		{"/other/f", 0, 0},
+		// the update keeps the ttl
+		{"/cache/a", 0, 24 * 3600},
+	} {
+		if ttlSec := create(c.path, c.ttlSec); ttlSec != c.want {
+			t.Errorf("%s ttl %d, expected %d", c.path, ttlSec, c.want)
+		}
+	}
+
+	// the ttl set by the caller is not kept
+	ctx = filer.WithTtlSet(ctx)
+	if ttlSec := create("/cache/a", 0); ttlSec != 0 {
+		t.Errorf("cleared ttl %d", ttlSec)
+	}
+
+	entry, err := testFiler.FindEntry(ctx, "/cache/x")
+	if err != nil {
+		t.Fatalf("find parent directory: %v", err)
+	}
+	if entry.TtlSec != 0 {
+		t.Errorf("directory ttl %d", entry.TtlSec)
+	}
+}
@@ -0,0 +1,23 @@
+package filer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExtendedTtlSec(t *testing.T) {
+	crtime := time.Unix(1000, 0)
+
+	if ttlSec := extendedTtlSec(crtime, crtime.Add(90*time.Second), 60); ttlSec != 150 {
+		t.Errorf("unexpected ttl %d", ttlSec)
+	}
+	// rounded up to not expire earlier than asked
+	now := crtime.Add(90*time.Second + time.Millisecond)
+	ttlSec := extendedTtlSec(crtime, now, 60)
+	if crtime.Add(time.Duration(ttlSec) * time.Second).Before(now.Add(60 * time.Second)) {
+		t.Errorf("ttl %d expires too early", ttlSec)
+	}
+	if ttlSec := extendedTtlSec(crtime, crtime.Add(-time.Second), 60); ttlSec != 60 {
+		t.Errorf("unexpected ttl %d with clock skew", ttlSec)
+	}
+}
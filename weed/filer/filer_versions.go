@@ -0,0 +1,231 @@
+package filer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+A directory can keep the prior versions of its files, with "versioning" for its location prefix in filer.conf,
+limited by "max_versions" per file and "version_retention_days".
+
+When a file is overwritten with new content, or deleted, the prior entry is kept as a version, with its chunks,
+under DirectoryVersions mirroring the file path:
+
+	/.versions/path/to/file/<version id>
+
+The version id is the time the version is superseded, in nanoseconds, so the versions sort by time.
+The metadata only updates, e.g. chmod or xattr changes, do not add versions.
+
+The versions of a file are pruned when adding a new version. Only the chunks not referenced by the remaining
+versions and the current entry are deleted. Restoring a version writes a copy of its chunks, so the restored
+file can be overwritten or deleted without touching the chunks of the version.
+
+The versions are not kept for the hard links, the archived entries, and the changes replicated from
+other clusters, which keep their own versions. Renaming a file does not move its versions.
+*/
+
+const (
+	DirectoryVersions    = "/.versions"
+	ExtVersionDeletedKey = "x-seaweedfs-version-deleted"
+)
+
+// VersionPath is where the version of the file is kept
+func VersionPath(p util.FullPath, versionId string) util.FullPath {
+	return versionsDir(p).Child(versionId)
+}
+
+func versionsDir(p util.FullPath) util.FullPath {
+	return util.FullPath(DirectoryVersions + string(p))
+}
+
+// VersionSupersededAt returns the time the version is superseded, from its version id
+func VersionSupersededAt(version *Entry) time.Time {
+	tsNs, _ := strconv.ParseInt(version.Name(), 10, 64)
+	return time.Unix(0, tsNs)
+}
+
+func IsDeletedVersion(version *Entry) bool {
+	return len(version.Extended[ExtVersionDeletedKey]) > 0
+}
+
+func (f *Filer) versioningConf(p util.FullPath) (conf *filer_pb.FilerConf_PathConf, isVersioned bool) {
+	if f.FilerConf == nil || strings.HasPrefix(string(p), DirectoryVersions+"/") {
+		return nil, false
+	}
+	conf = f.FilerConf.MatchStorageRule(string(p))
+	return conf, conf.Versioning
+}
+
+// needsVersion checks whether the content of the old entry is overwritten by the new entry, or deleted if the new entry is nil
+func needsVersion(oldEntry, newEntry *Entry) bool {
+	if oldEntry == nil || oldEntry.IsDirectory() || len(oldEntry.HardLinkId) > 0 || len(oldEntry.Extended[ExtArchivedChunksKey]) > 0 {
+		return false
+	}
+	if len(oldEntry.Chunks) == 0 && len(oldEntry.Content) == 0 {
+		return false
+	}
+	if newEntry == nil {
+		return true
+	}
+	if !bytes.Equal(oldEntry.Content, newEntry.Content) || len(oldEntry.Chunks) != len(newEntry.Chunks) {
+		return true
+	}
+	for i, chunk := range oldEntry.Chunks {
+		if chunk.GetFileIdString() != newEntry.Chunks[i].GetFileIdString() {
+			return true
+		}
+	}
+	return false
+}
+
+// KeepVersion keeps the old entry as a version, if it is in a versioned directory and
+// its content is overwritten by the new entry, or deleted if the new entry is nil.
+// The chunks of the old entry should not be deleted if the version is kept.
+func (f *Filer) KeepVersion(ctx context.Context, oldEntry, newEntry *Entry) (isKept bool, err error) {
+	if oldEntry == nil {
+		return false, nil
+	}
+	if _, isVersioned := f.versioningConf(oldEntry.FullPath); !isVersioned || !needsVersion(oldEntry, newEntry) {
+		return false, nil
+	}
+
+	version := cloneEntryExtended(oldEntry)
+	version.FullPath = VersionPath(oldEntry.FullPath, fmt.Sprintf("%019d", time.Now().UnixNano()))
+	if newEntry == nil {
+		version.Extended[ExtVersionDeletedKey] = []byte("true")
+	}
+	if err = f.CreateEntry(ctx, version, true, false, nil); err != nil {
+		return false, fmt.Errorf("keep version of %s: %v", oldEntry.FullPath, err)
+	}
+	glog.V(3).Infof("kept version %s", version.FullPath)
+	return true, nil
+}
+
+// ListVersions returns the versions of the file, the latest first
+func (f *Filer) ListVersions(ctx context.Context, p util.FullPath) (versions []*Entry, err error) {
+	lastFileName := ""
+	for {
+		entries, _, listErr := f.ListDirectoryEntries(ctx, versionsDir(p), lastFileName, false, PaginationSize, "", "")
+		if listErr != nil && listErr != filer_pb.ErrNotFound {
+			return nil, fmt.Errorf("list versions of %s: %v", p, listErr)
+		}
+		for _, entry := range entries {
+			lastFileName = entry.Name()
+			if !entry.IsDirectory() {
+				versions = append(versions, entry)
+			}
+		}
+		if len(entries) < PaginationSize {
+			break
+		}
+	}
+	for i, j := 0, len(versions)-1; i < j; i, j = i+1, j-1 {
+		versions[i], versions[j] = versions[j], versions[i]
+	}
+	return versions, nil
+}
+
+// PruneVersions deletes the versions of the file over the limits, with their chunks not referenced by the current entry or the other versions.
+func (f *Filer) PruneVersions(ctx context.Context, p util.FullPath, current *Entry) {
+	conf, isVersioned := f.versioningConf(p)
+	if !isVersioned || conf.MaxVersions == 0 && conf.VersionRetentionDays == 0 {
+		return
+	}
+	versions, err := f.ListVersions(ctx, p)
+	if err != nil {
+		glog.Errorf("prune versions: %v", err)
+		return
+	}
+
+	retentionCutoff := time.Now().Add(-time.Duration(conf.VersionRetentionDays) * 24 * time.Hour)
+	var kept, pruned []*Entry
+	for i, version := range versions {
+		if conf.MaxVersions > 0 && i >= int(conf.MaxVersions) || conf.VersionRetentionDays > 0 && VersionSupersededAt(version).Before(retentionCutoff) {
+			pruned = append(pruned, version)
+		} else {
+			kept = append(kept, version)
+		}
+	}
+	if len(pruned) == 0 {
+		return
+	}
+
+	if current != nil {
+		kept = append(kept, current)
+	}
+	f.deleteVersions(ctx, kept, pruned)
+}
+
+// deleteVersions deletes the pruned versions, with their chunks not referenced by the kept versions or entries.
+func (f *Filer) deleteVersions(ctx context.Context, kept, pruned []*Entry) {
+	referenced := make(map[string]bool)
+	for _, entry := range kept {
+		for _, chunk := range entry.Chunks {
+			referenced[chunk.GetFileIdString()] = true
+		}
+	}
+	for _, version := range pruned {
+		if err := f.Store.DeleteOneEntry(ctx, version); err != nil {
+			glog.Errorf("prune version %s: %v", version.FullPath, err)
+			continue
+		}
+		f.NotifyUpdateEvent(ctx, version, nil, false, false, nil)
+		var chunks []*filer_pb.FileChunk
+		for _, chunk := range version.Chunks {
+			if !referenced[chunk.GetFileIdString()] {
+				chunks = append(chunks, chunk)
+				referenced[chunk.GetFileIdString()] = true
+			}
+		}
+		f.DeleteChunks(chunks)
+		glog.V(3).Infof("pruned version %s", version.FullPath)
+	}
+}
+
+// ExcludeChunks returns the chunks not in the excluded chunks
+func ExcludeChunks(chunks, excluded []*filer_pb.FileChunk) (remaining []*filer_pb.FileChunk) {
+	excludedIds := make(map[string]bool)
+	for _, chunk := range excluded {
+		excludedIds[chunk.GetFileIdString()] = true
+	}
+	for _, chunk := range chunks {
+		if !excludedIds[chunk.GetFileIdString()] {
+			remaining = append(remaining, chunk)
+		}
+	}
+	return
+}
+
+// RestoreVersion writes the content of the version back to the file, keeping the current content as a new version.
+// The chunks of the version are written again by copyChunks, so the file does not share them with the version.
+func (f *Filer) RestoreVersion(ctx context.Context, p util.FullPath, versionId string, copyChunks func(version *Entry) ([]*filer_pb.FileChunk, error)) error {
+	version, err := f.FindEntry(ctx, VersionPath(p, versionId))
+	if err != nil {
+		return fmt.Errorf("find version %s of %s: %v", versionId, p, err)
+	}
+	restored := cloneEntryExtended(version)
+	restored.FullPath = p
+	restored.Mtime = time.Now()
+	delete(restored.Extended, ExtVersionDeletedKey)
+	if len(version.Chunks) > 0 {
+		if restored.Chunks, err = copyChunks(version); err != nil {
+			return fmt.Errorf("copy version %s of %s: %v", versionId, p, err)
+		}
+	}
+	if err = f.CreateEntry(ctx, restored, false, false, nil); err != nil {
+		if len(version.Chunks) > 0 {
+			f.DeleteChunks(restored.Chunks)
+		}
+		return err
+	}
+	return nil
+}
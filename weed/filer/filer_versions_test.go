@@ -0,0 +1,128 @@
+package filer_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	leveldb2 "github.com/chrislusf/seaweedfs/weed/filer/leveldb2"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func TestVersioning(t *testing.T) {
+	testFiler := newTestFiler(t, &leveldb2.LevelDB2Store{})
+	testFiler.FilerConf.AddLocationConf(&filer_pb.FilerConf_PathConf{
+		LocationPrefix: "/docs/",
+		Versioning:     true,
+		MaxVersions:    2,
+	})
+
+	ctx := context.Background()
+	p := util.FullPath("/docs/report.txt")
+
+	write := func(content string) {
+		if err := testFiler.CreateEntry(ctx, &filer.Entry{
+			FullPath: p,
+			Attr:     filer.Attr{Mode: 0644, Crtime: time.Now(), Mtime: time.Now()},
+			Content:  []byte(content),
+		}, false, false, nil); err != nil {
+			t.Fatalf("write %s: %v", content, err)
+		}
+	}
+	versionContents := func() (contents []string) {
+		versions, err := testFiler.ListVersions(ctx, p)
+		if err != nil {
+			t.Fatalf("list versions: %v", err)
+		}
+		for _, version := range versions {
+			contents = append(contents, string(version.Content))
+		}
+		return
+	}
+
+	write("v1")
+	write("v1")
+	if contents := versionContents(); len(contents) != 0 {
+		t.Errorf("same content kept versions %v", contents)
+	}
+
+	write("v2")
+	write("v3")
+	write("v4")
+	if contents := fmt.Sprint(versionContents()); contents != "[v3 v2]" {
+		t.Errorf("versions %s, expected the latest 2", contents)
+	}
+
+	if err := testFiler.DeleteEntryMetaAndData(ctx, p, false, false, true, false, nil); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	versions, _ := testFiler.ListVersions(ctx, p)
+	if len(versions) != 2 || string(versions[0].Content) != "v4" || !filer.IsDeletedVersion(versions[0]) {
+		t.Fatalf("versions after delete %v", versions)
+	}
+
+	if err := testFiler.RestoreVersion(ctx, p, versions[1].Name(), nil); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	entry, err := testFiler.FindEntry(ctx, p)
+	if err != nil || string(entry.Content) != "v3" {
+		t.Fatalf("restored %v: %v", entry, err)
+	}
+
+	// the versions are not versioned, and other directories are not versioned
+	write("v5")
+	if contents := fmt.Sprint(versionContents()); contents != "[v3 v4]" {
+		t.Errorf("versions %s after restoring", contents)
+	}
+	other := util.FullPath("/other/a.txt")
+	for _, content := range []string{"a", "b"} {
+		testFiler.CreateEntry(ctx, &filer.Entry{FullPath: other, Attr: filer.Attr{Mode: 0644}, Content: []byte(content)}, false, false, nil)
+	}
+	if versions, _ := testFiler.ListVersions(ctx, other); len(versions) != 0 {
+		t.Errorf("unversioned directory kept %d versions", len(versions))
+	}
+
+	// the restored file has its own copy of the chunks, so overwriting it keeps the chunks of the version
+	testFiler.FilerConf.AddLocationConf(&filer_pb.FilerConf_PathConf{
+		LocationPrefix: "/data/",
+		Versioning:     true,
+	})
+	chunked := util.FullPath("/data/chunked.bin")
+	for _, fileId := range []string{"1,01", "1,02"} {
+		if err := testFiler.CreateEntry(ctx, &filer.Entry{
+			FullPath: chunked,
+			Attr:     filer.Attr{Mode: 0644, Crtime: time.Now(), Mtime: time.Now(), FileSize: 3},
+			Chunks:   []*filer_pb.FileChunk{{FileId: fileId, Size: 3}},
+		}, false, false, nil); err != nil {
+			t.Fatalf("write %s: %v", fileId, err)
+		}
+	}
+	chunkedVersions, _ := testFiler.ListVersions(ctx, chunked)
+	if len(chunkedVersions) != 1 {
+		t.Fatalf("chunked versions %v", chunkedVersions)
+	}
+	copyChunks := func(version *filer.Entry) (chunks []*filer_pb.FileChunk, err error) {
+		for _, chunk := range version.Chunks {
+			chunks = append(chunks, &filer_pb.FileChunk{FileId: chunk.FileId + "0", Size: chunk.Size})
+		}
+		return
+	}
+	if err := testFiler.RestoreVersion(ctx, chunked, chunkedVersions[0].Name(), copyChunks); err != nil {
+		t.Fatalf("restore chunked: %v", err)
+	}
+	if entry, err := testFiler.FindEntry(ctx, chunked); err != nil || entry.Chunks[0].FileId != "1,010" {
+		t.Fatalf("restored chunked %v: %v", entry, err)
+	}
+	testFiler.CreateEntry(ctx, &filer.Entry{
+		FullPath: chunked,
+		Attr:     filer.Attr{Mode: 0644, Crtime: time.Now(), Mtime: time.Now(), FileSize: 3},
+		Chunks:   []*filer_pb.FileChunk{{FileId: "1,03", Size: 3}},
+	}, false, false, nil)
+	version, err := testFiler.FindEntry(ctx, chunkedVersions[0].FullPath)
+	if err != nil || version.Chunks[0].FileId != "1,01" {
+		t.Fatalf("version after restoring and overwriting %v: %v", version, err)
+	}
+}
@@ -0,0 +1,171 @@
+package filer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+A directory can be write once read many, with "worm" for its location prefix in filer.conf.
+
+A new file under it can be written during the commit window "worm_grace_period_seconds" after its creation on the filer.
+After the commit, the file can not be overwritten, truncated, renamed, or deleted, until "worm_retention_days"
+after the commit, or forever if not set. Only the metadata, e.g. the mtime or the extended attributes, can change.
+
+The commit and expiration times are kept in the entry extended attributes when the file is created,
+so the retention does not change with filer.conf later, and the files created before enabling worm are not retained.
+The extended attributes are kept by the updates, and only set by the filer: the ones supplied by the clients
+for new files are removed, except the ones replicated from other clusters into worm directories.
+The checks are in the filer, for all the clients. The retained files with TTL are not expired.
+
+Deleting or renaming a directory checks the files under it, if the directory is under or contains a worm location.
+*/
+
+const (
+	ExtWormCommitAtKey    = "x-seaweedfs-worm-commit-at"
+	ExtWormRetainUntilKey = "x-seaweedfs-worm-retain-until"
+)
+
+var ErrEntryRetained = errors.New("entry is under retention")
+
+// markWorm sets the commit and expiration times of a new file in a worm directory
+func (f *Filer) markWorm(entry *Entry) {
+	if f.FilerConf == nil || entry.IsDirectory() {
+		return
+	}
+	conf := f.FilerConf.MatchStorageRule(string(entry.FullPath))
+	if !conf.Worm {
+		return
+	}
+	commitAt := time.Now().Add(time.Duration(conf.WormGracePeriodSeconds) * time.Second)
+	if entry.Extended == nil {
+		entry.Extended = make(map[string][]byte)
+	}
+	entry.Extended[ExtWormCommitAtKey] = []byte(strconv.FormatInt(commitAt.Unix(), 10))
+	if conf.WormRetentionDays > 0 {
+		retainUntil := commitAt.Add(time.Duration(conf.WormRetentionDays) * 24 * time.Hour)
+		entry.Extended[ExtWormRetainUntilKey] = []byte(strconv.FormatInt(retainUntil.Unix(), 10))
+	}
+}
+
+// stripWorm removes the worm retention supplied with a new file, which is only set by markWorm.
+// The retention replicated from other clusters is kept in the worm directories.
+func (f *Filer) stripWorm(entry *Entry, isFromOtherCluster bool) {
+	if isFromOtherCluster && f.FilerConf != nil && f.FilerConf.MatchStorageRule(string(entry.FullPath)).Worm {
+		return
+	}
+	delete(entry.Extended, ExtWormCommitAtKey)
+	delete(entry.Extended, ExtWormRetainUntilKey)
+}
+
+// RetentionOf returns the commit and expiration times of the entry, with a zero retainUntil for retaining forever
+func RetentionOf(entry *Entry) (commitAt, retainUntil time.Time, isWorm bool) {
+	if entry == nil || len(entry.Extended[ExtWormCommitAtKey]) == 0 {
+		return
+	}
+	commitAtSec, _ := strconv.ParseInt(string(entry.Extended[ExtWormCommitAtKey]), 10, 64)
+	commitAt = time.Unix(commitAtSec, 0)
+	if value := entry.Extended[ExtWormRetainUntilKey]; len(value) > 0 {
+		retainUntilSec, _ := strconv.ParseInt(string(value), 10, 64)
+		retainUntil = time.Unix(retainUntilSec, 0)
+	}
+	return commitAt, retainUntil, true
+}
+
+// IsRetained checks the worm retention and the object lock retention of the entry
+func IsRetained(entry *Entry) bool {
+	return isWormRetained(entry) || isObjectLockRetained(entry, false)
+}
+
+// isRetained checks the worm retention, and the object lock retention in the buckets with object lock enabled
+func (f *Filer) isRetained(ctx context.Context, entry *Entry, bypassGovernance bool) bool {
+	return isWormRetained(entry) || isObjectLockRetained(entry, bypassGovernance) && f.isObjectLockEnabled(ctx, entry.FullPath)
+}
+
+func isWormRetained(entry *Entry) bool {
+	commitAt, retainUntil, isWorm := RetentionOf(entry)
+	if !isWorm {
+		return false
+	}
+	now := time.Now()
+	return !now.Before(commitAt) && (retainUntil.IsZero() || now.Before(retainUntil))
+}
+
+// CheckNotRetained returns ErrEntryRetained if the entry, or for directories any file under it, is retained.
+// The GOVERNANCE object lock retention is not checked if the context bypasses it.
+func (f *Filer) CheckNotRetained(ctx context.Context, entry *Entry) error {
+	bypassGovernance := IsGovernanceBypassed(ctx)
+	isRetained := func(e *Entry) bool {
+		return f.isRetained(ctx, e, bypassGovernance)
+	}
+	if isRetained(entry) {
+		return fmt.Errorf("%s: %v", entry.FullPath, ErrEntryRetained)
+	}
+	if !entry.IsDirectory() || !f.hasWormUnder(entry.FullPath) && !f.hasObjectLockUnder(ctx, entry.FullPath) {
+		return nil
+	}
+	var retainedPath util.FullPath
+	if err := f.walkDirectoryTree(ctx, entry.FullPath, func(sub *Entry) {
+		if retainedPath == "" && isRetained(sub) {
+			retainedPath = sub.FullPath
+		}
+	}); err != nil {
+		return err
+	}
+	if retainedPath != "" {
+		return fmt.Errorf("%s: %v", retainedPath, ErrEntryRetained)
+	}
+	return nil
+}
+
+// hasWormUnder checks whether the directory is under or contains a worm location
+func (f *Filer) hasWormUnder(dir util.FullPath) bool {
+	if f.FilerConf == nil {
+		return false
+	}
+	if f.FilerConf.MatchStorageRule(string(dir) + "/").Worm {
+		return true
+	}
+	dirPrefix := strings.TrimSuffix(string(dir), "/") + "/"
+	for _, conf := range f.FilerConf.WormLocations() {
+		if strings.HasPrefix(conf.LocationPrefix, dirPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkUpdateNotRetained keeps the retention of the old entry, and only allows metadata updates to a retained entry
+func checkUpdateNotRetained(oldEntry, newEntry *Entry) error {
+	if err := checkUpdateNotObjectLocked(oldEntry, newEntry); err != nil {
+		return err
+	}
+	if _, _, isWorm := RetentionOf(oldEntry); !isWorm {
+		return nil
+	}
+	if newEntry.Extended == nil {
+		newEntry.Extended = make(map[string][]byte)
+	}
+	for _, key := range []string{ExtWormCommitAtKey, ExtWormRetainUntilKey} {
+		if value, found := oldEntry.Extended[key]; found {
+			newEntry.Extended[key] = value
+		} else {
+			delete(newEntry.Extended, key)
+		}
+	}
+	if !isWormRetained(oldEntry) {
+		return nil
+	}
+	if !bytes.Equal(oldEntry.Content, newEntry.Content) || !sameChunks(oldEntry.Chunks, newEntry.Chunks) || oldEntry.FileSize != newEntry.FileSize ||
+		newEntry.IsDirectory() || !bytes.Equal(oldEntry.HardLinkId, newEntry.HardLinkId) {
+		return fmt.Errorf("%s: %v", oldEntry.FullPath, ErrEntryRetained)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package filer_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	leveldb2 "github.com/chrislusf/seaweedfs/weed/filer/leveldb2"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func TestWormRetention(t *testing.T) {
+	store := &leveldb2.LevelDB2Store{}
+	testFiler := newTestFiler(t, store)
+	testFiler.FilerConf.AddLocationConf(&filer_pb.FilerConf_PathConf{
+		LocationPrefix:    "/records/",
+		Worm:              true,
+		WormRetentionDays: 1,
+	})
+	testFiler.FilerConf.AddLocationConf(&filer_pb.FilerConf_PathConf{
+		LocationPrefix:         "/records/draft/",
+		WormGracePeriodSeconds: 3600,
+	})
+
+	ctx := context.Background()
+	write := func(p, content string, mode os.FileMode) error {
+		return testFiler.CreateEntry(ctx, &filer.Entry{
+			FullPath: util.FullPath(p),
+			Attr:     filer.Attr{Mode: mode, Crtime: time.Now(), Mtime: time.Now()},
+			Content:  []byte(content),
+		}, false, false, nil)
+	}
+	isRetainedErr := func(err error) bool {
+		return err != nil && strings.Contains(err.Error(), filer.ErrEntryRetained.Error())
+	}
+
+	if err := write("/records/a", "a", 0644); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := write("/records/a", "b", 0644); !isRetainedErr(err) {
+		t.Errorf("overwrite retained file: %v", err)
+	}
+	// the metadata can change, keeping the retention
+	if err := write("/records/a", "a", 0444); err != nil {
+		t.Errorf("chmod retained file: %v", err)
+	}
+	entry, _ := testFiler.FindEntry(ctx, "/records/a")
+	if !filer.IsRetained(entry) || entry.Mode != 0444 {
+		t.Errorf("retention after chmod: %v, mode %v", filer.IsRetained(entry), entry.Mode)
+	}
+	if err := testFiler.DeleteEntryMetaAndData(ctx, "/records/a", false, false, true, false, nil); !isRetainedErr(err) {
+		t.Errorf("delete retained file: %v", err)
+	}
+	if err := testFiler.DeleteEntryMetaAndData(ctx, "/records", true, false, true, false, nil); !isRetainedErr(err) {
+		t.Errorf("delete directory with retained file: %v", err)
+	}
+
+	// can be written during the commit window
+	if err := write("/records/draft/b", "a", 0644); err != nil {
+		t.Fatalf("create draft: %v", err)
+	}
+	if err := write("/records/draft/b", "b", 0644); err != nil {
+		t.Errorf("overwrite in commit window: %v", err)
+	}
+	entry, _ = testFiler.FindEntry(ctx, "/records/draft/b")
+	commitAt, retainUntil, isWorm := filer.RetentionOf(entry)
+	if !isWorm || filer.IsRetained(entry) || retainUntil.Sub(commitAt) != 24*time.Hour {
+		t.Errorf("draft retention %v %v %v", isWorm, commitAt, retainUntil)
+	}
+
+	// expired retention
+	entry, _ = testFiler.FindEntry(ctx, "/records/a")
+	entry.Extended[filer.ExtWormRetainUntilKey] = []byte(fmt.Sprintf("%d", time.Now().Add(-time.Minute).Unix()))
+	store.UpdateEntry(ctx, entry)
+	if err := testFiler.DeleteEntryMetaAndData(ctx, "/records/a", false, false, true, false, nil); err != nil {
+		t.Errorf("delete expired file: %v", err)
+	}
+
+	if err := write("/other/c", "a", 0644); err != nil {
+		t.Fatalf("create other: %v", err)
+	}
+	if err := write("/other/c", "b", 0644); err != nil {
+		t.Errorf("overwrite other: %v", err)
+	}
+
+	// the retention supplied by the clients is removed
+	if err := testFiler.CreateEntry(ctx, &filer.Entry{
+		FullPath: "/other/d",
+		Attr:     filer.Attr{Mode: 0644, Crtime: time.Now(), Mtime: time.Now()},
+		Content:  []byte("a"),
+		Extended: map[string][]byte{filer.ExtWormCommitAtKey: []byte("1")},
+	}, false, false, nil); err != nil {
+		t.Fatalf("create other with retention: %v", err)
+	}
+	if entry, _ = testFiler.FindEntry(ctx, "/other/d"); filer.IsRetained(entry) {
+		t.Errorf("client supplied retention on %s", entry.FullPath)
+	}
+
+	// the retained files with TTL are not expired
+	if err := testFiler.CreateEntry(ctx, &filer.Entry{
+		FullPath: "/records/e",
+		Attr:     filer.Attr{Mode: 0644, Crtime: time.Now().Add(-time.Hour), Mtime: time.Now(), TtlSec: 60},
+		Content:  []byte("a"),
+	}, false, false, nil); err != nil {
+		t.Fatalf("create with ttl: %v", err)
+	}
+	if _, err := testFiler.FindEntry(ctx, "/records/e"); err != nil {
+		t.Errorf("find retained file over ttl: %v", err)
+	}
+	entries, _, _ := testFiler.ListDirectoryEntries(ctx, "/records", "", false, 100, "", "")
+	found := false
+	for _, entry := range entries {
+		found = found || entry.Name() == "e"
+	}
+	if !found {
+		t.Errorf("retained file over ttl is not listed")
+	}
+}
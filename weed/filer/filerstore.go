@@ -11,6 +11,7 @@ var (
 	ErrUnsupportedSuperLargeDirectoryListing = errors.New("unsupported super large directory listing")
 	ErrKvNotImplemented                      = errors.New("kv not implemented yet")
 	ErrKvNotFound                            = errors.New("kv: not found")
+	ErrUnsupportedDirectoryRename            = errors.New("unsupported directory rename in the store")
 )
 
 type ListEachEntryFunc func(entry *Entry) bool
@@ -40,6 +41,13 @@ type FilerStore interface {
 	Shutdown()
 }
 
+// DirectoryRenamer is implemented by the stores which can rename a directory with all the entries under it
+// in the store itself, e.g. by rewriting the path prefix, instead of moving the entries one by one.
+// The new path should not exist. It returns ErrUnsupportedDirectoryRename to fall back to moving the entries.
+type DirectoryRenamer interface {
+	RenameDirectory(ctx context.Context, oldPath, newPath util.FullPath) error
+}
+
 type BucketAware interface {
 	OnBucketCreation(bucket string)
 	OnBucketDeletion(bucket string)
@@ -0,0 +1,477 @@
+package filer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/karlseguin/ccache/v2"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/stats"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+The entry lookups and the directory listings can be cached in front of the filer store, to protect the slow
+stores, e.g. SQL or Cassandra, from the hot directories looked up or listed by many clients.
+
+The cache is set by "store_cache" in filer.toml, in the memory of each filer, or in Redis shared by the filers.
+The writes on this filer invalidate the cached entry and the cached listings of its directory. The changes
+by the other filers are invalidated by their metadata events, so the filers sharing one store need to be
+-peers of each other. The metadata events of the local writes invalidate the entries again, in case a slow
+read cached an old value during the write. The cached values expire after "store_cache_ttl_seconds",
+to limit the staleness if an event is missed.
+
+The entries with hard links, and the listings with them, are not cached, since the hard links are
+changed without changing the entries. The reads in a transaction skip the cache, and the entries
+written in a transaction are invalidated again after the transaction. The path indexes are not cached,
+since they are changed without metadata events.
+*/
+
+const (
+	StoreCacheMemory            = "memory"
+	StoreCacheRedis             = "redis"
+	DefaultStoreCacheTtlSeconds = 60
+	DefaultStoreCacheSize       = 100000
+	DefaultStoreCacheKeyPrefix  = "seaweedfs.cache:"
+	maxCachedListingLimit       = 10000
+)
+
+type StoreCacheOption struct {
+	Type           string // StoreCacheMemory or StoreCacheRedis
+	Ttl            time.Duration
+	Size           int64 // the max number of cached entries and listings in memory
+	RedisAddresses []string
+	RedisPassword  string
+	RedisKeyPrefix string
+}
+
+// storeCacheBackend keeps the values by the directory and the key in the directory
+type storeCacheBackend interface {
+	get(ctx context.Context, dir, key string) (value []byte, found bool)
+	set(ctx context.Context, dir, key string, value []byte)
+	delete(ctx context.Context, dir, key string)
+	deleteAll(ctx context.Context, dir string)
+	// deleteTree deletes the values of all the directories under the directory
+	deleteTree(ctx context.Context, dir string)
+}
+
+type storeCache struct {
+	name    string
+	backend storeCacheBackend
+}
+
+func newStoreCache(option StoreCacheOption) (*storeCache, error) {
+	if option.Ttl <= 0 {
+		option.Ttl = DefaultStoreCacheTtlSeconds * time.Second
+	}
+	switch option.Type {
+	case StoreCacheMemory:
+		if option.Size <= 0 {
+			option.Size = DefaultStoreCacheSize
+		}
+		return &storeCache{
+			name: option.Type,
+			backend: &memoryStoreCache{
+				cache:   ccache.Layered(ccache.Configure().MaxSize(option.Size)),
+				ttl:     option.Ttl,
+				maxDirs: int(option.Size),
+				dirs:    make(map[string]struct{}),
+			},
+		}, nil
+	case StoreCacheRedis:
+		if len(option.RedisAddresses) == 0 {
+			return nil, fmt.Errorf("store cache %s: no redis addresses", option.Type)
+		}
+		if option.RedisKeyPrefix == "" {
+			option.RedisKeyPrefix = DefaultStoreCacheKeyPrefix
+		}
+		return &storeCache{
+			name: option.Type,
+			backend: &redisStoreCache{
+				client: redis.NewUniversalClient(&redis.UniversalOptions{
+					Addrs:    option.RedisAddresses,
+					Password: option.RedisPassword,
+				}),
+				keyPrefix: option.RedisKeyPrefix,
+				ttl:       option.Ttl,
+			},
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown store cache %s, expecting %s or %s", option.Type, StoreCacheMemory, StoreCacheRedis)
+}
+
+// SetStoreCache caches the entry lookups and the directory listings of the filer store
+func (f *Filer) SetStoreCache(option StoreCacheOption) error {
+	fsw, ok := f.Store.(*FilerStoreWrapper)
+	if !ok {
+		return fmt.Errorf("filer store %s can not be cached", f.Store.GetName())
+	}
+	cache, err := newStoreCache(option)
+	if err != nil {
+		return err
+	}
+	fsw.cache = cache
+	glog.V(0).Infof("cache filer store %s in %s for %v", fsw.GetName(), option.Type, option.Ttl)
+	return nil
+}
+
+func (c *storeCache) findEntry(ctx context.Context, fp util.FullPath, findFn func() (*Entry, error)) (*Entry, error) {
+	dir, name := fp.DirAndName()
+	if value, found := c.backend.get(ctx, "e"+dir, name); found {
+		if len(value) == 0 {
+			stats.FilerStoreCounter.WithLabelValues(c.name, "find").Inc()
+			return nil, filer_pb.ErrNotFound
+		}
+		entry := &Entry{FullPath: fp}
+		if err := entry.DecodeAttributesAndChunks(value); err == nil {
+			stats.FilerStoreCounter.WithLabelValues(c.name, "find").Inc()
+			return entry, nil
+		}
+	}
+
+	entry, err := findFn()
+	if err == filer_pb.ErrNotFound {
+		c.backend.set(ctx, "e"+dir, name, []byte{})
+	} else if err == nil && len(entry.HardLinkId) == 0 {
+		if value, encodeErr := entry.EncodeAttributesAndChunks(); encodeErr == nil {
+			c.backend.set(ctx, "e"+dir, name, value)
+		}
+	}
+	return entry, err
+}
+
+func (c *storeCache) listDirectoryEntries(ctx context.Context, dirPath util.FullPath, startFileName string, includeStartFile bool, limit int64, prefix string, eachEntryFunc ListEachEntryFunc, listFn func(eachEntryFunc ListEachEntryFunc) (string, error)) (lastFileName string, err error) {
+	key := fmt.Sprintf("%s\x00%v\x00%d\x00%s", startFileName, includeStartFile, limit, prefix)
+
+	var entries []*Entry
+	value, found := c.backend.get(ctx, "l"+string(dirPath), key)
+	if found {
+		if entries, lastFileName, err = decodeListing(dirPath, value); err != nil {
+			glog.V(1).Infof("decode cached listing %s: %v", dirPath, err)
+			found = false
+		}
+	}
+	if found {
+		stats.FilerStoreCounter.WithLabelValues(c.name, "list").Inc()
+	} else {
+		isCacheable := true
+		if lastFileName, err = listFn(func(entry *Entry) bool {
+			entries = append(entries, entry)
+			isCacheable = isCacheable && len(entry.HardLinkId) == 0
+			return true
+		}); err != nil {
+			return lastFileName, err
+		}
+		if isCacheable {
+			if value, err = encodeListing(entries, lastFileName); err == nil {
+				c.backend.set(ctx, "l"+string(dirPath), key, value)
+			}
+		}
+	}
+
+	for _, entry := range entries {
+		if !eachEntryFunc(entry) {
+			return entry.Name(), nil
+		}
+	}
+	return lastFileName, nil
+}
+
+// invalidate deletes the cached entry and the cached listings of its directory,
+// and the children of the entry if it is a directory, and all the entries under it if isTree.
+func (c *storeCache) invalidate(ctx context.Context, p util.FullPath, isDirectory, isTree bool) {
+	dir, name := p.DirAndName()
+	c.backend.delete(ctx, "e"+dir, name)
+	c.backend.deleteAll(ctx, "l"+dir)
+	if isDirectory || isTree {
+		c.backend.deleteAll(ctx, "e"+string(p))
+		c.backend.deleteAll(ctx, "l"+string(p))
+	}
+	if isTree {
+		c.backend.deleteTree(ctx, string(p))
+	}
+}
+
+// invalidateChildren deletes the cached children and listings of the directory, keeping the directory entry itself.
+func (c *storeCache) invalidateChildren(ctx context.Context, p util.FullPath) {
+	c.backend.deleteAll(ctx, "e"+string(p))
+	c.backend.deleteAll(ctx, "l"+string(p))
+}
+
+// the listing is encoded as the last file name, then the names and the encoded attributes and chunks of the entries
+func encodeListing(entries []*Entry, lastFileName string) ([]byte, error) {
+	value := appendBytes(nil, []byte(lastFileName))
+	for _, entry := range entries {
+		data, err := entry.EncodeAttributesAndChunks()
+		if err != nil {
+			return nil, err
+		}
+		value = appendBytes(value, []byte(entry.Name()))
+		value = appendBytes(value, data)
+	}
+	return value, nil
+}
+
+func decodeListing(dirPath util.FullPath, value []byte) (entries []*Entry, lastFileName string, err error) {
+	var name, data []byte
+	if name, value, err = nextBytes(value); err != nil {
+		return nil, "", err
+	}
+	lastFileName = string(name)
+	for len(value) > 0 {
+		if name, value, err = nextBytes(value); err != nil {
+			return nil, "", err
+		}
+		if data, value, err = nextBytes(value); err != nil {
+			return nil, "", err
+		}
+		entry := &Entry{FullPath: dirPath.Child(string(name))}
+		if err = entry.DecodeAttributesAndChunks(data); err != nil {
+			return nil, "", err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, lastFileName, nil
+}
+
+func appendBytes(buf []byte, data []byte) []byte {
+	size := make([]byte, 4)
+	util.Uint32toBytes(size, uint32(len(data)))
+	return append(append(buf, size...), data...)
+}
+
+func nextBytes(buf []byte) (data, remaining []byte, err error) {
+	if len(buf) < 4 {
+		return nil, nil, fmt.Errorf("truncated cached value")
+	}
+	size := int(util.BytesToUint32(buf[:4]))
+	if len(buf) < 4+size {
+		return nil, nil, fmt.Errorf("truncated cached value")
+	}
+	return buf[4 : 4+size], buf[4+size:], nil
+}
+
+// isInTransaction checks whether the context is in a store transaction not committed yet
+func (fsw *FilerStoreWrapper) isCached(ctx context.Context, p util.FullPath) bool {
+	return fsw.cache != nil && !isInTransaction(ctx) && !isPathIndexPath(p)
+}
+
+func isInTransaction(ctx context.Context) bool {
+	write, found := ctx.Value(storeWriteKey{}).(*storeWrite)
+	return found && write.inTransaction && !write.isEnded
+}
+
+type storeCacheInvalidation struct {
+	path           util.FullPath
+	isDirectory    bool
+	isTree         bool
+	isChildrenOnly bool
+}
+
+func (invalidation storeCacheInvalidation) apply(ctx context.Context, c *storeCache) {
+	if invalidation.isChildrenOnly {
+		c.invalidateChildren(ctx, invalidation.path)
+		return
+	}
+	c.invalidate(ctx, invalidation.path, invalidation.isDirectory, invalidation.isTree)
+}
+
+func (fsw *FilerStoreWrapper) invalidateCache(ctx context.Context, p util.FullPath, isDirectory, isTree bool) {
+	fsw.addInvalidation(ctx, storeCacheInvalidation{path: p, isDirectory: isDirectory, isTree: isTree})
+}
+
+// invalidateCacheChildren invalidates the children of the directory, but not the directory and its parent listings
+func (fsw *FilerStoreWrapper) invalidateCacheChildren(ctx context.Context, p util.FullPath) {
+	fsw.addInvalidation(ctx, storeCacheInvalidation{path: p, isDirectory: true, isChildrenOnly: true})
+}
+
+func (fsw *FilerStoreWrapper) addInvalidation(ctx context.Context, invalidation storeCacheInvalidation) {
+	if fsw.cache == nil {
+		return
+	}
+	invalidation.apply(ctx, fsw.cache)
+	if write, found := ctx.Value(storeWriteKey{}).(*storeWrite); found && write.inTransaction {
+		// a read before the commit could cache the old value again
+		write.invalidations = append(write.invalidations, invalidation)
+	}
+}
+
+// invalidateStoreCacheTree invalidates all the cached entries under the directory deleted without listing it
+func (f *Filer) invalidateStoreCacheTree(ctx context.Context, dir util.FullPath) {
+	if fsw, ok := f.Store.(*FilerStoreWrapper); ok {
+		fsw.invalidateCache(ctx, dir, true, true)
+	}
+}
+
+// invalidateStoreCache invalidates the entries changed by the metadata event
+func (f *Filer) invalidateStoreCache(event *filer_pb.SubscribeMetadataResponse) {
+	fsw, ok := f.Store.(*FilerStoreWrapper)
+	if !ok || fsw.cache == nil {
+		return
+	}
+	ctx := context.Background()
+	message := event.EventNotification
+	newParentPath := message.NewParentPath
+	if newParentPath == "" {
+		newParentPath = event.Directory
+	}
+	isRenamed := message.OldEntry != nil && message.NewEntry != nil &&
+		(event.Directory != newParentPath || message.OldEntry.Name != message.NewEntry.Name)
+	if message.OldEntry != nil {
+		// the buckets are deleted without the events of the entries in them
+		isTree := message.OldEntry.IsDirectory && (isRenamed || message.NewEntry == nil && event.Directory == f.DirBucketsPath)
+		fsw.cache.invalidate(ctx, util.NewFullPath(event.Directory, message.OldEntry.Name), message.OldEntry.IsDirectory, isTree)
+	}
+	if message.NewEntry != nil {
+		fsw.cache.invalidate(ctx, util.NewFullPath(newParentPath, message.NewEntry.Name), message.NewEntry.IsDirectory, isRenamed && message.NewEntry.IsDirectory)
+	}
+}
+
+// memoryStoreCache remembers the cached directories, to delete the values under a directory tree
+type memoryStoreCache struct {
+	cache    *ccache.LayeredCache
+	ttl      time.Duration
+	maxDirs  int
+	dirsLock sync.Mutex
+	dirs     map[string]struct{}
+}
+
+func (c *memoryStoreCache) get(ctx context.Context, dir, key string) ([]byte, bool) {
+	item := c.cache.Get(dir, key)
+	if item == nil || item.Expired() {
+		return nil, false
+	}
+	return item.Value().([]byte), true
+}
+
+func (c *memoryStoreCache) set(ctx context.Context, dir, key string, value []byte) {
+	c.dirsLock.Lock()
+	defer c.dirsLock.Unlock()
+	if _, found := c.dirs[dir]; !found && len(c.dirs) >= c.maxDirs {
+		// the directories evicted by the cache are still remembered
+		c.cache.Clear()
+		c.dirs = make(map[string]struct{})
+	}
+	c.dirs[dir] = struct{}{}
+	c.cache.Set(dir, key, value, c.ttl)
+}
+
+func (c *memoryStoreCache) delete(ctx context.Context, dir, key string) {
+	c.cache.Delete(dir, key)
+}
+
+func (c *memoryStoreCache) deleteAll(ctx context.Context, dir string) {
+	c.dirsLock.Lock()
+	defer c.dirsLock.Unlock()
+	delete(c.dirs, dir)
+	c.cache.DeleteAll(dir)
+}
+
+func (c *memoryStoreCache) deleteTree(ctx context.Context, dir string) {
+	treePrefix := strings.TrimSuffix(dir, "/") + "/"
+	c.dirsLock.Lock()
+	defer c.dirsLock.Unlock()
+	for cached := range c.dirs {
+		// the cached directories are prefixed by the kind of the values, see storeCache
+		if len(cached) > 1 && strings.HasPrefix(cached[1:], treePrefix) {
+			delete(c.dirs, cached)
+			c.cache.DeleteAll(cached)
+		}
+	}
+}
+
+// redisStoreCache keeps the values of one directory in a hash, with the expiration time before each value
+type redisStoreCache struct {
+	client    redis.UniversalClient
+	keyPrefix string
+	ttl       time.Duration
+}
+
+func (c *redisStoreCache) get(ctx context.Context, dir, key string) ([]byte, bool) {
+	data, err := c.client.HGet(ctx, c.keyPrefix+dir, key).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			glog.V(1).Infof("get cached %s %s: %v", dir, key, err)
+		}
+		return nil, false
+	}
+	if len(data) < 8 || time.Now().UnixNano() > int64(util.BytesToUint64(data[:8])) {
+		return nil, false
+	}
+	return data[8:], true
+}
+
+func (c *redisStoreCache) set(ctx context.Context, dir, key string, value []byte) {
+	data := make([]byte, 8+len(value))
+	util.Uint64toBytes(data[:8], uint64(time.Now().Add(c.ttl).UnixNano()))
+	copy(data[8:], value)
+	if _, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, c.keyPrefix+dir, key, data)
+		pipe.Expire(ctx, c.keyPrefix+dir, c.ttl)
+		return nil
+	}); err != nil {
+		glog.V(1).Infof("cache %s %s: %v", dir, key, err)
+	}
+}
+
+func (c *redisStoreCache) delete(ctx context.Context, dir, key string) {
+	if err := c.client.HDel(ctx, c.keyPrefix+dir, key).Err(); err != nil {
+		glog.Warningf("delete cached %s %s: %v", dir, key, err)
+	}
+}
+
+func (c *redisStoreCache) deleteAll(ctx context.Context, dir string) {
+	if err := c.client.Del(ctx, c.keyPrefix+dir).Err(); err != nil {
+		glog.Warningf("delete cached %s: %v", dir, err)
+	}
+}
+
+func (c *redisStoreCache) deleteTree(ctx context.Context, dir string) {
+	treePrefix := strings.TrimSuffix(dir, "/") + "/"
+	var patterns []string
+	for _, kind := range []string{"e", "l"} {
+		patterns = append(patterns, escapeRedisPattern(c.keyPrefix+kind+treePrefix)+"*")
+	}
+	deleteMatched := func(ctx context.Context, client redis.Cmdable) error {
+		for _, pattern := range patterns {
+			iter := client.Scan(ctx, 0, pattern, 1000).Iterator()
+			for iter.Next(ctx) {
+				if err := client.Del(ctx, iter.Val()).Err(); err != nil {
+					return err
+				}
+			}
+			if err := iter.Err(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var err error
+	if clusterClient, ok := c.client.(*redis.ClusterClient); ok {
+		err = clusterClient.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return deleteMatched(ctx, client)
+		})
+	} else {
+		err = deleteMatched(ctx, c.client)
+	}
+	if err != nil {
+		glog.Warningf("delete cached tree %s: %v", dir, err)
+	}
+}
+
+func escapeRedisPattern(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`\*?[]^`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
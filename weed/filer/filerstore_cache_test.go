@@ -0,0 +1,121 @@
+package filer
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreCacheFindEntry(t *testing.T) {
+
+	cache, err := newStoreCache(StoreCacheOption{Type: StoreCacheMemory, Ttl: time.Minute})
+	assert.Nil(t, err)
+	ctx := context.Background()
+
+	finds := 0
+	stored := &Entry{FullPath: "/a/b", Attr: Attr{Mode: 0644, Mime: "text/plain"}}
+	findFn := func() (*Entry, error) {
+		finds++
+		if stored == nil {
+			return nil, filer_pb.ErrNotFound
+		}
+		return stored.Clone(), nil
+	}
+
+	for i := 0; i < 3; i++ {
+		entry, err := cache.findEntry(ctx, "/a/b", findFn)
+		assert.Nil(t, err)
+		assert.Equal(t, "text/plain", entry.Mime)
+		assert.Equal(t, util.FullPath("/a/b"), entry.FullPath)
+	}
+	assert.Equal(t, 1, finds)
+
+	stored = nil
+	cache.invalidate(ctx, "/a/b", false, false)
+	for i := 0; i < 3; i++ {
+		_, err := cache.findEntry(ctx, "/a/b", findFn)
+		assert.Equal(t, filer_pb.ErrNotFound, err)
+	}
+	assert.Equal(t, 2, finds, "not found is cached")
+
+	// the entries with hard links are not cached
+	stored = &Entry{FullPath: "/a/c", HardLinkId: HardLinkId("link")}
+	cache.findEntry(ctx, "/a/c", findFn)
+	cache.findEntry(ctx, "/a/c", findFn)
+	assert.Equal(t, 4, finds)
+
+	// renaming the directory invalidates the entries under it
+	stored = &Entry{FullPath: "/a/d/e/f"}
+	cache.findEntry(ctx, "/a/d/e/f", findFn)
+	stored = &Entry{FullPath: "/a/dd/e"}
+	cache.findEntry(ctx, "/a/dd/e", findFn)
+	cache.invalidate(ctx, "/a/d", true, true)
+	cache.findEntry(ctx, "/a/d/e/f", findFn)
+	assert.Equal(t, 7, finds)
+	cache.findEntry(ctx, "/a/dd/e", findFn)
+	assert.Equal(t, 7, finds, "the entries outside the directory are kept")
+
+	// deleting the children of a directory keeps the directory and its siblings
+	stored = &Entry{FullPath: "/x/y/z"}
+	cache.findEntry(ctx, "/x/y/z", findFn)
+	stored = &Entry{FullPath: "/x/y", Attr: Attr{Mode: os.ModeDir}}
+	cache.findEntry(ctx, "/x/y", findFn)
+	stored = &Entry{FullPath: "/x/yz"}
+	cache.findEntry(ctx, "/x/yz", findFn)
+	assert.Equal(t, 10, finds)
+	cache.invalidateChildren(ctx, "/x/y")
+	cache.findEntry(ctx, "/x/y", findFn)
+	cache.findEntry(ctx, "/x/yz", findFn)
+	assert.Equal(t, 10, finds)
+	cache.findEntry(ctx, "/x/y/z", findFn)
+	assert.Equal(t, 11, finds)
+}
+
+func TestStoreCacheListing(t *testing.T) {
+
+	cache, err := newStoreCache(StoreCacheOption{Type: StoreCacheMemory, Ttl: time.Minute})
+	assert.Nil(t, err)
+	ctx := context.Background()
+
+	lists := 0
+	names := []string{"a", "b", "c"}
+	listFn := func(eachEntryFunc ListEachEntryFunc) (lastFileName string, err error) {
+		lists++
+		for _, name := range names {
+			lastFileName = name
+			if !eachEntryFunc(&Entry{FullPath: util.NewFullPath("/dir", name), Attr: Attr{Mode: 0644}}) {
+				break
+			}
+		}
+		return lastFileName, nil
+	}
+	list := func(limit int) (listed []string, lastFileName string) {
+		lastFileName, err := cache.listDirectoryEntries(ctx, "/dir", "", false, 100, "", func(entry *Entry) bool {
+			listed = append(listed, string(entry.FullPath))
+			return len(listed) < limit
+		}, listFn)
+		assert.Nil(t, err)
+		return
+	}
+
+	listed, lastFileName := list(10)
+	assert.Equal(t, []string{"/dir/a", "/dir/b", "/dir/c"}, listed)
+	assert.Equal(t, "c", lastFileName)
+
+	listed, lastFileName = list(2)
+	assert.Equal(t, []string{"/dir/a", "/dir/b"}, listed)
+	assert.Equal(t, "b", lastFileName)
+	assert.Equal(t, 1, lists)
+
+	// a change in the directory invalidates its listings
+	names = append(names, "d")
+	cache.invalidate(ctx, "/dir/d", false, false)
+	listed, _ = list(10)
+	assert.Equal(t, 4, len(listed))
+	assert.Equal(t, 2, lists)
+}
@@ -0,0 +1,247 @@
+package filer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+A huge flat directory can be sharded, to keep the number of entries under each directory in the filer store bounded.
+
+The children of a sharded directory are stored under hashed sub directories,
+
+	/path/to/dir/file.txt => /path/to/dir/.dirshard.0007/file.txt
+
+while the filer still presents one flat directory, by merging the listings of all the shards.
+The sub directories only exist in the filer store, and are not visible to the clients.
+
+The directory is sharded by filer.conf, for the exact directory and not its sub directories, e.g.
+
+	fs.configure -locationPrefix=/path/to/dir/ -dirShards=64 -apply
+
+The shard count should only be set or changed when the directory is empty,
+otherwise the existing entries are not found.
+
+A sharded directory renamed with AtomicRenameEntry keeps its shards: the shard count is also configured
+for the new path before its entries are moved one by one, and removed from the old path afterwards.
+*/
+
+const DirShardPrefix = ".dirshard."
+
+func (f *Filer) dirShardCount(dir string) int {
+	if f.FilerConf == nil {
+		return 0
+	}
+	return f.FilerConf.DirShardCount(dir)
+}
+
+// shardCount is the number of shards of the directory, 0 if not sharded
+func (fsw *FilerStoreWrapper) shardCount(dir util.FullPath) int {
+	if fsw.dirShardCount == nil {
+		return 0
+	}
+	if n := fsw.dirShardCount(string(dir)); n > 1 {
+		return n
+	}
+	return 0
+}
+
+func shardDirectory(dir util.FullPath, shard uint32) util.FullPath {
+	return dir.Child(fmt.Sprintf("%s%04d", DirShardPrefix, shard))
+}
+
+// physicalPath is the path of the entry in the filer store
+func (fsw *FilerStoreWrapper) physicalPath(fp util.FullPath) util.FullPath {
+	dir, name := fp.DirAndName()
+	n := fsw.shardCount(util.FullPath(dir))
+	if n == 0 {
+		return fp
+	}
+	return shardDirectory(util.FullPath(dir), crc32.ChecksumIEEE([]byte(name))%uint32(n)).Child(name)
+}
+
+func (fsw *FilerStoreWrapper) physicalEntry(entry *Entry) *Entry {
+	fp := fsw.physicalPath(entry.FullPath)
+	if fp == entry.FullPath {
+		return entry
+	}
+	physical := *entry
+	physical.FullPath = fp
+	return &physical
+}
+
+// listShards merges the entries of each shard, in the order of the file names.
+// Each shard is listed by small pages when needed, so only about one page of each shard is loaded at a time.
+func listShards(dirPath util.FullPath, shardCount int, startFileName string, includeStartFile bool, limit int64, listFn func(shardDir util.FullPath, startFileName string, includeStartFile bool, limit int64, eachEntryFunc ListEachEntryFunc) error, eachEntryFunc ListEachEntryFunc) (lastFileName string, err error) {
+
+	pageSize := limit/int64(shardCount) + 1
+	if pageSize > maxShardPageSize {
+		pageSize = maxShardPageSize
+	}
+
+	cursors := make([]*shardCursor, shardCount)
+	for shard := range cursors {
+		cursors[shard] = &shardCursor{dir: shardDirectory(dirPath, uint32(shard)), startFileName: startFileName, includeStartFile: includeStartFile}
+	}
+
+	for count := int64(0); count < limit; count++ {
+		var next *shardCursor
+		for _, c := range cursors {
+			if err = c.fill(pageSize, listFn); err != nil {
+				return "", err
+			}
+			if len(c.entries) > 0 && (next == nil || c.entries[0].Name() < next.entries[0].Name()) {
+				next = c
+			}
+		}
+		if next == nil {
+			break
+		}
+		entry := next.entries[0]
+		next.entries = next.entries[1:]
+		entry.FullPath = dirPath.Child(entry.Name())
+		lastFileName = entry.Name()
+		if !eachEntryFunc(entry) {
+			break
+		}
+	}
+	return
+}
+
+const maxShardPageSize = 1024
+
+// shardCursor is the listing position in one shard
+type shardCursor struct {
+	dir              util.FullPath
+	startFileName    string
+	includeStartFile bool
+	entries          []*Entry
+	isExhausted      bool
+}
+
+// fill lists the next page of the shard, after the loaded entries are all consumed
+func (c *shardCursor) fill(pageSize int64, listFn func(shardDir util.FullPath, startFileName string, includeStartFile bool, limit int64, eachEntryFunc ListEachEntryFunc) error) error {
+	if len(c.entries) > 0 || c.isExhausted {
+		return nil
+	}
+	if err := listFn(c.dir, c.startFileName, c.includeStartFile, pageSize, func(entry *Entry) bool {
+		c.entries = append(c.entries, entry)
+		return true
+	}); err != nil {
+		return err
+	}
+	c.isExhausted = int64(len(c.entries)) < pageSize
+	if len(c.entries) > 0 {
+		c.startFileName, c.includeStartFile = c.entries[len(c.entries)-1].Name(), false
+	}
+	return nil
+}
+
+// CopyDirShards configures the shards of a directory being renamed, and of its sharded sub directories, also for the new path.
+// So the entries moved one by one are sharded the same way under the new path.
+// It returns the sharded locations under the old path, and the locations added under the new path.
+func (f *Filer) CopyDirShards(ctx context.Context, oldDir, newDir util.FullPath) (oldLocations, newLocations []string, err error) {
+	if f.FilerConf == nil {
+		return nil, nil, nil
+	}
+	fc := NewFilerConf()
+	if err = fc.doLoadConf(f.FilerConf.ToProto()); err != nil {
+		return nil, nil, err
+	}
+	oldPrefix := string(oldDir) + "/"
+	for _, location := range f.FilerConf.ToProto().Locations {
+		if location.DirectoryShards <= 1 || !strings.HasPrefix(location.LocationPrefix, oldPrefix) {
+			continue
+		}
+		oldLocations = append(oldLocations, location.LocationPrefix)
+		newLocation := string(newDir) + "/" + location.LocationPrefix[len(oldPrefix):]
+		if fc.DirShardCount(newLocation) > 0 {
+			continue
+		}
+		pathConf := &filer_pb.FilerConf_PathConf{LocationPrefix: newLocation}
+		if existing, found := fc.GetLocationConf(newLocation); found {
+			pathConf = proto.Clone(existing).(*filer_pb.FilerConf_PathConf)
+		}
+		pathConf.DirectoryShards = location.DirectoryShards
+		fc.AddLocationConf(pathConf)
+		newLocations = append(newLocations, newLocation)
+	}
+	if len(newLocations) == 0 {
+		return oldLocations, nil, nil
+	}
+	if err = f.saveFilerConf(ctx, fc); err != nil {
+		return nil, nil, fmt.Errorf("copy dir shards of %s to %s: %v", oldDir, newDir, err)
+	}
+	glog.V(0).Infof("dir shards of %s copied to %v", oldDir, newLocations)
+	return
+}
+
+// DeleteDirShards removes the shards configured for the locations, keeping their other options.
+func (f *Filer) DeleteDirShards(ctx context.Context, locations []string) error {
+	if f.FilerConf == nil || len(locations) == 0 {
+		return nil
+	}
+	fc := NewFilerConf()
+	if err := fc.doLoadConf(f.FilerConf.ToProto()); err != nil {
+		return err
+	}
+	for _, location := range locations {
+		existing, found := fc.GetLocationConf(location)
+		if !found {
+			continue
+		}
+		pathConf := proto.Clone(existing).(*filer_pb.FilerConf_PathConf)
+		pathConf.DirectoryShards = 0
+		if proto.Equal(pathConf, &filer_pb.FilerConf_PathConf{LocationPrefix: location}) {
+			fc.DeleteLocationConf(location)
+		} else {
+			fc.AddLocationConf(pathConf)
+		}
+	}
+	if err := f.saveFilerConf(ctx, fc); err != nil {
+		return fmt.Errorf("delete dir shards of %v: %v", locations, err)
+	}
+	return nil
+}
+
+// saveFilerConf writes the filer configuration, and uses it right away
+func (f *Filer) saveFilerConf(ctx context.Context, fc *FilerConf) error {
+	var buf bytes.Buffer
+	if err := fc.ToText(&buf); err != nil {
+		return err
+	}
+
+	p := util.NewFullPath(DirectoryEtcSeaweedFS, FilerConfName)
+	now := time.Now()
+	entry, err := f.FindEntry(ctx, p)
+	if err == filer_pb.ErrNotFound {
+		entry = &Entry{FullPath: p, Attr: Attr{Crtime: now, Mode: 0644}}
+	} else if err != nil {
+		return err
+	} else {
+		entry = cloneEntryExtended(entry)
+	}
+	oldChunks := entry.Chunks
+	entry.Chunks = nil
+	entry.Content = buf.Bytes()
+	entry.FileSize = uint64(buf.Len())
+	entry.Mtime = now
+	entry.Md5, entry.Sha256 = nil, nil
+
+	if err = f.CreateEntry(ctx, entry, false, false, nil); err != nil {
+		return err
+	}
+	f.DeleteChunks(oldChunks)
+	f.FilerConf = fc
+	return nil
+}
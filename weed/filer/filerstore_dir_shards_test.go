@@ -0,0 +1,104 @@
+package filer_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	leveldb2 "github.com/chrislusf/seaweedfs/weed/filer/leveldb2"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func TestShardedDirectory(t *testing.T) {
+	store := &leveldb2.LevelDB2Store{}
+	testFiler := newTestFiler(t, store)
+	testFiler.FilerConf.AddLocationConf(&filer_pb.FilerConf_PathConf{
+		LocationPrefix:  "/flat/",
+		DirectoryShards: 4,
+	})
+
+	ctx := context.Background()
+
+	var names []string
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("file%02d", i)
+		names = append(names, name)
+		if err := testFiler.CreateEntry(ctx, &filer.Entry{
+			FullPath: util.NewFullPath("/flat", name),
+			Attr:     filer.Attr{Mode: 0644},
+		}, false, false, nil); err != nil {
+			t.Fatalf("create entry %s: %v", name, err)
+		}
+	}
+
+	// the entries are not stored directly under the directory
+	count := 0
+	store.ListDirectoryEntries(ctx, "/flat", "", false, 100, func(entry *filer.Entry) bool {
+		count++
+		return true
+	})
+	if count != 0 {
+		t.Errorf("unexpected %d entries stored directly under the sharded directory", count)
+	}
+
+	// list page by page
+	var listed []string
+	lastFileName := ""
+	for {
+		entries, _, err := testFiler.ListDirectoryEntries(ctx, "/flat", lastFileName, false, 7, "", "")
+		if err != nil {
+			t.Fatalf("list entries: %v", err)
+		}
+		for _, entry := range entries {
+			if entry.FullPath != util.NewFullPath("/flat", entry.Name()) {
+				t.Errorf("unexpected listed path %s", entry.FullPath)
+			}
+			listed = append(listed, entry.Name())
+			lastFileName = entry.Name()
+		}
+		if len(entries) < 7 {
+			break
+		}
+	}
+	if strings.Join(listed, ",") != strings.Join(names, ",") {
+		t.Errorf("listed %v", listed)
+	}
+
+	entries, _, _ := testFiler.ListDirectoryEntries(ctx, "/flat", "", false, 100, "file1", "")
+	if len(entries) != 10 {
+		t.Errorf("listed %d entries with prefix", len(entries))
+	}
+
+	entry, err := testFiler.FindEntry(ctx, "/flat/file07")
+	if err != nil || entry.FullPath != "/flat/file07" {
+		t.Errorf("find entry: %v %v", entry, err)
+	}
+
+	if err := testFiler.DeleteEntryMetaAndData(ctx, "/flat/file07", false, false, false, false, nil); err != nil {
+		t.Fatalf("delete entry: %v", err)
+	}
+	if _, err := testFiler.FindEntry(ctx, "/flat/file07"); err != filer_pb.ErrNotFound {
+		t.Errorf("find deleted entry: %v", err)
+	}
+
+	// the shards are carried to the new path of a renamed directory
+	oldShards, newShards, err := testFiler.CopyDirShards(ctx, "/flat", "/renamed")
+	if err != nil || strings.Join(oldShards, ",") != "/flat/" || strings.Join(newShards, ",") != "/renamed/" {
+		t.Fatalf("copy dir shards: %v %v %v", oldShards, newShards, err)
+	}
+	if n := testFiler.FilerConf.DirShardCount("/renamed"); n != 4 {
+		t.Errorf("renamed directory has %d shards", n)
+	}
+	if err = testFiler.DeleteDirShards(ctx, oldShards); err != nil {
+		t.Fatalf("delete dir shards: %v", err)
+	}
+	if n := testFiler.FilerConf.DirShardCount("/flat"); n != 0 {
+		t.Errorf("old directory still has %d shards", n)
+	}
+	if _, err = testFiler.FindEntry(ctx, util.NewFullPath(filer.DirectoryEtcSeaweedFS, filer.FilerConfName)); err != nil {
+		t.Errorf("find saved filer conf: %v", err)
+	}
+}
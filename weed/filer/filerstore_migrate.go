@@ -0,0 +1,508 @@
+package filer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+The default filer store can be migrated to another store while the filer keeps serving, e.g. from leveldb2 to postgres.
+
+The store wrapper starts tracking the paths and the kv keys changed in the default store,
+then all the entries are copied as they are in the filer store, including the dir shards.
+The changed paths and keys are copied again, by reading their latest values, until only a few are left.
+To switch, the writes are paused until the last changes are copied, and the target store replaces the default store.
+
+The writes of a transaction are tracked when it is committed. The open transactions do not hold the write lock,
+and the migration waits for them to end before tracking the changes and before switching, with a timeout.
+The kv keys can not be listed, so the well known keys, the keys registered in the kv key index, see filer_kv_keys.go,
+the keys of the entries, i.e. the hard links and the dedup index, and the changed keys are copied.
+The path specific stores are not changed.
+
+The switch is refused while any peer filer uses the same store, since the peers would keep writing to the old store.
+The switch is persisted before the store is replaced, e.g. in filer.toml, so the filer restarts with the new store.
+*/
+
+const (
+	StoreMigrationCopying    = "copying"
+	StoreMigrationCatchingUp = "catching up"
+	StoreMigrationSwitching  = "switching"
+	StoreMigrationDone       = "done"
+
+	storeMigrationListLimit       = 1024
+	storeMigrationReportEntries   = 10000
+	storeMigrationSwitchChanges   = 1000
+	storeMigrationShutdownTimeout = time.Minute
+	storeMigrationTxTimeout       = 10 * time.Second
+)
+
+type StoreMigrationProgress struct {
+	Phase          string
+	CopiedEntries  int64
+	CopiedKeys     int64
+	PendingChanges int64
+}
+
+// StoreMigrationHooks are the steps of the migration which need the filer
+type StoreMigrationHooks struct {
+	KvKeys        func(ctx context.Context) ([][]byte, error)               // the kv keys not of any entries
+	EntryKvKeys   func(ctx context.Context, entry *Entry) ([][]byte, error) // the kv keys of the entry, besides the hard link
+	CheckSwitch   func() error                                              // before pausing the writes to switch
+	PersistSwitch func() error                                              // with the writes paused, before switching
+}
+
+type storeMigration struct {
+	target     FilerStore
+	hooks      *StoreMigrationHooks
+	changes    map[string]storeChange
+	copiedKeys map[string]bool // the keys of the entries already copied, the later changes are tracked
+	sync.Mutex
+}
+
+// storeChange is an entry path, a folder whose children were deleted, or a kv key
+type storeChange struct {
+	path     util.FullPath
+	isFolder bool
+	key      []byte
+}
+
+func (c storeChange) id() string {
+	if c.key != nil {
+		return "k" + string(c.key)
+	}
+	if c.isFolder {
+		return "f" + string(c.path)
+	}
+	return "e" + string(c.path)
+}
+
+func (c storeChange) String() string {
+	if c.key != nil {
+		return fmt.Sprintf("key %x", c.key)
+	}
+	return string(c.path)
+}
+
+func (m *storeMigration) add(change storeChange) {
+	m.Lock()
+	defer m.Unlock()
+	m.changes[change.id()] = change
+}
+
+func (m *storeMigration) takeChanges() (changes []storeChange) {
+	m.Lock()
+	defer m.Unlock()
+	for _, change := range m.changes {
+		changes = append(changes, change)
+	}
+	m.changes = make(map[string]storeChange)
+	return
+}
+
+func (m *storeMigration) pendingCount() int64 {
+	m.Lock()
+	defer m.Unlock()
+	return int64(len(m.changes))
+}
+
+type storeWriteKey struct{}
+
+// storeWrite is kept in the context of a write or a transaction, which holds the write lock
+type storeWrite struct {
+	inTransaction bool
+	isEnded       bool
+	changes       []storeChange
+	invalidations []storeCacheInvalidation
+}
+
+// startWrite waits while the writes are paused. The nested writes with the returned context do not wait again.
+func (fsw *FilerStoreWrapper) startWrite(ctx context.Context) (context.Context, func()) {
+	if _, found := ctx.Value(storeWriteKey{}).(*storeWrite); found {
+		return ctx, func() {}
+	}
+	fsw.writeLock.RLock()
+	return context.WithValue(ctx, storeWriteKey{}, &storeWrite{}), fsw.writeLock.RUnlock
+}
+
+// startTransaction waits while the writes are paused, and counts the transaction as open until it is
+// committed or rolled back. The write lock is not held, so a write of another context in the transaction does not block.
+func (fsw *FilerStoreWrapper) startTransaction(ctx context.Context) (context.Context, func()) {
+	if _, found := ctx.Value(storeWriteKey{}).(*storeWrite); found {
+		return ctx, func() {}
+	}
+	fsw.writeLock.RLock()
+	atomic.AddInt64(&fsw.openTransactions, 1)
+	fsw.writeLock.RUnlock()
+	write := &storeWrite{inTransaction: true}
+	return context.WithValue(ctx, storeWriteKey{}, write), func() {
+		write.isEnded = true
+		atomic.AddInt64(&fsw.openTransactions, -1)
+	}
+}
+
+func (fsw *FilerStoreWrapper) endTransaction(ctx context.Context) {
+	write, found := ctx.Value(storeWriteKey{}).(*storeWrite)
+	if !found || !write.inTransaction || write.isEnded {
+		return
+	}
+	write.isEnded = true
+	if migration := fsw.getMigration(); migration != nil {
+		for _, change := range write.changes {
+			migration.add(change)
+		}
+	}
+	for _, invalidation := range write.invalidations {
+		invalidation.apply(ctx, fsw.cache)
+	}
+	atomic.AddInt64(&fsw.openTransactions, -1)
+}
+
+// pauseWrites holds the write lock, and waits for the open transactions to end.
+// The transactions are not waited for longer than storeMigrationTxTimeout, in case one waits for a paused write.
+func (fsw *FilerStoreWrapper) pauseWrites() error {
+	fsw.writeLock.Lock()
+	deadline := time.Now().Add(storeMigrationTxTimeout)
+	for atomic.LoadInt64(&fsw.openTransactions) > 0 {
+		if time.Now().After(deadline) {
+			fsw.writeLock.Unlock()
+			return fmt.Errorf("transactions still open after %v", storeMigrationTxTimeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil
+}
+
+func (fsw *FilerStoreWrapper) getMigration() *storeMigration {
+	fsw.migrationLock.Lock()
+	defer fsw.migrationLock.Unlock()
+	return fsw.migration
+}
+
+// setMigration is called with the write lock held
+func (fsw *FilerStoreWrapper) setMigration(migration *storeMigration) {
+	fsw.migrationLock.Lock()
+	defer fsw.migrationLock.Unlock()
+	fsw.migration = migration
+}
+
+// trackChange is called after a successful write, with the write lock held or in a transaction.
+func (fsw *FilerStoreWrapper) trackChange(ctx context.Context, store FilerStore, change storeChange) {
+	migration := fsw.getMigration()
+	if migration == nil || store != fsw.getDefaultStore() {
+		return
+	}
+	if write, found := ctx.Value(storeWriteKey{}).(*storeWrite); found && write.inTransaction {
+		// a read before the commit would still see the old value
+		write.changes = append(write.changes, change)
+		return
+	}
+	migration.add(change)
+}
+
+// MigrateStore copies the default filer store to the target store, and switches to the target store if switchStore is set.
+// The switch is persisted with persistSwitch, e.g. in filer.toml, while the writes are paused.
+// The target store is shut down if not switched to.
+func (f *Filer) MigrateStore(ctx context.Context, target FilerStore, switchStore bool, persistSwitch func() error, progressFn func(progress StoreMigrationProgress) error) error {
+	fsw, ok := f.Store.(*FilerStoreWrapper)
+	if !ok {
+		target.Shutdown()
+		return fmt.Errorf("filer store %s can not be migrated", f.Store.GetName())
+	}
+	if switchStore {
+		if err := f.checkStoreNotShared(); err != nil {
+			target.Shutdown()
+			return err
+		}
+	}
+	return fsw.migrateDefaultStore(ctx, target, switchStore, &StoreMigrationHooks{
+		KvKeys:        f.storeMigrationKvKeys,
+		EntryKvKeys:   f.entryKvKeys,
+		CheckSwitch:   f.checkStoreNotShared,
+		PersistSwitch: persistSwitch,
+	}, progressFn)
+}
+
+// checkStoreNotShared returns an error if any peer filer uses the same filer store
+func (f *Filer) checkStoreNotShared() error {
+	if f.MetaAggregator == nil {
+		return nil
+	}
+	for _, peer := range f.MetaAggregator.filers {
+		if peer == f.MetaAggregator.self {
+			continue
+		}
+		signature, err := f.MetaAggregator.readFilerStoreSignature(peer)
+		if err != nil {
+			return fmt.Errorf("read the filer store of filer %s: %v", peer, err)
+		}
+		if signature == f.Signature {
+			return fmt.Errorf("filer %s also uses the filer store, stop it before switching", peer)
+		}
+	}
+	return nil
+}
+
+func (fsw *FilerStoreWrapper) migrateDefaultStore(ctx context.Context, target FilerStore, switchStore bool, hooks *StoreMigrationHooks, progressFn func(progress StoreMigrationProgress) error) (err error) {
+
+	migration := &storeMigration{
+		target:     target,
+		hooks:      hooks,
+		changes:    make(map[string]storeChange),
+		copiedKeys: make(map[string]bool),
+	}
+
+	// wait for the writes and the transactions in progress, which would not be tracked
+	if err = fsw.pauseWrites(); err != nil {
+		target.Shutdown()
+		return err
+	}
+	if current := fsw.getMigration(); current != nil {
+		fsw.writeLock.Unlock()
+		target.Shutdown()
+		return fmt.Errorf("already migrating to %s", current.target.GetName())
+	}
+	fsw.setMigration(migration)
+	fsw.writeLock.Unlock()
+
+	source := fsw.getDefaultStore()
+	isSwitched := false
+	defer func() {
+		if isSwitched {
+			return
+		}
+		fsw.writeLock.Lock()
+		fsw.setMigration(nil)
+		fsw.writeLock.Unlock()
+		target.Shutdown()
+	}()
+
+	glog.V(0).Infof("migrating filer store %s to %s", source.GetName(), target.GetName())
+
+	progress := &StoreMigrationProgress{Phase: StoreMigrationCopying}
+	report := func() error {
+		progress.PendingChanges = migration.pendingCount()
+		return progressFn(*progress)
+	}
+
+	if err = report(); err != nil {
+		return err
+	}
+	keys := [][]byte{[]byte(FilerStoreId)}
+	if migration.hooks != nil && migration.hooks.KvKeys != nil {
+		moreKeys, keysErr := migration.hooks.KvKeys(ctx)
+		if keysErr != nil {
+			return fmt.Errorf("kv keys: %v", keysErr)
+		}
+		keys = append(keys, moreKeys...)
+	}
+	for _, key := range keys {
+		if err = migration.copyKey(ctx, source, key, progress); err != nil {
+			return err
+		}
+	}
+	if err = fsw.copyFolder(ctx, migration, source, "/", true, progress, report); err != nil {
+		return err
+	}
+
+	progress.Phase = StoreMigrationCatchingUp
+	for {
+		if err = fsw.copyChanges(ctx, migration, source, migration.takeChanges(), progress); err != nil {
+			return err
+		}
+		if err = report(); err != nil {
+			return err
+		}
+		if progress.PendingChanges <= storeMigrationSwitchChanges {
+			break
+		}
+	}
+
+	if !switchStore {
+		progress.Phase = StoreMigrationDone
+		glog.V(0).Infof("copied filer store %s to %s: %d entries, %d keys", source.GetName(), target.GetName(), progress.CopiedEntries, progress.CopiedKeys)
+		return report()
+	}
+
+	if hooks != nil && hooks.CheckSwitch != nil {
+		if err = hooks.CheckSwitch(); err != nil {
+			return err
+		}
+	}
+
+	progress.Phase = StoreMigrationSwitching
+	if err = report(); err != nil {
+		return err
+	}
+
+	if err = fsw.pauseWrites(); err != nil {
+		return err
+	}
+	if err = fsw.copyChanges(ctx, migration, source, migration.takeChanges(), progress); err != nil {
+		fsw.writeLock.Unlock()
+		return err
+	}
+	if hooks != nil && hooks.PersistSwitch != nil {
+		if err = hooks.PersistSwitch(); err != nil {
+			fsw.writeLock.Unlock()
+			return fmt.Errorf("persist the switch: %v", err)
+		}
+	}
+	fsw.storeLock.Lock()
+	fsw.defaultStore = target
+	fsw.storeLock.Unlock()
+	fsw.setMigration(nil)
+	isSwitched = true
+	fsw.writeLock.Unlock()
+
+	glog.V(0).Infof("switched filer store from %s to %s: %d entries, %d keys", source.GetName(), target.GetName(), progress.CopiedEntries, progress.CopiedKeys)
+
+	// the reads in progress may still use the source store
+	time.AfterFunc(storeMigrationShutdownTimeout, source.Shutdown)
+
+	progress.Phase = StoreMigrationDone
+	return report()
+}
+
+// copyFolder copies the children of the folder, and the children of its dir shards.
+// The entries are copied with their paths in the filer store, while the sub folders are visited by their paths in the filer.
+func (fsw *FilerStoreWrapper) copyFolder(ctx context.Context, migration *storeMigration, source FilerStore, dir util.FullPath, isRecursive bool, progress *StoreMigrationProgress, report func() error) error {
+
+	physicalDirs := []util.FullPath{dir}
+	for shard := 0; shard < fsw.shardCount(dir); shard++ {
+		physicalDirs = append(physicalDirs, shardDirectory(dir, uint32(shard)))
+	}
+
+	for _, physicalDir := range physicalDirs {
+		lastFileName := ""
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			var subDirs []util.FullPath
+			var copyErr error
+			count := 0
+			_, err := source.ListDirectoryEntries(ctx, physicalDir, lastFileName, false, storeMigrationListLimit, func(entry *Entry) bool {
+				count++
+				lastFileName = entry.Name()
+				if copyErr = migration.copyEntry(ctx, source, entry, progress); copyErr != nil {
+					return false
+				}
+				if isRecursive && entry.IsDirectory() {
+					subDirs = append(subDirs, dir.Child(entry.Name()))
+				}
+				return true
+			})
+			if err == nil {
+				err = copyErr
+			}
+			if err != nil {
+				return fmt.Errorf("copy %s: %v", physicalDir, err)
+			}
+			if progress.CopiedEntries/storeMigrationReportEntries != (progress.CopiedEntries-int64(count))/storeMigrationReportEntries {
+				if err = report(); err != nil {
+					return err
+				}
+			}
+			for _, subDir := range subDirs {
+				if err = fsw.copyFolder(ctx, migration, source, subDir, true, progress, report); err != nil {
+					return err
+				}
+			}
+			if count < storeMigrationListLimit {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// copyChanges copies the latest values of the changed entries and keys.
+func (fsw *FilerStoreWrapper) copyChanges(ctx context.Context, migration *storeMigration, source FilerStore, changes []storeChange, progress *StoreMigrationProgress) error {
+	target := migration.target
+	noReport := func() error { return nil }
+	for _, change := range changes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var err error
+		switch {
+		case change.key != nil:
+			err = migration.copyKey(ctx, source, change.key, progress)
+		case change.isFolder:
+			// the children are deleted in the same way as in the source store, then the remaining children are copied
+			if err = target.DeleteFolderChildren(ctx, change.path); err == nil {
+				err = fsw.copyFolder(ctx, migration, source, change.path, false, progress, noReport)
+			}
+		default:
+			var entry *Entry
+			entry, err = source.FindEntry(ctx, change.path)
+			if err == filer_pb.ErrNotFound {
+				err = target.DeleteEntry(ctx, change.path)
+			} else if err == nil {
+				err = migration.copyEntry(ctx, source, entry, progress)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("copy changed %s: %v", change, err)
+		}
+	}
+	return nil
+}
+
+// copyEntry copies the entry, and its kv keys not copied yet
+func (m *storeMigration) copyEntry(ctx context.Context, source FilerStore, entry *Entry, progress *StoreMigrationProgress) error {
+	if err := m.target.InsertEntry(ctx, entry); err != nil {
+		return fmt.Errorf("insert %s: %v", entry.FullPath, err)
+	}
+	progress.CopiedEntries++
+	var keys [][]byte
+	if len(entry.HardLinkId) != 0 {
+		keys = append(keys, entry.HardLinkId)
+	}
+	if m.hooks != nil && m.hooks.EntryKvKeys != nil {
+		entryKeys, err := m.hooks.EntryKvKeys(ctx, entry)
+		if err != nil {
+			return fmt.Errorf("kv keys of %s: %v", entry.FullPath, err)
+		}
+		keys = append(keys, entryKeys...)
+	}
+	for _, key := range keys {
+		if m.copiedKeys[string(key)] {
+			continue
+		}
+		if err := m.copyKey(ctx, source, key, progress); err != nil {
+			return err
+		}
+		m.copiedKeys[string(key)] = true
+	}
+	return nil
+}
+
+func (m *storeMigration) copyKey(ctx context.Context, source FilerStore, key []byte, progress *StoreMigrationProgress) error {
+	target := m.target
+	value, err := source.KvGet(ctx, key)
+	if err == ErrKvNotImplemented {
+		return nil
+	}
+	if err == ErrKvNotFound {
+		if err = target.KvDelete(ctx, key); err == ErrKvNotFound {
+			err = nil
+		}
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("read key %x: %v", key, err)
+	}
+	if err = target.KvPut(ctx, key, value); err != nil {
+		return fmt.Errorf("put key %x: %v", key, err)
+	}
+	progress.CopiedKeys++
+	return nil
+}
@@ -0,0 +1,103 @@
+package filer_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	leveldb2 "github.com/chrislusf/seaweedfs/weed/filer/leveldb2"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func TestMigrateStore(t *testing.T) {
+	testFiler := newTestFiler(t, &leveldb2.LevelDB2Store{})
+
+	ctx := context.Background()
+	for i := 0; i < 20; i++ {
+		entry := &filer.Entry{
+			FullPath: util.FullPath(fmt.Sprintf("/home/dir%d/file%02d", i%3, i)),
+			Attr:     filer.Attr{Mode: 0440, Mtime: time.Now()},
+		}
+		if err := testFiler.CreateEntry(ctx, entry, false, false, nil); err != nil {
+			t.Fatalf("create entry %v: %v", entry.FullPath, err)
+		}
+	}
+
+	// the kv keys registered, and of the deduped chunks
+	syncKey := []byte("sync.\x00\x01\x02\x03")
+	if err := testFiler.KvPutRegistered(ctx, syncKey, []byte("offset")); err != nil {
+		t.Fatalf("put registered key: %v", err)
+	}
+	fingerprint := filer.DedupFingerprint([]byte("content sha256"), "", "", "")
+	dedupKey := []byte(fmt.Sprintf("dedup.%x", fingerprint))
+	testFiler.Store.KvPut(ctx, dedupKey, []byte("record"))
+	if err := testFiler.CreateEntry(ctx, &filer.Entry{
+		FullPath: "/home/dir0/deduped",
+		Attr:     filer.Attr{Mode: 0440},
+		Chunks:   []*filer_pb.FileChunk{{FileId: "1,abc", Size: 1, Fingerprint: fingerprint}},
+	}, false, false, nil); err != nil {
+		t.Fatalf("create deduped entry: %v", err)
+	}
+
+	target := &leveldb2.LevelDB2Store{}
+	initTestStore(t, target)
+
+	var phases []string
+	isPersisted := false
+	persistSwitch := func() error {
+		isPersisted = true
+		return nil
+	}
+	err := testFiler.MigrateStore(ctx, target, true, persistSwitch, func(progress filer.StoreMigrationProgress) error {
+		if len(phases) == 0 || phases[len(phases)-1] != progress.Phase {
+			phases = append(phases, progress.Phase)
+		}
+		if progress.Phase == filer.StoreMigrationCatchingUp && len(phases) == 2 {
+			// changed after the copy
+			if err := testFiler.CreateEntry(ctx, &filer.Entry{FullPath: "/home/dir0/new", Attr: filer.Attr{Mode: 0440}}, false, false, nil); err != nil {
+				return err
+			}
+			return testFiler.DeleteEntryMetaAndData(ctx, "/home/dir1/file01", false, false, false, false, nil)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if strings.Join(phases, ",") != "copying,catching up,switching,done" {
+		t.Errorf("phases %v", phases)
+	}
+
+	if _, err := target.FindEntry(ctx, "/home/dir0/new"); err != nil {
+		t.Errorf("find entry created after the copy: %v", err)
+	}
+	if _, err := target.FindEntry(ctx, "/home/dir1/file01"); err != filer_pb.ErrNotFound {
+		t.Errorf("entry deleted after the copy is migrated: %v", err)
+	}
+	for _, p := range []util.FullPath{"/home", "/home/dir2", "/home/dir2/file17"} {
+		if _, err := target.FindEntry(ctx, p); err != nil {
+			t.Errorf("find migrated %s: %v", p, err)
+		}
+	}
+
+	for _, key := range [][]byte{syncKey, dedupKey} {
+		if _, err := target.KvGet(ctx, key); err != nil {
+			t.Errorf("kv key %q not migrated: %v", key, err)
+		}
+	}
+	if !isPersisted {
+		t.Errorf("switch not persisted")
+	}
+
+	entries, _, _ := testFiler.ListDirectoryEntries(ctx, "/home/dir0", "", false, 100, "", "")
+	if len(entries) != 9 {
+		t.Errorf("listed %d migrated entries", len(entries))
+	}
+	if testFiler.Store.GetName() != target.GetName() {
+		t.Errorf("not switched")
+	}
+}
@@ -106,6 +106,15 @@ func (t *FilerStorePathTranlator) DeleteFolderChildren(ctx context.Context, fp u
 	return t.actualStore.DeleteFolderChildren(ctx, newFullPath)
 }
 
+func (t *FilerStorePathTranlator) RenameDirectory(ctx context.Context, oldPath, newPath util.FullPath) (err error) {
+	renamer, ok := t.actualStore.(DirectoryRenamer)
+	if !ok {
+		return ErrUnsupportedDirectoryRename
+	}
+
+	return renamer.RenameDirectory(ctx, t.translatePath(oldPath), t.translatePath(newPath))
+}
+
 func (t *FilerStorePathTranlator) ListDirectoryEntries(ctx context.Context, dirPath util.FullPath, startFileName string, includeStartFile bool, limit int64, eachEntryFunc ListEachEntryFunc) (string, error) {
 
 	newFullPath := t.translatePath(dirPath)
@@ -5,6 +5,7 @@ import (
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/viant/ptrie"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
@@ -26,9 +27,17 @@ type VirtualFilerStore interface {
 }
 
 type FilerStoreWrapper struct {
-	defaultStore   FilerStore
-	pathToStore    ptrie.Trie
-	storeIdToStore map[string]FilerStore
+	defaultStore     FilerStore
+	pathToStore      ptrie.Trie
+	storeIdToStore   map[string]FilerStore
+	dirShardCount    func(dir string) int
+	writableCheck    func(p util.FullPath) error
+	storeLock        sync.RWMutex // guards switching the defaultStore
+	writeLock        sync.RWMutex // held exclusively to pause the writes, see filerstore_migrate.go
+	openTransactions int64
+	migration        *storeMigration
+	migrationLock    sync.Mutex
+	cache            *storeCache
 }
 
 func NewFilerStoreWrapper(store FilerStore) *FilerStoreWrapper {
@@ -48,7 +57,7 @@ func (fsw *FilerStoreWrapper) OnBucketCreation(bucket string) {
 			ba.OnBucketCreation(bucket)
 		}
 	}
-	if ba, ok := fsw.defaultStore.(BucketAware); ok {
+	if ba, ok := fsw.getDefaultStore().(BucketAware); ok {
 		ba.OnBucketCreation(bucket)
 	}
 }
@@ -58,7 +67,7 @@ func (fsw *FilerStoreWrapper) OnBucketDeletion(bucket string) {
 			ba.OnBucketDeletion(bucket)
 		}
 	}
-	if ba, ok := fsw.defaultStore.(BucketAware); ok {
+	if ba, ok := fsw.getDefaultStore().(BucketAware); ok {
 		ba.OnBucketDeletion(bucket)
 	}
 }
@@ -72,7 +81,7 @@ func (fsw *FilerStoreWrapper) AddPathSpecificStore(path string, storeId string,
 }
 
 func (fsw *FilerStoreWrapper) getActualStore(path util.FullPath) (store FilerStore) {
-	store = fsw.defaultStore
+	store = fsw.getDefaultStore()
 	if path == "/" {
 		return
 	}
@@ -87,7 +96,18 @@ func (fsw *FilerStoreWrapper) getActualStore(path util.FullPath) (store FilerSto
 	return
 }
 
+// checkWritable rejects the entry writes while the cluster is read only, see filer_cluster_read_only.go.
+// All the entry writes go through the store, including the background jobs.
+func (fsw *FilerStoreWrapper) checkWritable(p util.FullPath) error {
+	if fsw.writableCheck == nil {
+		return nil
+	}
+	return fsw.writableCheck(p)
+}
+
 func (fsw *FilerStoreWrapper) getDefaultStore() (store FilerStore) {
+	fsw.storeLock.RLock()
+	defer fsw.storeLock.RUnlock()
 	return fsw.defaultStore
 }
 
@@ -99,7 +119,13 @@ func (fsw *FilerStoreWrapper) Initialize(configuration util.Configuration, prefi
 	return fsw.getDefaultStore().Initialize(configuration, prefix)
 }
 
-func (fsw *FilerStoreWrapper) InsertEntry(ctx context.Context, entry *Entry) error {
+func (fsw *FilerStoreWrapper) InsertEntry(ctx context.Context, entry *Entry) (err error) {
+	if err = fsw.checkWritable(entry.FullPath); err != nil {
+		return err
+	}
+	ctx, done := fsw.startWrite(ctx)
+	defer done()
+
 	actualStore := fsw.getActualStore(entry.FullPath)
 	stats.FilerStoreCounter.WithLabelValues(actualStore.GetName(), "insert").Inc()
 	start := time.Now()
@@ -117,10 +143,21 @@ func (fsw *FilerStoreWrapper) InsertEntry(ctx context.Context, entry *Entry) err
 	}
 
 	glog.V(4).Infof("InsertEntry %s", entry.FullPath)
-	return actualStore.InsertEntry(ctx, entry)
+	physical := fsw.physicalEntry(entry)
+	if err = actualStore.InsertEntry(ctx, physical); err == nil {
+		fsw.trackChange(ctx, actualStore, storeChange{path: physical.FullPath})
+		fsw.invalidateCache(ctx, entry.FullPath, false, false)
+	}
+	return err
 }
 
-func (fsw *FilerStoreWrapper) UpdateEntry(ctx context.Context, entry *Entry) error {
+func (fsw *FilerStoreWrapper) UpdateEntry(ctx context.Context, entry *Entry) (err error) {
+	if err = fsw.checkWritable(entry.FullPath); err != nil {
+		return err
+	}
+	ctx, done := fsw.startWrite(ctx)
+	defer done()
+
 	actualStore := fsw.getActualStore(entry.FullPath)
 	stats.FilerStoreCounter.WithLabelValues(actualStore.GetName(), "update").Inc()
 	start := time.Now()
@@ -138,10 +175,24 @@ func (fsw *FilerStoreWrapper) UpdateEntry(ctx context.Context, entry *Entry) err
 	}
 
 	glog.V(4).Infof("UpdateEntry %s", entry.FullPath)
-	return actualStore.UpdateEntry(ctx, entry)
+	physical := fsw.physicalEntry(entry)
+	if err = actualStore.UpdateEntry(ctx, physical); err == nil {
+		fsw.trackChange(ctx, actualStore, storeChange{path: physical.FullPath})
+		fsw.invalidateCache(ctx, entry.FullPath, false, false)
+	}
+	return err
 }
 
 func (fsw *FilerStoreWrapper) FindEntry(ctx context.Context, fp util.FullPath) (entry *Entry, err error) {
+	if fsw.isCached(ctx, fp) {
+		return fsw.cache.findEntry(ctx, fp, func() (*Entry, error) {
+			return fsw.findEntry(ctx, fp)
+		})
+	}
+	return fsw.findEntry(ctx, fp)
+}
+
+func (fsw *FilerStoreWrapper) findEntry(ctx context.Context, fp util.FullPath) (entry *Entry, err error) {
 	actualStore := fsw.getActualStore(fp)
 	stats.FilerStoreCounter.WithLabelValues(actualStore.GetName(), "find").Inc()
 	start := time.Now()
@@ -150,10 +201,11 @@ func (fsw *FilerStoreWrapper) FindEntry(ctx context.Context, fp util.FullPath) (
 	}()
 
 	glog.V(4).Infof("FindEntry %s", fp)
-	entry, err = actualStore.FindEntry(ctx, fp)
+	entry, err = actualStore.FindEntry(ctx, fsw.physicalPath(fp))
 	if err != nil {
 		return nil, err
 	}
+	entry.FullPath = fp
 
 	fsw.maybeReadHardLink(ctx, entry)
 
@@ -162,6 +214,12 @@ func (fsw *FilerStoreWrapper) FindEntry(ctx context.Context, fp util.FullPath) (
 }
 
 func (fsw *FilerStoreWrapper) DeleteEntry(ctx context.Context, fp util.FullPath) (err error) {
+	if err = fsw.checkWritable(fp); err != nil {
+		return err
+	}
+	ctx, done := fsw.startWrite(ctx)
+	defer done()
+
 	actualStore := fsw.getActualStore(fp)
 	stats.FilerStoreCounter.WithLabelValues(actualStore.GetName(), "delete").Inc()
 	start := time.Now()
@@ -182,10 +240,21 @@ func (fsw *FilerStoreWrapper) DeleteEntry(ctx context.Context, fp util.FullPath)
 	}
 
 	glog.V(4).Infof("DeleteEntry %s", fp)
-	return actualStore.DeleteEntry(ctx, fp)
+	physicalPath := fsw.physicalPath(fp)
+	if err = actualStore.DeleteEntry(ctx, physicalPath); err == nil {
+		fsw.trackChange(ctx, actualStore, storeChange{path: physicalPath})
+		fsw.invalidateCache(ctx, fp, existingEntry.IsDirectory(), false)
+	}
+	return err
 }
 
 func (fsw *FilerStoreWrapper) DeleteOneEntry(ctx context.Context, existingEntry *Entry) (err error) {
+	if err = fsw.checkWritable(existingEntry.FullPath); err != nil {
+		return err
+	}
+	ctx, done := fsw.startWrite(ctx)
+	defer done()
+
 	actualStore := fsw.getActualStore(existingEntry.FullPath)
 	stats.FilerStoreCounter.WithLabelValues(actualStore.GetName(), "delete").Inc()
 	start := time.Now()
@@ -202,10 +271,21 @@ func (fsw *FilerStoreWrapper) DeleteOneEntry(ctx context.Context, existingEntry
 	}
 
 	glog.V(4).Infof("DeleteOneEntry %s", existingEntry.FullPath)
-	return actualStore.DeleteEntry(ctx, existingEntry.FullPath)
+	physicalPath := fsw.physicalPath(existingEntry.FullPath)
+	if err = actualStore.DeleteEntry(ctx, physicalPath); err == nil {
+		fsw.trackChange(ctx, actualStore, storeChange{path: physicalPath})
+		fsw.invalidateCache(ctx, existingEntry.FullPath, existingEntry.IsDirectory(), false)
+	}
+	return err
 }
 
 func (fsw *FilerStoreWrapper) DeleteFolderChildren(ctx context.Context, fp util.FullPath) (err error) {
+	if err = fsw.checkWritable(fp); err != nil {
+		return err
+	}
+	ctx, done := fsw.startWrite(ctx)
+	defer done()
+
 	actualStore := fsw.getActualStore(fp + "/")
 	stats.FilerStoreCounter.WithLabelValues(actualStore.GetName(), "deleteFolderChildren").Inc()
 	start := time.Now()
@@ -214,10 +294,80 @@ func (fsw *FilerStoreWrapper) DeleteFolderChildren(ctx context.Context, fp util.
 	}()
 
 	glog.V(4).Infof("DeleteFolderChildren %s", fp)
-	return actualStore.DeleteFolderChildren(ctx, fp)
+	if n := fsw.shardCount(fp); n > 0 {
+		for shard := 0; shard < n; shard++ {
+			shardDir := shardDirectory(fp, uint32(shard))
+			if err = actualStore.DeleteFolderChildren(ctx, shardDir); err != nil {
+				return err
+			}
+			fsw.trackChange(ctx, actualStore, storeChange{path: shardDir, isFolder: true})
+		}
+	}
+	if err = actualStore.DeleteFolderChildren(ctx, fp); err == nil {
+		fsw.trackChange(ctx, actualStore, storeChange{path: fp, isFolder: true})
+		// the sub directories are deleted recursively before, except for the buckets, see doBatchDeleteFolderMetaAndData
+		fsw.invalidateCacheChildren(ctx, fp)
+	}
+	return err
+}
+
+// RenameDirectory renames the directory in the store, if both paths are in the same store supporting it,
+// and no path specific stores are under the directory.
+func (fsw *FilerStoreWrapper) RenameDirectory(ctx context.Context, oldPath, newPath util.FullPath) (err error) {
+	if err = fsw.checkWritable(oldPath); err != nil {
+		return err
+	}
+	if err = fsw.checkWritable(newPath); err != nil {
+		return err
+	}
+	ctx, done := fsw.startWrite(ctx)
+	defer done()
+
+	actualStore := fsw.getActualStore(oldPath)
+	if actualStore != fsw.getActualStore(newPath) || fsw.hasPathSpecificStoreUnder(oldPath) || fsw.getMigration() != nil {
+		return ErrUnsupportedDirectoryRename
+	}
+	if fsw.physicalPath(oldPath) != oldPath || fsw.physicalPath(newPath) != newPath {
+		// the children are under the logical path, while the directory itself is in a dir shard
+		return ErrUnsupportedDirectoryRename
+	}
+	renamer, ok := actualStore.(DirectoryRenamer)
+	if !ok {
+		return ErrUnsupportedDirectoryRename
+	}
+	stats.FilerStoreCounter.WithLabelValues(actualStore.GetName(), "renameDirectory").Inc()
+	start := time.Now()
+	defer func() {
+		stats.FilerStoreHistogram.WithLabelValues(actualStore.GetName(), "renameDirectory").Observe(time.Since(start).Seconds())
+	}()
+
+	glog.V(4).Infof("RenameDirectory %s => %s", oldPath, newPath)
+	if err = renamer.RenameDirectory(ctx, oldPath, newPath); err == nil {
+		fsw.invalidateCache(ctx, oldPath, true, true)
+		fsw.invalidateCache(ctx, newPath, true, true)
+	}
+	return err
+}
+
+func (fsw *FilerStoreWrapper) hasPathSpecificStoreUnder(dir util.FullPath) (found bool) {
+	dirPrefix := string(dir) + "/"
+	fsw.pathToStore.Walk(func(key []byte, value interface{}) bool {
+		found = strings.HasPrefix(string(key), dirPrefix)
+		return !found
+	})
+	return
 }
 
 func (fsw *FilerStoreWrapper) ListDirectoryEntries(ctx context.Context, dirPath util.FullPath, startFileName string, includeStartFile bool, limit int64, eachEntryFunc ListEachEntryFunc) (string, error) {
+	if fsw.isCached(ctx, dirPath) && limit <= maxCachedListingLimit {
+		return fsw.cache.listDirectoryEntries(ctx, dirPath, startFileName, includeStartFile, limit, "", eachEntryFunc, func(eachEntryFunc ListEachEntryFunc) (string, error) {
+			return fsw.listDirectoryEntries(ctx, dirPath, startFileName, includeStartFile, limit, eachEntryFunc)
+		})
+	}
+	return fsw.listDirectoryEntries(ctx, dirPath, startFileName, includeStartFile, limit, eachEntryFunc)
+}
+
+func (fsw *FilerStoreWrapper) listDirectoryEntries(ctx context.Context, dirPath util.FullPath, startFileName string, includeStartFile bool, limit int64, eachEntryFunc ListEachEntryFunc) (string, error) {
 	actualStore := fsw.getActualStore(dirPath + "/")
 	stats.FilerStoreCounter.WithLabelValues(actualStore.GetName(), "list").Inc()
 	start := time.Now()
@@ -226,14 +376,30 @@ func (fsw *FilerStoreWrapper) ListDirectoryEntries(ctx context.Context, dirPath
 	}()
 
 	glog.V(4).Infof("ListDirectoryEntries %s from %s limit %d", dirPath, startFileName, limit)
-	return actualStore.ListDirectoryEntries(ctx, dirPath, startFileName, includeStartFile, limit, func(entry *Entry) bool {
+	eachDeserializedEntryFunc := func(entry *Entry) bool {
 		fsw.maybeReadHardLink(ctx, entry)
 		filer_pb.AfterEntryDeserialization(entry.Chunks)
 		return eachEntryFunc(entry)
-	})
+	}
+	if n := fsw.shardCount(dirPath); n > 0 {
+		return listShards(dirPath, n, startFileName, includeStartFile, limit, func(shardDir util.FullPath, startFileName string, includeStartFile bool, limit int64, eachShardEntryFunc ListEachEntryFunc) error {
+			_, err := actualStore.ListDirectoryEntries(ctx, shardDir, startFileName, includeStartFile, limit, eachShardEntryFunc)
+			return err
+		}, eachDeserializedEntryFunc)
+	}
+	return actualStore.ListDirectoryEntries(ctx, dirPath, startFileName, includeStartFile, limit, eachDeserializedEntryFunc)
 }
 
 func (fsw *FilerStoreWrapper) ListDirectoryPrefixedEntries(ctx context.Context, dirPath util.FullPath, startFileName string, includeStartFile bool, limit int64, prefix string, eachEntryFunc ListEachEntryFunc) (lastFileName string, err error) {
+	if fsw.isCached(ctx, dirPath) && limit <= maxCachedListingLimit {
+		return fsw.cache.listDirectoryEntries(ctx, dirPath, startFileName, includeStartFile, limit, prefix, eachEntryFunc, func(eachEntryFunc ListEachEntryFunc) (string, error) {
+			return fsw.listDirectoryPrefixedEntries(ctx, dirPath, startFileName, includeStartFile, limit, prefix, eachEntryFunc)
+		})
+	}
+	return fsw.listDirectoryPrefixedEntries(ctx, dirPath, startFileName, includeStartFile, limit, prefix, eachEntryFunc)
+}
+
+func (fsw *FilerStoreWrapper) listDirectoryPrefixedEntries(ctx context.Context, dirPath util.FullPath, startFileName string, includeStartFile bool, limit int64, prefix string, eachEntryFunc ListEachEntryFunc) (lastFileName string, err error) {
 	actualStore := fsw.getActualStore(dirPath + "/")
 	stats.FilerStoreCounter.WithLabelValues(actualStore.GetName(), "prefixList").Inc()
 	start := time.Now()
@@ -241,6 +407,16 @@ func (fsw *FilerStoreWrapper) ListDirectoryPrefixedEntries(ctx context.Context,
 		stats.FilerStoreHistogram.WithLabelValues(actualStore.GetName(), "prefixList").Observe(time.Since(start).Seconds())
 	}()
 	glog.V(4).Infof("ListDirectoryPrefixedEntries %s from %s prefix %s limit %d", dirPath, startFileName, prefix, limit)
+	if n := fsw.shardCount(dirPath); n > 0 {
+		return listShards(dirPath, n, startFileName, includeStartFile, limit, func(shardDir util.FullPath, startFileName string, includeStartFile bool, limit int64, eachShardEntryFunc ListEachEntryFunc) error {
+			_, err := fsw.listPrefixedEntries(ctx, actualStore, shardDir, startFileName, includeStartFile, limit, prefix, eachShardEntryFunc)
+			return err
+		}, eachEntryFunc)
+	}
+	return fsw.listPrefixedEntries(ctx, actualStore, dirPath, startFileName, includeStartFile, limit, prefix, eachEntryFunc)
+}
+
+func (fsw *FilerStoreWrapper) listPrefixedEntries(ctx context.Context, actualStore FilerStore, dirPath util.FullPath, startFileName string, includeStartFile bool, limit int64, prefix string, eachEntryFunc ListEachEntryFunc) (lastFileName string, err error) {
 	lastFileName, err = actualStore.ListDirectoryPrefixedEntries(ctx, dirPath, startFileName, includeStartFile, limit, prefix, eachEntryFunc)
 	if err == ErrUnsupportedListDirectoryPrefixed {
 		lastFileName, err = fsw.prefixFilterEntries(ctx, dirPath, startFileName, includeStartFile, limit, prefix, func(entry *Entry) bool {
@@ -296,14 +472,22 @@ func (fsw *FilerStoreWrapper) prefixFilterEntries(ctx context.Context, dirPath u
 }
 
 func (fsw *FilerStoreWrapper) BeginTransaction(ctx context.Context) (context.Context, error) {
-	return fsw.getDefaultStore().BeginTransaction(ctx)
+	ctx, done := fsw.startTransaction(ctx)
+	txCtx, err := fsw.getDefaultStore().BeginTransaction(ctx)
+	if err != nil {
+		done()
+		return ctx, err
+	}
+	return txCtx, nil
 }
 
 func (fsw *FilerStoreWrapper) CommitTransaction(ctx context.Context) error {
+	defer fsw.endTransaction(ctx)
 	return fsw.getDefaultStore().CommitTransaction(ctx)
 }
 
 func (fsw *FilerStoreWrapper) RollbackTransaction(ctx context.Context) error {
+	defer fsw.endTransaction(ctx)
 	return fsw.getDefaultStore().RollbackTransaction(ctx)
 }
 
@@ -312,11 +496,25 @@ func (fsw *FilerStoreWrapper) Shutdown() {
 }
 
 func (fsw *FilerStoreWrapper) KvPut(ctx context.Context, key []byte, value []byte) (err error) {
-	return fsw.getDefaultStore().KvPut(ctx, key, value)
+	ctx, done := fsw.startWrite(ctx)
+	defer done()
+
+	store := fsw.getDefaultStore()
+	if err = store.KvPut(ctx, key, value); err == nil {
+		fsw.trackChange(ctx, store, storeChange{key: key})
+	}
+	return err
 }
 func (fsw *FilerStoreWrapper) KvGet(ctx context.Context, key []byte) (value []byte, err error) {
 	return fsw.getDefaultStore().KvGet(ctx, key)
 }
 func (fsw *FilerStoreWrapper) KvDelete(ctx context.Context, key []byte) (err error) {
-	return fsw.getDefaultStore().KvDelete(ctx, key)
+	ctx, done := fsw.startWrite(ctx)
+	defer done()
+
+	store := fsw.getDefaultStore()
+	if err = store.KvDelete(ctx, key); err == nil {
+		fsw.trackChange(ctx, store, storeChange{key: key})
+	}
+	return err
 }
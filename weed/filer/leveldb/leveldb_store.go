@@ -9,6 +9,7 @@ import (
 	"github.com/syndtr/goleveldb/leveldb/opt"
 	leveldb_util "github.com/syndtr/goleveldb/leveldb/util"
 	"os"
+	"sync"
 
 	"github.com/chrislusf/seaweedfs/weed/filer"
 	"github.com/chrislusf/seaweedfs/weed/glog"
@@ -18,6 +19,8 @@ import (
 
 const (
 	DIR_FILE_SEPARATOR = byte(0x00)
+	// the directories with more entries are moved one by one, to bound the rename batch in memory
+	renameDirectoryMaxEntries = 100000
 )
 
 func init() {
@@ -26,6 +29,8 @@ func init() {
 
 type LevelDBStore struct {
 	db *leveldb.DB
+	// the writes hold the read lock, and renaming a directory the write lock, so no writes under it are lost
+	renameLock sync.RWMutex
 }
 
 func (store *LevelDBStore) GetName() string {
@@ -73,6 +78,9 @@ func (store *LevelDBStore) RollbackTransaction(ctx context.Context) error {
 }
 
 func (store *LevelDBStore) InsertEntry(ctx context.Context, entry *filer.Entry) (err error) {
+	store.renameLock.RLock()
+	defer store.renameLock.RUnlock()
+
 	key := genKey(entry.DirAndName())
 
 	value, err := entry.EncodeAttributesAndChunks()
@@ -126,6 +134,9 @@ func (store *LevelDBStore) FindEntry(ctx context.Context, fullpath weed_util.Ful
 }
 
 func (store *LevelDBStore) DeleteEntry(ctx context.Context, fullpath weed_util.FullPath) (err error) {
+	store.renameLock.RLock()
+	defer store.renameLock.RUnlock()
+
 	key := genKey(fullpath.DirAndName())
 
 	err = store.db.Delete(key, nil)
@@ -137,6 +148,8 @@ func (store *LevelDBStore) DeleteEntry(ctx context.Context, fullpath weed_util.F
 }
 
 func (store *LevelDBStore) DeleteFolderChildren(ctx context.Context, fullpath weed_util.FullPath) (err error) {
+	store.renameLock.RLock()
+	defer store.renameLock.RUnlock()
 
 	batch := new(leveldb.Batch)
 
@@ -164,6 +177,55 @@ func (store *LevelDBStore) DeleteFolderChildren(ctx context.Context, fullpath we
 	return nil
 }
 
+// RenameDirectory rewrites the keys of the directory and all the entries under it in one batch,
+// since the keys are prefixed by the full directory path. The writes wait until the batch is written.
+// The directories with too many entries are not renamed in the store.
+func (store *LevelDBStore) RenameDirectory(ctx context.Context, oldPath, newPath weed_util.FullPath) (err error) {
+	store.renameLock.Lock()
+	defer store.renameLock.Unlock()
+
+	batch := new(leveldb.Batch)
+
+	oldKey := genKey(oldPath.DirAndName())
+	value, err := store.db.Get(oldKey, nil)
+	if err == leveldb.ErrNotFound {
+		return filer_pb.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("get %s : %v", oldPath, err)
+	}
+	batch.Delete(oldKey)
+	batch.Put(genKey(newPath.DirAndName()), value)
+
+	// the children have the key prefix "oldPath\x00", and the deeper entries "oldPath/"
+	for _, prefix := range [][]byte{genDirectoryKeyPrefix(oldPath, ""), []byte(string(oldPath) + "/")} {
+		iter := store.db.NewIterator(leveldb_util.BytesPrefix(prefix), nil)
+		for iter.Next() {
+			key := iter.Key()
+			if bytes.IndexByte(key, DIR_FILE_SEPARATOR) < 0 {
+				continue
+			}
+			if batch.Len() >= 2*renameDirectoryMaxEntries {
+				iter.Release()
+				return filer.ErrUnsupportedDirectoryRename
+			}
+			newKey := append([]byte(string(newPath)), key[len(oldPath):]...)
+			batch.Delete(append([]byte{}, key...))
+			batch.Put(newKey, append([]byte{}, iter.Value()...))
+		}
+		iter.Release()
+		if err = iter.Error(); err != nil {
+			return fmt.Errorf("rename %s : %v", oldPath, err)
+		}
+	}
+
+	if err = store.db.Write(batch, nil); err != nil {
+		return fmt.Errorf("rename %s => %s : %v", oldPath, newPath, err)
+	}
+
+	return nil
+}
+
 func (store *LevelDBStore) ListDirectoryEntries(ctx context.Context, dirPath weed_util.FullPath, startFileName string, includeStartFile bool, limit int64, eachEntryFunc filer.ListEachEntryFunc) (lastFileName string, err error) {
 	return store.ListDirectoryPrefixedEntries(ctx, dirPath, startFileName, includeStartFile, limit, "", eachEntryFunc)
 }
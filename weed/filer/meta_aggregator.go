@@ -19,6 +19,7 @@ import (
 
 type MetaAggregator struct {
 	filers         []string
+	self           string
 	grpcDialOption grpc.DialOption
 	MetaLogBuffer  *log_buffer.LogBuffer
 	// notifying clients
@@ -41,6 +42,7 @@ func NewMetaAggregator(filers []string, grpcDialOption grpc.DialOption) *MetaAgg
 }
 
 func (ma *MetaAggregator) StartLoopSubscribe(f *Filer, self string) {
+	ma.self = self
 	for _, filer := range ma.filers {
 		go ma.subscribeToOneFiler(f, self, filer)
 	}
@@ -201,7 +203,7 @@ func (ma *MetaAggregator) updateOffset(f *Filer, peer string, peerSignature int3
 	value := make([]byte, 8)
 	util.Uint64toBytes(value, uint64(lastTsNs))
 
-	err = f.Store.KvPut(context.Background(), key, value)
+	err = f.KvPutRegistered(context.Background(), key, value)
 
 	if err != nil {
 		return fmt.Errorf("updateOffset %s : %v", peer, err)
@@ -11,9 +11,14 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/x/bsonx"
+	"math"
+	"regexp"
 	"time"
 )
 
+// the error code of the transactions on a standalone server
+const mongoIllegalOperation = 20
+
 func init() {
 	filer.Stores = append(filer.Stores, &MongodbStore{})
 }
@@ -178,6 +183,50 @@ func (store *MongodbStore) DeleteFolderChildren(ctx context.Context, fullpath ut
 	return nil
 }
 
+// RenameDirectory rewrites the directory path prefix of all the entries under the directory on the mongodb server,
+// with an update pipeline in one transaction, and needs a replica set or a sharded cluster of mongodb 4.2 or later.
+func (store *MongodbStore) RenameDirectory(ctx context.Context, oldPath, newPath util.FullPath) error {
+
+	c := store.connect.Database(store.database).Collection(store.collectionName)
+
+	session, err := store.connect.StartSession()
+	if err != nil {
+		return filer.ErrUnsupportedDirectoryRename
+	}
+	defer session.EndSession(ctx)
+
+	oldDir, oldName := oldPath.DirAndName()
+	newDir, newName := newPath.DirAndName()
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		result, err := c.UpdateOne(sessCtx, bson.M{"directory": oldDir, "name": oldName}, bson.M{"$set": bson.M{"directory": newDir, "name": newName}})
+		if err != nil {
+			return nil, err
+		}
+		if result.MatchedCount == 0 {
+			return nil, filer_pb.ErrNotFound
+		}
+
+		where := bson.M{"directory": bson.M{"$regex": "^" + regexp.QuoteMeta(string(oldPath)) + "(/|$)"}}
+		update := bson.A{bson.M{"$set": bson.M{"directory": bson.M{"$concat": bson.A{
+			string(newPath),
+			bson.M{"$substrBytes": bson.A{"$directory", len(oldPath), math.MaxInt32}},
+		}}}}}
+		return c.UpdateMany(sessCtx, where, update)
+	})
+	if err == filer_pb.ErrNotFound {
+		return err
+	}
+	if cmdErr, ok := err.(mongo.CommandError); ok && cmdErr.Code == mongoIllegalOperation {
+		// the transactions are not supported by a standalone server
+		return filer.ErrUnsupportedDirectoryRename
+	}
+	if err != nil {
+		return fmt.Errorf("rename %s => %s : %v", oldPath, newPath, err)
+	}
+
+	return nil
+}
+
 func (store *MongodbStore) ListDirectoryPrefixedEntries(ctx context.Context, dirPath util.FullPath, startFileName string, includeStartFile bool, limit int64, prefix string, eachEntryFunc filer.ListEachEntryFunc) (lastFileName string, err error) {
 	return lastFileName, filer.ErrUnsupportedListDirectoryPrefixed
 }
@@ -15,6 +15,7 @@ type SqlGenMysql struct {
 
 var (
 	_ = abstract_sql.SqlGenerator(&SqlGenMysql{})
+	_ = abstract_sql.SqlRangeGenerator(&SqlGenMysql{})
 )
 
 func (gen *SqlGenMysql) GetSqlInsert(tableName string) string {
@@ -49,6 +50,15 @@ func (gen *SqlGenMysql) GetSqlListInclusive(tableName string) string {
 	return fmt.Sprintf("SELECT NAME, meta FROM `%s` WHERE dirhash=? AND name>=? AND directory=? AND name like ? ORDER BY NAME ASC LIMIT ?", tableName)
 }
 
+// the names are ordered in binary, with "name VARCHAR(1000) BINARY"
+func (gen *SqlGenMysql) GetSqlListRangeExclusive(tableName string) string {
+	return fmt.Sprintf("SELECT NAME, meta FROM `%s` WHERE dirhash=? AND name>? AND name<? AND directory=? AND name like ? ORDER BY NAME ASC LIMIT ?", tableName)
+}
+
+func (gen *SqlGenMysql) GetSqlListRangeInclusive(tableName string) string {
+	return fmt.Sprintf("SELECT NAME, meta FROM `%s` WHERE dirhash=? AND name>=? AND name<? AND directory=? AND name like ? ORDER BY NAME ASC LIMIT ?", tableName)
+}
+
 func (gen *SqlGenMysql) GetSqlCreateTable(tableName string) string {
 	return fmt.Sprintf(gen.CreateTableSqlTemplate, tableName)
 }
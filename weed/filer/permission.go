@@ -1,5 +1,165 @@
 package filer
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+const (
+	AclRead    = 4
+	AclWrite   = 2
+	AclExecute = 1
+)
+
+var ErrPermissionDenied = errors.New("permission denied")
+
+// Identity is the user to check the permissions for, with the primary group first in Gids
+type Identity struct {
+	Uid  uint32
+	Gids []uint32
+}
+
+func (identity *Identity) inGroup(gid uint32) bool {
+	for _, g := range identity.Gids {
+		if g == gid {
+			return true
+		}
+	}
+	return false
+}
+
+type identityKey struct{}
+
+// WithIdentity marks the operations in the context as done by the identity
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext returns the identity of the context, or nil if the permissions are not checked
+func IdentityFromContext(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(identityKey{}).(*Identity)
+	return identity
+}
+
+// FindEntryFunc finds the entry of the path, or returns filer_pb.ErrNotFound
+type FindEntryFunc func(ctx context.Context, p util.FullPath) (*Entry, error)
+
+// HasPermission checks whether the identity has the permissions, a combination of AclRead, AclWrite and AclExecute,
+// to the entry, by the access ACL of the entry, or by the mode bits if no ACL.
+// The root user has all the permissions, except executing a file without any execute bit.
+func HasPermission(entry *Entry, identity *Identity, perm uint16) bool {
+	if identity.Uid == 0 {
+		return perm&AclExecute == 0 || entry.IsDirectory() || entry.Mode&0111 != 0
+	}
+	acl, err := DecodeAcl(entry.Extended[ExtPosixAclAccessKey])
+	if err != nil || len(acl) == 0 {
+		acl = modeAcl(entry.Mode)
+	}
+	isGranted := func(granted uint16) bool {
+		return granted&perm == perm
+	}
+
+	if identity.Uid == entry.Uid {
+		return isGranted(acl.entry(AclTagUserObj).Perm)
+	}
+	mask := uint16(7)
+	if maskEntry := acl.entry(AclTagMask); maskEntry != nil {
+		mask = maskEntry.Perm
+	}
+	for _, e := range acl {
+		if e.Tag == AclTagUser && e.Id == identity.Uid {
+			return isGranted(e.Perm & mask)
+		}
+	}
+	isGroupMatched := false
+	for _, e := range acl {
+		if e.Tag == AclTagGroupObj && identity.inGroup(entry.Gid) || e.Tag == AclTagGroup && identity.inGroup(e.Id) {
+			if isGranted(e.Perm & mask) {
+				return true
+			}
+			isGroupMatched = true
+		}
+	}
+	if isGroupMatched {
+		return false
+	}
+	return isGranted(acl.entry(AclTagOther).Perm)
+}
+
+func modeAcl(mode os.FileMode) Acl {
+	return Acl{
+		{Tag: AclTagUserObj, Perm: uint16(mode>>6) & 7},
+		{Tag: AclTagGroupObj, Perm: uint16(mode>>3) & 7},
+		{Tag: AclTagOther, Perm: uint16(mode) & 7},
+	}
+}
+
+// CheckAccess returns ErrPermissionDenied unless the identity can search the directories to the path,
+// and has the permissions to the entry of the path. A missing entry or directory returns filer_pb.ErrNotFound.
+func CheckAccess(ctx context.Context, findEntry FindEntryFunc, p util.FullPath, identity *Identity, perm uint16) error {
+	parts := p.Split()
+	for i := 0; i < len(parts); i++ {
+		dir := util.FullPath("/" + util.Join(parts[:i]...))
+		dirEntry, err := findEntry(ctx, dir)
+		if err != nil {
+			return err
+		}
+		if !HasPermission(dirEntry, identity, AclExecute) {
+			return fmt.Errorf("%s: %v", dir, ErrPermissionDenied)
+		}
+	}
+	entry, err := findEntry(ctx, p)
+	if err != nil {
+		return err
+	}
+	if !HasPermission(entry, identity, perm) {
+		return fmt.Errorf("%s: %v", p, ErrPermissionDenied)
+	}
+	return nil
+}
+
+// CheckCreateAccess checks the permissions to create an entry of the path, i.e. writing and searching
+// the nearest existing directory of the path, which is created with the missing parent directories.
+func CheckCreateAccess(ctx context.Context, findEntry FindEntryFunc, p util.FullPath, identity *Identity) error {
+	for dir := p; dir != "/"; {
+		parent, _ := dir.DirAndName()
+		dir = util.FullPath(parent)
+		if err := CheckAccess(ctx, findEntry, dir, identity, AclWrite|AclExecute); err != filer_pb.ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckDeleteAccess checks the permissions to delete or rename the entry of the path, i.e. writing and
+// searching its directory, and owning the entry or the directory if the directory has the sticky bit.
+func CheckDeleteAccess(ctx context.Context, findEntry FindEntryFunc, p util.FullPath, identity *Identity) error {
+	dir, _ := p.DirAndName()
+	if err := CheckAccess(ctx, findEntry, util.FullPath(dir), identity, AclWrite|AclExecute); err != nil {
+		return err
+	}
+	dirEntry, err := findEntry(ctx, util.FullPath(dir))
+	if err != nil {
+		return err
+	}
+	if dirEntry.Mode&os.ModeSticky == 0 || identity.Uid == 0 || identity.Uid == dirEntry.Uid {
+		return nil
+	}
+	entry, err := findEntry(ctx, p)
+	if err != nil {
+		return err
+	}
+	if identity.Uid != entry.Uid {
+		return fmt.Errorf("%s: %v", p, ErrPermissionDenied)
+	}
+	return nil
+}
+
 func hasWritePermission(dir *Entry, entry *Entry) bool {
 
 	if dir == nil {
@@ -220,7 +220,7 @@ func (c *ChunkReadAt) doFetchFullChunkData(chunkView *ChunkView) ([]byte, error)
 
 	glog.V(4).Infof("+ doFetchFullChunkData %s", chunkView.FileId)
 
-	data, err := fetchChunk(c.lookupFileId, chunkView.FileId, chunkView.CipherKey, chunkView.IsGzipped)
+	data, err := fetchChunk(c.lookupFileId, chunkView.FileId, chunkView.CipherKey, chunkView.IsGzipped, chunkView.Codec)
 
 	glog.V(4).Infof("- doFetchFullChunkData %s", chunkView.FileId)
 
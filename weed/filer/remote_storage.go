@@ -0,0 +1,512 @@
+package filer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/remote_storage"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+Remote storage mounts a bucket, or a directory in a bucket, of an external S3, GCS or Azure
+object store to a filer directory. Both the metadata and the content are fetched lazily.
+
+A mounted directory is listed from the remote storage when it is first looked up or listed,
+and again after the metadata ttl of the mount. Each remote object becomes a filer entry without
+chunks, with the remote object metadata kept in the extended attributes.
+
+The content is cached as normal chunks when the entry is first read, or with "remote.cache".
+The cached content is dropped after the cache max age, or when the mount caches more than its
+capacity, least recently read first. It is fetched again from the remote storage when needed.
+
+Entries written locally are never replaced by the remote objects, and are not uploaded back.
+*/
+
+const (
+	DirectoryEtcRemote     = "/etc/remote"
+	RemoteMountMappingFile = "mount.mapping"
+	RemoteConfExtension    = ".conf"
+	ExtRemoteKey           = "x-seaweedfs-remote"
+	remoteCacheIndexName   = "remote.cache"
+
+	DefaultRemoteMetadataTtlSec = 300
+	remoteListTimeout           = time.Minute
+)
+
+type FilerRemoteStorage struct {
+	sync.RWMutex
+	mounts     map[string]*filer_pb.RemoteStorageMapping_Mount
+	confs      map[string]*filer_pb.RemoteConf
+	listedDirs map[util.FullPath]time.Time
+	lastReads  map[util.FullPath]time.Time
+	cacheIndex pathIndex
+}
+
+func NewFilerRemoteStorage() *FilerRemoteStorage {
+	return &FilerRemoteStorage{
+		mounts:     make(map[string]*filer_pb.RemoteStorageMapping_Mount),
+		confs:      make(map[string]*filer_pb.RemoteConf),
+		listedDirs: make(map[util.FullPath]time.Time),
+		lastReads:  make(map[util.FullPath]time.Time),
+		cacheIndex: pathIndex{name: remoteCacheIndexName},
+	}
+}
+
+// FindMount returns the mount containing the path, and the remote location of the path.
+func (rs *FilerRemoteStorage) FindMount(p util.FullPath) (mount *filer_pb.RemoteStorageMapping_Mount, loc *filer_pb.RemoteStorageLocation) {
+	rs.RLock()
+	defer rs.RUnlock()
+	for dir, m := range rs.mounts {
+		if string(p) != dir && !strings.HasPrefix(string(p), dir+"/") {
+			continue
+		}
+		if mount != nil && len(mount.Dir) > len(dir) {
+			continue
+		}
+		mount = m
+	}
+	if mount == nil {
+		return nil, nil
+	}
+	remotePath := strings.TrimSuffix(mount.Location.Path, "/") + strings.TrimPrefix(string(p), mount.Dir)
+	if remotePath == "" {
+		remotePath = "/"
+	}
+	return mount, &filer_pb.RemoteStorageLocation{
+		Name:   mount.Location.Name,
+		Bucket: mount.Location.Bucket,
+		Path:   remotePath,
+	}
+}
+
+// hasMountUnder checks whether any mount is under the directory
+func (rs *FilerRemoteStorage) hasMountUnder(dir util.FullPath) bool {
+	rs.RLock()
+	defer rs.RUnlock()
+	for mountDir := range rs.mounts {
+		if strings.HasPrefix(mountDir, string(dir)+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (rs *FilerRemoteStorage) remoteConf(name string) (*filer_pb.RemoteConf, bool) {
+	rs.RLock()
+	defer rs.RUnlock()
+	conf, found := rs.confs[name]
+	return conf, found
+}
+
+// markListed returns false if the directory was listed within the metadata ttl.
+func (rs *FilerRemoteStorage) markListed(dir util.FullPath, mount *filer_pb.RemoteStorageMapping_Mount, now time.Time) bool {
+	ttl := time.Duration(mount.MetadataTtlSec) * time.Second
+	if mount.MetadataTtlSec == 0 {
+		ttl = DefaultRemoteMetadataTtlSec * time.Second
+	}
+	rs.Lock()
+	defer rs.Unlock()
+	if listedAt, found := rs.listedDirs[dir]; found && (mount.MetadataTtlSec < 0 || now.Sub(listedAt) < ttl) {
+		return false
+	}
+	rs.listedDirs[dir] = now
+	return true
+}
+
+// TouchRead records the read time of a cached remote entry, used to evict the least recently read ones.
+func (rs *FilerRemoteStorage) TouchRead(p util.FullPath) {
+	rs.Lock()
+	rs.lastReads[p] = time.Now()
+	rs.Unlock()
+}
+
+func (rs *FilerRemoteStorage) lastRead(p util.FullPath, remoteEntry *filer_pb.RemoteEntry) time.Time {
+	rs.RLock()
+	defer rs.RUnlock()
+	if t, found := rs.lastReads[p]; found {
+		return t
+	}
+	return time.Unix(0, remoteEntry.LastLocalSyncTsNs)
+}
+
+func (f *Filer) LoadRemoteStorageConfAndMapping() {
+	if err := util.Retry("loadRemoteStorage", func() error {
+		return f.loadRemoteStorage(context.Background())
+	}); err != nil {
+		glog.Errorf("read remote storage configuration: %v", err)
+	}
+}
+
+func (f *Filer) loadRemoteStorage(ctx context.Context) error {
+	var entries []*Entry
+	_, err := f.Store.ListDirectoryEntries(ctx, DirectoryEtcRemote, "", false, math.MaxInt32, func(entry *Entry) bool {
+		entries = append(entries, entry)
+		return true
+	})
+	if err != nil && err != filer_pb.ErrNotFound {
+		return err
+	}
+
+	confs := make(map[string]*filer_pb.RemoteConf)
+	mounts := make(map[string]*filer_pb.RemoteStorageMapping_Mount)
+	for _, entry := range entries {
+		name := entry.Name()
+		if name != RemoteMountMappingFile && !strings.HasSuffix(name, RemoteConfExtension) {
+			continue
+		}
+		data := entry.Content
+		if len(data) == 0 {
+			if data, err = f.readEntry(entry.Chunks); err != nil {
+				return fmt.Errorf("read %s: %v", entry.FullPath, err)
+			}
+		}
+		if name == RemoteMountMappingFile {
+			mapping, parseErr := ParseRemoteStorageMapping(data)
+			if parseErr != nil {
+				glog.Errorf("parse %s: %v", entry.FullPath, parseErr)
+				continue
+			}
+			for _, mount := range mapping.Mounts {
+				mounts[mount.Dir] = mount
+			}
+			continue
+		}
+		conf := &filer_pb.RemoteConf{}
+		if err = jsonpb.Unmarshal(bytes.NewReader(data), conf); err != nil {
+			glog.Errorf("parse %s: %v", entry.FullPath, err)
+			continue
+		}
+		confs[conf.Name] = conf
+	}
+
+	f.RemoteStorage.Lock()
+	f.RemoteStorage.confs = confs
+	f.RemoteStorage.mounts = mounts
+	f.RemoteStorage.listedDirs = make(map[util.FullPath]time.Time)
+	f.RemoteStorage.Unlock()
+
+	for dir, mount := range mounts {
+		glog.V(0).Infof("remote storage %s mounted to %s", remote_storage.FormatLocation(mount.Location), dir)
+	}
+	return nil
+}
+
+func ParseRemoteStorageMapping(data []byte) (*filer_pb.RemoteStorageMapping, error) {
+	mapping := &filer_pb.RemoteStorageMapping{}
+	if len(data) == 0 {
+		return mapping, nil
+	}
+	if err := jsonpb.Unmarshal(bytes.NewReader(data), mapping); err != nil {
+		return nil, err
+	}
+	return mapping, nil
+}
+
+// RemoteStorageToText writes the remote storage configuration or mount mapping as text.
+func RemoteStorageToText(writer io.Writer, message proto.Message) error {
+	m := jsonpb.Marshaler{
+		EmitDefaults: false,
+		Indent:       "  ",
+	}
+	return m.Marshal(writer, message)
+}
+
+func (f *Filer) maybeReloadRemoteStorage(event *filer_pb.SubscribeMetadataResponse) {
+	if DirectoryEtcRemote != event.Directory && DirectoryEtcRemote != event.EventNotification.NewParentPath {
+		return
+	}
+	if err := f.loadRemoteStorage(context.Background()); err != nil {
+		glog.Errorf("reload remote storage configuration: %v", err)
+	}
+}
+
+// RemoteEntryOf returns the remote object metadata, or nil if the entry is not from the remote storage.
+func RemoteEntryOf(entry *Entry) *filer_pb.RemoteEntry {
+	if entry == nil || len(entry.Extended[ExtRemoteKey]) == 0 {
+		return nil
+	}
+	remoteEntry := &filer_pb.RemoteEntry{}
+	if err := proto.Unmarshal(entry.Extended[ExtRemoteKey], remoteEntry); err != nil {
+		glog.Errorf("unmarshal remote entry of %s: %v", entry.FullPath, err)
+		return nil
+	}
+	return remoteEntry
+}
+
+// IsRemoteOnly returns true if the entry content is in the remote storage, but not cached locally.
+func IsRemoteOnly(entry *Entry) bool {
+	remoteEntry := RemoteEntryOf(entry)
+	return remoteEntry != nil && remoteEntry.RemoteSize > 0 && len(entry.Chunks) == 0 && len(entry.Content) == 0
+}
+
+func remoteOnlyEntry(p util.FullPath, remoteEntry *filer_pb.RemoteEntry) *Entry {
+	data, _ := proto.Marshal(remoteEntry)
+	mtime := time.Unix(remoteEntry.RemoteMtime, 0)
+	return &Entry{
+		FullPath: p,
+		Attr: Attr{
+			Mtime:    mtime,
+			Crtime:   mtime,
+			Mode:     0644,
+			Uid:      OS_UID,
+			Gid:      OS_GID,
+			FileSize: uint64(remoteEntry.RemoteSize),
+		},
+		Extended: map[string][]byte{ExtRemoteKey: data},
+	}
+}
+
+func sameRemoteObject(a, b *filer_pb.RemoteEntry) bool {
+	return a.RemoteSize == b.RemoteSize && a.RemoteMtime == b.RemoteMtime && a.RemoteETag == b.RemoteETag
+}
+
+// RemoteLocation returns the remote storage configuration and the object location of the entry.
+func (f *Filer) RemoteLocation(p util.FullPath) (*filer_pb.RemoteConf, *filer_pb.RemoteStorageLocation, error) {
+	mount, loc := f.RemoteStorage.FindMount(p)
+	if mount == nil {
+		return nil, nil, fmt.Errorf("%s is not in a remote storage mount", p)
+	}
+	conf, found := f.RemoteStorage.remoteConf(loc.Name)
+	if !found {
+		return nil, nil, fmt.Errorf("remote storage %s is not configured", loc.Name)
+	}
+	return conf, loc, nil
+}
+
+func (f *Filer) maybeListRemoteDirectory(ctx context.Context, dir util.FullPath) {
+	mount, _ := f.RemoteStorage.FindMount(dir)
+	if mount == nil || !f.RemoteStorage.markListed(dir, mount, time.Now()) {
+		return
+	}
+	if err := f.listRemoteDirectory(ctx, dir); err != nil {
+		glog.Errorf("list remote directory %s: %v", dir, err)
+	}
+}
+
+// listRemoteDirectory creates or updates the entries of the remote objects directly under the directory,
+// and removes the uncached entries of the deleted remote objects. The listing gives up after remoteListTimeout.
+func (f *Filer) listRemoteDirectory(ctx context.Context, dir util.FullPath) error {
+	conf, loc, err := f.RemoteLocation(dir)
+	if err != nil {
+		return err
+	}
+	client, err := remote_storage.GetRemoteStorage(conf)
+	if err != nil {
+		return err
+	}
+
+	listCtx, cancel := context.WithTimeout(ctx, remoteListTimeout)
+	defer cancel()
+
+	now := time.Now()
+	remoteNames := make(map[string]bool)
+	err = client.ListDirectory(listCtx, loc, func(name string, isDirectory bool, remoteEntry *filer_pb.RemoteEntry) error {
+		remoteNames[name] = true
+		p := dir.Child(name)
+		existing, findErr := f.Store.FindEntry(ctx, p)
+		if findErr != nil && findErr != filer_pb.ErrNotFound {
+			return findErr
+		}
+		if isDirectory {
+			if existing != nil {
+				return nil
+			}
+			return f.CreateEntry(ctx, &Entry{
+				FullPath: p,
+				Attr: Attr{
+					Mtime:  now,
+					Crtime: now,
+					Mode:   os.ModeDir | 0755,
+					Uid:    OS_UID,
+					Gid:    OS_GID,
+				},
+			}, false, false, nil)
+		}
+		if existing != nil {
+			existingRemote := RemoteEntryOf(existing)
+			if existingRemote == nil || sameRemoteObject(existingRemote, remoteEntry) {
+				// written locally, or not changed
+				return nil
+			}
+		}
+		if createErr := f.CreateEntry(ctx, remoteOnlyEntry(p, remoteEntry), false, false, nil); createErr != nil {
+			return createErr
+		}
+		if existing != nil {
+			// the cached content of the old remote object
+			f.DeleteChunks(existing.Chunks)
+			f.removeFromRemoteCacheIndex(ctx, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var deleted []util.FullPath
+	_, err = f.Store.ListDirectoryEntries(ctx, dir, "", false, math.MaxInt32, func(entry *Entry) bool {
+		if !entry.IsDirectory() && !remoteNames[entry.Name()] && RemoteEntryOf(entry) != nil {
+			deleted = append(deleted, entry.FullPath)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for _, p := range deleted {
+		glog.V(1).Infof("remote object of %s is deleted", p)
+		if err = f.DeleteEntryMetaAndData(ctx, p, false, false, true, false, nil); err != nil {
+			return err
+		}
+		f.removeFromRemoteCacheIndex(ctx, p)
+	}
+	return nil
+}
+
+// CompleteRemoteCache points the remote only entry to the chunks of the cached content, with its checksum.
+// It fails if the entry changed since the caching started.
+func (f *Filer) CompleteRemoteCache(ctx context.Context, entry *Entry, chunks []*filer_pb.FileChunk, checksum []byte) (*Entry, error) {
+	current, err := f.Store.FindEntry(ctx, entry.FullPath)
+	if err != nil {
+		return nil, fmt.Errorf("cache %s: %v", entry.FullPath, err)
+	}
+	remoteEntry, currentRemote := RemoteEntryOf(entry), RemoteEntryOf(current)
+	if !IsRemoteOnly(current) || remoteEntry == nil || !sameRemoteObject(remoteEntry, currentRemote) {
+		return nil, fmt.Errorf("cache %s: entry changed during caching", entry.FullPath)
+	}
+
+	remoteEntry.LastLocalSyncTsNs = time.Now().UnixNano()
+	data, err := proto.Marshal(remoteEntry)
+	if err != nil {
+		return nil, fmt.Errorf("marshal remote entry: %v", err)
+	}
+	cached := cloneEntryExtended(current)
+	cached.Chunks = chunks
+	cached.Sha256 = checksum
+	cached.Extended[ExtRemoteKey] = data
+
+	if err = f.updatePathIndex(ctx, &f.RemoteStorage.cacheIndex, entry.FullPath, true); err != nil {
+		return nil, err
+	}
+	if err = f.Store.UpdateEntry(ctx, cached); err != nil {
+		return nil, fmt.Errorf("cache %s: %v", entry.FullPath, err)
+	}
+	f.NotifyUpdateEvent(ctx, current, cached, false, false, nil)
+	return cached, nil
+}
+
+// UncacheRemoteEntry drops the cached content of the remote entry, keeping its metadata.
+func (f *Filer) UncacheRemoteEntry(ctx context.Context, entry *Entry) error {
+	if RemoteEntryOf(entry) == nil {
+		return fmt.Errorf("%s is not from the remote storage", entry.FullPath)
+	}
+	if IsRemoteOnly(entry) {
+		return nil
+	}
+	uncached := cloneEntryExtended(entry)
+	uncached.Chunks = nil
+	uncached.Content = nil
+	if err := f.Store.UpdateEntry(ctx, uncached); err != nil {
+		return fmt.Errorf("uncache %s: %v", entry.FullPath, err)
+	}
+	f.NotifyUpdateEvent(ctx, entry, uncached, false, false, nil)
+	f.DeleteChunks(entry.Chunks)
+	f.removeFromRemoteCacheIndex(ctx, entry.FullPath)
+	return nil
+}
+
+func (f *Filer) removeFromRemoteCacheIndex(ctx context.Context, p util.FullPath) {
+	f.RemoteStorage.Lock()
+	delete(f.RemoteStorage.lastReads, p)
+	f.RemoteStorage.Unlock()
+	if err := f.updatePathIndex(ctx, &f.RemoteStorage.cacheIndex, p, false); err != nil {
+		glog.Errorf("%v", err)
+	}
+}
+
+// LoopEvictingRemoteCache periodically drops the cached content exceeding the cache max age or capacity of the mounts.
+func (f *Filer) LoopEvictingRemoteCache() {
+	for {
+		time.Sleep(10 * time.Minute)
+		f.evictRemoteCache(context.Background(), time.Now())
+	}
+}
+
+type cachedRemoteEntry struct {
+	entry    *Entry
+	lastRead time.Time
+}
+
+func (f *Filer) evictRemoteCache(ctx context.Context, now time.Time) {
+	paths, err := f.loadPathIndex(ctx, &f.RemoteStorage.cacheIndex)
+	if err != nil {
+		glog.Errorf("load cached remote entries: %v", err)
+		return
+	}
+
+	cachedByMount := make(map[*filer_pb.RemoteStorageMapping_Mount][]cachedRemoteEntry)
+	for _, p := range paths {
+		entry, findErr := f.Store.FindEntry(ctx, util.FullPath(p))
+		mount, _ := f.RemoteStorage.FindMount(util.FullPath(p))
+		remoteEntry := RemoteEntryOf(entry)
+		if findErr != nil || mount == nil || remoteEntry == nil || IsRemoteOnly(entry) {
+			// deleted, unmounted, overwritten locally, or already uncached
+			f.removeFromRemoteCacheIndex(ctx, util.FullPath(p))
+			continue
+		}
+		cachedByMount[mount] = append(cachedByMount[mount], cachedRemoteEntry{
+			entry:    entry,
+			lastRead: f.RemoteStorage.lastRead(entry.FullPath, remoteEntry),
+		})
+	}
+
+	for mount, cached := range cachedByMount {
+		for _, entry := range remoteCacheEvictions(mount, cached, now) {
+			if err = f.UncacheRemoteEntry(ctx, entry); err != nil {
+				glog.Errorf("%v", err)
+				continue
+			}
+			glog.V(1).Infof("evicted cached content of %s", entry.FullPath)
+		}
+	}
+}
+
+// remoteCacheEvictions selects the entries older than the cache max age,
+// and then the least recently read entries until the mount is within its cache capacity.
+func remoteCacheEvictions(mount *filer_pb.RemoteStorageMapping_Mount, cached []cachedRemoteEntry, now time.Time) (evicted []*Entry) {
+	sort.Slice(cached, func(i, j int) bool {
+		return cached[i].lastRead.Before(cached[j].lastRead)
+	})
+
+	var totalSize int64
+	for _, c := range cached {
+		totalSize += int64(c.entry.Size())
+	}
+	capacity := mount.CacheCapacityMb * 1024 * 1024
+	maxAge := time.Duration(mount.CacheMaxAgeSec) * time.Second
+
+	for _, c := range cached {
+		isExpired := mount.CacheMaxAgeSec > 0 && now.Sub(c.lastRead) > maxAge
+		isOverCapacity := mount.CacheCapacityMb > 0 && totalSize > capacity
+		if !isExpired && !isOverCapacity {
+			break
+		}
+		evicted = append(evicted, c.entry)
+		totalSize -= int64(c.entry.Size())
+	}
+	return
+}
@@ -0,0 +1,83 @@
+package filer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func TestFindRemoteMount(t *testing.T) {
+	rs := NewFilerRemoteStorage()
+	rs.mounts["/buckets/b1"] = &filer_pb.RemoteStorageMapping_Mount{
+		Dir:      "/buckets/b1",
+		Location: &filer_pb.RemoteStorageLocation{Name: "s3_1", Bucket: "bucket1", Path: "/"},
+	}
+	rs.mounts["/buckets/b1/nested"] = &filer_pb.RemoteStorageMapping_Mount{
+		Dir:      "/buckets/b1/nested",
+		Location: &filer_pb.RemoteStorageLocation{Name: "gcs_1", Bucket: "bucket2", Path: "/some/dir"},
+	}
+
+	for p, expected := range map[util.FullPath]string{
+		"/buckets/b1":              "/",
+		"/buckets/b1/a/b":          "/a/b",
+		"/buckets/b1/nested":       "/some/dir",
+		"/buckets/b1/nested/x.txt": "/some/dir/x.txt",
+	} {
+		_, loc := rs.FindMount(p)
+		if loc == nil || loc.Path != expected {
+			t.Errorf("%s: expecting remote path %s, but got %+v", p, expected, loc)
+		}
+	}
+	for _, p := range []util.FullPath{"/buckets", "/buckets/b10", "/other"} {
+		if mount, _ := rs.FindMount(p); mount != nil {
+			t.Errorf("%s should not be mounted", p)
+		}
+	}
+}
+
+func TestRemoteOnlyEntry(t *testing.T) {
+	entry := remoteOnlyEntry("/buckets/b1/x.txt", &filer_pb.RemoteEntry{RemoteSize: 5, RemoteETag: "abc"})
+	if !IsRemoteOnly(entry) || entry.Size() != 5 {
+		t.Fatalf("remote entry should not be cached")
+	}
+	entry.Chunks = []*filer_pb.FileChunk{{FileId: "1,01", Size: 5}}
+	if IsRemoteOnly(entry) || RemoteEntryOf(entry).RemoteETag != "abc" {
+		t.Fatalf("remote entry should be cached")
+	}
+	if IsRemoteOnly(&Entry{FullPath: "/buckets/b1/local.txt"}) {
+		t.Fatalf("local entry should not be remote")
+	}
+}
+
+func TestRemoteCacheEvictions(t *testing.T) {
+	now := time.Now()
+	cachedEntry := func(name string, sizeMb uint64, lastRead time.Duration) cachedRemoteEntry {
+		return cachedRemoteEntry{
+			entry:    &Entry{FullPath: util.FullPath("/m/" + name), Attr: Attr{FileSize: sizeMb * 1024 * 1024}},
+			lastRead: now.Add(-lastRead),
+		}
+	}
+	cached := func() []cachedRemoteEntry {
+		return []cachedRemoteEntry{
+			cachedEntry("new", 2, time.Minute),
+			cachedEntry("old", 2, 3*time.Hour),
+			cachedEntry("recent", 2, time.Hour),
+		}
+	}
+
+	evicted := remoteCacheEvictions(&filer_pb.RemoteStorageMapping_Mount{CacheMaxAgeSec: 7200}, cached(), now)
+	if len(evicted) != 1 || evicted[0].Name() != "old" {
+		t.Errorf("evict by max age: %v", evicted)
+	}
+
+	evicted = remoteCacheEvictions(&filer_pb.RemoteStorageMapping_Mount{CacheCapacityMb: 3}, cached(), now)
+	if len(evicted) != 2 || evicted[0].Name() != "old" || evicted[1].Name() != "recent" {
+		t.Errorf("evict by capacity: %v", evicted)
+	}
+
+	if evicted = remoteCacheEvictions(&filer_pb.RemoteStorageMapping_Mount{}, cached(), now); len(evicted) != 0 {
+		t.Errorf("evict without limits: %v", evicted)
+	}
+}
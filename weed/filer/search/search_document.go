@@ -0,0 +1,72 @@
+package search
+
+import (
+	"mime"
+	"path/filepath"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+The filer entries are indexed into Elasticsearch or OpenSearch, one document per entry, to search the files by their meta data.
+
+The document id is the md5 of the full path. The extended attributes are indexed as "key=value" keywords,
+or only as "key" if the value is binary or too long.
+*/
+
+const maxXattrValueLength = 256
+
+type Document struct {
+	Path        string   `json:"path"`
+	Dir         string   `json:"dir,omitempty"`
+	Name        string   `json:"name,omitempty"`
+	IsDirectory bool     `json:"isDir,omitempty"`
+	Size        uint64   `json:"size,omitempty"`
+	Uid         uint32   `json:"uid,omitempty"`
+	Gid         uint32   `json:"gid,omitempty"`
+	UserName    string   `json:"userName,omitempty"`
+	Collection  string   `json:"collection,omitempty"`
+	Crtime      int64    `json:"crtime,omitempty"`
+	Mtime       int64    `json:"mtime,omitempty"`
+	Mime        string   `json:"mime,omitempty"`
+	Xattrs      []string `json:"xattrs,omitempty"`
+}
+
+func DocumentId(fullpath util.FullPath) string {
+	return util.Md5String([]byte(fullpath))
+}
+
+func NewDocument(dir string, entry *filer_pb.Entry) *Document {
+	doc := &Document{
+		Path:        string(util.NewFullPath(dir, entry.Name)),
+		Dir:         dir,
+		Name:        entry.Name,
+		IsDirectory: entry.IsDirectory,
+	}
+	if attr := entry.Attributes; attr != nil {
+		doc.Size = filer.FileSize(entry)
+		doc.Uid = attr.Uid
+		doc.Gid = attr.Gid
+		doc.UserName = attr.UserName
+		doc.Collection = attr.Collection
+		doc.Crtime = attr.Crtime
+		doc.Mtime = attr.Mtime
+		doc.Mime = attr.Mime
+	}
+	if doc.Mime == "" && !entry.IsDirectory {
+		doc.Mime = mime.TypeByExtension(filepath.Ext(entry.Name))
+	}
+	for k, v := range entry.Extended {
+		if len(v) <= maxXattrValueLength && utf8.Valid(v) {
+			doc.Xattrs = append(doc.Xattrs, k+"="+string(v))
+		} else {
+			doc.Xattrs = append(doc.Xattrs, k)
+		}
+	}
+	sort.Strings(doc.Xattrs)
+	return doc
+}
@@ -0,0 +1,40 @@
+package search
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/olivere/elastic/v7"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+)
+
+// NewSearchHandler serves GET /search?<query parameters>, see ParseQuery, with the Result in json.
+func NewSearchHandler(client *elastic.Client, index string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeSearchJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "only GET is supported"})
+			return
+		}
+		q, err := ParseQuery(r.URL.Query())
+		if err != nil {
+			writeSearchJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		result, err := Search(r.Context(), client, index, q)
+		if err != nil {
+			glog.Errorf("search %s: %v", r.URL.RawQuery, err)
+			writeSearchJson(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeSearchJson(w, http.StatusOK, result)
+	}
+}
+
+func writeSearchJson(w http.ResponseWriter, httpStatus int, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	if err := json.NewEncoder(w).Encode(obj); err != nil {
+		glog.V(0).Infof("write search response: %v", err)
+	}
+}
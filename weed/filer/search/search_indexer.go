@@ -0,0 +1,210 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// IndexMappingVersion is kept in the index mapping, and increased whenever the mapping or the documents change.
+// An index written with another version is not reused, since the searches would miss or mismatch the documents.
+const IndexMappingVersion = 1
+
+var indexMapping = fmt.Sprintf(`{
+	"mappings": {
+		"_meta": {"seaweedfsMappingVersion": %d},
+		"properties": {
+			"path":       {"type": "keyword"},
+			"dir":        {"type": "keyword"},
+			"name":       {"type": "keyword"},
+			"isDir":      {"type": "boolean"},
+			"size":       {"type": "long"},
+			"uid":        {"type": "long"},
+			"gid":        {"type": "long"},
+			"userName":   {"type": "keyword"},
+			"collection": {"type": "keyword"},
+			"crtime":     {"type": "long"},
+			"mtime":      {"type": "long"},
+			"mime":       {"type": "keyword"},
+			"xattrs":     {"type": "keyword"}
+		}
+	}
+}`, IndexMappingVersion)
+
+// NewClient connects to the comma separated Elasticsearch or OpenSearch servers
+func NewClient(servers string, username, password string) (*elastic.Client, error) {
+	options := []elastic.ClientOptionFunc{}
+	options = append(options, elastic.SetURL(strings.Split(servers, ",")...))
+	options = append(options, elastic.SetSniff(false))
+	options = append(options, elastic.SetHealthcheck(false))
+	if username != "" || password != "" {
+		options = append(options, elastic.SetBasicAuth(username, password))
+	}
+	return elastic.NewClient(options...)
+}
+
+// Indexer writes the filer entries to the index in bulk, in the order of the changes.
+type Indexer struct {
+	client   *elastic.Client
+	index    string
+	bulk     *elastic.BulkProcessor
+	bulkErr  error
+	bulkLock sync.Mutex
+}
+
+// NewIndexer creates the index with the mapping for the documents, if the index does not exist.
+// An existing index must have the same mapping version.
+func NewIndexer(ctx context.Context, client *elastic.Client, index string) (*Indexer, error) {
+	exists, err := client.IndexExists(index).Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("check index %s: %v", index, err)
+	}
+	if !exists {
+		if _, err = client.CreateIndex(index).BodyString(indexMapping).Do(ctx); err != nil {
+			return nil, fmt.Errorf("create index %s: %v", index, err)
+		}
+		glog.V(0).Infof("created index %s with mapping version %d", index, IndexMappingVersion)
+	} else {
+		mappings, err := client.GetMapping().Index(index).Do(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("get mapping of index %s: %v", index, err)
+		}
+		if version := mappingVersion(mappings, index); version != IndexMappingVersion {
+			return nil, fmt.Errorf("index %s has mapping version %d, expecting %d, please index into a new index", index, version, IndexMappingVersion)
+		}
+	}
+
+	indexer := &Indexer{
+		client: client,
+		index:  index,
+	}
+	// one worker keeps the writes to the same document in order
+	indexer.bulk, err = client.BulkProcessor().
+		Name("seaweedfs-indexer").
+		Workers(1).
+		BulkActions(1000).
+		FlushInterval(time.Second).
+		After(indexer.afterBulk).
+		Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("start bulk processor: %v", err)
+	}
+	return indexer, nil
+}
+
+// mappingVersion is the version in the mapping returned by the get mapping api, or 0 if not set.
+func mappingVersion(mappings map[string]interface{}, index string) int {
+	indexMappings, _ := mappings[index].(map[string]interface{})
+	mapping, _ := indexMappings["mappings"].(map[string]interface{})
+	meta, _ := mapping["_meta"].(map[string]interface{})
+	version, _ := meta["seaweedfsMappingVersion"].(float64)
+	return int(version)
+}
+
+func (ix *Indexer) afterBulk(executionId int64, requests []elastic.BulkableRequest, response *elastic.BulkResponse, err error) {
+	if err == nil && response != nil {
+		for _, item := range response.Failed() {
+			if item.Status == http.StatusNotFound {
+				// deleting a document not indexed yet
+				continue
+			}
+			err = fmt.Errorf("index %s: status %d %+v", item.Id, item.Status, item.Error)
+			break
+		}
+	}
+	if err != nil {
+		ix.bulkLock.Lock()
+		if ix.bulkErr == nil {
+			ix.bulkErr = err
+		}
+		ix.bulkLock.Unlock()
+	}
+}
+
+func (ix *Indexer) IndexEntry(dir string, entry *filer_pb.Entry) {
+	doc := NewDocument(dir, entry)
+	ix.bulk.Add(elastic.NewBulkIndexRequest().Index(ix.index).Id(DocumentId(util.FullPath(doc.Path))).Doc(doc))
+}
+
+// DeleteEntry deletes the document of the entry, and the documents under it if it is a directory.
+func (ix *Indexer) DeleteEntry(ctx context.Context, dir string, entry *filer_pb.Entry) error {
+	fullpath := util.NewFullPath(dir, entry.Name)
+	ix.bulk.Add(elastic.NewBulkDeleteRequest().Index(ix.index).Id(DocumentId(fullpath)))
+	if !entry.IsDirectory {
+		return nil
+	}
+
+	// the pending documents under the directory need to be searchable before deleting them by query
+	if err := ix.Flush(); err != nil {
+		return err
+	}
+	if _, err := ix.client.Refresh(ix.index).Do(ctx); err != nil {
+		return fmt.Errorf("refresh index %s: %v", ix.index, err)
+	}
+	_, err := ix.client.DeleteByQuery(ix.index).
+		Query(elastic.NewPrefixQuery("path", string(fullpath)+"/")).
+		ProceedOnVersionConflict().
+		Refresh("true").
+		Do(ctx)
+	if err != nil {
+		return fmt.Errorf("delete %s/ from index %s: %v", fullpath, ix.index, err)
+	}
+	return nil
+}
+
+// ApplyEvent updates the index with one meta data change
+func (ix *Indexer) ApplyEvent(ctx context.Context, resp *filer_pb.SubscribeMetadataResponse) error {
+	message := resp.EventNotification
+
+	newParentPath := message.NewParentPath
+	if newParentPath == "" {
+		newParentPath = resp.Directory
+	}
+
+	if message.OldEntry != nil {
+		isRenamed := message.NewEntry != nil && (newParentPath != resp.Directory || message.NewEntry.Name != message.OldEntry.Name)
+		if message.NewEntry == nil || isRenamed {
+			if err := ix.DeleteEntry(ctx, resp.Directory, message.OldEntry); err != nil {
+				return err
+			}
+		}
+	}
+
+	if message.NewEntry != nil {
+		ix.IndexEntry(newParentPath, message.NewEntry)
+	}
+	return nil
+}
+
+// Flush writes the pending documents, and returns the first failed write since the last flush.
+func (ix *Indexer) Flush() error {
+	if err := ix.bulk.Flush(); err != nil {
+		return err
+	}
+	return ix.takeBulkErr()
+}
+
+// Close writes the pending documents and stops the bulk processor, and returns the first failed write since the last flush.
+func (ix *Indexer) Close() error {
+	if err := ix.bulk.Close(); err != nil {
+		return err
+	}
+	return ix.takeBulkErr()
+}
+
+func (ix *Indexer) takeBulkErr() error {
+	ix.bulkLock.Lock()
+	defer ix.bulkLock.Unlock()
+	err := ix.bulkErr
+	ix.bulkErr = nil
+	return err
+}
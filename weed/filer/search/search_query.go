@@ -0,0 +1,180 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+)
+
+const (
+	TypeFile      = "file"
+	TypeDirectory = "dir"
+
+	DefaultLimit = 100
+	MaxLimit     = 10000
+)
+
+// Query matches the documents with all the set conditions.
+type Query struct {
+	PathPrefix     string    // a folder to search under
+	Name           string    // a wildcard pattern of the name, e.g. *.pdf
+	Mime           string    // a prefix of the mime type, e.g. image/
+	MinSize        int64     // in bytes, if positive
+	MaxSize        int64     // in bytes, if positive
+	ModifiedAfter  time.Time // if not zero
+	ModifiedBefore time.Time // if not zero
+	Xattrs         []string  // "key=value" or "key"
+	Type           string    // TypeFile, TypeDirectory, or empty for both
+	Limit          int
+	After          string // the path of the last result on the previous page
+}
+
+type Result struct {
+	Total   int64       `json:"total"`
+	Entries []*Document `json:"entries"`
+}
+
+// ParseQuery reads the query from the url parameters:
+// path, name, mime, minSize, maxSize, modifiedAfter, modifiedBefore (RFC3339), xattr (repeatable), type, limit, after.
+func ParseQuery(values url.Values) (q *Query, err error) {
+	q = &Query{
+		PathPrefix: values.Get("path"),
+		Name:       values.Get("name"),
+		Mime:       values.Get("mime"),
+		Xattrs:     values["xattr"],
+		Type:       values.Get("type"),
+		After:      values.Get("after"),
+	}
+	if q.MinSize, err = parseInt(values, "minSize"); err != nil {
+		return nil, err
+	}
+	if q.MaxSize, err = parseInt(values, "maxSize"); err != nil {
+		return nil, err
+	}
+	limit, err := parseInt(values, "limit")
+	if err != nil {
+		return nil, err
+	}
+	q.Limit = int(limit)
+	if q.ModifiedAfter, err = parseTime(values, "modifiedAfter"); err != nil {
+		return nil, err
+	}
+	if q.ModifiedBefore, err = parseTime(values, "modifiedBefore"); err != nil {
+		return nil, err
+	}
+	if q.Type != "" && q.Type != TypeFile && q.Type != TypeDirectory {
+		return nil, fmt.Errorf("type should be %s or %s: %s", TypeFile, TypeDirectory, q.Type)
+	}
+	return q, nil
+}
+
+func parseInt(values url.Values, name string) (int64, error) {
+	value := values.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse %s=%s: %v", name, value, err)
+	}
+	return n, nil
+}
+
+func parseTime(values url.Values, name string) (time.Time, error) {
+	value := values.Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse %s=%s: %v", name, value, err)
+	}
+	return t, nil
+}
+
+func (q *Query) toEsQuery() elastic.Query {
+	query := elastic.NewBoolQuery()
+	if dir := strings.TrimSuffix(q.PathPrefix, "/"); dir != "" {
+		query.Filter(elastic.NewPrefixQuery("path", dir+"/"))
+	}
+	if q.Name != "" {
+		query.Filter(elastic.NewWildcardQuery("name", q.Name))
+	}
+	if q.Mime != "" {
+		query.Filter(elastic.NewPrefixQuery("mime", q.Mime))
+	}
+	if q.MinSize > 0 || q.MaxSize > 0 {
+		sizeRange := elastic.NewRangeQuery("size")
+		if q.MinSize > 0 {
+			sizeRange.Gte(q.MinSize)
+		}
+		if q.MaxSize > 0 {
+			sizeRange.Lte(q.MaxSize)
+		}
+		query.Filter(sizeRange)
+	}
+	if !q.ModifiedAfter.IsZero() || !q.ModifiedBefore.IsZero() {
+		mtimeRange := elastic.NewRangeQuery("mtime")
+		if !q.ModifiedAfter.IsZero() {
+			mtimeRange.Gte(q.ModifiedAfter.Unix())
+		}
+		if !q.ModifiedBefore.IsZero() {
+			mtimeRange.Lt(q.ModifiedBefore.Unix())
+		}
+		query.Filter(mtimeRange)
+	}
+	for _, xattr := range q.Xattrs {
+		query.Filter(elastic.NewTermQuery("xattrs", xattr))
+	}
+	switch q.Type {
+	case TypeFile:
+		// isDir is omitted for the files
+		query.MustNot(elastic.NewTermQuery("isDir", true))
+	case TypeDirectory:
+		query.Filter(elastic.NewTermQuery("isDir", true))
+	}
+	return query
+}
+
+// Search returns the matched documents sorted by path. To get the next page, set the After to the last path.
+func Search(ctx context.Context, client *elastic.Client, index string, q *Query) (*Result, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+
+	search := client.Search(index).
+		Query(q.toEsQuery()).
+		Sort("path", true).
+		Size(limit).
+		TrackTotalHits(true)
+	if q.After != "" {
+		search = search.SearchAfter(q.After)
+	}
+	searchResult, err := search.Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("search index %s: %v", index, err)
+	}
+
+	result := &Result{
+		Total:   searchResult.TotalHits(),
+		Entries: []*Document{},
+	}
+	for _, hit := range searchResult.Hits.Hits {
+		doc := &Document{}
+		if err := json.Unmarshal(hit.Source, doc); err != nil {
+			return nil, fmt.Errorf("unmarshal %s: %v", hit.Id, err)
+		}
+		result.Entries = append(result.Entries, doc)
+	}
+	return result, nil
+}
@@ -0,0 +1,90 @@
+package search
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+)
+
+func TestNewDocument(t *testing.T) {
+	doc := NewDocument("/home/chris", &filer_pb.Entry{
+		Name: "report.pdf",
+		Attributes: &filer_pb.FuseAttributes{
+			FileSize: 1024,
+			Mtime:    1622505600,
+		},
+		Extended: map[string][]byte{
+			"project": []byte("apollo"),
+			"digest":  {0xff, 0xfe},
+		},
+	})
+	if doc.Path != "/home/chris/report.pdf" || doc.Dir != "/home/chris" || doc.Name != "report.pdf" {
+		t.Errorf("unexpected path %s dir %s name %s", doc.Path, doc.Dir, doc.Name)
+	}
+	if doc.Size != 1024 || doc.Mtime != 1622505600 {
+		t.Errorf("unexpected size %d mtime %d", doc.Size, doc.Mtime)
+	}
+	if doc.Mime != "application/pdf" {
+		t.Errorf("unexpected mime %s", doc.Mime)
+	}
+	if !reflect.DeepEqual(doc.Xattrs, []string{"digest", "project=apollo"}) {
+		t.Errorf("unexpected xattrs %v", doc.Xattrs)
+	}
+
+	dir := NewDocument("/", &filer_pb.Entry{Name: "home", IsDirectory: true})
+	if dir.Path != "/home" || !dir.IsDirectory || dir.Mime != "" {
+		t.Errorf("unexpected dir document %+v", dir)
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	values, _ := url.ParseQuery("path=/home/&name=*.pdf&minSize=10&modifiedAfter=2021-06-01T00:00:00Z&xattr=project=apollo&xattr=reviewed&type=file&limit=5")
+	q, err := ParseQuery(values)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if q.MinSize != 10 || q.Limit != 5 || q.ModifiedAfter.Unix() != time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC).Unix() {
+		t.Errorf("unexpected query %+v", q)
+	}
+
+	source, err := q.toEsQuery().Source()
+	if err != nil {
+		t.Fatalf("source: %v", err)
+	}
+	actual, _ := json.Marshal(source)
+	expected := `{"bool":{"filter":[{"prefix":{"path":"/home/"}},{"wildcard":{"name":{"wildcard":"*.pdf"}}},` +
+		`{"range":{"size":{"from":10,"include_lower":true,"include_upper":true,"to":null}}},` +
+		`{"range":{"mtime":{"from":1622505600,"include_lower":true,"include_upper":true,"to":null}}},` +
+		`{"term":{"xattrs":"project=apollo"}},{"term":{"xattrs":"reviewed"}}],` +
+		`"must_not":{"term":{"isDir":true}}}}`
+	if string(actual) != expected {
+		t.Errorf("unexpected es query\n%s\nexpected\n%s", actual, expected)
+	}
+
+	for _, bad := range []string{"minSize=abc", "modifiedBefore=yesterday", "type=link"} {
+		values, _ := url.ParseQuery(bad)
+		if _, err := ParseQuery(values); err == nil {
+			t.Errorf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestMappingVersion(t *testing.T) {
+	var created map[string]interface{}
+	if err := json.Unmarshal([]byte(indexMapping), &created); err != nil {
+		t.Fatalf("parse index mapping: %v", err)
+	}
+	if version := mappingVersion(map[string]interface{}{"files": created}, "files"); version != IndexMappingVersion {
+		t.Errorf("expecting version %d, got %d", IndexMappingVersion, version)
+	}
+	if version := mappingVersion(map[string]interface{}{"files": created}, "other"); version != 0 {
+		t.Errorf("expecting no version for another index, got %d", version)
+	}
+	if version := mappingVersion(map[string]interface{}{"files": map[string]interface{}{"mappings": map[string]interface{}{}}}, "files"); version != 0 {
+		t.Errorf("expecting no version for an index without _meta, got %d", version)
+	}
+}
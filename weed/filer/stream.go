@@ -48,7 +48,7 @@ func StreamContent(masterClient wdclient.HasLookupFileIdFunction, w io.Writer, c
 	for _, chunkView := range chunkViews {
 
 		urlStrings := fileId2Url[chunkView.FileId]
-		data, err := retriedFetchChunkData(urlStrings, chunkView.CipherKey, chunkView.IsGzipped, chunkView.IsFullChunk(), chunkView.Offset, int(chunkView.Size))
+		data, err := retriedFetchChunkViewData(urlStrings, chunkView)
 		if err != nil {
 			glog.Errorf("read chunk: %v", err)
 			return fmt.Errorf("read chunk: %v", err)
@@ -70,7 +70,7 @@ func CheckAllChunkViews(chunkViews []*ChunkView, fileId2Url *map[string][]string
 		urlStrings := (*fileId2Url)[chunkView.FileId]
 		glog.V(9).Infof("Check chunk: %+v\n url: %v", chunkView, urlStrings)
 		gErr.Go(func() error {
-			_, err := retriedFetchChunkData(urlStrings, chunkView.CipherKey, chunkView.IsGzipped, chunkView.IsFullChunk(), chunkView.Offset, int(chunkView.Size))
+			_, err := retriedFetchChunkViewData(urlStrings, chunkView)
 			return err
 		})
 	}
@@ -95,7 +95,7 @@ func ReadAll(masterClient *wdclient.MasterClient, chunks []*filer_pb.FileChunk)
 			return nil, err
 		}
 
-		data, err := retriedFetchChunkData(urlStrings, chunkView.CipherKey, chunkView.IsGzipped, chunkView.IsFullChunk(), chunkView.Offset, int(chunkView.Size))
+		data, err := retriedFetchChunkViewData(urlStrings, chunkView)
 		if err != nil {
 			return nil, err
 		}
@@ -206,23 +206,32 @@ func (c *ChunkStreamReader) fetchChunkToBuffer(chunkView *ChunkView) error {
 	}
 	var buffer bytes.Buffer
 	var shouldRetry bool
-	for _, urlString := range urlStrings {
-		shouldRetry, err = util.ReadUrlAsStream(urlString, chunkView.CipherKey, chunkView.IsGzipped, chunkView.IsFullChunk(), chunkView.Offset, int(chunkView.Size), func(data []byte) {
-			buffer.Write(data)
-		})
-		if !shouldRetry {
-			break
+	if chunkView.Codec != "" {
+		// the encoded chunk can only be decoded as a whole
+		data, fetchErr := retriedFetchChunkViewData(urlStrings, chunkView)
+		if fetchErr != nil {
+			return fetchErr
+		}
+		buffer.Write(data)
+	} else {
+		for _, urlString := range urlStrings {
+			shouldRetry, err = util.ReadUrlAsStream(urlString, chunkView.CipherKey, chunkView.IsGzipped, chunkView.IsFullChunk(), chunkView.Offset, int(chunkView.Size), func(data []byte) {
+				buffer.Write(data)
+			})
+			if !shouldRetry {
+				break
+			}
+			if err != nil {
+				glog.V(1).Infof("read %s failed, err: %v", chunkView.FileId, err)
+				buffer.Reset()
+			} else {
+				break
+			}
 		}
 		if err != nil {
-			glog.V(1).Infof("read %s failed, err: %v", chunkView.FileId, err)
-			buffer.Reset()
-		} else {
-			break
+			return err
 		}
 	}
-	if err != nil {
-		return err
-	}
 	c.buffer = buffer.Bytes()
 	c.bufferPos = 0
 	c.bufferOffset = chunkView.LogicOffset
@@ -41,6 +41,7 @@ var _ = fs.NodeSetxattrer(&Dir{})
 var _ = fs.NodeRemovexattrer(&Dir{})
 var _ = fs.NodeListxattrer(&Dir{})
 var _ = fs.NodeForgetter(&Dir{})
+var _ = fs.NodeOpener(&Dir{})
 
 func (dir *Dir) Attr(ctx context.Context, attr *fuse.Attr) error {
 
@@ -70,6 +71,15 @@ func (dir *Dir) Attr(ctx context.Context, attr *fuse.Attr) error {
 	return nil
 }
 
+func (dir *Dir) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
+
+	if err := dir.checkPermission(req.Header, filer.AclRead); err != nil {
+		return nil, err
+	}
+
+	return dir, nil
+}
+
 func (dir *Dir) Getxattr(ctx context.Context, req *fuse.GetxattrRequest, resp *fuse.GetxattrResponse) error {
 
 	glog.V(4).Infof("dir Getxattr %s", dir.FullPath())
@@ -130,6 +140,9 @@ func (dir *Dir) Create(ctx context.Context, req *fuse.CreateRequest,
 	if dir.wfs.option.ReadOnly {
 		return nil, nil, fuse.EPERM
 	}
+	if err := dir.checkPermission(req.Header, filer.AclWrite|filer.AclExecute); err != nil {
+		return nil, nil, err
+	}
 
 	request, err := dir.doCreateEntry(req.Name, req.Mode, req.Uid, req.Gid, req.Flags&fuse.OpenExclusive != 0)
 
@@ -154,6 +167,9 @@ func (dir *Dir) Mknod(ctx context.Context, req *fuse.MknodRequest) (fs.Node, err
 	if dir.wfs.option.ReadOnly {
 		return nil, fuse.EPERM
 	}
+	if err := dir.checkPermission(req.Header, filer.AclWrite|filer.AclExecute); err != nil {
+		return nil, err
+	}
 
 	request, err := dir.doCreateEntry(req.Name, req.Mode, req.Uid, req.Gid, false)
 
@@ -185,6 +201,7 @@ func (dir *Dir) doCreateEntry(name string, mode os.FileMode, uid, gid uint32, ex
 		OExcl:      exlusive,
 		Signatures: []int32{dir.wfs.signature},
 	}
+	dir.inheritDefaultAcl(request.Entry)
 	glog.V(1).Infof("create %s/%s", dir.FullPath(), name)
 
 	err := dir.wfs.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
@@ -197,7 +214,7 @@ func (dir *Dir) doCreateEntry(name string, mode os.FileMode, uid, gid uint32, ex
 				return fuse.EEXIST
 			}
 			glog.V(0).Infof("create %s/%s: %v", dir.FullPath(), name, err)
-			return fuse.EIO
+			return toFuseError(err)
 		}
 
 		dir.wfs.metaCache.InsertEntry(context.Background(), filer.FromPbEntry(request.Directory, request.Entry))
@@ -212,6 +229,9 @@ func (dir *Dir) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Node, err
 	if dir.wfs.option.ReadOnly {
 		return nil, fuse.EPERM
 	}
+	if err := dir.checkPermission(req.Header, filer.AclWrite|filer.AclExecute); err != nil {
+		return nil, err
+	}
 
 	glog.V(4).Infof("mkdir %s: %s", dir.FullPath(), req.Name)
 
@@ -226,6 +246,7 @@ func (dir *Dir) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Node, err
 			Gid:      req.Gid,
 		},
 	}
+	dir.inheritDefaultAcl(newEntry)
 
 	err := dir.wfs.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
 
@@ -257,13 +278,16 @@ func (dir *Dir) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Node, err
 
 	glog.V(0).Infof("mkdir %s/%s: %v", dir.FullPath(), req.Name, err)
 
-	return nil, fuse.EIO
+	return nil, toFuseError(err)
 }
 
 func (dir *Dir) Lookup(ctx context.Context, req *fuse.LookupRequest, resp *fuse.LookupResponse) (node fs.Node, err error) {
 
 	dirPath := util.FullPath(dir.FullPath())
 	glog.V(4).Infof("dir Lookup %s: %s by %s", dirPath, req.Name, req.Header.String())
+	if err := dir.checkPermission(req.Header, filer.AclExecute); err != nil {
+		return nil, err
+	}
 
 	fullFilePath := dirPath.Child(req.Name)
 	visitErr := meta_cache.EnsureVisited(dir.wfs.metaCache, dir.wfs, dirPath)
@@ -370,6 +394,9 @@ func (dir *Dir) Remove(ctx context.Context, req *fuse.RemoveRequest) error {
 	if dir.wfs.option.ReadOnly {
 		return fuse.EPERM
 	}
+	if err := dir.checkPermission(req.Header, filer.AclWrite|filer.AclExecute); err != nil {
+		return err
+	}
 
 	if !req.Dir {
 		return dir.removeOneFile(req)
@@ -396,6 +423,9 @@ func (dir *Dir) removeOneFile(req *fuse.RemoveRequest) error {
 	err = filer_pb.Remove(dir.wfs, dir.FullPath(), req.Name, isDeleteData, false, false, false, []int32{dir.wfs.signature})
 	if err != nil {
 		glog.V(3).Infof("not found remove file %s/%s: %v", dir.FullPath(), req.Name, err)
+		if fuseErr := toFuseError(err); fuseErr == fuse.EPERM {
+			return fuseErr
+		}
 		return fuse.ENOENT
 	}
 
@@ -431,6 +461,9 @@ func (dir *Dir) removeFolder(req *fuse.RemoveRequest) error {
 		if strings.Contains(err.Error(), "non-empty") {
 			return fuse.EEXIST
 		}
+		if fuseErr := toFuseError(err); fuseErr == fuse.EPERM {
+			return fuseErr
+		}
 		return fuse.ENOENT
 	}
 
@@ -453,9 +486,13 @@ func (dir *Dir) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fus
 	if err := dir.maybeLoadEntry(); err != nil {
 		return err
 	}
+	if err := dir.wfs.checkSetattrPermission(req, util.FullPath(dir.FullPath()), dir.entry); err != nil {
+		return err
+	}
 
 	if req.Valid.Mode() {
 		dir.entry.Attributes.FileMode = uint32(req.Mode)
+		filer.ChmodAcl(dir.entry.Extended, req.Mode)
 	}
 
 	if req.Valid.Uid() {
@@ -485,6 +522,9 @@ func (dir *Dir) Setxattr(ctx context.Context, req *fuse.SetxattrRequest) error {
 	if err := dir.maybeLoadEntry(); err != nil {
 		return err
 	}
+	if err := dir.checkPermission(req.Header, filer.AclWrite); err != nil {
+		return err
+	}
 
 	if err := setxattr(dir.entry, req); err != nil {
 		return err
@@ -505,6 +545,9 @@ func (dir *Dir) Removexattr(ctx context.Context, req *fuse.RemovexattrRequest) e
 	if err := dir.maybeLoadEntry(); err != nil {
 		return err
 	}
+	if err := dir.checkPermission(req.Header, filer.AclWrite); err != nil {
+		return err
+	}
 
 	if err := removexattr(dir.entry, req); err != nil {
 		return err
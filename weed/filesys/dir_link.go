@@ -27,6 +27,9 @@ func (dir *Dir) Link(ctx context.Context, req *fuse.LinkRequest, old fs.Node) (f
 	if dir.wfs.option.ReadOnly {
 		return nil, fuse.EPERM
 	}
+	if err := dir.checkPermission(req.Header, filer.AclWrite|filer.AclExecute); err != nil {
+		return nil, err
+	}
 
 	oldFile, ok := old.(*File)
 	if !ok {
@@ -112,6 +115,9 @@ func (dir *Dir) Symlink(ctx context.Context, req *fuse.SymlinkRequest) (fs.Node,
 	if dir.wfs.option.ReadOnly {
 		return nil, fuse.EPERM
 	}
+	if err := dir.checkPermission(req.Header, filer.AclWrite|filer.AclExecute); err != nil {
+		return nil, err
+	}
 
 	glog.V(4).Infof("Symlink: %v/%v to %v", dir.FullPath(), req.NewName, req.Target)
 
@@ -6,6 +6,7 @@ import (
 	"github.com/seaweedfs/fuse"
 	"github.com/seaweedfs/fuse/fs"
 
+	"github.com/chrislusf/seaweedfs/weed/filer"
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
 	"github.com/chrislusf/seaweedfs/weed/util"
@@ -18,6 +19,12 @@ func (dir *Dir) Rename(ctx context.Context, req *fuse.RenameRequest, newDirector
 	}
 
 	newDir := newDirectory.(*Dir)
+	if err := dir.checkPermission(req.Header, filer.AclWrite|filer.AclExecute); err != nil {
+		return err
+	}
+	if err := newDir.checkPermission(req.Header, filer.AclWrite|filer.AclExecute); err != nil {
+		return err
+	}
 
 	newPath := util.NewFullPath(newDir.FullPath(), req.NewName)
 	oldPath := util.NewFullPath(dir.FullPath(), req.OldName)
@@ -54,7 +61,7 @@ func (dir *Dir) Rename(ctx context.Context, req *fuse.RenameRequest, newDirector
 	})
 	if err != nil {
 		glog.V(0).Infof("dir Rename %s => %s : %v", oldPath, newPath, err)
-		return fuse.EIO
+		return toFuseError(err)
 	}
 
 	// TODO: replicate renaming logic on filer
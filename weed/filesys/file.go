@@ -93,6 +93,16 @@ func (file *File) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.Op
 
 	glog.V(4).Infof("file %v open %+v", file.fullpath(), req)
 
+	if file.wfs.option.Acl {
+		entry, err := file.maybeLoadEntry(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := file.wfs.checkPermission(req.Header, file.fullpath(), entry, openPermission(req.Flags)); err != nil {
+			return nil, err
+		}
+	}
+
 	handle := file.wfs.AcquireHandle(file, req.Uid, req.Gid)
 
 	resp.Handle = fuse.HandleID(handle.handle)
@@ -115,14 +125,17 @@ func (file *File) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *f
 	if err != nil {
 		return err
 	}
+	if err := file.wfs.checkSetattrPermission(req, file.fullpath(), entry); err != nil {
+		return err
+	}
 	if file.isOpen > 0 {
 		file.wfs.handlesLock.Lock()
 		fileHandle := file.wfs.handles[file.fullpath().AsInode()]
 		file.wfs.handlesLock.Unlock()
 
 		if fileHandle != nil {
-			fileHandle.Lock()
-			defer fileHandle.Unlock()
+			fileHandle.handleLock.Lock()
+			defer fileHandle.handleLock.Unlock()
 		}
 	}
 
@@ -151,11 +164,13 @@ func (file *File) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *f
 			entry.Chunks = chunks
 		}
 		entry.Attributes.FileSize = req.Size
+		entry.Attributes.Sha256 = nil
 		file.dirtyMetadata = true
 	}
 
 	if req.Valid.Mode() {
 		entry.Attributes.FileMode = uint32(req.Mode)
+		filer.ChmodAcl(entry.Extended, req.Mode)
 		file.dirtyMetadata = true
 	}
 
@@ -207,6 +222,9 @@ func (file *File) Setxattr(ctx context.Context, req *fuse.SetxattrRequest) error
 	if err != nil {
 		return err
 	}
+	if err := file.wfs.checkPermission(req.Header, file.fullpath(), entry, filer.AclWrite); err != nil {
+		return err
+	}
 
 	if err := setxattr(entry, req); err != nil {
 		return err
@@ -228,6 +246,9 @@ func (file *File) Removexattr(ctx context.Context, req *fuse.RemovexattrRequest)
 	if err != nil {
 		return err
 	}
+	if err := file.wfs.checkPermission(req.Header, file.fullpath(), entry, filer.AclWrite); err != nil {
+		return err
+	}
 
 	if err := removexattr(entry, req); err != nil {
 		return err
@@ -349,7 +370,7 @@ func (file *File) saveEntry(entry *filer_pb.Entry) error {
 		_, err := client.UpdateEntry(context.Background(), request)
 		if err != nil {
 			glog.Errorf("UpdateEntry file %s/%s: %v", file.dir.FullPath(), file.Name, err)
-			return fuse.EIO
+			return toFuseError(err)
 		}
 
 		file.wfs.metaCache.UpdateEntry(context.Background(), filer.FromPbEntry(request.Directory, request.Entry))
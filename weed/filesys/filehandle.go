@@ -25,7 +25,7 @@ type FileHandle struct {
 	reader         io.ReaderAt
 	contentType    string
 	handle         uint64
-	sync.Mutex
+	handleLock     sync.Mutex
 
 	f         *File
 	RequestId fuse.RequestID // unique ID for request
@@ -33,6 +33,8 @@ type FileHandle struct {
 	Uid       uint32         // user ID of process making request
 	Gid       uint32         // group ID of process making request
 
+	flocks     map[fuse.LockOwner]*filer.LiveLock
+	flocksLock sync.Mutex
 }
 
 func newFileHandle(file *File, uid, gid uint32) *FileHandle {
@@ -61,8 +63,8 @@ var _ = fs.HandleReleaser(&FileHandle{})
 func (fh *FileHandle) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
 
 	glog.V(4).Infof("%s read fh %d: [%d,%d) size %d resp.Data cap=%d", fh.f.fullpath(), fh.handle, req.Offset, req.Offset+int64(req.Size), req.Size, cap(resp.Data))
-	fh.Lock()
-	defer fh.Unlock()
+	fh.handleLock.Lock()
+	defer fh.handleLock.Unlock()
 
 	if req.Size <= 0 {
 		return nil
@@ -160,8 +162,8 @@ func (fh *FileHandle) Write(ctx context.Context, req *fuse.WriteRequest, resp *f
 		return fuse.EPERM
 	}
 
-	fh.Lock()
-	defer fh.Unlock()
+	fh.handleLock.Lock()
+	defer fh.handleLock.Unlock()
 
 	// write the request to volume servers
 	data := req.Data
@@ -177,6 +179,7 @@ func (fh *FileHandle) Write(ctx context.Context, req *fuse.WriteRequest, resp *f
 	}
 
 	entry.Content = nil
+	entry.Attributes.Sha256 = nil
 	entry.Attributes.FileSize = uint64(max(req.Offset+int64(len(data)), int64(entry.Attributes.FileSize)))
 	glog.V(4).Infof("%v write [%d,%d) %d", fh.f.fullpath(), req.Offset, req.Offset+int64(len(req.Data)), len(req.Data))
 
@@ -199,8 +202,13 @@ func (fh *FileHandle) Release(ctx context.Context, req *fuse.ReleaseRequest) err
 
 	glog.V(4).Infof("Release %v fh %d", fh.f.fullpath(), fh.handle)
 
-	fh.Lock()
-	defer fh.Unlock()
+	if req.ReleaseFlags&fuse.ReleaseFlockUnlock != 0 {
+		// flock locks belong to the open file description, so all of them go away
+		fh.releaseAllFlocks()
+	}
+
+	fh.handleLock.Lock()
+	defer fh.handleLock.Unlock()
 
 	if fh.f.isOpen <= 0 {
 		glog.V(0).Infof("Release reset %s open count %d => %d", fh.f.Name, fh.f.isOpen, 0)
@@ -224,8 +232,8 @@ func (fh *FileHandle) Flush(ctx context.Context, req *fuse.FlushRequest) error {
 
 	glog.V(4).Infof("Flush %v fh %d", fh.f.fullpath(), fh.handle)
 
-	fh.Lock()
-	defer fh.Unlock()
+	fh.handleLock.Lock()
+	defer fh.handleLock.Unlock()
 
 	if err := fh.doFlush(ctx, req.Header); err != nil {
 		glog.Errorf("Flush doFlush %s: %v", fh.f.Name, err)
@@ -247,7 +255,7 @@ func (fh *FileHandle) doFlush(ctx context.Context, header fuse.Header) error {
 
 	if fh.dirtyPages.lastErr != nil {
 		glog.Errorf("%v doFlush last err: %v", fh.f.fullpath(), fh.dirtyPages.lastErr)
-		return fuse.EIO
+		return toFuseError(fh.dirtyPages.lastErr)
 	}
 
 	if !fh.f.dirtyMetadata {
@@ -318,7 +326,7 @@ func (fh *FileHandle) doFlush(ctx context.Context, header fuse.Header) error {
 
 	if err != nil {
 		glog.Errorf("%v fh %d flush: %v", fh.f.fullpath(), fh.handle, err)
-		return fuse.EIO
+		return toFuseError(err)
 	}
 
 	return nil
@@ -0,0 +1,139 @@
+package filesys
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+	"time"
+
+	"github.com/seaweedfs/fuse"
+	"github.com/seaweedfs/fuse/fs"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// flock() on the mount is translated into whole-file filer locks, shared for LOCK_SH and exclusive for LOCK_EX,
+// so the locks are seen by other mounts, webdav, and applications using the filer lock API.
+
+var _ = fs.HandleFlockLocker(&FileHandle{})
+
+func (fh *FileHandle) flockOwner(lockOwner fuse.LockOwner) string {
+	return fmt.Sprintf("mount:%d:%s", fh.f.wfs.signature, lockOwner)
+}
+
+func (fh *FileHandle) Lock(ctx context.Context, req *fuse.LockRequest) error {
+	if !fh.f.wfs.option.FilerLock {
+		return fuse.ENOSYS
+	}
+
+	fh.flocksLock.Lock()
+	defer fh.flocksLock.Unlock()
+
+	isShared := req.Lock.Type == fuse.LockRead
+	if lock, found := fh.flocks[req.LockOwner]; found {
+		if lock.IsShared() == isShared {
+			return nil
+		}
+		return toFlockErr(fh.f.fullpath(), lock.Convert(isShared))
+	}
+
+	lock, err := filer.TryLiveLock(fh.f.wfs, string(fh.f.fullpath()), fh.flockOwner(req.LockOwner), isShared)
+	if err != nil {
+		return toFlockErr(fh.f.fullpath(), err)
+	}
+
+	if fh.flocks == nil {
+		fh.flocks = make(map[fuse.LockOwner]*filer.LiveLock)
+	}
+	fh.flocks[req.LockOwner] = lock
+
+	return nil
+}
+
+func toFlockErr(fullpath util.FullPath, err error) error {
+	if err == nil {
+		return nil
+	}
+	if err == filer_pb.ErrLockHeldByOthers {
+		return fuse.Errno(syscall.EAGAIN)
+	}
+	glog.Errorf("lock %s: %v", fullpath, err)
+	return fuse.EIO
+}
+
+func (fh *FileHandle) LockWait(ctx context.Context, req *fuse.LockWaitRequest) error {
+	for {
+		err := fh.Lock(ctx, (*fuse.LockRequest)(req))
+		if err != fuse.Errno(syscall.EAGAIN) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return fuse.EINTR
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (fh *FileHandle) Unlock(ctx context.Context, req *fuse.UnlockRequest) error {
+	if !fh.f.wfs.option.FilerLock {
+		return fuse.ENOSYS
+	}
+
+	fh.flocksLock.Lock()
+	lock, found := fh.flocks[req.LockOwner]
+	delete(fh.flocks, req.LockOwner)
+	fh.flocksLock.Unlock()
+
+	if !found {
+		return nil
+	}
+	if err := lock.Release(); err != nil {
+		glog.Warningf("unlock %s: %v", fh.f.fullpath(), err)
+	}
+
+	return nil
+}
+
+func (fh *FileHandle) QueryLock(ctx context.Context, req *fuse.QueryLockRequest, resp *fuse.QueryLockResponse) error {
+	if !fh.f.wfs.option.FilerLock {
+		return fuse.ENOSYS
+	}
+
+	owner, isShared, err := filer_pb.FindLockOwner(fh.f.wfs, string(fh.f.fullpath()))
+	if err != nil {
+		glog.Errorf("query lock %s: %v", fh.f.fullpath(), err)
+		return fuse.EIO
+	}
+	if owner == "" || owner == fh.flockOwner(req.LockOwner) || isShared && req.Lock.Type == fuse.LockRead {
+		return nil
+	}
+	lockType := fuse.LockWrite
+	if isShared {
+		lockType = fuse.LockRead
+	}
+	resp.Lock = fuse.FileLock{
+		Start: 0,
+		End:   req.Lock.End,
+		Type:  lockType,
+		PID:   -1,
+	}
+
+	return nil
+}
+
+func (fh *FileHandle) releaseAllFlocks() {
+	fh.flocksLock.Lock()
+	locks := fh.flocks
+	fh.flocks = nil
+	fh.flocksLock.Unlock()
+
+	for _, lock := range locks {
+		if err := lock.Release(); err != nil {
+			glog.Warningf("unlock %s: %v", fh.f.fullpath(), err)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package filesys
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/seaweedfs/fuse"
+	"github.com/seaweedfs/fuse/fs"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// With the "-acl" option, the permissions are checked here, with the POSIX ACLs and the mode bits,
+// instead of by the kernel with the mode bits only.
+
+// checkPermission returns EACCES unless the caller of the request has the permissions to the entry
+func (wfs *WFS) checkPermission(header fuse.Header, fullpath util.FullPath, entry *filer_pb.Entry, perm uint16) error {
+	if !wfs.option.Acl || entry == nil || entry.Attributes == nil {
+		return nil
+	}
+	dir, _ := fullpath.DirAndName()
+	e := filer.FromPbEntry(dir, entry)
+	// the entries in the meta cache have the local ids, and the ACLs have the filer ids
+	e.Uid, e.Gid = wfs.option.UidGidMapper.LocalToFiler(e.Uid, e.Gid)
+	if entry.IsDirectory {
+		e.Mode |= os.ModeDir
+	}
+	if !filer.HasPermission(e, wfs.identityOf(header), perm) {
+		return fuse.Errno(syscall.EACCES)
+	}
+	return nil
+}
+
+// checkOwner returns EPERM unless the caller of the request is the owner of the entry, or root
+func (wfs *WFS) checkOwner(header fuse.Header, entry *filer_pb.Entry) error {
+	if !wfs.option.Acl || entry == nil || entry.Attributes == nil || header.Uid == 0 || header.Uid == entry.Attributes.Uid {
+		return nil
+	}
+	return fuse.EPERM
+}
+
+func (wfs *WFS) identityOf(header fuse.Header) *filer.Identity {
+	uid, gid := wfs.option.UidGidMapper.LocalToFiler(header.Uid, header.Gid)
+	identity := &filer.Identity{Uid: uid, Gids: []uint32{gid}}
+	for _, localGid := range supplementaryGroups(header.Pid) {
+		_, filerGid := wfs.option.UidGidMapper.LocalToFiler(header.Uid, localGid)
+		identity.Gids = append(identity.Gids, filerGid)
+	}
+	return identity
+}
+
+// supplementaryGroups reads the supplementary groups of the process, which are not in the fuse requests
+func supplementaryGroups(pid uint32) (gids []uint32) {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "Groups:") {
+			continue
+		}
+		for _, field := range strings.Fields(strings.TrimPrefix(line, "Groups:")) {
+			if gid, parseErr := strconv.ParseUint(field, 10, 32); parseErr == nil {
+				gids = append(gids, uint32(gid))
+			}
+		}
+		break
+	}
+	return gids
+}
+
+// openPermission is the permissions needed to open with the flags
+func openPermission(flags fuse.OpenFlags) (perm uint16) {
+	switch {
+	case flags.IsReadOnly():
+		perm = filer.AclRead
+	case flags.IsWriteOnly():
+		perm = filer.AclWrite
+	case flags.IsReadWrite():
+		perm = filer.AclRead | filer.AclWrite
+	}
+	if flags&fuse.OpenTruncate != 0 {
+		perm |= filer.AclWrite
+	}
+	return perm
+}
+
+// permissionEntry is the entry of the directory to check the permissions, with the mount options for the mount root
+func (dir *Dir) permissionEntry() *filer_pb.Entry {
+	if dir.FullPath() == dir.wfs.option.FilerMountRootPath {
+		return &filer_pb.Entry{
+			IsDirectory: true,
+			Attributes: &filer_pb.FuseAttributes{
+				FileMode: uint32(dir.wfs.option.MountMode),
+				Uid:      dir.wfs.option.MountUid,
+				Gid:      dir.wfs.option.MountGid,
+			},
+		}
+	}
+	if err := dir.maybeLoadEntry(); err != nil {
+		return nil
+	}
+	return dir.entry
+}
+
+func (dir *Dir) checkPermission(header fuse.Header, perm uint16) error {
+	return dir.wfs.checkPermission(header, util.FullPath(dir.FullPath()), dir.permissionEntry(), perm)
+}
+
+// inheritDefaultAcl sets the ACLs of a new entry in the directory the same as the filer does, to keep the meta cache consistent
+func (dir *Dir) inheritDefaultAcl(entry *filer_pb.Entry) {
+	if err := dir.maybeLoadEntry(); err != nil || dir.entry == nil || len(dir.entry.Extended[filer.ExtPosixAclDefaultKey]) == 0 {
+		return
+	}
+	var mode os.FileMode
+	entry.Extended, mode = filer.InheritDefaultAcl(dir.entry.Extended, entry.Extended, os.FileMode(entry.Attributes.FileMode), entry.IsDirectory)
+	entry.Attributes.FileMode = uint32(mode)
+}
+
+var _ = fs.NodeAccesser(&Dir{})
+
+func (dir *Dir) Access(ctx context.Context, req *fuse.AccessRequest) error {
+	if !dir.wfs.option.Acl {
+		// https://github.com/bazil/fuse/issues/130
+		return fuse.ENOSYS
+	}
+	return dir.checkPermission(req.Header, uint16(req.Mask&7))
+}
+
+var _ = fs.NodeAccesser(&File{})
+
+func (file *File) Access(ctx context.Context, req *fuse.AccessRequest) error {
+	if !file.wfs.option.Acl {
+		return fuse.ENOSYS
+	}
+	entry, err := file.maybeLoadEntry(ctx)
+	if err != nil {
+		return err
+	}
+	return file.wfs.checkPermission(req.Header, file.fullpath(), entry, uint16(req.Mask&7))
+}
+
+// checkSetattrPermission checks the caller owns the entry to change its mode or owner, and can write to truncate it
+func (wfs *WFS) checkSetattrPermission(req *fuse.SetattrRequest, fullpath util.FullPath, entry *filer_pb.Entry) error {
+	if req.Valid.Mode() || req.Valid.Uid() || req.Valid.Gid() {
+		if err := wfs.checkOwner(req.Header, entry); err != nil {
+			return err
+		}
+	}
+	if req.Valid.Size() {
+		return wfs.checkPermission(req.Header, fullpath, entry, filer.AclWrite)
+	}
+	return nil
+}
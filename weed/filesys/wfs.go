@@ -44,6 +44,8 @@ type Option struct {
 	EntryCacheTtl      time.Duration
 	Umask              os.FileMode
 	ReadOnly           bool
+	FilerLock          bool // use filer locks for flock()
+	Acl                bool // check the permissions with the POSIX ACLs, instead of the mode bits in the kernel
 
 	MountUid   uint32
 	MountGid   uint32
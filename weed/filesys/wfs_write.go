@@ -4,6 +4,10 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"strings"
+	"syscall"
+
+	"github.com/seaweedfs/fuse"
 
 	"github.com/chrislusf/seaweedfs/weed/filer"
 	"github.com/chrislusf/seaweedfs/weed/glog"
@@ -73,3 +77,18 @@ func (wfs *WFS) saveDataAsChunk(fullPath util.FullPath) filer.SaveDataAsChunkFun
 		return chunk, collection, replication, nil
 	}
 }
+
+// toFuseError returns EDQUOT if a directory quota is exceeded, EROFS if the cluster is read only,
+// or EIO for the other errors from the filer
+func toFuseError(err error) error {
+	if strings.Contains(err.Error(), filer.ErrQuotaExceeded.Error()) {
+		return fuse.Errno(syscall.EDQUOT)
+	}
+	if strings.Contains(err.Error(), filer.ErrClusterReadOnly.Error()) {
+		return fuse.Errno(syscall.EROFS)
+	}
+	if strings.Contains(err.Error(), filer.ErrEntryHeld.Error()) || strings.Contains(err.Error(), filer.ErrEntryRetained.Error()) {
+		return fuse.EPERM
+	}
+	return fuse.EIO
+}
@@ -2,9 +2,11 @@ package filesys
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/seaweedfs/fuse"
 
+	"github.com/chrislusf/seaweedfs/weed/filer"
 	"github.com/chrislusf/seaweedfs/weed/filesys/meta_cache"
 	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
 	"github.com/chrislusf/seaweedfs/weed/util"
@@ -15,10 +17,7 @@ func getxattr(entry *filer_pb.Entry, req *fuse.GetxattrRequest, resp *fuse.Getxa
 	if entry == nil {
 		return fuse.ErrNoXattr
 	}
-	if entry.Extended == nil {
-		return fuse.ErrNoXattr
-	}
-	data, found := entry.Extended[req.Name]
+	data, found := entryXattr(entry, req.Name)
 	if !found {
 		return fuse.ErrNoXattr
 	}
@@ -38,11 +37,29 @@ func getxattr(entry *filer_pb.Entry, req *fuse.GetxattrRequest, resp *fuse.Getxa
 
 }
 
+// entryXattr returns the extended attribute, or the read only checksum of the file content.
+func entryXattr(entry *filer_pb.Entry, name string) ([]byte, bool) {
+	if name == filer.ChecksumXattrName {
+		if entry.Attributes == nil || len(entry.Attributes.Sha256) == 0 {
+			return nil, false
+		}
+		return []byte(fmt.Sprintf("%x", entry.Attributes.Sha256)), true
+	}
+	if entry.Extended == nil {
+		return nil, false
+	}
+	data, found := entry.Extended[name]
+	return data, found
+}
+
 func setxattr(entry *filer_pb.Entry, req *fuse.SetxattrRequest) error {
 
 	if entry == nil {
 		return fuse.EIO
 	}
+	if req.Name == filer.ChecksumXattrName {
+		return fuse.EPERM
+	}
 
 	if entry.Extended == nil {
 		entry.Extended = make(map[string][]byte)
@@ -66,6 +83,9 @@ func removexattr(entry *filer_pb.Entry, req *fuse.RemovexattrRequest) error {
 	if entry == nil {
 		return fuse.ErrNoXattr
 	}
+	if req.Name == filer.ChecksumXattrName {
+		return fuse.EPERM
+	}
 
 	if entry.Extended == nil {
 		return fuse.ErrNoXattr
@@ -92,6 +112,9 @@ func listxattr(entry *filer_pb.Entry, req *fuse.ListxattrRequest, resp *fuse.Lis
 	for k := range entry.Extended {
 		resp.Append(k)
 	}
+	if entry.Attributes != nil && len(entry.Attributes.Sha256) > 0 {
+		resp.Append(filer.ChecksumXattrName)
+	}
 
 	size := req.Size
 	if req.Position+size >= uint32(len(resp.Xattr)) {
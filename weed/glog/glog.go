@@ -800,9 +800,10 @@ func (l *loggingT) exit(err error) {
 type syncBuffer struct {
 	logger *loggingT
 	*bufio.Writer
-	file   *os.File
-	sev    severity
-	nbytes uint64 // The number of bytes written to this file
+	file      *os.File
+	sev       severity
+	nbytes    uint64    // The number of bytes written to this file
+	createdAt time.Time // The time this file is created
 }
 
 func (sb *syncBuffer) Sync() error {
@@ -813,7 +814,7 @@ func (sb *syncBuffer) Write(p []byte) (n int, err error) {
 	if sb.logger.exited {
 		return
 	}
-	if sb.nbytes+uint64(len(p)) >= MaxSize {
+	if sb.nbytes+uint64(len(p)) >= maxLogSize() || *logRotateAge > 0 && time.Since(sb.createdAt) >= *logRotateAge {
 		if err := sb.rotateFile(time.Now()); err != nil {
 			sb.logger.exit(err)
 		}
@@ -828,16 +829,21 @@ func (sb *syncBuffer) Write(p []byte) (n int, err error) {
 
 // rotateFile closes the syncBuffer's file and starts a new one.
 func (sb *syncBuffer) rotateFile(now time.Time) error {
+	var previous string
 	if sb.file != nil {
 		sb.Flush()
+		previous = sb.file.Name()
 		sb.file.Close()
 	}
 	var err error
-	sb.file, _, err = create(severityName[sb.sev], now)
+	var current string
+	sb.file, current, err = create(severityName[sb.sev], now)
 	sb.nbytes = 0
+	sb.createdAt = now
 	if err != nil {
 		return err
 	}
+	go archiveLogFiles(previous, current, severityName[sb.sev], now)
 
 	sb.Writer = bufio.NewWriterSize(sb.file, bufferSize)
 
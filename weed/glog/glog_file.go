@@ -19,12 +19,16 @@
 package glog
 
 import (
+	"compress/gzip"
 	"errors"
 	"fmt"
 	flag "github.com/chrislusf/seaweedfs/weed/util/fla9"
+	"io"
+	"io/ioutil"
 	"os"
 	"os/user"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -40,6 +44,17 @@ var logDirs []string
 // See createLogDirs for the full list of possible destinations.
 var logDir = flag.String("logdir", "", "If non-empty, write log files in this directory")
 
+// The log file rotation and retention, for the long running servers without an external logrotate.
+// A log file is rotated when it reaches the size, or the age. After each rotation, the previous file
+// can be compressed, and the old files of the same program and severity, also from the previous runs, are removed.
+var (
+	logMaxSizeMB = flag.Uint64("log_max_size_mb", 0, "If positive, rotate the log files reaching this size in MB, instead of the default size")
+	logRotateAge = flag.Duration("log_rotate_age", 0, "If positive, rotate the log files older than this, e.g. 24h")
+	logMaxFiles  = flag.Int("log_max_files", 0, "If positive, keep at most this number of log files of each severity, removing the oldest ones")
+	logMaxAge    = flag.Duration("log_max_age", 0, "If positive, remove the log files older than this, e.g. 168h")
+	logCompress  = flag.Bool("log_compress", false, "gzip the rotated log files")
+)
+
 func createLogDirs() {
 	if *logDir != "" {
 		logDirs = append(logDirs, *logDir)
@@ -78,14 +93,16 @@ func shortHostname(hostname string) string {
 	return hostname
 }
 
+// logNamePrefix returns the common prefix of the log file names containing tag.
+func logNamePrefix(tag string) string {
+	return fmt.Sprintf("%s.%s.%s.log.%s.", program, host, userName, tag)
+}
+
 // logName returns a new log file name containing tag, with start time t, and
 // the name for the symlink for tag.
 func logName(tag string, t time.Time) (name, link string) {
-	name = fmt.Sprintf("%s.%s.%s.log.%s.%04d%02d%02d-%02d%02d%02d.%d",
-		program,
-		host,
-		userName,
-		tag,
+	name = fmt.Sprintf("%s%04d%02d%02d-%02d%02d%02d.%d",
+		logNamePrefix(tag),
 		t.Year(),
 		t.Month(),
 		t.Day(),
@@ -122,3 +139,94 @@ func create(tag string, t time.Time) (f *os.File, filename string, err error) {
 	}
 	return nil, "", fmt.Errorf("log: cannot create log: %v", lastErr)
 }
+
+// maxLogSize returns the size to rotate the log files.
+func maxLogSize() uint64 {
+	if *logMaxSizeMB > 0 {
+		return *logMaxSizeMB * 1024 * 1024
+	}
+	return MaxSize
+}
+
+var rotationLock sync.Mutex
+
+// archiveLogFiles compresses the previous log file, and removes the old log files next to the current one.
+// It runs in the background, and reports errors to stderr, since it can not log.
+func archiveLogFiles(previous, current, tag string, now time.Time) {
+	rotationLock.Lock()
+	defer rotationLock.Unlock()
+
+	if previous != "" && *logCompress {
+		if err := compressLogFile(previous); err != nil {
+			fmt.Fprintf(os.Stderr, "log: compress %s: %v\n", previous, err)
+		}
+	}
+	if *logMaxFiles > 0 || *logMaxAge > 0 {
+		if err := removeOldLogFiles(filepath.Dir(current), logNamePrefix(tag), filepath.Base(current), *logMaxFiles, *logMaxAge, now); err != nil {
+			fmt.Fprintf(os.Stderr, "log: remove old log files: %v\n", err)
+		}
+	}
+}
+
+// compressLogFile replaces the file with its gzipped file.
+func compressLogFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmpName := name + ".gz.tmp"
+	dst, err := os.Create(tmpName)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if err == nil {
+		err = gz.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpName, name+".gz")
+	}
+	if err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return os.Remove(name)
+}
+
+// removeOldLogFiles removes the log files with the prefix in dir, older than maxAge,
+// or beyond the newest maxFiles including the current file.
+func removeOldLogFiles(dir, prefix, current string, maxFiles int, maxAge time.Duration, now time.Time) error {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var files []os.FileInfo
+	for _, info := range infos {
+		if info.Mode().IsRegular() && strings.HasPrefix(info.Name(), prefix) && info.Name() != current && !strings.HasSuffix(info.Name(), ".tmp") {
+			files = append(files, info)
+		}
+	}
+	// the names contain the creation time, so the newest are the last
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name() < files[j].Name()
+	})
+
+	var lastErr error
+	for i, info := range files {
+		isExtra := maxFiles > 0 && len(files)-i >= maxFiles
+		isExpired := maxAge > 0 && now.Sub(info.ModTime()) > maxAge
+		if !isExtra && !isExpired {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, info.Name())); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
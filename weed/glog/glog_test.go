@@ -18,8 +18,11 @@ package glog
 
 import (
 	"bytes"
+	"compress/gzip"
 	"fmt"
+	"io/ioutil"
 	stdLog "log"
+	"os"
 	"path/filepath"
 	"runtime"
 	"strconv"
@@ -371,6 +374,121 @@ func TestRollover(t *testing.T) {
 	}
 }
 
+func TestRolloverByAge(t *testing.T) {
+	setFlags()
+	var err error
+	defer func(previous func(error)) { logExitFunc = previous }(logExitFunc)
+	logExitFunc = func(e error) {
+		err = e
+	}
+	defer func(previous time.Duration) { *logRotateAge = previous }(*logRotateAge)
+	*logRotateAge = time.Second
+
+	Info("x") // Be sure we have a file.
+	info, ok := logging.file[infoLog].(*syncBuffer)
+	if !ok {
+		t.Fatal("info wasn't created")
+	}
+	fname0 := info.file.Name()
+	time.Sleep(1100 * time.Millisecond)
+
+	Info("x") // rotate the old file
+	if err != nil {
+		t.Fatalf("error after rotation: %v", err)
+	}
+	if fname1 := info.file.Name(); fname0 == fname1 {
+		t.Errorf("info.f.Name did not change: %v", fname0)
+	}
+}
+
+func TestCompressLogFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "test.log")
+	if err = ioutil.WriteFile(name, []byte("some log lines\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = compressLogFile(name); err != nil {
+		t.Fatalf("compress: %v", err)
+	}
+	if _, err = os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("the original file should be removed: %v", err)
+	}
+
+	f, err := os.Open(name + ".gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadAll(gz); err != nil || string(data) != "some log lines\n" {
+		t.Errorf("uncompressed %q: %v", data, err)
+	}
+}
+
+func TestRemoveOldLogFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	prefix := logNamePrefix("INFO")
+	var names []string
+	for i := 0; i < 5; i++ {
+		name, _ := logName("INFO", now.Add(time.Duration(i-5)*time.Hour))
+		if i < 3 {
+			name += ".gz"
+		}
+		names = append(names, name)
+		if err = ioutil.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := now.Add(time.Duration(i-5) * time.Hour)
+		os.Chtimes(filepath.Join(dir, name), mtime, mtime)
+	}
+	other, _ := logName("ERROR", now.Add(-10*time.Hour))
+	ioutil.WriteFile(filepath.Join(dir, other), nil, 0644)
+	current := names[4]
+
+	exists := func() (existing []string) {
+		for _, name := range names {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				existing = append(existing, name)
+			}
+		}
+		return
+	}
+
+	// keep the newest 4 files, including the current file
+	if err = removeOldLogFiles(dir, prefix, current, 4, 0, now); err != nil {
+		t.Fatal(err)
+	}
+	if existing := exists(); len(existing) != 4 || existing[0] != names[1] {
+		t.Errorf("after keeping 4: %v", existing)
+	}
+
+	// remove the files older than 2.5 hours, except the current file
+	if err = removeOldLogFiles(dir, prefix, current, 0, 150*time.Minute, now); err != nil {
+		t.Fatal(err)
+	}
+	if existing := exists(); len(existing) != 2 || existing[0] != names[3] {
+		t.Errorf("after removing expired: %v", existing)
+	}
+
+	if _, err = os.Stat(filepath.Join(dir, other)); err != nil {
+		t.Errorf("the other severity should not be removed: %v", err)
+	}
+}
+
 func TestLogBacktraceAt(t *testing.T) {
 	setFlags()
 	defer logging.swap(logging.newBuffers())
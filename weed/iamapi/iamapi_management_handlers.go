@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/pb/iam_pb"
+	"github.com/chrislusf/seaweedfs/weed/s3api/policy_engine"
 	"github.com/chrislusf/seaweedfs/weed/s3api/s3_constants"
 	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
 	"math/rand"
@@ -71,26 +72,15 @@ func MapToIdentitiesAction(action string) string {
 	}
 }
 
-type Statement struct {
-	Effect   string   `json:"Effect"`
-	Action   []string `json:"Action"`
-	Resource []string `json:"Resource"`
-}
+// the policy documents are shared with the s3 bucket policies
+type Statement = policy_engine.Statement
+
+type PolicyDocument = policy_engine.PolicyDocument
 
 type Policies struct {
 	Policies map[string]PolicyDocument `json:"policies"`
 }
 
-type PolicyDocument struct {
-	Version   string       `json:"Version"`
-	Statement []*Statement `json:"Statement"`
-}
-
-func (p PolicyDocument) String() string {
-	b, _ := json.Marshal(p)
-	return string(b)
-}
-
 func Hash(s *string) string {
 	h := sha1.New()
 	h.Write([]byte(*s))
@@ -155,6 +145,9 @@ func GetPolicyDocument(policy *string) (policyDocument PolicyDocument, err error
 	if err = json.Unmarshal([]byte(*policy), &policyDocument); err != nil {
 		return PolicyDocument{}, err
 	}
+	if err = policyDocument.Validate(); err != nil {
+		return PolicyDocument{}, err
+	}
 	return policyDocument, err
 }
 
@@ -235,7 +228,7 @@ func (iama *IamApiServer) GetUserPolicy(s3cfg *iam_pb.S3ApiConfiguration, values
 		for resource, actions := range statements {
 			isEqAction := false
 			for i, statement := range policyDocument.Statement {
-				if reflect.DeepEqual(statement.Action, actions) {
+				if reflect.DeepEqual([]string(statement.Action), actions) {
 					policyDocument.Statement[i].Resource = append(
 						policyDocument.Statement[i].Resource, resource)
 					isEqAction = true
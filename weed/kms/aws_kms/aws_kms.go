@@ -0,0 +1,82 @@
+package aws_kms
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	weed_kms "github.com/chrislusf/seaweedfs/weed/kms"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func init() {
+	weed_kms.KeyManagers = append(weed_kms.KeyManagers, &AwsKms{})
+}
+
+type AwsKms struct {
+	svc   *kms.KMS
+	keyId string
+}
+
+func (k *AwsKms) GetName() string {
+	return "aws"
+}
+
+func (k *AwsKms) Initialize(configuration util.Configuration, prefix string) (err error) {
+	glog.V(0).Infof("kms.aws.region: %v", configuration.GetString(prefix+"region"))
+	glog.V(0).Infof("kms.aws.key_id: %v", configuration.GetString(prefix+"key_id"))
+	return k.initialize(
+		configuration.GetString(prefix+"aws_access_key_id"),
+		configuration.GetString(prefix+"aws_secret_access_key"),
+		configuration.GetString(prefix+"region"),
+		configuration.GetString(prefix+"key_id"),
+	)
+}
+
+func (k *AwsKms) initialize(awsAccessKeyId, awsSecretAccessKey, region, keyId string) (err error) {
+
+	if keyId == "" {
+		return fmt.Errorf("missing key_id")
+	}
+
+	config := &aws.Config{
+		Region: aws.String(region),
+	}
+	if awsAccessKeyId != "" && awsSecretAccessKey != "" {
+		config.Credentials = credentials.NewStaticCredentials(awsAccessKeyId, awsSecretAccessKey, "")
+	}
+
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return fmt.Errorf("create aws session: %v", err)
+	}
+	k.svc = kms.New(sess)
+	k.keyId = keyId
+
+	return nil
+}
+
+func (k *AwsKms) GenerateDataKey() (plainKey, wrappedKey []byte, err error) {
+	result, err := k.svc.GenerateDataKey(&kms.GenerateDataKeyInput{
+		KeyId:   aws.String(k.keyId),
+		KeySpec: aws.String(kms.DataKeySpecAes256),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate data key with %s: %v", k.keyId, err)
+	}
+	return result.Plaintext, result.CiphertextBlob, nil
+}
+
+func (k *AwsKms) DecryptDataKey(wrappedKey []byte) ([]byte, error) {
+	result, err := k.svc.Decrypt(&kms.DecryptInput{
+		KeyId:          aws.String(k.keyId),
+		CiphertextBlob: wrappedKey,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decrypt data key with %s: %v", k.keyId, err)
+	}
+	return result.Plaintext, nil
+}
@@ -0,0 +1,88 @@
+package kms
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// KeyManager wraps and unwraps the data keys used to encrypt volume data at rest.
+type KeyManager interface {
+	// GetName gets the name to locate the configuration in security.toml file
+	GetName() string
+	// Initialize initializes the key manager
+	Initialize(configuration util.Configuration, prefix string) error
+	// GenerateDataKey returns a new data key, in plain text and wrapped by the key manager
+	GenerateDataKey() (plainKey, wrappedKey []byte, err error)
+	// DecryptDataKey unwraps a data key wrapped by the key manager
+	DecryptDataKey(wrappedKey []byte) ([]byte, error)
+}
+
+const (
+	AllCollections = "*"
+)
+
+var (
+	KeyManagers []KeyManager
+)
+
+// LoadKeyManager returns the enabled key manager in security.toml, or nil if none is enabled.
+func LoadKeyManager(config *util.ViperProxy) (KeyManager, error) {
+	var enabled KeyManager
+	for _, km := range KeyManagers {
+		if !config.GetBool("kms." + km.GetName() + ".enabled") {
+			continue
+		}
+		if enabled != nil {
+			return nil, fmt.Errorf("kms is enabled for both %s and %s", enabled.GetName(), km.GetName())
+		}
+		enabled = km
+	}
+	if enabled == nil {
+		return nil, nil
+	}
+	if err := enabled.Initialize(config, "kms."+enabled.GetName()+"."); err != nil {
+		return nil, fmt.Errorf("initialize kms %s: %v", enabled.GetName(), err)
+	}
+	glog.V(0).Infof("Configure kms %s", enabled.GetName())
+	return enabled, nil
+}
+
+// LoadDataKeys unwraps the data keys of each collection configured in security.toml.
+// The "*" collection applies to all collections not listed.
+func LoadDataKeys(config *util.ViperProxy) (dataKeys map[string][]byte, err error) {
+
+	wrappedKeys := config.GetStringMapString("volume.encryption.collections")
+	if len(wrappedKeys) == 0 {
+		return nil, nil
+	}
+
+	km, err := LoadKeyManager(config)
+	if err != nil {
+		return nil, err
+	}
+	if km == nil {
+		return nil, fmt.Errorf("volume.encryption requires a kms")
+	}
+
+	dataKeys = make(map[string][]byte)
+	for collection, wrappedKey := range wrappedKeys {
+		wrapped, decodeErr := base64.StdEncoding.DecodeString(strings.TrimSpace(wrappedKey))
+		if decodeErr != nil {
+			return nil, fmt.Errorf("decode data key of collection %s: %v", collection, decodeErr)
+		}
+		dataKey, decryptErr := km.DecryptDataKey(wrapped)
+		if decryptErr != nil {
+			return nil, fmt.Errorf("decrypt data key of collection %s: %v", collection, decryptErr)
+		}
+		if len(dataKey) != 32 {
+			return nil, fmt.Errorf("data key of collection %s has %d bytes, expecting 32 bytes", collection, len(dataKey))
+		}
+		dataKeys[collection] = dataKey
+		glog.V(0).Infof("volume data at rest encryption is enabled for collection %s", collection)
+	}
+	return dataKeys, nil
+}
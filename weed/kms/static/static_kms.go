@@ -0,0 +1,54 @@
+package static
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/kms"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func init() {
+	kms.KeyManagers = append(kms.KeyManagers, &StaticKms{})
+}
+
+// StaticKms wraps the data keys with a master key read from a local key file.
+type StaticKms struct {
+	masterKey util.CipherKey
+}
+
+func (k *StaticKms) GetName() string {
+	return "static"
+}
+
+func (k *StaticKms) Initialize(configuration util.Configuration, prefix string) (err error) {
+	return k.initialize(configuration.GetString(prefix + "key_file"))
+}
+
+func (k *StaticKms) initialize(keyFile string) error {
+	data, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return fmt.Errorf("read key file %s: %v", keyFile, err)
+	}
+	masterKey, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return fmt.Errorf("decode key file %s: %v", keyFile, err)
+	}
+	if len(masterKey) != 32 {
+		return fmt.Errorf("key file %s has %d bytes key, expecting 32 bytes", keyFile, len(masterKey))
+	}
+	k.masterKey = masterKey
+	return nil
+}
+
+func (k *StaticKms) GenerateDataKey() (plainKey, wrappedKey []byte, err error) {
+	plainKey = util.GenCipherKey()
+	wrappedKey, err = util.Encrypt(plainKey, k.masterKey)
+	return
+}
+
+func (k *StaticKms) DecryptDataKey(wrappedKey []byte) ([]byte, error) {
+	return util.Decrypt(wrappedKey, k.masterKey)
+}
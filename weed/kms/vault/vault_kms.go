@@ -0,0 +1,118 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/kms"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func init() {
+	kms.KeyManagers = append(kms.KeyManagers, &VaultKms{})
+}
+
+// VaultKms wraps the data keys with a key of the HashiCorp Vault transit secrets engine.
+// The wrapped key is the vault ciphertext, e.g., "vault:v1:...".
+type VaultKms struct {
+	address    string
+	token      string
+	transitKey string
+	client     *http.Client
+}
+
+func (k *VaultKms) GetName() string {
+	return "vault"
+}
+
+func (k *VaultKms) Initialize(configuration util.Configuration, prefix string) (err error) {
+	glog.V(0).Infof("kms.vault.address: %v", configuration.GetString(prefix+"address"))
+	glog.V(0).Infof("kms.vault.transit_key: %v", configuration.GetString(prefix+"transit_key"))
+	return k.initialize(
+		configuration.GetString(prefix+"address"),
+		configuration.GetString(prefix+"token"),
+		configuration.GetString(prefix+"transit_key"),
+	)
+}
+
+func (k *VaultKms) initialize(address, token, transitKey string) error {
+	if address == "" || transitKey == "" {
+		return fmt.Errorf("missing address or transit_key")
+	}
+	k.address = strings.TrimRight(address, "/")
+	k.token = token
+	k.transitKey = transitKey
+	k.client = &http.Client{Timeout: 10 * time.Second}
+	return nil
+}
+
+func (k *VaultKms) GenerateDataKey() (plainKey, wrappedKey []byte, err error) {
+	var result struct {
+		Data struct {
+			Plaintext  string `json:"plaintext"`
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}
+	if err = k.post("/v1/transit/datakey/plaintext/"+k.transitKey, map[string]interface{}{"bits": 256}, &result); err != nil {
+		return nil, nil, err
+	}
+	plainKey, err = base64.StdEncoding.DecodeString(result.Data.Plaintext)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decode vault data key: %v", err)
+	}
+	return plainKey, []byte(result.Data.Ciphertext), nil
+}
+
+func (k *VaultKms) DecryptDataKey(wrappedKey []byte) ([]byte, error) {
+	var result struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+	if err := k.post("/v1/transit/decrypt/"+k.transitKey, map[string]interface{}{"ciphertext": string(wrappedKey)}, &result); err != nil {
+		return nil, err
+	}
+	plainKey, err := base64.StdEncoding.DecodeString(result.Data.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("decode vault data key: %v", err)
+	}
+	return plainKey, nil
+}
+
+func (k *VaultKms) post(path string, input, output interface{}) error {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, k.address+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if k.token != "" {
+		req.Header.Set("X-Vault-Token", k.token)
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("vault %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("vault %s: %v", path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("vault %s: %s %s", path, resp.Status, data)
+	}
+	if err = json.Unmarshal(data, output); err != nil {
+		return fmt.Errorf("vault %s: %v", path, err)
+	}
+	return nil
+}
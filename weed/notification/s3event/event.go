@@ -0,0 +1,75 @@
+package s3event
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/notification-content-structure.html
+
+type Event struct {
+	Records []*Record `json:"Records"`
+}
+
+type Identity struct {
+	PrincipalId string `json:"principalId"`
+}
+
+type Record struct {
+	EventVersion      string            `json:"eventVersion"`
+	EventSource       string            `json:"eventSource"`
+	AwsRegion         string            `json:"awsRegion"`
+	EventTime         string            `json:"eventTime"`
+	EventName         string            `json:"eventName"`
+	UserIdentity      Identity          `json:"userIdentity"`
+	RequestParameters map[string]string `json:"requestParameters"`
+	ResponseElements  map[string]string `json:"responseElements"`
+	S3                struct {
+		SchemaVersion   string `json:"s3SchemaVersion"`
+		ConfigurationId string `json:"configurationId"`
+		Bucket          struct {
+			Name          string   `json:"name"`
+			OwnerIdentity Identity `json:"ownerIdentity"`
+			Arn           string   `json:"arn"`
+		} `json:"bucket"`
+		Object struct {
+			Key       string `json:"key"`
+			Size      uint64 `json:"size,omitempty"`
+			ETag      string `json:"eTag,omitempty"`
+			Sequencer string `json:"sequencer"`
+		} `json:"object"`
+	} `json:"s3"`
+}
+
+// ObjectInfo is the object of an event
+type ObjectInfo struct {
+	Bucket      string
+	Key         string // without the leading "/"
+	Size        uint64
+	ETag        string
+	PrincipalId string
+	Time        time.Time
+}
+
+func NewRecord(rule *Rule, eventName string, object *ObjectInfo) *Record {
+	r := &Record{
+		EventVersion:      "2.1",
+		EventSource:       "aws:s3",
+		AwsRegion:         "us-east-1",
+		EventTime:         object.Time.UTC().Format("2006-01-02T15:04:05.000Z"),
+		EventName:         eventName,
+		UserIdentity:      Identity{PrincipalId: object.PrincipalId},
+		RequestParameters: map[string]string{},
+		ResponseElements:  map[string]string{},
+	}
+	r.S3.SchemaVersion = "1.0"
+	r.S3.ConfigurationId = rule.Id
+	r.S3.Bucket.Name = object.Bucket
+	r.S3.Bucket.Arn = "arn:aws:s3:::" + object.Bucket
+	r.S3.Object.Key = url.QueryEscape(object.Key)
+	r.S3.Object.Size = object.Size
+	r.S3.Object.ETag = object.ETag
+	r.S3.Object.Sequencer = fmt.Sprintf("%016X", object.Time.UnixNano())
+	return r
+}
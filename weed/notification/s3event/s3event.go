@@ -0,0 +1,190 @@
+package s3event
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+/*
+The s3 bucket notifications are set by PutBucketNotificationConfiguration, and kept in the ExtS3NotificationKey
+extended attribute of the bucket directory. When the filer creates, overwrites or deletes an object under the buckets,
+it sends the event in the AWS event json format to the targets of the matched bucket notification rules.
+
+The targets are configured in notification.toml, and referenced in the rules by their arns:
+
+	arn:seaweedfs:kafka:::<topic>        one of the kafka topics
+	arn:seaweedfs:nats:::<subject>       one of the nats subjects
+	arn:seaweedfs:webhook:::<url>        one of the webhook endpoints
+
+Only the configured topics, subjects and endpoints can be used.
+
+The uploads, copies and completed multipart uploads are all reported as "ObjectCreated:Put", and the deletions as
+"ObjectRemoved:Delete". The metadata only updates, e.g. the tagging, do not send any events.
+The events are sent by the filer applying the change, so the events replicated from other clusters are not sent again.
+The events are sent in the background, and dropped if the filer queue or the target buffer is full.
+*/
+
+const (
+	ExtS3NotificationKey = "x-seaweedfs-s3-notification"
+
+	EventObjectCreatedPut    = "ObjectCreated:Put"
+	EventObjectRemovedDelete = "ObjectRemoved:Delete"
+
+	arnPrefix = "arn:seaweedfs:"
+)
+
+var supportedEvents = map[string]bool{
+	"s3:ObjectCreated:*":                       true,
+	"s3:ObjectCreated:Put":                     true,
+	"s3:ObjectCreated:Post":                    true,
+	"s3:ObjectCreated:Copy":                    true,
+	"s3:ObjectCreated:CompleteMultipartUpload": true,
+	"s3:ObjectRemoved:*":                       true,
+	"s3:ObjectRemoved:Delete":                  true,
+}
+
+type NotificationConfiguration struct {
+	XMLName             xml.Name             `xml:"http://s3.amazonaws.com/doc/2006-03-01/ NotificationConfiguration"`
+	TopicConfigurations []TopicConfiguration `xml:"TopicConfiguration"`
+	QueueConfigurations []QueueConfiguration `xml:"QueueConfiguration"`
+}
+
+type TopicConfiguration struct {
+	Id     string   `xml:"Id,omitempty"`
+	Topic  string   `xml:"Topic"`
+	Events []string `xml:"Event"`
+	Filter *Filter  `xml:"Filter,omitempty"`
+}
+
+type QueueConfiguration struct {
+	Id     string   `xml:"Id,omitempty"`
+	Queue  string   `xml:"Queue"`
+	Events []string `xml:"Event"`
+	Filter *Filter  `xml:"Filter,omitempty"`
+}
+
+type Filter struct {
+	S3Key struct {
+		FilterRules []FilterRule `xml:"FilterRule"`
+	} `xml:"S3Key"`
+}
+
+type FilterRule struct {
+	Name  string `xml:"Name"`
+	Value string `xml:"Value"`
+}
+
+// Rule is a topic or queue configuration
+type Rule struct {
+	Id     string
+	Arn    string
+	Events []string
+	Prefix string
+	Suffix string
+}
+
+func ParseNotificationConfiguration(data []byte) (*NotificationConfiguration, error) {
+	c := &NotificationConfiguration{}
+	if err := xml.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Rules returns the topic and queue configurations as the rules
+func (c *NotificationConfiguration) Rules() (rules []*Rule) {
+	for _, t := range c.TopicConfigurations {
+		rules = append(rules, newRule(t.Id, t.Topic, t.Events, t.Filter))
+	}
+	for _, q := range c.QueueConfigurations {
+		rules = append(rules, newRule(q.Id, q.Queue, q.Events, q.Filter))
+	}
+	return
+}
+
+func newRule(id, arn string, events []string, filter *Filter) *Rule {
+	rule := &Rule{Id: id, Arn: arn, Events: events}
+	if filter != nil {
+		for _, r := range filter.S3Key.FilterRules {
+			switch strings.ToLower(r.Name) {
+			case "prefix":
+				rule.Prefix = r.Value
+			case "suffix":
+				rule.Suffix = r.Value
+			}
+		}
+	}
+	return rule
+}
+
+// Validate checks the events, the filters and the target arns of the rules
+func (c *NotificationConfiguration) Validate() error {
+	for _, t := range c.TopicConfigurations {
+		if err := validateRule(t.Topic, t.Events, t.Filter); err != nil {
+			return err
+		}
+	}
+	for _, q := range c.QueueConfigurations {
+		if err := validateRule(q.Queue, q.Events, q.Filter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateRule(arn string, events []string, filter *Filter) error {
+	if _, _, err := ParseArn(arn); err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		return fmt.Errorf("no event for %s", arn)
+	}
+	for _, event := range events {
+		if !supportedEvents[event] {
+			return fmt.Errorf("unsupported event %s", event)
+		}
+	}
+	if filter != nil {
+		names := make(map[string]bool)
+		for _, r := range filter.S3Key.FilterRules {
+			name := strings.ToLower(r.Name)
+			if (name != "prefix" && name != "suffix") || names[name] {
+				return fmt.Errorf("invalid filter rule name %s", r.Name)
+			}
+			names[name] = true
+		}
+	}
+	return nil
+}
+
+// ParseArn returns the target type and the topic, subject or url of the target arn
+func ParseArn(arn string) (targetType, name string, err error) {
+	parts := strings.SplitN(strings.TrimPrefix(arn, arnPrefix), ":", 4)
+	if !strings.HasPrefix(arn, arnPrefix) || len(parts) != 4 || parts[3] == "" {
+		return "", "", fmt.Errorf("invalid target arn %s", arn)
+	}
+	switch parts[0] {
+	case targetKafka, targetNats, targetWebhook:
+	default:
+		return "", "", fmt.Errorf("unsupported target %s", arn)
+	}
+	return parts[0], parts[3], nil
+}
+
+// IsMatched checks the event name, e.g. "ObjectCreated:Put", and the object key without the leading "/"
+func (r *Rule) IsMatched(eventName, key string) bool {
+	if !strings.HasPrefix(key, r.Prefix) || !strings.HasSuffix(key, r.Suffix) {
+		return false
+	}
+	for _, event := range r.Events {
+		event = strings.TrimPrefix(event, "s3:")
+		if event == eventName {
+			return true
+		}
+		if strings.HasSuffix(event, "*") && strings.HasPrefix(eventName, strings.TrimSuffix(event, "*")) {
+			return true
+		}
+	}
+	return false
+}
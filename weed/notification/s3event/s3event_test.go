@@ -0,0 +1,102 @@
+package s3event
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotificationConfiguration(t *testing.T) {
+	c, err := ParseNotificationConfiguration([]byte(`<NotificationConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <TopicConfiguration>
+    <Id>images</Id>
+    <Topic>arn:seaweedfs:kafka:::image-events</Topic>
+    <Event>s3:ObjectCreated:*</Event>
+    <Filter><S3Key>
+      <FilterRule><Name>prefix</Name><Value>images/</Value></FilterRule>
+      <FilterRule><Name>suffix</Name><Value>.jpg</Value></FilterRule>
+    </S3Key></Filter>
+  </TopicConfiguration>
+  <QueueConfiguration>
+    <Queue>arn:seaweedfs:webhook:::http://localhost:8080/hook</Queue>
+    <Event>s3:ObjectRemoved:Delete</Event>
+  </QueueConfiguration>
+</NotificationConfiguration>`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	assert.Nil(t, c.Validate())
+
+	rules := c.Rules()
+	assert.Equal(t, 2, len(rules))
+	assert.True(t, rules[0].IsMatched(EventObjectCreatedPut, "images/a.jpg"))
+	assert.False(t, rules[0].IsMatched(EventObjectCreatedPut, "images/a.png"))
+	assert.False(t, rules[0].IsMatched(EventObjectRemovedDelete, "images/a.jpg"))
+	assert.True(t, rules[1].IsMatched(EventObjectRemovedDelete, "docs/a.txt"))
+
+	targetType, name, err := ParseArn(rules[1].Arn)
+	assert.Nil(t, err)
+	assert.Equal(t, "webhook", targetType)
+	assert.Equal(t, "http://localhost:8080/hook", name)
+
+	for _, invalid := range []*NotificationConfiguration{
+		{TopicConfigurations: []TopicConfiguration{{Topic: "arn:aws:sns:us-east-1:123456789012:topic", Events: []string{"s3:ObjectCreated:*"}}}},
+		{TopicConfigurations: []TopicConfiguration{{Topic: "arn:seaweedfs:kafka:::", Events: []string{"s3:ObjectCreated:*"}}}},
+		{QueueConfigurations: []QueueConfiguration{{Queue: "arn:seaweedfs:nats:::subject", Events: []string{"s3:ObjectRestore:Post"}}}},
+		{QueueConfigurations: []QueueConfiguration{{Queue: "arn:seaweedfs:nats:::subject"}}},
+	} {
+		assert.NotNil(t, invalid.Validate(), "%+v", invalid)
+	}
+}
+
+func TestWebhookTarget(t *testing.T) {
+	received := make(chan *Event, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "sha256=", r.Header.Get(SignatureHeader)[:7])
+		body, _ := ioutil.ReadAll(r.Body)
+		event := &Event{}
+		json.Unmarshal(body, event)
+		received <- event
+	}))
+	defer server.Close()
+
+	target, err := newWebhookTarget([]string{server.URL}, "secret", 1, 10)
+	if err != nil {
+		t.Fatalf("new webhook: %v", err)
+	}
+	targets[targetWebhook] = target
+	defer delete(targets, targetWebhook)
+
+	rule := &Rule{Id: "all", Arn: "arn:seaweedfs:webhook:::" + server.URL}
+	object := &ObjectInfo{Bucket: "bucket", Key: "a b.txt", Size: 3, ETag: "abc", Time: time.Now()}
+	assert.Nil(t, Send(rule.Arn, "bucket/a b.txt", &Event{Records: []*Record{NewRecord(rule, EventObjectCreatedPut, object)}}))
+	assert.NotNil(t, Send("arn:seaweedfs:webhook:::http://other/hook", "bucket/a b.txt", &Event{}))
+
+	select {
+	case event := <-received:
+		assert.Equal(t, 1, len(event.Records))
+		record := event.Records[0]
+		assert.Equal(t, "ObjectCreated:Put", record.EventName)
+		assert.Equal(t, "all", record.S3.ConfigurationId)
+		assert.Equal(t, "bucket", record.S3.Bucket.Name)
+		assert.Equal(t, "a+b.txt", record.S3.Object.Key)
+		assert.Equal(t, uint64(3), record.S3.Object.Size)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no event received")
+	}
+}
+
+func TestAllowedNames(t *testing.T) {
+	_, err := newAllowedNames("kafka topics", nil)
+	assert.NotNil(t, err)
+
+	allowed, err := newAllowedNames("kafka topics", []string{"s3-events"})
+	assert.Nil(t, err)
+	assert.Nil(t, allowed.check("kafka topic", "s3-events"))
+	assert.NotNil(t, allowed.check("kafka topic", "other"))
+}
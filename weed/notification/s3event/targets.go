@@ -0,0 +1,260 @@
+package s3event
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	targetKafka   = "kafka"
+	targetNats    = "nats"
+	targetWebhook = "webhook"
+
+	SignatureHeader = "X-Seaweedfs-Signature"
+)
+
+// Target sends the events to the topic, subject or url of a target arn
+type Target interface {
+	Send(name, key string, body []byte) error
+}
+
+var targets = make(map[string]Target)
+
+// LoadConfiguration initializes the enabled targets, e.g. with the prefix "notification.s3."
+func LoadConfiguration(configuration util.Configuration, prefix string) error {
+	if configuration.GetBool(prefix + targetKafka + ".enabled") {
+		t, err := newKafkaTarget(
+			configuration.GetStringSlice(prefix+targetKafka+".hosts"),
+			configuration.GetStringSlice(prefix+targetKafka+".topics"),
+		)
+		if err != nil {
+			return fmt.Errorf("s3 event kafka: %v", err)
+		}
+		targets[targetKafka] = t
+	}
+	if configuration.GetBool(prefix + targetNats + ".enabled") {
+		t, err := newNatsTarget(
+			configuration.GetString(prefix+targetNats+".url"),
+			configuration.GetStringSlice(prefix+targetNats+".subjects"),
+		)
+		if err != nil {
+			return fmt.Errorf("s3 event nats: %v", err)
+		}
+		targets[targetNats] = t
+	}
+	if configuration.GetBool(prefix + targetWebhook + ".enabled") {
+		t, err := newWebhookTarget(
+			configuration.GetStringSlice(prefix+targetWebhook+".endpoints"),
+			configuration.GetString(prefix+targetWebhook+".secret"),
+			configuration.GetInt(prefix+targetWebhook+".timeout_seconds"),
+			configuration.GetInt(prefix+targetWebhook+".buffer_size"),
+		)
+		if err != nil {
+			return fmt.Errorf("s3 event webhook: %v", err)
+		}
+		targets[targetWebhook] = t
+	}
+	for name := range targets {
+		glog.V(0).Infof("s3 event notification target %s", name)
+	}
+	return nil
+}
+
+func IsEnabled() bool {
+	return len(targets) > 0
+}
+
+// Send sends the event to the target of the arn
+func Send(arn, key string, event *Event) error {
+	targetType, name, err := ParseArn(arn)
+	if err != nil {
+		return err
+	}
+	t, found := targets[targetType]
+	if !found {
+		return fmt.Errorf("target %s is not enabled", targetType)
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return t.Send(name, key, body)
+}
+
+// allowedNames are the configured topics, subjects or urls, so the bucket owners can not send the events elsewhere
+type allowedNames map[string]bool
+
+func newAllowedNames(kind string, names []string) (allowedNames, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no %s configured", kind)
+	}
+	allowed := make(allowedNames)
+	for _, name := range names {
+		allowed[name] = true
+	}
+	return allowed, nil
+}
+
+func (a allowedNames) check(kind, name string) error {
+	if !a[name] {
+		return fmt.Errorf("%s %s is not configured", kind, name)
+	}
+	return nil
+}
+
+type kafkaTarget struct {
+	producer sarama.AsyncProducer
+	topics   allowedNames
+}
+
+func newKafkaTarget(hosts, topics []string) (*kafkaTarget, error) {
+	allowed, err := newAllowedNames("kafka topics", topics)
+	if err != nil {
+		return nil, err
+	}
+	config := sarama.NewConfig()
+	config.Producer.RequiredAcks = sarama.WaitForLocal
+	config.Producer.Partitioner = sarama.NewHashPartitioner
+	config.Producer.Return.Errors = true
+	producer, err := sarama.NewAsyncProducer(hosts, config)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for err := range producer.Errors() {
+			glog.Errorf("s3 event to kafka topic %s key %v: %v", err.Msg.Topic, err.Msg.Key, err.Err)
+		}
+	}()
+	return &kafkaTarget{producer: producer, topics: allowed}, nil
+}
+
+// Send does not wait for the producer, the event is dropped if the producer is busy
+func (k *kafkaTarget) Send(topic, key string, body []byte) error {
+	if err := k.topics.check("kafka topic", topic); err != nil {
+		return err
+	}
+	select {
+	case k.producer.Input() <- &sarama.ProducerMessage{
+		Topic: topic,
+		Key:   sarama.StringEncoder(key),
+		Value: sarama.ByteEncoder(body),
+	}:
+		return nil
+	default:
+		return fmt.Errorf("kafka producer is busy, dropped the event of %s", key)
+	}
+}
+
+type natsTarget struct {
+	conn     *nats.Conn
+	subjects allowedNames
+}
+
+func newNatsTarget(url string, subjects []string) (*natsTarget, error) {
+	allowed, err := newAllowedNames("nats subjects", subjects)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := nats.Connect(url, nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, err
+	}
+	return &natsTarget{conn: conn, subjects: allowed}, nil
+}
+
+func (n *natsTarget) Send(subject, key string, body []byte) error {
+	if err := n.subjects.check("nats subject", subject); err != nil {
+		return err
+	}
+	return n.conn.Publish(subject, body)
+}
+
+type webhookTarget struct {
+	endpoints allowedNames
+	secret    []byte
+	client    *http.Client
+	requests  chan *webhookRequest
+}
+
+type webhookRequest struct {
+	url  string
+	body []byte
+}
+
+func newWebhookTarget(endpoints []string, secret string, timeoutSeconds, bufferSize int) (*webhookTarget, error) {
+	allowed, err := newAllowedNames("webhook endpoints", endpoints)
+	if err != nil {
+		return nil, err
+	}
+	if timeoutSeconds <= 0 {
+		timeoutSeconds = 10
+	}
+	if bufferSize <= 0 {
+		bufferSize = 10000
+	}
+	w := &webhookTarget{
+		endpoints: allowed,
+		secret:    []byte(secret),
+		client:    &http.Client{Timeout: time.Duration(timeoutSeconds) * time.Second},
+		requests:  make(chan *webhookRequest, bufferSize),
+	}
+	go w.loopSend()
+	return w, nil
+}
+
+// Send only posts to the configured endpoints, so the bucket owners can not make the filer post to any urls
+func (w *webhookTarget) Send(url, key string, body []byte) error {
+	if err := w.endpoints.check("webhook endpoint", url); err != nil {
+		return err
+	}
+	select {
+	case w.requests <- &webhookRequest{url: url, body: body}:
+		return nil
+	default:
+		return fmt.Errorf("webhook buffer is full, dropped the event of %s", key)
+	}
+}
+
+func (w *webhookTarget) loopSend() {
+	for req := range w.requests {
+		if err := w.post(req); err != nil {
+			glog.Errorf("s3 event to webhook %s: %v", req.url, err)
+		}
+	}
+}
+
+func (w *webhookTarget) post(req *webhookRequest) error {
+	httpReq, err := http.NewRequest(http.MethodPost, req.url, bytes.NewReader(req.body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if len(w.secret) > 0 {
+		mac := hmac.New(sha256.New, w.secret)
+		mac.Write(req.body)
+		httpReq.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := w.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
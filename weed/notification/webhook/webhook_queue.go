@@ -0,0 +1,257 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/notification"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/golang/protobuf/proto"
+)
+
+/*
+The webhook queue POSTs each filer event as json to the configured http endpoints.
+
+Each endpoint has its own buffer and sender, so the events are delivered to an endpoint in order,
+and a slow endpoint does not delay the others. If a buffer is full, the new events for the endpoint are dropped.
+The failed requests are retried with a backoff, except for the 4xx responses other than 429.
+
+With a secret, the request body is signed with HMAC-SHA256, in the header
+	X-Seaweedfs-Signature: sha256=<hex encoded signature>
+*/
+
+const (
+	EventCreate = "create"
+	EventUpdate = "update"
+	EventDelete = "delete"
+	EventRename = "rename"
+
+	SignatureHeader = "X-Seaweedfs-Signature"
+	EventHeader     = "X-Seaweedfs-Event"
+)
+
+func init() {
+	notification.MessageQueues = append(notification.MessageQueues, &WebhookQueue{})
+}
+
+type WebhookQueue struct {
+	endpoints    []*endpoint
+	pathPrefixes []string
+	eventTypes   map[string]bool
+}
+
+type endpoint struct {
+	url        string
+	secret     []byte
+	maxRetries int
+	client     *http.Client
+	events     chan *Event
+}
+
+// Event is the json body of a webhook request
+type Event struct {
+	Type        string `json:"type"`
+	Path        string `json:"path"`
+	OldPath     string `json:"oldPath,omitempty"`
+	IsDirectory bool   `json:"isDirectory,omitempty"`
+	Size        uint64 `json:"size,omitempty"`
+	Mtime       int64  `json:"mtime,omitempty"`
+	Mime        string `json:"mime,omitempty"`
+	Collection  string `json:"collection,omitempty"`
+	TsNs        int64  `json:"tsNs"`
+}
+
+func (k *WebhookQueue) GetName() string {
+	return "webhook"
+}
+
+func (k *WebhookQueue) Initialize(configuration util.Configuration, prefix string) (err error) {
+	glog.V(0).Infof("filer.notification.webhook.endpoints: %v", configuration.GetStringSlice(prefix+"endpoints"))
+	glog.V(0).Infof("filer.notification.webhook.path_prefixes: %v", configuration.GetStringSlice(prefix+"path_prefixes"))
+	return k.initialize(
+		configuration.GetStringSlice(prefix+"endpoints"),
+		configuration.GetStringSlice(prefix+"path_prefixes"),
+		configuration.GetStringSlice(prefix+"event_types"),
+		configuration.GetString(prefix+"secret"),
+		configuration.GetInt(prefix+"max_retries"),
+		configuration.GetInt(prefix+"timeout_seconds"),
+		configuration.GetInt(prefix+"buffer_size"),
+	)
+}
+
+func (k *WebhookQueue) initialize(urls, pathPrefixes, eventTypes []string, secret string, maxRetries, timeoutSeconds, bufferSize int) (err error) {
+	if len(urls) == 0 {
+		return fmt.Errorf("no webhook endpoints")
+	}
+	for _, eventType := range eventTypes {
+		switch eventType {
+		case EventCreate, EventUpdate, EventDelete, EventRename:
+		default:
+			return fmt.Errorf("unknown event type %s", eventType)
+		}
+		if k.eventTypes == nil {
+			k.eventTypes = make(map[string]bool)
+		}
+		k.eventTypes[eventType] = true
+	}
+	if timeoutSeconds <= 0 {
+		timeoutSeconds = 10
+	}
+	if bufferSize <= 0 {
+		bufferSize = 10000
+	}
+	k.pathPrefixes = pathPrefixes
+	for _, url := range urls {
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			return fmt.Errorf("webhook endpoint %s should start with http:// or https://", url)
+		}
+		e := &endpoint{
+			url:        url,
+			secret:     []byte(secret),
+			maxRetries: maxRetries,
+			client:     &http.Client{Timeout: time.Duration(timeoutSeconds) * time.Second},
+			events:     make(chan *Event, bufferSize),
+		}
+		k.endpoints = append(k.endpoints, e)
+		go e.loopSend()
+	}
+	return nil
+}
+
+func (k *WebhookQueue) SendMessage(key string, message proto.Message) (err error) {
+	eventNotification, ok := message.(*filer_pb.EventNotification)
+	if !ok {
+		return nil
+	}
+	event := toEvent(key, eventNotification)
+	if event == nil || !k.isWatched(event) {
+		return nil
+	}
+	for _, e := range k.endpoints {
+		select {
+		case e.events <- event:
+		default:
+			err = fmt.Errorf("webhook %s buffer is full, dropped %s %s", e.url, event.Type, event.Path)
+		}
+	}
+	return err
+}
+
+func (k *WebhookQueue) isWatched(event *Event) bool {
+	if k.eventTypes != nil && !k.eventTypes[event.Type] {
+		return false
+	}
+	if len(k.pathPrefixes) == 0 {
+		return true
+	}
+	for _, pathPrefix := range k.pathPrefixes {
+		if strings.HasPrefix(event.Path, pathPrefix) || event.OldPath != "" && strings.HasPrefix(event.OldPath, pathPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// toEvent converts the filer event on the key, which is the old path if the old entry exists.
+func toEvent(key string, message *filer_pb.EventNotification) *Event {
+	event := &Event{
+		TsNs: time.Now().UnixNano(),
+	}
+	entry := message.NewEntry
+	switch {
+	case message.OldEntry == nil && message.NewEntry == nil:
+		return nil
+	case message.OldEntry == nil:
+		event.Type = EventCreate
+		event.Path = key
+	case message.NewEntry == nil:
+		event.Type = EventDelete
+		event.Path = key
+		entry = message.OldEntry
+	default:
+		event.Path = string(util.NewFullPath(message.NewParentPath, message.NewEntry.Name))
+		if message.NewParentPath == "" || event.Path == key {
+			event.Type = EventUpdate
+			event.Path = key
+		} else {
+			event.Type = EventRename
+			event.OldPath = key
+		}
+	}
+	event.IsDirectory = entry.IsDirectory
+	if entry.Attributes != nil {
+		event.Size = filer.FileSize(entry)
+		event.Mtime = entry.Attributes.Mtime
+		event.Mime = entry.Attributes.Mime
+		event.Collection = entry.Attributes.Collection
+	}
+	return event
+}
+
+func (e *endpoint) loopSend() {
+	for event := range e.events {
+		body, err := json.Marshal(event)
+		if err != nil {
+			glog.Errorf("marshal webhook event %+v: %v", event, err)
+			continue
+		}
+		waitTime := time.Second
+		for i := 0; ; i++ {
+			retryable, err := e.post(event.Type, body)
+			if err == nil {
+				break
+			}
+			if !retryable || i >= e.maxRetries {
+				glog.Errorf("webhook %s dropped %s %s: %v", e.url, event.Type, event.Path, err)
+				break
+			}
+			glog.V(1).Infof("webhook %s retry %s %s: %v", e.url, event.Type, event.Path, err)
+			time.Sleep(waitTime)
+			if waitTime < time.Minute {
+				waitTime *= 2
+			}
+		}
+	}
+}
+
+func (e *endpoint) post(eventType string, body []byte) (retryable bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, eventType)
+	if len(e.secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(e.secret, body))
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retryable = resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retryable, fmt.Errorf("status %s", resp.Status)
+}
+
+// Sign returns the value of the signature header for the body
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
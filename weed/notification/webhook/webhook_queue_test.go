@@ -0,0 +1,93 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+)
+
+func TestToEvent(t *testing.T) {
+	file := &filer_pb.Entry{Name: "a.txt", Attributes: &filer_pb.FuseAttributes{FileSize: 3}}
+	renamed := &filer_pb.Entry{Name: "b.txt", Attributes: &filer_pb.FuseAttributes{FileSize: 3}}
+
+	tests := []struct {
+		message  *filer_pb.EventNotification
+		expected Event
+	}{
+		{&filer_pb.EventNotification{NewEntry: file, NewParentPath: "/d"}, Event{Type: EventCreate, Path: "/d/a.txt", Size: 3}},
+		{&filer_pb.EventNotification{NewEntry: file}, Event{Type: EventCreate, Path: "/d/a.txt", Size: 3}},
+		{&filer_pb.EventNotification{OldEntry: file, NewEntry: file, NewParentPath: "/d"}, Event{Type: EventUpdate, Path: "/d/a.txt", Size: 3}},
+		{&filer_pb.EventNotification{OldEntry: file}, Event{Type: EventDelete, Path: "/d/a.txt", Size: 3}},
+		{&filer_pb.EventNotification{OldEntry: file, NewEntry: renamed, NewParentPath: "/e"}, Event{Type: EventRename, Path: "/e/b.txt", OldPath: "/d/a.txt", Size: 3}},
+	}
+	for _, test := range tests {
+		event := toEvent("/d/a.txt", test.message)
+		event.TsNs = 0
+		if *event != test.expected {
+			t.Errorf("expected %+v, got %+v", test.expected, *event)
+		}
+	}
+	if toEvent("/d/a.txt", &filer_pb.EventNotification{}) != nil {
+		t.Errorf("expected no event without entries")
+	}
+}
+
+func TestSendMessage(t *testing.T) {
+	secret := []byte("secret")
+	var requestCount int32
+	received := make(chan *Event, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requestCount, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get(SignatureHeader) != Sign(secret, body) {
+			t.Errorf("unexpected signature %s", r.Header.Get(SignatureHeader))
+		}
+		event := &Event{}
+		if err := json.Unmarshal(body, event); err != nil {
+			t.Errorf("unmarshal %s: %v", body, err)
+		}
+		if r.Header.Get(EventHeader) != event.Type {
+			t.Errorf("unexpected event header %s for %s", r.Header.Get(EventHeader), event.Type)
+		}
+		received <- event
+	}))
+	defer server.Close()
+
+	k := &WebhookQueue{}
+	if err := k.initialize([]string{server.URL}, []string{"/buckets/"}, []string{EventCreate, EventDelete}, string(secret), 2, 1, 10); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+
+	entry := &filer_pb.Entry{Name: "a.txt"}
+	k.SendMessage("/tmp/a.txt", &filer_pb.EventNotification{NewEntry: entry, NewParentPath: "/tmp"})
+	k.SendMessage("/buckets/b/a.txt", &filer_pb.EventNotification{OldEntry: entry, NewEntry: entry, NewParentPath: "/buckets/b"})
+	k.SendMessage("/buckets/b/a.txt", &filer_pb.EventNotification{NewEntry: entry, NewParentPath: "/buckets/b"})
+	k.SendMessage("/buckets/b/a.txt", &filer_pb.EventNotification{OldEntry: entry})
+
+	for _, expected := range []string{EventCreate, EventDelete} {
+		select {
+		case event := <-received:
+			if event.Type != expected || event.Path != "/buckets/b/a.txt" {
+				t.Errorf("expected %s /buckets/b/a.txt, got %+v", expected, event)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for %s", expected)
+		}
+	}
+	if count := atomic.LoadInt32(&requestCount); count != 3 {
+		t.Errorf("expected 3 requests with one retry, got %d", count)
+	}
+
+	if err := (&WebhookQueue{}).initialize([]string{server.URL}, nil, []string{"modify"}, "", 0, 0, 0); err == nil {
+		t.Errorf("expected error for unknown event type")
+	}
+}
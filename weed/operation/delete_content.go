@@ -5,6 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
 	"net/http"
 	"strings"
 	"sync"
@@ -121,23 +124,8 @@ func DeleteFilesWithLookupVolumeId(grpcDialOption grpc.DialOption, fileIds []str
 // DeleteFilesAtOneVolumeServer deletes a list of files that is on one volume server via gRpc
 func DeleteFilesAtOneVolumeServer(volumeServer string, grpcDialOption grpc.DialOption, fileIds []string, includeCookie bool) (ret []*volume_server_pb.DeleteResult, err error) {
 
-	err = WithVolumeServerClient(volumeServer, grpcDialOption, func(volumeServerClient volume_server_pb.VolumeServerClient) error {
-
-		req := &volume_server_pb.BatchDeleteRequest{
-			FileIds:         fileIds,
-			SkipCookieCheck: !includeCookie,
-		}
-
-		resp, err := volumeServerClient.BatchDelete(context.Background(), req)
-
-		// fmt.Printf("deleted %v %v: %v\n", fileIds, err, resp)
-
-		if err != nil {
-			return err
-		}
-
-		ret = append(ret, resp.Results...)
-
+	err = StreamDeleteFilesAtOneVolumeServer(volumeServer, grpcDialOption, fileIds, includeCookie, func(results []*volume_server_pb.DeleteResult) error {
+		ret = append(ret, results...)
 		return nil
 	})
 
@@ -154,3 +142,47 @@ func DeleteFilesAtOneVolumeServer(volumeServer string, grpcDialOption grpc.DialO
 	return
 
 }
+
+// StreamDeleteFilesAtOneVolumeServer deletes a list of files that is on one volume server in one gRpc call,
+// passing the results to fn batch by batch as they are deleted.
+// The volume servers without the streaming API delete all the files in one BatchDelete call.
+func StreamDeleteFilesAtOneVolumeServer(volumeServer string, grpcDialOption grpc.DialOption, fileIds []string, includeCookie bool, fn func(results []*volume_server_pb.DeleteResult) error) error {
+
+	return WithVolumeServerClient(volumeServer, grpcDialOption, func(volumeServerClient volume_server_pb.VolumeServerClient) error {
+
+		req := &volume_server_pb.BatchDeleteRequest{
+			FileIds:         fileIds,
+			SkipCookieCheck: !includeCookie,
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		stream, err := volumeServerClient.BatchDeleteStream(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		for {
+			resp, recvErr := stream.Recv()
+			if recvErr == io.EOF {
+				return nil
+			}
+			if status.Code(recvErr) == codes.Unimplemented {
+				// an older volume server
+				resp, err := volumeServerClient.BatchDelete(context.Background(), req)
+				if err != nil {
+					return err
+				}
+				return fn(resp.Results)
+			}
+			if recvErr != nil {
+				return recvErr
+			}
+			if err = fn(resp.Results); err != nil {
+				return err
+			}
+		}
+	})
+
+}
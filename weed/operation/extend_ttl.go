@@ -0,0 +1,88 @@
+package operation
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"google.golang.org/grpc"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/volume_server_pb"
+)
+
+// ExtendFilesTtlWithLookupVolumeId restarts the ttl of a list of fileIds on all their replicas.
+// The results of all the replicas are returned.
+func ExtendFilesTtlWithLookupVolumeId(grpcDialOption grpc.DialOption, fileIds []string, lookupFunc func(vid []string) (map[string]LookupResult, error)) ([]*volume_server_pb.ExtendTtlResult, error) {
+
+	var ret []*volume_server_pb.ExtendTtlResult
+
+	vid_to_fileIds := make(map[string][]string)
+	var vids []string
+	for _, fileId := range fileIds {
+		vid, _, err := ParseFileId(fileId)
+		if err != nil {
+			ret = append(ret, &volume_server_pb.ExtendTtlResult{
+				FileId: fileId,
+				Status: http.StatusBadRequest,
+				Error:  err.Error()},
+			)
+			continue
+		}
+		if _, ok := vid_to_fileIds[vid]; !ok {
+			vids = append(vids, vid)
+		}
+		vid_to_fileIds[vid] = append(vid_to_fileIds[vid], fileId)
+	}
+
+	lookupResults, err := lookupFunc(vids)
+	if err != nil {
+		return ret, err
+	}
+
+	server_to_fileIds := make(map[string][]string)
+	for vid, result := range lookupResults {
+		if result.Error != "" || len(result.Locations) == 0 {
+			for _, fileId := range vid_to_fileIds[vid] {
+				ret = append(ret, &volume_server_pb.ExtendTtlResult{
+					FileId: fileId,
+					Status: http.StatusNotFound,
+					Error:  fmt.Sprintf("volume %s not found: %s", vid, result.Error)},
+				)
+			}
+			continue
+		}
+		for _, location := range result.Locations {
+			server_to_fileIds[location.Url] = append(server_to_fileIds[location.Url], vid_to_fileIds[vid]...)
+		}
+	}
+
+	var resultsLock sync.Mutex
+	var wg sync.WaitGroup
+	for server, fidList := range server_to_fileIds {
+		wg.Add(1)
+		go func(server string, fidList []string) {
+			defer wg.Done()
+			extendErr := WithVolumeServerClient(server, grpcDialOption, func(volumeServerClient volume_server_pb.VolumeServerClient) error {
+				resp, err := volumeServerClient.BatchExtendTtl(context.Background(), &volume_server_pb.BatchExtendTtlRequest{
+					FileIds: fidList,
+				})
+				if err != nil {
+					return err
+				}
+				resultsLock.Lock()
+				ret = append(ret, resp.Results...)
+				resultsLock.Unlock()
+				return nil
+			})
+			if extendErr != nil {
+				resultsLock.Lock()
+				err = fmt.Errorf("extend ttl on %s: %v", server, extendErr)
+				resultsLock.Unlock()
+			}
+		}(server, fidList)
+	}
+	wg.Wait()
+
+	return ret, err
+}
@@ -27,8 +27,13 @@ func TailVolume(masterFn GetMasterFn, grpcDialOption grpc.DialOption, vid needle
 }
 
 func TailVolumeFromSource(volumeServer string, grpcDialOption grpc.DialOption, vid needle.VolumeId, sinceNs uint64, idleTimeoutSeconds int, fn func(n *needle.Needle) error) error {
+	return TailVolumeFromSourceWithContext(context.Background(), volumeServer, grpcDialOption, vid, sinceNs, idleTimeoutSeconds, fn)
+}
+
+// TailVolumeFromSourceWithContext stops tailing when the ctx is cancelled.
+func TailVolumeFromSourceWithContext(parentCtx context.Context, volumeServer string, grpcDialOption grpc.DialOption, vid needle.VolumeId, sinceNs uint64, idleTimeoutSeconds int, fn func(n *needle.Needle) error) error {
 	return WithVolumeServerClient(volumeServer, grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
-		ctx, cancel := context.WithCancel(context.Background())
+		ctx, cancel := context.WithCancel(parentCtx)
 		defer cancel()
 
 		stream, err := client.VolumeTailSender(ctx, &volume_server_pb.VolumeTailSenderRequest{
@@ -18,6 +18,7 @@ import (
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
 	"github.com/chrislusf/seaweedfs/weed/security"
+	"github.com/chrislusf/seaweedfs/weed/stats"
 	"github.com/chrislusf/seaweedfs/weed/util"
 )
 
@@ -31,6 +32,8 @@ type UploadResult struct {
 	Gzip       uint32 `json:"gzip,omitempty"`
 	ContentMd5 string `json:"contentMd5,omitempty"`
 	RetryCount int    `json:"-"`
+
+	ServerTiming string `json:"-"` // the upload stages measured by the volume server
 }
 
 func (uploadResult *UploadResult) ToPbFileChunk(fileId string, offset int64) *filer_pb.FileChunk {
@@ -109,7 +112,8 @@ func retriedUploadData(uploadUrl string, filename string, cipher bool, data []by
 func doUploadData(uploadUrl string, filename string, cipher bool, data []byte, isInputCompressed bool, mtype string, pairMap map[string]string, jwt security.EncodedJwt) (uploadResult *UploadResult, err error) {
 	contentIsGzipped := isInputCompressed
 	shouldGzipNow := false
-	if !isInputCompressed {
+	// the content already encoded by the client, e.g. with "Content-Encoding: gzip", is kept as is
+	if !isInputCompressed && !util.IsGzippedContent(data) {
 		if mtype == "" {
 			mtype = http.DetectContentType(data)
 			// println("detect1 mimetype to", mtype)
@@ -117,7 +121,7 @@ func doUploadData(uploadUrl string, filename string, cipher bool, data []byte, i
 				mtype = ""
 			}
 		}
-		if shouldBeCompressed, iAmSure := util.IsCompressableFileType(filepath.Base(filename), mtype); iAmSure && shouldBeCompressed {
+		if shouldBeCompressed, iAmSure := util.ShouldCompressFileType(filepath.Ext(filename), mtype); iAmSure && shouldBeCompressed {
 			shouldGzipNow = true
 		} else if !iAmSure && mtype == "" && len(data) > 16*1024 {
 			var compressed []byte
@@ -226,6 +230,7 @@ func upload_content(uploadUrl string, fillBufferFunction func(w io.Writer) error
 		return nil, fmt.Errorf("create upload request %s: %v", uploadUrl, postErr)
 	}
 	req.Header.Set("Content-Type", content_type)
+	req.Header.Set(stats.ServerTimingRequestHeader, "true")
 	for k, v := range pairMap {
 		req.Header.Set(k, v)
 	}
@@ -266,6 +271,7 @@ func upload_content(uploadUrl string, fillBufferFunction func(w io.Writer) error
 	}
 	ret.ETag = etag
 	ret.ContentMd5 = resp.Header.Get("Content-MD5")
+	ret.ServerTiming = resp.Header.Get(stats.ServerTimingHeader)
 	return &ret, nil
 }
 
@@ -239,7 +239,23 @@ func Mkdir(filerClient FilerClient, parentDirectoryPath string, dirName string,
 func MkFile(filerClient FilerClient, parentDirectoryPath string, fileName string, chunks []*FileChunk) error {
 	return filerClient.WithFilerClient(func(client SeaweedFilerClient) error {
 
-		entry := &Entry{
+		request := NewMkFileRequest(parentDirectoryPath, fileName, chunks)
+
+		glog.V(1).Infof("create file: %s/%s", parentDirectoryPath, fileName)
+		if err := CreateEntry(client, request); err != nil {
+			glog.V(0).Infof("create file %v:%v", request, err)
+			return fmt.Errorf("create file %s/%s: %v", parentDirectoryPath, fileName, err)
+		}
+
+		return nil
+	})
+}
+
+// NewMkFileRequest is the request of MkFile, to be sent separately or in batches.
+func NewMkFileRequest(parentDirectoryPath string, fileName string, chunks []*FileChunk) *CreateEntryRequest {
+	return &CreateEntryRequest{
+		Directory: parentDirectoryPath,
+		Entry: &Entry{
 			Name:        fileName,
 			IsDirectory: false,
 			Attributes: &FuseAttributes{
@@ -250,21 +266,8 @@ func MkFile(filerClient FilerClient, parentDirectoryPath string, fileName string
 				Gid:      OS_GID,
 			},
 			Chunks: chunks,
-		}
-
-		request := &CreateEntryRequest{
-			Directory: parentDirectoryPath,
-			Entry:     entry,
-		}
-
-		glog.V(1).Infof("create file: %s/%s", parentDirectoryPath, fileName)
-		if err := CreateEntry(client, request); err != nil {
-			glog.V(0).Infof("create file %v:%v", request, err)
-			return fmt.Errorf("create file %s/%s: %v", parentDirectoryPath, fileName, err)
-		}
-
-		return nil
-	})
+		},
+	}
 }
 
 func Remove(filerClient FilerClient, parentDirectoryPath, name string, isDeleteData, isRecursive, ignoreRecursiveErr, isFromOtherCluster bool, signatures []int32) error {
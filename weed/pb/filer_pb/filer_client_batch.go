@@ -0,0 +1,170 @@
+package filer_pb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CreateEntries creates the entries with one request, and returns the error of each entry in the request order.
+// The filers without the batched api create the entries one by one.
+func CreateEntries(client SeaweedFilerClient, requests []*CreateEntryRequest) (errs []error, err error) {
+	resp, err := client.CreateEntries(context.Background(), &CreateEntriesRequest{
+		Requests: requests,
+	})
+	if status.Code(err) == codes.Unimplemented {
+		for _, request := range requests {
+			errs = append(errs, CreateEntry(client, request))
+		}
+		return errs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("CreateEntries: %v", err)
+	}
+	if len(resp.Responses) != len(requests) {
+		return nil, fmt.Errorf("CreateEntries: %d results for %d entries", len(resp.Responses), len(requests))
+	}
+	for _, r := range resp.Responses {
+		if r.Error != "" {
+			errs = append(errs, fmt.Errorf("CreateEntry : %v", r.Error))
+		} else {
+			errs = append(errs, nil)
+		}
+	}
+	return errs, nil
+}
+
+// UpdateEntries updates the entries with one request, and returns the error of each entry in the request order.
+// The filers without the batched api update the entries one by one.
+func UpdateEntries(client SeaweedFilerClient, requests []*UpdateEntryRequest) (errs []error, err error) {
+	resp, err := client.UpdateEntries(context.Background(), &UpdateEntriesRequest{
+		Requests: requests,
+	})
+	if status.Code(err) == codes.Unimplemented {
+		for _, request := range requests {
+			errs = append(errs, UpdateEntry(client, request))
+		}
+		return errs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("UpdateEntries: %v", err)
+	}
+	if len(resp.Errors) != len(requests) {
+		return nil, fmt.Errorf("UpdateEntries: %d results for %d entries", len(resp.Errors), len(requests))
+	}
+	for _, e := range resp.Errors {
+		if e != "" {
+			errs = append(errs, fmt.Errorf("UpdateEntry: %v", e))
+		} else {
+			errs = append(errs, nil)
+		}
+	}
+	return errs, nil
+}
+
+// EntryBatcher groups the entry creations into CreateEntries requests, sent when the batch is full,
+// or when the first entry in the batch has waited for maxWait.
+type EntryBatcher struct {
+	filerClient  FilerClient
+	maxBatchSize int
+	maxWait      time.Duration
+
+	sync.Mutex
+	batch    []*CreateEntryRequest
+	onDone   []func(err error)
+	batchId  int64 // changes when the batch is taken, so a late timer does not send the next batch early
+	inFlight sync.WaitGroup
+}
+
+func NewEntryBatcher(filerClient FilerClient, maxBatchSize int, maxWait time.Duration) *EntryBatcher {
+	if maxBatchSize <= 0 {
+		maxBatchSize = 1
+	}
+	return &EntryBatcher{
+		filerClient:  filerClient,
+		maxBatchSize: maxBatchSize,
+		maxWait:      maxWait,
+	}
+}
+
+// Add queues the entry creation, and calls onDone with the result after the batch is sent.
+// When the batch is full, the batch is sent before Add returns.
+func (b *EntryBatcher) Add(request *CreateEntryRequest, onDone func(err error)) {
+	b.Lock()
+	b.batch = append(b.batch, request)
+	b.onDone = append(b.onDone, onDone)
+	b.inFlight.Add(1)
+	if len(b.batch) == 1 && b.maxWait > 0 && b.maxBatchSize > 1 {
+		batchId := b.batchId
+		time.AfterFunc(b.maxWait, func() {
+			b.Lock()
+			if b.batchId == batchId && len(b.batch) > 0 {
+				requests, onDone := b.takeBatch()
+				b.Unlock()
+				b.send(requests, onDone)
+				return
+			}
+			b.Unlock()
+		})
+	}
+	if len(b.batch) < b.maxBatchSize {
+		b.Unlock()
+		return
+	}
+	requests, callbacks := b.takeBatch()
+	b.Unlock()
+	b.send(requests, callbacks)
+}
+
+// CreateEntry queues the entry creation, and waits for the result.
+func (b *EntryBatcher) CreateEntry(request *CreateEntryRequest) error {
+	done := make(chan error, 1)
+	b.Add(request, func(err error) {
+		done <- err
+	})
+	return <-done
+}
+
+// Flush sends the queued entries, and waits for all the entries added so far.
+func (b *EntryBatcher) Flush() {
+	b.Lock()
+	requests, onDone := b.takeBatch()
+	b.Unlock()
+	if len(requests) > 0 {
+		b.send(requests, onDone)
+	}
+	b.inFlight.Wait()
+}
+
+func (b *EntryBatcher) takeBatch() (requests []*CreateEntryRequest, onDone []func(err error)) {
+	requests, onDone = b.batch, b.onDone
+	b.batch, b.onDone = nil, nil
+	b.batchId++
+	return
+}
+
+func (b *EntryBatcher) send(requests []*CreateEntryRequest, onDone []func(err error)) {
+	var errs []error
+	err := b.filerClient.WithFilerClient(func(client SeaweedFilerClient) (err error) {
+		errs, err = CreateEntries(client, requests)
+		return err
+	})
+	if err == nil && len(errs) != len(requests) {
+		err = errors.New("CreateEntries: missing results")
+	}
+	for i, fn := range onDone {
+		if fn != nil {
+			if err != nil {
+				fn(err)
+			} else {
+				fn(errs[i])
+			}
+		}
+		b.inFlight.Done()
+	}
+}
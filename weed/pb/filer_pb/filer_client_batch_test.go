@@ -0,0 +1,92 @@
+package filer_pb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+type batchTestClient struct {
+	SeaweedFilerClient
+	mu         sync.Mutex
+	batchSizes []int
+}
+
+func (c *batchTestClient) CreateEntries(ctx context.Context, in *CreateEntriesRequest, opts ...grpc.CallOption) (*CreateEntriesResponse, error) {
+	c.mu.Lock()
+	c.batchSizes = append(c.batchSizes, len(in.Requests))
+	c.mu.Unlock()
+	resp := &CreateEntriesResponse{}
+	for _, r := range in.Requests {
+		createResp := &CreateEntryResponse{}
+		if r.Entry.Name == "bad" {
+			createResp.Error = "bad name"
+		}
+		resp.Responses = append(resp.Responses, createResp)
+	}
+	return resp, nil
+}
+
+func (c *batchTestClient) WithFilerClient(fn func(SeaweedFilerClient) error) error {
+	return fn(c)
+}
+
+func (c *batchTestClient) AdjustedUrl(location *Location) string {
+	return location.Url
+}
+
+func TestEntryBatcher(t *testing.T) {
+	client := &batchTestClient{}
+	batcher := NewEntryBatcher(client, 3, time.Hour)
+
+	var errs [7]error
+	for i := range errs {
+		name := fmt.Sprintf("f%d", i)
+		if i == 4 {
+			name = "bad"
+		}
+		i := i
+		batcher.Add(&CreateEntryRequest{Directory: "/d", Entry: &Entry{Name: name}}, func(err error) {
+			errs[i] = err
+		})
+	}
+	batcher.Flush()
+
+	if fmt.Sprint(client.batchSizes) != "[3 3 1]" {
+		t.Errorf("unexpected batches %v", client.batchSizes)
+	}
+	for i, err := range errs {
+		if (err != nil) != (i == 4) {
+			t.Errorf("entry %d: unexpected error %v", i, err)
+		}
+	}
+}
+
+func TestEntryBatcherWait(t *testing.T) {
+	client := &batchTestClient{}
+	batcher := NewEntryBatcher(client, 100, 10*time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := batcher.CreateEntry(&CreateEntryRequest{Directory: "/d", Entry: &Entry{Name: fmt.Sprintf("f%d", i)}}); err != nil {
+				t.Errorf("create f%d: %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	total := 0
+	for _, size := range client.batchSizes {
+		total += size
+	}
+	if total != 5 {
+		t.Errorf("unexpected batches %v", client.batchSizes)
+	}
+}
@@ -0,0 +1,68 @@
+package filer_pb
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrLockHeldByOthers = errors.New("lock held by others")
+
+// Lock acquires or renews the path-scoped lock on the filer, shared with other shared locks if isShared,
+// and also on the entries under the directory if isRecursive. Renewing with the renew token converts the lock to shared or exclusive.
+// If the lock is held by others, resp.LockOwner is the current owner and err is ErrLockHeldByOthers.
+func Lock(filerClient FilerClient, path string, owner string, renewToken string, duration time.Duration, isShared bool, isRecursive bool) (resp *LockResponse, err error) {
+	err = filerClient.WithFilerClient(func(client SeaweedFilerClient) error {
+		resp, err = client.Lock(context.Background(), &LockRequest{
+			Path:          path,
+			Owner:         owner,
+			SecondsToLock: int64(duration / time.Second),
+			RenewToken:    renewToken,
+			IsShared:      isShared,
+			IsRecursive:   isRecursive,
+		})
+		if err != nil {
+			return err
+		}
+		if resp.Error != "" {
+			if resp.LockOwner != "" && resp.LockOwner != owner {
+				return ErrLockHeldByOthers
+			}
+			return errors.New(resp.Error)
+		}
+		return nil
+	})
+	return
+}
+
+// Unlock releases the path-scoped lock on the filer.
+func Unlock(filerClient FilerClient, path string, renewToken string) error {
+	return filerClient.WithFilerClient(func(client SeaweedFilerClient) error {
+		resp, err := client.Unlock(context.Background(), &UnlockRequest{
+			Path:       path,
+			RenewToken: renewToken,
+		})
+		if err != nil {
+			return err
+		}
+		if resp.Error != "" {
+			return errors.New(resp.Error)
+		}
+		return nil
+	})
+}
+
+// FindLockOwner returns one of the current lock owners, or empty if not locked.
+func FindLockOwner(filerClient FilerClient, path string) (owner string, isShared bool, err error) {
+	err = filerClient.WithFilerClient(func(client SeaweedFilerClient) error {
+		resp, err := client.FindLockOwner(context.Background(), &FindLockOwnerRequest{
+			Path: path,
+		})
+		if err != nil {
+			return err
+		}
+		owner, isShared = resp.Owner, resp.IsShared
+		return nil
+	})
+	return
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+	"github.com/chrislusf/seaweedfs/weed/util"
 	"github.com/golang/protobuf/proto"
 	"github.com/viant/ptrie"
 )
@@ -147,3 +148,21 @@ func (fp *FilerConf_PathConf) Key() interface{} {
 	key, _ := proto.Marshal(fp)
 	return string(key)
 }
+
+func ToPbCompressionPolicy(policy *util.CompressionPolicy) *CompressionPolicy {
+	return &CompressionPolicy{
+		CompressMimeTypes:  policy.CompressMimeTypes,
+		CompressExtensions: policy.CompressExtensions,
+		RawMimeTypes:       policy.RawMimeTypes,
+		RawExtensions:      policy.RawExtensions,
+	}
+}
+
+func (cp *CompressionPolicy) ToCompressionPolicy() *util.CompressionPolicy {
+	return &util.CompressionPolicy{
+		CompressMimeTypes:  cp.GetCompressMimeTypes(),
+		CompressExtensions: cp.GetCompressExtensions(),
+		RawMimeTypes:       cp.GetRawMimeTypes(),
+		RawExtensions:      cp.GetRawExtensions(),
+	}
+}
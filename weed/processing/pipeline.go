@@ -0,0 +1,185 @@
+package processing
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// Pipeline runs the processors on the new files from the filer metadata events.
+type Pipeline struct {
+	filer          string
+	grpcDialOption grpc.DialOption
+	processors     []*Processor
+}
+
+func NewPipeline(filer string, grpcDialOption grpc.DialOption, processors []*Processor) *Pipeline {
+	return &Pipeline{
+		filer:          filer,
+		grpcDialOption: grpcDialOption,
+		processors:     processors,
+	}
+}
+
+// NewFile returns the file with new content in the event, or nil for deletions, renames, and metadata only updates.
+func NewFile(resp *filer_pb.SubscribeMetadataResponse) (util.FullPath, *filer_pb.Entry) {
+	message := resp.EventNotification
+	entry := message.NewEntry
+	if entry == nil || entry.IsDirectory {
+		return "", nil
+	}
+	if old := message.OldEntry; old != nil && filer.ETag(old) == filer.ETag(entry) && filer.FileSize(old) == filer.FileSize(entry) {
+		return "", nil
+	}
+	dir := resp.Directory
+	if message.NewParentPath != "" {
+		dir = message.NewParentPath
+	}
+	return util.NewFullPath(dir, entry.Name), entry
+}
+
+// Process runs the matching processors on the file one by one, and records the status of each processor
+// in the extended attributes of the file. A failed processor does not fail the others.
+func (pl *Pipeline) Process(ctx context.Context, fullpath util.FullPath, entry *filer_pb.Entry) error {
+	for _, p := range pl.processors {
+		if p.IsOutput(fullpath) {
+			return nil
+		}
+	}
+	for _, p := range pl.processors {
+		if !p.Matches(fullpath, entry) {
+			continue
+		}
+		start := time.Now()
+		extended, runErr := pl.runProcessor(ctx, p, fullpath, entry)
+		status := fmt.Sprintf("ok %s", start.UTC().Format(time.RFC3339))
+		if runErr != nil {
+			glog.Errorf("process %s with %s: %v", fullpath, p.Name, runErr)
+			status = fmt.Sprintf("error %s: %v", start.UTC().Format(time.RFC3339), runErr)
+		} else {
+			glog.V(1).Infof("processed %s with %s in %v", fullpath, p.Name, time.Since(start))
+		}
+		attrs := map[string][]byte{StatusKeyPrefix + p.Name: []byte(status)}
+		if runErr == nil && p.Output == OutputExtended {
+			attrs[p.ExtendedKey] = extended
+		}
+		if err := pl.setExtended(fullpath, attrs); err != nil {
+			return fmt.Errorf("save %s status of %s: %v", p.Name, fullpath, err)
+		}
+	}
+	return nil
+}
+
+func (pl *Pipeline) runProcessor(ctx context.Context, p *Processor, fullpath util.FullPath, entry *filer_pb.Entry) (extended []byte, err error) {
+
+	// the file is read and the output is saved within the processor timeout
+	client := &http.Client{Timeout: p.Timeout}
+	resp, err := client.Get(pl.fileUrl(fullpath))
+	if err != nil {
+		return nil, fmt.Errorf("read: %v", err)
+	}
+	defer util.CloseResponse(resp)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("read: %s", resp.Status)
+	}
+
+	if p.Output == OutputExtended {
+		output := &limitedBuffer{limit: MaxExtendedOutputSize}
+		if err = p.Run(ctx, fullpath, entry, resp.Body, output); err != nil {
+			return nil, err
+		}
+		return output.Bytes(), nil
+	}
+
+	// keep the sibling output in a temp file, to not leave a partial result if the command fails
+	output, err := ioutil.TempFile("", "seaweedfs-process")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(output.Name())
+	defer output.Close()
+	if err = p.Run(ctx, fullpath, entry, resp.Body, output); err != nil {
+		return nil, err
+	}
+	if _, err = output.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return nil, pl.saveSibling(client, util.FullPath(string(fullpath)+p.Suffix), p.OutputMime, output)
+}
+
+func (pl *Pipeline) saveSibling(client *http.Client, fullpath util.FullPath, mime string, reader io.Reader) error {
+	req, err := http.NewRequest(http.MethodPut, pl.fileUrl(fullpath), reader)
+	if err != nil {
+		return err
+	}
+	if mime != "" {
+		req.Header.Set("Content-Type", mime)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("save %s: %v", fullpath, err)
+	}
+	defer util.CloseResponse(resp)
+	if resp.StatusCode >= 400 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("save %s: %s %s", fullpath, resp.Status, string(b))
+	}
+	return nil
+}
+
+// setExtended updates only the extended attributes of the latest entry, keeping the content written in the meantime,
+// and skips the files deleted in the meantime.
+func (pl *Pipeline) setExtended(fullpath util.FullPath, attrs map[string][]byte) error {
+	return pb.WithFilerClient(pl.filer, pl.grpcDialOption, func(client filer_pb.SeaweedFilerClient) error {
+		dir, name := fullpath.DirAndName()
+		err := filer_pb.UpdateEntry(client, &filer_pb.UpdateEntryRequest{
+			Directory: dir,
+			Entry: &filer_pb.Entry{
+				Name:     name,
+				Extended: attrs,
+			},
+			IsExtendedOnly: true,
+		})
+		if err != nil && strings.Contains(err.Error(), filer_pb.ErrNotFound.Error()) {
+			return nil
+		}
+		return err
+	})
+}
+
+func (pl *Pipeline) fileUrl(fullpath util.FullPath) string {
+	u := url.URL{Scheme: "http", Host: pl.filer, Path: string(fullpath)}
+	return u.String()
+}
+
+// limitedBuffer fails the writes beyond the limit
+type limitedBuffer struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.buf.Len()+len(p) > b.limit {
+		return 0, fmt.Errorf("output is larger than %d bytes", b.limit)
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
@@ -0,0 +1,160 @@
+package processing
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+A processor runs an external command on the new files, e.g. thumbnailing, video transcoding, or OCR.
+
+The command is run with "sh -c", reading the file content from stdin, and writing the result to stdout.
+The file is also described by the environment variables SEAWEEDFS_PATH, SEAWEEDFS_MIME and SEAWEEDFS_SIZE.
+The result is attached to the file either as a sibling entry, named by the file name and the suffix,
+or as an extended attribute of the file.
+*/
+
+const (
+	OutputSibling  = "sibling"
+	OutputExtended = "extended"
+
+	// the extended attribute value is kept with the entry metadata, so it should be small
+	MaxExtendedOutputSize = 64 * 1024
+
+	// StatusKeyPrefix is the extended attribute recording the last run of each processor on the file
+	StatusKeyPrefix = "x-seaweedfs-processed-"
+)
+
+type Processor struct {
+	Name        string
+	PathPrefix  string
+	NamePattern string // glob of the file name, e.g. "*.jpg"
+	MimeType    string // e.g. "image/jpeg", or "image/*" for any image
+	Command     string
+	Output      string
+	Suffix      string // for the sibling output, e.g. ".thumb.jpg"
+	OutputMime  string // for the sibling output
+	ExtendedKey string // for the extended output, the processor name by default
+	Timeout     time.Duration
+	MaxInputMB  int64
+}
+
+// LoadProcessors loads the enabled processors in process.toml, ordered by name.
+func LoadProcessors(configuration *util.ViperProxy) (processors []*Processor, err error) {
+	for name := range configuration.GetStringMap("processor") {
+		prefix := "processor." + name + "."
+		if !configuration.GetBool(prefix + "enabled") {
+			continue
+		}
+		p := &Processor{
+			Name:        name,
+			PathPrefix:  configuration.GetString(prefix + "path_prefix"),
+			NamePattern: configuration.GetString(prefix + "name_pattern"),
+			MimeType:    configuration.GetString(prefix + "mime_type"),
+			Command:     configuration.GetString(prefix + "command"),
+			Output:      configuration.GetString(prefix + "output"),
+			Suffix:      configuration.GetString(prefix + "suffix"),
+			OutputMime:  configuration.GetString(prefix + "output_mime"),
+			ExtendedKey: util.Nvl(configuration.GetString(prefix+"extended_key"), name),
+			Timeout:     10 * time.Minute,
+			MaxInputMB:  int64(configuration.GetInt(prefix + "max_input_mb")),
+		}
+		if timeout := configuration.GetString(prefix + "timeout"); timeout != "" {
+			if p.Timeout, err = time.ParseDuration(timeout); err != nil {
+				return nil, fmt.Errorf("processor %s timeout %s: %v", name, timeout, err)
+			}
+		}
+		if err = p.check(); err != nil {
+			return nil, err
+		}
+		processors = append(processors, p)
+	}
+	sort.Slice(processors, func(i, j int) bool {
+		return processors[i].Name < processors[j].Name
+	})
+	return processors, nil
+}
+
+func (p *Processor) check() error {
+	if p.Command == "" {
+		return fmt.Errorf("processor %s has no command", p.Name)
+	}
+	if _, err := path.Match(p.NamePattern, ""); err != nil {
+		return fmt.Errorf("processor %s name pattern %s: %v", p.Name, p.NamePattern, err)
+	}
+	switch p.Output {
+	case OutputSibling:
+		if p.Suffix == "" {
+			return fmt.Errorf("processor %s needs a suffix for the sibling output", p.Name)
+		}
+	case OutputExtended:
+	default:
+		return fmt.Errorf("processor %s output %q should be %q or %q", p.Name, p.Output, OutputSibling, OutputExtended)
+	}
+	return nil
+}
+
+// Matches checks whether the processor should run on the new file.
+func (p *Processor) Matches(fullpath util.FullPath, entry *filer_pb.Entry) bool {
+	if !strings.HasPrefix(string(fullpath), p.PathPrefix) {
+		return false
+	}
+	if p.NamePattern != "" {
+		if matched, _ := path.Match(p.NamePattern, fullpath.Name()); !matched {
+			return false
+		}
+	}
+	if p.MimeType != "" {
+		mime := entry.Attributes.GetMime()
+		if strings.HasSuffix(p.MimeType, "/*") {
+			if !strings.HasPrefix(mime, strings.TrimSuffix(p.MimeType, "*")) {
+				return false
+			}
+		} else if mime != p.MimeType {
+			return false
+		}
+	}
+	if p.MaxInputMB > 0 && int64(filer.FileSize(entry)) > p.MaxInputMB*1024*1024 {
+		return false
+	}
+	return true
+}
+
+// IsOutput checks whether the file is a sibling output of the processor, which is not processed again.
+func (p *Processor) IsOutput(fullpath util.FullPath) bool {
+	return p.Output == OutputSibling && strings.HasSuffix(fullpath.Name(), p.Suffix)
+}
+
+// Run runs the command on the input, and writes the result to the output.
+func (p *Processor) Run(ctx context.Context, fullpath util.FullPath, entry *filer_pb.Entry, input io.Reader, output io.Writer) error {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", p.Command)
+	cmd.Env = append(os.Environ(),
+		"SEAWEEDFS_PATH="+string(fullpath),
+		"SEAWEEDFS_MIME="+entry.Attributes.GetMime(),
+		fmt.Sprintf("SEAWEEDFS_SIZE=%d", filer.FileSize(entry)),
+	)
+	var stderr bytes.Buffer
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = input, output, &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("timed out after %v", p.Timeout)
+		}
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package processing
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func TestProcessorMatches(t *testing.T) {
+	p := &Processor{Name: "thumbnail", PathPrefix: "/photos/", NamePattern: "*.jpg", MimeType: "image/*", Output: OutputSibling, Suffix: ".thumb.jpg"}
+	entry := &filer_pb.Entry{Attributes: &filer_pb.FuseAttributes{Mime: "image/jpeg", FileSize: 1024}}
+
+	if !p.Matches("/photos/a.jpg", entry) {
+		t.Errorf("expected to match /photos/a.jpg")
+	}
+	if p.Matches("/videos/a.jpg", entry) {
+		t.Errorf("unexpected match outside the path prefix")
+	}
+	if p.Matches("/photos/a.png", entry) {
+		t.Errorf("unexpected match of another name pattern")
+	}
+	if p.Matches("/photos/a.jpg", &filer_pb.Entry{Attributes: &filer_pb.FuseAttributes{Mime: "text/plain"}}) {
+		t.Errorf("unexpected match of another mime type")
+	}
+	if !p.IsOutput("/photos/a.jpg.thumb.jpg") || p.IsOutput("/photos/a.jpg") {
+		t.Errorf("wrong sibling output detection")
+	}
+}
+
+func TestNewFile(t *testing.T) {
+	chunks := []*filer_pb.FileChunk{{FileId: "1,01", Size: 10, ETag: "abc"}}
+	newEntry := &filer_pb.Entry{Name: "a.jpg", Chunks: chunks, Attributes: &filer_pb.FuseAttributes{}}
+
+	created := &filer_pb.SubscribeMetadataResponse{Directory: "/photos", EventNotification: &filer_pb.EventNotification{NewEntry: newEntry}}
+	if fullpath, entry := NewFile(created); entry == nil || fullpath != "/photos/a.jpg" {
+		t.Errorf("created file: %v %v", fullpath, entry)
+	}
+
+	// updating only the extended attributes, e.g. by the processors
+	updated := &filer_pb.SubscribeMetadataResponse{Directory: "/photos", EventNotification: &filer_pb.EventNotification{OldEntry: newEntry, NewEntry: newEntry}}
+	if _, entry := NewFile(updated); entry != nil {
+		t.Errorf("metadata only update should be skipped")
+	}
+
+	deleted := &filer_pb.SubscribeMetadataResponse{Directory: "/photos", EventNotification: &filer_pb.EventNotification{OldEntry: newEntry}}
+	if _, entry := NewFile(deleted); entry != nil {
+		t.Errorf("deletion should be skipped")
+	}
+}
+
+func TestProcessorRun(t *testing.T) {
+	p := &Processor{Name: "upper", Command: "tr a-z A-Z && echo $SEAWEEDFS_PATH", Timeout: time.Minute}
+	entry := &filer_pb.Entry{Attributes: &filer_pb.FuseAttributes{}}
+
+	var output bytes.Buffer
+	if err := p.Run(context.Background(), util.FullPath("/a.txt"), entry, strings.NewReader("hello\n"), &output); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if output.String() != "HELLO\n/a.txt\n" {
+		t.Errorf("unexpected output %q", output.String())
+	}
+
+	p.Command = "echo failed >&2; exit 3"
+	if err := p.Run(context.Background(), util.FullPath("/a.txt"), entry, strings.NewReader(""), &output); err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("expected the failure with stderr, got %v", err)
+	}
+
+	limited := &limitedBuffer{limit: 4}
+	p.Command = "echo 0123456789"
+	if err := p.Run(context.Background(), util.FullPath("/a.txt"), entry, strings.NewReader(""), limited); err == nil {
+		t.Errorf("expected the output limit error")
+	}
+}
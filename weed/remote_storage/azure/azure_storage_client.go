@@ -0,0 +1,87 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/remote_storage"
+)
+
+func init() {
+	remote_storage.RemoteStorageClientMakers["azure"] = new(azureRemoteStorageMaker)
+}
+
+type azureRemoteStorageMaker struct{}
+
+func (s azureRemoteStorageMaker) Make(conf *filer_pb.RemoteConf) (remote_storage.RemoteStorageClient, error) {
+	credential, err := azblob.NewSharedKeyCredential(conf.AzureAccountName, conf.AzureAccountKey)
+	if err != nil {
+		return nil, fmt.Errorf("create azure credential with account name %s: %v", conf.AzureAccountName, err)
+	}
+	p := azblob.NewPipeline(credential, azblob.PipelineOptions{})
+	u, _ := url.Parse(fmt.Sprintf("https://%s.blob.core.windows.net", conf.AzureAccountName))
+	return &azureRemoteStorageClient{
+		conf:       conf,
+		serviceURL: azblob.NewServiceURL(*u, p),
+	}, nil
+}
+
+type azureRemoteStorageClient struct {
+	conf       *filer_pb.RemoteConf
+	serviceURL azblob.ServiceURL
+}
+
+func (az *azureRemoteStorageClient) ListDirectory(ctx context.Context, loc *filer_pb.RemoteStorageLocation, visitFn remote_storage.VisitFunc) error {
+	prefix := remote_storage.ObjectKey(loc.Path, true)
+	containerURL := az.serviceURL.NewContainerURL(loc.Bucket)
+
+	for marker := (azblob.Marker{}); marker.NotDone(); {
+		resp, err := containerURL.ListBlobsHierarchySegment(ctx, marker, "/", azblob.ListBlobsSegmentOptions{
+			Prefix: prefix,
+		})
+		if err != nil {
+			return fmt.Errorf("list %s: %v", remote_storage.FormatLocation(loc), err)
+		}
+		marker = resp.NextMarker
+
+		for _, blobPrefix := range resp.Segment.BlobPrefixes {
+			if err = visitFn(strings.TrimSuffix(strings.TrimPrefix(blobPrefix.Name, prefix), "/"), true, nil); err != nil {
+				return err
+			}
+		}
+		for _, blobItem := range resp.Segment.BlobItems {
+			name := strings.TrimPrefix(blobItem.Name, prefix)
+			if name == "" || strings.HasSuffix(name, "/") {
+				continue
+			}
+			var size int64
+			if blobItem.Properties.ContentLength != nil {
+				size = *blobItem.Properties.ContentLength
+			}
+			if err = visitFn(name, false, &filer_pb.RemoteEntry{
+				RemoteMtime: blobItem.Properties.LastModified.Unix(),
+				RemoteSize:  size,
+				RemoteETag:  strings.Trim(string(blobItem.Properties.Etag), `"`),
+				StorageName: az.conf.Name,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (az *azureRemoteStorageClient) ReadFile(loc *filer_pb.RemoteStorageLocation) (io.ReadCloser, error) {
+	blobURL := az.serviceURL.NewContainerURL(loc.Bucket).NewBlobURL(remote_storage.ObjectKey(loc.Path, false))
+	resp, err := blobURL.Download(context.Background(), 0, azblob.CountToEnd, azblob.BlobAccessConditions{}, false)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %v", remote_storage.FormatLocation(loc), err)
+	}
+	return resp.Body(azblob.RetryReaderOptions{MaxRetryRequests: 3}), nil
+}
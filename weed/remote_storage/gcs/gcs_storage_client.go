@@ -0,0 +1,84 @@
+package gcs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/remote_storage"
+)
+
+func init() {
+	remote_storage.RemoteStorageClientMakers["gcs"] = new(gcsRemoteStorageMaker)
+}
+
+type gcsRemoteStorageMaker struct{}
+
+func (s gcsRemoteStorageMaker) Make(conf *filer_pb.RemoteConf) (remote_storage.RemoteStorageClient, error) {
+	var opts []option.ClientOption
+	if conf.GcsGoogleApplicationCredentials != "" {
+		opts = append(opts, option.WithCredentialsFile(conf.GcsGoogleApplicationCredentials))
+	}
+	client, err := storage.NewClient(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("create gcs client: %v", err)
+	}
+	return &gcsRemoteStorageClient{
+		conf:   conf,
+		client: client,
+	}, nil
+}
+
+type gcsRemoteStorageClient struct {
+	conf   *filer_pb.RemoteConf
+	client *storage.Client
+}
+
+func (gcs *gcsRemoteStorageClient) ListDirectory(ctx context.Context, loc *filer_pb.RemoteStorageLocation, visitFn remote_storage.VisitFunc) error {
+	prefix := remote_storage.ObjectKey(loc.Path, true)
+	objects := gcs.client.Bucket(loc.Bucket).Objects(ctx, &storage.Query{
+		Prefix:    prefix,
+		Delimiter: "/",
+	})
+	for {
+		attrs, err := objects.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("list %s: %v", remote_storage.FormatLocation(loc), err)
+		}
+		if attrs.Prefix != "" {
+			if err = visitFn(strings.TrimSuffix(strings.TrimPrefix(attrs.Prefix, prefix), "/"), true, nil); err != nil {
+				return err
+			}
+			continue
+		}
+		name := strings.TrimPrefix(attrs.Name, prefix)
+		if name == "" || strings.HasSuffix(name, "/") {
+			continue
+		}
+		if err = visitFn(name, false, &filer_pb.RemoteEntry{
+			RemoteMtime: attrs.Updated.Unix(),
+			RemoteSize:  attrs.Size,
+			RemoteETag:  attrs.Etag,
+			StorageName: gcs.conf.Name,
+		}); err != nil {
+			return err
+		}
+	}
+}
+
+func (gcs *gcsRemoteStorageClient) ReadFile(loc *filer_pb.RemoteStorageLocation) (io.ReadCloser, error) {
+	reader, err := gcs.client.Bucket(loc.Bucket).Object(remote_storage.ObjectKey(loc.Path, false)).NewReader(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %v", remote_storage.FormatLocation(loc), err)
+	}
+	return reader, nil
+}
@@ -0,0 +1,94 @@
+package remote_storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+)
+
+// VisitFunc is called for each object and each sub directory directly under the listed directory.
+// The remoteEntry is nil for the sub directories.
+type VisitFunc func(name string, isDirectory bool, remoteEntry *filer_pb.RemoteEntry) error
+
+// RemoteStorageClient lists and reads the objects in an external object store.
+// The location path is the directory to list, or the object to read, always starting with "/".
+// The listing stops when the context is done.
+type RemoteStorageClient interface {
+	ListDirectory(ctx context.Context, loc *filer_pb.RemoteStorageLocation, visitFn VisitFunc) error
+	ReadFile(loc *filer_pb.RemoteStorageLocation) (io.ReadCloser, error)
+}
+
+type RemoteStorageClientMaker interface {
+	Make(remoteConf *filer_pb.RemoteConf) (RemoteStorageClient, error)
+}
+
+var (
+	RemoteStorageClientMakers = make(map[string]RemoteStorageClientMaker)
+	remoteStorageClients      = make(map[string]cachedRemoteStorageClient)
+	remoteStorageClientsLock  sync.Mutex
+)
+
+type cachedRemoteStorageClient struct {
+	remoteConf *filer_pb.RemoteConf
+	client     RemoteStorageClient
+}
+
+// GetRemoteStorage returns the client of the remote storage, reused until the configuration changes.
+func GetRemoteStorage(remoteConf *filer_pb.RemoteConf) (RemoteStorageClient, error) {
+	remoteStorageClientsLock.Lock()
+	defer remoteStorageClientsLock.Unlock()
+
+	if cached, found := remoteStorageClients[remoteConf.Name]; found && proto.Equal(cached.remoteConf, remoteConf) {
+		return cached.client, nil
+	}
+
+	maker, found := RemoteStorageClientMakers[remoteConf.Type]
+	if !found {
+		return nil, fmt.Errorf("remote storage type %s not found", remoteConf.Type)
+	}
+	client, err := maker.Make(remoteConf)
+	if err != nil {
+		return nil, fmt.Errorf("make remote storage %s: %v", remoteConf.Name, err)
+	}
+	remoteStorageClients[remoteConf.Name] = cachedRemoteStorageClient{
+		remoteConf: remoteConf,
+		client:     client,
+	}
+	return client, nil
+}
+
+// ParseLocation parses the remote location as <storage name>/<bucket>/<path>, e.g. "s3_1/bucket1/some/dir".
+func ParseLocation(remote string) (*filer_pb.RemoteStorageLocation, error) {
+	parts := strings.SplitN(strings.Trim(remote, "/"), "/", 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("remote location %q should be <storage name>/<bucket>/<path>", remote)
+	}
+	loc := &filer_pb.RemoteStorageLocation{
+		Name:   parts[0],
+		Bucket: parts[1],
+		Path:   "/",
+	}
+	if len(parts) == 3 {
+		loc.Path = "/" + strings.Trim(parts[2], "/")
+	}
+	return loc, nil
+}
+
+func FormatLocation(loc *filer_pb.RemoteStorageLocation) string {
+	return fmt.Sprintf("%s/%s%s", loc.Name, loc.Bucket, loc.Path)
+}
+
+// ObjectKey is the object key of a file, or the key prefix of a directory with the trailing "/".
+func ObjectKey(path string, isDirectory bool) string {
+	key := strings.TrimPrefix(path, "/")
+	if isDirectory && key != "" && !strings.HasSuffix(key, "/") {
+		key += "/"
+	}
+	return key
+}
@@ -0,0 +1,39 @@
+package remote_storage
+
+import (
+	"testing"
+)
+
+func TestParseLocation(t *testing.T) {
+	for remote, expected := range map[string]string{
+		"s3_1/bucket1":               "s3_1/bucket1/",
+		"s3_1/bucket1/":              "s3_1/bucket1/",
+		"s3_1/bucket1/some/dir/":     "s3_1/bucket1/some/dir",
+		"/gcs_1/bucket2/some/object": "gcs_1/bucket2/some/object",
+	} {
+		loc, err := ParseLocation(remote)
+		if err != nil {
+			t.Fatalf("parse %s: %v", remote, err)
+		}
+		if FormatLocation(loc) != expected {
+			t.Errorf("parse %s: expecting %s, but got %s", remote, expected, FormatLocation(loc))
+		}
+	}
+	for _, remote := range []string{"", "s3_1", "s3_1/", "/bucket1"} {
+		if _, err := ParseLocation(remote); err == nil {
+			t.Errorf("expecting error to parse %q", remote)
+		}
+	}
+}
+
+func TestObjectKey(t *testing.T) {
+	if key := ObjectKey("/", true); key != "" {
+		t.Errorf("root directory key: %q", key)
+	}
+	if key := ObjectKey("/some/dir", true); key != "some/dir/" {
+		t.Errorf("directory key: %q", key)
+	}
+	if key := ObjectKey("/some/object", false); key != "some/object" {
+		t.Errorf("object key: %q", key)
+	}
+}
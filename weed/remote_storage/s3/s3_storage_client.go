@@ -0,0 +1,96 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/remote_storage"
+)
+
+func init() {
+	remote_storage.RemoteStorageClientMakers["s3"] = new(s3RemoteStorageMaker)
+}
+
+type s3RemoteStorageMaker struct{}
+
+func (s s3RemoteStorageMaker) Make(conf *filer_pb.RemoteConf) (remote_storage.RemoteStorageClient, error) {
+	config := &aws.Config{
+		Region:           aws.String(conf.S3Region),
+		Endpoint:         aws.String(conf.S3Endpoint),
+		S3ForcePathStyle: aws.Bool(true),
+	}
+	if conf.S3AccessKey != "" && conf.S3SecretKey != "" {
+		config.Credentials = credentials.NewStaticCredentials(conf.S3AccessKey, conf.S3SecretKey, "")
+	}
+
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return nil, fmt.Errorf("create aws session: %v", err)
+	}
+	return &s3RemoteStorageClient{
+		conf: conf,
+		conn: s3.New(sess),
+	}, nil
+}
+
+type s3RemoteStorageClient struct {
+	conf *filer_pb.RemoteConf
+	conn s3iface.S3API
+}
+
+func (s *s3RemoteStorageClient) ListDirectory(ctx context.Context, loc *filer_pb.RemoteStorageLocation, visitFn remote_storage.VisitFunc) (err error) {
+	prefix := remote_storage.ObjectKey(loc.Path, true)
+	listInput := &s3.ListObjectsV2Input{
+		Bucket:    aws.String(loc.Bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	}
+	listErr := s.conn.ListObjectsV2PagesWithContext(ctx, listInput, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, commonPrefix := range page.CommonPrefixes {
+			name := strings.TrimSuffix(strings.TrimPrefix(aws.StringValue(commonPrefix.Prefix), prefix), "/")
+			if err = visitFn(name, true, nil); err != nil {
+				return false
+			}
+		}
+		for _, content := range page.Contents {
+			name := strings.TrimPrefix(aws.StringValue(content.Key), prefix)
+			if name == "" || strings.HasSuffix(name, "/") {
+				// the directory marker
+				continue
+			}
+			if err = visitFn(name, false, &filer_pb.RemoteEntry{
+				RemoteMtime: aws.TimeValue(content.LastModified).Unix(),
+				RemoteSize:  aws.Int64Value(content.Size),
+				RemoteETag:  strings.Trim(aws.StringValue(content.ETag), `"`),
+				StorageName: s.conf.Name,
+			}); err != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if listErr != nil {
+		return fmt.Errorf("list %s: %v", remote_storage.FormatLocation(loc), listErr)
+	}
+	return
+}
+
+func (s *s3RemoteStorageClient) ReadFile(loc *filer_pb.RemoteStorageLocation) (io.ReadCloser, error) {
+	resp, err := s.conn.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(loc.Bucket),
+		Key:    aws.String(remote_storage.ObjectKey(loc.Path, false)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %v", remote_storage.FormatLocation(loc), err)
+	}
+	return resp.Body, nil
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/chrislusf/seaweedfs/weed/filer"
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
 	"github.com/chrislusf/seaweedfs/weed/replication/sink"
@@ -58,6 +59,11 @@ func (r *Replicator) Replicate(ctx context.Context, key string, message *filer_p
 		glog.V(4).Infof("deleting %v", key)
 		return r.sink.DeleteEntry(key, message.OldEntry.IsDirectory, message.DeleteChunks, message.Signatures)
 	}
+	if message.NewEntry != nil && filer.IsSseEncrypted(message.NewEntry.Extended) {
+		// the chunk cipher keys are encrypted, and can only be decrypted by the source filer
+		glog.Warningf("skip replicating the server side encrypted %v", key)
+		return nil
+	}
 	if message.OldEntry == nil && message.NewEntry != nil {
 		glog.V(4).Infof("creating %v", key)
 		return r.sink.CreateEntry(key, message.NewEntry, message.Signatures)
@@ -67,7 +73,11 @@ func (r *Replicator) Replicate(ctx context.Context, key string, message *filer_p
 		return nil
 	}
 
-	foundExisting, err := r.sink.UpdateEntry(key, message.OldEntry, message.NewParentPath, message.NewEntry, message.DeleteChunks, message.Signatures)
+	newParentPath := message.NewParentPath
+	if strings.HasPrefix(newParentPath, r.source.Dir) {
+		newParentPath = util.Join(r.sink.GetSinkToDirectory(), dateKey, newParentPath[len(r.source.Dir):])
+	}
+	foundExisting, err := r.sink.UpdateEntry(key, message.OldEntry, newParentPath, message.NewEntry, message.DeleteChunks, message.Signatures)
 	if foundExisting {
 		glog.V(4).Infof("updated %v", key)
 		return err
@@ -31,6 +31,8 @@ type FilerSink struct {
 	address           string
 	writeChunkByFiler bool
 	isIncremental     bool
+	conflictPolicy    string
+	eventTsNs         int64
 }
 
 func init() {
@@ -110,48 +112,71 @@ func (fs *FilerSink) CreateEntry(key string, entry *filer_pb.Entry, signatures [
 		}
 		glog.V(1).Infof("lookup: %v", lookupRequest)
 		if resp, err := filer_pb.LookupEntry(client, lookupRequest); err == nil {
-			if filer.ETag(resp.Entry) == filer.ETag(entry) {
+			if isConflicting(entry, resp.Entry) {
+				if fs.isChangedAfterEvent(resp.Entry) {
+					glog.V(2).Infof("late creation %s", key)
+					return nil
+				}
+				if isSourceApplied, err := fs.resolveConflict(key, "created on both", entry, resp.Entry, signatures); err != nil || !isSourceApplied {
+					return err
+				}
+			} else if filer.ETag(resp.Entry) == filer.ETag(entry) {
 				glog.V(3).Infof("already replicated %s", key)
 				return nil
 			}
 		}
 
-		replicatedChunks, err := fs.replicateChunks(entry.Chunks, key)
+		return fs.writeEntry(client, key, entry, signatures)
+	})
+}
 
-		if err != nil {
-			// only warning here since the source chunk may have been deleted already
-			glog.Warningf("replicate entry chunks %s: %v", key, err)
-		}
+// writeEntry replicates the chunks of the source entry, and creates or overwrites the entry at the key
+func (fs *FilerSink) writeEntry(client filer_pb.SeaweedFilerClient, key string, entry *filer_pb.Entry, signatures []int32) error {
 
-		glog.V(4).Infof("replicated %s %+v ===> %+v", key, entry.Chunks, replicatedChunks)
+	dir, name := util.FullPath(key).DirAndName()
 
-		request := &filer_pb.CreateEntryRequest{
-			Directory: dir,
-			Entry: &filer_pb.Entry{
-				Name:        name,
-				IsDirectory: entry.IsDirectory,
-				Attributes:  entry.Attributes,
-				Chunks:      replicatedChunks,
-				Content:     entry.Content,
-			},
-			IsFromOtherCluster: true,
-			Signatures:         signatures,
-		}
+	replicatedChunks, err := fs.replicateChunks(entry.Chunks, key)
 
-		glog.V(3).Infof("create: %v", request)
-		if err := filer_pb.CreateEntry(client, request); err != nil {
-			glog.V(0).Infof("create entry %s: %v", key, err)
-			return fmt.Errorf("create entry %s: %v", key, err)
-		}
+	if err != nil {
+		// only warning here since the source chunk may have been deleted already
+		glog.Warningf("replicate entry chunks %s: %v", key, err)
+	}
 
-		return nil
-	})
+	glog.V(4).Infof("replicated %s %+v ===> %+v", key, entry.Chunks, replicatedChunks)
+
+	request := &filer_pb.CreateEntryRequest{
+		Directory: dir,
+		Entry: &filer_pb.Entry{
+			Name:        name,
+			IsDirectory: entry.IsDirectory,
+			Attributes:  entry.Attributes,
+			Chunks:      replicatedChunks,
+			Content:     entry.Content,
+		},
+		IsFromOtherCluster: true,
+		Signatures:         signatures,
+	}
+
+	glog.V(3).Infof("create: %v", request)
+	if err := filer_pb.CreateEntry(client, request); err != nil {
+		glog.V(0).Infof("create entry %s: %v", key, err)
+		return fmt.Errorf("create entry %s: %v", key, err)
+	}
+
+	return nil
 }
 
 func (fs *FilerSink) UpdateEntry(key string, oldEntry *filer_pb.Entry, newParentPath string, newEntry *filer_pb.Entry, deleteIncludeChunks bool, signatures []int32) (foundExistingEntry bool, err error) {
 
 	dir, name := util.FullPath(key).DirAndName()
 
+	if oldEntry.IsDirectory && newEntry.IsDirectory && newParentPath != "" {
+		if newKey := util.NewFullPath(newParentPath, newEntry.Name); newKey != util.FullPath(key) {
+			// the directory is renamed in the source filer store, with all the entries under it
+			return fs.renameDirectory(util.FullPath(key), newKey, signatures)
+		}
+	}
+
 	// read existing entry
 	var existingEntry *filer_pb.Entry
 	err = fs.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
@@ -179,6 +204,17 @@ func (fs *FilerSink) UpdateEntry(key string, oldEntry *filer_pb.Entry, newParent
 
 	glog.V(4).Infof("oldEntry %+v, newEntry %+v, existingEntry: %+v", oldEntry, newEntry, existingEntry)
 
+	if isConflicting(newEntry, existingEntry) && isConflicting(oldEntry, existingEntry) && !fs.isChangedAfterEvent(existingEntry) {
+		// the target file is changed since the source file before the update
+		isSourceApplied, err := fs.resolveConflict(key, "updated on both", newEntry, existingEntry, signatures)
+		if err != nil || !isSourceApplied {
+			return true, err
+		}
+		return true, fs.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+			return fs.writeEntry(client, key, newEntry, signatures)
+		})
+	}
+
 	if existingEntry.Attributes.Mtime > newEntry.Attributes.Mtime {
 		// skip if already changed
 		// this usually happens when the messages are not ordered
@@ -244,3 +280,34 @@ func compareChunks(lookupFileIdFn wdclient.LookupFileIdFunctionType, oldEntry, n
 
 	return
 }
+
+func (fs *FilerSink) renameDirectory(oldKey, newKey util.FullPath, signatures []int32) (foundExistingEntry bool, err error) {
+	oldDir, oldName := oldKey.DirAndName()
+	newDir, newName := newKey.DirAndName()
+	err = fs.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+
+		if _, err := filer_pb.LookupEntry(client, &filer_pb.LookupDirectoryEntryRequest{
+			Directory: oldDir,
+			Name:      oldName,
+		}); err != nil {
+			return err
+		}
+		foundExistingEntry = true
+
+		glog.V(4).Infof("rename directory %s => %s", oldKey, newKey)
+		if _, err := client.AtomicRenameEntry(context.Background(), &filer_pb.AtomicRenameEntryRequest{
+			OldDirectory: oldDir,
+			OldName:      oldName,
+			NewDirectory: newDir,
+			NewName:      newName,
+			Signatures:   signatures,
+		}); err != nil {
+			return fmt.Errorf("rename directory %s => %s: %v", oldKey, newKey, err)
+		}
+		return nil
+	})
+	if !foundExistingEntry {
+		return false, nil
+	}
+	return true, err
+}
@@ -0,0 +1,270 @@
+package filersink
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/replication/sink"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+With active-active filer.sync, one file can be changed on both filers before the changes are synced.
+The conflicting changes are found by comparing the target entry with the source entry before the change:
+
+	created on both      the source creates a file, and the target has a different file at the path
+	updated on both      the source updates a file, and the target file is different from the source file before the update
+	deleted and updated  the source deletes a file, and the target file is different from the deleted one
+
+For the files created or updated on both, the winner is the file with the newer mtime, or the larger ETag
+for the same mtime, so both sync directions pick the same winner. Depending on the conflict policy, the loser is
+
+	lastWriteWins     overwritten, or not synced
+	renameLoserAside  kept aside as "<name>.conflict-<loser mtime><ext>", on both filers
+	reject            not resolved, and both filers keep their own files
+
+A file deleted on one filer and updated on the other is kept by all the policies, since the update
+is synced back to recreate it on the other filer. The directories are not checked.
+
+Only the target files changed before the source change event are conflicting. A target file with a
+newer mtime than the event is changed after it, e.g. when the changes are synced again after filer.sync
+restarts from its last checkpoint, so the source change is stale and skipped.
+
+The conflicts are recorded in the kv of the target filer, and shown by "fs.sync.conflicts".
+*/
+
+const (
+	ConflictLastWriteWins    = "lastWriteWins"
+	ConflictRenameLoserAside = "renameLoserAside"
+	ConflictReject           = "reject"
+	SyncConflictsKey         = "sync.conflicts"
+	maxSyncConflicts         = 1000
+)
+
+var _ = sink.ConflictCheckingSink(&FilerSink{})
+
+func CheckConflictPolicy(policy string) error {
+	switch policy {
+	case ConflictLastWriteWins, ConflictRenameLoserAside, ConflictReject:
+		return nil
+	}
+	return fmt.Errorf("unknown conflict policy %q, expecting %s, %s or %s", policy, ConflictLastWriteWins, ConflictRenameLoserAside, ConflictReject)
+}
+
+// SetConflictPolicy sets how to resolve the conflicting changes, ConflictLastWriteWins by default
+func (fs *FilerSink) SetConflictPolicy(policy string) error {
+	if err := CheckConflictPolicy(policy); err != nil {
+		return err
+	}
+	fs.conflictPolicy = policy
+	return nil
+}
+
+func (fs *FilerSink) getConflictPolicy() string {
+	if fs.conflictPolicy == "" {
+		return ConflictLastWriteWins
+	}
+	return fs.conflictPolicy
+}
+
+// SetEventTsNs sets the time of the source change event being synced
+func (fs *FilerSink) SetEventTsNs(tsNs int64) {
+	fs.eventTsNs = tsNs
+}
+
+// isChangedAfterEvent checks whether the target file is changed after the source change event,
+// which is then stale. The time is not known for the changes not from filer.sync.
+func (fs *FilerSink) isChangedAfterEvent(targetEntry *filer_pb.Entry) bool {
+	return fs.eventTsNs != 0 && mtimeOf(targetEntry) > fs.eventTsNs/int64(time.Second)
+}
+
+// DeleteReplicatedEntry deletes the target entry, unless it is a file different from the deleted source file
+func (fs *FilerSink) DeleteReplicatedEntry(key string, oldEntry *filer_pb.Entry, deleteIncludeChunks bool, signatures []int32) error {
+	if !oldEntry.IsDirectory {
+		existingEntry, err := fs.lookupEntry(key)
+		if err != nil && err != filer_pb.ErrNotFound {
+			return fmt.Errorf("lookup %s: %v", key, err)
+		}
+		if existingEntry != nil && isConflicting(oldEntry, existingEntry) {
+			if fs.isChangedAfterEvent(existingEntry) {
+				glog.V(2).Infof("late deletion %s", key)
+				return nil
+			}
+			fs.recordConflict(key, "deleted and updated", oldEntry, existingEntry, "kept the target file")
+			return nil
+		}
+	}
+	return fs.DeleteEntry(key, oldEntry.IsDirectory, deleteIncludeChunks, signatures)
+}
+
+func (fs *FilerSink) lookupEntry(key string) (entry *filer_pb.Entry, err error) {
+	dir, name := util.FullPath(key).DirAndName()
+	err = fs.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+		resp, lookupErr := filer_pb.LookupEntry(client, &filer_pb.LookupDirectoryEntryRequest{
+			Directory: dir,
+			Name:      name,
+		})
+		if lookupErr != nil {
+			return lookupErr
+		}
+		entry = resp.Entry
+		return nil
+	})
+	return
+}
+
+// isConflicting checks whether the source and target files are different
+func isConflicting(sourceEntry, targetEntry *filer_pb.Entry) bool {
+	if sourceEntry.IsDirectory || targetEntry.IsDirectory {
+		return false
+	}
+	return filer.ETag(sourceEntry) != filer.ETag(targetEntry) || !bytes.Equal(sourceEntry.Content, targetEntry.Content)
+}
+
+func isSourceWinning(sourceEntry, targetEntry *filer_pb.Entry) bool {
+	if sourceMtime, targetMtime := mtimeOf(sourceEntry), mtimeOf(targetEntry); sourceMtime != targetMtime {
+		return sourceMtime > targetMtime
+	}
+	if sourceETag, targetETag := filer.ETag(sourceEntry), filer.ETag(targetEntry); sourceETag != targetETag {
+		return sourceETag > targetETag
+	}
+	return bytes.Compare(sourceEntry.Content, targetEntry.Content) > 0
+}
+
+func mtimeOf(entry *filer_pb.Entry) int64 {
+	if entry.Attributes == nil {
+		return 0
+	}
+	return entry.Attributes.Mtime
+}
+
+// asideKey is the same on both filers for the same loser
+func asideKey(key string, loser *filer_pb.Entry) string {
+	dir, name := util.FullPath(key).DirAndName()
+	ext := path.Ext(name)
+	if ext == name {
+		ext = ""
+	}
+	suffix := ".conflict-" + time.Unix(mtimeOf(loser), 0).UTC().Format("20060102-150405")
+	return string(util.NewFullPath(dir, strings.TrimSuffix(name, ext)+suffix+ext))
+}
+
+// resolveConflict resolves the conflict between the source file and the different target file at the key,
+// and returns whether to write the source file to the key.
+func (fs *FilerSink) resolveConflict(key, reason string, sourceEntry, targetEntry *filer_pb.Entry, signatures []int32) (isSourceApplied bool, err error) {
+	policy := fs.getConflictPolicy()
+	isSourceWinner := isSourceWinning(sourceEntry, targetEntry)
+
+	var resolution string
+	switch {
+	case policy == ConflictReject:
+		resolution = "rejected the source change"
+	case policy == ConflictLastWriteWins && isSourceWinner:
+		resolution, isSourceApplied = "overwrote the target file", true
+	case policy == ConflictLastWriteWins:
+		resolution = "skipped the older source change"
+	case isSourceWinner:
+		aside := asideKey(key, targetEntry)
+		if err = fs.moveAside(key, aside, targetEntry, signatures); err != nil {
+			return false, err
+		}
+		resolution, isSourceApplied = "moved the target file to "+aside, true
+	default:
+		// not signed by the source filer, to be synced back
+		aside := asideKey(key, sourceEntry)
+		if err = fs.CreateEntry(aside, sourceEntry, nil); err != nil {
+			return false, err
+		}
+		resolution = "wrote the source file to " + aside
+	}
+
+	fs.recordConflict(key, reason, sourceEntry, targetEntry, resolution)
+	return isSourceApplied, nil
+}
+
+// moveAside renames the target file to the aside key, signed by the source filer not to be synced back,
+// since the source filer keeps the file aside by itself, as the loser of the same conflict.
+func (fs *FilerSink) moveAside(key, aside string, targetEntry *filer_pb.Entry, signatures []int32) error {
+	existingAside, err := fs.lookupEntry(aside)
+	if err != nil && err != filer_pb.ErrNotFound {
+		return fmt.Errorf("lookup %s: %v", aside, err)
+	}
+	if existingAside != nil && !isConflicting(targetEntry, existingAside) {
+		// already synced from the source filer
+		return nil
+	}
+	oldDir, oldName := util.FullPath(key).DirAndName()
+	newDir, newName := util.FullPath(aside).DirAndName()
+	return fs.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+		if _, err := client.AtomicRenameEntry(context.Background(), &filer_pb.AtomicRenameEntryRequest{
+			OldDirectory: oldDir,
+			OldName:      oldName,
+			NewDirectory: newDir,
+			NewName:      newName,
+			Signatures:   signatures,
+		}); err != nil {
+			return fmt.Errorf("move %s aside to %s: %v", key, aside, err)
+		}
+		return nil
+	})
+}
+
+func (fs *FilerSink) recordConflict(key, reason string, sourceEntry, targetEntry *filer_pb.Entry, resolution string) {
+	glog.Warningf("sync conflict %s %s: %s", key, reason, resolution)
+
+	conflict := &filer_pb.SyncConflicts_Conflict{
+		DetectedAtNs: time.Now().UnixNano(),
+		Path:         key,
+		Reason:       reason,
+		Policy:       fs.getConflictPolicy(),
+		Resolution:   resolution,
+		SourceMtime:  mtimeOf(sourceEntry),
+		TargetMtime:  mtimeOf(targetEntry),
+	}
+	if fs.filerSource != nil {
+		conflict.SourceFiler = fs.filerSource.GetAddress()
+	}
+
+	err := fs.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+		resp, err := client.KvGet(context.Background(), &filer_pb.KvGetRequest{Key: []byte(SyncConflictsKey)})
+		if err != nil {
+			return err
+		}
+		if resp.Error != "" {
+			return errors.New(resp.Error)
+		}
+		conflicts := &filer_pb.SyncConflicts{}
+		if err = proto.Unmarshal(resp.Value, conflicts); err != nil {
+			return err
+		}
+		conflicts.Conflicts = append(conflicts.Conflicts, conflict)
+		if len(conflicts.Conflicts) > maxSyncConflicts {
+			conflicts.Conflicts = conflicts.Conflicts[len(conflicts.Conflicts)-maxSyncConflicts:]
+		}
+		value, err := proto.Marshal(conflicts)
+		if err != nil {
+			return err
+		}
+		putResp, err := client.KvPut(context.Background(), &filer_pb.KvPutRequest{Key: []byte(SyncConflictsKey), Value: value})
+		if err != nil {
+			return err
+		}
+		if putResp.Error != "" {
+			return errors.New(putResp.Error)
+		}
+		return nil
+	})
+	if err != nil {
+		glog.Errorf("record sync conflict %s: %v", key, err)
+	}
+}
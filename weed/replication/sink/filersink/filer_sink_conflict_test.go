@@ -0,0 +1,39 @@
+package filersink
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+)
+
+func TestConflictWinner(t *testing.T) {
+
+	older := &filer_pb.Entry{Name: "a.txt", Attributes: &filer_pb.FuseAttributes{Mtime: 100, Md5: []byte{1}}}
+	newer := &filer_pb.Entry{Name: "a.txt", Attributes: &filer_pb.FuseAttributes{Mtime: 200, Md5: []byte{2}}}
+	sameTime := &filer_pb.Entry{Name: "a.txt", Attributes: &filer_pb.FuseAttributes{Mtime: 200, Md5: []byte{3}}}
+
+	assert.True(t, isConflicting(older, newer))
+	assert.False(t, isConflicting(newer, newer))
+	assert.False(t, isConflicting(&filer_pb.Entry{IsDirectory: true}, newer), "directories are not checked")
+	assert.True(t, isConflicting(&filer_pb.Entry{Content: []byte("x")}, &filer_pb.Entry{Content: []byte("y")}))
+
+	// both sync directions pick the same winner
+	assert.True(t, isSourceWinning(newer, older))
+	assert.False(t, isSourceWinning(older, newer))
+	assert.NotEqual(t, isSourceWinning(newer, sameTime), isSourceWinning(sameTime, newer))
+
+	asideTime := time.Unix(100, 0).UTC().Format("20060102-150405")
+	assert.Equal(t, "/dir/a.conflict-"+asideTime+".txt", asideKey("/dir/a.txt", older))
+	assert.Equal(t, "/dir/.bashrc.conflict-"+asideTime, asideKey("/dir/.bashrc", older))
+	assert.Equal(t, "/dir/Makefile.conflict-"+asideTime, asideKey("/dir/Makefile", older))
+
+	// the target files changed after the source change event are not conflicting
+	fs := &FilerSink{}
+	assert.False(t, fs.isChangedAfterEvent(newer), "the event time is not known")
+	fs.SetEventTsNs(time.Unix(150, 0).UnixNano())
+	assert.True(t, fs.isChangedAfterEvent(newer))
+	assert.False(t, fs.isChangedAfterEvent(older))
+}
@@ -17,6 +17,13 @@ type ReplicationSink interface {
 	IsIncremental() bool
 }
 
+// ConflictCheckingSink checks the target entry is the same as the deleted source entry before deleting it,
+// and checks the target entries changed before the source change event for conflicts.
+type ConflictCheckingSink interface {
+	DeleteReplicatedEntry(key string, oldEntry *filer_pb.Entry, deleteIncludeChunks bool, signatures []int32) error
+	SetEventTsNs(tsNs int64)
+}
+
 var (
 	Sinks []ReplicationSink
 )
@@ -0,0 +1,263 @@
+package s3api
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/s3api/policy_engine"
+)
+
+/*
+The bucket policies are set by PutBucketPolicy, and kept in the ExtS3PolicyKey extended attribute of the bucket directory.
+They are evaluated after the request is authenticated, together with the actions of the identities:
+
+	a matched Deny       denies the request, except for the admin identities
+	a matched Allow      allows the request, also for the anonymous requests
+	otherwise            the actions of the identity decide
+
+Without the prefetch option, the bucket policies are looked up from the filer again after bucketPolicyCacheTtl.
+*/
+
+const (
+	ExtS3PolicyKey       = "x-seaweedfs-s3-policy"
+	bucketPolicyCacheTtl = 10 * time.Second
+)
+
+type cachedBucketPolicy struct {
+	raw          string
+	policy       *policy_engine.PolicyDocument
+	publicAccess string
+	loadedAt     time.Time
+}
+
+type bucketPolicyCache struct {
+	sync.Mutex
+	policies map[string]*cachedBucketPolicy
+}
+
+// getBucketPolicy returns the parsed policy of the bucket, nil if not set.
+func (s3a *S3ApiServer) getBucketPolicy(bucket string) (*policy_engine.PolicyDocument, error) {
+	cached, err := s3a.loadBucketPolicy(bucket)
+	if cached == nil || err != nil {
+		return nil, err
+	}
+	return cached.policy, nil
+}
+
+// getBucketPublicAccess returns the public access of the bucket, empty for the private buckets.
+func (s3a *S3ApiServer) getBucketPublicAccess(bucket string) (string, error) {
+	cached, err := s3a.loadBucketPolicy(bucket)
+	if cached == nil || err != nil {
+		return "", err
+	}
+	return cached.publicAccess, nil
+}
+
+// loadBucketPolicy returns the cached policy and public access of the bucket, nil if the bucket is not found.
+func (s3a *S3ApiServer) loadBucketPolicy(bucket string) (*cachedBucketPolicy, error) {
+	s3a.bucketPolicies.Lock()
+	cached, found := s3a.bucketPolicies.policies[bucket]
+	s3a.bucketPolicies.Unlock()
+	if found && s3a.buckets == nil && time.Since(cached.loadedAt) < bucketPolicyCacheTtl {
+		return cached, nil
+	}
+
+	entry, err := s3a.getBucketEntry(bucket)
+	if err == filer_pb.ErrNotFound || err == nil && entry == nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	raw := string(entry.Extended[ExtS3PolicyKey])
+	publicAccess := string(entry.Extended[ExtS3PublicAccessKey])
+	if found && cached.raw == raw && cached.publicAccess == publicAccess {
+		s3a.bucketPolicies.Lock()
+		cached.loadedAt = time.Now()
+		s3a.bucketPolicies.Unlock()
+		return cached, nil
+	}
+
+	var policy *policy_engine.PolicyDocument
+	if raw != "" {
+		if policy, err = policy_engine.ParsePolicyDocument([]byte(raw)); err != nil {
+			glog.Errorf("bucket %s policy: %v", bucket, err)
+			return nil, err
+		}
+	}
+	cached = &cachedBucketPolicy{raw: raw, policy: policy, publicAccess: publicAccess, loadedAt: time.Now()}
+	s3a.bucketPolicies.Lock()
+	s3a.bucketPolicies.policies[bucket] = cached
+	s3a.bucketPolicies.Unlock()
+	return cached, nil
+}
+
+func (s3a *S3ApiServer) evictBucketPolicy(bucket string) {
+	s3a.bucketPolicies.Lock()
+	delete(s3a.bucketPolicies.policies, bucket)
+	s3a.bucketPolicies.Unlock()
+}
+
+// evalBucketPolicy returns the decision of the bucket policy on the request
+func (iam *IdentityAccessManagement) evalBucketPolicy(r *http.Request, identity *Identity, bucket, object string) policy_engine.Decision {
+	if iam.bucketPolicy == nil || bucket == "" {
+		return policy_engine.DecisionNone
+	}
+	policy, err := iam.bucketPolicy(bucket)
+	if err != nil {
+		// fail closed with a broken policy
+		return policy_engine.DecisionDeny
+	}
+	if policy == nil {
+		return policy_engine.DecisionNone
+	}
+	return policy.Evaluate(policyRequestOf(r, identity, bucket, object))
+}
+
+func policyRequestOf(r *http.Request, identity *Identity, bucket, object string) *policy_engine.Request {
+	req := &policy_engine.Request{
+		Action:     policyActionOf(r, object),
+		Resource:   policy_engine.ResourceArnPrefix + bucket,
+		Conditions: make(map[string][]string),
+	}
+	if object != "" && object != "/" {
+		req.Resource += object
+	}
+	if identity != nil && identity.Name != "anonymous" {
+		req.Principal = identity.Name
+		req.Conditions["aws:username"] = []string{identity.Name}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		req.Conditions["aws:SourceIp"] = []string{host}
+	}
+	secure := r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+	req.Conditions["aws:SecureTransport"] = []string{boolString(secure)}
+	if userAgent := r.UserAgent(); userAgent != "" {
+		req.Conditions["aws:UserAgent"] = []string{userAgent}
+	}
+	if referer := r.Referer(); referer != "" {
+		req.Conditions["aws:Referer"] = []string{referer}
+	}
+	query := r.URL.Query()
+	for _, key := range []string{"prefix", "delimiter", "max-keys"} {
+		if values, found := query[key]; found {
+			req.Conditions["s3:"+key] = values
+		}
+	}
+	return req
+}
+
+// policyActionOf returns the s3 action of the request, used in the policy statements
+func policyActionOf(r *http.Request, object string) string {
+	query := r.URL.Query()
+	has := func(key string) bool {
+		_, found := query[key]
+		return found
+	}
+	if object == "" || object == "/" {
+		// the bucket level requests
+		for _, sub := range []struct{ query, get, put, delete string }{
+			{"policy", "GetBucketPolicy", "PutBucketPolicy", "DeleteBucketPolicy"},
+			{"lifecycle", "GetLifecycleConfiguration", "PutLifecycleConfiguration", "PutLifecycleConfiguration"},
+			{"encryption", "GetEncryptionConfiguration", "PutEncryptionConfiguration", "PutEncryptionConfiguration"},
+			{"versioning", "GetBucketVersioning", "PutBucketVersioning", ""},
+			{"tagging", "GetBucketTagging", "PutBucketTagging", "PutBucketTagging"},
+			{"cors", "GetBucketCORS", "PutBucketCORS", "PutBucketCORS"},
+			{"notification", "GetBucketNotification", "PutBucketNotification", ""},
+			{"object-lock", "GetBucketObjectLockConfiguration", "PutBucketObjectLockConfiguration", ""},
+			{"acl", "GetBucketAcl", "PutBucketAcl", ""},
+			{"website", "GetBucketWebsite", "PutBucketWebsite", "DeleteBucketWebsite"},
+			{"inventory", "GetInventoryConfiguration", "PutInventoryConfiguration", "PutInventoryConfiguration"},
+			{"uploads", "ListBucketMultipartUploads", "", ""},
+			{"versions", "ListBucketVersions", "", ""},
+		} {
+			if !has(sub.query) {
+				continue
+			}
+			action := ""
+			switch r.Method {
+			case http.MethodGet, http.MethodHead:
+				action = sub.get
+			case http.MethodPut:
+				action = sub.put
+			case http.MethodDelete:
+				action = sub.delete
+			}
+			if action != "" {
+				return "s3:" + action
+			}
+		}
+		switch r.Method {
+		case http.MethodPut:
+			return "s3:CreateBucket"
+		case http.MethodDelete:
+			return "s3:DeleteBucket"
+		case http.MethodPost:
+			if has("delete") {
+				return "s3:DeleteObject"
+			}
+			// the post policy uploads
+			return "s3:PutObject"
+		}
+		return "s3:ListBucket"
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		switch {
+		case has("tagging"):
+			return "s3:GetObjectTagging"
+		case has("acl"):
+			return "s3:GetObjectAcl"
+		case has("retention"):
+			return "s3:GetObjectRetention"
+		case has("legal-hold"):
+			return "s3:GetObjectLegalHold"
+		case has("uploadId"):
+			return "s3:ListMultipartUploadParts"
+		case has("attributes"):
+			return "s3:GetObjectAttributes"
+		}
+		return "s3:GetObject"
+	case http.MethodPut:
+		switch {
+		case has("tagging"):
+			return "s3:PutObjectTagging"
+		case has("acl"):
+			return "s3:PutObjectAcl"
+		case has("retention"):
+			return "s3:PutObjectRetention"
+		case has("legal-hold"):
+			return "s3:PutObjectLegalHold"
+		}
+		return "s3:PutObject"
+	case http.MethodDelete:
+		switch {
+		case has("tagging"):
+			return "s3:DeleteObjectTagging"
+		case has("uploadId"):
+			return "s3:AbortMultipartUpload"
+		}
+		return "s3:DeleteObject"
+	case http.MethodPost:
+		if has("restore") {
+			return "s3:RestoreObject"
+		}
+		if has("select") {
+			return "s3:GetObject"
+		}
+		return "s3:PutObject"
+	}
+	return "s3:" + r.Method
+}
+
+func boolString(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
@@ -0,0 +1,126 @@
+package s3api
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/chrislusf/seaweedfs/weed/s3api/policy_engine"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3_constants"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+)
+
+func TestPolicyActionOf(t *testing.T) {
+	for _, c := range []struct {
+		method, target, object, expected string
+	}{
+		{"GET", "/bucket/a.txt", "/a.txt", "s3:GetObject"},
+		{"HEAD", "/bucket/a.txt", "/a.txt", "s3:GetObject"},
+		{"PUT", "/bucket/a.txt?tagging", "/a.txt", "s3:PutObjectTagging"},
+		{"DELETE", "/bucket/a.txt?uploadId=1", "/a.txt", "s3:AbortMultipartUpload"},
+		{"POST", "/bucket/a.csv?select&select-type=2", "/a.csv", "s3:GetObject"},
+		{"PUT", "/bucket/a.txt?retention", "/a.txt", "s3:PutObjectRetention"},
+		{"GET", "/bucket/a.txt?attributes", "/a.txt", "s3:GetObjectAttributes"},
+		{"GET", "/bucket/a.txt?legal-hold", "/a.txt", "s3:GetObjectLegalHold"},
+		{"GET", "/bucket?prefix=a", "", "s3:ListBucket"},
+		{"PUT", "/bucket?policy", "", "s3:PutBucketPolicy"},
+		{"PUT", "/bucket?object-lock", "", "s3:PutBucketObjectLockConfiguration"},
+		{"DELETE", "/bucket?encryption", "", "s3:PutEncryptionConfiguration"},
+		{"DELETE", "/bucket?website", "", "s3:DeleteBucketWebsite"},
+		{"GET", "/bucket?inventory&id=daily", "", "s3:GetInventoryConfiguration"},
+		{"DELETE", "/bucket?inventory&id=daily", "", "s3:PutInventoryConfiguration"},
+		{"POST", "/bucket?delete", "", "s3:DeleteObject"},
+		{"PUT", "/bucket", "", "s3:CreateBucket"},
+	} {
+		r := httptest.NewRequest(c.method, c.target, nil)
+		assert.Equal(t, c.expected, policyActionOf(r, c.object), "%s %s", c.method, c.target)
+	}
+}
+
+func TestPolicyRequestOf(t *testing.T) {
+	r := httptest.NewRequest("GET", "/bucket/docs/a.txt", nil)
+	r.RemoteAddr = "10.1.2.3:5678"
+	req := policyRequestOf(r, &Identity{Name: "alice"}, "bucket", "/docs/a.txt")
+	assert.Equal(t, "alice", req.Principal)
+	assert.Equal(t, "arn:aws:s3:::bucket/docs/a.txt", req.Resource)
+	assert.Equal(t, []string{"10.1.2.3"}, req.Conditions["aws:SourceIp"])
+	assert.Equal(t, []string{"false"}, req.Conditions["aws:SecureTransport"])
+
+	req = policyRequestOf(r, &Identity{Name: "anonymous"}, "bucket", "")
+	assert.Equal(t, "", req.Principal)
+	assert.Equal(t, "arn:aws:s3:::bucket", req.Resource)
+}
+
+func TestAuthorizeWithBucketPolicy(t *testing.T) {
+	policy, err := policy_engine.ParsePolicyDocument([]byte(`{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Principal": "*",
+      "Action": ["s3:GetObject", "s3:PutBucketPolicy", "s3:DeleteBucket"],
+      "Resource": ["arn:aws:s3:::bucket", "arn:aws:s3:::bucket/*"]
+    },
+    {
+      "Effect": "Allow",
+      "Principal": "*",
+      "Action": "s3:*",
+      "Resource": ["arn:aws:s3:::bucket", "arn:aws:s3:::bucket/*"]
+    },
+    {
+      "Effect": "Allow",
+      "Principal": {"AWS": "alice"},
+      "Action": "s3:*",
+      "Resource": ["arn:aws:s3:::bucket", "arn:aws:s3:::bucket/*"]
+    }
+  ]
+}`))
+	assert.NoError(t, err)
+	iam := &IdentityAccessManagement{
+		bucketPolicy: func(bucket string) (*policy_engine.PolicyDocument, error) {
+			return policy, nil
+		},
+	}
+	alice := &Identity{Name: "alice"}
+
+	for _, c := range []struct {
+		method, target, object string
+		identity               *Identity
+		action                 Action
+		expected               s3err.ErrorCode
+	}{
+		// the named actions are granted to everyone
+		{"GET", "/bucket/a.txt", "/a.txt", nil, s3_constants.ACTION_READ, s3err.ErrNone},
+		// "s3:*" for "*" does not grant the actions not named
+		{"PUT", "/bucket/a.txt", "/a.txt", nil, s3_constants.ACTION_WRITE, s3err.ErrAccessDenied},
+		{"PUT", "/bucket/a.txt", "/a.txt", alice, s3_constants.ACTION_WRITE, s3err.ErrNone},
+		// the admin actions are never granted by the policies, even named
+		{"PUT", "/bucket?policy", "", nil, s3_constants.ACTION_ADMIN, s3err.ErrAccessDenied},
+		{"DELETE", "/bucket", "", nil, s3_constants.ACTION_ADMIN, s3err.ErrAccessDenied},
+		{"PUT", "/bucket?encryption", "", nil, s3_constants.ACTION_ADMIN, s3err.ErrAccessDenied},
+		{"PUT", "/bucket?lifecycle", "", alice, s3_constants.ACTION_ADMIN, s3err.ErrAccessDenied},
+	} {
+		r := httptest.NewRequest(c.method, c.target, nil)
+		assert.Equal(t, c.expected, iam.authorize(r, c.identity, c.action, "bucket", c.object), "%s %s", c.method, c.target)
+	}
+}
+
+func TestAuthorizeCopySource(t *testing.T) {
+	iam := &IdentityAccessManagement{}
+	identity := &Identity{Name: "bob", Actions: []Action{"Write:dst", "Read:src"}}
+
+	for _, c := range []struct {
+		copySource string
+		expected   s3err.ErrorCode
+	}{
+		{"/src/a.txt", s3err.ErrNone},
+		{"src/a%20b.txt?versionId=1", s3err.ErrNone},
+		{"/secret/a.txt", s3err.ErrAccessDenied},
+		{"/dst/a.txt", s3err.ErrAccessDenied},
+	} {
+		r := httptest.NewRequest("PUT", "/dst/b.txt", nil)
+		r.Header.Set("X-Amz-Copy-Source", c.copySource)
+		assert.Equal(t, c.expected, iam.authorizeCopySource(r, identity), c.copySource)
+	}
+}
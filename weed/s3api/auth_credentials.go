@@ -3,14 +3,20 @@ package s3api
 import (
 	"fmt"
 	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/s3api/policy_engine"
 	"github.com/chrislusf/seaweedfs/weed/s3api/s3_constants"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/pb/iam_pb"
 	xhttp "github.com/chrislusf/seaweedfs/weed/s3api/http"
 	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+	"github.com/chrislusf/seaweedfs/weed/security"
+	"github.com/chrislusf/seaweedfs/weed/stats"
+	"github.com/chrislusf/seaweedfs/weed/util"
 )
 
 type Action string
@@ -21,13 +27,24 @@ type Iam interface {
 
 type IdentityAccessManagement struct {
 	identities []*Identity
-	domain     string
+	domains    []string
+	// looks up the bucket policies, not set without the buckets
+	bucketPolicy func(bucket string) (*policy_engine.PolicyDocument, error)
+	// looks up the public access of the buckets and the objects, also not set without the buckets
+	bucketPublicAccess func(bucket string) (string, error)
+	objectPublicAccess func(bucket, object string) (string, error)
+	blockPublic        bool
+	// signs the session tokens of the temporary credentials
+	stsSigningKey security.SigningKey
 }
 
 type Identity struct {
 	Name        string
 	Credentials []*Credential
 	Actions     []Action
+	// only for the temporary credentials
+	sessionExpiration time.Time
+	sessionPolicy     *policy_engine.PolicyDocument
 }
 
 type Credential struct {
@@ -36,9 +53,7 @@ type Credential struct {
 }
 
 func NewIdentityAccessManagement(option *S3ApiServerOption) *IdentityAccessManagement {
-	iam := &IdentityAccessManagement{
-		domain: option.DomainName,
-	}
+	iam := newIdentityAccessManagement(option)
 	if option.Config != "" {
 		if err := iam.loadS3ApiConfigurationFromFile(option.Config); err != nil {
 			glog.Fatalf("fail to load config file %s: %v", option.Config, err)
@@ -51,6 +66,15 @@ func NewIdentityAccessManagement(option *S3ApiServerOption) *IdentityAccessManag
 	return iam
 }
 
+// newIdentityAccessManagement creates the identity access management without loading the identities.
+func newIdentityAccessManagement(option *S3ApiServerOption) *IdentityAccessManagement {
+	return &IdentityAccessManagement{
+		domains:       parseDomainNames(option.DomainName),
+		blockPublic:   option.BlockPublic,
+		stsSigningKey: security.SigningKey(util.GetViper().GetString("jwt.sts.key")),
+	}
+}
+
 func (iam *IdentityAccessManagement) loadS3ApiConfigurationFromFiler(option *S3ApiServerOption) error {
 	content, err := filer.ReadContent(option.Filer, filer.IamConfigDirecotry, filer.IamIdentityFile)
 	if err != nil {
@@ -122,6 +146,19 @@ func (iam *IdentityAccessManagement) lookupByAccessKey(accessKey string) (identi
 	return nil, nil, false
 }
 
+// IsEnabled, LookupByAccessKey and CanDo share the S3 identities with the other gateways.
+func (iam *IdentityAccessManagement) IsEnabled() bool {
+	return iam.isEnabled()
+}
+
+func (iam *IdentityAccessManagement) LookupByAccessKey(accessKey string) (identity *Identity, cred *Credential, found bool) {
+	return iam.lookupByAccessKey(accessKey)
+}
+
+func (identity *Identity) CanDo(action Action, bucket string) bool {
+	return identity.canDo(action, bucket)
+}
+
 func (iam *IdentityAccessManagement) lookupAnonymous() (identity *Identity, found bool) {
 
 	for _, ident := range iam.identities {
@@ -139,14 +176,27 @@ func (iam *IdentityAccessManagement) Auth(f http.HandlerFunc, action Action) htt
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
 		identity, errCode := iam.authRequest(r, action)
+		if errCode == s3err.ErrNone && r.Header.Get(xhttp.AmzCopySource) != "" {
+			errCode = iam.authorizeCopySource(r, identity)
+		}
 		if errCode == s3err.ErrNone {
+			stages := stats.NewUploadStages()
+			stages.Since(stats.UploadStageAuth, start)
+			r = r.WithContext(stats.WithUploadStages(r.Context(), stages))
+			r.Header.Del(xhttp.AmzIdentityId)
+			r.Header.Del(xhttp.AmzIsAdmin)
+			if bucket, _ := getBucketAndObject(r); !identity.canBypassGovernance(bucket) {
+				r.Header.Del(xhttp.AmzBypassGovernanceRetention)
+			}
 			if identity != nil && identity.Name != "" {
 				r.Header.Set(xhttp.AmzIdentityId, identity.Name)
 				if identity.isAdmin() {
 					r.Header.Set(xhttp.AmzIsAdmin, "true")
 				}
 			}
+			setAuthorizedUsage(r, identity)
 			f(w, r)
 			return
 		}
@@ -158,17 +208,15 @@ func (iam *IdentityAccessManagement) Auth(f http.HandlerFunc, action Action) htt
 func (iam *IdentityAccessManagement) authRequest(r *http.Request, action Action) (*Identity, s3err.ErrorCode) {
 	var identity *Identity
 	var s3Err s3err.ErrorCode
-	var found bool
 	switch getRequestAuthType(r) {
-	case authTypeStreamingSigned:
-		return identity, s3err.ErrNone
 	case authTypeUnknown:
 		glog.V(3).Infof("unknown auth type")
 		return identity, s3err.ErrAccessDenied
 	case authTypePresignedV2, authTypeSignedV2:
 		glog.V(3).Infof("v2 auth type")
 		identity, s3Err = iam.isReqAuthenticatedV2(r)
-	case authTypeSigned, authTypePresigned:
+	case authTypeSigned, authTypePresigned, authTypeStreamingSigned:
+		// the streaming uploads are authorized by the seed signature, and the chunks verified by newSignV4ChunkedReader
 		glog.V(3).Infof("v4 auth type")
 		identity, s3Err = iam.reqSignatureV4Verify(r)
 	case authTypePostPolicy:
@@ -178,10 +226,8 @@ func (iam *IdentityAccessManagement) authRequest(r *http.Request, action Action)
 		glog.V(3).Infof("jwt auth type")
 		return identity, s3err.ErrNotImplemented
 	case authTypeAnonymous:
-		identity, found = iam.lookupAnonymous()
-		if !found {
-			return identity, s3err.ErrAccessDenied
-		}
+		// without the anonymous identity, the anonymous requests can still be allowed by the bucket policies
+		identity, _ = iam.lookupAnonymous()
 	default:
 		return identity, s3err.ErrNotImplemented
 	}
@@ -190,16 +236,58 @@ func (iam *IdentityAccessManagement) authRequest(r *http.Request, action Action)
 		return identity, s3Err
 	}
 
-	glog.V(3).Infof("user name: %v actions: %v", identity.Name, identity.Actions)
+	bucket, object := getBucketAndObject(r)
 
-	bucket, _ := getBucketAndObject(r)
+	return identity, iam.authorize(r, identity, action, bucket, object)
 
-	if !identity.canDo(action, bucket) {
-		return identity, s3err.ErrAccessDenied
+}
+
+// authorize checks the authenticated identity, nil for the anonymous requests, with the policies and the actions
+func (iam *IdentityAccessManagement) authorize(r *http.Request, identity *Identity, action Action, bucket, object string) s3err.ErrorCode {
+
+	if !evalSessionPolicy(r, identity, bucket, object) {
+		return s3err.ErrAccessDenied
+	}
+	if identity != nil && identity.isAdmin() {
+		return s3err.ErrNone
+	}
+	switch iam.evalBucketPolicy(r, identity, bucket, object) {
+	case policy_engine.DecisionDeny:
+		return s3err.ErrAccessDenied
+	case policy_engine.DecisionAllow:
+		// the admin actions, e.g. changing the bucket policy or deleting the bucket, are never granted by the policies
+		if action != s3_constants.ACTION_ADMIN && (!iam.blockPublic || !isAnonymous(identity)) {
+			return s3err.ErrNone
+		}
 	}
 
-	return identity, s3err.ErrNone
+	if identity != nil {
+		glog.V(3).Infof("user name: %v actions: %v", identity.Name, identity.Actions)
+		if identity.canDo(action, bucket) {
+			return s3err.ErrNone
+		}
+	}
+
+	// the public buckets are open to everyone, also without credentials
+	if iam.isPublicAccessAllowed(r, bucket, object) {
+		return s3err.ErrNone
+	}
 
+	return s3err.ErrAccessDenied
+}
+
+// authorizeCopySource checks the same identity can read the source of the copy requests
+func (iam *IdentityAccessManagement) authorizeCopySource(r *http.Request, identity *Identity) s3err.ErrorCode {
+	srcBucket, srcObject := pathToBucketAndObject(copySourcePathOf(r))
+	if srcBucket == "" {
+		return s3err.ErrNone
+	}
+	// evaluated as a GET of the source object, for the policies on the source bucket
+	srcRequest := new(http.Request)
+	*srcRequest = *r
+	srcRequest.Method = http.MethodGet
+	srcRequest.URL = &url.URL{Path: "/" + srcBucket + srcObject}
+	return iam.authorize(srcRequest, identity, s3_constants.ACTION_READ, srcBucket, srcObject)
 }
 
 func (iam *IdentityAccessManagement) authUser(r *http.Request) (*Identity, s3err.ErrorCode) {
@@ -207,15 +295,13 @@ func (iam *IdentityAccessManagement) authUser(r *http.Request) (*Identity, s3err
 	var s3Err s3err.ErrorCode
 	var found bool
 	switch getRequestAuthType(r) {
-	case authTypeStreamingSigned:
-		return identity, s3err.ErrNone
 	case authTypeUnknown:
 		glog.V(3).Infof("unknown auth type")
 		return identity, s3err.ErrAccessDenied
 	case authTypePresignedV2, authTypeSignedV2:
 		glog.V(3).Infof("v2 auth type")
 		identity, s3Err = iam.isReqAuthenticatedV2(r)
-	case authTypeSigned, authTypePresigned:
+	case authTypeSigned, authTypePresigned, authTypeStreamingSigned:
 		glog.V(3).Infof("v4 auth type")
 		identity, s3Err = iam.reqSignatureV4Verify(r)
 	case authTypePostPolicy:
@@ -265,6 +351,11 @@ func (identity *Identity) canDo(action Action, bucket string) bool {
 	return false
 }
 
+// canBypassGovernance checks whether the identity can bypass the GOVERNANCE object lock retention in the bucket
+func (identity *Identity) canBypassGovernance(bucket string) bool {
+	return identity != nil && identity.sessionPolicy == nil && identity.canDo(s3_constants.ACTION_ADMIN, bucket)
+}
+
 func (identity *Identity) isAdmin() bool {
 	for _, a := range identity.Actions {
 		if a == "Admin" {
@@ -15,6 +15,9 @@ func (s3a *S3ApiServer) subscribeMetaEvents(clientName string, prefix string, la
 	processEventFn := func(resp *filer_pb.SubscribeMetadataResponse) error {
 
 		message := resp.EventNotification
+		if s3a.buckets != nil {
+			s3a.buckets.processEvent(s3a.option.BucketsPath, resp)
+		}
 		if message.NewEntry == nil {
 			return nil
 		}
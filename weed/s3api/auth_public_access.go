@@ -0,0 +1,96 @@
+package s3api
+
+import (
+	"net/http"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+)
+
+/*
+The public buckets are created with the "public-read" or the "public-read-write" canned ACL in the x-amz-acl header,
+or set public by PutBucketAcl, kept in the ExtS3PublicAccessKey extended attribute of the bucket directory.
+
+	public-read          everyone, also without credentials, can list the bucket and read the objects
+	public-read-write    everyone can also upload, overwrite and delete the objects
+
+The objects can also be read by everyone with the "public-read" object ACL, see s3api_acl_handlers.go.
+
+The public access is checked after the bucket policies and the actions of the identities, except after a matched Deny.
+With the blockPublicAccess option, the public access of the buckets and the objects is ignored, and so are the Allow
+statements of the bucket policies for the anonymous requests. The buckets and the objects can not be made public then.
+*/
+
+const (
+	ExtS3PublicAccessKey = "x-seaweedfs-s3-public-access"
+
+	CannedAclPrivate         = "private"
+	CannedAclPublicRead      = "public-read"
+	CannedAclPublicReadWrite = "public-read-write"
+)
+
+var (
+	publicReadActions = map[string]bool{
+		"s3:GetObject":  true,
+		"s3:ListBucket": true,
+	}
+	publicWriteActions = map[string]bool{
+		"s3:PutObject":                  true,
+		"s3:DeleteObject":               true,
+		"s3:AbortMultipartUpload":       true,
+		"s3:ListMultipartUploadParts":   true,
+		"s3:ListBucketMultipartUploads": true,
+	}
+)
+
+// isPublicCannedAcl checks the canned ACL, and returns whether it makes the bucket public
+func isPublicCannedAcl(acl string) (isPublic bool, ok bool) {
+	switch acl {
+	case "", CannedAclPrivate, "authenticated-read", "aws-exec-read", "bucket-owner-read", "bucket-owner-full-control":
+		// only the identities with the actions can access the bucket
+		return false, true
+	case CannedAclPublicRead, CannedAclPublicReadWrite:
+		return true, true
+	}
+	return false, false
+}
+
+// isPublicAction checks whether the public access of the bucket allows the policy action
+func isPublicAction(publicAccess string, action string) bool {
+	switch publicAccess {
+	case CannedAclPublicRead:
+		return publicReadActions[action]
+	case CannedAclPublicReadWrite:
+		return publicReadActions[action] || publicWriteActions[action]
+	}
+	return false
+}
+
+// isAnonymous checks the requests without credentials, with or without the anonymous identity
+func isAnonymous(identity *Identity) bool {
+	return identity == nil || identity.Name == "anonymous"
+}
+
+// isPublicAccessAllowed checks whether the bucket, or the object to read, is public for the request
+func (iam *IdentityAccessManagement) isPublicAccessAllowed(r *http.Request, bucket, object string) bool {
+	if iam.blockPublic || iam.bucketPublicAccess == nil || bucket == "" {
+		return false
+	}
+	publicAccess, err := iam.bucketPublicAccess(bucket)
+	if err != nil {
+		glog.V(1).Infof("bucket %s public access: %v", bucket, err)
+		return false
+	}
+	action := policyActionOf(r, object)
+	if isPublicAction(publicAccess, action) {
+		return true
+	}
+	if action != "s3:GetObject" || iam.objectPublicAccess == nil {
+		return false
+	}
+	objectAccess, err := iam.objectPublicAccess(bucket, object)
+	if err != nil {
+		glog.V(1).Infof("object %s%s public access: %v", bucket, object, err)
+		return false
+	}
+	return objectAccess == CannedAclPublicRead
+}
@@ -0,0 +1,87 @@
+package s3api
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/s3api/policy_engine"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3_constants"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPublicAction(t *testing.T) {
+	assert.True(t, isPublicAction(CannedAclPublicRead, "s3:GetObject"))
+	assert.True(t, isPublicAction(CannedAclPublicRead, "s3:ListBucket"))
+	assert.False(t, isPublicAction(CannedAclPublicRead, "s3:PutObject"))
+	assert.False(t, isPublicAction(CannedAclPublicRead, "s3:GetObjectTagging"))
+	assert.True(t, isPublicAction(CannedAclPublicReadWrite, "s3:PutObject"))
+	assert.True(t, isPublicAction(CannedAclPublicReadWrite, "s3:DeleteObject"))
+	assert.False(t, isPublicAction(CannedAclPublicReadWrite, "s3:PutBucketPolicy"))
+	assert.False(t, isPublicAction("", "s3:GetObject"))
+
+	for acl, expected := range map[string]bool{"": false, "private": false, "bucket-owner-full-control": false, "public-read": true, "public-read-write": true} {
+		isPublic, ok := isPublicCannedAcl(acl)
+		assert.True(t, ok, acl)
+		assert.Equal(t, expected, isPublic, acl)
+	}
+	_, ok := isPublicCannedAcl("public")
+	assert.False(t, ok)
+}
+
+func TestAuthorizePublicAccess(t *testing.T) {
+	policy, err := policy_engine.ParsePolicyDocument([]byte(`{"Version": "2012-10-17", "Statement": [
+		{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::shared/*"}]}`))
+	if err != nil {
+		t.Fatalf("parse policy: %v", err)
+	}
+	iam := newStsTestIam()
+	iam.bucketPolicy = func(bucket string) (*policy_engine.PolicyDocument, error) {
+		if bucket == "shared" {
+			return policy, nil
+		}
+		return nil, nil
+	}
+	iam.bucketPublicAccess = func(bucket string) (string, error) {
+		switch bucket {
+		case "site":
+			return CannedAclPublicRead, nil
+		case "dropbox":
+			return CannedAclPublicReadWrite, nil
+		}
+		return "", nil
+	}
+	iam.objectPublicAccess = func(bucket, object string) (string, error) {
+		if bucket == "private" && object == "/public.html" {
+			return CannedAclPublicRead, nil
+		}
+		return "", nil
+	}
+
+	get := httptest.NewRequest("GET", "/site/index.html", nil)
+	put := httptest.NewRequest("PUT", "/site/index.html", nil)
+	assert.Equal(t, s3err.ErrNone, iam.authorize(get, nil, s3_constants.ACTION_READ, "site", "/index.html"))
+	assert.Equal(t, s3err.ErrAccessDenied, iam.authorize(put, nil, s3_constants.ACTION_WRITE, "site", "/index.html"))
+	assert.Equal(t, s3err.ErrNone, iam.authorize(put, nil, s3_constants.ACTION_WRITE, "dropbox", "/index.html"))
+	assert.Equal(t, s3err.ErrAccessDenied, iam.authorize(get, nil, s3_constants.ACTION_READ, "private", "/index.html"))
+	assert.Equal(t, s3err.ErrNone, iam.authorize(get, nil, s3_constants.ACTION_READ, "shared", "/index.html"))
+
+	// the public objects in the private buckets can be read, but not written
+	getPublic := httptest.NewRequest("GET", "/private/public.html", nil)
+	putPublic := httptest.NewRequest("PUT", "/private/public.html", nil)
+	assert.Equal(t, s3err.ErrNone, iam.authorize(getPublic, nil, s3_constants.ACTION_READ, "private", "/public.html"))
+	assert.Equal(t, s3err.ErrAccessDenied, iam.authorize(putPublic, nil, s3_constants.ACTION_WRITE, "private", "/public.html"))
+	getPublicAcl := httptest.NewRequest("GET", "/private/public.html?acl", nil)
+	assert.Equal(t, s3err.ErrAccessDenied, iam.authorize(getPublicAcl, nil, s3_constants.ACTION_READ, "private", "/public.html"))
+
+	// the identities without the actions can also access the public buckets
+	readOnly := &Identity{Name: "reader", Actions: []Action{"Read"}}
+	assert.Equal(t, s3err.ErrNone, iam.authorize(put, readOnly, s3_constants.ACTION_WRITE, "dropbox", "/index.html"))
+
+	iam.blockPublic = true
+	assert.Equal(t, s3err.ErrAccessDenied, iam.authorize(get, nil, s3_constants.ACTION_READ, "site", "/index.html"))
+	assert.Equal(t, s3err.ErrAccessDenied, iam.authorize(put, nil, s3_constants.ACTION_WRITE, "dropbox", "/index.html"))
+	assert.Equal(t, s3err.ErrAccessDenied, iam.authorize(get, &Identity{Name: "anonymous"}, s3_constants.ACTION_READ, "shared", "/index.html"))
+	assert.Equal(t, s3err.ErrAccessDenied, iam.authorize(getPublic, nil, s3_constants.ACTION_READ, "private", "/public.html"))
+	assert.Equal(t, s3err.ErrNone, iam.authorize(get, readOnly, s3_constants.ACTION_READ, "site", "/index.html"))
+}
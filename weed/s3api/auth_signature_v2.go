@@ -23,8 +23,8 @@ import (
 	"crypto/subtle"
 	"encoding/base64"
 	"fmt"
+	xhttp "github.com/chrislusf/seaweedfs/weed/s3api/http"
 	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
-	"net"
 	"net/http"
 	"net/url"
 	"path"
@@ -69,18 +69,18 @@ func (iam *IdentityAccessManagement) isReqAuthenticatedV2(r *http.Request) (*Ide
 	return iam.doesPresignV2SignatureMatch(r)
 }
 
-func (iam *IdentityAccessManagement) doesPolicySignatureV2Match(formValues http.Header) s3err.ErrorCode {
+func (iam *IdentityAccessManagement) doesPolicySignatureV2Match(formValues http.Header) (*Identity, s3err.ErrorCode) {
 	accessKey := formValues.Get("AWSAccessKeyId")
-	_, cred, found := iam.lookupByAccessKey(accessKey)
-	if !found {
-		return s3err.ErrInvalidAccessKeyID
+	identity, cred, errCode := iam.lookupCredential(accessKey, formValues.Get(xhttp.AmzSecurityToken))
+	if errCode != s3err.ErrNone {
+		return nil, errCode
 	}
 	policy := formValues.Get("Policy")
 	signature := formValues.Get("Signature")
 	if !compareSignatureV2(signature, calculateSignatureV2(policy, cred.SecretKey)) {
-		return s3err.ErrSignatureDoesNotMatch
+		return nil, s3err.ErrSignatureDoesNotMatch
 	}
-	return s3err.ErrNone
+	return identity, s3err.ErrNone
 }
 
 // Authorization = "AWS" + " " + AWSAccessKeyId + ":" + Signature;
@@ -138,9 +138,9 @@ func (iam *IdentityAccessManagement) doesSignV2Match(r *http.Request) (*Identity
 
 	// Access credentials.
 	// Validate if access key id same.
-	ident, cred, found := iam.lookupByAccessKey(accessKey)
-	if !found {
-		return nil, s3err.ErrInvalidAccessKeyID
+	ident, cred, errCode := iam.lookupCredential(accessKey, sessionTokenOf(r))
+	if errCode != s3err.ErrNone {
+		return nil, errCode
 	}
 
 	// r.RequestURI will have raw encoded URI as sent by the client.
@@ -156,7 +156,7 @@ func (iam *IdentityAccessManagement) doesSignV2Match(r *http.Request) (*Identity
 		return nil, s3err.ErrInvalidQueryParams
 	}
 
-	encodedResource, err = getResource(encodedResource, r.Host, iam.domain)
+	encodedResource, err = getResource(encodedResource, r.Host, iam.domains)
 	if err != nil {
 		return nil, s3err.ErrInvalidRequest
 	}
@@ -224,9 +224,9 @@ func (iam *IdentityAccessManagement) doesPresignV2SignatureMatch(r *http.Request
 	}
 
 	// Validate if access key id same.
-	ident, cred, found := iam.lookupByAccessKey(accessKey)
-	if !found {
-		return nil, s3err.ErrInvalidAccessKeyID
+	ident, cred, errCode := iam.lookupCredential(accessKey, sessionTokenOf(r))
+	if errCode != s3err.ErrNone {
+		return nil, errCode
 	}
 
 	// Make sure the request has not expired.
@@ -240,7 +240,7 @@ func (iam *IdentityAccessManagement) doesPresignV2SignatureMatch(r *http.Request
 		return nil, s3err.ErrExpiredPresignRequest
 	}
 
-	encodedResource, err = getResource(encodedResource, r.Host, iam.domain)
+	encodedResource, err = getResource(encodedResource, r.Host, iam.domains)
 	if err != nil {
 		return nil, s3err.ErrInvalidRequest
 	}
@@ -268,22 +268,11 @@ func unescapeQueries(encodedQuery string) (unescapedQueries []string, err error)
 }
 
 // Returns "/bucketName/objectName" for path-style or virtual-host-style requests.
-func getResource(path string, host string, domain string) (string, error) {
-	if domain == "" {
-		return path, nil
-	}
-	// If virtual-host-style is enabled construct the "resource" properly.
-	if strings.Contains(host, ":") {
-		// In bucket.mydomain.com:9000, strip out :9000
-		var err error
-		if host, _, err = net.SplitHostPort(host); err != nil {
-			return "", err
-		}
-	}
-	if !strings.HasSuffix(host, "."+domain) {
+func getResource(path string, host string, domains []string) (string, error) {
+	bucket := bucketOfHost(host, domains)
+	if bucket == "" {
 		return path, nil
 	}
-	bucket := strings.TrimSuffix(host, "."+domain)
 	return "/" + pathJoin(bucket, path), nil
 }
 
@@ -23,6 +23,7 @@ import (
 	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/hex"
+	xhttp "github.com/chrislusf/seaweedfs/weed/s3api/http"
 	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
 	"io/ioutil"
 	"net/http"
@@ -55,6 +56,12 @@ const (
 	// http Header "x-amz-content-sha256" == "UNSIGNED-PAYLOAD" indicates that the
 	// client did not calculate sha256 of the payload.
 	unsignedPayload = "UNSIGNED-PAYLOAD"
+
+	// the aws-chunked payloads with the checksum in the trailer
+	streamingUnsignedPayloadTrailer    = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+	streamingContentSHA256Trailer      = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+	streamingECDSAContentSHA256        = "STREAMING-AWS4-ECDSA-P256-SHA256-PAYLOAD"
+	streamingECDSAContentSHA256Trailer = "STREAMING-AWS4-ECDSA-P256-SHA256-PAYLOAD-TRAILER"
 )
 
 // Returns SHA256 for calculating canonical-request.
@@ -112,9 +119,9 @@ func (iam *IdentityAccessManagement) doesSignatureMatch(hashedPayload string, r
 	}
 
 	// Verify if the access key id matches.
-	identity, cred, found := iam.lookupByAccessKey(signV4Values.Credential.accessKey)
-	if !found {
-		return nil, s3err.ErrInvalidAccessKeyID
+	identity, cred, errCode := iam.lookupCredential(signV4Values.Credential.accessKey, sessionTokenOf(r))
+	if errCode != s3err.ErrNone {
+		return nil, errCode
 	}
 
 	// Extract date, if not present throw error.
@@ -311,17 +318,17 @@ func parseSignature(signElement string) (string, s3err.ErrorCode) {
 // doesPolicySignatureMatch - Verify query headers with post policy
 //     - http://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-HTTPPOSTConstructPolicy.html
 // returns ErrNone if the signature matches.
-func (iam *IdentityAccessManagement) doesPolicySignatureV4Match(formValues http.Header) s3err.ErrorCode {
+func (iam *IdentityAccessManagement) doesPolicySignatureV4Match(formValues http.Header) (*Identity, s3err.ErrorCode) {
 
 	// Parse credential tag.
 	credHeader, err := parseCredentialHeader("Credential=" + formValues.Get("X-Amz-Credential"))
 	if err != s3err.ErrNone {
-		return s3err.ErrMissingFields
+		return nil, s3err.ErrMissingFields
 	}
 
-	_, cred, found := iam.lookupByAccessKey(credHeader.accessKey)
-	if !found {
-		return s3err.ErrInvalidAccessKeyID
+	identity, cred, errCode := iam.lookupCredential(credHeader.accessKey, formValues.Get(xhttp.AmzSecurityToken))
+	if errCode != s3err.ErrNone {
+		return nil, errCode
 	}
 
 	// Get signing key.
@@ -332,11 +339,11 @@ func (iam *IdentityAccessManagement) doesPolicySignatureV4Match(formValues http.
 
 	// Verify signature.
 	if !compareSignatureV4(newSignature, formValues.Get("X-Amz-Signature")) {
-		return s3err.ErrSignatureDoesNotMatch
+		return nil, s3err.ErrSignatureDoesNotMatch
 	}
 
 	// Success.
-	return s3err.ErrNone
+	return identity, s3err.ErrNone
 }
 
 // check query headers with presigned signature
@@ -353,9 +360,9 @@ func (iam *IdentityAccessManagement) doesPresignedSignatureMatch(hashedPayload s
 	}
 
 	// Verify if the access key id matches.
-	identity, cred, found := iam.lookupByAccessKey(pSignValues.Credential.accessKey)
-	if !found {
-		return nil, s3err.ErrInvalidAccessKeyID
+	identity, cred, errCode := iam.lookupCredential(pSignValues.Credential.accessKey, sessionTokenOf(r))
+	if errCode != s3err.ErrNone {
+		return nil, errCode
 	}
 
 	// Extract all the signed headers along with its values.
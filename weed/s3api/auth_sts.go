@@ -0,0 +1,179 @@
+package s3api
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/s3api/policy_engine"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+	"github.com/chrislusf/seaweedfs/weed/security"
+	jwt "github.com/dgrijalva/jwt-go"
+
+	xhttp "github.com/chrislusf/seaweedfs/weed/s3api/http"
+)
+
+/*
+The temporary credentials are issued by AssumeRole and GetSessionToken, without any state kept on the s3 gateways.
+
+The session token is a jwt signed with the "jwt.sts.key" of security.toml, with the access key, the identity
+to act as, the expiration, and the optional session policy. The secret key is derived from the access key
+with the same signing key, so all s3 gateways with the same key validate the temporary credentials.
+
+A request signed with the temporary credentials is authorized as the identity of the session, with the current
+actions of the identity, and further limited by the session policy if any. Removing the identity revokes its sessions.
+*/
+
+const (
+	stsAccessKeyPrefix = "ASIA"
+	stsAccessKeyLength = 20
+	stsAccessKeyChars  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+)
+
+type sessionClaims struct {
+	SessionName string `json:"session,omitempty"`
+	Policy      string `json:"policy,omitempty"`
+	jwt.StandardClaims
+}
+
+type sessionCredential struct {
+	Credential
+	SessionToken string
+	Expiration   time.Time
+}
+
+func (iam *IdentityAccessManagement) isStsEnabled() bool {
+	return iam.isEnabled() && len(iam.stsSigningKey) > 0
+}
+
+// issueSessionCredential creates the temporary credentials acting as the identity
+func (iam *IdentityAccessManagement) issueSessionCredential(identityName, sessionName, policy string, duration time.Duration) (*sessionCredential, error) {
+	accessKey, err := genSessionAccessKey()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	claims := sessionClaims{
+		SessionName: sessionName,
+		Policy:      policy,
+		StandardClaims: jwt.StandardClaims{
+			Id:        accessKey,
+			Subject:   identityName,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(duration).Unix(),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(iam.stsSigningKey))
+	if err != nil {
+		return nil, fmt.Errorf("sign session token: %v", err)
+	}
+	return &sessionCredential{
+		Credential: Credential{
+			AccessKey: accessKey,
+			SecretKey: sessionSecretKey(iam.stsSigningKey, accessKey),
+		},
+		SessionToken: token,
+		Expiration:   time.Unix(claims.ExpiresAt, 0).UTC(),
+	}, nil
+}
+
+// lookupCredential looks up the static credentials, or the temporary credentials with the session token.
+func (iam *IdentityAccessManagement) lookupCredential(accessKey, sessionToken string) (*Identity, *Credential, s3err.ErrorCode) {
+	if sessionToken == "" {
+		identity, cred, found := iam.lookupByAccessKey(accessKey)
+		if !found {
+			return nil, nil, s3err.ErrInvalidAccessKeyID
+		}
+		return identity, cred, s3err.ErrNone
+	}
+	if !iam.isStsEnabled() {
+		return nil, nil, s3err.ErrInvalidToken
+	}
+
+	claims := &sessionClaims{}
+	_, err := jwt.ParseWithClaims(sessionToken, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unknown token method")
+		}
+		return []byte(iam.stsSigningKey), nil
+	})
+	if err != nil {
+		if validationErr, ok := err.(*jwt.ValidationError); ok && validationErr.Errors&jwt.ValidationErrorExpired != 0 {
+			return nil, nil, s3err.ErrExpiredToken
+		}
+		glog.V(1).Infof("invalid session token of %s: %v", accessKey, err)
+		return nil, nil, s3err.ErrInvalidToken
+	}
+	if claims.Id != accessKey {
+		return nil, nil, s3err.ErrInvalidToken
+	}
+	parent, found := iam.lookupByName(claims.Subject)
+	if !found {
+		return nil, nil, s3err.ErrInvalidToken
+	}
+
+	identity := &Identity{
+		Name:              parent.Name,
+		Actions:           parent.Actions,
+		sessionExpiration: time.Unix(claims.ExpiresAt, 0),
+	}
+	if claims.Policy != "" {
+		if identity.sessionPolicy, err = policy_engine.ParsePolicyDocument([]byte(claims.Policy)); err != nil {
+			return nil, nil, s3err.ErrInvalidToken
+		}
+	}
+	return identity, &Credential{
+		AccessKey: accessKey,
+		SecretKey: sessionSecretKey(iam.stsSigningKey, accessKey),
+	}, s3err.ErrNone
+}
+
+func (iam *IdentityAccessManagement) lookupByName(name string) (identity *Identity, found bool) {
+	for _, ident := range iam.identities {
+		if ident.Name == name {
+			return ident, true
+		}
+	}
+	return nil, false
+}
+
+// evalSessionPolicy returns whether the session policy allows the request
+func evalSessionPolicy(r *http.Request, identity *Identity, bucket, object string) bool {
+	if identity == nil || identity.sessionPolicy == nil {
+		return true
+	}
+	return identity.sessionPolicy.Evaluate(policyRequestOf(r, identity, bucket, object)) == policy_engine.DecisionAllow
+}
+
+func (identity *Identity) isSession() bool {
+	return !identity.sessionExpiration.IsZero()
+}
+
+func sessionTokenOf(r *http.Request) string {
+	if token := r.Header.Get(xhttp.AmzSecurityToken); token != "" {
+		return token
+	}
+	return r.URL.Query().Get(xhttp.AmzSecurityToken)
+}
+
+func sessionSecretKey(signingKey security.SigningKey, accessKey string) string {
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write([]byte(accessKey))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))[:40]
+}
+
+func genSessionAccessKey() (string, error) {
+	b := make([]byte, stsAccessKeyLength-len(stsAccessKeyPrefix))
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = stsAccessKeyChars[int(b[i])%len(stsAccessKeyChars)]
+	}
+	return stsAccessKeyPrefix + string(b), nil
+}
@@ -0,0 +1,126 @@
+package s3api
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+	"github.com/stretchr/testify/assert"
+)
+
+func newStsTestIam() *IdentityAccessManagement {
+	return &IdentityAccessManagement{
+		identities: []*Identity{
+			{
+				Name:        "someone",
+				Credentials: []*Credential{{AccessKey: "access_key_1", SecretKey: "secret_key_1"}},
+				Actions:     []Action{"Read", "Write"},
+			},
+			{
+				Name:        "admin",
+				Credentials: []*Credential{{AccessKey: "access_key_2", SecretKey: "secret_key_2"}},
+				Actions:     []Action{"Admin"},
+			},
+		},
+		stsSigningKey: []byte("sts signing key"),
+	}
+}
+
+func TestSessionCredential(t *testing.T) {
+	iam := newStsTestIam()
+
+	cred, err := iam.issueSessionCredential("someone", "", "", time.Hour)
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	assert.True(t, strings.HasPrefix(cred.AccessKey, stsAccessKeyPrefix))
+	assert.Equal(t, stsAccessKeyLength, len(cred.AccessKey))
+
+	identity, found, errCode := iam.lookupCredential(cred.AccessKey, cred.SessionToken)
+	assert.Equal(t, s3err.ErrNone, errCode)
+	assert.Equal(t, "someone", identity.Name)
+	assert.Equal(t, cred.SecretKey, found.SecretKey)
+	assert.True(t, identity.isSession())
+	assert.True(t, identity.canDo("Write", "bucket"))
+
+	// the token of another access key
+	other, _ := iam.issueSessionCredential("someone", "", "", time.Hour)
+	_, _, errCode = iam.lookupCredential(cred.AccessKey, other.SessionToken)
+	assert.Equal(t, s3err.ErrInvalidToken, errCode)
+
+	// signed with another key
+	another := newStsTestIam()
+	another.stsSigningKey = []byte("another key")
+	_, _, errCode = another.lookupCredential(cred.AccessKey, cred.SessionToken)
+	assert.Equal(t, s3err.ErrInvalidToken, errCode)
+
+	expired, _ := iam.issueSessionCredential("someone", "", "", -time.Minute)
+	_, _, errCode = iam.lookupCredential(expired.AccessKey, expired.SessionToken)
+	assert.Equal(t, s3err.ErrExpiredToken, errCode)
+
+	// the removed identities can not use their sessions
+	iam.identities = iam.identities[1:]
+	_, _, errCode = iam.lookupCredential(cred.AccessKey, cred.SessionToken)
+	assert.Equal(t, s3err.ErrInvalidToken, errCode)
+}
+
+func TestSignedWithSessionCredential(t *testing.T) {
+	iam := newStsTestIam()
+	cred, _ := iam.issueSessionCredential("someone", "", "", time.Hour)
+
+	req := mustNewRequest("GET", "http://127.0.0.1:9000/bucket/a.txt", 0, nil, t)
+	req.Header.Set("X-Amz-Security-Token", cred.SessionToken)
+	if err := signRequestV4(req, cred.AccessKey, cred.SecretKey); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	identity, errCode := iam.reqSignatureV4Verify(req)
+	assert.Equal(t, s3err.ErrNone, errCode)
+	assert.Equal(t, "someone", identity.Name)
+
+	// without the session token
+	req = mustNewRequest("GET", "http://127.0.0.1:9000/bucket/a.txt", 0, nil, t)
+	signRequestV4(req, cred.AccessKey, cred.SecretKey)
+	_, errCode = iam.reqSignatureV4Verify(req)
+	assert.Equal(t, s3err.ErrInvalidAccessKeyID, errCode)
+}
+
+func TestSessionPolicy(t *testing.T) {
+	iam := newStsTestIam()
+	policy := `{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/public/*"}]}`
+	cred, _ := iam.issueSessionCredential("admin", "test", policy, time.Hour)
+	identity, _, errCode := iam.lookupCredential(cred.AccessKey, cred.SessionToken)
+	assert.Equal(t, s3err.ErrNone, errCode)
+
+	assert.True(t, evalSessionPolicy(httptest.NewRequest("GET", "/bucket/public/a.txt", nil), identity, "bucket", "/public/a.txt"))
+	assert.False(t, evalSessionPolicy(httptest.NewRequest("GET", "/bucket/private/a.txt", nil), identity, "bucket", "/private/a.txt"))
+	assert.False(t, evalSessionPolicy(httptest.NewRequest("PUT", "/bucket/public/a.txt", nil), identity, "bucket", "/public/a.txt"))
+}
+
+func TestAssumeRoleOf(t *testing.T) {
+	s3a := &S3ApiServer{iam: newStsTestIam()}
+	someone, _ := s3a.iam.lookupByName("someone")
+	admin, _ := s3a.iam.lookupByName("admin")
+
+	for _, c := range []struct {
+		identity *Identity
+		form     url.Values
+		role     string
+		err      bool
+	}{
+		{someone, url.Values{"RoleArn": {"arn:aws:iam::000000000000:role/someone"}, "RoleSessionName": {"s1"}}, "someone", false},
+		{admin, url.Values{"RoleArn": {"someone"}, "RoleSessionName": {"s1"}}, "someone", false},
+		{someone, url.Values{"RoleArn": {"admin"}, "RoleSessionName": {"s1"}}, "", true},
+		{someone, url.Values{"RoleArn": {"nobody"}, "RoleSessionName": {"s1"}}, "", true},
+		{someone, url.Values{"RoleArn": {"someone"}}, "", true},
+		{someone, url.Values{"RoleArn": {"someone"}, "RoleSessionName": {"s1"}, "Policy": {"{}"}}, "", true},
+	} {
+		r := httptest.NewRequest("POST", "/", nil)
+		r.Form = c.form
+		role, _, _, err := s3a.assumeRoleOf(r, c.identity)
+		assert.Equal(t, c.err, err != nil, "%v", c.form)
+		assert.Equal(t, c.role, role)
+	}
+}
@@ -24,10 +24,13 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"github.com/chrislusf/seaweedfs/weed/filer"
 	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
 	"hash"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/dustin/go-humanize"
@@ -85,9 +88,9 @@ func (iam *IdentityAccessManagement) calculateSeedSignature(r *http.Request) (cr
 		return nil, "", "", time.Time{}, errCode
 	}
 	// Verify if the access key id matches.
-	_, cred, found := iam.lookupByAccessKey(signV4Values.Credential.accessKey)
-	if !found {
-		return nil, "", "", time.Time{}, s3err.ErrInvalidAccessKeyID
+	_, cred, errCode = iam.lookupCredential(signV4Values.Credential.accessKey, sessionTokenOf(r))
+	if errCode != s3err.ErrNone {
+		return nil, "", "", time.Time{}, errCode
 	}
 
 	// Verify if region is valid.
@@ -147,15 +150,42 @@ func (iam *IdentityAccessManagement) newSignV4ChunkedReader(req *http.Request) (
 	if errCode != s3err.ErrNone {
 		return nil, errCode
 	}
-	return &s3ChunkedReader{
-		cred:              ident,
+	cr, errCode := newChunkedReader(req)
+	if errCode != s3err.ErrNone {
+		return nil, errCode
+	}
+	cr.cred = ident
+	cr.seedSignature = seedSignature
+	cr.seedDate = seedDate
+	cr.region = region
+	return cr, s3err.ErrNone
+}
+
+// newChunkedReader returns the s3ChunkedReader only decoding the chunks, without verifying the chunk signatures,
+// for the streaming uploads without the identities configured, and the unsigned uploads with the checksum trailer.
+func newChunkedReader(req *http.Request) (*s3ChunkedReader, s3err.ErrorCode) {
+	decodedLength := int64(-1)
+	if v := req.Header.Get("X-Amz-Decoded-Content-Length"); v != "" {
+		var err error
+		if decodedLength, err = strconv.ParseInt(v, 10, 64); err != nil || decodedLength < 0 {
+			return nil, s3err.ErrInvalidRequest
+		}
+	}
+	cr := &s3ChunkedReader{
 		reader:            bufio.NewReader(req.Body),
-		seedSignature:     seedSignature,
-		seedDate:          seedDate,
-		region:            region,
 		chunkSHA256Writer: sha256.New(),
 		state:             readChunkHeader,
-	}, s3err.ErrNone
+		decodedLength:     decodedLength,
+	}
+	if isRequestUnsignedTrailer(req) {
+		algorithm := filer.S3ChecksumTrailerAlgorithm(req.Header)
+		if algorithm == "" {
+			return nil, s3err.ErrInvalidRequest
+		}
+		// the keys are sent to the filer before the upload, and the values after it
+		cr.trailer = http.Header{filer.S3ChecksumHeader(algorithm): nil}
+	}
+	return cr, s3err.ErrNone
 }
 
 // Represents the overall state that is required for decoding a
@@ -172,6 +202,21 @@ type s3ChunkedReader struct {
 	chunkSHA256Writer hash.Hash // Calculates sha256 of chunk data.
 	n                 uint64    // Unread bytes in chunk
 	err               error
+	errCode           s3err.ErrorCode
+	decodedLength     int64 // from X-Amz-Decoded-Content-Length, -1 if not set
+	decoded           int64
+	trailer           http.Header // the checksum declared by X-Amz-Trailer, read after the last chunk
+}
+
+// s3Error returns the s3 error of the failed read, e.g. the chunk signature mismatch
+func (cr *s3ChunkedReader) s3Error() s3err.ErrorCode {
+	if cr.err == nil || cr.err == io.EOF {
+		return s3err.ErrNone
+	}
+	if cr.errCode != s3err.ErrNone {
+		return cr.errCode
+	}
+	return s3err.ErrIncompleteBody
 }
 
 // Read chunk reads the chunk token signature portion.
@@ -242,11 +287,20 @@ func (cr *s3ChunkedReader) Read(buf []byte) (n int, err error) {
 			if cr.err != nil {
 				return 0, cr.err
 			}
+			if cr.decodedLength >= 0 && cr.decoded+int64(cr.n) > cr.decodedLength {
+				cr.err = errMalformedEncoding
+				return 0, cr.err
+			}
 			cr.state = readChunk
 		case readChunkTrailer:
-			cr.err = readCRLF(cr.reader)
+			if cr.lastChunk && cr.trailer != nil {
+				cr.err = cr.readTrailer()
+			} else {
+				cr.err = readCRLF(cr.reader)
+			}
 			if cr.err != nil {
-				return 0, errMalformedEncoding
+				cr.err = errMalformedEncoding
+				return 0, cr.err
 			}
 			cr.state = verifyChunk
 		case readChunk:
@@ -278,6 +332,7 @@ func (cr *s3ChunkedReader) Read(buf []byte) (n int, err error) {
 			buf = buf[n0:]
 			// Update bytes to be read of the current chunk before verifying chunk's signature.
 			cr.n -= uint64(n0)
+			cr.decoded += int64(n0)
 
 			// If we're at the end of a chunk.
 			if cr.n == 0 {
@@ -285,19 +340,27 @@ func (cr *s3ChunkedReader) Read(buf []byte) (n int, err error) {
 				continue
 			}
 		case verifyChunk:
-			// Calculate the hashed chunk.
-			hashedChunk := hex.EncodeToString(cr.chunkSHA256Writer.Sum(nil))
-			// Calculate the chunk signature.
-			newSignature := getChunkSignature(cr.cred.SecretKey, cr.seedSignature, cr.region, cr.seedDate, hashedChunk)
-			if !compareSignatureV4(cr.chunkSignature, newSignature) {
-				// Chunk signature doesn't match we return signature does not match.
-				cr.err = errors.New("chunk signature does not match")
-				return 0, cr.err
+			// Without the credential, the chunks are only decoded.
+			if cr.cred != nil {
+				// Calculate the hashed chunk.
+				hashedChunk := hex.EncodeToString(cr.chunkSHA256Writer.Sum(nil))
+				// Calculate the chunk signature.
+				newSignature := getChunkSignature(cr.cred.SecretKey, cr.seedSignature, cr.region, cr.seedDate, hashedChunk)
+				if !compareSignatureV4(cr.chunkSignature, newSignature) {
+					// Chunk signature doesn't match we return signature does not match.
+					cr.err = errors.New("chunk signature does not match")
+					cr.errCode = s3err.ErrSignatureDoesNotMatch
+					return 0, cr.err
+				}
+				// Newly calculated signature becomes the seed for the next chunk
+				// this follows the chaining.
+				cr.seedSignature = newSignature
 			}
-			// Newly calculated signature becomes the seed for the next chunk
-			// this follows the chaining.
-			cr.seedSignature = newSignature
 			cr.chunkSHA256Writer.Reset()
+			if cr.lastChunk && cr.decodedLength >= 0 && cr.decoded != cr.decodedLength {
+				cr.err = io.ErrUnexpectedEOF
+				return 0, cr.err
+			}
 			if cr.lastChunk {
 				cr.state = eofChunk
 			} else {
@@ -309,6 +372,36 @@ func (cr *s3ChunkedReader) Read(buf []byte) (n int, err error) {
 	}
 }
 
+// readTrailer reads the "<name>:<value>" lines after the last chunk until an empty line,
+// expecting exactly the declared trailer.
+func (cr *s3ChunkedReader) readTrailer() error {
+	var found bool
+	for {
+		line, err := cr.reader.ReadSlice('\n')
+		if err != nil {
+			return err
+		}
+		line = trimTrailingWhitespace(line)
+		if len(line) == 0 {
+			break
+		}
+		colon := bytes.IndexByte(line, ':')
+		if colon < 0 {
+			return errMalformedEncoding
+		}
+		name := http.CanonicalHeaderKey(strings.TrimSpace(string(line[:colon])))
+		if _, declared := cr.trailer[name]; !declared || found {
+			return errMalformedEncoding
+		}
+		cr.trailer.Set(name, strings.TrimSpace(string(line[colon+1:])))
+		found = true
+	}
+	if !found {
+		return errMalformedEncoding
+	}
+	return nil
+}
+
 // readCRLF - check if reader only has '\r\n' CRLF character.
 // returns malformed encoding if it doesn't.
 func readCRLF(reader io.Reader) error {
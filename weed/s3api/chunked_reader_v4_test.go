@@ -0,0 +1,161 @@
+package s3api
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3_constants"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+	"github.com/stretchr/testify/assert"
+)
+
+// newStreamingRequest signs the request with the seed signature, and encodes the chunks with the chained chunk signatures
+func newStreamingRequest(t *testing.T, secretKey string, chunks ...string) *http.Request {
+	decodedLength := 0
+	for _, chunk := range chunks {
+		decodedLength += len(chunk)
+	}
+	r := mustNewRequest("PUT", "http://127.0.0.1:9000/bucket/a.txt", 0, nil, t)
+	r.Header.Set("X-Amz-Content-Sha256", streamingContentSHA256)
+	r.Header.Set("Content-Encoding", "aws-chunked,gzip")
+	r.Header.Set("X-Amz-Decoded-Content-Length", fmt.Sprint(decodedLength))
+	if err := signRequestV4(r, "access_key_1", secretKey); err != nil {
+		t.Fatal(err)
+	}
+
+	auth := r.Header.Get("Authorization")
+	signature := auth[strings.Index(auth, "Signature=")+len("Signature="):]
+	date, _ := time.Parse(iso8601Format, r.Header.Get("X-Amz-Date"))
+	var body bytes.Buffer
+	for _, chunk := range append(chunks, "") {
+		signature = getChunkSignature(secretKey, signature, "us-east-1", date, getSHA256Hash([]byte(chunk)))
+		fmt.Fprintf(&body, "%x;chunk-signature=%s\r\n%s\r\n", len(chunk), signature, chunk)
+	}
+	r.Body = ioutil.NopCloser(&body)
+	r.ContentLength = int64(body.Len())
+	return r
+}
+
+func TestSignV4ChunkedReader(t *testing.T) {
+	iam := newStsTestIam()
+
+	r := newStreamingRequest(t, "secret_key_1", strings.Repeat("a", 8192), "some content")
+	assert.Equal(t, authTypeStreamingSigned, getRequestAuthType(r))
+	identity, errCode := iam.authRequest(r, s3_constants.ACTION_WRITE)
+	assert.Equal(t, s3err.ErrNone, errCode)
+	if assert.NotNil(t, identity) {
+		assert.Equal(t, "someone", identity.Name)
+	}
+
+	reader, errCode := iam.newSignV4ChunkedReader(r)
+	if assert.Equal(t, s3err.ErrNone, errCode) {
+		data, err := ioutil.ReadAll(reader)
+		assert.NoError(t, err)
+		assert.Equal(t, strings.Repeat("a", 8192)+"some content", string(data))
+		assert.Equal(t, s3err.ErrNone, reader.(*s3ChunkedReader).s3Error())
+	}
+
+	// the seed signature with another secret key
+	r = newStreamingRequest(t, "secret_key_2", "some content")
+	_, errCode = iam.authRequest(r, s3_constants.ACTION_WRITE)
+	assert.Equal(t, s3err.ErrSignatureDoesNotMatch, errCode)
+	_, errCode = iam.newSignV4ChunkedReader(r)
+	assert.Equal(t, s3err.ErrSignatureDoesNotMatch, errCode)
+
+	// the identity still needs the permission of the action
+	r = newStreamingRequest(t, "secret_key_1", "some content")
+	_, errCode = iam.authRequest(r, s3_constants.ACTION_ADMIN)
+	assert.Equal(t, s3err.ErrAccessDenied, errCode)
+}
+
+func TestSignV4ChunkedReaderTampered(t *testing.T) {
+	iam := newStsTestIam()
+
+	r := newStreamingRequest(t, "secret_key_1", "some content", "more content")
+	body, _ := ioutil.ReadAll(r.Body)
+	r.Body = ioutil.NopCloser(bytes.NewReader(bytes.Replace(body, []byte("more"), []byte("MORE"), 1)))
+	reader, errCode := iam.newSignV4ChunkedReader(r)
+	if assert.Equal(t, s3err.ErrNone, errCode) {
+		_, err := ioutil.ReadAll(reader)
+		assert.Error(t, err)
+		assert.Equal(t, s3err.ErrSignatureDoesNotMatch, reader.(*s3ChunkedReader).s3Error())
+	}
+
+	// fewer bytes than X-Amz-Decoded-Content-Length
+	r = newStreamingRequest(t, "secret_key_1", "some content")
+	r.Header.Set("X-Amz-Decoded-Content-Length", "100")
+	cr, errCode := newChunkedReader(r)
+	if assert.Equal(t, s3err.ErrNone, errCode) {
+		_, err := ioutil.ReadAll(cr)
+		assert.Error(t, err)
+		assert.Equal(t, s3err.ErrIncompleteBody, cr.s3Error())
+	}
+
+	// truncated
+	r = newStreamingRequest(t, "secret_key_1", "some content")
+	body, _ = ioutil.ReadAll(r.Body)
+	r.Body = ioutil.NopCloser(bytes.NewReader(body[:20]))
+	cr, _ = newChunkedReader(r)
+	_, err := ioutil.ReadAll(cr)
+	assert.Error(t, err)
+	assert.Equal(t, s3err.ErrIncompleteBody, cr.s3Error())
+}
+
+func TestChunkedReaderWithoutIdentities(t *testing.T) {
+	// the chunks are decoded, without verifying the signatures
+	r := newStreamingRequest(t, "any_secret_key", "some content", "more content")
+	cr, errCode := newChunkedReader(r)
+	if assert.Equal(t, s3err.ErrNone, errCode) {
+		data, err := ioutil.ReadAll(cr)
+		assert.NoError(t, err)
+		assert.Equal(t, "some contentmore content", string(data))
+	}
+
+	r.Header.Set("X-Amz-Decoded-Content-Length", "-1")
+	_, errCode = newChunkedReader(r)
+	assert.Equal(t, s3err.ErrInvalidRequest, errCode)
+}
+
+// newTrailerRequest encodes the chunks without the chunk signatures, followed by the trailer lines
+func newTrailerRequest(t *testing.T, trailer string, chunks ...string) *http.Request {
+	r := mustNewRequest("PUT", "http://127.0.0.1:9000/bucket/a.txt", 0, nil, t)
+	r.Header.Set("X-Amz-Content-Sha256", streamingUnsignedPayloadTrailer)
+	r.Header.Set("Content-Encoding", "aws-chunked")
+	r.Header.Set("X-Amz-Trailer", "x-amz-checksum-crc32")
+	var body bytes.Buffer
+	for _, chunk := range chunks {
+		fmt.Fprintf(&body, "%x\r\n%s\r\n", len(chunk), chunk)
+	}
+	fmt.Fprintf(&body, "0\r\n%s\r\n", trailer)
+	r.Body = ioutil.NopCloser(&body)
+	return r
+}
+
+func TestChunkedReaderWithTrailer(t *testing.T) {
+	r := newTrailerRequest(t, "x-amz-checksum-crc32:AAAAAA==\r\n", "some content", "more content")
+	cr, errCode := newChunkedReader(r)
+	if assert.Equal(t, s3err.ErrNone, errCode) {
+		data, err := ioutil.ReadAll(cr)
+		assert.NoError(t, err)
+		assert.Equal(t, "some contentmore content", string(data))
+		assert.Equal(t, "AAAAAA==", cr.trailer.Get("X-Amz-Checksum-Crc32"))
+	}
+
+	// not the declared trailer, or missing
+	for _, trailer := range []string{"x-amz-checksum-sha1:AAAAAA==\r\n", "", "x-amz-checksum-crc32\r\n"} {
+		cr, _ = newChunkedReader(newTrailerRequest(t, trailer, "some content"))
+		_, err := ioutil.ReadAll(cr)
+		assert.Equal(t, errMalformedEncoding, err, trailer)
+	}
+
+	// no trailer declared
+	r = newTrailerRequest(t, "", "some content")
+	r.Header.Del("X-Amz-Trailer")
+	_, errCode = newChunkedReader(r)
+	assert.Equal(t, s3err.ErrInvalidRequest, errCode)
+}
@@ -4,7 +4,9 @@ import (
 	"encoding/xml"
 	"fmt"
 	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+	"math"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -16,6 +18,7 @@ import (
 	"github.com/chrislusf/seaweedfs/weed/filer"
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	xhttp "github.com/chrislusf/seaweedfs/weed/s3api/http"
 )
 
 type InitiateMultipartUploadResult struct {
@@ -23,7 +26,7 @@ type InitiateMultipartUploadResult struct {
 	s3.CreateMultipartUploadOutput
 }
 
-func (s3a *S3ApiServer) createMultipartUpload(input *s3.CreateMultipartUploadInput) (output *InitiateMultipartUploadResult, code s3err.ErrorCode) {
+func (s3a *S3ApiServer) createMultipartUpload(input *s3.CreateMultipartUploadInput, checksumAlgorithm string) (output *InitiateMultipartUploadResult, code s3err.ErrorCode) {
 
 	glog.V(2).Infof("createMultipartUpload input %v", input)
 
@@ -35,6 +38,40 @@ func (s3a *S3ApiServer) createMultipartUpload(input *s3.CreateMultipartUploadInp
 			entry.Extended = make(map[string][]byte)
 		}
 		entry.Extended["key"] = []byte(*input.Key)
+		// the tags of the completed object
+		if input.Tagging != nil {
+			tags, _ := ParseTagsHeader(*input.Tagging)
+			for k, v := range tags {
+				entry.Extended[S3TAG_PREFIX+k] = []byte(v)
+			}
+		}
+		// the server side encryption of the parts
+		if input.ServerSideEncryption != nil {
+			entry.Extended[filer.SseAlgorithmHeader] = []byte(*input.ServerSideEncryption)
+		}
+		if input.SSEKMSKeyId != nil {
+			entry.Extended[filer.SseKmsKeyIdHeader] = []byte(*input.SSEKMSKeyId)
+		}
+		if input.SSECustomerKeyMD5 != nil {
+			entry.Extended[filer.SseCustomerAlgorithmHeader] = []byte(*input.SSECustomerAlgorithm)
+			entry.Extended[filer.SseCustomerKeyMD5Header] = []byte(*input.SSECustomerKeyMD5)
+		}
+		// the object lock of the completed object
+		if input.ObjectLockMode != nil {
+			entry.Extended[filer.ExtObjectLockModeKey] = []byte(*input.ObjectLockMode)
+			entry.Extended[filer.ExtObjectLockRetainUntilKey] = []byte(input.ObjectLockRetainUntilDate.UTC().Format(time.RFC3339))
+		}
+		// the public access of the completed object
+		if input.ACL != nil {
+			entry.Extended[xhttp.AmzCannedAcl] = []byte(*input.ACL)
+		}
+		if input.ObjectLockLegalHoldStatus != nil {
+			entry.Extended[xhttp.AmzObjectLockLegalHold] = []byte(*input.ObjectLockLegalHoldStatus)
+		}
+		// the checksums of the parts
+		if checksumAlgorithm != "" {
+			entry.Extended[xhttp.AmzChecksumAlgorithm] = []byte(checksumAlgorithm)
+		}
 	}); err != nil {
 		glog.Errorf("NewMultipartUpload error: %v", err)
 		return nil, s3err.ErrInternalError
@@ -54,6 +91,7 @@ func (s3a *S3ApiServer) createMultipartUpload(input *s3.CreateMultipartUploadInp
 type CompleteMultipartUploadResult struct {
 	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CompleteMultipartUploadResult"`
 	s3.CompleteMultipartUploadOutput
+	s3Checksums
 }
 
 func (s3a *S3ApiServer) completeMultipartUpload(input *s3.CompleteMultipartUploadInput) (output *CompleteMultipartUploadResult, code s3err.ErrorCode) {
@@ -70,9 +108,21 @@ func (s3a *S3ApiServer) completeMultipartUpload(input *s3.CompleteMultipartUploa
 
 	var finalParts []*filer_pb.FileChunk
 	var offset int64
+	var sseExtended map[string][]byte
+	var parts []*filer_pb.Entry
 
 	for _, entry := range entries {
 		if strings.HasSuffix(entry.Name, ".part") && !entry.IsDirectory {
+			parts = append(parts, entry)
+			// the encrypted cipher keys of the chunks are kept, so all parts should share the data key or the customer key
+			extended := sseExtendedOf(entry)
+			if sseExtended == nil {
+				sseExtended = extended
+			} else if string(sseExtended[filer.ExtSseDataKeyKey]) != string(extended[filer.ExtSseDataKeyKey]) ||
+				string(sseExtended[filer.SseCustomerKeyMD5Header]) != string(extended[filer.SseCustomerKeyMD5Header]) {
+				glog.Errorf("completeMultipartUpload %s %s: parts with different encryption", *input.Bucket, *input.UploadId)
+				return nil, s3err.ErrInvalidPart
+			}
 			for _, chunk := range entry.Chunks {
 				p := &filer_pb.FileChunk{
 					FileId:    chunk.GetFileIdString(),
@@ -103,20 +153,56 @@ func (s3a *S3ApiServer) completeMultipartUpload(input *s3.CompleteMultipartUploa
 		dirName = dirName[:len(dirName)-1]
 	}
 
-	err = s3a.mkFile(dirName, entryName, finalParts)
+	request := filer_pb.NewMkFileRequest(dirName, entryName, finalParts)
+	request.Entry.Extended = map[string][]byte{
+		filer.ExtS3PartsCountKey: []byte(strconv.Itoa(len(parts))),
+	}
+	isLegalHold := false
+	var checksums s3Checksums
+	if uploadEntry, lookupErr := s3a.getEntry(s3a.genUploadsFolder(*input.Bucket), *input.UploadId); lookupErr == nil {
+		isLegalHold = string(uploadEntry.Extended[xhttp.AmzObjectLockLegalHold]) == LegalHoldOn
+		if algorithm := string(uploadEntry.Extended[xhttp.AmzChecksumAlgorithm]); algorithm != "" {
+			checksum, errCode := compositeChecksumOf(algorithm, parts)
+			if errCode != s3err.ErrNone {
+				glog.Errorf("completeMultipartUpload %s %s: parts without the %s checksums", *input.Bucket, *input.UploadId, algorithm)
+				return nil, errCode
+			}
+			request.Entry.Extended[filer.S3ChecksumHeader(algorithm)] = []byte(checksum)
+			checksums = newS3Checksums(algorithm, checksum)
+		}
+		for k, v := range uploadEntry.Extended {
+			if strings.HasPrefix(k, S3TAG_PREFIX) || k == filer.ExtObjectLockModeKey || k == filer.ExtObjectLockRetainUntilKey ||
+				k == xhttp.AmzCannedAcl {
+				request.Entry.Extended[k] = v
+			}
+		}
+	}
+
+	for k, v := range sseExtended {
+		request.Entry.Extended[k] = v
+	}
+
+	err = s3a.entryBatcher.CreateEntry(request)
 
 	if err != nil {
 		glog.Errorf("completeMultipartUpload %s/%s error: %v", dirName, entryName, err)
-		return nil, s3err.ErrInternalError
+		return nil, filerErrorToS3Error(err.Error())
+	}
+
+	if isLegalHold {
+		if errCode := s3a.setLegalHold(dirName+"/"+entryName, true); errCode != s3err.ErrNone {
+			glog.Errorf("completeMultipartUpload %s/%s legal hold: %v", dirName, entryName, errCode)
+			return nil, errCode
+		}
 	}
 
 	output = &CompleteMultipartUploadResult{
 		CompleteMultipartUploadOutput: s3.CompleteMultipartUploadOutput{
-			Location: aws.String(fmt.Sprintf("http://%s%s/%s", s3a.option.Filer, dirName, entryName)),
-			Bucket:   input.Bucket,
-			ETag:     aws.String("\"" + filer.ETagChunks(finalParts) + "\""),
-			Key:      objectKey(input.Key),
+			Bucket: input.Bucket,
+			ETag:   aws.String("\"" + filer.ETagChunks(finalParts) + "\""),
+			Key:    objectKey(input.Key),
 		},
+		s3Checksums: checksums,
 	}
 
 	if err = s3a.rm(s3a.genUploadsFolder(*input.Bucket), *input.UploadId, false, true); err != nil {
@@ -126,6 +212,35 @@ func (s3a *S3ApiServer) completeMultipartUpload(input *s3.CompleteMultipartUploa
 	return
 }
 
+// compositeChecksumOf is the checksum of the multipart object, from the checksums of all the parts in order
+func compositeChecksumOf(algorithm string, parts []*filer_pb.Entry) (string, s3err.ErrorCode) {
+	var partChecksums []string
+	for _, part := range parts {
+		checksum, found := part.Extended[filer.S3ChecksumHeader(algorithm)]
+		if !found {
+			return "", s3err.ErrInvalidPart
+		}
+		partChecksums = append(partChecksums, string(checksum))
+	}
+	composite, err := filer.CompositeS3Checksum(algorithm, partChecksums)
+	if err != nil {
+		glog.V(1).Infof("composite checksum: %v", err)
+		return "", s3err.ErrInvalidPart
+	}
+	return composite, s3err.ErrNone
+}
+
+// sseExtendedOf returns the server side encryption of the part, empty if not encrypted
+func sseExtendedOf(entry *filer_pb.Entry) map[string][]byte {
+	extended := make(map[string][]byte)
+	for _, k := range []string{filer.SseAlgorithmHeader, filer.SseKmsKeyIdHeader, filer.ExtSseDataKeyKey, filer.SseCustomerAlgorithmHeader, filer.SseCustomerKeyMD5Header} {
+		if v, found := entry.Extended[k]; found {
+			extended[k] = v
+		}
+	}
+	return extended
+}
+
 func (s3a *S3ApiServer) abortMultipartUpload(input *s3.AbortMultipartUploadInput) (output *s3.AbortMultipartUploadOutput, code s3err.ErrorCode) {
 
 	glog.V(2).Infof("abortMultipartUpload input %v", input)
@@ -161,6 +276,7 @@ type ListMultipartUploadsResult struct {
 	Prefix             *string               `type:"string"`
 	UploadIdMarker     *string               `type:"string"`
 	Upload             []*s3.MultipartUpload `locationName:"Upload" type:"list" flattened:"true"`
+	CommonPrefixes     []PrefixEntry         `xml:"CommonPrefixes,omitempty"`
 }
 
 func (s3a *S3ApiServer) listMultipartUploads(input *s3.ListMultipartUploadsInput) (output *ListMultipartUploadsResult, code s3err.ErrorCode) {
@@ -169,43 +285,115 @@ func (s3a *S3ApiServer) listMultipartUploads(input *s3.ListMultipartUploadsInput
 	glog.V(2).Infof("listMultipartUploads input %v", input)
 
 	output = &ListMultipartUploadsResult{
-		Bucket:       input.Bucket,
-		Delimiter:    input.Delimiter,
-		EncodingType: input.EncodingType,
-		KeyMarker:    input.KeyMarker,
-		MaxUploads:   input.MaxUploads,
-		Prefix:       input.Prefix,
+		Bucket:         input.Bucket,
+		Delimiter:      input.Delimiter,
+		EncodingType:   input.EncodingType,
+		KeyMarker:      input.KeyMarker,
+		MaxUploads:     input.MaxUploads,
+		Prefix:         input.Prefix,
+		UploadIdMarker: input.UploadIdMarker,
 	}
 
-	entries, isLast, err := s3a.list(s3a.genUploadsFolder(*input.Bucket), "", *input.UploadIdMarker, false, uint32(*input.MaxUploads))
+	// the upload folders are named by the upload ids, so all of them are listed to sort by the keys
+	entries, _, err := s3a.list(s3a.genUploadsFolder(*input.Bucket), "", "", false, math.MaxInt32)
 	if err != nil {
 		glog.Errorf("listMultipartUploads %s error: %v", *input.Bucket, err)
 		return
 	}
-	output.IsTruncated = aws.Bool(!isLast)
 
+	var uploads []*s3.MultipartUpload
 	for _, entry := range entries {
-		if entry.Extended != nil {
-			key := string(entry.Extended["key"])
-			if *input.KeyMarker != "" && *input.KeyMarker != key {
-				continue
-			}
-			if *input.Prefix != "" && !strings.HasPrefix(key, *input.Prefix) {
-				continue
-			}
-			output.Upload = append(output.Upload, &s3.MultipartUpload{
-				Key:      objectKey(aws.String(key)),
-				UploadId: aws.String(entry.Name),
-			})
-			if !isLast {
-				output.NextUploadIdMarker = aws.String(entry.Name)
-			}
+		if !entry.IsDirectory || entry.Extended == nil {
+			continue
+		}
+		upload := &s3.MultipartUpload{
+			Key:          objectKey(aws.String(string(entry.Extended["key"]))),
+			UploadId:     aws.String(entry.Name),
+			StorageClass: aws.String("STANDARD"),
 		}
+		if entry.Attributes != nil {
+			upload.Initiated = aws.Time(time.Unix(entry.Attributes.Crtime, 0).UTC())
+		}
+		uploads = append(uploads, upload)
 	}
 
+	paginateMultipartUploads(output, uploads)
+
 	return
 }
 
+// paginateMultipartUploads sets the uploads after the markers, in the order of the keys and the initiation times,
+// with the keys containing the delimiter after the prefix grouped as the common prefixes
+func paginateMultipartUploads(output *ListMultipartUploadsResult, uploads []*s3.MultipartUpload) {
+	sort.SliceStable(uploads, func(i, j int) bool {
+		if *uploads[i].Key != *uploads[j].Key {
+			return *uploads[i].Key < *uploads[j].Key
+		}
+		ti, tj := aws.TimeValue(uploads[i].Initiated), aws.TimeValue(uploads[j].Initiated)
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return *uploads[i].UploadId < *uploads[j].UploadId
+	})
+
+	prefix, delimiter := aws.StringValue(output.Prefix), aws.StringValue(output.Delimiter)
+	keyMarker, uploadIdMarker := aws.StringValue(output.KeyMarker), aws.StringValue(output.UploadIdMarker)
+	maxUploads := int(aws.Int64Value(output.MaxUploads))
+
+	start := 0
+	if keyMarker != "" {
+		start = sort.Search(len(uploads), func(i int) bool { return *uploads[i].Key > keyMarker })
+		if uploadIdMarker != "" {
+			for i, upload := range uploads {
+				if *upload.Key == keyMarker && *upload.UploadId == uploadIdMarker {
+					start = i + 1
+					break
+				}
+			}
+		}
+	}
+
+	output.Upload, output.CommonPrefixes = nil, nil
+	output.IsTruncated = aws.Bool(false)
+	count := 0
+	for _, upload := range uploads[start:] {
+		key := *upload.Key
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		commonPrefix := ""
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				commonPrefix = key[:len(prefix)+i+len(delimiter)]
+			}
+		}
+		if commonPrefix != "" && (commonPrefix == keyMarker || strings.HasPrefix(keyMarker, commonPrefix)) {
+			// already listed in the previous pages
+			continue
+		}
+		if commonPrefix != "" && len(output.CommonPrefixes) > 0 && output.CommonPrefixes[len(output.CommonPrefixes)-1].Prefix == commonPrefix {
+			continue
+		}
+		if count >= maxUploads {
+			output.IsTruncated = aws.Bool(true)
+			break
+		}
+		count++
+		if commonPrefix != "" {
+			output.CommonPrefixes = append(output.CommonPrefixes, PrefixEntry{Prefix: commonPrefix})
+			output.NextKeyMarker = aws.String(commonPrefix)
+			output.NextUploadIdMarker = nil
+		} else {
+			output.Upload = append(output.Upload, upload)
+			output.NextKeyMarker = upload.Key
+			output.NextUploadIdMarker = upload.UploadId
+		}
+	}
+	if !*output.IsTruncated {
+		output.NextKeyMarker, output.NextUploadIdMarker = nil, nil
+	}
+}
+
 type ListPartsResult struct {
 	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListPartsResult"`
 
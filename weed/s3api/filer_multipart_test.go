@@ -1,8 +1,10 @@
 package s3api
 
 import (
+	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
 	"testing"
 	"time"
 )
@@ -47,3 +49,63 @@ func TestListPartsResult(t *testing.T) {
 	}
 
 }
+
+func TestPaginateMultipartUploads(t *testing.T) {
+
+	now := time.Now()
+	newUploads := func() []*s3.MultipartUpload {
+		var uploads []*s3.MultipartUpload
+		for i, key := range []string{"b.txt", "a.txt", "logs/2.log", "logs/1.log", "a.txt"} {
+			uploads = append(uploads, &s3.MultipartUpload{
+				Key:       aws.String(key),
+				UploadId:  aws.String(fmt.Sprintf("u%d", i)),
+				Initiated: aws.Time(now.Add(time.Duration(-i) * time.Minute)),
+			})
+		}
+		return uploads
+	}
+	listed := func(output *ListMultipartUploadsResult) (result []string) {
+		for _, upload := range output.Upload {
+			result = append(result, *upload.Key+"/"+*upload.UploadId)
+		}
+		for _, prefix := range output.CommonPrefixes {
+			result = append(result, prefix.Prefix)
+		}
+		return
+	}
+
+	// sorted by the keys and the initiation times
+	output := &ListMultipartUploadsResult{MaxUploads: aws.Int64(3)}
+	paginateMultipartUploads(output, newUploads())
+	assert.Equal(t, []string{"a.txt/u4", "a.txt/u1", "b.txt/u0"}, listed(output))
+	assert.True(t, *output.IsTruncated)
+	assert.Equal(t, "b.txt", *output.NextKeyMarker)
+	assert.Equal(t, "u0", *output.NextUploadIdMarker)
+
+	// the next page
+	output = &ListMultipartUploadsResult{MaxUploads: aws.Int64(3), KeyMarker: output.NextKeyMarker, UploadIdMarker: output.NextUploadIdMarker}
+	paginateMultipartUploads(output, newUploads())
+	assert.Equal(t, []string{"logs/1.log/u3", "logs/2.log/u2"}, listed(output))
+	assert.False(t, *output.IsTruncated)
+	assert.Nil(t, output.NextKeyMarker)
+
+	// in the middle of the uploads of one key
+	output = &ListMultipartUploadsResult{MaxUploads: aws.Int64(10), KeyMarker: aws.String("a.txt"), UploadIdMarker: aws.String("u4")}
+	paginateMultipartUploads(output, newUploads())
+	assert.Equal(t, []string{"a.txt/u1", "b.txt/u0", "logs/1.log/u3", "logs/2.log/u2"}, listed(output))
+
+	// the key marker without the upload id marker
+	output = &ListMultipartUploadsResult{MaxUploads: aws.Int64(10), KeyMarker: aws.String("a.txt")}
+	paginateMultipartUploads(output, newUploads())
+	assert.Equal(t, []string{"b.txt/u0", "logs/1.log/u3", "logs/2.log/u2"}, listed(output))
+
+	// grouped by the delimiter
+	output = &ListMultipartUploadsResult{MaxUploads: aws.Int64(10), Delimiter: aws.String("/")}
+	paginateMultipartUploads(output, newUploads())
+	assert.Equal(t, []string{"a.txt/u4", "a.txt/u1", "b.txt/u0", "logs/"}, listed(output))
+
+	// with the prefix
+	output = &ListMultipartUploadsResult{MaxUploads: aws.Int64(10), Prefix: aws.String("logs/"), Delimiter: aws.String("/")}
+	paginateMultipartUploads(output, newUploads())
+	assert.Equal(t, []string{"logs/1.log/u3", "logs/2.log/u2"}, listed(output))
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
 	"github.com/chrislusf/seaweedfs/weed/util"
 	"strings"
+	"time"
 )
 
 func (s3a *S3ApiServer) mkdir(parentDirectoryPath string, dirName string, fn func(entry *filer_pb.Entry)) error {
@@ -73,6 +74,54 @@ func doDeleteEntry(client filer_pb.SeaweedFilerClient, parentDirectoryPath strin
 	return nil
 }
 
+const bulkDeleteJobTimeout = 5 * time.Minute
+
+// doBulkDeleteEntries deletes the files or empty folders with a filer bulk delete job,
+// and returns the failed paths with the errors.
+func doBulkDeleteEntries(client filer_pb.SeaweedFilerClient, paths []string) (failures map[string]string, err error) {
+	resp, err := client.BulkDelete(context.Background(), &filer_pb.BulkDeleteRequest{
+		Paths:        paths,
+		IsDeleteData: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bulk delete %d entries: %v", len(paths), err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("bulk delete %d entries: %v", len(paths), resp.Error)
+	}
+
+	deadline := time.Now().Add(bulkDeleteJobTimeout)
+	for {
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("bulk delete job %s: not completed in %v", resp.JobId, bulkDeleteJobTimeout)
+		}
+		jobResp, err := client.GetBulkDeleteJob(context.Background(), &filer_pb.GetBulkDeleteJobRequest{
+			JobId:       resp.JobId,
+			WaitSeconds: 10,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("bulk delete job %s: %v", resp.JobId, err)
+		}
+		if jobResp.Error != "" {
+			return nil, fmt.Errorf("bulk delete job %s: %v", resp.JobId, jobResp.Error)
+		}
+		if jobResp.Job.CompletedAtNs == 0 {
+			// in case the filer returns without waiting
+			time.Sleep(time.Second)
+			continue
+		}
+		failures = make(map[string]string)
+		for _, failure := range jobResp.Job.Failures {
+			failures[failure.Path] = failure.Error
+		}
+		return failures, nil
+	}
+}
+
+func (s3a *S3ApiServer) objectFullPath(bucket, object string) string {
+	return fmt.Sprintf("%s/%s/%s", s3a.option.BucketsPath, bucket, strings.Trim(object, "/"))
+}
+
 func (s3a *S3ApiServer) exists(parentDirectoryPath string, entryName string, isDirectory bool) (exists bool, err error) {
 
 	return filer_pb.Exists(s3a, parentDirectoryPath, entryName, isDirectory)
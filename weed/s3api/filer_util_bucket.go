@@ -0,0 +1,61 @@
+package s3api
+
+import (
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+)
+
+// getBucketExtended returns the extended attribute of the bucket directory, nil if not set.
+func (s3a *S3ApiServer) getBucketExtended(bucket, key string) ([]byte, s3err.ErrorCode) {
+	entry, err := s3a.getBucketEntry(bucket)
+	if err == filer_pb.ErrNotFound || err == nil && entry == nil {
+		return nil, s3err.ErrNoSuchBucket
+	}
+	if err != nil {
+		glog.Errorf("get bucket %s: %v", bucket, err)
+		return nil, s3err.ErrInternalError
+	}
+	return entry.Extended[key], s3err.ErrNone
+}
+
+// setBucketExtended sets the extended attribute of the bucket directory, or removes it if the value is nil.
+func (s3a *S3ApiServer) setBucketExtended(bucket, key string, value []byte) s3err.ErrorCode {
+	var entry *filer_pb.Entry
+	err := s3a.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+		resp, err := filer_pb.LookupEntry(client, &filer_pb.LookupDirectoryEntryRequest{
+			Directory: s3a.option.BucketsPath,
+			Name:      bucket,
+		})
+		if err != nil {
+			return err
+		}
+		entry = resp.Entry
+		if value == nil {
+			if _, found := entry.Extended[key]; !found {
+				return nil
+			}
+			delete(entry.Extended, key)
+		} else {
+			if entry.Extended == nil {
+				entry.Extended = make(map[string][]byte)
+			}
+			entry.Extended[key] = value
+		}
+		return filer_pb.UpdateEntry(client, &filer_pb.UpdateEntryRequest{
+			Directory: s3a.option.BucketsPath,
+			Entry:     entry,
+		})
+	})
+	if err == filer_pb.ErrNotFound {
+		return s3err.ErrNoSuchBucket
+	}
+	if err != nil {
+		glog.Errorf("update bucket %s: %v", bucket, err)
+		return s3err.ErrInternalError
+	}
+	if s3a.buckets != nil {
+		s3a.buckets.set(entry)
+	}
+	return s3err.ErrNone
+}
@@ -27,6 +27,37 @@ const (
 	// S3 object tagging
 	AmzObjectTagging = "X-Amz-Tagging"
 	AmzTagCount      = "x-amz-tagging-count"
+	AmzTagDirective  = "X-Amz-Tagging-Directive"
+
+	// S3 copy source
+	AmzCopySource                  = "X-Amz-Copy-Source"
+	AmzCopySourceRange             = "X-Amz-Copy-Source-Range"
+	AmzCopySourceIfMatch           = "X-Amz-Copy-Source-If-Match"
+	AmzCopySourceIfNoneMatch       = "X-Amz-Copy-Source-If-None-Match"
+	AmzCopySourceIfModifiedSince   = "X-Amz-Copy-Source-If-Modified-Since"
+	AmzCopySourceIfUnmodifiedSince = "X-Amz-Copy-Source-If-Unmodified-Since"
+
+	AmzCopySourceSseCustomerAlgorithm = "X-Amz-Copy-Source-Server-Side-Encryption-Customer-Algorithm"
+	AmzCopySourceSseCustomerKey       = "X-Amz-Copy-Source-Server-Side-Encryption-Customer-Key"
+	AmzCopySourceSseCustomerKeyMD5    = "X-Amz-Copy-Source-Server-Side-Encryption-Customer-Key-Md5"
+
+	// the session token of the temporary credentials
+	AmzSecurityToken = "X-Amz-Security-Token"
+
+	// S3 object lock
+	AmzObjectLockMode            = "X-Amz-Object-Lock-Mode"
+	AmzObjectLockRetainUntilDate = "X-Amz-Object-Lock-Retain-Until-Date"
+	AmzObjectLockLegalHold       = "X-Amz-Object-Lock-Legal-Hold"
+	AmzBypassGovernanceRetention = "X-Amz-Bypass-Governance-Retention"
+	AmzBucketObjectLockEnabled   = "X-Amz-Bucket-Object-Lock-Enabled"
+
+	// the canned ACL
+	AmzCannedAcl = "X-Amz-Acl"
+
+	// the additional checksums
+	AmzChecksumAlgorithm = "X-Amz-Checksum-Algorithm"
+	AmzChecksumMode      = "X-Amz-Checksum-Mode"
+	AmzObjectAttributes  = "X-Amz-Object-Attributes"
 )
 
 // Non-Standard S3 HTTP request constants
@@ -45,6 +45,19 @@ var startsWithConds = map[string]bool{
 	"$x-amz-date":              false,
 }
 
+// the form fields not required to be in the policy conditions
+var exemptFormFields = map[string]bool{
+	"Policy":          true,
+	"X-Amz-Signature": true,
+	"Signature":       true,
+	"Awsaccesskeyid":  true,
+	"File":            true,
+	"Bucket":          true, // the bucket is from the url
+}
+
+// ErrPolicyExpired is returned when the expiration of the policy has passed
+var ErrPolicyExpired = errors.New("Invalid according to Policy: Policy expired")
+
 // Add policy conditionals.
 const (
 	policyCondEqual         = "eq"
@@ -220,7 +233,7 @@ func checkPolicyCond(op string, input1, input2 string) bool {
 func CheckPostPolicy(formValues http.Header, postPolicyForm PostPolicyForm) error {
 	// Check if policy document expiry date is still not reached
 	if !postPolicyForm.Expiration.After(time.Now().UTC()) {
-		return fmt.Errorf("Invalid according to Policy: Policy expired")
+		return ErrPolicyExpired
 	}
 	// map to store the metadata
 	metaMap := make(map[string]string)
@@ -272,5 +285,21 @@ func CheckPostPolicy(formValues http.Header, postPolicyForm PostPolicyForm) erro
 		}
 	}
 
+	return checkExtraFormFields(formValues, postPolicyForm)
+}
+
+// checkExtraFormFields makes sure each form field appears in the policy conditions,
+// except the exempt ones and those with the x-ignore- prefix.
+func checkExtraFormFields(formValues http.Header, postPolicyForm PostPolicyForm) error {
+	conditions := make(map[string]bool)
+	for _, policy := range postPolicyForm.Conditions.Policies {
+		conditions[http.CanonicalHeaderKey(strings.TrimPrefix(policy.Key, "$"))] = true
+	}
+	for key := range formValues {
+		if exemptFormFields[key] || strings.HasPrefix(key, "X-Ignore-") || conditions[key] {
+			continue
+		}
+		return fmt.Errorf("Invalid according to Policy: Extra input fields: %s", key)
+	}
 	return nil
 }
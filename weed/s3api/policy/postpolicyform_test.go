@@ -104,3 +104,36 @@ func TestPostPolicyForm(t *testing.T) {
 		}
 	}
 }
+
+func TestPostPolicyExtraFormFields(t *testing.T) {
+	pp := NewPostPolicy()
+	pp.SetBucket("testbucket")
+	pp.SetKeyStartsWith("uploads/")
+	pp.SetExpires(time.Now().UTC().AddDate(0, 0, 1))
+
+	postPolicyForm, err := ParsePostPolicyForm(pp.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	formValues := make(http.Header)
+	formValues.Set("Bucket", "testbucket")
+	formValues.Set("Key", "uploads/a.txt")
+	formValues.Set("Policy", base64.StdEncoding.EncodeToString([]byte(pp.String())))
+	formValues.Set("X-Amz-Signature", "signature")
+	formValues.Set("X-Ignore-Comment", "not checked")
+	if err = CheckPostPolicy(formValues, postPolicyForm); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	formValues.Set("Content-Type", "text/html")
+	if err = CheckPostPolicy(formValues, postPolicyForm); err == nil || err.Error() != "Invalid according to Policy: Extra input fields: Content-Type" {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	pp.SetExpires(time.Now().UTC().AddDate(0, 0, -1))
+	postPolicyForm, _ = ParsePostPolicyForm(pp.String())
+	if err = CheckPostPolicy(formValues, postPolicyForm); err != ErrPolicyExpired {
+		t.Fatalf("unexpected %v", err)
+	}
+}
@@ -0,0 +1,398 @@
+package policy_engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+/*
+The policy documents are the AWS IAM json policies, used as the s3 bucket policies and the iam user policies.
+
+A request is described by the principal, the s3 action, e.g. "s3:GetObject", the resource arn, e.g.
+"arn:aws:s3:::bucket/key", and the condition values, e.g. "aws:SourceIp". The statements matching the request
+are evaluated the same way as AWS: any matched Deny denies the request, otherwise any matched Allow allows it,
+otherwise the policy does not decide, and the request is authorized by the other permissions.
+
+The supported condition operators are the String*, Numeric*, Bool, IpAddress and NotIpAddress ones,
+with the IfExists suffix. The principals are matched by the identity names, "*" matching all including anonymous.
+Allowing "*" only grants the actions named in the statement, so the bucket policies allowing "*" the wildcard
+actions like "s3:Get*" are rejected. The condition keys are matched case-insensitively.
+*/
+
+const (
+	PolicyVersion = "2012-10-17"
+	EffectAllow   = "Allow"
+	EffectDeny    = "Deny"
+
+	ResourceArnPrefix = "arn:aws:s3:::"
+	PrincipalAll      = "*"
+)
+
+type Decision int
+
+const (
+	DecisionNone Decision = iota
+	DecisionAllow
+	DecisionDeny
+)
+
+// Strings is a json string or an array of strings
+type Strings []string
+
+func (s *Strings) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*s = Strings{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return fmt.Errorf("expecting a string or an array of strings: %s", string(data))
+	}
+	*s = multiple
+	return nil
+}
+
+// Principal is "*", or {"AWS": ...} with the identity names or the user arns
+type Principal struct {
+	AWS Strings `json:"AWS,omitempty"`
+}
+
+func (p *Principal) UnmarshalJSON(data []byte) error {
+	var all string
+	if err := json.Unmarshal(data, &all); err == nil {
+		if all != PrincipalAll {
+			return fmt.Errorf("invalid principal %q", all)
+		}
+		p.AWS = Strings{PrincipalAll}
+		return nil
+	}
+	var principal struct {
+		AWS Strings `json:"AWS"`
+	}
+	if err := json.Unmarshal(data, &principal); err != nil {
+		return fmt.Errorf("invalid principal: %v", err)
+	}
+	p.AWS = principal.AWS
+	return nil
+}
+
+type Statement struct {
+	Sid       string                        `json:"Sid,omitempty"`
+	Effect    string                        `json:"Effect"`
+	Principal *Principal                    `json:"Principal,omitempty"`
+	Action    Strings                       `json:"Action"`
+	Resource  Strings                       `json:"Resource"`
+	Condition map[string]map[string]Strings `json:"Condition,omitempty"`
+}
+
+type PolicyDocument struct {
+	Version   string       `json:"Version"`
+	Id        string       `json:"Id,omitempty"`
+	Statement []*Statement `json:"Statement"`
+}
+
+// Request is the request evaluated by the policies
+type Request struct {
+	Principal  string // the identity name, empty for the anonymous requests
+	Action     string
+	Resource   string
+	Conditions map[string][]string
+}
+
+func (p PolicyDocument) String() string {
+	b, _ := json.Marshal(p)
+	return string(b)
+}
+
+func ParsePolicyDocument(data []byte) (*PolicyDocument, error) {
+	policy := &PolicyDocument{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// ValidateBucketPolicy checks the bucket policy, which should only have statements on the bucket and its objects.
+func (p *PolicyDocument) ValidateBucketPolicy(bucket string) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	for _, s := range p.Statement {
+		if s.Principal == nil || len(s.Principal.AWS) == 0 {
+			return fmt.Errorf("statement %q without principal", s.Sid)
+		}
+		if s.Effect == EffectAllow && s.Principal.isAll() {
+			for _, action := range s.Action {
+				if strings.ContainsAny(action, "*?") {
+					return fmt.Errorf("statement %q allows \"*\" the wildcard action %s, only the named actions can be allowed to everyone", s.Sid, action)
+				}
+			}
+		}
+		for _, resource := range s.Resource {
+			name := strings.TrimPrefix(resource, ResourceArnPrefix)
+			if name != bucket && !strings.HasPrefix(name, bucket+"/") {
+				return fmt.Errorf("resource %s is not in the bucket %s", resource, bucket)
+			}
+		}
+	}
+	return nil
+}
+
+// Validate checks the statements of the policy
+func (p *PolicyDocument) Validate() error {
+	if p.Version != PolicyVersion {
+		return fmt.Errorf("unsupported policy version %q", p.Version)
+	}
+	if len(p.Statement) == 0 {
+		return fmt.Errorf("no statement")
+	}
+	for _, s := range p.Statement {
+		if s.Effect != EffectAllow && s.Effect != EffectDeny {
+			return fmt.Errorf("invalid effect %q", s.Effect)
+		}
+		if len(s.Action) == 0 || len(s.Resource) == 0 {
+			return fmt.Errorf("statement %q without action or resource", s.Sid)
+		}
+		for _, action := range s.Action {
+			if action != "*" && !strings.HasPrefix(action, "s3:") {
+				return fmt.Errorf("unsupported action %s", action)
+			}
+		}
+		for _, resource := range s.Resource {
+			if resource != "*" && !strings.HasPrefix(resource, ResourceArnPrefix) {
+				return fmt.Errorf("unsupported resource %s", resource)
+			}
+		}
+		for operator := range s.Condition {
+			if _, found := conditionOperator(operator); !found {
+				return fmt.Errorf("unsupported condition operator %s", operator)
+			}
+		}
+	}
+	return nil
+}
+
+// Evaluate returns the decision of the policy on the request
+func (p *PolicyDocument) Evaluate(req *Request) Decision {
+	decision := DecisionNone
+	for _, s := range p.Statement {
+		if !s.isMatched(req) {
+			continue
+		}
+		if s.Effect == EffectDeny {
+			return DecisionDeny
+		}
+		decision = DecisionAllow
+	}
+	return decision
+}
+
+func (s *Statement) isMatched(req *Request) bool {
+	if s.Principal != nil && !s.Principal.isMatched(req.Principal) {
+		return false
+	}
+	if s.Effect == EffectAllow && s.Principal != nil && !s.Principal.isNamed(req.Principal) {
+		// allowing everyone, including the anonymous requests, only grants the actions named in the statement
+		if !matchAnyExactly(s.Action, req.Action) {
+			return false
+		}
+	} else if !matchAny(s.Action, req.Action, true) {
+		return false
+	}
+	if !matchAny(s.Resource, req.Resource, false) {
+		return false
+	}
+	for operator, conditions := range s.Condition {
+		for key, values := range conditions {
+			if !isConditionMatched(operator, values, conditionValues(req.Conditions, key)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// conditionValues looks up the condition key case-insensitively, as AWS does
+func conditionValues(conditions map[string][]string, key string) []string {
+	if values, found := conditions[key]; found {
+		return values
+	}
+	for k, values := range conditions {
+		if strings.EqualFold(k, key) {
+			return values
+		}
+	}
+	return nil
+}
+
+func (p *Principal) isMatched(principal string) bool {
+	return p.isAll() || p.isNamed(principal)
+}
+
+func (p *Principal) isAll() bool {
+	for _, name := range p.AWS {
+		if name == PrincipalAll {
+			return true
+		}
+	}
+	return false
+}
+
+// isNamed is true if the principal is named in the statement, not only matched by "*"
+func (p *Principal) isNamed(principal string) bool {
+	if principal == "" {
+		return false
+	}
+	for _, name := range p.AWS {
+		// arn:aws:iam::<account>:user/<name>
+		if i := strings.LastIndex(name, ":user/"); i >= 0 && strings.HasPrefix(name, "arn:aws:iam:") {
+			name = name[i+len(":user/"):]
+		}
+		if name == principal {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAnyExactly(actions []string, action string) bool {
+	for _, a := range actions {
+		if strings.EqualFold(a, action) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(patterns []string, s string, ignoreCase bool) bool {
+	for _, pattern := range patterns {
+		if ignoreCase {
+			if MatchWildcard(strings.ToLower(pattern), strings.ToLower(s)) {
+				return true
+			}
+		} else if MatchWildcard(pattern, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchWildcard matches the string with the pattern, where "*" matches any characters and "?" matches one character.
+func MatchWildcard(pattern, s string) bool {
+	var p, i, starP, starI = 0, 0, -1, 0
+	for i < len(s) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == s[i]):
+			p++
+			i++
+		case p < len(pattern) && pattern[p] == '*':
+			starP, starI = p, i
+			p++
+		case starP >= 0:
+			starI++
+			p, i = starP+1, starI
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+type conditionFunc func(expected string, actual string) bool
+
+func conditionOperator(operator string) (fn conditionFunc, found bool) {
+	operator = strings.TrimSuffix(operator, "IfExists")
+	switch operator {
+	case "StringEquals":
+		return func(e, a string) bool { return e == a }, true
+	case "StringNotEquals":
+		return func(e, a string) bool { return e != a }, true
+	case "StringEqualsIgnoreCase":
+		return strings.EqualFold, true
+	case "StringNotEqualsIgnoreCase":
+		return func(e, a string) bool { return !strings.EqualFold(e, a) }, true
+	case "StringLike":
+		return MatchWildcard, true
+	case "StringNotLike":
+		return func(e, a string) bool { return !MatchWildcard(e, a) }, true
+	case "NumericEquals", "NumericNotEquals", "NumericLessThan", "NumericLessThanEquals", "NumericGreaterThan", "NumericGreaterThanEquals":
+		return numericCondition(operator), true
+	case "Bool":
+		return strings.EqualFold, true
+	case "IpAddress":
+		return isIpInRange, true
+	case "NotIpAddress":
+		return func(e, a string) bool { return !isIpInRange(e, a) }, true
+	}
+	return nil, false
+}
+
+// isConditionMatched matches when any expected value matches any actual value,
+// or for the negated operators, when all of the expected values match.
+func isConditionMatched(operator string, expected, actual []string) bool {
+	fn, found := conditionOperator(operator)
+	if !found {
+		return false
+	}
+	if len(actual) == 0 {
+		return strings.HasSuffix(operator, "IfExists")
+	}
+	isNegated := strings.Contains(operator, "Not")
+	for _, a := range actual {
+		for _, e := range expected {
+			matched := fn(e, a)
+			if isNegated && !matched {
+				return false
+			}
+			if !isNegated && matched {
+				return true
+			}
+		}
+	}
+	return isNegated
+}
+
+func numericCondition(operator string) conditionFunc {
+	return func(e, a string) bool {
+		expected, err := strconv.ParseFloat(e, 64)
+		if err != nil {
+			return false
+		}
+		actual, err := strconv.ParseFloat(a, 64)
+		if err != nil {
+			return false
+		}
+		switch operator {
+		case "NumericEquals":
+			return actual == expected
+		case "NumericNotEquals":
+			return actual != expected
+		case "NumericLessThan":
+			return actual < expected
+		case "NumericLessThanEquals":
+			return actual <= expected
+		case "NumericGreaterThan":
+			return actual > expected
+		}
+		return actual >= expected
+	}
+}
+
+func isIpInRange(cidr, ip string) bool {
+	actual := net.ParseIP(ip)
+	if actual == nil {
+		return false
+	}
+	if !strings.Contains(cidr, "/") {
+		return actual.Equal(net.ParseIP(cidr))
+	}
+	_, ipNet, err := net.ParseCIDR(cidr)
+	return err == nil && ipNet.Contains(actual)
+}
@@ -0,0 +1,148 @@
+package policy_engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchWildcard(t *testing.T) {
+	assert.True(t, MatchWildcard("*", ""))
+	assert.True(t, MatchWildcard("arn:aws:s3:::bucket/*", "arn:aws:s3:::bucket/a/b.txt"))
+	assert.True(t, MatchWildcard("arn:aws:s3:::bucket/*/b.txt", "arn:aws:s3:::bucket/a/b.txt"))
+	assert.True(t, MatchWildcard("s3:Get*", "s3:GetObject"))
+	assert.True(t, MatchWildcard("a?c", "abc"))
+	assert.False(t, MatchWildcard("arn:aws:s3:::bucket/*", "arn:aws:s3:::bucket"))
+	assert.False(t, MatchWildcard("arn:aws:s3:::bucket/a*", "arn:aws:s3:::bucket/b"))
+	assert.False(t, MatchWildcard("a?c", "ac"))
+}
+
+func TestEvaluateBucketPolicy(t *testing.T) {
+
+	policy, err := ParsePolicyDocument([]byte(`{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Sid": "PublicRead",
+      "Effect": "Allow",
+      "Principal": "*",
+      "Action": ["s3:GetObject"],
+      "Resource": "arn:aws:s3:::bucket/public/*"
+    },
+    {
+      "Effect": "Allow",
+      "Principal": {"AWS": ["arn:aws:iam::123456789012:user/alice", "bob"]},
+      "Action": "s3:*",
+      "Resource": ["arn:aws:s3:::bucket", "arn:aws:s3:::bucket/*"],
+      "Condition": {"IpAddress": {"aws:SourceIp": "10.0.0.0/8"}}
+    },
+    {
+      "Effect": "Deny",
+      "Principal": "*",
+      "Action": "s3:DeleteObject",
+      "Resource": "arn:aws:s3:::bucket/*",
+      "Condition": {"StringNotEquals": {"aws:username": ["admin"]}}
+    }
+  ]
+}`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if err = policy.ValidateBucketPolicy("bucket"); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if err = policy.ValidateBucketPolicy("other"); err == nil {
+		t.Errorf("validated the resources of another bucket")
+	}
+
+	local := map[string][]string{"aws:SourceIp": {"10.1.2.3"}}
+	remote := map[string][]string{"aws:SourceIp": {"192.168.1.1"}}
+
+	for _, c := range []struct {
+		request  Request
+		expected Decision
+	}{
+		{Request{"", "s3:GetObject", "arn:aws:s3:::bucket/public/a.txt", nil}, DecisionAllow},
+		{Request{"", "s3:GetObject", "arn:aws:s3:::bucket/private/a.txt", nil}, DecisionNone},
+		{Request{"alice", "s3:PutObject", "arn:aws:s3:::bucket/private/a.txt", local}, DecisionAllow},
+		{Request{"bob", "s3:ListBucket", "arn:aws:s3:::bucket", local}, DecisionAllow},
+		{Request{"alice", "s3:PutObject", "arn:aws:s3:::bucket/private/a.txt", remote}, DecisionNone},
+		{Request{"carol", "s3:PutObject", "arn:aws:s3:::bucket/private/a.txt", local}, DecisionNone},
+		{Request{"alice", "s3:DeleteObject", "arn:aws:s3:::bucket/a.txt", map[string][]string{"aws:SourceIp": {"10.1.2.3"}, "aws:username": {"alice"}}}, DecisionDeny},
+		{Request{"admin", "s3:DeleteObject", "arn:aws:s3:::bucket/a.txt", map[string][]string{"aws:username": {"admin"}}}, DecisionNone},
+		{Request{"admin", "s3:DeleteObject", "arn:aws:s3:::bucket/a.txt", map[string][]string{"AWS:UserName": {"admin"}}}, DecisionNone},
+		{Request{"bob", "s3:ListBucket", "arn:aws:s3:::bucket", map[string][]string{"aws:sourceip": {"10.1.2.3"}}}, DecisionAllow},
+	} {
+		assert.Equal(t, c.expected, policy.Evaluate(&c.request), "%+v", c.request)
+	}
+}
+
+func TestEvaluateEveryone(t *testing.T) {
+	policy, err := ParsePolicyDocument([]byte(`{
+  "Version": "2012-10-17",
+  "Statement": [
+    {"Effect": "Allow", "Principal": "*", "Action": ["s3:*", "s3:GetObject"], "Resource": "arn:aws:s3:::bucket/*"},
+    {"Effect": "Allow", "Principal": {"AWS": "*"}, "Action": "s3:Put*", "Resource": "arn:aws:s3:::bucket/*"}
+  ]
+}`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	for _, c := range []struct {
+		request  Request
+		expected Decision
+	}{
+		{Request{"", "s3:GetObject", "arn:aws:s3:::bucket/a.txt", nil}, DecisionAllow},
+		{Request{"alice", "s3:GetObject", "arn:aws:s3:::bucket/a.txt", nil}, DecisionAllow},
+		{Request{"", "s3:DeleteObject", "arn:aws:s3:::bucket/a.txt", nil}, DecisionNone},
+		{Request{"", "s3:PutObject", "arn:aws:s3:::bucket/a.txt", nil}, DecisionNone},
+		{Request{"alice", "s3:PutObject", "arn:aws:s3:::bucket/a.txt", nil}, DecisionNone},
+	} {
+		assert.Equal(t, c.expected, policy.Evaluate(&c.request), "%+v", c.request)
+	}
+}
+
+func TestConditions(t *testing.T) {
+	assert.True(t, isConditionMatched("StringLike", []string{"home/${aws:username}/*", "docs/*"}, []string{"docs/a"}))
+	assert.False(t, isConditionMatched("StringLike", []string{"docs/*"}, nil))
+	assert.True(t, isConditionMatched("StringLikeIfExists", []string{"docs/*"}, nil))
+	assert.True(t, isConditionMatched("NumericLessThanEquals", []string{"100"}, []string{"10"}))
+	assert.True(t, isConditionMatched("Bool", []string{"true"}, []string{"true"}))
+	assert.True(t, isConditionMatched("NotIpAddress", []string{"10.0.0.0/8", "192.168.0.0/16"}, []string{"172.16.0.1"}))
+	assert.False(t, isConditionMatched("NotIpAddress", []string{"10.0.0.0/8", "192.168.0.0/16"}, []string{"192.168.0.1"}))
+}
+
+func TestInvalidPolicy(t *testing.T) {
+	for _, input := range []string{
+		`{"Version": "2008-10-17", "Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/*"}]}`,
+		`{"Version": "2012-10-17", "Statement": [{"Effect": "Maybe", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/*"}]}`,
+		`{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/*"}]}`,
+		`{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Principal": "*", "Action": "ec2:RunInstances", "Resource": "arn:aws:s3:::bucket/*"}]}`,
+		`{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:Get*", "Resource": "arn:aws:s3:::bucket/*"}]}`,
+		`{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Principal": {"AWS": ["alice", "*"]}, "Action": "s3:*", "Resource": "arn:aws:s3:::bucket/*"}]}`,
+		`{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/*", "Condition": {"DateLessThan": {"aws:CurrentTime": "2020-01-01T00:00:00Z"}}}]}`,
+	} {
+		policy, err := ParsePolicyDocument([]byte(input))
+		if err != nil {
+			continue
+		}
+		if err = policy.ValidateBucketPolicy("bucket"); err == nil {
+			t.Errorf("validated %s", input)
+		}
+	}
+}
+
+func TestValidateWildcardActions(t *testing.T) {
+	policy, err := ParsePolicyDocument([]byte(`{
+  "Version": "2012-10-17",
+  "Statement": [
+    {"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/*"},
+    {"Effect": "Allow", "Principal": {"AWS": "alice"}, "Action": "s3:Get*", "Resource": "arn:aws:s3:::bucket/*"},
+    {"Effect": "Deny", "Principal": "*", "Action": "s3:Delete*", "Resource": "arn:aws:s3:::bucket/*"}
+  ]
+}`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	assert.Nil(t, policy.ValidateBucketPolicy("bucket"))
+}
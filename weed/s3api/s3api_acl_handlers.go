@@ -0,0 +1,357 @@
+package s3api
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	xhttp "github.com/chrislusf/seaweedfs/weed/s3api/http"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+The ACLs are mapped onto the identities and the public access, so only the canned ACLs are supported:
+
+	private, bucket-owner-full-control, ...    only the identities with the actions can access
+	public-read                                everyone can also read the objects, and list the bucket
+	public-read-write                          everyone can also write the objects of the bucket
+
+The bucket ACL is the public access of the bucket, see auth_public_access.go. The object ACL is kept in the
+X-Amz-Acl extended attribute of the object only if it is public, as "public-read", since the objects can not be
+written by others. The ACLs are reported as the grants to the bucket owner and to all users, and the same grants
+can be put instead of the x-amz-acl header, as the SDKs do to change the ACLs they have got.
+*/
+
+const (
+	aclGroupAllUsers         = "http://acs.amazonaws.com/groups/global/AllUsers"
+	aclPermissionFullControl = "FULL_CONTROL"
+	aclPermissionRead        = "READ"
+	aclPermissionWrite       = "WRITE"
+	aclXsiNamespace          = "http://www.w3.org/2001/XMLSchema-instance"
+	aclGrantHeaderPrefix     = "X-Amz-Grant-"
+)
+
+// CannedAccessControlPolicy is the AccessControlPolicy of a canned ACL
+type CannedAccessControlPolicy struct {
+	XMLName xml.Name      `xml:"http://s3.amazonaws.com/doc/2006-03-01/ AccessControlPolicy"`
+	Owner   CanonicalUser `xml:"Owner"`
+	Grants  []AclGrant    `xml:"AccessControlList>Grant"`
+}
+
+type AclGrant struct {
+	Grantee    AclGrantee `xml:"Grantee"`
+	Permission string     `xml:"Permission"`
+}
+
+type AclGrantee struct {
+	XMLNS        string `xml:"xmlns:xsi,attr"`
+	Type         string `xml:"xsi:type,attr"`
+	ID           string `xml:"ID,omitempty"`
+	DisplayName  string `xml:"DisplayName,omitempty"`
+	EmailAddress string `xml:"EmailAddress,omitempty"`
+	URI          string `xml:"URI,omitempty"`
+}
+
+// GetBucketAclHandler Get Bucket ACL
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketAcl.html
+func (s3a *S3ApiServer) GetBucketAclHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, _ := getBucketAndObject(r)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		writeErrorResponse(w, err, r.URL)
+		return
+	}
+
+	owner, errCode := s3a.getBucketExtended(bucket, xhttp.AmzIdentityId)
+	if errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	publicAccess, errCode := s3a.getBucketExtended(bucket, ExtS3PublicAccessKey)
+	if errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	writeSuccessResponseXML(w, encodeResponse(cannedAccessControlPolicy(string(owner), string(publicAccess))))
+}
+
+// PutBucketAclHandler Put Bucket ACL
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketAcl.html
+func (s3a *S3ApiServer) PutBucketAclHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, _ := getBucketAndObject(r)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		writeErrorResponse(w, err, r.URL)
+		return
+	}
+
+	owner, errCode := s3a.getBucketExtended(bucket, xhttp.AmzIdentityId)
+	if errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	cannedAcl, errCode := readCannedAcl(r, string(owner))
+	if errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	publicAccess, errCode := storedCannedAcl(cannedAcl, false, s3a.option.BlockPublic)
+	if errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	var value []byte
+	if publicAccess != "" {
+		value = []byte(publicAccess)
+	}
+	if errCode := s3a.setBucketExtended(bucket, ExtS3PublicAccessKey, value); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	s3a.evictBucketPolicy(bucket)
+
+	writeSuccessResponseEmpty(w)
+}
+
+// GetObjectAclHandler Get Object ACL
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectAcl.html
+func (s3a *S3ApiServer) GetObjectAclHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, object := getBucketAndObject(r)
+
+	owner, errCode := s3a.getBucketExtended(bucket, xhttp.AmzIdentityId)
+	if errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	target := util.FullPath(fmt.Sprintf("%s/%s%s", s3a.option.BucketsPath, bucket, object))
+	dir, name := target.DirAndName()
+
+	entry, err := s3a.getEntry(dir, name)
+	if err != nil {
+		if err == filer_pb.ErrNotFound {
+			writeErrorResponse(w, s3err.ErrNoSuchKey, r.URL)
+		} else {
+			glog.Errorf("GetObjectAclHandler %s: %v", r.URL, err)
+			writeErrorResponse(w, s3err.ErrInternalError, r.URL)
+		}
+		return
+	}
+
+	writeSuccessResponseXML(w, encodeResponse(cannedAccessControlPolicy(string(owner), string(entry.Extended[xhttp.AmzCannedAcl]))))
+}
+
+// PutObjectAclHandler Put Object ACL
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectAcl.html
+func (s3a *S3ApiServer) PutObjectAclHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, object := getBucketAndObject(r)
+
+	owner, errCode := s3a.getBucketExtended(bucket, xhttp.AmzIdentityId)
+	if errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	cannedAcl, errCode := readCannedAcl(r, string(owner))
+	if errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	objectAcl, errCode := storedCannedAcl(cannedAcl, true, s3a.option.BlockPublic)
+	if errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	target := util.FullPath(fmt.Sprintf("%s/%s%s", s3a.option.BucketsPath, bucket, object))
+	dir, name := target.DirAndName()
+
+	if err := s3a.setObjectAcl(dir, name, objectAcl); err != nil {
+		if err == filer_pb.ErrNotFound {
+			writeErrorResponse(w, s3err.ErrNoSuchKey, r.URL)
+		} else {
+			glog.Errorf("PutObjectAclHandler %s: %v", r.URL, err)
+			writeErrorResponse(w, s3err.ErrInternalError, r.URL)
+		}
+		return
+	}
+
+	writeSuccessResponseEmpty(w)
+}
+
+// applyObjectAcl validates the canned ACL of the uploaded object, and keeps it in the header only if it is public,
+// for the filer to save it with the object.
+func (s3a *S3ApiServer) applyObjectAcl(r *http.Request) s3err.ErrorCode {
+	if hasAclGrantHeaders(r) {
+		return s3err.ErrUnsupportedAcl
+	}
+	objectAcl, errCode := storedCannedAcl(r.Header.Get(xhttp.AmzCannedAcl), true, s3a.option.BlockPublic)
+	if errCode != s3err.ErrNone {
+		return errCode
+	}
+	r.Header.Del(xhttp.AmzCannedAcl)
+	if objectAcl != "" {
+		r.Header.Set(xhttp.AmzCannedAcl, objectAcl)
+	}
+	return s3err.ErrNone
+}
+
+// getObjectPublicAccess returns the public access of the object, empty for the private or missing objects.
+func (s3a *S3ApiServer) getObjectPublicAccess(bucket, object string) (string, error) {
+	dir, name := util.FullPath(fmt.Sprintf("%s/%s%s", s3a.option.BucketsPath, bucket, object)).DirAndName()
+	entry, err := s3a.getEntry(dir, name)
+	if err == filer_pb.ErrNotFound || err == nil && entry == nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(entry.Extended[xhttp.AmzCannedAcl]), nil
+}
+
+func (s3a *S3ApiServer) setObjectAcl(parentDirectoryPath string, entryName string, objectAcl string) error {
+
+	return s3a.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+
+		resp, err := filer_pb.LookupEntry(client, &filer_pb.LookupDirectoryEntryRequest{
+			Directory: parentDirectoryPath,
+			Name:      entryName,
+		})
+		if err != nil {
+			return err
+		}
+
+		if objectAcl == "" {
+			if _, found := resp.Entry.Extended[xhttp.AmzCannedAcl]; !found {
+				return nil
+			}
+			delete(resp.Entry.Extended, xhttp.AmzCannedAcl)
+		} else {
+			if resp.Entry.Extended == nil {
+				resp.Entry.Extended = make(map[string][]byte)
+			}
+			resp.Entry.Extended[xhttp.AmzCannedAcl] = []byte(objectAcl)
+		}
+
+		return filer_pb.UpdateEntry(client, &filer_pb.UpdateEntryRequest{
+			Directory: parentDirectoryPath,
+			Entry:     resp.Entry,
+		})
+
+	})
+
+}
+
+// storedCannedAcl validates the canned ACL, and returns the public access to keep, empty if not public.
+// The objects are only readable by everyone.
+func storedCannedAcl(cannedAcl string, isObject, blockPublic bool) (string, s3err.ErrorCode) {
+	isPublic, ok := isPublicCannedAcl(cannedAcl)
+	if !ok {
+		return "", s3err.ErrInvalidRequest
+	}
+	if !isPublic {
+		return "", s3err.ErrNone
+	}
+	if blockPublic {
+		return "", s3err.ErrAccessDenied
+	}
+	if isObject {
+		return CannedAclPublicRead, s3err.ErrNone
+	}
+	return cannedAcl, s3err.ErrNone
+}
+
+// readCannedAcl returns the canned ACL in the x-amz-acl header, or the one of the grants in the request body
+func readCannedAcl(r *http.Request, owner string) (string, s3err.ErrorCode) {
+	if hasAclGrantHeaders(r) {
+		return "", s3err.ErrUnsupportedAcl
+	}
+	if cannedAcl := r.Header.Get(xhttp.AmzCannedAcl); cannedAcl != "" {
+		return cannedAcl, s3err.ErrNone
+	}
+
+	input, err := ioutil.ReadAll(io.LimitReader(r.Body, r.ContentLength))
+	if err != nil {
+		glog.Errorf("read acl %s: %v", r.URL, err)
+		return "", s3err.ErrInternalError
+	}
+	policy := &CannedAccessControlPolicy{}
+	if err = xml.Unmarshal(input, policy); err != nil {
+		glog.V(1).Infof("unmarshal acl %s: %v", r.URL, err)
+		return "", s3err.ErrMalformedACL
+	}
+	return policy.cannedAcl(owner)
+}
+
+func hasAclGrantHeaders(r *http.Request) bool {
+	for header := range r.Header {
+		if strings.HasPrefix(header, aclGrantHeaderPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// cannedAcl maps the grants onto the canned ACL. The grants to the owner are implied.
+func (p *CannedAccessControlPolicy) cannedAcl(owner string) (string, s3err.ErrorCode) {
+	isRead, isWrite := false, false
+	for _, grant := range p.Grants {
+		switch {
+		case grant.Grantee.URI == aclGroupAllUsers:
+			switch grant.Permission {
+			case aclPermissionRead:
+				isRead = true
+			case aclPermissionWrite:
+				isWrite = true
+			case aclPermissionFullControl:
+				isRead, isWrite = true, true
+			default:
+				return "", s3err.ErrUnsupportedAcl
+			}
+		case grant.Grantee.URI == "" && grant.Grantee.EmailAddress == "" && grant.Grantee.ID == owner:
+		default:
+			return "", s3err.ErrUnsupportedAcl
+		}
+	}
+	switch {
+	case isRead && isWrite:
+		return CannedAclPublicReadWrite, s3err.ErrNone
+	case isRead:
+		return CannedAclPublicRead, s3err.ErrNone
+	case isWrite:
+		return "", s3err.ErrUnsupportedAcl
+	}
+	return CannedAclPrivate, s3err.ErrNone
+}
+
+// cannedAccessControlPolicy returns the grants of the public access to the owner and to all users
+func cannedAccessControlPolicy(owner, publicAccess string) *CannedAccessControlPolicy {
+	policy := &CannedAccessControlPolicy{
+		Owner: CanonicalUser{ID: owner, DisplayName: owner},
+		Grants: []AclGrant{{
+			Grantee:    AclGrantee{XMLNS: aclXsiNamespace, Type: "CanonicalUser", ID: owner, DisplayName: owner},
+			Permission: aclPermissionFullControl,
+		}},
+	}
+	allUsers := AclGrantee{XMLNS: aclXsiNamespace, Type: "Group", URI: aclGroupAllUsers}
+	switch publicAccess {
+	case CannedAclPublicReadWrite:
+		policy.Grants = append(policy.Grants,
+			AclGrant{Grantee: allUsers, Permission: aclPermissionRead},
+			AclGrant{Grantee: allUsers, Permission: aclPermissionWrite})
+	case CannedAclPublicRead:
+		policy.Grants = append(policy.Grants, AclGrant{Grantee: allUsers, Permission: aclPermissionRead})
+	}
+	return policy
+}
@@ -0,0 +1,87 @@
+package s3api
+
+import (
+	"encoding/xml"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	xhttp "github.com/chrislusf/seaweedfs/weed/s3api/http"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCannedAccessControlPolicy(t *testing.T) {
+	for _, publicAccess := range []string{"", CannedAclPublicRead, CannedAclPublicReadWrite} {
+		policy := cannedAccessControlPolicy("alice", publicAccess)
+		data := encodeResponse(policy)
+
+		parsed := &CannedAccessControlPolicy{}
+		if assert.NoError(t, xml.Unmarshal(data, parsed), publicAccess) {
+			cannedAcl, errCode := parsed.cannedAcl("alice")
+			assert.Equal(t, s3err.ErrNone, errCode, publicAccess)
+			if publicAccess == "" {
+				assert.Equal(t, CannedAclPrivate, cannedAcl)
+			} else {
+				assert.Equal(t, publicAccess, cannedAcl)
+			}
+		}
+	}
+
+	data := string(encodeResponse(cannedAccessControlPolicy("alice", CannedAclPublicRead)))
+	assert.Contains(t, data, `<AccessControlPolicy xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Owner><ID>alice</ID>`)
+	assert.Contains(t, data, `<Grant><Grantee xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="Group"><URI>http://acs.amazonaws.com/groups/global/AllUsers</URI></Grantee><Permission>READ</Permission></Grant>`)
+}
+
+func TestReadCannedAcl(t *testing.T) {
+	for body, expected := range map[string]struct {
+		cannedAcl string
+		errCode   s3err.ErrorCode
+	}{
+		`<AccessControlPolicy xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><AccessControlList><Grant><Grantee xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="Group"><URI>http://acs.amazonaws.com/groups/global/AllUsers</URI></Grantee><Permission>READ</Permission></Grant></AccessControlList></AccessControlPolicy>`: {CannedAclPublicRead, s3err.ErrNone},
+		`<AccessControlPolicy xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><AccessControlList><Grant><Grantee><ID>alice</ID></Grantee><Permission>FULL_CONTROL</Permission></Grant></AccessControlList></AccessControlPolicy>`:                                                                                                            {CannedAclPrivate, s3err.ErrNone},
+		`<AccessControlPolicy xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><AccessControlList><Grant><Grantee><ID>bob</ID></Grantee><Permission>READ</Permission></Grant></AccessControlList></AccessControlPolicy>`:                                                                                                                      {"", s3err.ErrUnsupportedAcl},
+		`<AccessControlPolicy xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><AccessControlList><Grant><Grantee><URI>http://acs.amazonaws.com/groups/global/AllUsers</URI></Grantee><Permission>WRITE</Permission></Grant></AccessControlList></AccessControlPolicy>`:                                                                       {"", s3err.ErrUnsupportedAcl},
+		`<AccessControlPolicy xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><AccessControlList><Grant><Grantee><URI>http://acs.amazonaws.com/groups/global/AllUsers</URI></Grantee><Permission>FULL_CONTROL</Permission></Grant></AccessControlList></AccessControlPolicy>`:                                                                {CannedAclPublicReadWrite, s3err.ErrNone},
+		`<AccessControlPolicy xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><AccessControlList><Grant><Grantee><URI>http://acs.amazonaws.com/groups/global/AuthenticatedUsers</URI></Grantee><Permission>READ</Permission></Grant></AccessControlList></AccessControlPolicy>`:                                                              {"", s3err.ErrUnsupportedAcl},
+		`<AccessControlPolicy>`: {"", s3err.ErrMalformedACL},
+	} {
+		r := httptest.NewRequest("PUT", "/bucket?acl", strings.NewReader(body))
+		cannedAcl, errCode := readCannedAcl(r, "alice")
+		assert.Equal(t, expected.errCode, errCode, body)
+		assert.Equal(t, expected.cannedAcl, cannedAcl, body)
+	}
+
+	r := httptest.NewRequest("PUT", "/bucket?acl", nil)
+	r.Header.Set(xhttp.AmzCannedAcl, CannedAclPublicRead)
+	cannedAcl, errCode := readCannedAcl(r, "alice")
+	assert.Equal(t, s3err.ErrNone, errCode)
+	assert.Equal(t, CannedAclPublicRead, cannedAcl)
+
+	r.Header.Set("X-Amz-Grant-Read", `id="bob"`)
+	_, errCode = readCannedAcl(r, "alice")
+	assert.Equal(t, s3err.ErrUnsupportedAcl, errCode)
+}
+
+func TestStoredCannedAcl(t *testing.T) {
+	for _, c := range []struct {
+		cannedAcl   string
+		isObject    bool
+		blockPublic bool
+		stored      string
+		errCode     s3err.ErrorCode
+	}{
+		{"", false, false, "", s3err.ErrNone},
+		{"bucket-owner-full-control", true, false, "", s3err.ErrNone},
+		{CannedAclPublicRead, false, false, CannedAclPublicRead, s3err.ErrNone},
+		{CannedAclPublicReadWrite, false, false, CannedAclPublicReadWrite, s3err.ErrNone},
+		{CannedAclPublicReadWrite, true, false, CannedAclPublicRead, s3err.ErrNone},
+		{CannedAclPublicRead, true, true, "", s3err.ErrAccessDenied},
+		{CannedAclPrivate, true, true, "", s3err.ErrNone},
+		{"public", false, false, "", s3err.ErrInvalidRequest},
+	} {
+		stored, errCode := storedCannedAcl(c.cannedAcl, c.isObject, c.blockPublic)
+		assert.Equal(t, c.errCode, errCode, c.cannedAcl)
+		assert.Equal(t, c.stored, stored, c.cannedAcl)
+	}
+}
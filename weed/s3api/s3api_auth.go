@@ -53,6 +53,13 @@ func isRequestSignStreamingV4(r *http.Request) bool {
 		r.Method == http.MethodPut
 }
 
+// Verify if the request is aws-chunked without the chunk signatures, and with the checksum in the trailer.
+// This is only valid for 'PUT' operation.
+func isRequestUnsignedTrailer(r *http.Request) bool {
+	return r.Header.Get("x-amz-content-sha256") == streamingUnsignedPayloadTrailer &&
+		r.Method == http.MethodPut
+}
+
 // Authorization type.
 type authType int
 
@@ -0,0 +1,358 @@
+package s3api
+
+import (
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/s3api/policy_engine"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+)
+
+/*
+The CORS rules are set by PutBucketCors, and kept in the ExtS3CorsKey extended attribute of the bucket directory.
+
+The preflight OPTIONS requests are answered by the first rule matching the origin, the requested method and headers,
+without authentication. The other requests with the Origin header get the CORS response headers of the first rule
+matching the origin and the method. The Origin header is not passed to the filer, so only the bucket rules apply.
+The headers exposed by the rule are merged with the headers exposed by the filer.
+
+Like the bucket policies, the CORS rules are looked up from the filer again after bucketCorsCacheTtl,
+except with the prefetch option.
+*/
+
+const (
+	ExtS3CorsKey       = "x-seaweedfs-s3-cors"
+	maxCorsRules       = 100
+	maxCorsRuleSize    = 64 * 1024
+	bucketCorsCacheTtl = 10 * time.Second
+)
+
+type cachedBucketCors struct {
+	raw           string
+	configuration *CORSConfiguration
+	loadedAt      time.Time
+}
+
+type bucketCorsCache struct {
+	sync.Mutex
+	configurations map[string]*cachedBucketCors
+}
+
+type CORSConfiguration struct {
+	XMLName   xml.Name   `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CORSConfiguration"`
+	CORSRules []CORSRule `xml:"CORSRule"`
+}
+
+type CORSRule struct {
+	ID             string   `xml:"ID,omitempty"`
+	AllowedHeaders []string `xml:"AllowedHeader,omitempty"`
+	AllowedMethods []string `xml:"AllowedMethod"`
+	AllowedOrigins []string `xml:"AllowedOrigin"`
+	ExposeHeaders  []string `xml:"ExposeHeader,omitempty"`
+	MaxAgeSeconds  *int     `xml:"MaxAgeSeconds,omitempty"`
+}
+
+// GetBucketCorsHandler Get Bucket CORS
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketCors.html
+func (s3a *S3ApiServer) GetBucketCorsHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, _ := getBucketAndObject(r)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		writeErrorResponse(w, err, r.URL)
+		return
+	}
+
+	configuration, errCode := s3a.getBucketCors(bucket)
+	if errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	if configuration == nil {
+		writeErrorResponse(w, s3err.ErrNoSuchCORSConfiguration, r.URL)
+		return
+	}
+
+	writeSuccessResponseXML(w, encodeResponse(configuration))
+}
+
+// PutBucketCorsHandler Put Bucket CORS
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketCors.html
+func (s3a *S3ApiServer) PutBucketCorsHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, _ := getBucketAndObject(r)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		writeErrorResponse(w, err, r.URL)
+		return
+	}
+
+	input, err := ioutil.ReadAll(io.LimitReader(r.Body, maxCorsRuleSize))
+	if err != nil {
+		glog.Errorf("PutBucketCorsHandler read input %s: %v", r.URL, err)
+		writeErrorResponse(w, s3err.ErrInternalError, r.URL)
+		return
+	}
+	configuration := &CORSConfiguration{}
+	if err = xml.Unmarshal(input, configuration); err != nil {
+		glog.Errorf("PutBucketCorsHandler Unmarshal %s: %v", r.URL, err)
+		writeErrorResponse(w, s3err.ErrMalformedXML, r.URL)
+		return
+	}
+	if errCode := configuration.validate(); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	if errCode := s3a.setBucketExtended(bucket, ExtS3CorsKey, encodeResponse(configuration)); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	s3a.evictBucketCors(bucket)
+
+	writeSuccessResponseEmpty(w)
+}
+
+// DeleteBucketCorsHandler Delete Bucket CORS
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketCors.html
+func (s3a *S3ApiServer) DeleteBucketCorsHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, _ := getBucketAndObject(r)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		writeErrorResponse(w, err, r.URL)
+		return
+	}
+
+	if errCode := s3a.setBucketExtended(bucket, ExtS3CorsKey, nil); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	s3a.evictBucketCors(bucket)
+
+	writeResponse(w, http.StatusNoContent, nil, mimeNone)
+}
+
+// CorsPreflightHandler answers the preflight OPTIONS requests of the browsers
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/RESTOPTIONSobject.html
+func (s3a *S3ApiServer) CorsPreflightHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, _ := getBucketAndObject(r)
+
+	origin := r.Header.Get("Origin")
+	method := r.Header.Get("Access-Control-Request-Method")
+	if origin == "" || method == "" {
+		writeErrorResponse(w, s3err.ErrInvalidRequest, r.URL)
+		return
+	}
+	var requestHeaders []string
+	for _, h := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			requestHeaders = append(requestHeaders, h)
+		}
+	}
+
+	configuration, errCode := s3a.getBucketCors(bucket)
+	if errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	if configuration == nil {
+		writeErrorResponse(w, s3err.ErrCorsNotEnabled, r.URL)
+		return
+	}
+	rule := configuration.match(origin, method, requestHeaders)
+	if rule == nil {
+		writeErrorResponse(w, s3err.ErrCorsForbidden, r.URL)
+		return
+	}
+
+	rule.setResponseHeaders(w, origin)
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(rule.AllowedMethods, ", "))
+	if len(requestHeaders) > 0 {
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(requestHeaders, ", "))
+	}
+	if rule.MaxAgeSeconds != nil {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(*rule.MaxAgeSeconds))
+	}
+	writeSuccessResponseEmpty(w)
+}
+
+// applyCors adds the CORS response headers to the requests with the Origin header
+func (s3a *S3ApiServer) applyCors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin != "" && r.Method != http.MethodOptions {
+			bucket, _ := getBucketAndObject(r)
+			if configuration, _ := s3a.getBucketCors(bucket); configuration != nil {
+				if rule := configuration.match(origin, r.Method, nil); rule != nil {
+					rule.setResponseHeaders(w, origin)
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *CORSConfiguration) validate() s3err.ErrorCode {
+	if len(c.CORSRules) == 0 || len(c.CORSRules) > maxCorsRules {
+		return s3err.ErrMalformedXML
+	}
+	for _, rule := range c.CORSRules {
+		if len(rule.AllowedMethods) == 0 || len(rule.AllowedOrigins) == 0 {
+			return s3err.ErrMalformedXML
+		}
+		for _, method := range rule.AllowedMethods {
+			switch method {
+			case http.MethodGet, http.MethodPut, http.MethodHead, http.MethodPost, http.MethodDelete:
+			default:
+				return s3err.ErrInvalidRequest
+			}
+		}
+		for _, origin := range rule.AllowedOrigins {
+			if strings.Count(origin, "*") > 1 {
+				return s3err.ErrInvalidRequest
+			}
+		}
+		for _, header := range rule.AllowedHeaders {
+			if strings.Count(header, "*") > 1 {
+				return s3err.ErrInvalidRequest
+			}
+		}
+		if rule.MaxAgeSeconds != nil && *rule.MaxAgeSeconds < 0 {
+			return s3err.ErrInvalidRequest
+		}
+	}
+	return s3err.ErrNone
+}
+
+// match returns the first rule allowing the origin, the method and all the request headers
+func (c *CORSConfiguration) match(origin, method string, requestHeaders []string) *CORSRule {
+	for i := range c.CORSRules {
+		rule := &c.CORSRules[i]
+		if rule.isMatched(origin, method, requestHeaders) {
+			return rule
+		}
+	}
+	return nil
+}
+
+func (rule *CORSRule) isMatched(origin, method string, requestHeaders []string) bool {
+	if method == http.MethodOptions {
+		return false
+	}
+	methodAllowed := false
+	for _, m := range rule.AllowedMethods {
+		if m == method {
+			methodAllowed = true
+			break
+		}
+	}
+	if !methodAllowed {
+		return false
+	}
+	originAllowed := false
+	for _, o := range rule.AllowedOrigins {
+		if policy_engine.MatchWildcard(o, origin) {
+			originAllowed = true
+			break
+		}
+	}
+	if !originAllowed {
+		return false
+	}
+	for _, h := range requestHeaders {
+		headerAllowed := false
+		for _, allowed := range rule.AllowedHeaders {
+			if policy_engine.MatchWildcard(strings.ToLower(allowed), strings.ToLower(h)) {
+				headerAllowed = true
+				break
+			}
+		}
+		if !headerAllowed {
+			return false
+		}
+	}
+	return true
+}
+
+func (rule *CORSRule) setResponseHeaders(w http.ResponseWriter, origin string) {
+	allowAll := false
+	for _, o := range rule.AllowedOrigins {
+		if o == "*" {
+			allowAll = true
+		}
+	}
+	if allowAll {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+	w.Header().Add("Vary", "Origin")
+	if len(rule.ExposeHeaders) > 0 {
+		w.Header().Set("Access-Control-Expose-Headers", strings.Join(rule.ExposeHeaders, ", "))
+	}
+}
+
+// mergeExposeHeaders joins the comma separated header names, without the duplicates.
+func mergeExposeHeaders(values ...string) string {
+	var headers []string
+	seen := make(map[string]bool)
+	for _, value := range values {
+		for _, h := range strings.Split(value, ",") {
+			if h = strings.TrimSpace(h); h != "" && !seen[strings.ToLower(h)] {
+				seen[strings.ToLower(h)] = true
+				headers = append(headers, h)
+			}
+		}
+	}
+	return strings.Join(headers, ", ")
+}
+
+// getBucketCors returns the cached CORS rules of the bucket, nil if not set.
+func (s3a *S3ApiServer) getBucketCors(bucket string) (*CORSConfiguration, s3err.ErrorCode) {
+	s3a.bucketCors.Lock()
+	cached, found := s3a.bucketCors.configurations[bucket]
+	s3a.bucketCors.Unlock()
+	if found && s3a.buckets == nil && time.Since(cached.loadedAt) < bucketCorsCacheTtl {
+		return cached.configuration, s3err.ErrNone
+	}
+
+	data, errCode := s3a.getBucketExtended(bucket, ExtS3CorsKey)
+	if errCode != s3err.ErrNone {
+		return nil, errCode
+	}
+	raw := string(data)
+	if found && cached.raw == raw {
+		s3a.bucketCors.Lock()
+		cached.loadedAt = time.Now()
+		s3a.bucketCors.Unlock()
+		return cached.configuration, s3err.ErrNone
+	}
+
+	var configuration *CORSConfiguration
+	if raw != "" {
+		configuration = &CORSConfiguration{}
+		if err := xml.Unmarshal(data, configuration); err != nil {
+			glog.Errorf("bucket %s cors: %v", bucket, err)
+			return nil, s3err.ErrInternalError
+		}
+	}
+	s3a.bucketCors.Lock()
+	s3a.bucketCors.configurations[bucket] = &cachedBucketCors{raw: raw, configuration: configuration, loadedAt: time.Now()}
+	s3a.bucketCors.Unlock()
+	return configuration, s3err.ErrNone
+}
+
+func (s3a *S3ApiServer) evictBucketCors(bucket string) {
+	s3a.bucketCors.Lock()
+	delete(s3a.bucketCors.configurations, bucket)
+	s3a.bucketCors.Unlock()
+}
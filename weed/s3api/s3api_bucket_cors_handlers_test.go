@@ -0,0 +1,80 @@
+package s3api
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCorsConfiguration(t *testing.T) {
+	input := `<CORSConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <CORSRule>
+    <AllowedOrigin>https://*.example.com</AllowedOrigin>
+    <AllowedMethod>GET</AllowedMethod>
+    <AllowedMethod>PUT</AllowedMethod>
+    <AllowedHeader>Content-*</AllowedHeader>
+    <AllowedHeader>x-amz-date</AllowedHeader>
+    <ExposeHeader>ETag</ExposeHeader>
+    <MaxAgeSeconds>3000</MaxAgeSeconds>
+  </CORSRule>
+  <CORSRule>
+    <AllowedOrigin>*</AllowedOrigin>
+    <AllowedMethod>GET</AllowedMethod>
+  </CORSRule>
+</CORSConfiguration>`
+	configuration := &CORSConfiguration{}
+	if err := xml.Unmarshal([]byte(input), configuration); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	assert.Equal(t, s3err.ErrNone, configuration.validate())
+	assert.Equal(t, 3000, *configuration.CORSRules[0].MaxAgeSeconds)
+
+	rule := configuration.match("https://app.example.com", "PUT", []string{"content-type", "X-Amz-Date"})
+	if assert.NotNil(t, rule) {
+		w := httptest.NewRecorder()
+		rule.setResponseHeaders(w, "https://app.example.com")
+		assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+		assert.Equal(t, "ETag", w.Header().Get("Access-Control-Expose-Headers"))
+	}
+	assert.Nil(t, configuration.match("https://app.example.com", "PUT", []string{"x-amz-acl"}))
+	assert.Nil(t, configuration.match("https://other.com", "PUT", nil))
+	assert.Nil(t, configuration.match("https://app.example.com", "DELETE", nil))
+
+	rule = configuration.match("https://other.com", "GET", nil)
+	if assert.NotNil(t, rule) {
+		w := httptest.NewRecorder()
+		rule.setResponseHeaders(w, "https://other.com")
+		assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "", w.Header().Get("Access-Control-Allow-Credentials"))
+	}
+
+	invalid := &CORSConfiguration{CORSRules: []CORSRule{{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"PATCH"}}}}
+	assert.Equal(t, s3err.ErrInvalidRequest, invalid.validate())
+	invalid = &CORSConfiguration{CORSRules: []CORSRule{{AllowedMethods: []string{"GET"}}}}
+	assert.Equal(t, s3err.ErrMalformedXML, invalid.validate())
+	assert.Equal(t, s3err.ErrMalformedXML, (&CORSConfiguration{}).validate())
+}
+
+func TestCorsExposeHeadersMerged(t *testing.T) {
+	w := httptest.NewRecorder()
+	w.Header().Set("Access-Control-Expose-Headers", "ETag, x-amz-meta-custom")
+	proxyResponse := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Access-Control-Expose-Headers": []string{"Seaweed-Name,Content-Disposition,etag"}},
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+	}
+	passThroughResponse(proxyResponse, w)
+	assert.Equal(t, "ETag, x-amz-meta-custom, Seaweed-Name, Content-Disposition", w.Header().Get("Access-Control-Expose-Headers"))
+
+	// without a CORS rule, the filer value is kept
+	w = httptest.NewRecorder()
+	passThroughResponse(proxyResponse, w)
+	assert.Equal(t, "Seaweed-Name,Content-Disposition,etag", w.Header().Get("Access-Control-Expose-Headers"))
+}
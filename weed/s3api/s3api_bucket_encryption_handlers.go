@@ -0,0 +1,252 @@
+package s3api
+
+import (
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	xhttp "github.com/chrislusf/seaweedfs/weed/s3api/http"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+const (
+	ExtS3EncryptionKey = "x-seaweedfs-s3-encryption"
+)
+
+type ServerSideEncryptionConfiguration struct {
+	XMLName xml.Name                   `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ServerSideEncryptionConfiguration"`
+	Rules   []ServerSideEncryptionRule `xml:"Rule"`
+}
+
+type ServerSideEncryptionRule struct {
+	ApplyServerSideEncryptionByDefault ApplyServerSideEncryptionByDefault `xml:"ApplyServerSideEncryptionByDefault"`
+	BucketKeyEnabled                   bool                               `xml:"BucketKeyEnabled,omitempty"`
+}
+
+type ApplyServerSideEncryptionByDefault struct {
+	SSEAlgorithm   string `xml:"SSEAlgorithm"`
+	KMSMasterKeyID string `xml:"KMSMasterKeyID,omitempty"`
+}
+
+// GetBucketEncryptionHandler Get Bucket Encryption
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketEncryption.html
+func (s3a *S3ApiServer) GetBucketEncryptionHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, _ := getBucketAndObject(r)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		writeErrorResponse(w, err, r.URL)
+		return
+	}
+
+	configuration, errCode := s3a.getBucketEncryption(bucket)
+	if errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	if configuration == nil {
+		writeErrorResponse(w, s3err.ErrServerSideEncryptionConfigurationNotFound, r.URL)
+		return
+	}
+
+	writeSuccessResponseXML(w, encodeResponse(configuration))
+}
+
+// PutBucketEncryptionHandler Put Bucket Encryption
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketEncryption.html
+func (s3a *S3ApiServer) PutBucketEncryptionHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, _ := getBucketAndObject(r)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		writeErrorResponse(w, err, r.URL)
+		return
+	}
+
+	input, err := ioutil.ReadAll(io.LimitReader(r.Body, r.ContentLength))
+	if err != nil {
+		glog.Errorf("PutBucketEncryptionHandler read input %s: %v", r.URL, err)
+		writeErrorResponse(w, s3err.ErrInternalError, r.URL)
+		return
+	}
+	configuration := &ServerSideEncryptionConfiguration{}
+	if err = xml.Unmarshal(input, configuration); err != nil {
+		glog.Errorf("PutBucketEncryptionHandler Unmarshal %s: %v", r.URL, err)
+		writeErrorResponse(w, s3err.ErrMalformedXML, r.URL)
+		return
+	}
+	if errCode := configuration.validate(); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	if errCode := s3a.setBucketExtended(bucket, ExtS3EncryptionKey, encodeResponse(configuration)); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	writeSuccessResponseEmpty(w)
+}
+
+// DeleteBucketEncryptionHandler Delete Bucket Encryption
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketEncryption.html
+func (s3a *S3ApiServer) DeleteBucketEncryptionHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, _ := getBucketAndObject(r)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		writeErrorResponse(w, err, r.URL)
+		return
+	}
+
+	if errCode := s3a.setBucketExtended(bucket, ExtS3EncryptionKey, nil); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	writeResponse(w, http.StatusNoContent, nil, mimeNone)
+}
+
+func (c *ServerSideEncryptionConfiguration) validate() s3err.ErrorCode {
+	if len(c.Rules) != 1 {
+		return s3err.ErrMalformedXML
+	}
+	byDefault := c.Rules[0].ApplyServerSideEncryptionByDefault
+	switch byDefault.SSEAlgorithm {
+	case filer.SseAlgorithmAES256:
+		if byDefault.KMSMasterKeyID != "" {
+			return s3err.ErrInvalidRequest
+		}
+	case filer.SseAlgorithmKms:
+	default:
+		return s3err.ErrInvalidEncryptionAlgorithm
+	}
+	return s3err.ErrNone
+}
+
+// getBucketEncryption returns the default encryption of the bucket, nil if not set.
+func (s3a *S3ApiServer) getBucketEncryption(bucket string) (*ServerSideEncryptionConfiguration, s3err.ErrorCode) {
+	data, errCode := s3a.getBucketExtended(bucket, ExtS3EncryptionKey)
+	if errCode != s3err.ErrNone || len(data) == 0 {
+		return nil, errCode
+	}
+	configuration := &ServerSideEncryptionConfiguration{}
+	if err := xml.Unmarshal(data, configuration); err != nil {
+		glog.Errorf("bucket %s encryption: %v", bucket, err)
+		return nil, s3err.ErrInternalError
+	}
+	return configuration, s3err.ErrNone
+}
+
+// applySse validates the server side encryption requested in the headers,
+// or sets the headers with the default encryption of the bucket.
+// The objects with the customer keys are not encrypted with the bucket default.
+func (s3a *S3ApiServer) applySse(r *http.Request, bucket string) s3err.ErrorCode {
+	if key, _, errCode := sseCustomerKeyOf(r); errCode != s3err.ErrNone {
+		return errCode
+	} else if key != nil {
+		if r.Header.Get(filer.SseAlgorithmHeader) != "" || r.Header.Get(filer.SseKmsKeyIdHeader) != "" {
+			return s3err.ErrInvalidRequest
+		}
+		return s3err.ErrNone
+	}
+	if algorithm := r.Header.Get(filer.SseAlgorithmHeader); algorithm != "" {
+		if algorithm != filer.SseAlgorithmAES256 && algorithm != filer.SseAlgorithmKms {
+			return s3err.ErrInvalidEncryptionAlgorithm
+		}
+		if algorithm != filer.SseAlgorithmKms && r.Header.Get(filer.SseKmsKeyIdHeader) != "" {
+			return s3err.ErrInvalidRequest
+		}
+		return s3err.ErrNone
+	}
+	if r.Header.Get(filer.SseKmsKeyIdHeader) != "" {
+		return s3err.ErrInvalidRequest
+	}
+	configuration, errCode := s3a.getBucketEncryption(bucket)
+	if errCode != s3err.ErrNone || configuration == nil {
+		return errCode
+	}
+	byDefault := configuration.Rules[0].ApplyServerSideEncryptionByDefault
+	r.Header.Set(filer.SseAlgorithmHeader, byDefault.SSEAlgorithm)
+	if byDefault.KMSMasterKeyID != "" {
+		r.Header.Set(filer.SseKmsKeyIdHeader, byDefault.KMSMasterKeyID)
+	}
+	return s3err.ErrNone
+}
+
+// applySseUpload sets the headers of the parts with the encryption of the multipart upload.
+// The parts of the uploads with the customer keys should be sent with the same key.
+func applySseUpload(r *http.Request, uploadEntry *filer_pb.Entry) s3err.ErrorCode {
+	r.Header.Del(filer.SseAlgorithmHeader)
+	r.Header.Del(filer.SseKmsKeyIdHeader)
+	for _, k := range []string{filer.SseAlgorithmHeader, filer.SseKmsKeyIdHeader} {
+		if v := uploadEntry.Extended[k]; len(v) > 0 {
+			r.Header.Set(k, string(v))
+		}
+	}
+	_, keyMD5, errCode := sseCustomerKeyOf(r)
+	if errCode != s3err.ErrNone {
+		return errCode
+	}
+	if keyMD5 != string(uploadEntry.Extended[filer.SseCustomerKeyMD5Header]) {
+		return s3err.ErrInvalidSseCustomerKey
+	}
+	return s3err.ErrNone
+}
+
+func sseCustomerKeyOf(r *http.Request) (util.CipherKey, string, s3err.ErrorCode) {
+	key, keyMD5, err := filer.ParseSseCustomerKey(r.Header.Get(filer.SseCustomerAlgorithmHeader), r.Header.Get(filer.SseCustomerKeyHeader), r.Header.Get(filer.SseCustomerKeyMD5Header))
+	if err != nil {
+		return nil, "", s3err.ErrInvalidSseCustomerKey
+	}
+	return key, keyMD5, s3err.ErrNone
+}
+
+// copySourceSseHeaders checks the customer key of the copy source, and returns the headers to read it
+func copySourceSseHeaders(r *http.Request, srcEntry *filer_pb.Entry) (http.Header, s3err.ErrorCode) {
+	header := make(http.Header)
+	algorithm, key, keyMD5 := r.Header.Get(xhttp.AmzCopySourceSseCustomerAlgorithm), r.Header.Get(xhttp.AmzCopySourceSseCustomerKey), r.Header.Get(xhttp.AmzCopySourceSseCustomerKeyMD5)
+	if _, _, err := filer.ParseSseCustomerKey(algorithm, key, keyMD5); err != nil {
+		return nil, s3err.ErrInvalidSseCustomerKey
+	}
+	if expected := string(srcEntry.Extended[filer.SseCustomerKeyMD5Header]); expected != keyMD5 {
+		if keyMD5 == "" {
+			return nil, s3err.ErrSseCustomerKeyRequired
+		}
+		return nil, s3err.ErrAccessDenied
+	}
+	if algorithm != "" {
+		header.Set(filer.SseCustomerAlgorithmHeader, algorithm)
+		header.Set(filer.SseCustomerKeyHeader, key)
+		header.Set(filer.SseCustomerKeyMD5Header, keyMD5)
+	}
+	return header, s3err.ErrNone
+}
+
+// sseReadError returns the error of reading the objects encrypted with the customer key
+func sseReadError(resp *http.Response) (s3err.ErrorCode, bool) {
+	if resp.Header.Get(filer.SseCustomerKeyMD5Header) == "" {
+		return s3err.ErrNone, false
+	}
+	switch resp.StatusCode {
+	case http.StatusBadRequest:
+		return s3err.ErrSseCustomerKeyRequired, true
+	case http.StatusForbidden:
+		return s3err.ErrAccessDenied, true
+	}
+	return s3err.ErrNone, false
+}
+
+// setSseResponseHeaders returns the server side encryption of the uploaded object
+func setSseResponseHeaders(w http.ResponseWriter, r *http.Request) {
+	for _, k := range []string{filer.SseAlgorithmHeader, filer.SseKmsKeyIdHeader, filer.SseCustomerAlgorithmHeader, filer.SseCustomerKeyMD5Header} {
+		if v := r.Header.Get(k); v != "" {
+			w.Header().Set(k, v)
+		}
+	}
+}
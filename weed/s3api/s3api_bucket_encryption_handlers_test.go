@@ -0,0 +1,53 @@
+package s3api
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+)
+
+func TestServerSideEncryptionConfiguration(t *testing.T) {
+
+	for input, expected := range map[string]s3err.ErrorCode{
+		`<ServerSideEncryptionConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Rule><ApplyServerSideEncryptionByDefault><SSEAlgorithm>AES256</SSEAlgorithm></ApplyServerSideEncryptionByDefault></Rule></ServerSideEncryptionConfiguration>`:                                     s3err.ErrNone,
+		`<ServerSideEncryptionConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Rule><ApplyServerSideEncryptionByDefault><SSEAlgorithm>aws:kms</SSEAlgorithm><KMSMasterKeyID>k1</KMSMasterKeyID></ApplyServerSideEncryptionByDefault></Rule></ServerSideEncryptionConfiguration>`: s3err.ErrNone,
+		`<ServerSideEncryptionConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Rule><ApplyServerSideEncryptionByDefault><SSEAlgorithm>AES256</SSEAlgorithm><KMSMasterKeyID>k1</KMSMasterKeyID></ApplyServerSideEncryptionByDefault></Rule></ServerSideEncryptionConfiguration>`:  s3err.ErrInvalidRequest,
+		`<ServerSideEncryptionConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Rule><ApplyServerSideEncryptionByDefault><SSEAlgorithm>DES</SSEAlgorithm></ApplyServerSideEncryptionByDefault></Rule></ServerSideEncryptionConfiguration>`:                                        s3err.ErrInvalidEncryptionAlgorithm,
+		`<ServerSideEncryptionConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"></ServerSideEncryptionConfiguration>`:                                                                                                                                                              s3err.ErrMalformedXML,
+	} {
+		configuration := &ServerSideEncryptionConfiguration{}
+		if err := xml.Unmarshal([]byte(input), configuration); err != nil {
+			t.Fatalf("unmarshal %s: %v", input, err)
+		}
+		if errCode := configuration.validate(); errCode != expected {
+			t.Errorf("%s: expecting error %v, got %v", input, expected, errCode)
+		}
+	}
+}
+
+func TestSseResponseHeaders(t *testing.T) {
+
+	r, _ := http.NewRequest("PUT", "http://localhost/bucket/object", nil)
+	r.Header.Set(filer.SseAlgorithmHeader, filer.SseAlgorithmKms)
+	r.Header.Set(filer.SseKmsKeyIdHeader, "k1")
+
+	s3a := &S3ApiServer{}
+	if errCode := s3a.applySse(r, "bucket"); errCode != s3err.ErrNone {
+		t.Fatalf("unexpected error %v", errCode)
+	}
+
+	w := httptest.NewRecorder()
+	setSseResponseHeaders(w, r)
+	if w.Header().Get(filer.SseAlgorithmHeader) != filer.SseAlgorithmKms || w.Header().Get(filer.SseKmsKeyIdHeader) != "k1" {
+		t.Errorf("unexpected headers %v", w.Header())
+	}
+
+	r.Header.Set(filer.SseAlgorithmHeader, filer.SseAlgorithmAES256)
+	if errCode := s3a.applySse(r, "bucket"); errCode != s3err.ErrInvalidRequest {
+		t.Errorf("expecting invalid request for the kms key id with AES256, got %v", errCode)
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"github.com/chrislusf/seaweedfs/weed/s3api/s3_constants"
 	"math"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/chrislusf/seaweedfs/weed/filer"
 	xhttp "github.com/chrislusf/seaweedfs/weed/s3api/http"
 	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
 
@@ -35,11 +37,18 @@ func (s3a *S3ApiServer) ListBucketsHandler(w http.ResponseWriter, r *http.Reques
 			writeErrorResponse(w, s3Err, r.URL)
 			return
 		}
+		setAuthorizedUsage(r, identity)
 	}
 
 	var response ListAllMyBucketsResult
 
-	entries, _, err := s3a.list(s3a.option.BucketsPath, "", "", false, math.MaxInt32)
+	var entries []*filer_pb.Entry
+	var err error
+	if s3a.buckets != nil {
+		entries = s3a.buckets.list()
+	} else {
+		entries, _, err = s3a.list(s3a.option.BucketsPath, "", "", false, math.MaxInt32)
+	}
 
 	if err != nil {
 		writeErrorResponse(w, s3err.ErrInternalError, r.URL)
@@ -98,7 +107,7 @@ func (s3a *S3ApiServer) PutBucketHandler(w http.ResponseWriter, r *http.Request)
 		writeErrorResponse(w, s3err.ErrInternalError, r.URL)
 		return
 	}
-	if exist, err := s3a.exists(s3a.option.BucketsPath, bucket, true); err == nil && exist {
+	if entry, err := s3a.getBucketEntry(bucket); err == nil && entry != nil && entry.IsDirectory {
 		errCode = s3err.ErrBucketAlreadyExists
 	}
 	if errCode != s3err.ErrNone {
@@ -106,6 +115,27 @@ func (s3a *S3ApiServer) PutBucketHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	isObjectLockEnabled := false
+	switch strings.ToLower(r.Header.Get(xhttp.AmzBucketObjectLockEnabled)) {
+	case "true":
+		isObjectLockEnabled = true
+	case "", "false":
+	default:
+		writeErrorResponse(w, s3err.ErrInvalidRequest, r.URL)
+		return
+	}
+
+	cannedAcl := r.Header.Get(xhttp.AmzCannedAcl)
+	isPublic, ok := isPublicCannedAcl(cannedAcl)
+	if !ok {
+		writeErrorResponse(w, s3err.ErrInvalidRequest, r.URL)
+		return
+	}
+	if isPublic && s3a.option.BlockPublic {
+		writeErrorResponse(w, s3err.ErrAccessDenied, r.URL)
+		return
+	}
+
 	fn := func(entry *filer_pb.Entry) {
 		if identityId := r.Header.Get(xhttp.AmzIdentityId); identityId != "" {
 			if entry.Extended == nil {
@@ -113,6 +143,18 @@ func (s3a *S3ApiServer) PutBucketHandler(w http.ResponseWriter, r *http.Request)
 			}
 			entry.Extended[xhttp.AmzIdentityId] = []byte(identityId)
 		}
+		if isObjectLockEnabled {
+			if entry.Extended == nil {
+				entry.Extended = make(map[string][]byte)
+			}
+			entry.Extended[filer.ExtS3ObjectLockKey] = encodeResponse(&ObjectLockConfiguration{ObjectLockEnabled: ObjectLockEnabled})
+		}
+		if isPublic {
+			if entry.Extended == nil {
+				entry.Extended = make(map[string][]byte)
+			}
+			entry.Extended[ExtS3PublicAccessKey] = []byte(cannedAcl)
+		}
 	}
 
 	// create the folder for bucket, but lazily create actual collection
@@ -148,11 +190,22 @@ func (s3a *S3ApiServer) DeleteBucketHandler(w http.ResponseWriter, r *http.Reque
 
 		return nil
 	})
+	if err != nil {
+		// the held or retained files are kept with the bucket collection
+		if errCode := filerErrorToS3Error(err.Error()); errCode == s3err.ErrAccessDenied {
+			writeErrorResponse(w, errCode, r.URL)
+			return
+		}
+		glog.V(1).Infof("DeleteBucketHandler %s: %v", bucket, err)
+	}
 
 	err = s3a.rm(s3a.option.BucketsPath, bucket, false, true)
+	if err == nil && s3a.buckets != nil {
+		s3a.buckets.delete(bucket)
+	}
 
 	if err != nil {
-		writeErrorResponse(w, s3err.ErrInternalError, r.URL)
+		writeErrorResponse(w, filerErrorToS3Error(err.Error()), r.URL)
 		return
 	}
 
@@ -172,7 +225,7 @@ func (s3a *S3ApiServer) HeadBucketHandler(w http.ResponseWriter, r *http.Request
 }
 
 func (s3a *S3ApiServer) checkBucket(r *http.Request, bucket string) s3err.ErrorCode {
-	entry, err := s3a.getEntry(s3a.option.BucketsPath, bucket)
+	entry, err := s3a.getBucketEntry(bucket)
 	if entry == nil || err == filer_pb.ErrNotFound {
 		return s3err.ErrNoSuchBucket
 	}
@@ -0,0 +1,190 @@
+package s3api
+
+import (
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+)
+
+// the inventory reports are written by the filer, see filer_inventory.go
+
+const maxInventoryConfigurations = 1000
+
+// GetBucketInventoryConfigurationHandler Get Bucket Inventory Configuration
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketInventoryConfiguration.html
+func (s3a *S3ApiServer) GetBucketInventoryConfigurationHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, _ := getBucketAndObject(r)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		writeErrorResponse(w, err, r.URL)
+		return
+	}
+
+	inventories, errCode := s3a.getBucketInventories(bucket)
+	if errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	i := indexOfInventory(inventories, r.URL.Query().Get("id"))
+	if i < 0 {
+		writeErrorResponse(w, s3err.ErrNoSuchConfiguration, r.URL)
+		return
+	}
+
+	writeSuccessResponseXML(w, encodeResponse(inventories.Configurations[i]))
+}
+
+// PutBucketInventoryConfigurationHandler Put Bucket Inventory Configuration
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketInventoryConfiguration.html
+func (s3a *S3ApiServer) PutBucketInventoryConfigurationHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, _ := getBucketAndObject(r)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		writeErrorResponse(w, err, r.URL)
+		return
+	}
+
+	input, err := ioutil.ReadAll(io.LimitReader(r.Body, r.ContentLength))
+	if err != nil {
+		glog.Errorf("PutBucketInventoryConfigurationHandler read input %s: %v", r.URL, err)
+		writeErrorResponse(w, s3err.ErrInternalError, r.URL)
+		return
+	}
+	configuration := &filer.InventoryConfiguration{}
+	if err = xml.Unmarshal(input, configuration); err != nil {
+		glog.Errorf("PutBucketInventoryConfigurationHandler Unmarshal %s: %v", r.URL, err)
+		writeErrorResponse(w, s3err.ErrMalformedXML, r.URL)
+		return
+	}
+	if configuration.Id != r.URL.Query().Get("id") {
+		writeErrorResponse(w, s3err.ErrInvalidRequest, r.URL)
+		return
+	}
+	if err = configuration.Validate(); err != nil {
+		glog.V(1).Infof("PutBucketInventoryConfigurationHandler %s: %v", r.URL, err)
+		if err == filer.ErrInventoryNotImplemented {
+			writeErrorResponse(w, s3err.ErrNotImplemented, r.URL)
+		} else {
+			writeErrorResponse(w, s3err.ErrMalformedXML, r.URL)
+		}
+		return
+	}
+
+	// the reports are written with the permission to write into the destination bucket
+	if errCode := s3a.checkBucket(r, configuration.DestinationBucket()); errCode != s3err.ErrNone {
+		if errCode == s3err.ErrNoSuchBucket {
+			errCode = s3err.ErrInvalidRequest
+		}
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	inventories, errCode := s3a.getBucketInventories(bucket)
+	if errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	if i := indexOfInventory(inventories, configuration.Id); i >= 0 {
+		inventories.Configurations[i] = configuration
+	} else if len(inventories.Configurations) >= maxInventoryConfigurations {
+		writeErrorResponse(w, s3err.ErrTooManyConfigurations, r.URL)
+		return
+	} else {
+		inventories.Configurations = append(inventories.Configurations, configuration)
+	}
+
+	if errCode := s3a.setBucketExtended(bucket, filer.ExtS3InventoryKey, encodeResponse(inventories)); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	writeSuccessResponseEmpty(w)
+}
+
+// DeleteBucketInventoryConfigurationHandler Delete Bucket Inventory Configuration
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketInventoryConfiguration.html
+func (s3a *S3ApiServer) DeleteBucketInventoryConfigurationHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, _ := getBucketAndObject(r)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		writeErrorResponse(w, err, r.URL)
+		return
+	}
+
+	inventories, errCode := s3a.getBucketInventories(bucket)
+	if errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	i := indexOfInventory(inventories, r.URL.Query().Get("id"))
+	if i < 0 {
+		writeErrorResponse(w, s3err.ErrNoSuchConfiguration, r.URL)
+		return
+	}
+	inventories.Configurations = append(inventories.Configurations[:i], inventories.Configurations[i+1:]...)
+
+	var data []byte
+	if len(inventories.Configurations) > 0 {
+		data = encodeResponse(inventories)
+	}
+	if errCode := s3a.setBucketExtended(bucket, filer.ExtS3InventoryKey, data); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	writeResponse(w, http.StatusNoContent, nil, mimeNone)
+}
+
+// ListBucketInventoryConfigurationsHandler List Bucket Inventory Configurations, all in one page
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListBucketInventoryConfigurations.html
+func (s3a *S3ApiServer) ListBucketInventoryConfigurationsHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, _ := getBucketAndObject(r)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		writeErrorResponse(w, err, r.URL)
+		return
+	}
+
+	inventories, errCode := s3a.getBucketInventories(bucket)
+	if errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	writeSuccessResponseXML(w, encodeResponse(inventories))
+}
+
+// getBucketInventories returns the inventory configurations of the bucket, empty if not set
+func (s3a *S3ApiServer) getBucketInventories(bucket string) (*filer.InventoryConfigurations, s3err.ErrorCode) {
+	data, errCode := s3a.getBucketExtended(bucket, filer.ExtS3InventoryKey)
+	if errCode != s3err.ErrNone {
+		return nil, errCode
+	}
+	inventories, err := filer.LoadBucketInventories(map[string][]byte{filer.ExtS3InventoryKey: data})
+	if err != nil {
+		glog.Errorf("bucket %s inventory: %v", bucket, err)
+		return nil, s3err.ErrInternalError
+	}
+	if inventories == nil {
+		inventories = &filer.InventoryConfigurations{}
+	}
+	return inventories, s3err.ErrNone
+}
+
+func indexOfInventory(inventories *filer.InventoryConfigurations, id string) int {
+	for i, c := range inventories.Configurations {
+		if c.Id == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,61 @@
+package s3api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	xhttp "github.com/chrislusf/seaweedfs/weed/s3api/http"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucketInventoryHandlers(t *testing.T) {
+	s3a := &S3ApiServer{
+		option:  &S3ApiServerOption{BucketsPath: "/buckets"},
+		buckets: newBucketCache(),
+	}
+	inventories := encodeResponse(&filer.InventoryConfigurations{Configurations: []*filer.InventoryConfiguration{{
+		Id:                     "daily",
+		IsEnabled:              true,
+		Destination:            filer.InventoryDestination{S3BucketDestination: filer.InventoryS3BucketDestination{Bucket: "arn:aws:s3:::reports", Format: "CSV"}},
+		IncludedObjectVersions: "Current",
+		Schedule:               filer.InventorySchedule{Frequency: "Daily"},
+	}}})
+	s3a.buckets.set(&filer_pb.Entry{Name: "photos", IsDirectory: true, Extended: map[string][]byte{filer.ExtS3InventoryKey: inventories, xhttp.AmzIdentityId: []byte("alice")}})
+	s3a.buckets.set(&filer_pb.Entry{Name: "reports", IsDirectory: true, Extended: map[string][]byte{xhttp.AmzIdentityId: []byte("bob")}})
+
+	do := func(handler http.HandlerFunc, method, query, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/photos?inventory"+query, strings.NewReader(body))
+		r.Header.Set(xhttp.AmzIdentityId, "alice")
+		w := httptest.NewRecorder()
+		handler(w, mux.SetURLVars(r, map[string]string{"bucket": "photos"}))
+		return w
+	}
+
+	w := do(s3a.GetBucketInventoryConfigurationHandler, "GET", "&id=daily", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "<Bucket>arn:aws:s3:::reports</Bucket>")
+	assert.Equal(t, http.StatusNotFound, do(s3a.GetBucketInventoryConfigurationHandler, "GET", "&id=weekly", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(s3a.DeleteBucketInventoryConfigurationHandler, "DELETE", "&id=weekly", "").Code)
+
+	w = do(s3a.ListBucketInventoryConfigurationsHandler, "GET", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "<Id>daily</Id>")
+	assert.Contains(t, w.Body.String(), "<IsTruncated>false</IsTruncated>")
+
+	configuration := func(id, bucket, format string) string {
+		return `<InventoryConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Id>` + id + `</Id><IsEnabled>true</IsEnabled><Destination><S3BucketDestination><Bucket>` + bucket +
+			`</Bucket><Format>` + format + `</Format></S3BucketDestination></Destination><IncludedObjectVersions>Current</IncludedObjectVersions><Schedule><Frequency>Weekly</Frequency></Schedule></InventoryConfiguration>`
+	}
+	// the id in the query and the configuration
+	assert.Equal(t, http.StatusBadRequest, do(s3a.PutBucketInventoryConfigurationHandler, "PUT", "&id=other", configuration("weekly", "photos", "CSV")).Code)
+	assert.Equal(t, http.StatusNotImplemented, do(s3a.PutBucketInventoryConfigurationHandler, "PUT", "&id=weekly", configuration("weekly", "photos", "ORC")).Code)
+	assert.Equal(t, http.StatusBadRequest, do(s3a.PutBucketInventoryConfigurationHandler, "PUT", "&id=weekly", configuration("weekly", "photos", "JSON")).Code)
+	// the destination bucket should exist, and be writable by the owner
+	assert.Equal(t, http.StatusBadRequest, do(s3a.PutBucketInventoryConfigurationHandler, "PUT", "&id=weekly", configuration("weekly", "missing", "CSV")).Code)
+	assert.Equal(t, http.StatusForbidden, do(s3a.PutBucketInventoryConfigurationHandler, "PUT", "&id=weekly", configuration("weekly", "arn:aws:s3:::reports", "CSV")).Code)
+}
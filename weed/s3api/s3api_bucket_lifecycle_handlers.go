@@ -0,0 +1,286 @@
+package s3api
+
+import (
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+)
+
+const (
+	maxLifecycleRules      = 1000
+	lifecycleStatusEnabled = "Enabled"
+)
+
+type LifecycleConfiguration struct {
+	XMLName xml.Name        `xml:"http://s3.amazonaws.com/doc/2006-03-01/ LifecycleConfiguration"`
+	Rules   []LifecycleRule `xml:"Rule"`
+}
+
+type LifecycleRule struct {
+	ID                             string                          `xml:"ID,omitempty"`
+	Status                         string                          `xml:"Status"`
+	Prefix                         *string                         `xml:"Prefix,omitempty"` // deprecated, replaced by the filter
+	Filter                         *LifecycleFilter                `xml:"Filter,omitempty"`
+	Expiration                     *LifecycleExpiration            `xml:"Expiration,omitempty"`
+	NoncurrentVersionExpiration    *NoncurrentVersionExpiration    `xml:"NoncurrentVersionExpiration,omitempty"`
+	AbortIncompleteMultipartUpload *AbortIncompleteMultipartUpload `xml:"AbortIncompleteMultipartUpload,omitempty"`
+	Transitions                    []struct{}                      `xml:"Transition"`
+	NoncurrentVersionTransitions   []struct{}                      `xml:"NoncurrentVersionTransition"`
+}
+
+type LifecycleFilter struct {
+	Prefix *string             `xml:"Prefix,omitempty"`
+	Tag    *Tag                `xml:"Tag,omitempty"`
+	And    *LifecycleAndFilter `xml:"And,omitempty"`
+}
+
+type LifecycleAndFilter struct {
+	Prefix string `xml:"Prefix,omitempty"`
+	Tags   []Tag  `xml:"Tag"`
+}
+
+type LifecycleExpiration struct {
+	Days int32  `xml:"Days,omitempty"`
+	Date string `xml:"Date,omitempty"`
+}
+
+type NoncurrentVersionExpiration struct {
+	NoncurrentDays int32 `xml:"NoncurrentDays"`
+}
+
+type AbortIncompleteMultipartUpload struct {
+	DaysAfterInitiation int32 `xml:"DaysAfterInitiation"`
+}
+
+// GetBucketLifecycleConfigurationHandler Get Bucket Lifecycle
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketLifecycleConfiguration.html
+func (s3a *S3ApiServer) GetBucketLifecycleConfigurationHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, _ := getBucketAndObject(r)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		writeErrorResponse(w, err, r.URL)
+		return
+	}
+
+	data, errCode := s3a.getBucketExtended(bucket, filer.ExtS3LifecycleKey)
+	if errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	lifecycle, err := filer.LoadBucketLifecycle(map[string][]byte{filer.ExtS3LifecycleKey: data})
+	if err != nil {
+		glog.Errorf("GetBucketLifecycleConfigurationHandler %s: %v", r.URL, err)
+		writeErrorResponse(w, s3err.ErrInternalError, r.URL)
+		return
+	}
+	if lifecycle == nil || len(lifecycle.Rules) == 0 {
+		writeErrorResponse(w, s3err.ErrNoSuchLifecycleConfiguration, r.URL)
+		return
+	}
+
+	writeSuccessResponseXML(w, encodeResponse(FromBucketLifecycle(lifecycle)))
+}
+
+// PutBucketLifecycleConfigurationHandler Put Bucket Lifecycle
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketLifecycleConfiguration.html
+func (s3a *S3ApiServer) PutBucketLifecycleConfigurationHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, _ := getBucketAndObject(r)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		writeErrorResponse(w, err, r.URL)
+		return
+	}
+
+	input, err := ioutil.ReadAll(io.LimitReader(r.Body, r.ContentLength))
+	if err != nil {
+		glog.Errorf("PutBucketLifecycleConfigurationHandler read input %s: %v", r.URL, err)
+		writeErrorResponse(w, s3err.ErrInternalError, r.URL)
+		return
+	}
+	configuration := &LifecycleConfiguration{}
+	if err = xml.Unmarshal(input, configuration); err != nil {
+		glog.Errorf("PutBucketLifecycleConfigurationHandler Unmarshal %s: %v", r.URL, err)
+		writeErrorResponse(w, s3err.ErrMalformedXML, r.URL)
+		return
+	}
+	lifecycle, errCode := configuration.ToBucketLifecycle()
+	if errCode != s3err.ErrNone {
+		glog.V(1).Infof("PutBucketLifecycleConfigurationHandler %s: invalid configuration", r.URL)
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	data, err := proto.Marshal(lifecycle)
+	if err != nil {
+		glog.Errorf("PutBucketLifecycleConfigurationHandler marshal %s: %v", r.URL, err)
+		writeErrorResponse(w, s3err.ErrInternalError, r.URL)
+		return
+	}
+
+	if errCode = s3a.setBucketExtended(bucket, filer.ExtS3LifecycleKey, data); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	writeSuccessResponseEmpty(w)
+}
+
+// DeleteBucketLifecycleHandler Delete Bucket Lifecycle
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketLifecycle.html
+func (s3a *S3ApiServer) DeleteBucketLifecycleHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, _ := getBucketAndObject(r)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		writeErrorResponse(w, err, r.URL)
+		return
+	}
+
+	if errCode := s3a.setBucketExtended(bucket, filer.ExtS3LifecycleKey, nil); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	writeResponse(w, http.StatusNoContent, nil, mimeNone)
+}
+
+// ToBucketLifecycle validates the rules, and converts them to the rules run by the filer.
+// The transitions are not supported.
+func (c *LifecycleConfiguration) ToBucketLifecycle() (*filer_pb.BucketLifecycle, s3err.ErrorCode) {
+	if len(c.Rules) == 0 || len(c.Rules) > maxLifecycleRules {
+		return nil, s3err.ErrMalformedXML
+	}
+	lifecycle := &filer_pb.BucketLifecycle{}
+	ids := make(map[string]bool)
+	for _, r := range c.Rules {
+		if r.ID != "" {
+			if ids[r.ID] || len(r.ID) > 255 {
+				return nil, s3err.ErrInvalidRequest
+			}
+			ids[r.ID] = true
+		}
+		if len(r.Transitions) > 0 || len(r.NoncurrentVersionTransitions) > 0 {
+			return nil, s3err.ErrNotImplemented
+		}
+		if r.Status != lifecycleStatusEnabled && r.Status != "Disabled" {
+			return nil, s3err.ErrMalformedXML
+		}
+		rule := &filer_pb.BucketLifecycle_Rule{
+			Id:      r.ID,
+			Enabled: r.Status == lifecycleStatusEnabled,
+		}
+		if r.Prefix != nil {
+			if r.Filter != nil {
+				return nil, s3err.ErrMalformedXML
+			}
+			rule.Prefix = *r.Prefix
+		}
+		if f := r.Filter; f != nil {
+			filters := 0
+			if f.Prefix != nil {
+				rule.Prefix = *f.Prefix
+				filters++
+			}
+			var tags []Tag
+			if f.Tag != nil {
+				tags = append(tags, *f.Tag)
+				filters++
+			}
+			if f.And != nil {
+				rule.Prefix = f.And.Prefix
+				tags = append(tags, f.And.Tags...)
+				filters++
+			}
+			if filters > 1 {
+				return nil, s3err.ErrMalformedXML
+			}
+			for _, tag := range tags {
+				if rule.Tags == nil {
+					rule.Tags = make(map[string]string)
+				}
+				rule.Tags[tag.Key] = tag.Value
+			}
+		}
+		if e := r.Expiration; e != nil {
+			if e.Days < 0 || e.Days > 0 && e.Date != "" {
+				return nil, s3err.ErrInvalidRequest
+			}
+			rule.ExpirationDays = e.Days
+			if e.Date != "" {
+				date, err := time.Parse(time.RFC3339, e.Date)
+				if err != nil {
+					return nil, s3err.ErrMalformedDate
+				}
+				rule.ExpirationDate = date.Unix()
+			}
+		}
+		if e := r.NoncurrentVersionExpiration; e != nil {
+			if e.NoncurrentDays <= 0 {
+				return nil, s3err.ErrInvalidRequest
+			}
+			rule.NoncurrentVersionExpirationDays = e.NoncurrentDays
+		}
+		if a := r.AbortIncompleteMultipartUpload; a != nil {
+			if a.DaysAfterInitiation <= 0 || len(rule.Tags) > 0 {
+				return nil, s3err.ErrInvalidRequest
+			}
+			rule.AbortIncompleteMultipartUploadDays = a.DaysAfterInitiation
+		}
+		if rule.ExpirationDays == 0 && rule.ExpirationDate == 0 && rule.NoncurrentVersionExpirationDays == 0 && rule.AbortIncompleteMultipartUploadDays == 0 {
+			return nil, s3err.ErrInvalidRequest
+		}
+		lifecycle.Rules = append(lifecycle.Rules, rule)
+	}
+	return lifecycle, s3err.ErrNone
+}
+
+func FromBucketLifecycle(lifecycle *filer_pb.BucketLifecycle) *LifecycleConfiguration {
+	c := &LifecycleConfiguration{}
+	for _, rule := range lifecycle.Rules {
+		r := LifecycleRule{
+			ID:     rule.Id,
+			Status: "Disabled",
+		}
+		if rule.Enabled {
+			r.Status = lifecycleStatusEnabled
+		}
+		prefix := rule.Prefix
+		switch {
+		case len(rule.Tags) == 0:
+			r.Filter = &LifecycleFilter{Prefix: &prefix}
+		case len(rule.Tags) == 1 && prefix == "":
+			for k, v := range rule.Tags {
+				r.Filter = &LifecycleFilter{Tag: &Tag{Key: k, Value: v}}
+			}
+		default:
+			and := &LifecycleAndFilter{Prefix: prefix}
+			for k, v := range rule.Tags {
+				and.Tags = append(and.Tags, Tag{Key: k, Value: v})
+			}
+			r.Filter = &LifecycleFilter{And: and}
+		}
+		if rule.ExpirationDays > 0 {
+			r.Expiration = &LifecycleExpiration{Days: rule.ExpirationDays}
+		} else if rule.ExpirationDate > 0 {
+			r.Expiration = &LifecycleExpiration{Date: time.Unix(rule.ExpirationDate, 0).UTC().Format(time.RFC3339)}
+		}
+		if rule.NoncurrentVersionExpirationDays > 0 {
+			r.NoncurrentVersionExpiration = &NoncurrentVersionExpiration{NoncurrentDays: rule.NoncurrentVersionExpirationDays}
+		}
+		if rule.AbortIncompleteMultipartUploadDays > 0 {
+			r.AbortIncompleteMultipartUpload = &AbortIncompleteMultipartUpload{DaysAfterInitiation: rule.AbortIncompleteMultipartUploadDays}
+		}
+		c.Rules = append(c.Rules, r)
+	}
+	return c
+}
@@ -0,0 +1,71 @@
+package s3api
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+)
+
+func TestLifecycleConfiguration(t *testing.T) {
+
+	input := `<LifecycleConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Rule>
+    <ID>logs</ID>
+    <Filter><And><Prefix>logs/</Prefix><Tag><Key>tier</Key><Value>temp</Value></Tag></And></Filter>
+    <Status>Enabled</Status>
+    <Expiration><Days>7</Days></Expiration>
+    <NoncurrentVersionExpiration><NoncurrentDays>3</NoncurrentDays></NoncurrentVersionExpiration>
+  </Rule>
+  <Rule>
+    <ID>uploads</ID>
+    <Filter><Prefix></Prefix></Filter>
+    <Status>Disabled</Status>
+    <AbortIncompleteMultipartUpload><DaysAfterInitiation>1</DaysAfterInitiation></AbortIncompleteMultipartUpload>
+  </Rule>
+</LifecycleConfiguration>`
+
+	configuration := &LifecycleConfiguration{}
+	if err := xml.Unmarshal([]byte(input), configuration); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	lifecycle, errCode := configuration.ToBucketLifecycle()
+	if errCode != s3err.ErrNone {
+		t.Fatalf("unexpected error %v", errCode)
+	}
+	if len(lifecycle.Rules) != 2 {
+		t.Fatalf("unexpected rules %v", lifecycle.Rules)
+	}
+	logs := lifecycle.Rules[0]
+	if !logs.Enabled || logs.Prefix != "logs/" || logs.Tags["tier"] != "temp" || logs.ExpirationDays != 7 || logs.NoncurrentVersionExpirationDays != 3 {
+		t.Errorf("unexpected rule %v", logs)
+	}
+	uploads := lifecycle.Rules[1]
+	if uploads.Enabled || uploads.AbortIncompleteMultipartUploadDays != 1 {
+		t.Errorf("unexpected rule %v", uploads)
+	}
+
+	output := FromBucketLifecycle(lifecycle)
+	if len(output.Rules) != 2 || output.Rules[0].Filter.And == nil || output.Rules[1].Status != "Disabled" {
+		t.Errorf("unexpected output %+v", output)
+	}
+}
+
+func TestLifecycleConfigurationInvalid(t *testing.T) {
+
+	for input, expected := range map[string]s3err.ErrorCode{
+		`<LifecycleConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"></LifecycleConfiguration>`:                                                                                    s3err.ErrMalformedXML,
+		`<LifecycleConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Rule><Status>On</Status><Expiration><Days>1</Days></Expiration></Rule></LifecycleConfiguration>`:             s3err.ErrMalformedXML,
+		`<LifecycleConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Rule><Status>Enabled</Status></Rule></LifecycleConfiguration>`:                                               s3err.ErrInvalidRequest,
+		`<LifecycleConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Rule><Status>Enabled</Status><Transition><Days>1</Days></Transition></Rule></LifecycleConfiguration>`:        s3err.ErrNotImplemented,
+		`<LifecycleConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Rule><Status>Enabled</Status><Expiration><Date>tomorrow</Date></Expiration></Rule></LifecycleConfiguration>`: s3err.ErrMalformedDate,
+	} {
+		configuration := &LifecycleConfiguration{}
+		if err := xml.Unmarshal([]byte(input), configuration); err != nil {
+			t.Fatalf("unmarshal %s: %v", input, err)
+		}
+		if _, errCode := configuration.ToBucketLifecycle(); errCode != expected {
+			t.Errorf("%s: expecting error %v, got %v", input, expected, errCode)
+		}
+	}
+}
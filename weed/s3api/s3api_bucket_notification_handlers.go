@@ -0,0 +1,83 @@
+package s3api
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/notification/s3event"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+)
+
+const maxNotificationConfigurationSize = 64 * 1024
+
+// GetBucketNotificationHandler Get Bucket Notification Configuration
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketNotificationConfiguration.html
+func (s3a *S3ApiServer) GetBucketNotificationHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, _ := getBucketAndObject(r)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		writeErrorResponse(w, err, r.URL)
+		return
+	}
+
+	data, errCode := s3a.getBucketExtended(bucket, s3event.ExtS3NotificationKey)
+	if errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	configuration := &s3event.NotificationConfiguration{}
+	if len(data) > 0 {
+		var err error
+		if configuration, err = s3event.ParseNotificationConfiguration(data); err != nil {
+			glog.Errorf("bucket %s notification: %v", bucket, err)
+			writeErrorResponse(w, s3err.ErrInternalError, r.URL)
+			return
+		}
+	}
+
+	writeSuccessResponseXML(w, encodeResponse(configuration))
+}
+
+// PutBucketNotificationHandler Put Bucket Notification Configuration, all the rules are removed with an empty configuration
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketNotificationConfiguration.html
+func (s3a *S3ApiServer) PutBucketNotificationHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, _ := getBucketAndObject(r)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		writeErrorResponse(w, err, r.URL)
+		return
+	}
+
+	input, err := ioutil.ReadAll(io.LimitReader(r.Body, maxNotificationConfigurationSize))
+	if err != nil {
+		glog.Errorf("PutBucketNotificationHandler read input %s: %v", r.URL, err)
+		writeErrorResponse(w, s3err.ErrInternalError, r.URL)
+		return
+	}
+	configuration, err := s3event.ParseNotificationConfiguration(input)
+	if err != nil {
+		glog.Errorf("PutBucketNotificationHandler Unmarshal %s: %v", r.URL, err)
+		writeErrorResponse(w, s3err.ErrMalformedXML, r.URL)
+		return
+	}
+	if err = configuration.Validate(); err != nil {
+		glog.V(1).Infof("PutBucketNotificationHandler %s: %v", r.URL, err)
+		writeErrorResponse(w, s3err.ErrInvalidRequest, r.URL)
+		return
+	}
+
+	var value []byte
+	if len(configuration.Rules()) > 0 {
+		value = encodeResponse(configuration)
+	}
+	if errCode := s3a.setBucketExtended(bucket, s3event.ExtS3NotificationKey, value); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	writeSuccessResponseEmpty(w)
+}
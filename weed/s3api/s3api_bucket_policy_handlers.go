@@ -0,0 +1,99 @@
+package s3api
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/s3api/policy_engine"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+)
+
+const (
+	maxBucketPolicySize = 20 * 1024
+)
+
+// GetBucketPolicyHandler Get Bucket Policy
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketPolicy.html
+func (s3a *S3ApiServer) GetBucketPolicyHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, _ := getBucketAndObject(r)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		writeErrorResponse(w, err, r.URL)
+		return
+	}
+
+	data, errCode := s3a.getBucketExtended(bucket, ExtS3PolicyKey)
+	if errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	if len(data) == 0 {
+		writeErrorResponse(w, s3err.ErrNoSuchBucketPolicy, r.URL)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, data, mimeJSON)
+}
+
+// PutBucketPolicyHandler Put Bucket Policy
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketPolicy.html
+func (s3a *S3ApiServer) PutBucketPolicyHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, _ := getBucketAndObject(r)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		writeErrorResponse(w, err, r.URL)
+		return
+	}
+
+	input, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBucketPolicySize+1))
+	if err != nil {
+		glog.Errorf("PutBucketPolicyHandler read input %s: %v", r.URL, err)
+		writeErrorResponse(w, s3err.ErrInternalError, r.URL)
+		return
+	}
+	if len(input) > maxBucketPolicySize {
+		writeErrorResponse(w, s3err.ErrEntityTooLarge, r.URL)
+		return
+	}
+	policy, err := policy_engine.ParsePolicyDocument(input)
+	if err == nil {
+		err = policy.ValidateBucketPolicy(bucket)
+	}
+	if err != nil {
+		glog.V(1).Infof("PutBucketPolicyHandler %s: %v", r.URL, err)
+		writeErrorResponse(w, s3err.ErrMalformedPolicy, r.URL)
+		return
+	}
+
+	if errCode := s3a.setBucketExtended(bucket, ExtS3PolicyKey, input); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	s3a.evictBucketPolicy(bucket)
+
+	writeResponse(w, http.StatusNoContent, nil, mimeNone)
+}
+
+// DeleteBucketPolicyHandler Delete Bucket Policy
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketPolicy.html
+func (s3a *S3ApiServer) DeleteBucketPolicyHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, _ := getBucketAndObject(r)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		writeErrorResponse(w, err, r.URL)
+		return
+	}
+
+	if errCode := s3a.setBucketExtended(bucket, ExtS3PolicyKey, nil); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	s3a.evictBucketPolicy(bucket)
+
+	writeResponse(w, http.StatusNoContent, nil, mimeNone)
+}
@@ -0,0 +1,404 @@
+package s3api
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	xhttp "github.com/chrislusf/seaweedfs/weed/s3api/http"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3_constants"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+The website configuration is set by PutBucketWebsite, and kept in the ExtS3WebsiteKey extended attribute of the bucket.
+With the websiteDomainName option, e.g. "s3-website.example.com", the buckets are served as static sites on
+http://bucket.s3-website.example.com/, only for GET and HEAD, without the credentials:
+
+	/path/to/             is served with the index document, e.g. /path/to/index.html
+	/path/to              is redirected to /path/to/, if only /path/to/index.html is found
+	missing objects       are served with the error document, and the 403 or 404 status code
+
+The objects are only served if everyone can read them, by the "anonymous" identity, the bucket policies,
+or the public access of the bucket or the object. The routing rules redirect the requests by the key prefixes,
+or by the error codes, and RedirectAllRequestsTo redirects all requests to another host.
+*/
+
+const (
+	ExtS3WebsiteKey = "x-seaweedfs-s3-website"
+	maxRoutingRules = 50
+)
+
+type WebsiteConfiguration struct {
+	XMLName               xml.Name               `xml:"http://s3.amazonaws.com/doc/2006-03-01/ WebsiteConfiguration"`
+	RedirectAllRequestsTo *RedirectAllRequestsTo `xml:"RedirectAllRequestsTo,omitempty"`
+	IndexDocument         *IndexDocument         `xml:"IndexDocument,omitempty"`
+	ErrorDocument         *ErrorDocument         `xml:"ErrorDocument,omitempty"`
+	RoutingRules          []RoutingRule          `xml:"RoutingRules>RoutingRule,omitempty"`
+}
+
+type RedirectAllRequestsTo struct {
+	HostName string `xml:"HostName"`
+	Protocol string `xml:"Protocol,omitempty"`
+}
+
+type IndexDocument struct {
+	Suffix string `xml:"Suffix"`
+}
+
+type ErrorDocument struct {
+	Key string `xml:"Key"`
+}
+
+type RoutingRule struct {
+	Condition *RoutingRuleCondition `xml:"Condition,omitempty"`
+	Redirect  RoutingRuleRedirect   `xml:"Redirect"`
+}
+
+type RoutingRuleCondition struct {
+	HttpErrorCodeReturnedEquals string `xml:"HttpErrorCodeReturnedEquals,omitempty"`
+	KeyPrefixEquals             string `xml:"KeyPrefixEquals,omitempty"`
+}
+
+type RoutingRuleRedirect struct {
+	HostName             string  `xml:"HostName,omitempty"`
+	HttpRedirectCode     string  `xml:"HttpRedirectCode,omitempty"`
+	Protocol             string  `xml:"Protocol,omitempty"`
+	ReplaceKeyPrefixWith *string `xml:"ReplaceKeyPrefixWith"`
+	ReplaceKeyWith       *string `xml:"ReplaceKeyWith"`
+}
+
+// GetBucketWebsiteHandler Get Bucket Website
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketWebsite.html
+func (s3a *S3ApiServer) GetBucketWebsiteHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, _ := getBucketAndObject(r)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		writeErrorResponse(w, err, r.URL)
+		return
+	}
+
+	configuration, errCode := s3a.getBucketWebsite(bucket)
+	if errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	if configuration == nil {
+		writeErrorResponse(w, s3err.ErrNoSuchWebsiteConfiguration, r.URL)
+		return
+	}
+
+	writeSuccessResponseXML(w, encodeResponse(configuration))
+}
+
+// PutBucketWebsiteHandler Put Bucket Website
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketWebsite.html
+func (s3a *S3ApiServer) PutBucketWebsiteHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, _ := getBucketAndObject(r)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		writeErrorResponse(w, err, r.URL)
+		return
+	}
+
+	input, err := ioutil.ReadAll(io.LimitReader(r.Body, r.ContentLength))
+	if err != nil {
+		glog.Errorf("PutBucketWebsiteHandler read input %s: %v", r.URL, err)
+		writeErrorResponse(w, s3err.ErrInternalError, r.URL)
+		return
+	}
+	configuration := &WebsiteConfiguration{}
+	if err = xml.Unmarshal(input, configuration); err != nil {
+		glog.Errorf("PutBucketWebsiteHandler Unmarshal %s: %v", r.URL, err)
+		writeErrorResponse(w, s3err.ErrMalformedXML, r.URL)
+		return
+	}
+	if errCode := configuration.validate(); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	if errCode := s3a.setBucketExtended(bucket, ExtS3WebsiteKey, encodeResponse(configuration)); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	writeSuccessResponseEmpty(w)
+}
+
+// DeleteBucketWebsiteHandler Delete Bucket Website
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketWebsite.html
+func (s3a *S3ApiServer) DeleteBucketWebsiteHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, _ := getBucketAndObject(r)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		writeErrorResponse(w, err, r.URL)
+		return
+	}
+
+	if errCode := s3a.setBucketExtended(bucket, ExtS3WebsiteKey, nil); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	writeResponse(w, http.StatusNoContent, nil, mimeNone)
+}
+
+// WebsiteHandler serves the GET and HEAD requests on the website endpoints
+func (s3a *S3ApiServer) WebsiteHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, object := getBucketAndObject(r)
+
+	// the website requests are always anonymous
+	r.Header.Del(xhttp.AmzIdentityId)
+	r.Header.Del(xhttp.AmzIsAdmin)
+
+	configuration, errCode := s3a.getBucketWebsite(bucket)
+	if errCode == s3err.ErrNone && configuration == nil {
+		errCode = s3err.ErrNoSuchWebsiteConfiguration
+	}
+	if errCode != s3err.ErrNone {
+		writeWebsiteErrorResponse(w, errCode)
+		return
+	}
+
+	if redirectAll := configuration.RedirectAllRequestsTo; redirectAll != nil {
+		protocol := redirectAll.Protocol
+		if protocol == "" {
+			protocol = requestProtocol(r)
+		}
+		http.Redirect(w, r, protocol+"://"+redirectAll.HostName+r.URL.RequestURI(), http.StatusMovedPermanently)
+		return
+	}
+
+	key := strings.TrimPrefix(object, "/")
+	if location, code := configuration.routingRedirect(r, key, 0); location != "" {
+		http.Redirect(w, r, location, code)
+		return
+	}
+
+	found, errCode := s3a.serveWebsiteObject(w, r, bucket, websiteKey(key, configuration.IndexDocument.Suffix), http.StatusOK)
+	if found {
+		return
+	}
+	if errCode == s3err.ErrNoSuchKey && key != "" && !strings.HasSuffix(key, "/") {
+		// the "directory" with the index document
+		indexKey := websiteKey(key+"/", configuration.IndexDocument.Suffix)
+		if s3a.isWebsiteObjectFound(r, bucket, indexKey) {
+			http.Redirect(w, r, "/"+urlPathEscape(key)+"/", http.StatusFound)
+			return
+		}
+	}
+
+	statusCode := s3err.GetAPIError(errCode).HTTPStatusCode
+	if location, code := configuration.routingRedirect(r, key, statusCode); location != "" {
+		http.Redirect(w, r, location, code)
+		return
+	}
+	if configuration.ErrorDocument != nil && (errCode == s3err.ErrNoSuchKey || errCode == s3err.ErrAccessDenied) {
+		if found, _ = s3a.serveWebsiteObject(w, r, bucket, configuration.ErrorDocument.Key, statusCode); found {
+			return
+		}
+	}
+	writeWebsiteErrorResponse(w, errCode)
+}
+
+// websiteMethodNotAllowedHandler rejects the other requests on the website endpoints
+func websiteMethodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	writeWebsiteErrorResponse(w, s3err.ErrMethodNotAllowed)
+}
+
+// serveWebsiteObject serves the object if everyone can read it, or returns the error otherwise
+func (s3a *S3ApiServer) serveWebsiteObject(w http.ResponseWriter, r *http.Request, bucket, key string, statusCode int) (found bool, errCode s3err.ErrorCode) {
+	object := "/" + key
+	if errCode = s3a.iam.authorizeWebsite(r, bucket, object); errCode != s3err.ErrNone {
+		return false, errCode
+	}
+
+	dir, name := util.FullPath(fmt.Sprintf("%s/%s%s", s3a.option.BucketsPath, bucket, object)).DirAndName()
+	entry, err := s3a.getEntry(dir, name)
+	if err == filer_pb.ErrNotFound || err == nil && (entry == nil || entry.IsDirectory) {
+		return false, s3err.ErrNoSuchKey
+	}
+	if err != nil {
+		glog.Errorf("website %s%s: %v", bucket, object, err)
+		return false, s3err.ErrInternalError
+	}
+
+	destUrl := fmt.Sprintf("http://%s%s/%s%s",
+		s3a.option.Filer, s3a.option.BucketsPath, bucket, urlPathEscape(object))
+
+	s3a.proxyToFiler(w, r, destUrl, func(proxyResponse *http.Response, w http.ResponseWriter) {
+		if statusCode == http.StatusOK {
+			passThroughResponse(proxyResponse, w)
+			return
+		}
+		// the error document
+		for k, v := range proxyResponse.Header {
+			if k != "Content-Range" {
+				w.Header()[k] = v
+			}
+		}
+		w.WriteHeader(statusCode)
+		io.Copy(w, proxyResponse.Body)
+	})
+	return true, s3err.ErrNone
+}
+
+func (s3a *S3ApiServer) isWebsiteObjectFound(r *http.Request, bucket, key string) bool {
+	object := "/" + key
+	if s3a.iam.authorizeWebsite(r, bucket, object) != s3err.ErrNone {
+		return false
+	}
+	dir, name := util.FullPath(fmt.Sprintf("%s/%s%s", s3a.option.BucketsPath, bucket, object)).DirAndName()
+	entry, err := s3a.getEntry(dir, name)
+	return err == nil && entry != nil && !entry.IsDirectory
+}
+
+// authorizeWebsite checks whether everyone can read the object, or the identities are not enabled
+func (iam *IdentityAccessManagement) authorizeWebsite(r *http.Request, bucket, object string) s3err.ErrorCode {
+	if !iam.isEnabled() {
+		return s3err.ErrNone
+	}
+	identity, _ := iam.lookupAnonymous()
+	errCode := iam.authorize(r, identity, s3_constants.ACTION_READ, bucket, object)
+	if errCode == s3err.ErrNone {
+		setAuthorizedUsage(r, identity)
+	}
+	return errCode
+}
+
+func (s3a *S3ApiServer) getBucketWebsite(bucket string) (*WebsiteConfiguration, s3err.ErrorCode) {
+	data, errCode := s3a.getBucketExtended(bucket, ExtS3WebsiteKey)
+	if errCode != s3err.ErrNone || len(data) == 0 {
+		return nil, errCode
+	}
+	configuration := &WebsiteConfiguration{}
+	if err := xml.Unmarshal(data, configuration); err != nil {
+		glog.Errorf("bucket %s website: %v", bucket, err)
+		return nil, s3err.ErrInternalError
+	}
+	return configuration, s3err.ErrNone
+}
+
+// websiteKey appends the index document to the keys of the "directories"
+func websiteKey(key, suffix string) string {
+	if key == "" || strings.HasSuffix(key, "/") {
+		return key + suffix
+	}
+	return key
+}
+
+// routingRedirect returns the redirect of the first matched routing rule, empty if not matched.
+// The rules with the error codes are only matched with the status code of the response.
+func (c *WebsiteConfiguration) routingRedirect(r *http.Request, key string, statusCode int) (location string, redirectCode int) {
+	for _, rule := range c.RoutingRules {
+		prefix := ""
+		if condition := rule.Condition; condition != nil {
+			if condition.HttpErrorCodeReturnedEquals != "" && condition.HttpErrorCodeReturnedEquals != strconv.Itoa(statusCode) {
+				continue
+			}
+			if condition.HttpErrorCodeReturnedEquals == "" && statusCode != 0 {
+				continue
+			}
+			if !strings.HasPrefix(key, condition.KeyPrefixEquals) {
+				continue
+			}
+			prefix = condition.KeyPrefixEquals
+		} else if statusCode != 0 {
+			continue
+		}
+
+		redirect := rule.Redirect
+		switch {
+		case redirect.ReplaceKeyWith != nil:
+			key = *redirect.ReplaceKeyWith
+		case redirect.ReplaceKeyPrefixWith != nil:
+			key = *redirect.ReplaceKeyPrefixWith + key[len(prefix):]
+		}
+		protocol, host := redirect.Protocol, redirect.HostName
+		if protocol == "" {
+			protocol = requestProtocol(r)
+		}
+		if host == "" {
+			host = r.Host
+		}
+		redirectCode = http.StatusMovedPermanently
+		if redirect.HttpRedirectCode != "" {
+			redirectCode, _ = strconv.Atoi(redirect.HttpRedirectCode)
+		}
+		return protocol + "://" + host + "/" + urlPathEscape(key), redirectCode
+	}
+	return "", 0
+}
+
+func (c *WebsiteConfiguration) validate() s3err.ErrorCode {
+	if redirectAll := c.RedirectAllRequestsTo; redirectAll != nil {
+		if redirectAll.HostName == "" || !isWebsiteProtocol(redirectAll.Protocol) {
+			return s3err.ErrInvalidRequest
+		}
+		if c.IndexDocument != nil || c.ErrorDocument != nil || len(c.RoutingRules) > 0 {
+			return s3err.ErrInvalidRequest
+		}
+		return s3err.ErrNone
+	}
+	if c.IndexDocument == nil || c.IndexDocument.Suffix == "" || strings.Contains(c.IndexDocument.Suffix, "/") {
+		return s3err.ErrInvalidRequest
+	}
+	if c.ErrorDocument != nil && c.ErrorDocument.Key == "" {
+		return s3err.ErrInvalidRequest
+	}
+	if len(c.RoutingRules) > maxRoutingRules {
+		return s3err.ErrInvalidRequest
+	}
+	for _, rule := range c.RoutingRules {
+		if condition := rule.Condition; condition != nil {
+			if condition.KeyPrefixEquals == "" && condition.HttpErrorCodeReturnedEquals == "" {
+				return s3err.ErrMalformedXML
+			}
+			if code := condition.HttpErrorCodeReturnedEquals; code != "" && !isStatusCodeIn(code, 400, 599) {
+				return s3err.ErrInvalidRequest
+			}
+		}
+		redirect := rule.Redirect
+		if redirect.ReplaceKeyWith != nil && redirect.ReplaceKeyPrefixWith != nil {
+			return s3err.ErrInvalidRequest
+		}
+		if !isWebsiteProtocol(redirect.Protocol) {
+			return s3err.ErrInvalidRequest
+		}
+		if code := redirect.HttpRedirectCode; code != "" && !isStatusCodeIn(code, 300, 399) {
+			return s3err.ErrInvalidRequest
+		}
+	}
+	return s3err.ErrNone
+}
+
+func isWebsiteProtocol(protocol string) bool {
+	return protocol == "" || protocol == "http" || protocol == "https"
+}
+
+func isStatusCodeIn(code string, min, max int) bool {
+	statusCode, err := strconv.Atoi(code)
+	return err == nil && min <= statusCode && statusCode <= max
+}
+
+// writeWebsiteErrorResponse writes the errors as the html pages, for the browsers
+func writeWebsiteErrorResponse(w http.ResponseWriter, errorCode s3err.ErrorCode) {
+	apiError := s3err.GetAPIError(errorCode)
+	title := fmt.Sprintf("%d %s", apiError.HTTPStatusCode, http.StatusText(apiError.HTTPStatusCode))
+	body := fmt.Sprintf("<html>\n<head><title>%s</title></head>\n<body>\n<h1>%s</h1>\n<ul>\n<li>Code: %s</li>\n<li>Message: %s</li>\n</ul>\n</body>\n</html>\n",
+		title, title, apiError.Code, html.EscapeString(apiError.Description))
+	writeResponse(w, apiError.HTTPStatusCode, []byte(body), mimeHTML)
+}
@@ -0,0 +1,106 @@
+package s3api
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebsiteConfigurationValidate(t *testing.T) {
+	for input, expected := range map[string]s3err.ErrorCode{
+		`<WebsiteConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><IndexDocument><Suffix>index.html</Suffix></IndexDocument><ErrorDocument><Key>404.html</Key></ErrorDocument></WebsiteConfiguration>`:                                                                                                                                                  s3err.ErrNone,
+		`<WebsiteConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><RedirectAllRequestsTo><HostName>example.com</HostName><Protocol>https</Protocol></RedirectAllRequestsTo></WebsiteConfiguration>`:                                                                                                                                                     s3err.ErrNone,
+		`<WebsiteConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><RedirectAllRequestsTo><HostName>example.com</HostName><Protocol>ftp</Protocol></RedirectAllRequestsTo></WebsiteConfiguration>`:                                                                                                                                                       s3err.ErrInvalidRequest,
+		`<WebsiteConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><ErrorDocument><Key>404.html</Key></ErrorDocument></WebsiteConfiguration>`:                                                                                                                                                                                                            s3err.ErrInvalidRequest,
+		`<WebsiteConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><IndexDocument><Suffix>a/index.html</Suffix></IndexDocument></WebsiteConfiguration>`:                                                                                                                                                                                                  s3err.ErrInvalidRequest,
+		`<WebsiteConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><IndexDocument><Suffix>index.html</Suffix></IndexDocument><RoutingRules><RoutingRule><Condition><KeyPrefixEquals>docs/</KeyPrefixEquals></Condition><Redirect><ReplaceKeyPrefixWith>documents/</ReplaceKeyPrefixWith></Redirect></RoutingRule></RoutingRules></WebsiteConfiguration>`: s3err.ErrNone,
+		`<WebsiteConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><IndexDocument><Suffix>index.html</Suffix></IndexDocument><RoutingRules><RoutingRule><Redirect><ReplaceKeyPrefixWith>a</ReplaceKeyPrefixWith><ReplaceKeyWith>b</ReplaceKeyWith></Redirect></RoutingRule></RoutingRules></WebsiteConfiguration>`:                                       s3err.ErrInvalidRequest,
+		`<WebsiteConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><IndexDocument><Suffix>index.html</Suffix></IndexDocument><RoutingRules><RoutingRule><Condition><HttpErrorCodeReturnedEquals>200</HttpErrorCodeReturnedEquals></Condition><Redirect><HostName>example.com</HostName></Redirect></RoutingRule></RoutingRules></WebsiteConfiguration>`:  s3err.ErrInvalidRequest,
+		`<WebsiteConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><IndexDocument><Suffix>index.html</Suffix></IndexDocument><RoutingRules><RoutingRule><Redirect><HttpRedirectCode>200</HttpRedirectCode></Redirect></RoutingRule></RoutingRules></WebsiteConfiguration>`:                                                                               s3err.ErrInvalidRequest,
+	} {
+		configuration := &WebsiteConfiguration{}
+		if assert.NoError(t, xml.Unmarshal([]byte(input), configuration), input) {
+			assert.Equal(t, expected, configuration.validate(), input)
+		}
+	}
+}
+
+func TestWebsiteKey(t *testing.T) {
+	assert.Equal(t, "index.html", websiteKey("", "index.html"))
+	assert.Equal(t, "docs/index.html", websiteKey("docs/", "index.html"))
+	assert.Equal(t, "docs", websiteKey("docs", "index.html"))
+	assert.Equal(t, "docs/a.html", websiteKey("docs/a.html", "index.html"))
+}
+
+func TestRoutingRedirect(t *testing.T) {
+	documents, empty := "documents/", ""
+	configuration := &WebsiteConfiguration{
+		IndexDocument: &IndexDocument{Suffix: "index.html"},
+		RoutingRules: []RoutingRule{
+			{Condition: &RoutingRuleCondition{KeyPrefixEquals: "docs/"}, Redirect: RoutingRuleRedirect{ReplaceKeyPrefixWith: &documents}},
+			{Condition: &RoutingRuleCondition{KeyPrefixEquals: "old/"}, Redirect: RoutingRuleRedirect{ReplaceKeyPrefixWith: &empty, HttpRedirectCode: "302"}},
+			{Condition: &RoutingRuleCondition{HttpErrorCodeReturnedEquals: "404"}, Redirect: RoutingRuleRedirect{HostName: "example.com", Protocol: "https", ReplaceKeyWith: &empty}},
+		},
+	}
+	r := httptest.NewRequest("GET", "http://site.s3-website.example.com/docs/a%20b.html", nil)
+
+	location, code := configuration.routingRedirect(r, "docs/a b.html", 0)
+	assert.Equal(t, "http://site.s3-website.example.com/documents/a%20b.html", location)
+	assert.Equal(t, http.StatusMovedPermanently, code)
+
+	location, code = configuration.routingRedirect(r, "old/page.html", 0)
+	assert.Equal(t, "http://site.s3-website.example.com/page.html", location)
+	assert.Equal(t, http.StatusFound, code)
+
+	location, _ = configuration.routingRedirect(r, "missing.html", 0)
+	assert.Equal(t, "", location)
+	location, code = configuration.routingRedirect(r, "missing.html", http.StatusNotFound)
+	assert.Equal(t, "https://example.com/", location)
+	assert.Equal(t, http.StatusMovedPermanently, code)
+	location, _ = configuration.routingRedirect(r, "missing.html", http.StatusForbidden)
+	assert.Equal(t, "", location)
+}
+
+func TestWebsiteEndpoint(t *testing.T) {
+	s3a := &S3ApiServer{
+		option:  &S3ApiServerOption{DomainName: "s3.example.com", WebsiteDomainName: "s3-website.example.com", BucketsPath: "/buckets"},
+		iam:     &IdentityAccessManagement{},
+		buckets: newBucketCache(),
+		usage:   newUsageMeter(),
+	}
+	redirectAll := encodeResponse(&WebsiteConfiguration{RedirectAllRequestsTo: &RedirectAllRequestsTo{HostName: "www.example.com"}})
+	s3a.buckets.set(&filer_pb.Entry{Name: "moved", IsDirectory: true, Extended: map[string][]byte{ExtS3WebsiteKey: redirectAll}})
+	s3a.buckets.set(&filer_pb.Entry{Name: "plain", IsDirectory: true})
+
+	router := mux.NewRouter().SkipClean(true)
+	s3a.registerRouter(router)
+
+	for _, c := range []struct {
+		method, target string
+		code           int
+		location       string
+	}{
+		{"GET", "http://moved.s3-website.example.com/a.html?x=1", http.StatusMovedPermanently, "http://www.example.com/a.html?x=1"},
+		{"HEAD", "http://moved.s3-website.example.com:8333/", http.StatusMovedPermanently, "http://www.example.com/"},
+		{"GET", "http://plain.s3-website.example.com/a.html", http.StatusNotFound, ""},
+		{"PUT", "http://plain.s3-website.example.com/a.html", http.StatusMethodNotAllowed, ""},
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(c.method, c.target, nil))
+		assert.Equal(t, c.code, w.Code, c.target)
+		assert.Equal(t, c.location, w.Header().Get("Location"), c.target)
+	}
+
+	// the website domain is not routed to the api
+	var match mux.RouteMatch
+	if assert.True(t, router.Match(httptest.NewRequest("GET", "http://plain.s3-website.example.com/?acl", nil), &match)) {
+		assert.Equal(t, "plain", match.Vars["bucket"])
+		assert.Equal(t, "", match.Vars["object"])
+	}
+}
@@ -24,6 +24,7 @@ const (
 	mimeNone mimeType = ""
 	mimeJSON mimeType = "application/json"
 	mimeXML  mimeType = "application/xml"
+	mimeHTML mimeType = "text/html; charset=utf-8"
 )
 
 func setCommonHeaders(w http.ResponseWriter) {
@@ -0,0 +1,223 @@
+package s3api
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	xhttp "github.com/chrislusf/seaweedfs/weed/s3api/http"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// the additional checksums are verified and kept by the filer, see filer_s3_checksum.go
+
+const (
+	ObjectAttributeETag         = "ETag"
+	ObjectAttributeChecksum     = "Checksum"
+	ObjectAttributeObjectParts  = "ObjectParts"
+	ObjectAttributeStorageClass = "StorageClass"
+	ObjectAttributeObjectSize   = "ObjectSize"
+)
+
+// s3Checksums are the checksum elements of the responses, not in the s3 types of this sdk version
+type s3Checksums struct {
+	ChecksumCRC32  string `xml:",omitempty"`
+	ChecksumCRC32C string `xml:",omitempty"`
+	ChecksumSHA1   string `xml:",omitempty"`
+	ChecksumSHA256 string `xml:",omitempty"`
+}
+
+func newS3Checksums(algorithm, checksum string) (c s3Checksums) {
+	switch algorithm {
+	case filer.S3ChecksumCRC32:
+		c.ChecksumCRC32 = checksum
+	case filer.S3ChecksumCRC32C:
+		c.ChecksumCRC32C = checksum
+	case filer.S3ChecksumSHA1:
+		c.ChecksumSHA1 = checksum
+	case filer.S3ChecksumSHA256:
+		c.ChecksumSHA256 = checksum
+	}
+	return
+}
+
+type GetObjectAttributesResponse struct {
+	XMLName      xml.Name                  `xml:"http://s3.amazonaws.com/doc/2006-03-01/ GetObjectAttributesResponse"`
+	ETag         string                    `xml:",omitempty"`
+	Checksum     *s3Checksums              `xml:",omitempty"`
+	ObjectParts  *GetObjectAttributesParts `xml:",omitempty"`
+	StorageClass string                    `xml:",omitempty"`
+	ObjectSize   *int64                    `xml:",omitempty"`
+}
+
+// GetObjectAttributesParts only has the number of the parts, the parts are not kept after the upload completes
+type GetObjectAttributesParts struct {
+	TotalPartsCount int
+}
+
+// GetObjectAttributesHandler Get Object Attributes
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectAttributes.html
+func (s3a *S3ApiServer) GetObjectAttributesHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, object := getBucketAndObject(r)
+
+	attributes, errCode := parseObjectAttributes(r.Header.Get(xhttp.AmzObjectAttributes))
+	if errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	target := util.FullPath(fmt.Sprintf("%s/%s%s", s3a.option.BucketsPath, bucket, object))
+	dir, name := target.DirAndName()
+
+	entry, err := s3a.getEntry(dir, name)
+	if err != nil {
+		glog.Errorf("GetObjectAttributesHandler %s: %v", r.URL, err)
+		if err == filer_pb.ErrNotFound {
+			writeErrorResponse(w, s3err.ErrNoSuchKey, r.URL)
+		} else {
+			writeErrorResponse(w, s3err.ErrInternalError, r.URL)
+		}
+		return
+	}
+	if entry.IsDirectory {
+		writeErrorResponse(w, s3err.ErrNoSuchKey, r.URL)
+		return
+	}
+
+	if entry.Attributes != nil {
+		w.Header().Set("Last-Modified", time.Unix(entry.Attributes.Mtime, 0).UTC().Format(http.TimeFormat))
+	}
+	writeSuccessResponseXML(w, encodeResponse(objectAttributesOf(entry, attributes)))
+}
+
+// parseObjectAttributes parses the comma separated attributes to return, at least one is required
+func parseObjectAttributes(header string) (attributes map[string]bool, errCode s3err.ErrorCode) {
+	attributes = make(map[string]bool)
+	for _, attribute := range strings.Split(header, ",") {
+		attribute = strings.TrimSpace(attribute)
+		switch attribute {
+		case "":
+			continue
+		case ObjectAttributeETag, ObjectAttributeChecksum, ObjectAttributeObjectParts, ObjectAttributeStorageClass, ObjectAttributeObjectSize:
+			attributes[attribute] = true
+		default:
+			return nil, s3err.ErrInvalidRequest
+		}
+	}
+	if len(attributes) == 0 {
+		return nil, s3err.ErrInvalidRequest
+	}
+	return attributes, s3err.ErrNone
+}
+
+func objectAttributesOf(entry *filer_pb.Entry, attributes map[string]bool) *GetObjectAttributesResponse {
+	response := &GetObjectAttributesResponse{}
+	if attributes[ObjectAttributeETag] {
+		response.ETag = filer.ETag(entry)
+	}
+	if attributes[ObjectAttributeChecksum] {
+		if algorithm, checksum := filer.StoredS3Checksum(entry.Extended); algorithm != "" {
+			checksums := newS3Checksums(algorithm, checksum)
+			response.Checksum = &checksums
+		}
+	}
+	if attributes[ObjectAttributeObjectParts] {
+		if count, err := strconv.Atoi(string(entry.Extended[filer.ExtS3PartsCountKey])); err == nil {
+			response.ObjectParts = &GetObjectAttributesParts{TotalPartsCount: count}
+		}
+	}
+	if attributes[ObjectAttributeStorageClass] {
+		response.StorageClass = "STANDARD"
+		if storageClass := string(entry.Extended[xhttp.AmzStorageClass]); storageClass != "" {
+			response.StorageClass = storageClass
+		}
+	}
+	if attributes[ObjectAttributeObjectSize] {
+		size := int64(filer.FileSize(entry))
+		response.ObjectSize = &size
+	}
+	return response
+}
+
+// applyChecksum validates the requested checksum of the upload, which is verified by the filer
+func applyChecksum(r *http.Request) s3err.ErrorCode {
+	switch r.Header.Get("X-Amz-Content-Sha256") {
+	case streamingContentSHA256Trailer, streamingECDSAContentSHA256, streamingECDSAContentSHA256Trailer:
+		// the trailer signatures and the ecdsa chunk signatures are not verified
+		return s3err.ErrNotImplemented
+	}
+	requested, err := filer.RequestedS3Checksum(r.Header)
+	if err != nil {
+		glog.V(1).Infof("%s: %v", r.URL, err)
+		return s3err.ErrInvalidRequest
+	}
+	requestedAlgorithm := filer.S3ChecksumTrailerAlgorithm(r.Header)
+	if requested != nil {
+		if requestedAlgorithm != "" {
+			return s3err.ErrInvalidRequest
+		}
+		requestedAlgorithm = requested.Algorithm
+	} else if isRequestUnsignedTrailer(r) && requestedAlgorithm == "" {
+		return s3err.ErrInvalidRequest
+	}
+	if algorithm := r.Header.Get(xhttp.AmzChecksumAlgorithm); algorithm != "" {
+		if !filer.IsS3ChecksumAlgorithm(algorithm) || requestedAlgorithm != "" && !strings.EqualFold(requestedAlgorithm, algorithm) {
+			return s3err.ErrInvalidRequest
+		}
+	}
+	return s3err.ErrNone
+}
+
+// applyChecksumUpload requires the parts to have the checksums of the algorithm of the multipart upload, if any
+func applyChecksumUpload(r *http.Request, uploadEntry *filer_pb.Entry) s3err.ErrorCode {
+	if errCode := applyChecksum(r); errCode != s3err.ErrNone {
+		return errCode
+	}
+	algorithm := string(uploadEntry.Extended[xhttp.AmzChecksumAlgorithm])
+	if algorithm == "" {
+		return s3err.ErrNone
+	}
+	if requested, _ := filer.RequestedS3Checksum(r.Header); requested != nil && requested.Algorithm != algorithm {
+		return s3err.ErrInvalidRequest
+	}
+	if trailerAlgorithm := filer.S3ChecksumTrailerAlgorithm(r.Header); trailerAlgorithm != "" && trailerAlgorithm != algorithm {
+		return s3err.ErrInvalidRequest
+	}
+	return s3err.ErrNone
+}
+
+// setChecksumResponseHeaders returns the verified checksum, from the headers or the trailer of the upload
+func setChecksumResponseHeaders(w http.ResponseWriter, r *http.Request, dataReader io.Reader) {
+	headers := []http.Header{r.Header}
+	if cr, ok := dataReader.(*s3ChunkedReader); ok && cr.trailer != nil {
+		headers = append(headers, cr.trailer)
+	}
+	for _, header := range headers {
+		for _, algorithm := range filer.S3ChecksumAlgorithms {
+			if v := header.Get(filer.S3ChecksumHeader(algorithm)); v != "" {
+				w.Header().Set(filer.S3ChecksumHeader(algorithm), v)
+			}
+		}
+	}
+}
+
+// passThroughObjectResponse returns the checksums of the whole object only in the checksum mode
+func passThroughObjectResponse(r *http.Request) func(proxyResponse *http.Response, w http.ResponseWriter) {
+	return func(proxyResponse *http.Response, w http.ResponseWriter) {
+		if !strings.EqualFold(r.Header.Get(xhttp.AmzChecksumMode), "ENABLED") || r.Header.Get("Range") != "" {
+			for _, algorithm := range filer.S3ChecksumAlgorithms {
+				proxyResponse.Header.Del(filer.S3ChecksumHeader(algorithm))
+			}
+		}
+		passThroughResponse(proxyResponse, w)
+	}
+}
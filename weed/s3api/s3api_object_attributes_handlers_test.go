@@ -0,0 +1,81 @@
+package s3api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	xhttp "github.com/chrislusf/seaweedfs/weed/s3api/http"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectAttributes(t *testing.T) {
+	_, errCode := parseObjectAttributes("")
+	assert.Equal(t, s3err.ErrInvalidRequest, errCode)
+	_, errCode = parseObjectAttributes("ETag,Owner")
+	assert.Equal(t, s3err.ErrInvalidRequest, errCode)
+
+	attributes, errCode := parseObjectAttributes("ETag, Checksum,ObjectParts,StorageClass,ObjectSize")
+	assert.Equal(t, s3err.ErrNone, errCode)
+
+	entry := &filer_pb.Entry{
+		Name:       "a.txt",
+		Attributes: &filer_pb.FuseAttributes{FileSize: 12, Md5: []byte{0x12, 0x34}},
+		Extended: map[string][]byte{
+			"X-Amz-Checksum-Sha256":  []byte("Y2hlY2tzdW0=-2"),
+			filer.ExtS3PartsCountKey: []byte("2"),
+		},
+	}
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<GetObjectAttributesResponse xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><ETag>1234</ETag><Checksum><ChecksumSHA256>Y2hlY2tzdW0=-2</ChecksumSHA256></Checksum><ObjectParts><TotalPartsCount>2</TotalPartsCount></ObjectParts><StorageClass>STANDARD</StorageClass><ObjectSize>12</ObjectSize></GetObjectAttributesResponse>`
+	assert.Equal(t, expected, string(encodeResponse(objectAttributesOf(entry, attributes))))
+
+	// only the requested attributes, and the known ones
+	entry.Extended = map[string][]byte{xhttp.AmzStorageClass: []byte("GLACIER")}
+	attributes, _ = parseObjectAttributes("Checksum,ObjectParts,StorageClass")
+	expected = `<?xml version="1.0" encoding="UTF-8"?>
+<GetObjectAttributesResponse xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><StorageClass>GLACIER</StorageClass></GetObjectAttributesResponse>`
+	assert.Equal(t, expected, string(encodeResponse(objectAttributesOf(entry, attributes))))
+}
+
+func TestCompositeChecksumOf(t *testing.T) {
+	parts := []*filer_pb.Entry{
+		{Name: "0001.part", Extended: map[string][]byte{"X-Amz-Checksum-Crc32": []byte("AAAAAQ==")}},
+		{Name: "0002.part", Extended: map[string][]byte{"X-Amz-Checksum-Crc32": []byte("AAAAAg==")}},
+	}
+	checksum, errCode := compositeChecksumOf(filer.S3ChecksumCRC32, parts)
+	assert.Equal(t, s3err.ErrNone, errCode)
+	expected, _ := filer.CompositeS3Checksum(filer.S3ChecksumCRC32, []string{"AAAAAQ==", "AAAAAg=="})
+	assert.Equal(t, expected, checksum)
+
+	_, errCode = compositeChecksumOf(filer.S3ChecksumSHA256, parts)
+	assert.Equal(t, s3err.ErrInvalidPart, errCode)
+
+	response := &CompleteMultipartUploadResult{
+		CompleteMultipartUploadOutput: s3.CompleteMultipartUploadOutput{
+			Bucket: aws.String("bucket"),
+			Key:    aws.String("a.txt"),
+		},
+		s3Checksums: newS3Checksums(filer.S3ChecksumCRC32, checksum),
+	}
+	assert.Contains(t, string(encodeResponse(response)), "<Key>a.txt</Key><ChecksumCRC32>"+checksum+"</ChecksumCRC32></CompleteMultipartUploadResult>")
+}
+
+func TestApplyChecksumUpload(t *testing.T) {
+	newRequest := func(header, value string) *http.Request {
+		r, _ := http.NewRequest("PUT", "http://localhost/bucket/a.txt?partNumber=1&uploadId=1", nil)
+		r.Header.Set(header, value)
+		return r
+	}
+	uploadEntry := &filer_pb.Entry{Extended: map[string][]byte{xhttp.AmzChecksumAlgorithm: []byte(filer.S3ChecksumCRC32)}}
+
+	assert.Equal(t, s3err.ErrNone, applyChecksumUpload(newRequest("x-amz-checksum-crc32", "AAAAAQ=="), uploadEntry))
+	assert.Equal(t, s3err.ErrInvalidRequest, applyChecksumUpload(newRequest("x-amz-checksum-crc32c", "AAAAAQ=="), uploadEntry))
+	assert.Equal(t, s3err.ErrInvalidRequest, applyChecksumUpload(newRequest("x-amz-checksum-crc32", "AAAA"), uploadEntry))
+	assert.Equal(t, s3err.ErrInvalidRequest, applyChecksum(newRequest(xhttp.AmzChecksumAlgorithm, "MD5")))
+	assert.Equal(t, s3err.ErrNone, applyChecksum(newRequest(xhttp.AmzChecksumAlgorithm, "crc32")))
+}
@@ -2,7 +2,10 @@ package s3api
 
 import (
 	"fmt"
+	"github.com/chrislusf/seaweedfs/weed/filer"
 	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	xhttp "github.com/chrislusf/seaweedfs/weed/s3api/http"
 	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
 	weed_server "github.com/chrislusf/seaweedfs/weed/server"
 	"net/http"
@@ -19,14 +22,15 @@ func (s3a *S3ApiServer) CopyObjectHandler(w http.ResponseWriter, r *http.Request
 	dstBucket, dstObject := getBucketAndObject(r)
 
 	// Copy source path.
-	cpSrcPath, err := url.QueryUnescape(r.Header.Get("X-Amz-Copy-Source"))
-	if err != nil {
-		// Save unescaped string as is.
-		cpSrcPath = r.Header.Get("X-Amz-Copy-Source")
-	}
+	cpSrcPath := copySourcePathOf(r)
 
 	srcBucket, srcObject := pathToBucketAndObject(cpSrcPath)
 
+	if errCode := s3a.applyObjectAcl(r); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
 	if (srcBucket == dstBucket && srcObject == dstObject || cpSrcPath == "") && isReplace(r) {
 		fullPath := util.FullPath(fmt.Sprintf("%s/%s%s", s3a.option.BucketsPath, dstBucket, dstObject))
 		dir, name := fullPath.DirAndName()
@@ -34,7 +38,19 @@ func (s3a *S3ApiServer) CopyObjectHandler(w http.ResponseWriter, r *http.Request
 		if err != nil {
 			writeErrorResponse(w, s3err.ErrInvalidCopySource, r.URL)
 		}
-		entry.Extended = weed_server.SaveAmzMetaData(r, entry.Extended, isReplace(r))
+		extended := weed_server.SaveAmzMetaData(r, entry.Extended, isReplace(r))
+		if !isTaggingReplace(r) {
+			for k, v := range entry.Extended {
+				if strings.HasPrefix(k, S3TAG_PREFIX) {
+					extended[k] = v
+				}
+			}
+		}
+		// the chunks are still encrypted with the same keys
+		for k, v := range sseExtendedOf(entry) {
+			extended[k] = v
+		}
+		entry.Extended = extended
 		err = s3a.touch(dir, name, entry)
 		if err != nil {
 			writeErrorResponse(w, s3err.ErrInvalidCopySource, r.URL)
@@ -57,20 +73,61 @@ func (s3a *S3ApiServer) CopyObjectHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	srcDir, srcName := util.FullPath(fmt.Sprintf("%s/%s%s", s3a.option.BucketsPath, srcBucket, srcObject)).DirAndName()
+	srcEntry, err := s3a.getEntry(srcDir, srcName)
+	if err != nil || srcEntry.IsDirectory {
+		writeErrorResponse(w, s3err.ErrInvalidCopySource, r.URL)
+		return
+	}
+	srcHeader, errCode := copySourceSseHeaders(r, srcEntry)
+	if errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	if isTaggingReplace(r) {
+		if _, errCode := ParseTagsHeader(r.Header.Get(xhttp.AmzObjectTagging)); errCode != s3err.ErrNone {
+			writeErrorResponse(w, errCode, r.URL)
+			return
+		}
+	} else {
+		// copy the tags of the source object
+		tags, err := s3a.getTags(srcDir, srcName)
+		if err != nil {
+			writeErrorResponse(w, s3err.ErrInvalidCopySource, r.URL)
+			return
+		}
+		r.Header.Del(xhttp.AmzObjectTagging)
+		if len(tags) > 0 {
+			r.Header.Set(xhttp.AmzObjectTagging, EncodeTagsHeader(tags))
+		}
+	}
+
+	if errCode := s3a.applySse(r, dstBucket); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	if errCode := s3a.applyObjectLock(r, dstBucket); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
 	dstUrl := fmt.Sprintf("http://%s%s/%s%s?collection=%s",
 		s3a.option.Filer, s3a.option.BucketsPath, dstBucket, dstObject, dstBucket)
 	srcUrl := fmt.Sprintf("http://%s%s/%s%s",
 		s3a.option.Filer, s3a.option.BucketsPath, srcBucket, srcObject)
 
-	_, _, resp, err := util.DownloadFile(srcUrl)
+	dataReader, err := util.ReadUrlAsReaderCloser(srcUrl, "", srcHeader)
 	if err != nil {
+		glog.Errorf("CopyObjectHandler read %s: %v", srcUrl, err)
 		writeErrorResponse(w, s3err.ErrInvalidCopySource, r.URL)
 		return
 	}
-	defer util.CloseResponse(resp)
+	defer dataReader.Close()
 
 	glog.V(2).Infof("copy from %s to %s", srcUrl, dstUrl)
-	etag, errCode := s3a.putToFiler(r, dstUrl, resp.Body)
+	etag, errCode := s3a.putToFiler(w, r, dstUrl, dataReader)
 
 	if errCode != s3err.ErrNone {
 		writeErrorResponse(w, errCode, r.URL)
@@ -78,6 +135,7 @@ func (s3a *S3ApiServer) CopyObjectHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	setEtag(w, etag)
+	setSseResponseHeaders(w, r)
 
 	response := CopyObjectResult{
 		ETag:         etag,
@@ -88,6 +146,20 @@ func (s3a *S3ApiServer) CopyObjectHandler(w http.ResponseWriter, r *http.Request
 
 }
 
+// copySourcePathOf returns the unescaped X-Amz-Copy-Source, without the version id
+func copySourcePathOf(r *http.Request) string {
+	cpSrcPath, err := url.QueryUnescape(r.Header.Get(xhttp.AmzCopySource))
+	if err != nil {
+		// Save unescaped string as is.
+		cpSrcPath = r.Header.Get(xhttp.AmzCopySource)
+	}
+	// the versions are not addressed by id
+	if i := strings.Index(cpSrcPath, "?versionId="); i >= 0 {
+		cpSrcPath = cpSrcPath[:i]
+	}
+	return cpSrcPath
+}
+
 func pathToBucketAndObject(path string) (bucket, object string) {
 	path = strings.TrimPrefix(path, "/")
 	parts := strings.SplitN(path, "/", 2)
@@ -108,24 +180,30 @@ func (s3a *S3ApiServer) CopyObjectPartHandler(w http.ResponseWriter, r *http.Req
 	dstBucket, _ := getBucketAndObject(r)
 
 	// Copy source path.
-	cpSrcPath, err := url.QueryUnescape(r.Header.Get("X-Amz-Copy-Source"))
-	if err != nil {
-		// Save unescaped string as is.
-		cpSrcPath = r.Header.Get("X-Amz-Copy-Source")
-	}
+	cpSrcPath := copySourcePathOf(r)
 
 	srcBucket, srcObject := pathToBucketAndObject(cpSrcPath)
 	// If source object is empty or bucket is empty, reply back invalid copy source.
-	if srcObject == "" || srcBucket == "" {
+	if srcObject == "" || srcObject == "/" || srcBucket == "" {
 		writeErrorResponse(w, s3err.ErrInvalidCopySource, r.URL)
 		return
 	}
 
 	uploadID := r.URL.Query().Get("uploadId")
+	uploadEntry, err := s3a.getEntry(s3a.genUploadsFolder(dstBucket), uploadID)
+	if err != nil || !uploadEntry.IsDirectory {
+		writeErrorResponse(w, s3err.ErrNoSuchUpload, r.URL)
+		return
+	}
+	if errCode := applySseUpload(r, uploadEntry); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
 	partIDString := r.URL.Query().Get("partNumber")
 
 	partID, err := strconv.Atoi(partIDString)
-	if err != nil {
+	if err != nil || partID < 1 {
 		writeErrorResponse(w, s3err.ErrInvalidPart, r.URL)
 		return
 	}
@@ -136,22 +214,54 @@ func (s3a *S3ApiServer) CopyObjectPartHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	rangeHeader := r.Header.Get("x-amz-copy-source-range")
+	srcPath := util.FullPath(fmt.Sprintf("%s/%s%s", s3a.option.BucketsPath, srcBucket, srcObject))
+	srcDir, srcName := srcPath.DirAndName()
+	srcEntry, err := s3a.getEntry(srcDir, srcName)
+	if err == filer_pb.ErrNotFound || err == nil && srcEntry.IsDirectory {
+		writeErrorResponse(w, s3err.ErrNoSuchKey, r.URL)
+		return
+	}
+	if err != nil {
+		glog.Errorf("CopyObjectPartHandler lookup %s: %v", srcPath, err)
+		writeErrorResponse(w, s3err.ErrInternalError, r.URL)
+		return
+	}
+
+	if errCode := checkCopySourcePreconditions(r, srcEntry); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	srcHeader, errCode := copySourceSseHeaders(r, srcEntry)
+	if errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	rangeHeader := ""
+	if copySourceRange := r.Header.Get(xhttp.AmzCopySourceRange); copySourceRange != "" {
+		start, stop, errCode := parseCopySourceRange(copySourceRange, int64(filer.FileSize(srcEntry)))
+		if errCode != s3err.ErrNone {
+			writeErrorResponse(w, errCode, r.URL)
+			return
+		}
+		rangeHeader = fmt.Sprintf("bytes=%d-%d", start, stop)
+	}
 
 	dstUrl := fmt.Sprintf("http://%s%s/%s/%04d.part?collection=%s",
 		s3a.option.Filer, s3a.genUploadsFolder(dstBucket), uploadID, partID, dstBucket)
 	srcUrl := fmt.Sprintf("http://%s%s/%s%s",
-		s3a.option.Filer, s3a.option.BucketsPath, srcBucket, srcObject)
+		s3a.option.Filer, s3a.option.BucketsPath, srcBucket, urlPathEscape(srcObject))
 
-	dataReader, err := util.ReadUrlAsReaderCloser(srcUrl, rangeHeader)
+	dataReader, err := util.ReadUrlAsReaderCloser(srcUrl, rangeHeader, srcHeader)
 	if err != nil {
+		glog.Errorf("CopyObjectPartHandler read %s: %v", srcUrl, err)
 		writeErrorResponse(w, s3err.ErrInvalidCopySource, r.URL)
 		return
 	}
 	defer dataReader.Close()
 
 	glog.V(2).Infof("copy from %s to %s", srcUrl, dstUrl)
-	etag, errCode := s3a.putToFiler(r, dstUrl, dataReader)
+	etag, errCode := s3a.putToFiler(w, r, dstUrl, dataReader)
 
 	if errCode != s3err.ErrNone {
 		writeErrorResponse(w, errCode, r.URL)
@@ -159,9 +269,10 @@ func (s3a *S3ApiServer) CopyObjectPartHandler(w http.ResponseWriter, r *http.Req
 	}
 
 	setEtag(w, etag)
+	setSseResponseHeaders(w, r)
 
 	response := CopyPartResult{
-		ETag:         etag,
+		ETag:         "\"" + etag + "\"",
 		LastModified: time.Now().UTC(),
 	}
 
@@ -169,6 +280,59 @@ func (s3a *S3ApiServer) CopyObjectPartHandler(w http.ResponseWriter, r *http.Req
 
 }
 
+// parseCopySourceRange parses the x-amz-copy-source-range "bytes=first-last", with both offsets required
+func parseCopySourceRange(copySourceRange string, size int64) (start, stop int64, errCode s3err.ErrorCode) {
+	if !strings.HasPrefix(copySourceRange, "bytes=") {
+		return 0, 0, s3err.ErrInvalidCopyPartRange
+	}
+	parts := strings.SplitN(strings.TrimPrefix(copySourceRange, "bytes="), "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, s3err.ErrInvalidCopyPartRange
+	}
+	start, startErr := strconv.ParseInt(parts[0], 10, 64)
+	stop, stopErr := strconv.ParseInt(parts[1], 10, 64)
+	if startErr != nil || stopErr != nil || start < 0 || start > stop {
+		return 0, 0, s3err.ErrInvalidCopyPartRange
+	}
+	if stop >= size {
+		return 0, 0, s3err.ErrInvalidCopyPartRangeSource
+	}
+	return start, stop, s3err.ErrNone
+}
+
+// checkCopySourcePreconditions checks the x-amz-copy-source-if-* headers against the source object
+func checkCopySourcePreconditions(r *http.Request, entry *filer_pb.Entry) s3err.ErrorCode {
+	etag := filer.ETag(entry)
+	mtime := time.Unix(entry.Attributes.Mtime, 0)
+	if ifMatch := r.Header.Get(xhttp.AmzCopySourceIfMatch); ifMatch != "" && !isETagMatched(ifMatch, etag) {
+		return s3err.ErrPreconditionFailed
+	}
+	if ifNoneMatch := r.Header.Get(xhttp.AmzCopySourceIfNoneMatch); ifNoneMatch != "" && isETagMatched(ifNoneMatch, etag) {
+		return s3err.ErrPreconditionFailed
+	}
+	if since, err := http.ParseTime(r.Header.Get(xhttp.AmzCopySourceIfUnmodifiedSince)); err == nil && mtime.After(since) {
+		return s3err.ErrPreconditionFailed
+	}
+	if since, err := http.ParseTime(r.Header.Get(xhttp.AmzCopySourceIfModifiedSince)); err == nil && !mtime.After(since) {
+		return s3err.ErrPreconditionFailed
+	}
+	return s3err.ErrNone
+}
+
+func isETagMatched(condition, etag string) bool {
+	for _, c := range strings.Split(condition, ",") {
+		c = strings.Trim(strings.TrimSpace(c), "\"")
+		if c == "*" || c == etag {
+			return true
+		}
+	}
+	return false
+}
+
 func isReplace(r *http.Request) bool {
 	return r.Header.Get("X-Amz-Metadata-Directive") == "REPLACE"
 }
+
+func isTaggingReplace(r *http.Request) bool {
+	return r.Header.Get(xhttp.AmzTagDirective) == "REPLACE"
+}
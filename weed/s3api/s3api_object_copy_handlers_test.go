@@ -0,0 +1,52 @@
+package s3api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	xhttp "github.com/chrislusf/seaweedfs/weed/s3api/http"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+)
+
+func TestParseCopySourceRange(t *testing.T) {
+
+	start, stop, errCode := parseCopySourceRange("bytes=0-99", 100)
+	if errCode != s3err.ErrNone || start != 0 || stop != 99 {
+		t.Errorf("unexpected range %d-%d: %v", start, stop, errCode)
+	}
+
+	for copySourceRange, expected := range map[string]s3err.ErrorCode{
+		"0-99":        s3err.ErrInvalidCopyPartRange,
+		"bytes=10-":   s3err.ErrInvalidCopyPartRange,
+		"bytes=-10":   s3err.ErrInvalidCopyPartRange,
+		"bytes=20-10": s3err.ErrInvalidCopyPartRange,
+		"bytes=0-100": s3err.ErrInvalidCopyPartRangeSource,
+	} {
+		if _, _, errCode := parseCopySourceRange(copySourceRange, 100); errCode != expected {
+			t.Errorf("%s: expecting %v, got %v", copySourceRange, expected, errCode)
+		}
+	}
+}
+
+func TestCheckCopySourcePreconditions(t *testing.T) {
+
+	mtime := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	entry := &filer_pb.Entry{Attributes: &filer_pb.FuseAttributes{Mtime: mtime.Unix(), Md5: []byte{0xab, 0xcd}}}
+
+	for headers, expected := range map[[2]string]s3err.ErrorCode{
+		{xhttp.AmzCopySourceIfMatch, `"abcd"`}:                                                s3err.ErrNone,
+		{xhttp.AmzCopySourceIfMatch, `"ffff"`}:                                                s3err.ErrPreconditionFailed,
+		{xhttp.AmzCopySourceIfNoneMatch, `"abcd"`}:                                            s3err.ErrPreconditionFailed,
+		{xhttp.AmzCopySourceIfUnmodifiedSince, mtime.Add(-time.Hour).Format(http.TimeFormat)}: s3err.ErrPreconditionFailed,
+		{xhttp.AmzCopySourceIfModifiedSince, mtime.Add(-time.Hour).Format(http.TimeFormat)}:   s3err.ErrNone,
+		{xhttp.AmzCopySourceIfModifiedSince, mtime.Format(http.TimeFormat)}:                   s3err.ErrPreconditionFailed,
+	} {
+		r, _ := http.NewRequest("PUT", "/bucket/object", nil)
+		r.Header.Set(headers[0], headers[1])
+		if errCode := checkCopySourcePreconditions(r, entry); errCode != expected {
+			t.Errorf("%s: %s expecting %v, got %v", headers[0], headers[1], expected, errCode)
+		}
+	}
+}
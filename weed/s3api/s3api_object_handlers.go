@@ -13,7 +13,9 @@ import (
 	"sort"
 	"strings"
 
+	xhttp "github.com/chrislusf/seaweedfs/weed/s3api/http"
 	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+	"github.com/chrislusf/seaweedfs/weed/stats"
 
 	"github.com/gorilla/mux"
 
@@ -31,6 +33,8 @@ func init() {
 	client = &http.Client{Transport: &http.Transport{
 		MaxIdleConns:        1024,
 		MaxIdleConnsPerHost: 1024,
+		// pass the stored Content-Encoding to the clients, instead of decoding the content
+		DisableCompression: true,
 	}}
 }
 
@@ -46,6 +50,33 @@ func (s3a *S3ApiServer) PutObjectHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if tagging := r.Header.Get(xhttp.AmzObjectTagging); tagging != "" {
+		if _, errCode := ParseTagsHeader(tagging); errCode != s3err.ErrNone {
+			writeErrorResponse(w, errCode, r.URL)
+			return
+		}
+	}
+
+	if errCode := s3a.applySse(r, bucket); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	if errCode := s3a.applyObjectLock(r, bucket); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	if errCode := s3a.applyObjectAcl(r); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	if errCode := applyChecksum(r); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
 	dataReader := r.Body
 	if s3a.iam.isEnabled() {
 		rAuthType := getRequestAuthType(r)
@@ -62,6 +93,19 @@ func (s3a *S3ApiServer) PutObjectHandler(w http.ResponseWriter, r *http.Request)
 			writeErrorResponse(w, s3ErrCode, r.URL)
 			return
 		}
+	} else if isRequestSignStreamingV4(r) {
+		var s3ErrCode s3err.ErrorCode
+		if dataReader, s3ErrCode = newChunkedReader(r); s3ErrCode != s3err.ErrNone {
+			writeErrorResponse(w, s3ErrCode, r.URL)
+			return
+		}
+	}
+	if isRequestUnsignedTrailer(r) {
+		var s3ErrCode s3err.ErrorCode
+		if dataReader, s3ErrCode = newChunkedReader(r); s3ErrCode != s3err.ErrNone {
+			writeErrorResponse(w, s3ErrCode, r.URL)
+			return
+		}
 	}
 	defer dataReader.Close()
 
@@ -73,7 +117,7 @@ func (s3a *S3ApiServer) PutObjectHandler(w http.ResponseWriter, r *http.Request)
 	} else {
 		uploadUrl := fmt.Sprintf("http://%s%s/%s%s", s3a.option.Filer, s3a.option.BucketsPath, bucket, urlPathEscape(object))
 
-		etag, errCode := s3a.putToFiler(r, uploadUrl, dataReader)
+		etag, errCode := s3a.putToFiler(w, r, uploadUrl, dataReader)
 
 		if errCode != s3err.ErrNone {
 			writeErrorResponse(w, errCode, r.URL)
@@ -81,6 +125,8 @@ func (s3a *S3ApiServer) PutObjectHandler(w http.ResponseWriter, r *http.Request)
 		}
 
 		setEtag(w, etag)
+		setSseResponseHeaders(w, r)
+		setChecksumResponseHeaders(w, r, dataReader)
 	}
 
 	writeSuccessResponseEmpty(w)
@@ -106,7 +152,7 @@ func (s3a *S3ApiServer) GetObjectHandler(w http.ResponseWriter, r *http.Request)
 	destUrl := fmt.Sprintf("http://%s%s/%s%s",
 		s3a.option.Filer, s3a.option.BucketsPath, bucket, urlPathEscape(object))
 
-	s3a.proxyToFiler(w, r, destUrl, passThroughResponse)
+	s3a.proxyToFiler(w, r, destUrl, passThroughObjectResponse(r))
 
 }
 
@@ -117,7 +163,7 @@ func (s3a *S3ApiServer) HeadObjectHandler(w http.ResponseWriter, r *http.Request
 	destUrl := fmt.Sprintf("http://%s%s/%s%s",
 		s3a.option.Filer, s3a.option.BucketsPath, bucket, urlPathEscape(object))
 
-	s3a.proxyToFiler(w, r, destUrl, passThroughResponse)
+	s3a.proxyToFiler(w, r, destUrl, passThroughObjectResponse(r))
 
 }
 
@@ -129,6 +175,11 @@ func (s3a *S3ApiServer) DeleteObjectHandler(w http.ResponseWriter, r *http.Reque
 		s3a.option.Filer, s3a.option.BucketsPath, bucket, urlPathEscape(object))
 
 	s3a.proxyToFiler(w, r, destUrl, func(proxyResponse *http.Response, w http.ResponseWriter) {
+		if proxyResponse.StatusCode == http.StatusForbidden {
+			// held or retained
+			writeErrorResponse(w, s3err.ErrAccessDenied, r.URL)
+			return
+		}
 		for k, v := range proxyResponse.Header {
 			w.Header()[k] = v
 		}
@@ -191,28 +242,49 @@ func (s3a *S3ApiServer) DeleteMultipleObjectsHandler(w http.ResponseWriter, r *h
 
 	s3a.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
 
-		// delete file entries
+		// delete file entries with one bulk delete job on the filer
+		var paths []string
 		for _, object := range deleteObjects.Objects {
+			paths = append(paths, s3a.objectFullPath(bucket, object.ObjectName))
+		}
 
-			lastSeparator := strings.LastIndex(object.ObjectName, "/")
-			parentDirectoryPath, entryName, isDeleteData, isRecursive := "", object.ObjectName, true, false
-			if lastSeparator > 0 && lastSeparator+1 < len(object.ObjectName) {
-				entryName = object.ObjectName[lastSeparator+1:]
-				parentDirectoryPath = "/" + object.ObjectName[:lastSeparator]
+		failures, err := doBulkDeleteEntries(client, paths)
+		if err != nil {
+			glog.Errorf("DeleteMultipleObjectsHandler %s: %v", r.URL, err)
+			for _, object := range deleteObjects.Objects {
+				deleteErrors = append(deleteErrors, DeleteError{
+					Code:    "InternalError",
+					Message: err.Error(),
+					Key:     object.ObjectName,
+				})
 			}
-			parentDirectoryPath = fmt.Sprintf("%s/%s%s", s3a.option.BucketsPath, bucket, parentDirectoryPath)
+			return nil
+		}
 
-			err := doDeleteEntry(client, parentDirectoryPath, entryName, isDeleteData, isRecursive)
-			if err == nil {
+		isGovernanceBypassed := strings.EqualFold(r.Header.Get(xhttp.AmzBypassGovernanceRetention), "true")
+		for i, object := range deleteObjects.Objects {
+			parentDirectoryPath, _ := util.FullPath(paths[i]).DirAndName()
+			failure, failed := failures[paths[i]]
+			if failed && isGovernanceBypassed && strings.Contains(failure, filer.ErrEntryRetained.Error()) {
+				// the bulk delete job does not bypass the governance retention
+				if errCode := s3a.deleteBypassingGovernance(paths[i]); errCode == s3err.ErrNone {
+					failed = false
+				}
+			}
+			if !failed {
 				directoriesWithDeletion[parentDirectoryPath]++
 				deletedObjects = append(deletedObjects, object)
-			} else if strings.Contains(err.Error(), filer.MsgFailDelNonEmptyFolder) {
+			} else if strings.Contains(failure, filer.MsgFailDelNonEmptyFolder) {
 				deletedObjects = append(deletedObjects, object)
 			} else {
 				delete(directoriesWithDeletion, parentDirectoryPath)
+				code := ""
+				if strings.Contains(failure, filer.ErrEntryHeld.Error()) || strings.Contains(failure, filer.ErrEntryRetained.Error()) {
+					code = "AccessDenied"
+				}
 				deleteErrors = append(deleteErrors, DeleteError{
-					Code:    "",
-					Message: err.Error(),
+					Code:    code,
+					Message: failure,
 					Key:     object.ObjectName,
 				})
 			}
@@ -296,6 +368,10 @@ func (s3a *S3ApiServer) proxyToFiler(w http.ResponseWriter, r *http.Request, des
 		if passed {
 			continue
 		}
+		// the cors headers are from the bucket rules, not the filer
+		if header == "Origin" {
+			continue
+		}
 		// handle other headers
 		for _, value := range values {
 			proxyReq.Header.Add(header, value)
@@ -311,6 +387,16 @@ func (s3a *S3ApiServer) proxyToFiler(w http.ResponseWriter, r *http.Request, des
 	}
 	defer util.CloseResponse(resp)
 
+	if resp.StatusCode == http.StatusForbidden && resp.Header.Get(xhttp.AmzStorageClass) == filer.ArchiveStorageClass {
+		writeErrorResponse(w, s3err.ErrInvalidObjectState, r.URL)
+		return
+	}
+
+	if errCode, isSseError := sseReadError(resp); isSseError {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
 	if (resp.ContentLength == -1 || resp.StatusCode == 404) && resp.StatusCode != 304 {
 		if r.Method != "DELETE" {
 			writeErrorResponse(w, s3err.ErrNoSuchKey, r.URL)
@@ -323,9 +409,14 @@ func (s3a *S3ApiServer) proxyToFiler(w http.ResponseWriter, r *http.Request, des
 }
 
 func passThroughResponse(proxyResponse *http.Response, w http.ResponseWriter) {
+	// set by the bucket CORS rule
+	exposeHeaders := w.Header().Get("Access-Control-Expose-Headers")
 	for k, v := range proxyResponse.Header {
 		w.Header()[k] = v
 	}
+	if exposeHeaders != "" {
+		w.Header().Set("Access-Control-Expose-Headers", mergeExposeHeaders(exposeHeaders, proxyResponse.Header.Get("Access-Control-Expose-Headers")))
+	}
 	if proxyResponse.Header.Get("Content-Range") != "" && proxyResponse.StatusCode == 200 {
 		w.WriteHeader(http.StatusPartialContent)
 	} else {
@@ -334,7 +425,7 @@ func passThroughResponse(proxyResponse *http.Response, w http.ResponseWriter) {
 	io.Copy(w, proxyResponse.Body)
 }
 
-func (s3a *S3ApiServer) putToFiler(r *http.Request, uploadUrl string, dataReader io.Reader) (etag string, code s3err.ErrorCode) {
+func (s3a *S3ApiServer) putToFiler(w http.ResponseWriter, r *http.Request, uploadUrl string, dataReader io.Reader) (etag string, code s3err.ErrorCode) {
 
 	hash := md5.New()
 	var body = io.TeeReader(dataReader, hash)
@@ -354,15 +445,37 @@ func (s3a *S3ApiServer) putToFiler(r *http.Request, uploadUrl string, dataReader
 			proxyReq.Header.Add(header, value)
 		}
 	}
+	if cr, ok := dataReader.(*s3ChunkedReader); ok && cr.trailer != nil {
+		// the checksum is verified by the filer after the content
+		proxyReq.Trailer = cr.trailer
+	}
 
 	resp, postErr := client.Do(proxyReq)
 
+	// the streaming upload with the wrong chunk signature, or not complete
+	if cr, ok := dataReader.(*s3ChunkedReader); ok && cr.s3Error() != s3err.ErrNone {
+		glog.V(1).Infof("upload to %s: %v", uploadUrl, cr.err)
+		if postErr == nil {
+			resp.Body.Close()
+		}
+		return "", cr.s3Error()
+	}
+
 	if postErr != nil {
 		glog.Errorf("post to filer: %v", postErr)
 		return "", s3err.ErrInternalError
 	}
 	defer resp.Body.Close()
 
+	// add the filer upload stages to the auth stage, only returned if the client opts in
+	stages := stats.UploadStagesFromContext(r.Context())
+	if stages == nil {
+		stages = stats.NewUploadStages()
+	}
+	stages.AddServerTiming(resp.Header.Get(stats.ServerTimingHeader))
+	stages.Observe()
+	stages.SetServerTiming(w, r)
+
 	etag = fmt.Sprintf("%x", hash.Sum(nil))
 
 	resp_body, ra_err := ioutil.ReadAll(resp.Body)
@@ -409,5 +522,23 @@ func filerErrorToS3Error(errString string) s3err.ErrorCode {
 	if strings.HasPrefix(errString, "existing ") && strings.HasSuffix(errString, "is a directory") {
 		return s3err.ErrExistingObjectIsDirectory
 	}
+	if strings.Contains(errString, filer.ErrQuotaExceeded.Error()) {
+		return s3err.ErrQuotaExceeded
+	}
+	if strings.Contains(errString, filer.ErrEntryHeld.Error()) || strings.Contains(errString, filer.ErrEntryRetained.Error()) {
+		return s3err.ErrAccessDenied
+	}
+	if strings.Contains(errString, filer.ErrClusterReadOnly.Error()) {
+		return s3err.ErrClusterReadOnly
+	}
+	if strings.Contains(errString, filer.ErrSseNotConfigured.Error()) {
+		return s3err.ErrNotImplemented
+	}
+	if strings.Contains(errString, filer.ErrS3ChecksumMismatch.Error()) {
+		return s3err.ErrBadDigest
+	}
+	if strings.Contains(errString, filer.ErrInvalidS3Checksum.Error()) {
+		return s3err.ErrInvalidRequest
+	}
 	return s3err.ErrInternalError
 }
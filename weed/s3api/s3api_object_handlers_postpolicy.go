@@ -5,7 +5,11 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	xhttp "github.com/chrislusf/seaweedfs/weed/s3api/http"
 	"github.com/chrislusf/seaweedfs/weed/s3api/policy"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3_constants"
 	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
 	"github.com/dustin/go-humanize"
 	"github.com/gorilla/mux"
@@ -53,6 +57,14 @@ func (s3a *S3ApiServer) PostPolicyBucketHandler(w http.ResponseWriter, r *http.R
 		formValues.Set("Key", strings.Replace(formValues.Get("Key"), "${filename}", fileName, -1))
 	}
 	object := formValues.Get("Key")
+	if object == "" {
+		writeErrorResponse(w, s3err.ErrMalformedPOSTRequest, r.URL)
+		return
+	}
+	objectPath := object
+	if !strings.HasPrefix(objectPath, "/") {
+		objectPath = "/" + objectPath
+	}
 
 	successRedirect := formValues.Get("success_action_redirect")
 	successStatus := formValues.Get("success_action_status")
@@ -65,8 +77,8 @@ func (s3a *S3ApiServer) PostPolicyBucketHandler(w http.ResponseWriter, r *http.R
 		}
 	}
 
-	// Verify policy signature.
-	errCode := s3a.iam.doesPolicySignatureMatch(formValues)
+	// Verify policy signature, and the permission of the signer, or the anonymous upload.
+	errCode := s3a.iam.authPostPolicy(r, formValues, bucket, objectPath)
 	if errCode != s3err.ErrNone {
 		writeErrorResponse(w, errCode, r.URL)
 		return
@@ -89,8 +101,12 @@ func (s3a *S3ApiServer) PostPolicyBucketHandler(w http.ResponseWriter, r *http.R
 
 		// Make sure formValues adhere to policy restrictions.
 		if err = policy.CheckPostPolicy(formValues, postPolicyForm); err != nil {
-			w.Header().Set("Location", r.URL.Path)
-			w.WriteHeader(http.StatusTemporaryRedirect)
+			glog.V(1).Infof("post policy upload %s%s: %v", bucket, objectPath, err)
+			if err == policy.ErrPolicyExpired {
+				writeErrorResponse(w, s3err.ErrPostPolicyExpired, r.URL)
+			} else {
+				writeErrorResponse(w, s3err.ErrPostPolicyConditionFailed, r.URL)
+			}
 			return
 		}
 
@@ -110,9 +126,29 @@ func (s3a *S3ApiServer) PostPolicyBucketHandler(w http.ResponseWriter, r *http.R
 		}
 	}
 
-	uploadUrl := fmt.Sprintf("http://%s%s/%s%s", s3a.option.Filer, s3a.option.BucketsPath, bucket, urlPathEscape(object))
+	// the object headers are from the form fields, instead of the multipart/form-data request
+	r.Header.Del("Content-Type")
+	for k, values := range formValues {
+		if postPolicyObjectHeaders[k] || strings.HasPrefix(k, xhttp.AmzUserMetaPrefix) || strings.HasPrefix(k, filer.SseAlgorithmHeader) {
+			r.Header[k] = values
+		}
+	}
+	r.Header.Del(xhttp.AmzCannedAcl)
+	if acl := formValues.Get("Acl"); acl != "" {
+		r.Header.Set(xhttp.AmzCannedAcl, acl)
+	}
+	if errCode = s3a.applySse(r, bucket); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	if errCode = s3a.applyObjectAcl(r); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	uploadUrl := fmt.Sprintf("http://%s%s/%s%s", s3a.option.Filer, s3a.option.BucketsPath, bucket, urlPathEscape(objectPath))
 
-	etag, errCode := s3a.putToFiler(r, uploadUrl, fileBody)
+	etag, errCode := s3a.putToFiler(w, r, uploadUrl, fileBody)
 
 	if errCode != s3err.ErrNone {
 		writeErrorResponse(w, errCode, r.URL)
@@ -128,6 +164,7 @@ func (s3a *S3ApiServer) PostPolicyBucketHandler(w http.ResponseWriter, r *http.R
 	}
 
 	setEtag(w, etag)
+	setSseResponseHeaders(w, r)
 
 	// Decide what http response to send depending on success_action_status parameter
 	switch successStatus {
@@ -147,6 +184,15 @@ func (s3a *S3ApiServer) PostPolicyBucketHandler(w http.ResponseWriter, r *http.R
 
 }
 
+var postPolicyObjectHeaders = map[string]bool{
+	"Content-Type":        true,
+	"Cache-Control":       true,
+	"Content-Disposition": true,
+	"Content-Encoding":    true,
+	"Expires":             true,
+	http.CanonicalHeaderKey(xhttp.AmzStorageClass): true,
+}
+
 // Extract form fields and file data from a HTTP POST Policy
 func extractPostPolicyFormValues(form *multipart.Form) (filePart io.ReadCloser, fileName string, fileSize int64, formValues http.Header, err error) {
 	/// HTML Form values
@@ -231,8 +277,38 @@ func getRedirectPostRawQuery(bucket, key, etag string) string {
 	return redirectValues.Encode()
 }
 
+// authPostPolicy authenticates the signed policy, or the anonymous upload without any policy,
+// and checks the upload permission as the other object writes.
+func (iam *IdentityAccessManagement) authPostPolicy(r *http.Request, formValues http.Header, bucket, object string) s3err.ErrorCode {
+	if !iam.isEnabled() {
+		return s3err.ErrNone
+	}
+	var identity *Identity
+	_, isV2 := formValues["Signature"]
+	_, isV4 := formValues["X-Amz-Signature"]
+	if isV2 || isV4 {
+		if formValues.Get("Policy") == "" {
+			return s3err.ErrMissingFields
+		}
+		var errCode s3err.ErrorCode
+		if identity, errCode = iam.doesPolicySignatureMatch(formValues); errCode != s3err.ErrNone {
+			return errCode
+		}
+	} else {
+		identity, _ = iam.lookupAnonymous()
+	}
+	if errCode := iam.authorize(r, identity, s3_constants.ACTION_WRITE, bucket, object); errCode != s3err.ErrNone {
+		return errCode
+	}
+	if identity != nil && identity.Name != "" {
+		r.Header.Set(xhttp.AmzIdentityId, identity.Name)
+	}
+	setAuthorizedUsage(r, identity)
+	return s3err.ErrNone
+}
+
 // Check to see if Policy is signed correctly.
-func (iam *IdentityAccessManagement) doesPolicySignatureMatch(formValues http.Header) s3err.ErrorCode {
+func (iam *IdentityAccessManagement) doesPolicySignatureMatch(formValues http.Header) (*Identity, s3err.ErrorCode) {
 	// For SignV2 - Signature field will be valid
 	if _, ok := formValues["Signature"]; ok {
 		return iam.doesPolicySignatureV2Match(formValues)
@@ -0,0 +1,51 @@
+package s3api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+	"github.com/stretchr/testify/assert"
+)
+
+func signedPostPolicyForm(accessKey, secretKey string) http.Header {
+	now := time.Now().UTC()
+	date := now.Format(yyyymmdd)
+	policy := "eyJleHBpcmF0aW9uIjoiMjA5OS0wMS0wMVQwMDowMDowMC4wMDBaIn0=" // {"expiration":"2099-01-01T00:00:00.000Z"}
+	formValues := make(http.Header)
+	formValues.Set("Policy", policy)
+	formValues.Set("X-Amz-Credential", accessKey+"/"+date+"/us-east-1/s3/aws4_request")
+	formValues.Set("X-Amz-Signature", getSignature(getSigningKey(secretKey, now, "us-east-1", "s3"), policy))
+	return formValues
+}
+
+func TestAuthPostPolicy(t *testing.T) {
+	iam := &IdentityAccessManagement{
+		identities: []*Identity{
+			{
+				Name:        "writer",
+				Credentials: []*Credential{{AccessKey: "access_key_1", SecretKey: "secret_key_1"}},
+				Actions:     []Action{"Write:bucket"},
+			},
+		},
+	}
+	r := httptest.NewRequest("POST", "/bucket", nil)
+
+	assert.Equal(t, s3err.ErrNone, iam.authPostPolicy(r, signedPostPolicyForm("access_key_1", "secret_key_1"), "bucket", "/a.txt"))
+	assert.Equal(t, "writer", r.Header.Get("s3-identity-id"))
+
+	// no permission on the other buckets
+	assert.Equal(t, s3err.ErrAccessDenied, iam.authPostPolicy(r, signedPostPolicyForm("access_key_1", "secret_key_1"), "other", "/a.txt"))
+	assert.Equal(t, s3err.ErrSignatureDoesNotMatch, iam.authPostPolicy(r, signedPostPolicyForm("access_key_1", "wrong"), "bucket", "/a.txt"))
+	assert.Equal(t, s3err.ErrInvalidAccessKeyID, iam.authPostPolicy(r, signedPostPolicyForm("unknown", "secret_key_1"), "bucket", "/a.txt"))
+
+	// the signed form requires the policy
+	formValues := signedPostPolicyForm("access_key_1", "secret_key_1")
+	formValues.Del("Policy")
+	assert.Equal(t, s3err.ErrMissingFields, iam.authPostPolicy(r, formValues, "bucket", "/a.txt"))
+
+	// the anonymous uploads without the anonymous identity
+	assert.Equal(t, s3err.ErrAccessDenied, iam.authPostPolicy(r, make(http.Header), "bucket", "/a.txt"))
+}
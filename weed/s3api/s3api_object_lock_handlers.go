@@ -0,0 +1,421 @@
+package s3api
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	xhttp "github.com/chrislusf/seaweedfs/weed/s3api/http"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+	weed_server "github.com/chrislusf/seaweedfs/weed/server"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+const (
+	ObjectLockEnabled = "Enabled"
+
+	LegalHoldOn  = "ON"
+	LegalHoldOff = "OFF"
+
+	maxObjectLockRequestSize = 64 * 1024
+)
+
+type ObjectLockConfiguration struct {
+	XMLName           xml.Name        `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ObjectLockConfiguration"`
+	ObjectLockEnabled string          `xml:"ObjectLockEnabled,omitempty"`
+	Rule              *ObjectLockRule `xml:"Rule,omitempty"`
+}
+
+type ObjectLockRule struct {
+	DefaultRetention *DefaultRetention `xml:"DefaultRetention"`
+}
+
+type DefaultRetention struct {
+	Mode  string `xml:"Mode"`
+	Days  int    `xml:"Days,omitempty"`
+	Years int    `xml:"Years,omitempty"`
+}
+
+type ObjectRetention struct {
+	XMLName         xml.Name   `xml:"http://s3.amazonaws.com/doc/2006-03-01/ Retention"`
+	Mode            string     `xml:"Mode,omitempty"`
+	RetainUntilDate *time.Time `xml:"RetainUntilDate,omitempty"`
+}
+
+type ObjectLegalHold struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ LegalHold"`
+	Status  string   `xml:"Status"`
+}
+
+// GetObjectLockConfigurationHandler Get Object Lock Configuration
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectLockConfiguration.html
+func (s3a *S3ApiServer) GetObjectLockConfigurationHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, _ := getBucketAndObject(r)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		writeErrorResponse(w, err, r.URL)
+		return
+	}
+
+	configuration, errCode := s3a.getBucketObjectLock(bucket)
+	if errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	if configuration == nil {
+		writeErrorResponse(w, s3err.ErrObjectLockConfigurationNotFound, r.URL)
+		return
+	}
+
+	writeSuccessResponseXML(w, encodeResponse(configuration))
+}
+
+// PutObjectLockConfigurationHandler Put Object Lock Configuration, the object lock can not be disabled once enabled
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectLockConfiguration.html
+func (s3a *S3ApiServer) PutObjectLockConfigurationHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, _ := getBucketAndObject(r)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		writeErrorResponse(w, err, r.URL)
+		return
+	}
+
+	configuration := &ObjectLockConfiguration{}
+	if errCode := readObjectLockRequest(r, configuration); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	if errCode := configuration.validate(); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	if errCode := s3a.setBucketExtended(bucket, filer.ExtS3ObjectLockKey, encodeResponse(configuration)); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	writeSuccessResponseEmpty(w)
+}
+
+// GetObjectRetentionHandler Get Object Retention
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectRetention.html
+func (s3a *S3ApiServer) GetObjectRetentionHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, object := getBucketAndObject(r)
+
+	entry, errCode := s3a.getObjectLockEntry(bucket, object)
+	if errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	mode := string(entry.Extended[filer.ExtObjectLockModeKey])
+	retainUntil, err := time.Parse(time.RFC3339, string(entry.Extended[filer.ExtObjectLockRetainUntilKey]))
+	if mode == "" || err != nil {
+		writeErrorResponse(w, s3err.ErrNoSuchObjectLockConfiguration, r.URL)
+		return
+	}
+
+	writeSuccessResponseXML(w, encodeResponse(&ObjectRetention{Mode: mode, RetainUntilDate: &retainUntil}))
+}
+
+// PutObjectRetentionHandler Put Object Retention, shortening or removing a GOVERNANCE retention requires the bypass header
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectRetention.html
+func (s3a *S3ApiServer) PutObjectRetentionHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, object := getBucketAndObject(r)
+
+	if _, errCode := s3a.getObjectLockEntry(bucket, object); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	retention := &ObjectRetention{}
+	if errCode := readObjectLockRequest(r, retention); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	header := make(http.Header)
+	if retention.Mode != "" || retention.RetainUntilDate != nil {
+		if retention.RetainUntilDate == nil {
+			writeErrorResponse(w, s3err.ErrMalformedXML, r.URL)
+			return
+		}
+		date := retention.RetainUntilDate.UTC().Format(time.RFC3339)
+		if _, errCode := parseObjectRetention(retention.Mode, date); errCode != s3err.ErrNone {
+			writeErrorResponse(w, errCode, r.URL)
+			return
+		}
+		header.Set(xhttp.AmzObjectLockMode, retention.Mode)
+		header.Set(xhttp.AmzObjectLockRetainUntilDate, date)
+	}
+	if bypass := r.Header.Get(xhttp.AmzBypassGovernanceRetention); bypass != "" {
+		header.Set(xhttp.AmzBypassGovernanceRetention, bypass)
+	}
+
+	if errCode := s3a.putObjectLockToFiler(s3a.objectFullPath(bucket, object), "retention", header); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	writeSuccessResponseEmpty(w)
+}
+
+// GetObjectLegalHoldHandler Get Object Legal Hold
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectLegalHold.html
+func (s3a *S3ApiServer) GetObjectLegalHoldHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, object := getBucketAndObject(r)
+
+	entry, errCode := s3a.getObjectLockEntry(bucket, object)
+	if errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	legalHold := &ObjectLegalHold{Status: LegalHoldOff}
+	if string(entry.Extended[filer.ExtHoldKey]) == filer.ObjectLockLegalHoldIdentity {
+		legalHold.Status = LegalHoldOn
+	}
+
+	writeSuccessResponseXML(w, encodeResponse(legalHold))
+}
+
+// PutObjectLegalHoldHandler Put Object Legal Hold, kept as a hold by the legal hold identity
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectLegalHold.html
+func (s3a *S3ApiServer) PutObjectLegalHoldHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, object := getBucketAndObject(r)
+
+	if _, errCode := s3a.getObjectLockEntry(bucket, object); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	legalHold := &ObjectLegalHold{}
+	if errCode := readObjectLockRequest(r, legalHold); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	if legalHold.Status != LegalHoldOn && legalHold.Status != LegalHoldOff {
+		writeErrorResponse(w, s3err.ErrMalformedXML, r.URL)
+		return
+	}
+
+	if errCode := s3a.setLegalHold(s3a.objectFullPath(bucket, object), legalHold.Status == LegalHoldOn); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+
+	writeSuccessResponseEmpty(w)
+}
+
+func (c *ObjectLockConfiguration) validate() s3err.ErrorCode {
+	if c.ObjectLockEnabled != ObjectLockEnabled {
+		return s3err.ErrMalformedXML
+	}
+	if c.Rule == nil {
+		return s3err.ErrNone
+	}
+	retention := c.Rule.DefaultRetention
+	if retention == nil {
+		return s3err.ErrMalformedXML
+	}
+	if retention.Mode != filer.ObjectLockGovernance && retention.Mode != filer.ObjectLockCompliance {
+		return s3err.ErrMalformedXML
+	}
+	if retention.Days < 0 || retention.Years < 0 || (retention.Days > 0) == (retention.Years > 0) {
+		return s3err.ErrInvalidRequest
+	}
+	return s3err.ErrNone
+}
+
+// retainUntil returns the end of the default retention for the objects created at the time
+func (d *DefaultRetention) retainUntil(now time.Time) time.Time {
+	if d.Years > 0 {
+		return now.UTC().AddDate(d.Years, 0, 0)
+	}
+	return now.UTC().AddDate(0, 0, d.Days)
+}
+
+// getBucketObjectLock returns the object lock configuration of the bucket, nil if object lock is not enabled.
+func (s3a *S3ApiServer) getBucketObjectLock(bucket string) (*ObjectLockConfiguration, s3err.ErrorCode) {
+	data, errCode := s3a.getBucketExtended(bucket, filer.ExtS3ObjectLockKey)
+	if errCode != s3err.ErrNone || len(data) == 0 {
+		return nil, errCode
+	}
+	configuration := &ObjectLockConfiguration{}
+	if err := xml.Unmarshal(data, configuration); err != nil {
+		glog.Errorf("bucket %s object lock: %v", bucket, err)
+		return nil, s3err.ErrInternalError
+	}
+	return configuration, s3err.ErrNone
+}
+
+// applyObjectLock validates the object lock requested in the headers, or sets the headers with the default
+// retention of the bucket. The filer keeps the retention and places the legal hold with the hold headers.
+func (s3a *S3ApiServer) applyObjectLock(r *http.Request, bucket string) s3err.ErrorCode {
+	// the holds are only placed by the object lock
+	r.Header.Del(weed_server.SeaweedHoldHeader)
+	r.Header.Del(weed_server.SeaweedIdentityHeader)
+
+	mode := r.Header.Get(xhttp.AmzObjectLockMode)
+	date := r.Header.Get(xhttp.AmzObjectLockRetainUntilDate)
+	legalHold := r.Header.Get(xhttp.AmzObjectLockLegalHold)
+
+	configuration, errCode := s3a.getBucketObjectLock(bucket)
+	if errCode != s3err.ErrNone {
+		return errCode
+	}
+	if configuration == nil {
+		if mode != "" || date != "" || legalHold != "" {
+			return s3err.ErrInvalidRequest
+		}
+		return s3err.ErrNone
+	}
+
+	if mode != "" || date != "" {
+		if _, errCode := parseObjectRetention(mode, date); errCode != s3err.ErrNone {
+			return errCode
+		}
+	} else if configuration.Rule != nil && configuration.Rule.DefaultRetention != nil {
+		retention := configuration.Rule.DefaultRetention
+		r.Header.Set(xhttp.AmzObjectLockMode, retention.Mode)
+		r.Header.Set(xhttp.AmzObjectLockRetainUntilDate, retention.retainUntil(time.Now()).Format(time.RFC3339))
+	}
+
+	switch legalHold {
+	case LegalHoldOn:
+		r.Header.Set(weed_server.SeaweedHoldHeader, "on")
+		r.Header.Set(weed_server.SeaweedIdentityHeader, filer.ObjectLockLegalHoldIdentity)
+	case "", LegalHoldOff:
+	default:
+		return s3err.ErrInvalidRequest
+	}
+	return s3err.ErrNone
+}
+
+// stripObjectLockHeaders removes the object lock from the requests not creating the objects, e.g. the parts
+func stripObjectLockHeaders(r *http.Request) {
+	for _, h := range []string{xhttp.AmzObjectLockMode, xhttp.AmzObjectLockRetainUntilDate, xhttp.AmzObjectLockLegalHold,
+		weed_server.SeaweedHoldHeader, weed_server.SeaweedIdentityHeader} {
+		r.Header.Del(h)
+	}
+}
+
+func parseObjectRetention(mode, date string) (time.Time, s3err.ErrorCode) {
+	if mode != filer.ObjectLockGovernance && mode != filer.ObjectLockCompliance {
+		return time.Time{}, s3err.ErrInvalidRequest
+	}
+	retainUntil, err := time.Parse(time.RFC3339, date)
+	if err != nil || !retainUntil.After(time.Now()) {
+		return time.Time{}, s3err.ErrInvalidRequest
+	}
+	return retainUntil, s3err.ErrNone
+}
+
+// getObjectLockEntry returns the object entry, if the bucket has object lock enabled
+func (s3a *S3ApiServer) getObjectLockEntry(bucket, object string) (*filer_pb.Entry, s3err.ErrorCode) {
+	configuration, errCode := s3a.getBucketObjectLock(bucket)
+	if errCode != s3err.ErrNone {
+		return nil, errCode
+	}
+	if configuration == nil {
+		return nil, s3err.ErrInvalidRequest
+	}
+	dir, name := util.FullPath(s3a.objectFullPath(bucket, object)).DirAndName()
+	entry, err := s3a.getEntry(dir, name)
+	if err != nil || entry.IsDirectory {
+		return nil, s3err.ErrNoSuchKey
+	}
+	return entry, s3err.ErrNone
+}
+
+func (s3a *S3ApiServer) setLegalHold(fullPath string, isOn bool) s3err.ErrorCode {
+	header := make(http.Header)
+	header.Set(weed_server.SeaweedHoldHeader, "off")
+	if isOn {
+		header.Set(weed_server.SeaweedHoldHeader, "on")
+	}
+	header.Set(weed_server.SeaweedIdentityHeader, filer.ObjectLockLegalHoldIdentity)
+	return s3a.putObjectLockToFiler(fullPath, "hold", header)
+}
+
+// putObjectLockToFiler updates the retention or the hold of the object on the filer
+func (s3a *S3ApiServer) putObjectLockToFiler(fullPath, query string, header http.Header) s3err.ErrorCode {
+	destUrl := fmt.Sprintf("http://%s%s?%s", s3a.option.Filer, urlPathEscape(fullPath), query)
+	req, err := http.NewRequest("PUT", destUrl, nil)
+	if err != nil {
+		glog.Errorf("NewRequest %s: %v", destUrl, err)
+		return s3err.ErrInternalError
+	}
+	req.Header = header
+	resp, err := client.Do(req)
+	if err != nil {
+		glog.Errorf("put %s: %v", destUrl, err)
+		return s3err.ErrInternalError
+	}
+	defer util.CloseResponse(resp)
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusAccepted, http.StatusNoContent:
+		return s3err.ErrNone
+	case http.StatusForbidden:
+		return s3err.ErrAccessDenied
+	case http.StatusNotFound:
+		return s3err.ErrNoSuchKey
+	case http.StatusBadRequest:
+		return s3err.ErrInvalidRequest
+	}
+	glog.Errorf("put %s: %s", destUrl, resp.Status)
+	return s3err.ErrInternalError
+}
+
+// deleteBypassingGovernance deletes the file on the filer, bypassing its GOVERNANCE retention
+func (s3a *S3ApiServer) deleteBypassingGovernance(fullPath string) s3err.ErrorCode {
+	destUrl := fmt.Sprintf("http://%s%s", s3a.option.Filer, urlPathEscape(fullPath))
+	req, err := http.NewRequest("DELETE", destUrl, nil)
+	if err != nil {
+		glog.Errorf("NewRequest %s: %v", destUrl, err)
+		return s3err.ErrInternalError
+	}
+	req.Header.Set(xhttp.AmzBypassGovernanceRetention, "true")
+	resp, err := client.Do(req)
+	if err != nil {
+		glog.Errorf("delete %s: %v", destUrl, err)
+		return s3err.ErrInternalError
+	}
+	defer util.CloseResponse(resp)
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusAccepted, http.StatusNoContent:
+		return s3err.ErrNone
+	case http.StatusForbidden:
+		return s3err.ErrAccessDenied
+	}
+	glog.Errorf("delete %s: %s", destUrl, resp.Status)
+	return s3err.ErrInternalError
+}
+
+func readObjectLockRequest(r *http.Request, v interface{}) s3err.ErrorCode {
+	input, err := ioutil.ReadAll(io.LimitReader(r.Body, maxObjectLockRequestSize))
+	if err != nil {
+		glog.Errorf("read input %s: %v", r.URL, err)
+		return s3err.ErrInternalError
+	}
+	if err = xml.Unmarshal(input, v); err != nil {
+		glog.V(1).Infof("Unmarshal %s: %v", r.URL, err)
+		return s3err.ErrMalformedXML
+	}
+	return s3err.ErrNone
+}
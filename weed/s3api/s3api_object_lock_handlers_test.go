@@ -0,0 +1,64 @@
+package s3api
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+)
+
+func TestObjectLockConfiguration(t *testing.T) {
+
+	for input, expected := range map[string]s3err.ErrorCode{
+		`<ObjectLockConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><ObjectLockEnabled>Enabled</ObjectLockEnabled></ObjectLockConfiguration>`:                                                                                                        s3err.ErrNone,
+		`<ObjectLockConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><ObjectLockEnabled>Enabled</ObjectLockEnabled><Rule><DefaultRetention><Mode>GOVERNANCE</Mode><Days>7</Days></DefaultRetention></Rule></ObjectLockConfiguration>`:                 s3err.ErrNone,
+		`<ObjectLockConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><ObjectLockEnabled>Enabled</ObjectLockEnabled><Rule><DefaultRetention><Mode>COMPLIANCE</Mode><Years>1</Years></DefaultRetention></Rule></ObjectLockConfiguration>`:               s3err.ErrNone,
+		`<ObjectLockConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><ObjectLockEnabled>Enabled</ObjectLockEnabled><Rule><DefaultRetention><Mode>GOVERNANCE</Mode><Days>7</Days><Years>1</Years></DefaultRetention></Rule></ObjectLockConfiguration>`: s3err.ErrInvalidRequest,
+		`<ObjectLockConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><ObjectLockEnabled>Enabled</ObjectLockEnabled><Rule><DefaultRetention><Mode>GOVERNANCE</Mode></DefaultRetention></Rule></ObjectLockConfiguration>`:                               s3err.ErrInvalidRequest,
+		`<ObjectLockConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><ObjectLockEnabled>Enabled</ObjectLockEnabled><Rule><DefaultRetention><Mode>LEGAL</Mode><Days>7</Days></DefaultRetention></Rule></ObjectLockConfiguration>`:                      s3err.ErrMalformedXML,
+		`<ObjectLockConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><ObjectLockEnabled>Enabled</ObjectLockEnabled><Rule></Rule></ObjectLockConfiguration>`:                                                                                           s3err.ErrMalformedXML,
+		`<ObjectLockConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"></ObjectLockConfiguration>`:                                                                                                                                                      s3err.ErrMalformedXML,
+	} {
+		configuration := &ObjectLockConfiguration{}
+		if err := xml.Unmarshal([]byte(input), configuration); err != nil {
+			t.Fatalf("unmarshal %s: %v", input, err)
+		}
+		if errCode := configuration.validate(); errCode != expected {
+			t.Errorf("%s: expecting error %v, got %v", input, expected, errCode)
+		}
+	}
+}
+
+func TestDefaultRetention(t *testing.T) {
+
+	now := time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC)
+	if until := (&DefaultRetention{Days: 1}).retainUntil(now); !until.Equal(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected retain until date %v", until)
+	}
+	if until := (&DefaultRetention{Years: 1}).retainUntil(now); !until.Equal(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected retain until date %v", until)
+	}
+}
+
+func TestParseObjectRetention(t *testing.T) {
+
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+
+	for _, c := range []struct {
+		mode, date string
+		expected   s3err.ErrorCode
+	}{
+		{"GOVERNANCE", future, s3err.ErrNone},
+		{"COMPLIANCE", "2100-01-01T00:00:00.000Z", s3err.ErrNone},
+		{"COMPLIANCE", past, s3err.ErrInvalidRequest},
+		{"GOVERNANCE", "", s3err.ErrInvalidRequest},
+		{"", future, s3err.ErrInvalidRequest},
+		{"governance", future, s3err.ErrInvalidRequest},
+	} {
+		if _, errCode := parseObjectRetention(c.mode, c.date); errCode != c.expected {
+			t.Errorf("%s %s: expecting error %v, got %v", c.mode, c.date, c.expected, errCode)
+		}
+	}
+}
@@ -2,12 +2,15 @@ package s3api
 
 import (
 	"fmt"
+	"github.com/chrislusf/seaweedfs/weed/filer"
 	"github.com/chrislusf/seaweedfs/weed/glog"
+	xhttp "github.com/chrislusf/seaweedfs/weed/s3api/http"
 	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/s3"
@@ -24,10 +27,57 @@ const (
 func (s3a *S3ApiServer) NewMultipartUploadHandler(w http.ResponseWriter, r *http.Request) {
 	bucket, object := getBucketAndObject(r)
 
-	response, errCode := s3a.createMultipartUpload(&s3.CreateMultipartUploadInput{
+	createMultipartUploadInput := &s3.CreateMultipartUploadInput{
 		Bucket: aws.String(bucket),
 		Key:    objectKey(aws.String(object)),
-	})
+	}
+	if tagging := r.Header.Get(xhttp.AmzObjectTagging); tagging != "" {
+		if _, errCode := ParseTagsHeader(tagging); errCode != s3err.ErrNone {
+			writeErrorResponse(w, errCode, r.URL)
+			return
+		}
+		createMultipartUploadInput.Tagging = aws.String(tagging)
+	}
+	if errCode := s3a.applySse(r, bucket); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	if errCode := s3a.applyObjectLock(r, bucket); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	if errCode := s3a.applyObjectAcl(r); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	checksumAlgorithm := strings.ToUpper(r.Header.Get(xhttp.AmzChecksumAlgorithm))
+	if checksumAlgorithm != "" && !filer.IsS3ChecksumAlgorithm(checksumAlgorithm) {
+		writeErrorResponse(w, s3err.ErrInvalidRequest, r.URL)
+		return
+	}
+	if objectAcl := r.Header.Get(xhttp.AmzCannedAcl); objectAcl != "" {
+		createMultipartUploadInput.ACL = aws.String(objectAcl)
+	}
+	if mode := r.Header.Get(xhttp.AmzObjectLockMode); mode != "" {
+		retainUntil, _ := time.Parse(time.RFC3339, r.Header.Get(xhttp.AmzObjectLockRetainUntilDate))
+		createMultipartUploadInput.ObjectLockMode = aws.String(mode)
+		createMultipartUploadInput.ObjectLockRetainUntilDate = aws.Time(retainUntil)
+	}
+	if r.Header.Get(xhttp.AmzObjectLockLegalHold) == LegalHoldOn {
+		createMultipartUploadInput.ObjectLockLegalHoldStatus = aws.String(LegalHoldOn)
+	}
+	if _, keyMD5, _ := sseCustomerKeyOf(r); keyMD5 != "" {
+		createMultipartUploadInput.SSECustomerAlgorithm = aws.String(filer.SseAlgorithmAES256)
+		createMultipartUploadInput.SSECustomerKeyMD5 = aws.String(keyMD5)
+	}
+	if algorithm := r.Header.Get(filer.SseAlgorithmHeader); algorithm != "" {
+		createMultipartUploadInput.ServerSideEncryption = aws.String(algorithm)
+		if keyId := r.Header.Get(filer.SseKmsKeyIdHeader); keyId != "" {
+			createMultipartUploadInput.SSEKMSKeyId = aws.String(keyId)
+		}
+	}
+
+	response, errCode := s3a.createMultipartUpload(createMultipartUploadInput, checksumAlgorithm)
 
 	glog.V(2).Info("NewMultipartUploadHandler", string(encodeResponse(response)), errCode)
 
@@ -36,6 +86,10 @@ func (s3a *S3ApiServer) NewMultipartUploadHandler(w http.ResponseWriter, r *http
 		return
 	}
 
+	setSseResponseHeaders(w, r)
+	if checksumAlgorithm != "" {
+		w.Header().Set(xhttp.AmzChecksumAlgorithm, checksumAlgorithm)
+	}
 	writeSuccessResponseXML(w, encodeResponse(response))
 
 }
@@ -59,6 +113,7 @@ func (s3a *S3ApiServer) CompleteMultipartUploadHandler(w http.ResponseWriter, r
 		writeErrorResponse(w, errCode, r.URL)
 		return
 	}
+	response.Location = aws.String(requestLocation(r))
 
 	writeSuccessResponseXML(w, encodeResponse(response))
 
@@ -165,11 +220,19 @@ func (s3a *S3ApiServer) PutObjectPartHandler(w http.ResponseWriter, r *http.Requ
 	bucket, _ := getBucketAndObject(r)
 
 	uploadID := r.URL.Query().Get("uploadId")
-	exists, err := s3a.exists(s3a.genUploadsFolder(bucket), uploadID, true)
-	if !exists {
+	uploadEntry, err := s3a.getEntry(s3a.genUploadsFolder(bucket), uploadID)
+	if err != nil || !uploadEntry.IsDirectory {
 		writeErrorResponse(w, s3err.ErrNoSuchUpload, r.URL)
 		return
 	}
+	if errCode := applySseUpload(r, uploadEntry); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
+	if errCode := applyChecksumUpload(r, uploadEntry); errCode != s3err.ErrNone {
+		writeErrorResponse(w, errCode, r.URL)
+		return
+	}
 
 	partIDString := r.URL.Query().Get("partNumber")
 	partID, err := strconv.Atoi(partIDString)
@@ -198,13 +261,29 @@ func (s3a *S3ApiServer) PutObjectPartHandler(w http.ResponseWriter, r *http.Requ
 			writeErrorResponse(w, s3ErrCode, r.URL)
 			return
 		}
+	} else if isRequestSignStreamingV4(r) {
+		var s3ErrCode s3err.ErrorCode
+		if dataReader, s3ErrCode = newChunkedReader(r); s3ErrCode != s3err.ErrNone {
+			writeErrorResponse(w, s3ErrCode, r.URL)
+			return
+		}
+	}
+	if isRequestUnsignedTrailer(r) {
+		var s3ErrCode s3err.ErrorCode
+		if dataReader, s3ErrCode = newChunkedReader(r); s3ErrCode != s3err.ErrNone {
+			writeErrorResponse(w, s3ErrCode, r.URL)
+			return
+		}
 	}
 	defer dataReader.Close()
 
 	uploadUrl := fmt.Sprintf("http://%s%s/%s/%04d.part?collection=%s",
 		s3a.option.Filer, s3a.genUploadsFolder(bucket), uploadID, partID, bucket)
 
-	etag, errCode := s3a.putToFiler(r, uploadUrl, dataReader)
+	// the parts are deleted once the upload completes
+	stripObjectLockHeaders(r)
+
+	etag, errCode := s3a.putToFiler(w, r, uploadUrl, dataReader)
 
 	if errCode != s3err.ErrNone {
 		writeErrorResponse(w, errCode, r.URL)
@@ -212,6 +291,8 @@ func (s3a *S3ApiServer) PutObjectPartHandler(w http.ResponseWriter, r *http.Requ
 	}
 
 	setEtag(w, etag)
+	setSseResponseHeaders(w, r)
+	setChecksumResponseHeaders(w, r, dataReader)
 
 	writeSuccessResponseEmpty(w)
 
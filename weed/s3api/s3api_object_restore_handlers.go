@@ -0,0 +1,81 @@
+package s3api
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+type RestoreRequest struct {
+	XMLName xml.Name `xml:"RestoreRequest"`
+	Days    int32    `xml:"Days"`
+}
+
+// RestoreObjectHandler restores an archived object for some days
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_RestoreObject.html
+func (s3a *S3ApiServer) RestoreObjectHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, object := getBucketAndObject(r)
+
+	target := util.FullPath(fmt.Sprintf("%s/%s%s", s3a.option.BucketsPath, bucket, object))
+	dir, name := target.DirAndName()
+
+	restoreRequest := &RestoreRequest{Days: 1}
+	input, err := ioutil.ReadAll(io.LimitReader(r.Body, r.ContentLength))
+	if err != nil {
+		glog.Errorf("RestoreObjectHandler read input %s: %v", r.URL, err)
+		writeErrorResponse(w, s3err.ErrInternalError, r.URL)
+		return
+	}
+	if len(input) > 0 {
+		if err = xml.Unmarshal(input, restoreRequest); err != nil {
+			glog.Errorf("RestoreObjectHandler Unmarshal %s: %v", r.URL, err)
+			writeErrorResponse(w, s3err.ErrMalformedXML, r.URL)
+			return
+		}
+	}
+
+	var resp *filer_pb.RestoreEntryResponse
+	err = s3a.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+		resp, err = client.RestoreEntry(context.Background(), &filer_pb.RestoreEntryRequest{
+			Directory: dir,
+			Name:      name,
+			Days:      restoreRequest.Days,
+		})
+		return err
+	})
+	if err != nil {
+		glog.Errorf("RestoreObjectHandler %s: %v", r.URL, err)
+		writeErrorResponse(w, s3err.ErrInternalError, r.URL)
+		return
+	}
+
+	switch {
+	case resp.Error != "":
+		glog.V(1).Infof("RestoreObjectHandler %s: %s", r.URL, resp.Error)
+		if strings.Contains(resp.Error, filer.ErrEntryNotArchived.Error()) {
+			writeErrorResponse(w, s3err.ErrInvalidObjectState, r.URL)
+		} else if resp.Error == filer_pb.ErrNotFound.Error() {
+			writeErrorResponse(w, s3err.ErrNoSuchKey, r.URL)
+		} else {
+			writeErrorResponse(w, s3err.ErrInternalError, r.URL)
+		}
+	case resp.IsOngoing:
+		writeErrorResponse(w, s3err.ErrRestoreAlreadyInProgress, r.URL)
+	case resp.IsRestored:
+		writeSuccessResponseEmpty(w)
+	default:
+		writeResponse(w, http.StatusAccepted, nil, mimeNone)
+	}
+
+}
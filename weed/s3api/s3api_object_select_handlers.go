@@ -0,0 +1,65 @@
+package s3api
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3select"
+)
+
+const maxSelectRequestSize = 512 * 1024
+
+// SelectObjectContentHandler filters the csv or json object with the sql expression
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_SelectObjectContent.html
+func (s3a *S3ApiServer) SelectObjectContentHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, object := getBucketAndObject(r)
+
+	input, err := ioutil.ReadAll(io.LimitReader(r.Body, maxSelectRequestSize))
+	if err != nil {
+		glog.Errorf("SelectObjectContentHandler read input %s: %v", r.URL, err)
+		writeErrorResponse(w, s3err.ErrInternalError, r.URL)
+		return
+	}
+	selectRequest, query, err := s3select.ParseRequest(input)
+	if err != nil {
+		glog.V(1).Infof("SelectObjectContentHandler %s: %v", r.URL, err)
+		selectErr := err.(*s3select.Error)
+		apiError := s3err.APIError{
+			Code:           selectErr.Code,
+			Description:    selectErr.Message,
+			HTTPStatusCode: http.StatusBadRequest,
+		}
+		writeResponse(w, apiError.HTTPStatusCode, encodeResponse(getRESTErrorResponse(apiError, r.URL.Path)), mimeXML)
+		return
+	}
+
+	// read the object the same way as GetObject, with the sse-c headers if any
+	getRequest := r.Clone(r.Context())
+	getRequest.Method = http.MethodGet
+	getRequest.Body = http.NoBody
+	for _, h := range []string{"Content-Length", "Content-Type", "Content-Md5", "Range"} {
+		getRequest.Header.Del(h)
+	}
+
+	destUrl := fmt.Sprintf("http://%s%s/%s%s",
+		s3a.option.Filer, s3a.option.BucketsPath, bucket, urlPathEscape(object))
+
+	s3a.proxyToFiler(w, getRequest, destUrl, func(proxyResponse *http.Response, w http.ResponseWriter) {
+		if proxyResponse.StatusCode != http.StatusOK {
+			glog.Errorf("SelectObjectContentHandler read %s: %s", destUrl, proxyResponse.Status)
+			writeErrorResponse(w, s3err.ErrInternalError, r.URL)
+			return
+		}
+		setCommonHeaders(w)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.WriteHeader(http.StatusOK)
+		if err := s3select.Execute(w, proxyResponse.Body, selectRequest, query); err != nil {
+			glog.V(1).Infof("SelectObjectContentHandler %s: %v", r.URL, err)
+		}
+	})
+}
@@ -59,25 +59,18 @@ func (s3a *S3ApiServer) PutObjectTaggingHandler(w http.ResponseWriter, r *http.R
 		return
 	}
 	tags := tagging.ToTags()
-	if len(tags) > 10 {
-		glog.Errorf("PutObjectTaggingHandler tags %s: %d tags more than 10", r.URL, len(tags))
+	if len(tags) != len(tagging.TagSet.Tag) {
+		glog.Errorf("PutObjectTaggingHandler tags %s: duplicated tag keys", r.URL)
 		writeErrorResponse(w, s3err.ErrInvalidTag, r.URL)
 		return
 	}
-	for k, v := range tags {
-		if len(k) > 128 {
-			glog.Errorf("PutObjectTaggingHandler tags %s: tag key %s longer than 128", r.URL, k)
-			writeErrorResponse(w, s3err.ErrInvalidTag, r.URL)
-			return
-		}
-		if len(v) > 256 {
-			glog.Errorf("PutObjectTaggingHandler tags %s: tag value %s longer than 256", r.URL, v)
-			writeErrorResponse(w, s3err.ErrInvalidTag, r.URL)
-			return
-		}
+	if errCode := ValidateTags(tags); errCode != s3err.ErrNone {
+		glog.Errorf("PutObjectTaggingHandler tags %s: invalid tags %v", r.URL, tags)
+		writeErrorResponse(w, errCode, r.URL)
+		return
 	}
 
-	if err = s3a.setTags(dir, name, tagging.ToTags()); err != nil {
+	if err = s3a.setTags(dir, name, tags); err != nil {
 		if err == filer_pb.ErrNotFound {
 			glog.Errorf("PutObjectTaggingHandler setTags %s: %v", r.URL, err)
 			writeErrorResponse(w, s3err.ErrNoSuchKey, r.URL)
@@ -0,0 +1,173 @@
+package s3api
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+
+	"github.com/gorilla/mux"
+)
+
+/*
+With the prefetch option, the s3 gateway loads the buckets and the identities from the filer before serving,
+and keeps them updated by subscribing to the filer metadata changes, so the first requests to a new gateway
+do not all look them up from the filer.
+
+The buckets not found in the cache are still looked up from the filer, in case the change is not received yet.
+*/
+
+type bucketCache struct {
+	buckets map[string]*filer_pb.Entry
+	sync.RWMutex
+}
+
+func newBucketCache() *bucketCache {
+	return &bucketCache{
+		buckets: make(map[string]*filer_pb.Entry),
+	}
+}
+
+func (bc *bucketCache) get(bucket string) (entry *filer_pb.Entry, found bool) {
+	bc.RLock()
+	defer bc.RUnlock()
+	entry, found = bc.buckets[bucket]
+	return
+}
+
+func (bc *bucketCache) set(entry *filer_pb.Entry) {
+	bc.Lock()
+	defer bc.Unlock()
+	bc.buckets[entry.Name] = entry
+}
+
+func (bc *bucketCache) delete(bucket string) {
+	bc.Lock()
+	defer bc.Unlock()
+	delete(bc.buckets, bucket)
+}
+
+// list returns the buckets sorted by name, the same order as listed from the filer.
+func (bc *bucketCache) list() (entries []*filer_pb.Entry) {
+	bc.RLock()
+	for _, entry := range bc.buckets {
+		entries = append(entries, entry)
+	}
+	bc.RUnlock()
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+	return
+}
+
+// processEvent applies the filer metadata change of the buckets directory.
+func (bc *bucketCache) processEvent(bucketsPath string, resp *filer_pb.SubscribeMetadataResponse) {
+	message := resp.EventNotification
+	if message.OldEntry != nil && resp.Directory == bucketsPath {
+		bc.delete(message.OldEntry.Name)
+	}
+	newDir := resp.Directory
+	if message.NewParentPath != "" {
+		newDir = message.NewParentPath
+	}
+	if message.NewEntry != nil && message.NewEntry.IsDirectory && newDir == bucketsPath {
+		bc.set(message.NewEntry)
+	}
+}
+
+func newPrefetchedS3ApiServer(router *mux.Router, option *S3ApiServerOption) *S3ApiServer {
+	s3ApiServer := &S3ApiServer{
+		option:         option,
+		iam:            newIdentityAccessManagement(option),
+		bucketPolicies: &bucketPolicyCache{policies: make(map[string]*cachedBucketPolicy)},
+		bucketCors:     &bucketCorsCache{configurations: make(map[string]*cachedBucketCors)},
+		usage:          newUsageMeter(),
+	}
+	s3ApiServer.iam.bucketPolicy = s3ApiServer.getBucketPolicy
+	s3ApiServer.iam.bucketPublicAccess = s3ApiServer.getBucketPublicAccess
+	s3ApiServer.iam.objectPublicAccess = s3ApiServer.getObjectPublicAccess
+	s3ApiServer.entryBatcher = filer_pb.NewEntryBatcher(s3ApiServer, entryBatchSize, entryBatchWait)
+	if option.Config != "" {
+		if err := s3ApiServer.iam.loadS3ApiConfigurationFromFile(option.Config); err != nil {
+			glog.Fatalf("fail to load config file %s: %v", option.Config, err)
+		}
+	}
+
+	// subscribe since before the prefetching, to not miss any changes
+	lastTsNs := time.Now().UnixNano()
+	s3ApiServer.prefetch()
+
+	s3ApiServer.registerRouter(router)
+
+	go s3ApiServer.subscribeMetaEvents("s3", filer.IamConfigDirecotry+"/"+filer.IamIdentityFile, lastTsNs)
+	go s3ApiServer.subscribeMetaEvents("s3", s3ApiServer.option.BucketsPath, lastTsNs)
+	go s3ApiServer.loopCollectingStorageUsage()
+
+	return s3ApiServer
+}
+
+// prefetch loads the identities and the buckets from the filer, retrying until the filer is available.
+func (s3a *S3ApiServer) prefetch() {
+	for {
+		err := s3a.doPrefetch()
+		if err == nil {
+			return
+		}
+		glog.V(0).Infof("wait to prefetch from filer %s: %v", s3a.option.Filer, err)
+		time.Sleep(time.Second)
+	}
+}
+
+func (s3a *S3ApiServer) doPrefetch() error {
+
+	if s3a.option.Config == "" {
+		entry, err := filer_pb.GetEntry(s3a, util.NewFullPath(filer.IamConfigDirecotry, filer.IamIdentityFile))
+		if err != nil {
+			return fmt.Errorf("read S3 config: %v", err)
+		}
+		if entry != nil {
+			content := entry.Content
+			if len(content) == 0 && len(entry.Chunks) > 0 {
+				if content, err = filer.ReadContent(s3a.option.Filer, filer.IamConfigDirecotry, filer.IamIdentityFile); err != nil {
+					return fmt.Errorf("read S3 config: %v", err)
+				}
+			}
+			if len(content) > 0 {
+				if err = s3a.iam.loadS3ApiConfigurationFromBytes(content); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	entries, _, err := s3a.list(s3a.option.BucketsPath, "", "", false, math.MaxInt32)
+	if err != nil {
+		return fmt.Errorf("list buckets: %v", err)
+	}
+	buckets := newBucketCache()
+	for _, entry := range entries {
+		if entry.IsDirectory {
+			buckets.set(entry)
+		}
+	}
+	s3a.buckets = buckets
+
+	glog.V(0).Infof("prefetched %d buckets from filer %s", len(buckets.buckets), s3a.option.Filer)
+	return nil
+}
+
+// getBucketEntry looks up the bucket from the prefetched buckets first.
+func (s3a *S3ApiServer) getBucketEntry(bucket string) (*filer_pb.Entry, error) {
+	if s3a.buckets != nil {
+		if entry, found := s3a.buckets.get(bucket); found {
+			return entry, nil
+		}
+	}
+	return s3a.getEntry(s3a.option.BucketsPath, bucket)
+}
@@ -0,0 +1,60 @@
+package s3api
+
+import (
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+)
+
+func TestBucketCacheProcessEvent(t *testing.T) {
+	bc := newBucketCache()
+	bc.set(&filer_pb.Entry{Name: "existing", IsDirectory: true})
+
+	events := []*filer_pb.SubscribeMetadataResponse{
+		// created bucket
+		{Directory: "/buckets", EventNotification: &filer_pb.EventNotification{
+			NewEntry: &filer_pb.Entry{Name: "created", IsDirectory: true},
+		}},
+		// object in a bucket
+		{Directory: "/buckets/created", EventNotification: &filer_pb.EventNotification{
+			NewEntry: &filer_pb.Entry{Name: "object"},
+		}},
+		// file in the buckets directory
+		{Directory: "/buckets", EventNotification: &filer_pb.EventNotification{
+			NewEntry: &filer_pb.Entry{Name: "file"},
+		}},
+		// renamed bucket
+		{Directory: "/buckets", EventNotification: &filer_pb.EventNotification{
+			OldEntry:      &filer_pb.Entry{Name: "existing", IsDirectory: true},
+			NewEntry:      &filer_pb.Entry{Name: "renamed", IsDirectory: true},
+			NewParentPath: "/buckets",
+		}},
+		// deleted bucket
+		{Directory: "/buckets", EventNotification: &filer_pb.EventNotification{
+			OldEntry: &filer_pb.Entry{Name: "created", IsDirectory: true},
+		}},
+		// bucket moved out of the buckets directory
+		{Directory: "/buckets", EventNotification: &filer_pb.EventNotification{
+			OldEntry:      &filer_pb.Entry{Name: "renamed", IsDirectory: true},
+			NewEntry:      &filer_pb.Entry{Name: "renamed", IsDirectory: true},
+			NewParentPath: "/archived",
+		}},
+		{Directory: "/buckets", EventNotification: &filer_pb.EventNotification{
+			NewEntry: &filer_pb.Entry{Name: "b", IsDirectory: true},
+		}},
+		{Directory: "/buckets", EventNotification: &filer_pb.EventNotification{
+			NewEntry: &filer_pb.Entry{Name: "a", IsDirectory: true},
+		}},
+	}
+	for _, event := range events {
+		bc.processEvent("/buckets", event)
+	}
+
+	var names []string
+	for _, entry := range bc.list() {
+		names = append(names, entry.Name)
+	}
+	if len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Errorf("unexpected buckets %v", names)
+	}
+}
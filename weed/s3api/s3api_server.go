@@ -3,9 +3,9 @@ package s3api
 import (
 	"fmt"
 	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
 	. "github.com/chrislusf/seaweedfs/weed/s3api/s3_constants"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -18,97 +18,222 @@ type S3ApiServerOption struct {
 	FilerGrpcAddress string
 	Config           string
 	DomainName       string
-	BucketsPath      string
-	GrpcDialOption   grpc.DialOption
-	AllowEmptyFolder bool
+	// serves the buckets as static websites on {bucket}.{websiteDomainName}
+	WebsiteDomainName string
+	BucketsPath       string
+	GrpcDialOption    grpc.DialOption
+	AllowEmptyFolder  bool
+	Prefetch          bool
+	// ignores the public access of the buckets and the bucket policies for the anonymous requests
+	BlockPublic bool
 }
 
+const (
+	// the multipart completions within the wait are created on the filer with one request
+	entryBatchSize = 64
+	entryBatchWait = 5 * time.Millisecond
+)
+
 type S3ApiServer struct {
-	option *S3ApiServerOption
-	iam    *IdentityAccessManagement
+	option  *S3ApiServerOption
+	iam     *IdentityAccessManagement
+	buckets *bucketCache // only with the prefetch option
+	// the parsed bucket policies
+	bucketPolicies *bucketPolicyCache
+	// the parsed bucket CORS rules
+	bucketCors *bucketCorsCache
+	// groups the entry creations of the concurrent multipart completions
+	entryBatcher *filer_pb.EntryBatcher
+	usage        *usageMeter
 }
 
 func NewS3ApiServer(router *mux.Router, option *S3ApiServerOption) (s3ApiServer *S3ApiServer, err error) {
+	if option.Prefetch {
+		return newPrefetchedS3ApiServer(router, option), nil
+	}
+
 	s3ApiServer = &S3ApiServer{
-		option: option,
-		iam:    NewIdentityAccessManagement(option),
+		option:         option,
+		iam:            NewIdentityAccessManagement(option),
+		bucketPolicies: &bucketPolicyCache{policies: make(map[string]*cachedBucketPolicy)},
+		bucketCors:     &bucketCorsCache{configurations: make(map[string]*cachedBucketCors)},
+		usage:          newUsageMeter(),
 	}
+	s3ApiServer.iam.bucketPolicy = s3ApiServer.getBucketPolicy
+	s3ApiServer.iam.bucketPublicAccess = s3ApiServer.getBucketPublicAccess
+	s3ApiServer.iam.objectPublicAccess = s3ApiServer.getObjectPublicAccess
+	s3ApiServer.entryBatcher = filer_pb.NewEntryBatcher(s3ApiServer, entryBatchSize, entryBatchWait)
 
 	s3ApiServer.registerRouter(router)
 
+	go s3ApiServer.loopCollectingStorageUsage()
+
 	go s3ApiServer.subscribeMetaEvents("s3", filer.IamConfigDirecotry+"/"+filer.IamIdentityFile, time.Now().UnixNano())
 
 	return s3ApiServer, nil
 }
 
 func (s3a *S3ApiServer) registerRouter(router *mux.Router) {
+	// Website endpoints
+	for _, domainName := range parseDomainNames(s3a.option.WebsiteDomainName) {
+		website := router.Host(
+			fmt.Sprintf("%s.%s%s", "{bucket:.+}", domainName, "{port:(?::[0-9]+)?}")).Subrouter()
+		website.Use(s3a.applyCors)
+		website.Methods("OPTIONS").Path("/{object:.*}").HandlerFunc(s3a.track(s3a.CorsPreflightHandler, "OPTIONS"))
+		website.Methods("GET", "HEAD").Path("/{object:.*}").HandlerFunc(s3a.track(s3a.WebsiteHandler, "GET"))
+		website.PathPrefix("/").HandlerFunc(websiteMethodNotAllowedHandler)
+	}
+
 	// API Router
 	apiRouter := router.PathPrefix("/").Subrouter()
 	var routers []*mux.Router
-	if s3a.option.DomainName != "" {
-		domainNames := strings.Split(s3a.option.DomainName, ",")
-		for _, domainName := range domainNames {
-			routers = append(routers, apiRouter.Host(
-				fmt.Sprintf("%s.%s:%d", "{bucket:.+}", domainName, s3a.option.Port)).Subrouter())
-			routers = append(routers, apiRouter.Host(
-				fmt.Sprintf("%s.%s", "{bucket:.+}", domainName)).Subrouter())
-		}
+	for _, domainName := range parseDomainNames(s3a.option.DomainName) {
+		// match the hosts with any port, or without the port
+		routers = append(routers, apiRouter.Host(
+			fmt.Sprintf("%s.%s%s", "{bucket:.+}", domainName, "{port:(?::[0-9]+)?}")).Subrouter())
 	}
 	routers = append(routers, apiRouter.PathPrefix("/{bucket}").Subrouter())
 
 	for _, bucket := range routers {
 
+		bucket.Use(s3a.applyCors)
+
+		// CORS preflight
+		bucket.Methods("OPTIONS").Path("/{object:.+}").HandlerFunc(s3a.track(s3a.CorsPreflightHandler, "OPTIONS"))
+		bucket.Methods("OPTIONS").HandlerFunc(s3a.track(s3a.CorsPreflightHandler, "OPTIONS"))
+
 		// HeadObject
-		bucket.Methods("HEAD").Path("/{object:.+}").HandlerFunc(track(s3a.iam.Auth(s3a.HeadObjectHandler, ACTION_READ), "GET"))
+		bucket.Methods("HEAD").Path("/{object:.+}").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.HeadObjectHandler, ACTION_READ), "GET"))
 		// HeadBucket
-		bucket.Methods("HEAD").HandlerFunc(track(s3a.iam.Auth(s3a.HeadBucketHandler, ACTION_ADMIN), "GET"))
+		bucket.Methods("HEAD").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.HeadBucketHandler, ACTION_ADMIN), "GET"))
 
 		// CopyObjectPart
-		bucket.Methods("PUT").Path("/{object:.+}").HeadersRegexp("X-Amz-Copy-Source", `.*?(\/|%2F).*?`).HandlerFunc(track(s3a.iam.Auth(s3a.CopyObjectPartHandler, ACTION_WRITE), "PUT")).Queries("partNumber", "{partNumber:[0-9]+}", "uploadId", "{uploadId:.*}")
+		bucket.Methods("PUT").Path("/{object:.+}").HeadersRegexp("X-Amz-Copy-Source", `.*?(\/|%2F).*?`).HandlerFunc(s3a.track(s3a.iam.Auth(s3a.CopyObjectPartHandler, ACTION_WRITE), "PUT")).Queries("partNumber", "{partNumber:[0-9]+}", "uploadId", "{uploadId:.*}")
 		// PutObjectPart
-		bucket.Methods("PUT").Path("/{object:.+}").HandlerFunc(track(s3a.iam.Auth(s3a.PutObjectPartHandler, ACTION_WRITE), "PUT")).Queries("partNumber", "{partNumber:[0-9]+}", "uploadId", "{uploadId:.*}")
+		bucket.Methods("PUT").Path("/{object:.+}").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.PutObjectPartHandler, ACTION_WRITE), "PUT")).Queries("partNumber", "{partNumber:[0-9]+}", "uploadId", "{uploadId:.*}")
 		// CompleteMultipartUpload
-		bucket.Methods("POST").Path("/{object:.+}").HandlerFunc(track(s3a.iam.Auth(s3a.CompleteMultipartUploadHandler, ACTION_WRITE), "POST")).Queries("uploadId", "{uploadId:.*}")
+		bucket.Methods("POST").Path("/{object:.+}").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.CompleteMultipartUploadHandler, ACTION_WRITE), "POST")).Queries("uploadId", "{uploadId:.*}")
 		// NewMultipartUpload
-		bucket.Methods("POST").Path("/{object:.+}").HandlerFunc(track(s3a.iam.Auth(s3a.NewMultipartUploadHandler, ACTION_WRITE), "POST")).Queries("uploads", "")
+		bucket.Methods("POST").Path("/{object:.+}").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.NewMultipartUploadHandler, ACTION_WRITE), "POST")).Queries("uploads", "")
 		// AbortMultipartUpload
-		bucket.Methods("DELETE").Path("/{object:.+}").HandlerFunc(track(s3a.iam.Auth(s3a.AbortMultipartUploadHandler, ACTION_WRITE), "DELETE")).Queries("uploadId", "{uploadId:.*}")
+		bucket.Methods("DELETE").Path("/{object:.+}").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.AbortMultipartUploadHandler, ACTION_WRITE), "DELETE")).Queries("uploadId", "{uploadId:.*}")
 		// ListObjectParts
-		bucket.Methods("GET").Path("/{object:.+}").HandlerFunc(track(s3a.iam.Auth(s3a.ListObjectPartsHandler, ACTION_READ), "GET")).Queries("uploadId", "{uploadId:.*}")
+		bucket.Methods("GET").Path("/{object:.+}").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.ListObjectPartsHandler, ACTION_READ), "GET")).Queries("uploadId", "{uploadId:.*}")
 		// ListMultipartUploads
-		bucket.Methods("GET").HandlerFunc(track(s3a.iam.Auth(s3a.ListMultipartUploadsHandler, ACTION_READ), "GET")).Queries("uploads", "")
+		bucket.Methods("GET").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.ListMultipartUploadsHandler, ACTION_READ), "GET")).Queries("uploads", "")
+
+		// GetObjectAttributes
+		bucket.Methods("GET").Path("/{object:.+}").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.GetObjectAttributesHandler, ACTION_READ), "GET")).Queries("attributes", "")
 
 		// GetObjectTagging
-		bucket.Methods("GET").Path("/{object:.+}").HandlerFunc(track(s3a.iam.Auth(s3a.GetObjectTaggingHandler, ACTION_READ), "GET")).Queries("tagging", "")
+		bucket.Methods("GET").Path("/{object:.+}").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.GetObjectTaggingHandler, ACTION_READ), "GET")).Queries("tagging", "")
 		// PutObjectTagging
-		bucket.Methods("PUT").Path("/{object:.+}").HandlerFunc(track(s3a.iam.Auth(s3a.PutObjectTaggingHandler, ACTION_TAGGING), "PUT")).Queries("tagging", "")
+		bucket.Methods("PUT").Path("/{object:.+}").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.PutObjectTaggingHandler, ACTION_TAGGING), "PUT")).Queries("tagging", "")
 		// DeleteObjectTagging
-		bucket.Methods("DELETE").Path("/{object:.+}").HandlerFunc(track(s3a.iam.Auth(s3a.DeleteObjectTaggingHandler, ACTION_TAGGING), "DELETE")).Queries("tagging", "")
+		bucket.Methods("DELETE").Path("/{object:.+}").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.DeleteObjectTaggingHandler, ACTION_TAGGING), "DELETE")).Queries("tagging", "")
+
+		// RestoreObject
+		bucket.Methods("POST").Path("/{object:.+}").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.RestoreObjectHandler, ACTION_WRITE), "POST")).Queries("restore", "")
+		// SelectObjectContent
+		bucket.Methods("POST").Path("/{object:.+}").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.SelectObjectContentHandler, ACTION_READ), "POST")).Queries("select", "", "select-type", "2")
+
+		// GetObjectAcl
+		bucket.Methods("GET").Path("/{object:.+}").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.GetObjectAclHandler, ACTION_READ), "GET")).Queries("acl", "")
+		// PutObjectAcl
+		bucket.Methods("PUT").Path("/{object:.+}").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.PutObjectAclHandler, ACTION_WRITE), "PUT")).Queries("acl", "")
+
+		// GetObjectRetention
+		bucket.Methods("GET").Path("/{object:.+}").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.GetObjectRetentionHandler, ACTION_READ), "GET")).Queries("retention", "")
+		// PutObjectRetention
+		bucket.Methods("PUT").Path("/{object:.+}").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.PutObjectRetentionHandler, ACTION_WRITE), "PUT")).Queries("retention", "")
+		// GetObjectLegalHold
+		bucket.Methods("GET").Path("/{object:.+}").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.GetObjectLegalHoldHandler, ACTION_READ), "GET")).Queries("legal-hold", "")
+		// PutObjectLegalHold
+		bucket.Methods("PUT").Path("/{object:.+}").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.PutObjectLegalHoldHandler, ACTION_WRITE), "PUT")).Queries("legal-hold", "")
+
+		// GetBucketLifecycleConfiguration
+		bucket.Methods("GET").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.GetBucketLifecycleConfigurationHandler, ACTION_ADMIN), "GET")).Queries("lifecycle", "")
+		// PutBucketLifecycleConfiguration
+		bucket.Methods("PUT").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.PutBucketLifecycleConfigurationHandler, ACTION_ADMIN), "PUT")).Queries("lifecycle", "")
+		// DeleteBucketLifecycle
+		bucket.Methods("DELETE").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.DeleteBucketLifecycleHandler, ACTION_ADMIN), "DELETE")).Queries("lifecycle", "")
+
+		// GetBucketEncryption
+		bucket.Methods("GET").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.GetBucketEncryptionHandler, ACTION_ADMIN), "GET")).Queries("encryption", "")
+		// PutBucketEncryption
+		bucket.Methods("PUT").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.PutBucketEncryptionHandler, ACTION_ADMIN), "PUT")).Queries("encryption", "")
+		// DeleteBucketEncryption
+		bucket.Methods("DELETE").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.DeleteBucketEncryptionHandler, ACTION_ADMIN), "DELETE")).Queries("encryption", "")
+
+		// GetBucketWebsite
+		bucket.Methods("GET").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.GetBucketWebsiteHandler, ACTION_ADMIN), "GET")).Queries("website", "")
+		// PutBucketWebsite
+		bucket.Methods("PUT").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.PutBucketWebsiteHandler, ACTION_ADMIN), "PUT")).Queries("website", "")
+		// DeleteBucketWebsite
+		bucket.Methods("DELETE").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.DeleteBucketWebsiteHandler, ACTION_ADMIN), "DELETE")).Queries("website", "")
+
+		// GetBucketInventoryConfiguration
+		bucket.Methods("GET").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.GetBucketInventoryConfigurationHandler, ACTION_ADMIN), "GET")).Queries("inventory", "", "id", "{id:.*}")
+		// PutBucketInventoryConfiguration
+		bucket.Methods("PUT").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.PutBucketInventoryConfigurationHandler, ACTION_ADMIN), "PUT")).Queries("inventory", "", "id", "{id:.*}")
+		// DeleteBucketInventoryConfiguration
+		bucket.Methods("DELETE").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.DeleteBucketInventoryConfigurationHandler, ACTION_ADMIN), "DELETE")).Queries("inventory", "", "id", "{id:.*}")
+		// ListBucketInventoryConfigurations
+		bucket.Methods("GET").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.ListBucketInventoryConfigurationsHandler, ACTION_ADMIN), "LIST")).Queries("inventory", "")
+
+		// GetBucketAcl
+		bucket.Methods("GET").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.GetBucketAclHandler, ACTION_ADMIN), "GET")).Queries("acl", "")
+		// PutBucketAcl
+		bucket.Methods("PUT").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.PutBucketAclHandler, ACTION_ADMIN), "PUT")).Queries("acl", "")
+
+		// GetBucketPolicy
+		bucket.Methods("GET").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.GetBucketPolicyHandler, ACTION_ADMIN), "GET")).Queries("policy", "")
+		// PutBucketPolicy
+		bucket.Methods("PUT").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.PutBucketPolicyHandler, ACTION_ADMIN), "PUT")).Queries("policy", "")
+		// DeleteBucketPolicy
+		bucket.Methods("DELETE").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.DeleteBucketPolicyHandler, ACTION_ADMIN), "DELETE")).Queries("policy", "")
+
+		// GetBucketCors
+		bucket.Methods("GET").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.GetBucketCorsHandler, ACTION_ADMIN), "GET")).Queries("cors", "")
+		// PutBucketCors
+		bucket.Methods("PUT").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.PutBucketCorsHandler, ACTION_ADMIN), "PUT")).Queries("cors", "")
+		// DeleteBucketCors
+		bucket.Methods("DELETE").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.DeleteBucketCorsHandler, ACTION_ADMIN), "DELETE")).Queries("cors", "")
+
+		// GetBucketNotificationConfiguration
+		bucket.Methods("GET").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.GetBucketNotificationHandler, ACTION_ADMIN), "GET")).Queries("notification", "")
+		// PutBucketNotificationConfiguration
+		bucket.Methods("PUT").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.PutBucketNotificationHandler, ACTION_ADMIN), "PUT")).Queries("notification", "")
+
+		// GetObjectLockConfiguration
+		bucket.Methods("GET").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.GetObjectLockConfigurationHandler, ACTION_ADMIN), "GET")).Queries("object-lock", "")
+		// PutObjectLockConfiguration
+		bucket.Methods("PUT").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.PutObjectLockConfigurationHandler, ACTION_ADMIN), "PUT")).Queries("object-lock", "")
 
 		// CopyObject
-		bucket.Methods("PUT").Path("/{object:.+}").HeadersRegexp("X-Amz-Copy-Source", ".*?(\\/|%2F).*?").HandlerFunc(track(s3a.iam.Auth(s3a.CopyObjectHandler, ACTION_WRITE), "COPY"))
+		bucket.Methods("PUT").Path("/{object:.+}").HeadersRegexp("X-Amz-Copy-Source", ".*?(\\/|%2F).*?").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.CopyObjectHandler, ACTION_WRITE), "COPY"))
 		// PutObject
-		bucket.Methods("PUT").Path("/{object:.+}").HandlerFunc(track(s3a.iam.Auth(s3a.PutObjectHandler, ACTION_WRITE), "PUT"))
+		bucket.Methods("PUT").Path("/{object:.+}").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.PutObjectHandler, ACTION_WRITE), "PUT"))
 		// PutBucket
-		bucket.Methods("PUT").HandlerFunc(track(s3a.iam.Auth(s3a.PutBucketHandler, ACTION_ADMIN), "PUT"))
+		bucket.Methods("PUT").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.PutBucketHandler, ACTION_ADMIN), "PUT"))
 
 		// DeleteObject
-		bucket.Methods("DELETE").Path("/{object:.+}").HandlerFunc(track(s3a.iam.Auth(s3a.DeleteObjectHandler, ACTION_WRITE), "DELETE"))
+		bucket.Methods("DELETE").Path("/{object:.+}").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.DeleteObjectHandler, ACTION_WRITE), "DELETE"))
 		// DeleteBucket
-		bucket.Methods("DELETE").HandlerFunc(track(s3a.iam.Auth(s3a.DeleteBucketHandler, ACTION_WRITE), "DELETE"))
+		bucket.Methods("DELETE").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.DeleteBucketHandler, ACTION_WRITE), "DELETE"))
 
 		// ListObjectsV2
-		bucket.Methods("GET").HandlerFunc(track(s3a.iam.Auth(s3a.ListObjectsV2Handler, ACTION_LIST), "LIST")).Queries("list-type", "2")
+		bucket.Methods("GET").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.ListObjectsV2Handler, ACTION_LIST), "LIST")).Queries("list-type", "2")
 		// GetObject, but directory listing is not supported
-		bucket.Methods("GET").Path("/{object:.+}").HandlerFunc(track(s3a.iam.Auth(s3a.GetObjectHandler, ACTION_READ), "GET"))
+		bucket.Methods("GET").Path("/{object:.+}").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.GetObjectHandler, ACTION_READ), "GET"))
 		// ListObjectsV1 (Legacy)
-		bucket.Methods("GET").HandlerFunc(track(s3a.iam.Auth(s3a.ListObjectsV1Handler, ACTION_LIST), "LIST"))
+		bucket.Methods("GET").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.ListObjectsV1Handler, ACTION_LIST), "LIST"))
 
 		// PostPolicy
-		bucket.Methods("POST").HeadersRegexp("Content-Type", "multipart/form-data*").HandlerFunc(track(s3a.iam.Auth(s3a.PostPolicyBucketHandler, ACTION_WRITE), "POST"))
+		bucket.Methods("POST").HeadersRegexp("Content-Type", "multipart/form-data*").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.PostPolicyBucketHandler, ACTION_WRITE), "POST"))
 
 		// DeleteMultipleObjects
-		bucket.Methods("POST").HandlerFunc(track(s3a.iam.Auth(s3a.DeleteMultipleObjectsHandler, ACTION_WRITE), "DELETE")).Queries("delete", "")
+		bucket.Methods("POST").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.DeleteMultipleObjectsHandler, ACTION_WRITE), "DELETE")).Queries("delete", "")
 		/*
 
 			// not implemented
@@ -116,10 +241,6 @@ func (s3a *S3ApiServer) registerRouter(router *mux.Router) {
 			bucket.Methods("GET").HandlerFunc(s3a.GetBucketLocationHandler).Queries("location", "")
 			// GetBucketPolicy
 			bucket.Methods("GET").HandlerFunc(s3a.GetBucketPolicyHandler).Queries("policy", "")
-			// GetObjectACL
-			bucket.Methods("GET").Path("/{object:.+}").HandlerFunc(s3a.GetObjectACLHandler).Queries("acl", "")
-			// GetBucketACL
-			bucket.Methods("GET").HandlerFunc(s3a.GetBucketACLHandler).Queries("acl", "")
 			// PutBucketPolicy
 			bucket.Methods("PUT").HandlerFunc(s3a.PutBucketPolicyHandler).Queries("policy", "")
 			// DeleteBucketPolicy
@@ -128,8 +249,14 @@ func (s3a *S3ApiServer) registerRouter(router *mux.Router) {
 
 	}
 
+	// GetUsage
+	apiRouter.Methods("GET").Path("/").HandlerFunc(s3a.track(s3a.iam.Auth(s3a.GetUsageHandler, ACTION_ADMIN), "GET")).Queries("usage", "")
+
 	// ListBuckets
-	apiRouter.Methods("GET").Path("/").HandlerFunc(track(s3a.ListBucketsHandler, "LIST"))
+	apiRouter.Methods("GET").Path("/").HandlerFunc(s3a.track(s3a.ListBucketsHandler, "LIST"))
+
+	// AssumeRole, GetSessionToken
+	apiRouter.Methods("POST").Path("/").HandlerFunc(s3a.track(s3a.StsHandler, "STS"))
 
 	// NotFound
 	apiRouter.NotFoundHandler = http.HandlerFunc(notFoundHandler)
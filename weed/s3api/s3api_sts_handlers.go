@@ -0,0 +1,220 @@
+package s3api
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/s3api/policy_engine"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+)
+
+// https://docs.aws.amazon.com/STS/latest/APIReference/API_AssumeRole.html
+// https://docs.aws.amazon.com/STS/latest/APIReference/API_GetSessionToken.html
+
+const (
+	stsDefaultDuration = time.Hour
+	stsMinDuration     = 15 * time.Minute
+	stsMaxDuration     = 12 * time.Hour
+	stsMaxPolicySize   = 2048
+)
+
+type StsCredentials struct {
+	AccessKeyId     string    `xml:"AccessKeyId"`
+	SecretAccessKey string    `xml:"SecretAccessKey"`
+	SessionToken    string    `xml:"SessionToken"`
+	Expiration      time.Time `xml:"Expiration"`
+}
+
+type StsResponseMetadata struct {
+	RequestId string `xml:"RequestId"`
+}
+
+type AssumeRoleResponse struct {
+	XMLName          xml.Name `xml:"https://sts.amazonaws.com/doc/2011-06-15/ AssumeRoleResponse"`
+	AssumeRoleResult struct {
+		Credentials     StsCredentials `xml:"Credentials"`
+		AssumedRoleUser struct {
+			AssumedRoleId string `xml:"AssumedRoleId"`
+			Arn           string `xml:"Arn"`
+		} `xml:"AssumedRoleUser"`
+	} `xml:"AssumeRoleResult"`
+	ResponseMetadata StsResponseMetadata `xml:"ResponseMetadata"`
+}
+
+type GetSessionTokenResponse struct {
+	XMLName               xml.Name `xml:"https://sts.amazonaws.com/doc/2011-06-15/ GetSessionTokenResponse"`
+	GetSessionTokenResult struct {
+		Credentials StsCredentials `xml:"Credentials"`
+	} `xml:"GetSessionTokenResult"`
+	ResponseMetadata StsResponseMetadata `xml:"ResponseMetadata"`
+}
+
+type StsErrorResponse struct {
+	XMLName xml.Name `xml:"https://sts.amazonaws.com/doc/2011-06-15/ ErrorResponse"`
+	Error   struct {
+		Type    string `xml:"Type"`
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	} `xml:"Error"`
+	RequestId string `xml:"RequestId"`
+}
+
+// StsHandler issues the temporary credentials, with the sts actions sent as the form values of "POST /".
+func (s3a *S3ApiServer) StsHandler(w http.ResponseWriter, r *http.Request) {
+
+	if !s3a.iam.isStsEnabled() {
+		writeStsErrorResponse(w, http.StatusNotImplemented, "NotImplemented", "The temporary credentials are not enabled.")
+		return
+	}
+
+	identity, errCode := s3a.iam.authUser(r)
+	if errCode != s3err.ErrNone {
+		apiError := s3err.GetAPIError(errCode)
+		writeStsErrorResponse(w, apiError.HTTPStatusCode, apiError.Code, apiError.Description)
+		return
+	}
+	if identity == nil || identity.Name == "anonymous" {
+		writeStsErrorResponse(w, http.StatusForbidden, "AccessDenied", "The request is not signed with the credentials of an identity.")
+		return
+	}
+	if identity.isSession() {
+		writeStsErrorResponse(w, http.StatusForbidden, "AccessDenied", "The temporary credentials can not request other temporary credentials.")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		writeStsErrorResponse(w, http.StatusBadRequest, "InvalidParameterValue", err.Error())
+		return
+	}
+
+	duration, err := stsDurationOf(r.Form.Get("DurationSeconds"))
+	if err != nil {
+		writeStsErrorResponse(w, http.StatusBadRequest, "ValidationError", err.Error())
+		return
+	}
+
+	requestId := fmt.Sprintf("%d", time.Now().UnixNano())
+	switch action := r.Form.Get("Action"); action {
+	case "GetSessionToken":
+		cred, err := s3a.iam.issueSessionCredential(identity.Name, "", "", duration)
+		if err != nil {
+			glog.Errorf("GetSessionToken of %s: %v", identity.Name, err)
+			writeStsErrorResponse(w, http.StatusInternalServerError, "InternalError", err.Error())
+			return
+		}
+		resp := &GetSessionTokenResponse{}
+		resp.GetSessionTokenResult.Credentials = stsCredentialsOf(cred)
+		resp.ResponseMetadata.RequestId = requestId
+		writeSuccessResponseXML(w, encodeResponse(resp))
+
+	case "AssumeRole":
+		roleName, sessionName, policy, err := s3a.assumeRoleOf(r, identity)
+		if err != nil {
+			code := "ValidationError"
+			if err == errAssumeRoleDenied {
+				writeStsErrorResponse(w, http.StatusForbidden, "AccessDenied", err.Error())
+				return
+			}
+			if err == errMalformedSessionPolicy {
+				code = "MalformedPolicyDocument"
+			}
+			writeStsErrorResponse(w, http.StatusBadRequest, code, err.Error())
+			return
+		}
+		cred, err := s3a.iam.issueSessionCredential(roleName, sessionName, policy, duration)
+		if err != nil {
+			glog.Errorf("AssumeRole %s by %s: %v", roleName, identity.Name, err)
+			writeStsErrorResponse(w, http.StatusInternalServerError, "InternalError", err.Error())
+			return
+		}
+		resp := &AssumeRoleResponse{}
+		resp.AssumeRoleResult.Credentials = stsCredentialsOf(cred)
+		resp.AssumeRoleResult.AssumedRoleUser.AssumedRoleId = cred.AccessKey + ":" + sessionName
+		resp.AssumeRoleResult.AssumedRoleUser.Arn = fmt.Sprintf("arn:aws:sts::000000000000:assumed-role/%s/%s", roleName, sessionName)
+		resp.ResponseMetadata.RequestId = requestId
+		writeSuccessResponseXML(w, encodeResponse(resp))
+
+	default:
+		writeStsErrorResponse(w, http.StatusBadRequest, "InvalidAction", fmt.Sprintf("Could not find operation %s.", action))
+	}
+}
+
+var (
+	errAssumeRoleDenied       = fmt.Errorf("not authorized to assume the role")
+	errMalformedSessionPolicy = fmt.Errorf("the session policy is not valid")
+)
+
+// assumeRoleOf checks the role to assume, which is an identity name or "arn:aws:iam::<account>:role/<name>".
+// Only the admins can assume the roles of other identities.
+func (s3a *S3ApiServer) assumeRoleOf(r *http.Request, identity *Identity) (roleName, sessionName, policy string, err error) {
+	roleName = r.Form.Get("RoleArn")
+	if i := strings.LastIndex(roleName, ":role/"); i >= 0 && strings.HasPrefix(roleName, "arn:aws:iam:") {
+		roleName = roleName[i+len(":role/"):]
+	}
+	if roleName == "" {
+		return "", "", "", fmt.Errorf("RoleArn is required")
+	}
+	sessionName = r.Form.Get("RoleSessionName")
+	if len(sessionName) < 2 || len(sessionName) > 64 {
+		return "", "", "", fmt.Errorf("RoleSessionName should have 2 to 64 characters")
+	}
+
+	if _, found := s3a.iam.lookupByName(roleName); !found || roleName == "anonymous" {
+		return "", "", "", fmt.Errorf("role %s is not found", roleName)
+	}
+	if roleName != identity.Name && !identity.isAdmin() {
+		return "", "", "", errAssumeRoleDenied
+	}
+
+	if policy = r.Form.Get("Policy"); policy != "" {
+		if len(policy) > stsMaxPolicySize {
+			return "", "", "", errMalformedSessionPolicy
+		}
+		sessionPolicy, err := policy_engine.ParsePolicyDocument([]byte(policy))
+		if err != nil || sessionPolicy.Validate() != nil {
+			return "", "", "", errMalformedSessionPolicy
+		}
+	}
+	return
+}
+
+func stsDurationOf(durationSeconds string) (time.Duration, error) {
+	if durationSeconds == "" {
+		return stsDefaultDuration, nil
+	}
+	seconds, err := strconv.ParseInt(durationSeconds, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid DurationSeconds %s", durationSeconds)
+	}
+	duration := time.Duration(seconds) * time.Second
+	if duration < stsMinDuration || duration > stsMaxDuration {
+		return 0, fmt.Errorf("DurationSeconds should be between %d and %d", int(stsMinDuration.Seconds()), int(stsMaxDuration.Seconds()))
+	}
+	return duration, nil
+}
+
+func stsCredentialsOf(cred *sessionCredential) StsCredentials {
+	return StsCredentials{
+		AccessKeyId:     cred.AccessKey,
+		SecretAccessKey: cred.SecretKey,
+		SessionToken:    cred.SessionToken,
+		Expiration:      cred.Expiration,
+	}
+}
+
+func writeStsErrorResponse(w http.ResponseWriter, statusCode int, code, message string) {
+	resp := &StsErrorResponse{}
+	resp.Error.Type = "Sender"
+	if statusCode >= http.StatusInternalServerError {
+		resp.Error.Type = "Receiver"
+	}
+	resp.Error.Code = code
+	resp.Error.Message = message
+	resp.RequestId = fmt.Sprintf("%d", time.Now().UnixNano())
+	writeResponse(w, statusCode, encodeResponse(resp), mimeXML)
+}
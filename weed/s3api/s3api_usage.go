@@ -0,0 +1,334 @@
+package s3api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	xhttp "github.com/chrislusf/seaweedfs/weed/s3api/http"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+	"github.com/chrislusf/seaweedfs/weed/stats"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+The usage of the buckets is metered by the identities and their access keys, e.g. for the chargeback:
+
+	requests, bytes in and out    of the authorized requests, or of all requests without the identities
+	storage bytes                 of the live data in the bucket collection, attributed to the bucket owner
+
+The requests with the temporary credentials are metered by their identities, with the access key "temporary".
+The requests not authorized are metered under the identity "_unverified", and the requests to the buckets
+not found under the bucket "_unknown", so the clients can not add any labels to the metrics.
+The storage is refreshed every usageStorageInterval from the "/stats/capacity" of the master,
+so the master needs to white list the s3 gateway, if it has a white list.
+
+The usage is exported as the Prometheus metrics, and reported by "GET /?usage" to the admin identities.
+The report adds up the usage since the s3 gateway started.
+*/
+
+const (
+	usageStorageInterval = 5 * time.Minute
+	temporaryAccessKey   = "temporary"
+	unverifiedIdentity   = "_unverified" // not a valid identity name from the user
+	unknownBucket        = "_unknown"    // not a valid bucket name
+)
+
+type usageKey struct {
+	bucket    string
+	identity  string
+	accessKey string
+}
+
+type BucketTraffic struct {
+	Bucket    string
+	Identity  string
+	AccessKey string
+	Requests  int64
+	BytesIn   int64
+	BytesOut  int64
+}
+
+type BucketStorage struct {
+	Bucket string
+	Owner  string
+	Bytes  uint64
+}
+
+type UsageReport struct {
+	Since            time.Time
+	StorageUpdatedAt time.Time
+	Traffic          []*BucketTraffic
+	Storage          []*BucketStorage
+}
+
+type usageMeter struct {
+	sync.Mutex
+	since            time.Time
+	traffic          map[usageKey]*BucketTraffic
+	storage          []*BucketStorage
+	storageUpdatedAt time.Time
+}
+
+func newUsageMeter() *usageMeter {
+	return &usageMeter{
+		since:   time.Now(),
+		traffic: make(map[usageKey]*BucketTraffic),
+	}
+}
+
+func (m *usageMeter) record(key usageKey, action string, bytesIn, bytesOut int64) {
+	m.Lock()
+	traffic, found := m.traffic[key]
+	if !found {
+		traffic = &BucketTraffic{Bucket: key.bucket, Identity: key.identity, AccessKey: key.accessKey}
+		m.traffic[key] = traffic
+	}
+	traffic.Requests++
+	traffic.BytesIn += bytesIn
+	traffic.BytesOut += bytesOut
+	m.Unlock()
+
+	stats.S3BucketRequestCounter.WithLabelValues(key.bucket, key.identity, key.accessKey, action).Inc()
+	stats.S3BucketTrafficCounter.WithLabelValues(key.bucket, key.identity, key.accessKey, "in").Add(float64(bytesIn))
+	stats.S3BucketTrafficCounter.WithLabelValues(key.bucket, key.identity, key.accessKey, "out").Add(float64(bytesOut))
+}
+
+func (m *usageMeter) setStorage(storage []*BucketStorage) {
+	m.Lock()
+	m.storage = storage
+	m.storageUpdatedAt = time.Now()
+	m.Unlock()
+
+	stats.S3BucketStorageGauge.Reset()
+	for _, s := range storage {
+		stats.S3BucketStorageGauge.WithLabelValues(s.Bucket, s.Owner).Set(float64(s.Bytes))
+	}
+}
+
+// report returns the usage of the bucket and the identity, or all of them if empty.
+// The storage of the buckets is selected by their owners.
+func (m *usageMeter) report(bucket, identity string) *UsageReport {
+	m.Lock()
+	defer m.Unlock()
+
+	report := &UsageReport{
+		Since:            m.since,
+		StorageUpdatedAt: m.storageUpdatedAt,
+		Traffic:          []*BucketTraffic{},
+		Storage:          []*BucketStorage{},
+	}
+	for _, t := range m.traffic {
+		if (bucket == "" || t.Bucket == bucket) && (identity == "" || t.Identity == identity) {
+			traffic := *t
+			report.Traffic = append(report.Traffic, &traffic)
+		}
+	}
+	sort.Slice(report.Traffic, func(i, j int) bool {
+		a, b := report.Traffic[i], report.Traffic[j]
+		if a.Bucket != b.Bucket {
+			return a.Bucket < b.Bucket
+		}
+		if a.Identity != b.Identity {
+			return a.Identity < b.Identity
+		}
+		return a.AccessKey < b.AccessKey
+	})
+	for _, s := range m.storage {
+		if (bucket == "" || s.Bucket == bucket) && (identity == "" || s.Owner == identity) {
+			storage := *s
+			report.Storage = append(report.Storage, &storage)
+		}
+	}
+	return report
+}
+
+// requestUsage is filled in by the authentication, to meter the request by its identity
+type requestUsage struct {
+	isAuthorized bool
+	identity     string
+	accessKey    string
+}
+
+type requestUsageKey struct{}
+
+func withRequestUsage(ctx context.Context, usage *requestUsage) context.Context {
+	return context.WithValue(ctx, requestUsageKey{}, usage)
+}
+
+func requestUsageOf(ctx context.Context) *requestUsage {
+	usage, _ := ctx.Value(requestUsageKey{}).(*requestUsage)
+	return usage
+}
+
+// setAuthorizedUsage records the identity of the authorized request, nil for the anonymous requests
+func setAuthorizedUsage(r *http.Request, identity *Identity) {
+	usage := requestUsageOf(r.Context())
+	if usage == nil {
+		return
+	}
+	usage.isAuthorized = true
+	switch {
+	case identity == nil:
+		usage.identity, usage.accessKey = "anonymous", ""
+	case identity.isSession():
+		usage.identity, usage.accessKey = identity.Name, temporaryAccessKey
+	default:
+		usage.identity, usage.accessKey = identity.Name, accessKeyOf(r)
+	}
+}
+
+// accessKeyOf returns the access key the request is signed with, empty for the anonymous or post policy requests
+func accessKeyOf(r *http.Request) string {
+	switch getRequestAuthType(r) {
+	case authTypeSigned, authTypeStreamingSigned:
+		if sv, errCode := parseSignV4(r.Header.Get("Authorization")); errCode == s3err.ErrNone {
+			return sv.Credential.accessKey
+		}
+	case authTypePresigned:
+		if psv, errCode := parsePreSignV4(r.URL.Query()); errCode == s3err.ErrNone {
+			return psv.Credential.accessKey
+		}
+	case authTypeSignedV2:
+		if accessKey, errCode := validateV2AuthHeader(r.Header.Get("Authorization")); errCode == s3err.ErrNone {
+			return accessKey
+		}
+	case authTypePresignedV2:
+		return r.URL.Query().Get("AWSAccessKeyId")
+	}
+	return ""
+}
+
+// meterUsage records the usage of the request by its verified identity, and by the bucket if it exists
+func (s3a *S3ApiServer) meterUsage(r *http.Request, action string, status int, usage *requestUsage, bytesIn, bytesOut int64) {
+	key := usageKey{identity: usage.identity, accessKey: usage.accessKey}
+	if !usage.isAuthorized {
+		// the access key is not verified
+		key.identity, key.accessKey = "", ""
+		if s3a.iam.isEnabled() {
+			key.identity = unverifiedIdentity
+		}
+	}
+	key.bucket, _ = getBucketAndObject(r)
+	if key.bucket != "" && status >= http.StatusMultipleChoices && !s3a.bucketExists(key.bucket) {
+		key.bucket = unknownBucket
+	}
+	s3a.usage.record(key, action, bytesIn, bytesOut)
+}
+
+// bucketExists looks up the prefetched buckets, or the cached bucket policies.
+// The successful requests are not looked up, their buckets exist.
+func (s3a *S3ApiServer) bucketExists(bucket string) bool {
+	if s3a.buckets != nil {
+		_, found := s3a.buckets.get(bucket)
+		return found
+	}
+	cached, err := s3a.loadBucketPolicy(bucket)
+	return cached != nil && err == nil
+}
+
+type countingReader struct {
+	io.ReadCloser
+	count int64
+}
+
+func (c *countingReader) Read(p []byte) (n int, err error) {
+	n, err = c.ReadCloser.Read(p)
+	c.count += int64(n)
+	return
+}
+
+// GetUsageHandler reports the usage since the s3 gateway started, optionally of one "bucket" or "identity"
+func (s3a *S3ApiServer) GetUsageHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	data, err := json.Marshal(s3a.usage.report(query.Get("bucket"), query.Get("identity")))
+	if err != nil {
+		glog.Errorf("usage report: %v", err)
+		writeErrorResponse(w, s3err.ErrInternalError, r.URL)
+		return
+	}
+	writeResponse(w, http.StatusOK, data, mimeJSON)
+}
+
+func (s3a *S3ApiServer) loopCollectingStorageUsage() {
+	for {
+		if err := s3a.collectStorageUsage(); err != nil {
+			glog.V(1).Infof("collect bucket storage usage: %v", err)
+		}
+		time.Sleep(usageStorageInterval)
+	}
+}
+
+type collectionCapacity struct {
+	Collection  string `json:"collection"`
+	LogicalSize uint64 `json:"logicalSize"`
+}
+
+func (s3a *S3ApiServer) collectStorageUsage() error {
+	var masters []string
+	if err := s3a.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+		resp, err := client.GetFilerConfiguration(context.Background(), &filer_pb.GetFilerConfigurationRequest{})
+		if err != nil {
+			return err
+		}
+		masters = resp.Masters
+		return nil
+	}); err != nil {
+		return fmt.Errorf("get filer configuration: %v", err)
+	}
+
+	var capacity struct {
+		Collections []*collectionCapacity
+	}
+	var err error
+	for _, master := range masters {
+		var data []byte
+		if data, _, err = util.Get(fmt.Sprintf("http://%s/stats/capacity", master)); err == nil {
+			err = json.Unmarshal(data, &capacity)
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("get capacity from masters %v: %v", masters, err)
+	}
+
+	var buckets []*filer_pb.Entry
+	if s3a.buckets != nil {
+		buckets = s3a.buckets.list()
+	} else if buckets, _, err = s3a.list(s3a.option.BucketsPath, "", "", false, math.MaxInt32); err != nil {
+		return fmt.Errorf("list buckets: %v", err)
+	}
+
+	s3a.usage.setStorage(bucketStorageOf(buckets, capacity.Collections))
+	return nil
+}
+
+// bucketStorageOf returns the storage of the buckets, kept in the collections of the same names
+func bucketStorageOf(buckets []*filer_pb.Entry, collections []*collectionCapacity) (storage []*BucketStorage) {
+	capacities := make(map[string]*collectionCapacity)
+	for _, c := range collections {
+		capacities[c.Collection] = c
+	}
+	for _, entry := range buckets {
+		if !entry.IsDirectory {
+			continue
+		}
+		s := &BucketStorage{Bucket: entry.Name}
+		if owner, found := entry.Extended[xhttp.AmzIdentityId]; found {
+			s.Owner = string(owner)
+		}
+		if c, found := capacities[entry.Name]; found {
+			s.Bytes = c.LogicalSize
+		}
+		storage = append(storage, s)
+	}
+	return
+}
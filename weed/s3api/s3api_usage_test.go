@@ -0,0 +1,114 @@
+package s3api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	xhttp "github.com/chrislusf/seaweedfs/weed/s3api/http"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrackUsage(t *testing.T) {
+	s3a := &S3ApiServer{iam: &IdentityAccessManagement{}, usage: newUsageMeter()}
+	h := s3a.track(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		w.Write([]byte("done"))
+	}, "PUT")
+
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest("PUT", "/bucket1/a.txt", strings.NewReader("some content"))
+		h(httptest.NewRecorder(), mux.SetURLVars(r, map[string]string{"bucket": "bucket1", "object": "a.txt"}))
+	}
+
+	report := s3a.usage.report("", "")
+	if assert.Equal(t, 1, len(report.Traffic)) {
+		assert.Equal(t, BucketTraffic{Bucket: "bucket1", Requests: 2, BytesIn: 24, BytesOut: 8}, *report.Traffic[0])
+	}
+
+	// only the authorized requests are metered with the identities
+	s3a.iam = newStsTestIam()
+	r := httptest.NewRequest("PUT", "/bucket1/a.txt", strings.NewReader("some content"))
+	r.Header.Set("Authorization", "AWS any_key:signature")
+	h(httptest.NewRecorder(), mux.SetURLVars(r, map[string]string{"bucket": "bucket1", "object": "a.txt"}))
+	assert.Equal(t, int64(2), s3a.usage.report("bucket1", "").Traffic[0].Requests)
+	report = s3a.usage.report("bucket1", unverifiedIdentity)
+	if assert.Equal(t, 1, len(report.Traffic)) {
+		assert.Equal(t, "", report.Traffic[0].AccessKey)
+	}
+
+	// the buckets not found are metered under a fixed name
+	s3a.buckets = newBucketCache()
+	s3a.buckets.set(&filer_pb.Entry{Name: "bucket1", IsDirectory: true})
+	notFound := s3a.track(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}, "GET")
+	for _, bucket := range []string{"bucket1", "no-such-bucket"} {
+		r = httptest.NewRequest("GET", "/"+bucket+"/a.txt", nil)
+		notFound(httptest.NewRecorder(), mux.SetURLVars(r, map[string]string{"bucket": bucket, "object": "a.txt"}))
+	}
+	assert.Equal(t, 2, len(s3a.usage.report("bucket1", "").Traffic))
+	assert.Equal(t, 0, len(s3a.usage.report("no-such-bucket", "").Traffic))
+	assert.Equal(t, 1, len(s3a.usage.report(unknownBucket, "").Traffic))
+}
+
+func TestUsageReport(t *testing.T) {
+	m := newUsageMeter()
+	m.record(usageKey{"bucket2", "alice", "key_a"}, "GET", 0, 100)
+	m.record(usageKey{"bucket1", "bob", "key_b"}, "PUT", 50, 0)
+	m.record(usageKey{"bucket1", "alice", "key_a"}, "PUT", 10, 0)
+	m.record(usageKey{"bucket1", "alice", "key_a"}, "GET", 0, 30)
+	m.setStorage([]*BucketStorage{{Bucket: "bucket1", Owner: "alice", Bytes: 1024}, {Bucket: "bucket2", Owner: "bob", Bytes: 2048}})
+
+	report := m.report("", "")
+	assert.Equal(t, 3, len(report.Traffic))
+	assert.Equal(t, BucketTraffic{Bucket: "bucket1", Identity: "alice", AccessKey: "key_a", Requests: 2, BytesIn: 10, BytesOut: 30}, *report.Traffic[0])
+	assert.Equal(t, "bob", report.Traffic[1].Identity)
+	assert.Equal(t, "bucket2", report.Traffic[2].Bucket)
+	assert.Equal(t, 2, len(report.Storage))
+
+	report = m.report("", "alice")
+	assert.Equal(t, 2, len(report.Traffic))
+	assert.Equal(t, []*BucketStorage{{Bucket: "bucket1", Owner: "alice", Bytes: 1024}}, report.Storage)
+
+	report = m.report("bucket2", "")
+	assert.Equal(t, 1, len(report.Traffic))
+	assert.Equal(t, int64(100), report.Traffic[0].BytesOut)
+}
+
+func TestAuthorizedUsage(t *testing.T) {
+	usage := &requestUsage{}
+	r := mustNewSignedRequest("GET", "http://127.0.0.1:9000/bucket/a.txt", 0, nil, t)
+	r = r.WithContext(withRequestUsage(r.Context(), usage))
+	setAuthorizedUsage(r, &Identity{Name: "someone"})
+	assert.Equal(t, requestUsage{isAuthorized: true, identity: "someone", accessKey: "access_key_1"}, *usage)
+
+	setAuthorizedUsage(r, &Identity{Name: "someone", sessionExpiration: time.Now().Add(time.Hour)})
+	assert.Equal(t, temporaryAccessKey, usage.accessKey)
+
+	setAuthorizedUsage(r, nil)
+	assert.Equal(t, requestUsage{isAuthorized: true, identity: "anonymous"}, *usage)
+
+	r = mustNewPresignedRequest("GET", "http://127.0.0.1:9000/bucket/a.txt", 0, nil, t)
+	assert.Equal(t, "access_key_1", accessKeyOf(r))
+	r = httptest.NewRequest("GET", "/bucket/a.txt?AWSAccessKeyId=key_v2&Signature=x&Expires=1", nil)
+	assert.Equal(t, "key_v2", accessKeyOf(r))
+	r = httptest.NewRequest("GET", "/bucket/a.txt", nil)
+	r.Header.Set("Authorization", "AWS key_v2:signature")
+	assert.Equal(t, "key_v2", accessKeyOf(r))
+}
+
+func TestBucketStorageOf(t *testing.T) {
+	buckets := []*filer_pb.Entry{
+		{Name: "bucket1", IsDirectory: true, Extended: map[string][]byte{xhttp.AmzIdentityId: []byte("alice")}},
+		{Name: "bucket2", IsDirectory: true},
+		{Name: "a.txt"},
+	}
+	storage := bucketStorageOf(buckets, []*collectionCapacity{{Collection: "bucket1", LogicalSize: 1024}, {Collection: "other", LogicalSize: 1}})
+	assert.Equal(t, []*BucketStorage{{Bucket: "bucket1", Owner: "alice", Bytes: 1024}, {Bucket: "bucket2"}}, storage)
+}
@@ -0,0 +1,59 @@
+package s3api
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+/*
+With the domainName option, e.g. "s3.example.com,s3.internal", the buckets are also addressed in the host name:
+
+	http://bucket.s3.example.com/path/to/object    virtual-hosted style
+	http://s3.example.com/bucket/path/to/object    path style, also for the hosts out of the domains
+
+The port in the host is ignored, so the requests forwarded by a proxy on another port are routed the same way,
+as long as the proxy keeps the Host header the clients signed.
+*/
+
+// parseDomainNames returns the lower cased domains in the comma separated list, the longer domains first
+func parseDomainNames(domainName string) (domains []string) {
+	for _, domain := range strings.Split(domainName, ",") {
+		domain = strings.Trim(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	sort.SliceStable(domains, func(i, j int) bool {
+		return len(domains[i]) > len(domains[j])
+	})
+	return
+}
+
+// bucketOfHost returns the bucket addressed in the host name, empty for the path style requests
+func bucketOfHost(host string, domains []string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	for _, domain := range domains {
+		if strings.HasSuffix(host, "."+domain) {
+			return strings.TrimSuffix(host, "."+domain)
+		}
+	}
+	return ""
+}
+
+// requestLocation returns the url of the object in the same style as the request
+func requestLocation(r *http.Request) string {
+	return requestProtocol(r) + "://" + r.Host + r.URL.EscapedPath()
+}
+
+// requestProtocol returns the protocol the client used, also behind a proxy
+func requestProtocol(r *http.Request) string {
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		return "https"
+	}
+	return "http"
+}
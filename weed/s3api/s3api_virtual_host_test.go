@@ -0,0 +1,83 @@
+package s3api
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucketOfHost(t *testing.T) {
+	domains := parseDomainNames("example.com, S3.example.com,")
+	assert.Equal(t, []string{"s3.example.com", "example.com"}, domains)
+
+	for host, expected := range map[string]string{
+		"bucket.s3.example.com":      "bucket",
+		"bucket.s3.example.com:8333": "bucket",
+		"Bucket.S3.Example.com":      "bucket",
+		"my.bucket.s3.example.com":   "my.bucket",
+		"bucket.example.com":         "bucket",
+		"s3.example.com":             "s3",
+		"example.com":                "",
+		"localhost:8333":             "",
+		"bucket.example.org":         "",
+	} {
+		assert.Equal(t, expected, bucketOfHost(host, domains), host)
+	}
+}
+
+func TestVirtualHostRouting(t *testing.T) {
+	s3a := &S3ApiServer{
+		option: &S3ApiServerOption{DomainName: "s3.example.com", Port: 8333},
+		iam:    &IdentityAccessManagement{},
+	}
+	router := mux.NewRouter().SkipClean(true)
+	s3a.registerRouter(router)
+
+	for _, c := range []struct {
+		target, bucket, object string
+	}{
+		{"http://bucket.s3.example.com/path/to/a.txt", "bucket", "path/to/a.txt"},
+		{"http://bucket.s3.example.com:443/a.txt", "bucket", "a.txt"},
+		{"http://bucket.s3.example.com/", "bucket", ""},
+		{"http://s3.example.com/bucket/a.txt", "bucket", "a.txt"},
+		{"http://localhost:8333/bucket/a.txt", "bucket", "a.txt"},
+	} {
+		var match mux.RouteMatch
+		r := httptest.NewRequest("GET", c.target, nil)
+		if assert.True(t, router.Match(r, &match), c.target) {
+			assert.Equal(t, c.bucket, match.Vars["bucket"], c.target)
+			assert.Equal(t, c.object, match.Vars["object"], c.target)
+		}
+	}
+}
+
+func TestSignedVirtualHostRequest(t *testing.T) {
+	iam := newStsTestIam()
+	iam.domains = parseDomainNames("s3.example.com")
+
+	r := mustNewSignedRequest("GET", "http://bucket.s3.example.com:8333/a.txt", 0, nil, t)
+	identity, errCode := iam.reqSignatureV4Verify(r)
+	assert.Equal(t, s3err.ErrNone, errCode)
+	if assert.NotNil(t, identity) {
+		assert.Equal(t, "someone", identity.Name)
+	}
+
+	r = mustNewPresignedRequest("GET", "http://bucket.s3.example.com/a.txt", 0, nil, t)
+	_, errCode = iam.reqSignatureV4Verify(r)
+	assert.Equal(t, s3err.ErrNone, errCode)
+
+	resource, _ := getResource("/a.txt", "bucket.s3.example.com:8333", iam.domains)
+	assert.Equal(t, "/bucket/a.txt", resource)
+	resource, _ = getResource("/bucket/a.txt", "localhost:8333", iam.domains)
+	assert.Equal(t, "/bucket/a.txt", resource)
+}
+
+func TestRequestLocation(t *testing.T) {
+	r := httptest.NewRequest("POST", "http://bucket.s3.example.com/a%20b.txt?uploadId=1", nil)
+	assert.Equal(t, "http://bucket.s3.example.com/a%20b.txt", requestLocation(r))
+	r.Header.Set("X-Forwarded-Proto", "https")
+	assert.Equal(t, "https://bucket.s3.example.com/a%20b.txt", requestLocation(r))
+}
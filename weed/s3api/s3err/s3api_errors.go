@@ -61,12 +61,17 @@ const (
 	ErrInternalError
 	ErrInvalidCopyDest
 	ErrInvalidCopySource
+	ErrInvalidCopyPartRange
+	ErrInvalidCopyPartRangeSource
+	ErrPreconditionFailed
 	ErrInvalidTag
 	ErrAuthHeaderEmpty
 	ErrSignatureVersionNotSupported
 	ErrMalformedPOSTRequest
 	ErrPOSTFileRequired
 	ErrPostPolicyConditionInvalidFormat
+	ErrPostPolicyConditionFailed
+	ErrPostPolicyExpired
 	ErrEntityTooSmall
 	ErrEntityTooLarge
 	ErrMissingFields
@@ -94,6 +99,31 @@ const (
 	ErrNotImplemented
 
 	ErrExistingObjectIsDirectory
+	ErrInvalidObjectState
+	ErrRestoreAlreadyInProgress
+	ErrQuotaExceeded
+	ErrClusterReadOnly
+	ErrNoSuchLifecycleConfiguration
+	ErrServerSideEncryptionConfigurationNotFound
+	ErrInvalidEncryptionAlgorithm
+	ErrInvalidSseCustomerKey
+	ErrSseCustomerKeyRequired
+	ErrNoSuchBucketPolicy
+	ErrMalformedPolicy
+	ErrInvalidToken
+	ErrExpiredToken
+	ErrNoSuchCORSConfiguration
+	ErrCorsNotEnabled
+	ErrCorsForbidden
+	ErrObjectLockConfigurationNotFound
+	ErrNoSuchObjectLockConfiguration
+	ErrMalformedACL
+	ErrUnsupportedAcl
+	ErrNoSuchWebsiteConfiguration
+	ErrNoSuchConfiguration
+	ErrTooManyConfigurations
+	ErrIncompleteBody
+	ErrBadDigest
 )
 
 // error code to APIError structure, these fields carry respective
@@ -191,6 +221,21 @@ var errorCodeResponse = map[ErrorCode]APIError{
 		Description:    "Copy Source must mention the source bucket and key: sourcebucket/sourcekey.",
 		HTTPStatusCode: http.StatusBadRequest,
 	},
+	ErrInvalidCopyPartRange: {
+		Code:           "InvalidArgument",
+		Description:    "The x-amz-copy-source-range value must be of the form bytes=first-last where first and last are the zero-based offsets of the first and last bytes to copy",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidCopyPartRangeSource: {
+		Code:           "InvalidArgument",
+		Description:    "Range specified is not valid for source object",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrPreconditionFailed: {
+		Code:           "PreconditionFailed",
+		Description:    "At least one of the pre-conditions you specified did not hold",
+		HTTPStatusCode: http.StatusPreconditionFailed,
+	},
 	ErrInvalidTag: {
 		Code:           "InvalidArgument",
 		Description:    "The Tag value you have provided is invalid",
@@ -226,6 +271,16 @@ var errorCodeResponse = map[ErrorCode]APIError{
 		Description:    "Invalid according to Policy: Policy Condition failed",
 		HTTPStatusCode: http.StatusForbidden,
 	},
+	ErrPostPolicyConditionFailed: {
+		Code:           "AccessDenied",
+		Description:    "Invalid according to Policy: Policy Condition failed",
+		HTTPStatusCode: http.StatusForbidden,
+	},
+	ErrPostPolicyExpired: {
+		Code:           "AccessDenied",
+		Description:    "Invalid according to Policy: Policy expired.",
+		HTTPStatusCode: http.StatusForbidden,
+	},
 	ErrEntityTooSmall: {
 		Code:           "EntityTooSmall",
 		Description:    "Your proposed upload is smaller than the minimum allowed object size.",
@@ -351,6 +406,131 @@ var errorCodeResponse = map[ErrorCode]APIError{
 		Description:    "Existing Object is a directory.",
 		HTTPStatusCode: http.StatusConflict,
 	},
+	ErrInvalidObjectState: {
+		Code:           "InvalidObjectState",
+		Description:    "The operation is not valid for the object's storage class.",
+		HTTPStatusCode: http.StatusForbidden,
+	},
+	ErrRestoreAlreadyInProgress: {
+		Code:           "RestoreAlreadyInProgress",
+		Description:    "Object restore is already in progress.",
+		HTTPStatusCode: http.StatusConflict,
+	},
+	ErrQuotaExceeded: {
+		Code:           "QuotaExceeded",
+		Description:    "The directory quota of the bucket or the path is exceeded.",
+		HTTPStatusCode: http.StatusInsufficientStorage,
+	},
+	ErrClusterReadOnly: {
+		Code:           "ServiceUnavailable",
+		Description:    "The cluster is read only.",
+		HTTPStatusCode: http.StatusServiceUnavailable,
+	},
+	ErrNoSuchLifecycleConfiguration: {
+		Code:           "NoSuchLifecycleConfiguration",
+		Description:    "The lifecycle configuration does not exist.",
+		HTTPStatusCode: http.StatusNotFound,
+	},
+	ErrServerSideEncryptionConfigurationNotFound: {
+		Code:           "ServerSideEncryptionConfigurationNotFoundError",
+		Description:    "The server side encryption configuration was not found.",
+		HTTPStatusCode: http.StatusNotFound,
+	},
+	ErrInvalidEncryptionAlgorithm: {
+		Code:           "InvalidEncryptionAlgorithmError",
+		Description:    "The encryption request you specified is not valid. The valid value is AES256 or aws:kms.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidSseCustomerKey: {
+		Code:           "InvalidArgument",
+		Description:    "The secret key was invalid for the specified algorithm, or the calculated MD5 hash of the key did not match the hash that was provided.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrSseCustomerKeyRequired: {
+		Code:           "InvalidRequest",
+		Description:    "The object was stored using a form of Server Side Encryption. The correct parameters must be provided to retrieve the object.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrNoSuchBucketPolicy: {
+		Code:           "NoSuchBucketPolicy",
+		Description:    "The bucket policy does not exist.",
+		HTTPStatusCode: http.StatusNotFound,
+	},
+	ErrMalformedPolicy: {
+		Code:           "MalformedPolicy",
+		Description:    "The bucket policy is not valid.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidToken: {
+		Code:           "InvalidToken",
+		Description:    "The provided token is malformed or otherwise invalid.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrExpiredToken: {
+		Code:           "ExpiredToken",
+		Description:    "The provided token has expired.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrNoSuchCORSConfiguration: {
+		Code:           "NoSuchCORSConfiguration",
+		Description:    "The CORS configuration does not exist.",
+		HTTPStatusCode: http.StatusNotFound,
+	},
+	ErrCorsNotEnabled: {
+		Code:           "AccessForbidden",
+		Description:    "CORSResponse: CORS is not enabled for this bucket.",
+		HTTPStatusCode: http.StatusForbidden,
+	},
+	ErrCorsForbidden: {
+		Code:           "AccessForbidden",
+		Description:    "CORSResponse: This CORS request is not allowed.",
+		HTTPStatusCode: http.StatusForbidden,
+	},
+	ErrObjectLockConfigurationNotFound: {
+		Code:           "ObjectLockConfigurationNotFoundError",
+		Description:    "Object Lock configuration does not exist for this bucket",
+		HTTPStatusCode: http.StatusNotFound,
+	},
+	ErrNoSuchObjectLockConfiguration: {
+		Code:           "NoSuchObjectLockConfiguration",
+		Description:    "The specified object does not have a ObjectLock configuration",
+		HTTPStatusCode: http.StatusNotFound,
+	},
+	ErrMalformedACL: {
+		Code:           "MalformedACLError",
+		Description:    "The XML you provided was not well-formed or did not validate against our published schema.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrUnsupportedAcl: {
+		Code:           "NotImplemented",
+		Description:    "Only the grants to the owner and to all users of the canned ACLs are supported.",
+		HTTPStatusCode: http.StatusNotImplemented,
+	},
+	ErrNoSuchWebsiteConfiguration: {
+		Code:           "NoSuchWebsiteConfiguration",
+		Description:    "The specified bucket does not have a website configuration",
+		HTTPStatusCode: http.StatusNotFound,
+	},
+	ErrNoSuchConfiguration: {
+		Code:           "NoSuchConfiguration",
+		Description:    "The specified configuration does not exist.",
+		HTTPStatusCode: http.StatusNotFound,
+	},
+	ErrTooManyConfigurations: {
+		Code:           "TooManyConfigurations",
+		Description:    "You are attempting to create a new configuration but have already reached the 1,000-configuration limit.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrIncompleteBody: {
+		Code:           "IncompleteBody",
+		Description:    "You did not provide the number of bytes specified by the Content-Length HTTP header.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrBadDigest: {
+		Code:           "BadDigest",
+		Description:    "The Content-MD5 or checksum value that you specified did not match what the server received.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
 }
 
 // GetAPIError provides API Error for input API error code.
@@ -0,0 +1,666 @@
+package s3select
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// the values are nil, bool, int64, float64, string, or the json *jsonObject and []interface{}
+
+type record interface {
+	// get returns the top level field, the unquoted names are matched case insensitively
+	get(name string, quoted bool) (interface{}, bool)
+	// columns returns the names and values of all fields, for SELECT *
+	columns() ([]string, []interface{})
+}
+
+type expr interface {
+	eval(rec record) (interface{}, error)
+}
+
+type pathElem struct {
+	name   string
+	quoted bool
+	index  int
+}
+
+type literalExpr struct {
+	value interface{}
+}
+
+func (e *literalExpr) eval(rec record) (interface{}, error) {
+	return e.value, nil
+}
+
+type columnRef struct {
+	path []pathElem
+}
+
+func (e *columnRef) eval(rec record) (interface{}, error) {
+	if len(e.path) == 0 || e.path[0].index >= 0 {
+		return nil, nil
+	}
+	v, found := rec.get(e.path[0].name, e.path[0].quoted)
+	if !found {
+		return nil, nil
+	}
+	return navigate(v, e.path[1:]), nil
+}
+
+// name is the output name of the projected column
+func (e *columnRef) name() string {
+	for i := len(e.path) - 1; i >= 0; i-- {
+		if e.path[i].index < 0 {
+			return e.path[i].name
+		}
+	}
+	return ""
+}
+
+func navigate(v interface{}, path []pathElem) interface{} {
+	for _, elem := range path {
+		switch x := v.(type) {
+		case *jsonObject:
+			if elem.index >= 0 {
+				return nil
+			}
+			var found bool
+			if v, found = x.get(elem.name, elem.quoted); !found {
+				return nil
+			}
+		case []interface{}:
+			if elem.index < 0 || elem.index >= len(x) {
+				return nil
+			}
+			v = x[elem.index]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+type logicalExpr struct {
+	op          string
+	left, right expr
+}
+
+func (e *logicalExpr) eval(rec record) (interface{}, error) {
+	l, err := evalBool(e.left, rec)
+	if err != nil {
+		return nil, err
+	}
+	// short circuit
+	if l != nil && *l == (e.op == "OR") {
+		return *l, nil
+	}
+	r, err := evalBool(e.right, rec)
+	if err != nil {
+		return nil, err
+	}
+	if r != nil && *r == (e.op == "OR") {
+		return *r, nil
+	}
+	if l == nil || r == nil {
+		return nil, nil
+	}
+	return *r, nil
+}
+
+type notExpr struct {
+	x expr
+}
+
+func (e *notExpr) eval(rec record) (interface{}, error) {
+	b, err := evalBool(e.x, rec)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	return !*b, nil
+}
+
+func evalBool(e expr, rec record) (*bool, error) {
+	v, err := e.eval(rec)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	switch x := v.(type) {
+	case bool:
+		return &x, nil
+	case string:
+		if b, err := strconv.ParseBool(x); err == nil {
+			return &b, nil
+		}
+	}
+	return nil, errorf("InvalidDataType", "%v is not a boolean", v)
+}
+
+type compareExpr struct {
+	op          string
+	left, right expr
+}
+
+func (e *compareExpr) eval(rec record) (interface{}, error) {
+	l, err := e.left.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	r, err := e.right.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	c, ok := compare(l, r)
+	if !ok {
+		return nil, nil
+	}
+	switch e.op {
+	case "=":
+		return c == 0, nil
+	case "!=", "<>":
+		return c != 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+// compare compares the numbers, the strings, or the booleans,
+// the strings are compared as numbers with the numbers if possible.
+func compare(l, r interface{}) (int, bool) {
+	if l == nil || r == nil {
+		return 0, false
+	}
+	if li, ok := l.(int64); ok {
+		if ri, ok := r.(int64); ok {
+			return compareInt(li, ri), true
+		}
+	}
+	lf, lok := toFloat(l, isNumber(r))
+	rf, rok := toFloat(r, isNumber(l))
+	if lok && rok {
+		switch {
+		case lf < rf:
+			return -1, true
+		case lf > rf:
+			return 1, true
+		}
+		return 0, true
+	}
+	if lb, ok := l.(bool); ok {
+		if rb, ok := r.(bool); ok {
+			return compareInt(boolInt(lb), boolInt(rb)), true
+		}
+	}
+	return strings.Compare(toString(l), toString(r)), true
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func isNumber(v interface{}) bool {
+	switch v.(type) {
+	case int64, float64:
+		return true
+	}
+	return false
+}
+
+// toFloat converts the numbers, and the numeric strings if parseString
+func toFloat(v interface{}, parseString bool) (float64, bool) {
+	switch x := v.(type) {
+	case int64:
+		return float64(x), true
+	case float64:
+		return x, true
+	case string:
+		if parseString {
+			f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+			return f, err == nil
+		}
+	}
+	return 0, false
+}
+
+// toNumber converts the value to int64 or float64
+func toNumber(v interface{}) (interface{}, bool) {
+	switch x := v.(type) {
+	case int64, float64:
+		return x, true
+	case string:
+		s := strings.TrimSpace(x)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, true
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+func toString(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case bool:
+		return strconv.FormatBool(x)
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	}
+	data, _ := marshalJson(v)
+	return string(data)
+}
+
+type arithmeticExpr struct {
+	op          string
+	left, right expr
+}
+
+func (e *arithmeticExpr) eval(rec record) (interface{}, error) {
+	l, err := e.left.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	r, err := e.right.eval(rec)
+	if err != nil || l == nil || r == nil {
+		return nil, err
+	}
+	if e.op == "||" {
+		return toString(l) + toString(r), nil
+	}
+	ln, lok := toNumber(l)
+	rn, rok := toNumber(r)
+	if !lok || !rok {
+		return nil, errorf("InvalidDataType", "the operands of %s should be numbers", e.op)
+	}
+	if li, ok := ln.(int64); ok {
+		if ri, ok := rn.(int64); ok {
+			switch e.op {
+			case "+":
+				return li + ri, nil
+			case "-":
+				return li - ri, nil
+			case "*":
+				return li * ri, nil
+			}
+			if ri == 0 {
+				return nil, errorf("DivisionByZero", "division by zero")
+			}
+			if e.op == "%" {
+				return li % ri, nil
+			}
+			return li / ri, nil
+		}
+	}
+	lf, _ := toFloat(ln, false)
+	rf, _ := toFloat(rn, false)
+	switch e.op {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	}
+	if rf == 0 {
+		return nil, errorf("DivisionByZero", "division by zero")
+	}
+	if e.op == "%" {
+		return math.Mod(lf, rf), nil
+	}
+	return lf / rf, nil
+}
+
+type isNullExpr struct {
+	x   expr
+	not bool
+}
+
+func (e *isNullExpr) eval(rec record) (interface{}, error) {
+	v, err := e.x.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	return (v == nil) != e.not, nil
+}
+
+type likeExpr struct {
+	x, pattern, escape expr
+	not                bool
+}
+
+func (e *likeExpr) eval(rec record) (interface{}, error) {
+	v, err := e.x.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	pattern, err := e.pattern.eval(rec)
+	if err != nil || v == nil || pattern == nil {
+		return nil, err
+	}
+	escape := rune(0)
+	if e.escape != nil {
+		esc, err := e.escape.eval(rec)
+		if err != nil {
+			return nil, err
+		}
+		if s := toString(esc); utf8.RuneCountInString(s) != 1 {
+			return nil, errorf("InvalidArgument", "the escape of LIKE should be one character")
+		} else {
+			escape, _ = utf8.DecodeRuneInString(s)
+		}
+	}
+	return matchLike([]rune(toString(v)), []rune(toString(pattern)), escape) != e.not, nil
+}
+
+// matchLike matches the sql pattern, with "%" for any characters and "_" for one character
+func matchLike(s, pattern []rune, escape rune) bool {
+	for len(pattern) > 0 {
+		c := pattern[0]
+		switch {
+		case escape != 0 && c == escape && len(pattern) > 1:
+			if len(s) == 0 || s[0] != pattern[1] {
+				return false
+			}
+			s, pattern = s[1:], pattern[2:]
+		case c == '%':
+			for len(pattern) > 0 && pattern[0] == '%' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if matchLike(s[i:], pattern, escape) {
+					return true
+				}
+			}
+			return false
+		case c == '_':
+			if len(s) == 0 {
+				return false
+			}
+			s, pattern = s[1:], pattern[1:]
+		default:
+			if len(s) == 0 || s[0] != c {
+				return false
+			}
+			s, pattern = s[1:], pattern[1:]
+		}
+	}
+	return len(s) == 0
+}
+
+type betweenExpr struct {
+	x, low, high expr
+	not          bool
+}
+
+func (e *betweenExpr) eval(rec record) (interface{}, error) {
+	v, err := e.x.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	low, err := e.low.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	high, err := e.high.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	c1, ok1 := compare(v, low)
+	c2, ok2 := compare(v, high)
+	if !ok1 || !ok2 {
+		return nil, nil
+	}
+	return (c1 >= 0 && c2 <= 0) != e.not, nil
+}
+
+type inExpr struct {
+	x    expr
+	list []expr
+	not  bool
+}
+
+func (e *inExpr) eval(rec record) (interface{}, error) {
+	v, err := e.x.eval(rec)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	for _, item := range e.list {
+		x, err := item.eval(rec)
+		if err != nil {
+			return nil, err
+		}
+		if c, ok := compare(v, x); ok && c == 0 {
+			return !e.not, nil
+		}
+	}
+	return e.not, nil
+}
+
+type castExpr struct {
+	x   expr
+	typ string
+}
+
+func (e *castExpr) eval(rec record) (interface{}, error) {
+	v, err := e.x.eval(rec)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	switch e.typ {
+	case "INT", "INTEGER":
+		n, ok := toNumber(v)
+		if !ok {
+			break
+		}
+		if f, isFloat := n.(float64); isFloat {
+			return int64(f), nil
+		}
+		return n, nil
+	case "FLOAT", "DECIMAL", "NUMERIC":
+		n, ok := toNumber(v)
+		if !ok {
+			break
+		}
+		f, _ := toFloat(n, false)
+		return f, nil
+	case "STRING", "VARCHAR", "CHAR":
+		return toString(v), nil
+	case "BOOL", "BOOLEAN":
+		switch x := v.(type) {
+		case bool:
+			return x, nil
+		case int64:
+			return x != 0, nil
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(x)); err == nil {
+				return b, nil
+			}
+		}
+	}
+	return nil, errorf("CastFailed", "can not cast %q to %s", toString(v), e.typ)
+}
+
+type functionExpr struct {
+	name string
+	args []expr
+}
+
+func (e *functionExpr) eval(rec record) (interface{}, error) {
+	args := make([]interface{}, len(e.args))
+	for i, arg := range e.args {
+		v, err := arg.eval(rec)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+
+	switch e.name {
+	case "COALESCE":
+		for _, v := range args {
+			if v != nil {
+				return v, nil
+			}
+		}
+		return nil, nil
+	case "NULLIF":
+		if c, ok := compare(args[0], args[1]); ok && c == 0 {
+			return nil, nil
+		}
+		return args[0], nil
+	}
+
+	if args[0] == nil {
+		return nil, nil
+	}
+	if _, isString := args[0].(string); !isString && e.name != "SUBSTRING" {
+		return nil, errorf("InvalidDataType", "the argument of %s should be a string", e.name)
+	}
+	s := toString(args[0])
+
+	switch e.name {
+	case "LOWER":
+		return strings.ToLower(s), nil
+	case "UPPER":
+		return strings.ToUpper(s), nil
+	case "CHAR_LENGTH", "CHARACTER_LENGTH":
+		return int64(utf8.RuneCountInString(s)), nil
+	case "TRIM":
+		// the arguments are the trim type, the string, and the characters
+		if args[1] == nil || args[2] == nil {
+			return nil, nil
+		}
+		str, chars := toString(args[1]), toString(args[2])
+		switch s {
+		case "LEADING":
+			return strings.TrimLeft(str, chars), nil
+		case "TRAILING":
+			return strings.TrimRight(str, chars), nil
+		}
+		return strings.Trim(str, chars), nil
+	case "SUBSTRING":
+		runes := []rune(s)
+		start, ok := toNumber(args[1])
+		if !ok {
+			return nil, errorf("InvalidDataType", "the start of SUBSTRING should be a number")
+		}
+		from := int64(toFloatValue(start)) - 1
+		to := int64(len(runes))
+		if len(args) > 2 {
+			length, ok := toNumber(args[2])
+			if !ok || toFloatValue(length) < 0 {
+				return nil, errorf("InvalidDataType", "the length of SUBSTRING should be a positive number")
+			}
+			to = from + int64(toFloatValue(length))
+		}
+		if from < 0 {
+			from = 0
+		}
+		if to > int64(len(runes)) {
+			to = int64(len(runes))
+		}
+		if from >= to {
+			return "", nil
+		}
+		return string(runes[from:to]), nil
+	}
+	return nil, errorf("UnsupportedFunction", "function %s is not supported", e.name)
+}
+
+func toFloatValue(v interface{}) float64 {
+	f, _ := toFloat(v, false)
+	return f
+}
+
+// aggregateExpr accumulates the values of all records, and evaluates to the result
+type aggregateExpr struct {
+	fn    string
+	arg   expr
+	count int64
+	sum   interface{}
+	value interface{}
+}
+
+func (e *aggregateExpr) accumulate(rec record) error {
+	if e.arg == nil {
+		e.count++
+		return nil
+	}
+	v, err := e.arg.eval(rec)
+	if err != nil || v == nil {
+		return err
+	}
+	e.count++
+	switch e.fn {
+	case "SUM", "AVG":
+		n, ok := toNumber(v)
+		if !ok {
+			return errorf("InvalidDataType", "the values of %s should be numbers", e.fn)
+		}
+		if e.sum == nil {
+			e.sum = n
+		} else if si, ok := e.sum.(int64); ok {
+			if ni, ok := n.(int64); ok {
+				e.sum = si + ni
+			} else {
+				e.sum = float64(si) + toFloatValue(n)
+			}
+		} else {
+			e.sum = toFloatValue(e.sum) + toFloatValue(n)
+		}
+	case "MIN", "MAX":
+		if n, ok := toNumber(v); ok {
+			v = n
+		}
+		if e.value == nil {
+			e.value = v
+		} else if c, ok := compare(v, e.value); ok && (c < 0) == (e.fn == "MIN") && c != 0 {
+			e.value = v
+		}
+	}
+	return nil
+}
+
+func (e *aggregateExpr) eval(rec record) (interface{}, error) {
+	switch e.fn {
+	case "COUNT":
+		return e.count, nil
+	case "SUM":
+		return e.sum, nil
+	case "AVG":
+		if e.count == 0 {
+			return nil, nil
+		}
+		return toFloatValue(e.sum) / float64(e.count), nil
+	}
+	return e.value, nil
+}
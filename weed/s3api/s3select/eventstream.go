@@ -0,0 +1,100 @@
+package s3select
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// https://docs.aws.amazon.com/AmazonS3/latest/API/RESTSelectObjectAppendix.html
+//
+// A message is the prelude of the total length, the headers length and the prelude crc,
+// followed by the headers, the payload, and the message crc, all integers in big endian.
+
+const headerValueTypeString = 7
+
+type header struct {
+	name, value string
+}
+
+func writeMessage(w io.Writer, headers []header, payload []byte) (int, error) {
+	var headerBuf bytes.Buffer
+	for _, h := range headers {
+		headerBuf.WriteByte(byte(len(h.name)))
+		headerBuf.WriteString(h.name)
+		headerBuf.WriteByte(headerValueTypeString)
+		binary.Write(&headerBuf, binary.BigEndian, uint16(len(h.value)))
+		headerBuf.WriteString(h.value)
+	}
+
+	totalLength := 4 + 4 + 4 + headerBuf.Len() + len(payload) + 4
+	var buf bytes.Buffer
+	buf.Grow(totalLength)
+	binary.Write(&buf, binary.BigEndian, uint32(totalLength))
+	binary.Write(&buf, binary.BigEndian, uint32(headerBuf.Len()))
+	binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(buf.Bytes()))
+	buf.Write(headerBuf.Bytes())
+	buf.Write(payload)
+	binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(buf.Bytes()))
+
+	return w.Write(buf.Bytes())
+}
+
+func writeRecordsMessage(w io.Writer, payload []byte) (int, error) {
+	return writeMessage(w, []header{
+		{":event-type", "Records"},
+		{":content-type", "application/octet-stream"},
+		{":message-type", "event"},
+	}, payload)
+}
+
+func writeContinuationMessage(w io.Writer) (int, error) {
+	return writeMessage(w, []header{
+		{":event-type", "Cont"},
+		{":message-type", "event"},
+	}, nil)
+}
+
+func writeProgressMessage(w io.Writer, s *stats) (int, error) {
+	return writeMessage(w, []header{
+		{":event-type", "Progress"},
+		{":content-type", "text/xml"},
+		{":message-type", "event"},
+	}, s.xml("Progress"))
+}
+
+func writeStatsMessage(w io.Writer, s *stats) (int, error) {
+	return writeMessage(w, []header{
+		{":event-type", "Stats"},
+		{":content-type", "text/xml"},
+		{":message-type", "event"},
+	}, s.xml("Stats"))
+}
+
+func writeEndMessage(w io.Writer) (int, error) {
+	return writeMessage(w, []header{
+		{":event-type", "End"},
+		{":message-type", "event"},
+	}, nil)
+}
+
+func writeErrorMessage(w io.Writer, code, message string) (int, error) {
+	return writeMessage(w, []header{
+		{":error-code", code},
+		{":error-message", message},
+		{":message-type", "error"},
+	}, nil)
+}
+
+type stats struct {
+	bytesScanned   int64
+	bytesProcessed int64
+	bytesReturned  int64
+}
+
+func (s *stats) xml(name string) []byte {
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?><%s><BytesScanned>%d</BytesScanned><BytesProcessed>%d</BytesProcessed><BytesReturned>%d</BytesReturned></%s>`,
+		name, s.bytesScanned, s.bytesProcessed, s.bytesReturned, name))
+}
@@ -0,0 +1,336 @@
+package s3select
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type recordReader interface {
+	// read returns the next record, or io.EOF
+	read() (record, error)
+}
+
+func newRecordReader(in *InputSerialization, query *Query, input io.Reader) (recordReader, error) {
+	if in.CSV != nil {
+		return newCsvReader(in.CSV, input)
+	}
+	return newJsonReader(query.fromPath, input), nil
+}
+
+func decompress(compressionType string, input io.Reader) (io.Reader, error) {
+	switch compressionType {
+	case CompressionGzip:
+		gzipReader, err := gzip.NewReader(input)
+		if err != nil {
+			return nil, errorf("InvalidCompressionFormat", "gzip: %v", err)
+		}
+		return gzipReader, nil
+	case CompressionBzip2:
+		return bzip2.NewReader(input), nil
+	}
+	return input, nil
+}
+
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (n int, err error) {
+	n, err = r.reader.Read(p)
+	r.count += int64(n)
+	return
+}
+
+type csvReader struct {
+	reader      *csv.Reader
+	header      []string
+	headerIndex map[string]int
+}
+
+type csvRecord struct {
+	reader *csvReader
+	fields []string
+}
+
+func newCsvReader(in *CSVInput, input io.Reader) (*csvReader, error) {
+	if in.RecordDelimiter != "\n" && in.RecordDelimiter != "\r\n" {
+		input = &delimiterReader{reader: input, delimiter: in.RecordDelimiter[0]}
+	}
+	reader := csv.NewReader(input)
+	reader.Comma = rune(in.FieldDelimiter[0])
+	if in.Comments != "" {
+		reader.Comment = rune(in.Comments[0])
+	}
+	reader.FieldsPerRecord = -1
+	r := &csvReader{reader: reader}
+
+	if in.FileHeaderInfo == FileHeaderNone {
+		return r, nil
+	}
+	header, err := reader.Read()
+	if err == io.EOF {
+		return r, nil
+	}
+	if err != nil {
+		return nil, errorf("CSVParsingError", "%v", err)
+	}
+	if in.FileHeaderInfo == FileHeaderUse {
+		r.header = header
+		r.headerIndex = make(map[string]int)
+		for i, name := range header {
+			if _, found := r.headerIndex[name]; !found {
+				r.headerIndex[name] = i
+			}
+		}
+	}
+	return r, nil
+}
+
+func (r *csvReader) read() (record, error) {
+	fields, err := r.reader.Read()
+	if err == io.EOF {
+		return nil, err
+	}
+	if err != nil {
+		return nil, errorf("CSVParsingError", "%v", err)
+	}
+	return &csvRecord{reader: r, fields: fields}, nil
+}
+
+func (rec *csvRecord) get(name string, quoted bool) (interface{}, bool) {
+	index, found := rec.reader.headerIndex[name]
+	if !found && !quoted {
+		for i, h := range rec.reader.header {
+			if strings.EqualFold(h, name) {
+				index, found = i, true
+				break
+			}
+		}
+	}
+	if !found && strings.HasPrefix(name, "_") {
+		if position, err := strconv.Atoi(name[1:]); err == nil && position > 0 {
+			index, found = position-1, true
+		}
+	}
+	if !found || index >= len(rec.fields) {
+		return nil, false
+	}
+	return rec.fields[index], true
+}
+
+func (rec *csvRecord) columns() ([]string, []interface{}) {
+	names := make([]string, len(rec.fields))
+	values := make([]interface{}, len(rec.fields))
+	for i, field := range rec.fields {
+		if i < len(rec.reader.header) {
+			names[i] = rec.reader.header[i]
+		} else {
+			names[i] = fmt.Sprintf("_%d", i+1)
+		}
+		values[i] = field
+	}
+	return names, values
+}
+
+// delimiterReader replaces the custom record delimiter with the new line
+type delimiterReader struct {
+	reader    io.Reader
+	delimiter byte
+}
+
+func (r *delimiterReader) Read(p []byte) (n int, err error) {
+	n, err = r.reader.Read(p)
+	for i := 0; i < n; i++ {
+		if p[i] == r.delimiter {
+			p[i] = '\n'
+		}
+	}
+	return
+}
+
+type jsonReader struct {
+	decoder  *json.Decoder
+	fromPath []pathElem
+	pending  []interface{}
+}
+
+type jsonRecord struct {
+	value interface{}
+}
+
+// the json objects keep the order of the keys
+type jsonObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newJsonReader(fromPath []pathElem, input io.Reader) *jsonReader {
+	decoder := json.NewDecoder(bufio.NewReader(input))
+	decoder.UseNumber()
+	return &jsonReader{decoder: decoder, fromPath: fromPath}
+}
+
+// read returns the documents, or the elements of the documents if they are arrays
+func (r *jsonReader) read() (record, error) {
+	for len(r.pending) == 0 {
+		v, err := decodeJsonValue(r.decoder)
+		if err == io.EOF {
+			return nil, err
+		}
+		if err != nil {
+			return nil, errorf("JSONParsingError", "%v", err)
+		}
+		v = navigate(v, r.fromPath)
+		if array, isArray := v.([]interface{}); isArray {
+			r.pending = array
+		} else if v != nil {
+			r.pending = []interface{}{v}
+		}
+	}
+	v := r.pending[0]
+	r.pending = r.pending[1:]
+	return &jsonRecord{value: v}, nil
+}
+
+func (rec *jsonRecord) get(name string, quoted bool) (interface{}, bool) {
+	if obj, ok := rec.value.(*jsonObject); ok {
+		return obj.get(name, quoted)
+	}
+	if name == "_1" {
+		return rec.value, true
+	}
+	return nil, false
+}
+
+func (rec *jsonRecord) columns() ([]string, []interface{}) {
+	obj, ok := rec.value.(*jsonObject)
+	if !ok {
+		return []string{"_1"}, []interface{}{rec.value}
+	}
+	values := make([]interface{}, len(obj.keys))
+	for i, k := range obj.keys {
+		values[i] = obj.values[k]
+	}
+	return obj.keys, values
+}
+
+func (obj *jsonObject) get(name string, quoted bool) (interface{}, bool) {
+	if v, found := obj.values[name]; found {
+		return v, true
+	}
+	if !quoted {
+		for _, k := range obj.keys {
+			if strings.EqualFold(k, name) {
+				return obj.values[k], true
+			}
+		}
+	}
+	return nil, false
+}
+
+func (obj *jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range obj.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(k)
+		buf.Write(key)
+		buf.WriteByte(':')
+		value, err := marshalJson(obj.values[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func marshalJson(v interface{}) ([]byte, error) {
+	if f, ok := v.(float64); ok {
+		// the same format as the csv output
+		return []byte(strconv.FormatFloat(f, 'f', -1, 64)), nil
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// decodeJsonValue decodes the value with the ordered objects, the int64 and float64 numbers
+func decodeJsonValue(decoder *json.Decoder) (interface{}, error) {
+	t, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch x := t.(type) {
+	case json.Delim:
+		switch x {
+		case '{':
+			obj := &jsonObject{values: make(map[string]interface{})}
+			for decoder.More() {
+				t, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				key, ok := t.(string)
+				if !ok {
+					return nil, fmt.Errorf("unexpected %v", t)
+				}
+				v, err := decodeJsonValue(decoder)
+				if err != nil {
+					return nil, unexpectedEOF(err)
+				}
+				if _, found := obj.values[key]; !found {
+					obj.keys = append(obj.keys, key)
+				}
+				obj.values[key] = v
+			}
+			if _, err = decoder.Token(); err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			return obj, nil
+		case '[':
+			array := []interface{}{}
+			for decoder.More() {
+				v, err := decodeJsonValue(decoder)
+				if err != nil {
+					return nil, unexpectedEOF(err)
+				}
+				array = append(array, v)
+			}
+			if _, err = decoder.Token(); err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			return array, nil
+		}
+		return nil, fmt.Errorf("unexpected %v", x)
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			return i, nil
+		}
+		return x.Float64()
+	}
+	return t, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
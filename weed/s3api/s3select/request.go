@@ -0,0 +1,217 @@
+package s3select
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+/*
+SelectObjectContent runs a sql expression over a csv or json object, optionally gzip or bzip2 compressed,
+and streams the results back as the AWS event stream messages.
+
+The supported sql subset is
+
+	SELECT * | expr [AS alias], ... | aggregate(expr), ...
+	FROM S3Object[*][.path] [AS] [alias]
+	[WHERE condition]
+	[LIMIT n]
+
+with the comparison, arithmetic, LIKE, BETWEEN, IN, IS NULL operators, the CAST, COALESCE, NULLIF,
+LOWER, UPPER, TRIM, SUBSTRING, CHAR_LENGTH functions, and the COUNT, SUM, AVG, MIN, MAX aggregates.
+The csv columns are referenced by the positions _1, _2, ..., or by the header names with FileHeaderInfo USE.
+The json fields are referenced by the paths, e.g. s.a.b[0].
+*/
+
+const (
+	ExpressionTypeSql = "SQL"
+
+	CompressionNone  = "NONE"
+	CompressionGzip  = "GZIP"
+	CompressionBzip2 = "BZIP2"
+
+	FileHeaderNone   = "NONE"
+	FileHeaderUse    = "USE"
+	FileHeaderIgnore = "IGNORE"
+
+	JsonTypeDocument = "DOCUMENT"
+	JsonTypeLines    = "LINES"
+
+	QuoteFieldsAlways   = "ALWAYS"
+	QuoteFieldsAsNeeded = "ASNEEDED"
+
+	maxExpressionLength = 256 * 1024
+)
+
+type Request struct {
+	XMLName             xml.Name            `xml:"SelectObjectContentRequest"`
+	Expression          string              `xml:"Expression"`
+	ExpressionType      string              `xml:"ExpressionType"`
+	InputSerialization  InputSerialization  `xml:"InputSerialization"`
+	OutputSerialization OutputSerialization `xml:"OutputSerialization"`
+	RequestProgress     struct {
+		Enabled bool `xml:"Enabled"`
+	} `xml:"RequestProgress"`
+}
+
+type InputSerialization struct {
+	CompressionType string     `xml:"CompressionType"`
+	CSV             *CSVInput  `xml:"CSV"`
+	JSON            *JSONInput `xml:"JSON"`
+}
+
+type CSVInput struct {
+	FileHeaderInfo             string `xml:"FileHeaderInfo"`
+	RecordDelimiter            string `xml:"RecordDelimiter"`
+	FieldDelimiter             string `xml:"FieldDelimiter"`
+	QuoteCharacter             string `xml:"QuoteCharacter"`
+	QuoteEscapeCharacter       string `xml:"QuoteEscapeCharacter"`
+	Comments                   string `xml:"Comments"`
+	AllowQuotedRecordDelimiter bool   `xml:"AllowQuotedRecordDelimiter"`
+}
+
+type JSONInput struct {
+	Type string `xml:"Type"`
+}
+
+type OutputSerialization struct {
+	CSV  *CSVOutput  `xml:"CSV"`
+	JSON *JSONOutput `xml:"JSON"`
+}
+
+type CSVOutput struct {
+	QuoteFields          string `xml:"QuoteFields"`
+	RecordDelimiter      string `xml:"RecordDelimiter"`
+	FieldDelimiter       string `xml:"FieldDelimiter"`
+	QuoteCharacter       string `xml:"QuoteCharacter"`
+	QuoteEscapeCharacter string `xml:"QuoteEscapeCharacter"`
+}
+
+type JSONOutput struct {
+	RecordDelimiter string `xml:"RecordDelimiter"`
+}
+
+// Error is an invalid request or a failed evaluation, with the s3 error code
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func errorf(code, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// ParseRequest parses and validates the SelectObjectContentRequest, with the defaults filled in.
+func ParseRequest(data []byte) (*Request, *Query, error) {
+	req := &Request{}
+	if err := xml.Unmarshal(data, req); err != nil {
+		return nil, nil, errorf("MalformedXML", "%v", err)
+	}
+	if err := req.validate(); err != nil {
+		return nil, nil, err
+	}
+	query, err := ParseQuery(req.Expression)
+	if err != nil {
+		return nil, nil, err
+	}
+	return req, query, nil
+}
+
+func (req *Request) validate() error {
+	if req.Expression == "" {
+		return errorf("MissingRequiredParameter", "Expression is required")
+	}
+	if len(req.Expression) > maxExpressionLength {
+		return errorf("ExpressionTooLong", "the expression is longer than %d", maxExpressionLength)
+	}
+	if !strings.EqualFold(req.ExpressionType, ExpressionTypeSql) {
+		return errorf("InvalidExpressionType", "ExpressionType %q is not supported", req.ExpressionType)
+	}
+
+	in := &req.InputSerialization
+	switch in.CompressionType = strings.ToUpper(in.CompressionType); in.CompressionType {
+	case "":
+		in.CompressionType = CompressionNone
+	case CompressionNone, CompressionGzip, CompressionBzip2:
+	default:
+		return errorf("InvalidCompressionFormat", "CompressionType %q is not supported", in.CompressionType)
+	}
+	if (in.CSV == nil) == (in.JSON == nil) {
+		return errorf("InvalidDataSource", "one of the CSV and JSON input serialization is required")
+	}
+	if in.CSV != nil {
+		switch in.CSV.FileHeaderInfo = strings.ToUpper(in.CSV.FileHeaderInfo); in.CSV.FileHeaderInfo {
+		case "":
+			in.CSV.FileHeaderInfo = FileHeaderNone
+		case FileHeaderNone, FileHeaderUse, FileHeaderIgnore:
+		default:
+			return errorf("InvalidFileHeaderInfo", "FileHeaderInfo %q is not supported", in.CSV.FileHeaderInfo)
+		}
+		if err := checkDelimiters(&in.CSV.RecordDelimiter, &in.CSV.FieldDelimiter, &in.CSV.QuoteCharacter); err != nil {
+			return err
+		}
+		if in.CSV.QuoteCharacter != `"` {
+			return errorf("InvalidQuoteFields", "QuoteCharacter %q is not supported", in.CSV.QuoteCharacter)
+		}
+		if in.CSV.QuoteEscapeCharacter != "" && in.CSV.QuoteEscapeCharacter != `"` {
+			return errorf("InvalidQuoteFields", "QuoteEscapeCharacter %q is not supported", in.CSV.QuoteEscapeCharacter)
+		}
+		if len(in.CSV.Comments) > 1 {
+			return errorf("InvalidRequestParameter", "Comments should be one character")
+		}
+	}
+	if in.JSON != nil {
+		switch in.JSON.Type = strings.ToUpper(in.JSON.Type); in.JSON.Type {
+		case JsonTypeDocument, JsonTypeLines:
+		default:
+			return errorf("InvalidJsonType", "JSON Type %q is not supported", in.JSON.Type)
+		}
+	}
+
+	out := &req.OutputSerialization
+	if (out.CSV == nil) == (out.JSON == nil) {
+		return errorf("InvalidRequestParameter", "one of the CSV and JSON output serialization is required")
+	}
+	if out.CSV != nil {
+		switch out.CSV.QuoteFields = strings.ToUpper(out.CSV.QuoteFields); out.CSV.QuoteFields {
+		case "":
+			out.CSV.QuoteFields = QuoteFieldsAsNeeded
+		case QuoteFieldsAlways, QuoteFieldsAsNeeded:
+		default:
+			return errorf("InvalidQuoteFields", "QuoteFields %q is not supported", out.CSV.QuoteFields)
+		}
+		if err := checkDelimiters(&out.CSV.RecordDelimiter, &out.CSV.FieldDelimiter, &out.CSV.QuoteCharacter); err != nil {
+			return err
+		}
+		if out.CSV.QuoteEscapeCharacter == "" {
+			out.CSV.QuoteEscapeCharacter = out.CSV.QuoteCharacter
+		}
+	}
+	if out.JSON != nil && out.JSON.RecordDelimiter == "" {
+		out.JSON.RecordDelimiter = "\n"
+	}
+	return nil
+}
+
+func checkDelimiters(recordDelimiter, fieldDelimiter, quoteCharacter *string) error {
+	if *recordDelimiter == "" {
+		*recordDelimiter = "\n"
+	}
+	if *fieldDelimiter == "" {
+		*fieldDelimiter = ","
+	}
+	if *quoteCharacter == "" {
+		*quoteCharacter = `"`
+	}
+	if len(*recordDelimiter) > 2 || (len(*recordDelimiter) == 2 && *recordDelimiter != "\r\n") {
+		return errorf("InvalidRequestParameter", "RecordDelimiter %q is not supported", *recordDelimiter)
+	}
+	if len(*fieldDelimiter) != 1 || len(*quoteCharacter) != 1 {
+		return errorf("InvalidRequestParameter", "FieldDelimiter and QuoteCharacter should be one character")
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package s3select
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type message struct {
+	headers map[string]string
+	payload []byte
+}
+
+func readMessages(t *testing.T, data []byte) (messages []message) {
+	for len(data) > 0 {
+		totalLength := binary.BigEndian.Uint32(data[0:4])
+		headersLength := binary.BigEndian.Uint32(data[4:8])
+		assert.Equal(t, crc32.ChecksumIEEE(data[0:8]), binary.BigEndian.Uint32(data[8:12]))
+		assert.Equal(t, crc32.ChecksumIEEE(data[:totalLength-4]), binary.BigEndian.Uint32(data[totalLength-4:totalLength]))
+
+		m := message{headers: make(map[string]string)}
+		headers := data[12 : 12+headersLength]
+		for len(headers) > 0 {
+			nameLength := int(headers[0])
+			name := string(headers[1 : 1+nameLength])
+			valueLength := int(binary.BigEndian.Uint16(headers[2+nameLength:]))
+			m.headers[name] = string(headers[4+nameLength : 4+nameLength+valueLength])
+			headers = headers[4+nameLength+valueLength:]
+		}
+		m.payload = data[12+headersLength : totalLength-4]
+		messages = append(messages, m)
+		data = data[totalLength:]
+	}
+	return
+}
+
+func runSelect(t *testing.T, requestXml string, input io.Reader) (records string, messages []message, err error) {
+	req, query, err := ParseRequest([]byte(requestXml))
+	if err != nil {
+		return "", nil, err
+	}
+	var buf bytes.Buffer
+	err = Execute(&buf, input, req, query)
+	messages = readMessages(t, buf.Bytes())
+	for _, m := range messages {
+		if m.headers[":event-type"] == "Records" {
+			records += string(m.payload)
+		}
+	}
+	return
+}
+
+func selectRequest(expression, input, output string) string {
+	return `<SelectObjectContentRequest><Expression>` + expression + `</Expression><ExpressionType>SQL</ExpressionType>` +
+		`<InputSerialization>` + input + `</InputSerialization><OutputSerialization>` + output + `</OutputSerialization></SelectObjectContentRequest>`
+}
+
+const people = "name,age,city\nalice,30,paris\nbob,25,\"new york, ny\"\ncarol,41,london\n"
+
+func TestSelectCsv(t *testing.T) {
+	csvInput := `<CSV><FileHeaderInfo>USE</FileHeaderInfo></CSV>`
+	tests := []struct {
+		expression string
+		output     string
+		expected   string
+	}{
+		{"SELECT * FROM S3Object", `<CSV/>`, "alice,30,paris\nbob,25,\"new york, ny\"\ncarol,41,london\n"},
+		{"SELECT s.name, s.age FROM S3Object s WHERE CAST(s.age AS INT) &gt; 28", `<CSV/>`, "alice,30\ncarol,41\n"},
+		{"SELECT name FROM S3Object WHERE age &lt; 30 OR city LIKE '%on'", `<CSV/>`, "bob\ncarol\n"},
+		{"SELECT _1, UPPER(_3) AS town FROM S3Object WHERE name IN ('alice', 'carol') LIMIT 1", `<JSON/>`, "{\"_1\":\"alice\",\"town\":\"PARIS\"}\n"},
+		{"SELECT COUNT(*), SUM(CAST(age AS INT)), MAX(age), AVG(age) FROM S3Object", `<CSV/>`, "3,96,41,32\n"},
+		{"SELECT name FROM S3Object WHERE age BETWEEN 26 AND 35", `<CSV><QuoteFields>ALWAYS</QuoteFields></CSV>`, "\"alice\"\n"},
+		{"SELECT SUBSTRING(name FROM 2 FOR 2), CHAR_LENGTH(city) FROM S3Object WHERE NOT name = 'bob'", `<JSON/>`, "{\"_1\":\"li\",\"_2\":5}\n{\"_1\":\"ar\",\"_2\":6}\n"},
+	}
+	for _, test := range tests {
+		records, messages, err := runSelect(t, selectRequest(test.expression, csvInput, test.output), strings.NewReader(people))
+		if err != nil {
+			t.Fatalf("%s: %v", test.expression, err)
+		}
+		assert.Equal(t, test.expected, records, test.expression)
+		assert.Equal(t, "Stats", messages[len(messages)-2].headers[":event-type"])
+		assert.Equal(t, "End", messages[len(messages)-1].headers[":event-type"])
+	}
+}
+
+func TestSelectJson(t *testing.T) {
+	lines := `{"id":1,"user":{"name":"alice","tags":["a","b"]},"score":9.5}
+{"id":2,"user":{"name":"bob","tags":[]},"score":null}
+{"id":3,"user":{"name":"carol","tags":["c"]},"score":7}
+`
+	var compressed bytes.Buffer
+	gzipWriter := gzip.NewWriter(&compressed)
+	gzipWriter.Write([]byte(lines))
+	gzipWriter.Close()
+
+	tests := []struct {
+		expression string
+		input      string
+		data       []byte
+		expected   string
+	}{
+		{"SELECT * FROM S3Object[*] s WHERE s.id = 2", `<JSON><Type>LINES</Type></JSON>`, []byte(lines),
+			"{\"id\":2,\"user\":{\"name\":\"bob\",\"tags\":[]},\"score\":null}\n"},
+		{"SELECT s.user.name, s.user.tags[0] AS tag FROM S3Object s WHERE s.score IS NOT NULL", `<CompressionType>GZIP</CompressionType><JSON><Type>LINES</Type></JSON>`, compressed.Bytes(),
+			"{\"name\":\"alice\",\"tag\":\"a\"}\n{\"name\":\"carol\",\"tag\":\"c\"}\n"},
+		{"SELECT MIN(s.score), COUNT(s.score) FROM S3Object[*].items s", `<JSON><Type>DOCUMENT</Type></JSON>`,
+			[]byte(`{"items":[{"score":3.5},{"score":1},{"score":null}]}`), "{\"_1\":1,\"_2\":2}\n"},
+	}
+	for _, test := range tests {
+		records, _, err := runSelect(t, selectRequest(test.expression, test.input, `<JSON/>`), bytes.NewReader(test.data))
+		if err != nil {
+			t.Fatalf("%s: %v", test.expression, err)
+		}
+		assert.Equal(t, test.expected, records, test.expression)
+	}
+}
+
+func TestSelectErrors(t *testing.T) {
+	csvInput := `<CSV><FileHeaderInfo>USE</FileHeaderInfo></CSV>`
+	for expression, code := range map[string]string{
+		"SELECT FROM S3Object":                         "ParseUnexpectedToken",
+		"DELETE FROM S3Object":                         "ParseUnexpectedToken",
+		"SELECT * FROM table":                          "ParseUnexpectedToken",
+		"SELECT name, COUNT(*) FROM S3Object":          "UnsupportedSqlStructure",
+		"SELECT * FROM S3Object WHERE COUNT(*) &gt; 1": "UnsupportedSqlOperation",
+		"SELECT * FROM S3Object WHERE name = 'alice":   "ParseInvalidLiteral",
+		"SELECT CAST(age AS DATE) FROM S3Object":       "ParseInvalidTypeParam",
+		"SELECT * FROM S3Object LIMIT -1":              "ParseUnexpectedToken",
+		"SELECT * FROM S3Object WHERE name = 'a' ; --": "ParseInvalidCharacter",
+	} {
+		_, _, err := runSelect(t, selectRequest(expression, csvInput, `<CSV/>`), strings.NewReader(people))
+		if assert.NotNil(t, err, expression) {
+			assert.Equal(t, code, err.(*Error).Code, expression)
+		}
+	}
+
+	_, _, err := runSelect(t, selectRequest("SELECT * FROM S3Object", `<CompressionType>ZIP</CompressionType>`+csvInput, `<CSV/>`), strings.NewReader(people))
+	assert.Equal(t, "InvalidCompressionFormat", err.(*Error).Code)
+	_, _, err = runSelect(t, selectRequest("SELECT * FROM S3Object", csvInput, ``), strings.NewReader(people))
+	assert.Equal(t, "InvalidRequestParameter", err.(*Error).Code)
+
+	// the evaluation errors are sent as the error messages
+	_, messages, err := runSelect(t, selectRequest("SELECT CAST(name AS INT) FROM S3Object", csvInput, `<CSV/>`), strings.NewReader(people))
+	assert.NotNil(t, err)
+	last := messages[len(messages)-1]
+	assert.Equal(t, "error", last.headers[":message-type"])
+	assert.Equal(t, "CastFailed", last.headers[":error-code"])
+}
+
+func TestMatchLike(t *testing.T) {
+	for _, test := range []struct {
+		s, pattern string
+		matched    bool
+	}{
+		{"london", "%on", true},
+		{"london", "l_nd%", true},
+		{"london", "_ondon_", false},
+		{"50%", "50!%", true},
+		{"500", "50!%", false},
+		{"", "%", true},
+	} {
+		assert.Equal(t, test.matched, matchLike([]rune(test.s), []rune(test.pattern), '!'), test.pattern)
+	}
+}
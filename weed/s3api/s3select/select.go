@@ -0,0 +1,200 @@
+package s3select
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	maxRecordsPayload = 64 * 1024
+	keepAliveInterval = 5 * time.Second
+)
+
+type flusher interface {
+	Flush()
+}
+
+type selector struct {
+	w         io.Writer
+	req       *Request
+	query     *Query
+	stats     stats
+	scanned   *countingReader
+	processed *countingReader
+	lastSent  time.Time
+}
+
+// Execute runs the query over the object content, and writes the results to w as the event stream messages.
+// The failure after the records are sent is also written as an error message.
+func Execute(w io.Writer, input io.Reader, req *Request, query *Query) error {
+	s := &selector{
+		w:        w,
+		req:      req,
+		query:    query,
+		scanned:  &countingReader{reader: input},
+		lastSent: time.Now(),
+	}
+	if err := s.run(); err != nil {
+		code, message := "InternalError", err.Error()
+		if e, ok := err.(*Error); ok {
+			code, message = e.Code, e.Message
+		}
+		writeErrorMessage(w, code, message)
+		s.flush()
+		return err
+	}
+	s.updateStats()
+	if _, err := writeStatsMessage(w, &s.stats); err != nil {
+		return err
+	}
+	if _, err := writeEndMessage(w); err != nil {
+		return err
+	}
+	s.flush()
+	return nil
+}
+
+func (s *selector) run() error {
+	decompressed, err := decompress(s.req.InputSerialization.CompressionType, s.scanned)
+	if err != nil {
+		return err
+	}
+	s.processed = &countingReader{reader: decompressed}
+	reader, err := newRecordReader(&s.req.InputSerialization, s.query, s.processed)
+	if err != nil {
+		return err
+	}
+	writer := newRecordWriter(&s.req.OutputSerialization)
+
+	var buf bytes.Buffer
+	var matched int64
+	for count := 1; s.query.limit < 0 || matched < s.query.limit; count++ {
+		rec, err := reader.read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if count%1024 == 0 && time.Since(s.lastSent) > keepAliveInterval {
+			if err = s.send(writeContinuationMessage(s.w)); err != nil {
+				return err
+			}
+		}
+
+		if s.query.where != nil {
+			isMatched, err := evalBool(s.query.where, rec)
+			if err != nil {
+				return err
+			}
+			if isMatched == nil || !*isMatched {
+				continue
+			}
+		}
+		matched++
+
+		if len(s.query.aggregates) > 0 {
+			for _, agg := range s.query.aggregates {
+				if err = agg.accumulate(rec); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		names, values, err := s.query.project(rec)
+		if err != nil {
+			return err
+		}
+		if err = writer.write(&buf, names, values); err != nil {
+			return err
+		}
+		if buf.Len() >= maxRecordsPayload {
+			if err = s.sendRecords(&buf); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(s.query.aggregates) > 0 {
+		names, values, err := s.query.project(nil)
+		if err != nil {
+			return err
+		}
+		if err = writer.write(&buf, names, values); err != nil {
+			return err
+		}
+	}
+	if buf.Len() > 0 {
+		return s.sendRecords(&buf)
+	}
+	return nil
+}
+
+func (s *selector) sendRecords(buf *bytes.Buffer) error {
+	if err := s.send(writeRecordsMessage(s.w, buf.Bytes())); err != nil {
+		return err
+	}
+	s.stats.bytesReturned += int64(buf.Len())
+	buf.Reset()
+	if s.req.RequestProgress.Enabled {
+		s.updateStats()
+		return s.send(writeProgressMessage(s.w, &s.stats))
+	}
+	return nil
+}
+
+func (s *selector) send(n int, err error) error {
+	if err != nil {
+		return fmt.Errorf("write event: %v", err)
+	}
+	s.flush()
+	s.lastSent = time.Now()
+	return nil
+}
+
+func (s *selector) flush() {
+	if f, ok := s.w.(flusher); ok {
+		f.Flush()
+	}
+}
+
+func (s *selector) updateStats() {
+	s.stats.bytesScanned = s.scanned.count
+	if s.processed != nil {
+		s.stats.bytesProcessed = s.processed.count
+	}
+}
+
+// project evaluates the selected columns, with the names for the json output
+func (q *Query) project(rec record) ([]string, []interface{}, error) {
+	if q.star {
+		names, values := rec.columns()
+		return names, values, nil
+	}
+	names := make([]string, len(q.projections))
+	values := make([]interface{}, len(q.projections))
+	for i, proj := range q.projections {
+		v, err := proj.expr.eval(rec)
+		if err != nil {
+			return nil, nil, err
+		}
+		values[i] = v
+		switch {
+		case proj.alias != "":
+			names[i] = proj.alias
+		case isColumnRef(proj.expr):
+			names[i] = proj.expr.(*columnRef).name()
+		default:
+			names[i] = fmt.Sprintf("_%d", i+1)
+		}
+	}
+	return names, values, nil
+}
+
+func isColumnRef(e expr) bool {
+	ref, ok := e.(*columnRef)
+	return ok && ref.name() != ""
+}
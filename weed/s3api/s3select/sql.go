@@ -0,0 +1,713 @@
+package s3select
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenQuotedIdent
+	tokenString
+	tokenNumber
+	tokenOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// Query is the parsed sql expression
+type Query struct {
+	star        bool
+	projections []*projection
+	aggregates  []*aggregateExpr
+	fromPath    []pathElem
+	alias       string
+	where       expr
+	limit       int64
+}
+
+type projection struct {
+	expr  expr
+	alias string
+}
+
+// ParseQuery parses the sql expression of the supported subset
+func ParseQuery(sql string) (*Query, error) {
+	tokens, err := tokenize(sql)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	return p.parseQuery()
+}
+
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '\'' || c == '"':
+			kind := tokenString
+			if c == '"' {
+				kind = tokenQuotedIdent
+			}
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(s); j++ {
+				if s[j] == c {
+					if j+1 < len(s) && s[j+1] == c {
+						b.WriteByte(c)
+						j++
+						continue
+					}
+					break
+				}
+				b.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return nil, errorf("ParseInvalidLiteral", "unterminated quote at %d", i)
+			}
+			tokens = append(tokens, token{kind: kind, text: b.String(), pos: i})
+			i = j + 1
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9':
+			j := i
+			for j < len(s) && (s[j] >= '0' && s[j] <= '9' || s[j] == '.') {
+				j++
+			}
+			if j < len(s) && (s[j] == 'e' || s[j] == 'E') {
+				k := j + 1
+				if k < len(s) && (s[k] == '+' || s[k] == '-') {
+					k++
+				}
+				if k < len(s) && s[k] >= '0' && s[k] <= '9' {
+					for j = k; j < len(s) && s[j] >= '0' && s[j] <= '9'; j++ {
+					}
+				}
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: s[i:j], pos: i})
+			i = j
+		case c == '_' || c < 0x80 && unicode.IsLetter(rune(c)):
+			j := i
+			for j < len(s) && (s[j] == '_' || s[j] < 0x80 && (unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j])))) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: s[i:j], pos: i})
+			i = j
+		default:
+			op := string(c)
+			if i+1 < len(s) {
+				switch two := s[i : i+2]; two {
+				case "<=", ">=", "<>", "!=", "||":
+					op = two
+				}
+			}
+			if !strings.Contains("=<>!|+-*/%(),.[]", op[:1]) || op == "!" || op == "|" {
+				return nil, errorf("ParseInvalidCharacter", "unexpected character %q at %d", c, i)
+			}
+			tokens = append(tokens, token{kind: tokenOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(s)}), nil
+}
+
+type parser struct {
+	tokens      []token
+	pos         int
+	refs        []*columnRef
+	aggregates  []*aggregateExpr
+	inAggregate bool
+	// the column references outside of the aggregates
+	plainRefs int
+}
+
+func (p *parser) peek() token {
+	if p.pos >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *parser) isKeyword(keyword string) bool {
+	t := p.peek()
+	return t.kind == tokenIdent && strings.EqualFold(t.text, keyword)
+}
+
+func (p *parser) acceptKeyword(keyword string) bool {
+	if p.isKeyword(keyword) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) isOp(op string) bool {
+	t := p.peek()
+	return t.kind == tokenOp && t.text == op
+}
+
+func (p *parser) acceptOp(op string) bool {
+	if p.isOp(op) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) unexpected() error {
+	t := p.peek()
+	if t.kind == tokenEOF {
+		return errorf("ParseUnexpectedToken", "unexpected end of the expression")
+	}
+	return errorf("ParseUnexpectedToken", "unexpected %q at %d", t.text, t.pos)
+}
+
+func (p *parser) expectKeyword(keyword string) error {
+	if !p.acceptKeyword(keyword) {
+		return p.unexpected()
+	}
+	return nil
+}
+
+func (p *parser) expectOp(op string) error {
+	if !p.acceptOp(op) {
+		return p.unexpected()
+	}
+	return nil
+}
+
+var reservedWords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "LIMIT": true, "AS": true, "AND": true, "OR": true, "NOT": true,
+	"LIKE": true, "ESCAPE": true, "BETWEEN": true, "IN": true, "IS": true, "NULL": true, "TRUE": true, "FALSE": true,
+	"CAST": true, "MISSING": true,
+}
+
+func (p *parser) parseQuery() (*Query, error) {
+	q := &Query{limit: -1}
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return nil, err
+	}
+
+	if p.acceptOp("*") {
+		q.star = true
+	} else if t := p.peek(); t.kind == tokenIdent && p.pos+2 < len(p.tokens) && p.tokens[p.pos+1].text == "." && p.tokens[p.pos+2].text == "*" {
+		// alias.*
+		p.pos += 3
+		q.star = true
+	} else {
+		for {
+			e, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			proj := &projection{expr: e}
+			if p.acceptKeyword("AS") {
+				t := p.next()
+				if t.kind != tokenIdent && t.kind != tokenQuotedIdent {
+					p.pos--
+					return nil, p.unexpected()
+				}
+				proj.alias = t.text
+			} else if t := p.peek(); t.kind == tokenQuotedIdent || t.kind == tokenIdent && !reservedWords[strings.ToUpper(t.text)] {
+				p.pos++
+				proj.alias = t.text
+			}
+			q.projections = append(q.projections, proj)
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+		if len(p.aggregates) > 0 && p.plainRefs > 0 {
+			return nil, errorf("UnsupportedSqlStructure", "the columns should be in the aggregate functions")
+		}
+		q.aggregates = p.aggregates
+	}
+
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	if t := p.next(); t.kind != tokenIdent || !strings.EqualFold(t.text, "S3Object") {
+		p.pos--
+		return nil, p.unexpected()
+	}
+	if p.acceptOp("[") {
+		if err := p.expectOp("*"); err != nil {
+			return nil, err
+		}
+		if err := p.expectOp("]"); err != nil {
+			return nil, err
+		}
+		for p.acceptOp(".") {
+			t := p.next()
+			if t.kind != tokenIdent && t.kind != tokenQuotedIdent {
+				p.pos--
+				return nil, p.unexpected()
+			}
+			q.fromPath = append(q.fromPath, pathElem{name: t.text, quoted: t.kind == tokenQuotedIdent, index: -1})
+		}
+	}
+	if p.acceptKeyword("AS") {
+		t := p.next()
+		if t.kind != tokenIdent && t.kind != tokenQuotedIdent {
+			p.pos--
+			return nil, p.unexpected()
+		}
+		q.alias = t.text
+	} else if t := p.peek(); t.kind == tokenIdent && !reservedWords[strings.ToUpper(t.text)] {
+		p.pos++
+		q.alias = t.text
+	}
+
+	if p.acceptKeyword("WHERE") {
+		count := len(p.aggregates)
+		where, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if len(p.aggregates) > count {
+			return nil, errorf("UnsupportedSqlOperation", "the aggregate functions are not allowed in WHERE")
+		}
+		q.where = where
+	}
+
+	if p.acceptKeyword("LIMIT") {
+		t := p.next()
+		limit, err := strconv.ParseInt(t.text, 10, 64)
+		if t.kind != tokenNumber || err != nil || limit < 0 {
+			p.pos--
+			return nil, p.unexpected()
+		}
+		q.limit = limit
+	}
+
+	if p.peek().kind != tokenEOF {
+		return nil, p.unexpected()
+	}
+
+	// the references qualified by the alias, e.g. s._1 or S3Object.name
+	for _, ref := range p.refs {
+		if len(ref.path) > 1 && ref.path[0].index < 0 {
+			if first := ref.path[0].name; q.alias != "" && strings.EqualFold(first, q.alias) || strings.EqualFold(first, "S3Object") {
+				ref.path = ref.path[1:]
+			}
+		}
+	}
+	return q, nil
+}
+
+func (p *parser) parseExpr() (expr, error) {
+	return p.parseOr()
+}
+
+func (p *parser) parseOr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpr{op: "OR", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpr{op: "AND", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (expr, error) {
+	if p.acceptKeyword("NOT") {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{x: x}, nil
+	}
+	return p.parsePredicate()
+}
+
+func (p *parser) parsePredicate() (expr, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind == tokenOp {
+		switch t.text {
+		case "=", "!=", "<>", "<", "<=", ">", ">=":
+			p.pos++
+			right, err := p.parseAdditive()
+			if err != nil {
+				return nil, err
+			}
+			return &compareExpr{op: t.text, left: left, right: right}, nil
+		}
+	}
+
+	if p.acceptKeyword("IS") {
+		not := p.acceptKeyword("NOT")
+		if p.acceptKeyword("NULL") || p.acceptKeyword("MISSING") {
+			return &isNullExpr{x: left, not: not}, nil
+		}
+		return nil, p.unexpected()
+	}
+
+	not := p.acceptKeyword("NOT")
+	switch {
+	case p.acceptKeyword("LIKE"):
+		pattern, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		e := &likeExpr{x: left, pattern: pattern, not: not}
+		if p.acceptKeyword("ESCAPE") {
+			if e.escape, err = p.parseAdditive(); err != nil {
+				return nil, err
+			}
+		}
+		return e, nil
+	case p.acceptKeyword("BETWEEN"):
+		low, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expectKeyword("AND"); err != nil {
+			return nil, err
+		}
+		high, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return &betweenExpr{x: left, low: low, high: high, not: not}, nil
+	case p.acceptKeyword("IN"):
+		if err := p.expectOp("("); err != nil {
+			return nil, err
+		}
+		list, err := p.parseExprList()
+		if err != nil {
+			return nil, err
+		}
+		return &inExpr{x: left, list: list, not: not}, nil
+	}
+	if not {
+		return nil, p.unexpected()
+	}
+	return left, nil
+}
+
+// parseExprList parses the expressions until the closing parenthesis
+func (p *parser) parseExprList() ([]expr, error) {
+	var list []expr
+	for {
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+		if p.acceptOp(")") {
+			return list, nil
+		}
+		if err = p.expectOp(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) parseAdditive() (expr, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+") || p.isOp("-") || p.isOp("||") {
+		op := p.next().text
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithmeticExpr{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseMultiplicative() (expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("*") || p.isOp("/") || p.isOp("%") {
+		op := p.next().text
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithmeticExpr{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (expr, error) {
+	if p.acceptOp("-") {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &arithmeticExpr{op: "-", left: &literalExpr{value: int64(0)}, right: x}, nil
+	}
+	if p.acceptOp("+") {
+		return p.parseUnary()
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		return &literalExpr{value: t.text}, nil
+	case tokenNumber:
+		if i, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return &literalExpr{value: i}, nil
+		}
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, errorf("ParseInvalidLiteral", "invalid number %q at %d", t.text, t.pos)
+		}
+		return &literalExpr{value: f}, nil
+	case tokenOp:
+		if t.text == "(" {
+			e, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			return e, nil
+		}
+	case tokenQuotedIdent:
+		p.pos--
+		return p.parseColumnRef()
+	case tokenIdent:
+		keyword := strings.ToUpper(t.text)
+		switch keyword {
+		case "NULL", "MISSING":
+			return &literalExpr{value: nil}, nil
+		case "TRUE":
+			return &literalExpr{value: true}, nil
+		case "FALSE":
+			return &literalExpr{value: false}, nil
+		}
+		if p.isOp("(") {
+			p.pos++
+			return p.parseFunction(keyword)
+		}
+		if reservedWords[keyword] {
+			break
+		}
+		p.pos--
+		return p.parseColumnRef()
+	}
+	p.pos--
+	return nil, p.unexpected()
+}
+
+func (p *parser) parseColumnRef() (expr, error) {
+	ref := &columnRef{}
+	t := p.next()
+	ref.path = append(ref.path, pathElem{name: t.text, quoted: t.kind == tokenQuotedIdent, index: -1})
+	for {
+		if p.acceptOp(".") {
+			t := p.next()
+			if t.kind != tokenIdent && t.kind != tokenQuotedIdent {
+				p.pos--
+				return nil, p.unexpected()
+			}
+			ref.path = append(ref.path, pathElem{name: t.text, quoted: t.kind == tokenQuotedIdent, index: -1})
+		} else if p.acceptOp("[") {
+			t := p.next()
+			index, err := strconv.Atoi(t.text)
+			if t.kind != tokenNumber || err != nil || index < 0 {
+				p.pos--
+				return nil, p.unexpected()
+			}
+			if err = p.expectOp("]"); err != nil {
+				return nil, err
+			}
+			ref.path = append(ref.path, pathElem{index: index})
+		} else {
+			break
+		}
+	}
+	p.refs = append(p.refs, ref)
+	if !p.inAggregate {
+		p.plainRefs++
+	}
+	return ref, nil
+}
+
+func (p *parser) parseFunction(name string) (expr, error) {
+	switch name {
+	case "COUNT", "SUM", "AVG", "MIN", "MAX":
+		if p.inAggregate {
+			return nil, errorf("UnsupportedSqlStructure", "the aggregate functions can not be nested")
+		}
+		agg := &aggregateExpr{fn: name}
+		if name == "COUNT" && p.acceptOp("*") {
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+		} else {
+			p.inAggregate = true
+			arg, err := p.parseExpr()
+			p.inAggregate = false
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			agg.arg = arg
+		}
+		p.aggregates = append(p.aggregates, agg)
+		return agg, nil
+
+	case "CAST":
+		x, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expectKeyword("AS"); err != nil {
+			return nil, err
+		}
+		t := p.next()
+		typ := strings.ToUpper(t.text)
+		switch typ {
+		case "INT", "INTEGER", "FLOAT", "DECIMAL", "NUMERIC", "STRING", "VARCHAR", "CHAR", "BOOL", "BOOLEAN":
+		default:
+			p.pos--
+			return nil, errorf("ParseInvalidTypeParam", "unsupported type %q", t.text)
+		}
+		if err = p.expectOp(")"); err != nil {
+			return nil, err
+		}
+		return &castExpr{x: x, typ: typ}, nil
+
+	case "TRIM":
+		e := &functionExpr{name: name}
+		trimType := "BOTH"
+		if p.isKeyword("LEADING") || p.isKeyword("TRAILING") || p.isKeyword("BOTH") {
+			trimType = strings.ToUpper(p.next().text)
+		}
+		var chars expr
+		if !p.isKeyword("FROM") {
+			x, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if p.acceptOp(")") {
+				e.args = []expr{&literalExpr{value: trimType}, x, &literalExpr{value: " "}}
+				return e, nil
+			}
+			chars = x
+		}
+		if err := p.expectKeyword("FROM"); err != nil {
+			return nil, err
+		}
+		x, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expectOp(")"); err != nil {
+			return nil, err
+		}
+		if chars == nil {
+			chars = &literalExpr{value: " "}
+		}
+		e.args = []expr{&literalExpr{value: trimType}, x, chars}
+		return e, nil
+
+	case "SUBSTRING":
+		x, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		e := &functionExpr{name: name, args: []expr{x}}
+		if p.acceptKeyword("FROM") || p.acceptOp(",") {
+			start, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			e.args = append(e.args, start)
+			if p.acceptKeyword("FOR") || p.acceptOp(",") {
+				length, err := p.parseExpr()
+				if err != nil {
+					return nil, err
+				}
+				e.args = append(e.args, length)
+			}
+		} else {
+			return nil, p.unexpected()
+		}
+		if err = p.expectOp(")"); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+
+	arity, found := functionArity[name]
+	if !found {
+		return nil, errorf("UnsupportedFunction", "function %s is not supported", name)
+	}
+	e := &functionExpr{name: name}
+	if !p.acceptOp(")") {
+		args, err := p.parseExprList()
+		if err != nil {
+			return nil, err
+		}
+		e.args = args
+	}
+	if arity >= 0 && len(e.args) != arity || arity < 0 && len(e.args) == 0 {
+		return nil, errorf("IncorrectSqlFunctionArgumentType", "wrong number of arguments of %s", name)
+	}
+	return e, nil
+}
+
+// the number of arguments of the plain functions, -1 for any
+var functionArity = map[string]int{
+	"LOWER":            1,
+	"UPPER":            1,
+	"CHAR_LENGTH":      1,
+	"CHARACTER_LENGTH": 1,
+	"COALESCE":         -1,
+	"NULLIF":           2,
+}
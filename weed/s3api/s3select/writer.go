@@ -0,0 +1,69 @@
+package s3select
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+type recordWriter interface {
+	write(buf *bytes.Buffer, names []string, values []interface{}) error
+}
+
+func newRecordWriter(out *OutputSerialization) recordWriter {
+	if out.CSV != nil {
+		return &csvWriter{out: out.CSV}
+	}
+	return &jsonWriter{out: out.JSON}
+}
+
+type csvWriter struct {
+	out *CSVOutput
+}
+
+func (w *csvWriter) write(buf *bytes.Buffer, names []string, values []interface{}) error {
+	for i, v := range values {
+		if i > 0 {
+			buf.WriteString(w.out.FieldDelimiter)
+		}
+		s := toString(v)
+		if w.out.QuoteFields == QuoteFieldsAlways || w.needsQuote(s) {
+			buf.WriteString(w.out.QuoteCharacter)
+			buf.WriteString(strings.Replace(s, w.out.QuoteCharacter, w.out.QuoteEscapeCharacter+w.out.QuoteCharacter, -1))
+			buf.WriteString(w.out.QuoteCharacter)
+		} else {
+			buf.WriteString(s)
+		}
+	}
+	buf.WriteString(w.out.RecordDelimiter)
+	return nil
+}
+
+func (w *csvWriter) needsQuote(s string) bool {
+	return strings.Contains(s, w.out.FieldDelimiter) || strings.Contains(s, w.out.QuoteCharacter) ||
+		strings.Contains(s, w.out.RecordDelimiter) || strings.ContainsAny(s, "\r\n")
+}
+
+type jsonWriter struct {
+	out *JSONOutput
+}
+
+func (w *jsonWriter) write(buf *bytes.Buffer, names []string, values []interface{}) error {
+	buf.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(names[i])
+		buf.Write(name)
+		buf.WriteByte(':')
+		value, err := marshalJson(v)
+		if err != nil {
+			return errorf("InternalError", "encode %s: %v", names[i], err)
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	buf.WriteString(w.out.RecordDelimiter)
+	return nil
+}
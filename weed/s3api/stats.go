@@ -10,11 +10,18 @@ import (
 
 type StatusRecorder struct {
 	http.ResponseWriter
-	Status int
+	Status  int
+	Written int64
 }
 
 func NewStatusResponseWriter(w http.ResponseWriter) *StatusRecorder {
-	return &StatusRecorder{w, http.StatusOK}
+	return &StatusRecorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (r *StatusRecorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.Written += int64(n)
+	return n, err
 }
 
 func (r *StatusRecorder) WriteHeader(status int) {
@@ -26,13 +33,20 @@ func (r *StatusRecorder) Flush() {
 	r.ResponseWriter.(http.Flusher).Flush()
 }
 
-func track(f http.HandlerFunc, action string) http.HandlerFunc {
+func (s3a *S3ApiServer) track(f http.HandlerFunc, action string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Server", "SeaweedFS S3 "+util.VERSION)
 		recorder := NewStatusResponseWriter(w)
+		body := &countingReader{ReadCloser: r.Body}
+		if r.Body != nil {
+			r.Body = body
+		}
+		usage := &requestUsage{}
+		r = r.WithContext(withRequestUsage(r.Context(), usage))
 		start := time.Now()
 		f(recorder, r)
 		stats_collect.S3RequestHistogram.WithLabelValues(action).Observe(time.Since(start).Seconds())
 		stats_collect.S3RequestCounter.WithLabelValues(action, strconv.Itoa(recorder.Status)).Inc()
+		s3a.meterUsage(r, action, recorder.Status, usage, body.count, recorder.Written)
 	}
 }
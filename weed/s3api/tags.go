@@ -2,6 +2,16 @@ package s3api
 
 import (
 	"encoding/xml"
+	"net/url"
+	"sort"
+
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
+)
+
+const (
+	maxObjectTags        = 10
+	maxObjectTagKeyLen   = 128
+	maxObjectTagValueLen = 256
 )
 
 type Tag struct {
@@ -34,5 +44,46 @@ func FromTags(tags map[string]string) (t *Tagging) {
 			Value: v,
 		})
 	}
+	sort.Slice(t.TagSet.Tag, func(i, j int) bool {
+		return t.TagSet.Tag[i].Key < t.TagSet.Tag[j].Key
+	})
 	return
 }
+
+// ParseTagsHeader parses the url encoded x-amz-tagging header, e.g. "k1=v1&k2=v2"
+func ParseTagsHeader(header string) (map[string]string, s3err.ErrorCode) {
+	values, err := url.ParseQuery(header)
+	if err != nil {
+		return nil, s3err.ErrInvalidTag
+	}
+	tags := make(map[string]string)
+	for k, v := range values {
+		if len(v) != 1 {
+			return nil, s3err.ErrInvalidTag
+		}
+		tags[k] = v[0]
+	}
+	return tags, ValidateTags(tags)
+}
+
+// EncodeTagsHeader encodes the tags as the x-amz-tagging header
+func EncodeTagsHeader(tags map[string]string) string {
+	values := url.Values{}
+	for k, v := range tags {
+		values.Set(k, v)
+	}
+	return values.Encode()
+}
+
+// ValidateTags checks the limits of the object tags
+func ValidateTags(tags map[string]string) s3err.ErrorCode {
+	if len(tags) > maxObjectTags {
+		return s3err.ErrInvalidTag
+	}
+	for k, v := range tags {
+		if len(k) == 0 || len(k) > maxObjectTagKeyLen || len(v) > maxObjectTagValueLen {
+			return s3err.ErrInvalidTag
+		}
+	}
+	return s3err.ErrNone
+}
@@ -2,6 +2,7 @@ package s3api
 
 import (
 	"encoding/xml"
+	"github.com/chrislusf/seaweedfs/weed/s3api/s3err"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -48,3 +49,24 @@ func TestXMLMarshall(t *testing.T) {
 	assert.Equal(t, expected, actual)
 
 }
+
+func TestParseTagsHeader(t *testing.T) {
+
+	tags, errCode := ParseTagsHeader("project=seaweed%20fs&empty=")
+	assert.Equal(t, s3err.ErrNone, errCode)
+	assert.Equal(t, map[string]string{"project": "seaweed fs", "empty": ""}, tags)
+
+	tags, errCode = ParseTagsHeader(EncodeTagsHeader(map[string]string{"a&b": "c=d"}))
+	assert.Equal(t, s3err.ErrNone, errCode)
+	assert.Equal(t, "c=d", tags["a&b"])
+
+	_, errCode = ParseTagsHeader("k=1&k=2")
+	assert.Equal(t, s3err.ErrInvalidTag, errCode, "duplicated key")
+	_, errCode = ParseTagsHeader("a=1&b=2&c=3&d=4&e=5&f=6&g=7&h=8&i=9&j=10&k=11")
+	assert.Equal(t, s3err.ErrInvalidTag, errCode, "too many tags")
+	_, errCode = ParseTagsHeader("=v")
+	assert.Equal(t, s3err.ErrInvalidTag, errCode, "empty key")
+
+	sorted := FromTags(map[string]string{"b": "2", "a": "1"})
+	assert.Equal(t, "a", sorted.TagSet.Tag[0].Key)
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/chrislusf/seaweedfs/weed/operation"
 	"github.com/chrislusf/seaweedfs/weed/stats"
 	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+	"github.com/chrislusf/seaweedfs/weed/storage/super_block"
 	"github.com/chrislusf/seaweedfs/weed/util"
 
 	"github.com/gorilla/mux"
@@ -26,6 +27,10 @@ import (
 	_ "github.com/chrislusf/seaweedfs/weed/statik"
 )
 
+// ReplicationHeader sets the replication of one uploaded file, the same as the "replication" parameter,
+// overriding the collection or bucket default, e.g. for the S3 clients, whose headers are passed on to the filer.
+const ReplicationHeader = "Seaweed-Replication"
+
 var serverStats *stats.ServerStats
 var startTime = time.Now()
 var statikFS http.FileSystem
@@ -193,6 +198,18 @@ func parseURLPath(path string) (vid, fid, filename, ext string, isVolumeIdOnly b
 	return
 }
 
+// requestedReplication is the replication parameter, or the replication header, and empty to use the defaults.
+func requestedReplication(r *http.Request, qReplication string) (replication string, err error) {
+	replication = util.Nvl(qReplication, r.Header.Get(ReplicationHeader))
+	if replication == "" {
+		return "", nil
+	}
+	if _, err = super_block.NewReplicaPlacementFromString(replication); err != nil {
+		return "", fmt.Errorf("replication %s: %v", replication, err)
+	}
+	return replication, nil
+}
+
 func statsHealthHandler(w http.ResponseWriter, r *http.Request) {
 	m := make(map[string]interface{})
 	m["Version"] = util.Version()
@@ -212,6 +229,13 @@ func statsMemoryHandler(w http.ResponseWriter, r *http.Request) {
 	writeJsonQuiet(w, r, http.StatusOK, m)
 }
 
+func statsConfigHandler(w http.ResponseWriter, r *http.Request) {
+	m := make(map[string]interface{})
+	m["Version"] = util.Version()
+	m["Configuration"] = util.GetViper().EffectiveConfiguration()
+	writeJsonQuiet(w, r, http.StatusOK, m)
+}
+
 func handleStaticResources(defaultMux *http.ServeMux) {
 	defaultMux.Handle("/favicon.ico", http.FileServer(statikFS))
 	defaultMux.Handle("/seaweedfsstatic/", http.StripPrefix("/seaweedfsstatic", http.FileServer(statikFS)))
@@ -1,6 +1,7 @@
 package weed_server
 
 import (
+	"net/http/httptest"
 	"strings"
 	"testing"
 )
@@ -29,3 +30,22 @@ func TestParseURL(t *testing.T) {
 		}
 	}
 }
+
+func TestRequestedReplication(t *testing.T) {
+	r := httptest.NewRequest("POST", "/buckets/b1/critical.txt", nil)
+	if replication, err := requestedReplication(r, ""); replication != "" || err != nil {
+		t.Errorf("default replication: %q, %v", replication, err)
+	}
+
+	r.Header.Set(ReplicationHeader, "002")
+	if replication, _ := requestedReplication(r, ""); replication != "002" {
+		t.Errorf("header replication: %q", replication)
+	}
+	if replication, _ := requestedReplication(r, "010"); replication != "010" {
+		t.Errorf("parameter replication: %q", replication)
+	}
+
+	if _, err := requestedReplication(r, "0x1"); err == nil {
+		t.Errorf("invalid replication accepted")
+	}
+}
@@ -21,6 +21,10 @@ func (fs *FilerServer) LookupDirectoryEntry(ctx context.Context, req *filer_pb.L
 
 	glog.V(4).Infof("LookupDirectoryEntry %s", filepath.Join(req.Directory, req.Name))
 
+	if err := fs.checkGrpcPermission(ctx, util.JoinPath(req.Directory, req.Name), filer.AclRead); err != nil {
+		return nil, err
+	}
+
 	entry, err := fs.filer.FindEntry(ctx, util.JoinPath(req.Directory, req.Name))
 	if err == filer_pb.ErrNotFound {
 		return &filer_pb.LookupDirectoryEntryResponse{}, err
@@ -48,6 +52,10 @@ func (fs *FilerServer) ListEntries(req *filer_pb.ListEntriesRequest, stream file
 
 	glog.V(4).Infof("ListEntries %v", req)
 
+	if err = fs.checkGrpcPermission(stream.Context(), util.FullPath(req.Directory), filer.AclRead); err != nil {
+		return err
+	}
+
 	limit := int(req.Limit)
 	if limit == 0 {
 		limit = fs.option.DirListingLimit
@@ -156,12 +164,17 @@ func (fs *FilerServer) CreateEntry(ctx context.Context, req *filer_pb.CreateEntr
 
 	resp = &filer_pb.CreateEntryResponse{}
 
+	if err = fs.checkGrpcPermission(ctx, util.JoinPath(req.Directory, req.Entry.Name), filer.AclWrite); err != nil {
+		resp.Error = err.Error()
+		return
+	}
+
 	chunks, garbage, err2 := fs.cleanupChunks(util.Join(req.Directory, req.Entry.Name), nil, req.Entry)
 	if err2 != nil {
 		return &filer_pb.CreateEntryResponse{}, fmt.Errorf("CreateEntry cleanupChunks %s %s: %v", req.Directory, req.Entry.Name, err2)
 	}
 
-	createErr := fs.filer.CreateEntry(ctx, &filer.Entry{
+	newEntry := &filer.Entry{
 		FullPath:        util.JoinPath(req.Directory, req.Entry.Name),
 		Attr:            filer.PbToEntryAttribute(req.Entry.Attributes),
 		Chunks:          chunks,
@@ -169,7 +182,12 @@ func (fs *FilerServer) CreateEntry(ctx context.Context, req *filer_pb.CreateEntr
 		HardLinkId:      filer.HardLinkId(req.Entry.HardLinkId),
 		HardLinkCounter: req.Entry.HardLinkCounter,
 		Content:         req.Entry.Content,
-	}, req.OExcl, req.IsFromOtherCluster, req.Signatures)
+	}
+	// the checksum is not taken from the client, but computed by the filer
+	newEntry.Sha256 = nil
+	fs.filer.EnsureChecksum(newEntry)
+
+	createErr := fs.filer.CreateEntry(ctx, newEntry, req.OExcl, req.IsFromOtherCluster, req.Signatures)
 
 	if createErr == nil {
 		fs.filer.DeleteChunks(garbage)
@@ -186,10 +204,16 @@ func (fs *FilerServer) UpdateEntry(ctx context.Context, req *filer_pb.UpdateEntr
 	glog.V(4).Infof("UpdateEntry %v", req)
 
 	fullpath := util.Join(req.Directory, req.Entry.Name)
+	if err := fs.checkGrpcPermission(ctx, util.FullPath(fullpath), filer.AclWrite); err != nil {
+		return &filer_pb.UpdateEntryResponse{}, err
+	}
 	entry, err := fs.filer.FindEntry(ctx, util.FullPath(fullpath))
 	if err != nil {
 		return &filer_pb.UpdateEntryResponse{}, fmt.Errorf("not found %s: %v", fullpath, err)
 	}
+	if req.IsExtendedOnly {
+		return &filer_pb.UpdateEntryResponse{}, fs.updateEntryExtended(ctx, entry, req)
+	}
 
 	chunks, garbage, err2 := fs.cleanupChunks(fullpath, entry, req.Entry)
 	if err2 != nil {
@@ -223,15 +247,37 @@ func (fs *FilerServer) UpdateEntry(ctx context.Context, req *filer_pb.UpdateEntr
 		newEntry.Attr.Mime = req.Entry.Attributes.Mime
 		newEntry.Attr.UserName = req.Entry.Attributes.UserName
 		newEntry.Attr.GroupNames = req.Entry.Attributes.GroupName
+		// the checksum is not taken from the client, but kept if the content is the same, or computed again
 
 	}
+	filer.MaybeClearStaleChecksum(entry, newEntry)
+	fs.filer.EnsureChecksum(newEntry)
 
 	if filer.EqualEntry(entry, newEntry) {
 		return &filer_pb.UpdateEntryResponse{}, err
 	}
 
+	isVersionKept := false
+	if !req.IsFromOtherCluster {
+		if err = fs.filer.CheckQuotas(ctx, entry, newEntry); err != nil {
+			return &filer_pb.UpdateEntryResponse{}, err
+		}
+		if err = fs.filer.CheckUpdateAllowed(ctx, entry, newEntry); err != nil {
+			return &filer_pb.UpdateEntryResponse{}, err
+		}
+		if isVersionKept, err = fs.filer.KeepVersion(ctx, entry, newEntry); err != nil {
+			return &filer_pb.UpdateEntryResponse{}, err
+		}
+	}
+
 	if err = fs.filer.UpdateEntry(ctx, entry, newEntry); err == nil {
-		fs.filer.DeleteChunks(garbage)
+		if isVersionKept {
+			// the old chunks are kept by the version
+			fs.filer.DeleteChunks(filer.ExcludeChunks(garbage, entry.Chunks))
+			fs.filer.PruneVersions(ctx, newEntry.FullPath, newEntry)
+		} else {
+			fs.filer.DeleteChunks(garbage)
+		}
 
 		fs.filer.NotifyUpdateEvent(ctx, entry, newEntry, true, req.IsFromOtherCluster, req.Signatures)
 
@@ -242,6 +288,33 @@ func (fs *FilerServer) UpdateEntry(ctx context.Context, req *filer_pb.UpdateEntr
 	return &filer_pb.UpdateEntryResponse{}, err
 }
 
+// updateEntryExtended sets the extended attributes in the request on the current entry, and keeps its content and the other attributes,
+// so the update does not overwrite the content written concurrently.
+func (fs *FilerServer) updateEntryExtended(ctx context.Context, entry *filer.Entry, req *filer_pb.UpdateEntryRequest) error {
+	newEntry := entry.Clone()
+	newEntry.Content = entry.Content
+	newEntry.Extended = make(map[string][]byte)
+	for k, v := range entry.Extended {
+		newEntry.Extended[k] = v
+	}
+	for k, v := range req.Entry.Extended {
+		newEntry.Extended[k] = v
+	}
+	if filer.EqualEntry(entry, newEntry) {
+		return nil
+	}
+	if !req.IsFromOtherCluster {
+		if err := fs.filer.CheckUpdateAllowed(ctx, entry, newEntry); err != nil {
+			return err
+		}
+	}
+	if err := fs.filer.UpdateEntry(ctx, entry, newEntry); err != nil {
+		return fmt.Errorf("update extended attributes of %s: %v", entry.FullPath, err)
+	}
+	fs.filer.NotifyUpdateEvent(ctx, entry, newEntry, false, req.IsFromOtherCluster, req.Signatures)
+	return nil
+}
+
 func (fs *FilerServer) cleanupChunks(fullpath string, existingEntry *filer.Entry, newEntry *filer_pb.Entry) (chunks, garbage []*filer_pb.FileChunk, err error) {
 
 	// remove old chunks if not included in the new ones
@@ -267,7 +340,7 @@ func (fs *FilerServer) cleanupChunks(fullpath string, existingEntry *filer.Entry
 			"",
 			"",
 		)
-		chunks, err = filer.MaybeManifestize(fs.saveAsChunk(so), chunks)
+		chunks, err = filer.MaybeManifestize(fs.saveAsChunk(so, fs.option.Cipher), chunks)
 		if err != nil {
 			// not good, but should be ok
 			glog.V(0).Infof("MaybeManifestize: %v", err)
@@ -284,6 +357,9 @@ func (fs *FilerServer) AppendToEntry(ctx context.Context, req *filer_pb.AppendTo
 	glog.V(4).Infof("AppendToEntry %v", req)
 
 	fullpath := util.NewFullPath(req.Directory, req.EntryName)
+	if err := fs.checkGrpcPermission(ctx, fullpath, filer.AclWrite); err != nil {
+		return &filer_pb.AppendToEntryResponse{}, err
+	}
 	var offset int64 = 0
 	entry, err := fs.filer.FindEntry(ctx, fullpath)
 	if err == filer_pb.ErrNotFound {
@@ -308,11 +384,13 @@ func (fs *FilerServer) AppendToEntry(ctx context.Context, req *filer_pb.AppendTo
 
 	entry.Chunks = append(entry.Chunks, req.Chunks...)
 	so := fs.detectStorageOption(string(fullpath), entry.Collection, entry.Replication, entry.TtlSec, entry.DiskType, "", "")
-	entry.Chunks, err = filer.MaybeManifestize(fs.saveAsChunk(so), entry.Chunks)
+	entry.Chunks, err = filer.MaybeManifestize(fs.saveAsChunk(so, fs.option.Cipher), entry.Chunks)
 	if err != nil {
 		// not good, but should be ok
 		glog.V(0).Infof("MaybeManifestize: %v", err)
 	}
+	entry.Sha256 = nil
+	fs.filer.EnsureChecksum(entry)
 
 	err = fs.filer.CreateEntry(context.Background(), entry, false, false, nil)
 
@@ -323,6 +401,9 @@ func (fs *FilerServer) DeleteEntry(ctx context.Context, req *filer_pb.DeleteEntr
 
 	glog.V(4).Infof("DeleteEntry %v", req)
 
+	if err = fs.checkGrpcPermission(ctx, util.JoinPath(req.Directory, req.Name), aclDelete); err != nil {
+		return &filer_pb.DeleteEntryResponse{Error: err.Error()}, nil
+	}
 	err = fs.filer.DeleteEntryMetaAndData(ctx, util.JoinPath(req.Directory, req.Name), req.IsRecursive, req.IgnoreRecursiveError, req.IsDeleteData, req.IsFromOtherCluster, req.Signatures)
 	resp = &filer_pb.DeleteEntryResponse{}
 	if err != nil && err != filer_pb.ErrNotFound {
@@ -333,7 +414,22 @@ func (fs *FilerServer) DeleteEntry(ctx context.Context, req *filer_pb.DeleteEntr
 
 func (fs *FilerServer) AssignVolume(ctx context.Context, req *filer_pb.AssignVolumeRequest) (resp *filer_pb.AssignVolumeResponse, err error) {
 
+	if err = fs.filer.CheckClusterWritable(); err != nil {
+		glog.V(3).Infof("AssignVolume %s: %v", req.Path, err)
+		return &filer_pb.AssignVolumeResponse{Error: err.Error()}, nil
+	}
+	if req.Path != "" {
+		if err = fs.filer.CheckQuotasForWrite(util.FullPath(req.Path)); err != nil {
+			glog.V(3).Infof("AssignVolume %s: %v", req.Path, err)
+			return &filer_pb.AssignVolumeResponse{Error: err.Error()}, nil
+		}
+	}
+
 	so := fs.detectStorageOption(req.Path, req.Collection, req.Replication, req.TtlSec, req.DiskType, req.DataCenter, req.Rack)
+	// the content type and file size are not known when assigning for the chunks
+	if err = fs.routeStorageOption(so, req.Path, "", -1, req.Collection, req.Replication, req.DiskType, req.TtlSec); err != nil {
+		return &filer_pb.AssignVolumeResponse{Error: err.Error()}, nil
+	}
 
 	assignRequest, altRequest := so.ToAssignRequests(int(req.Count))
 
@@ -384,6 +480,16 @@ func (fs *FilerServer) DeleteCollection(ctx context.Context, req *filer_pb.Delet
 
 	glog.V(4).Infof("DeleteCollection %v", req)
 
+	// deleting the bucket collection deletes the data of all the files in the bucket
+	if bucketEntry, findErr := fs.filer.FindEntry(ctx, util.NewFullPath(fs.filer.DirBucketsPath, req.GetCollection())); findErr == nil {
+		if err = fs.filer.CheckNotHeld(ctx, bucketEntry); err != nil {
+			return nil, err
+		}
+		if err = fs.filer.CheckNotRetained(ctx, bucketEntry); err != nil {
+			return nil, err
+		}
+	}
+
 	err = fs.filer.MasterClient.WithClient(func(client master_pb.SeaweedClient) error {
 		_, err := client.CollectionDelete(context.Background(), &master_pb.CollectionDeleteRequest{
 			Name: req.GetCollection(),
@@ -436,6 +542,7 @@ func (fs *FilerServer) GetFilerConfiguration(ctx context.Context, req *filer_pb.
 		Signature:          fs.filer.Signature,
 		MetricsAddress:     fs.metricsAddress,
 		MetricsIntervalSec: int32(fs.metricsIntervalSec),
+		CompressionPolicy:  filer_pb.ToPbCompressionPolicy(util.GetCompressionPolicy()),
 	}
 
 	glog.V(4).Infof("GetFilerConfiguration: %v", t)
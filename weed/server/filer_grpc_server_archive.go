@@ -0,0 +1,181 @@
+package weed_server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// ArchiveEntry copies the entry content to the archive collection, and marks the entry as archived.
+func (fs *FilerServer) ArchiveEntry(ctx context.Context, req *filer_pb.ArchiveEntryRequest) (*filer_pb.ArchiveEntryResponse, error) {
+
+	fullpath := util.NewFullPath(req.Directory, req.Name)
+	entry, err := fs.filer.FindEntry(ctx, fullpath)
+	if err != nil {
+		return &filer_pb.ArchiveEntryResponse{Error: err.Error()}, nil
+	}
+	if entry.IsDirectory() {
+		return &filer_pb.ArchiveEntryResponse{Error: fmt.Sprintf("%s is a directory", fullpath)}, nil
+	}
+	if filer.IsArchived(entry) {
+		return &filer_pb.ArchiveEntryResponse{}, nil
+	}
+
+	so := fs.detectStorageOption(string(fullpath), "", "", 0, "", "", "")
+	so.Collection = util.Nvl(req.Collection, fs.option.archiveCollection)
+
+	chunks, err := fs.copyEntryChunks(entry, so)
+	if err != nil {
+		return &filer_pb.ArchiveEntryResponse{Error: fmt.Sprintf("archive %s: %v", fullpath, err)}, nil
+	}
+
+	if err = fs.filer.CompleteArchive(ctx, entry, chunks); err != nil {
+		fs.filer.DeleteChunks(chunks)
+		return &filer_pb.ArchiveEntryResponse{Error: err.Error()}, nil
+	}
+
+	glog.V(1).Infof("archived %s to collection %s", fullpath, so.Collection)
+
+	return &filer_pb.ArchiveEntryResponse{}, nil
+}
+
+// RestoreEntry starts restoring an archived entry for some days, or extends an existing restore.
+func (fs *FilerServer) RestoreEntry(ctx context.Context, req *filer_pb.RestoreEntryRequest) (*filer_pb.RestoreEntryResponse, error) {
+
+	fullpath := util.NewFullPath(req.Directory, req.Name)
+	entry, err := fs.filer.FindEntry(ctx, fullpath)
+	if err != nil {
+		return &filer_pb.RestoreEntryResponse{Error: err.Error()}, nil
+	}
+	if !filer.IsArchived(entry) {
+		return &filer_pb.RestoreEntryResponse{Error: fmt.Sprintf("%s: %v", fullpath, filer.ErrEntryNotArchived)}, nil
+	}
+
+	days := int(req.Days)
+	if days <= 0 {
+		days = 1
+	}
+
+	if filer.IsRestored(entry, time.Now()) {
+		if err = fs.filer.ExtendRestore(ctx, entry, filer.RestoreExpiryAfterDays(time.Now(), days)); err != nil {
+			return &filer_pb.RestoreEntryResponse{Error: err.Error()}, nil
+		}
+		return &filer_pb.RestoreEntryResponse{IsRestored: true}, nil
+	}
+	if filer.IsRestoring(entry) {
+		return &filer_pb.RestoreEntryResponse{IsOngoing: true}, nil
+	}
+
+	if err = fs.filer.MarkRestoring(ctx, entry); err != nil {
+		return &filer_pb.RestoreEntryResponse{Error: err.Error()}, nil
+	}
+
+	go fs.restoreEntry(fullpath, entry.Chunks, entry.Extended, days)
+
+	return &filer_pb.RestoreEntryResponse{}, nil
+}
+
+func (fs *FilerServer) restoreEntry(fullpath util.FullPath, archivedChunks []*filer_pb.FileChunk, extended map[string][]byte, days int) {
+
+	ctx := context.Background()
+
+	so := fs.detectStorageOption(string(fullpath), "", "", 0, "", "", "")
+	restoredChunks, err := fs.copyEntryChunks(&filer.Entry{FullPath: fullpath, Chunks: archivedChunks, Extended: extended}, so)
+
+	entry, findErr := fs.filer.FindEntry(ctx, fullpath)
+	if findErr != nil {
+		fs.filer.DeleteChunks(restoredChunks)
+		glog.Errorf("restore %s: %v", fullpath, findErr)
+		return
+	}
+	if err == nil {
+		err = fs.filer.CompleteRestore(ctx, entry, archivedChunks, restoredChunks, filer.RestoreExpiryAfterDays(time.Now(), days))
+	}
+	if err != nil {
+		glog.Errorf("restore %s: %v", fullpath, err)
+		fs.filer.DeleteChunks(restoredChunks)
+		if cancelErr := fs.filer.CancelRestore(ctx, entry); cancelErr != nil {
+			glog.Errorf("%v", cancelErr)
+		}
+		return
+	}
+
+	glog.V(1).Infof("restored %s for %d days", fullpath, days)
+}
+
+// copyEntryChunks writes the entry content as new chunks with the storage option.
+// The server side encrypted content is read decrypted, and the new chunks are encrypted with the same data key.
+// The content encrypted with the customer key can not be copied without the key.
+func (fs *FilerServer) copyEntryChunks(entry *filer.Entry, so *operation.StorageOption) (chunks []*filer_pb.FileChunk, err error) {
+
+	if filer.IsSseCustomerEntry(entry) {
+		return nil, filer.ErrSseCustomerKeyRequired
+	}
+	decrypted, err := fs.filer.DecryptSseEntry(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	var reader io.Reader
+	if len(entry.Content) > 0 {
+		reader = bytes.NewReader(entry.Content)
+	} else {
+		chunkReader := filer.NewChunkStreamReaderFromFiler(fs.filer.MasterClient, decrypted.Chunks)
+		defer chunkReader.Close()
+		reader = chunkReader
+	}
+
+	if !filer.IsSseEntry(entry) {
+		return fs.saveReaderAsChunks(reader, entry.FullPath.Name(), so, fs.option.Cipher)
+	}
+	if chunks, err = fs.saveReaderAsChunks(reader, entry.FullPath.Name(), so, true); err != nil {
+		return nil, err
+	}
+	if err = fs.filer.EncryptSseEntryChunks(entry, chunks); err != nil {
+		fs.filer.DeleteChunks(chunks)
+		return nil, err
+	}
+	return chunks, nil
+}
+
+// saveReaderAsChunks writes the content of the reader as chunks with the storage option.
+func (fs *FilerServer) saveReaderAsChunks(reader io.Reader, name string, so *operation.StorageOption, cipher bool) (chunks []*filer_pb.FileChunk, err error) {
+
+	saveAsChunk := fs.saveAsChunk(so, cipher)
+	chunkSize := int64(fs.option.MaxMB) * 1024 * 1024
+	for offset := int64(0); ; offset += chunkSize {
+		data, readErr := ioutil.ReadAll(io.LimitReader(reader, chunkSize))
+		if readErr != nil {
+			fs.filer.DeleteChunks(chunks)
+			return nil, fmt.Errorf("read at %d: %v", offset, readErr)
+		}
+		if len(data) == 0 {
+			break
+		}
+		chunk, _, _, saveErr := saveAsChunk(bytes.NewReader(data), name, offset)
+		if saveErr != nil {
+			fs.filer.DeleteChunks(chunks)
+			return nil, fmt.Errorf("save at %d: %v", offset, saveErr)
+		}
+		chunks = append(chunks, chunk)
+		if int64(len(data)) < chunkSize {
+			break
+		}
+	}
+
+	manifestized, err := filer.MaybeManifestize(saveAsChunk, chunks)
+	if err != nil {
+		fs.filer.DeleteChunks(chunks)
+		return nil, err
+	}
+	return manifestized, nil
+}
@@ -0,0 +1,55 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+)
+
+// MaxBatchEntries limits the number of entries written by one CreateEntries or UpdateEntries request
+const MaxBatchEntries = 1024
+
+// CreateEntries creates the entries one by one in the request order, saving the per entry rpc overhead.
+// One failed entry does not stop the rest, and its error is in the response at the same index.
+func (fs *FilerServer) CreateEntries(ctx context.Context, req *filer_pb.CreateEntriesRequest) (*filer_pb.CreateEntriesResponse, error) {
+
+	if len(req.Requests) > MaxBatchEntries {
+		return nil, fmt.Errorf("CreateEntries: %d entries exceed the limit %d", len(req.Requests), MaxBatchEntries)
+	}
+
+	glog.V(4).Infof("CreateEntries %d entries", len(req.Requests))
+
+	resp := &filer_pb.CreateEntriesResponse{}
+	for _, request := range req.Requests {
+		createResp, err := fs.CreateEntry(ctx, request)
+		if err != nil {
+			createResp = &filer_pb.CreateEntryResponse{Error: err.Error()}
+		}
+		resp.Responses = append(resp.Responses, createResp)
+	}
+
+	return resp, nil
+}
+
+// UpdateEntries updates the entries one by one in the request order, the same as UpdateEntry for each.
+func (fs *FilerServer) UpdateEntries(ctx context.Context, req *filer_pb.UpdateEntriesRequest) (*filer_pb.UpdateEntriesResponse, error) {
+
+	if len(req.Requests) > MaxBatchEntries {
+		return nil, fmt.Errorf("UpdateEntries: %d entries exceed the limit %d", len(req.Requests), MaxBatchEntries)
+	}
+
+	glog.V(4).Infof("UpdateEntries %d entries", len(req.Requests))
+
+	resp := &filer_pb.UpdateEntriesResponse{}
+	for _, request := range req.Requests {
+		var updateErr string
+		if _, err := fs.UpdateEntry(ctx, request); err != nil {
+			updateErr = err.Error()
+		}
+		resp.Errors = append(resp.Errors, updateErr)
+	}
+
+	return resp, nil
+}
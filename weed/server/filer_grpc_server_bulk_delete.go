@@ -0,0 +1,46 @@
+package weed_server
+
+import (
+	"context"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// BulkDelete starts a server side job to delete many entries, and returns the job id to track the progress.
+func (fs *FilerServer) BulkDelete(ctx context.Context, req *filer_pb.BulkDeleteRequest) (*filer_pb.BulkDeleteResponse, error) {
+
+	var paths []util.FullPath
+	for _, p := range req.Paths {
+		paths = append(paths, util.FullPath(p))
+	}
+
+	job, err := fs.filer.StartDeleteJob(util.FullPath(req.Directory), req.NamePrefix, paths, req.IsRecursive, req.IsDeleteData, req.EntriesPerSecond)
+	if err != nil {
+		return &filer_pb.BulkDeleteResponse{Error: err.Error()}, nil
+	}
+
+	glog.V(1).Infof("bulk delete job %s: %s prefix %s, %d paths", job.Status().JobId, req.Directory, req.NamePrefix, len(req.Paths))
+
+	return &filer_pb.BulkDeleteResponse{JobId: job.Status().JobId}, nil
+}
+
+func (fs *FilerServer) GetBulkDeleteJob(ctx context.Context, req *filer_pb.GetBulkDeleteJobRequest) (*filer_pb.GetBulkDeleteJobResponse, error) {
+
+	job, found := fs.filer.FindDeleteJob(req.JobId)
+	if !found {
+		return &filer_pb.GetBulkDeleteJobResponse{Error: "bulk delete job " + req.JobId + " not found"}, nil
+	}
+
+	if req.WaitSeconds > 0 {
+		job.Wait(time.Duration(req.WaitSeconds) * time.Second)
+	}
+
+	return &filer_pb.GetBulkDeleteJobResponse{Job: job.Status()}, nil
+}
+
+func (fs *FilerServer) ListBulkDeleteJobs(ctx context.Context, req *filer_pb.ListBulkDeleteJobsRequest) (*filer_pb.ListBulkDeleteJobsResponse, error) {
+	return &filer_pb.ListBulkDeleteJobsResponse{Jobs: fs.filer.ListDeleteJobs()}, nil
+}
@@ -0,0 +1,60 @@
+package weed_server
+
+import (
+	"context"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+)
+
+// DedupChunks references the stored chunks by their content, or registers the chunks uploaded by the client,
+// with the same storage options as AssignVolume, so the chunks are deduped in the collection they are assigned to.
+// The references are counted on the lock filer, so the request is forwarded to it.
+func (fs *FilerServer) DedupChunks(ctx context.Context, req *filer_pb.DedupChunksRequest) (resp *filer_pb.DedupChunksResponse, err error) {
+
+	if lockFiler, findErr := fs.findLockFiler(ctx); findErr != nil {
+		return &filer_pb.DedupChunksResponse{Error: findErr.Error()}, nil
+	} else if lockFiler != "" {
+		err = fs.withLockFiler(ctx, lockFiler, func(ctx context.Context, client filer_pb.SeaweedFilerClient) (err error) {
+			resp, err = client.DedupChunks(ctx, req)
+			return err
+		})
+		return
+	}
+
+	resp = &filer_pb.DedupChunksResponse{}
+
+	so := fs.detectStorageOption(req.Path, req.Collection, req.Replication, 0, req.DiskType, "", "")
+	if err := fs.routeStorageOption(so, req.Path, "", -1, req.Collection, req.Replication, req.DiskType, 0); err != nil {
+		resp.Error = err.Error()
+		return resp, nil
+	}
+
+	for _, dedupChunk := range req.Chunks {
+		fingerprint := filer.DedupFingerprint(dedupChunk.ContentSha256, so.Collection, so.Replication, so.DiskType)
+		chunk, err := fs.filer.ReferenceDedupChunk(ctx, fingerprint, dedupChunk.ContentSha256, dedupChunk.Chunk, dedupChunk.Chunk.GetOffset())
+		if err != nil {
+			glog.V(0).Infof("DedupChunks %s: %v", req.Path, err)
+			resp.Error = err.Error()
+			return resp, nil
+		}
+		resp.Chunks = append(resp.Chunks, &filer_pb.DedupChunk{
+			ContentSha256: dedupChunk.ContentSha256,
+			Chunk:         chunk,
+		})
+	}
+
+	return resp, nil
+}
+
+// ReleaseDedupChunks releases the deduped chunks deleted on the other filers, and deletes the ones not referenced any more.
+func (fs *FilerServer) ReleaseDedupChunks(ctx context.Context, req *filer_pb.ReleaseDedupChunksRequest) (*filer_pb.ReleaseDedupChunksResponse, error) {
+
+	if err := fs.filer.ReleaseDedupChunks(req.Chunks); err != nil {
+		glog.V(0).Infof("ReleaseDedupChunks %d chunks: %v", len(req.Chunks), err)
+		return &filer_pb.ReleaseDedupChunksResponse{Error: err.Error()}, nil
+	}
+
+	return &filer_pb.ReleaseDedupChunksResponse{}, nil
+}
@@ -32,7 +32,7 @@ func (fs *FilerServer) KvPut(ctx context.Context, req *filer_pb.KvPutRequest) (*
 		}
 	}
 
-	err := fs.filer.Store.KvPut(ctx, req.Key, req.Value)
+	err := fs.filer.KvPutRegistered(ctx, req.Key, req.Value)
 	if err != nil {
 		return &filer_pb.KvPutResponse{Error: err.Error()}, nil
 	}
@@ -0,0 +1,117 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// set on the lock requests forwarded to the lock filer, so they are not forwarded again
+const lockForwardedMetadata = "x-seaweedfs-lock-forwarded"
+
+// Lock acquires or renews a path-scoped lock.
+func (fs *FilerServer) Lock(ctx context.Context, req *filer_pb.LockRequest) (resp *filer_pb.LockResponse, err error) {
+
+	if lockFiler, findErr := fs.findLockFiler(ctx); findErr != nil {
+		return &filer_pb.LockResponse{Error: findErr.Error()}, nil
+	} else if lockFiler != "" {
+		err = fs.withLockFiler(ctx, lockFiler, func(ctx context.Context, client filer_pb.SeaweedFilerClient) (err error) {
+			resp, err = client.Lock(ctx, req)
+			return err
+		})
+		return
+	}
+
+	lock, err := fs.filer.LockManager.Lock(util.FullPath(req.Path), req.Owner, req.RenewToken, time.Duration(req.SecondsToLock)*time.Second, req.IsShared, req.IsRecursive)
+	if err != nil {
+		glog.V(3).Infof("lock %s by %s: %v", req.Path, req.Owner, err)
+		resp := &filer_pb.LockResponse{Error: err.Error()}
+		if lock != nil {
+			resp.LockOwner, resp.ExpireAtNs = lock.Owner, lock.ExpireAtNs
+		}
+		return resp, nil
+	}
+
+	return &filer_pb.LockResponse{
+		RenewToken: lock.Token,
+		LockOwner:  lock.Owner,
+		ExpireAtNs: lock.ExpireAtNs,
+	}, nil
+}
+
+// Unlock releases a path-scoped lock held with the renew token.
+func (fs *FilerServer) Unlock(ctx context.Context, req *filer_pb.UnlockRequest) (resp *filer_pb.UnlockResponse, err error) {
+
+	if lockFiler, findErr := fs.findLockFiler(ctx); findErr != nil {
+		return &filer_pb.UnlockResponse{Error: findErr.Error()}, nil
+	} else if lockFiler != "" {
+		err = fs.withLockFiler(ctx, lockFiler, func(ctx context.Context, client filer_pb.SeaweedFilerClient) (err error) {
+			resp, err = client.Unlock(ctx, req)
+			return err
+		})
+		return
+	}
+
+	if err := fs.filer.LockManager.Unlock(util.FullPath(req.Path), req.RenewToken); err != nil {
+		return &filer_pb.UnlockResponse{Error: err.Error()}, nil
+	}
+
+	return &filer_pb.UnlockResponse{}, nil
+}
+
+func (fs *FilerServer) FindLockOwner(ctx context.Context, req *filer_pb.FindLockOwnerRequest) (resp *filer_pb.FindLockOwnerResponse, err error) {
+
+	if lockFiler, findErr := fs.findLockFiler(ctx); findErr != nil {
+		return nil, findErr
+	} else if lockFiler != "" {
+		err = fs.withLockFiler(ctx, lockFiler, func(ctx context.Context, client filer_pb.SeaweedFilerClient) (err error) {
+			resp, err = client.FindLockOwner(ctx, req)
+			return err
+		})
+		return
+	}
+
+	lock, found := fs.filer.LockManager.FindLock(util.FullPath(req.Path))
+	if !found {
+		return &filer_pb.FindLockOwnerResponse{}, nil
+	}
+
+	return &filer_pb.FindLockOwnerResponse{
+		Owner:      lock.Owner,
+		ExpireAtNs: lock.ExpireAtNs,
+		IsShared:   lock.IsShared,
+	}, nil
+}
+
+// findLockFiler returns the lock filer to forward the lock request to, or empty if this filer is the lock filer
+func (fs *FilerServer) findLockFiler(ctx context.Context) (string, error) {
+	if fs.filer.IsLockFiler() {
+		return "", nil
+	}
+	if md, _ := metadata.FromIncomingContext(ctx); len(md.Get(lockForwardedMetadata)) > 0 {
+		// forwarded by a filer which has not seen the lock filer change yet
+		return "", filer.ErrNoLockFiler
+	}
+	lockFiler, err := fs.filer.FindLockFiler(ctx)
+	if err != nil {
+		return "", err
+	}
+	if lockFiler == fmt.Sprintf("%s:%d", fs.option.Host, fs.option.Port) {
+		return "", filer.ErrNoLockFiler
+	}
+	return lockFiler, nil
+}
+
+func (fs *FilerServer) withLockFiler(ctx context.Context, lockFiler string, fn func(ctx context.Context, client filer_pb.SeaweedFilerClient) error) error {
+	return pb.WithFilerClient(lockFiler, fs.grpcDialOption, func(client filer_pb.SeaweedFilerClient) error {
+		return fn(metadata.AppendToOutgoingContext(ctx, lockForwardedMetadata, "true"), client)
+	})
+}
@@ -0,0 +1,51 @@
+package weed_server
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	leveldb2 "github.com/chrislusf/seaweedfs/weed/filer/leveldb2"
+)
+
+func TestFindLockFiler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "seaweedfs_lock_filer")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	store := &leveldb2.LevelDB2Store{}
+	configuration := viper.New()
+	configuration.Set("dir", dir)
+	assert.Nil(t, store.Initialize(configuration, ""))
+	defer store.Shutdown()
+
+	fs := &FilerServer{option: &FilerOption{Host: "localhost", Port: 8888}, filer: &filer.Filer{Store: filer.NewFilerStoreWrapper(store)}}
+	ctx := context.Background()
+
+	// no lock filer elected yet
+	_, err = fs.findLockFiler(ctx)
+	assert.Equal(t, filer.ErrNoLockFiler, err)
+	resp, err := fs.Lock(ctx, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, filer.ErrNoLockFiler.Error(), resp.Error)
+
+	// this filer was the lock filer, but lost the lease
+	assert.Nil(t, store.KvPut(ctx, []byte("filer.lock.filer"), []byte("localhost:8888")))
+	_, err = fs.findLockFiler(ctx)
+	assert.Equal(t, filer.ErrNoLockFiler, err)
+
+	assert.Nil(t, store.KvPut(ctx, []byte("filer.lock.filer"), []byte("localhost:8889")))
+	lockFiler, err := fs.findLockFiler(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "localhost:8889", lockFiler)
+
+	// the forwarded requests are not forwarded again
+	forwarded := metadata.NewIncomingContext(ctx, metadata.Pairs(lockForwardedMetadata, "true"))
+	_, err = fs.findLockFiler(forwarded)
+	assert.Equal(t, filer.ErrNoLockFiler, err)
+}
@@ -0,0 +1,77 @@
+package weed_server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/spf13/viper"
+)
+
+// MigrateFilerStore copies the filer store to the store configured in the request, while streaming the progress.
+// The migration stops if the client disconnects before the switch.
+func (fs *FilerServer) MigrateFilerStore(req *filer_pb.MigrateFilerStoreRequest, stream filer_pb.SeaweedFiler_MigrateFilerStoreServer) error {
+
+	v := viper.New()
+	v.SetConfigType("toml")
+	if err := v.ReadConfig(strings.NewReader(req.StoreConfig)); err != nil {
+		return fmt.Errorf("parse store config: %v", err)
+	}
+	v.SetDefault("filer.options.buckets_folder", fs.filer.DirBucketsPath)
+
+	target, err := filer.LoadStore(v)
+	if err != nil {
+		return err
+	}
+
+	currentStore := fs.filer.Store.GetName()
+	glog.V(0).Infof("migrate filer store %s to %s, switch: %v", currentStore, target.GetName(), req.SwitchStore)
+
+	persistSwitch := func() error {
+		return persistFilerStore(fs.filerConfigFile, currentStore, req.StoreConfig)
+	}
+	return fs.filer.MigrateStore(stream.Context(), target, req.SwitchStore, persistSwitch, func(progress filer.StoreMigrationProgress) error {
+		return stream.Send(&filer_pb.MigrateFilerStoreResponse{
+			Phase:          progress.Phase,
+			TargetStore:    target.GetName(),
+			CopiedEntries:  progress.CopiedEntries,
+			CopiedKeys:     progress.CopiedKeys,
+			PendingChanges: progress.PendingChanges,
+		})
+	})
+}
+
+// persistFilerStore rewrites filer.toml with the target store enabled instead of the current store,
+// so the filer restarts with the target store. The old filer.toml is kept as filer.toml.bak.
+func persistFilerStore(configFile, currentStore, storeConfig string) error {
+	if configFile == "" {
+		return fmt.Errorf("no filer.toml is loaded to switch the store in")
+	}
+	v := viper.New()
+	v.SetConfigFile(configFile)
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("read %s: %v", configFile, err)
+	}
+	v.Set(currentStore+".enabled", false)
+	if err := v.MergeConfig(strings.NewReader(storeConfig)); err != nil {
+		return fmt.Errorf("merge store config: %v", err)
+	}
+
+	old, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(configFile+".bak", old, 0600); err != nil {
+		return err
+	}
+	// the config type is told by the extension
+	newFile := strings.TrimSuffix(configFile, ".toml") + ".new.toml"
+	if err = v.WriteConfigAs(newFile); err != nil {
+		return fmt.Errorf("write %s: %v", newFile, err)
+	}
+	return os.Rename(newFile, configFile)
+}
@@ -0,0 +1,34 @@
+package weed_server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPersistFilerStore(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "filer_toml")
+	defer os.RemoveAll(dir)
+	configFile := filepath.Join(dir, "filer.toml")
+	old := "[filer.options]\nrecursive_delete = true\n\n[leveldb2]\nenabled = true\ndir = \"./filerldb2\"\n"
+	assert.Nil(t, ioutil.WriteFile(configFile, []byte(old), 0644))
+
+	assert.Nil(t, persistFilerStore(configFile, "leveldb2", "[leveldb3]\nenabled = true\ndir = \"./filerldb3\"\n"))
+
+	v := viper.New()
+	v.SetConfigFile(configFile)
+	assert.Nil(t, v.ReadInConfig())
+	assert.False(t, v.GetBool("leveldb2.enabled"))
+	assert.True(t, v.GetBool("leveldb3.enabled"))
+	assert.Equal(t, "./filerldb3", v.GetString("leveldb3.dir"))
+	assert.True(t, v.GetBool("filer.options.recursive_delete"))
+
+	backup, _ := ioutil.ReadFile(configFile + ".bak")
+	assert.Equal(t, old, string(backup))
+
+	assert.NotNil(t, persistFilerStore("", "leveldb2", "[leveldb3]\nenabled = true\n"))
+}
@@ -0,0 +1,25 @@
+package weed_server
+
+import (
+	"context"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+)
+
+// GetDirectoryQuotas returns the quotas and the usages counted by this filer.
+func (fs *FilerServer) GetDirectoryQuotas(ctx context.Context, req *filer_pb.GetDirectoryQuotasRequest) (*filer_pb.GetDirectoryQuotasResponse, error) {
+
+	resp := &filer_pb.GetDirectoryQuotasResponse{}
+	for _, usage := range fs.filer.GetDirQuotaUsages(req.Directory) {
+		resp.Quotas = append(resp.Quotas, &filer_pb.GetDirectoryQuotasResponse_DirectoryQuota{
+			Directory:    usage.Directory,
+			QuotaBytes:   usage.QuotaBytes,
+			QuotaEntries: usage.QuotaEntries,
+			UsedBytes:    usage.UsedBytes,
+			UsedEntries:  usage.UsedEntries,
+			IsCounted:    usage.IsCounted,
+		})
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,18 @@
+package weed_server
+
+import (
+	"context"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+)
+
+func (fs *FilerServer) ReconcileStatus(ctx context.Context, req *filer_pb.ReconcileStatusRequest) (*filer_pb.ReconcileStatusResponse, error) {
+
+	status := fs.filer.ReconcileStatus()
+
+	return &filer_pb.ReconcileStatusResponse{
+		IsEnabled: status != nil,
+		Status:    status,
+	}, nil
+
+}
@@ -0,0 +1,92 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/remote_storage"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// CacheRemoteObjectToLocalCluster fetches the content of a remote entry into the local cluster.
+func (fs *FilerServer) CacheRemoteObjectToLocalCluster(ctx context.Context, req *filer_pb.CacheRemoteObjectToLocalClusterRequest) (*filer_pb.CacheRemoteObjectToLocalClusterResponse, error) {
+
+	fullpath := util.NewFullPath(req.Directory, req.Name)
+	entry, err := fs.filer.FindEntry(ctx, fullpath)
+	if err != nil {
+		return &filer_pb.CacheRemoteObjectToLocalClusterResponse{Error: err.Error()}, nil
+	}
+	if filer.RemoteEntryOf(entry) == nil {
+		return &filer_pb.CacheRemoteObjectToLocalClusterResponse{Error: fmt.Sprintf("%s is not from the remote storage", fullpath)}, nil
+	}
+
+	cached, err := fs.cacheRemoteEntry(ctx, entry)
+	if err != nil {
+		return &filer_pb.CacheRemoteObjectToLocalClusterResponse{Error: err.Error()}, nil
+	}
+
+	return &filer_pb.CacheRemoteObjectToLocalClusterResponse{Entry: cached.ToProtoEntry()}, nil
+}
+
+// UncacheRemoteObject drops the locally cached content of a remote entry.
+func (fs *FilerServer) UncacheRemoteObject(ctx context.Context, req *filer_pb.UncacheRemoteObjectRequest) (*filer_pb.UncacheRemoteObjectResponse, error) {
+
+	fullpath := util.NewFullPath(req.Directory, req.Name)
+	entry, err := fs.filer.FindEntry(ctx, fullpath)
+	if err != nil {
+		return &filer_pb.UncacheRemoteObjectResponse{Error: err.Error()}, nil
+	}
+
+	if err = fs.filer.UncacheRemoteEntry(ctx, entry); err != nil {
+		return &filer_pb.UncacheRemoteObjectResponse{Error: err.Error()}, nil
+	}
+
+	return &filer_pb.UncacheRemoteObjectResponse{}, nil
+}
+
+// cacheRemoteEntry copies the remote object content as chunks, unless it is already cached.
+// Concurrent requests for the same entry share one copy.
+func (fs *FilerServer) cacheRemoteEntry(ctx context.Context, entry *filer.Entry) (*filer.Entry, error) {
+	if !filer.IsRemoteOnly(entry) {
+		return entry, nil
+	}
+
+	cached, err := fs.remoteCacheGroup.Do(string(entry.FullPath), func() (interface{}, error) {
+		conf, loc, err := fs.filer.RemoteLocation(entry.FullPath)
+		if err != nil {
+			return nil, err
+		}
+		client, err := remote_storage.GetRemoteStorage(conf)
+		if err != nil {
+			return nil, err
+		}
+		reader, err := client.ReadFile(loc)
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+
+		so := fs.detectStorageOption(string(entry.FullPath), "", "", 0, "", "", "")
+		checksumHash := filer.NewChecksumHash()
+		chunks, err := fs.saveReaderAsChunks(io.TeeReader(reader, checksumHash), entry.Name(), so, fs.option.Cipher)
+		if err != nil {
+			return nil, fmt.Errorf("cache %s: %v", entry.FullPath, err)
+		}
+
+		cached, err := fs.filer.CompleteRemoteCache(ctx, entry, chunks, checksumHash.Sum(nil))
+		if err != nil {
+			fs.filer.DeleteChunks(chunks)
+			return nil, err
+		}
+		glog.V(1).Infof("cached %s from %s", entry.FullPath, remote_storage.FormatLocation(loc))
+		return cached, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cached.(*filer.Entry), nil
+}
@@ -21,8 +21,31 @@ func (fs *FilerServer) AtomicRenameEntry(ctx context.Context, req *filer_pb.Atom
 	if err := fs.filer.CanRename(oldParent, newParent); err != nil {
 		return nil, err
 	}
+	if err := fs.checkGrpcPermission(ctx, oldParent.Child(req.OldName), aclDelete); err != nil {
+		return nil, err
+	}
+	if err := fs.checkGrpcPermission(ctx, newParent.Child(req.NewName), aclDelete); err != nil {
+		return nil, err
+	}
+
+	// the entries moved one by one are sharded the same way under the new path
+	confCtx := ctx
+	oldShards, newShards, err := fs.filer.CopyDirShards(confCtx, oldParent.Child(req.OldName), newParent.Child(req.NewName))
+	if err != nil {
+		return nil, err
+	}
+	isRenamed := false
+	defer func() {
+		deletedShards := newShards
+		if isRenamed {
+			deletedShards = oldShards
+		}
+		if deleteErr := fs.filer.DeleteDirShards(confCtx, deletedShards); deleteErr != nil {
+			glog.Errorf("rename %s/%s: %v", req.OldDirectory, req.OldName, deleteErr)
+		}
+	}()
 
-	ctx, err := fs.filer.BeginTransaction(ctx)
+	ctx, err = fs.filer.BeginTransaction(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -33,7 +56,23 @@ func (fs *FilerServer) AtomicRenameEntry(ctx context.Context, req *filer_pb.Atom
 		return nil, fmt.Errorf("%s/%s not found: %v", req.OldDirectory, req.OldName, err)
 	}
 
-	moveErr := fs.moveEntry(ctx, oldParent, oldEntry, newParent, req.NewName)
+	if err = fs.filer.CheckNotHeld(ctx, oldEntry); err != nil {
+		fs.filer.RollbackTransaction(ctx)
+		return nil, err
+	}
+	if err = fs.filer.CheckNotRetained(ctx, oldEntry); err != nil {
+		fs.filer.RollbackTransaction(ctx)
+		return nil, err
+	}
+
+	// rename the directory in the filer store if possible, otherwise move the entries one by one
+	moveErr := filer.ErrUnsupportedDirectoryRename
+	if oldEntry.IsDirectory() {
+		moveErr = fs.filer.MoveDirectoryInStore(ctx, oldEntry, newParent.Child(req.NewName), req.Signatures)
+	}
+	if moveErr == filer.ErrUnsupportedDirectoryRename {
+		moveErr = fs.moveEntry(ctx, oldParent, oldEntry, newParent, req.NewName)
+	}
 	if moveErr != nil {
 		fs.filer.RollbackTransaction(ctx)
 		return nil, fmt.Errorf("%s/%s move error: %v", req.OldDirectory, req.OldName, moveErr)
@@ -43,6 +82,7 @@ func (fs *FilerServer) AtomicRenameEntry(ctx context.Context, req *filer_pb.Atom
 			return nil, fmt.Errorf("%s/%s move commit error: %v", req.OldDirectory, req.OldName, commitError)
 		}
 	}
+	isRenamed = true
 
 	return &filer_pb.AtomicRenameEntryResponse{}, nil
 }
@@ -115,7 +155,7 @@ func (fs *FilerServer) moveSelfEntry(ctx context.Context, oldParent util.FullPat
 		Extended: entry.Extended,
 		Content:  entry.Content,
 	}
-	createErr := fs.filer.CreateEntry(ctx, newEntry, false, false, nil)
+	createErr := fs.filer.CreateEntry(filer.WithMovingFrom(ctx, oldPath), newEntry, false, false, nil)
 	if createErr != nil {
 		return createErr
 	}
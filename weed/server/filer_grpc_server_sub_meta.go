@@ -3,15 +3,12 @@ package weed_server
 import (
 	"fmt"
 	"github.com/chrislusf/seaweedfs/weed/util/log_buffer"
-	"strings"
 	"time"
 
 	"github.com/golang/protobuf/proto"
 
-	"github.com/chrislusf/seaweedfs/weed/filer"
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
-	"github.com/chrislusf/seaweedfs/weed/util"
 )
 
 func (fs *FilerServer) SubscribeMetadata(req *filer_pb.SubscribeMetadataRequest, stream filer_pb.SeaweedFiler_SubscribeMetadataServer) error {
@@ -25,12 +22,16 @@ func (fs *FilerServer) SubscribeMetadata(req *filer_pb.SubscribeMetadataRequest,
 	lastReadTime := time.Unix(0, req.SinceNs)
 	glog.V(0).Infof(" %v starts to subscribe %s from %+v", clientName, req.PathPrefix, lastReadTime)
 
-	eachEventNotificationFn := fs.eachEventNotificationFn(req, stream, clientName, req.Signature)
+	metaFilter, err := newMetadataFilter(req)
+	if err != nil {
+		return err
+	}
+
+	eachEventNotificationFn := fs.eachEventNotificationFn(metaFilter, stream, clientName, req.Signature)
 
 	eachLogEntryFn := eachLogEntryFn(eachEventNotificationFn)
 
 	var processedTsNs int64
-	var err error
 
 	for {
 
@@ -76,12 +77,16 @@ func (fs *FilerServer) SubscribeLocalMetadata(req *filer_pb.SubscribeMetadataReq
 	lastReadTime := time.Unix(0, req.SinceNs)
 	glog.V(0).Infof(" %v local subscribe %s from %+v", clientName, req.PathPrefix, lastReadTime)
 
-	eachEventNotificationFn := fs.eachEventNotificationFn(req, stream, clientName, req.Signature)
+	metaFilter, err := newMetadataFilter(req)
+	if err != nil {
+		return err
+	}
+
+	eachEventNotificationFn := fs.eachEventNotificationFn(metaFilter, stream, clientName, req.Signature)
 
 	eachLogEntryFn := eachLogEntryFn(eachEventNotificationFn)
 
 	var processedTsNs int64
-	var err error
 
 	for {
 		// println("reading from persisted logs ...")
@@ -135,7 +140,7 @@ func eachLogEntryFn(eachEventNotificationFn func(dirPath string, eventNotificati
 	}
 }
 
-func (fs *FilerServer) eachEventNotificationFn(req *filer_pb.SubscribeMetadataRequest, stream filer_pb.SeaweedFiler_SubscribeMetadataServer, clientName string, clientSignature int32) func(dirPath string, eventNotification *filer_pb.EventNotification, tsNs int64) error {
+func (fs *FilerServer) eachEventNotificationFn(metaFilter *metadataFilter, stream filer_pb.SeaweedFiler_SubscribeMetadataServer, clientName string, clientSignature int32) func(dirPath string, eventNotification *filer_pb.EventNotification, tsNs int64) error {
 	return func(dirPath string, eventNotification *filer_pb.EventNotification, tsNs int64) error {
 
 		foundSelf := false
@@ -151,38 +156,14 @@ func (fs *FilerServer) eachEventNotificationFn(req *filer_pb.SubscribeMetadataRe
 			eventNotification.Signatures = append(eventNotification.Signatures, fs.filer.Signature)
 		}
 
-		// get complete path to the file or directory
-		var entryName string
-		if eventNotification.OldEntry != nil {
-			entryName = eventNotification.OldEntry.Name
-		} else if eventNotification.NewEntry != nil {
-			entryName = eventNotification.NewEntry.Name
-		}
-
-		fullpath := util.Join(dirPath, entryName)
-
-		// skip on filer internal meta logs
-		if strings.HasPrefix(fullpath, filer.SystemLogDir) {
-			return nil
-		}
-
-		if !strings.HasPrefix(fullpath, req.PathPrefix) {
-			if eventNotification.NewParentPath != "" {
-				newFullPath := util.Join(eventNotification.NewParentPath, entryName)
-				if !strings.HasPrefix(newFullPath, req.PathPrefix) {
-					return nil
-				}
-			} else {
-				return nil
-			}
-		}
-
 		message := &filer_pb.SubscribeMetadataResponse{
 			Directory:         dirPath,
 			EventNotification: eventNotification,
 			TsNs:              tsNs,
 		}
-		// println("sending", dirPath, entryName)
+		if !metaFilter.isMatched(message) {
+			return nil
+		}
 		if err := stream.Send(message); err != nil {
 			glog.V(0).Infof("=> client %v: %+v", clientName, err)
 			return err
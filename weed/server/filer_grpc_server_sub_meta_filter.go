@@ -0,0 +1,141 @@
+package weed_server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+const (
+	MetadataEventCreate = "create"
+	MetadataEventUpdate = "update"
+	MetadataEventDelete = "delete"
+	MetadataEventRename = "rename"
+)
+
+// metadataFilter selects the events to send to a subscriber.
+// The path filters match either the old or the new path of an event,
+// and the attribute filters match either the old or the new entry.
+type metadataFilter struct {
+	pathPrefixes        []string
+	excludePathPrefixes []string
+	eventTypes          map[string]bool
+	minFileSize         uint64
+	maxFileSize         uint64
+	excludeDirectories  bool
+}
+
+func newMetadataFilter(req *filer_pb.SubscribeMetadataRequest) (*metadataFilter, error) {
+	f := &metadataFilter{
+		excludePathPrefixes: req.ExcludePathPrefixes,
+		excludeDirectories:  req.ExcludeDirectories,
+	}
+	if req.PathPrefix != "" || len(req.PathPrefixes) == 0 {
+		f.pathPrefixes = append(f.pathPrefixes, req.PathPrefix)
+	}
+	f.pathPrefixes = append(f.pathPrefixes, req.PathPrefixes...)
+	for _, eventType := range req.EventTypes {
+		switch eventType {
+		case MetadataEventCreate, MetadataEventUpdate, MetadataEventDelete, MetadataEventRename:
+		default:
+			return nil, fmt.Errorf("unknown event type %s", eventType)
+		}
+		if f.eventTypes == nil {
+			f.eventTypes = make(map[string]bool)
+		}
+		f.eventTypes[eventType] = true
+	}
+	if req.MinFileSize > 0 {
+		f.minFileSize = uint64(req.MinFileSize)
+	}
+	if req.MaxFileSize > 0 {
+		f.maxFileSize = uint64(req.MaxFileSize)
+	}
+	return f, nil
+}
+
+func (f *metadataFilter) isMatched(event *filer_pb.SubscribeMetadataResponse) bool {
+	message := event.EventNotification
+
+	var fullpath, newFullPath string
+	if message.OldEntry != nil {
+		fullpath = util.Join(event.Directory, message.OldEntry.Name)
+	}
+	if message.NewEntry != nil {
+		newParentPath := message.NewParentPath
+		if newParentPath == "" {
+			newParentPath = event.Directory
+		}
+		newFullPath = util.Join(newParentPath, message.NewEntry.Name)
+	}
+	if fullpath == "" {
+		fullpath = newFullPath
+	}
+
+	// skip on filer internal meta logs
+	if strings.HasPrefix(fullpath, filer.SystemLogDir) {
+		return false
+	}
+
+	if !f.isPathMatched(fullpath) && (newFullPath == "" || !f.isPathMatched(newFullPath)) {
+		return false
+	}
+
+	if f.eventTypes != nil && !f.eventTypes[metadataEventType(message, fullpath, newFullPath)] {
+		return false
+	}
+
+	return f.isEntryMatched(message.OldEntry) || f.isEntryMatched(message.NewEntry)
+}
+
+func (f *metadataFilter) isPathMatched(fullpath string) bool {
+	for _, prefix := range f.excludePathPrefixes {
+		if strings.HasPrefix(fullpath, prefix) {
+			return false
+		}
+	}
+	for _, prefix := range f.pathPrefixes {
+		if strings.HasPrefix(fullpath, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isEntryMatched checks the attribute filters. The size limits only apply to the files.
+func (f *metadataFilter) isEntryMatched(entry *filer_pb.Entry) bool {
+	if entry == nil {
+		return false
+	}
+	if entry.IsDirectory {
+		return !f.excludeDirectories
+	}
+	if f.minFileSize == 0 && f.maxFileSize == 0 {
+		return true
+	}
+	size := filer.TotalSize(entry.Chunks)
+	if entry.Attributes != nil {
+		size = filer.FileSize(entry)
+	}
+	if size < f.minFileSize {
+		return false
+	}
+	return f.maxFileSize == 0 || size <= f.maxFileSize
+}
+
+// metadataEventType returns the rename type also for the renames in the same directory
+func metadataEventType(message *filer_pb.EventNotification, fullpath, newFullPath string) string {
+	switch {
+	case message.OldEntry == nil:
+		return MetadataEventCreate
+	case message.NewEntry == nil:
+		return MetadataEventDelete
+	case fullpath != newFullPath:
+		return MetadataEventRename
+	default:
+		return MetadataEventUpdate
+	}
+}
@@ -0,0 +1,62 @@
+package weed_server
+
+import (
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+)
+
+func TestMetadataFilter(t *testing.T) {
+	small := &filer_pb.Entry{Name: "small.txt", Attributes: &filer_pb.FuseAttributes{FileSize: 10}}
+	large := &filer_pb.Entry{Name: "large.bin", Attributes: &filer_pb.FuseAttributes{FileSize: 2 << 20}}
+	dir := &filer_pb.Entry{Name: "sub", IsDirectory: true}
+
+	create := func(dir string, entry *filer_pb.Entry) *filer_pb.SubscribeMetadataResponse {
+		return &filer_pb.SubscribeMetadataResponse{Directory: dir, EventNotification: &filer_pb.EventNotification{NewEntry: entry, NewParentPath: dir}}
+	}
+	rename := func(dir string, entry *filer_pb.Entry, newDir string) *filer_pb.SubscribeMetadataResponse {
+		return &filer_pb.SubscribeMetadataResponse{Directory: dir, EventNotification: &filer_pb.EventNotification{OldEntry: entry, NewEntry: entry, NewParentPath: newDir}}
+	}
+	remove := func(dir string, entry *filer_pb.Entry) *filer_pb.SubscribeMetadataResponse {
+		return &filer_pb.SubscribeMetadataResponse{Directory: dir, EventNotification: &filer_pb.EventNotification{OldEntry: entry}}
+	}
+
+	tests := []struct {
+		name     string
+		req      *filer_pb.SubscribeMetadataRequest
+		event    *filer_pb.SubscribeMetadataResponse
+		expected bool
+	}{
+		{"all", &filer_pb.SubscribeMetadataRequest{}, create("/a", small), true},
+		{"system log", &filer_pb.SubscribeMetadataRequest{}, create("/topics/.system/log", small), false},
+		{"prefix", &filer_pb.SubscribeMetadataRequest{PathPrefix: "/a/"}, create("/b", small), false},
+		{"prefix list", &filer_pb.SubscribeMetadataRequest{PathPrefix: "/a/", PathPrefixes: []string{"/b/"}}, create("/b", small), true},
+		{"only prefix list", &filer_pb.SubscribeMetadataRequest{PathPrefixes: []string{"/b/"}}, create("/a", small), false},
+		{"renamed into prefix", &filer_pb.SubscribeMetadataRequest{PathPrefix: "/a/"}, rename("/b", small, "/a"), true},
+		{"excluded", &filer_pb.SubscribeMetadataRequest{ExcludePathPrefixes: []string{"/a/tmp/"}}, create("/a/tmp", small), false},
+		{"renamed out of excluded", &filer_pb.SubscribeMetadataRequest{ExcludePathPrefixes: []string{"/a/tmp/"}}, rename("/a/tmp", small, "/a"), true},
+		{"event type", &filer_pb.SubscribeMetadataRequest{EventTypes: []string{MetadataEventDelete}}, create("/a", small), false},
+		{"delete type", &filer_pb.SubscribeMetadataRequest{EventTypes: []string{MetadataEventDelete}}, remove("/a", small), true},
+		{"rename in same dir", &filer_pb.SubscribeMetadataRequest{EventTypes: []string{MetadataEventRename}},
+			&filer_pb.SubscribeMetadataResponse{Directory: "/a", EventNotification: &filer_pb.EventNotification{OldEntry: small, NewEntry: large, NewParentPath: "/a"}}, true},
+		{"update type", &filer_pb.SubscribeMetadataRequest{EventTypes: []string{MetadataEventRename}}, rename("/a", small, "/a"), false},
+		{"small file", &filer_pb.SubscribeMetadataRequest{MinFileSize: 1 << 20}, create("/a", small), false},
+		{"large file", &filer_pb.SubscribeMetadataRequest{MinFileSize: 1 << 20}, create("/a", large), true},
+		{"too large file", &filer_pb.SubscribeMetadataRequest{MaxFileSize: 1 << 20}, create("/a", large), false},
+		{"directory with size", &filer_pb.SubscribeMetadataRequest{MinFileSize: 1 << 20}, create("/a", dir), true},
+		{"excluded directory", &filer_pb.SubscribeMetadataRequest{ExcludeDirectories: true}, create("/a", dir), false},
+	}
+	for _, test := range tests {
+		f, err := newMetadataFilter(test.req)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if actual := f.isMatched(test.event); actual != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, actual)
+		}
+	}
+
+	if _, err := newMetadataFilter(&filer_pb.SubscribeMetadataRequest{EventTypes: []string{"modify"}}); err == nil {
+		t.Errorf("expected error for unknown event type")
+	}
+}
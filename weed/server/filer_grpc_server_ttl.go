@@ -0,0 +1,34 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// ExtendEntryTtl restarts the ttl of the entry and its chunks, to keep the hot entries alive.
+func (fs *FilerServer) ExtendEntryTtl(ctx context.Context, req *filer_pb.ExtendEntryTtlRequest) (*filer_pb.ExtendEntryTtlResponse, error) {
+
+	fullpath := util.NewFullPath(req.Directory, req.Name)
+	entry, err := fs.filer.FindEntry(ctx, fullpath)
+	if err != nil {
+		return &filer_pb.ExtendEntryTtlResponse{Error: err.Error()}, nil
+	}
+	if entry.IsDirectory() {
+		return &filer_pb.ExtendEntryTtlResponse{Error: fmt.Sprintf("%s is a directory", fullpath)}, nil
+	}
+
+	expireAt, err := fs.filer.ExtendTtl(ctx, entry, req.TtlSec)
+	if err != nil {
+		return &filer_pb.ExtendEntryTtlResponse{Error: err.Error()}, nil
+	}
+
+	glog.V(3).Infof("extended ttl of %s until %v", fullpath, expireAt)
+
+	return &filer_pb.ExtendEntryTtlResponse{
+		ExpireAtSec: expireAt.Unix(),
+	}, nil
+}
@@ -0,0 +1,46 @@
+package weed_server
+
+import (
+	"context"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// ListEntryVersions returns the prior versions of the file, the latest first.
+func (fs *FilerServer) ListEntryVersions(ctx context.Context, req *filer_pb.ListEntryVersionsRequest) (*filer_pb.ListEntryVersionsResponse, error) {
+
+	resp := &filer_pb.ListEntryVersionsResponse{}
+	versions, err := fs.filer.ListVersions(ctx, util.NewFullPath(req.Directory, req.Name))
+	if err != nil {
+		resp.Error = err.Error()
+		return resp, nil
+	}
+	for _, version := range versions {
+		resp.Versions = append(resp.Versions, &filer_pb.ListEntryVersionsResponse_Version{
+			VersionId:      version.Name(),
+			Entry:          version.ToProtoEntry(),
+			SupersededAtNs: filer.VersionSupersededAt(version).UnixNano(),
+			IsDeleted:      filer.IsDeletedVersion(version),
+		})
+	}
+
+	return resp, nil
+}
+
+// RestoreEntryVersion writes the version back to the file, keeping the current content as a new version.
+func (fs *FilerServer) RestoreEntryVersion(ctx context.Context, req *filer_pb.RestoreEntryVersionRequest) (*filer_pb.RestoreEntryVersionResponse, error) {
+
+	resp := &filer_pb.RestoreEntryVersionResponse{}
+	fullpath := util.NewFullPath(req.Directory, req.Name)
+	copyChunks := func(version *filer.Entry) ([]*filer_pb.FileChunk, error) {
+		so := fs.detectStorageOption(string(fullpath), "", "", 0, "", "", "")
+		return fs.copyEntryChunks(&filer.Entry{FullPath: fullpath, Chunks: version.Chunks, Extended: version.Extended}, so)
+	}
+	if err := fs.filer.RestoreVersion(ctx, fullpath, req.VersionId, copyChunks); err != nil {
+		resp.Error = err.Error()
+	}
+
+	return resp, nil
+}
@@ -12,6 +12,7 @@ import (
 
 	"google.golang.org/grpc"
 
+	"github.com/chrislusf/seaweedfs/weed/util/codec"
 	"github.com/chrislusf/seaweedfs/weed/util/grace"
 
 	"github.com/chrislusf/seaweedfs/weed/operation"
@@ -21,6 +22,7 @@ import (
 
 	"github.com/chrislusf/seaweedfs/weed/filer"
 	_ "github.com/chrislusf/seaweedfs/weed/filer/cassandra"
+	_ "github.com/chrislusf/seaweedfs/weed/filer/cockroachdb"
 	_ "github.com/chrislusf/seaweedfs/weed/filer/elastic/v7"
 	_ "github.com/chrislusf/seaweedfs/weed/filer/etcd"
 	_ "github.com/chrislusf/seaweedfs/weed/filer/hbase"
@@ -35,13 +37,23 @@ import (
 	_ "github.com/chrislusf/seaweedfs/weed/filer/redis"
 	_ "github.com/chrislusf/seaweedfs/weed/filer/redis2"
 	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/kms"
+	_ "github.com/chrislusf/seaweedfs/weed/kms/aws_kms"
+	_ "github.com/chrislusf/seaweedfs/weed/kms/static"
+	_ "github.com/chrislusf/seaweedfs/weed/kms/vault"
 	"github.com/chrislusf/seaweedfs/weed/notification"
 	_ "github.com/chrislusf/seaweedfs/weed/notification/aws_sqs"
 	_ "github.com/chrislusf/seaweedfs/weed/notification/gocdk_pub_sub"
 	_ "github.com/chrislusf/seaweedfs/weed/notification/google_pub_sub"
 	_ "github.com/chrislusf/seaweedfs/weed/notification/kafka"
 	_ "github.com/chrislusf/seaweedfs/weed/notification/log"
+	"github.com/chrislusf/seaweedfs/weed/notification/s3event"
+	_ "github.com/chrislusf/seaweedfs/weed/notification/webhook"
+	_ "github.com/chrislusf/seaweedfs/weed/remote_storage/azure"
+	_ "github.com/chrislusf/seaweedfs/weed/remote_storage/gcs"
+	_ "github.com/chrislusf/seaweedfs/weed/remote_storage/s3"
 	"github.com/chrislusf/seaweedfs/weed/security"
+	"github.com/golang/groupcache/singleflight"
 )
 
 type FilerOption struct {
@@ -58,17 +70,23 @@ type FilerOption struct {
 	Host                  string
 	Port                  uint32
 	recursiveDelete       bool
+	archiveCollection     string
 	Cipher                bool
 	SaveToFilerLimit      int64
 	Filers                []string
 	ConcurrentUploadLimit int64
+	ChunkCodec            string
+	anonymousIdentity     *filer.Identity
+	WhiteList             []string
 }
 
 type FilerServer struct {
-	option         *FilerOption
-	secret         security.SigningKey
-	filer          *filer.Filer
-	grpcDialOption grpc.DialOption
+	option          *FilerOption
+	secret          security.SigningKey
+	guard           *security.Guard
+	filer           *filer.Filer
+	grpcDialOption  grpc.DialOption
+	filerConfigFile string // the loaded filer.toml, to persist the store switch
 
 	// metrics read from the master
 	metricsAddress     string
@@ -83,6 +101,8 @@ type FilerServer struct {
 
 	inFlightDataSize      int64
 	inFlightDataLimitCond *sync.Cond
+
+	remoteCacheGroup singleflight.Group
 }
 
 func NewFilerServer(defaultMux, readonlyMux *http.ServeMux, option *FilerOption) (fs *FilerServer, err error) {
@@ -92,6 +112,7 @@ func NewFilerServer(defaultMux, readonlyMux *http.ServeMux, option *FilerOption)
 		grpcDialOption:        security.LoadClientTLS(util.GetViper(), "grpc.filer"),
 		brokers:               make(map[string]map[string]bool),
 		inFlightDataLimitCond: sync.NewCond(new(sync.Mutex)),
+		guard:                 security.NewGuard(option.WhiteList, "", 0, "", 0),
 	}
 	fs.listenersCond = sync.NewCond(&fs.listenersLock)
 
@@ -120,6 +141,7 @@ func NewFilerServer(defaultMux, readonlyMux *http.ServeMux, option *FilerOption)
 		glog.V(0).Infof("default to create filer store dir in %s", option.DefaultLevelDbDir)
 	} else {
 		glog.Warningf("skipping default store dir in %s", option.DefaultLevelDbDir)
+		fs.filerConfigFile = v.ConfigFileUsed()
 	}
 	util.LoadConfiguration("notification", false)
 
@@ -129,9 +151,76 @@ func NewFilerServer(defaultMux, readonlyMux *http.ServeMux, option *FilerOption)
 	// TODO deprecated, will be be removed after 2020-12-31
 	// replaced by https://github.com/chrislusf/seaweedfs/wiki/Path-Specific-Configuration
 	fs.filer.FsyncBuckets = v.GetStringSlice("filer.options.buckets_fsync")
+	fs.filer.Holds.AdminIdentities = v.GetStringSlice("filer.options.hold_admins")
+	v.SetDefault("filer.options.anonymous_uid", -1)
+	if anonymousUid := v.GetInt64("filer.options.anonymous_uid"); anonymousUid >= 0 {
+		fs.option.anonymousIdentity = &filer.Identity{Uid: uint32(anonymousUid), Gids: []uint32{uint32(anonymousUid)}}
+	}
+	v.SetDefault("filer.options.archive_collection", filer.DefaultArchiveCollection)
+	fs.option.archiveCollection = v.GetString("filer.options.archive_collection")
+	v.SetDefault("filer.options.deletion_files_per_second", filer.DefaultDeletionFilesPerSecond)
+	fs.filer.DeletionFilesPerSecond = int64(v.GetInt("filer.options.deletion_files_per_second"))
+	v.SetDefault("filer.options.bulk_delete_entries_per_second", filer.DefaultDeleteJobEntriesPerSecond)
+	fs.filer.DeleteJobs.EntriesPerSecond = int64(v.GetInt("filer.options.bulk_delete_entries_per_second"))
+	fs.filer.DirEntries.Limit = int64(v.GetInt("filer.options.dir_entries_limit"))
+	v.SetDefault("filer.options.dir_entries_warn_percent", filer.DefaultDirEntriesWarnPercent)
+	fs.filer.DirEntries.WarnPercent = int64(v.GetInt("filer.options.dir_entries_warn_percent"))
 	fs.filer.LoadConfiguration(v)
+	if storeCache := v.GetString("filer.options.store_cache"); storeCache != "" {
+		v.SetDefault("filer.options.store_cache_ttl_seconds", filer.DefaultStoreCacheTtlSeconds)
+		v.SetDefault("filer.options.store_cache_size", filer.DefaultStoreCacheSize)
+		if err := fs.filer.SetStoreCache(filer.StoreCacheOption{
+			Type:           storeCache,
+			Ttl:            time.Duration(v.GetInt("filer.options.store_cache_ttl_seconds")) * time.Second,
+			Size:           int64(v.GetInt("filer.options.store_cache_size")),
+			RedisAddresses: v.GetStringSlice("filer.options.store_cache_redis_addresses"),
+			RedisPassword:  v.GetString("filer.options.store_cache_redis_password"),
+			RedisKeyPrefix: v.GetString("filer.options.store_cache_redis_key_prefix"),
+		}); err != nil {
+			glog.Fatalf("filer.options.store_cache: %v", err)
+		}
+	}
+	if err := codec.LoadPlugins(v.GetString("filer.options.codec_plugins")); err != nil {
+		glog.Fatalf("filer.options.codec_plugins: %v", err)
+	}
+	if fs.option.ChunkCodec = v.GetString("filer.options.chunk_codec"); fs.option.ChunkCodec != "" {
+		if _, err := codec.Get(fs.option.ChunkCodec); err != nil {
+			glog.Fatalf("filer.options.chunk_codec: %v", err)
+		}
+	}
+	util.SetCompressionPolicy(&util.CompressionPolicy{
+		CompressMimeTypes:  v.GetStringSlice("filer.options.compress_mime_types"),
+		CompressExtensions: v.GetStringSlice("filer.options.compress_extensions"),
+		RawMimeTypes:       v.GetStringSlice("filer.options.raw_mime_types"),
+		RawExtensions:      v.GetStringSlice("filer.options.raw_extensions"),
+	})
+	if reconcileFilesPerSecond := int64(v.GetInt("filer.options.reconcile_files_per_second")); reconcileFilesPerSecond > 0 {
+		v.SetDefault("filer.options.reconcile_action", filer.ReconcileActionReport)
+		if err := fs.filer.StartReconciling(reconcileFilesPerSecond, v.GetString("filer.options.reconcile_action")); err != nil {
+			glog.Fatalf("filer.options.reconcile_action: %v", err)
+		}
+	}
+	if km, kmsErr := kms.LoadKeyManager(v); kmsErr != nil {
+		glog.Fatalf("kms: %v", kmsErr)
+	} else if km != nil {
+		fs.filer.SetKeyManager(km)
+	}
+
+	if lifecycleInterval := v.GetInt("filer.options.s3_lifecycle_interval_minutes"); lifecycleInterval > 0 {
+		fs.filer.StartBucketLifecycle(time.Duration(lifecycleInterval)*time.Minute,
+			time.Duration(v.GetInt("filer.options.s3_abort_idle_multipart_upload_hours"))*time.Hour)
+	}
+	if inventoryInterval := v.GetInt("filer.options.s3_inventory_interval_minutes"); inventoryInterval > 0 {
+		fs.filer.StartBucketInventory(time.Duration(inventoryInterval) * time.Minute)
+	}
+	if tieredInterval := v.GetInt("filer.options.s3_tiered_archive_interval_minutes"); tieredInterval > 0 {
+		fs.filer.StartArchivingTieredObjects(time.Duration(tieredInterval) * time.Minute)
+	}
 
 	notification.LoadConfiguration(v, "notification.")
+	if err := s3event.LoadConfiguration(v, "notification.s3."); err != nil {
+		glog.Fatalf("load s3 event notification: %v", err)
+	}
 
 	handleStaticResources(defaultMux)
 	if !option.DisableHttp {
@@ -148,6 +237,15 @@ func NewFilerServer(defaultMux, readonlyMux *http.ServeMux, option *FilerOption)
 
 	fs.filer.LoadFilerConf()
 
+	fs.filer.LoadRemoteStorageConfAndMapping()
+
+	fs.filer.ResumeDeleteJobs(fmt.Sprintf("%s:%d", option.Host, option.Port))
+
+	go fs.filer.LoopExpiringRestores()
+	go fs.filer.LoopEvictingRemoteCache()
+	go fs.filer.LoopCheckingClusterReadOnly()
+	go fs.filer.LoopElectingLockFiler(fmt.Sprintf("%s:%d", option.Host, option.Port))
+
 	grace.OnInterrupt(func() {
 		fs.filer.Shutdown()
 	})
@@ -173,9 +271,7 @@ func (fs *FilerServer) checkWithMaster() {
 					return fmt.Errorf("get master %s configuration: %v", master, err)
 				}
 				fs.metricsAddress, fs.metricsIntervalSec = resp.MetricsAddress, int(resp.MetricsIntervalSeconds)
-				if fs.option.DefaultReplication == "" {
-					fs.option.DefaultReplication = resp.DefaultReplication
-				}
+				// the empty replication is left to the master, preferring the collection defaults
 				return nil
 			})
 			if readErr == nil {
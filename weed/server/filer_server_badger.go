@@ -0,0 +1,8 @@
+//go:build badger
+// +build badger
+
+package weed_server
+
+import (
+	_ "github.com/chrislusf/seaweedfs/weed/filer/badger"
+)
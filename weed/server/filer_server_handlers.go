@@ -31,10 +31,27 @@ func (fs *FilerServer) filerHandler(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 	}
+	if !fs.checkPermission(w, r) {
+		return
+	}
 	switch r.Method {
 	case "GET":
 		stats.FilerRequestCounter.WithLabelValues("get").Inc()
-		fs.GetOrHeadHandler(w, r, true)
+		if _, ok := r.URL.Query()["bulkDeleteJob"]; ok {
+			fs.GetBulkDeleteJobHandler(w, r)
+		} else if _, ok := r.URL.Query()["bulkDeleteJobs"]; ok && r.URL.Path == "/" {
+			fs.ListBulkDeleteJobsHandler(w, r)
+		} else if _, ok := r.URL.Query()["dirEntriesCountJobs"]; ok && r.URL.Path == "/" {
+			fs.ListDirEntriesCountJobsHandler(w, r)
+		} else if _, ok := r.URL.Query()["parts"]; ok {
+			fs.GetPartsHandler(w, r)
+		} else if _, ok := r.URL.Query()["verify"]; ok {
+			fs.GetVerifyHandler(w, r)
+		} else if _, ok := r.URL.Query()["config"]; ok && r.URL.Path == "/" {
+			fs.guard.WhiteList(statsConfigHandler)(w, r)
+		} else {
+			fs.GetOrHeadHandler(w, r, true)
+		}
 		stats.FilerRequestHistogram.WithLabelValues("get").Observe(time.Since(start).Seconds())
 	case "HEAD":
 		stats.FilerRequestCounter.WithLabelValues("head").Inc()
@@ -44,6 +61,8 @@ func (fs *FilerServer) filerHandler(w http.ResponseWriter, r *http.Request) {
 		stats.FilerRequestCounter.WithLabelValues("delete").Inc()
 		if _, ok := r.URL.Query()["tagging"]; ok {
 			fs.DeleteTaggingHandler(w, r)
+		} else if _, ok := r.URL.Query()["bulk"]; ok {
+			fs.BulkDeleteHandler(w, r)
 		} else {
 			fs.DeleteHandler(w, r)
 		}
@@ -67,6 +86,10 @@ func (fs *FilerServer) filerHandler(w http.ResponseWriter, r *http.Request) {
 			stats.FilerRequestCounter.WithLabelValues("put").Inc()
 			if _, ok := r.URL.Query()["tagging"]; ok {
 				fs.PutTaggingHandler(w, r)
+			} else if _, ok := r.URL.Query()["hold"]; ok {
+				fs.PutHoldHandler(w, r)
+			} else if _, ok := r.URL.Query()["retention"]; ok {
+				fs.PutRetentionHandler(w, r)
 			} else {
 				fs.PostHandler(w, r, contentLength)
 			}
@@ -89,6 +112,9 @@ func (fs *FilerServer) readonlyFilerHandler(w http.ResponseWriter, r *http.Reque
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 	}
+	if !fs.checkPermission(w, r) {
+		return
+	}
 	start := time.Now()
 	switch r.Method {
 	case "GET":
@@ -0,0 +1,71 @@
+package weed_server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// delete all entries under a directory, optionally with a name prefix, on the filer side
+// curl -X DELETE "http://localhost:8888/path/to/dir/?bulk&prefix=2020-&recursive=true&entriesPerSecond=1000"
+// the response has the job id to check the progress
+// curl "http://localhost:8888/?bulkDeleteJob=<jobId>&wait=10"
+// curl "http://localhost:8888/?bulkDeleteJobs"
+func (fs *FilerServer) BulkDeleteHandler(w http.ResponseWriter, r *http.Request) {
+
+	path := r.URL.Path
+	if len(path) > 1 && strings.HasSuffix(path, "/") {
+		path = path[:len(path)-1]
+	}
+
+	isRecursive := r.FormValue("recursive") == "true"
+	skipChunkDeletion := r.FormValue("skipChunkDeletion") == "true"
+	entriesPerSecond, _ := strconv.ParseInt(r.FormValue("entriesPerSecond"), 10, 64)
+
+	job, err := fs.filer.StartDeleteJob(util.FullPath(path), r.FormValue("prefix"), nil, isRecursive, !skipChunkDeletion, entriesPerSecond)
+	if err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	writeJsonQuiet(w, r, http.StatusAccepted, map[string]string{"jobId": job.Status().JobId})
+}
+
+func (fs *FilerServer) GetBulkDeleteJobHandler(w http.ResponseWriter, r *http.Request) {
+
+	jobId := r.FormValue("bulkDeleteJob")
+	job, found := fs.filer.FindDeleteJob(jobId)
+	if !found {
+		writeJsonError(w, r, http.StatusNotFound, fmt.Errorf("bulk delete job %s not found", jobId))
+		return
+	}
+
+	if waitSeconds, _ := strconv.Atoi(r.FormValue("wait")); waitSeconds > 0 {
+		job.Wait(time.Duration(waitSeconds) * time.Second)
+	}
+
+	writeJsonQuiet(w, r, http.StatusOK, job.Status())
+}
+
+// AsyncDeleteHandler deletes the entry itself, and recursively its children, with a bulk delete job
+// curl -X DELETE "http://localhost:8888/path/to/dir?recursive=true&async=true"
+func (fs *FilerServer) AsyncDeleteHandler(w http.ResponseWriter, r *http.Request, objectPath string, isRecursive, skipChunkDeletion bool) {
+
+	entriesPerSecond, _ := strconv.ParseInt(r.FormValue("entriesPerSecond"), 10, 64)
+
+	job, err := fs.filer.StartDeleteJob("", "", []util.FullPath{util.FullPath(objectPath)}, isRecursive, !skipChunkDeletion, entriesPerSecond)
+	if err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	writeJsonQuiet(w, r, http.StatusAccepted, map[string]string{"jobId": job.Status().JobId})
+}
+
+func (fs *FilerServer) ListBulkDeleteJobsHandler(w http.ResponseWriter, r *http.Request) {
+	writeJsonQuiet(w, r, http.StatusOK, fs.filer.ListDeleteJobs())
+}
@@ -0,0 +1,112 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+const (
+	SeaweedHoldHeader     = "X-Seaweedfs-Hold"
+	SeaweedIdentityHeader = "X-Seaweedfs-Identity"
+)
+
+// place or remove a hold on one file or directory
+// curl -X PUT -H "X-Seaweedfs-Hold: on" -H "X-Seaweedfs-Uid: 1000" http://localhost:8888/path/to/a/file?hold
+// curl -X PUT -H "X-Seaweedfs-Hold: off" -H "X-Seaweedfs-Uid: 1000" http://localhost:8888/path/to/a/file?hold
+//
+// The holder is the request identity, e.g. "uid:1000". Only the internal clients, i.e. uid 0 or the requests
+// without an identity if the filer does not check the identities, can hold as a named identity, e.g. the s3 legal hold.
+// curl -X PUT -H "X-Seaweedfs-Hold: on" -H "X-Seaweedfs-Identity: admin" http://localhost:8888/path/to/a/file?hold
+func (fs *FilerServer) PutHoldHandler(w http.ResponseWriter, r *http.Request) {
+
+	path := r.URL.Path
+	if len(path) > 1 && strings.HasSuffix(path, "/") {
+		path = path[:len(path)-1]
+	}
+
+	isHold, found := parseHoldHeader(r)
+	if !found {
+		writeJsonError(w, r, http.StatusBadRequest, fmt.Errorf("missing header %s: on|off", SeaweedHoldHeader))
+		return
+	}
+
+	holder, err := fs.holdIdentity(r)
+	if err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := fs.filer.SetHold(context.Background(), util.FullPath(path), holder, isHold); err != nil {
+		glog.V(1).Infof("hold %s: %v", path, err)
+		writeJsonError(w, r, holdErrorToHttpStatus(err), err)
+		return
+	}
+
+	writeJsonQuiet(w, r, http.StatusAccepted, nil)
+}
+
+// maybeSetHoldOnUpload places the hold if the upload request asks for it
+func (fs *FilerServer) maybeSetHoldOnUpload(ctx context.Context, r *http.Request, path string) error {
+	if isHold, found := parseHoldHeader(r); found && isHold {
+		holder, err := fs.holdIdentity(r)
+		if err != nil {
+			return err
+		}
+		return fs.filer.SetHold(ctx, util.FullPath(path), holder, true)
+	}
+	return nil
+}
+
+// holdIdentity is the holder by the request identity, not by the X-Seaweedfs-Identity header,
+// except for the internal clients.
+func (fs *FilerServer) holdIdentity(r *http.Request) (string, error) {
+	identity, err := fs.requestIdentity(r)
+	if err != nil {
+		return "", err
+	}
+	if identity != nil && identity.Uid != 0 {
+		return fmt.Sprintf("uid:%d", identity.Uid), nil
+	}
+	if holder := r.Header.Get(SeaweedIdentityHeader); holder != "" {
+		return holder, nil
+	}
+	if identity != nil {
+		return "uid:0", nil
+	}
+	return "", nil
+}
+
+func parseHoldHeader(r *http.Request) (isHold bool, found bool) {
+	switch strings.ToLower(r.Header.Get(SeaweedHoldHeader)) {
+	case "on", "true":
+		return true, true
+	case "off", "false":
+		return false, true
+	}
+	return false, false
+}
+
+func holdErrorToHttpStatus(err error) int {
+	switch {
+	case err == filer_pb.ErrNotFound:
+		return http.StatusNotFound
+	case err == filer.ErrHoldNotAuthorized:
+		return http.StatusForbidden
+	case strings.Contains(err.Error(), filer.ErrEntryHeld.Error()):
+		return http.StatusForbidden
+	case strings.Contains(err.Error(), filer.ErrEntryRetained.Error()):
+		return http.StatusForbidden
+	case strings.Contains(err.Error(), filer.ErrObjectLockNotEnabled.Error()):
+		return http.StatusBadRequest
+	case strings.Contains(err.Error(), filer.ErrClusterReadOnly.Error()):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
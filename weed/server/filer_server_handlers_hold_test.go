@@ -0,0 +1,44 @@
+package weed_server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+)
+
+func TestHoldIdentity(t *testing.T) {
+
+	fs := &FilerServer{option: &FilerOption{}}
+	anonymousFs := &FilerServer{option: &FilerOption{anonymousIdentity: &filer.Identity{Uid: 65534}}}
+
+	for _, c := range []struct {
+		fs       *FilerServer
+		uid      string
+		identity string
+		expected string
+	}{
+		{fs, "1000", "admin", "uid:1000"},
+		{fs, "1000", "", "uid:1000"},
+		{fs, "0", "s3.legal-hold", "s3.legal-hold"},
+		{fs, "0", "", "uid:0"},
+		{fs, "", "admin", "admin"},
+		{fs, "", "", ""},
+		{anonymousFs, "", "admin", "uid:65534"},
+	} {
+		r, _ := http.NewRequest("PUT", "/path/to/file?hold", nil)
+		if c.uid != "" {
+			r.Header.Set(SeaweedUidHeader, c.uid)
+		}
+		if c.identity != "" {
+			r.Header.Set(SeaweedIdentityHeader, c.identity)
+		}
+		holder, err := c.fs.holdIdentity(r)
+		if err != nil {
+			t.Fatalf("hold identity: %v", err)
+		}
+		if holder != c.expected {
+			t.Errorf("uid %q identity %q: expecting holder %q, got %q", c.uid, c.identity, c.expected, holder)
+		}
+	}
+}
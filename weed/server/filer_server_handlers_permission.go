@@ -0,0 +1,198 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"google.golang.org/grpc/metadata"
+)
+
+// The requests with the identity headers are checked with the POSIX ACLs and the mode bits of the entries,
+// and the new entries are owned by the identity, and the holds are placed by the identity. The filer trusts
+// the headers, so the users should reach the filer via a proxy authenticating them and setting the headers.
+// curl -H "X-Seaweedfs-Uid: 1000" -H "X-Seaweedfs-Gids: 1000,27" http://localhost:8888/path/to/a/file
+//
+// The gRPC requests carry the identity in the x-seaweedfs-uid and x-seaweedfs-gids metadata.
+// The requests without an identity are not checked, unless filer.options.anonymous_uid is configured,
+// in which case they are checked as the anonymous uid, and the internal clients need to send uid 0.
+
+// aclDelete is the access to delete or rename an entry, checked on its parent directory and the sticky bit
+const aclDelete uint16 = 0x8000
+
+const (
+	SeaweedUidHeader    = "X-Seaweedfs-Uid"
+	SeaweedGidsHeader   = "X-Seaweedfs-Gids"
+	seaweedUidMetadata  = "x-seaweedfs-uid"
+	seaweedGidsMetadata = "x-seaweedfs-gids"
+)
+
+// identityFromHeaders returns the identity of the request, or nil if the request has no identity headers
+func identityFromHeaders(r *http.Request) (*filer.Identity, error) {
+	return parseIdentity(r.Header.Get(SeaweedUidHeader), r.Header.Get(SeaweedGidsHeader))
+}
+
+// identityFromMetadata returns the identity of the gRPC request, or nil if the request has no identity metadata
+func identityFromMetadata(ctx context.Context) (*filer.Identity, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return parseIdentity(firstValue(md.Get(seaweedUidMetadata)), firstValue(md.Get(seaweedGidsMetadata)))
+}
+
+func firstValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func parseIdentity(uidString, gidsString string) (*filer.Identity, error) {
+	if uidString == "" {
+		return nil, nil
+	}
+	uid, err := strconv.ParseUint(uidString, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid uid: %s", uidString)
+	}
+	identity := &filer.Identity{Uid: uint32(uid)}
+	for _, gidString := range strings.Split(gidsString, ",") {
+		if gidString = strings.TrimSpace(gidString); gidString == "" {
+			continue
+		}
+		gid, err := strconv.ParseUint(gidString, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gids: %s", gidsString)
+		}
+		identity.Gids = append(identity.Gids, uint32(gid))
+	}
+	return identity, nil
+}
+
+// requestIdentity returns the identity of the request, the anonymous identity if configured, or nil
+func (fs *FilerServer) requestIdentity(r *http.Request) (*filer.Identity, error) {
+	identity, err := identityFromHeaders(r)
+	if identity == nil && err == nil {
+		identity = fs.option.anonymousIdentity
+	}
+	return identity, err
+}
+
+// requestOwner is the owner of the new entries by the request
+func (fs *FilerServer) requestOwner(r *http.Request) (uid, gid uint32) {
+	identity, _ := fs.requestIdentity(r)
+	if identity == nil {
+		return OS_UID, OS_GID
+	}
+	gid = OS_GID
+	if len(identity.Gids) > 0 {
+		gid = identity.Gids[0]
+	}
+	return identity.Uid, gid
+}
+
+// checkPermission checks the permissions of the request identity, and replies 403 Forbidden if not permitted
+func (fs *FilerServer) checkPermission(w http.ResponseWriter, r *http.Request) bool {
+	identity, err := fs.requestIdentity(r)
+	if err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return false
+	}
+	if identity == nil {
+		return true
+	}
+
+	ctx := context.Background()
+	path := r.URL.Path
+	if len(path) > 1 && strings.HasSuffix(path, "/") {
+		path = path[:len(path)-1]
+	}
+	p := util.FullPath(path)
+	query := r.URL.Query()
+	_, isTagging := query["tagging"]
+	_, isRetention := query["retention"]
+
+	switch r.Method {
+	case "GET", "HEAD":
+		if _, isConfig := query["config"]; isConfig && identity.Uid != 0 {
+			err = fmt.Errorf("read configuration by %d: %v", identity.Uid, filer.ErrPermissionDenied)
+		} else {
+			err = filer.CheckAccess(ctx, fs.filer.FindEntry, p, identity, filer.AclRead)
+		}
+	case "DELETE":
+		if _, isBulk := query["bulk"]; isBulk && identity.Uid != 0 {
+			err = fmt.Errorf("bulk delete by %d: %v", identity.Uid, filer.ErrPermissionDenied)
+		} else if isTagging {
+			err = filer.CheckAccess(ctx, fs.filer.FindEntry, p, identity, filer.AclWrite)
+		} else {
+			err = filer.CheckDeleteAccess(ctx, fs.filer.FindEntry, p, identity)
+		}
+	case "POST", "PUT":
+		if _, isHold := query["hold"]; isHold {
+			// the holds are authorized by the hold identity
+			return true
+		}
+		if strings.HasSuffix(r.URL.Path, "/") && !isTagging && !isRetention {
+			// upload into or create the directory
+			err = filer.CheckAccess(ctx, fs.filer.FindEntry, p, identity, filer.AclWrite|filer.AclExecute)
+			if err == filer_pb.ErrNotFound {
+				err = filer.CheckCreateAccess(ctx, fs.filer.FindEntry, p, identity)
+			}
+		} else if isTagging || isRetention {
+			err = filer.CheckAccess(ctx, fs.filer.FindEntry, p, identity, filer.AclWrite)
+		} else {
+			err = fs.checkWriteAccess(ctx, p, identity)
+		}
+	}
+
+	if err == nil || err == filer_pb.ErrNotFound {
+		return true
+	}
+	glog.V(2).Infof("%s %s by uid %d: %v", r.Method, r.URL.Path, identity.Uid, err)
+	if strings.Contains(err.Error(), filer.ErrPermissionDenied.Error()) {
+		writeJsonError(w, r, http.StatusForbidden, err)
+	} else {
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+	}
+	return false
+}
+
+// checkWriteAccess checks the identity can write the entry, or create it if not found
+func (fs *FilerServer) checkWriteAccess(ctx context.Context, p util.FullPath, identity *filer.Identity) error {
+	err := filer.CheckAccess(ctx, fs.filer.FindEntry, p, identity, filer.AclWrite)
+	if err == filer_pb.ErrNotFound {
+		err = filer.CheckCreateAccess(ctx, fs.filer.FindEntry, p, identity)
+	}
+	return err
+}
+
+// checkGrpcPermission checks the permissions of the gRPC request identity to the entry.
+// The access is one of filer.AclRead, filer.AclWrite to write or create the entry, or aclDelete.
+func (fs *FilerServer) checkGrpcPermission(ctx context.Context, p util.FullPath, access uint16) error {
+	identity, err := identityFromMetadata(ctx)
+	if err != nil {
+		return err
+	}
+	if identity == nil {
+		if identity = fs.option.anonymousIdentity; identity == nil {
+			return nil
+		}
+	}
+	switch access {
+	case filer.AclRead:
+		err = filer.CheckAccess(ctx, fs.filer.FindEntry, p, identity, filer.AclRead)
+	case filer.AclWrite:
+		err = fs.checkWriteAccess(ctx, p, identity)
+	case aclDelete:
+		err = filer.CheckDeleteAccess(ctx, fs.filer.FindEntry, p, identity)
+	}
+	if err == nil || err == filer_pb.ErrNotFound {
+		return nil
+	}
+	glog.V(2).Infof("%s by uid %d: %v", p, identity.Uid, err)
+	return err
+}
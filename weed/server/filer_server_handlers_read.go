@@ -3,6 +3,7 @@ package weed_server
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"io"
 	"mime"
 	"net/http"
@@ -29,6 +30,11 @@ func (fs *FilerServer) GetOrHeadHandler(w http.ResponseWriter, r *http.Request,
 		path = path[:len(path)-1]
 	}
 
+	// read a prior version of the file, see fs.versions
+	if versionId := r.URL.Query().Get("version"); versionId != "" && !isForDirectory {
+		path = string(filer.VersionPath(util.FullPath(path), versionId))
+	}
+
 	entry, err := fs.filer.FindEntry(context.Background(), util.FullPath(path))
 	if err != nil {
 		if path == "/" {
@@ -89,8 +95,14 @@ func (fs *FilerServer) GetOrHeadHandler(w http.ResponseWriter, r *http.Request,
 
 	// print out the header from extended properties
 	for k, v := range entry.Extended {
+		if k == filer.ExtArchivedChunksKey || k == filer.ExtRemoteKey || k == filer.ExtSseDataKeyKey {
+			continue
+		}
 		w.Header().Set(k, string(v))
 	}
+	if string(entry.Extended[filer.ExtHoldKey]) == filer.ObjectLockLegalHoldIdentity {
+		w.Header().Set(xhttp.AmzObjectLockLegalHold, "ON")
+	}
 
 	//Seaweed custom header are not visible to Vue or javascript
 	seaweedHeaders := []string{}
@@ -115,6 +127,41 @@ func (fs *FilerServer) GetOrHeadHandler(w http.ResponseWriter, r *http.Request,
 		}
 	}
 
+	// archived content can only be read after being restored
+	if r.Method == "GET" && !filer.IsReadable(entry) {
+		writeJsonError(w, r, http.StatusForbidden, filer.ErrEntryArchived)
+		return
+	}
+
+	// the content of remote entries is cached locally before being read
+	if r.Method == "GET" && filer.RemoteEntryOf(entry) != nil {
+		if entry, err = fs.cacheRemoteEntry(context.Background(), entry); err != nil {
+			glog.Errorf("cache remote entry %s: %v", path, err)
+			writeJsonError(w, r, http.StatusBadGateway, err)
+			return
+		}
+		fs.filer.RemoteStorage.TouchRead(entry.FullPath)
+	}
+
+	// the entries encrypted with the customer keys are only read with the same key
+	if filer.IsSseCustomerEntry(entry) {
+		if entry, err = decryptSseCustomerEntry(r, entry); err != nil {
+			status := http.StatusBadRequest
+			if err == filer.ErrSseCustomerKeyMismatch {
+				status = http.StatusForbidden
+			}
+			writeJsonError(w, r, status, err)
+			return
+		}
+	}
+
+	// the chunk cipher keys of the server side encrypted entries are decrypted for reading
+	if entry, err = fs.filer.DecryptSseEntry(entry); err != nil {
+		glog.Errorf("decrypt %s: %v", path, err)
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
 	// set etag
 	etag := filer.ETagEntry(entry)
 	if inm := r.Header.Get("If-None-Match"); inm == "\""+etag+"\"" {
@@ -122,6 +169,14 @@ func (fs *FilerServer) GetOrHeadHandler(w http.ResponseWriter, r *http.Request,
 		return
 	}
 	setEtag(w, etag)
+	if md5 := filer.EntryMd5(entry); md5 != nil && r.Header.Get("Range") == "" {
+		w.Header().Set("Content-MD5", base64.StdEncoding.EncodeToString(md5))
+	}
+	// only the checksum computed by the filer, not an extended attribute with the same name
+	w.Header().Del(filer.ChecksumHeader)
+	if checksum := filer.ChecksumHex(entry); checksum != "" {
+		w.Header().Set(filer.ChecksumHeader, checksum)
+	}
 
 	filename := entry.Name()
 	filename = url.QueryEscape(filename)
@@ -153,6 +208,11 @@ func (fs *FilerServer) GetOrHeadHandler(w http.ResponseWriter, r *http.Request,
 		}
 	}
 
+	if r.Header.Get("Range") == "" && r.Header.Get(VerifyChecksumHeader) == "true" && len(entry.Sha256) > 0 {
+		fs.streamVerifiedContent(w, entry, totalSize)
+		return
+	}
+
 	processRangeRequest(r, w, totalSize, mimeType, func(writer io.Writer, offset int64, size int64) error {
 		if offset+size <= int64(len(entry.Content)) {
 			_, err := writer.Write(entry.Content[offset : offset+size])
@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/chrislusf/seaweedfs/weed/filer"
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	ui "github.com/chrislusf/seaweedfs/weed/server/filer_ui"
 	"github.com/chrislusf/seaweedfs/weed/stats"
@@ -95,3 +96,12 @@ func (fs *FilerServer) listDirectoryHandler(w http.ResponseWriter, r *http.Reque
 		qrImageString,
 	})
 }
+
+// ListDirEntriesCountJobsHandler lists the directories being counted in the background for the directory entries limit.
+func (fs *FilerServer) ListDirEntriesCountJobsHandler(w http.ResponseWriter, r *http.Request) {
+	jobs := fs.filer.DirEntries.CountJobs()
+	if jobs == nil {
+		jobs = []filer.DirEntriesCountJob{}
+	}
+	writeJsonQuiet(w, r, http.StatusOK, jobs)
+}
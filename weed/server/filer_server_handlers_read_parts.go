@@ -0,0 +1,87 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+The parts of a file can be listed with their hashes, so that the clients, e.g. rclone or restic,
+can verify the stored content without downloading it.
+
+	GET /path/to/file?parts
+
+	{
+	  "path": "/path/to/file",
+	  "algorithm": "md5",
+	  "size": 12582912,
+	  "md5": "<hex md5 of the whole file, if known>",
+	  "sha256": "<hex sha256 of the whole file, if known>",
+	  "etag": "<the same as the ETag header>",
+	  "parts": [
+	    {"offset": 0, "size": 8388608, "md5": "<hex md5 of the part>"},
+	    {"offset": 8388608, "size": 4194304, "md5": "<hex md5 of the part>"}
+	  ]
+	}
+
+The algorithm is always md5 of the original content. A part without md5 can not be verified,
+e.g., it is encrypted, or partially overwritten. HEAD and GET without range also return
+the md5 of the whole file in the Content-MD5 header, if known, and the sha256 of the whole file
+in the Seaweed-Checksum-Sha256 header.
+*/
+
+type FilePartsResult struct {
+	Path      string           `json:"path"`
+	Algorithm string           `json:"algorithm"`
+	Size      uint64           `json:"size"`
+	Md5       string           `json:"md5,omitempty"`
+	Sha256    string           `json:"sha256,omitempty"`
+	ETag      string           `json:"etag"`
+	Parts     []filer.FilePart `json:"parts"`
+}
+
+func (fs *FilerServer) GetPartsHandler(w http.ResponseWriter, r *http.Request) {
+
+	path := util.FullPath(r.URL.Path)
+	entry, err := fs.filer.FindEntry(context.Background(), path)
+	if err == filer_pb.ErrNotFound {
+		writeJsonError(w, r, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		glog.V(0).Infof("find %s: %v", path, err)
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if entry.IsDirectory() {
+		writeJsonError(w, r, http.StatusBadRequest, fmt.Errorf("%s is a directory", path))
+		return
+	}
+
+	parts, err := filer.FileParts(fs.filer.MasterClient.GetLookupFileIdFunction(), entry)
+	if err != nil {
+		glog.V(0).Infof("list parts of %s: %v", path, err)
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	result := &FilePartsResult{
+		Path:      string(path),
+		Algorithm: filer.PartsHashAlgorithm,
+		Size:      entry.Size(),
+		ETag:      filer.ETagEntry(entry),
+		Parts:     parts,
+	}
+	if md5 := filer.EntryMd5(entry); md5 != nil {
+		result.Md5 = fmt.Sprintf("%x", md5)
+	}
+	result.Sha256 = filer.ChecksumHex(entry)
+
+	writeJsonQuiet(w, r, http.StatusOK, result)
+}
@@ -0,0 +1,139 @@
+package weed_server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash"
+	"net/http"
+	"strconv"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/stats"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// VerifyChecksumHeader asks to verify the whole file checksum while downloading it.
+// If the content does not match the checksum, the connection is closed before sending
+// the last byte, so the client always sees a failed download instead of corrupted data.
+const VerifyChecksumHeader = "Seaweed-Verify-Checksum"
+
+func (fs *FilerServer) streamVerifiedContent(w http.ResponseWriter, entry *filer.Entry, totalSize int64) {
+
+	w.Header().Set("Content-Length", strconv.FormatInt(totalSize, 10))
+
+	writer := &checksumVerifyingWriter{w: w, hash: filer.NewChecksumHash()}
+	var err error
+	if len(entry.Content) > 0 {
+		_, err = writer.Write(entry.Content)
+	} else {
+		err = filer.StreamContent(fs.filer.MasterClient, writer, entry.Chunks, 0, totalSize, false)
+	}
+	if err != nil {
+		glog.Errorf("read %s: %v", entry.FullPath, err)
+		panic(http.ErrAbortHandler)
+	}
+
+	if !bytes.Equal(writer.hash.Sum(nil), entry.Sha256) {
+		stats.FilerRequestCounter.WithLabelValues("read.checksumMismatch").Inc()
+		glog.Errorf("read %s: content does not match %s checksum %x", entry.FullPath, filer.ChecksumAlgorithm, entry.Sha256)
+		panic(http.ErrAbortHandler)
+	}
+
+	if writer.hasHeldByte {
+		w.Write([]byte{writer.heldByte})
+	}
+}
+
+// checksumVerifyingWriter hashes the written data, and holds back the last byte until the data is verified.
+type checksumVerifyingWriter struct {
+	w           http.ResponseWriter
+	hash        hash.Hash
+	heldByte    byte
+	hasHeldByte bool
+}
+
+func (cw *checksumVerifyingWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	cw.hash.Write(p)
+	if cw.hasHeldByte {
+		if _, err := cw.w.Write([]byte{cw.heldByte}); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := cw.w.Write(p[:len(p)-1]); err != nil {
+		return 0, err
+	}
+	cw.heldByte, cw.hasHeldByte = p[len(p)-1], true
+	return len(p), nil
+}
+
+// GetVerifyHandler reads the whole file, and compares its sha256 and md5 with the checksums stored at upload time.
+//
+//	GET /path/to/file?verify
+//
+//	{"path": "/path/to/file", "storedSha256": "...", "storedMd5": "...",
+//	 "computedSha256": "...", "computedMd5": "...", "isVerified": true}
+func (fs *FilerServer) GetVerifyHandler(w http.ResponseWriter, r *http.Request) {
+
+	path := util.FullPath(r.URL.Path)
+	entry, err := fs.filer.FindEntry(context.Background(), path)
+	if err == filer_pb.ErrNotFound {
+		writeJsonError(w, r, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		glog.V(0).Infof("find %s: %v", path, err)
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if entry.IsDirectory() {
+		writeJsonError(w, r, http.StatusBadRequest, fmt.Errorf("%s is a directory", path))
+		return
+	}
+	if !filer.IsReadable(entry) {
+		writeJsonError(w, r, http.StatusForbidden, filer.ErrEntryArchived)
+		return
+	}
+	if filer.RemoteEntryOf(entry) != nil {
+		if entry, err = fs.cacheRemoteEntry(context.Background(), entry); err != nil {
+			glog.Errorf("cache remote entry %s: %v", path, err)
+			writeJsonError(w, r, http.StatusBadGateway, err)
+			return
+		}
+	}
+
+	// the same as reading, the content encrypted with the customer key is only verified with the key
+	if filer.IsSseCustomerEntry(entry) {
+		if entry, err = decryptSseCustomerEntry(r, entry); err != nil {
+			status := http.StatusBadRequest
+			if err == filer.ErrSseCustomerKeyMismatch {
+				status = http.StatusForbidden
+			}
+			writeJsonError(w, r, status, err)
+			return
+		}
+	}
+	if entry, err = fs.filer.DecryptSseEntry(entry); err != nil {
+		glog.Errorf("decrypt %s: %v", path, err)
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	verification, err := filer.VerifyChecksums(fs.filer.MasterClient, entry)
+	if err != nil {
+		glog.Errorf("verify %s: %v", path, err)
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if len(verification.Mismatches) > 0 {
+		stats.FilerRequestCounter.WithLabelValues("read.checksumMismatch").Inc()
+		glog.Errorf("verify %s: content does not match the stored %v checksums", path, verification.Mismatches)
+	}
+
+	writeJsonQuiet(w, r, http.StatusOK, verification)
+}
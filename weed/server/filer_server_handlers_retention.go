@@ -0,0 +1,62 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	xhttp "github.com/chrislusf/seaweedfs/weed/s3api/http"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// set or remove the object lock retention on one file
+// curl -X PUT -H "X-Amz-Object-Lock-Mode: GOVERNANCE" -H "X-Amz-Object-Lock-Retain-Until-Date: 2030-01-01T00:00:00Z" http://localhost:8888/path/to/a/file?retention
+// curl -X PUT -H "X-Amz-Bypass-Governance-Retention: true" http://localhost:8888/path/to/a/file?retention
+func (fs *FilerServer) PutRetentionHandler(w http.ResponseWriter, r *http.Request) {
+
+	path := r.URL.Path
+	if len(path) > 1 && strings.HasSuffix(path, "/") {
+		path = path[:len(path)-1]
+	}
+
+	mode, retainUntil, err := parseRetentionHeaders(r)
+	if err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := fs.filer.SetObjectRetention(context.Background(), util.FullPath(path), mode, retainUntil, isGovernanceBypassed(r)); err != nil {
+		glog.V(1).Infof("retention %s: %v", path, err)
+		writeJsonError(w, r, holdErrorToHttpStatus(err), err)
+		return
+	}
+
+	writeJsonQuiet(w, r, http.StatusAccepted, nil)
+}
+
+// parseRetentionHeaders returns the object lock mode and retain until date, both or neither set
+func parseRetentionHeaders(r *http.Request) (mode string, retainUntil time.Time, err error) {
+	mode = strings.ToUpper(r.Header.Get(xhttp.AmzObjectLockMode))
+	date := r.Header.Get(xhttp.AmzObjectLockRetainUntilDate)
+	if mode == "" && date == "" {
+		return "", time.Time{}, nil
+	}
+	if mode != filer.ObjectLockGovernance && mode != filer.ObjectLockCompliance {
+		return "", time.Time{}, fmt.Errorf("invalid header %s: %q", xhttp.AmzObjectLockMode, mode)
+	}
+	if retainUntil, err = time.Parse(time.RFC3339, date); err != nil {
+		return "", time.Time{}, fmt.Errorf("invalid header %s: %v", xhttp.AmzObjectLockRetainUntilDate, err)
+	}
+	if !retainUntil.After(time.Now()) {
+		return "", time.Time{}, fmt.Errorf("header %s %s is not in the future", xhttp.AmzObjectLockRetainUntilDate, date)
+	}
+	return mode, retainUntil, nil
+}
+
+func isGovernanceBypassed(r *http.Request) bool {
+	return strings.ToLower(r.Header.Get(xhttp.AmzBypassGovernanceRetention)) == "true"
+}
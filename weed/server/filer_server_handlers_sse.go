@@ -0,0 +1,88 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// sseAlgorithm returns the server side encryption requested by the s3 gateway, or empty if not requested.
+func sseAlgorithm(r *http.Request) string {
+	return r.Header.Get(filer.SseAlgorithmHeader)
+}
+
+// isSseUpload tells whether the chunks are encrypted with either the data key or the customer key
+func isSseUpload(r *http.Request) bool {
+	return sseAlgorithm(r) != "" || r.Header.Get(filer.SseCustomerAlgorithmHeader) != ""
+}
+
+func sseCustomerKey(r *http.Request) (util.CipherKey, string, error) {
+	return filer.ParseSseCustomerKey(r.Header.Get(filer.SseCustomerAlgorithmHeader), r.Header.Get(filer.SseCustomerKeyHeader), r.Header.Get(filer.SseCustomerKeyMD5Header))
+}
+
+func (fs *FilerServer) checkSseUpload(r *http.Request) error {
+	if key, _, err := sseCustomerKey(r); err != nil {
+		return err
+	} else if key != nil {
+		if sseAlgorithm(r) != "" {
+			return fmt.Errorf("both server side encryption and customer key are requested")
+		}
+		if isAppend(r) {
+			return fmt.Errorf("append with server side encryption is not supported")
+		}
+		return nil
+	}
+	switch sseAlgorithm(r) {
+	case "":
+		return nil
+	case filer.SseAlgorithmAES256, filer.SseAlgorithmKms:
+	default:
+		return fmt.Errorf("unknown server side encryption %q", sseAlgorithm(r))
+	}
+	if !fs.filer.IsSseEnabled() {
+		return filer.ErrSseNotConfigured
+	}
+	if isAppend(r) {
+		return fmt.Errorf("append with server side encryption is not supported")
+	}
+	return nil
+}
+
+// encryptSseEntry encrypts the chunk cipher keys of the uploaded entry with the customer key or the data key,
+// and keeps the md5 of the customer key or the wrapped data key, and the encryption headers in the entry.
+func (fs *FilerServer) encryptSseEntry(ctx context.Context, r *http.Request, entry *filer.Entry) error {
+	if key, keyMD5, err := sseCustomerKey(r); err != nil {
+		return err
+	} else if key != nil {
+		if err = filer.EncryptSseChunks(entry.Chunks, key); err != nil {
+			return err
+		}
+		entry.Extended[filer.SseCustomerAlgorithmHeader] = []byte(filer.SseAlgorithmAES256)
+		entry.Extended[filer.SseCustomerKeyMD5Header] = []byte(keyMD5)
+		return nil
+	}
+	dataKey, wrappedKey, err := fs.filer.SseDataKey(ctx, entry.FullPath)
+	if err != nil {
+		return err
+	}
+	if err = filer.EncryptSseChunks(entry.Chunks, dataKey); err != nil {
+		return err
+	}
+	entry.Extended[filer.ExtSseDataKeyKey] = wrappedKey
+	entry.Extended[filer.SseAlgorithmHeader] = []byte(sseAlgorithm(r))
+	if keyId := r.Header.Get(filer.SseKmsKeyIdHeader); keyId != "" && sseAlgorithm(r) == filer.SseAlgorithmKms {
+		entry.Extended[filer.SseKmsKeyIdHeader] = []byte(keyId)
+	}
+	return nil
+}
+
+func decryptSseCustomerEntry(r *http.Request, entry *filer.Entry) (*filer.Entry, error) {
+	key, keyMD5, err := sseCustomerKey(r)
+	if err != nil {
+		return nil, err
+	}
+	return filer.DecryptSseCustomerEntry(entry, key, keyMD5)
+}
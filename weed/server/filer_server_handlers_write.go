@@ -2,14 +2,18 @@ package weed_server
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/chrislusf/seaweedfs/weed/filer"
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/operation"
 	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	xhttp "github.com/chrislusf/seaweedfs/weed/s3api/http"
 	"github.com/chrislusf/seaweedfs/weed/security"
 	"github.com/chrislusf/seaweedfs/weed/stats"
 	"github.com/chrislusf/seaweedfs/weed/storage/needle"
@@ -19,6 +23,9 @@ import (
 var (
 	OS_UID = uint32(os.Getuid())
 	OS_GID = uint32(os.Getgid())
+
+	errInvalidTtl       = errors.New("invalid ttl")
+	errChecksumMismatch = errors.New("checksum mismatch")
 )
 
 type FilerPostResult struct {
@@ -57,14 +64,26 @@ func (fs *FilerServer) PostHandler(w http.ResponseWriter, r *http.Request, conte
 	ctx := context.Background()
 
 	query := r.URL.Query()
-	so := fs.detectStorageOption0(r.RequestURI,
+	replication, err := requestedReplication(r, query.Get("replication"))
+	if err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	so, err := fs.detectStorageOption0(r.RequestURI,
 		query.Get("collection"),
-		query.Get("replication"),
+		replication,
 		query.Get("ttl"),
 		query.Get("disk"),
 		query.Get("dataCenter"),
 		query.Get("rack"),
 	)
+	if err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if r.Header.Get(xhttp.AmzStorageClass) == filer.ArchiveStorageClass {
+		so.Collection = fs.option.archiveCollection
+	}
 
 	fs.autoChunk(ctx, w, r, contentLength, so)
 	util.CloseRequest(r)
@@ -91,10 +110,20 @@ func (fs *FilerServer) DeleteHandler(w http.ResponseWriter, r *http.Request) {
 		objectPath = objectPath[0 : len(objectPath)-1]
 	}
 
-	err := fs.filer.DeleteEntryMetaAndData(context.Background(), util.FullPath(objectPath), isRecursive, ignoreRecursiveError, !skipChunkDeletion, false, nil)
+	if r.FormValue("async") == "true" && objectPath != "/" {
+		fs.AsyncDeleteHandler(w, r, objectPath, isRecursive, skipChunkDeletion)
+		return
+	}
+
+	ctx := context.Background()
+	if isGovernanceBypassed(r) {
+		ctx = filer.WithGovernanceBypass(ctx)
+	}
+
+	err := fs.filer.DeleteEntryMetaAndData(ctx, util.FullPath(objectPath), isRecursive, ignoreRecursiveError, !skipChunkDeletion, false, nil)
 	if err != nil {
 		glog.V(1).Infoln("deleting", objectPath, ":", err.Error())
-		httpStatus := http.StatusInternalServerError
+		httpStatus := holdErrorToHttpStatus(err)
 		if err == filer_pb.ErrNotFound {
 			httpStatus = http.StatusNoContent
 		}
@@ -141,12 +170,39 @@ func (fs *FilerServer) detectStorageOption(requestURI, qCollection, qReplication
 	}
 }
 
-func (fs *FilerServer) detectStorageOption0(requestURI, qCollection, qReplication string, qTtl string, diskType string, dataCenter, rack string) *operation.StorageOption {
+func (fs *FilerServer) detectStorageOption0(requestURI, qCollection, qReplication string, qTtl string, diskType string, dataCenter, rack string) (*operation.StorageOption, error) {
 
 	ttl, err := needle.ReadTTL(qTtl)
 	if err != nil {
-		glog.Errorf("fail to parse ttl %s: %v", qTtl, err)
+		return nil, fmt.Errorf("%v %s: %v", errInvalidTtl, qTtl, err)
 	}
 
-	return fs.detectStorageOption(requestURI, qCollection, qReplication, int32(ttl.Minutes())*60, diskType, dataCenter, rack)
+	return fs.detectStorageOption(requestURI, qCollection, qReplication, int32(ttl.Minutes())*60, diskType, dataCenter, rack), nil
+}
+
+// routeStorageOption applies the first routing rule in filer.conf matching the new file, to the options not set by the request.
+// The files in a bucket always go to the bucket collection.
+// It fails if the ttl of the matching rule is invalid, instead of writing the files without the ttl.
+func (fs *FilerServer) routeStorageOption(so *operation.StorageOption, fullpath, contentType string, size int64, qCollection, qReplication, qDiskType string, qTtlSeconds int32) error {
+	route := fs.filer.FilerConf.MatchRouteRule(fullpath, contentType, size)
+	if route == nil {
+		return nil
+	}
+	if qCollection == "" && route.Collection != "" && !strings.HasPrefix(fullpath, fs.filer.DirBucketsPath+"/") {
+		so.Collection = route.Collection
+	}
+	if qReplication == "" && route.Replication != "" {
+		so.Replication = route.Replication
+	}
+	if qDiskType == "" && route.DiskType != "" {
+		so.DiskType = route.DiskType
+	}
+	if qTtlSeconds == 0 && route.Ttl != "" {
+		ttl, err := needle.ReadTTL(route.Ttl)
+		if err != nil {
+			return fmt.Errorf("%v %s in route %s%s: %v", errInvalidTtl, route.Ttl, route.LocationPrefix, route.NamePattern, err)
+		}
+		so.TtlSeconds = int32(ttl.Minutes()) * 60
+	}
+	return nil
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"strconv"
@@ -40,9 +41,16 @@ func (fs *FilerServer) autoChunk(ctx context.Context, w http.ResponseWriter, r *
 		stats.FilerRequestHistogram.WithLabelValues("chunk").Observe(time.Since(start).Seconds())
 	}()
 
+	stages := stats.NewUploadStages()
+	r = r.WithContext(stats.WithUploadStages(r.Context(), stages))
+
 	var reply *FilerPostResult
 	var err error
 	var md5bytes []byte
+	if sseErr := fs.checkSseUpload(r); sseErr != nil {
+		writeJsonError(w, r, http.StatusBadRequest, sseErr)
+		return
+	}
 	if r.Method == "POST" {
 		if r.Header.Get("Content-Type") == "" && strings.HasSuffix(r.URL.Path, "/") {
 			reply, err = fs.mkdir(ctx, w, r)
@@ -52,11 +60,21 @@ func (fs *FilerServer) autoChunk(ctx context.Context, w http.ResponseWriter, r *
 	} else {
 		reply, md5bytes, err = fs.doPutAutoChunk(ctx, w, r, chunkSize, contentLength, so)
 	}
+	stages.Observe()
+	stages.SetServerTiming(w, r)
 	if err != nil {
 		if strings.HasPrefix(err.Error(), "read input:") {
 			writeJsonError(w, r, 499, err)
 		} else if strings.HasSuffix(err.Error(), "is a file") {
 			writeJsonError(w, r, http.StatusConflict, err)
+		} else if strings.Contains(err.Error(), filer.ErrDirectoryFull.Error()) || strings.Contains(err.Error(), filer.ErrQuotaExceeded.Error()) {
+			writeJsonError(w, r, http.StatusInsufficientStorage, err)
+		} else if strings.Contains(err.Error(), filer.ErrEntryHeld.Error()) || strings.Contains(err.Error(), filer.ErrEntryRetained.Error()) {
+			writeJsonError(w, r, http.StatusForbidden, err)
+		} else if strings.Contains(err.Error(), filer.ErrClusterReadOnly.Error()) {
+			writeJsonError(w, r, http.StatusServiceUnavailable, err)
+		} else if strings.Contains(err.Error(), filer.ErrS3ChecksumMismatch.Error()) || strings.Contains(err.Error(), filer.ErrInvalidS3Checksum.Error()) || strings.Contains(err.Error(), errInvalidTtl.Error()) || strings.Contains(err.Error(), errChecksumMismatch.Error()) {
+			writeJsonError(w, r, http.StatusBadRequest, err)
 		} else {
 			writeJsonError(w, r, http.StatusInternalServerError, err)
 		}
@@ -88,14 +106,31 @@ func (fs *FilerServer) doPostAutoChunk(ctx context.Context, w http.ResponseWrite
 	if contentType == "application/octet-stream" {
 		contentType = ""
 	}
+	if err := fs.routeUpload(r, so, fileName, contentType, contentLength); err != nil {
+		return nil, nil, err
+	}
 
-	fileChunks, md5Hash, chunkOffset, err, smallContent := fs.uploadReaderToChunks(w, r, part1, chunkSize, fileName, contentType, contentLength, so)
+	s3Checksum, err := filer.NewS3ChecksumVerifier(r.Header)
+	if err != nil {
+		return nil, nil, err
+	}
+	checksumHash := filer.NewChecksumHash()
+	fileChunks, md5Hash, chunkOffset, err, smallContent := fs.uploadReaderToChunks(w, r, io.TeeReader(part1, io.MultiWriter(checksumHash, s3Checksum)), chunkSize, fileName, contentType, contentLength, so)
 	if err != nil {
 		return nil, nil, err
 	}
+	if err = s3Checksum.SetTrailer(r.Trailer); err == nil {
+		err = s3Checksum.Verify()
+	}
+	if err != nil {
+		fs.filer.DeleteChunks(fileChunks)
+		return nil, nil, err
+	}
 
 	md5bytes = md5Hash.Sum(nil)
-	filerResult, replyerr = fs.saveMetaData(ctx, r, fileName, contentType, so, md5bytes, fileChunks, chunkOffset, smallContent)
+	start := time.Now()
+	filerResult, replyerr = fs.saveMetaData(ctx, r, fileName, contentType, so, md5bytes, checksumHash.Sum(nil), s3Checksum, fileChunks, chunkOffset, smallContent)
+	stats.UploadStagesFromContext(r.Context()).Since(stats.UploadStageCommit, start)
 
 	return
 }
@@ -107,23 +142,78 @@ func (fs *FilerServer) doPutAutoChunk(ctx context.Context, w http.ResponseWriter
 	if contentType == "application/octet-stream" {
 		contentType = ""
 	}
+	if err := fs.routeUpload(r, so, "", contentType, contentLength); err != nil {
+		return nil, nil, err
+	}
 
-	fileChunks, md5Hash, chunkOffset, err, smallContent := fs.uploadReaderToChunks(w, r, r.Body, chunkSize, fileName, contentType, contentLength, so)
+	s3Checksum, err := filer.NewS3ChecksumVerifier(r.Header)
 	if err != nil {
 		return nil, nil, err
 	}
+	checksumHash := filer.NewChecksumHash()
+	fileChunks, md5Hash, chunkOffset, err, smallContent := fs.uploadReaderToChunks(w, r, io.TeeReader(r.Body, io.MultiWriter(checksumHash, s3Checksum)), chunkSize, fileName, contentType, contentLength, so)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = s3Checksum.SetTrailer(r.Trailer); err == nil {
+		err = s3Checksum.Verify()
+	}
+	if err != nil {
+		fs.filer.DeleteChunks(fileChunks)
+		return nil, nil, err
+	}
 
 	md5bytes = md5Hash.Sum(nil)
-	filerResult, replyerr = fs.saveMetaData(ctx, r, fileName, contentType, so, md5bytes, fileChunks, chunkOffset, smallContent)
+	start := time.Now()
+	filerResult, replyerr = fs.saveMetaData(ctx, r, fileName, contentType, so, md5bytes, checksumHash.Sum(nil), s3Checksum, fileChunks, chunkOffset, smallContent)
+	stats.UploadStagesFromContext(r.Context()).Since(stats.UploadStageCommit, start)
 
 	return
 }
 
+// routeUpload applies the routing rules by the uploaded file, before assigning the file ids
+func (fs *FilerServer) routeUpload(r *http.Request, so *operation.StorageOption, fileName string, contentType string, contentLength int64) error {
+	fullpath := r.URL.Path
+	if strings.HasSuffix(fullpath, "/") {
+		fullpath += fileName
+	}
+	var qTtlSeconds int32
+	query := r.URL.Query()
+	if query.Get("ttl") != "" {
+		qTtlSeconds = so.TtlSeconds
+	}
+	if isAppend(r) {
+		// the size of the whole file is not known
+		contentLength = -1
+	}
+	// the replication is validated already
+	qReplication, _ := requestedReplication(r, query.Get("replication"))
+	return fs.routeStorageOption(so, fullpath, contentType, contentLength, query.Get("collection"), qReplication, query.Get("disk"), qTtlSeconds)
+}
+
+// verifyUploadChecksum compares the checksum of the uploaded content with the one sent by the client, if any.
+// The stored checksum is always the one computed by the filer.
+func verifyUploadChecksum(r *http.Request, checksum []byte) error {
+	expected := r.Header.Get(filer.ChecksumHeader)
+	if expected == "" {
+		return nil
+	}
+	if computed := fmt.Sprintf("%x", checksum); !strings.EqualFold(expected, computed) {
+		return fmt.Errorf("%v: %s %s, computed %s", errChecksumMismatch, filer.ChecksumHeader, expected, computed)
+	}
+	return nil
+}
+
 func isAppend(r *http.Request) bool {
 	return r.URL.Query().Get("op") == "append"
 }
 
-func (fs *FilerServer) saveMetaData(ctx context.Context, r *http.Request, fileName string, contentType string, so *operation.StorageOption, md5bytes []byte, fileChunks []*filer_pb.FileChunk, chunkOffset int64, content []byte) (filerResult *FilerPostResult, replyerr error) {
+func (fs *FilerServer) saveMetaData(ctx context.Context, r *http.Request, fileName string, contentType string, so *operation.StorageOption, md5bytes, checksum []byte, s3Checksum *filer.S3ChecksumVerifier, fileChunks []*filer_pb.FileChunk, chunkOffset int64, content []byte) (filerResult *FilerPostResult, replyerr error) {
+
+	if replyerr = verifyUploadChecksum(r, checksum); replyerr != nil {
+		fs.filer.DeleteChunks(fileChunks)
+		return
+	}
 
 	// detect file mode
 	modeStr := r.URL.Query().Get("mode")
@@ -165,6 +255,7 @@ func (fs *FilerServer) saveMetaData(ctx context.Context, r *http.Request, fileNa
 	if entry != nil {
 		entry.Mtime = time.Now()
 		entry.Md5 = nil
+		entry.Sha256 = nil
 		// adjust chunk offsets
 		for _, chunk := range fileChunks {
 			chunk.Offset += int64(entry.FileSize)
@@ -177,18 +268,23 @@ func (fs *FilerServer) saveMetaData(ctx context.Context, r *http.Request, fileNa
 			replyerr = fmt.Errorf("append to small file is not supported yet")
 			return
 		}
+		if filer.IsSseEntry(entry) || filer.IsSseCustomerEntry(entry) {
+			replyerr = fmt.Errorf("append to server side encrypted file is not supported")
+			return
+		}
 
 	} else {
 		glog.V(4).Infoln("saving", path)
 		mergedChunks = fileChunks
+		uid, gid := fs.requestOwner(r)
 		entry = &filer.Entry{
 			FullPath: util.FullPath(path),
 			Attr: filer.Attr{
 				Mtime:       time.Now(),
 				Crtime:      time.Now(),
 				Mode:        os.FileMode(mode),
-				Uid:         OS_UID,
-				Gid:         OS_GID,
+				Uid:         uid,
+				Gid:         gid,
 				Replication: so.Replication,
 				Collection:  so.Collection,
 				TtlSec:      so.TtlSeconds,
@@ -196,13 +292,14 @@ func (fs *FilerServer) saveMetaData(ctx context.Context, r *http.Request, fileNa
 				Mime:        contentType,
 				Md5:         md5bytes,
 				FileSize:    uint64(chunkOffset),
+				Sha256:      checksum,
 			},
 			Content: content,
 		}
 	}
 
 	// maybe compact entry chunks
-	mergedChunks, replyerr = filer.MaybeManifestize(fs.saveAsChunk(so), mergedChunks)
+	mergedChunks, replyerr = filer.MaybeManifestize(fs.saveAsChunk(so, fs.option.Cipher || isSseUpload(r)), mergedChunks)
 	if replyerr != nil {
 		glog.V(0).Infof("manifestize %s: %v", r.RequestURI, replyerr)
 		return
@@ -218,24 +315,54 @@ func (fs *FilerServer) saveMetaData(ctx context.Context, r *http.Request, fileNa
 		entry.Extended = make(map[string][]byte)
 	}
 
-	SaveAmzMetaData(r, entry.Extended, false)
+	entry.Extended = SaveAmzMetaData(r, entry.Extended, false)
+	if isAppend(r) {
+		// the checksum of the appended part only
+		filer.ClearS3Checksums(entry.Extended)
+	} else {
+		s3Checksum.SaveTo(entry.Extended)
+	}
 
 	for k, v := range r.Header {
-		if len(v) > 0 && strings.HasPrefix(k, needle.PairNamePrefix) {
+		if len(v) > 0 && strings.HasPrefix(k, needle.PairNamePrefix) && k != filer.ChecksumHeader {
 			entry.Extended[k] = []byte(v[0])
 		}
 	}
+	if contentEncoding := uploadedContentEncoding(r); contentEncoding != "" {
+		entry.Extended["Content-Encoding"] = []byte(contentEncoding)
+	}
 
+	if isSseUpload(r) {
+		if replyerr = fs.encryptSseEntry(ctx, r, entry); replyerr != nil {
+			fs.filer.DeleteChunks(entry.Chunks)
+			filerResult.Error = replyerr.Error()
+			glog.V(0).Infof("failing to encrypt %s: %v", path, replyerr)
+			return
+		}
+	}
+
+	fs.filer.EnsureChecksum(entry)
+
+	if r.URL.Query().Get("ttl") != "" {
+		ctx = filer.WithTtlSet(ctx)
+	}
 	if dbErr := fs.filer.CreateEntry(ctx, entry, false, false, nil); dbErr != nil {
 		fs.filer.DeleteChunks(fileChunks)
 		replyerr = dbErr
 		filerResult.Error = dbErr.Error()
 		glog.V(0).Infof("failing to write %s to filer server : %v", path, dbErr)
+		return filerResult, replyerr
+	}
+
+	if holdErr := fs.maybeSetHoldOnUpload(ctx, r, path); holdErr != nil {
+		replyerr = holdErr
+		filerResult.Error = holdErr.Error()
+		glog.V(0).Infof("failing to hold %s: %v", path, holdErr)
 	}
 	return filerResult, replyerr
 }
 
-func (fs *FilerServer) saveAsChunk(so *operation.StorageOption) filer.SaveDataAsChunkFunctionType {
+func (fs *FilerServer) saveAsChunk(so *operation.StorageOption, cipher bool) filer.SaveDataAsChunkFunctionType {
 
 	return func(reader io.Reader, name string, offset int64) (*filer_pb.FileChunk, string, string, error) {
 		// assign one file id for one chunk
@@ -245,7 +372,7 @@ func (fs *FilerServer) saveAsChunk(so *operation.StorageOption) filer.SaveDataAs
 		}
 
 		// upload the chunk to the volume server
-		uploadResult, uploadErr, _ := operation.Upload(urlLocation, name, fs.option.Cipher, reader, false, "", nil, auth)
+		uploadResult, uploadErr, _ := operation.Upload(urlLocation, name, cipher, reader, false, "", nil, auth)
 		if uploadErr != nil {
 			return nil, "", "", uploadErr
 		}
@@ -280,14 +407,15 @@ func (fs *FilerServer) mkdir(ctx context.Context, w http.ResponseWriter, r *http
 	}
 
 	glog.V(4).Infoln("mkdir", path)
+	uid, gid := fs.requestOwner(r)
 	entry := &filer.Entry{
 		FullPath: util.FullPath(path),
 		Attr: filer.Attr{
 			Mtime:  time.Now(),
 			Crtime: time.Now(),
 			Mode:   os.FileMode(mode) | os.ModeDir,
-			Uid:    OS_UID,
-			Gid:    OS_GID,
+			Uid:    uid,
+			Gid:    gid,
 		},
 	}
 
@@ -317,14 +445,23 @@ func SaveAmzMetaData(r *http.Request, existing map[string][]byte, isReplace bool
 	}
 
 	if tags := r.Header.Get(xhttp.AmzObjectTagging); tags != "" {
-		for _, v := range strings.Split(tags, "&") {
-			tag := strings.Split(v, "=")
-			if len(tag) == 2 {
-				metadata[xhttp.AmzObjectTagging+"-"+tag[0]] = []byte(tag[1])
+		// the tag keys and values are url encoded
+		if values, err := url.ParseQuery(tags); err == nil {
+			for k, v := range values {
+				metadata[xhttp.AmzObjectTagging+"-"+k] = []byte(v[0])
 			}
 		}
 	}
 
+	if acl := r.Header.Get(xhttp.AmzCannedAcl); acl != "" {
+		metadata[xhttp.AmzCannedAcl] = []byte(acl)
+	}
+
+	if mode, retainUntil, err := parseRetentionHeaders(r); err == nil && mode != "" {
+		metadata[filer.ExtObjectLockModeKey] = []byte(mode)
+		metadata[filer.ExtObjectLockRetainUntilKey] = []byte(retainUntil.UTC().Format(time.RFC3339))
+	}
+
 	for header, values := range r.Header {
 		if strings.HasPrefix(header, xhttp.AmzUserMetaPrefix) {
 			for _, value := range values {
@@ -336,3 +473,15 @@ func SaveAmzMetaData(r *http.Request, existing map[string][]byte, isReplace bool
 	return
 
 }
+
+// uploadedContentEncoding is the encoding applied by the client, to be returned as is when reading the file.
+// The S3 "aws-chunked" transfer encoding is not part of the content.
+func uploadedContentEncoding(r *http.Request) string {
+	var encodings []string
+	for _, encoding := range strings.Split(r.Header.Get("Content-Encoding"), ",") {
+		if encoding = strings.TrimSpace(encoding); encoding != "" && encoding != "aws-chunked" {
+			encodings = append(encodings, encoding)
+		}
+	}
+	return strings.Join(encodings, ",")
+}
@@ -0,0 +1,90 @@
+package weed_server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	xhttp "github.com/chrislusf/seaweedfs/weed/s3api/http"
+)
+
+func TestSaveAmzMetaData(t *testing.T) {
+
+	r, _ := http.NewRequest("PUT", "/buckets/b/o", nil)
+	r.Header.Set(xhttp.AmzObjectTagging, "project=seaweed%20fs&a%26b=c%3Dd")
+	r.Header.Set(xhttp.AmzUserMetaPrefix+"Color", "blue")
+	r.Header.Set(xhttp.AmzCannedAcl, "public-read")
+
+	metadata := SaveAmzMetaData(r, map[string][]byte{"existing": []byte("kept")}, false)
+
+	for k, expected := range map[string]string{
+		xhttp.AmzObjectTagging + "-project": "seaweed fs",
+		xhttp.AmzObjectTagging + "-a&b":     "c=d",
+		xhttp.AmzUserMetaPrefix + "Color":   "blue",
+		xhttp.AmzCannedAcl:                  "public-read",
+		"existing":                          "kept",
+	} {
+		if string(metadata[k]) != expected {
+			t.Errorf("%s: expecting %q, got %q", k, expected, metadata[k])
+		}
+	}
+
+	if _, found := SaveAmzMetaData(r, map[string][]byte{"existing": []byte("kept")}, true)["existing"]; found {
+		t.Errorf("replaced metadata should not keep the existing values")
+	}
+}
+
+func TestRouteUploadInvalidTtl(t *testing.T) {
+
+	fc := filer.NewFilerConf()
+	fc.AddRouteRule(&filer_pb.FilerConf_RouteRule{LocationPrefix: "/tmp/", Ttl: "xd"})
+	fc.AddRouteRule(&filer_pb.FilerConf_RouteRule{LocationPrefix: "/", Ttl: "3d"})
+	fs := &FilerServer{option: &FilerOption{}, filer: &filer.Filer{FilerConf: fc, DirBucketsPath: "/buckets"}}
+
+	r, _ := http.NewRequest("PUT", "/tmp/a.txt", nil)
+	so := &operation.StorageOption{}
+	if err := fs.routeUpload(r, so, "", "", 1); err == nil || !strings.Contains(err.Error(), errInvalidTtl.Error()) {
+		t.Errorf("invalid route ttl: %v", err)
+	}
+
+	r, _ = http.NewRequest("PUT", "/data/a.txt", nil)
+	if err := fs.routeUpload(r, so, "", "", 1); err != nil || so.TtlSeconds != 3*24*3600 {
+		t.Errorf("route ttl: %d %v", so.TtlSeconds, err)
+	}
+
+	if _, err := fs.detectStorageOption0("/data/a.txt", "", "", "xd", "", "", ""); err == nil {
+		t.Errorf("invalid ttl should fail")
+	}
+}
+
+func TestVerifyUploadChecksum(t *testing.T) {
+
+	h := filer.NewChecksumHash()
+	h.Write([]byte("hello"))
+	checksum := h.Sum(nil)
+
+	for _, c := range []struct {
+		header  string
+		isValid bool
+	}{
+		{"", true},
+		{"2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", true},
+		{"2CF24DBA5FB0A30E26E83B2AC5B9E29E1B161E5C1FA7425E73043362938B9824", true},
+		{"0000000000000000000000000000000000000000000000000000000000000000", false},
+	} {
+		r, _ := http.NewRequest("PUT", "/path/to/file", nil)
+		if c.header != "" {
+			r.Header.Set(filer.ChecksumHeader, c.header)
+		}
+		err := verifyUploadChecksum(r, checksum)
+		if c.isValid && err != nil {
+			t.Errorf("checksum %q: %v", c.header, err)
+		}
+		if !c.isValid && (err == nil || !strings.Contains(err.Error(), errChecksumMismatch.Error())) {
+			t.Errorf("checksum %q: expecting mismatch, got %v", c.header, err)
+		}
+	}
+}
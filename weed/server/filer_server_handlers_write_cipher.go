@@ -2,6 +2,7 @@ package weed_server
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"net/http"
 	"strings"
@@ -45,6 +46,8 @@ func (fs *FilerServer) encrypt(ctx context.Context, w http.ResponseWriter, r *ht
 		return nil, fmt.Errorf("upload to volume server: %v", uploadError)
 	}
 
+	checksum := sha256.Sum256(uncompressedData)
+
 	// Save to chunk manifest structure
 	fileChunks := []*filer_pb.FileChunk{uploadResult.ToPbFileChunk(fileId, 0)}
 
@@ -57,20 +60,22 @@ func (fs *FilerServer) encrypt(ctx context.Context, w http.ResponseWriter, r *ht
 		}
 	}
 
+	uid, gid := fs.requestOwner(r)
 	entry := &filer.Entry{
 		FullPath: util.FullPath(path),
 		Attr: filer.Attr{
 			Mtime:       time.Now(),
 			Crtime:      time.Now(),
 			Mode:        0660,
-			Uid:         OS_UID,
-			Gid:         OS_GID,
+			Uid:         uid,
+			Gid:         gid,
 			Replication: so.Replication,
 			Collection:  so.Collection,
 			TtlSec:      so.TtlSeconds,
 			DiskType:    so.DiskType,
 			Mime:        pu.MimeType,
 			Md5:         util.Base64Md5ToBytes(pu.ContentMd5),
+			Sha256:      checksum[:],
 		},
 		Chunks: fileChunks,
 	}
@@ -80,6 +85,9 @@ func (fs *FilerServer) encrypt(ctx context.Context, w http.ResponseWriter, r *ht
 		Size: int64(pu.OriginalDataSize),
 	}
 
+	if r.URL.Query().Get("ttl") != "" {
+		ctx = filer.WithTtlSet(ctx)
+	}
 	if dbErr := fs.filer.CreateEntry(ctx, entry, false, false, nil); dbErr != nil {
 		fs.filer.DeleteChunks(entry.Chunks)
 		err = dbErr
@@ -0,0 +1,101 @@
+package weed_server
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/stats"
+	"github.com/chrislusf/seaweedfs/weed/util/chunker"
+)
+
+// isDedupUpload checks the "dedup" query, or else the dedup for the path in filer.conf. The files with ttl are not deduped.
+func (fs *FilerServer) isDedupUpload(r *http.Request, so *operation.StorageOption) bool {
+	if so.TtlSeconds > 0 {
+		return false
+	}
+	if dedup := r.URL.Query().Get("dedup"); dedup != "" {
+		return dedup == "true"
+	}
+	return fs.filer.FilerConf.MatchStorageRule(r.URL.Path).Dedup
+}
+
+// uploadReaderToDedupChunks splits the content by content defined chunking, and only uploads the chunks not stored yet.
+func (fs *FilerServer) uploadReaderToDedupChunks(w http.ResponseWriter, r *http.Request, reader io.Reader, chunkSize int32, fileName, contentType string, so *operation.StorageOption) ([]*filer_pb.FileChunk, hash.Hash, int64, error, []byte) {
+	var fileChunks []*filer_pb.FileChunk
+
+	md5Hash := md5.New()
+	contentChunker := chunker.New(io.TeeReader(reader, md5Hash), chunker.DefaultOption(int(chunkSize)))
+
+	chunkOffset := int64(0)
+	stages := stats.UploadStagesFromContext(r.Context())
+
+	for {
+		readStart := time.Now()
+		data, err := contentChunker.Next()
+		stages.Since(stats.UploadStageRead, readStart)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fs.filer.DeleteChunks(fileChunks)
+			return nil, nil, 0, err, nil
+		}
+		if chunkOffset == 0 && contentChunker.IsEOF() && !isAppend(r) {
+			if len(data) < int(fs.option.SaveToFilerLimit) || strings.HasPrefix(r.URL.Path, filer.DirectoryEtcRoot) && len(data) < 4*1024 {
+				return nil, md5Hash, int64(len(data)), nil, append([]byte(nil), data...)
+			}
+		}
+
+		chunk, err := fs.uploadDedupChunk(w, r, data, fileName, contentType, chunkOffset, so)
+		if err != nil {
+			fs.filer.DeleteChunks(fileChunks)
+			return nil, nil, 0, err, nil
+		}
+		fileChunks = append(fileChunks, chunk)
+
+		glog.V(4).Infof("deduped %s chunk %d to %s [%d,%d)", fileName, len(fileChunks), chunk.GetFileIdString(), chunkOffset, chunkOffset+int64(len(data)))
+
+		chunkOffset += int64(len(data))
+	}
+
+	return fileChunks, md5Hash, chunkOffset, nil, nil
+}
+
+// uploadDedupChunk references the chunk with the same content if already stored, or else uploads and registers it.
+func (fs *FilerServer) uploadDedupChunk(w http.ResponseWriter, r *http.Request, data []byte, fileName, contentType string, chunkOffset int64, so *operation.StorageOption) (*filer_pb.FileChunk, error) {
+	contentSha256 := sha256.Sum256(data)
+	fingerprint := filer.DedupFingerprint(contentSha256[:], so.Collection, so.Replication, so.DiskType)
+
+	chunk, err := fs.filer.ReferenceDedupChunk(context.Background(), fingerprint, contentSha256[:], nil, chunkOffset)
+	if err != nil {
+		return nil, err
+	}
+	if chunk != nil {
+		stats.FilerRequestCounter.WithLabelValues("chunkDedup").Inc()
+		return chunk, nil
+	}
+
+	uploaded, err := fs.uploadChunk(w, r, data, fileName, contentType, chunkOffset, so)
+	if err != nil {
+		return nil, err
+	}
+	if chunk, err = fs.filer.ReferenceDedupChunk(context.Background(), fingerprint, contentSha256[:], uploaded, chunkOffset); err != nil {
+		fs.filer.DeleteChunks([]*filer_pb.FileChunk{uploaded})
+		return nil, err
+	}
+	if chunk.GetFileIdString() != uploaded.GetFileIdString() {
+		// the same content was uploaded concurrently
+		fs.filer.DeleteChunks([]*filer_pb.FileChunk{uploaded})
+	}
+	return chunk, nil
+}
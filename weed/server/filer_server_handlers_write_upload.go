@@ -16,9 +16,14 @@ import (
 	"github.com/chrislusf/seaweedfs/weed/security"
 	"github.com/chrislusf/seaweedfs/weed/stats"
 	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/chrislusf/seaweedfs/weed/util/codec"
 )
 
 func (fs *FilerServer) uploadReaderToChunks(w http.ResponseWriter, r *http.Request, reader io.Reader, chunkSize int32, fileName, contentType string, contentLength int64, so *operation.StorageOption) ([]*filer_pb.FileChunk, hash.Hash, int64, error, []byte) {
+	if fs.isDedupUpload(r, so) && !isSseUpload(r) {
+		return fs.uploadReaderToDedupChunks(w, r, reader, chunkSize, fileName, contentType, so)
+	}
+
 	var fileChunks []*filer_pb.FileChunk
 
 	md5Hash := md5.New()
@@ -26,62 +31,45 @@ func (fs *FilerServer) uploadReaderToChunks(w http.ResponseWriter, r *http.Reque
 
 	chunkOffset := int64(0)
 	var smallContent []byte
+	stages := stats.UploadStagesFromContext(r.Context())
 
 	for {
 		limitedReader := io.LimitReader(partReader, int64(chunkSize))
 
+		readStart := time.Now()
 		data, err := ioutil.ReadAll(limitedReader)
+		stages.Since(stats.UploadStageRead, readStart)
 		if err != nil {
 			return nil, nil, 0, err, nil
 		}
-		if chunkOffset == 0 && !isAppend(r) {
+		// the server side encrypted content is not kept in the filer store
+		if chunkOffset == 0 && !isAppend(r) && !isSseUpload(r) {
 			if len(data) < int(fs.option.SaveToFilerLimit) || strings.HasPrefix(r.URL.Path, filer.DirectoryEtcRoot) && len(data) < 4*1024 {
 				smallContent = data
 				chunkOffset += int64(len(data))
 				break
 			}
 		}
-		dataReader := util.NewBytesReader(data)
-
-		// retry to assign a different file id
-		var fileId, urlLocation string
-		var auth security.EncodedJwt
-		var assignErr, uploadErr error
-		var uploadResult *operation.UploadResult
-		for i := 0; i < 3; i++ {
-			// assign one file id for one chunk
-			fileId, urlLocation, auth, assignErr = fs.assignNewFileInfo(so)
-			if assignErr != nil {
-				return nil, nil, 0, assignErr, nil
-			}
-
-			// upload the chunk to the volume server
-			uploadResult, uploadErr, _ = fs.doUpload(urlLocation, w, r, dataReader, fileName, contentType, nil, auth)
-			if uploadErr != nil {
-				time.Sleep(251 * time.Millisecond)
-				continue
-			}
-			break
-		}
-		if uploadErr != nil {
-			return nil, nil, 0, uploadErr, nil
+		chunk, err := fs.uploadChunk(w, r, data, fileName, contentType, chunkOffset, so)
+		if err != nil {
+			return nil, nil, 0, err, nil
 		}
 
 		// if last chunk exhausted the reader exactly at the border
-		if uploadResult.Size == 0 {
+		if chunk.Size == 0 {
 			break
 		}
 
 		// Save to chunk manifest structure
-		fileChunks = append(fileChunks, uploadResult.ToPbFileChunk(fileId, chunkOffset))
+		fileChunks = append(fileChunks, chunk)
 
-		glog.V(4).Infof("uploaded %s chunk %d to %s [%d,%d)", fileName, len(fileChunks), fileId, chunkOffset, chunkOffset+int64(uploadResult.Size))
+		glog.V(4).Infof("uploaded %s chunk %d to %s [%d,%d)", fileName, len(fileChunks), chunk.GetFileIdString(), chunkOffset, chunkOffset+int64(chunk.Size))
 
 		// reset variables for the next chunk
-		chunkOffset = chunkOffset + int64(uploadResult.Size)
+		chunkOffset = chunkOffset + int64(chunk.Size)
 
 		// if last chunk was not at full chunk size, but already exhausted the reader
-		if int64(uploadResult.Size) < int64(chunkSize) {
+		if int64(chunk.Size) < int64(chunkSize) {
 			break
 		}
 	}
@@ -89,6 +77,63 @@ func (fs *FilerServer) uploadReaderToChunks(w http.ResponseWriter, r *http.Reque
 	return fileChunks, md5Hash, chunkOffset, nil, smallContent
 }
 
+// uploadChunk uploads the data as one chunk at the offset, encoded by the chunk codec if configured
+func (fs *FilerServer) uploadChunk(w http.ResponseWriter, r *http.Request, data []byte, fileName, contentType string, chunkOffset int64, so *operation.StorageOption) (*filer_pb.FileChunk, error) {
+	stages := stats.UploadStagesFromContext(r.Context())
+
+	var err error
+	plainSize := len(data)
+	if fs.option.ChunkCodec != "" {
+		if data, err = codec.Encode(fs.option.ChunkCodec, data); err != nil {
+			return nil, err
+		}
+	}
+	dataReader := util.NewBytesReader(data)
+
+	if err = fs.filer.CheckQuotasForWrite(util.FullPath(r.URL.Path)); err != nil {
+		return nil, err
+	}
+
+	// retry to assign a different file id
+	var fileId, urlLocation string
+	var auth security.EncodedJwt
+	var assignErr, uploadErr error
+	var uploadResult *operation.UploadResult
+	for i := 0; i < 3; i++ {
+		// assign one file id for one chunk
+		assignStart := time.Now()
+		fileId, urlLocation, auth, assignErr = fs.assignNewFileInfo(so)
+		stages.Since(stats.UploadStageAssign, assignStart)
+		if assignErr != nil {
+			return nil, assignErr
+		}
+
+		// upload the chunk to the volume server
+		uploadStart := time.Now()
+		uploadResult, uploadErr, _ = fs.doUpload(urlLocation, w, r, dataReader, fileName, contentType, nil, auth)
+		uploadDuration := time.Since(uploadStart)
+		stages.Add(stats.UploadStageUpload, uploadDuration)
+		if uploadResult != nil {
+			stages.Add(stats.UploadStageNetwork, uploadDuration-stages.AddServerTiming(uploadResult.ServerTiming))
+		}
+		if uploadErr != nil {
+			time.Sleep(251 * time.Millisecond)
+			continue
+		}
+		break
+	}
+	if uploadErr != nil {
+		return nil, uploadErr
+	}
+
+	chunk := uploadResult.ToPbFileChunk(fileId, chunkOffset)
+	if fs.option.ChunkCodec != "" {
+		// the chunk size is the content size before encoding
+		chunk.Size, chunk.Codec = uint64(plainSize), fs.option.ChunkCodec
+	}
+	return chunk, nil
+}
+
 func (fs *FilerServer) doUpload(urlLocation string, w http.ResponseWriter, r *http.Request, limitedReader io.Reader, fileName string, contentType string, pairMap map[string]string, auth security.EncodedJwt) (*operation.UploadResult, error, []byte) {
 
 	stats.FilerRequestCounter.WithLabelValues("chunkUpload").Inc()
@@ -97,7 +142,8 @@ func (fs *FilerServer) doUpload(urlLocation string, w http.ResponseWriter, r *ht
 		stats.FilerRequestHistogram.WithLabelValues("chunkUpload").Observe(time.Since(start).Seconds())
 	}()
 
-	uploadResult, err, data := operation.Upload(urlLocation, fileName, fs.option.Cipher, limitedReader, false, contentType, pairMap, auth)
+	cipher := fs.option.Cipher || isSseUpload(r)
+	uploadResult, err, data := operation.Upload(urlLocation, fileName, cipher, limitedReader, false, contentType, pairMap, auth)
 	if uploadResult != nil && uploadResult.RetryCount > 0 {
 		stats.FilerRequestCounter.WithLabelValues("chunkUploadRetry").Add(float64(uploadResult.RetryCount))
 	}
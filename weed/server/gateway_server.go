@@ -28,6 +28,7 @@ import (
 	_ "github.com/chrislusf/seaweedfs/weed/notification/google_pub_sub"
 	_ "github.com/chrislusf/seaweedfs/weed/notification/kafka"
 	_ "github.com/chrislusf/seaweedfs/weed/notification/log"
+	_ "github.com/chrislusf/seaweedfs/weed/notification/webhook"
 	"github.com/chrislusf/seaweedfs/weed/security"
 )
 
@@ -15,6 +15,7 @@ import (
 	"github.com/chrislusf/seaweedfs/weed/pb/master_pb"
 	"github.com/chrislusf/seaweedfs/weed/storage/needle"
 	"github.com/chrislusf/seaweedfs/weed/topology"
+	"github.com/chrislusf/seaweedfs/weed/wdclient"
 )
 
 func (ms *MasterServer) SendHeartbeat(stream master_pb.Seaweed_SendHeartbeatServer) error {
@@ -211,7 +212,7 @@ func (ms *MasterServer) KeepConnected(stream master_pb.Seaweed_KeepConnectedServ
 		}
 	}()
 
-	ticker := time.NewTicker(5 * time.Second)
+	ticker := time.NewTicker(wdclient.MasterKeepAliveInterval)
 	for {
 		select {
 		case message := <-messageChan:
@@ -223,6 +224,10 @@ func (ms *MasterServer) KeepConnected(stream master_pb.Seaweed_KeepConnectedServ
 			if !ms.Topo.IsLeader() {
 				return ms.informNewLeader(stream)
 			}
+			// an empty message, so the clients can tell a gone leader from a quiet one
+			if err := stream.Send(&master_pb.VolumeLocation{}); err != nil {
+				return err
+			}
 		case <-stopChan:
 			return nil
 		}
@@ -236,6 +241,9 @@ func (ms *MasterServer) informNewLeader(stream master_pb.Seaweed_KeepConnectedSe
 		glog.Errorf("topo leader: %v", err)
 		return raft.NotLeaderError
 	}
+	if leader == "" {
+		return raft.NotLeaderError
+	}
 	if err := stream.Send(&master_pb.VolumeLocation{
 		Leader: leader,
 	}); err != nil {
@@ -305,8 +313,8 @@ func (ms *MasterServer) ListMasterClients(ctx context.Context, req *master_pb.Li
 
 func (ms *MasterServer) GetMasterConfiguration(ctx context.Context, req *master_pb.GetMasterConfigurationRequest) (*master_pb.GetMasterConfigurationResponse, error) {
 
-	// tell the volume servers about the leader
-	leader, _ := ms.Topo.Leader()
+	// tell the volume servers about the leader, used as the leader hint when the leader is gone
+	leader := ms.Topo.LeaderHint()
 
 	resp := &master_pb.GetMasterConfigurationResponse{
 		MetricsAddress:         ms.option.MetricsAddress,
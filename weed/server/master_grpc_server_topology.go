@@ -0,0 +1,23 @@
+package weed_server
+
+import (
+	"context"
+
+	"github.com/chrislusf/raft"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/master_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// ClusterTopology returns the same health annotated topology as the /cluster/topology handler.
+func (ms *MasterServer) ClusterTopology(ctx context.Context, req *master_pb.ClusterTopologyRequest) (*master_pb.ClusterTopologyResponse, error) {
+
+	if !ms.Topo.IsLeader() {
+		return nil, raft.NotLeaderError
+	}
+
+	return &master_pb.ClusterTopologyResponse{
+		Version:  util.Version(),
+		Topology: ms.Topo.ToClusterTopology().ToClusterTopologyInfo(),
+	}, nil
+}
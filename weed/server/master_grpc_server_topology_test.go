@@ -0,0 +1,22 @@
+package weed_server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chrislusf/raft"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/master_pb"
+	"github.com/chrislusf/seaweedfs/weed/sequence"
+	"github.com/chrislusf/seaweedfs/weed/topology"
+)
+
+func TestClusterTopologyNotLeader(t *testing.T) {
+	topo := topology.NewTopology("weedfs", sequence.NewMemorySequencer(), 32*1024, 5, false)
+	ms := &MasterServer{option: &MasterOption{}, Topo: topo}
+
+	resp, err := ms.ClusterTopology(context.Background(), &master_pb.ClusterTopologyRequest{})
+	assert.Equal(t, raft.NotLeaderError, err)
+	assert.Nil(t, resp)
+}
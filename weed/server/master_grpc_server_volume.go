@@ -11,14 +11,12 @@ import (
 	"github.com/chrislusf/seaweedfs/weed/storage/needle"
 	"github.com/chrislusf/seaweedfs/weed/storage/super_block"
 	"github.com/chrislusf/seaweedfs/weed/topology"
+	"github.com/chrislusf/seaweedfs/weed/util"
 )
 
+// LookupVolume is also served by non-leader masters, with the volume locations learned from the leader.
 func (ms *MasterServer) LookupVolume(ctx context.Context, req *master_pb.LookupVolumeRequest) (*master_pb.LookupVolumeResponse, error) {
 
-	if !ms.Topo.IsLeader() {
-		return nil, raft.NotLeaderError
-	}
-
 	resp := &master_pb.LookupVolumeResponse{}
 	volumeLocations := ms.lookupVolumeId(req.VolumeIds, req.Collection)
 
@@ -46,10 +44,16 @@ func (ms *MasterServer) Assign(ctx context.Context, req *master_pb.AssignRequest
 		return nil, raft.NotLeaderError
 	}
 
+	if err := ms.Topo.CheckClusterWritable(); err != nil {
+		return nil, err
+	}
+
 	if req.Count == 0 {
 		req.Count = 1
 	}
 
+	defaults, _ := ms.Topo.GetCollectionDefaults(req.Collection)
+	req.Replication = util.Nvl(req.Replication, defaults.Replication)
 	if req.Replication == "" {
 		req.Replication = ms.option.DefaultReplicaPlacement
 	}
@@ -57,11 +61,11 @@ func (ms *MasterServer) Assign(ctx context.Context, req *master_pb.AssignRequest
 	if err != nil {
 		return nil, err
 	}
-	ttl, err := needle.ReadTTL(req.Ttl)
+	ttl, err := needle.ReadTTL(util.Nvl(req.Ttl, defaults.Ttl))
 	if err != nil {
 		return nil, err
 	}
-	diskType := types.ToDiskType(req.DiskType)
+	diskType := types.ToDiskType(util.Nvl(req.DiskType, defaults.DiskType))
 
 	option := &topology.VolumeGrowOption{
 		Collection:         req.Collection,
@@ -20,6 +20,7 @@ import (
 	"github.com/chrislusf/seaweedfs/weed/security"
 	"github.com/chrislusf/seaweedfs/weed/sequence"
 	"github.com/chrislusf/seaweedfs/weed/shell"
+	"github.com/chrislusf/seaweedfs/weed/stats"
 	"github.com/chrislusf/seaweedfs/weed/topology"
 	"github.com/chrislusf/seaweedfs/weed/util"
 	"github.com/chrislusf/seaweedfs/weed/wdclient"
@@ -39,6 +40,8 @@ type MasterOption struct {
 	// PulseSeconds            int
 	DefaultReplicaPlacement string
 	GarbageThreshold        float64
+	VacuumWindows           util.TimeWindows
+	VacuumBytePerSecond     int64
 	WhiteList               []string
 	DisableHttp             bool
 	MetricsAddress          string
@@ -104,6 +107,9 @@ func NewMasterServer(r *mux.Router, option *MasterOption, peers []string) *Maste
 	}
 	ms.Topo = topology.NewTopology("topo", seq, uint64(ms.option.VolumeSizeLimitMB)*1024*1024, 5, replicationAsMin)
 	ms.vg = topology.NewDefaultVolumeGrowth()
+	if err := ms.vg.LoadPlacementStrategies(); err != nil {
+		glog.Fatalf("placement strategies: %v", err)
+	}
 	glog.V(0).Infoln("Volume Size Limit is", ms.option.VolumeSizeLimitMB, "MB")
 
 	ms.guard = security.NewGuard(ms.option.WhiteList, signingKey, expiresAfterSec, readSigningKey, readExpiresAfterSec)
@@ -116,10 +122,23 @@ func NewMasterServer(r *mux.Router, option *MasterOption, peers []string) *Maste
 		r.HandleFunc("/dir/lookup", ms.guard.WhiteList(ms.dirLookupHandler))
 		r.HandleFunc("/dir/status", ms.proxyToLeader(ms.guard.WhiteList(ms.dirStatusHandler)))
 		r.HandleFunc("/col/delete", ms.proxyToLeader(ms.guard.WhiteList(ms.collectionDeleteHandler)))
+		r.HandleFunc("/col/defaults", ms.proxyToLeader(ms.guard.WhiteList(ms.collectionDefaultsHandler)))
+		r.HandleFunc("/col/defaults/set", ms.proxyToLeader(ms.guard.WhiteList(ms.collectionDefaultsSetHandler)))
+		r.HandleFunc("/col/defaults/delete", ms.proxyToLeader(ms.guard.WhiteList(ms.collectionDefaultsDeleteHandler)))
 		r.HandleFunc("/vol/grow", ms.proxyToLeader(ms.guard.WhiteList(ms.volumeGrowHandler)))
 		r.HandleFunc("/vol/status", ms.proxyToLeader(ms.guard.WhiteList(ms.volumeStatusHandler)))
 		r.HandleFunc("/vol/vacuum", ms.proxyToLeader(ms.guard.WhiteList(ms.volumeVacuumHandler)))
+		r.HandleFunc("/stats/capacity", ms.proxyToLeader(ms.guard.WhiteList(ms.capacityStatusHandler)))
+		r.HandleFunc("/cluster/plan", ms.proxyToLeader(ms.guard.WhiteList(ms.capacityPlanHandler)))
+		r.HandleFunc("/cluster/topology", ms.proxyToLeader(ms.guard.WhiteList(ms.clusterTopologyHandler)))
+		r.HandleFunc("/cluster/health", ms.proxyToLeader(ms.guard.WhiteList(ms.clusterHealthHandler)))
+		r.HandleFunc("/cluster/maintenance", ms.proxyToLeader(ms.guard.WhiteList(ms.maintenanceStatusHandler)))
+		r.HandleFunc("/cluster/maintenance/enter", ms.proxyToLeader(ms.guard.WhiteList(ms.maintenanceEnterHandler)))
+		r.HandleFunc("/cluster/maintenance/exit", ms.proxyToLeader(ms.guard.WhiteList(ms.maintenanceExitHandler)))
+		r.HandleFunc("/cluster/readonly", ms.guard.WhiteList(ms.clusterReadOnlyHandler))
+		r.HandleFunc("/cluster/readonly/set", ms.proxyToLeader(ms.guard.WhiteList(ms.clusterReadOnlySetHandler)))
 		r.HandleFunc("/submit", ms.guard.WhiteList(ms.submitFromMasterServerHandler))
+		r.HandleFunc("/stats/config", ms.guard.WhiteList(statsConfigHandler))
 		/*
 			r.HandleFunc("/stats/health", ms.guard.WhiteList(statsHealthHandler))
 			r.HandleFunc("/stats/counter", ms.guard.WhiteList(statsCounterHandler))
@@ -128,15 +147,25 @@ func NewMasterServer(r *mux.Router, option *MasterOption, peers []string) *Maste
 		r.HandleFunc("/{fileId}", ms.redirectHandler)
 	}
 
+	ms.Topo.SetVacuumSchedule(topology.VacuumSchedule{
+		Windows:                 ms.option.VacuumWindows,
+		CompactionBytePerSecond: ms.option.VacuumBytePerSecond,
+	})
 	ms.Topo.StartRefreshWritableVolumes(ms.grpcDialOption, ms.option.GarbageThreshold, ms.preallocateSize)
 
 	ms.startAdminScripts()
+	ms.startVolumeBalancer()
+	ms.startAutoTierer()
+
+	go ms.loopUpdateCapacityMetrics()
+	go stats.LoopPushingMetric("master", fmt.Sprintf("%s:%d", ms.option.Host, ms.option.Port), ms.option.MetricsAddress, ms.option.MetricsIntervalSec)
 
 	return ms
 }
 
 func (ms *MasterServer) SetRaftServer(raftServer *RaftServer) {
 	ms.Topo.RaftServer = raftServer.raftServer
+	go ms.Topo.LoopRenewingLeaderLease()
 	ms.Topo.RaftServer.AddEventListener(raft.LeaderChangeEventType, func(e raft.Event) {
 		glog.V(0).Infof("leader change event: %+v => %+v", e.PrevValue(), e.Value())
 		if ms.Topo.RaftServer.Leader() != "" {
@@ -152,11 +181,16 @@ func (ms *MasterServer) SetRaftServer(raftServer *RaftServer) {
 	}
 }
 
+// WhiteList only allows the white listed addresses, for the handlers registered outside of the master server
+func (ms *MasterServer) WhiteList(f http.HandlerFunc) http.HandlerFunc {
+	return ms.guard.WhiteList(f)
+}
+
 func (ms *MasterServer) proxyToLeader(f http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if ms.Topo.IsLeader() {
 			f(w, r)
-		} else if ms.Topo.RaftServer != nil && ms.Topo.RaftServer.Leader() != "" {
+		} else if ms.Topo.LeaderHint() != "" {
 			ms.boundedLeaderChan <- 1
 			defer func() { <-ms.boundedLeaderChan }()
 			targetUrl, err := url.Parse("http://" + ms.Topo.RaftServer.Leader())
@@ -177,6 +211,9 @@ func (ms *MasterServer) proxyToLeader(f http.HandlerFunc) http.HandlerFunc {
 			}
 			proxy.Transport = util.Transport
 			proxy.ServeHTTP(w, r)
+		} else if ms.Topo.RaftServer != nil && ms.Topo.RaftServer.Leader() == ms.Topo.RaftServer.Name() {
+			// the leader without the leader lease, the clients should retry with the new leader
+			writeJsonError(w, r, http.StatusServiceUnavailable, fmt.Errorf("%s has lost the leader lease", ms.Topo.RaftServer.Name()))
 		} else {
 			// drop it to the floor
 			// writeJsonError(w, r, errors.New(ms.Topo.RaftServer.Name()+" does not know Leader yet:"+ms.Topo.RaftServer.Leader()))
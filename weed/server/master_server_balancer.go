@@ -0,0 +1,63 @@
+package weed_server
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/security"
+	"github.com/chrislusf/seaweedfs/weed/shell"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// startVolumeBalancer continuously moves the volumes to even out the volume servers, if "master.balancer.enabled".
+// The volume servers in maintenance are left out.
+func (ms *MasterServer) startVolumeBalancer() {
+	v := util.GetViper()
+	if !v.GetBool("master.balancer.enabled") {
+		return
+	}
+
+	v.SetDefault("master.balancer.interval_minutes", 10)
+	v.SetDefault("master.balancer.threshold", 0.05)
+	v.SetDefault("master.balancer.max_moves_per_round", 4)
+	v.SetDefault("master.balancer.max_bytes_per_round", "100GiB")
+
+	maxBytesPerRound, err := humanize.ParseBytes(v.GetString("master.balancer.max_bytes_per_round"))
+	if err != nil {
+		glog.Fatalf("invalid master.balancer.max_bytes_per_round %s: %v", v.GetString("master.balancer.max_bytes_per_round"), err)
+	}
+	windows, err := util.ParseTimeWindows(v.GetString("master.balancer.windows"))
+	if err != nil {
+		glog.Fatalf("invalid master.balancer.windows %s: %v", v.GetString("master.balancer.windows"), err)
+	}
+	intervalMinutes := v.GetInt("master.balancer.interval_minutes")
+	if intervalMinutes <= 0 {
+		glog.Fatalf("invalid master.balancer.interval_minutes %d", intervalMinutes)
+	}
+
+	option := shell.VolumeBalancerOption{
+		Interval:         time.Duration(intervalMinutes) * time.Minute,
+		Threshold:        v.GetFloat64("master.balancer.threshold"),
+		MaxMovesPerRound: v.GetInt("master.balancer.max_moves_per_round"),
+		MaxBytesPerRound: maxBytesPerRound,
+		Windows:          windows,
+		SkipDataNode:     ms.Topo.IsDataNodeInMaintenance,
+	}
+	glog.V(0).Infof("volume balancer: every %v, threshold %.2f, at most %d volumes and %s per round, windows %s",
+		option.Interval, option.Threshold, option.MaxMovesPerRound, humanize.IBytes(option.MaxBytesPerRound), windows)
+
+	masterAddress := fmt.Sprintf("%s:%d", ms.option.Host, ms.option.Port)
+	var shellOptions shell.ShellOptions
+	shellOptions.GrpcDialOption = security.LoadClientTLS(v, "grpc.master")
+	shellOptions.Masters = &masterAddress
+	shellOptions.Directory = "/"
+
+	commandEnv := shell.NewCommandEnv(shellOptions)
+	go commandEnv.MasterClient.KeepConnectedToMaster()
+
+	shell.NewVolumeBalancer(commandEnv, option).Start(ms.Topo.IsLeader, os.Stdout)
+}
@@ -0,0 +1,88 @@
+package weed_server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/stats"
+	"github.com/chrislusf/seaweedfs/weed/topology"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// capacityStatusHandler reports the logical, physical, and reclaimable capacity of the cluster and each collection.
+// Use "collection" to report only one collection.
+func (ms *MasterServer) capacityStatusHandler(w http.ResponseWriter, r *http.Request) {
+	total, collections := ms.Topo.ToCapacity()
+	if collection, found := r.URL.Query()["collection"]; found {
+		var selected []*topology.Capacity
+		for _, c := range collections {
+			if c.Collection == collection[0] {
+				selected = append(selected, c)
+			}
+		}
+		collections = selected
+	}
+	if collections == nil {
+		collections = []*topology.Capacity{}
+	}
+	m := make(map[string]interface{})
+	m["Version"] = util.Version()
+	m["Total"] = total
+	m["Collections"] = collections
+	writeJsonQuiet(w, r, http.StatusOK, m)
+}
+
+// capacityPlanHandler forecasts when the cluster runs out of volume slots, and recommends the volumes to grow.
+// Each "growth" is "<collection>:<replication>:<disk type>:<size per day>", and "days" is the planning horizon.
+func (ms *MasterServer) capacityPlanHandler(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	if len(r.Form["growth"]) == 0 {
+		writeJsonError(w, r, http.StatusBadRequest, fmt.Errorf("missing growth"))
+		return
+	}
+	var growths []*topology.CollectionGrowth
+	for _, spec := range r.Form["growth"] {
+		growth, err := topology.ParseCollectionGrowth(spec, ms.option.DefaultReplicaPlacement)
+		if err != nil {
+			writeJsonError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		growths = append(growths, growth)
+	}
+	days := 30
+	if r.FormValue("days") != "" {
+		var err error
+		if days, err = strconv.Atoi(r.FormValue("days")); err != nil || days <= 0 {
+			writeJsonError(w, r, http.StatusBadRequest, fmt.Errorf("invalid days %s", r.FormValue("days")))
+			return
+		}
+	}
+	plan, err := ms.Topo.PlanCapacity(growths, days)
+	if err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	writeJsonQuiet(w, r, http.StatusOK, plan)
+}
+
+func (ms *MasterServer) loopUpdateCapacityMetrics() {
+	for range time.Tick(time.Duration(ms.option.MetricsIntervalSec+1) * time.Second) {
+		// only the leader has the latest volume information
+		if !ms.Topo.IsLeader() {
+			stats.MasterCapacityGauge.Reset()
+			stats.MasterGarbageRatioGauge.Reset()
+			continue
+		}
+		_, collections := ms.Topo.ToCapacity()
+		stats.MasterCapacityGauge.Reset()
+		stats.MasterGarbageRatioGauge.Reset()
+		for _, c := range collections {
+			stats.MasterCapacityGauge.WithLabelValues(c.Collection, "logical").Set(float64(c.LogicalSize))
+			stats.MasterCapacityGauge.WithLabelValues(c.Collection, "physical").Set(float64(c.PhysicalSize))
+			stats.MasterCapacityGauge.WithLabelValues(c.Collection, "reclaimable").Set(float64(c.ReclaimableSize))
+			stats.MasterGarbageRatioGauge.WithLabelValues(c.Collection).Set(c.GarbageRatio)
+		}
+	}
+}
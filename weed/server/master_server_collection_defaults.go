@@ -0,0 +1,64 @@
+package weed_server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/chrislusf/seaweedfs/weed/topology"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// collectionDefaultsHandler lists the defaults of all collections, or only the "collection".
+func (ms *MasterServer) collectionDefaultsHandler(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	list := []*topology.CollectionDefaults{}
+	if _, found := r.Form["collection"]; found {
+		if defaults, found := ms.Topo.GetCollectionDefaults(r.FormValue("collection")); found {
+			list = append(list, &defaults)
+		}
+	} else {
+		list = append(list, ms.Topo.ListCollectionDefaults()...)
+	}
+	m := make(map[string]interface{})
+	m["Version"] = util.Version()
+	m["Collections"] = list
+	writeJsonQuiet(w, r, http.StatusOK, m)
+}
+
+// collectionDefaultsSetHandler replaces the defaults of the "collection" with the "replication", "ttl", "disk",
+// "ecLayout" and "maxVolumeCount", used by the assign and grow requests leaving them empty.
+func (ms *MasterServer) collectionDefaultsSetHandler(w http.ResponseWriter, r *http.Request) {
+	defaults := &topology.CollectionDefaults{
+		Collection:  r.FormValue("collection"),
+		Replication: r.FormValue("replication"),
+		Ttl:         r.FormValue("ttl"),
+		DiskType:    r.FormValue("disk"),
+		EcLayout:    r.FormValue("ecLayout"),
+	}
+	if r.FormValue("maxVolumeCount") != "" {
+		maxVolumeCount, err := strconv.Atoi(r.FormValue("maxVolumeCount"))
+		if err != nil {
+			writeJsonError(w, r, http.StatusBadRequest, fmt.Errorf("invalid maxVolumeCount %s", r.FormValue("maxVolumeCount")))
+			return
+		}
+		defaults.MaxVolumeCount = maxVolumeCount
+	}
+	if err := ms.Topo.SetCollectionDefaults(defaults); err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	m := make(map[string]interface{})
+	m["Version"] = util.Version()
+	m["Collections"] = []*topology.CollectionDefaults{defaults}
+	writeJsonQuiet(w, r, http.StatusOK, m)
+}
+
+// collectionDefaultsDeleteHandler removes the defaults of the "collection".
+func (ms *MasterServer) collectionDefaultsDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	if err := ms.Topo.DeleteCollectionDefaults(r.FormValue("collection")); err != nil {
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
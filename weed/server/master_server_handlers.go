@@ -95,6 +95,10 @@ func (ms *MasterServer) findVolumeLocation(collection, vid string) operation.Loo
 
 func (ms *MasterServer) dirAssignHandler(w http.ResponseWriter, r *http.Request) {
 	stats.AssignRequest()
+	if err := ms.Topo.CheckClusterWritable(); err != nil {
+		writeJsonQuiet(w, r, http.StatusServiceUnavailable, operation.AssignResult{Error: err.Error()})
+		return
+	}
 	requestedCount, e := strconv.ParseUint(r.FormValue("count"), 10, 64)
 	if e != nil || requestedCount == 0 {
 		requestedCount = 1
@@ -142,7 +142,8 @@ func (ms *MasterServer) HasWritableVolume(option *topology.VolumeGrowOption) boo
 }
 
 func (ms *MasterServer) getVolumeGrowOption(r *http.Request) (*topology.VolumeGrowOption, error) {
-	replicationString := r.FormValue("replication")
+	defaults, _ := ms.Topo.GetCollectionDefaults(r.FormValue("collection"))
+	replicationString := util.Nvl(r.FormValue("replication"), r.Header.Get(ReplicationHeader), defaults.Replication)
 	if replicationString == "" {
 		replicationString = ms.option.DefaultReplicaPlacement
 	}
@@ -150,7 +151,7 @@ func (ms *MasterServer) getVolumeGrowOption(r *http.Request) (*topology.VolumeGr
 	if err != nil {
 		return nil, err
 	}
-	ttl, err := needle.ReadTTL(r.FormValue("ttl"))
+	ttl, err := needle.ReadTTL(util.Nvl(r.FormValue("ttl"), defaults.Ttl))
 	if err != nil {
 		return nil, err
 	}
@@ -158,7 +159,7 @@ func (ms *MasterServer) getVolumeGrowOption(r *http.Request) (*topology.VolumeGr
 	if err != nil {
 		return nil, err
 	}
-	diskType := types.ToDiskType(r.FormValue("disk"))
+	diskType := types.ToDiskType(util.Nvl(r.FormValue("disk"), defaults.DiskType))
 
 	preallocate := ms.preallocateSize
 	if r.FormValue("preallocate") != "" {
@@ -0,0 +1,34 @@
+package weed_server
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/chrislusf/seaweedfs/weed/sequence"
+	"github.com/chrislusf/seaweedfs/weed/topology"
+)
+
+func TestVolumeGrowOptionCollectionDefaults(t *testing.T) {
+	topo := topology.NewTopology("weedfs", sequence.NewMemorySequencer(), 32*1024, 5, false)
+	assert.NoError(t, topo.SetCollectionDefaults(&topology.CollectionDefaults{Collection: "logs", Replication: "010", Ttl: "7d", DiskType: "ssd"}))
+	ms := &MasterServer{option: &MasterOption{DefaultReplicaPlacement: "000"}, Topo: topo}
+
+	option, err := ms.getVolumeGrowOption(httptest.NewRequest("GET", "/dir/assign?collection=logs", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, "010", option.ReplicaPlacement.String())
+	assert.Equal(t, "7d", option.Ttl.String())
+	assert.Equal(t, "ssd", string(option.DiskType))
+
+	// the request values are kept
+	option, err = ms.getVolumeGrowOption(httptest.NewRequest("GET", "/dir/assign?collection=logs&ttl=3h&replication=001", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, "001", option.ReplicaPlacement.String())
+	assert.Equal(t, "3h", option.Ttl.String())
+
+	option, err = ms.getVolumeGrowOption(httptest.NewRequest("GET", "/dir/assign?collection=other", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, "000", option.ReplicaPlacement.String())
+	assert.Equal(t, "", option.Ttl.String())
+}
@@ -0,0 +1,60 @@
+package weed_server
+
+import (
+	"net/http"
+
+	"github.com/chrislusf/seaweedfs/weed/topology"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// maintenanceStatusHandler reports the data centers, racks and data nodes in maintenance,
+// and whether it is safe to power them down. Use "dataCenter", "rack" or "node" to report only one of them.
+func (ms *MasterServer) maintenanceStatusHandler(w http.ResponseWriter, r *http.Request) {
+	dataCenter, rack, dataNode := r.FormValue("dataCenter"), r.FormValue("rack"), r.FormValue("node")
+	statuses := []*topology.MaintenanceStatus{}
+	if dataCenter != "" || rack != "" || dataNode != "" {
+		status, err := ms.Topo.MaintenanceStatusOf(dataCenter, rack, dataNode)
+		if err != nil {
+			writeJsonError(w, r, http.StatusNotFound, err)
+			return
+		}
+		statuses = append(statuses, status)
+	} else {
+		statuses = append(statuses, ms.Topo.MaintenanceStatus()...)
+	}
+	m := make(map[string]interface{})
+	m["Version"] = util.Version()
+	m["Maintenance"] = statuses
+	writeJsonQuiet(w, r, http.StatusOK, m)
+}
+
+// maintenanceEnterHandler stops assigning new volumes and new writes to the "dataCenter", the "rack" of the data center,
+// or the data "node", and reports the volumes not safe to power down yet.
+func (ms *MasterServer) maintenanceEnterHandler(w http.ResponseWriter, r *http.Request) {
+	scope, err := ms.Topo.EnterMaintenance(r.FormValue("dataCenter"), r.FormValue("rack"), r.FormValue("node"))
+	if err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	status, err := ms.Topo.MaintenanceStatusOf(scope.DataCenter, scope.Rack, scope.DataNode)
+	if err != nil {
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	m := make(map[string]interface{})
+	m["Version"] = util.Version()
+	m["Maintenance"] = []*topology.MaintenanceStatus{status}
+	writeJsonQuiet(w, r, http.StatusOK, m)
+}
+
+// maintenanceExitHandler takes the "dataCenter", the "rack" of the data center, or the data "node" out of maintenance.
+func (ms *MasterServer) maintenanceExitHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := ms.Topo.ExitMaintenance(r.FormValue("dataCenter"), r.FormValue("rack"), r.FormValue("node")); err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	m := make(map[string]interface{})
+	m["Version"] = util.Version()
+	m["Maintenance"] = append([]*topology.MaintenanceStatus{}, ms.Topo.MaintenanceStatus()...)
+	writeJsonQuiet(w, r, http.StatusOK, m)
+}
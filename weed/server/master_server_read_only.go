@@ -0,0 +1,36 @@
+package weed_server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// clusterReadOnlyHandler reports whether the cluster is read only, polled by the filers.
+func (ms *MasterServer) clusterReadOnlyHandler(w http.ResponseWriter, r *http.Request) {
+	m := make(map[string]interface{})
+	m["Version"] = util.Version()
+	m["ReadOnly"] = ms.Topo.GetClusterReadOnly()
+	writeJsonQuiet(w, r, http.StatusOK, m)
+}
+
+// clusterReadOnlySetHandler switches the cluster to read only with "readOnly=true" and the "reason",
+// rejecting all new assignments and writes, or back to writable with "readOnly=false".
+func (ms *MasterServer) clusterReadOnlySetHandler(w http.ResponseWriter, r *http.Request) {
+	readOnly, err := strconv.ParseBool(r.FormValue("readOnly"))
+	if err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, fmt.Errorf("invalid readOnly %q", r.FormValue("readOnly")))
+		return
+	}
+	state, err := ms.Topo.SetClusterReadOnly(readOnly, r.FormValue("reason"))
+	if err != nil {
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	m := make(map[string]interface{})
+	m["Version"] = util.Version()
+	m["ReadOnly"] = state
+	writeJsonQuiet(w, r, http.StatusOK, m)
+}
@@ -0,0 +1,73 @@
+package weed_server
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/security"
+	"github.com/chrislusf/seaweedfs/weed/shell"
+	"github.com/chrislusf/seaweedfs/weed/storage/types"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// startAutoTierer periodically moves the hot and cold volumes with the policy in "master.tier", if enabled.
+func (ms *MasterServer) startAutoTierer() {
+	v := util.GetViper()
+	if !v.GetBool("master.tier.enabled") {
+		return
+	}
+
+	v.SetDefault("master.tier.interval_minutes", 60)
+	v.SetDefault("master.tier.collection", "EACH_COLLECTION")
+	v.SetDefault("master.tier.hot_reads_per_day", 1000)
+	v.SetDefault("master.tier.hot_disk_type", "ssd")
+	v.SetDefault("master.tier.cold_reads_per_day", 10)
+	v.SetDefault("master.tier.cold_disk_type", "hdd")
+	v.SetDefault("master.tier.full_percent", 95)
+	v.SetDefault("master.tier.quiet_for", "24h")
+	v.SetDefault("master.tier.tracked_for", "24h")
+
+	intervalMinutes := v.GetInt("master.tier.interval_minutes")
+	if intervalMinutes <= 0 {
+		glog.Fatalf("invalid master.tier.interval_minutes %d", intervalMinutes)
+	}
+	quietFor, err := time.ParseDuration(v.GetString("master.tier.quiet_for"))
+	if err != nil {
+		glog.Fatalf("invalid master.tier.quiet_for %s: %v", v.GetString("master.tier.quiet_for"), err)
+	}
+	trackedFor, err := time.ParseDuration(v.GetString("master.tier.tracked_for"))
+	if err != nil {
+		glog.Fatalf("invalid master.tier.tracked_for %s: %v", v.GetString("master.tier.tracked_for"), err)
+	}
+
+	option := shell.AutoTierOption{
+		Interval:        time.Duration(intervalMinutes) * time.Minute,
+		Collection:      v.GetString("master.tier.collection"),
+		HotReadsPerDay:  uint64(v.GetInt64("master.tier.hot_reads_per_day")),
+		HotDiskType:     types.ToDiskType(v.GetString("master.tier.hot_disk_type")),
+		ColdReadsPerDay: uint64(v.GetInt64("master.tier.cold_reads_per_day")),
+		ColdDiskType:    types.ToDiskType(v.GetString("master.tier.cold_disk_type")),
+		ColdDest:        v.GetString("master.tier.cold_dest"),
+		FullPercentage:  v.GetFloat64("master.tier.full_percent"),
+		QuietFor:        quietFor,
+		TrackedFor:      trackedFor,
+	}
+	if err = option.Validate(); err != nil {
+		glog.Fatalf("invalid master.tier: %v", err)
+	}
+	glog.V(0).Infof("auto tierer: every %v, hot at %d reads per day to %s, cold under %d reads per day to %s %s",
+		option.Interval, option.HotReadsPerDay, option.HotDiskType.ReadableString(), option.ColdReadsPerDay, option.ColdDiskType.ReadableString(), option.ColdDest)
+
+	masterAddress := fmt.Sprintf("%s:%d", ms.option.Host, ms.option.Port)
+	var shellOptions shell.ShellOptions
+	shellOptions.GrpcDialOption = security.LoadClientTLS(v, "grpc.master")
+	shellOptions.Masters = &masterAddress
+	shellOptions.Directory = "/"
+
+	commandEnv := shell.NewCommandEnv(shellOptions)
+	go commandEnv.MasterClient.KeepConnectedToMaster()
+
+	shell.NewAutoTierer(commandEnv, option).Start(ms.Topo.IsLeader, os.Stdout)
+}
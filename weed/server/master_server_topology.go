@@ -0,0 +1,37 @@
+package weed_server
+
+import (
+	"net/http"
+
+	"github.com/chrislusf/seaweedfs/weed/topology"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// clusterTopologyHandler reports the data centers, racks, data nodes, disks, volumes and ec shards,
+// with the free slots and the health of each part.
+// The gRPC clients get the same topology with the ClusterTopology rpc.
+func (ms *MasterServer) clusterTopologyHandler(w http.ResponseWriter, r *http.Request) {
+	m := make(map[string]interface{})
+	m["Version"] = util.Version()
+	m["Topology"] = ms.Topo.ToClusterTopology()
+	writeJsonQuiet(w, r, http.StatusOK, m)
+}
+
+// clusterHealthHandler reports the health of the whole cluster, with all the problems found in the topology.
+// The status code is 503 if the health is critical, for the health checks only looking at the status code.
+func (ms *MasterServer) clusterHealthHandler(w http.ResponseWriter, r *http.Request) {
+	ct := ms.Topo.ToClusterTopology()
+	problems := ct.AllProblems()
+	if problems == nil {
+		problems = []string{}
+	}
+	m := make(map[string]interface{})
+	m["Version"] = util.Version()
+	m["Status"] = ct.Health.Status
+	m["Problems"] = problems
+	status := http.StatusOK
+	if ct.Health.Status == topology.HealthCritical {
+		status = http.StatusServiceUnavailable
+	}
+	writeJsonQuiet(w, r, status, m)
+}
@@ -2,10 +2,14 @@ package weed_server
 
 import (
 	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"os"
 	"path"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"google.golang.org/grpc"
@@ -15,6 +19,7 @@ import (
 	"github.com/chrislusf/raft"
 
 	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
 	"github.com/chrislusf/seaweedfs/weed/topology"
 )
 
@@ -25,6 +30,8 @@ type RaftServer struct {
 	serverAddr string
 	topo       *topology.Topology
 	*raft.GrpcServer
+
+	snapshotLock sync.Mutex
 }
 
 type StateMachine struct {
@@ -32,31 +39,94 @@ type StateMachine struct {
 	topo *topology.Topology
 }
 
+// RaftState is the master state kept in the raft snapshots.
+// It can also be recovered from the older snapshots with only the max volume id.
+type RaftState struct {
+	MaxVolumeId needle.VolumeId `json:"maxVolumeId"`
+	MaxFileKey  uint64          `json:"maxFileKey,omitempty"`
+
+	CollectionDefaults []*topology.CollectionDefaults `json:"collectionDefaults,omitempty"`
+	ReadOnly           topology.ClusterReadOnly       `json:"readOnly"`
+	MaintenanceScopes  []*topology.MaintenanceScope   `json:"maintenanceScopes,omitempty"`
+}
+
 func (s StateMachine) Save() ([]byte, error) {
-	state := topology.MaxVolumeIdCommand{
+	state := RaftState{
 		MaxVolumeId: s.topo.GetMaxVolumeId(),
+		MaxFileKey:  s.topo.Sequence.Peek(),
+
+		CollectionDefaults: s.topo.ListCollectionDefaults(),
+		ReadOnly:           s.topo.GetClusterReadOnly(),
+		MaintenanceScopes:  s.topo.ListMaintenanceScopes(),
 	}
 	glog.V(1).Infof("Save raft state %+v", state)
 	return json.Marshal(state)
 }
 
 func (s StateMachine) Recovery(data []byte) error {
-	state := topology.MaxVolumeIdCommand{}
+	state := RaftState{}
 	err := json.Unmarshal(data, &state)
 	if err != nil {
 		return err
 	}
 	glog.V(1).Infof("Recovery raft state %+v", state)
 	s.topo.UpAdjustMaxVolumeId(state.MaxVolumeId)
+	if state.MaxFileKey > 0 {
+		s.topo.Sequence.SetMax(state.MaxFileKey)
+	}
+	s.topo.RecoverCollectionDefaults(state.CollectionDefaults)
+	s.topo.RecoverClusterReadOnly(state.ReadOnly)
+	s.topo.RecoverMaintenanceScopes(state.MaintenanceScopes)
 	return nil
 }
 
-func NewRaftServer(grpcDialOption grpc.DialOption, peers []string, serverAddr, dataDir string, topo *topology.Topology, raftResumeState bool) (*RaftServer, error) {
+type RaftServerOption struct {
+	GrpcDialOption  grpc.DialOption
+	Peers           []string
+	ServerAddr      string
+	DataDir         string
+	Topo            *topology.Topology
+	RaftResumeState bool
+	// with up to 50% jitter, 0 to derive it from the election timeout
+	HeartbeatInterval time.Duration
+	// the leader lease is half of the election timeout
+	ElectionTimeout time.Duration
+}
+
+const maxRaftHeartbeatInterval = 300 * time.Millisecond
+
+// heartbeatInterval is at most a third of the leader lease with the jitter,
+// so the leader renews its lease even if a heartbeat response is lost.
+func (option *RaftServerOption) heartbeatInterval() (time.Duration, error) {
+	lease := option.ElectionTimeout / 2
+	maxInterval := lease / 3 * 2 / 3
+	if option.HeartbeatInterval == 0 {
+		if maxInterval > maxRaftHeartbeatInterval {
+			return maxRaftHeartbeatInterval, nil
+		}
+		return maxInterval, nil
+	}
+	if option.HeartbeatInterval > maxInterval {
+		return 0, fmt.Errorf("raft heartbeat interval %v exceeds %v, a third of the leader lease %v with the jitter",
+			option.HeartbeatInterval, maxInterval, lease)
+	}
+	return option.HeartbeatInterval, nil
+}
+
+func NewRaftServer(option *RaftServerOption) (*RaftServer, error) {
+	heartbeatInterval, err := option.heartbeatInterval()
+	if err != nil {
+		return nil, err
+	}
+	if heartbeatInterval <= 0 {
+		return nil, fmt.Errorf("raft election timeout %v is too short", option.ElectionTimeout)
+	}
+
 	s := &RaftServer{
-		peers:      peers,
-		serverAddr: serverAddr,
-		dataDir:    dataDir,
-		topo:       topo,
+		peers:      option.Peers,
+		serverAddr: option.ServerAddr,
+		dataDir:    option.DataDir,
+		topo:       option.Topo,
 	}
 
 	if glog.V(4) {
@@ -64,12 +134,14 @@ func NewRaftServer(grpcDialOption grpc.DialOption, peers []string, serverAddr, d
 	}
 
 	raft.RegisterCommand(&topology.MaxVolumeIdCommand{})
+	raft.RegisterCommand(&topology.CollectionDefaultsCommand{})
+	raft.RegisterCommand(&topology.ClusterReadOnlyCommand{})
+	raft.RegisterCommand(&topology.MaintenanceCommand{})
 
-	var err error
-	transporter := raft.NewGrpcTransporter(grpcDialOption)
-	glog.V(0).Infof("Starting RaftServer with %v", serverAddr)
+	transporter := raft.NewGrpcTransporter(option.GrpcDialOption)
+	glog.V(0).Infof("Starting RaftServer with %v", s.serverAddr)
 
-	if !raftResumeState {
+	if !option.RaftResumeState {
 		// always clear previous metadata
 		os.RemoveAll(path.Join(s.dataDir, "conf"))
 		os.RemoveAll(path.Join(s.dataDir, "log"))
@@ -79,14 +151,14 @@ func NewRaftServer(grpcDialOption grpc.DialOption, peers []string, serverAddr, d
 		return nil, err
 	}
 
-	stateMachine := StateMachine{topo: topo}
-	s.raftServer, err = raft.NewServer(s.serverAddr, s.dataDir, transporter, stateMachine, topo, "")
+	stateMachine := StateMachine{topo: option.Topo}
+	s.raftServer, err = raft.NewServer(s.serverAddr, s.dataDir, transporter, stateMachine, option.Topo, "")
 	if err != nil {
 		glog.V(0).Infoln(err)
 		return nil, err
 	}
-	s.raftServer.SetHeartbeatInterval(time.Duration(300+rand.Intn(150)) * time.Millisecond)
-	s.raftServer.SetElectionTimeout(10 * time.Second)
+	s.raftServer.SetHeartbeatInterval(heartbeatInterval + time.Duration(rand.Int63n(int64(heartbeatInterval)/2+1)))
+	s.raftServer.SetElectionTimeout(option.ElectionTimeout)
 	if err := s.raftServer.LoadSnapshot(); err != nil {
 		return nil, err
 	}
@@ -121,7 +193,7 @@ func NewRaftServer(grpcDialOption grpc.DialOption, peers []string, serverAddr, d
 
 	s.GrpcServer = raft.NewGrpcServer(s.raftServer)
 
-	if s.raftServer.IsLogEmpty() && isTheFirstOne(serverAddr, s.peers) {
+	if s.raftServer.IsLogEmpty() && isTheFirstOne(s.serverAddr, s.peers) {
 		// Initialize the server by joining itself.
 		// s.DoJoinCommand()
 	}
@@ -131,6 +203,56 @@ func NewRaftServer(grpcDialOption grpc.DialOption, peers []string, serverAddr, d
 	return s, nil
 }
 
+// StartSnapshotting takes the raft snapshots once per interval, which also compacts the raft log.
+// The raft library only takes a snapshot after many log entries, so the log of a long running master
+// with few changes is kept small, and the restart does not replay the whole log.
+func (s *RaftServer) StartSnapshotting(interval time.Duration) {
+	go func() {
+		for {
+			time.Sleep(interval)
+			if _, err := s.TakeSnapshot(); err != nil {
+				glog.V(1).Infof("raft snapshot: %v", err)
+			}
+		}
+	}()
+}
+
+// TakeSnapshot saves the raft state if any log entry is committed since the last snapshot,
+// and removes the log entries before it, except the last ones for the slow followers.
+//
+// The raft library keeps a snapshot failed to save as pending, refusing all the later snapshots,
+// and still removes the log entries. So the snapshot folder is checked to be writable first.
+func (s *RaftServer) TakeSnapshot() (*RaftSnapshotResult, error) {
+	s.snapshotLock.Lock()
+	defer s.snapshotLock.Unlock()
+
+	if err := checkWritable(path.Join(s.dataDir, "snapshot")); err != nil {
+		return nil, fmt.Errorf("raft snapshot folder: %v", err)
+	}
+	if err := s.raftServer.TakeSnapshot(); err != nil {
+		if strings.Contains(err.Error(), "Last snapshot is not finished") {
+			return nil, fmt.Errorf("%v: the raft library may be taking a snapshot by itself, or a failed snapshot is pending until the master restarts", err)
+		}
+		return nil, err
+	}
+	result := &RaftSnapshotResult{
+		Name:        s.raftServer.Name(),
+		CommitIndex: s.raftServer.CommitIndex(),
+		LogEntries:  len(s.raftServer.LogEntries()),
+	}
+	glog.V(1).Infof("raft snapshot %+v", result)
+	return result, nil
+}
+
+func checkWritable(dir string) error {
+	f, err := ioutil.TempFile(dir, ".check")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
 func (s *RaftServer) Peers() (members []string) {
 	peers := s.raftServer.Peers()
 
@@ -24,3 +24,19 @@ func (s *RaftServer) StatusHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	writeJsonQuiet(w, r, http.StatusOK, ret)
 }
+
+type RaftSnapshotResult struct {
+	Name        string `json:"Name,omitempty"`
+	CommitIndex uint64 `json:"CommitIndex"`
+	LogEntries  int    `json:"LogEntries"`
+}
+
+// SnapshotHandler takes the raft snapshot of this master, without proxying to the leader
+func (s *RaftServer) SnapshotHandler(w http.ResponseWriter, r *http.Request) {
+	ret, err := s.TakeSnapshot()
+	if err != nil {
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	writeJsonQuiet(w, r, http.StatusOK, ret)
+}
@@ -0,0 +1,50 @@
+package weed_server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/chrislusf/seaweedfs/weed/sequence"
+	"github.com/chrislusf/seaweedfs/weed/topology"
+)
+
+func TestRaftStateSaveRecovery(t *testing.T) {
+	topo := topology.NewTopology("weedfs", sequence.NewMemorySequencer(), 32*1024, 5, false)
+	topo.UpAdjustMaxVolumeId(7)
+	topo.Sequence.SetMax(1000)
+	_, err := topo.SetClusterReadOnly(true, "migrating")
+	assert.NoError(t, err)
+	topo.RecoverMaintenanceScopes([]*topology.MaintenanceScope{{DataCenter: "dc1", Rack: "rack1", Since: 1}})
+
+	data, err := StateMachine{topo: topo}.Save()
+	assert.NoError(t, err)
+
+	recovered := topology.NewTopology("weedfs", sequence.NewMemorySequencer(), 32*1024, 5, false)
+	assert.NoError(t, StateMachine{topo: recovered}.Recovery(data))
+	assert.Equal(t, topo.GetMaxVolumeId(), recovered.GetMaxVolumeId())
+	assert.True(t, recovered.Sequence.Peek() >= 1000)
+	assert.EqualError(t, recovered.CheckClusterWritable(), "cluster is read only: migrating")
+	assert.True(t, recovered.IsDataNodeInMaintenance("dc1", "rack1", "node1"))
+	assert.False(t, recovered.IsDataNodeInMaintenance("dc1", "rack2", "node1"))
+
+	// the snapshots before the sequence is kept
+	old := topology.NewTopology("weedfs", sequence.NewMemorySequencer(), 32*1024, 5, false)
+	assert.NoError(t, StateMachine{topo: old}.Recovery([]byte(`{"maxVolumeId":3}`)))
+	assert.Equal(t, uint32(3), uint32(old.GetMaxVolumeId()))
+	assert.NoError(t, old.CheckClusterWritable())
+}
+
+func TestRaftHeartbeatInterval(t *testing.T) {
+	interval, err := (&RaftServerOption{ElectionTimeout: 10 * time.Second}).heartbeatInterval()
+	assert.NoError(t, err)
+	assert.Equal(t, maxRaftHeartbeatInterval, interval)
+
+	interval, err = (&RaftServerOption{ElectionTimeout: 900 * time.Millisecond}).heartbeatInterval()
+	assert.NoError(t, err)
+	assert.Equal(t, 100*time.Millisecond, interval)
+
+	_, err = (&RaftServerOption{ElectionTimeout: time.Second, HeartbeatInterval: 300 * time.Millisecond}).heartbeatInterval()
+	assert.Error(t, err)
+}
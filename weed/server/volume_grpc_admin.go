@@ -75,6 +75,9 @@ func (vs *VolumeServer) VolumeUnmount(ctx context.Context, req *volume_server_pb
 	resp := &volume_server_pb.VolumeUnmountResponse{}
 
 	err := vs.store.UnmountVolume(needle.VolumeId(req.VolumeId))
+	if err == nil {
+		vs.clearLiveCopySource(needle.VolumeId(req.VolumeId))
+	}
 
 	if err != nil {
 		glog.Errorf("volume unmount %v: %v", req, err)
@@ -91,6 +94,9 @@ func (vs *VolumeServer) VolumeDelete(ctx context.Context, req *volume_server_pb.
 	resp := &volume_server_pb.VolumeDeleteResponse{}
 
 	err := vs.store.DeleteVolume(needle.VolumeId(req.VolumeId))
+	if err == nil {
+		vs.clearLiveCopySource(needle.VolumeId(req.VolumeId))
+	}
 
 	if err != nil {
 		glog.Errorf("volume delete %v: %v", req, err)
@@ -157,6 +163,9 @@ func (vs *VolumeServer) VolumeMarkWritable(ctx context.Context, req *volume_serv
 	resp := &volume_server_pb.VolumeMarkWritableResponse{}
 
 	err := vs.store.MarkVolumeWritable(needle.VolumeId(req.VolumeId))
+	if err == nil {
+		vs.clearLiveCopySource(needle.VolumeId(req.VolumeId))
+	}
 
 	if err != nil {
 		glog.Errorf("volume mark writable %v: %v", req, err)
@@ -241,9 +250,29 @@ func (vs *VolumeServer) VolumeNeedleStatus(ctx context.Context, req *volume_serv
 	resp.Size = uint32(n.Size)
 	resp.LastModified = n.LastModified
 	resp.Crc = n.Checksum.Value()
+	resp.DataSize = n.DataSize
+	resp.IsCompressed = n.IsCompressed()
 	if n.HasTtl() {
 		resp.Ttl = n.Ttl.String()
 	}
 	return resp, nil
 
 }
+
+func (vs *VolumeServer) VolumeNeedleUndelete(ctx context.Context, req *volume_server_pb.VolumeNeedleUndeleteRequest) (*volume_server_pb.VolumeNeedleUndeleteResponse, error) {
+
+	if err := vs.startGrpcWrite(); err != nil {
+		return nil, err
+	}
+	defer vs.finishWrite()
+
+	size, err := vs.store.UndeleteVolumeNeedle(needle.VolumeId(req.VolumeId), types.NeedleId(req.NeedleId), types.Cookie(req.Cookie))
+	if err != nil {
+		return nil, fmt.Errorf("undelete volume %d needle %d: %v", req.VolumeId, req.NeedleId, err)
+	}
+
+	return &volume_server_pb.VolumeNeedleUndeleteResponse{
+		Size: uint32(size),
+	}, nil
+
+}
@@ -13,6 +13,11 @@ import (
 
 func (vs *VolumeServer) BatchDelete(ctx context.Context, req *volume_server_pb.BatchDeleteRequest) (*volume_server_pb.BatchDeleteResponse, error) {
 
+	if err := vs.startGrpcWrite(); err != nil {
+		return nil, err
+	}
+	defer vs.finishWrite()
+
 	resp := &volume_server_pb.BatchDeleteResponse{}
 
 	now := uint64(time.Now().Unix())
@@ -87,3 +92,36 @@ func (vs *VolumeServer) BatchDelete(ctx context.Context, req *volume_server_pb.B
 	return resp, nil
 
 }
+
+const batchDeleteStreamSize = 1000 // file ids per streamed BatchDeleteResponse
+
+// BatchDeleteStream deletes the file ids in batches, sending back the results of each batch once deleted,
+// so the client does not wait for, or hold, all the results of a large deletion in one response.
+func (vs *VolumeServer) BatchDeleteStream(req *volume_server_pb.BatchDeleteRequest, stream volume_server_pb.VolumeServer_BatchDeleteStreamServer) error {
+
+	fileIds := req.FileIds
+	for len(fileIds) > 0 {
+		if err := stream.Context().Err(); err != nil {
+			return err
+		}
+
+		batch := fileIds
+		if len(batch) > batchDeleteStreamSize {
+			batch = fileIds[:batchDeleteStreamSize]
+		}
+		fileIds = fileIds[len(batch):]
+
+		resp, err := vs.BatchDelete(stream.Context(), &volume_server_pb.BatchDeleteRequest{
+			FileIds:         batch,
+			SkipCookieCheck: req.SkipCookieCheck,
+		})
+		if err != nil {
+			return err
+		}
+		if err = stream.Send(resp); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
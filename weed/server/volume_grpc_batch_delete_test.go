@@ -0,0 +1,78 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/chrislusf/seaweedfs/weed/storage"
+)
+
+type fakeBatchDeleteStream struct {
+	volume_server_pb.VolumeServer_BatchDeleteStreamServer
+	ctx       context.Context
+	responses []*volume_server_pb.BatchDeleteResponse
+}
+
+func (s *fakeBatchDeleteStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeBatchDeleteStream) Send(resp *volume_server_pb.BatchDeleteResponse) error {
+	s.responses = append(s.responses, resp)
+	return nil
+}
+
+func TestBatchDeleteStream(t *testing.T) {
+	vs := &VolumeServer{store: &storage.Store{}}
+
+	var fileIds []string
+	for i := 0; i < 2*batchDeleteStreamSize+1; i++ {
+		fileIds = append(fileIds, fmt.Sprintf("3,%x01020304", i+1))
+	}
+	fileIds = append(fileIds, "bad")
+
+	stream := &fakeBatchDeleteStream{ctx: context.Background()}
+	if err := vs.BatchDeleteStream(&volume_server_pb.BatchDeleteRequest{FileIds: fileIds}, stream); err != nil {
+		t.Fatalf("BatchDeleteStream: %v", err)
+	}
+
+	if len(stream.responses) != 3 {
+		t.Fatalf("responses %d, expected 3", len(stream.responses))
+	}
+	var results []*volume_server_pb.DeleteResult
+	for _, resp := range stream.responses {
+		results = append(results, resp.Results...)
+	}
+	if len(results) != len(fileIds) {
+		t.Fatalf("results %d, expected %d", len(results), len(fileIds))
+	}
+	for i, result := range results {
+		if result.FileId != fileIds[i] {
+			t.Errorf("result %d of %s, expected %s", i, result.FileId, fileIds[i])
+		}
+	}
+	if results[0].Status != http.StatusNotFound {
+		t.Errorf("missing volume status %d", results[0].Status)
+	}
+	if last := results[len(results)-1]; last.Status != http.StatusBadRequest {
+		t.Errorf("bad file id status %d", last.Status)
+	}
+}
+
+func TestBatchDeleteStreamCanceled(t *testing.T) {
+	vs := &VolumeServer{store: &storage.Store{}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	stream := &fakeBatchDeleteStream{ctx: ctx}
+	err := vs.BatchDeleteStream(&volume_server_pb.BatchDeleteRequest{FileIds: []string{"3,0101020304"}}, stream)
+	if err != context.Canceled {
+		t.Errorf("canceled stream: %v", err)
+	}
+	if len(stream.responses) != 0 {
+		t.Errorf("responses %d after canceled", len(stream.responses))
+	}
+}
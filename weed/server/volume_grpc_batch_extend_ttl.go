@@ -0,0 +1,67 @@
+package weed_server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/chrislusf/seaweedfs/weed/storage"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+)
+
+func (vs *VolumeServer) BatchExtendTtl(ctx context.Context, req *volume_server_pb.BatchExtendTtlRequest) (*volume_server_pb.BatchExtendTtlResponse, error) {
+
+	if err := vs.startGrpcWrite(); err != nil {
+		return nil, err
+	}
+	defer vs.finishWrite()
+
+	resp := &volume_server_pb.BatchExtendTtlResponse{}
+
+	for _, fid := range req.FileIds {
+		vid, id_cookie, err := operation.ParseFileId(fid)
+		if err != nil {
+			resp.Results = append(resp.Results, &volume_server_pb.ExtendTtlResult{
+				FileId: fid,
+				Status: http.StatusBadRequest,
+				Error:  err.Error()})
+			continue
+		}
+
+		n := new(needle.Needle)
+		volumeId, _ := needle.NewVolumeId(vid)
+		if err = n.ParsePath(id_cookie); err != nil {
+			resp.Results = append(resp.Results, &volume_server_pb.ExtendTtlResult{
+				FileId: fid,
+				Status: http.StatusBadRequest,
+				Error:  err.Error()})
+			continue
+		}
+
+		ttl, err := vs.store.ExtendVolumeNeedleTtl(volumeId, n)
+		switch err {
+		case nil:
+			resp.Results = append(resp.Results, &volume_server_pb.ExtendTtlResult{
+				FileId: fid,
+				Status: http.StatusAccepted,
+				TtlSec: ttl.Minutes() * 60,
+			})
+		case storage.ErrorNotFound, storage.ErrorDeleted:
+			resp.Results = append(resp.Results, &volume_server_pb.ExtendTtlResult{
+				FileId: fid,
+				Status: http.StatusNotFound,
+				Error:  err.Error(),
+			})
+		default:
+			resp.Results = append(resp.Results, &volume_server_pb.ExtendTtlResult{
+				FileId: fid,
+				Status: http.StatusInternalServerError,
+				Error:  err.Error(),
+			})
+		}
+	}
+
+	return resp, nil
+
+}
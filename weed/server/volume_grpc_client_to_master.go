@@ -17,6 +17,7 @@ import (
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/pb/master_pb"
 	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/chrislusf/seaweedfs/weed/wdclient"
 )
 
 func (vs *VolumeServer) GetMaster() string {
@@ -56,15 +57,18 @@ func (vs *VolumeServer) heartbeat() {
 	grpcDialOption := security.LoadClientTLS(util.GetViper(), "grpc.volume")
 
 	var err error
-	var newLeader string
+	var newLeader, lastMaster string
 	for vs.isHeartbeating {
 		for _, master := range vs.SeedMasterNodes {
 			if newLeader != "" {
 				// the new leader may actually is the same master
 				// need to wait a bit before adding itself
-				time.Sleep(3 * time.Second)
+				if newLeader == lastMaster {
+					time.Sleep(3 * time.Second)
+				}
 				master = newLeader
 			}
+			lastMaster = master
 			masterGrpcAddress, parseErr := pb.ParseServerToGrpcAddress(master)
 			if parseErr != nil {
 				glog.V(0).Infof("failed to parse master grpc %v: %v", masterGrpcAddress, parseErr)
@@ -74,9 +78,13 @@ func (vs *VolumeServer) heartbeat() {
 			newLeader, err = vs.doHeartbeat(master, masterGrpcAddress, grpcDialOption, time.Duration(vs.pulseSeconds)*time.Second)
 			if err != nil {
 				glog.V(0).Infof("heartbeat error: %v", err)
-				time.Sleep(time.Duration(vs.pulseSeconds) * time.Second)
-				newLeader = ""
 				vs.store.MasterAddress = ""
+				// go to the new leader directly if the other masters know it
+				if newLeader = wdclient.FindLeaderHint(vs.SeedMasterNodes, grpcDialOption, master); newLeader == "" {
+					time.Sleep(time.Duration(vs.pulseSeconds) * time.Second)
+				} else {
+					glog.V(0).Infof("Volume Server hinted to the new leader %s", newLeader)
+				}
 			}
 			if !vs.isHeartbeating {
 				break
@@ -110,8 +110,14 @@ func (vs *VolumeServer) VolumeCopy(ctx context.Context, req *volume_server_pb.Vo
 	}
 
 	// mount the volume
-	err = vs.store.MountVolume(needle.VolumeId(req.VolumeId))
+	if req.Live {
+		vs.setLiveCopySource(needle.VolumeId(req.VolumeId), req.SourceDataNode)
+		err = vs.store.MountLiveCopyVolume(needle.VolumeId(req.VolumeId))
+	} else {
+		err = vs.store.MountVolume(needle.VolumeId(req.VolumeId))
+	}
 	if err != nil {
+		vs.clearLiveCopySource(needle.VolumeId(req.VolumeId))
 		return nil, fmt.Errorf("failed to mount volume %d: %v", req.VolumeId, err)
 	}
 
@@ -135,7 +141,7 @@ func (vs *VolumeServer) doCopyFile(client volume_server_pb.VolumeServerClient, i
 		return fmt.Errorf("failed to start copying volume %d %s file: %v", vid, ext, err)
 	}
 
-	err = writeToFile(copyFileClient, baseFileName+ext, util.NewWriteThrottler(vs.compactionBytePerSecond), isAppend)
+	err = writeToFile(copyFileClient, baseFileName+ext, vs.store.BackgroundThrottler(baseFileName+ext, vs.compactionBytePerSecond, storage.BackgroundWrite), isAppend)
 	if err != nil {
 		return fmt.Errorf("failed to copy %s file: %v", baseFileName+ext, err)
 	}
@@ -221,7 +227,20 @@ func (vs *VolumeServer) ReadVolumeFileStatus(ctx context.Context, req *volume_se
 func (vs *VolumeServer) CopyFile(req *volume_server_pb.CopyFileRequest, stream volume_server_pb.VolumeServer_CopyFileServer) error {
 
 	var fileName string
-	if !req.IsEcVolume {
+	if req.Snapshot != "" {
+		location, found := vs.store.FindSnapshot(req.Collection, needle.VolumeId(req.VolumeId), req.Snapshot)
+		if !found {
+			return fmt.Errorf("CopyFile not found snapshot %s of volume id %d", req.Snapshot, req.VolumeId)
+		}
+		dir := location.Directory
+		if req.Ext == ".idx" {
+			dir = location.IdxDirectory
+		}
+		fileName = storage.SnapshotFileName(storage.VolumeFileName(dir, req.Collection, int(req.VolumeId)), req.Snapshot, req.Ext)
+		if req.IgnoreSourceFileNotFound && !util.FileExists(fileName) {
+			return nil
+		}
+	} else if !req.IsEcVolume {
 		v := vs.store.GetVolume(needle.VolumeId(req.VolumeId))
 		if v == nil {
 			return fmt.Errorf("not found volume id %d", req.VolumeId)
@@ -34,7 +34,7 @@ Steps to apply erasure coding to .dat .idx files
 
 */
 
-// VolumeEcShardsGenerate generates the .ecx and .ec00 ~ .ec13 files
+// VolumeEcShardsGenerate generates the .ecx and .ec00 ~ .ec13 files, or the shard files of the requested layout
 func (vs *VolumeServer) VolumeEcShardsGenerate(ctx context.Context, req *volume_server_pb.VolumeEcShardsGenerateRequest) (*volume_server_pb.VolumeEcShardsGenerateResponse, error) {
 
 	glog.V(0).Infof("VolumeEcShardsGenerate: %v", req)
@@ -49,8 +49,13 @@ func (vs *VolumeServer) VolumeEcShardsGenerate(ctx context.Context, req *volume_
 		return nil, fmt.Errorf("existing collection:%v unexpected input: %v", v.Collection, req.Collection)
 	}
 
+	layout, err := erasure_coding.NewEcLayout(int(req.DataShards), int(req.ParityShards))
+	if err != nil {
+		return nil, err
+	}
+
 	// write .ec00 ~ .ec13 files
-	if err := erasure_coding.WriteEcFiles(baseFileName); err != nil {
+	if err := erasure_coding.WriteEcFiles(baseFileName, layout, vs.store.BackgroundThrottler(baseFileName, req.BytesPerSecond, storage.BackgroundReadWrite)); err != nil {
 		return nil, fmt.Errorf("WriteEcFiles %s: %v", baseFileName, err)
 	}
 
@@ -60,7 +65,11 @@ func (vs *VolumeServer) VolumeEcShardsGenerate(ctx context.Context, req *volume_
 	}
 
 	// write .vif files
-	if err := pb.SaveVolumeInfo(baseFileName+".vif", &volume_server_pb.VolumeInfo{Version: uint32(v.Version())}); err != nil {
+	if err := pb.SaveVolumeInfo(baseFileName+".vif", &volume_server_pb.VolumeInfo{
+		Version:        uint32(v.Version()),
+		EcDataShards:   uint32(layout.DataShards),
+		EcParityShards: uint32(layout.ParityShards),
+	}); err != nil {
 		return nil, fmt.Errorf("WriteEcFiles %s: %v", baseFileName, err)
 	}
 
@@ -80,7 +89,8 @@ func (vs *VolumeServer) VolumeEcShardsRebuild(ctx context.Context, req *volume_s
 		if util.FileExists(path.Join(location.IdxDirectory, baseFileName+".ecx")) {
 			// write .ec00 ~ .ec13 files
 			dataBaseFileName := path.Join(location.Directory, baseFileName)
-			if generatedShardIds, err := erasure_coding.RebuildEcFiles(dataBaseFileName); err != nil {
+			layout := erasure_coding.ReadEcLayout(dataBaseFileName)
+			if generatedShardIds, err := erasure_coding.RebuildEcFiles(dataBaseFileName, layout); err != nil {
 				return nil, fmt.Errorf("RebuildEcFiles %s: %v", dataBaseFileName, err)
 			} else {
 				rebuiltShardIds = generatedShardIds
@@ -387,7 +397,7 @@ func (vs *VolumeServer) VolumeEcShardsToVolume(ctx context.Context, req *volume_
 	}
 
 	// write .dat file from .ec00 ~ .ec09 files
-	if err := erasure_coding.WriteDatFile(dataBaseFileName, datFileSize); err != nil {
+	if err := erasure_coding.WriteDatFile(dataBaseFileName, v.Layout, datFileSize); err != nil {
 		return nil, fmt.Errorf("WriteEcFiles %s: %v", dataBaseFileName, err)
 	}
 
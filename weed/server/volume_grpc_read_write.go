@@ -15,7 +15,22 @@ func (vs *VolumeServer) ReadNeedleBlob(ctx context.Context, req *volume_server_p
 		return nil, fmt.Errorf("not found volume id %d", req.VolumeId)
 	}
 
+	if req.ByNeedleId {
+		var size types.Size
+		resp.NeedleBlob, size, err = v.ReadNeedleBlobById(types.NeedleId(req.NeedleId))
+		if err == nil {
+			err = vs.store.VerifyNeedleBlob(v, types.NeedleId(req.NeedleId), resp.NeedleBlob, size)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read needle blob %d: %v", req.NeedleId, err)
+		}
+		return resp, nil
+	}
+
 	resp.NeedleBlob, err = v.ReadNeedleBlob(req.Offset, types.Size(req.Size))
+	if err == nil {
+		err = vs.store.VerifyNeedleBlob(v, types.NeedleId(req.NeedleId), resp.NeedleBlob, types.Size(req.Size))
+	}
 	if err != nil {
 		return nil, fmt.Errorf("read needle blob offset %d size %d: %v", req.Offset, req.Size, err)
 	}
@@ -24,6 +39,11 @@ func (vs *VolumeServer) ReadNeedleBlob(ctx context.Context, req *volume_server_p
 }
 
 func (vs *VolumeServer) WriteNeedleBlob(ctx context.Context, req *volume_server_pb.WriteNeedleBlobRequest) (resp *volume_server_pb.WriteNeedleBlobResponse, err error) {
+	if err = vs.startGrpcWrite(); err != nil {
+		return nil, err
+	}
+	defer vs.finishWrite()
+
 	resp = &volume_server_pb.WriteNeedleBlobResponse{}
 	v := vs.store.GetVolume(needle.VolumeId(req.VolumeId))
 	if v == nil {
@@ -0,0 +1,35 @@
+package weed_server
+
+import (
+	"context"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/volume_server_pb"
+)
+
+func (vs *VolumeServer) VolumeScrubStatus(ctx context.Context, req *volume_server_pb.VolumeScrubStatusRequest) (*volume_server_pb.VolumeScrubStatusResponse, error) {
+
+	resp := &volume_server_pb.VolumeScrubStatusResponse{
+		IsEnabled: vs.store.IsScrubbing(),
+	}
+
+	for _, status := range vs.store.ScrubStatuses() {
+		scrub := &volume_server_pb.VolumeScrubStatusResponse_VolumeScrub{
+			VolumeId:      uint32(status.VolumeId),
+			Collection:    status.Collection,
+			IsEcVolume:    status.IsEcVolume,
+			NeedleCount:   status.NeedleCount,
+			ScannedCount:  status.ScannedCount,
+			CorruptCount:  status.CorruptCount,
+			RepairedCount: status.RepairedCount,
+			StartedAtNs:   status.StartedAt.UnixNano(),
+			LastError:     status.LastError,
+		}
+		if !status.CompletedAt.IsZero() {
+			scrub.CompletedAtNs = status.CompletedAt.UnixNano()
+		}
+		resp.VolumeScrubs = append(resp.VolumeScrubs, scrub)
+	}
+
+	return resp, nil
+
+}
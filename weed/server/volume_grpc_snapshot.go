@@ -0,0 +1,181 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/chrislusf/seaweedfs/weed/storage"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+	"github.com/chrislusf/seaweedfs/weed/storage/types"
+)
+
+func (vs *VolumeServer) VolumeSnapshotCreate(ctx context.Context, req *volume_server_pb.VolumeSnapshotCreateRequest) (*volume_server_pb.VolumeSnapshotCreateResponse, error) {
+
+	v := vs.store.GetVolume(needle.VolumeId(req.VolumeId))
+	if v == nil {
+		return nil, fmt.Errorf("volume %d not found", req.VolumeId)
+	}
+
+	snapshot, err := v.CreateSnapshot(req.Name)
+	if err != nil {
+		return nil, fmt.Errorf("create snapshot %s of volume %d: %v", req.Name, req.VolumeId, err)
+	}
+
+	return &volume_server_pb.VolumeSnapshotCreateResponse{
+		Snapshot: snapshot,
+	}, nil
+}
+
+func (vs *VolumeServer) VolumeSnapshotList(ctx context.Context, req *volume_server_pb.VolumeSnapshotListRequest) (*volume_server_pb.VolumeSnapshotListResponse, error) {
+
+	collection := req.Collection
+	if v := vs.store.GetVolume(needle.VolumeId(req.VolumeId)); v != nil {
+		collection = v.Collection
+	}
+
+	snapshots, err := vs.store.ListSnapshots(collection, needle.VolumeId(req.VolumeId))
+	if err != nil {
+		return nil, err
+	}
+
+	return &volume_server_pb.VolumeSnapshotListResponse{
+		Snapshots: snapshots,
+	}, nil
+}
+
+func (vs *VolumeServer) VolumeSnapshotDelete(ctx context.Context, req *volume_server_pb.VolumeSnapshotDeleteRequest) (*volume_server_pb.VolumeSnapshotDeleteResponse, error) {
+
+	glog.V(0).Infof("delete snapshot %s of volume %d", req.Name, req.VolumeId)
+
+	collection := req.Collection
+	if v := vs.store.GetVolume(needle.VolumeId(req.VolumeId)); v != nil {
+		collection = v.Collection
+	}
+
+	if err := vs.store.DeleteSnapshot(collection, needle.VolumeId(req.VolumeId), req.Name); err != nil {
+		return nil, err
+	}
+
+	return &volume_server_pb.VolumeSnapshotDeleteResponse{}, nil
+}
+
+// VolumeSnapshotClone copies the snapshot content from the source volume server, as a snapshot on this server.
+func (vs *VolumeServer) VolumeSnapshotClone(ctx context.Context, req *volume_server_pb.VolumeSnapshotCloneRequest) (*volume_server_pb.VolumeSnapshotCloneResponse, error) {
+
+	vid := needle.VolumeId(req.VolumeId)
+	if err := storage.ValidateSnapshotName(req.Name); err != nil {
+		return nil, err
+	}
+
+	var snapshot *volume_server_pb.VolumeSnapshot
+	var dataBaseFileName, indexBaseFileName string
+	err := operation.WithVolumeServerClient(req.SourceDataNode, vs.grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+
+		resp, err := client.VolumeSnapshotList(context.Background(), &volume_server_pb.VolumeSnapshotListRequest{
+			VolumeId:   req.VolumeId,
+			Collection: req.Collection,
+		})
+		if err != nil {
+			return fmt.Errorf("list snapshots on %s: %v", req.SourceDataNode, err)
+		}
+		for _, s := range resp.Snapshots {
+			if s.Name == req.Name {
+				snapshot = s
+			}
+		}
+		if snapshot == nil {
+			return fmt.Errorf("snapshot %s of volume %d not found on %s", req.Name, req.VolumeId, req.SourceDataNode)
+		}
+		if _, found := vs.store.FindSnapshot(snapshot.Collection, vid, req.Name); found {
+			return fmt.Errorf("snapshot %s of volume %d already exists", req.Name, req.VolumeId)
+		}
+
+		location := vs.store.FindFreeLocation(types.ToDiskType(req.DiskType))
+		if location == nil {
+			return fmt.Errorf("no space left for disk type %s", types.ToDiskType(req.DiskType).ReadableString())
+		}
+		dataBaseFileName = storage.VolumeFileName(location.Directory, snapshot.Collection, int(vid))
+		indexBaseFileName = storage.VolumeFileName(location.IdxDirectory, snapshot.Collection, int(vid))
+		for _, baseFileName := range []string{dataBaseFileName, indexBaseFileName} {
+			if err := os.MkdirAll(storage.SnapshotDirectory(baseFileName), 0755); err != nil {
+				return err
+			}
+		}
+
+		if err := vs.doCopySnapshotFile(client, snapshot, snapshot.DatFileSize, dataBaseFileName, ".dat"); err != nil {
+			return err
+		}
+		if err := vs.doCopySnapshotFile(client, snapshot, snapshot.IdxFileSize, indexBaseFileName, ".idx"); err != nil {
+			return err
+		}
+		if err := vs.doCopySnapshotFile(client, snapshot, math.MaxUint64, dataBaseFileName, ".vif"); err != nil {
+			return err
+		}
+		// the .vif file is optional
+		vifFileName := storage.SnapshotFileName(dataBaseFileName, req.Name, ".vif")
+		if stat, statErr := os.Stat(vifFileName); statErr == nil && stat.Size() == 0 {
+			os.Remove(vifFileName)
+		}
+		return nil
+	})
+	if err == nil {
+		err = checkSnapshotFileSize(storage.SnapshotFileName(dataBaseFileName, req.Name, ".dat"), snapshot.DatFileSize)
+	}
+	if err == nil {
+		err = checkSnapshotFileSize(storage.SnapshotFileName(indexBaseFileName, req.Name, ".idx"), snapshot.IdxFileSize)
+	}
+	if err == nil {
+		err = storage.SaveSnapshotInfo(storage.SnapshotFileName(dataBaseFileName, req.Name, ".snap"), snapshot)
+	}
+	if err != nil {
+		if dataBaseFileName != "" {
+			os.Remove(storage.SnapshotFileName(dataBaseFileName, req.Name, ".dat"))
+			os.Remove(storage.SnapshotFileName(dataBaseFileName, req.Name, ".vif"))
+			os.Remove(storage.SnapshotFileName(indexBaseFileName, req.Name, ".idx"))
+		}
+		return nil, fmt.Errorf("clone snapshot %s of volume %d: %v", req.Name, req.VolumeId, err)
+	}
+
+	glog.V(0).Infof("cloned snapshot %s of volume %d from %s", req.Name, req.VolumeId, req.SourceDataNode)
+	return &volume_server_pb.VolumeSnapshotCloneResponse{
+		Snapshot: snapshot,
+	}, nil
+}
+
+func (vs *VolumeServer) doCopySnapshotFile(client volume_server_pb.VolumeServerClient, snapshot *volume_server_pb.VolumeSnapshot, stopOffset uint64, baseFileName, ext string) error {
+
+	copyFileClient, err := client.CopyFile(context.Background(), &volume_server_pb.CopyFileRequest{
+		VolumeId:                 snapshot.VolumeId,
+		Ext:                      ext,
+		CompactionRevision:       math.MaxUint32,
+		StopOffset:               stopOffset,
+		Collection:               snapshot.Collection,
+		IgnoreSourceFileNotFound: ext == ".vif",
+		Snapshot:                 snapshot.Name,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start copying snapshot %s %s file: %v", snapshot.Name, ext, err)
+	}
+
+	fileName := storage.SnapshotFileName(baseFileName, snapshot.Name, ext)
+	if err = writeToFile(copyFileClient, fileName, vs.store.BackgroundThrottler(fileName, vs.compactionBytePerSecond, storage.BackgroundWrite), false); err != nil {
+		return fmt.Errorf("failed to copy %s: %v", fileName, err)
+	}
+	return nil
+}
+
+func checkSnapshotFileSize(fileName string, expectedSize uint64) error {
+	stat, err := os.Stat(fileName)
+	if err != nil {
+		return err
+	}
+	if uint64(stat.Size()) != expectedSize {
+		return fmt.Errorf("%s size %d is not the snapshot size %d", fileName, stat.Size(), expectedSize)
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/chrislusf/seaweedfs/weed/storage"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+	"github.com/chrislusf/seaweedfs/weed/storage/types"
+)
+
+const standbySyncRetryInterval = 10 * time.Second
+
+// standbySync tails the writes of the source volume into a standby volume, until stopped.
+type standbySync struct {
+	sv        *storage.StandbyVolume
+	cancel    context.CancelFunc
+	done      chan struct{}
+	lastError string
+	errLock   sync.Mutex
+}
+
+func (ss *standbySync) setLastError(err error) {
+	ss.errLock.Lock()
+	defer ss.errLock.Unlock()
+	if err == nil {
+		ss.lastError = ""
+	} else {
+		ss.lastError = err.Error()
+	}
+}
+
+func (ss *standbySync) getLastError() string {
+	ss.errLock.Lock()
+	defer ss.errLock.Unlock()
+	return ss.lastError
+}
+
+func (vs *VolumeServer) startStandbySync(sv *storage.StandbyVolume) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ss := &standbySync{
+		sv:     sv,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	vs.standbySyncLock.Lock()
+	vs.standbySyncs[sv.Id] = ss
+	vs.standbySyncLock.Unlock()
+
+	go func() {
+		defer close(ss.done)
+		for {
+			err := operation.TailVolumeFromSourceWithContext(ctx, sv.Source, vs.grpcDialOption, sv.Id, sv.SinceNs(), 0, func(n *needle.Needle) error {
+				ss.setLastError(nil)
+				return sv.ApplyNeedle(n)
+			})
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				glog.Warningf("tail standby volume %d from %s: %v", sv.Id, sv.Source, err)
+				ss.setLastError(err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(standbySyncRetryInterval):
+			}
+		}
+	}()
+}
+
+// stopStandbySync stops tailing into the standby volume, and returns it, or nil if not found.
+func (vs *VolumeServer) stopStandbySync(vid needle.VolumeId) *storage.StandbyVolume {
+	vs.standbySyncLock.Lock()
+	ss, found := vs.standbySyncs[vid]
+	delete(vs.standbySyncs, vid)
+	vs.standbySyncLock.Unlock()
+	if !found {
+		return nil
+	}
+	ss.cancel()
+	<-ss.done
+	if err := ss.sv.SaveNote(); err != nil {
+		glog.Warningf("save standby volume %d note: %v", vid, err)
+	}
+	return ss.sv
+}
+
+func (vs *VolumeServer) stopStandbySyncs() {
+	vs.standbySyncLock.Lock()
+	var vids []needle.VolumeId
+	for vid := range vs.standbySyncs {
+		vids = append(vids, vid)
+	}
+	vs.standbySyncLock.Unlock()
+	for _, vid := range vids {
+		vs.stopStandbySync(vid)
+	}
+}
+
+// VolumeStandbyStart copies the volume files from the source, and keeps tailing the writes into the standby volume.
+func (vs *VolumeServer) VolumeStandbyStart(ctx context.Context, req *volume_server_pb.VolumeStandbyStartRequest) (*volume_server_pb.VolumeStandbyStartResponse, error) {
+
+	vid := needle.VolumeId(req.VolumeId)
+	var volFileInfoResp *volume_server_pb.ReadVolumeFileStatusResponse
+	var location *storage.DiskLocation
+	var dataBaseFileName, indexBaseFileName string
+	err := operation.WithVolumeServerClient(req.SourceDataNode, vs.grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+		var err error
+		volFileInfoResp, err = client.ReadVolumeFileStatus(context.Background(),
+			&volume_server_pb.ReadVolumeFileStatusRequest{
+				VolumeId: req.VolumeId,
+			})
+		if err != nil {
+			return fmt.Errorf("read volume file status failed, %v", err)
+		}
+
+		diskType := volFileInfoResp.DiskType
+		if req.DiskType != "" {
+			diskType = req.DiskType
+		}
+		location, dataBaseFileName, indexBaseFileName, err = vs.store.NewStandbyFileNames(vid, volFileInfoResp.Collection, types.ToDiskType(diskType))
+		if err != nil {
+			return err
+		}
+
+		if err := vs.doCopyFile(client, false, volFileInfoResp.Collection, req.VolumeId, volFileInfoResp.CompactionRevision, volFileInfoResp.DatFileSize, dataBaseFileName, ".dat", false, true); err != nil {
+			return err
+		}
+		if err := vs.doCopyFile(client, false, volFileInfoResp.Collection, req.VolumeId, volFileInfoResp.CompactionRevision, volFileInfoResp.IdxFileSize, indexBaseFileName, ".idx", false, false); err != nil {
+			return err
+		}
+		if err := vs.doCopyFile(client, false, volFileInfoResp.Collection, req.VolumeId, volFileInfoResp.CompactionRevision, volFileInfoResp.DatFileSize, dataBaseFileName, ".vif", false, true); err != nil {
+			return err
+		}
+		return checkCopyFiles(volFileInfoResp, indexBaseFileName+".idx", dataBaseFileName+".dat")
+	})
+
+	removeCopiedFiles := func() {
+		if dataBaseFileName != "" {
+			os.Remove(indexBaseFileName + ".idx")
+			os.Remove(dataBaseFileName + ".dat")
+			os.Remove(dataBaseFileName + ".vif")
+		}
+	}
+	if err != nil {
+		removeCopiedFiles()
+		return nil, err
+	}
+
+	// the writes during the copying are tailed again, which are skipped as unchanged
+	sinceNs := volFileInfoResp.DatFileTimestampSeconds * uint64(time.Second)
+	sv, err := vs.store.OpenStandbyVolume(location, vid, volFileInfoResp.Collection, req.SourceDataNode, sinceNs)
+	if err != nil {
+		removeCopiedFiles()
+		return nil, err
+	}
+	vs.startStandbySync(sv)
+
+	glog.V(0).Infof("started standby volume %d from %s", vid, req.SourceDataNode)
+
+	return &volume_server_pb.VolumeStandbyStartResponse{
+		LastAppendAtNs: sinceNs,
+	}, nil
+}
+
+func (vs *VolumeServer) VolumeStandbyStatus(ctx context.Context, req *volume_server_pb.VolumeStandbyStatusRequest) (*volume_server_pb.VolumeStandbyStatusResponse, error) {
+
+	resp := &volume_server_pb.VolumeStandbyStatusResponse{}
+	for _, sv := range vs.store.StandbyVolumes() {
+		if req.VolumeId != 0 && uint32(sv.Id) != req.VolumeId {
+			continue
+		}
+		standby := &volume_server_pb.VolumeStandby{
+			VolumeId:       uint32(sv.Id),
+			Collection:     sv.Collection,
+			SourceDataNode: sv.Source,
+			LastAppendAtNs: sv.SinceNs(),
+			DatFileSize:    sv.DatFileSize(),
+		}
+		vs.standbySyncLock.Lock()
+		if ss, found := vs.standbySyncs[sv.Id]; found {
+			standby.Error = ss.getLastError()
+		}
+		vs.standbySyncLock.Unlock()
+		resp.StandbyVolumes = append(resp.StandbyVolumes, standby)
+	}
+	if req.VolumeId != 0 && len(resp.StandbyVolumes) == 0 {
+		return nil, fmt.Errorf("not found standby volume %d", req.VolumeId)
+	}
+	return resp, nil
+}
+
+// VolumeStandbySwitchover receives the last writes from the read only source volume, and mounts the standby volume.
+func (vs *VolumeServer) VolumeStandbySwitchover(ctx context.Context, req *volume_server_pb.VolumeStandbySwitchoverRequest) (*volume_server_pb.VolumeStandbySwitchoverResponse, error) {
+
+	vid := needle.VolumeId(req.VolumeId)
+	sv := vs.store.FindStandbyVolume(vid)
+	if sv == nil {
+		return nil, fmt.Errorf("not found standby volume %d", vid)
+	}
+
+	err := operation.WithVolumeServerClient(sv.Source, vs.grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+		resp, err := client.VolumeStatus(context.Background(), &volume_server_pb.VolumeStatusRequest{VolumeId: req.VolumeId})
+		if err != nil {
+			return err
+		}
+		if !resp.IsReadOnly {
+			return fmt.Errorf("source volume %d on %s is still writable", vid, sv.Source)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("check source volume %d: %v", vid, err)
+	}
+
+	if stopped := vs.stopStandbySync(vid); stopped == nil {
+		return nil, fmt.Errorf("standby volume %d is switching over or cancelled", vid)
+	}
+
+	idleTimeoutSeconds := int(req.IdleTimeoutSeconds)
+	if idleTimeoutSeconds <= 0 {
+		idleTimeoutSeconds = 2
+	}
+	err = operation.TailVolumeFromSource(sv.Source, vs.grpcDialOption, vid, sv.SinceNs(), idleTimeoutSeconds, sv.ApplyNeedle)
+	if err == nil {
+		err = vs.store.PromoteStandbyVolume(sv)
+	}
+	if err != nil {
+		if vs.store.FindStandbyVolume(vid) != nil {
+			vs.startStandbySync(sv)
+		}
+		return nil, fmt.Errorf("switch over standby volume %d: %v", vid, err)
+	}
+
+	glog.V(0).Infof("switched over standby volume %d from %s", vid, sv.Source)
+
+	return &volume_server_pb.VolumeStandbySwitchoverResponse{
+		LastAppendAtNs: sv.SinceNs(),
+	}, nil
+}
+
+func (vs *VolumeServer) VolumeStandbyCancel(ctx context.Context, req *volume_server_pb.VolumeStandbyCancelRequest) (*volume_server_pb.VolumeStandbyCancelResponse, error) {
+
+	vid := needle.VolumeId(req.VolumeId)
+	sv := vs.stopStandbySync(vid)
+	if sv == nil {
+		return nil, fmt.Errorf("not found standby volume %d", vid)
+	}
+	vs.store.DeleteStandbyVolume(sv)
+
+	glog.V(0).Infof("cancelled standby volume %d from %s", vid, sv.Source)
+
+	return &volume_server_pb.VolumeStandbyCancelResponse{}, nil
+}
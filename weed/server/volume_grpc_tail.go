@@ -89,7 +89,12 @@ func (vs *VolumeServer) VolumeTailReceiver(ctx context.Context, req *volume_serv
 
 	defer glog.V(1).Infof("receive tailing volume %d finished", v.Id)
 
+	isLiveCopy := vs.getLiveCopySource(v.Id) != ""
+
 	return resp, operation.TailVolumeFromSource(req.SourceVolumeServer, vs.grpcDialOption, v.Id, req.SinceNs, int(req.IdleTimeoutSeconds), func(n *needle.Needle) error {
+		if isLiveCopy {
+			return vs.store.WriteVolumeNeedleFromSource(v.Id, n)
+		}
 		_, err := vs.store.WriteVolumeNeedle(v.Id, n, false)
 		return err
 	})
@@ -2,6 +2,8 @@ package weed_server
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/pb/volume_server_pb"
@@ -12,6 +14,10 @@ func (vs *VolumeServer) VacuumVolumeCheck(ctx context.Context, req *volume_serve
 
 	resp := &volume_server_pb.VacuumVolumeCheckResponse{}
 
+	if !vs.vacuumWindows.Contains(time.Now()) {
+		return resp, fmt.Errorf("volume %d: out of vacuum windows %s", req.VolumeId, vs.vacuumWindows)
+	}
+
 	garbageRatio, err := vs.store.CheckCompactVolume(needle.VolumeId(req.VolumeId))
 
 	resp.GarbageRatio = garbageRatio
@@ -28,7 +34,17 @@ func (vs *VolumeServer) VacuumVolumeCompact(ctx context.Context, req *volume_ser
 
 	resp := &volume_server_pb.VacuumVolumeCompactResponse{}
 
-	err := vs.store.CompactVolume(needle.VolumeId(req.VolumeId), req.Preallocate, vs.compactionBytePerSecond)
+	if !vs.vacuumWindows.Contains(time.Now()) {
+		return resp, fmt.Errorf("compact volume %d: out of vacuum windows %s", req.VolumeId, vs.vacuumWindows)
+	}
+
+	// use the lower of the limits from the master and this volume server
+	compactionBytePerSecond := vs.compactionBytePerSecond
+	if req.CompactionBytePerSecond > 0 && (compactionBytePerSecond <= 0 || req.CompactionBytePerSecond < compactionBytePerSecond) {
+		compactionBytePerSecond = req.CompactionBytePerSecond
+	}
+
+	err := vs.store.CompactVolume(needle.VolumeId(req.VolumeId), req.Preallocate, compactionBytePerSecond)
 
 	if err != nil {
 		glog.Errorf("compact volume %d: %v", req.VolumeId, err)
@@ -5,6 +5,7 @@ import (
 	"github.com/chrislusf/seaweedfs/weed/storage/types"
 	"net/http"
 	"sync"
+	"time"
 
 	"google.golang.org/grpc"
 
@@ -12,8 +13,13 @@ import (
 	"github.com/chrislusf/seaweedfs/weed/util"
 
 	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/kms"
+	_ "github.com/chrislusf/seaweedfs/weed/kms/aws_kms"
+	_ "github.com/chrislusf/seaweedfs/weed/kms/static"
+	_ "github.com/chrislusf/seaweedfs/weed/kms/vault"
 	"github.com/chrislusf/seaweedfs/weed/security"
 	"github.com/chrislusf/seaweedfs/weed/storage"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
 )
 
 type VolumeServer struct {
@@ -30,6 +36,7 @@ type VolumeServer struct {
 	FixJpgOrientation       bool
 	ReadRedirect            bool
 	compactionBytePerSecond int64
+	vacuumWindows           util.TimeWindows
 	metricsAddress          string
 	metricsIntervalSec      int
 	fileSizeLimitBytes      int64
@@ -39,11 +46,22 @@ type VolumeServer struct {
 	inFlightDataSize      int64
 	inFlightDataLimitCond *sync.Cond
 	concurrentUploadLimit int64
+
+	liveCopyLock    sync.RWMutex
+	liveCopySources map[needle.VolumeId]string
+
+	standbySyncLock sync.Mutex
+	standbySyncs    map[needle.VolumeId]*standbySync
+
+	drainLock          sync.Mutex
+	isDraining         int32
+	drainedVolumeIds   []uint32
+	inFlightWriteCount int64
 }
 
 func NewVolumeServer(adminMux, publicMux *http.ServeMux, ip string,
 	port int, publicUrl string,
-	folders []string, maxCounts []int, minFreeSpacePercents []float32, diskTypes []types.DiskType,
+	folders []string, maxCounts []int, minFreeSpacePercents []float32, diskTypes []types.DiskType, ioLimits []*storage.DiskIoLimits,
 	idxFolder string,
 	needleMapKind storage.NeedleMapKind,
 	masterNodes []string, pulseSeconds int,
@@ -51,9 +69,13 @@ func NewVolumeServer(adminMux, publicMux *http.ServeMux, ip string,
 	whiteList []string,
 	fixJpgOrientation bool,
 	readRedirect bool,
+	readVerifyCrc bool,
 	compactionMBPerSecond int,
+	vacuumWindows util.TimeWindows,
 	fileSizeLimitMB int,
 	concurrentUploadLimit int64,
+	scrubMBPerSecond int,
+	scrubInterval time.Duration,
 ) *VolumeServer {
 
 	v := util.GetViper()
@@ -75,17 +97,36 @@ func NewVolumeServer(adminMux, publicMux *http.ServeMux, ip string,
 		ReadRedirect:            readRedirect,
 		grpcDialOption:          security.LoadClientTLS(util.GetViper(), "grpc.volume"),
 		compactionBytePerSecond: int64(compactionMBPerSecond) * 1024 * 1024,
+		vacuumWindows:           vacuumWindows,
 		fileSizeLimitBytes:      int64(fileSizeLimitMB) * 1024 * 1024,
 		isHeartbeating:          true,
 		stopChan:                make(chan bool),
 		inFlightDataLimitCond:   sync.NewCond(new(sync.Mutex)),
 		concurrentUploadLimit:   concurrentUploadLimit,
+		liveCopySources:         make(map[needle.VolumeId]string),
+		standbySyncs:            make(map[needle.VolumeId]*standbySync),
 	}
 	vs.SeedMasterNodes = masterNodes
 
 	vs.checkWithMaster()
 
+	dataKeys, err := kms.LoadDataKeys(v)
+	if err != nil {
+		glog.Fatalf("load volume encryption data keys: %v", err)
+	}
+	storage.SetAtRestDataKeys(dataKeys)
+
 	vs.store = storage.NewStore(vs.grpcDialOption, port, ip, publicUrl, folders, maxCounts, minFreeSpacePercents, idxFolder, vs.needleMapKind, diskTypes)
+	vs.store.SetIoLimits(ioLimits)
+	if readVerifyCrc {
+		vs.store.EnableReadCrcVerification()
+	}
+	if scrubMBPerSecond > 0 {
+		vs.store.StartScrubbing(int64(scrubMBPerSecond)*1024*1024, scrubInterval)
+	}
+	for _, sv := range vs.store.LoadStandbyVolumes() {
+		vs.startStandbySync(sv)
+	}
 	vs.guard = security.NewGuard(whiteList, signingKey, expiresAfterSec, readSigningKey, readExpiresAfterSec)
 
 	handleStaticResources(adminMux)
@@ -93,6 +134,7 @@ func NewVolumeServer(adminMux, publicMux *http.ServeMux, ip string,
 	if signingKey == "" || enableUiAccess {
 		// only expose the volume server details for safe environments
 		adminMux.HandleFunc("/ui/index.html", vs.uiStatusHandler)
+		adminMux.HandleFunc("/stats/config", vs.guard.WhiteList(statsConfigHandler))
 		/*
 			adminMux.HandleFunc("/stats/counter", vs.guard.WhiteList(statsCounterHandler))
 			adminMux.HandleFunc("/stats/memory", vs.guard.WhiteList(statsMemoryHandler))
@@ -114,6 +156,7 @@ func NewVolumeServer(adminMux, publicMux *http.ServeMux, ip string,
 
 func (vs *VolumeServer) Shutdown() {
 	glog.V(0).Infoln("Shutting down volume server...")
+	vs.stopStandbySyncs()
 	vs.store.Close()
 	glog.V(0).Infoln("Shut down successfully!")
 }
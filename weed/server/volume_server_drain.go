@@ -0,0 +1,79 @@
+package weed_server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/volume_server_pb"
+)
+
+/*
+Draining prepares a volume server to shut down without failing the writes:
+
+	1. the writable volumes are marked readonly, so the master stops assigning writes to them
+	   after the next heartbeat
+	2. the new http writes and deletes are rejected with 503, so the clients retry with another assignment,
+	   and the new grpc writes, deletes, ttl extensions and undeletes fail
+	3. the in flight writes are counted, to know when they are finished
+
+The shell volumeServer.drain polls until there are no in flight writes, and can then move the
+drained volumes to the other volume servers.
+
+The heartbeats continue, so the volumes are still readable until the volume server is stopped.
+Draining is not revocable unless the volume server is restarted.
+*/
+
+func (vs *VolumeServer) VolumeServerDrain(ctx context.Context, req *volume_server_pb.VolumeServerDrainRequest) (*volume_server_pb.VolumeServerDrainResponse, error) {
+
+	vs.drainLock.Lock()
+	defer vs.drainLock.Unlock()
+
+	if atomic.CompareAndSwapInt32(&vs.isDraining, 0, 1) {
+		for _, v := range vs.store.VolumeInfos() {
+			if v.ReadOnly {
+				continue
+			}
+			if err := vs.store.MarkVolumeReadonly(v.Id); err != nil {
+				glog.Errorf("drain volume %d: %v", v.Id, err)
+				continue
+			}
+			vs.drainedVolumeIds = append(vs.drainedVolumeIds, uint32(v.Id))
+		}
+		glog.V(0).Infof("draining volume server, marked %d volumes readonly", len(vs.drainedVolumeIds))
+	}
+
+	return &volume_server_pb.VolumeServerDrainResponse{
+		WritableVolumeIds: vs.drainedVolumeIds,
+		InFlightWrites:    atomic.LoadInt64(&vs.inFlightWriteCount),
+	}, nil
+
+}
+
+var errVolumeServerDraining = errors.New("volume server is draining")
+
+// startWrite counts the http write as in flight, or rejects it when draining.
+func (vs *VolumeServer) startWrite(w http.ResponseWriter, r *http.Request) bool {
+	if err := vs.startGrpcWrite(); err != nil {
+		writeJsonError(w, r, http.StatusServiceUnavailable, err)
+		return false
+	}
+	return true
+}
+
+// startGrpcWrite counts the grpc write as in flight, or fails it when draining.
+// The count is increased before the check, so no write accepted before the draining is missed.
+func (vs *VolumeServer) startGrpcWrite() error {
+	atomic.AddInt64(&vs.inFlightWriteCount, 1)
+	if atomic.LoadInt32(&vs.isDraining) == 1 {
+		vs.finishWrite()
+		return errVolumeServerDraining
+	}
+	return nil
+}
+
+func (vs *VolumeServer) finishWrite() {
+	atomic.AddInt64(&vs.inFlightWriteCount, -1)
+}
@@ -0,0 +1,47 @@
+package weed_server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/chrislusf/seaweedfs/weed/storage"
+)
+
+func TestVolumeServerDrain(t *testing.T) {
+	vs := &VolumeServer{store: &storage.Store{}}
+
+	if !vs.startWrite(httptest.NewRecorder(), httptest.NewRequest("POST", "/3,01637037d6", nil)) {
+		t.Fatalf("write should be accepted before draining")
+	}
+
+	resp, err := vs.VolumeServerDrain(context.Background(), &volume_server_pb.VolumeServerDrainRequest{})
+	if err != nil {
+		t.Fatalf("drain: %v", err)
+	}
+	if resp.InFlightWrites != 1 {
+		t.Errorf("in flight writes %d, expected 1", resp.InFlightWrites)
+	}
+
+	for _, method := range []string{"POST", "PUT", "DELETE"} {
+		w := httptest.NewRecorder()
+		vs.privateStoreHandler(w, httptest.NewRequest(method, "/3,01637037d6", nil))
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s while draining: %d, expected %d", method, w.Code, http.StatusServiceUnavailable)
+		}
+	}
+
+	if _, err = vs.BatchDelete(context.Background(), &volume_server_pb.BatchDeleteRequest{FileIds: []string{"3,01637037d6"}}); err != errVolumeServerDraining {
+		t.Errorf("grpc delete while draining: %v, expected %v", err, errVolumeServerDraining)
+	}
+	if _, err = vs.WriteNeedleBlob(context.Background(), &volume_server_pb.WriteNeedleBlobRequest{VolumeId: 3}); err != errVolumeServerDraining {
+		t.Errorf("grpc write while draining: %v, expected %v", err, errVolumeServerDraining)
+	}
+
+	vs.finishWrite()
+	if resp, _ = vs.VolumeServerDrain(context.Background(), &volume_server_pb.VolumeServerDrainRequest{}); resp.InFlightWrites != 0 {
+		t.Errorf("in flight writes %d after finished, expected 0", resp.InFlightWrites)
+	}
+}
@@ -39,9 +39,17 @@ func (vs *VolumeServer) privateStoreHandler(w http.ResponseWriter, r *http.Reque
 		stats.ReadRequest()
 		vs.GetOrHeadHandler(w, r)
 	case "DELETE":
+		if !vs.startWrite(w, r) {
+			return
+		}
+		defer vs.finishWrite()
 		stats.DeleteRequest()
 		vs.guard.WhiteList(vs.DeleteHandler)(w, r)
 	case "PUT", "POST":
+		if !vs.startWrite(w, r) {
+			return
+		}
+		defer vs.finishWrite()
 
 		// wait until in flight data is less than the limit
 		contentLength := getContentLength(r)
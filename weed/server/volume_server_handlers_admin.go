@@ -14,7 +14,12 @@ func (vs *VolumeServer) statusHandler(w http.ResponseWriter, r *http.Request) {
 	m := make(map[string]interface{})
 	m["Version"] = util.Version()
 	var ds []*volume_server_pb.DiskStatus
+	var failedDisks []string
 	for _, loc := range vs.store.Locations {
+		if loc.IsFailed() {
+			failedDisks = append(failedDisks, loc.Directory)
+			continue
+		}
 		if dir, e := filepath.Abs(loc.Directory); e == nil {
 			newDiskStatus := stats.NewDiskStatus(dir)
 			newDiskStatus.DiskType = loc.DiskType.String()
@@ -22,7 +27,16 @@ func (vs *VolumeServer) statusHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	m["DiskStatuses"] = ds
+	if len(failedDisks) > 0 {
+		m["FailedDisks"] = failedDisks
+	}
 	m["Volumes"] = vs.store.VolumeInfos()
+	if vs.store.IsScrubbing() {
+		m["Scrub"] = vs.store.ScrubStatuses()
+	}
+	if corruptNeedles := vs.store.CorruptNeedles(); len(corruptNeedles) > 0 {
+		m["CorruptNeedles"] = corruptNeedles
+	}
 	writeJsonQuiet(w, r, http.StatusOK, m)
 }
 
@@ -31,7 +45,12 @@ func (vs *VolumeServer) statsDiskHandler(w http.ResponseWriter, r *http.Request)
 	m := make(map[string]interface{})
 	m["Version"] = util.Version()
 	var ds []*volume_server_pb.DiskStatus
+	var failedDisks []string
 	for _, loc := range vs.store.Locations {
+		if loc.IsFailed() {
+			failedDisks = append(failedDisks, loc.Directory)
+			continue
+		}
 		if dir, e := filepath.Abs(loc.Directory); e == nil {
 			newDiskStatus := stats.NewDiskStatus(dir)
 			newDiskStatus.DiskType = loc.DiskType.String()
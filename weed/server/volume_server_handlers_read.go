@@ -98,6 +98,15 @@ func (vs *VolumeServer) GetOrHeadHandler(w http.ResponseWriter, r *http.Request)
 		// start to fix it from other replicas, if not deleted and hasVolume and is not a replicated request
 	}
 	// glog.V(4).Infoln("read bytes", count, "error", err)
+	if err == storage.ErrorNotFound && hasVolume && vs.redirectToLiveCopySource(w, r, volumeId) {
+		return
+	}
+	if err == storage.ErrorCorrupted {
+		// a server error lets the client read from another replica
+		glog.V(0).Infof("read %s: %v", r.URL.Path, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
 	if err != nil || count < 0 {
 		glog.V(3).Infof("read %s isNormalVolume %v error: %v", r.URL.Path, hasVolume, err)
 		w.WriteHeader(http.StatusNotFound)
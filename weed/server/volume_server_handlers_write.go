@@ -50,7 +50,10 @@ func (vs *VolumeServer) PostHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ret := operation.UploadResult{}
-	isUnchanged, writeError := topology.ReplicatedWrite(vs.GetMaster, vs.store, volumeId, reqNeedle, r)
+	stages := stats.NewUploadStages()
+	isUnchanged, writeError := topology.ReplicatedWrite(vs.GetMaster, vs.store, volumeId, reqNeedle, r.WithContext(stats.WithUploadStages(r.Context(), stages)))
+	stages.Observe()
+	stages.SetServerTiming(w, r)
 
 	// http 204 status code does not allow body
 	if writeError == nil && isUnchanged {
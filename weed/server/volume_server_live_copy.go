@@ -0,0 +1,46 @@
+package weed_server
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// A live copy is mounted read only while it tails the writes from the source volume.
+// The reads of the needles not tailed yet are redirected to the source, until the copy is marked writable.
+
+func (vs *VolumeServer) setLiveCopySource(vid needle.VolumeId, sourceVolumeServer string) {
+	vs.liveCopyLock.Lock()
+	defer vs.liveCopyLock.Unlock()
+	vs.liveCopySources[vid] = sourceVolumeServer
+}
+
+func (vs *VolumeServer) getLiveCopySource(vid needle.VolumeId) string {
+	vs.liveCopyLock.RLock()
+	defer vs.liveCopyLock.RUnlock()
+	return vs.liveCopySources[vid]
+}
+
+func (vs *VolumeServer) clearLiveCopySource(vid needle.VolumeId) {
+	vs.liveCopyLock.Lock()
+	defer vs.liveCopyLock.Unlock()
+	delete(vs.liveCopySources, vid)
+}
+
+// redirectToLiveCopySource returns false if the volume is not a live copy
+func (vs *VolumeServer) redirectToLiveCopySource(w http.ResponseWriter, r *http.Request, vid needle.VolumeId) bool {
+	sourceVolumeServer := vs.getLiveCopySource(vid)
+	if sourceVolumeServer == "" || r.FormValue("type") == "replicate" {
+		return false
+	}
+	u, err := url.Parse(util.NormalizeUrl(sourceVolumeServer))
+	if err != nil {
+		return false
+	}
+	u.Path = r.URL.Path
+	u.RawQuery = r.URL.RawQuery
+	http.Redirect(w, r, u.String(), http.StatusTemporaryRedirect)
+	return true
+}
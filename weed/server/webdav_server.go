@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"net/http"
 	"os"
 	"path"
 	"strings"
@@ -53,19 +54,33 @@ type WebDavServer struct {
 func NewWebDavServer(option *WebDavOption) (ws *WebDavServer, err error) {
 
 	fs, _ := NewWebDavFileSystem(option)
+	davFs := fs.(*WebDavFileSystem)
 
 	ws = &WebDavServer{
 		option:         option,
 		grpcDialOption: security.LoadClientTLS(util.GetViper(), "grpc.filer"),
 		Handler: &webdav.Handler{
 			FileSystem: fs,
-			LockSystem: webdav.NewMemLS(),
+			LockSystem: NewFilerLockSystem(davFs, davFs.signature),
 		},
 	}
 
 	return ws, nil
 }
 
+// ServeHTTP serves the webdav requests, checking the permissions of the requests with the identity headers
+func (ws *WebDavServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	identity, err := identityFromHeaders(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if identity != nil {
+		r = r.WithContext(filer.WithIdentity(r.Context(), identity))
+	}
+	ws.Handler.ServeHTTP(w, r)
+}
+
 // adapted from https://github.com/mattn/davfs/blob/master/plugin/mysql/mysql.go
 
 type WebDavFileSystem struct {
@@ -162,6 +177,10 @@ func (fs *WebDavFileSystem) Mkdir(ctx context.Context, fullDirPath string, perm
 	if err == nil {
 		return os.ErrExist
 	}
+	if err = fs.checkCreateAccess(ctx, fullDirPath); err != nil {
+		return err
+	}
+	uid, gid := fs.owner(ctx)
 
 	return fs.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
 		dir, name := util.FullPath(fullDirPath).DirAndName()
@@ -174,8 +193,8 @@ func (fs *WebDavFileSystem) Mkdir(ctx context.Context, fullDirPath string, perm
 					Mtime:    time.Now().Unix(),
 					Crtime:   time.Now().Unix(),
 					FileMode: uint32(perm | os.ModeDir),
-					Uid:      fs.option.Uid,
-					Gid:      fs.option.Gid,
+					Uid:      uid,
+					Gid:      gid,
 				},
 			},
 			Signatures: []int32{fs.signature},
@@ -209,8 +228,14 @@ func (fs *WebDavFileSystem) OpenFile(ctx context.Context, fullFilePath string, f
 			if flag&os.O_EXCL != 0 {
 				return nil, os.ErrExist
 			}
+			if err = fs.checkAccess(ctx, fullFilePath, filer.AclWrite); err != nil {
+				return nil, err
+			}
 			fs.removeAll(ctx, fullFilePath)
+		} else if err = fs.checkCreateAccess(ctx, fullFilePath); err != nil {
+			return nil, err
 		}
+		uid, gid := fs.owner(ctx)
 
 		dir, name := util.FullPath(fullFilePath).DirAndName()
 		err = fs.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
@@ -223,8 +248,8 @@ func (fs *WebDavFileSystem) OpenFile(ctx context.Context, fullFilePath string, f
 						Mtime:       time.Now().Unix(),
 						Crtime:      time.Now().Unix(),
 						FileMode:    uint32(perm),
-						Uid:         fs.option.Uid,
-						Gid:         fs.option.Gid,
+						Uid:         uid,
+						Gid:         gid,
 						Collection:  fs.option.Collection,
 						Replication: fs.option.Replication,
 						TtlSec:      0,
@@ -251,6 +276,15 @@ func (fs *WebDavFileSystem) OpenFile(ctx context.Context, fullFilePath string, f
 	if err != nil {
 		return nil, os.ErrNotExist
 	}
+	var accessPerm uint16 = filer.AclRead
+	if flag&os.O_WRONLY != 0 {
+		accessPerm = filer.AclWrite
+	} else if flag&os.O_RDWR != 0 {
+		accessPerm = filer.AclRead | filer.AclWrite
+	}
+	if err = fs.checkAccess(ctx, fullFilePath, accessPerm); err != nil {
+		return nil, err
+	}
 	if !strings.HasSuffix(fullFilePath, "/") && fi.IsDir() {
 		fullFilePath += "/"
 	}
@@ -280,6 +314,10 @@ func (fs *WebDavFileSystem) RemoveAll(ctx context.Context, name string) error {
 
 	glog.V(2).Infof("WebDavFileSystem.RemoveAll %v", name)
 
+	if err := fs.checkDeleteAccess(ctx, name); err != nil {
+		return err
+	}
+
 	return fs.removeAll(ctx, name)
 }
 
@@ -312,6 +350,12 @@ func (fs *WebDavFileSystem) Rename(ctx context.Context, oldName, newName string)
 	if err == nil {
 		return os.ErrExist
 	}
+	if err = fs.checkDeleteAccess(ctx, oldName); err != nil {
+		return err
+	}
+	if err = fs.checkCreateAccess(ctx, newName); err != nil {
+		return err
+	}
 
 	oldDir, oldBaseName := util.FullPath(oldName).DirAndName()
 	newDir, newBaseName := util.FullPath(newName).DirAndName()
@@ -364,6 +408,78 @@ func (fs *WebDavFileSystem) stat(ctx context.Context, fullFilePath string) (os.F
 	return &fi, nil
 }
 
+// checkAccess checks the permissions of the request identity, if any, to the entry
+func (fs *WebDavFileSystem) checkAccess(ctx context.Context, fullFilePath string, perm uint16) error {
+	identity := filer.IdentityFromContext(ctx)
+	if identity == nil {
+		return nil
+	}
+	return toWebDavPermissionError(filer.CheckAccess(ctx, fs.findEntry, webDavPath(fullFilePath), identity, perm))
+}
+
+// checkCreateAccess checks the permissions of the request identity, if any, to create the entry
+func (fs *WebDavFileSystem) checkCreateAccess(ctx context.Context, fullFilePath string) error {
+	identity := filer.IdentityFromContext(ctx)
+	if identity == nil {
+		return nil
+	}
+	return toWebDavPermissionError(filer.CheckCreateAccess(ctx, fs.findEntry, webDavPath(fullFilePath), identity))
+}
+
+// checkDeleteAccess checks the permissions of the request identity, if any, to delete or rename the entry
+func (fs *WebDavFileSystem) checkDeleteAccess(ctx context.Context, fullFilePath string) error {
+	identity := filer.IdentityFromContext(ctx)
+	if identity == nil {
+		return nil
+	}
+	return toWebDavPermissionError(filer.CheckDeleteAccess(ctx, fs.findEntry, webDavPath(fullFilePath), identity))
+}
+
+// owner is the owner of the new entries, the request identity or the webdav server user
+func (fs *WebDavFileSystem) owner(ctx context.Context) (uid, gid uint32) {
+	identity := filer.IdentityFromContext(ctx)
+	if identity == nil {
+		return fs.option.Uid, fs.option.Gid
+	}
+	gid = fs.option.Gid
+	if len(identity.Gids) > 0 {
+		gid = identity.Gids[0]
+	}
+	return identity.Uid, gid
+}
+
+func (fs *WebDavFileSystem) findEntry(ctx context.Context, p util.FullPath) (*filer.Entry, error) {
+	entry, err := filer_pb.GetEntry(fs, p)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, filer_pb.ErrNotFound
+	}
+	dir, _ := p.DirAndName()
+	return filer.FromPbEntry(dir, entry), nil
+}
+
+func webDavPath(fullFilePath string) util.FullPath {
+	if len(fullFilePath) > 1 && strings.HasSuffix(fullFilePath, "/") {
+		fullFilePath = fullFilePath[:len(fullFilePath)-1]
+	}
+	return util.FullPath(fullFilePath)
+}
+
+// toWebDavPermissionError returns os.ErrPermission for the denied requests, replied as 403 Forbidden,
+// and leaves the missing entries to the operations
+func toWebDavPermissionError(err error) error {
+	switch {
+	case err == nil || err == filer_pb.ErrNotFound:
+		return nil
+	case strings.Contains(err.Error(), filer.ErrPermissionDenied.Error()):
+		glog.V(2).Infof("webdav: %v", err)
+		return os.ErrPermission
+	}
+	return err
+}
+
 func (fs *WebDavFileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
 
 	glog.V(2).Infof("WebDavFileSystem.Stat %v", name)
@@ -0,0 +1,117 @@
+package weed_server
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/net/webdav"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// FilerLockSystem keeps the webdav lock tokens in memory,
+// and also holds a filer lock for each webdav lock,
+// so FUSE mounts, other webdav gateways and applications see the same locks.
+type FilerLockSystem struct {
+	webdav.LockSystem
+	filerClient filer_pb.FilerClient
+	signature   int32
+
+	filerLocks     map[string]*webdavFilerLock // webdav token => filer lock
+	filerLocksLock sync.Mutex
+}
+
+type webdavFilerLock struct {
+	path       string
+	owner      string
+	renewToken string
+}
+
+func NewFilerLockSystem(filerClient filer_pb.FilerClient, signature int32) *FilerLockSystem {
+	return &FilerLockSystem{
+		LockSystem:  webdav.NewMemLS(),
+		filerClient: filerClient,
+		signature:   signature,
+		filerLocks:  make(map[string]*webdavFilerLock),
+	}
+}
+
+func (ls *FilerLockSystem) Create(now time.Time, details webdav.LockDetails) (token string, err error) {
+
+	owner := fmt.Sprintf("webdav:%d:%x", ls.signature, util.RandomBytes(8))
+	// the locks with infinite depth also lock the entries under the directory
+	resp, err := filer_pb.Lock(ls.filerClient, details.Root, owner, "", toFilerLockDuration(details.Duration), false, !details.ZeroDepth)
+	if err == filer_pb.ErrLockHeldByOthers {
+		return "", webdav.ErrLocked
+	}
+	if err != nil {
+		glog.Errorf("webdav lock %s: %v", details.Root, err)
+		return "", err
+	}
+
+	token, err = ls.LockSystem.Create(now, details)
+	if err != nil {
+		filer_pb.Unlock(ls.filerClient, details.Root, resp.RenewToken)
+		return "", err
+	}
+
+	ls.filerLocksLock.Lock()
+	ls.filerLocks[token] = &webdavFilerLock{
+		path:       details.Root,
+		owner:      owner,
+		renewToken: resp.RenewToken,
+	}
+	ls.filerLocksLock.Unlock()
+
+	return token, nil
+}
+
+func (ls *FilerLockSystem) Refresh(now time.Time, token string, duration time.Duration) (webdav.LockDetails, error) {
+
+	details, err := ls.LockSystem.Refresh(now, token, duration)
+	if err != nil {
+		return details, err
+	}
+
+	ls.filerLocksLock.Lock()
+	filerLock, found := ls.filerLocks[token]
+	ls.filerLocksLock.Unlock()
+
+	if found {
+		if _, err = filer_pb.Lock(ls.filerClient, filerLock.path, filerLock.owner, filerLock.renewToken, toFilerLockDuration(duration), false, false); err != nil {
+			glog.Errorf("webdav refresh lock %s: %v", filerLock.path, err)
+			ls.Unlock(now, token)
+			return details, webdav.ErrNoSuchLock
+		}
+	}
+
+	return details, nil
+}
+
+func (ls *FilerLockSystem) Unlock(now time.Time, token string) error {
+
+	ls.filerLocksLock.Lock()
+	filerLock, found := ls.filerLocks[token]
+	delete(ls.filerLocks, token)
+	ls.filerLocksLock.Unlock()
+
+	if found {
+		if err := filer_pb.Unlock(ls.filerClient, filerLock.path, filerLock.renewToken); err != nil {
+			glog.Warningf("webdav unlock %s: %v", filerLock.path, err)
+		}
+	}
+
+	return ls.LockSystem.Unlock(now, token)
+}
+
+func toFilerLockDuration(d time.Duration) time.Duration {
+	// webdav uses a negative duration for infinite timeout
+	if d < 0 || d > filer.MaxLockDuration {
+		return filer.MaxLockDuration
+	}
+	return d
+}
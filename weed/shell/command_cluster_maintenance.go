@@ -0,0 +1,220 @@
+package shell
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/chrislusf/seaweedfs/weed/storage/super_block"
+	"github.com/chrislusf/seaweedfs/weed/storage/types"
+	"github.com/chrislusf/seaweedfs/weed/topology"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func init() {
+	Commands = append(Commands, &commandClusterMaintenance{})
+}
+
+type commandClusterMaintenance struct {
+}
+
+func (c *commandClusterMaintenance) Name() string {
+	return "cluster.maintenance"
+}
+
+func (c *commandClusterMaintenance) Help() string {
+	return `put a data center, a rack, or a data node into maintenance before powering it down
+
+	cluster.maintenance                                        # list all in maintenance
+	cluster.maintenance -dataCenter dc1 [-rack rack1] [-status] [-exit]
+	cluster.maintenance -node <volume server host:port> [-status] [-exit]
+	cluster.maintenance -dataCenter dc1 -fixReplication [-n]
+
+	In maintenance, no new volumes are created there, and the volumes with a replica there are not writable,
+	so no new writes are assigned there. The reads are not affected.
+
+	It is safe to power down when every volume with a replica there has enough copies elsewhere,
+	and every erasure coded shard there also has a copy elsewhere.
+	With -fixReplication, the volumes without enough copies elsewhere are copied to other data nodes,
+	following the replication of the volumes. The erasure coded shards are to be moved by ec.balance or volumeServer.evacuate.
+
+	The maintenance is kept by the leader master, and needs to be entered again if the leader changes.
+`
+}
+
+type clusterMaintenanceResult struct {
+	Maintenance []*topology.MaintenanceStatus
+}
+
+func (c *commandClusterMaintenance) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	maintenanceCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	dataCenter := maintenanceCommand.String("dataCenter", "", "the data center")
+	rack := maintenanceCommand.String("rack", "", "the rack of the data center")
+	dataNode := maintenanceCommand.String("node", "", "the volume server <host>:<port>")
+	statusOnly := maintenanceCommand.Bool("status", false, "only report the maintenance")
+	exitMaintenance := maintenanceCommand.Bool("exit", false, "take it out of maintenance")
+	fixReplication := maintenanceCommand.Bool("fixReplication", false, "copy the volumes without enough copies elsewhere")
+	skipChange := maintenanceCommand.Bool("n", false, "skip the changes of -fixReplication")
+	if err = maintenanceCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	values := url.Values{}
+	values.Set("dataCenter", *dataCenter)
+	values.Set("rack", *rack)
+	values.Set("node", *dataNode)
+
+	path := "/cluster/maintenance"
+	hasScope := *dataCenter != "" || *rack != "" || *dataNode != ""
+	if hasScope && !*statusOnly {
+		if err = commandEnv.confirmIsLocked(); err != nil {
+			return
+		}
+		path = "/cluster/maintenance/enter"
+		if *exitMaintenance {
+			path = "/cluster/maintenance/exit"
+		}
+	}
+
+	result, err := c.post(commandEnv, path, values)
+	if err != nil {
+		return err
+	}
+
+	if *fixReplication && hasScope && !*exitMaintenance {
+		for _, status := range result.Maintenance {
+			if err = c.fixReplication(commandEnv, writer, status, !*skipChange); err != nil {
+				return err
+			}
+		}
+		if !*skipChange {
+			fmt.Fprintf(writer, "the new copies are counted after the next heartbeats, check again with -status\n")
+		}
+	}
+
+	if len(result.Maintenance) == 0 {
+		fmt.Fprintf(writer, "nothing in maintenance\n")
+	}
+	for _, status := range result.Maintenance {
+		printMaintenanceStatus(writer, status)
+	}
+	return nil
+}
+
+func (c *commandClusterMaintenance) post(commandEnv *CommandEnv, path string, values url.Values) (*clusterMaintenanceResult, error) {
+	data, err := util.Post(fmt.Sprintf("http://%s%s", commandEnv.MasterClient.GetMaster(), path), values)
+	if err != nil {
+		return nil, err
+	}
+	var result clusterMaintenanceResult
+	if err = json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("parse maintenance: %v", err)
+	}
+	return &result, nil
+}
+
+func printMaintenanceStatus(writer io.Writer, status *topology.MaintenanceStatus) {
+	fmt.Fprintf(writer, "%s in maintenance since %s, %d data nodes, %d volumes, %d ec volumes\n",
+		status.MaintenanceScope.String(), time.Unix(status.Since, 0).Format(time.RFC3339),
+		len(status.DataNodes), status.VolumeCount, status.EcVolumeCount)
+	for _, v := range status.UnsafeVolumes {
+		fmt.Fprintf(writer, "  volume %d collection %q replication %s: %d copies, %d of %d copies elsewhere\n",
+			v.Id, v.Collection, v.Replication, v.Copies, v.CopiesOutside, v.ExpectedCopies)
+	}
+	for _, v := range status.UnsafeEcVolumes {
+		fmt.Fprintf(writer, "  ec volume %d collection %q: shards %v only here\n", v.Id, v.Collection, v.ShardIds)
+	}
+	if status.SafeToPowerDown {
+		fmt.Fprintf(writer, "  safe to power down\n")
+	} else {
+		fmt.Fprintf(writer, "  not safe to power down yet\n")
+	}
+}
+
+// fixReplication copies the volumes without enough copies outside of the maintenance to the data nodes outside
+func (c *commandClusterMaintenance) fixReplication(commandEnv *CommandEnv, writer io.Writer, status *topology.MaintenanceStatus, takeAction bool) error {
+	if len(status.UnsafeVolumes) == 0 {
+		return nil
+	}
+
+	topologyInfo, _, err := collectTopologyInfo(commandEnv)
+	if err != nil {
+		return err
+	}
+	volumeReplicas, allLocations := collectVolumeReplicaLocations(topologyInfo)
+
+	inMaintenance := func(loc location) bool {
+		return loc.dc == status.DataCenter &&
+			(status.Rack == "" || loc.rack == status.Rack) &&
+			(status.DataNode == "" || loc.dataNode.Id == status.DataNode)
+	}
+	var outsideLocations []location
+	for _, loc := range allLocations {
+		if !inMaintenance(loc) {
+			outsideLocations = append(outsideLocations, loc)
+		}
+	}
+
+	for _, v := range status.UnsafeVolumes {
+		replicas := volumeReplicas[uint32(v.Id)]
+		if len(replicas) == 0 {
+			continue
+		}
+		source := pickOneReplicaToCopyFrom(replicas)
+		replicaPlacement, _ := super_block.NewReplicaPlacementFromByte(byte(source.info.ReplicaPlacement))
+		diskType := types.ToDiskType(source.info.DiskType)
+		var outsideReplicas []*VolumeReplica
+		for _, replica := range replicas {
+			if !inMaintenance(*replica.location) {
+				outsideReplicas = append(outsideReplicas, replica)
+			}
+		}
+
+		for len(outsideReplicas) < replicaPlacement.GetCopyCount() {
+			keepDataNodesSorted(outsideLocations, diskType)
+			fn := capacityByFreeVolumeCount(diskType)
+			var dst *location
+			for i := range outsideLocations {
+				if fn(outsideLocations[i].dataNode) > 0 && satisfyReplicaPlacement(replicaPlacement, outsideReplicas, outsideLocations[i]) {
+					dst = &outsideLocations[i]
+					break
+				}
+			}
+			if dst == nil {
+				fmt.Fprintf(writer, "failed to place volume %d replica as %s outside of the maintenance, existing:%d\n", v.Id, replicaPlacement, len(outsideReplicas))
+				break
+			}
+
+			fmt.Fprintf(writer, "replicating volume %d %s from %s to dataNode %s ...\n", v.Id, replicaPlacement, source.location.dataNode.Id, dst.dataNode.Id)
+			if takeAction {
+				err := operation.WithVolumeServerClient(dst.dataNode.Id, commandEnv.option.GrpcDialOption, func(volumeServerClient volume_server_pb.VolumeServerClient) error {
+					_, replicateErr := volumeServerClient.VolumeCopy(context.Background(), &volume_server_pb.VolumeCopyRequest{
+						VolumeId:       source.info.Id,
+						SourceDataNode: source.location.dataNode.Id,
+						DiskType:       source.info.DiskType,
+					})
+					if replicateErr != nil {
+						return fmt.Errorf("copying from %s => %s : %v", source.location.dataNode.Id, dst.dataNode.Id, replicateErr)
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			// adjust the volume count
+			dst.dataNode.DiskInfos[string(diskType)].VolumeCount++
+			newLocation := *dst
+			outsideReplicas = append(outsideReplicas, &VolumeReplica{location: &newLocation, info: source.info})
+		}
+	}
+	return nil
+}
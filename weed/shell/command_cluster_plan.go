@@ -0,0 +1,119 @@
+package shell
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/topology"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func init() {
+	Commands = append(Commands, &commandClusterPlan{})
+}
+
+type commandClusterPlan struct {
+}
+
+func (c *commandClusterPlan) Name() string {
+	return "cluster.plan"
+}
+
+func (c *commandClusterPlan) Help() string {
+	return `forecast when the volume slots run out with the expected growth, and recommend the volumes to grow
+
+	cluster.plan -growth logs:010:hdd:50GiB,photos::ssd:10GiB [-days 30] [-apply]
+
+	Each growth is "<collection>:<replication>:<disk type>:<size per day>", with the default replication if empty.
+	The new data first fills the writable volumes of the collection, then takes new volumes.
+
+	For each disk type, the plan shows the free slots, the slots used per day, the days until the free slots run out,
+	and the slots missing within the days. For each collection, the plan recommends the volumes to grow now,
+	which are grown with -apply.
+`
+}
+
+func (c *commandClusterPlan) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	planCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	growth := planCommand.String("growth", "", "comma separated <collection>:<replication>:<disk type>:<size per day>")
+	days := planCommand.Int("days", 30, "the planning horizon in days")
+	applyGrowth := planCommand.Bool("apply", false, "grow the recommended volumes")
+	if err = planCommand.Parse(args); err != nil {
+		return nil
+	}
+	if *growth == "" {
+		return fmt.Errorf("missing -growth")
+	}
+
+	if *applyGrowth {
+		if err = commandEnv.confirmIsLocked(); err != nil {
+			return
+		}
+	}
+
+	values := url.Values{}
+	for _, spec := range strings.Split(*growth, ",") {
+		values.Add("growth", strings.TrimSpace(spec))
+	}
+	values.Set("days", strconv.Itoa(*days))
+
+	master := commandEnv.MasterClient.GetMaster()
+	data, err := util.Post(fmt.Sprintf("http://%s/cluster/plan", master), values)
+	if err != nil {
+		return err
+	}
+	var plan topology.CapacityPlan
+	if err = json.Unmarshal(data, &plan); err != nil {
+		return fmt.Errorf("parse plan: %v", err)
+	}
+	c.printPlan(writer, &plan)
+
+	if !*applyGrowth {
+		return nil
+	}
+	for _, cp := range plan.Collections {
+		if cp.RecommendedVolumes <= 0 {
+			continue
+		}
+		growValues := url.Values{}
+		growValues.Set("collection", cp.Collection)
+		growValues.Set("replication", cp.Replication)
+		growValues.Set("disk", cp.DiskType)
+		growValues.Set("count", strconv.FormatInt(cp.RecommendedVolumes, 10))
+		if _, err = util.Post(fmt.Sprintf("http://%s/vol/grow", master), growValues); err != nil {
+			return fmt.Errorf("grow %d volumes for collection %q: %v", cp.RecommendedVolumes, cp.Collection, err)
+		}
+		fmt.Fprintf(writer, "grew %d volumes for collection %q\n", cp.RecommendedVolumes, cp.Collection)
+	}
+	return nil
+}
+
+func (c *commandClusterPlan) printPlan(writer io.Writer, plan *topology.CapacityPlan) {
+	fmt.Fprintf(writer, "in %d days, with the volume size limit %s:\n", plan.HorizonDays, util.BytesToHumanReadable(plan.VolumeSizeLimit))
+	for _, tier := range plan.Tiers {
+		diskType := tier.DiskType
+		if diskType == "" {
+			diskType = "hdd"
+		}
+		full := "never"
+		if tier.DaysUntilFull >= 0 {
+			full = fmt.Sprintf("in %d days", tier.DaysUntilFull)
+		}
+		fmt.Fprintf(writer, "  disk %s: %d free slots, %.2f slots per day, %d slots needed, full %s",
+			diskType, tier.FreeSlots, tier.SlotsPerDay, tier.SlotsNeeded, full)
+		if tier.Shortage > 0 {
+			fmt.Fprintf(writer, ", short of %d slots", tier.Shortage)
+		}
+		fmt.Fprintln(writer)
+	}
+	for _, cp := range plan.Collections {
+		fmt.Fprintf(writer, "  collection %q replication %s: %d writable volumes with %s room, %d volumes needed, grow %d volumes now\n",
+			cp.Collection, cp.Replication, cp.WritableVolumes, util.BytesToHumanReadable(cp.WritableRoom), cp.VolumesNeeded, cp.RecommendedVolumes)
+	}
+}
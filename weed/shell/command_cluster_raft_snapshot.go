@@ -0,0 +1,67 @@
+package shell
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func init() {
+	Commands = append(Commands, &commandClusterRaftSnapshot{})
+}
+
+type commandClusterRaftSnapshot struct {
+}
+
+func (c *commandClusterRaftSnapshot) Name() string {
+	return "cluster.raft.snapshot"
+}
+
+func (c *commandClusterRaftSnapshot) Help() string {
+	return `take the raft snapshots of the masters, and compact their raft logs
+
+	cluster.raft.snapshot [-master <master host:port>]
+
+	The masters also take the snapshots periodically, with "-raft.snapshotIntervalMinutes".
+	A snapshot is only taken if any raft log entry is committed since the last snapshot.
+`
+}
+
+type raftSnapshotResult struct {
+	CommitIndex uint64
+	LogEntries  int
+}
+
+func (c *commandClusterRaftSnapshot) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	snapshotCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	master := snapshotCommand.String("master", "", "<host>:<port> of the master, default to all masters")
+	if err = snapshotCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	masters := strings.Split(*commandEnv.option.Masters, ",")
+	if *master != "" {
+		masters = []string{*master}
+	}
+
+	for _, m := range masters {
+		data, postErr := util.Post(fmt.Sprintf("http://%s/cluster/raft/snapshot", m), nil)
+		if postErr != nil {
+			fmt.Fprintf(writer, "%s: %v\n", m, postErr)
+			err = postErr
+			continue
+		}
+		var result raftSnapshotResult
+		if err := json.Unmarshal(data, &result); err != nil {
+			return fmt.Errorf("%s: %v", m, err)
+		}
+		fmt.Fprintf(writer, "%s: commit index %d, %d log entries kept\n", m, result.CommitIndex, result.LogEntries)
+	}
+
+	return err
+}
@@ -0,0 +1,82 @@
+package shell
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+
+	"github.com/chrislusf/seaweedfs/weed/topology"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func init() {
+	Commands = append(Commands, &commandClusterReadOnly{})
+}
+
+type commandClusterReadOnly struct {
+}
+
+func (c *commandClusterReadOnly) Name() string {
+	return "cluster.readonly"
+}
+
+func (c *commandClusterReadOnly) Help() string {
+	return `freeze or unfreeze all writes to the cluster
+
+	cluster.readonly                                    # show whether the cluster is read only
+	cluster.readonly -enable -reason "migrating to dc2" # reject all new writes
+	cluster.readonly -disable                           # accept the writes again
+
+	When the cluster is read only, the master rejects all file id assignments and volume growths,
+	and the filers reject all writes, including the writes from S3 and mount, with "cluster is read only".
+	The filers pick up the switch within a few seconds.
+	This is for emergencies, e.g. during migrations, or when the free space is critically low.
+`
+}
+
+func (c *commandClusterReadOnly) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	readOnlyCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	enable := readOnlyCommand.Bool("enable", false, "switch the cluster to read only")
+	disable := readOnlyCommand.Bool("disable", false, "switch the cluster back to writable")
+	reason := readOnlyCommand.String("reason", "", "the reason shown in the errors of the rejected writes")
+	if err = readOnlyCommand.Parse(args); err != nil {
+		return nil
+	}
+	if *enable && *disable {
+		return fmt.Errorf("only one of -enable and -disable")
+	}
+
+	master := commandEnv.MasterClient.GetMaster()
+	var data []byte
+	if *enable || *disable {
+		if err = commandEnv.confirmIsLocked(); err != nil {
+			return
+		}
+		values := url.Values{}
+		values.Set("readOnly", strconv.FormatBool(*enable))
+		values.Set("reason", *reason)
+		data, err = util.Post(fmt.Sprintf("http://%s/cluster/readonly/set", master), values)
+	} else {
+		data, err = util.Post(fmt.Sprintf("http://%s/cluster/readonly", master), url.Values{})
+	}
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		ReadOnly topology.ClusterReadOnly
+	}
+	if err = json.Unmarshal(data, &resp); err != nil {
+		return fmt.Errorf("parse cluster read only: %v", err)
+	}
+	if !resp.ReadOnly.ReadOnly {
+		fmt.Fprintf(writer, "cluster is writable\n")
+		return nil
+	}
+	fmt.Fprintf(writer, "cluster is read only since %v: %s\n", resp.ReadOnly.Since.Format("2006-01-02 15:04:05"), resp.ReadOnly.Reason)
+	return nil
+}
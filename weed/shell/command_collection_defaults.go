@@ -0,0 +1,146 @@
+package shell
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+
+	"github.com/chrislusf/seaweedfs/weed/topology"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func init() {
+	Commands = append(Commands, &commandCollectionDefaults{})
+}
+
+type commandCollectionDefaults struct {
+}
+
+func (c *commandCollectionDefaults) Name() string {
+	return "collection.defaults"
+}
+
+func (c *commandCollectionDefaults) Help() string {
+	return `show or change the defaults of collections registered at the master
+
+	collection.defaults [-collection <collection>]
+	collection.defaults -collection <collection> [-replication 001] [-ttl 7d] [-disk ssd] [-ecLayout 6+3] [-maxVolumeCount 100]
+	collection.defaults -collection <collection> -delete
+
+	The assign and grow requests of the collection without the replication, ttl or disk type use the defaults.
+	The ec.encode without -layout uses the ec layout of the collection.
+	New volumes are not grown for the collection once it has the max volume count. 0 means no limit.
+
+	Only the given settings are changed, and an empty value clears the setting.
+`
+}
+
+func (c *commandCollectionDefaults) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	defaultsCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	collection := defaultsCommand.String("collection", "", "the collection name")
+	replication := defaultsCommand.String("replication", "", "the default replication")
+	ttl := defaultsCommand.String("ttl", "", "the default time to live, e.g. 1m, 1h, 1d, 1M, 1y")
+	diskType := defaultsCommand.String("disk", "", "the default disk type, [hdd|ssd|<tag>]")
+	ecLayout := defaultsCommand.String("ecLayout", "", "the number of data shards and parity shards for ec.encode, e.g. 6+3")
+	maxVolumeCount := defaultsCommand.Int("maxVolumeCount", 0, "the max volume count of the collection, 0 means no limit")
+	deleteDefaults := defaultsCommand.Bool("delete", false, "remove the defaults of the collection")
+	if err = defaultsCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	changed := make(map[string]bool)
+	defaultsCommand.Visit(func(f *flag.Flag) {
+		changed[f.Name] = true
+	})
+	hasCollection := changed["collection"]
+	delete(changed, "collection")
+
+	if len(changed) == 0 {
+		list, err := fetchCollectionDefaults(commandEnv, *collection, !hasCollection)
+		if err != nil {
+			return err
+		}
+		for _, defaults := range list {
+			printCollectionDefaults(writer, defaults)
+		}
+		return nil
+	}
+
+	if err = commandEnv.confirmIsLocked(); err != nil {
+		return
+	}
+
+	master := commandEnv.MasterClient.GetMaster()
+	if *deleteDefaults {
+		if _, err = util.Post(fmt.Sprintf("http://%s/col/defaults/delete", master), url.Values{"collection": []string{*collection}}); err != nil {
+			return fmt.Errorf("delete the defaults of collection %q: %v", *collection, err)
+		}
+		fmt.Fprintf(writer, "removed the defaults of collection %q\n", *collection)
+		return nil
+	}
+
+	list, err := fetchCollectionDefaults(commandEnv, *collection, false)
+	if err != nil {
+		return err
+	}
+	defaults := &topology.CollectionDefaults{Collection: *collection}
+	if len(list) > 0 {
+		defaults = list[0]
+	}
+	if changed["replication"] {
+		defaults.Replication = *replication
+	}
+	if changed["ttl"] {
+		defaults.Ttl = *ttl
+	}
+	if changed["disk"] {
+		defaults.DiskType = *diskType
+	}
+	if changed["ecLayout"] {
+		defaults.EcLayout = *ecLayout
+	}
+	if changed["maxVolumeCount"] {
+		defaults.MaxVolumeCount = *maxVolumeCount
+	}
+
+	values := url.Values{}
+	values.Set("collection", defaults.Collection)
+	values.Set("replication", defaults.Replication)
+	values.Set("ttl", defaults.Ttl)
+	values.Set("disk", defaults.DiskType)
+	values.Set("ecLayout", defaults.EcLayout)
+	values.Set("maxVolumeCount", strconv.Itoa(defaults.MaxVolumeCount))
+	if _, err = util.Post(fmt.Sprintf("http://%s/col/defaults/set", master), values); err != nil {
+		return fmt.Errorf("set the defaults of collection %q: %v", *collection, err)
+	}
+	printCollectionDefaults(writer, defaults)
+	return nil
+}
+
+// fetchCollectionDefaults returns the defaults of all collections, or only the registered defaults of the collection
+func fetchCollectionDefaults(commandEnv *CommandEnv, collection string, all bool) ([]*topology.CollectionDefaults, error) {
+	values := url.Values{}
+	if !all {
+		values.Set("collection", collection)
+	}
+	data, err := util.Post(fmt.Sprintf("http://%s/col/defaults", commandEnv.MasterClient.GetMaster()), values)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Collections []*topology.CollectionDefaults
+	}
+	if err = json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("parse collection defaults: %v", err)
+	}
+	return resp.Collections, nil
+}
+
+func printCollectionDefaults(writer io.Writer, defaults *topology.CollectionDefaults) {
+	fmt.Fprintf(writer, "collection:%q\treplication:%s\tttl:%s\tdisk:%s\tecLayout:%s\tmaxVolumeCount:%d\n",
+		defaults.Collection, defaults.Replication, defaults.Ttl, defaults.DiskType, defaults.EcLayout, defaults.MaxVolumeCount)
+}
@@ -44,7 +44,7 @@ func moveMountedShardToEcNode(commandEnv *CommandEnv, existingLocation *EcNode,
 
 	}
 
-	destinationEcNode.addEcVolumeShards(vid, collection, copiedShardIds)
+	destinationEcNode.addEcVolumeShards(vid, collection, ecVolumeLayout([]*EcNode{existingLocation}, vid), copiedShardIds)
 	existingLocation.deleteEcVolumeShards(vid, copiedShardIds)
 
 	return nil
@@ -296,7 +296,21 @@ func findEcVolumeShards(ecNode *EcNode, vid needle.VolumeId) erasure_coding.Shar
 	return 0
 }
 
-func (ecNode *EcNode) addEcVolumeShards(vid needle.VolumeId, collection string, shardIds []uint32) *EcNode {
+// ecVolumeLayout finds the erasure coding layout of the ec volume on any of the ec nodes
+func ecVolumeLayout(ecNodes []*EcNode, vid needle.VolumeId) erasure_coding.EcLayout {
+	for _, ecNode := range ecNodes {
+		for _, diskInfo := range ecNode.info.DiskInfos {
+			for _, shardInfo := range diskInfo.EcShardInfos {
+				if needle.VolumeId(shardInfo.Id) == vid {
+					return erasure_coding.ToEcLayout(shardInfo.DataShards, shardInfo.ParityShards)
+				}
+			}
+		}
+	}
+	return erasure_coding.DefaultEcLayout
+}
+
+func (ecNode *EcNode) addEcVolumeShards(vid needle.VolumeId, collection string, layout erasure_coding.EcLayout, shardIds []uint32) *EcNode {
 
 	foundVolume := false
 	diskInfo, found := ecNode.info.DiskInfos[string(types.HardDriveType)]
@@ -327,10 +341,12 @@ func (ecNode *EcNode) addEcVolumeShards(vid needle.VolumeId, collection string,
 			newShardBits = newShardBits.AddShardId(erasure_coding.ShardId(shardId))
 		}
 		diskInfo.EcShardInfos = append(diskInfo.EcShardInfos, &master_pb.VolumeEcShardInformationMessage{
-			Id:          uint32(vid),
-			Collection:  collection,
-			EcIndexBits: uint32(newShardBits),
-			DiskType:    string(types.HardDriveType),
+			Id:           uint32(vid),
+			Collection:   collection,
+			EcIndexBits:  uint32(newShardBits),
+			DiskType:     string(types.HardDriveType),
+			DataShards:   uint32(layout.DataShards),
+			ParityShards: uint32(layout.ParityShards),
 		})
 		ecNode.freeEcSlot -= len(shardIds)
 	}
@@ -32,17 +32,28 @@ func (c *commandEcEncode) Name() string {
 func (c *commandEcEncode) Help() string {
 	return `apply erasure coding to a volume
 
-	ec.encode [-collection=""] [-fullPercent=95] [-quietFor=1h]
-	ec.encode [-collection=""] [-volumeId=<volume_id>]
+	ec.encode [-collection=""] [-fullPercent=95] [-quietFor=1h] [-layout=10+4] [-encodeMBps=0]
+	ec.encode [-collection=""] [-volumeId=<volume_id>] [-layout=10+4] [-encodeMBps=0]
 
 	This command will:
 	1. freeze one volume
 	2. apply erasure coding to the volume
 	3. move the encoded shards to multiple volume servers
 
-	The erasure coding is 10.4. So ideally you have more than 14 volume servers, and you can afford
+	The erasure coding is 10.4 by default. So ideally you have more than 14 volume servers, and you can afford
 	to lose 4 volume servers.
 
+	The layout can be changed with "-layout=<data shards>+<parity shards>", at most 32 shards in total,
+	e.g. 4+2 for small clusters, or 12+3 for large clusters. Run ec.encode for each collection
+	to use different layouts for different collections, or register the layout of a collection with
+	collection.defaults -ecLayout, which is used without "-layout". The layout is saved with the ec volume,
+	and ec.rebuild, ec.balance and ec.decode follow it.
+
+	To avoid disturbing the foreground traffic, "-encodeMBps=<n>" limits how fast the volume server
+	reads the volume for encoding. If the encoding is interrupted, e.g. by a volume server restart,
+	running ec.encode again for the volume continues from the last checkpoint, instead of
+	encoding the whole volume again.
+
 	If the number of volumes are not high, the worst case is that you only have 4 volume servers,
 	and the shards are spread as 4,4,3,3, respectively. You can afford to lose one volume server.
 
@@ -63,15 +74,32 @@ func (c *commandEcEncode) Do(args []string, commandEnv *CommandEnv, writer io.Wr
 	collection := encodeCommand.String("collection", "", "the collection name")
 	fullPercentage := encodeCommand.Float64("fullPercent", 95, "the volume reaches the percentage of max volume size")
 	quietPeriod := encodeCommand.Duration("quietFor", time.Hour, "select volumes without no writes for this period")
+	layoutString := encodeCommand.String("layout", "", "the number of data shards and parity shards, e.g. 4+2, by default the ec layout of the collection, or 10+4")
+	encodeMBps := encodeCommand.Int("encodeMBps", 0, "limit the volume encoding speed in mega bytes per second, 0 means no limit")
 	if err = encodeCommand.Parse(args); err != nil {
 		return nil
 	}
 
+	if *layoutString == "" {
+		list, err := fetchCollectionDefaults(commandEnv, *collection, false)
+		if err != nil {
+			return fmt.Errorf("find the ec layout of collection %q: %v", *collection, err)
+		}
+		if len(list) > 0 {
+			*layoutString = list[0].EcLayout
+		}
+	}
+	layout, err := erasure_coding.ParseEcLayout(*layoutString)
+	if err != nil {
+		return err
+	}
+
+	bytesPerSecond := int64(*encodeMBps) * 1024 * 1024
 	vid := needle.VolumeId(*volumeId)
 
 	// volumeId is provided
 	if vid != 0 {
-		return doEcEncode(commandEnv, *collection, vid)
+		return doEcEncode(commandEnv, *collection, vid, layout, bytesPerSecond)
 	}
 
 	// apply to all volumes in the collection
@@ -81,7 +109,7 @@ func (c *commandEcEncode) Do(args []string, commandEnv *CommandEnv, writer io.Wr
 	}
 	fmt.Printf("ec encode volumes: %v\n", volumeIds)
 	for _, vid := range volumeIds {
-		if err = doEcEncode(commandEnv, *collection, vid); err != nil {
+		if err = doEcEncode(commandEnv, *collection, vid, layout, bytesPerSecond); err != nil {
 			return err
 		}
 	}
@@ -89,7 +117,7 @@ func (c *commandEcEncode) Do(args []string, commandEnv *CommandEnv, writer io.Wr
 	return nil
 }
 
-func doEcEncode(commandEnv *CommandEnv, collection string, vid needle.VolumeId) (err error) {
+func doEcEncode(commandEnv *CommandEnv, collection string, vid needle.VolumeId, layout erasure_coding.EcLayout, bytesPerSecond int64) (err error) {
 	// find volume location
 	locations, found := commandEnv.MasterClient.GetLocations(uint32(vid))
 	if !found {
@@ -105,13 +133,13 @@ func doEcEncode(commandEnv *CommandEnv, collection string, vid needle.VolumeId)
 	}
 
 	// generate ec shards
-	err = generateEcShards(commandEnv.option.GrpcDialOption, vid, collection, locations[0].Url)
+	err = generateEcShards(commandEnv.option.GrpcDialOption, vid, collection, layout, bytesPerSecond, locations[0].Url)
 	if err != nil {
 		return fmt.Errorf("generate ec shards for volume %d on %s: %v", vid, locations[0].Url, err)
 	}
 
 	// balance the ec shards to current cluster
-	err = spreadEcShards(commandEnv, vid, collection, locations)
+	err = spreadEcShards(commandEnv, vid, collection, layout, locations)
 	if err != nil {
 		return fmt.Errorf("spread ec shards for volume %d from %s: %v", vid, locations[0].Url, err)
 	}
@@ -141,14 +169,17 @@ func markVolumeReadonly(grpcDialOption grpc.DialOption, volumeId needle.VolumeId
 	return nil
 }
 
-func generateEcShards(grpcDialOption grpc.DialOption, volumeId needle.VolumeId, collection string, sourceVolumeServer string) error {
+func generateEcShards(grpcDialOption grpc.DialOption, volumeId needle.VolumeId, collection string, layout erasure_coding.EcLayout, bytesPerSecond int64, sourceVolumeServer string) error {
 
-	fmt.Printf("generateEcShards %s %d on %s ...\n", collection, volumeId, sourceVolumeServer)
+	fmt.Printf("generateEcShards %s %d with layout %s on %s ...\n", collection, volumeId, layout, sourceVolumeServer)
 
 	err := operation.WithVolumeServerClient(sourceVolumeServer, grpcDialOption, func(volumeServerClient volume_server_pb.VolumeServerClient) error {
 		_, genErr := volumeServerClient.VolumeEcShardsGenerate(context.Background(), &volume_server_pb.VolumeEcShardsGenerateRequest{
-			VolumeId:   uint32(volumeId),
-			Collection: collection,
+			VolumeId:       uint32(volumeId),
+			Collection:     collection,
+			DataShards:     uint32(layout.DataShards),
+			ParityShards:   uint32(layout.ParityShards),
+			BytesPerSecond: bytesPerSecond,
 		})
 		return genErr
 	})
@@ -157,26 +188,26 @@ func generateEcShards(grpcDialOption grpc.DialOption, volumeId needle.VolumeId,
 
 }
 
-func spreadEcShards(commandEnv *CommandEnv, volumeId needle.VolumeId, collection string, existingLocations []wdclient.Location) (err error) {
+func spreadEcShards(commandEnv *CommandEnv, volumeId needle.VolumeId, collection string, layout erasure_coding.EcLayout, existingLocations []wdclient.Location) (err error) {
 
 	allEcNodes, totalFreeEcSlots, err := collectEcNodes(commandEnv, "")
 	if err != nil {
 		return err
 	}
 
-	if totalFreeEcSlots < erasure_coding.TotalShardsCount {
+	if totalFreeEcSlots < layout.TotalShards() {
 		return fmt.Errorf("not enough free ec shard slots. only %d left", totalFreeEcSlots)
 	}
 	allocatedDataNodes := allEcNodes
-	if len(allocatedDataNodes) > erasure_coding.TotalShardsCount {
-		allocatedDataNodes = allocatedDataNodes[:erasure_coding.TotalShardsCount]
+	if len(allocatedDataNodes) > layout.TotalShards() {
+		allocatedDataNodes = allocatedDataNodes[:layout.TotalShards()]
 	}
 
 	// calculate how many shards to allocate for these servers
-	allocatedEcIds := balancedEcDistribution(allocatedDataNodes)
+	allocatedEcIds := balancedEcDistribution(allocatedDataNodes, layout)
 
 	// ask the data nodes to copy from the source volume server
-	copiedShardIds, err := parallelCopyEcShardsFromSource(commandEnv.option.GrpcDialOption, allocatedDataNodes, allocatedEcIds, volumeId, collection, existingLocations[0])
+	copiedShardIds, err := parallelCopyEcShardsFromSource(commandEnv.option.GrpcDialOption, allocatedDataNodes, allocatedEcIds, volumeId, collection, layout, existingLocations[0])
 	if err != nil {
 		return err
 	}
@@ -206,7 +237,7 @@ func spreadEcShards(commandEnv *CommandEnv, volumeId needle.VolumeId, collection
 
 }
 
-func parallelCopyEcShardsFromSource(grpcDialOption grpc.DialOption, targetServers []*EcNode, allocatedEcIds [][]uint32, volumeId needle.VolumeId, collection string, existingLocation wdclient.Location) (actuallyCopied []uint32, err error) {
+func parallelCopyEcShardsFromSource(grpcDialOption grpc.DialOption, targetServers []*EcNode, allocatedEcIds [][]uint32, volumeId needle.VolumeId, collection string, layout erasure_coding.EcLayout, existingLocation wdclient.Location) (actuallyCopied []uint32, err error) {
 
 	fmt.Printf("parallelCopyEcShardsFromSource %d %s\n", volumeId, existingLocation.Url)
 
@@ -227,7 +258,7 @@ func parallelCopyEcShardsFromSource(grpcDialOption grpc.DialOption, targetServer
 				err = copyErr
 			} else {
 				shardIdChan <- copiedShardIds
-				server.addEcVolumeShards(volumeId, collection, copiedShardIds)
+				server.addEcVolumeShards(volumeId, collection, layout, copiedShardIds)
 			}
 		}(server, allocatedEcIds[i])
 	}
@@ -245,11 +276,11 @@ func parallelCopyEcShardsFromSource(grpcDialOption grpc.DialOption, targetServer
 	return
 }
 
-func balancedEcDistribution(servers []*EcNode) (allocated [][]uint32) {
+func balancedEcDistribution(servers []*EcNode, layout erasure_coding.EcLayout) (allocated [][]uint32) {
 	allocated = make([][]uint32, len(servers))
 	allocatedShardIdIndex := uint32(0)
 	serverIndex := 0
-	for allocatedShardIdIndex < erasure_coding.TotalShardsCount {
+	for allocatedShardIdIndex < uint32(layout.TotalShards()) {
 		if servers[serverIndex].freeEcSlot > 0 {
 			allocated[serverIndex] = append(allocated[serverIndex], allocatedShardIdIndex)
 			allocatedShardIdIndex++
@@ -105,21 +105,22 @@ func rebuildEcVolumes(commandEnv *CommandEnv, allEcNodes []*EcNode, collection s
 	}
 
 	for vid, locations := range ecShardMap {
+		layout := ecVolumeLayout(allEcNodes, vid)
 		shardCount := locations.shardCount()
-		if shardCount == erasure_coding.TotalShardsCount {
+		if shardCount == layout.TotalShards() {
 			continue
 		}
-		if shardCount < erasure_coding.DataShardsCount {
-			return fmt.Errorf("ec volume %d is unrepairable with %d shards\n", vid, shardCount)
+		if shardCount < layout.DataShards {
+			return fmt.Errorf("ec volume %d is unrepairable with %d shards of layout %s\n", vid, shardCount, layout)
 		}
 
 		sortEcNodesByFreeslotsDecending(allEcNodes)
 
-		if allEcNodes[0].freeEcSlot < erasure_coding.TotalShardsCount {
+		if allEcNodes[0].freeEcSlot < layout.TotalShards() {
 			return fmt.Errorf("disk space is not enough")
 		}
 
-		if err := rebuildOneEcVolume(commandEnv, allEcNodes[0], collection, vid, locations, writer, applyChanges); err != nil {
+		if err := rebuildOneEcVolume(commandEnv, allEcNodes[0], collection, vid, layout, locations, writer, applyChanges); err != nil {
 			return err
 		}
 	}
@@ -127,13 +128,13 @@ func rebuildEcVolumes(commandEnv *CommandEnv, allEcNodes []*EcNode, collection s
 	return nil
 }
 
-func rebuildOneEcVolume(commandEnv *CommandEnv, rebuilder *EcNode, collection string, volumeId needle.VolumeId, locations EcShardLocations, writer io.Writer, applyChanges bool) error {
+func rebuildOneEcVolume(commandEnv *CommandEnv, rebuilder *EcNode, collection string, volumeId needle.VolumeId, layout erasure_coding.EcLayout, locations EcShardLocations, writer io.Writer, applyChanges bool) error {
 
 	fmt.Printf("rebuildOneEcVolume %s %d\n", collection, volumeId)
 
 	// collect shard files to rebuilder local disk
 	var generatedShardIds []uint32
-	copiedShardIds, _, err := prepareDataToRecover(commandEnv, rebuilder, collection, volumeId, locations, writer, applyChanges)
+	copiedShardIds, _, err := prepareDataToRecover(commandEnv, rebuilder, collection, volumeId, layout, locations, writer, applyChanges)
 	if err != nil {
 		return err
 	}
@@ -164,7 +165,7 @@ func rebuildOneEcVolume(commandEnv *CommandEnv, rebuilder *EcNode, collection st
 		return err
 	}
 
-	rebuilder.addEcVolumeShards(volumeId, collection, generatedShardIds)
+	rebuilder.addEcVolumeShards(volumeId, collection, layout, generatedShardIds)
 
 	return nil
 }
@@ -184,7 +185,7 @@ func generateMissingShards(grpcDialOption grpc.DialOption, collection string, vo
 	return
 }
 
-func prepareDataToRecover(commandEnv *CommandEnv, rebuilder *EcNode, collection string, volumeId needle.VolumeId, locations EcShardLocations, writer io.Writer, applyBalancing bool) (copiedShardIds []uint32, localShardIds []uint32, err error) {
+func prepareDataToRecover(commandEnv *CommandEnv, rebuilder *EcNode, collection string, volumeId needle.VolumeId, layout erasure_coding.EcLayout, locations EcShardLocations, writer io.Writer, applyBalancing bool) (copiedShardIds []uint32, localShardIds []uint32, err error) {
 
 	needEcxFile := true
 	var localShardBits erasure_coding.ShardBits
@@ -237,7 +238,7 @@ func prepareDataToRecover(commandEnv *CommandEnv, rebuilder *EcNode, collection
 
 	}
 
-	if len(copiedShardIds)+len(localShardIds) >= erasure_coding.DataShardsCount {
+	if len(copiedShardIds)+len(localShardIds) >= layout.DataShards {
 		return copiedShardIds, localShardIds, nil
 	}
 
@@ -254,7 +255,7 @@ func (ecShardMap EcShardMap) registerEcNode(ecNode *EcNode, collection string) {
 			if shardInfo.Collection == collection {
 				existing, found := ecShardMap[needle.VolumeId(shardInfo.Id)]
 				if !found {
-					existing = make([][]*EcNode, erasure_coding.TotalShardsCount)
+					existing = make([][]*EcNode, erasure_coding.ToEcLayout(shardInfo.DataShards, shardInfo.ParityShards).TotalShards())
 					ecShardMap[needle.VolumeId(shardInfo.Id)] = existing
 				}
 				for _, shardId := range erasure_coding.ShardBits(shardInfo.EcIndexBits).ShardIds() {
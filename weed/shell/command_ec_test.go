@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/chrislusf/seaweedfs/weed/pb/master_pb"
+	"github.com/chrislusf/seaweedfs/weed/storage/erasure_coding"
 	"github.com/chrislusf/seaweedfs/weed/storage/needle"
 )
 
@@ -15,11 +16,39 @@ func TestCommandEcDistribution(t *testing.T) {
 		newEcNode("dc1", "rack2", "dn2", 100),
 	}
 
-	allocated := balancedEcDistribution(allEcNodes)
+	allocated := balancedEcDistribution(allEcNodes, erasure_coding.DefaultEcLayout)
 
 	fmt.Printf("allocated: %+v", allocated)
 }
 
+func TestCommandEcDistributionWithLayout(t *testing.T) {
+
+	allEcNodes := []*EcNode{
+		newEcNode("dc1", "rack1", "dn1", 100),
+		newEcNode("dc1", "rack2", "dn2", 100),
+		newEcNode("dc1", "rack3", "dn3", 100),
+		newEcNode("dc1", "rack4", "dn4", 100),
+	}
+
+	allocated := balancedEcDistribution(allEcNodes, erasure_coding.EcLayout{DataShards: 4, ParityShards: 2})
+
+	var shardIds []uint32
+	for _, ids := range allocated {
+		if len(ids) > 2 {
+			t.Errorf("unbalanced allocation: %+v", allocated)
+		}
+		shardIds = append(shardIds, ids...)
+	}
+	if len(shardIds) != 6 {
+		t.Errorf("expected 6 shards allocated: %+v", allocated)
+	}
+
+	node := newEcNode("dc1", "rack1", "dn5", 100).addEcVolumeShards(1, "c1", erasure_coding.EcLayout{DataShards: 4, ParityShards: 2}, shardIds)
+	if layout := ecVolumeLayout([]*EcNode{node}, 1); layout.String() != "4+2" {
+		t.Errorf("unexpected layout %s", layout)
+	}
+}
+
 func TestCommandEcBalanceSmall(t *testing.T) {
 
 	allEcNodes := []*EcNode{
@@ -136,5 +165,5 @@ func newEcNode(dc string, rack string, dataNodeId string, freeEcSlot int) *EcNod
 }
 
 func (ecNode *EcNode) addEcVolumeAndShardsForTest(vid uint32, collection string, shardIds []uint32) *EcNode {
-	return ecNode.addEcVolumeShards(needle.VolumeId(vid), collection, shardIds)
+	return ecNode.addEcVolumeShards(needle.VolumeId(vid), collection, erasure_coding.DefaultEcLayout, shardIds)
 }
@@ -0,0 +1,137 @@
+package shell
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func init() {
+	Commands = append(Commands, &commandFsAcl{})
+}
+
+type commandFsAcl struct {
+}
+
+func (c *commandFsAcl) Name() string {
+	return "fs.acl"
+}
+
+func (c *commandFsAcl) Help() string {
+	return `set or show the POSIX ACLs of a file or directory
+
+	# show the access ACL and the default ACL
+	fs.acl -path=/data/projectA
+
+	# allow the user 1000 and the group 100 to read and write, in the getfacl short text form with numeric ids
+	fs.acl -path=/data/projectA -set="user::rwx,user:1000:rwx,group::r-x,group:100:rwx,other::---"
+
+	# the default ACL of a directory, inherited by the new files and directories under it
+	fs.acl -path=/data/projectA -default -set="user::rwx,user:1000:rwx,group::r-x,other::---"
+
+	# remove the ACL, or the default ACL
+	fs.acl -path=/data/projectA -remove
+	fs.acl -path=/data/projectA -default -remove
+
+	The mask is computed if not set. The permission bits of the mode follow the access ACL.
+	The ACLs are checked for the http and webdav requests with the identity headers,
+	and for "weed mount -acl".
+
+`
+}
+
+func (c *commandFsAcl) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	fsAclCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	path := fsAclCommand.String("path", "", "the file or directory path")
+	aclText := fsAclCommand.String("set", "", "the ACL to set, e.g. user::rw-,user:1000:rw-,group::r--,other::---")
+	isDefault := fsAclCommand.Bool("default", false, "set or remove the default ACL of the directory")
+	isRemove := fsAclCommand.Bool("remove", false, "remove the ACL")
+	if err = fsAclCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	if *path == "" {
+		return fmt.Errorf("need to specify -path")
+	}
+	p, err := commandEnv.parseUrl(*path)
+	if err != nil {
+		return err
+	}
+	dir, _ := util.FullPath(p).DirAndName()
+
+	entry, err := filer_pb.GetEntry(commandEnv, util.FullPath(p))
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return fmt.Errorf("%s not found", p)
+	}
+
+	if *aclText == "" && !*isRemove {
+		return printAcls(writer, p, entry)
+	}
+
+	key := filer.ExtPosixAclAccessKey
+	if *isDefault {
+		if !entry.IsDirectory {
+			return fmt.Errorf("%s: only directories have the default ACL", p)
+		}
+		key = filer.ExtPosixAclDefaultKey
+	}
+
+	if *isRemove {
+		delete(entry.Extended, key)
+	} else {
+		acl, parseErr := filer.ParseAcl(*aclText)
+		if parseErr != nil {
+			return parseErr
+		}
+		if entry.Extended == nil {
+			entry.Extended = make(map[string][]byte)
+		}
+		entry.Extended[key] = acl.Encode()
+	}
+
+	if err = commandEnv.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+		return filer_pb.UpdateEntry(client, &filer_pb.UpdateEntryRequest{
+			Directory: dir,
+			Entry:     entry,
+		})
+	}); err != nil {
+		return err
+	}
+
+	if entry, err = filer_pb.GetEntry(commandEnv, util.FullPath(p)); err != nil {
+		return err
+	}
+	return printAcls(writer, p, entry)
+}
+
+func printAcls(writer io.Writer, p string, entry *filer_pb.Entry) error {
+	fmt.Fprintf(writer, "# file: %s\n", p)
+	fmt.Fprintf(writer, "# owner: %d\n", entry.Attributes.Uid)
+	fmt.Fprintf(writer, "# group: %d\n", entry.Attributes.Gid)
+	fmt.Fprintf(writer, "# mode: %v\n", os.FileMode(entry.Attributes.FileMode))
+	for _, acl := range []struct {
+		key    string
+		prefix string
+	}{
+		{filer.ExtPosixAclAccessKey, ""},
+		{filer.ExtPosixAclDefaultKey, "default:"},
+	} {
+		parsed, err := filer.DecodeAcl(entry.Extended[acl.key])
+		if err != nil {
+			return fmt.Errorf("%s: %v", p, err)
+		}
+		for _, e := range parsed {
+			fmt.Fprintf(writer, "%s%s\n", acl.prefix, filer.Acl{e}.String())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func init() {
+	Commands = append(Commands, &commandFsArchive{})
+}
+
+type commandFsArchive struct {
+}
+
+func (c *commandFsArchive) Name() string {
+	return "fs.archive"
+}
+
+func (c *commandFsArchive) Help() string {
+	return `recursively move files to the archive storage class
+
+	fs.archive -olderThanDays=90 /buckets/bucket1/logs
+	fs.archive -collection=archive -olderThanDays=30 -apply /buckets/bucket1/logs
+
+	The file content is copied to the archive collection, and the files are marked with storage class GLACIER.
+	Archived files can not be read until being restored with the S3 RestoreObject API.
+
+	Run it periodically in master.maintenance scripts, and move the archive collection volumes to a
+	slow and cheap remote tier with "volume.tier.upload -collection=archive".
+
+`
+}
+
+func (c *commandFsArchive) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	fsArchiveCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	collection := fsArchiveCommand.String("collection", "", "the archive collection, default to the filer archive_collection option")
+	olderThanDays := fsArchiveCommand.Int("olderThanDays", 0, "only archive files not modified in these days")
+	apply := fsArchiveCommand.Bool("apply", false, "archive the files, otherwise only list them")
+	if err = fsArchiveCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	path, err := commandEnv.parseUrl(findInputDirectory(fsArchiveCommand.Args()))
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-time.Duration(*olderThanDays) * 24 * time.Hour).Unix()
+
+	var fileCount, archivedCount uint64
+
+	err = filer_pb.TraverseBfs(commandEnv, util.FullPath(path), func(parentPath util.FullPath, entry *filer_pb.Entry) {
+
+		if entry.IsDirectory || entry.Attributes == nil || entry.Attributes.Mtime > cutoff {
+			return
+		}
+		if string(entry.Extended[filer.ExtStorageClassKey]) == filer.ArchiveStorageClass {
+			return
+		}
+		fileCount++
+
+		fullpath := parentPath.Child(entry.Name)
+		if !*apply {
+			fmt.Fprintf(writer, "%s\n", fullpath)
+			return
+		}
+
+		archiveErr := commandEnv.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+			resp, err := client.ArchiveEntry(context.Background(), &filer_pb.ArchiveEntryRequest{
+				Directory:  string(parentPath),
+				Name:       entry.Name,
+				Collection: *collection,
+			})
+			if err != nil {
+				return err
+			}
+			if resp.Error != "" {
+				return fmt.Errorf("%s", resp.Error)
+			}
+			return nil
+		})
+		if archiveErr != nil {
+			fmt.Fprintf(writer, "fail to archive %s: %v\n", fullpath, archiveErr)
+			return
+		}
+		archivedCount++
+		fmt.Fprintf(writer, "archived %s\n", fullpath)
+
+	})
+
+	if err == nil {
+		if *apply {
+			fmt.Fprintf(writer, "\ntotal archived %d of %d files\n", archivedCount, fileCount)
+		} else {
+			fmt.Fprintf(writer, "\ntotal %d files to archive, use -apply to archive them\n", fileCount)
+		}
+	}
+
+	return err
+
+}
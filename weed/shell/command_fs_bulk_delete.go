@@ -0,0 +1,135 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+)
+
+func init() {
+	Commands = append(Commands, &commandFsBulkDelete{})
+}
+
+type commandFsBulkDelete struct {
+}
+
+func (c *commandFsBulkDelete) Name() string {
+	return "fs.bulk.delete"
+}
+
+func (c *commandFsBulkDelete) Help() string {
+	return `delete many entries with a background job on the filer, and show the progress
+
+	# delete everything under /logs with the name prefix 2020-, recursively
+	fs.bulk.delete -dir=/logs -prefix=2020- -recursive
+
+	# delete a huge directory itself, at most 1000 entries per second, without waiting
+	fs.bulk.delete -path=/data/huge -recursive -entriesPerSecond=1000 -async
+
+	# show the progress of a job, or all recent jobs
+	fs.bulk.delete -job=<jobId>
+	fs.bulk.delete -list
+
+	The job continues if the shell exits, and is resumed after the filer restarts.
+	The filer default speed is set by "bulk_delete_entries_per_second" in filer.toml.
+
+`
+}
+
+func (c *commandFsBulkDelete) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	bulkDeleteCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	dir := bulkDeleteCommand.String("dir", "", "delete the entries under this directory")
+	prefix := bulkDeleteCommand.String("prefix", "", "only delete the entries in -dir with this name prefix")
+	path := bulkDeleteCommand.String("path", "", "delete this file or directory")
+	isRecursive := bulkDeleteCommand.Bool("recursive", false, "delete the directories with their children")
+	skipChunkDeletion := bulkDeleteCommand.Bool("skipChunkDeletion", false, "only delete the meta data, keeping the file content on volume servers")
+	entriesPerSecond := bulkDeleteCommand.Int64("entriesPerSecond", 0, "max number of entries deleted per second, 0 for the filer default")
+	isAsync := bulkDeleteCommand.Bool("async", false, "start the job without waiting for it to complete")
+	jobId := bulkDeleteCommand.String("job", "", "show the progress of the job")
+	isList := bulkDeleteCommand.Bool("list", false, "show the running and recently completed jobs")
+	if err = bulkDeleteCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	return commandEnv.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+
+		if *isList {
+			resp, err := client.ListBulkDeleteJobs(context.Background(), &filer_pb.ListBulkDeleteJobsRequest{})
+			if err != nil {
+				return err
+			}
+			for _, job := range resp.Jobs {
+				printBulkDeleteJob(writer, job)
+			}
+			return nil
+		}
+
+		if *jobId == "" {
+			request := &filer_pb.BulkDeleteRequest{
+				Directory:        *dir,
+				NamePrefix:       *prefix,
+				IsRecursive:      *isRecursive,
+				IsDeleteData:     !*skipChunkDeletion,
+				EntriesPerSecond: *entriesPerSecond,
+			}
+			if *path != "" {
+				request.Paths = []string{*path}
+			}
+			resp, err := client.BulkDelete(context.Background(), request)
+			if err != nil {
+				return err
+			}
+			if resp.Error != "" {
+				return fmt.Errorf("bulk delete: %s", resp.Error)
+			}
+			*jobId = resp.JobId
+			fmt.Fprintf(writer, "started bulk delete job %s\n", *jobId)
+		}
+
+		for {
+			var waitSeconds int32 = 10
+			if *isAsync {
+				waitSeconds = 0
+			}
+			resp, err := client.GetBulkDeleteJob(context.Background(), &filer_pb.GetBulkDeleteJobRequest{
+				JobId:       *jobId,
+				WaitSeconds: waitSeconds,
+			})
+			if err != nil {
+				return err
+			}
+			if resp.Error != "" {
+				return fmt.Errorf("bulk delete job: %s", resp.Error)
+			}
+			printBulkDeleteJob(writer, resp.Job)
+			if *isAsync || resp.Job.CompletedAtNs != 0 {
+				for _, failure := range resp.Job.Failures {
+					fmt.Fprintf(writer, "  failed %s: %s\n", failure.Path, failure.Error)
+				}
+				return nil
+			}
+		}
+	})
+
+}
+
+func printBulkDeleteJob(writer io.Writer, job *filer_pb.BulkDeleteJob) {
+	target := fmt.Sprintf("%s prefix %s", job.Directory, job.NamePrefix)
+	if job.Directory == "" {
+		target = fmt.Sprintf("%d paths", job.PathCount)
+	}
+	progress := "running"
+	elapsed := time.Since(time.Unix(0, job.StartedAtNs))
+	if job.CompletedAtNs != 0 {
+		progress = "completed"
+		elapsed = time.Unix(0, job.CompletedAtNs).Sub(time.Unix(0, job.StartedAtNs))
+	}
+	fmt.Fprintf(writer, "%s %s %s: %d deleted, %d chunks, %d failed, in %v, %.0f/sec\n",
+		job.JobId, target, progress, job.DeletedEntryCount, job.DeletedChunkCount, job.FailedEntryCount,
+		elapsed.Round(time.Second), float64(job.DeletedEntryCount)/(elapsed.Seconds()+0.001))
+}
@@ -5,10 +5,12 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"path"
 	"strings"
 
 	"github.com/chrislusf/seaweedfs/weed/filer"
 	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
 	"github.com/chrislusf/seaweedfs/weed/storage/super_block"
 )
 
@@ -36,12 +38,43 @@ func (c *commandFsConfigure) Help() string {
 	# example: configure adding only 1 physical volume for each bucket collection
 	fs.configure -locationPrfix=/buckets/ -volumeGrowthCount=1
 
+	# example: allow at most 1 million entries in each directory under /data/
+	fs.configure -locationPrfix=/data/ -dirEntriesLimit=1000000
+
+	# example: the new files under /cache/ expire in 7 days, except under /cache/keep/
+	fs.configure -locationPrefix=/cache/ -ttl=7d -apply
+	fs.configure -locationPrefix=/cache/keep/ -ttl=0 -apply
+
+	# example: keep at most 10 prior versions of each file under /docs/, for up to 30 days, see fs.versions
+	fs.configure -locationPrefix=/docs/ -versioning -maxVersions=10 -versionRetentionDays=30 -apply
+
+	# example: the files under /records/ can be written in 1 hour after creation, then can not be changed or deleted for 7 years
+	fs.configure -locationPrefix=/records/ -worm -wormGracePeriod=1h -wormRetentionDays=2557 -apply
+
+	# example: split the files uploaded under /backups/ by content, and store the identical chunks only once
+	fs.configure -locationPrefix=/backups/ -dedup -apply
+
+	# example: shard the entries of a huge flat directory in the filer store, only for empty directories
+	fs.configure -locationPrfix=/data/flat/ -dirShards=64
+
 	# apply the changes
 	fs.configure -locationPrfix=/my/folder -collection=abc -apply
 
 	# delete the changes
 	fs.configure -locationPrfix=/my/folder -delete -apply
 
+	# example: route new files by name, content type, or size, the first matching route wins
+	fs.configure -locationPrefix=/ -namePattern=*.tmp -collection=tmp -ttl=1d -apply
+	fs.configure -locationPrefix=/data/ -contentType=video/* -collection=videos -apply
+	fs.configure -locationPrefix=/data/ -minSize=1073741824 -disk=hdd -apply
+
+	# delete a route with the same conditions
+	fs.configure -locationPrefix=/ -namePattern=*.tmp -delete -apply
+
+	The routes apply to the options not set by the request, and are evaluated when the filer assigns the file ids.
+	The content type and size are only known for the files uploaded via http, including s3, but not for "weed mount".
+	The files in s3 buckets always go to the bucket collection.
+
 `
 }
 
@@ -55,7 +88,20 @@ func (c *commandFsConfigure) Do(args []string, commandEnv *CommandEnv, writer io
 	diskType := fsConfigureCommand.String("disk", "", "[hdd|ssd|<tag>] hard drive or solid state drive or any tag")
 	fsync := fsConfigureCommand.Bool("fsync", false, "fsync for the writes")
 	volumeGrowthCount := fsConfigureCommand.Int("volumeGrowthCount", 0, "the number of physical volumes to add if no writable volumes")
-	isDelete := fsConfigureCommand.Bool("delete", false, "delete the configuration by locationPrefix")
+	dirEntriesLimit := fsConfigureCommand.Uint64("dirEntriesLimit", 0, "the max number of entries in each directory under the path prefix")
+	dirShards := fsConfigureCommand.Uint("dirShards", 0, "shard the entries of this exact directory in the filer store, only for empty directories")
+	versioning := fsConfigureCommand.Bool("versioning", false, "keep the prior versions of the overwritten or deleted files")
+	maxVersions := fsConfigureCommand.Uint("maxVersions", 0, "the max number of prior versions kept for each file, 0 for no limit")
+	versionRetentionDays := fsConfigureCommand.Uint("versionRetentionDays", 0, "the number of days to keep the prior versions, 0 for no limit")
+	worm := fsConfigureCommand.Bool("worm", false, "write once read many, the files can not be changed or deleted after the grace period")
+	wormGracePeriod := fsConfigureCommand.Duration("wormGracePeriod", 0, "the commit window after the file creation for worm, e.g. 10m")
+	wormRetentionDays := fsConfigureCommand.Uint("wormRetentionDays", 0, "the number of days to retain the worm files after the commit, 0 to retain forever")
+	dedup := fsConfigureCommand.Bool("dedup", false, "split the uploaded files by content, and store the identical chunks only once")
+	namePattern := fsConfigureCommand.String("namePattern", "", "route the new files with the file name matching this glob, e.g. *.tmp")
+	contentType := fsConfigureCommand.String("contentType", "", "route the new files with this content type, e.g. video/mp4, or video/* for any video")
+	minSize := fsConfigureCommand.Uint64("minSize", 0, "route the new files of at least this size in bytes")
+	maxSize := fsConfigureCommand.Uint64("maxSize", 0, "route the new files smaller than this size in bytes")
+	isDelete := fsConfigureCommand.Bool("delete", false, "delete the configuration by locationPrefix, or the route with the same conditions")
 	apply := fsConfigureCommand.Bool("apply", false, "update and apply filer configuration")
 	if err = fsConfigureCommand.Parse(args); err != nil {
 		return nil
@@ -75,7 +121,28 @@ func (c *commandFsConfigure) Do(args []string, commandEnv *CommandEnv, writer io
 		}
 	}
 
-	if *locationPrefix != "" {
+	isRoute := *namePattern != "" || *contentType != "" || *minSize > 0 || *maxSize > 0
+	if isRoute {
+		route := &filer_pb.FilerConf_RouteRule{
+			LocationPrefix: *locationPrefix,
+			NamePattern:    *namePattern,
+			ContentType:    *contentType,
+			MinSize:        *minSize,
+			MaxSize:        *maxSize,
+			Collection:     *collection,
+			Replication:    *replication,
+			Ttl:            *ttl,
+			DiskType:       *diskType,
+		}
+		if err = checkRouteRule(route); err != nil {
+			return err
+		}
+		if *isDelete {
+			fc.DeleteRouteRule(route)
+		} else {
+			fc.AddRouteRule(route)
+		}
+	} else if *locationPrefix != "" {
 		locConf := &filer_pb.FilerConf_PathConf{
 			LocationPrefix:    *locationPrefix,
 			Collection:        *collection,
@@ -84,6 +151,23 @@ func (c *commandFsConfigure) Do(args []string, commandEnv *CommandEnv, writer io
 			Fsync:             *fsync,
 			DiskType:          *diskType,
 			VolumeGrowthCount: uint32(*volumeGrowthCount),
+
+			DirectoryEntriesLimit: *dirEntriesLimit,
+			DirectoryShards:       uint32(*dirShards),
+
+			Versioning:           *versioning,
+			MaxVersions:          uint32(*maxVersions),
+			VersionRetentionDays: uint32(*versionRetentionDays),
+
+			Worm:                   *worm,
+			WormGracePeriodSeconds: uint32(wormGracePeriod.Seconds()),
+			WormRetentionDays:      uint32(*wormRetentionDays),
+
+			Dedup: *dedup,
+		}
+		// the quotas are set by fs.quota
+		if existing, found := fc.GetLocationConf(*locationPrefix); found {
+			locConf.QuotaBytes, locConf.QuotaEntries = existing.QuotaBytes, existing.QuotaEntries
 		}
 
 		// check collection
@@ -102,6 +186,24 @@ func (c *commandFsConfigure) Do(args []string, commandEnv *CommandEnv, writer io
 			}
 		}
 
+		// check ttl
+		if *ttl != "" {
+			if _, err := needle.ReadTTL(*ttl); err != nil {
+				return fmt.Errorf("parse ttl %s: %v", *ttl, err)
+			}
+		}
+
+		// check directory shards
+		if *dirShards > maxDirShards {
+			return fmt.Errorf("dirShards %d should be at most %d", *dirShards, maxDirShards)
+		}
+		existingDirShards := uint(fc.DirShardCount(*locationPrefix))
+		if *isDelete && existingDirShards > 0 || !*isDelete && existingDirShards != *dirShards {
+			if err = checkDirectoryEmpty(commandEnv, *locationPrefix); err != nil {
+				return fmt.Errorf("change dirShards from %d: %v", existingDirShards, err)
+			}
+		}
+
 		// save it
 		if *isDelete {
 			fc.DeleteLocationConf(*locationPrefix)
@@ -127,3 +229,45 @@ func (c *commandFsConfigure) Do(args []string, commandEnv *CommandEnv, writer io
 	return nil
 
 }
+
+const maxDirShards = 1024
+
+func checkRouteRule(route *filer_pb.FilerConf_RouteRule) error {
+	if _, err := path.Match(route.NamePattern, ""); err != nil {
+		return fmt.Errorf("parse name pattern %s: %v", route.NamePattern, err)
+	}
+	if route.MaxSize > 0 && route.MaxSize <= route.MinSize {
+		return fmt.Errorf("maxSize %d should be larger than minSize %d", route.MaxSize, route.MinSize)
+	}
+	if route.Replication != "" {
+		if _, err := super_block.NewReplicaPlacementFromString(route.Replication); err != nil {
+			return fmt.Errorf("parse replication %s: %v", route.Replication, err)
+		}
+	}
+	if route.Ttl != "" {
+		if _, err := needle.ReadTTL(route.Ttl); err != nil {
+			return fmt.Errorf("parse ttl %s: %v", route.Ttl, err)
+		}
+	}
+	return nil
+}
+
+// checkDirectoryEmpty ensures the directory has no entries, which would be lost when changing the shards
+func checkDirectoryEmpty(commandEnv *CommandEnv, locationPrefix string) error {
+	dir := strings.TrimSuffix(locationPrefix, "/")
+	if dir == "" {
+		dir = "/"
+	}
+	hasEntries := false
+	err := filer_pb.List(commandEnv, dir, "", func(entry *filer_pb.Entry, isLast bool) error {
+		hasEntries = true
+		return nil
+	}, "", false, 1)
+	if err != nil && err != filer_pb.ErrNotFound {
+		return fmt.Errorf("list %s: %v", dir, err)
+	}
+	if hasEntries {
+		return fmt.Errorf("directory %s is not empty", dir)
+	}
+	return nil
+}
@@ -1,9 +1,14 @@
 package shell
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 
@@ -26,22 +31,44 @@ func (c *commandFsMetaLoad) Help() string {
 	return `load saved filer meta data to restore the directory and file structure
 
 	fs.meta.load <filer_host>-<port>-<time>.meta
+	fs.meta.load -logs t.meta                             # also replay all the change log segments of t.meta
+	fs.meta.load -until=2021-06-01T10:00:00Z t.meta      # restore to the time, by replaying the changes until then
+
+	The change log segments are the <file>.meta.<time>.log files saved by "fs.meta.save -follow", next to the snapshot.
+	The changes during saving the snapshot are replayed again, so the restored time should be after the snapshot is saved.
+	The file contents are not restored, only the meta data. The chunks should still exist in the volume servers.
 
 `
 }
 
 func (c *commandFsMetaLoad) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
 
-	if len(args) == 0 {
+	fsMetaLoadCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	withLogs := fsMetaLoadCommand.Bool("logs", false, "replay the change log segments after loading the snapshot")
+	until := fsMetaLoadCommand.String("until", "", "replay the changes until this RFC3339 time, e.g. 2021-06-01T10:00:00Z")
+	if err = fsMetaLoadCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	if fsMetaLoadCommand.NArg() == 0 {
 		fmt.Fprintf(writer, "missing a metadata file\n")
 		return nil
 	}
+	fileName := fsMetaLoadCommand.Arg(fsMetaLoadCommand.NArg() - 1)
 
-	fileName := args[len(args)-1]
+	var untilNs int64
+	if *until != "" {
+		untilTime, parseErr := time.Parse(time.RFC3339, *until)
+		if parseErr != nil {
+			return fmt.Errorf("parse -until=%s: %v", *until, parseErr)
+		}
+		untilNs = untilTime.UnixNano()
+		*withLogs = true
+	}
 
 	dst, err := os.OpenFile(fileName, os.O_RDONLY, 0644)
 	if err != nil {
-		return nil
+		return err
 	}
 	defer dst.Close()
 
@@ -49,26 +76,10 @@ func (c *commandFsMetaLoad) Do(args []string, commandEnv *CommandEnv, writer io.
 
 	err = commandEnv.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
 
-		sizeBuf := make([]byte, 4)
-
-		for {
-			if n, err := dst.Read(sizeBuf); n != 4 {
-				if err == io.EOF {
-					return nil
-				}
-				return err
-			}
-
-			size := util.BytesToUint32(sizeBuf)
-
-			data := make([]byte, int(size))
-
-			if n, err := dst.Read(data); n != len(data) {
-				return err
-			}
+		return readMetaRecords(dst, func(data []byte) error {
 
 			fullEntry := &filer_pb.FullEntry{}
-			if err = proto.Unmarshal(data, fullEntry); err != nil {
+			if err := proto.Unmarshal(data, fullEntry); err != nil {
 				return err
 			}
 
@@ -86,8 +97,8 @@ func (c *commandFsMetaLoad) Do(args []string, commandEnv *CommandEnv, writer io.
 			} else {
 				fileCount++
 			}
-
-		}
+			return nil
+		})
 
 	})
 
@@ -96,5 +107,116 @@ func (c *commandFsMetaLoad) Do(args []string, commandEnv *CommandEnv, writer io.
 		fmt.Fprintf(writer, "\n%s is loaded.\n", fileName)
 	}
 
+	if err == nil && *withLogs {
+		err = replayMetaChangeLog(commandEnv, writer, fileName, untilNs)
+	}
+
 	return err
 }
+
+// readMetaRecords reads the records saved by writeMetaRecord
+func readMetaRecords(src io.Reader, fn func(data []byte) error) error {
+	sizeBuf := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(src, sizeBuf); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		data := make([]byte, int(util.BytesToUint32(sizeBuf)))
+		if _, err := io.ReadFull(src, data); err != nil {
+			return err
+		}
+
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+}
+
+var errReachedUntilTime = errors.New("reached the until time")
+
+// replayMetaChangeLog replays the changes in the change log segments in order, until the untilNs if not 0.
+func replayMetaChangeLog(commandEnv *CommandEnv, writer io.Writer, snapshotFileName string, untilNs int64) error {
+
+	segmentNames, err := filepath.Glob(snapshotFileName + ".*.log")
+	if err != nil {
+		return err
+	}
+	sort.Strings(segmentNames)
+
+	var changeCount uint64
+	var lastTsNs int64
+
+	err = commandEnv.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+		for _, segmentName := range segmentNames {
+			segment, err := os.Open(segmentName)
+			if err != nil {
+				return err
+			}
+			err = readMetaRecords(segment, func(data []byte) error {
+				resp := &filer_pb.SubscribeMetadataResponse{}
+				if err := proto.Unmarshal(data, resp); err != nil {
+					return err
+				}
+				if untilNs > 0 && resp.TsNs > untilNs {
+					return errReachedUntilTime
+				}
+				if err := replayMetaChange(commandEnv, client, resp); err != nil {
+					return fmt.Errorf("replay change in %s at %v: %v", resp.Directory, time.Unix(0, resp.TsNs), err)
+				}
+				changeCount++
+				lastTsNs = resp.TsNs
+				return nil
+			})
+			segment.Close()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(writer, "replayed %s\n", segmentName)
+		}
+		return nil
+	})
+
+	if err == errReachedUntilTime {
+		err = nil
+	}
+	if err == nil {
+		fmt.Fprintf(writer, "replayed %d changes in %d change log segments", changeCount, len(segmentNames))
+		if lastTsNs > 0 {
+			fmt.Fprintf(writer, ", restored to %v", time.Unix(0, lastTsNs).UTC().Format(time.RFC3339Nano))
+		}
+		fmt.Fprintln(writer)
+	}
+	return err
+}
+
+// replayMetaChange applies one change, without deleting any file content.
+// The changes already in the snapshot can be replayed again.
+func replayMetaChange(commandEnv *CommandEnv, client filer_pb.SeaweedFilerClient, resp *filer_pb.SubscribeMetadataResponse) error {
+	message := resp.EventNotification
+
+	newParentPath := message.NewParentPath
+	if newParentPath == "" {
+		newParentPath = resp.Directory
+	}
+
+	if message.OldEntry != nil {
+		isRenamed := message.NewEntry != nil && (newParentPath != resp.Directory || message.NewEntry.Name != message.OldEntry.Name)
+		if message.NewEntry == nil || isRenamed {
+			if err := filer_pb.Remove(commandEnv, resp.Directory, message.OldEntry.Name, false, true, true, false, nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	if message.NewEntry != nil {
+		return filer_pb.CreateEntry(client, &filer_pb.CreateEntryRequest{
+			Directory: newParentPath,
+			Entry:     message.NewEntry,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package shell
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestMetaRecords(t *testing.T) {
+	var buf bytes.Buffer
+	sizeBuf := make([]byte, 4)
+	records := []string{"a", "", "bcd"}
+	for _, record := range records {
+		if err := writeMetaRecord(&buf, sizeBuf, []byte(record)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	var read []string
+	if err := readMetaRecords(&buf, func(data []byte) error {
+		read = append(read, string(data))
+		return nil
+	}); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(read) != len(records) || read[0] != "a" || read[1] != "" || read[2] != "bcd" {
+		t.Errorf("read %q", read)
+	}
+
+	if err := readMetaRecords(bytes.NewReader([]byte{0, 0, 0, 5, 'a'}), func(data []byte) error { return nil }); err == nil {
+		t.Errorf("read a truncated record")
+	}
+}
+
+func TestMetaChangeLogSegmentName(t *testing.T) {
+	start := time.Date(2021, 6, 1, 23, 59, 59, 999000000, time.UTC)
+	var expected []string
+	for _, d := range []time.Duration{0, time.Nanosecond, time.Millisecond, 2 * time.Hour} {
+		expected = append(expected, metaChangeLogSegmentName("t.meta", start.Add(d).UnixNano()))
+	}
+	names := []string{expected[2], expected[0], expected[3], expected[1]}
+	sort.Strings(names)
+	for i := range names {
+		if names[i] != expected[i] {
+			t.Errorf("sorted %v", names)
+			break
+		}
+	}
+	if expected[0] != "t.meta.20210601-235959.999000000.log" {
+		t.Errorf("segment name %s", expected[0])
+	}
+}
@@ -0,0 +1,96 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+)
+
+func init() {
+	Commands = append(Commands, &commandFsMetaMigrate{})
+}
+
+type commandFsMetaMigrate struct {
+}
+
+func (c *commandFsMetaMigrate) Name() string {
+	return "fs.meta.migrate"
+}
+
+func (c *commandFsMetaMigrate) Help() string {
+	return `copy the filer store to another filer store while the filer is serving, then switch to it
+
+	fs.meta.migrate -config=/path/to/target_filer.toml          # copy and catch up, without switching
+	fs.meta.migrate -config=/path/to/target_filer.toml -apply   # copy, catch up, and switch
+
+	The config file has the same format as filer.toml, with only the target store enabled, e.g. [postgres2].
+	The filer opens the target store, so the target store paths and addresses are as seen from the filer.
+
+	The filer tracks the changes while copying all the entries, and copies the changed entries again.
+	To switch, the writes pause for a moment while the last changes are copied.
+	Without -apply, or if this command is stopped before the switch, the filer keeps the current store.
+
+	To switch, the filer updates its filer.toml to the target store, keeping the old one as filer.toml.bak,
+	so the filer needs to be started with a filer.toml. The switch is refused while other filers use the same store.
+	The path specific stores are not migrated.
+
+`
+}
+
+func (c *commandFsMetaMigrate) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	migrateCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	configFile := migrateCommand.String("config", "", "the filer.toml file with only the target store enabled")
+	applySwitch := migrateCommand.Bool("apply", false, "switch to the target store after copying")
+	if err = migrateCommand.Parse(args); err != nil {
+		return nil
+	}
+	if *configFile == "" {
+		return fmt.Errorf("missing -config")
+	}
+
+	storeConfig, err := ioutil.ReadFile(*configFile)
+	if err != nil {
+		return fmt.Errorf("read %s: %v", *configFile, err)
+	}
+
+	return commandEnv.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+		stream, err := client.MigrateFilerStore(context.Background(), &filer_pb.MigrateFilerStoreRequest{
+			StoreConfig: string(storeConfig),
+			SwitchStore: *applySwitch,
+		})
+		if err != nil {
+			return err
+		}
+
+		var last *filer_pb.MigrateFilerStoreResponse
+		for {
+			resp, recvErr := stream.Recv()
+			if recvErr == io.EOF {
+				break
+			}
+			if recvErr != nil {
+				return recvErr
+			}
+			fmt.Fprintf(writer, "%s to %s: copied %d entries, %d keys, %d pending changes\n",
+				resp.Phase, resp.TargetStore, resp.CopiedEntries, resp.CopiedKeys, resp.PendingChanges)
+			last = resp
+		}
+
+		if last == nil || last.Phase != filer.StoreMigrationDone {
+			return fmt.Errorf("migration stopped before completion")
+		}
+		if *applySwitch {
+			fmt.Fprintf(writer, "switched to %s, please update filer.toml on the filer to use %s\n", last.TargetStore, last.TargetStore)
+		} else {
+			fmt.Fprintf(writer, "the filer keeps the current store, use -apply to switch to %s\n", last.TargetStore)
+		}
+		return nil
+	})
+
+}
@@ -1,6 +1,7 @@
 package shell
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"io"
@@ -34,10 +35,15 @@ func (c *commandFsMetaSave) Help() string {
 	fs.meta.save /path/to/save   # save from the directory /path/to/save
 	fs.meta.save .               # save from current directory
 	fs.meta.save                 # save from current directory
+	fs.meta.save -follow -o t.meta /   # save from the root, then keep saving the changes
 
 	The meta data will be saved into a local <filer_host>-<port>-<time>.meta file.
 	These meta data can be later loaded by fs.meta.load command, 
 
+	With -follow, the changes since the start of the snapshot are saved into the change log segments,
+	<file>.meta.<time>.log files next to the snapshot, one segment for each -segment duration, until this command is stopped.
+	With the snapshot and its change logs, fs.meta.load can restore the meta data to any time after the snapshot is saved.
+
 `
 }
 
@@ -46,6 +52,8 @@ func (c *commandFsMetaSave) Do(args []string, commandEnv *CommandEnv, writer io.
 	fsMetaSaveCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
 	verbose := fsMetaSaveCommand.Bool("v", false, "print out each processed files")
 	outputFileName := fsMetaSaveCommand.String("o", "", "output the meta data to this file")
+	isFollow := fsMetaSaveCommand.Bool("follow", false, "keep saving the meta data changes into the change log segments after the snapshot")
+	segmentDuration := fsMetaSaveCommand.Duration("segment", time.Hour, "the time span of each change log segment")
 	// chunksFileName := fsMetaSaveCommand.String("chunks", "", "output all the chunks to this file")
 	if err = fsMetaSaveCommand.Parse(args); err != nil {
 		return nil
@@ -63,19 +71,23 @@ func (c *commandFsMetaSave) Do(args []string, commandEnv *CommandEnv, writer io.
 			commandEnv.option.FilerHost, commandEnv.option.FilerPort, t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second())
 	}
 
+	if *isFollow && *segmentDuration < time.Second {
+		return fmt.Errorf("the change log segment %v is too short", *segmentDuration)
+	}
+
 	dst, openErr := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if openErr != nil {
 		return fmt.Errorf("failed to create file %s: %v", fileName, openErr)
 	}
 	defer dst.Close()
 
+	// the changes during the traversal are also in the change log
+	snapshotStartNs := time.Now().UnixNano()
+
 	err = doTraverseBfsAndSaving(commandEnv, writer, path, *verbose, func(outputChan chan interface{}) {
 		sizeBuf := make([]byte, 4)
 		for item := range outputChan {
-			b := item.([]byte)
-			util.Uint32toBytes(sizeBuf, uint32(len(b)))
-			dst.Write(sizeBuf)
-			dst.Write(b)
+			writeMetaRecord(dst, sizeBuf, item.([]byte))
 		}
 	}, func(entry *filer_pb.FullEntry, outputChan chan interface{}) (err error) {
 		bytes, err := proto.Marshal(entry)
@@ -92,10 +104,86 @@ func (c *commandFsMetaSave) Do(args []string, commandEnv *CommandEnv, writer io.
 		fmt.Fprintf(writer, "meta data for http://%s:%d%s is saved to %s\n", commandEnv.option.FilerHost, commandEnv.option.FilerPort, path, fileName)
 	}
 
+	if err == nil && *isFollow {
+		err = saveMetaChangeLog(commandEnv, writer, path, fileName, snapshotStartNs, *segmentDuration)
+	}
+
 	return err
 
 }
 
+func writeMetaRecord(dst io.Writer, sizeBuf []byte, b []byte) error {
+	util.Uint32toBytes(sizeBuf, uint32(len(b)))
+	if _, err := dst.Write(sizeBuf); err != nil {
+		return err
+	}
+	_, err := dst.Write(b)
+	return err
+}
+
+// metaChangeLogSegmentName sorts the segments by their first change
+func metaChangeLogSegmentName(snapshotFileName string, tsNs int64) string {
+	return fmt.Sprintf("%s.%s.log", snapshotFileName, time.Unix(0, tsNs).UTC().Format("20060102-150405.000000000"))
+}
+
+// saveMetaChangeLog saves the meta data change events since the snapshot started, until the subscription fails.
+func saveMetaChangeLog(commandEnv *CommandEnv, writer io.Writer, path, snapshotFileName string, sinceNs int64, segmentDuration time.Duration) error {
+
+	var segment *os.File
+	var segmentEndNs int64
+	defer func() {
+		if segment != nil {
+			segment.Close()
+		}
+	}()
+	sizeBuf := make([]byte, 4)
+
+	return commandEnv.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		stream, err := client.SubscribeMetadata(ctx, &filer_pb.SubscribeMetadataRequest{
+			ClientName: "fs.meta.save",
+			PathPrefix: path,
+			SinceNs:    sinceNs,
+		})
+		if err != nil {
+			return fmt.Errorf("subscribe meta data changes: %v", err)
+		}
+
+		for {
+			resp, listenErr := stream.Recv()
+			if listenErr == io.EOF {
+				return nil
+			}
+			if listenErr != nil {
+				return listenErr
+			}
+
+			if segment == nil || resp.TsNs >= segmentEndNs {
+				if segment != nil {
+					segment.Close()
+				}
+				segmentName := metaChangeLogSegmentName(snapshotFileName, resp.TsNs)
+				if segment, err = os.OpenFile(segmentName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644); err != nil {
+					return fmt.Errorf("failed to create file %s: %v", segmentName, err)
+				}
+				segmentEndNs = resp.TsNs + int64(segmentDuration)
+				fmt.Fprintf(writer, "saving meta data changes to %s\n", segmentName)
+			}
+
+			data, err := proto.Marshal(resp)
+			if err != nil {
+				return fmt.Errorf("marshal change %s: %v", resp.Directory, err)
+			}
+			if err = writeMetaRecord(segment, sizeBuf, data); err != nil {
+				return fmt.Errorf("save change to %s: %v", segment.Name(), err)
+			}
+		}
+	})
+}
+
 func doTraverseBfsAndSaving(filerClient filer_pb.FilerClient, writer io.Writer, path string, verbose bool, saveFn func(outputChan chan interface{}), genFn func(entry *filer_pb.FullEntry, outputChan chan interface{}) error) error {
 
 	var wg sync.WaitGroup
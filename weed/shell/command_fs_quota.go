@@ -0,0 +1,168 @@
+package shell
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/golang/protobuf/proto"
+)
+
+func init() {
+	Commands = append(Commands, &commandFsQuota{})
+}
+
+type commandFsQuota struct {
+}
+
+func (c *commandFsQuota) Name() string {
+	return "fs.quota"
+}
+
+func (c *commandFsQuota) Help() string {
+	return `set or show the quotas of the total file size and the number of entries under a directory
+
+	# show all the quotas and the usages
+	fs.quota
+
+	# show the quotas of the directory and its sub directories
+	fs.quota -dir=/data/
+
+	# allow at most 10GiB and 1 million entries under /data/projectA, recursively
+	fs.quota -dir=/data/projectA -bytes=10737418240 -entries=1000000 -apply
+
+	# remove the quotas
+	fs.quota -dir=/data/projectA -delete -apply
+
+	The quotas are saved in filer.conf, along with the options by fs.configure. 0 means no limit.
+	The filers count the usages in the background, and only enforce the quotas after counting.
+	The usages are counted again every hour, to pick up the changes via the other filers.
+
+	An exceeded quota fails the new entries and the larger files, with "507 Insufficient Storage" for http,
+	"QuotaExceeded" for s3, and EDQUOT for "weed mount".
+
+`
+}
+
+func (c *commandFsQuota) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	fsQuotaCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	dir := fsQuotaCommand.String("dir", "", "the directory")
+	quotaBytes := fsQuotaCommand.Uint64("bytes", 0, "the max total file size in bytes under the directory")
+	quotaEntries := fsQuotaCommand.Uint64("entries", 0, "the max number of files and directories under the directory")
+	isDelete := fsQuotaCommand.Bool("delete", false, "remove the quotas of the directory")
+	apply := fsQuotaCommand.Bool("apply", false, "update and apply filer configuration")
+	if err = fsQuotaCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	isSet := make(map[string]bool)
+	fsQuotaCommand.Visit(func(f *flag.Flag) {
+		isSet[f.Name] = true
+	})
+
+	if !isSet["bytes"] && !isSet["entries"] && !*isDelete {
+		return showDirectoryQuotas(commandEnv, *dir, writer)
+	}
+
+	if *dir == "" {
+		return fmt.Errorf("need to specify -dir")
+	}
+	locationPrefix := strings.TrimSuffix(*dir, "/") + "/"
+
+	var buf bytes.Buffer
+	if err = commandEnv.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+		return filer.ReadEntry(commandEnv.MasterClient, client, filer.DirectoryEtcSeaweedFS, filer.FilerConfName, &buf)
+	}); err != nil && err != filer_pb.ErrNotFound {
+		return err
+	}
+
+	fc := filer.NewFilerConf()
+	if buf.Len() > 0 {
+		if err = fc.LoadFromBytes(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	// keep the other options of the location
+	locConf := &filer_pb.FilerConf_PathConf{
+		LocationPrefix: locationPrefix,
+	}
+	if existing, found := fc.GetLocationConf(locationPrefix); found {
+		locConf = proto.Clone(existing).(*filer_pb.FilerConf_PathConf)
+	}
+	if *isDelete {
+		locConf.QuotaBytes, locConf.QuotaEntries = 0, 0
+	}
+	if isSet["bytes"] {
+		locConf.QuotaBytes = *quotaBytes
+	}
+	if isSet["entries"] {
+		locConf.QuotaEntries = *quotaEntries
+	}
+
+	if proto.Equal(locConf, &filer_pb.FilerConf_PathConf{LocationPrefix: locationPrefix}) {
+		fc.DeleteLocationConf(locationPrefix)
+	} else {
+		fc.AddLocationConf(locConf)
+	}
+
+	buf.Reset()
+	fc.ToText(&buf)
+
+	fmt.Fprintf(writer, string(buf.Bytes()))
+	fmt.Fprintln(writer)
+
+	if *apply {
+
+		if err := filer.SaveAs(commandEnv.option.FilerHost, int(commandEnv.option.FilerPort), filer.DirectoryEtcSeaweedFS, filer.FilerConfName, "text/plain; charset=utf-8", &buf); err != nil {
+			return err
+		}
+
+	}
+
+	return nil
+}
+
+func showDirectoryQuotas(commandEnv *CommandEnv, dir string, writer io.Writer) error {
+	return commandEnv.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+		resp, err := client.GetDirectoryQuotas(context.Background(), &filer_pb.GetDirectoryQuotasRequest{
+			Directory: dir,
+		})
+		if err != nil {
+			return err
+		}
+		if len(resp.Quotas) == 0 {
+			fmt.Fprintf(writer, "no quotas\n")
+			return nil
+		}
+		for _, quota := range resp.Quotas {
+			if !quota.IsCounted {
+				fmt.Fprintf(writer, "%s\tbytes: %s\tentries: %s\tcounting...\n", quota.Directory,
+					formatQuota(quota.QuotaBytes, util.BytesToHumanReadable), formatQuota(quota.QuotaEntries, formatCount))
+				continue
+			}
+			fmt.Fprintf(writer, "%s\tbytes: %s of %s\tentries: %d of %s\n", quota.Directory,
+				util.BytesToHumanReadable(uint64(quota.UsedBytes)), formatQuota(quota.QuotaBytes, util.BytesToHumanReadable),
+				quota.UsedEntries, formatQuota(quota.QuotaEntries, formatCount))
+		}
+		return nil
+	})
+}
+
+func formatQuota(quota uint64, format func(uint64) string) string {
+	if quota == 0 {
+		return "unlimited"
+	}
+	return format(quota)
+}
+
+func formatCount(n uint64) string {
+	return fmt.Sprintf("%d", n)
+}
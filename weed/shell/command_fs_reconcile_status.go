@@ -0,0 +1,79 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+)
+
+func init() {
+	Commands = append(Commands, &commandFsReconcileStatus{})
+}
+
+type commandFsReconcileStatus struct {
+}
+
+func (c *commandFsReconcileStatus) Name() string {
+	return "fs.reconcile.status"
+}
+
+func (c *commandFsReconcileStatus) Help() string {
+	return `show the background reconciling progress, and the files with missing or broken chunks
+
+	fs.reconcile.status [-limit=100]
+
+	The reconciler is enabled by the "reconcile_files_per_second" option in filer.toml.
+	Depending on the "reconcile_action" option, the broken files are only reported, or also marked with
+	the "x-seaweedfs-quarantine" extended attribute, or truncated to the chunks before the first broken chunk.
+`
+}
+
+func (c *commandFsReconcileStatus) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	reconcileCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	limit := reconcileCommand.Int("limit", 100, "show at most these latest quarantined files")
+	if err = reconcileCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	return commandEnv.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+		resp, err := client.ReconcileStatus(context.Background(), &filer_pb.ReconcileStatusRequest{})
+		if err != nil {
+			return err
+		}
+		if !resp.IsEnabled {
+			fmt.Fprintf(writer, "reconciling is not enabled\n")
+			return nil
+		}
+
+		status := resp.Status
+		progress := "not started"
+		if status.StartedAtNs > 0 {
+			progress = fmt.Sprintf("round %d started at %s, in %s", status.RoundCount, time.Unix(0, status.StartedAtNs).Format(time.RFC3339), status.CurrentDirectory)
+		}
+		if status.CurrentDirectory == "" && status.CompletedAtNs > 0 {
+			progress = fmt.Sprintf("round %d completed at %s", status.RoundCount, time.Unix(0, status.CompletedAtNs).Format(time.RFC3339))
+		}
+		fmt.Fprintf(writer, "action %s, %d files per second, %s\n", status.Action, status.FilesPerSecond, progress)
+		fmt.Fprintf(writer, "scanned %d files %d chunks, broken %d files, %d errors\n", status.ScannedFileCount, status.ScannedChunkCount, status.BrokenFileCount, status.ErrorCount)
+		if status.LastError != "" {
+			fmt.Fprintf(writer, "last error: %s\n", status.LastError)
+		}
+
+		files := status.QuarantinedFiles
+		if len(files) > *limit {
+			files = files[len(files)-*limit:]
+		}
+		for _, file := range files {
+			fmt.Fprintf(writer, "  %s %s chunk %s at offset %d: %s, %s\n",
+				time.Unix(0, file.DetectedAtNs).Format(time.RFC3339), file.Path, file.FileId, file.Offset, file.Error, file.Action)
+		}
+		fmt.Fprintf(writer, "total quarantined %d files\n", len(status.QuarantinedFiles))
+		return nil
+	})
+
+}
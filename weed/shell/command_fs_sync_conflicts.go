@@ -0,0 +1,94 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/replication/sink/filersink"
+)
+
+func init() {
+	Commands = append(Commands, &commandFsSyncConflicts{})
+}
+
+type commandFsSyncConflicts struct {
+}
+
+func (c *commandFsSyncConflicts) Name() string {
+	return "fs.sync.conflicts"
+}
+
+func (c *commandFsSyncConflicts) Help() string {
+	return `show the conflicting changes found by filer.sync when writing to this filer
+
+	fs.sync.conflicts [-limit=100] [-path=/prefix]
+	fs.sync.conflicts -clear
+
+	A conflict is a file changed on both filers of an active-active filer.sync before being synced,
+	resolved by the "-conflictPolicy" of filer.sync. The latest 1000 conflicts are kept.
+`
+}
+
+func (c *commandFsSyncConflicts) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	syncConflictsCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	limit := syncConflictsCommand.Int("limit", 100, "show at most these latest conflicts")
+	pathPrefix := syncConflictsCommand.String("path", "", "only show the conflicts under this path prefix")
+	isClear := syncConflictsCommand.Bool("clear", false, "remove all the recorded conflicts")
+	if err = syncConflictsCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	return commandEnv.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+
+		if *isClear {
+			resp, err := client.KvPut(context.Background(), &filer_pb.KvPutRequest{Key: []byte(filersink.SyncConflictsKey)})
+			if err != nil {
+				return err
+			}
+			if resp.Error != "" {
+				return errors.New(resp.Error)
+			}
+			return nil
+		}
+
+		resp, err := client.KvGet(context.Background(), &filer_pb.KvGetRequest{Key: []byte(filersink.SyncConflictsKey)})
+		if err != nil {
+			return err
+		}
+		if resp.Error != "" {
+			return errors.New(resp.Error)
+		}
+		conflicts := &filer_pb.SyncConflicts{}
+		if err = proto.Unmarshal(resp.Value, conflicts); err != nil {
+			return fmt.Errorf("decode sync conflicts: %v", err)
+		}
+
+		var matched []*filer_pb.SyncConflicts_Conflict
+		for _, conflict := range conflicts.Conflicts {
+			if strings.HasPrefix(conflict.Path, *pathPrefix) {
+				matched = append(matched, conflict)
+			}
+		}
+		if len(matched) > *limit {
+			matched = matched[len(matched)-*limit:]
+		}
+		for _, conflict := range matched {
+			fmt.Fprintf(writer, "%s %s %s from %s, source mtime %s target mtime %s, %s: %s\n",
+				time.Unix(0, conflict.DetectedAtNs).Format(time.RFC3339), conflict.Path, conflict.Reason, conflict.SourceFiler,
+				time.Unix(conflict.SourceMtime, 0).Format(time.RFC3339), time.Unix(conflict.TargetMtime, 0).Format(time.RFC3339),
+				conflict.Policy, conflict.Resolution)
+		}
+		fmt.Fprintf(writer, "total %d conflicts\n", len(conflicts.Conflicts))
+		return nil
+	})
+
+}
@@ -0,0 +1,108 @@
+package shell
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func init() {
+	Commands = append(Commands, &commandFsVerify{})
+}
+
+type commandFsVerify struct {
+}
+
+func (c *commandFsVerify) Name() string {
+	return "fs.verify"
+}
+
+func (c *commandFsVerify) Help() string {
+	return `recursively verify the file content against the stored whole file checksums
+
+	fs.verify /buckets/bucket1
+	fs.verify -v /buckets/bucket1/some/file
+
+	The content is read from the volume servers, and compared with the sha256 checksum and the md5
+	kept in the file attributes. Files without any checksum yet are reported separately.
+	The server side encrypted files are skipped, and can be verified by the filer with the key.
+	The checksums are also returned by HEAD requests in the Seaweed-Checksum-Sha256 and Content-MD5 headers,
+	by mount as the "user.seaweedfs.sha256" extended attribute, and a single file is verified by the filer
+	with "GET /path/to/file?verify".
+
+`
+}
+
+func (c *commandFsVerify) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	fsVerifyCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	verbose := fsVerifyCommand.Bool("v", false, "print each verified file")
+	if err = fsVerifyCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	path, err := commandEnv.parseUrl(findInputDirectory(fsVerifyCommand.Args()))
+	if err != nil {
+		return err
+	}
+
+	var verifiedCount, missingCount, mismatchCount, encryptedCount uint64
+
+	verifyFn := func(parentPath util.FullPath, entry *filer_pb.Entry) {
+		if entry.IsDirectory || entry.Attributes == nil {
+			return
+		}
+		fullpath := parentPath.Child(entry.Name)
+		if filer.IsSseEncrypted(entry.Extended) {
+			// the chunk cipher keys are encrypted, and only decrypted by the filer
+			atomic.AddUint64(&encryptedCount, 1)
+			if *verbose {
+				fmt.Fprintf(writer, "server side encrypted %s\n", fullpath)
+			}
+			return
+		}
+		if len(entry.Attributes.Sha256) == 0 && len(entry.Attributes.Md5) == 0 {
+			atomic.AddUint64(&missingCount, 1)
+			if *verbose {
+				fmt.Fprintf(writer, "no checksum %s\n", fullpath)
+			}
+			return
+		}
+
+		verification, verifyErr := filer.VerifyChecksums(commandEnv.MasterClient, filer.FromPbEntry(string(parentPath), entry))
+		if verifyErr != nil {
+			atomic.AddUint64(&mismatchCount, 1)
+			fmt.Fprintf(writer, "fail to read %s: %v\n", fullpath, verifyErr)
+			return
+		}
+		if !verification.IsVerified {
+			atomic.AddUint64(&mismatchCount, 1)
+			fmt.Fprintf(writer, "mismatch %s: expected %s %s md5 %s, but got %s md5 %s\n", fullpath,
+				filer.ChecksumAlgorithm, verification.StoredSha256, verification.StoredMd5,
+				verification.ComputedSha256, verification.ComputedMd5)
+			return
+		}
+		atomic.AddUint64(&verifiedCount, 1)
+		if *verbose {
+			fmt.Fprintf(writer, "verified %s %s %s md5 %s\n", fullpath, filer.ChecksumAlgorithm, verification.ComputedSha256, verification.ComputedMd5)
+		}
+	}
+
+	if entry, lookupErr := filer_pb.GetEntry(commandEnv, util.FullPath(path)); lookupErr == nil && entry != nil && !entry.IsDirectory {
+		dir, _ := util.FullPath(path).DirAndName()
+		verifyFn(util.FullPath(dir), entry)
+	} else if err = filer_pb.TraverseBfs(commandEnv, util.FullPath(path), verifyFn); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(writer, "\ntotal %d verified, %d failed, %d without checksum, %d server side encrypted\n", verifiedCount, mismatchCount, missingCount, encryptedCount)
+	if mismatchCount > 0 {
+		return fmt.Errorf("%d files failed the verification", mismatchCount)
+	}
+	return nil
+}
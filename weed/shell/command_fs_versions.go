@@ -0,0 +1,105 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func init() {
+	Commands = append(Commands, &commandFsVersions{})
+}
+
+type commandFsVersions struct {
+}
+
+func (c *commandFsVersions) Name() string {
+	return "fs.versions"
+}
+
+func (c *commandFsVersions) Help() string {
+	return `list or restore the prior versions of a file in a versioned directory
+
+	# list the versions, the latest first
+	fs.versions -path=/docs/report.txt
+
+	# write a version back to the file, keeping the current content as a new version
+	fs.versions -path=/docs/report.txt -restore=<versionId>
+
+	The versioning is configured by fs.configure, e.g.
+	fs.configure -locationPrefix=/docs/ -versioning -maxVersions=10 -versionRetentionDays=30 -apply
+
+	A version can also be read via http, with "?version=<versionId>" for the file url.
+
+`
+}
+
+func (c *commandFsVersions) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	fsVersionsCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	path := fsVersionsCommand.String("path", "", "the file path")
+	restore := fsVersionsCommand.String("restore", "", "the version id to restore")
+	if err = fsVersionsCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	if *path == "" {
+		return fmt.Errorf("need to specify -path")
+	}
+	p, err := commandEnv.parseUrl(*path)
+	if err != nil {
+		return err
+	}
+	dir, name := util.FullPath(p).DirAndName()
+
+	return commandEnv.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+
+		if *restore != "" {
+			resp, err := client.RestoreEntryVersion(context.Background(), &filer_pb.RestoreEntryVersionRequest{
+				Directory: dir,
+				Name:      name,
+				VersionId: *restore,
+			})
+			if err != nil {
+				return err
+			}
+			if resp.Error != "" {
+				return fmt.Errorf("restore version %s: %s", *restore, resp.Error)
+			}
+			fmt.Fprintf(writer, "restored %s to version %s\n", p, *restore)
+			return nil
+		}
+
+		resp, err := client.ListEntryVersions(context.Background(), &filer_pb.ListEntryVersionsRequest{
+			Directory: dir,
+			Name:      name,
+		})
+		if err != nil {
+			return err
+		}
+		if resp.Error != "" {
+			return fmt.Errorf("list versions: %s", resp.Error)
+		}
+		if len(resp.Versions) == 0 {
+			fmt.Fprintf(writer, "no versions\n")
+			return nil
+		}
+		for _, version := range resp.Versions {
+			state := ""
+			if version.IsDeleted {
+				state = "\tdeleted after"
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%s%s\n", version.VersionId,
+				time.Unix(0, version.SupersededAtNs).Format(time.RFC3339),
+				util.BytesToHumanReadable(filer.FileSize(version.Entry)), state)
+		}
+		return nil
+	})
+
+}
@@ -0,0 +1,123 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func init() {
+	Commands = append(Commands, &commandRemoteCache{})
+}
+
+type commandRemoteCache struct {
+}
+
+func (c *commandRemoteCache) Name() string {
+	return "remote.cache"
+}
+
+func (c *commandRemoteCache) Help() string {
+	return `cache the content of remote files in a mounted directory
+
+	remote.cache -dir=/buckets/remote1
+	remote.cache -dir=/buckets/remote1/some/dir -include=*.pdf
+
+	The directories are listed from the remote storage if needed, so the metadata of the whole
+	directory tree is also cached. Files already cached are skipped.
+
+`
+}
+
+func (c *commandRemoteCache) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	remoteCacheCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	dir := remoteCacheCommand.String("dir", "", "a directory in a mounted remote storage")
+	include := remoteCacheCommand.String("include", "", "only cache files with names matching the wildcard pattern, e.g. *.pdf")
+	if err = remoteCacheCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	return visitRemoteEntries(commandEnv, writer, *dir, *include, "cache", func(parentPath util.FullPath, entry *filer_pb.Entry) (bool, error) {
+		if isCached(entry) {
+			return false, nil
+		}
+		return true, commandEnv.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+			resp, err := client.CacheRemoteObjectToLocalCluster(context.Background(), &filer_pb.CacheRemoteObjectToLocalClusterRequest{
+				Directory: string(parentPath),
+				Name:      entry.Name,
+			})
+			if err != nil {
+				return err
+			}
+			if resp.Error != "" {
+				return fmt.Errorf("%s", resp.Error)
+			}
+			return nil
+		})
+	})
+}
+
+func isCached(entry *filer_pb.Entry) bool {
+	return len(entry.Chunks) > 0 || len(entry.Content) > 0 || filer.FileSize(entry) == 0
+}
+
+// visitRemoteEntries calls fn for the remote files under the mounted directory with names matching the pattern.
+// fn returns false if the file is skipped.
+func visitRemoteEntries(commandEnv *CommandEnv, writer io.Writer, dir, include, action string, fn func(parentPath util.FullPath, entry *filer_pb.Entry) (bool, error)) error {
+
+	mapping, err := readRemoteStorageMapping(commandEnv)
+	if err != nil {
+		return err
+	}
+	path, err := commandEnv.parseUrl(dir)
+	if err != nil {
+		return err
+	}
+	path = strings.TrimSuffix(path, "/")
+	isMounted := false
+	for _, mount := range mapping.Mounts {
+		if path == mount.Dir || strings.HasPrefix(path, mount.Dir+"/") {
+			isMounted = true
+		}
+	}
+	if !isMounted {
+		return fmt.Errorf("%s is not in a mounted remote storage", path)
+	}
+
+	var fileCount, failedCount uint64
+	err = filer_pb.TraverseBfs(commandEnv, util.FullPath(path), func(parentPath util.FullPath, entry *filer_pb.Entry) {
+		if entry.IsDirectory || len(entry.Extended[filer.ExtRemoteKey]) == 0 {
+			return
+		}
+		if include != "" {
+			if matched, _ := filepath.Match(include, entry.Name); !matched {
+				return
+			}
+		}
+		isVisited, visitErr := fn(parentPath, entry)
+		if visitErr != nil {
+			atomic.AddUint64(&failedCount, 1)
+			fmt.Fprintf(writer, "fail to %s %s: %v\n", action, parentPath.Child(entry.Name), visitErr)
+			return
+		}
+		if isVisited {
+			atomic.AddUint64(&fileCount, 1)
+			fmt.Fprintf(writer, "%s %s\n", action, parentPath.Child(entry.Name))
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(writer, "\ntotal %d files to %s, %d failed\n", fileCount+failedCount, action, failedCount)
+	return nil
+}
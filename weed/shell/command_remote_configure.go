@@ -0,0 +1,130 @@
+package shell
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func init() {
+	Commands = append(Commands, &commandRemoteConfigure{})
+}
+
+type commandRemoteConfigure struct {
+}
+
+func (c *commandRemoteConfigure) Name() string {
+	return "remote.configure"
+}
+
+func (c *commandRemoteConfigure) Help() string {
+	return `remote storage configuration
+
+	# see the current configurations
+	remote.configure
+
+	# set or update a configuration
+	remote.configure -name=s3_1 -type=s3 -s3.access_key=xxx -s3.secret_key=yyy -s3.region=us-east-2
+	remote.configure -name=gcs_1 -type=gcs -gcs.appCredentialsFile=~/service-account-file.json
+	remote.configure -name=azure_1 -type=azure -azure.account_name=xxx -azure.account_key=yyy
+
+	# delete a configuration
+	remote.configure -name=s3_1 -delete
+
+	The configurations are saved in the filer at ` + filer.DirectoryEtcRemote + `/<name>` + filer.RemoteConfExtension + `,
+	and used by "remote.mount" to mount the remote storage buckets.
+
+`
+}
+
+func (c *commandRemoteConfigure) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	conf := &filer_pb.RemoteConf{}
+	remoteConfigureCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	isDelete := remoteConfigureCommand.Bool("delete", false, "delete one remote storage by its name")
+	remoteConfigureCommand.StringVar(&conf.Name, "name", "", "a short name to identify the remote storage")
+	remoteConfigureCommand.StringVar(&conf.Type, "type", "s3", "storage type, one of s3, gcs, azure")
+	remoteConfigureCommand.StringVar(&conf.S3AccessKey, "s3.access_key", "", "s3 access key")
+	remoteConfigureCommand.StringVar(&conf.S3SecretKey, "s3.secret_key", "", "s3 secret key")
+	remoteConfigureCommand.StringVar(&conf.S3Region, "s3.region", "us-east-2", "s3 region")
+	remoteConfigureCommand.StringVar(&conf.S3Endpoint, "s3.endpoint", "", "endpoint for s3-compatible local object store")
+	remoteConfigureCommand.StringVar(&conf.GcsGoogleApplicationCredentials, "gcs.appCredentialsFile", "", "google cloud storage credentials file on the filer, default to use env GOOGLE_APPLICATION_CREDENTIALS")
+	remoteConfigureCommand.StringVar(&conf.AzureAccountName, "azure.account_name", "", "azure account name")
+	remoteConfigureCommand.StringVar(&conf.AzureAccountKey, "azure.account_key", "", "azure account key")
+	if err = remoteConfigureCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	if conf.Name == "" {
+		return c.listExistingConfigurations(commandEnv, writer)
+	}
+
+	if *isDelete {
+		return filer_pb.Remove(commandEnv, filer.DirectoryEtcRemote, conf.Name+filer.RemoteConfExtension, true, false, false, false, nil)
+	}
+
+	if conf.Type != "s3" && conf.Type != "gcs" && conf.Type != "azure" {
+		return fmt.Errorf("unknown remote storage type %s", conf.Type)
+	}
+	if strings.ContainsAny(conf.Name, "/.") {
+		return fmt.Errorf("remote storage name %s should not contain '/' or '.'", conf.Name)
+	}
+
+	var buf bytes.Buffer
+	if err = filer.RemoteStorageToText(&buf, conf); err != nil {
+		return err
+	}
+
+	return commandEnv.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+		return filer.SaveInsideFiler(client, filer.DirectoryEtcRemote, conf.Name+filer.RemoteConfExtension, buf.Bytes())
+	})
+}
+
+func (c *commandRemoteConfigure) listExistingConfigurations(commandEnv *CommandEnv, writer io.Writer) error {
+
+	return filer_pb.ReadDirAllEntries(commandEnv, util.FullPath(filer.DirectoryEtcRemote), "", func(entry *filer_pb.Entry, isLast bool) error {
+		if !strings.HasSuffix(entry.Name, filer.RemoteConfExtension) {
+			return nil
+		}
+		conf, err := readRemoteConf(commandEnv, strings.TrimSuffix(entry.Name, filer.RemoteConfExtension))
+		if err != nil {
+			return err
+		}
+		// do not show the secrets
+		secretHidden := proto.Clone(conf).(*filer_pb.RemoteConf)
+		if secretHidden.S3SecretKey != "" {
+			secretHidden.S3SecretKey = "***"
+		}
+		if secretHidden.AzureAccountKey != "" {
+			secretHidden.AzureAccountKey = "***"
+		}
+		if err = filer.RemoteStorageToText(writer, secretHidden); err != nil {
+			return err
+		}
+		fmt.Fprintln(writer)
+		return nil
+	})
+}
+
+func readRemoteConf(commandEnv *CommandEnv, name string) (*filer_pb.RemoteConf, error) {
+	var buf bytes.Buffer
+	if err := commandEnv.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+		return filer.ReadEntry(commandEnv.MasterClient, client, filer.DirectoryEtcRemote, name+filer.RemoteConfExtension, &buf)
+	}); err != nil {
+		return nil, fmt.Errorf("read remote storage %s: %v", name, err)
+	}
+	conf := &filer_pb.RemoteConf{}
+	if err := jsonpb.Unmarshal(&buf, conf); err != nil {
+		return nil, fmt.Errorf("parse remote storage %s: %v", name, err)
+	}
+	return conf, nil
+}
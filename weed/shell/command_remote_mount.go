@@ -0,0 +1,155 @@
+package shell
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/remote_storage"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func init() {
+	Commands = append(Commands, &commandRemoteMount{})
+}
+
+type commandRemoteMount struct {
+}
+
+func (c *commandRemoteMount) Name() string {
+	return "remote.mount"
+}
+
+func (c *commandRemoteMount) Help() string {
+	return `mount a remote storage bucket, or a directory in it, to a filer directory
+
+	# see the current mounts
+	remote.mount
+
+	# mount a whole bucket, or a directory in a bucket
+	remote.mount -dir=/buckets/remote1 -remote=s3_1/bucket1
+	remote.mount -dir=/buckets/remote2 -remote=s3_1/bucket2/some/dir
+
+	# keep at most 10GB of cached content for one week
+	remote.mount -dir=/buckets/remote1 -remote=s3_1/bucket1 -cacheMaxAge=168h -cacheCapacityMB=10240
+
+	The remote storage is configured by "remote.configure". The mounted directory should be new or empty.
+
+	The remote metadata is listed lazily when the directories are looked up or listed, and listed again
+	after the metadata ttl. The file content is cached locally when first read through the filer,
+	or with "remote.cache", and is dropped after the cache max age or when exceeding the cache capacity.
+
+	Files written locally into the mounted directory stay local, and are not uploaded to the remote storage.
+
+`
+}
+
+func (c *commandRemoteMount) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	remoteMountCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	dir := remoteMountCommand.String("dir", "", "a new or empty directory on filer")
+	remote := remoteMountCommand.String("remote", "", "a directory in remote storage, ex. <storage name>/<bucket>/path/to/dir")
+	metadataTtl := remoteMountCommand.Duration("metadataTtl", filer.DefaultRemoteMetadataTtlSec*1e9, "list a directory again from the remote storage after this duration, -1s to never list again")
+	cacheMaxAge := remoteMountCommand.Duration("cacheMaxAge", 0, "drop the cached content not read for this duration, 0 to keep it")
+	cacheCapacityMB := remoteMountCommand.Int64("cacheCapacityMB", 0, "drop the least recently read content when caching more than this size, 0 for unlimited")
+	if err = remoteMountCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	mapping, err := readRemoteStorageMapping(commandEnv)
+	if err != nil {
+		return err
+	}
+
+	if *dir == "" {
+		return filer.RemoteStorageToText(writer, mapping)
+	}
+
+	mountDir, err := commandEnv.parseUrl(*dir)
+	if err != nil {
+		return err
+	}
+	mountDir = strings.TrimSuffix(mountDir, "/")
+	loc, err := remote_storage.ParseLocation(*remote)
+	if err != nil {
+		return err
+	}
+	if _, err = readRemoteConf(commandEnv, loc.Name); err != nil {
+		return err
+	}
+
+	for _, mount := range mapping.Mounts {
+		if mount.Dir == mountDir || strings.HasPrefix(mountDir, mount.Dir+"/") || strings.HasPrefix(mount.Dir, mountDir+"/") {
+			return fmt.Errorf("%s overlaps with the mounted %s", mountDir, mount.Dir)
+		}
+	}
+
+	if err = ensureEmptyDirectory(commandEnv, util.FullPath(mountDir)); err != nil {
+		return err
+	}
+
+	mapping.Mounts = append(mapping.Mounts, &filer_pb.RemoteStorageMapping_Mount{
+		Dir:             mountDir,
+		Location:        loc,
+		MetadataTtlSec:  int32(metadataTtl.Seconds()),
+		CacheMaxAgeSec:  int32(cacheMaxAge.Seconds()),
+		CacheCapacityMb: *cacheCapacityMB,
+	})
+	if err = saveRemoteStorageMapping(commandEnv, mapping); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(writer, "mounted %s to %s\n", remote_storage.FormatLocation(loc), mountDir)
+	return nil
+}
+
+func ensureEmptyDirectory(commandEnv *CommandEnv, dir util.FullPath) error {
+	parent, name := dir.DirAndName()
+	exists, err := filer_pb.Exists(commandEnv, parent, name, true)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return filer_pb.Mkdir(commandEnv, parent, name, nil)
+	}
+	isEmpty := true
+	err = filer_pb.List(commandEnv, string(dir), "", func(entry *filer_pb.Entry, isLast bool) error {
+		isEmpty = false
+		return nil
+	}, "", false, 1)
+	if err != nil {
+		return err
+	}
+	if !isEmpty {
+		return fmt.Errorf("directory %s is not empty", dir)
+	}
+	return nil
+}
+
+func readRemoteStorageMapping(commandEnv *CommandEnv) (*filer_pb.RemoteStorageMapping, error) {
+	var buf bytes.Buffer
+	if err := commandEnv.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+		return filer.ReadEntry(commandEnv.MasterClient, client, filer.DirectoryEtcRemote, filer.RemoteMountMappingFile, &buf)
+	}); err != nil && err != filer_pb.ErrNotFound {
+		return nil, fmt.Errorf("read remote storage mapping: %v", err)
+	}
+	mapping, err := filer.ParseRemoteStorageMapping(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("parse remote storage mapping: %v", err)
+	}
+	return mapping, nil
+}
+
+func saveRemoteStorageMapping(commandEnv *CommandEnv, mapping *filer_pb.RemoteStorageMapping) error {
+	var buf bytes.Buffer
+	if err := filer.RemoteStorageToText(&buf, mapping); err != nil {
+		return err
+	}
+	return commandEnv.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+		return filer.SaveInsideFiler(client, filer.DirectoryEtcRemote, filer.RemoteMountMappingFile, buf.Bytes())
+	})
+}
@@ -0,0 +1,62 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func init() {
+	Commands = append(Commands, &commandRemoteUncache{})
+}
+
+type commandRemoteUncache struct {
+}
+
+func (c *commandRemoteUncache) Name() string {
+	return "remote.uncache"
+}
+
+func (c *commandRemoteUncache) Help() string {
+	return `drop the cached content of remote files in a mounted directory
+
+	remote.uncache -dir=/buckets/remote1
+	remote.uncache -dir=/buckets/remote1/some/dir -include=*.pdf
+
+	The file metadata is kept, and the content is fetched again from the remote storage when read.
+
+`
+}
+
+func (c *commandRemoteUncache) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	remoteUncacheCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	dir := remoteUncacheCommand.String("dir", "", "a directory in a mounted remote storage")
+	include := remoteUncacheCommand.String("include", "", "only uncache files with names matching the wildcard pattern, e.g. *.pdf")
+	if err = remoteUncacheCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	return visitRemoteEntries(commandEnv, writer, *dir, *include, "uncache", func(parentPath util.FullPath, entry *filer_pb.Entry) (bool, error) {
+		if len(entry.Chunks) == 0 && len(entry.Content) == 0 {
+			return false, nil
+		}
+		return true, commandEnv.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+			resp, err := client.UncacheRemoteObject(context.Background(), &filer_pb.UncacheRemoteObjectRequest{
+				Directory: string(parentPath),
+				Name:      entry.Name,
+			})
+			if err != nil {
+				return err
+			}
+			if resp.Error != "" {
+				return fmt.Errorf("%s", resp.Error)
+			}
+			return nil
+		})
+	})
+}
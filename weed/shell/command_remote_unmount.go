@@ -0,0 +1,93 @@
+package shell
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/remote_storage"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func init() {
+	Commands = append(Commands, &commandRemoteUnmount{})
+}
+
+type commandRemoteUnmount struct {
+}
+
+func (c *commandRemoteUnmount) Name() string {
+	return "remote.unmount"
+}
+
+func (c *commandRemoteUnmount) Help() string {
+	return `unmount a remote storage from a filer directory
+
+	remote.unmount -dir=/buckets/remote1
+
+	The entries of the remote objects are removed from the directory, together with their cached content.
+	The remote storage is not changed. Files written locally into the directory are kept.
+
+`
+}
+
+func (c *commandRemoteUnmount) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	remoteUnmountCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	dir := remoteUnmountCommand.String("dir", "", "a mounted directory on filer")
+	if err = remoteUnmountCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	mapping, err := readRemoteStorageMapping(commandEnv)
+	if err != nil {
+		return err
+	}
+
+	mountDir, err := commandEnv.parseUrl(*dir)
+	if err != nil {
+		return err
+	}
+	mountDir = strings.TrimSuffix(mountDir, "/")
+
+	var unmounted *filer_pb.RemoteStorageMapping_Mount
+	var mounts []*filer_pb.RemoteStorageMapping_Mount
+	for _, mount := range mapping.Mounts {
+		if mount.Dir == mountDir {
+			unmounted = mount
+			continue
+		}
+		mounts = append(mounts, mount)
+	}
+	if unmounted == nil {
+		return fmt.Errorf("directory %s is not mounted", mountDir)
+	}
+
+	// stop listing the remote storage before removing the remote entries
+	mapping.Mounts = mounts
+	if err = saveRemoteStorageMapping(commandEnv, mapping); err != nil {
+		return err
+	}
+
+	var removedCount uint64
+	err = filer_pb.TraverseBfs(commandEnv, util.FullPath(mountDir), func(parentPath util.FullPath, entry *filer_pb.Entry) {
+		if entry.IsDirectory || len(entry.Extended[filer.ExtRemoteKey]) == 0 {
+			return
+		}
+		if removeErr := filer_pb.Remove(commandEnv, string(parentPath), entry.Name, true, false, false, false, nil); removeErr != nil {
+			fmt.Fprintf(writer, "fail to remove %s: %v\n", parentPath.Child(entry.Name), removeErr)
+			return
+		}
+		atomic.AddUint64(&removedCount, 1)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(writer, "unmounted %s from %s, removed %d remote entries\n", remote_storage.FormatLocation(unmounted.Location), mountDir, removedCount)
+	return nil
+}
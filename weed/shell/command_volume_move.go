@@ -43,6 +43,17 @@ func (c *commandVolumeMove) Help() string {
 
 	The option "-disk [hdd|ssd|<tag>]" can be used to change the volume disk type.
 
+	With "-live", the volume is moved without read errors:
+
+	1. The target volume server copies the source volume, while the source volume is still writable.
+	2. The copy is mounted as read only, and not reported to the master, so the master does not assign the writes
+		to it nor return it for the reads. The reads of the needles not copied yet are redirected to the source volume server.
+	3. The target volume server tails the source volume for the in-flight writes, until idle.
+	4. The source volume is marked as readonly, and the last writes are tailed.
+	5. The copy is marked as writable and reported to the master, and then the source volume is deleted.
+		Now the master only returns the target volume server for this volume id.
+		The reads still sent to the source volume server are redirected to the target volume server.
+
 `
 }
 
@@ -57,6 +68,7 @@ func (c *commandVolumeMove) Do(args []string, commandEnv *CommandEnv, writer io.
 	sourceNodeStr := volMoveCommand.String("source", "", "the source volume server <host>:<port>")
 	targetNodeStr := volMoveCommand.String("target", "", "the target volume server <host>:<port>")
 	diskTypeStr := volMoveCommand.String("disk", "", "[hdd|ssd|<tag>] hard drive or solid state drive or any tag")
+	isLive := volMoveCommand.Bool("live", false, "keep the volume readable all the time, redirecting the reads between the source and the target")
 	if err = volMoveCommand.Parse(args); err != nil {
 		return nil
 	}
@@ -69,9 +81,97 @@ func (c *commandVolumeMove) Do(args []string, commandEnv *CommandEnv, writer io.
 		return fmt.Errorf("source and target volume servers are the same!")
 	}
 
+	if *isLive {
+		return moveVolumeWithoutReadErrors(commandEnv, volumeId, sourceVolumeServer, targetVolumeServer, 5*time.Second, *diskTypeStr)
+	}
+
 	return LiveMoveVolume(commandEnv.option.GrpcDialOption, volumeId, sourceVolumeServer, targetVolumeServer, 5*time.Second, *diskTypeStr)
 }
 
+// moveVolumeWithoutReadErrors keeps at least one readable location with all the needles in the master during the move.
+func moveVolumeWithoutReadErrors(commandEnv *CommandEnv, volumeId needle.VolumeId, sourceVolumeServer, targetVolumeServer string, idleTimeout time.Duration, diskType string) (err error) {
+
+	grpcDialOption := commandEnv.option.GrpcDialOption
+
+	log.Printf("copying volume %d from %s to %s live", volumeId, sourceVolumeServer, targetVolumeServer)
+	var lastAppendAtNs uint64
+	err = operation.WithVolumeServerClient(targetVolumeServer, grpcDialOption, func(volumeServerClient volume_server_pb.VolumeServerClient) error {
+		resp, copyErr := volumeServerClient.VolumeCopy(context.Background(), &volume_server_pb.VolumeCopyRequest{
+			VolumeId:       uint32(volumeId),
+			SourceDataNode: sourceVolumeServer,
+			DiskType:       diskType,
+			Live:           true,
+		})
+		if copyErr == nil {
+			lastAppendAtNs = resp.LastAppendAtNs
+		}
+		return copyErr
+	})
+	if err != nil {
+		return fmt.Errorf("copy volume %d from %s to %s: %v", volumeId, sourceVolumeServer, targetVolumeServer, err)
+	}
+
+	sourceMarkedReadonly := false
+	defer func() {
+		if err == nil {
+			return
+		}
+		if sourceMarkedReadonly {
+			if writableErr := markVolumeWritable(grpcDialOption, volumeId, sourceVolumeServer, true); writableErr != nil {
+				log.Printf("failed to mark volume %d as writable on %s: %v", volumeId, sourceVolumeServer, writableErr)
+			}
+		}
+		if deleteErr := deleteVolume(grpcDialOption, volumeId, targetVolumeServer); deleteErr != nil {
+			log.Printf("failed to delete the copy of volume %d on %s: %v", volumeId, targetVolumeServer, deleteErr)
+		}
+	}()
+
+	log.Printf("tailing volume %d from %s to %s", volumeId, sourceVolumeServer, targetVolumeServer)
+	if err = tailVolume(grpcDialOption, volumeId, sourceVolumeServer, targetVolumeServer, lastAppendAtNs, idleTimeout); err != nil {
+		return fmt.Errorf("tail volume %d from %s to %s: %v", volumeId, sourceVolumeServer, targetVolumeServer, err)
+	}
+
+	log.Printf("draining volume %d from %s to %s", volumeId, sourceVolumeServer, targetVolumeServer)
+	if err = markVolumeWritable(grpcDialOption, volumeId, sourceVolumeServer, false); err != nil {
+		return fmt.Errorf("mark volume %d as readonly on %s: %v", volumeId, sourceVolumeServer, err)
+	}
+	sourceMarkedReadonly = true
+	// the needles tailed already are skipped as unchanged, except in the TTL volumes
+	if err = tailVolume(grpcDialOption, volumeId, sourceVolumeServer, targetVolumeServer, lastAppendAtNs, 2*time.Second); err != nil {
+		return fmt.Errorf("drain volume %d from %s to %s: %v", volumeId, sourceVolumeServer, targetVolumeServer, err)
+	}
+
+	// the copy is only reported to the master after marked writable
+	if err = markVolumeWritable(grpcDialOption, volumeId, targetVolumeServer, true); err != nil {
+		return fmt.Errorf("mark volume %d as writable on %s: %v", volumeId, targetVolumeServer, err)
+	}
+	if err = waitForVolumeLocation(commandEnv, volumeId, targetVolumeServer, time.Minute); err != nil {
+		return err
+	}
+
+	log.Printf("deleting volume %d from %s", volumeId, sourceVolumeServer)
+	if deleteErr := deleteVolume(grpcDialOption, volumeId, sourceVolumeServer); deleteErr != nil {
+		// the copy is complete and writable now, only the source needs to be cleaned up
+		return fmt.Errorf("moved volume %d to %s, but failed to delete it from %s: %v", volumeId, targetVolumeServer, sourceVolumeServer, deleteErr)
+	}
+
+	log.Printf("moved volume %d from %s to %s", volumeId, sourceVolumeServer, targetVolumeServer)
+	return nil
+}
+
+// waitForVolumeLocation waits until the master knows the volume on the volume server
+func waitForVolumeLocation(commandEnv *CommandEnv, volumeId needle.VolumeId, volumeServer string, timeout time.Duration) error {
+	for start := time.Now(); time.Since(start) < timeout; time.Sleep(time.Second) {
+		locations, _ := commandEnv.MasterClient.GetLocations(uint32(volumeId))
+		for _, location := range locations {
+			if location.Url == volumeServer {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("master does not know volume %d on %s after %v", volumeId, volumeServer, timeout)
+}
+
 // LiveMoveVolume moves one volume from one source volume server to one target volume server, with idleTimeout to drain the incoming requests.
 func LiveMoveVolume(grpcDialOption grpc.DialOption, volumeId needle.VolumeId, sourceVolumeServer, targetVolumeServer string, idleTimeout time.Duration, diskType string) (err error) {
 
@@ -0,0 +1,105 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/pb/master_pb"
+	"github.com/chrislusf/seaweedfs/weed/pb/volume_server_pb"
+)
+
+func init() {
+	Commands = append(Commands, &commandVolumeScrubStatus{})
+}
+
+type commandVolumeScrubStatus struct {
+}
+
+func (c *commandVolumeScrubStatus) Name() string {
+	return "volume.scrub.status"
+}
+
+func (c *commandVolumeScrubStatus) Help() string {
+	return `show the background checksum scrubbing progress and corruptions on volume servers
+
+	volume.scrub.status [-node <volume server host:port>] [-corruptedOnly]
+
+	The scrubber is enabled by starting volume servers with "-scrubMBps=<n>".
+`
+}
+
+func (c *commandVolumeScrubStatus) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	scrubCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	volumeServer := scrubCommand.String("node", "", "<host>:<port> of the volume server, default to all volume servers")
+	corruptedOnly := scrubCommand.Bool("corruptedOnly", false, "only show volumes with corrupted needles")
+	if err = scrubCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	var volumeServers []string
+	if *volumeServer != "" {
+		volumeServers = append(volumeServers, *volumeServer)
+	} else {
+		topologyInfo, _, err := collectTopologyInfo(commandEnv)
+		if err != nil {
+			return err
+		}
+		eachDataNode(topologyInfo, func(dc string, rack RackId, dn *master_pb.DataNodeInfo) {
+			volumeServers = append(volumeServers, dn.Id)
+		})
+	}
+
+	var totalScanned, totalCorrupted, totalRepaired uint64
+	for _, server := range volumeServers {
+		err = operation.WithVolumeServerClient(server, commandEnv.option.GrpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+			resp, err := client.VolumeScrubStatus(context.Background(), &volume_server_pb.VolumeScrubStatusRequest{})
+			if err != nil {
+				return err
+			}
+			if !resp.IsEnabled {
+				fmt.Fprintf(writer, "%s: scrubbing is not enabled\n", server)
+				return nil
+			}
+			fmt.Fprintf(writer, "%s:\n", server)
+			for _, scrub := range resp.VolumeScrubs {
+				totalScanned += scrub.ScannedCount
+				totalCorrupted += scrub.CorruptCount
+				totalRepaired += scrub.RepairedCount
+				if *corruptedOnly && scrub.CorruptCount == 0 {
+					continue
+				}
+				c.printVolumeScrub(writer, scrub)
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(writer, "%s: %v\n", server, err)
+		}
+	}
+
+	fmt.Fprintf(writer, "total scanned %d needles, corrupted %d, repaired %d\n", totalScanned, totalCorrupted, totalRepaired)
+
+	return nil
+}
+
+func (c *commandVolumeScrubStatus) printVolumeScrub(writer io.Writer, scrub *volume_server_pb.VolumeScrubStatusResponse_VolumeScrub) {
+	kind := "volume"
+	if scrub.IsEcVolume {
+		kind = "ec volume"
+	}
+	progress := "in progress"
+	if scrub.CompletedAtNs > 0 {
+		progress = "completed at " + time.Unix(0, scrub.CompletedAtNs).Format(time.RFC3339)
+	}
+	fmt.Fprintf(writer, "  %s %d collection:%q scanned %d/%d corrupted %d repaired %d, %s",
+		kind, scrub.VolumeId, scrub.Collection, scrub.ScannedCount, scrub.NeedleCount, scrub.CorruptCount, scrub.RepairedCount, progress)
+	if scrub.LastError != "" {
+		fmt.Fprintf(writer, ", last error: %s", scrub.LastError)
+	}
+	fmt.Fprintln(writer)
+}
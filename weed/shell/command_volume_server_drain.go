@@ -0,0 +1,138 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/chrislusf/seaweedfs/weed/storage/super_block"
+)
+
+func init() {
+	Commands = append(Commands, &commandVolumeServerDrain{})
+}
+
+type commandVolumeServerDrain struct {
+}
+
+func (c *commandVolumeServerDrain) Name() string {
+	return "volumeServer.drain"
+}
+
+func (c *commandVolumeServerDrain) Help() string {
+	return `stop a volume server from accepting new writes, before shutting it down
+
+	volumeServer.drain -node <volume server host:port> [-offload] [-timeout 1m]
+
+	This command marks all writable volumes on the volume server readonly,
+	rejects the new writes and deletes, and waits for the in flight writes to finish.
+	The volume server keeps sending heartbeats, so the volumes are still readable.
+
+	With -offload, the volumes which were writable are moved to the other volume servers,
+	the same as volumeServer.evacuate. The volumes which can not be moved are skipped.
+
+	This operation is not revocable unless the volume server is restarted.
+`
+}
+
+func (c *commandVolumeServerDrain) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	if err = commandEnv.confirmIsLocked(); err != nil {
+		return
+	}
+
+	vsDrainCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	volumeServer := vsDrainCommand.String("node", "", "<host>:<port> of the volume server")
+	offload := vsDrainCommand.Bool("offload", false, "move the writable volumes to other volume servers")
+	timeout := vsDrainCommand.Duration("timeout", time.Minute, "how long to wait for the in flight writes")
+	if err = vsDrainCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	if *volumeServer == "" {
+		return fmt.Errorf("need to specify volume server by -node=<host>:<port>")
+	}
+
+	writableVolumeIds, err := volumeServerDrain(commandEnv, *volumeServer, *timeout, writer)
+	if err != nil {
+		return err
+	}
+
+	if *offload && len(writableVolumeIds) > 0 {
+		if err = offloadDrainedVolumes(commandEnv, *volumeServer, writableVolumeIds, writer); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(writer, "drained volume server %s. It is safe to stop the volume server.\n", *volumeServer)
+	return nil
+}
+
+// volumeServerDrain starts draining, and waits until there are no in flight writes.
+func volumeServerDrain(commandEnv *CommandEnv, volumeServer string, timeout time.Duration, writer io.Writer) (writableVolumeIds []uint32, err error) {
+	deadline := time.Now().Add(timeout)
+	isFirst := true
+	for {
+		var resp *volume_server_pb.VolumeServerDrainResponse
+		err = operation.WithVolumeServerClient(volumeServer, commandEnv.option.GrpcDialOption, func(volumeServerClient volume_server_pb.VolumeServerClient) error {
+			resp, err = volumeServerClient.VolumeServerDrain(context.Background(), &volume_server_pb.VolumeServerDrainRequest{})
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("drain volume server %s: %v", volumeServer, err)
+		}
+		if isFirst {
+			fmt.Fprintf(writer, "marked %d writable volumes readonly on %s: %v\n", len(resp.WritableVolumeIds), volumeServer, resp.WritableVolumeIds)
+			isFirst = false
+		}
+		if resp.InFlightWrites <= 0 {
+			return resp.WritableVolumeIds, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("volume server %s still has %d in flight writes after %v", volumeServer, resp.InFlightWrites, timeout)
+		}
+		fmt.Fprintf(writer, "waiting for %d in flight writes on %s\n", resp.InFlightWrites, volumeServer)
+		time.Sleep(time.Second)
+	}
+}
+
+// offloadDrainedVolumes moves the drained volumes to the other volume servers, skipping the non moveable ones.
+func offloadDrainedVolumes(commandEnv *CommandEnv, volumeServer string, volumeIds []uint32, writer io.Writer) error {
+	topologyInfo, _, err := collectTopologyInfo(commandEnv)
+	if err != nil {
+		return err
+	}
+
+	volumeServers := collectVolumeServersByDc(topologyInfo, "")
+	thisNode, otherNodes := nodesOtherThan(volumeServers, volumeServer)
+	if thisNode == nil {
+		return fmt.Errorf("%s is not found in this cluster", volumeServer)
+	}
+
+	drained := make(map[uint32]bool)
+	for _, vid := range volumeIds {
+		drained[vid] = true
+	}
+
+	volumeReplicas, _ := collectVolumeReplicaLocations(topologyInfo)
+	for _, diskInfo := range thisNode.info.DiskInfos {
+		for _, vol := range diskInfo.VolumeInfos {
+			if !drained[vol.Id] {
+				continue
+			}
+			hasMoved, err := moveAwayOneNormalVolume(commandEnv, volumeReplicas, vol, thisNode, otherNodes, true)
+			if err != nil {
+				return fmt.Errorf("move away volume %d from %s: %v", vol.Id, volumeServer, err)
+			}
+			if !hasMoved {
+				replicaPlacement, _ := super_block.NewReplicaPlacementFromByte(byte(vol.ReplicaPlacement))
+				fmt.Fprintf(writer, "skipping non moveable volume %d replication:%s\n", vol.Id, replicaPlacement.String())
+			}
+		}
+	}
+	return nil
+}
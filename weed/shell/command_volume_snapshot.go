@@ -0,0 +1,161 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+)
+
+func init() {
+	Commands = append(Commands, &commandVolumeSnapshot{})
+}
+
+type commandVolumeSnapshot struct {
+}
+
+func (c *commandVolumeSnapshot) Name() string {
+	return "volume.snapshot"
+}
+
+func (c *commandVolumeSnapshot) Help() string {
+	return `create, list, delete, or clone point in time snapshots of a volume
+
+	# create a snapshot on one volume server, by default the first replica
+	volume.snapshot -volumeId=<volume id> -name=<snapshot name> [-node=<host:port>]
+
+	# list the snapshots on all replicas, or on one volume server
+	volume.snapshot -volumeId=<volume id> -list [-node=<host:port>]
+
+	# copy the snapshot to another volume server, e.g. a backup server
+	volume.snapshot -volumeId=<volume id> -name=<snapshot name> -cloneTo=<host:port> [-node=<host:port>]
+
+	# delete the snapshot
+	volume.snapshot -volumeId=<volume id> -name=<snapshot name> -delete [-node=<host:port>]
+
+	Creating a snapshot only blocks the writes for a moment. The snapshot hard links the volume files
+	and remembers their sizes, so it does not take more disk space, until the volume is compacted.
+	The writes after the snapshot are not in the snapshot.
+
+	The cloned snapshot has exactly the volume content at the snapshot time, as a plain .dat and .idx files
+	in the "<volume>.snapshots" folder of the target volume server, which is not mounted as a volume.
+	Use -collection to list or delete the cloned snapshots on a server without the volume.
+
+`
+}
+
+func (c *commandVolumeSnapshot) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	snapshotCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	volumeIdInt := snapshotCommand.Int("volumeId", 0, "the volume id")
+	collection := snapshotCommand.String("collection", "", "the collection name of a cloned snapshot")
+	name := snapshotCommand.String("name", "", "the snapshot name")
+	nodeStr := snapshotCommand.String("node", "", "the volume server <host>:<port>, by default the first replica")
+	isList := snapshotCommand.Bool("list", false, "list the snapshots")
+	isDelete := snapshotCommand.Bool("delete", false, "delete the snapshot")
+	cloneTo := snapshotCommand.String("cloneTo", "", "copy the snapshot to this volume server <host>:<port>")
+	diskType := snapshotCommand.String("disk", "", "[hdd|ssd|<tag>] the disk type to store the cloned snapshot")
+	if err = snapshotCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	volumeId := needle.VolumeId(*volumeIdInt)
+	if volumeId == 0 {
+		return fmt.Errorf("missing -volumeId")
+	}
+
+	var nodes []string
+	if *nodeStr != "" {
+		nodes = append(nodes, *nodeStr)
+	} else if locations, found := commandEnv.MasterClient.GetLocations(uint32(volumeId)); found {
+		for _, location := range locations {
+			nodes = append(nodes, location.Url)
+		}
+	}
+	if len(nodes) == 0 {
+		return fmt.Errorf("volume %d not found, use -node to find its cloned snapshots", volumeId)
+	}
+
+	if *isList {
+		for _, node := range nodes {
+			if err = listVolumeSnapshots(commandEnv, volumeId, *collection, node, writer); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if *name == "" {
+		return fmt.Errorf("missing -name")
+	}
+
+	if err = commandEnv.confirmIsLocked(); err != nil {
+		return
+	}
+
+	node := nodes[0]
+	return operation.WithVolumeServerClient(node, commandEnv.option.GrpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+		switch {
+		case *isDelete:
+			_, deleteErr := client.VolumeSnapshotDelete(context.Background(), &volume_server_pb.VolumeSnapshotDeleteRequest{
+				VolumeId:   uint32(volumeId),
+				Collection: *collection,
+				Name:       *name,
+			})
+			if deleteErr == nil {
+				fmt.Fprintf(writer, "deleted snapshot %s of volume %d on %s\n", *name, volumeId, node)
+			}
+			return deleteErr
+		case *cloneTo != "":
+			fmt.Fprintf(writer, "cloning snapshot %s of volume %d from %s to %s ...\n", *name, volumeId, node, *cloneTo)
+			return operation.WithVolumeServerClient(*cloneTo, commandEnv.option.GrpcDialOption, func(targetClient volume_server_pb.VolumeServerClient) error {
+				resp, cloneErr := targetClient.VolumeSnapshotClone(context.Background(), &volume_server_pb.VolumeSnapshotCloneRequest{
+					VolumeId:       uint32(volumeId),
+					Collection:     *collection,
+					Name:           *name,
+					SourceDataNode: node,
+					DiskType:       *diskType,
+				})
+				if cloneErr == nil {
+					printVolumeSnapshot(writer, *cloneTo, resp.Snapshot)
+				}
+				return cloneErr
+			})
+		default:
+			resp, createErr := client.VolumeSnapshotCreate(context.Background(), &volume_server_pb.VolumeSnapshotCreateRequest{
+				VolumeId: uint32(volumeId),
+				Name:     *name,
+			})
+			if createErr == nil {
+				printVolumeSnapshot(writer, node, resp.Snapshot)
+			}
+			return createErr
+		}
+	})
+}
+
+func listVolumeSnapshots(commandEnv *CommandEnv, volumeId needle.VolumeId, collection string, node string, writer io.Writer) error {
+	return operation.WithVolumeServerClient(node, commandEnv.option.GrpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+		resp, err := client.VolumeSnapshotList(context.Background(), &volume_server_pb.VolumeSnapshotListRequest{
+			VolumeId:   uint32(volumeId),
+			Collection: collection,
+		})
+		if err != nil {
+			return fmt.Errorf("list snapshots of volume %d on %s: %v", volumeId, node, err)
+		}
+		for _, snapshot := range resp.Snapshots {
+			printVolumeSnapshot(writer, node, snapshot)
+		}
+		return nil
+	})
+}
+
+func printVolumeSnapshot(writer io.Writer, node string, snapshot *volume_server_pb.VolumeSnapshot) {
+	fmt.Fprintf(writer, "volume %d snapshot %s on %s: created at %v, .dat %d bytes, .idx %d bytes\n",
+		snapshot.VolumeId, snapshot.Name, node, time.Unix(0, snapshot.CreatedAtNs).Format(time.RFC3339), snapshot.DatFileSize, snapshot.IdxFileSize)
+}
@@ -0,0 +1,180 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+)
+
+func init() {
+	Commands = append(Commands, &commandVolumeStandby{})
+}
+
+type commandVolumeStandby struct {
+}
+
+func (c *commandVolumeStandby) Name() string {
+	return "volume.standby"
+}
+
+func (c *commandVolumeStandby) Help() string {
+	return `pre-copy a volume to a standby volume server, and switch over to it quickly
+
+	# start copying the volume, and keep tailing the writes in the background
+	volume.standby -volumeId <volume id> -source <source volume server host:port> -target <target volume server host:port> [-disk [hdd|ssd|<tag>]]
+	# show the standby volumes on the target volume server
+	volume.standby -target <target volume server host:port> [-volumeId <volume id>]
+	# switch the volume over to the target volume server
+	volume.standby -volumeId <volume id> -target <target volume server host:port> -switchover
+	# stop tailing, and delete the standby volume on the target volume server
+	volume.standby -volumeId <volume id> -target <target volume server host:port> -cancel
+
+	This is for planned maintenance, e.g. replacing the hardware of a volume server, where moving
+	large volumes takes hours. The standby volume is copied and kept in sync ahead of time,
+	and is not known to the master, so the source volume keeps serving all the reads and writes.
+	The tailing resumes after the target volume server restarts.
+
+	The switchover steps are:
+
+	1. The source volume is marked as readonly.
+	2. The target volume server receives the last writes from the source volume, until idle for 2 seconds.
+	3. The standby volume is mounted on the target volume server.
+	4. The source volume is deleted.
+
+	If the switchover fails, the source volume is marked as writable again, and the standby volume keeps tailing.
+
+`
+}
+
+func (c *commandVolumeStandby) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	volStandbyCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	volumeIdInt := volStandbyCommand.Int("volumeId", 0, "the volume id")
+	sourceNodeStr := volStandbyCommand.String("source", "", "the source volume server <host>:<port>, to start the standby volume")
+	targetNodeStr := volStandbyCommand.String("target", "", "the standby volume server <host>:<port>")
+	diskTypeStr := volStandbyCommand.String("disk", "", "[hdd|ssd|<tag>] hard drive or solid state drive or any tag")
+	isSwitchover := volStandbyCommand.Bool("switchover", false, "switch the volume over to the standby volume server")
+	isCancel := volStandbyCommand.Bool("cancel", false, "delete the standby volume")
+	if err = volStandbyCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	volumeId, targetVolumeServer := needle.VolumeId(*volumeIdInt), *targetNodeStr
+	if targetVolumeServer == "" {
+		return fmt.Errorf("need the standby volume server with -target")
+	}
+	if volumeId == 0 && (*isSwitchover || *isCancel || *sourceNodeStr != "") {
+		return fmt.Errorf("need the volume id with -volumeId")
+	}
+
+	switch {
+	case *isSwitchover:
+		if err = commandEnv.confirmIsLocked(); err != nil {
+			return
+		}
+		return switchoverStandbyVolume(commandEnv, volumeId, targetVolumeServer, 2*time.Second)
+	case *isCancel:
+		return operation.WithVolumeServerClient(targetVolumeServer, commandEnv.option.GrpcDialOption, func(volumeServerClient volume_server_pb.VolumeServerClient) error {
+			_, cancelErr := volumeServerClient.VolumeStandbyCancel(context.Background(), &volume_server_pb.VolumeStandbyCancelRequest{
+				VolumeId: uint32(volumeId),
+			})
+			return cancelErr
+		})
+	case *sourceNodeStr != "":
+		if *sourceNodeStr == targetVolumeServer {
+			return fmt.Errorf("source and target volume servers are the same!")
+		}
+		if err = commandEnv.confirmIsLocked(); err != nil {
+			return
+		}
+		log.Printf("copying volume %d from %s to standby %s", volumeId, *sourceNodeStr, targetVolumeServer)
+		return operation.WithVolumeServerClient(targetVolumeServer, commandEnv.option.GrpcDialOption, func(volumeServerClient volume_server_pb.VolumeServerClient) error {
+			_, startErr := volumeServerClient.VolumeStandbyStart(context.Background(), &volume_server_pb.VolumeStandbyStartRequest{
+				VolumeId:       uint32(volumeId),
+				SourceDataNode: *sourceNodeStr,
+				DiskType:       *diskTypeStr,
+			})
+			return startErr
+		})
+	}
+
+	return operation.WithVolumeServerClient(targetVolumeServer, commandEnv.option.GrpcDialOption, func(volumeServerClient volume_server_pb.VolumeServerClient) error {
+		resp, statusErr := volumeServerClient.VolumeStandbyStatus(context.Background(), &volume_server_pb.VolumeStandbyStatusRequest{
+			VolumeId: uint32(volumeId),
+		})
+		if statusErr != nil {
+			return statusErr
+		}
+		for _, standby := range resp.StandbyVolumes {
+			fmt.Fprintf(writer, "volume %d collection:%q source:%s size:%d lastAppendAt:%v",
+				standby.VolumeId, standby.Collection, standby.SourceDataNode, standby.DatFileSize,
+				time.Unix(0, int64(standby.LastAppendAtNs)).Format(time.RFC3339))
+			if standby.Error != "" {
+				fmt.Fprintf(writer, " error:%s", standby.Error)
+			}
+			fmt.Fprintln(writer)
+		}
+		return nil
+	})
+}
+
+// switchoverStandbyVolume replaces the source volume with the standby volume, with idleTimeout to drain the last writes.
+func switchoverStandbyVolume(commandEnv *CommandEnv, volumeId needle.VolumeId, targetVolumeServer string, idleTimeout time.Duration) (err error) {
+
+	grpcDialOption := commandEnv.option.GrpcDialOption
+
+	var sourceVolumeServer string
+	err = operation.WithVolumeServerClient(targetVolumeServer, grpcDialOption, func(volumeServerClient volume_server_pb.VolumeServerClient) error {
+		resp, statusErr := volumeServerClient.VolumeStandbyStatus(context.Background(), &volume_server_pb.VolumeStandbyStatusRequest{
+			VolumeId: uint32(volumeId),
+		})
+		if statusErr != nil {
+			return statusErr
+		}
+		sourceVolumeServer = resp.StandbyVolumes[0].SourceDataNode
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("find standby volume %d on %s: %v", volumeId, targetVolumeServer, err)
+	}
+
+	log.Printf("marking volume %d as readonly on %s", volumeId, sourceVolumeServer)
+	if err = markVolumeWritable(grpcDialOption, volumeId, sourceVolumeServer, false); err != nil {
+		return fmt.Errorf("mark volume %d as readonly on %s: %v", volumeId, sourceVolumeServer, err)
+	}
+
+	log.Printf("switching volume %d over from %s to %s", volumeId, sourceVolumeServer, targetVolumeServer)
+	err = operation.WithVolumeServerClient(targetVolumeServer, grpcDialOption, func(volumeServerClient volume_server_pb.VolumeServerClient) error {
+		_, switchoverErr := volumeServerClient.VolumeStandbySwitchover(context.Background(), &volume_server_pb.VolumeStandbySwitchoverRequest{
+			VolumeId:           uint32(volumeId),
+			IdleTimeoutSeconds: uint32(idleTimeout.Seconds()),
+		})
+		return switchoverErr
+	})
+	if err != nil {
+		if writableErr := markVolumeWritable(grpcDialOption, volumeId, sourceVolumeServer, true); writableErr != nil {
+			log.Printf("failed to mark volume %d as writable on %s: %v", volumeId, sourceVolumeServer, writableErr)
+		}
+		return fmt.Errorf("switch volume %d over from %s to %s: %v", volumeId, sourceVolumeServer, targetVolumeServer, err)
+	}
+
+	if err = waitForVolumeLocation(commandEnv, volumeId, targetVolumeServer, time.Minute); err != nil {
+		return err
+	}
+
+	log.Printf("deleting volume %d from %s", volumeId, sourceVolumeServer)
+	if deleteErr := deleteVolume(grpcDialOption, volumeId, sourceVolumeServer); deleteErr != nil {
+		// the standby volume is mounted and writable now, only the source needs to be cleaned up
+		return fmt.Errorf("switched volume %d over to %s, but failed to delete it from %s: %v", volumeId, targetVolumeServer, sourceVolumeServer, deleteErr)
+	}
+
+	log.Printf("switched volume %d over from %s to %s", volumeId, sourceVolumeServer, targetVolumeServer)
+	return nil
+}
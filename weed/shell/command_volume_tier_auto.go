@@ -0,0 +1,232 @@
+package shell
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/master_pb"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+	"github.com/chrislusf/seaweedfs/weed/storage/types"
+)
+
+func init() {
+	Commands = append(Commands, &commandVolumeTierAuto{})
+}
+
+type commandVolumeTierAuto struct {
+}
+
+func (c *commandVolumeTierAuto) Name() string {
+	return "volume.tier.auto"
+}
+
+func (c *commandVolumeTierAuto) Help() string {
+	return `move volumes between disk types and the cloud tier by how often they are read
+
+	volume.tier.auto [-collection=EACH_COLLECTION] [-hotReadsPerDay=1000] [-hotDiskType=ssd]
+		[-coldReadsPerDay=10] [-coldDiskType=hdd] [-coldDest=s3.default] [-fullPercent=95] [-quietFor=24h] [-trackedFor=24h] [-force]
+
+	The volume servers report the recent reads per day of each volume, decayed with a half life of one day.
+	The reads of all the replicas of a volume are added up.
+
+	A volume read at least "hotReadsPerDay" is moved to the "hotDiskType" disks,
+	and downloaded first if it is on the cloud tier.
+	A volume on the "hotDiskType" disks read less than "coldReadsPerDay" is moved to the "coldDiskType" disks.
+	If "coldDest" is set, a volume on other disks read less than "coldReadsPerDay" is uploaded to the cloud tier.
+
+	Only the volumes reaching "fullPercent" of the volume size limit, and without writes for "quietFor", are moved.
+	The reads are tracked from zero after a volume server restarts or a volume is moved,
+	so a volume is only considered cold after its reads are tracked for "trackedFor".
+
+	Enable "master.tier" in master.toml to apply the tiering periodically on the leader master.
+	As with "volume.tier.move", only one replica is moved and the rest replicas are dropped,
+	so "volume.fix.replication" should be followed.
+
+`
+}
+
+const (
+	autoTierPromote  = "promote"
+	autoTierDemote   = "demote"
+	autoTierUpload   = "upload"
+	autoTierDownload = "download"
+)
+
+type autoTierPolicy struct {
+	hotReadsPerDay    uint64
+	hotDiskType       types.DiskType
+	coldReadsPerDay   uint64
+	coldDiskType      types.DiskType
+	coldDest          string
+	fullSize          uint64
+	quietSeconds      int64
+	trackedForSeconds int64
+}
+
+type autoTierMove struct {
+	vid         needle.VolumeId
+	collection  string
+	readsPerDay uint64
+	action      string
+}
+
+func (c *commandVolumeTierAuto) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	tierCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	collection := tierCommand.String("collection", "EACH_COLLECTION", "collection name, or \"EACH_COLLECTION\" for each collection")
+	hotReadsPerDay := tierCommand.Uint64("hotReadsPerDay", 1000, "move volumes read at least this many times per day to the hot disk type")
+	hotDiskType := tierCommand.String("hotDiskType", "ssd", "the disk type for hot volumes")
+	coldReadsPerDay := tierCommand.Uint64("coldReadsPerDay", 10, "move volumes read less than this many times per day to the cold disk type or the cloud tier")
+	coldDiskType := tierCommand.String("coldDiskType", "hdd", "the disk type for cold volumes")
+	coldDest := tierCommand.String("coldDest", "", "the cloud tier for cold volumes, e.g. s3.default; empty to keep cold volumes local")
+	fullPercentage := tierCommand.Float64("fullPercent", 95, "the volume reaches the percentage of max volume size")
+	quietPeriod := tierCommand.Duration("quietFor", 24*time.Hour, "select volumes without writes for this period")
+	trackedPeriod := tierCommand.Duration("trackedFor", 24*time.Hour, "only consider volumes cold after their reads are tracked for this period")
+	applyChange := tierCommand.Bool("force", false, "actually apply the changes")
+	if err = tierCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	option := AutoTierOption{
+		Collection:      *collection,
+		HotReadsPerDay:  *hotReadsPerDay,
+		HotDiskType:     types.ToDiskType(*hotDiskType),
+		ColdReadsPerDay: *coldReadsPerDay,
+		ColdDiskType:    types.ToDiskType(*coldDiskType),
+		ColdDest:        *coldDest,
+		FullPercentage:  *fullPercentage,
+		QuietFor:        *quietPeriod,
+		TrackedFor:      *trackedPeriod,
+	}
+	if err = option.Validate(); err != nil {
+		return err
+	}
+
+	if *applyChange {
+		if err = commandEnv.confirmIsLocked(); err != nil {
+			return
+		}
+	}
+
+	return runAutoTier(commandEnv, option, writer, *applyChange)
+}
+
+// runAutoTier moves the hot and cold volumes, or only prints the moves if not applyChange.
+func runAutoTier(commandEnv *CommandEnv, option AutoTierOption, writer io.Writer, applyChange bool) error {
+
+	// collect topology information
+	topologyInfo, volumeSizeLimitMb, err := collectTopologyInfo(commandEnv)
+	if err != nil {
+		return err
+	}
+	policy := option.policy(volumeSizeLimitMb)
+
+	moves := planAutoTierMoves(topologyInfo, option.Collection, policy, time.Now().Unix())
+
+	_, allLocations := collectVolumeReplicaLocations(topologyInfo)
+	for _, move := range moves {
+		fmt.Fprintf(writer, "%s volume %d of collection %q read %d times per day\n", move.action, move.vid, move.collection, move.readsPerDay)
+		switch move.action {
+		case autoTierPromote:
+			err = doVolumeTierMove(commandEnv, writer, move.collection, move.vid, policy.hotDiskType, allLocations, applyChange)
+		case autoTierDemote:
+			err = doVolumeTierMove(commandEnv, writer, move.collection, move.vid, policy.coldDiskType, allLocations, applyChange)
+		case autoTierUpload:
+			if applyChange {
+				err = doVolumeTierUpload(commandEnv, writer, move.collection, move.vid, policy.coldDest, false)
+			}
+		case autoTierDownload:
+			if applyChange {
+				err = doVolumeTierDownload(commandEnv, writer, move.collection, move.vid)
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(writer, "%s volume %d: %v\n", move.action, move.vid, err)
+		}
+	}
+
+	return nil
+}
+
+// planAutoTierMoves decides the moves by the reads of all replicas of each volume.
+func planAutoTierMoves(topologyInfo *master_pb.TopologyInfo, selectedCollection string, policy *autoTierPolicy, nowUnixSeconds int64) (moves []*autoTierMove) {
+
+	type volumeHeat struct {
+		collection              string
+		readsPerDay             uint64
+		readsTrackedSinceSecond int64
+		modifiedAtSecond        int64
+		size                    uint64
+		isRemote                bool
+		isAllHot                bool
+		hasHot                  bool
+	}
+
+	heats := make(map[uint32]*volumeHeat)
+	eachDataNode(topologyInfo, func(dc string, rack RackId, dn *master_pb.DataNodeInfo) {
+		for _, diskInfo := range dn.DiskInfos {
+			for _, v := range diskInfo.VolumeInfos {
+				if selectedCollection != "EACH_COLLECTION" && v.Collection != selectedCollection {
+					continue
+				}
+				h, found := heats[v.Id]
+				if !found {
+					h = &volumeHeat{collection: v.Collection, isAllHot: true}
+					heats[v.Id] = h
+				}
+				h.readsPerDay += v.ReadsPerDay
+				if v.ReadsTrackedSinceSecond > h.readsTrackedSinceSecond {
+					h.readsTrackedSinceSecond = v.ReadsTrackedSinceSecond
+				}
+				if v.ModifiedAtSecond > h.modifiedAtSecond {
+					h.modifiedAtSecond = v.ModifiedAtSecond
+				}
+				if v.Size > h.size {
+					h.size = v.Size
+				}
+				if v.RemoteStorageName != "" {
+					h.isRemote = true
+				}
+				if types.ToDiskType(v.DiskType) == policy.hotDiskType {
+					h.hasHot = true
+				} else {
+					h.isAllHot = false
+				}
+			}
+		}
+	})
+
+	for vid, h := range heats {
+		if h.size < policy.fullSize || h.modifiedAtSecond+policy.quietSeconds >= nowUnixSeconds {
+			continue
+		}
+		action := ""
+		isCold := h.readsPerDay < policy.coldReadsPerDay && h.readsTrackedSinceSecond+policy.trackedForSeconds <= nowUnixSeconds
+		switch {
+		case h.readsPerDay >= policy.hotReadsPerDay && h.isRemote:
+			action = autoTierDownload
+		case h.readsPerDay >= policy.hotReadsPerDay && !h.isAllHot:
+			action = autoTierPromote
+		case isCold && h.isAllHot && !h.isRemote:
+			action = autoTierDemote
+		case isCold && !h.hasHot && !h.isRemote && policy.coldDest != "":
+			action = autoTierUpload
+		}
+		if action != "" {
+			moves = append(moves, &autoTierMove{
+				vid:         needle.VolumeId(vid),
+				collection:  h.collection,
+				readsPerDay: h.readsPerDay,
+				action:      action,
+			})
+		}
+	}
+	sort.Slice(moves, func(i, j int) bool {
+		return moves[i].vid < moves[j].vid
+	})
+
+	return
+}
@@ -0,0 +1,64 @@
+package shell
+
+import (
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/master_pb"
+	"github.com/chrislusf/seaweedfs/weed/storage/types"
+)
+
+func TestPlanAutoTierMoves(t *testing.T) {
+
+	now := int64(1000000)
+	old := now - 100000
+	volume := func(id uint32, diskType string, readsPerDay uint64, remote string) *master_pb.VolumeInformationMessage {
+		return &master_pb.VolumeInformationMessage{Id: id, Size: 1000, DiskType: diskType, ReadsPerDay: readsPerDay,
+			ReadsTrackedSinceSecond: old, ModifiedAtSecond: old, RemoteStorageName: remote}
+	}
+	topologyInfo := &master_pb.TopologyInfo{
+		DataCenterInfos: []*master_pb.DataCenterInfo{{Id: "dc1", RackInfos: []*master_pb.RackInfo{{Id: "r1", DataNodeInfos: []*master_pb.DataNodeInfo{
+			{Id: "dn1", DiskInfos: map[string]*master_pb.DiskInfo{
+				"": {VolumeInfos: []*master_pb.VolumeInformationMessage{
+					volume(1, "", 600, ""), // hot with replicas
+					volume(3, "", 5, ""),   // cold on hdd
+					volume(4, "", 5000, "s3.default"),
+					volume(6, "", 1, ""), // still written
+					volume(7, "", 1, ""), // not tracked long enough
+				}},
+				"ssd": {VolumeInfos: []*master_pb.VolumeInformationMessage{
+					volume(2, "ssd", 1, ""), // cold on ssd
+					volume(5, "ssd", 5000, ""),
+				}},
+			}},
+			{Id: "dn2", DiskInfos: map[string]*master_pb.DiskInfo{
+				"": {VolumeInfos: []*master_pb.VolumeInformationMessage{
+					volume(1, "", 600, ""),
+				}},
+			}},
+		}}}}},
+	}
+	topologyInfo.DataCenterInfos[0].RackInfos[0].DataNodeInfos[0].DiskInfos[""].VolumeInfos[3].ModifiedAtSecond = now - 10
+	topologyInfo.DataCenterInfos[0].RackInfos[0].DataNodeInfos[0].DiskInfos[""].VolumeInfos[4].ReadsTrackedSinceSecond = now - 10
+
+	policy := &autoTierPolicy{
+		hotReadsPerDay:    1000,
+		hotDiskType:       types.SsdType,
+		coldReadsPerDay:   10,
+		coldDiskType:      types.HardDriveType,
+		coldDest:          "s3.default",
+		fullSize:          900,
+		quietSeconds:      3600,
+		trackedForSeconds: 3600,
+	}
+	moves := planAutoTierMoves(topologyInfo, "EACH_COLLECTION", policy, now)
+
+	expected := map[uint32]string{1: autoTierPromote, 2: autoTierDemote, 3: autoTierUpload, 4: autoTierDownload}
+	if len(moves) != len(expected) {
+		t.Fatalf("expected %d moves, but got %d", len(expected), len(moves))
+	}
+	for _, move := range moves {
+		if expected[uint32(move.vid)] != move.action {
+			t.Errorf("volume %d: expected %q, but got %q", move.vid, expected[uint32(move.vid)], move.action)
+		}
+	}
+}
@@ -34,10 +34,15 @@ func (c *commandVolumeTierUpload) Help() string {
 	e.g.:
 	volume.tier.upload -volumeId=7 -dest=s3
 	volume.tier.upload -volumeId=7 -dest=s3.default
+	volume.tier.upload -volumeId=7 -dest=gcs.default
+	volume.tier.upload -volumeId=7 -dest=azure.default
 
 	The <storage_backend> is defined in master.toml.
 	For example, "s3.default" in [storage.backend.s3.default]
 
+	The volume index stays on the volume server, and the needle data are read from the remote tier by ranges.
+	Start volume servers with "-tier.cacheSizeMB" to cache the remote data locally.
+
 	This command will move the dat file of a volume to a remote tier.
 
 	SeaweedFS enables scalable and fast local access to lots of files, 
@@ -0,0 +1,85 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+)
+
+func init() {
+	Commands = append(Commands, &commandVolumeUndelete{})
+}
+
+type commandVolumeUndelete struct {
+}
+
+func (c *commandVolumeUndelete) Name() string {
+	return "volume.undelete"
+}
+
+func (c *commandVolumeUndelete) Help() string {
+	return `restore deleted files by file ids, on all the replicas
+
+	volume.undelete -fid=<file id>[,<file id>...]
+
+	The deleted files are kept from vacuum for the grace period of the collection,
+	set by starting volume servers with "-softDeleteHours=<collection>:<hours>".
+	After the grace period, a deleted file can still be restored until its volume is vacuumed.
+
+	Only the volume data is restored. If the file was deleted on the filer, the filer entry is not restored.
+
+`
+}
+
+func (c *commandVolumeUndelete) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	undeleteCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	fileIdsStr := undeleteCommand.String("fid", "", "comma separated file ids to undelete")
+	if err = undeleteCommand.Parse(args); err != nil {
+		return nil
+	}
+	if *fileIdsStr == "" {
+		return fmt.Errorf("missing -fid")
+	}
+
+	if err = commandEnv.confirmIsLocked(); err != nil {
+		return
+	}
+
+	for _, fileId := range strings.Split(*fileIdsStr, ",") {
+		fid, parseErr := needle.ParseFileIdFromString(fileId)
+		if parseErr != nil {
+			return fmt.Errorf("parse file id %s: %v", fileId, parseErr)
+		}
+		locations, found := commandEnv.MasterClient.GetLocations(uint32(fid.VolumeId))
+		if !found {
+			fmt.Fprintf(writer, "%s: volume %d not found\n", fileId, fid.VolumeId)
+			continue
+		}
+		for _, location := range locations {
+			err = operation.WithVolumeServerClient(location.Url, commandEnv.option.GrpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+				resp, undeleteErr := client.VolumeNeedleUndelete(context.Background(), &volume_server_pb.VolumeNeedleUndeleteRequest{
+					VolumeId: uint32(fid.VolumeId),
+					NeedleId: uint64(fid.Key),
+					Cookie:   uint32(fid.Cookie),
+				})
+				if undeleteErr != nil {
+					return undeleteErr
+				}
+				fmt.Fprintf(writer, "%s: undeleted %d bytes on %s\n", fileId, resp.Size, location.Url)
+				return nil
+			})
+			if err != nil {
+				fmt.Fprintf(writer, "%s: %s: %v\n", fileId, location.Url, err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/storage/types"
+)
+
+/*
+The auto tierer runs on the leader master, and periodically moves the volumes between the disk types
+and the cloud tier by how often they are read, as volume.tier.auto does, with the policy in master.toml.
+*/
+
+type AutoTierOption struct {
+	Interval        time.Duration
+	Collection      string // "EACH_COLLECTION" for each collection
+	HotReadsPerDay  uint64
+	HotDiskType     types.DiskType
+	ColdReadsPerDay uint64
+	ColdDiskType    types.DiskType
+	ColdDest        string // the cloud tier for cold volumes, empty to keep them local
+	FullPercentage  float64
+	QuietFor        time.Duration
+	TrackedFor      time.Duration
+}
+
+func (option AutoTierOption) Validate() error {
+	if option.ColdReadsPerDay >= option.HotReadsPerDay {
+		return fmt.Errorf("coldReadsPerDay %d should be less than hotReadsPerDay %d", option.ColdReadsPerDay, option.HotReadsPerDay)
+	}
+	if option.HotDiskType == option.ColdDiskType {
+		return fmt.Errorf("hot disk type %s is the same as cold disk type %s", option.HotDiskType.ReadableString(), option.ColdDiskType.ReadableString())
+	}
+	return nil
+}
+
+func (option AutoTierOption) policy(volumeSizeLimitMb uint64) *autoTierPolicy {
+	return &autoTierPolicy{
+		hotReadsPerDay:    option.HotReadsPerDay,
+		hotDiskType:       option.HotDiskType,
+		coldReadsPerDay:   option.ColdReadsPerDay,
+		coldDiskType:      option.ColdDiskType,
+		coldDest:          option.ColdDest,
+		fullSize:          uint64(option.FullPercentage / 100 * float64(volumeSizeLimitMb) * 1024 * 1024),
+		quietSeconds:      int64(option.QuietFor / time.Second),
+		trackedForSeconds: int64(option.TrackedFor / time.Second),
+	}
+}
+
+type AutoTierer struct {
+	commandEnv *CommandEnv
+	option     AutoTierOption
+}
+
+func NewAutoTierer(commandEnv *CommandEnv, option AutoTierOption) *AutoTierer {
+	return &AutoTierer{
+		commandEnv: commandEnv,
+		option:     option,
+	}
+}
+
+// Start runs the tiering at the interval, while isLeader().
+func (t *AutoTierer) Start(isLeader func() bool, writer io.Writer) {
+	go func() {
+		t.commandEnv.MasterClient.WaitUntilConnected()
+		c := time.Tick(t.option.Interval)
+		for range c {
+			if !isLeader() {
+				continue
+			}
+			if err := t.RunOnce(writer); err != nil {
+				glog.V(0).Infof("auto tierer: %v", err)
+			}
+		}
+	}()
+}
+
+// RunOnce locks the cluster, and moves the hot and cold volumes.
+func (t *AutoTierer) RunOnce(writer io.Writer) error {
+	t.commandEnv.locker.RequestLock()
+	defer t.commandEnv.locker.ReleaseLock()
+
+	return runAutoTier(t.commandEnv, t.option, writer, true)
+}
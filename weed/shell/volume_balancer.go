@@ -0,0 +1,212 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/master_pb"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+	"github.com/chrislusf/seaweedfs/weed/storage/super_block"
+	"github.com/chrislusf/seaweedfs/weed/storage/types"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+The volume balancer runs on the leader master, and continuously moves the volumes to even out the volume servers,
+instead of running volume.balance by hand.
+
+Same as volume.balance, the writable volumes are balanced for the write load, and the read only or full volumes
+for the disk usage, by the volume count to the max volume count of each disk type. A volume server is balanced
+if its ratio is within the threshold of the ideal ratio. Each round moves at most the volumes and the bytes of
+the io budget, the smaller volumes first, and only runs within the balancing windows if any.
+*/
+
+type VolumeBalancerOption struct {
+	Interval         time.Duration
+	Threshold        float64
+	MaxMovesPerRound int
+	MaxBytesPerRound uint64
+	Windows          util.TimeWindows
+	// the volume servers to skip, e.g. the ones in maintenance
+	SkipDataNode func(dataCenter, rack, dataNode string) bool
+}
+
+type VolumeBalancer struct {
+	commandEnv *CommandEnv
+	option     VolumeBalancerOption
+}
+
+func NewVolumeBalancer(commandEnv *CommandEnv, option VolumeBalancerOption) *VolumeBalancer {
+	return &VolumeBalancer{
+		commandEnv: commandEnv,
+		option:     option,
+	}
+}
+
+// Start runs the balancing rounds at the interval, while isLeader() and within the balancing windows.
+func (b *VolumeBalancer) Start(isLeader func() bool, writer io.Writer) {
+	go func() {
+		b.commandEnv.MasterClient.WaitUntilConnected()
+		c := time.Tick(b.option.Interval)
+		for now := range c {
+			if !isLeader() || !b.option.Windows.Contains(now) {
+				continue
+			}
+			if err := b.RunOnce(writer); err != nil {
+				glog.V(0).Infof("volume balancer: %v", err)
+			}
+		}
+	}()
+}
+
+// RunOnce locks the cluster, and moves the volumes within the io budget.
+func (b *VolumeBalancer) RunOnce(writer io.Writer) error {
+	b.commandEnv.locker.RequestLock()
+	defer b.commandEnv.locker.ReleaseLock()
+
+	topologyInfo, volumeSizeLimitMb, err := collectTopologyInfo(b.commandEnv)
+	if err != nil {
+		return err
+	}
+	var nodes []*Node
+	for _, n := range collectVolumeServersByDc(topologyInfo, "") {
+		if b.option.SkipDataNode != nil && b.option.SkipDataNode(n.dc, n.rack, n.info.Id) {
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	volumeReplicas, _ := collectVolumeReplicaLocations(topologyInfo)
+	diskTypes := collectVolumeDiskTypes(topologyInfo)
+
+	moves := planVolumeMoves(diskTypes, volumeReplicas, nodes, volumeSizeLimitMb*1024*1024, b.option)
+	for _, move := range moves {
+		fmt.Fprintf(writer, "volume balancer: moving %s volume %d of %d bytes %s => %s\n", move.diskType, move.volumeId, move.size, move.source, move.target)
+		if err = LiveMoveVolume(b.commandEnv.option.GrpcDialOption, move.volumeId, move.source, move.target, 5*time.Second, string(move.diskType)); err != nil {
+			return fmt.Errorf("move volume %d %s => %s: %v", move.volumeId, move.source, move.target, err)
+		}
+	}
+	return nil
+}
+
+type volumeMove struct {
+	volumeId needle.VolumeId
+	diskType types.DiskType
+	size     uint64
+	source   string
+	target   string
+}
+
+// planVolumeMoves balances the writable volumes, then the read only or full volumes, for each disk type
+func planVolumeMoves(diskTypes []types.DiskType, volumeReplicas map[uint32][]*VolumeReplica, nodes []*Node, volumeSizeLimit uint64, option VolumeBalancerOption) (moves []*volumeMove) {
+	sort.Slice(diskTypes, func(i, j int) bool {
+		return diskTypes[i] < diskTypes[j]
+	})
+	budget := &balanceBudget{moves: option.MaxMovesPerRound, bytes: option.MaxBytesPerRound}
+	for _, diskType := range diskTypes {
+		for _, writable := range []bool{true, false} {
+			for _, n := range nodes {
+				n.selectVolumes(func(v *master_pb.VolumeInformationMessage) bool {
+					isWritable := !v.ReadOnly && v.Size < volumeSizeLimit
+					return v.DiskType == string(diskType) && isWritable == writable
+				})
+			}
+			moves = append(moves, planSelectedVolumeMoves(diskType, volumeReplicas, nodes, capacityByMaxVolumeCount(diskType), option.Threshold, budget)...)
+		}
+	}
+	return
+}
+
+type balanceBudget struct {
+	moves int
+	bytes uint64
+}
+
+func (budget *balanceBudget) allows(size uint64) bool {
+	return budget.moves > 0 && size <= budget.bytes
+}
+
+func (budget *balanceBudget) spend(size uint64) {
+	budget.moves--
+	budget.bytes -= size
+}
+
+func planSelectedVolumeMoves(diskType types.DiskType, volumeReplicas map[uint32][]*VolumeReplica, nodes []*Node, capacityFunc CapacityFunc, threshold float64, budget *balanceBudget) (moves []*volumeMove) {
+	selectedVolumeCount, volumeMaxCount := 0, 0
+	var nodesWithCapacity []*Node
+	for _, dn := range nodes {
+		selectedVolumeCount += len(dn.selectedVolumes)
+		capacity := capacityFunc(dn.info)
+		if capacity > 0 {
+			nodesWithCapacity = append(nodesWithCapacity, dn)
+		}
+		volumeMaxCount += capacity
+	}
+	if len(nodesWithCapacity) < 2 {
+		return nil
+	}
+	idealVolumeRatio := divide(selectedVolumeCount, volumeMaxCount)
+
+	for hasMoved := true; hasMoved && budget.moves > 0; {
+		hasMoved = false
+		sort.Slice(nodesWithCapacity, func(i, j int) bool {
+			return nodesWithCapacity[i].localVolumeRatio(capacityFunc) < nodesWithCapacity[j].localVolumeRatio(capacityFunc)
+		})
+
+		fullNode := nodesWithCapacity[len(nodesWithCapacity)-1]
+		if fullNode.localVolumeRatio(capacityFunc) <= idealVolumeRatio+threshold {
+			break
+		}
+		var candidateVolumes []*master_pb.VolumeInformationMessage
+		for _, v := range fullNode.selectedVolumes {
+			if budget.allows(v.Size) {
+				candidateVolumes = append(candidateVolumes, v)
+			}
+		}
+		// the smaller volumes spend less of the io budget
+		sortWritableVolumes(candidateVolumes)
+
+		for i := 0; i < len(nodesWithCapacity)-1 && !hasMoved; i++ {
+			emptyNode := nodesWithCapacity[i]
+			if emptyNode.localVolumeNextRatio(capacityFunc) > idealVolumeRatio {
+				// no more volume servers with empty slots
+				break
+			}
+			for _, v := range candidateVolumes {
+				if !canMoveVolume(volumeReplicas, fullNode, v, emptyNode) {
+					continue
+				}
+				moves = append(moves, &volumeMove{
+					volumeId: needle.VolumeId(v.Id),
+					diskType: diskType,
+					size:     v.Size,
+					source:   fullNode.info.Id,
+					target:   emptyNode.info.Id,
+				})
+				budget.spend(v.Size)
+				adjustAfterMove(v, volumeReplicas, fullNode, emptyNode)
+				hasMoved = true
+				break
+			}
+		}
+	}
+	return
+}
+
+func canMoveVolume(volumeReplicas map[uint32][]*VolumeReplica, fullNode *Node, v *master_pb.VolumeInformationMessage, emptyNode *Node) bool {
+	if _, found := emptyNode.selectedVolumes[v.Id]; found {
+		return false
+	}
+	for _, replica := range volumeReplicas[v.Id] {
+		if replica.location.dataNode.Id == emptyNode.info.Id {
+			return false
+		}
+	}
+	if v.ReplicaPlacement > 0 {
+		replicaPlacement, _ := super_block.NewReplicaPlacementFromByte(byte(v.ReplicaPlacement))
+		return isGoodMove(replicaPlacement, volumeReplicas[v.Id], fullNode, emptyNode)
+	}
+	return true
+}
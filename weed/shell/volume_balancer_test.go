@@ -0,0 +1,89 @@
+package shell
+
+import (
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/master_pb"
+)
+
+func newBalancerTopology(volumeCounts ...int) *master_pb.TopologyInfo {
+	rack := &master_pb.RackInfo{Id: "r1"}
+	vid := uint32(1)
+	for i, count := range volumeCounts {
+		diskInfo := &master_pb.DiskInfo{MaxVolumeCount: 10, VolumeCount: uint64(count)}
+		for j := 0; j < count; j++ {
+			diskInfo.VolumeInfos = append(diskInfo.VolumeInfos, &master_pb.VolumeInformationMessage{
+				Id:   vid,
+				Size: uint64(vid) * 1000,
+			})
+			vid++
+		}
+		rack.DataNodeInfos = append(rack.DataNodeInfos, &master_pb.DataNodeInfo{
+			Id:        string(rune('a'+i)) + ":8080",
+			DiskInfos: map[string]*master_pb.DiskInfo{"": diskInfo},
+		})
+	}
+	return &master_pb.TopologyInfo{
+		DataCenterInfos: []*master_pb.DataCenterInfo{{Id: "dc1", RackInfos: []*master_pb.RackInfo{rack}}},
+	}
+}
+
+func planBalancerTopology(topologyInfo *master_pb.TopologyInfo, option VolumeBalancerOption) []*volumeMove {
+	volumeReplicas, _ := collectVolumeReplicaLocations(topologyInfo)
+	return planVolumeMoves(collectVolumeDiskTypes(topologyInfo), volumeReplicas, collectVolumeServersByDc(topologyInfo, ""), 30000, option)
+}
+
+func TestPlanVolumeMoves(t *testing.T) {
+	option := VolumeBalancerOption{Threshold: 0.05, MaxMovesPerRound: 4, MaxBytesPerRound: 1 << 30}
+
+	moves := planBalancerTopology(newBalancerTopology(10, 0, 0), option)
+	if len(moves) != 4 {
+		t.Fatalf("expecting 4 moves within the budget, but got %d", len(moves))
+	}
+	targets := make(map[string]int)
+	for i, move := range moves {
+		if move.source != "a:8080" {
+			t.Errorf("unexpected move %+v", move)
+		}
+		if i > 0 && moves[i-1].size > move.size {
+			t.Errorf("expecting the smaller volumes moved first, but got %+v", moves)
+		}
+		targets[move.target]++
+	}
+	if targets["b:8080"] != 2 || targets["c:8080"] != 2 {
+		t.Errorf("expecting the moves spread evenly, but got %+v", targets)
+	}
+
+	// volume 1 and 2 take 3000 bytes
+	option.MaxBytesPerRound = 3500
+	if moves = planBalancerTopology(newBalancerTopology(10, 0, 0), option); len(moves) != 2 {
+		t.Errorf("expecting 2 moves within the byte budget, but got %d", len(moves))
+	}
+
+	option.MaxBytesPerRound = 1 << 30
+	if moves = planBalancerTopology(newBalancerTopology(4, 3, 3), option); len(moves) != 0 {
+		t.Errorf("expecting no moves when balanced, but got %d", len(moves))
+	}
+
+	// all volumes of the full server are larger than the budget
+	option.MaxBytesPerRound = 500
+	if moves = planBalancerTopology(newBalancerTopology(10, 0, 0), option); len(moves) != 0 {
+		t.Errorf("expecting no moves over the budget, but got %d", len(moves))
+	}
+}
+
+func TestPlanVolumeMovesSkipsReplicaLocations(t *testing.T) {
+	topologyInfo := newBalancerTopology(3, 0)
+	// server b already has a copy of volume 1, so volume 2 is moved instead
+	b := topologyInfo.DataCenterInfos[0].RackInfos[0].DataNodeInfos[1]
+	b.DiskInfos[""].VolumeInfos = append(b.DiskInfos[""].VolumeInfos, &master_pb.VolumeInformationMessage{Id: 1, Size: 1000, ReadOnly: true})
+	b.DiskInfos[""].VolumeCount++
+
+	moves := planBalancerTopology(topologyInfo, VolumeBalancerOption{MaxMovesPerRound: 4, MaxBytesPerRound: 1 << 30})
+	if len(moves) != 1 || moves[0].volumeId != 2 || moves[0].target != "b:8080" {
+		for _, m := range moves {
+			t.Logf("move %+v", m)
+		}
+		t.Errorf("expecting volume 2 moved to b:8080")
+	}
+}
@@ -35,6 +35,54 @@ var (
 			Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 24),
 		}, []string{"type"})
 
+	FilerDirectoryEntriesGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "SeaweedFS",
+			Subsystem: "filer",
+			Name:      "directory_entries",
+			Help:      "Number of entries in the directories close to the directory entries limit.",
+		}, []string{"directory"})
+
+	FilerReconcileCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "SeaweedFS",
+			Subsystem: "filer",
+			Name:      "reconcile_total",
+			Help:      "Counter of files checked by the filer reconciler.",
+		}, []string{"type"})
+
+	FilerLifecycleCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "SeaweedFS",
+			Subsystem: "filer",
+			Name:      "lifecycle_total",
+			Help:      "Counter of entries removed by the s3 bucket lifecycle rules.",
+		}, []string{"type"})
+
+	FilerInventoryCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "SeaweedFS",
+			Subsystem: "filer",
+			Name:      "inventory_total",
+			Help:      "Counter of the s3 bucket inventory reports, with the objects listed and the errors.",
+		}, []string{"type"})
+
+	FilerTieredArchiveCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "SeaweedFS",
+			Subsystem: "filer",
+			Name:      "tiered_archive_total",
+			Help:      "Counter of the s3 objects on the tiered volumes marked as archived, or back as normal objects.",
+		}, []string{"type"})
+
+	FilerS3EventCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "SeaweedFS",
+			Subsystem: "filer",
+			Name:      "s3_event_total",
+			Help:      "Counter of the s3 event notifications sent, failed, or dropped if the queue is full.",
+		}, []string{"type"})
+
 	FilerStoreCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: "SeaweedFS",
@@ -109,6 +157,14 @@ var (
 			Help:      "Resource usage",
 		}, []string{"name", "type"})
 
+	VolumeServerCorruptNeedleCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "SeaweedFS",
+			Subsystem: "volumeServer",
+			Name:      "corrupt_needles_total",
+			Help:      "Counter of needles failing the CRC check on read.",
+		}, []string{"collection", "type"})
+
 	S3RequestCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: "SeaweedFS",
@@ -124,12 +180,64 @@ var (
 			Help:      "Bucketed histogram of s3 request processing time.",
 			Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 24),
 		}, []string{"type"})
+	S3BucketRequestCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "SeaweedFS",
+			Subsystem: "s3",
+			Name:      "bucket_request_total",
+			Help:      "Counter of s3 requests of each bucket and access key.",
+		}, []string{"bucket", "identity", "access_key", "type"})
+	S3BucketTrafficCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "SeaweedFS",
+			Subsystem: "s3",
+			Name:      "bucket_traffic_bytes_total",
+			Help:      "Counter of the bytes received and sent for each bucket and access key.",
+		}, []string{"bucket", "identity", "access_key", "type"})
+	S3BucketStorageGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "SeaweedFS",
+			Subsystem: "s3",
+			Name:      "bucket_storage_bytes",
+			Help:      "Bytes of the live data stored in each bucket.",
+		}, []string{"bucket", "owner"})
+
+	MasterCapacityGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "SeaweedFS",
+			Subsystem: "master",
+			Name:      "capacity_bytes",
+			Help:      "Logical, physical, and reclaimable bytes of the volumes in each collection.",
+		}, []string{"collection", "type"})
+
+	MasterGarbageRatioGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "SeaweedFS",
+			Subsystem: "master",
+			Name:      "garbage_ratio",
+			Help:      "Share of the physical bytes reclaimable by vacuum in each collection.",
+		}, []string{"collection"})
+
+	UploadStageHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "SeaweedFS",
+			Subsystem: "upload",
+			Name:      "stage_seconds",
+			Help:      "Bucketed histogram of the time spent in each stage of uploads.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 24),
+		}, []string{"stage"})
 )
 
 func init() {
 
 	Gather.MustRegister(FilerRequestCounter)
 	Gather.MustRegister(FilerRequestHistogram)
+	Gather.MustRegister(FilerDirectoryEntriesGauge)
+	Gather.MustRegister(FilerReconcileCounter)
+	Gather.MustRegister(FilerLifecycleCounter)
+	Gather.MustRegister(FilerInventoryCounter)
+	Gather.MustRegister(FilerTieredArchiveCounter)
+	Gather.MustRegister(FilerS3EventCounter)
 	Gather.MustRegister(FilerStoreCounter)
 	Gather.MustRegister(FilerStoreHistogram)
 	Gather.MustRegister(prometheus.NewGoCollector())
@@ -141,9 +249,18 @@ func init() {
 	Gather.MustRegister(VolumeServerReadOnlyVolumeGauge)
 	Gather.MustRegister(VolumeServerDiskSizeGauge)
 	Gather.MustRegister(VolumeServerResourceGauge)
+	Gather.MustRegister(VolumeServerCorruptNeedleCounter)
 
 	Gather.MustRegister(S3RequestCounter)
 	Gather.MustRegister(S3RequestHistogram)
+	Gather.MustRegister(S3BucketRequestCounter)
+	Gather.MustRegister(S3BucketTrafficCounter)
+	Gather.MustRegister(S3BucketStorageGauge)
+
+	Gather.MustRegister(MasterCapacityGauge)
+	Gather.MustRegister(MasterGarbageRatioGauge)
+
+	Gather.MustRegister(UploadStageHistogram)
 }
 
 func LoopPushingMetric(name, instance, addr string, intervalSeconds int) {
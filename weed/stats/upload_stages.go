@@ -0,0 +1,151 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServerTimingHeader returns the upload stages to the clients, in the format of
+// https://www.w3.org/TR/server-timing/, e.g. "assign;dur=1.25, upload;dur=20.5, commit;dur=3"
+const ServerTimingHeader = "Server-Timing"
+
+// ServerTimingRequestHeader opts in to the ServerTimingHeader in the response.
+// It is not returned by default, to not expose the internal timings to all the clients.
+// The filer always opts in when uploading to the volume servers, to tell the network time from the disk time.
+const ServerTimingRequestHeader = "X-Seaweedfs-Server-Timing"
+
+const (
+	UploadStageAuth      = "auth"      // s3 signature verification
+	UploadStageRead      = "read"      // reading the request body from the client
+	UploadStageAssign    = "assign"    // assigning file ids from the master
+	UploadStageUpload    = "upload"    // uploading the chunks to the volume servers, including disk and replicate
+	UploadStageNetwork   = "network"   // the chunk upload time not spent on the volume server disk or replicas
+	UploadStageDisk      = "disk"      // writing the needles to the local volume
+	UploadStageReplicate = "replicate" // waiting for the replicas to ack
+	UploadStageCommit    = "commit"    // saving the entry to the filer store
+)
+
+type uploadStagesKey struct{}
+
+type uploadStage struct {
+	name     string
+	duration time.Duration
+	isRemote bool
+}
+
+// UploadStages adds up the time spent in each stage of one upload request,
+// to tell whether a slow upload is bound by the master, the network, the disks, or the filer store.
+// The methods can be called on a nil *UploadStages, to not track the stages.
+type UploadStages struct {
+	sync.Mutex
+	stages []*uploadStage
+}
+
+func NewUploadStages() *UploadStages {
+	return &UploadStages{}
+}
+
+func WithUploadStages(ctx context.Context, stages *UploadStages) context.Context {
+	return context.WithValue(ctx, uploadStagesKey{}, stages)
+}
+
+// UploadStagesFromContext returns nil if the stages are not tracked for the request
+func UploadStagesFromContext(ctx context.Context) *UploadStages {
+	stages, _ := ctx.Value(uploadStagesKey{}).(*UploadStages)
+	return stages
+}
+
+// Since adds the time since start to the stage measured by this server.
+func (s *UploadStages) Since(name string, start time.Time) {
+	s.add(name, time.Since(start), false)
+}
+
+// Add adds to the stage measured by this server.
+func (s *UploadStages) Add(name string, d time.Duration) {
+	s.add(name, d, false)
+}
+
+// AddServerTiming adds the stages reported by upstream servers, and returns the total reported time.
+func (s *UploadStages) AddServerTiming(header string) (total time.Duration) {
+	for _, metric := range strings.Split(header, ",") {
+		parts := strings.Split(strings.TrimSpace(metric), ";")
+		name := strings.TrimSpace(parts[0])
+		if name == "" {
+			continue
+		}
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "dur=") {
+				continue
+			}
+			ms, err := strconv.ParseFloat(param[len("dur="):], 64)
+			if err != nil || ms < 0 {
+				continue
+			}
+			d := time.Duration(ms * float64(time.Millisecond))
+			s.add(name, d, true)
+			total += d
+		}
+	}
+	return
+}
+
+func (s *UploadStages) add(name string, d time.Duration, isRemote bool) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	for _, stage := range s.stages {
+		if stage.name == name {
+			stage.duration += d
+			stage.isRemote = stage.isRemote && isRemote
+			return
+		}
+	}
+	s.stages = append(s.stages, &uploadStage{name: name, duration: d, isRemote: isRemote})
+}
+
+// ServerTiming formats the stages in milliseconds, in the order first added.
+func (s *UploadStages) ServerTiming() string {
+	if s == nil {
+		return ""
+	}
+	s.Lock()
+	defer s.Unlock()
+	var metrics []string
+	for _, stage := range s.stages {
+		metrics = append(metrics, fmt.Sprintf("%s;dur=%.3f", stage.name, float64(stage.duration)/float64(time.Millisecond)))
+	}
+	return strings.Join(metrics, ", ")
+}
+
+// SetServerTiming returns the stages in the ServerTimingHeader, if the request opts in.
+func (s *UploadStages) SetServerTiming(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get(ServerTimingRequestHeader) == "" {
+		return
+	}
+	if timing := s.ServerTiming(); timing != "" {
+		w.Header().Set(ServerTimingHeader, timing)
+	}
+}
+
+// Observe records the stages measured by this server in the metrics.
+// The stages reported by upstream servers are recorded by themselves.
+func (s *UploadStages) Observe() {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	for _, stage := range s.stages {
+		if !stage.isRemote {
+			UploadStageHistogram.WithLabelValues(stage.name).Observe(stage.duration.Seconds())
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package stats
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestUploadStages(t *testing.T) {
+
+	var untracked *UploadStages
+	untracked.Add(UploadStageAssign, time.Second)
+	if untracked.ServerTiming() != "" || UploadStagesFromContext(context.Background()) != nil {
+		t.Fatalf("untracked stages should be empty")
+	}
+
+	stages := NewUploadStages()
+	stages.Add(UploadStageAssign, 2*time.Millisecond)
+	stages.Add(UploadStageUpload, 10*time.Millisecond)
+	total := stages.AddServerTiming("disk;dur=1.5, replicate;desc=\"replicas\";dur=6, bad;dur=x")
+	stages.Add(UploadStageAssign, 500*time.Microsecond)
+	if total != 7500*time.Microsecond {
+		t.Errorf("unexpected reported time %v", total)
+	}
+
+	expected := "assign;dur=2.500, upload;dur=10.000, disk;dur=1.500, replicate;dur=6.000"
+	if timing := stages.ServerTiming(); timing != expected {
+		t.Errorf("expected %q, but got %q", expected, timing)
+	}
+
+	ctx := WithUploadStages(context.Background(), stages)
+	if UploadStagesFromContext(ctx) != stages {
+		t.Errorf("stages not found in the context")
+	}
+}
+
+func TestSetServerTiming(t *testing.T) {
+
+	stages := NewUploadStages()
+	stages.Add(UploadStageCommit, 3*time.Millisecond)
+
+	// not returned by default
+	w := httptest.NewRecorder()
+	stages.SetServerTiming(w, httptest.NewRequest("PUT", "/a", nil))
+	if timing := w.Header().Get(ServerTimingHeader); timing != "" {
+		t.Errorf("unexpected %s: %q", ServerTimingHeader, timing)
+	}
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("PUT", "/a", nil)
+	r.Header.Set(ServerTimingRequestHeader, "true")
+	stages.SetServerTiming(w, r)
+	if timing := w.Header().Get(ServerTimingHeader); timing != "commit;dur=3.000" {
+		t.Errorf("unexpected %s: %q", ServerTimingHeader, timing)
+	}
+}
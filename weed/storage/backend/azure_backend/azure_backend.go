@@ -0,0 +1,210 @@
+package azure_backend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/google/uuid"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/chrislusf/seaweedfs/weed/storage/backend"
+)
+
+func init() {
+	backend.BackendStorageFactories["azure"] = &AzureBackendFactory{}
+}
+
+type AzureBackendFactory struct {
+}
+
+func (factory *AzureBackendFactory) StorageType() backend.StorageType {
+	return backend.StorageType("azure")
+}
+func (factory *AzureBackendFactory) BuildStorage(configuration backend.StringProperties, configPrefix string, id string) (backend.BackendStorage, error) {
+	return newAzureBackendStorage(configuration, configPrefix, id)
+}
+
+type AzureBackendStorage struct {
+	id           string
+	account_name string
+	account_key  string
+	container    string
+	containerURL azblob.ContainerURL
+}
+
+func newAzureBackendStorage(configuration backend.StringProperties, configPrefix string, id string) (s *AzureBackendStorage, err error) {
+	s = &AzureBackendStorage{}
+	s.id = id
+	s.account_name = configuration.GetString(configPrefix + "account_name")
+	s.account_key = configuration.GetString(configPrefix + "account_key")
+	s.container = configuration.GetString(configPrefix + "container")
+
+	credential, err := azblob.NewSharedKeyCredential(s.account_name, s.account_key)
+	if err != nil {
+		return nil, fmt.Errorf("create azure credential with account name %s: %v", s.account_name, err)
+	}
+	p := azblob.NewPipeline(credential, azblob.PipelineOptions{})
+	u, _ := url.Parse(fmt.Sprintf("https://%s.blob.core.windows.net", s.account_name))
+	s.containerURL = azblob.NewServiceURL(*u, p).NewContainerURL(s.container)
+
+	glog.V(0).Infof("created backend storage azure.%s for account %s container %s", s.id, s.account_name, s.container)
+	return
+}
+
+func (s *AzureBackendStorage) ToProperties() map[string]string {
+	m := make(map[string]string)
+	m["account_name"] = s.account_name
+	m["account_key"] = s.account_key
+	m["container"] = s.container
+	return m
+}
+
+func (s *AzureBackendStorage) NewStorageFile(key string, tierInfo *volume_server_pb.VolumeInfo) backend.BackendStorageFile {
+	if strings.HasPrefix(key, "/") {
+		key = key[1:]
+	}
+
+	f := &AzureBackendStorageFile{
+		backendStorage: s,
+		key:            key,
+		tierInfo:       tierInfo,
+	}
+
+	return f
+}
+
+func (s *AzureBackendStorage) CopyFile(f *os.File, attributes map[string]string, fn func(progressed int64, percentage float32) error) (key string, size int64, err error) {
+	randomUuid, _ := uuid.NewRandom()
+	key = randomUuid.String()
+
+	glog.V(1).Infof("copying dat file of %s to remote azure.%s as %s", f.Name(), s.id, key)
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to stat file %q, %v", f.Name(), err)
+	}
+	size = info.Size()
+
+	_, err = azblob.UploadFileToBlockBlob(context.Background(), f, s.containerURL.NewBlockBlobURL(key), azblob.UploadToBlockBlobOptions{
+		BlockSize:   4 * 1024 * 1024,
+		Parallelism: 16,
+		Metadata:    attributes,
+		Progress:    progressReceiver(size, fn),
+	})
+	if err != nil {
+		return "", 0, fmt.Errorf("upload %s to azure %s/%s: %v", f.Name(), s.container, key, err)
+	}
+
+	return
+}
+
+func (s *AzureBackendStorage) DownloadFile(fileName string, key string, fn func(progressed int64, percentage float32) error) (size int64, err error) {
+
+	glog.V(1).Infof("download dat file of %s from remote azure.%s as %s", fileName, s.id, key)
+
+	blobURL := s.containerURL.NewBlobURL(key)
+	props, err := blobURL.GetProperties(context.Background(), azblob.BlobAccessConditions{})
+	if err != nil {
+		return 0, fmt.Errorf("get azure %s/%s properties: %v", s.container, key, err)
+	}
+	size = props.ContentLength()
+
+	f, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open file %q, %v", fileName, err)
+	}
+	defer f.Close()
+
+	err = azblob.DownloadBlobToFile(context.Background(), blobURL, 0, size, f, azblob.DownloadFromBlobOptions{
+		BlockSize:   4 * 1024 * 1024,
+		Parallelism: 16,
+		Progress:    progressReceiver(size, fn),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to download file %s: %v", fileName, err)
+	}
+
+	return
+}
+
+func (s *AzureBackendStorage) DeleteFile(key string) (err error) {
+
+	glog.V(1).Infof("delete dat file %s from remote", key)
+
+	_, err = s.containerURL.NewBlobURL(key).Delete(context.Background(), azblob.DeleteSnapshotsOptionInclude, azblob.BlobAccessConditions{})
+
+	return
+}
+
+func progressReceiver(size int64, fn func(progressed int64, percentage float32) error) func(bytesTransferred int64) {
+	return func(bytesTransferred int64) {
+		if fn != nil && size > 0 {
+			fn(bytesTransferred, float32(bytesTransferred*100)/float32(size))
+		}
+	}
+}
+
+type AzureBackendStorageFile struct {
+	backendStorage *AzureBackendStorage
+	key            string
+	tierInfo       *volume_server_pb.VolumeInfo
+}
+
+func (azureBackendStorageFile AzureBackendStorageFile) ReadAt(p []byte, off int64) (n int, err error) {
+
+	resp, err := azureBackendStorageFile.backendStorage.containerURL.NewBlobURL(azureBackendStorageFile.key).Download(context.Background(), off, int64(len(p)), azblob.BlobAccessConditions{}, false)
+	if err != nil {
+		return 0, fmt.Errorf("container %s download %s: %v", azureBackendStorageFile.backendStorage.container, azureBackendStorageFile.key, err)
+	}
+	body := resp.Body(azblob.RetryReaderOptions{MaxRetryRequests: 3})
+	defer body.Close()
+
+	n, err = io.ReadFull(body, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+
+	return
+}
+
+func (azureBackendStorageFile AzureBackendStorageFile) WriteAt(p []byte, off int64) (n int, err error) {
+	panic("not implemented")
+}
+
+func (azureBackendStorageFile AzureBackendStorageFile) Truncate(off int64) error {
+	panic("not implemented")
+}
+
+func (azureBackendStorageFile AzureBackendStorageFile) Close() error {
+	return nil
+}
+
+func (azureBackendStorageFile AzureBackendStorageFile) GetStat() (datSize int64, modTime time.Time, err error) {
+
+	files := azureBackendStorageFile.tierInfo.GetFiles()
+
+	if len(files) == 0 {
+		err = fmt.Errorf("remote file info not found")
+		return
+	}
+
+	datSize = int64(files[0].FileSize)
+	modTime = time.Unix(int64(files[0].ModifiedTime), 0)
+
+	return
+}
+
+func (azureBackendStorageFile AzureBackendStorageFile) Name() string {
+	return azureBackendStorageFile.key
+}
+
+func (azureBackendStorageFile AzureBackendStorageFile) Sync() error {
+	return nil
+}
@@ -0,0 +1,245 @@
+// +build linux
+
+package backend
+
+import (
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+)
+
+/*
+With direct IO, volume .dat files are read and written with O_DIRECT, bypassing the page cache.
+
+O_DIRECT requires the file offset, the length, and the memory address to be aligned.
+Needles are only aligned to NeedlePaddingSize, so each read or write is widened to the
+aligned blocks covering it, using a pooled aligned buffer. For writes, the partially covered
+head and tail blocks are read first and merged.
+
+The aligned write past the end leaves a zero padded tail after the real size. The tail is
+overwritten by the next appends, and only truncated on Sync and Close, so the appends do not
+truncate the file each time. The reads stop at the real size. After a crash, the padded tail
+is truncated by the volume integrity check, as the other partial appends.
+*/
+
+const (
+	directIoAlignment = 4096
+	// the buffers up to directIoAlignment<<(directIoPoolClasses-1) bytes are pooled
+	directIoPoolClasses = 9
+)
+
+var (
+	directIoEnabled    bool
+	alignedBufferPools [directIoPoolClasses]sync.Pool
+)
+
+// EnableDirectIo opens volume .dat files with O_DIRECT from now on.
+func EnableDirectIo() error {
+	directIoEnabled = true
+	glog.V(0).Infof("volume files use direct io")
+	return nil
+}
+
+type directIoFile struct {
+	file       *os.File
+	size       int64 // the real size, read atomically
+	paddedSize int64 // the size on disk, with the padded tail
+	sync.Mutex       // for the writes and the truncates
+}
+
+func openDirectIo(f *os.File) *directIoFile {
+	if !directIoEnabled {
+		return nil
+	}
+	flags, err := unix.FcntlInt(f.Fd(), unix.F_GETFL, 0)
+	if err != nil {
+		glog.Warningf("direct io %s: %v", f.Name(), err)
+		return nil
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		glog.Warningf("direct io %s: %v", f.Name(), err)
+		return nil
+	}
+	flag := os.O_RDONLY
+	if flags&unix.O_ACCMODE != unix.O_RDONLY {
+		flag = os.O_RDWR
+	}
+	directFile, err := os.OpenFile(f.Name(), flag|unix.O_DIRECT, 0)
+	if err != nil {
+		glog.Warningf("direct io %s, fall back to buffered io: %v", f.Name(), err)
+		return nil
+	}
+	return &directIoFile{file: directFile, size: stat.Size(), paddedSize: stat.Size()}
+}
+
+func (d *directIoFile) ReadAt(p []byte, off int64) (n int, err error) {
+	size := atomic.LoadInt64(&d.size)
+	if off >= size {
+		return 0, io.EOF
+	}
+	end := off + int64(len(p))
+	if end > size {
+		end = size
+	}
+	start, stop := alignDown(off), alignUp(end)
+	buf, release := getAlignedBuffer(int(stop - start))
+	defer release()
+
+	m, err := d.readFull(buf, start)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	if int64(m) <= off-start {
+		return 0, io.EOF
+	}
+	if int64(m) > end-start {
+		m = int(end - start)
+	}
+	n = copy(p, buf[off-start:m])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (d *directIoFile) WriteAt(p []byte, off int64) (n int, err error) {
+	d.Lock()
+	defer d.Unlock()
+
+	size := atomic.LoadInt64(&d.size)
+	start, stop := alignDown(off), alignUp(off+int64(len(p)))
+	buf, release := getAlignedBuffer(int(stop - start))
+	defer release()
+	for i := range buf {
+		buf[i] = 0
+	}
+
+	// merge the partially overwritten head and tail blocks
+	if start < off && start < size {
+		if _, err = d.readFull(buf[:directIoAlignment], start); err != nil && err != io.EOF {
+			return 0, err
+		}
+	}
+	tail := stop - directIoAlignment
+	if off+int64(len(p)) < stop && tail < size && (tail > start || start == off) {
+		if _, err = d.readFull(buf[tail-start:], tail); err != nil && err != io.EOF {
+			return 0, err
+		}
+	}
+	copy(buf[off-start:], p)
+
+	if _, err = d.file.WriteAt(buf, start); err != nil {
+		return 0, err
+	}
+
+	if off+int64(len(p)) > size {
+		atomic.StoreInt64(&d.size, off+int64(len(p)))
+	}
+	if stop > d.paddedSize {
+		d.paddedSize = stop
+	}
+	return len(p), nil
+}
+
+// Truncate sets the real size, also dropping the padded tail
+func (d *directIoFile) Truncate(size int64) error {
+	d.Lock()
+	defer d.Unlock()
+
+	if err := d.file.Truncate(size); err != nil {
+		return err
+	}
+	atomic.StoreInt64(&d.size, size)
+	d.paddedSize = size
+	return nil
+}
+
+// Sync drops the padded tail, and syncs the file
+func (d *directIoFile) Sync() error {
+	d.Lock()
+	defer d.Unlock()
+
+	if err := d.trim(); err != nil {
+		return err
+	}
+	return d.file.Sync()
+}
+
+func (d *directIoFile) Close() error {
+	d.Lock()
+	defer d.Unlock()
+
+	if err := d.trim(); err != nil {
+		glog.Warningf("direct io %s: %v", d.file.Name(), err)
+	}
+	return d.file.Close()
+}
+
+func (d *directIoFile) trim() error {
+	size := atomic.LoadInt64(&d.size)
+	if d.paddedSize <= size {
+		return nil
+	}
+	if err := d.file.Truncate(size); err != nil {
+		return err
+	}
+	d.paddedSize = size
+	return nil
+}
+
+func (d *directIoFile) readFull(buf []byte, off int64) (n int, err error) {
+	for n < len(buf) {
+		m, err := d.file.ReadAt(buf[n:], off+int64(n))
+		n += m
+		if err != nil {
+			return n, err
+		}
+		if m == 0 {
+			return n, io.EOF
+		}
+	}
+	return n, nil
+}
+
+func alignDown(x int64) int64 {
+	return x / directIoAlignment * directIoAlignment
+}
+
+func alignUp(x int64) int64 {
+	return alignDown(x + directIoAlignment - 1)
+}
+
+// getAlignedBuffer returns an aligned buffer of the size, and the func to put it back to the pool
+func getAlignedBuffer(size int) (buf []byte, release func()) {
+	class := uint(0)
+	for directIoAlignment<<class < size {
+		class++
+	}
+	if class >= directIoPoolClasses {
+		return alignedBuffer(size), func() {}
+	}
+	pooled, _ := alignedBufferPools[class].Get().(*[]byte)
+	if pooled == nil {
+		b := alignedBuffer(directIoAlignment << class)
+		pooled = &b
+	}
+	return (*pooled)[:size], func() {
+		alignedBufferPools[class].Put(pooled)
+	}
+}
+
+func alignedBuffer(size int) []byte {
+	buf := make([]byte, size+directIoAlignment)
+	shift := 0
+	if remainder := int(uintptr(unsafe.Pointer(&buf[0])) % directIoAlignment); remainder != 0 {
+		shift = directIoAlignment - remainder
+	}
+	return buf[shift : shift+size]
+}
@@ -0,0 +1,108 @@
+// +build linux
+
+package backend
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func TestDirectIoReadWrite(t *testing.T) {
+	f, err := ioutil.TempFile("", "direct_io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	directIoEnabled = true
+	defer func() { directIoEnabled = false }()
+
+	df := NewDiskFile(f)
+	if df.directIo == nil {
+		t.Skipf("direct io not supported on %s", f.Name())
+	}
+
+	var expected []byte
+	for i := 0; i < 200; i++ {
+		data := make([]byte, 1+rand.Intn(3*directIoAlignment))
+		rand.Read(data)
+		// append at a padded offset, as volumes do, with an optional gap
+		off := int64(len(expected))
+		if rand.Intn(4) == 0 {
+			off += 8
+		}
+		if _, err := df.WriteAt(data, off); err != nil {
+			t.Fatalf("write %d bytes at %d: %v", len(data), off, err)
+		}
+		for int64(len(expected)) < off {
+			expected = append(expected, 0)
+		}
+		expected = append(expected, data...)
+
+		// the appends leave the padded tail, instead of truncating the file each time
+		if stat, _ := f.Stat(); stat.Size()%directIoAlignment != 0 || stat.Size() < int64(len(expected)) {
+			t.Fatalf("file size %d, expected the aligned size after %d", stat.Size(), len(expected))
+		}
+		if size, _, _ := df.GetStat(); size != int64(len(expected)) {
+			t.Fatalf("size %d, expected %d", size, len(expected))
+		}
+	}
+
+	for i := 0; i < 200; i++ {
+		off := rand.Int63n(int64(len(expected)))
+		p := make([]byte, rand.Intn(2*directIoAlignment))
+		n, err := df.ReadAt(p, off)
+		if err != nil && err != io.EOF {
+			t.Fatalf("read at %d: %v", off, err)
+		}
+		if !bytes.Equal(p[:n], expected[off:off+int64(n)]) {
+			t.Fatalf("read at %d: unexpected content", off)
+		}
+		if err == io.EOF && off+int64(len(p)) <= int64(len(expected)) {
+			t.Fatalf("read at %d: unexpected EOF", off)
+		}
+		if err == nil && off+int64(len(p)) > int64(len(expected)) {
+			t.Fatalf("read at %d: expecting EOF after the size %d", off, len(expected))
+		}
+	}
+
+	// the padded tail is dropped on sync
+	if err := df.Sync(); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if stat, _ := f.Stat(); stat.Size() != int64(len(expected)) {
+		t.Fatalf("file size %d after sync, expected %d", stat.Size(), len(expected))
+	}
+}
+
+func BenchmarkDirectIoAppend(b *testing.B) {
+	f, err := ioutil.TempFile("", "direct_io")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	directIoEnabled = true
+	defer func() { directIoEnabled = false }()
+
+	df := NewDiskFile(f)
+	if df.directIo == nil {
+		b.Skipf("direct io not supported on %s", f.Name())
+	}
+
+	data := make([]byte, 1000)
+	rand.Read(data)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := df.Write(data); err != nil {
+			b.Fatalf("append: %v", err)
+		}
+	}
+}
@@ -11,8 +11,17 @@ var (
 	_ BackendStorageFile = &DiskFile{}
 )
 
+// FileReaderAt reads the os file at the offset, in place of the default pread.
+type FileReaderAt interface {
+	ReadFileAt(f *os.File, p []byte, off int64) (n int, err error)
+}
+
+var diskFileReader FileReaderAt
+
 type DiskFile struct {
 	File         *os.File
+	directIo     *directIoFile
+	zoned        bool
 	fullFilePath string
 	fileSize     int64
 	modTime      time.Time
@@ -28,20 +37,38 @@ func NewDiskFile(f *os.File) *DiskFile {
 		offset = offset + (NeedlePaddingSize - offset%NeedlePaddingSize)
 	}
 
-	return &DiskFile{
+	df := &DiskFile{
 		fullFilePath: f.Name(),
 		File:         f,
+		zoned:        IsZonedFile(f.Name()),
 		fileSize:     offset,
 		modTime:      stat.ModTime(),
 	}
+	if !df.zoned {
+		df.directIo = openDirectIo(f)
+	}
+	return df
 }
 
 func (df *DiskFile) ReadAt(p []byte, off int64) (n int, err error) {
+	if df.directIo != nil {
+		return df.directIo.ReadAt(p, off)
+	}
+	if diskFileReader != nil {
+		return diskFileReader.ReadFileAt(df.File, p, off)
+	}
 	return df.File.ReadAt(p, off)
 }
 
 func (df *DiskFile) WriteAt(p []byte, off int64) (n int, err error) {
-	n, err = df.File.WriteAt(p, off)
+	if df.zoned && off < df.fileSize {
+		return 0, ErrZonedRewrite
+	}
+	if df.directIo != nil {
+		n, err = df.directIo.WriteAt(p, off)
+	} else {
+		n, err = df.File.WriteAt(p, off)
+	}
 	if err == nil {
 		waterMark := off + int64(n)
 		if waterMark > df.fileSize {
@@ -57,7 +84,16 @@ func (df *DiskFile) Write(p []byte) (n int, err error) {
 }
 
 func (df *DiskFile) Truncate(off int64) error {
-	err := df.File.Truncate(off)
+	// only the failed append after the end can be dropped
+	if df.zoned && off < df.fileSize {
+		return ErrZonedRewrite
+	}
+	var err error
+	if df.directIo != nil {
+		err = df.directIo.Truncate(off)
+	} else {
+		err = df.File.Truncate(off)
+	}
 	if err == nil {
 		df.fileSize = off
 		df.modTime = time.Now()
@@ -66,6 +102,9 @@ func (df *DiskFile) Truncate(off int64) error {
 }
 
 func (df *DiskFile) Close() error {
+	if df.directIo != nil {
+		df.directIo.Close()
+	}
 	return df.File.Close()
 }
 
@@ -78,5 +117,8 @@ func (df *DiskFile) Name() string {
 }
 
 func (df *DiskFile) Sync() error {
+	if df.directIo != nil {
+		return df.directIo.Sync()
+	}
 	return df.File.Sync()
 }
@@ -0,0 +1,194 @@
+package gcs_backend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/uuid"
+	"google.golang.org/api/option"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/chrislusf/seaweedfs/weed/storage/backend"
+)
+
+func init() {
+	backend.BackendStorageFactories["gcs"] = &GcsBackendFactory{}
+}
+
+type GcsBackendFactory struct {
+}
+
+func (factory *GcsBackendFactory) StorageType() backend.StorageType {
+	return backend.StorageType("gcs")
+}
+func (factory *GcsBackendFactory) BuildStorage(configuration backend.StringProperties, configPrefix string, id string) (backend.BackendStorage, error) {
+	return newGcsBackendStorage(configuration, configPrefix, id)
+}
+
+type GcsBackendStorage struct {
+	id                             string
+	google_application_credentials string
+	bucket                         string
+	client                         *storage.Client
+}
+
+func newGcsBackendStorage(configuration backend.StringProperties, configPrefix string, id string) (s *GcsBackendStorage, err error) {
+	s = &GcsBackendStorage{}
+	s.id = id
+	s.google_application_credentials = configuration.GetString(configPrefix + "google_application_credentials")
+	s.bucket = configuration.GetString(configPrefix + "bucket")
+
+	var opts []option.ClientOption
+	if s.google_application_credentials != "" {
+		opts = append(opts, option.WithCredentialsFile(s.google_application_credentials))
+	}
+	s.client, err = storage.NewClient(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("create gcs client: %v", err)
+	}
+
+	glog.V(0).Infof("created backend storage gcs.%s for bucket %s", s.id, s.bucket)
+	return
+}
+
+func (s *GcsBackendStorage) ToProperties() map[string]string {
+	m := make(map[string]string)
+	m["google_application_credentials"] = s.google_application_credentials
+	m["bucket"] = s.bucket
+	return m
+}
+
+func (s *GcsBackendStorage) NewStorageFile(key string, tierInfo *volume_server_pb.VolumeInfo) backend.BackendStorageFile {
+	if strings.HasPrefix(key, "/") {
+		key = key[1:]
+	}
+
+	f := &GcsBackendStorageFile{
+		backendStorage: s,
+		key:            key,
+		tierInfo:       tierInfo,
+	}
+
+	return f
+}
+
+func (s *GcsBackendStorage) CopyFile(f *os.File, attributes map[string]string, fn func(progressed int64, percentage float32) error) (key string, size int64, err error) {
+	randomUuid, _ := uuid.NewRandom()
+	key = randomUuid.String()
+
+	glog.V(1).Infof("copying dat file of %s to remote gcs.%s as %s", f.Name(), s.id, key)
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to stat file %q, %v", f.Name(), err)
+	}
+
+	wc := s.client.Bucket(s.bucket).Object(key).NewWriter(context.Background())
+	wc.Metadata = attributes
+	wc.StorageClass = "NEARLINE"
+
+	size, err = io.Copy(wc, &backend.ProgressedReader{Reader: io.NewSectionReader(f, 0, info.Size()), Size: info.Size(), Fn: fn})
+	if err != nil {
+		wc.Close()
+		return "", 0, fmt.Errorf("upload %s to gcs %s/%s: %v", f.Name(), s.bucket, key, err)
+	}
+	if err = wc.Close(); err != nil {
+		return "", 0, fmt.Errorf("upload %s to gcs %s/%s: %v", f.Name(), s.bucket, key, err)
+	}
+
+	return
+}
+
+func (s *GcsBackendStorage) DownloadFile(fileName string, key string, fn func(progressed int64, percentage float32) error) (size int64, err error) {
+
+	glog.V(1).Infof("download dat file of %s from remote gcs.%s as %s", fileName, s.id, key)
+
+	rc, err := s.client.Bucket(s.bucket).Object(key).NewReader(context.Background())
+	if err != nil {
+		return 0, fmt.Errorf("read gcs %s/%s: %v", s.bucket, key, err)
+	}
+	defer rc.Close()
+
+	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open file %q, %v", fileName, err)
+	}
+	defer f.Close()
+
+	size, err = io.Copy(f, &backend.ProgressedReader{Reader: rc, Size: rc.Attrs.Size, Fn: fn})
+	if err != nil {
+		return size, fmt.Errorf("failed to download file %s: %v", fileName, err)
+	}
+
+	return
+}
+
+func (s *GcsBackendStorage) DeleteFile(key string) (err error) {
+
+	glog.V(1).Infof("delete dat file %s from remote", key)
+
+	return s.client.Bucket(s.bucket).Object(key).Delete(context.Background())
+}
+
+type GcsBackendStorageFile struct {
+	backendStorage *GcsBackendStorage
+	key            string
+	tierInfo       *volume_server_pb.VolumeInfo
+}
+
+func (gcsBackendStorageFile GcsBackendStorageFile) ReadAt(p []byte, off int64) (n int, err error) {
+
+	rc, err := gcsBackendStorageFile.backendStorage.client.Bucket(gcsBackendStorageFile.backendStorage.bucket).Object(gcsBackendStorageFile.key).NewRangeReader(context.Background(), off, int64(len(p)))
+	if err != nil {
+		return 0, fmt.Errorf("bucket %s read %s: %v", gcsBackendStorageFile.backendStorage.bucket, gcsBackendStorageFile.key, err)
+	}
+	defer rc.Close()
+
+	n, err = io.ReadFull(rc, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+
+	return
+}
+
+func (gcsBackendStorageFile GcsBackendStorageFile) WriteAt(p []byte, off int64) (n int, err error) {
+	panic("not implemented")
+}
+
+func (gcsBackendStorageFile GcsBackendStorageFile) Truncate(off int64) error {
+	panic("not implemented")
+}
+
+func (gcsBackendStorageFile GcsBackendStorageFile) Close() error {
+	return nil
+}
+
+func (gcsBackendStorageFile GcsBackendStorageFile) GetStat() (datSize int64, modTime time.Time, err error) {
+
+	files := gcsBackendStorageFile.tierInfo.GetFiles()
+
+	if len(files) == 0 {
+		err = fmt.Errorf("remote file info not found")
+		return
+	}
+
+	datSize = int64(files[0].FileSize)
+	modTime = time.Unix(int64(files[0].ModifiedTime), 0)
+
+	return
+}
+
+func (gcsBackendStorageFile GcsBackendStorageFile) Name() string {
+	return gcsBackendStorageFile.key
+}
+
+func (gcsBackendStorageFile GcsBackendStorageFile) Sync() error {
+	return nil
+}
@@ -0,0 +1,299 @@
+// +build linux
+
+package backend
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+)
+
+/*
+A minimal io_uring reader for volume .dat files.
+
+All reads are queued to one ring. A single loop goroutine batches the queued reads
+into one io_uring_enter() call, and waits for their completions. The callers only park
+their goroutines, instead of each blocking an OS thread in pread().
+
+On kernels without io_uring, EnableIoUring() returns an error, and reads keep using pread.
+*/
+
+const (
+	ioringOpReadv        = 1
+	ioringEnterGetEvents = 1
+	ioringOffSqRing      = 0
+	ioringOffCqRing      = 0x8000000
+	ioringOffSqes        = 0x10000000
+)
+
+type ioSqringOffsets struct {
+	head        uint32
+	tail        uint32
+	ringMask    uint32
+	ringEntries uint32
+	flags       uint32
+	dropped     uint32
+	array       uint32
+	resv1       uint32
+	resv2       uint64
+}
+
+type ioCqringOffsets struct {
+	head        uint32
+	tail        uint32
+	ringMask    uint32
+	ringEntries uint32
+	overflow    uint32
+	cqes        uint32
+	flags       uint32
+	resv1       uint32
+	resv2       uint64
+}
+
+type ioUringParams struct {
+	sqEntries    uint32
+	cqEntries    uint32
+	flags        uint32
+	sqThreadCpu  uint32
+	sqThreadIdle uint32
+	features     uint32
+	wqFd         uint32
+	resv         [3]uint32
+	sqOff        ioSqringOffsets
+	cqOff        ioCqringOffsets
+}
+
+type ioUringSqe struct {
+	opcode      uint8
+	flags       uint8
+	ioprio      uint16
+	fd          int32
+	off         uint64
+	addr        uint64
+	len         uint32
+	rwFlags     uint32
+	userData    uint64
+	bufIndex    uint16
+	personality uint16
+	spliceFdIn  int32
+	pad2        [2]uint64
+}
+
+type ioUringCqe struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+type ioUringRead struct {
+	fd     int32
+	iovec  unix.Iovec
+	offset int64
+	doneCh chan int32
+}
+
+type ioUring struct {
+	fd        int
+	sqRing    []byte
+	cqRing    []byte
+	sqesMem   []byte
+	sqHead    *uint32
+	sqTail    *uint32
+	sqMask    uint32
+	sqArray   []uint32
+	sqes      []ioUringSqe
+	cqHead    *uint32
+	cqTail    *uint32
+	cqMask    uint32
+	cqes      []ioUringCqe
+	entries   uint32
+	requestCh chan *ioUringRead
+	inflight  map[uint64]*ioUringRead
+	nextId    uint64
+	closeOnce sync.Once
+}
+
+func newIoUring(entries uint32) (*ioUring, error) {
+	params := &ioUringParams{}
+	fd, _, errno := unix.Syscall(unix.SYS_IO_URING_SETUP, uintptr(entries), uintptr(unsafe.Pointer(params)), 0)
+	if errno != 0 {
+		return nil, fmt.Errorf("io_uring_setup: %v", errno)
+	}
+
+	ring := &ioUring{
+		fd:        int(fd),
+		entries:   params.sqEntries,
+		requestCh: make(chan *ioUringRead, params.sqEntries*4),
+		inflight:  make(map[uint64]*ioUringRead),
+	}
+
+	var err error
+	sqRingSize := int(params.sqOff.array + params.sqEntries*4)
+	if ring.sqRing, err = unix.Mmap(ring.fd, ioringOffSqRing, sqRingSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE); err != nil {
+		ring.close()
+		return nil, fmt.Errorf("mmap sq ring: %v", err)
+	}
+	cqRingSize := int(params.cqOff.cqes + params.cqEntries*uint32(unsafe.Sizeof(ioUringCqe{})))
+	if ring.cqRing, err = unix.Mmap(ring.fd, ioringOffCqRing, cqRingSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE); err != nil {
+		ring.close()
+		return nil, fmt.Errorf("mmap cq ring: %v", err)
+	}
+	sqesSize := int(params.sqEntries * uint32(unsafe.Sizeof(ioUringSqe{})))
+	if ring.sqesMem, err = unix.Mmap(ring.fd, ioringOffSqes, sqesSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE); err != nil {
+		ring.close()
+		return nil, fmt.Errorf("mmap sqes: %v", err)
+	}
+
+	ring.sqHead = (*uint32)(unsafe.Pointer(&ring.sqRing[params.sqOff.head]))
+	ring.sqTail = (*uint32)(unsafe.Pointer(&ring.sqRing[params.sqOff.tail]))
+	ring.sqMask = *(*uint32)(unsafe.Pointer(&ring.sqRing[params.sqOff.ringMask]))
+	ring.sqArray = (*[1 << 20]uint32)(unsafe.Pointer(&ring.sqRing[params.sqOff.array]))[:params.sqEntries:params.sqEntries]
+	ring.sqes = (*[1 << 16]ioUringSqe)(unsafe.Pointer(&ring.sqesMem[0]))[:params.sqEntries:params.sqEntries]
+	ring.cqHead = (*uint32)(unsafe.Pointer(&ring.cqRing[params.cqOff.head]))
+	ring.cqTail = (*uint32)(unsafe.Pointer(&ring.cqRing[params.cqOff.tail]))
+	ring.cqMask = *(*uint32)(unsafe.Pointer(&ring.cqRing[params.cqOff.ringMask]))
+	ring.cqes = (*[1 << 20]ioUringCqe)(unsafe.Pointer(&ring.cqRing[params.cqOff.cqes]))[:params.cqEntries:params.cqEntries]
+
+	go ring.loop()
+
+	return ring, nil
+}
+
+func (ring *ioUring) ReadFileAt(f *os.File, p []byte, off int64) (n int, err error) {
+	for n < len(p) {
+		req := &ioUringRead{
+			fd:     int32(f.Fd()),
+			offset: off + int64(n),
+			doneCh: make(chan int32, 1),
+		}
+		req.iovec.Base = &p[n]
+		req.iovec.SetLen(len(p) - n)
+		ring.requestCh <- req
+		res := <-req.doneCh
+		runtime.KeepAlive(f)
+		if res < 0 {
+			return n, &os.PathError{Op: "read", Path: f.Name(), Err: syscall.Errno(-res)}
+		}
+		if res == 0 {
+			return n, io.EOF
+		}
+		n += int(res)
+	}
+	return n, nil
+}
+
+func (ring *ioUring) loop() {
+	runtime.LockOSThread()
+	for {
+		// wait for at least one request if nothing is in flight
+		if len(ring.inflight) == 0 {
+			req, ok := <-ring.requestCh
+			if !ok {
+				return
+			}
+			ring.queue(req)
+		}
+
+		// batch up as many queued requests as the submission queue can take
+		for len(ring.inflight) < int(ring.entries) {
+			drained := false
+			select {
+			case req, ok := <-ring.requestCh:
+				if !ok {
+					return
+				}
+				ring.queue(req)
+			default:
+				drained = true
+			}
+			if drained {
+				break
+			}
+		}
+
+		toSubmit := atomic.LoadUint32(ring.sqTail) - atomic.LoadUint32(ring.sqHead)
+		_, _, errno := unix.Syscall6(unix.SYS_IO_URING_ENTER, uintptr(ring.fd), uintptr(toSubmit), 1, ioringEnterGetEvents, 0, 0)
+		if errno != 0 && errno != syscall.EINTR && errno != syscall.EAGAIN && errno != syscall.EBUSY {
+			glog.Errorf("io_uring_enter: %v", errno)
+			ring.failAll(int32(-errno))
+			continue
+		}
+
+		ring.reap()
+	}
+}
+
+func (ring *ioUring) queue(req *ioUringRead) {
+	ring.nextId++
+	ring.inflight[ring.nextId] = req
+
+	tail := atomic.LoadUint32(ring.sqTail)
+	index := tail & ring.sqMask
+	ring.sqes[index] = ioUringSqe{
+		opcode:   ioringOpReadv,
+		fd:       req.fd,
+		off:      uint64(req.offset),
+		addr:     uint64(uintptr(unsafe.Pointer(&req.iovec))),
+		len:      1,
+		userData: ring.nextId,
+	}
+	ring.sqArray[index] = index
+	atomic.StoreUint32(ring.sqTail, tail+1)
+}
+
+func (ring *ioUring) reap() {
+	head := atomic.LoadUint32(ring.cqHead)
+	tail := atomic.LoadUint32(ring.cqTail)
+	for ; head != tail; head++ {
+		cqe := ring.cqes[head&ring.cqMask]
+		if req, found := ring.inflight[cqe.userData]; found {
+			delete(ring.inflight, cqe.userData)
+			req.doneCh <- cqe.res
+		}
+	}
+	atomic.StoreUint32(ring.cqHead, head)
+}
+
+func (ring *ioUring) failAll(res int32) {
+	for id, req := range ring.inflight {
+		delete(ring.inflight, id)
+		req.doneCh <- res
+	}
+	// drop the unsubmitted entries
+	atomic.StoreUint32(ring.sqTail, atomic.LoadUint32(ring.sqHead))
+}
+
+func (ring *ioUring) close() {
+	ring.closeOnce.Do(func() {
+		if ring.sqesMem != nil {
+			unix.Munmap(ring.sqesMem)
+		}
+		if ring.cqRing != nil {
+			unix.Munmap(ring.cqRing)
+		}
+		if ring.sqRing != nil {
+			unix.Munmap(ring.sqRing)
+		}
+		unix.Close(ring.fd)
+	})
+}
+
+// EnableIoUring switches volume file reads to io_uring.
+func EnableIoUring(entries uint32) error {
+	ring, err := newIoUring(entries)
+	if err != nil {
+		return err
+	}
+	diskFileReader = ring
+	glog.V(0).Infof("volume file reads use io_uring with %d entries", ring.entries)
+	return nil
+}
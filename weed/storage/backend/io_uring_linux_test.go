@@ -0,0 +1,58 @@
+// +build linux
+
+package backend
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestIoUringReadFileAt(t *testing.T) {
+	ring, err := newIoUring(8)
+	if err != nil {
+		t.Skipf("io_uring not supported: %v", err)
+	}
+	defer ring.close()
+
+	f, err := ioutil.TempFile("", "io_uring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	data := make([]byte, 64*1024)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	f.Write(data)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			buf := make([]byte, 1000)
+			off := int64(i * 1000)
+			n, err := ring.ReadFileAt(f, buf, off)
+			if err != nil || n != len(buf) {
+				t.Errorf("read at %d: %d %v", off, n, err)
+				return
+			}
+			if !bytes.Equal(buf, data[off:off+1000]) {
+				t.Errorf("read at %d: unexpected content", off)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	buf := make([]byte, 100)
+	n, err := ring.ReadFileAt(f, buf, int64(len(data)-10))
+	if n != 10 || err != io.EOF {
+		t.Errorf("read past end: %d %v", n, err)
+	}
+}
@@ -0,0 +1,10 @@
+// +build !linux
+
+package backend
+
+import "fmt"
+
+// EnableIoUring switches volume file reads to io_uring.
+func EnableIoUring(entries uint32) error {
+	return fmt.Errorf("io_uring is only supported on linux")
+}
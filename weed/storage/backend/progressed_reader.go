@@ -0,0 +1,26 @@
+package backend
+
+import "io"
+
+// ProgressedReader reports the progress of copying a volume file to or from a remote storage.
+type ProgressedReader struct {
+	Reader io.Reader
+	Size   int64
+	Fn     func(progressed int64, percentage float32) error
+	read   int64
+}
+
+func (r *ProgressedReader) Read(p []byte) (n int, err error) {
+	n, err = r.Reader.Read(p)
+	r.read += int64(n)
+	if r.Fn != nil && n > 0 {
+		var percentage float32
+		if r.Size > 0 {
+			percentage = float32(r.read*100) / float32(r.Size)
+		}
+		if fnErr := r.Fn(r.read, percentage); fnErr != nil {
+			return n, fnErr
+		}
+	}
+	return
+}
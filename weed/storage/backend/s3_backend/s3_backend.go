@@ -134,16 +134,9 @@ func (s3backendStorageFile S3BackendStorageFile) ReadAt(p []byte, off int64) (n
 	glog.V(4).Infof("read %s %s", s3backendStorageFile.key, bytesRange)
 	glog.V(4).Infof("content range: %s, contentLength: %d", *getObjectOutput.ContentRange, *getObjectOutput.ContentLength)
 
-	for {
-		if n, err = getObjectOutput.Body.Read(p); err == nil && n < len(p) {
-			p = p[n:]
-		} else {
-			break
-		}
-	}
-
-	if err == io.EOF {
-		err = nil
+	n, err = io.ReadFull(getObjectOutput.Body, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
 	}
 
 	return
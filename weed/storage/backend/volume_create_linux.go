@@ -14,7 +14,8 @@ func CreateVolumeFile(fileName string, preallocate int64, memoryMapSizeMB uint32
 	if e != nil {
 		return nil, e
 	}
-	if preallocate != 0 {
+	// the file systems on zoned devices do not preallocate the sequential zones
+	if preallocate != 0 && !IsZonedFile(fileName) {
 		syscall.Fallocate(int(file.Fd()), 1, 0, preallocate)
 		glog.V(1).Infof("Preallocated %d bytes disk space for %s", preallocate, fileName)
 	}
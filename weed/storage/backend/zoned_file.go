@@ -0,0 +1,52 @@
+package backend
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+)
+
+/*
+Zoned devices, the host-managed SMR drives and the ZNS SSDs, only write each zone sequentially,
+and reset a whole zone before writing it again. They are used with a zoned file system, e.g. f2fs or btrfs in zoned mode.
+
+The volume .dat files in a zoned directory are append only. Writing before the end of the file,
+or truncating the file except dropping a failed append, is rejected instead of rewriting in place.
+The files are not preallocated, and not opened with direct io, which rewrites the partially written blocks.
+The vacuum writes the compacted volume to new files, and truncates the replaced .dat file to reset its zones right away.
+
+The .ecx index of erasure coded volumes is still updated in place for deletions,
+so it is better to keep the index files on a conventional disk with -dir.idx.
+*/
+
+var ErrZonedRewrite = errors.New("zoned volume file can only be appended")
+
+var (
+	zonedDirectories     = make(map[string]bool)
+	zonedDirectoriesLock sync.RWMutex
+)
+
+// SetZonedDirectory marks the volume files in the directory as on a zoned device, before loading the volumes.
+func SetZonedDirectory(dir string) {
+	zonedDirectoriesLock.Lock()
+	defer zonedDirectoriesLock.Unlock()
+	zonedDirectories[filepath.Clean(dir)] = true
+	glog.V(0).Infof("volume files in %s are append only for zoned devices", dir)
+}
+
+func IsZonedFile(fileName string) bool {
+	zonedDirectoriesLock.RLock()
+	defer zonedDirectoriesLock.RUnlock()
+	return zonedDirectories[filepath.Dir(fileName)]
+}
+
+// ReleaseZones truncates the replaced volume file, still opened after being renamed over,
+// so the file system resets its zones without waiting for the file to be closed by all readers.
+func ReleaseZones(f *os.File) {
+	if err := f.Truncate(0); err != nil {
+		glog.Warningf("release zones of replaced %s: %v", f.Name(), err)
+	}
+}
@@ -0,0 +1,54 @@
+package backend
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestZonedFileAppendOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zoned")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	SetZonedDirectory(dir)
+	defer func() {
+		zonedDirectoriesLock.Lock()
+		delete(zonedDirectories, filepath.Clean(dir))
+		zonedDirectoriesLock.Unlock()
+	}()
+
+	f, err := os.Create(filepath.Join(dir, "1.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	df := NewDiskFile(f)
+	defer df.Close()
+
+	if _, err = df.WriteAt(make([]byte, 16), 0); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if _, err = df.Write(make([]byte, 16)); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if _, err = df.WriteAt(make([]byte, 8), 8); err != ErrZonedRewrite {
+		t.Errorf("rewrite in place: %v", err)
+	}
+	if err = df.Truncate(16); err != ErrZonedRewrite {
+		t.Errorf("truncate in place: %v", err)
+	}
+	if err = df.Truncate(32); err != nil {
+		t.Errorf("drop the failed append: %v", err)
+	}
+	if size, _, _ := df.GetStat(); size != 32 {
+		t.Errorf("size %d, expected 32", size)
+	}
+
+	ReleaseZones(df.File)
+	if stat, _ := os.Stat(f.Name()); stat.Size() != 0 {
+		t.Errorf("released file size %d", stat.Size())
+	}
+}
@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// DiskIoLimit is the bandwidth and IOPS ceilings of one kind of traffic on a disk location, 0 means no limit.
+type DiskIoLimit struct {
+	ReadBytesPerSecond  int64
+	WriteBytesPerSecond int64
+	ReadIops            int64
+	WriteIops           int64
+}
+
+// DiskIoLimits caps the client traffic, and the background tasks as vacuum, erasure coding, volume copying
+// and scrubbing, independently.
+type DiskIoLimits struct {
+	Client     DiskIoLimit
+	Background DiskIoLimit
+}
+
+// ParseDiskIoLimits parses the colon separated <key>=<value> of one disk location,
+// e.g. "readMBps=200:writeMBps=100:writeIops=1000:backgroundWriteMBps=20".
+// The keys are readMBps, writeMBps, readIops, writeIops, and the same with the "background" prefix.
+func ParseDiskIoLimits(s string) (*DiskIoLimits, error) {
+	limits := &DiskIoLimits{}
+	for _, part := range strings.Split(s, ":") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("expecting <key>=<value>, but got %q", part)
+		}
+		value, err := strconv.ParseInt(strings.TrimSpace(kv[1]), 10, 64)
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("invalid value in %q", part)
+		}
+		key := strings.TrimSpace(kv[0])
+		limit := &limits.Client
+		if strings.HasPrefix(key, "background") && len(key) > len("background") {
+			limit = &limits.Background
+			key = strings.TrimPrefix(key, "background")
+			key = strings.ToLower(key[:1]) + key[1:]
+		}
+		switch key {
+		case "readMBps":
+			limit.ReadBytesPerSecond = value * 1024 * 1024
+		case "writeMBps":
+			limit.WriteBytesPerSecond = value * 1024 * 1024
+		case "readIops":
+			limit.ReadIops = value
+		case "writeIops":
+			limit.WriteIops = value
+		default:
+			return nil, fmt.Errorf("unknown io limit %q", kv[0])
+		}
+	}
+	return limits, nil
+}
+
+// BackgroundIo is the kind of io a background task does on the disk
+type BackgroundIo int
+
+const (
+	BackgroundRead BackgroundIo = 1 << iota
+	BackgroundWrite
+	BackgroundReadWrite = BackgroundRead | BackgroundWrite
+)
+
+// diskIoLimiter applies one DiskIoLimit. A nil *diskIoLimiter has no limit.
+type diskIoLimiter struct {
+	readBytes  *util.RateLimiter
+	writeBytes *util.RateLimiter
+	readOps    *util.RateLimiter
+	writeOps   *util.RateLimiter
+}
+
+func newDiskIoLimiter(limit DiskIoLimit) *diskIoLimiter {
+	if limit == (DiskIoLimit{}) {
+		return nil
+	}
+	return &diskIoLimiter{
+		readBytes:  util.NewRateLimiter(limit.ReadBytesPerSecond),
+		writeBytes: util.NewRateLimiter(limit.WriteBytesPerSecond),
+		readOps:    util.NewRateLimiter(limit.ReadIops),
+		writeOps:   util.NewRateLimiter(limit.WriteIops),
+	}
+}
+
+func (l *diskIoLimiter) read(bytes int64) {
+	if l == nil {
+		return
+	}
+	l.readOps.Wait(1)
+	l.readBytes.Wait(bytes)
+}
+
+func (l *diskIoLimiter) write(bytes int64) {
+	if l == nil {
+		return
+	}
+	l.writeOps.Wait(1)
+	l.writeBytes.Wait(bytes)
+}
+
+func (l *DiskLocation) SetIoLimits(limits *DiskIoLimits) {
+	if limits == nil {
+		limits = &DiskIoLimits{}
+	}
+	l.clientIo = newDiskIoLimiter(limits.Client)
+	l.backgroundIo = newDiskIoLimiter(limits.Background)
+}
+
+// backgroundThrottler limits a background task by the bytesPerSecond of the task, and the background io limits of the disk.
+func (l *DiskLocation) backgroundThrottler(bytesPerSecond int64, kind BackgroundIo) *util.WriteThrottler {
+	throttler := util.NewWriteThrottler(bytesPerSecond)
+	if l == nil || l.backgroundIo == nil {
+		return throttler
+	}
+	limiter := l.backgroundIo
+	return throttler.WithLimiter(func(delta int64) {
+		if kind&BackgroundRead != 0 {
+			limiter.read(delta)
+		}
+		if kind&BackgroundWrite != 0 {
+			limiter.write(delta)
+		}
+	})
+}
+
+// SetIoLimits sets the io limits of each disk location, in the order of the locations.
+func (s *Store) SetIoLimits(limits []*DiskIoLimits) {
+	for i, location := range s.Locations {
+		if i < len(limits) {
+			location.SetIoLimits(limits[i])
+		}
+	}
+}
+
+// BackgroundThrottler limits a background task on the file, by the bytesPerSecond of the task,
+// and the background io limits of the disk location of the file.
+func (s *Store) BackgroundThrottler(fileName string, bytesPerSecond int64, kind BackgroundIo) *util.WriteThrottler {
+	dir := filepath.Dir(fileName)
+	for _, location := range s.Locations {
+		if dir == location.Directory || dir == location.IdxDirectory {
+			return location.backgroundThrottler(bytesPerSecond, kind)
+		}
+	}
+	return util.NewWriteThrottler(bytesPerSecond)
+}
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"testing"
+)
+
+func TestParseDiskIoLimits(t *testing.T) {
+	limits, err := ParseDiskIoLimits("readMBps=200: writeIops=1000:backgroundWriteMBps=20:backgroundReadIops=50")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	expected := DiskIoLimits{
+		Client:     DiskIoLimit{ReadBytesPerSecond: 200 * 1024 * 1024, WriteIops: 1000},
+		Background: DiskIoLimit{WriteBytesPerSecond: 20 * 1024 * 1024, ReadIops: 50},
+	}
+	if *limits != expected {
+		t.Errorf("expected %+v, but got %+v", expected, *limits)
+	}
+
+	if limits, err = ParseDiskIoLimits(""); err != nil || *limits != (DiskIoLimits{}) {
+		t.Errorf("empty limits: %+v, %v", limits, err)
+	}
+	for _, s := range []string{"readMBps", "readMBps=-1", "readMbps=1", "backgroundFoo=1", "background=1", "=1"} {
+		if _, err = ParseDiskIoLimits(s); err == nil {
+			t.Errorf("invalid limits %q is not rejected", s)
+		}
+	}
+}
+
+func TestDiskLocationIoLimits(t *testing.T) {
+	l := &DiskLocation{}
+	l.SetIoLimits(&DiskIoLimits{Background: DiskIoLimit{WriteIops: 10}})
+	if l.clientIo != nil || l.backgroundIo == nil || l.backgroundIo.writeOps == nil || l.backgroundIo.readOps != nil {
+		t.Fatalf("unexpected limiters %+v %+v", l.clientIo, l.backgroundIo)
+	}
+
+	// no limits on a location without io limits
+	var nilLocation *DiskLocation
+	nilLocation.backgroundThrottler(0, BackgroundReadWrite).MaybeSlowdown(1 << 30)
+	(&DiskLocation{}).clientIo.read(1 << 30)
+}
@@ -32,6 +32,15 @@ type DiskLocation struct {
 	ecVolumesLock sync.RWMutex
 
 	isDiskSpaceLow bool
+
+	// io limits, nil if not limited
+	clientIo     *diskIoLimiter
+	backgroundIo *diskIoLimiter
+
+	// disk failure
+	isFailed         int32
+	isCheckingHealth int32
+	onFailure        func(location *DiskLocation, volumes map[needle.VolumeId]*Volume, ecVolumes map[needle.VolumeId]*erasure_coding.EcVolume)
 }
 
 func NewDiskLocation(dir string, maxVolumeCount int, minFreeSpacePercent float32, idxDir string, diskType types.DiskType) *DiskLocation {
@@ -241,6 +250,9 @@ func (l *DiskLocation) deleteVolumeById(vid needle.VolumeId) (found bool, e erro
 }
 
 func (l *DiskLocation) LoadVolume(vid needle.VolumeId, needleMapKind NeedleMapKind) bool {
+	if l.IsFailed() {
+		return false
+	}
 	if fileInfo, found := l.LocateVolume(vid); found {
 		return l.loadExistingVolume(fileInfo, needleMapKind)
 	}
@@ -371,6 +383,9 @@ func (l *DiskLocation) CheckDiskSpace() {
 			}
 		}
 		time.Sleep(time.Minute)
+		if l.checkHealth(); l.IsFailed() {
+			return
+		}
 	}
 
 }
@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/master_pb"
+	"github.com/chrislusf/seaweedfs/weed/stats"
+	"github.com/chrislusf/seaweedfs/weed/storage/erasure_coding"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+)
+
+/*
+A disk location fails when its disk can not be written or read any more, e.g. a dead disk, or a disk remounted read only.
+
+An io error of a volume triggers a health check of its disk location in the background,
+which writes, syncs, reads back and removes a small file in the data and index directories.
+The health check also runs periodically, together with the disk space check.
+An io error alone, e.g. from a bad sector, does not fail the location.
+
+When the health check fails, the volumes and ec shards on the location are dropped from the location,
+without closing the files, and reported to the master as deleted.
+So the master stops assigning writes and locating reads to them, and the volume server continues serving the other disks.
+A failed location stays failed until the volume server is restarted.
+*/
+
+const diskHealthCheckFileName = ".seaweedfs_disk_check"
+
+func isIoError(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, syscall.EIO) || errors.Is(err, syscall.EROFS) || strings.Contains(err.Error(), "input/output error")
+}
+
+func (l *DiskLocation) IsFailed() bool {
+	return atomic.LoadInt32(&l.isFailed) == 1
+}
+
+// checkIoError starts a health check of the location in the background, if the err is an io error.
+func (l *DiskLocation) checkIoError(err error) {
+	if l == nil || !isIoError(err) || l.IsFailed() {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&l.isCheckingHealth, 0, 1) {
+		return
+	}
+	glog.Warningf("disk location %s has io error: %v", l.Directory, err)
+	go func() {
+		defer atomic.StoreInt32(&l.isCheckingHealth, 0)
+		l.checkHealth()
+	}()
+}
+
+// checkHealth fails the location if its directories can not be written and read back.
+func (l *DiskLocation) checkHealth() error {
+	if l.IsFailed() {
+		return fmt.Errorf("disk location %s is failed", l.Directory)
+	}
+	dirs := []string{l.Directory}
+	if l.IdxDirectory != l.Directory {
+		dirs = append(dirs, l.IdxDirectory)
+	}
+	for _, dir := range dirs {
+		if err := checkDirHealth(dir); err != nil {
+			err = fmt.Errorf("check %s: %v", dir, err)
+			l.markFailed(err)
+			return err
+		}
+	}
+	return nil
+}
+
+func checkDirHealth(dir string) error {
+	fileName := filepath.Join(dir, diskHealthCheckFileName)
+	f, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(fileName)
+	defer f.Close()
+
+	data := []byte(time.Now().String())
+	if _, err = f.Write(data); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	readBack := make([]byte, len(data))
+	if _, err = f.ReadAt(readBack, 0); err != nil {
+		return err
+	}
+	if !bytes.Equal(data, readBack) {
+		return fmt.Errorf("read back %q, expecting %q", readBack, data)
+	}
+	return nil
+}
+
+// markFailed drops the volumes and ec shards from the failed location, and reports them by the onFailure.
+func (l *DiskLocation) markFailed(err error) {
+	if !atomic.CompareAndSwapInt32(&l.isFailed, 0, 1) {
+		return
+	}
+	glog.Errorf("disk location %s failed, stop serving its volumes: %v", l.Directory, err)
+	stats.VolumeServerResourceGauge.WithLabelValues(l.Directory, "failed").Set(1)
+
+	l.volumesLock.Lock()
+	volumes := l.volumes
+	l.volumes = make(map[needle.VolumeId]*Volume)
+	l.MaxVolumeCount = 0
+	l.volumesLock.Unlock()
+
+	l.ecVolumesLock.Lock()
+	ecVolumes := l.ecVolumes
+	l.ecVolumes = make(map[needle.VolumeId]*erasure_coding.EcVolume)
+	l.ecVolumesLock.Unlock()
+
+	if l.onFailure != nil {
+		l.onFailure(l, volumes, ecVolumes)
+	}
+}
+
+// reportFailedLocation reports the volumes and ec shards on the failed location to the master as deleted.
+func (s *Store) reportFailedLocation(location *DiskLocation, volumes map[needle.VolumeId]*Volume, ecVolumes map[needle.VolumeId]*erasure_coding.EcVolume) {
+	stats.VolumeServerMaxVolumeCounter.Add(-float64(location.OriginalMaxVolumeCount))
+	go func() {
+		for _, v := range volumes {
+			s.DeletedVolumesChan <- master_pb.VolumeShortInformationMessage{
+				Id:               uint32(v.Id),
+				Collection:       v.Collection,
+				ReplicaPlacement: uint32(v.ReplicaPlacement.Byte()),
+				Version:          uint32(v.Version()),
+				Ttl:              v.Ttl.ToUint32(),
+				DiskType:         string(location.DiskType),
+			}
+		}
+		for _, ecVolume := range ecVolumes {
+			for _, message := range ecVolume.ToVolumeEcShardInformationMessage() {
+				s.DeletedEcShardsChan <- *message
+			}
+		}
+	}()
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+	. "github.com/chrislusf/seaweedfs/weed/storage/types"
+)
+
+func TestDiskLocationFailure(t *testing.T) {
+	var dirs []string
+	for i := 0; i < 2; i++ {
+		dir, err := ioutil.TempDir("", "disk")
+		if err != nil {
+			t.Fatalf("temp dir: %v", err)
+		}
+		defer os.RemoveAll(dir)
+		dirs = append(dirs, dir)
+	}
+	s := NewStore(nil, 8080, "localhost", "", dirs, []int{2, 2}, []float32{0, 0}, "", NeedleMapInMemory, []DiskType{HardDriveType, HardDriveType})
+	defer s.Close()
+
+	for vid := needle.VolumeId(1); vid <= 2; vid++ {
+		if err := s.AddVolume(vid, "", NeedleMapInMemory, "000", "", 0, 0, HardDriveType); err != nil {
+			t.Fatalf("add volume %d: %v", vid, err)
+		}
+		<-s.NewVolumesChan
+	}
+	failed := s.Locations[1]
+	if v, found := failed.FindVolume(2); !found || v.location != failed {
+		t.Fatalf("volume 2 should be on the second location")
+	}
+
+	// the healthy location passes the health check
+	if err := s.Locations[0].checkHealth(); err != nil {
+		t.Fatalf("check healthy location: %v", err)
+	}
+
+	os.RemoveAll(failed.Directory)
+	if err := failed.checkHealth(); err == nil {
+		t.Fatalf("expecting the health check to fail")
+	}
+	if !failed.IsFailed() || s.Locations[0].IsFailed() {
+		t.Fatalf("only the second location should fail")
+	}
+
+	if deletedId := (<-s.DeletedVolumesChan).Id; deletedId != 2 {
+		t.Errorf("expecting volume 2 reported as deleted, but got %d", deletedId)
+	}
+	if s.HasVolume(2) {
+		t.Errorf("volume 2 on the failed location should not be served")
+	}
+	if !s.HasVolume(1) {
+		t.Errorf("volume 1 on the healthy location should be served")
+	}
+	if failed.LoadVolume(2, NeedleMapInMemory) {
+		t.Errorf("volume should not be loaded on the failed location")
+	}
+
+	heartbeat := s.CollectHeartbeat()
+	if len(heartbeat.Volumes) != 1 || heartbeat.Volumes[0].Id != 1 {
+		t.Errorf("expecting only volume 1 in the heartbeat, but got %+v", heartbeat.Volumes)
+	}
+	if max := heartbeat.MaxVolumeCounts[string(HardDriveType)]; max != 2 {
+		t.Errorf("expecting max volume count 2, but got %d", max)
+	}
+	if location := s.FindFreeLocation(HardDriveType); location != s.Locations[0] {
+		t.Errorf("new volumes should be created on the healthy location")
+	}
+}
@@ -150,8 +150,8 @@ func iterateEcjFile(baseFileName string, processNeedleFn func(key types.NeedleId
 
 }
 
-// WriteDatFile generates .dat from from .ec00 ~ .ec09 files
-func WriteDatFile(baseFileName string, datFileSize int64) error {
+// WriteDatFile generates .dat from the data shard files, .ec00 ~ .ec09 for the default 10+4 layout
+func WriteDatFile(baseFileName string, layout EcLayout, datFileSize int64) error {
 
 	datFile, openErr := os.OpenFile(baseFileName+".dat", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if openErr != nil {
@@ -159,9 +159,9 @@ func WriteDatFile(baseFileName string, datFileSize int64) error {
 	}
 	defer datFile.Close()
 
-	inputFiles := make([]*os.File, DataShardsCount)
+	inputFiles := make([]*os.File, layout.DataShards)
 
-	for shardId := 0; shardId < DataShardsCount; shardId++ {
+	for shardId := 0; shardId < layout.DataShards; shardId++ {
 		shardFileName := baseFileName + ToExt(shardId)
 		inputFiles[shardId], openErr = os.OpenFile(shardFileName, os.O_RDONLY, 0)
 		if openErr != nil {
@@ -170,8 +170,8 @@ func WriteDatFile(baseFileName string, datFileSize int64) error {
 		defer inputFiles[shardId].Close()
 	}
 
-	for datFileSize >= DataShardsCount*ErasureCodingLargeBlockSize {
-		for shardId := 0; shardId < DataShardsCount; shardId++ {
+	for datFileSize >= int64(layout.DataShards)*ErasureCodingLargeBlockSize {
+		for shardId := 0; shardId < layout.DataShards; shardId++ {
 			w, err := io.CopyN(datFile, inputFiles[shardId], ErasureCodingLargeBlockSize)
 			if w != ErasureCodingLargeBlockSize {
 				return fmt.Errorf("copy %s large block %d: %v", baseFileName, shardId, err)
@@ -181,7 +181,7 @@ func WriteDatFile(baseFileName string, datFileSize int64) error {
 	}
 
 	for datFileSize > 0 {
-		for shardId := 0; shardId < DataShardsCount; shardId++ {
+		for shardId := 0; shardId < layout.DataShards; shardId++ {
 			toRead := min(datFileSize, ErasureCodingSmallBlockSize)
 			w, err := io.CopyN(datFile, inputFiles[shardId], toRead)
 			if w != toRead {
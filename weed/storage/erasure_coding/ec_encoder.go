@@ -1,9 +1,11 @@
 package erasure_coding
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/klauspost/reedsolomon"
 
@@ -53,20 +55,22 @@ func WriteSortedFileFromIdx(baseFileName string, ext string) (e error) {
 	return nil
 }
 
-// WriteEcFiles generates .ec00 ~ .ec13 files
-func WriteEcFiles(baseFileName string) error {
-	return generateEcFiles(baseFileName, 256*1024, ErasureCodingLargeBlockSize, ErasureCodingSmallBlockSize)
+// WriteEcFiles generates one .ecXX file for each shard of the layout, .ec00 ~ .ec13 for the default 10+4 layout.
+// The reading of the .dat file is slowed down by the throttler.
+// An interrupted encoding continues from the last checkpoint saved in the .ecp file.
+func WriteEcFiles(baseFileName string, layout EcLayout, throttler *util.WriteThrottler) error {
+	return generateEcFiles(baseFileName, layout, 256*1024, ErasureCodingLargeBlockSize, ErasureCodingSmallBlockSize, throttler)
 }
 
-func RebuildEcFiles(baseFileName string) ([]uint32, error) {
-	return generateMissingEcFiles(baseFileName, 256*1024, ErasureCodingLargeBlockSize, ErasureCodingSmallBlockSize)
+func RebuildEcFiles(baseFileName string, layout EcLayout) ([]uint32, error) {
+	return generateMissingEcFiles(baseFileName, layout, 256*1024, ErasureCodingLargeBlockSize, ErasureCodingSmallBlockSize)
 }
 
 func ToExt(ecIndex int) string {
 	return fmt.Sprintf(".ec%02d", ecIndex)
 }
 
-func generateEcFiles(baseFileName string, bufferSize int, largeBlockSize int64, smallBlockSize int64) error {
+func generateEcFiles(baseFileName string, layout EcLayout, bufferSize int, largeBlockSize int64, smallBlockSize int64, throttler *util.WriteThrottler) error {
 	file, err := os.OpenFile(baseFileName+".dat", os.O_RDONLY, 0)
 	if err != nil {
 		return fmt.Errorf("failed to open dat file: %v", err)
@@ -78,20 +82,32 @@ func generateEcFiles(baseFileName string, bufferSize int, largeBlockSize int64,
 		return fmt.Errorf("failed to stat dat file: %v", err)
 	}
 
-	glog.V(0).Infof("encodeDatFile %s.dat size:%d", baseFileName, fi.Size())
-	err = encodeDatFile(fi.Size(), err, baseFileName, bufferSize, largeBlockSize, file, smallBlockSize)
+	progress := loadEncodingProgress(baseFileName, fi.Size(), layout, bufferSize)
+	if progress.ShardOffset > 0 {
+		glog.V(0).Infof("encodeDatFile %s.dat size:%d layout:%s resume from shard offset %d", baseFileName, fi.Size(), layout, progress.ShardOffset)
+	} else {
+		glog.V(0).Infof("encodeDatFile %s.dat size:%d layout:%s", baseFileName, fi.Size(), layout)
+	}
+	err = encodeDatFile(fi.Size(), layout, baseFileName, bufferSize, largeBlockSize, file, smallBlockSize, progress, throttler)
+	if err != nil && strings.Contains(err.Error(), errStaleCheckpoint.Error()) {
+		glog.Warningf("encodeDatFile %s.dat: %v, encode from the beginning", baseFileName, err)
+		progress.restart()
+		err = encodeDatFile(fi.Size(), layout, baseFileName, bufferSize, largeBlockSize, file, smallBlockSize, progress, throttler)
+	}
 	if err != nil {
 		return fmt.Errorf("encodeDatFile: %v", err)
 	}
+	progress.remove()
 	return nil
 }
 
-func generateMissingEcFiles(baseFileName string, bufferSize int, largeBlockSize int64, smallBlockSize int64) (generatedShardIds []uint32, err error) {
+func generateMissingEcFiles(baseFileName string, layout EcLayout, bufferSize int, largeBlockSize int64, smallBlockSize int64) (generatedShardIds []uint32, err error) {
 
-	shardHasData := make([]bool, TotalShardsCount)
-	inputFiles := make([]*os.File, TotalShardsCount)
-	outputFiles := make([]*os.File, TotalShardsCount)
-	for shardId := 0; shardId < TotalShardsCount; shardId++ {
+	totalShards := layout.TotalShards()
+	shardHasData := make([]bool, totalShards)
+	inputFiles := make([]*os.File, totalShards)
+	outputFiles := make([]*os.File, totalShards)
+	for shardId := 0; shardId < totalShards; shardId++ {
 		shardFileName := baseFileName + ToExt(shardId)
 		if util.FileExists(shardFileName) {
 			shardHasData[shardId] = true
@@ -110,14 +126,16 @@ func generateMissingEcFiles(baseFileName string, bufferSize int, largeBlockSize
 		}
 	}
 
-	err = rebuildEcFiles(shardHasData, inputFiles, outputFiles)
+	err = rebuildEcFiles(layout, shardHasData, inputFiles, outputFiles)
 	if err != nil {
 		return nil, fmt.Errorf("rebuildEcFiles: %v", err)
 	}
 	return
 }
 
-func encodeData(file *os.File, enc reedsolomon.Encoder, startOffset, blockSize int64, buffers [][]byte, outputs []*os.File) error {
+// encodeData encodes one row of blocks, written to the shard files at the shard offset.
+// The batches already encoded before the checkpoint are skipped, except the last one is verified.
+func encodeData(file *os.File, enc reedsolomon.Encoder, layout EcLayout, startOffset, blockSize int64, buffers [][]byte, outputs []*os.File, shardOffset int64, progress *encodingProgress, throttler *util.WriteThrottler) error {
 
 	bufferSize := int64(len(buffers[0]))
 	batchCount := blockSize / bufferSize
@@ -126,17 +144,32 @@ func encodeData(file *os.File, enc reedsolomon.Encoder, startOffset, blockSize i
 	}
 
 	for b := int64(0); b < batchCount; b++ {
-		err := encodeDataOneBatch(file, enc, startOffset+b*bufferSize, blockSize, buffers, outputs)
+		batchShardOffset := shardOffset + b*bufferSize
+		if batchShardOffset+bufferSize < progress.ShardOffset {
+			continue
+		}
+		if batchShardOffset+bufferSize == progress.ShardOffset && progress.resumedFrom == progress.ShardOffset {
+			if err := verifyDataOneBatch(file, enc, layout, startOffset+b*bufferSize, blockSize, buffers, outputs, batchShardOffset); err != nil {
+				return err
+			}
+			progress.verified = true
+			continue
+		}
+		err := encodeDataOneBatch(file, enc, layout, startOffset+b*bufferSize, blockSize, buffers, outputs, batchShardOffset)
 		if err != nil {
 			return err
 		}
+		throttler.MaybeSlowdown(bufferSize * int64(layout.DataShards))
+		if err = progress.advance(batchShardOffset+bufferSize, outputs); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func openEcFiles(baseFileName string, forRead bool) (files []*os.File, err error) {
-	for i := 0; i < TotalShardsCount; i++ {
+func openEcFiles(baseFileName string, totalShards int, forRead bool) (files []*os.File, err error) {
+	for i := 0; i < totalShards; i++ {
 		fname := baseFileName + ToExt(i)
 		openOption := os.O_TRUNC | os.O_CREATE | os.O_WRONLY
 		if forRead {
@@ -159,10 +192,47 @@ func closeEcFiles(files []*os.File) {
 	}
 }
 
-func encodeDataOneBatch(file *os.File, enc reedsolomon.Encoder, startOffset, blockSize int64, buffers [][]byte, outputs []*os.File) error {
+func encodeDataOneBatch(file *os.File, enc reedsolomon.Encoder, layout EcLayout, startOffset, blockSize int64, buffers [][]byte, outputs []*os.File, shardOffset int64) error {
+
+	if err := encodeBuffers(file, enc, layout, startOffset, blockSize, buffers); err != nil {
+		return err
+	}
+
+	for i := 0; i < layout.TotalShards(); i++ {
+		_, err := outputs[i].WriteAt(buffers[i], shardOffset)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// verifyDataOneBatch encodes the batch again, and compares it with the shard files at the shard offset.
+func verifyDataOneBatch(file *os.File, enc reedsolomon.Encoder, layout EcLayout, startOffset, blockSize int64, buffers [][]byte, outputs []*os.File, shardOffset int64) error {
+
+	if err := encodeBuffers(file, enc, layout, startOffset, blockSize, buffers); err != nil {
+		return err
+	}
+
+	encoded := make([]byte, len(buffers[0]))
+	for i := 0; i < layout.TotalShards(); i++ {
+		if _, err := outputs[i].ReadAt(encoded, shardOffset); err != nil {
+			return fmt.Errorf("%v: read %s at %d: %v", errStaleCheckpoint, outputs[i].Name(), shardOffset, err)
+		}
+		if !bytes.Equal(encoded, buffers[i]) {
+			return fmt.Errorf("%v: %s differs at %d", errStaleCheckpoint, outputs[i].Name(), shardOffset)
+		}
+	}
+
+	return nil
+}
+
+// encodeBuffers reads the data of the batch into the data shard buffers, and computes the parity shard buffers.
+func encodeBuffers(file *os.File, enc reedsolomon.Encoder, layout EcLayout, startOffset, blockSize int64, buffers [][]byte) error {
 
 	// read data into buffers
-	for i := 0; i < DataShardsCount; i++ {
+	for i := 0; i < layout.DataShards; i++ {
 		n, err := file.ReadAt(buffers[i], startOffset+blockSize*int64(i))
 		if err != nil {
 			if err != io.EOF {
@@ -176,68 +246,59 @@ func encodeDataOneBatch(file *os.File, enc reedsolomon.Encoder, startOffset, blo
 		}
 	}
 
-	err := enc.Encode(buffers)
-	if err != nil {
-		return err
-	}
-
-	for i := 0; i < TotalShardsCount; i++ {
-		_, err := outputs[i].Write(buffers[i])
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return enc.Encode(buffers)
 }
 
-func encodeDatFile(remainingSize int64, err error, baseFileName string, bufferSize int, largeBlockSize int64, file *os.File, smallBlockSize int64) error {
+func encodeDatFile(remainingSize int64, layout EcLayout, baseFileName string, bufferSize int, largeBlockSize int64, file *os.File, smallBlockSize int64, progress *encodingProgress, throttler *util.WriteThrottler) error {
 
-	var processedSize int64
+	var processedSize, shardOffset int64
 
-	enc, err := reedsolomon.New(DataShardsCount, ParityShardsCount)
+	enc, err := reedsolomon.New(layout.DataShards, layout.ParityShards)
 	if err != nil {
 		return fmt.Errorf("failed to create encoder: %v", err)
 	}
 
-	buffers := make([][]byte, TotalShardsCount)
+	buffers := make([][]byte, layout.TotalShards())
 	for i := range buffers {
 		buffers[i] = make([]byte, bufferSize)
 	}
 
-	outputs, err := openEcFiles(baseFileName, false)
+	outputs, err := openEcFilesForEncoding(baseFileName, layout.TotalShards(), progress.ShardOffset)
 	defer closeEcFiles(outputs)
 	if err != nil {
 		return fmt.Errorf("failed to open ec files %s: %v", baseFileName, err)
 	}
 
-	for remainingSize > largeBlockSize*DataShardsCount {
-		err = encodeData(file, enc, processedSize, largeBlockSize, buffers, outputs)
+	dataShards := int64(layout.DataShards)
+	for remainingSize > largeBlockSize*dataShards {
+		err = encodeData(file, enc, layout, processedSize, largeBlockSize, buffers, outputs, shardOffset, progress, throttler)
 		if err != nil {
 			return fmt.Errorf("failed to encode large chunk data: %v", err)
 		}
-		remainingSize -= largeBlockSize * DataShardsCount
-		processedSize += largeBlockSize * DataShardsCount
+		remainingSize -= largeBlockSize * dataShards
+		processedSize += largeBlockSize * dataShards
+		shardOffset += largeBlockSize
 	}
 	for remainingSize > 0 {
-		encodeData(file, enc, processedSize, smallBlockSize, buffers, outputs)
+		err = encodeData(file, enc, layout, processedSize, smallBlockSize, buffers, outputs, shardOffset, progress, throttler)
 		if err != nil {
 			return fmt.Errorf("failed to encode small chunk data: %v", err)
 		}
-		remainingSize -= smallBlockSize * DataShardsCount
-		processedSize += smallBlockSize * DataShardsCount
+		remainingSize -= smallBlockSize * dataShards
+		processedSize += smallBlockSize * dataShards
+		shardOffset += smallBlockSize
 	}
-	return nil
+	return progress.checkResumed()
 }
 
-func rebuildEcFiles(shardHasData []bool, inputFiles []*os.File, outputFiles []*os.File) error {
+func rebuildEcFiles(layout EcLayout, shardHasData []bool, inputFiles []*os.File, outputFiles []*os.File) error {
 
-	enc, err := reedsolomon.New(DataShardsCount, ParityShardsCount)
+	enc, err := reedsolomon.New(layout.DataShards, layout.ParityShards)
 	if err != nil {
 		return fmt.Errorf("failed to create encoder: %v", err)
 	}
 
-	buffers := make([][]byte, TotalShardsCount)
+	buffers := make([][]byte, layout.TotalShards())
 	for i := range buffers {
 		if shardHasData[i] {
 			buffers[i] = make([]byte, ErasureCodingSmallBlockSize)
@@ -249,7 +310,7 @@ func rebuildEcFiles(shardHasData []bool, inputFiles []*os.File, outputFiles []*o
 	for {
 
 		// read the input data from files
-		for i := 0; i < TotalShardsCount; i++ {
+		for i := 0; i < layout.TotalShards(); i++ {
 			if shardHasData[i] {
 				n, _ := inputFiles[i].ReadAt(buffers[i], startOffset)
 				if n == 0 {
@@ -273,7 +334,7 @@ func rebuildEcFiles(shardHasData []bool, inputFiles []*os.File, outputFiles []*o
 		}
 
 		// write the data to output files
-		for i := 0; i < TotalShardsCount; i++ {
+		for i := 0; i < layout.TotalShards(); i++ {
 			if !shardHasData[i] {
 				n, _ := outputFiles[i].WriteAt(buffers[i][:inputBufferDataSize], startOffset)
 				if inputBufferDataSize != n {
@@ -0,0 +1,129 @@
+package erasure_coding
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+)
+
+// encodingCheckpointInterval is the shard bytes encoded between two checkpoints
+const encodingCheckpointInterval = 64 * 1024 * 1024
+
+var errStaleCheckpoint = errors.New("stale encoding checkpoint")
+
+// encodingProgress is saved in the .ecp file while encoding the .dat file.
+// The shard files are complete up to the shard offset, so an interrupted encoding,
+// e.g. by a volume server restart, continues from there instead of encoding the whole volume again.
+// When resuming, the last batch before the shard offset is encoded again and compared with the shard files,
+// and the checkpoint is discarded if they differ.
+type encodingProgress struct {
+	DatFileSize  int64 `json:"datFileSize"`
+	DataShards   int   `json:"dataShards"`
+	ParityShards int   `json:"parityShards"`
+	ShardOffset  int64 `json:"shardOffset"`
+
+	fileName    string
+	lastSaved   int64
+	resumedFrom int64 // the shard offset of the checkpoint, 0 if not resumed
+	verified    bool  // the batch before the checkpoint matches the shard files
+}
+
+// loadEncodingProgress loads the checkpoint of the same .dat file and layout,
+// if all shard files are still there, or starts from the beginning.
+func loadEncodingProgress(baseFileName string, datFileSize int64, layout EcLayout, bufferSize int) *encodingProgress {
+
+	progress := &encodingProgress{
+		DatFileSize:  datFileSize,
+		DataShards:   layout.DataShards,
+		ParityShards: layout.ParityShards,
+		fileName:     baseFileName + ".ecp",
+	}
+
+	data, err := ioutil.ReadFile(progress.fileName)
+	if err != nil {
+		return progress
+	}
+	saved := &encodingProgress{}
+	if err = json.Unmarshal(data, saved); err != nil {
+		glog.Warningf("ignore %s: %v", progress.fileName, err)
+		return progress
+	}
+	if saved.DatFileSize != datFileSize || saved.DataShards != layout.DataShards || saved.ParityShards != layout.ParityShards {
+		glog.V(0).Infof("ignore %s: encoded %d bytes with layout %d+%d", progress.fileName, saved.DatFileSize, saved.DataShards, saved.ParityShards)
+		return progress
+	}
+	if saved.ShardOffset <= 0 || saved.ShardOffset%int64(bufferSize) != 0 {
+		glog.V(0).Infof("ignore %s: shard offset %d", progress.fileName, saved.ShardOffset)
+		return progress
+	}
+	for i := 0; i < layout.TotalShards(); i++ {
+		if fi, statErr := os.Stat(baseFileName + ToExt(i)); statErr != nil || fi.Size() < saved.ShardOffset {
+			return progress
+		}
+	}
+
+	progress.ShardOffset = saved.ShardOffset
+	progress.lastSaved = saved.ShardOffset
+	progress.resumedFrom = saved.ShardOffset
+	return progress
+}
+
+// restart discards the checkpoint, to encode from the beginning.
+func (progress *encodingProgress) restart() {
+	progress.remove()
+	progress.ShardOffset, progress.lastSaved, progress.resumedFrom, progress.verified = 0, 0, 0, false
+}
+
+// checkResumed fails if the checkpoint is not verified after encoding, e.g. beyond the shard size of the .dat file.
+func (progress *encodingProgress) checkResumed() error {
+	if progress.resumedFrom > 0 && !progress.verified {
+		return fmt.Errorf("%v: shard offset %d not verified", errStaleCheckpoint, progress.resumedFrom)
+	}
+	return nil
+}
+
+// advance moves the progress to the shard offset, and saves a checkpoint after every encodingCheckpointInterval.
+func (progress *encodingProgress) advance(shardOffset int64, outputs []*os.File) error {
+	progress.ShardOffset = shardOffset
+	if progress.ShardOffset-progress.lastSaved < encodingCheckpointInterval {
+		return nil
+	}
+	for _, f := range outputs {
+		if err := f.Sync(); err != nil {
+			return fmt.Errorf("sync %s: %v", f.Name(), err)
+		}
+	}
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(progress.fileName, data, 0644); err != nil {
+		return fmt.Errorf("save %s: %v", progress.fileName, err)
+	}
+	progress.lastSaved = progress.ShardOffset
+	return nil
+}
+
+func (progress *encodingProgress) remove() {
+	os.Remove(progress.fileName)
+}
+
+// openEcFilesForEncoding opens the shard files, keeping the content before the shard offset to be verified
+func openEcFilesForEncoding(baseFileName string, totalShards int, shardOffset int64) (files []*os.File, err error) {
+	for i := 0; i < totalShards; i++ {
+		fname := baseFileName + ToExt(i)
+		f, err := os.OpenFile(fname, os.O_CREATE|os.O_RDWR, 0644)
+		if err != nil {
+			return files, fmt.Errorf("failed to open file %s: %v", fname, err)
+		}
+		files = append(files, f)
+		if err = f.Truncate(shardOffset); err != nil {
+			return files, fmt.Errorf("failed to truncate file %s: %v", fname, err)
+		}
+	}
+	return
+}
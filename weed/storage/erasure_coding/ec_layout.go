@@ -0,0 +1,85 @@
+package erasure_coding
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/pb"
+)
+
+// MaxShardsCount is limited by the 32 bits of ShardBits
+const MaxShardsCount = 32
+
+// EcLayout is the number of data shards and parity shards of one erasure coded volume.
+// The layout is chosen when the volume is encoded, and saved in the .vif file of the ec volume.
+type EcLayout struct {
+	DataShards   int
+	ParityShards int
+}
+
+var DefaultEcLayout = EcLayout{DataShards: DataShardsCount, ParityShards: ParityShardsCount}
+
+// NewEcLayout validates the shard counts. Zero counts mean the default 10+4 layout.
+func NewEcLayout(dataShards, parityShards int) (EcLayout, error) {
+	if dataShards == 0 && parityShards == 0 {
+		return DefaultEcLayout, nil
+	}
+	layout := EcLayout{DataShards: dataShards, ParityShards: parityShards}
+	if dataShards <= 0 || parityShards <= 0 {
+		return layout, fmt.Errorf("invalid ec layout %s: need at least one data shard and one parity shard", layout)
+	}
+	if layout.TotalShards() > MaxShardsCount {
+		return layout, fmt.Errorf("invalid ec layout %s: at most %d shards", layout, MaxShardsCount)
+	}
+	return layout, nil
+}
+
+// ParseEcLayout parses a layout like "4+2". An empty string means the default 10+4 layout.
+func ParseEcLayout(s string) (EcLayout, error) {
+	if s == "" {
+		return DefaultEcLayout, nil
+	}
+	parts := strings.Split(s, "+")
+	if len(parts) != 2 {
+		return EcLayout{}, fmt.Errorf("invalid ec layout %q, expecting data+parity, e.g. 10+4", s)
+	}
+	dataShards, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return EcLayout{}, fmt.Errorf("invalid ec layout %q: %v", s, err)
+	}
+	parityShards, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return EcLayout{}, fmt.Errorf("invalid ec layout %q: %v", s, err)
+	}
+	if dataShards == 0 || parityShards == 0 {
+		return EcLayout{}, fmt.Errorf("invalid ec layout %q: need at least one data shard and one parity shard", s)
+	}
+	return NewEcLayout(dataShards, parityShards)
+}
+
+// ToEcLayout converts the shard counts in the protobuf messages.
+// Zero counts are sent by older servers, which only support the default 10+4 layout.
+func ToEcLayout(dataShards, parityShards uint32) EcLayout {
+	layout, err := NewEcLayout(int(dataShards), int(parityShards))
+	if err != nil {
+		return DefaultEcLayout
+	}
+	return layout
+}
+
+func (l EcLayout) TotalShards() int {
+	return l.DataShards + l.ParityShards
+}
+
+func (l EcLayout) String() string {
+	return fmt.Sprintf("%d+%d", l.DataShards, l.ParityShards)
+}
+
+// ReadEcLayout reads the layout from the .vif file of the ec volume, defaulting to the 10+4 layout
+func ReadEcLayout(dataBaseFileName string) EcLayout {
+	if volumeInfo, _, found, _ := pb.MaybeLoadVolumeInfo(dataBaseFileName + ".vif"); found {
+		return ToEcLayout(volumeInfo.EcDataShards, volumeInfo.EcParityShards)
+	}
+	return DefaultEcLayout
+}
@@ -95,3 +95,16 @@ func (shard *EcVolumeShard) ReadAt(buf []byte, offset int64) (int, error) {
 	return shard.ecdFile.ReadAt(buf, offset)
 
 }
+
+// RepairAt overwrites corrupted shard data with the recovered data.
+func (shard *EcVolumeShard) RepairAt(buf []byte, offset int64) error {
+	f, err := os.OpenFile(shard.FileName()+ToExt(int(shard.ShardId)), os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("open %s for repair: %v", shard, err)
+	}
+	defer f.Close()
+	if _, err = f.WriteAt(buf, offset); err != nil {
+		return fmt.Errorf("repair %s at %d: %v", shard, offset, err)
+	}
+	return f.Sync()
+}
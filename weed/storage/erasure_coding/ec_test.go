@@ -2,7 +2,9 @@ package erasure_coding
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"os"
 	"testing"
@@ -11,6 +13,7 @@ import (
 
 	"github.com/chrislusf/seaweedfs/weed/storage/needle_map"
 	"github.com/chrislusf/seaweedfs/weed/storage/types"
+	"github.com/chrislusf/seaweedfs/weed/util"
 )
 
 const (
@@ -19,29 +22,132 @@ const (
 )
 
 func TestEncodingDecoding(t *testing.T) {
+	testEncodingDecoding(t, DefaultEcLayout)
+}
+
+func TestEncodingDecodingWithLayout(t *testing.T) {
+	testEncodingDecoding(t, EcLayout{DataShards: 4, ParityShards: 2})
+	testEncodingDecoding(t, EcLayout{DataShards: 12, ParityShards: 3})
+}
+
+func testEncodingDecoding(t *testing.T, layout EcLayout) {
 	bufferSize := 50
 	baseFileName := "1"
 
-	err := generateEcFiles(baseFileName, bufferSize, largeBlockSize, smallBlockSize)
+	err := generateEcFiles(baseFileName, layout, bufferSize, largeBlockSize, smallBlockSize, util.NewWriteThrottler(0))
 	if err != nil {
-		t.Logf("generateEcFiles: %v", err)
+		t.Errorf("generateEcFiles: %v", err)
 	}
 
 	err = WriteSortedFileFromIdx(baseFileName, ".ecx")
 	if err != nil {
-		t.Logf("WriteSortedFileFromIdx: %v", err)
+		t.Errorf("WriteSortedFileFromIdx: %v", err)
 	}
 
-	err = validateFiles(baseFileName)
+	err = validateFiles(baseFileName, layout)
 	if err != nil {
-		t.Logf("WriteSortedFileFromIdx: %v", err)
+		t.Errorf("validateFiles %s: %v", layout, err)
 	}
 
-	removeGeneratedFiles(baseFileName)
+	removeGeneratedFiles(baseFileName, layout)
 
 }
 
-func validateFiles(baseFileName string) error {
+func TestResumeEncoding(t *testing.T) {
+	bufferSize := 50
+	baseFileName := "1"
+	layout := EcLayout{DataShards: 4, ParityShards: 2}
+	defer removeGeneratedFiles(baseFileName, layout)
+
+	if err := generateEcFiles(baseFileName, layout, bufferSize, largeBlockSize, smallBlockSize, util.NewWriteThrottler(0)); err != nil {
+		t.Fatalf("generateEcFiles: %v", err)
+	}
+	expected, _ := ioutil.ReadFile(baseFileName + ToExt(1))
+
+	// interrupted after the checkpoint, with partially written shards
+	fi, _ := os.Stat(baseFileName + ".dat")
+	shardOffset := int64(len(expected)/2/bufferSize) * int64(bufferSize)
+	data, _ := json.Marshal(&encodingProgress{
+		DatFileSize:  fi.Size(),
+		DataShards:   layout.DataShards,
+		ParityShards: layout.ParityShards,
+		ShardOffset:  shardOffset,
+	})
+	ioutil.WriteFile(baseFileName+".ecp", data, 0644)
+	corrupted := append([]byte{}, expected...)
+	corrupted[0]++
+	corrupted[len(corrupted)-1]++
+	ioutil.WriteFile(baseFileName+ToExt(1), corrupted, 0644)
+
+	if err := generateEcFiles(baseFileName, layout, bufferSize, largeBlockSize, smallBlockSize, util.NewWriteThrottler(0)); err != nil {
+		t.Fatalf("resume generateEcFiles: %v", err)
+	}
+	actual, _ := ioutil.ReadFile(baseFileName + ToExt(1))
+	if len(actual) != len(expected) {
+		t.Fatalf("resumed shard size %d, expected %d", len(actual), len(expected))
+	}
+	if actual[0] != corrupted[0] {
+		t.Errorf("shard encoded again before the checkpoint")
+	}
+	if !bytes.Equal(actual[shardOffset:], expected[shardOffset:]) {
+		t.Errorf("shard not encoded after the checkpoint")
+	}
+	if _, err := os.Stat(baseFileName + ".ecp"); !os.IsNotExist(err) {
+		t.Errorf("progress file not removed: %v", err)
+	}
+}
+
+func TestResumeEncodingStaleCheckpoint(t *testing.T) {
+	bufferSize := 50
+	baseFileName := "1"
+	layout := EcLayout{DataShards: 4, ParityShards: 2}
+	defer removeGeneratedFiles(baseFileName, layout)
+
+	if err := generateEcFiles(baseFileName, layout, bufferSize, largeBlockSize, smallBlockSize, util.NewWriteThrottler(0)); err != nil {
+		t.Fatalf("generateEcFiles: %v", err)
+	}
+	expected, _ := ioutil.ReadFile(baseFileName + ToExt(1))
+
+	fi, _ := os.Stat(baseFileName + ".dat")
+	shardOffset := int64(len(expected)/2/bufferSize) * int64(bufferSize)
+	for _, c := range []struct {
+		shardOffset int64
+		corruptAt   int64
+	}{
+		{shardOffset, shardOffset - 1}, // the shards differ from the checkpoint
+		{shardOffset + 1, 0},           // not at a batch boundary
+		{int64(len(expected)) * 2, 0},  // beyond the shards of the .dat file
+	} {
+		data, _ := json.Marshal(&encodingProgress{
+			DatFileSize:  fi.Size(),
+			DataShards:   layout.DataShards,
+			ParityShards: layout.ParityShards,
+			ShardOffset:  c.shardOffset,
+		})
+		ioutil.WriteFile(baseFileName+".ecp", data, 0644)
+		corrupted := append([]byte{}, expected...)
+		corrupted[c.corruptAt]++
+		for len(corrupted) < int(c.shardOffset) {
+			corrupted = append(corrupted, 0)
+		}
+		ioutil.WriteFile(baseFileName+ToExt(1), corrupted, 0644)
+		if c.shardOffset > int64(len(expected)) {
+			for i := 0; i < layout.TotalShards(); i++ {
+				os.Truncate(baseFileName+ToExt(i), c.shardOffset)
+			}
+		}
+
+		if err := generateEcFiles(baseFileName, layout, bufferSize, largeBlockSize, smallBlockSize, util.NewWriteThrottler(0)); err != nil {
+			t.Fatalf("resume generateEcFiles: %v", err)
+		}
+		actual, _ := ioutil.ReadFile(baseFileName + ToExt(1))
+		if !bytes.Equal(actual, expected) {
+			t.Errorf("shard offset %d: shard not encoded from the beginning", c.shardOffset)
+		}
+	}
+}
+
+func validateFiles(baseFileName string, layout EcLayout) error {
 	nm, err := readNeedleMap(baseFileName)
 	defer nm.Close()
 	if err != nil {
@@ -59,11 +165,11 @@ func validateFiles(baseFileName string) error {
 		return fmt.Errorf("failed to stat dat file: %v", err)
 	}
 
-	ecFiles, err := openEcFiles(baseFileName, true)
+	ecFiles, err := openEcFiles(baseFileName, layout.TotalShards(), true)
 	defer closeEcFiles(ecFiles)
 
 	err = nm.AscendingVisit(func(value needle_map.NeedleValue) error {
-		return assertSame(datFile, fi.Size(), ecFiles, value.Offset, value.Size)
+		return assertSame(datFile, fi.Size(), layout, ecFiles, value.Offset, value.Size)
 	})
 	if err != nil {
 		return fmt.Errorf("failed to check ec files: %v", err)
@@ -71,14 +177,14 @@ func validateFiles(baseFileName string) error {
 	return nil
 }
 
-func assertSame(datFile *os.File, datSize int64, ecFiles []*os.File, offset types.Offset, size types.Size) error {
+func assertSame(datFile *os.File, datSize int64, layout EcLayout, ecFiles []*os.File, offset types.Offset, size types.Size) error {
 
 	data, err := readDatFile(datFile, offset, size)
 	if err != nil {
 		return fmt.Errorf("failed to read dat file: %v", err)
 	}
 
-	ecData, err := readEcFile(datSize, ecFiles, offset, size)
+	ecData, err := readEcFile(datSize, layout, ecFiles, offset, size)
 	if err != nil {
 		return fmt.Errorf("failed to read ec file: %v", err)
 	}
@@ -103,12 +209,12 @@ func readDatFile(datFile *os.File, offset types.Offset, size types.Size) ([]byte
 	return data, nil
 }
 
-func readEcFile(datSize int64, ecFiles []*os.File, offset types.Offset, size types.Size) (data []byte, err error) {
+func readEcFile(datSize int64, layout EcLayout, ecFiles []*os.File, offset types.Offset, size types.Size) (data []byte, err error) {
 
-	intervals := LocateData(largeBlockSize, smallBlockSize, datSize, offset.ToActualOffset(), size)
+	intervals := LocateData(largeBlockSize, smallBlockSize, layout.DataShards, datSize, offset.ToActualOffset(), size)
 
 	for i, interval := range intervals {
-		if d, e := readOneInterval(interval, ecFiles); e != nil {
+		if d, e := readOneInterval(interval, layout, ecFiles); e != nil {
 			return nil, e
 		} else {
 			if i == 0 {
@@ -122,14 +228,14 @@ func readEcFile(datSize int64, ecFiles []*os.File, offset types.Offset, size typ
 	return data, nil
 }
 
-func readOneInterval(interval Interval, ecFiles []*os.File) (data []byte, err error) {
+func readOneInterval(interval Interval, layout EcLayout, ecFiles []*os.File) (data []byte, err error) {
 
 	ecFileIndex, ecFileOffset := interval.ToShardIdAndOffset(largeBlockSize, smallBlockSize)
 
 	data = make([]byte, interval.Size)
 	err = readFromFile(ecFiles[ecFileIndex], data, ecFileOffset)
 	{ // do some ec testing
-		ecData, err := readFromOtherEcFiles(ecFiles, int(ecFileIndex), ecFileOffset, interval.Size)
+		ecData, err := readFromOtherEcFiles(ecFiles, layout, int(ecFileIndex), ecFileOffset, interval.Size)
 		if err != nil {
 			return nil, fmt.Errorf("ec reconstruct error: %v", err)
 		}
@@ -140,15 +246,15 @@ func readOneInterval(interval Interval, ecFiles []*os.File) (data []byte, err er
 	return
 }
 
-func readFromOtherEcFiles(ecFiles []*os.File, ecFileIndex int, ecFileOffset int64, size types.Size) (data []byte, err error) {
-	enc, err := reedsolomon.New(DataShardsCount, ParityShardsCount)
+func readFromOtherEcFiles(ecFiles []*os.File, layout EcLayout, ecFileIndex int, ecFileOffset int64, size types.Size) (data []byte, err error) {
+	enc, err := reedsolomon.New(layout.DataShards, layout.ParityShards)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create encoder: %v", err)
 	}
 
-	bufs := make([][]byte, TotalShardsCount)
-	for i := 0; i < DataShardsCount; {
-		n := int(rand.Int31n(TotalShardsCount))
+	bufs := make([][]byte, layout.TotalShards())
+	for i := 0; i < layout.DataShards; {
+		n := int(rand.Int31n(int32(layout.TotalShards())))
 		if n == ecFileIndex || bufs[n] != nil {
 			continue
 		}
@@ -178,8 +284,8 @@ func readFromFile(file *os.File, data []byte, ecFileOffset int64) (err error) {
 	return
 }
 
-func removeGeneratedFiles(baseFileName string) {
-	for i := 0; i < DataShardsCount+ParityShardsCount; i++ {
+func removeGeneratedFiles(baseFileName string, layout EcLayout) {
+	for i := 0; i < layout.TotalShards(); i++ {
 		fname := fmt.Sprintf("%s.ec%02d", baseFileName, i)
 		os.Remove(fname)
 	}
@@ -187,18 +293,53 @@ func removeGeneratedFiles(baseFileName string) {
 }
 
 func TestLocateData(t *testing.T) {
-	intervals := LocateData(largeBlockSize, smallBlockSize, DataShardsCount*largeBlockSize+1, DataShardsCount*largeBlockSize, 1)
+	intervals := LocateData(largeBlockSize, smallBlockSize, DataShardsCount, DataShardsCount*largeBlockSize+1, DataShardsCount*largeBlockSize, 1)
 	if len(intervals) != 1 {
 		t.Errorf("unexpected interval size %d", len(intervals))
 	}
-	if !intervals[0].sameAs(Interval{0, 0, 1, false, 1}) {
+	if !intervals[0].sameAs(Interval{0, 0, 1, false, 1, DataShardsCount}) {
 		t.Errorf("unexpected interval %+v", intervals[0])
 	}
 
-	intervals = LocateData(largeBlockSize, smallBlockSize, DataShardsCount*largeBlockSize+1, DataShardsCount*largeBlockSize/2+100, DataShardsCount*largeBlockSize+1-DataShardsCount*largeBlockSize/2-100)
+	intervals = LocateData(largeBlockSize, smallBlockSize, DataShardsCount, DataShardsCount*largeBlockSize+1, DataShardsCount*largeBlockSize/2+100, DataShardsCount*largeBlockSize+1-DataShardsCount*largeBlockSize/2-100)
 	fmt.Printf("%+v\n", intervals)
 }
 
+func TestLocateDataWithLayout(t *testing.T) {
+	// 4 data shards, the offset is in the 2nd small block row
+	intervals := LocateData(largeBlockSize, smallBlockSize, 4, 4*largeBlockSize+10*smallBlockSize, 4*largeBlockSize+5*smallBlockSize+1, 1)
+	if len(intervals) != 1 {
+		t.Fatalf("unexpected interval size %d", len(intervals))
+	}
+	if !intervals[0].sameAs(Interval{5, 1, 1, false, 1, 4}) {
+		t.Errorf("unexpected interval %+v", intervals[0])
+	}
+	shardId, offset := intervals[0].ToShardIdAndOffset(largeBlockSize, smallBlockSize)
+	if shardId != 1 || offset != largeBlockSize+smallBlockSize+1 {
+		t.Errorf("unexpected shard %d offset %d", shardId, offset)
+	}
+}
+
+func TestParseEcLayout(t *testing.T) {
+	for _, s := range []string{"", "10+4", "4+2", "12+3", "28+4"} {
+		layout, err := ParseEcLayout(s)
+		if err != nil {
+			t.Errorf("parse %q: %v", s, err)
+		}
+		if s != "" && layout.String() != s {
+			t.Errorf("parse %q: %s", s, layout)
+		}
+	}
+	for _, s := range []string{"4", "4+0", "0+2", "a+2", "30+4"} {
+		if _, err := ParseEcLayout(s); err == nil {
+			t.Errorf("parse %q should fail", s)
+		}
+	}
+	if ToEcLayout(0, 0) != DefaultEcLayout {
+		t.Errorf("zero layout should be the default layout")
+	}
+}
+
 func (this Interval) sameAs(that Interval) bool {
 	return this.IsLargeBlock == that.IsLargeBlock &&
 		this.InnerBlockOffset == that.InnerBlockOffset &&
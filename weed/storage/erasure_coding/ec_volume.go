@@ -34,6 +34,7 @@ type EcVolume struct {
 	ShardLocationsRefreshTime time.Time
 	ShardLocationsLock        sync.RWMutex
 	Version                   needle.Version
+	Layout                    EcLayout
 	ecjFile                   *os.File
 	ecjFileAccessLock         sync.Mutex
 	diskType                  types.DiskType
@@ -63,8 +64,10 @@ func NewEcVolume(diskType types.DiskType, dir string, dirIdx string, collection
 
 	// read volume info
 	ev.Version = needle.Version3
+	ev.Layout = DefaultEcLayout
 	if volumeInfo, _, found, _ := pb.MaybeLoadVolumeInfo(dataBaseFileName + ".vif"); found {
 		ev.Version = needle.Version(volumeInfo.Version)
+		ev.Layout = ToEcLayout(volumeInfo.EcDataShards, volumeInfo.EcParityShards)
 	} else {
 		pb.SaveVolumeInfo(dataBaseFileName+".vif", &volume_server_pb.VolumeInfo{Version: uint32(ev.Version)})
 	}
@@ -190,9 +193,11 @@ func (ev *EcVolume) ToVolumeEcShardInformationMessage() (messages []*master_pb.V
 	for _, s := range ev.Shards {
 		if s.VolumeId != prevVolumeId {
 			m = &master_pb.VolumeEcShardInformationMessage{
-				Id:         uint32(s.VolumeId),
-				Collection: s.Collection,
-				DiskType:   string(ev.diskType),
+				Id:           uint32(s.VolumeId),
+				Collection:   s.Collection,
+				DiskType:     string(ev.diskType),
+				DataShards:   uint32(ev.Layout.DataShards),
+				ParityShards: uint32(ev.Layout.ParityShards),
 			}
 			messages = append(messages, m)
 		}
@@ -213,7 +218,7 @@ func (ev *EcVolume) LocateEcShardNeedle(needleId types.NeedleId, version needle.
 	shard := ev.Shards[0]
 
 	// calculate the locations in the ec shards
-	intervals = LocateData(ErasureCodingLargeBlockSize, ErasureCodingSmallBlockSize, DataShardsCount*shard.ecdFileSize, offset.ToActualOffset(), types.Size(needle.GetActualSize(size, version)))
+	intervals = LocateData(ErasureCodingLargeBlockSize, ErasureCodingSmallBlockSize, ev.Layout.DataShards, int64(ev.Layout.DataShards)*shard.ecdFileSize, offset.ToActualOffset(), types.Size(needle.GetActualSize(size, version)))
 
 	return
 }
@@ -222,6 +227,21 @@ func (ev *EcVolume) FindNeedleFromEcx(needleId types.NeedleId) (offset types.Off
 	return SearchNeedleFromSortedIndex(ev.ecxFile, ev.ecxFileSize, needleId, nil)
 }
 
+// EcxEntryCount returns the number of entries in the sorted .ecx index
+func (ev *EcVolume) EcxEntryCount() int64 {
+	return ev.ecxFileSize / types.NeedleMapEntrySize
+}
+
+// ReadEcxEntry reads the n-th entry of the sorted .ecx index
+func (ev *EcVolume) ReadEcxEntry(n int64) (key types.NeedleId, offset types.Offset, size types.Size, err error) {
+	buf := make([]byte, types.NeedleMapEntrySize)
+	if _, err = ev.ecxFile.ReadAt(buf, n*types.NeedleMapEntrySize); err != nil {
+		return 0, types.Offset{}, 0, fmt.Errorf("ecx file %d read at %d: %v", ev.ecxFileSize, n*types.NeedleMapEntrySize, err)
+	}
+	key, offset, size = idx.IdxFileEntry(buf)
+	return
+}
+
 func SearchNeedleFromSortedIndex(ecxFile *os.File, ecxFileSize int64, needleId types.NeedleId, processNeedleFn func(file *os.File, offset int64) error) (offset types.Offset, size types.Size, err error) {
 	var key types.NeedleId
 	buf := make([]byte, types.NeedleMapEntrySize)
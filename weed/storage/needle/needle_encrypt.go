@@ -0,0 +1,77 @@
+package needle
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+
+	. "github.com/chrislusf/seaweedfs/weed/storage/types"
+)
+
+func (n *Needle) IsEncrypted() bool {
+	return n.Flags&FlagIsEncrypted != 0
+}
+
+func (n *Needle) SetIsEncrypted() {
+	n.Flags = n.Flags | FlagIsEncrypted
+}
+
+// Encrypt encrypts the needle data with AES-GCM, and marks the needle as encrypted.
+// The needle id and cookie are authenticated together with the data,
+// so the encrypted data can not be moved to another needle.
+// The checksum is updated to the encrypted data, so that the stored data can be verified without the key.
+func (n *Needle) Encrypt(key []byte) error {
+	gcm, err := newNeedleCipher(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	n.Data = gcm.Seal(nonce, nonce, n.Data, n.additionalData())
+	n.Checksum = NewCRC(n.Data)
+	n.SetIsEncrypted()
+	return nil
+}
+
+// Decrypt restores the needle data and its checksum, as if the needle is not encrypted.
+func (n *Needle) Decrypt(key []byte) error {
+	if len(key) == 0 {
+		return fmt.Errorf("needle %s is encrypted, but missing the key", n.String())
+	}
+	gcm, err := newNeedleCipher(key)
+	if err != nil {
+		return err
+	}
+	nonceSize := gcm.NonceSize()
+	if len(n.Data) < nonceSize {
+		return fmt.Errorf("needle %s encrypted data too short", n.String())
+	}
+	data, err := gcm.Open(nil, n.Data[:nonceSize], n.Data[nonceSize:], n.additionalData())
+	if err != nil {
+		return fmt.Errorf("decrypt needle %s: %v", n.String(), err)
+	}
+	n.Data = data
+	n.DataSize = uint32(len(data))
+	n.Checksum = NewCRC(data)
+	n.Flags = n.Flags &^ FlagIsEncrypted
+	return nil
+}
+
+func (n *Needle) additionalData() []byte {
+	ad := make([]byte, NeedleIdSize+CookieSize)
+	NeedleIdToBytes(ad[0:NeedleIdSize], n.Id)
+	CookieToBytes(ad[NeedleIdSize:], n.Cookie)
+	return ad
+}
+
+func newNeedleCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
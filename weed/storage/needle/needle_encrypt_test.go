@@ -0,0 +1,52 @@
+package needle
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/storage/types"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	key := util.GenCipherKey()
+	data := []byte("some data to encrypt")
+
+	n := &Needle{Id: types.NeedleId(123), Cookie: types.Cookie(456), Data: data}
+	n.Checksum = NewCRC(data)
+	etag := n.Etag()
+	if err := n.Encrypt(key); err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if !n.IsEncrypted() || bytes.Equal(n.Data, data) {
+		t.Fatalf("needle data is not encrypted")
+	}
+
+	writeBytes, _, _, err := n.prepareWriteBuffer(Version3)
+	if err != nil {
+		t.Fatalf("prepareWriteBuffer: %v", err)
+	}
+	stored := new(Needle)
+	if err = stored.ReadBytes(writeBytes, 0, n.Size, Version3); err != nil {
+		t.Fatalf("ReadBytes: %v", err)
+	}
+	if !stored.IsEncrypted() {
+		t.Fatalf("stored needle should be encrypted")
+	}
+
+	moved := *stored
+	moved.Id = types.NeedleId(124)
+	if err = moved.Decrypt(key); err == nil {
+		t.Fatalf("decrypting a moved needle should fail")
+	}
+
+	if err = stored.Decrypt(key); err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if stored.IsEncrypted() || !bytes.Equal(stored.Data, data) || stored.DataSize != uint32(len(data)) {
+		t.Fatalf("unexpected decrypted needle %+v", stored)
+	}
+	if stored.Etag() != etag {
+		t.Fatalf("etag changed from %s to %s", etag, stored.Etag())
+	}
+}
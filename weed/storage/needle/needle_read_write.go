@@ -18,12 +18,14 @@ const (
 	FlagHasLastModifiedDate = 0x08
 	FlagHasTtl              = 0x10
 	FlagHasPairs            = 0x20
+	FlagIsEncrypted         = 0x40
 	FlagIsChunkManifest     = 0x80
 	LastModifiedBytesLength = 5
 	TtlBytesLength          = 2
 )
 
 var ErrorSizeMismatch = errors.New("size mismatch")
+var ErrorCrcMismatch = errors.New("CRC error! Data On Disk Corrupted")
 
 func (n *Needle) DiskSize(version Version) int64 {
 	return GetActualSize(n.Size, version)
@@ -127,6 +129,12 @@ func (n *Needle) prepareWriteBuffer(version Version) ([]byte, Size, int64, error
 }
 
 func (n *Needle) Append(w backend.BackendStorageFile, version Version) (offset uint64, size Size, actualSize int64, err error) {
+	offset, size, actualSize, _, err = n.AppendWithBlob(w, version)
+	return
+}
+
+// AppendWithBlob is the same as Append, and also returns the needle blob written.
+func (n *Needle) AppendWithBlob(w backend.BackendStorageFile, version Version) (offset uint64, size Size, actualSize int64, needleBlob []byte, err error) {
 
 	if end, _, e := w.GetStat(); e == nil {
 		defer func(w backend.BackendStorageFile, off int64) {
@@ -146,13 +154,13 @@ func (n *Needle) Append(w backend.BackendStorageFile, version Version) (offset u
 		return
 	}
 
-	bytesToWrite, size, actualSize, err := n.prepareWriteBuffer(version)
+	needleBlob, size, actualSize, err = n.prepareWriteBuffer(version)
 
 	if err == nil {
-		_, err = w.WriteAt(bytesToWrite, int64(offset))
+		_, err = w.WriteAt(needleBlob, int64(offset))
 	}
 
-	return offset, size, actualSize, err
+	return offset, size, actualSize, needleBlob, err
 }
 
 func WriteNeedleBlob(w backend.BackendStorageFile, dataSlice []byte, size Size, appendAtNs uint64, version Version) (offset uint64, err error) {
@@ -226,7 +234,7 @@ func (n *Needle) ReadBytes(bytes []byte, offset int64, size Size, version Versio
 		checksum := util.BytesToUint32(bytes[NeedleHeaderSize+size : NeedleHeaderSize+size+NeedleChecksumSize])
 		newChecksum := NewCRC(n.Data)
 		if checksum != newChecksum.Value() {
-			return errors.New("CRC error! Data On Disk Corrupted")
+			return ErrorCrcMismatch
 		}
 		n.Checksum = newChecksum
 	}
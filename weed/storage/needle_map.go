@@ -17,6 +17,7 @@ const (
 	NeedleMapLevelDb                     // small memory footprint, 4MB total, 1 write buffer, 3 block buffer
 	NeedleMapLevelDbMedium               // medium memory footprint, 8MB total, 3 write buffer, 5 block buffer
 	NeedleMapLevelDbLarge                // large memory footprint, 12MB total, 4write buffer, 8 block buffer
+	NeedleMapRocksDb                     // tunable block cache, no long compaction stalls, requires the rocksdb build tag
 )
 
 type NeedleMapper interface {
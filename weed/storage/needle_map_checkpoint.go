@@ -0,0 +1,272 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/storage/idx"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle_map"
+	. "github.com/chrislusf/seaweedfs/weed/storage/types"
+)
+
+/*
+An index checkpoint is a snapshot of the in memory needle map, saved in the .ckp file next to the .idx file.
+When loading the volume, the needle map is restored from the checkpoint,
+and only the .idx entries appended after the checkpoint are replayed, instead of the whole .idx file.
+
+The checkpoint remembers the .idx file size when it was taken, and the checksum of the .idx tail before that size.
+A checkpoint not matching the .idx file, e.g. after the volume is compacted, is ignored.
+
+The .ckp file layout:
+	magic "SWCK", idx offset, idx tail crc, the map metrics, entry count,
+	the live entries in the .idx entry format ordered by key,
+	and the crc of all the above.
+*/
+
+const (
+	indexCheckpointMagic    = "SWCK"
+	indexCheckpointTailSize = 4096
+)
+
+var (
+	indexCheckpointInterval time.Duration
+)
+
+// SetIndexCheckpointInterval enables saving the index checkpoints of the in memory needle maps, at most once per interval,
+// and when the volumes are closed. It should be called before NewStore.
+func SetIndexCheckpointInterval(interval time.Duration) {
+	indexCheckpointInterval = interval
+}
+
+type indexCheckpoint struct {
+	indexFileOffset int64
+	indexTailCrc    uint32
+	metric          mapMetric
+	entries         []byte
+}
+
+// snapshotCheckpoint should be called without concurrent writes to the needle map
+func (nm *NeedleMap) snapshotCheckpoint() (*indexCheckpoint, error) {
+	cp := &indexCheckpoint{
+		indexFileOffset: nm.indexFileOffset,
+		metric:          nm.mapMetric,
+	}
+	var err error
+	if cp.indexTailCrc, err = indexTailCrc(nm.indexFile, cp.indexFileOffset); err != nil {
+		return nil, err
+	}
+	var entries bytes.Buffer
+	if liveCount := nm.FileCount() - nm.DeletedCount(); liveCount > 0 {
+		entries.Grow(liveCount * NeedleMapEntrySize)
+	}
+	err = nm.m.AscendingVisit(func(value needle_map.NeedleValue) error {
+		_, err := entries.Write(value.ToBytes())
+		return err
+	})
+	cp.entries = entries.Bytes()
+	return cp, err
+}
+
+func indexTailCrc(indexFile io.ReaderAt, indexFileOffset int64) (uint32, error) {
+	start := indexFileOffset - indexCheckpointTailSize
+	if start < 0 {
+		start = 0
+	}
+	tail := make([]byte, indexFileOffset-start)
+	if _, err := indexFile.ReadAt(tail, start); err != nil && err != io.EOF {
+		return 0, err
+	}
+	return crc32.ChecksumIEEE(tail), nil
+}
+
+func (cp *indexCheckpoint) save(fileName string) error {
+	tmpFileName := fileName + ".tmp"
+	f, err := os.OpenFile(tmpFileName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFileName)
+
+	crc := crc32.NewIEEE()
+	w := bufio.NewWriter(io.MultiWriter(f, crc))
+	w.WriteString(indexCheckpointMagic)
+	header := make([]byte, 8+4+4+4+8+8+8+8)
+	binary.BigEndian.PutUint64(header[0:8], uint64(cp.indexFileOffset))
+	binary.BigEndian.PutUint32(header[8:12], cp.indexTailCrc)
+	binary.BigEndian.PutUint32(header[12:16], cp.metric.FileCounter)
+	binary.BigEndian.PutUint32(header[16:20], cp.metric.DeletionCounter)
+	binary.BigEndian.PutUint64(header[20:28], cp.metric.FileByteCounter)
+	binary.BigEndian.PutUint64(header[28:36], cp.metric.DeletionByteCounter)
+	binary.BigEndian.PutUint64(header[36:44], cp.metric.MaximumFileKey)
+	binary.BigEndian.PutUint64(header[44:52], uint64(len(cp.entries)/NeedleMapEntrySize))
+	w.Write(header)
+	w.Write(cp.entries)
+	if err = w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	checksum := make([]byte, 4)
+	binary.BigEndian.PutUint32(checksum, crc.Sum32())
+	if _, err = f.Write(checksum); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFileName, fileName)
+}
+
+func loadIndexCheckpoint(fileName string) (*indexCheckpoint, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	headerSize := len(indexCheckpointMagic) + 52
+	if len(data) < headerSize+4 || string(data[:len(indexCheckpointMagic)]) != indexCheckpointMagic {
+		return nil, fmt.Errorf("not an index checkpoint")
+	}
+	if crc32.ChecksumIEEE(data[:len(data)-4]) != binary.BigEndian.Uint32(data[len(data)-4:]) {
+		return nil, fmt.Errorf("checksum mismatch")
+	}
+	header := data[len(indexCheckpointMagic):headerSize]
+	cp := &indexCheckpoint{
+		indexFileOffset: int64(binary.BigEndian.Uint64(header[0:8])),
+		indexTailCrc:    binary.BigEndian.Uint32(header[8:12]),
+		metric: mapMetric{
+			FileCounter:         binary.BigEndian.Uint32(header[12:16]),
+			DeletionCounter:     binary.BigEndian.Uint32(header[16:20]),
+			FileByteCounter:     binary.BigEndian.Uint64(header[20:28]),
+			DeletionByteCounter: binary.BigEndian.Uint64(header[28:36]),
+			MaximumFileKey:      binary.BigEndian.Uint64(header[36:44]),
+		},
+		entries: data[headerSize : len(data)-4],
+	}
+	if count := binary.BigEndian.Uint64(header[44:52]); count*NeedleMapEntrySize != uint64(len(cp.entries)) {
+		return nil, fmt.Errorf("expecting %d entries, but got %d bytes", count, len(cp.entries))
+	}
+	return cp, nil
+}
+
+// LoadCompactNeedleMapFromCheckpoint loads the needle map from the checkpoint and the .idx entries after it,
+// or from the whole .idx file if the checkpoint is missing or does not match the .idx file.
+func LoadCompactNeedleMapFromCheckpoint(file *os.File, checkpointFileName string) (*NeedleMap, error) {
+	cp, err := loadIndexCheckpoint(checkpointFileName)
+	if err == nil {
+		err = cp.check(file)
+	}
+	if err != nil {
+		if !os.IsNotExist(err) {
+			glog.V(0).Infof("ignore index checkpoint %s: %v", checkpointFileName, err)
+		}
+		return LoadCompactNeedleMap(file)
+	}
+
+	nm := NewCompactNeedleMap(file)
+	nm.mapMetric = cp.metric
+	for i := 0; i+NeedleMapEntrySize <= len(cp.entries); i += NeedleMapEntrySize {
+		key, offset, size := idx.IdxFileEntry(cp.entries[i : i+NeedleMapEntrySize])
+		nm.m.Set(key, offset, size)
+	}
+	nm.checkpointOffset = cp.indexFileOffset
+	glog.V(1).Infof("loaded index checkpoint %s, replaying %d bytes of %s", checkpointFileName, nm.indexFileOffset-cp.indexFileOffset, file.Name())
+
+	return doLoadingFrom(file, cp.indexFileOffset, nm)
+}
+
+func (cp *indexCheckpoint) check(indexFile *os.File) error {
+	stat, err := indexFile.Stat()
+	if err != nil {
+		return err
+	}
+	if stat.Size() < cp.indexFileOffset {
+		return fmt.Errorf("index file size %d is smaller than the checkpoint %d", stat.Size(), cp.indexFileOffset)
+	}
+	tailCrc, err := indexTailCrc(indexFile, cp.indexFileOffset)
+	if err != nil {
+		return err
+	}
+	if tailCrc != cp.indexTailCrc {
+		return fmt.Errorf("index file is changed")
+	}
+	return nil
+}
+
+// saveIndexCheckpoint saves the checkpoint if the in memory needle map is changed since the last checkpoint.
+// The writes are blocked while taking the snapshot, but not while saving it.
+func (v *Volume) saveIndexCheckpoint() error {
+	v.dataFileAccessLock.Lock()
+	nm, ok := v.nm.(*NeedleMap)
+	if !ok || nm.indexFileOffset == nm.checkpointOffset {
+		v.dataFileAccessLock.Unlock()
+		return nil
+	}
+	cp, err := nm.snapshotCheckpoint()
+	v.dataFileAccessLock.Unlock()
+	if err != nil {
+		return err
+	}
+	return v.doSaveIndexCheckpoint(nm, cp)
+}
+
+// saveIndexCheckpointLocked is called when the volume is closed, with the dataFileAccessLock held
+func (v *Volume) saveIndexCheckpointLocked() {
+	nm, ok := v.nm.(*NeedleMap)
+	if !ok || nm.indexFileOffset == nm.checkpointOffset {
+		return
+	}
+	cp, err := nm.snapshotCheckpoint()
+	if err == nil {
+		err = v.doSaveIndexCheckpoint(nm, cp)
+	}
+	if err != nil {
+		glog.Warningf("save index checkpoint of volume %d: %v", v.Id, err)
+	}
+}
+
+func (v *Volume) doSaveIndexCheckpoint(nm *NeedleMap, cp *indexCheckpoint) error {
+	// the checkpoint should not be newer than the index file on disk
+	if err := nm.Sync(); err != nil {
+		return err
+	}
+	if err := cp.save(v.FileName(".ckp")); err != nil {
+		return err
+	}
+	nm.checkpointOffset = cp.indexFileOffset
+	return nil
+}
+
+func (s *Store) loopIndexCheckpoints() {
+	for {
+		time.Sleep(indexCheckpointInterval)
+		for _, location := range s.Locations {
+			var volumes []*Volume
+			location.volumesLock.RLock()
+			for _, v := range location.volumes {
+				volumes = append(volumes, v)
+			}
+			location.volumesLock.RUnlock()
+
+			for _, v := range volumes {
+				if v.isCompacting {
+					continue
+				}
+				if err := v.saveIndexCheckpoint(); err != nil {
+					glog.Warningf("save index checkpoint of volume %d: %v", v.Id, err)
+				}
+			}
+		}
+	}
+}
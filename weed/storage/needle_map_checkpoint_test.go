@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/chrislusf/seaweedfs/weed/storage/types"
+)
+
+func TestIndexCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	idxFileName, checkpointFileName := filepath.Join(dir, "1.idx"), filepath.Join(dir, "1.ckp")
+
+	idxFile, err := os.OpenFile(idxFileName, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	nm := NewCompactNeedleMap(idxFile)
+	for i := 1; i <= 1000; i++ {
+		nm.Put(NeedleId(i), Uint32ToOffset(uint32(i)), Size(i))
+		if i%7 == 0 {
+			nm.Delete(NeedleId(i/2), Uint32ToOffset(uint32(i)))
+		}
+	}
+	cp, err := nm.snapshotCheckpoint()
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if err = cp.save(checkpointFileName); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	// the entries after the checkpoint are replayed from the .idx file
+	for i := 900; i <= 1100; i++ {
+		nm.Put(NeedleId(i), Uint32ToOffset(uint32(i+1)), Size(i+1))
+	}
+	nm.Delete(NeedleId(3), Uint32ToOffset(uint32(0)))
+	nm.Close()
+
+	load := func(fromCheckpoint bool) *NeedleMap {
+		f, err := os.OpenFile(idxFileName, os.O_RDWR, 0644)
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		var loaded *NeedleMap
+		if fromCheckpoint {
+			loaded, err = LoadCompactNeedleMapFromCheckpoint(f, checkpointFileName)
+		} else {
+			loaded, err = LoadCompactNeedleMap(f)
+		}
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		return loaded
+	}
+
+	expected, actual := load(false), load(true)
+	defer expected.Close()
+	defer actual.Close()
+	if actual.checkpointOffset != cp.indexFileOffset {
+		t.Errorf("not loaded from checkpoint")
+	}
+	if expected.mapMetric != actual.mapMetric {
+		t.Errorf("metrics %+v, expected %+v", actual.mapMetric, expected.mapMetric)
+	}
+	for i := 1; i <= 1100; i++ {
+		e, expectedOk := expected.Get(NeedleId(i))
+		a, actualOk := actual.Get(NeedleId(i))
+		if expectedOk != actualOk || expectedOk && (e.Offset != a.Offset || e.Size != a.Size) {
+			t.Fatalf("needle %d: %+v %v, expected %+v %v", i, a, actualOk, e, expectedOk)
+		}
+	}
+
+	// a checkpoint not matching the .idx file is ignored
+	if err = ioutil.WriteFile(idxFileName, nil, 0644); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	empty := load(true)
+	defer empty.Close()
+	if empty.FileCount() != 0 || empty.checkpointOffset != 0 {
+		t.Errorf("loaded from a mismatched checkpoint")
+	}
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"github.com/chrislusf/seaweedfs/weed/storage/needle_map"
+	. "github.com/chrislusf/seaweedfs/weed/storage/types"
+)
+
+/*
+Tiny needles, e.g. metadata blobs or markers, can be kept inline in the leveldb index,
+in the value after the offset and size, so reading them does not seek the .dat file.
+
+The needles are still appended to the .dat file, which the replication, vacuum,
+erasure coding and backups work on, so the inline blob is only a copy of it.
+Any other writes or deletes of the needle replace the value with the plain offset and size,
+and the index regenerated from the .idx file has no inline blobs.
+*/
+
+var (
+	inlineNeedleSizeLimit int64
+)
+
+// SetInlineNeedleSizeLimit keeps the needles up to this actual size also in the leveldb needle maps.
+// It should be called before NewStore.
+func SetInlineNeedleSizeLimit(sizeLimit int64) {
+	inlineNeedleSizeLimit = sizeLimit
+}
+
+type inlineNeedleMapper interface {
+	PutInline(key NeedleId, offset Offset, size Size, needleBlob []byte) error
+	GetInline(key NeedleId) (element *needle_map.NeedleValue, needleBlob []byte, ok bool)
+}
+
+// putNeedleValue adds to the needle map, with the needle blob inline if it is small enough.
+func (v *Volume) putNeedleValue(key NeedleId, offset Offset, size Size, needleBlob []byte) error {
+	if inm, ok := v.nm.(inlineNeedleMapper); ok && len(needleBlob) > 0 && int64(len(needleBlob)) <= inlineNeedleSizeLimit {
+		return inm.PutInline(key, offset, size, needleBlob)
+	}
+	return v.nm.Put(key, offset, size)
+}
+
+// getNeedleValue looks up the needle map, also returning the inline needle blob, if any.
+func (v *Volume) getNeedleValue(key NeedleId) (nv *needle_map.NeedleValue, needleBlob []byte, ok bool) {
+	if inm, isInline := v.nm.(inlineNeedleMapper); isInline && inlineNeedleSizeLimit > 0 {
+		return inm.GetInline(key)
+	}
+	nv, ok = v.nm.Get(key)
+	return
+}
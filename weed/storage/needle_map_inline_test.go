@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+	"github.com/chrislusf/seaweedfs/weed/storage/super_block"
+	"github.com/chrislusf/seaweedfs/weed/storage/types"
+)
+
+func TestInlineNeedles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inline")
+	if err != nil {
+		t.Fatalf("temp dir creation: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	SetInlineNeedleSizeLimit(256)
+	defer SetInlineNeedleSizeLimit(0)
+
+	v, err := NewVolume(dir, dir, "", 1, NeedleMapLevelDb, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0)
+	if err != nil {
+		t.Fatalf("volume creation: %v", err)
+	}
+	defer v.Close()
+
+	tiny := &needle.Needle{Id: types.Uint64ToNeedleId(1), Cookie: 0x12345678, Data: []byte("tiny marker")}
+	tiny.Checksum = needle.NewCRC(tiny.Data)
+	large := &needle.Needle{Id: types.Uint64ToNeedleId(2), Cookie: 0x12345678, Data: make([]byte, 1024)}
+	large.Checksum = needle.NewCRC(large.Data)
+	for _, n := range []*needle.Needle{tiny, large} {
+		if _, _, _, err := v.writeNeedle2(n, false); err != nil {
+			t.Fatalf("write needle %s: %v", n.Id, err)
+		}
+	}
+
+	if _, blob, _ := v.getNeedleValue(large.Id); len(blob) > 0 {
+		t.Fatalf("large needle should not be inline")
+	}
+	nv, blob, _ := v.getNeedleValue(tiny.Id)
+	if int64(len(blob)) != needle.GetActualSize(nv.Size, v.Version()) {
+		t.Fatalf("unexpected inline blob size %d", len(blob))
+	}
+
+	// the tiny needle is read from the index, not the .dat file
+	dataOffset := nv.Offset.ToActualOffset() + types.NeedleHeaderSize + 4
+	v.DataBackend.WriteAt([]byte{'X'}, dataOffset)
+	n := &needle.Needle{Id: tiny.Id}
+	if _, err = v.readNeedle(n, nil); err != nil {
+		t.Fatalf("read tiny needle: %v", err)
+	}
+	if !bytes.Equal(n.Data, tiny.Data) {
+		t.Fatalf("unexpected tiny needle data %q", n.Data)
+	}
+
+	if _, err = v.deleteNeedle2(&needle.Needle{Id: tiny.Id, Cookie: tiny.Cookie}); err != nil {
+		t.Fatalf("delete tiny needle: %v", err)
+	}
+	if _, blob, _ := v.getNeedleValue(tiny.Id); len(blob) > 0 {
+		t.Fatalf("deleted needle should not be inline")
+	}
+	if _, err = v.readNeedle(&needle.Needle{Id: tiny.Id}, nil); err != ErrorDeleted {
+		t.Fatalf("expecting deleted needle, but got %v", err)
+	}
+}
@@ -89,18 +89,32 @@ func generateLevelDbFile(dbFileName string, indexFile *os.File) error {
 }
 
 func (m *LevelDbNeedleMap) Get(key NeedleId) (element *needle_map.NeedleValue, ok bool) {
+	element, _, ok = m.GetInline(key)
+	return
+}
+
+// GetInline also returns the needle blob kept in the value, if any.
+func (m *LevelDbNeedleMap) GetInline(key NeedleId) (element *needle_map.NeedleValue, needleBlob []byte, ok bool) {
 	bytes := make([]byte, NeedleIdSize)
 	NeedleIdToBytes(bytes[0:NeedleIdSize], key)
 	data, err := m.db.Get(bytes, nil)
-	if err != nil || len(data) != OffsetSize+SizeSize {
-		return nil, false
+	if err != nil || len(data) < OffsetSize+SizeSize {
+		return nil, nil, false
 	}
 	offset := BytesToOffset(data[0:OffsetSize])
 	size := BytesToSize(data[OffsetSize : OffsetSize+SizeSize])
-	return &needle_map.NeedleValue{Key: key, Offset: offset, Size: size}, true
+	if len(data) > OffsetSize+SizeSize {
+		needleBlob = data[OffsetSize+SizeSize:]
+	}
+	return &needle_map.NeedleValue{Key: key, Offset: offset, Size: size}, needleBlob, true
 }
 
 func (m *LevelDbNeedleMap) Put(key NeedleId, offset Offset, size Size) error {
+	return m.PutInline(key, offset, size, nil)
+}
+
+// PutInline also keeps the needle blob in the value, after the offset and size.
+func (m *LevelDbNeedleMap) PutInline(key NeedleId, offset Offset, size Size, needleBlob []byte) error {
 	var oldSize Size
 	if oldNeedle, ok := m.Get(key); ok {
 		oldSize = oldNeedle.Size
@@ -110,14 +124,22 @@ func (m *LevelDbNeedleMap) Put(key NeedleId, offset Offset, size Size) error {
 	if err := m.appendToIndexFile(key, offset, size); err != nil {
 		return fmt.Errorf("cannot write to indexfile %s: %v", m.indexFile.Name(), err)
 	}
-	return levelDbWrite(m.db, key, offset, size)
+	return levelDbWriteInline(m.db, key, offset, size, needleBlob)
 }
 
 func levelDbWrite(db *leveldb.DB, key NeedleId, offset Offset, size Size) error {
+	return levelDbWriteInline(db, key, offset, size, nil)
+}
+
+func levelDbWriteInline(db *leveldb.DB, key NeedleId, offset Offset, size Size, needleBlob []byte) error {
 
 	bytes := needle_map.ToBytes(key, offset, size)
+	value := bytes[NeedleIdSize : NeedleIdSize+OffsetSize+SizeSize]
+	if len(needleBlob) > 0 {
+		value = append(value, needleBlob...)
+	}
 
-	if err := db.Put(bytes[0:NeedleIdSize], bytes[NeedleIdSize:NeedleIdSize+OffsetSize+SizeSize], nil); err != nil {
+	if err := db.Put(bytes[0:NeedleIdSize], value, nil); err != nil {
 		return fmt.Errorf("failed to write leveldb: %v", err)
 	}
 	return nil
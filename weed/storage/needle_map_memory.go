@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"io"
 	"os"
 
 	"github.com/chrislusf/seaweedfs/weed/glog"
@@ -12,6 +13,8 @@ import (
 type NeedleMap struct {
 	baseNeedleMapper
 	m needle_map.NeedleValueMap
+
+	checkpointOffset int64 // the index file offset of the last checkpoint
 }
 
 func NewCompactNeedleMap(file *os.File) *NeedleMap {
@@ -33,7 +36,12 @@ func LoadCompactNeedleMap(file *os.File) (*NeedleMap, error) {
 }
 
 func doLoading(file *os.File, nm *NeedleMap) (*NeedleMap, error) {
-	e := idx.WalkIndexFile(file, func(key NeedleId, offset Offset, size Size) error {
+	return doLoadingFrom(file, 0, nm)
+}
+
+// doLoadingFrom replays the index file entries after the offset
+func doLoadingFrom(file *os.File, offset int64, nm *NeedleMap) (*NeedleMap, error) {
+	e := idx.WalkIndexFile(io.NewSectionReader(file, offset, nm.indexFileOffset-offset), func(key NeedleId, offset Offset, size Size) error {
 		nm.MaybeSetMaxFileKey(key)
 		if !offset.IsZero() && size.IsValid() {
 			nm.FileCounter++
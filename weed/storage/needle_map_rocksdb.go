@@ -0,0 +1,193 @@
+// +build rocksdb
+
+package storage
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/tecbot/gorocksdb"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/storage/idx"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle_map"
+	. "github.com/chrislusf/seaweedfs/weed/storage/types"
+)
+
+func init() {
+	newRocksDbNeedleMap = func(dbFileName string, indexFile *os.File, blockCacheSize uint64) (NeedleMapper, error) {
+		return NewRocksDbNeedleMapWithCache(dbFileName, indexFile, blockCacheSize)
+	}
+}
+
+var (
+	rocksDbBlockCache     *gorocksdb.Cache
+	rocksDbBlockCacheOnce sync.Once
+)
+
+type RocksDbNeedleMap struct {
+	baseNeedleMapper
+	dbFileName string
+	db         *gorocksdb.DB
+	opts       *gorocksdb.Options
+	ro         *gorocksdb.ReadOptions
+	wo         *gorocksdb.WriteOptions
+}
+
+func newRocksDbOptions(blockCacheSize uint64) *gorocksdb.Options {
+	rocksDbBlockCacheOnce.Do(func() {
+		rocksDbBlockCache = gorocksdb.NewLRUCache(blockCacheSize)
+	})
+	bbto := gorocksdb.NewDefaultBlockBasedTableOptions()
+	bbto.SetBlockCache(rocksDbBlockCache)
+	bbto.SetFilterPolicy(gorocksdb.NewBloomFilter(10))
+
+	opts := gorocksdb.NewDefaultOptions()
+	opts.SetBlockBasedTableFactory(bbto)
+	opts.SetCreateIfMissing(true)
+	// compact in smaller steps, to avoid long stalls
+	opts.SetLevelCompactionDynamicLevelBytes(true)
+	return opts
+}
+
+func NewRocksDbNeedleMapWithCache(dbFileName string, indexFile *os.File, blockCacheSize uint64) (m *RocksDbNeedleMap, err error) {
+	m = &RocksDbNeedleMap{
+		dbFileName: dbFileName,
+		opts:       newRocksDbOptions(blockCacheSize),
+		ro:         gorocksdb.NewDefaultReadOptions(),
+		wo:         gorocksdb.NewDefaultWriteOptions(),
+	}
+	m.indexFile = indexFile
+	// rocksdb also keeps a LOG file in the db folder
+	if !isLevelDbFresh(dbFileName, indexFile) {
+		glog.V(1).Infof("Start to Generate %s from %s", dbFileName, indexFile.Name())
+		os.RemoveAll(dbFileName)
+		if err = m.generateRocksDbFile(indexFile); err != nil {
+			return nil, fmt.Errorf("generate %s: %v", dbFileName, err)
+		}
+		glog.V(1).Infof("Finished Generating %s from %s", dbFileName, indexFile.Name())
+	} else {
+		glog.V(1).Infof("Opening %s...", dbFileName)
+		if m.db, err = gorocksdb.OpenDb(m.opts, dbFileName); err != nil {
+			return nil, err
+		}
+	}
+	if stat, err := indexFile.Stat(); err != nil {
+		glog.Fatalf("stat file %s: %v", indexFile.Name(), err)
+	} else {
+		m.indexFileOffset = stat.Size()
+	}
+	glog.V(1).Infof("Loading %s...", indexFile.Name())
+	mm, indexLoadError := newNeedleMapMetricFromIndexFile(indexFile)
+	if indexLoadError != nil {
+		m.Close()
+		return nil, indexLoadError
+	}
+	m.mapMetric = *mm
+	return
+}
+
+func (m *RocksDbNeedleMap) generateRocksDbFile(indexFile *os.File) (err error) {
+	if m.db, err = gorocksdb.OpenDb(m.opts, m.dbFileName); err != nil {
+		return err
+	}
+	batch := gorocksdb.NewWriteBatch()
+	defer batch.Destroy()
+	err = idx.WalkIndexFile(indexFile, func(key NeedleId, offset Offset, size Size) error {
+		bytes := needle_map.ToBytes(key, offset, size)
+		if !offset.IsZero() && size.IsValid() {
+			batch.Put(bytes[0:NeedleIdSize], bytes[NeedleIdSize:NeedleIdSize+OffsetSize+SizeSize])
+		} else {
+			batch.Delete(bytes[0:NeedleIdSize])
+		}
+		if batch.Count() >= 10000 {
+			if writeErr := m.db.Write(m.wo, batch); writeErr != nil {
+				return writeErr
+			}
+			batch.Clear()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return m.db.Write(m.wo, batch)
+}
+
+func (m *RocksDbNeedleMap) Get(key NeedleId) (element *needle_map.NeedleValue, ok bool) {
+	bytes := make([]byte, NeedleIdSize)
+	NeedleIdToBytes(bytes[0:NeedleIdSize], key)
+	data, err := m.db.Get(m.ro, bytes)
+	if err != nil {
+		return nil, false
+	}
+	defer data.Free()
+	if data.Size() != OffsetSize+SizeSize {
+		return nil, false
+	}
+	value := data.Data()
+	offset := BytesToOffset(value[0:OffsetSize])
+	size := BytesToSize(value[OffsetSize : OffsetSize+SizeSize])
+	return &needle_map.NeedleValue{Key: key, Offset: offset, Size: size}, true
+}
+
+func (m *RocksDbNeedleMap) Put(key NeedleId, offset Offset, size Size) error {
+	var oldSize Size
+	if oldNeedle, ok := m.Get(key); ok {
+		oldSize = oldNeedle.Size
+	}
+	m.logPut(key, oldSize, size)
+	// write to index file first
+	if err := m.appendToIndexFile(key, offset, size); err != nil {
+		return fmt.Errorf("cannot write to indexfile %s: %v", m.indexFile.Name(), err)
+	}
+	return m.write(key, offset, size)
+}
+
+func (m *RocksDbNeedleMap) write(key NeedleId, offset Offset, size Size) error {
+	bytes := needle_map.ToBytes(key, offset, size)
+	if err := m.db.Put(m.wo, bytes[0:NeedleIdSize], bytes[NeedleIdSize:NeedleIdSize+OffsetSize+SizeSize]); err != nil {
+		return fmt.Errorf("failed to write rocksdb: %v", err)
+	}
+	return nil
+}
+
+func (m *RocksDbNeedleMap) Delete(key NeedleId, offset Offset) error {
+	oldNeedle, found := m.Get(key)
+	if !found || oldNeedle.Size.IsDeleted() {
+		return nil
+	}
+	m.logDelete(oldNeedle.Size)
+
+	// write to index file first
+	if err := m.appendToIndexFile(key, offset, TombstoneFileSize); err != nil {
+		return err
+	}
+
+	return m.write(key, oldNeedle.Offset, -oldNeedle.Size)
+}
+
+func (m *RocksDbNeedleMap) Close() {
+	indexFileName := m.indexFile.Name()
+	if err := m.indexFile.Sync(); err != nil {
+		glog.Warningf("sync file %s failed: %v", indexFileName, err)
+	}
+	if err := m.indexFile.Close(); err != nil {
+		glog.Warningf("close index file %s failed: %v", indexFileName, err)
+	}
+
+	if m.db != nil {
+		m.db.Close()
+		m.db = nil
+		m.opts.Destroy()
+		m.ro.Destroy()
+		m.wo.Destroy()
+	}
+}
+
+func (m *RocksDbNeedleMap) Destroy() error {
+	m.Close()
+	os.Remove(m.indexFile.Name())
+	return os.RemoveAll(m.dbFileName)
+}
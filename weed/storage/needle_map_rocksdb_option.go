@@ -0,0 +1,23 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+)
+
+// RocksDbNeedleMapBlockCacheMB is the block cache size shared by all volumes using the rocksdb needle map
+var RocksDbNeedleMapBlockCacheMB = 64
+
+// set when built with the rocksdb tag
+var newRocksDbNeedleMap func(dbFileName string, indexFile *os.File, blockCacheSize uint64) (NeedleMapper, error)
+
+func IsRocksDbNeedleMapSupported() bool {
+	return newRocksDbNeedleMap != nil
+}
+
+func NewRocksDbNeedleMap(dbFileName string, indexFile *os.File) (NeedleMapper, error) {
+	if newRocksDbNeedleMap == nil {
+		return nil, fmt.Errorf("rocksdb needle map requires building with the rocksdb tag")
+	}
+	return newRocksDbNeedleMap(dbFileName, indexFile, uint64(RocksDbNeedleMapBlockCacheMB)*1024*1024)
+}
@@ -0,0 +1,54 @@
+// +build rocksdb
+
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/chrislusf/seaweedfs/weed/storage/types"
+)
+
+func TestRocksDbNeedleMap(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "seaweedfs_rocksdb_needle_map")
+	defer os.RemoveAll(dir)
+
+	indexFile, err := os.OpenFile(filepath.Join(dir, "1.idx"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("create index file: %v", err)
+	}
+	m, err := NewRocksDbNeedleMapWithCache(filepath.Join(dir, "1.rdb"), indexFile, 1024*1024)
+	if err != nil {
+		t.Fatalf("open rocksdb needle map: %v", err)
+	}
+	for i := 1; i <= 100; i++ {
+		if err = m.Put(NeedleId(i), ToOffset(int64(i*NeedlePaddingSize)), Size(i)); err != nil {
+			t.Fatalf("put %d: %v", i, err)
+		}
+	}
+	if err = m.Delete(NeedleId(50), ToOffset(int64(200*NeedlePaddingSize))); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	m.Close()
+
+	// regenerate from the index file
+	os.RemoveAll(filepath.Join(dir, "1.rdb"))
+	indexFile, _ = os.OpenFile(filepath.Join(dir, "1.idx"), os.O_RDWR, 0644)
+	m, err = NewRocksDbNeedleMapWithCache(filepath.Join(dir, "1.rdb"), indexFile, 1024*1024)
+	if err != nil {
+		t.Fatalf("reopen rocksdb needle map: %v", err)
+	}
+	defer m.Close()
+
+	if nv, ok := m.Get(NeedleId(7)); !ok || nv.Size != Size(7) {
+		t.Errorf("unexpected needle 7: %+v", nv)
+	}
+	if nv, ok := m.Get(NeedleId(50)); ok && !nv.Size.IsDeleted() {
+		t.Errorf("needle 50 should be deleted: %+v", nv)
+	}
+	if m.FileCount() != 100 || m.DeletedCount() != 1 {
+		t.Errorf("unexpected counts %d %d", m.FileCount(), m.DeletedCount())
+	}
+}
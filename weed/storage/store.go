@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"strings"
+	"sync"
 	"sync/atomic"
 
 	"google.golang.org/grpc"
@@ -45,6 +46,10 @@ type Store struct {
 	DeletedVolumesChan  chan master_pb.VolumeShortInformationMessage
 	NewEcShardsChan     chan master_pb.VolumeEcShardInformationMessage
 	DeletedEcShardsChan chan master_pb.VolumeEcShardInformationMessage
+	scrubber            *volumeScrubber
+	readCrcVerifier     *readCrcVerifier
+	standbyVolumes      map[needle.VolumeId]*StandbyVolume
+	standbyVolumesLock  sync.RWMutex
 }
 
 func (s *Store) String() (str string) {
@@ -54,18 +59,26 @@ func (s *Store) String() (str string) {
 
 func NewStore(grpcDialOption grpc.DialOption, port int, ip, publicUrl string, dirnames []string, maxVolumeCounts []int, minFreeSpacePercents []float32, idxFolder string, needleMapKind NeedleMapKind, diskTypes []DiskType) (s *Store) {
 	s = &Store{grpcDialOption: grpcDialOption, Port: port, Ip: ip, PublicUrl: publicUrl, NeedleMapKind: needleMapKind}
+	s.NewVolumesChan = make(chan master_pb.VolumeShortInformationMessage, 3)
+	s.DeletedVolumesChan = make(chan master_pb.VolumeShortInformationMessage, 3)
+
+	s.NewEcShardsChan = make(chan master_pb.VolumeEcShardInformationMessage, 3)
+	s.DeletedEcShardsChan = make(chan master_pb.VolumeEcShardInformationMessage, 3)
+
 	s.Locations = make([]*DiskLocation, 0)
 	for i := 0; i < len(dirnames); i++ {
 		location := NewDiskLocation(dirnames[i], maxVolumeCounts[i], minFreeSpacePercents[i], idxFolder, diskTypes[i])
-		location.loadExistingVolumes(needleMapKind)
-		s.Locations = append(s.Locations, location)
+		location.onFailure = s.reportFailedLocation
 		stats.VolumeServerMaxVolumeCounter.Add(float64(maxVolumeCounts[i]))
+		if err := location.checkHealth(); err == nil {
+			location.loadExistingVolumes(needleMapKind)
+		}
+		s.Locations = append(s.Locations, location)
 	}
-	s.NewVolumesChan = make(chan master_pb.VolumeShortInformationMessage, 3)
-	s.DeletedVolumesChan = make(chan master_pb.VolumeShortInformationMessage, 3)
 
-	s.NewEcShardsChan = make(chan master_pb.VolumeEcShardInformationMessage, 3)
-	s.DeletedEcShardsChan = make(chan master_pb.VolumeEcShardInformationMessage, 3)
+	if indexCheckpointInterval > 0 {
+		go s.loopIndexCheckpoints()
+	}
 
 	return
 }
@@ -216,6 +229,10 @@ func (s *Store) CollectHeartbeat() *master_pb.Heartbeat {
 		maxVolumeCounts[string(location.DiskType)] += uint32(location.MaxVolumeCount)
 		location.volumesLock.RLock()
 		for _, v := range location.volumes {
+			if v.IsLiveCopy() {
+				// the master should not assign the writes to it, nor return it for the reads
+				continue
+			}
 			curMaxFileKey, volumeMessage := v.ToVolumeInformationMessage()
 			if volumeMessage == nil {
 				continue
@@ -315,6 +332,7 @@ func (s *Store) Close() {
 	for _, location := range s.Locations {
 		location.Close()
 	}
+	s.closeStandbyVolumes()
 }
 
 func (s *Store) WriteVolumeNeedle(i needle.VolumeId, n *needle.Needle, fsync bool) (isUnchanged bool, err error) {
@@ -323,6 +341,7 @@ func (s *Store) WriteVolumeNeedle(i needle.VolumeId, n *needle.Needle, fsync boo
 			err = fmt.Errorf("volume %d is read only", i)
 			return
 		}
+		v.location.clientIo.write(int64(len(n.Data)))
 		_, _, isUnchanged, err = v.writeNeedle2(n, fsync)
 		return
 	}
@@ -331,11 +350,34 @@ func (s *Store) WriteVolumeNeedle(i needle.VolumeId, n *needle.Needle, fsync boo
 	return
 }
 
+// ExtendVolumeNeedleTtl restarts the ttl of the needle, returning the ttl, which is empty if the needle does not expire.
+func (s *Store) ExtendVolumeNeedleTtl(i needle.VolumeId, n *needle.Needle) (*needle.TTL, error) {
+	if v := s.findVolume(i); v != nil {
+		if v.IsReadOnly() {
+			return nil, fmt.Errorf("volume %d is read only", i)
+		}
+		v.location.clientIo.write(0)
+		return v.extendNeedleTtl(n)
+	}
+	return nil, fmt.Errorf("volume %d not found on %s:%d", i, s.Ip, s.Port)
+}
+
+// WriteVolumeNeedleFromSource writes the needle tailed from the source of a live copy,
+// which is read only to the clients until caught up.
+func (s *Store) WriteVolumeNeedleFromSource(i needle.VolumeId, n *needle.Needle) (err error) {
+	if v := s.findVolume(i); v != nil {
+		_, _, _, err = v.writeNeedle2(n, false)
+		return
+	}
+	return fmt.Errorf("volume %d not found on %s:%d", i, s.Ip, s.Port)
+}
+
 func (s *Store) DeleteVolumeNeedle(i needle.VolumeId, n *needle.Needle) (Size, error) {
 	if v := s.findVolume(i); v != nil {
 		if v.noWriteOrDelete {
 			return 0, fmt.Errorf("volume %d is read only", i)
 		}
+		v.location.clientIo.write(0)
 		return v.deleteNeedle2(n)
 	}
 	return 0, fmt.Errorf("volume %d not found on %s:%d", i, s.Ip, s.Port)
@@ -343,7 +385,14 @@ func (s *Store) DeleteVolumeNeedle(i needle.VolumeId, n *needle.Needle) (Size, e
 
 func (s *Store) ReadVolumeNeedle(i needle.VolumeId, n *needle.Needle, readOption *ReadOption) (int, error) {
 	if v := s.findVolume(i); v != nil {
-		return v.readNeedle(n, readOption)
+		count, err := v.readNeedle(n, readOption)
+		v.location.clientIo.read(int64(len(n.Data)))
+		if err == nil || err == needle.ErrorCrcMismatch {
+			err = s.checkReadCrc(v.Id, v.Collection, false, n.Id, err, func() error {
+				return s.repairVolumeNeedle(v, n.Id)
+			})
+		}
+		return count, err
 	}
 	return 0, fmt.Errorf("volume %d not found", i)
 }
@@ -373,24 +422,39 @@ func (s *Store) MarkVolumeWritable(i needle.VolumeId) error {
 		return fmt.Errorf("volume %d not found", i)
 	}
 	v.noWriteLock.Lock()
+	wasLiveCopy := v.isLiveCopy
 	v.noWriteOrDelete = false
+	v.isLiveCopy = false
 	v.noWriteLock.Unlock()
+	if wasLiveCopy {
+		glog.V(0).Infof("live copy of volume %d is complete", i)
+		s.NewVolumesChan <- newVolumeShortInformationMessage(v)
+	}
 	return nil
 }
 
 func (s *Store) MountVolume(i needle.VolumeId) error {
+	return s.mountVolume(i, false)
+}
+
+// MountLiveCopyVolume mounts the volume read only, and does not report it to the master until marked writable
+func (s *Store) MountLiveCopyVolume(i needle.VolumeId) error {
+	return s.mountVolume(i, true)
+}
+
+func (s *Store) mountVolume(i needle.VolumeId, isLiveCopy bool) error {
 	for _, location := range s.Locations {
 		if found := location.LoadVolume(i, s.NeedleMapKind); found == true {
 			glog.V(0).Infof("mount volume %d", i)
 			v := s.findVolume(i)
-			s.NewVolumesChan <- master_pb.VolumeShortInformationMessage{
-				Id:               uint32(v.Id),
-				Collection:       v.Collection,
-				ReplicaPlacement: uint32(v.ReplicaPlacement.Byte()),
-				Version:          uint32(v.Version()),
-				Ttl:              v.Ttl.ToUint32(),
-				DiskType:         string(v.location.DiskType),
+			if isLiveCopy {
+				v.noWriteLock.Lock()
+				v.noWriteOrDelete = true
+				v.isLiveCopy = true
+				v.noWriteLock.Unlock()
+				return nil
 			}
+			s.NewVolumesChan <- newVolumeShortInformationMessage(v)
 			return nil
 		}
 	}
@@ -398,6 +462,18 @@ func (s *Store) MountVolume(i needle.VolumeId) error {
 	return fmt.Errorf("volume %d not found on disk", i)
 }
 
+func newVolumeShortInformationMessage(v *Volume) master_pb.VolumeShortInformationMessage {
+	return master_pb.VolumeShortInformationMessage{
+		Id:               uint32(v.Id),
+		Collection:       v.Collection,
+		ReadOnly:         v.IsReadOnly(),
+		ReplicaPlacement: uint32(v.ReplicaPlacement.Byte()),
+		Version:          uint32(v.Version()),
+		Ttl:              v.Ttl.ToUint32(),
+		DiskType:         string(v.location.DiskType),
+	}
+}
+
 func (s *Store) UnmountVolume(i needle.VolumeId) error {
 	v := s.findVolume(i)
 	if v == nil {
@@ -411,11 +487,14 @@ func (s *Store) UnmountVolume(i needle.VolumeId) error {
 		Ttl:              v.Ttl.ToUint32(),
 		DiskType:         string(v.location.DiskType),
 	}
+	isLiveCopy := v.IsLiveCopy()
 
 	for _, location := range s.Locations {
 		if err := location.UnloadVolume(i); err == nil {
 			glog.V(0).Infof("UnmountVolume %d", i)
-			s.DeletedVolumesChan <- message
+			if !isLiveCopy {
+				s.DeletedVolumesChan <- message
+			}
 			return nil
 		}
 	}
@@ -436,10 +515,14 @@ func (s *Store) DeleteVolume(i needle.VolumeId) error {
 		Ttl:              v.Ttl.ToUint32(),
 		DiskType:         string(v.location.DiskType),
 	}
+	isLiveCopy := v.IsLiveCopy()
 	for _, location := range s.Locations {
 		if err := location.DeleteVolume(i); err == nil {
 			glog.V(0).Infof("DeleteVolume %d", i)
-			s.DeletedVolumesChan <- message
+			if !isLiveCopy {
+				// the master does not know the live copy
+				s.DeletedVolumesChan <- message
+			}
 			return nil
 		} else {
 			glog.Errorf("DeleteVolume %d: %v", i, err)
@@ -488,7 +571,7 @@ func (s *Store) MaybeAdjustVolumeMax() (hasChanges bool) {
 		return
 	}
 	for _, diskLocation := range s.Locations {
-		if diskLocation.OriginalMaxVolumeCount == 0 {
+		if diskLocation.OriginalMaxVolumeCount == 0 && !diskLocation.IsFailed() {
 			currentMaxVolumeCount := diskLocation.MaxVolumeCount
 			diskStatus := stats.NewDiskStatus(diskLocation.Directory)
 			unusedSpace := diskLocation.UnUsedSpace(volumeSizeLimit)
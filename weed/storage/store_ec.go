@@ -53,12 +53,18 @@ func (s *Store) MountEcShards(collection string, vid needle.VolumeId, shardId er
 			glog.V(0).Infof("MountEcShards %d.%d", vid, shardId)
 
 			var shardBits erasure_coding.ShardBits
+			layout := erasure_coding.DefaultEcLayout
+			if ecVolume, found := location.FindEcVolume(vid); found {
+				layout = ecVolume.Layout
+			}
 
 			s.NewEcShardsChan <- master_pb.VolumeEcShardInformationMessage{
-				Id:          uint32(vid),
-				Collection:  collection,
-				EcIndexBits: uint32(shardBits.AddShardId(shardId)),
-				DiskType:    string(location.DiskType),
+				Id:           uint32(vid),
+				Collection:   collection,
+				EcIndexBits:  uint32(shardBits.AddShardId(shardId)),
+				DiskType:     string(location.DiskType),
+				DataShards:   uint32(layout.DataShards),
+				ParityShards: uint32(layout.ParityShards),
 			}
 			return nil
 		} else if err == os.ErrNotExist {
@@ -79,11 +85,17 @@ func (s *Store) UnmountEcShards(vid needle.VolumeId, shardId erasure_coding.Shar
 	}
 
 	var shardBits erasure_coding.ShardBits
+	layout := erasure_coding.DefaultEcLayout
+	if ecVolume, found := s.FindEcVolume(vid); found {
+		layout = ecVolume.Layout
+	}
 	message := master_pb.VolumeEcShardInformationMessage{
-		Id:          uint32(vid),
-		Collection:  ecShard.Collection,
-		EcIndexBits: uint32(shardBits.AddShardId(shardId)),
-		DiskType:    string(ecShard.DiskType),
+		Id:           uint32(vid),
+		Collection:   ecShard.Collection,
+		EcIndexBits:  uint32(shardBits.AddShardId(shardId)),
+		DiskType:     string(ecShard.DiskType),
+		DataShards:   uint32(layout.DataShards),
+		ParityShards: uint32(layout.ParityShards),
 	}
 
 	for _, location := range s.Locations {
@@ -139,6 +151,7 @@ func (s *Store) ReadEcShardNeedle(vid needle.VolumeId, n *needle.Needle) (int, e
 				glog.V(3).Infof("ReadEcShardNeedle needle id %s intervals:%+v", n.String(), intervals)
 			}
 			bytes, isDeleted, err := s.readEcShardIntervals(vid, n.Id, localEcVolume, intervals)
+			location.clientIo.read(int64(len(bytes)))
 			if err != nil {
 				return 0, fmt.Errorf("ReadEcShardIntervals: %v", err)
 			}
@@ -147,9 +160,20 @@ func (s *Store) ReadEcShardNeedle(vid needle.VolumeId, n *needle.Needle) (int, e
 			}
 
 			err = n.ReadBytes(bytes, offset.ToActualOffset(), size, localEcVolume.Version)
+			err = s.checkReadCrc(vid, localEcVolume.Collection, true, n.Id, err, func() error {
+				return s.repairEcNeedle(localEcVolume, n.Id)
+			})
+			if err == ErrorCorrupted {
+				return 0, err
+			}
 			if err != nil {
 				return 0, fmt.Errorf("readbytes: %v", err)
 			}
+			if n.IsEncrypted() {
+				if err = n.Decrypt(atRestVolumeKey(localEcVolume.Collection, vid)); err != nil {
+					return 0, err
+				}
+			}
 
 			return len(bytes), nil
 		}
@@ -223,11 +247,12 @@ func forgetShardId(ecVolume *erasure_coding.EcVolume, shardId erasure_coding.Sha
 func (s *Store) cachedLookupEcShardLocations(ecVolume *erasure_coding.EcVolume) (err error) {
 
 	shardCount := len(ecVolume.ShardLocations)
-	if shardCount < erasure_coding.DataShardsCount &&
+	dataShards := ecVolume.Layout.DataShards
+	if shardCount < dataShards &&
 		ecVolume.ShardLocationsRefreshTime.Add(11*time.Second).After(time.Now()) ||
-		shardCount == erasure_coding.TotalShardsCount &&
+		shardCount == ecVolume.Layout.TotalShards() &&
 			ecVolume.ShardLocationsRefreshTime.Add(37*time.Minute).After(time.Now()) ||
-		shardCount >= erasure_coding.DataShardsCount &&
+		shardCount >= dataShards &&
 			ecVolume.ShardLocationsRefreshTime.Add(7*time.Minute).After(time.Now()) {
 		// still fresh
 		return nil
@@ -243,8 +268,8 @@ func (s *Store) cachedLookupEcShardLocations(ecVolume *erasure_coding.EcVolume)
 		if err != nil {
 			return fmt.Errorf("lookup ec volume %d: %v", ecVolume.VolumeId, err)
 		}
-		if len(resp.ShardIdLocations) < erasure_coding.DataShardsCount {
-			return fmt.Errorf("only %d shards found but %d required", len(resp.ShardIdLocations), erasure_coding.DataShardsCount)
+		if len(resp.ShardIdLocations) < dataShards {
+			return fmt.Errorf("only %d shards found but %d required", len(resp.ShardIdLocations), dataShards)
 		}
 
 		ecVolume.ShardLocationsLock.Lock()
@@ -324,19 +349,19 @@ func (s *Store) doReadRemoteEcShardInterval(sourceDataNode string, needleId type
 func (s *Store) recoverOneRemoteEcShardInterval(needleId types.NeedleId, ecVolume *erasure_coding.EcVolume, shardIdToRecover erasure_coding.ShardId, buf []byte, offset int64) (n int, is_deleted bool, err error) {
 	glog.V(3).Infof("recover ec shard %d.%d from other locations", ecVolume.VolumeId, shardIdToRecover)
 
-	enc, err := reedsolomon.New(erasure_coding.DataShardsCount, erasure_coding.ParityShardsCount)
+	enc, err := reedsolomon.New(ecVolume.Layout.DataShards, ecVolume.Layout.ParityShards)
 	if err != nil {
 		return 0, false, fmt.Errorf("failed to create encoder: %v", err)
 	}
 
-	bufs := make([][]byte, erasure_coding.TotalShardsCount)
+	bufs := make([][]byte, ecVolume.Layout.TotalShards())
 
 	var wg sync.WaitGroup
 	ecVolume.ShardLocationsLock.RLock()
 	for shardId, locations := range ecVolume.ShardLocations {
 
-		// skip currnent shard or empty shard
-		if shardId == shardIdToRecover {
+		// skip currnent shard or empty shard, or shards not in the layout
+		if shardId == shardIdToRecover || int(shardId) >= len(bufs) {
 			continue
 		}
 		if len(locations) == 0 {
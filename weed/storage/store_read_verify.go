@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/stats"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+	. "github.com/chrislusf/seaweedfs/weed/storage/types"
+)
+
+/*
+The CRC of a needle is always checked when the whole needle is read.
+With the read crc verification enabled, a needle failing the check is marked as corrupted,
+and the read fails with ErrorCorrupted instead of looking like a missing needle,
+so the clients can read from another replica. The needle blobs sent to other volume servers,
+e.g. for volume.check.disk or scrub repairs, are also verified, to avoid spreading the corruption.
+
+A marked needle is repaired in background from the other replicas or ec shards,
+the same way as the scrubber does. The mark is removed once the needle is read correctly.
+*/
+
+var ErrorCorrupted = errors.New("needle is corrupted")
+
+type CorruptNeedle struct {
+	VolumeId    needle.VolumeId
+	Collection  string
+	IsEcVolume  bool
+	NeedleId    string
+	FoundAt     time.Time
+	RepairError string
+}
+
+type corruptNeedleKey struct {
+	vid needle.VolumeId
+	key NeedleId
+}
+
+type readCrcVerifier struct {
+	corruptNeedles     map[corruptNeedleKey]*CorruptNeedle
+	corruptNeedlesLock sync.RWMutex
+}
+
+func (s *Store) EnableReadCrcVerification() {
+	s.readCrcVerifier = &readCrcVerifier{
+		corruptNeedles: make(map[corruptNeedleKey]*CorruptNeedle),
+	}
+}
+
+// CorruptNeedles lists the needles marked as corrupted, or nil if the read crc verification is not enabled.
+func (s *Store) CorruptNeedles() (corruptNeedles []*CorruptNeedle) {
+	rv := s.readCrcVerifier
+	if rv == nil {
+		return nil
+	}
+	rv.corruptNeedlesLock.RLock()
+	defer rv.corruptNeedlesLock.RUnlock()
+	for _, cn := range rv.corruptNeedles {
+		t := *cn
+		corruptNeedles = append(corruptNeedles, &t)
+	}
+	sort.Slice(corruptNeedles, func(i, j int) bool {
+		if corruptNeedles[i].VolumeId != corruptNeedles[j].VolumeId {
+			return corruptNeedles[i].VolumeId < corruptNeedles[j].VolumeId
+		}
+		return corruptNeedles[i].NeedleId < corruptNeedles[j].NeedleId
+	})
+	return
+}
+
+// VerifyNeedleBlob checks the CRC of a needle blob read from the volume, before sending it out.
+func (s *Store) VerifyNeedleBlob(v *Volume, key NeedleId, needleBlob []byte, size Size) error {
+	if s.readCrcVerifier == nil || size.IsDeleted() || size == 0 {
+		return nil
+	}
+	n := new(needle.Needle)
+	err := n.ReadBytes(needleBlob, 0, size, v.Version())
+	return s.checkReadCrc(v.Id, v.Collection, false, key, err, func() error {
+		return s.repairVolumeNeedle(v, key)
+	})
+}
+
+// checkReadCrc marks the needle on CRC errors and starts the repair, or unmarks it after a good read.
+func (s *Store) checkReadCrc(vid needle.VolumeId, collection string, isEcVolume bool, key NeedleId, err error, repairFn func() error) error {
+	rv := s.readCrcVerifier
+	if rv == nil {
+		return err
+	}
+	nk := corruptNeedleKey{vid: vid, key: key}
+	if err == nil {
+		rv.unmark(nk)
+		return nil
+	}
+	if err != needle.ErrorCrcMismatch {
+		return err
+	}
+
+	if !rv.mark(nk, &CorruptNeedle{
+		VolumeId:   vid,
+		Collection: collection,
+		IsEcVolume: isEcVolume,
+		NeedleId:   key.String(),
+		FoundAt:    time.Now(),
+	}) {
+		return ErrorCorrupted
+	}
+
+	volumeType := "volume"
+	if isEcVolume {
+		volumeType = "ec_shards"
+	}
+	stats.VolumeServerCorruptNeedleCounter.WithLabelValues(collection, volumeType).Inc()
+	glog.Errorf("volume %d needle %s: %v", vid, key, err)
+
+	go func() {
+		if repairErr := repairFn(); repairErr != nil {
+			glog.Errorf("repair volume %d needle %s: %v", vid, key, repairErr)
+			rv.corruptNeedlesLock.Lock()
+			if cn, found := rv.corruptNeedles[nk]; found {
+				cn.RepairError = repairErr.Error()
+			}
+			rv.corruptNeedlesLock.Unlock()
+			return
+		}
+		glog.V(0).Infof("repaired volume %d needle %s", vid, key)
+		rv.unmark(nk)
+	}()
+
+	return ErrorCorrupted
+}
+
+// mark returns true if the needle is not marked yet.
+func (rv *readCrcVerifier) mark(nk corruptNeedleKey, cn *CorruptNeedle) bool {
+	rv.corruptNeedlesLock.Lock()
+	defer rv.corruptNeedlesLock.Unlock()
+	if _, found := rv.corruptNeedles[nk]; found {
+		return false
+	}
+	rv.corruptNeedles[nk] = cn
+	return true
+}
+
+func (rv *readCrcVerifier) unmark(nk corruptNeedleKey) {
+	rv.corruptNeedlesLock.RLock()
+	_, found := rv.corruptNeedles[nk]
+	rv.corruptNeedlesLock.RUnlock()
+	if !found {
+		return
+	}
+	rv.corruptNeedlesLock.Lock()
+	delete(rv.corruptNeedles, nk)
+	rv.corruptNeedlesLock.Unlock()
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+	. "github.com/chrislusf/seaweedfs/weed/storage/types"
+)
+
+func TestReadCrcVerification(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crc")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := NewStore(nil, 8080, "localhost", "", []string{dir}, []int{1}, []float32{0}, "", NeedleMapInMemory, []DiskType{HardDriveType})
+	defer s.Close()
+	if err := s.AddVolume(1, "", NeedleMapInMemory, "000", "", 0, 0, HardDriveType); err != nil {
+		t.Fatalf("add volume: %v", err)
+	}
+	<-s.NewVolumesChan
+	v := s.GetVolume(1)
+
+	for i := 1; i <= 3; i++ {
+		n := newRandomNeedle(uint64(i))
+		n.Data = append(n.Data, make([]byte, 16)...)
+		n.Checksum = needle.NewCRC(n.Data)
+		if _, _, _, err := v.writeNeedle2(n, false); err != nil {
+			t.Fatalf("write file %d: %v", i, err)
+		}
+	}
+
+	corruptedKey := Uint64ToNeedleId(2)
+	goodBlob, size, err := v.ReadNeedleBlobById(corruptedKey)
+	if err != nil {
+		t.Fatalf("read needle blob: %v", err)
+	}
+	nv, _ := v.nm.Get(corruptedKey)
+	dataOffset := nv.Offset.ToActualOffset() + NeedleHeaderSize + 4
+	b := make([]byte, 1)
+	v.DataBackend.ReadAt(b, dataOffset)
+	b[0] ^= 0xff
+	v.DataBackend.WriteAt(b, dataOffset)
+
+	// without the verification, the CRC error is returned as is
+	if _, err = s.ReadVolumeNeedle(1, &needle.Needle{Id: corruptedKey}, nil); err != needle.ErrorCrcMismatch {
+		t.Fatalf("expecting crc mismatch, but got %v", err)
+	}
+
+	s.EnableReadCrcVerification()
+	if _, err = s.ReadVolumeNeedle(1, &needle.Needle{Id: corruptedKey}, nil); err != ErrorCorrupted {
+		t.Fatalf("expecting corrupted needle, but got %v", err)
+	}
+	if _, err = s.ReadVolumeNeedle(1, &needle.Needle{Id: Uint64ToNeedleId(1)}, nil); err != nil {
+		t.Fatalf("read good needle: %v", err)
+	}
+	corruptBlob, _, _ := v.ReadNeedleBlobById(corruptedKey)
+	if err = s.VerifyNeedleBlob(v, corruptedKey, corruptBlob, size); err != ErrorCorrupted {
+		t.Fatalf("expecting corrupted needle blob, but got %v", err)
+	}
+	if corruptNeedles := s.CorruptNeedles(); len(corruptNeedles) != 1 || corruptNeedles[0].NeedleId != corruptedKey.String() {
+		t.Fatalf("unexpected corrupt needles %+v", corruptNeedles)
+	}
+
+	if err = v.repairNeedle(corruptedKey, goodBlob); err != nil {
+		t.Fatalf("repair: %v", err)
+	}
+	if _, err = s.ReadVolumeNeedle(1, &needle.Needle{Id: corruptedKey}, nil); err != nil {
+		t.Fatalf("read repaired needle: %v", err)
+	}
+	if corruptNeedles := s.CorruptNeedles(); len(corruptNeedles) != 0 {
+		t.Errorf("repaired needle should be unmarked, but got %+v", corruptNeedles)
+	}
+}
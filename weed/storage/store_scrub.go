@@ -0,0 +1,427 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/pb/master_pb"
+	"github.com/chrislusf/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/chrislusf/seaweedfs/weed/storage/erasure_coding"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+	. "github.com/chrislusf/seaweedfs/weed/storage/types"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+The scrubber slowly reads through all needles on this volume server, and verifies their CRC.
+
+For a normal volume, each live needle in the .idx file is read and checked.
+A corrupted needle is repaired by copying a good copy from another replica, if the volume is replicated.
+
+For an ec volume, a needle is checked by the server holding the shard of its first interval,
+so each needle is only checked once in the cluster. If the CRC does not match,
+the intervals on local shards are recovered from the other shards, and written back if
+the recovered needle is good.
+
+The scrub speed is throttled, and each volume is scrubbed at most once per interval.
+*/
+
+type VolumeScrubStatus struct {
+	VolumeId      needle.VolumeId
+	Collection    string
+	IsEcVolume    bool
+	NeedleCount   uint64
+	ScannedCount  uint64
+	CorruptCount  uint64
+	RepairedCount uint64
+	StartedAt     time.Time
+	CompletedAt   time.Time
+	LastError     string
+}
+
+type volumeScrubber struct {
+	store        *Store
+	throttler    *util.WriteThrottler
+	interval     time.Duration
+	statuses     map[needle.VolumeId]*VolumeScrubStatus
+	statusesLock sync.RWMutex
+}
+
+// StartScrubbing starts the background scrubber, reading at most bytesPerSecond,
+// and scrubbing each volume once per interval.
+func (s *Store) StartScrubbing(bytesPerSecond int64, interval time.Duration) {
+	s.scrubber = &volumeScrubber{
+		store:     s,
+		throttler: util.NewWriteThrottler(bytesPerSecond),
+		interval:  interval,
+		statuses:  make(map[needle.VolumeId]*VolumeScrubStatus),
+	}
+	go s.scrubber.loop()
+}
+
+// ScrubStatuses returns the scrub status of each volume, or nil if the scrubber is not started.
+func (s *Store) ScrubStatuses() (statuses []*VolumeScrubStatus) {
+	if s.scrubber == nil {
+		return nil
+	}
+	s.scrubber.statusesLock.RLock()
+	defer s.scrubber.statusesLock.RUnlock()
+	for _, status := range s.scrubber.statuses {
+		t := *status
+		statuses = append(statuses, &t)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].VolumeId < statuses[j].VolumeId
+	})
+	return
+}
+
+func (s *Store) IsScrubbing() bool {
+	return s.scrubber != nil
+}
+
+func (sc *volumeScrubber) loop() {
+	for {
+		// also wait for the master connection on start
+		time.Sleep(time.Minute)
+		for _, location := range sc.store.Locations {
+			var volumes []*Volume
+			location.volumesLock.RLock()
+			for _, v := range location.volumes {
+				volumes = append(volumes, v)
+			}
+			location.volumesLock.RUnlock()
+			var ecVolumes []*erasure_coding.EcVolume
+			location.ecVolumesLock.RLock()
+			for _, ev := range location.ecVolumes {
+				ecVolumes = append(ecVolumes, ev)
+			}
+			location.ecVolumesLock.RUnlock()
+
+			for _, v := range volumes {
+				if sc.isDue(v.Id) {
+					sc.scrubVolume(v)
+				}
+			}
+			for _, ev := range ecVolumes {
+				if sc.isDue(ev.VolumeId) {
+					sc.scrubEcVolume(ev)
+				}
+			}
+		}
+		sc.removeStaleStatuses()
+	}
+}
+
+func (sc *volumeScrubber) isDue(vid needle.VolumeId) bool {
+	sc.statusesLock.RLock()
+	defer sc.statusesLock.RUnlock()
+	status, found := sc.statuses[vid]
+	if !found || status.CompletedAt.IsZero() {
+		return true
+	}
+	return status.CompletedAt.Add(sc.interval).Before(time.Now())
+}
+
+func (sc *volumeScrubber) startStatus(vid needle.VolumeId, collection string, isEcVolume bool, needleCount int64) *VolumeScrubStatus {
+	status := &VolumeScrubStatus{
+		VolumeId:    vid,
+		Collection:  collection,
+		IsEcVolume:  isEcVolume,
+		NeedleCount: uint64(needleCount),
+		StartedAt:   time.Now(),
+	}
+	sc.statusesLock.Lock()
+	sc.statuses[vid] = status
+	sc.statusesLock.Unlock()
+	return status
+}
+
+func (sc *volumeScrubber) updateStatus(status *VolumeScrubStatus, fn func(status *VolumeScrubStatus)) {
+	sc.statusesLock.Lock()
+	fn(status)
+	sc.statusesLock.Unlock()
+}
+
+func (sc *volumeScrubber) removeStaleStatuses() {
+	sc.statusesLock.Lock()
+	defer sc.statusesLock.Unlock()
+	for vid, status := range sc.statuses {
+		if status.IsEcVolume {
+			if _, found := sc.store.FindEcVolume(vid); found {
+				continue
+			}
+		} else if sc.store.findVolume(vid) != nil {
+			continue
+		}
+		delete(sc.statuses, vid)
+	}
+}
+
+func (sc *volumeScrubber) scrubVolume(v *Volume) {
+
+	if v.HasRemoteFile() {
+		return
+	}
+
+	count, compactionRevision := v.scrubIndexEntryCount()
+	status := sc.startStatus(v.Id, v.Collection, false, count)
+	glog.V(1).Infof("scrub volume %d with %d index entries", v.Id, count)
+
+	for n := int64(0); n < count; n++ {
+		if sc.store.findVolume(v.Id) != v {
+			sc.updateStatus(status, func(status *VolumeScrubStatus) {
+				status.LastError = "volume is unloaded"
+			})
+			return
+		}
+		if _, revision := v.scrubIndexEntryCount(); revision != compactionRevision {
+			// the index is replaced after compaction, scrub again later
+			sc.updateStatus(status, func(status *VolumeScrubStatus) {
+				status.LastError = "volume is compacted"
+			})
+			return
+		}
+
+		key, size, checked, err := v.scrubIndexEntry(n)
+		if err != nil {
+			glog.Errorf("scrub volume %d needle %s: %v", v.Id, key, err)
+			repairErr := sc.store.repairVolumeNeedle(v, key)
+			if repairErr != nil {
+				glog.Errorf("repair volume %d needle %s: %v", v.Id, key, repairErr)
+			}
+			sc.updateStatus(status, func(status *VolumeScrubStatus) {
+				status.CorruptCount++
+				if repairErr == nil {
+					status.RepairedCount++
+				} else {
+					status.LastError = fmt.Sprintf("needle %s: %v", key, err)
+				}
+			})
+		}
+		sc.updateStatus(status, func(status *VolumeScrubStatus) {
+			status.ScannedCount++
+		})
+		if checked {
+			sc.throttler.MaybeSlowdown(int64(size))
+			v.location.backgroundIo.read(int64(size))
+		}
+	}
+
+	sc.updateStatus(status, func(status *VolumeScrubStatus) {
+		status.CompletedAt = time.Now()
+	})
+	glog.V(1).Infof("scrub volume %d completed, %d corrupted, %d repaired", v.Id, status.CorruptCount, status.RepairedCount)
+}
+
+func (s *Store) repairVolumeNeedle(v *Volume, key NeedleId) error {
+	if v.ReplicaPlacement == nil || v.ReplicaPlacement.GetCopyCount() <= 1 {
+		return fmt.Errorf("volume %d is not replicated", v.Id)
+	}
+
+	self := fmt.Sprintf("%s:%d", s.Ip, s.Port)
+	locations, err := s.lookupVolumeLocations(v.Id, v.Collection)
+	if err != nil {
+		return err
+	}
+
+	for _, location := range locations {
+		if location == self {
+			continue
+		}
+		var needleBlob []byte
+		err = operation.WithVolumeServerClient(location, s.grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+			resp, err := client.ReadNeedleBlob(context.Background(), &volume_server_pb.ReadNeedleBlobRequest{
+				VolumeId:   uint32(v.Id),
+				NeedleId:   uint64(key),
+				ByNeedleId: true,
+			})
+			if err != nil {
+				return err
+			}
+			needleBlob = resp.NeedleBlob
+			return nil
+		})
+		if err != nil {
+			glog.V(0).Infof("read volume %d needle %s from %s: %v", v.Id, key, location, err)
+			continue
+		}
+		if err = v.repairNeedle(key, needleBlob); err != nil {
+			glog.V(0).Infof("repair volume %d needle %s from %s: %v", v.Id, key, location, err)
+			continue
+		}
+		glog.V(0).Infof("repaired volume %d needle %s from %s", v.Id, key, location)
+		return nil
+	}
+
+	return fmt.Errorf("no good copy found in %v", locations)
+}
+
+func (s *Store) lookupVolumeLocations(vid needle.VolumeId, collection string) (locations []string, err error) {
+	err = operation.WithMasterServerClient(s.MasterAddress, s.grpcDialOption, func(masterClient master_pb.SeaweedClient) error {
+		resp, err := masterClient.LookupVolume(context.Background(), &master_pb.LookupVolumeRequest{
+			VolumeIds:  []string{vid.String()},
+			Collection: collection,
+		})
+		if err != nil {
+			return fmt.Errorf("lookup volume %d: %v", vid, err)
+		}
+		for _, vidLocations := range resp.VolumeIdLocations {
+			if vidLocations.Error != "" {
+				return fmt.Errorf("lookup volume %d: %s", vid, vidLocations.Error)
+			}
+			for _, loc := range vidLocations.Locations {
+				locations = append(locations, loc.Url)
+			}
+		}
+		return nil
+	})
+	return
+}
+
+func (sc *volumeScrubber) scrubEcVolume(ev *erasure_coding.EcVolume) {
+
+	count := ev.EcxEntryCount()
+	status := sc.startStatus(ev.VolumeId, ev.Collection, true, count)
+	glog.V(1).Infof("scrub ec volume %d with %d index entries", ev.VolumeId, count)
+
+	for n := int64(0); n < count; n++ {
+		if current, found := sc.store.FindEcVolume(ev.VolumeId); !found || current != ev {
+			sc.updateStatus(status, func(status *VolumeScrubStatus) {
+				status.LastError = "ec volume is unloaded"
+			})
+			return
+		}
+
+		key, size, checked, err := sc.store.scrubEcNeedle(ev, n)
+		if err != nil {
+			glog.Errorf("scrub ec volume %d needle %s: %v", ev.VolumeId, key, err)
+			repairErr := sc.store.repairEcNeedle(ev, key)
+			if repairErr != nil {
+				glog.Errorf("repair ec volume %d needle %s: %v", ev.VolumeId, key, repairErr)
+			}
+			sc.updateStatus(status, func(status *VolumeScrubStatus) {
+				status.CorruptCount++
+				if repairErr == nil {
+					status.RepairedCount++
+				} else {
+					status.LastError = fmt.Sprintf("needle %s: %v", key, err)
+				}
+			})
+		}
+		sc.updateStatus(status, func(status *VolumeScrubStatus) {
+			status.ScannedCount++
+		})
+		if checked {
+			sc.throttler.MaybeSlowdown(int64(size))
+		}
+	}
+
+	sc.updateStatus(status, func(status *VolumeScrubStatus) {
+		status.CompletedAt = time.Now()
+	})
+	glog.V(1).Infof("scrub ec volume %d completed, %d corrupted, %d repaired", ev.VolumeId, status.CorruptCount, status.RepairedCount)
+}
+
+// scrubEcNeedle verifies the needle of the n-th .ecx entry, if its first interval is on a local shard.
+func (s *Store) scrubEcNeedle(ev *erasure_coding.EcVolume, n int64) (key NeedleId, size Size, checked bool, err error) {
+	key, _, size, err = ev.ReadEcxEntry(n)
+	if err != nil {
+		return key, 0, false, err
+	}
+	if size.IsDeleted() || size == 0 {
+		return key, 0, false, nil
+	}
+
+	offset, _, intervals, err := ev.LocateEcShardNeedle(key, ev.Version)
+	if err != nil {
+		return key, 0, false, err
+	}
+	if len(intervals) == 0 {
+		return key, 0, false, nil
+	}
+	firstShardId, _ := intervals[0].ToShardIdAndOffset(erasure_coding.ErasureCodingLargeBlockSize, erasure_coding.ErasureCodingSmallBlockSize)
+	if _, found := ev.FindEcVolumeShard(firstShardId); !found {
+		return key, 0, false, nil
+	}
+
+	data, isDeleted, err := s.readEcShardIntervals(ev.VolumeId, key, ev, intervals)
+	if err != nil {
+		return key, 0, false, fmt.Errorf("read ec shard intervals: %v", err)
+	}
+	if isDeleted {
+		return key, 0, false, nil
+	}
+
+	nd := new(needle.Needle)
+	return key, size, true, nd.ReadBytes(data, offset.ToActualOffset(), size, ev.Version)
+}
+
+// repairEcNeedle recovers the needle intervals on local shards from the other shards,
+// and writes back the intervals that differ, if the recovered needle is good.
+func (s *Store) repairEcNeedle(ev *erasure_coding.EcVolume, key NeedleId) error {
+
+	offset, size, intervals, err := ev.LocateEcShardNeedle(key, ev.Version)
+	if err != nil {
+		return err
+	}
+	if err = s.cachedLookupEcShardLocations(ev); err != nil {
+		return fmt.Errorf("locate ec shards: %v", err)
+	}
+
+	type repair struct {
+		shard  *erasure_coding.EcVolumeShard
+		data   []byte
+		offset int64
+	}
+	var repairs []repair
+	var data []byte
+
+	for _, interval := range intervals {
+		shardId, actualOffset := interval.ToShardIdAndOffset(erasure_coding.ErasureCodingLargeBlockSize, erasure_coding.ErasureCodingSmallBlockSize)
+		shard, isLocal := ev.FindEcVolumeShard(shardId)
+		if !isLocal {
+			d, _, err := s.readOneEcShardInterval(key, ev, interval)
+			if err != nil {
+				return err
+			}
+			data = append(data, d...)
+			continue
+		}
+
+		localData := make([]byte, interval.Size)
+		if _, err = shard.ReadAt(localData, actualOffset); err != nil {
+			return fmt.Errorf("read local ec shard %d.%d: %v", ev.VolumeId, shardId, err)
+		}
+		recovered := make([]byte, interval.Size)
+		if _, _, err = s.recoverOneRemoteEcShardInterval(key, ev, shardId, recovered, actualOffset); err != nil {
+			return fmt.Errorf("recover ec shard %d.%d: %v", ev.VolumeId, shardId, err)
+		}
+		if !bytes.Equal(localData, recovered) {
+			repairs = append(repairs, repair{shard: shard, data: recovered, offset: actualOffset})
+		}
+		data = append(data, recovered...)
+	}
+
+	nd := new(needle.Needle)
+	if err = nd.ReadBytes(data, offset.ToActualOffset(), size, ev.Version); err != nil {
+		return fmt.Errorf("recovered needle is still bad: %v", err)
+	}
+	if len(repairs) == 0 {
+		return fmt.Errorf("corrupted data is not on local shards")
+	}
+
+	for _, r := range repairs {
+		if err = r.shard.RepairAt(r.data, r.offset); err != nil {
+			return err
+		}
+		glog.V(0).Infof("repaired %s at offset %d size %d", r.shard, r.offset, len(r.data))
+	}
+	return nil
+}
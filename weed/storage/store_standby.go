@@ -0,0 +1,290 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+	. "github.com/chrislusf/seaweedfs/weed/storage/types"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+A standby volume is a copy of a volume on another volume server, kept in sync by tailing the writes,
+so the volume can be switched over to this volume server quickly, e.g. for planned hardware replacement.
+
+The standby volume files are kept in the "standby" sub directory of a disk location, not loaded as
+normal volumes, so the master does not know them as replicas.
+
+	standby/<volume>.dat      the copied .dat file, in the data directory
+	standby/<volume>.idx      the copied .idx file, in the index directory
+	standby/<volume>.vif      the copied .vif file, if any
+	standby/<volume>.standby  the source volume server, and the timestamp of the last tailed write
+
+The tailing resumes from the timestamp in the .standby file after restarts. The switchover moves
+the files into the disk location, and mounts the volume.
+*/
+
+const (
+	StandbyDirectory        = "standby"
+	standbyNoteExt          = ".standby"
+	standbyNoteSaveInterval = 10 * time.Second
+)
+
+type StandbyVolume struct {
+	Id         needle.VolumeId
+	Collection string
+	Source     string
+
+	location    *DiskLocation
+	volume      *Volume
+	sinceNs     uint64 // of the source volume
+	noteSavedAt time.Time
+	lock        sync.Mutex
+}
+
+type standbyNote struct {
+	Source  string `json:"source"`
+	SinceNs uint64 `json:"sinceNs"`
+}
+
+func standbyBaseFileNames(location *DiskLocation, collection string, vid needle.VolumeId) (dataBaseFileName, indexBaseFileName string) {
+	return VolumeFileName(path.Join(location.Directory, StandbyDirectory), collection, int(vid)),
+		VolumeFileName(path.Join(location.IdxDirectory, StandbyDirectory), collection, int(vid))
+}
+
+// NewStandbyFileNames picks a disk location for a new standby volume, and returns the base file names to copy the volume files to.
+func (s *Store) NewStandbyFileNames(vid needle.VolumeId, collection string, diskType DiskType) (location *DiskLocation, dataBaseFileName, indexBaseFileName string, err error) {
+	if s.findVolume(vid) != nil {
+		return nil, "", "", fmt.Errorf("volume %d already exists", vid)
+	}
+	if sv := s.FindStandbyVolume(vid); sv != nil {
+		return nil, "", "", fmt.Errorf("volume %d is already in standby from %s", vid, sv.Source)
+	}
+	if location = s.FindFreeLocation(diskType); location == nil {
+		return nil, "", "", fmt.Errorf("no space left for disk type %s", diskType.ReadableString())
+	}
+	dataBaseFileName, indexBaseFileName = standbyBaseFileNames(location, collection, vid)
+	for _, dir := range []string{path.Dir(dataBaseFileName), path.Dir(indexBaseFileName)} {
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			return nil, "", "", err
+		}
+	}
+	return
+}
+
+// OpenStandbyVolume loads the copied volume files, to apply the writes tailed from the source since sinceNs.
+func (s *Store) OpenStandbyVolume(location *DiskLocation, vid needle.VolumeId, collection, source string, sinceNs uint64) (*StandbyVolume, error) {
+	dataBaseFileName, indexBaseFileName := standbyBaseFileNames(location, collection, vid)
+	v, err := NewVolume(path.Dir(dataBaseFileName), path.Dir(indexBaseFileName), collection, vid, s.NeedleMapKind, nil, nil, 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("load standby volume %d: %v", vid, err)
+	}
+	v.location = location
+	sv := &StandbyVolume{
+		Id:         vid,
+		Collection: collection,
+		Source:     source,
+		location:   location,
+		volume:     v,
+		sinceNs:    sinceNs,
+	}
+	if err = sv.saveNote(); err != nil {
+		v.Close()
+		return nil, err
+	}
+
+	s.standbyVolumesLock.Lock()
+	defer s.standbyVolumesLock.Unlock()
+	if s.standbyVolumes == nil {
+		s.standbyVolumes = make(map[needle.VolumeId]*StandbyVolume)
+	}
+	s.standbyVolumes[vid] = sv
+	return sv, nil
+}
+
+// LoadStandbyVolumes loads the standby volumes left by the last run.
+func (s *Store) LoadStandbyVolumes() (standbyVolumes []*StandbyVolume) {
+	for _, location := range s.Locations {
+		fileInfos, err := ioutil.ReadDir(path.Join(location.Directory, StandbyDirectory))
+		if err != nil {
+			continue
+		}
+		for _, fileInfo := range fileInfos {
+			if fileInfo.IsDir() || !strings.HasSuffix(fileInfo.Name(), standbyNoteExt) {
+				continue
+			}
+			collection, vid, err := parseCollectionVolumeId(strings.TrimSuffix(fileInfo.Name(), standbyNoteExt))
+			if err != nil {
+				continue
+			}
+			data, err := ioutil.ReadFile(path.Join(location.Directory, StandbyDirectory, fileInfo.Name()))
+			if err != nil {
+				glog.Warningf("read standby volume %d note: %v", vid, err)
+				continue
+			}
+			var note standbyNote
+			if err = json.Unmarshal(data, &note); err != nil {
+				glog.Warningf("parse standby volume %d note: %v", vid, err)
+				continue
+			}
+			sv, err := s.OpenStandbyVolume(location, vid, collection, note.Source, note.SinceNs)
+			if err != nil {
+				glog.Warningf("open standby volume %d: %v", vid, err)
+				continue
+			}
+			glog.V(0).Infof("loaded standby volume %d from %s", vid, note.Source)
+			standbyVolumes = append(standbyVolumes, sv)
+		}
+	}
+	return
+}
+
+func (s *Store) FindStandbyVolume(vid needle.VolumeId) *StandbyVolume {
+	s.standbyVolumesLock.RLock()
+	defer s.standbyVolumesLock.RUnlock()
+	return s.standbyVolumes[vid]
+}
+
+func (s *Store) StandbyVolumes() (standbyVolumes []*StandbyVolume) {
+	s.standbyVolumesLock.RLock()
+	defer s.standbyVolumesLock.RUnlock()
+	for _, sv := range s.standbyVolumes {
+		standbyVolumes = append(standbyVolumes, sv)
+	}
+	return
+}
+
+// PromoteStandbyVolume moves the standby volume files into the disk location, and mounts the volume.
+func (s *Store) PromoteStandbyVolume(sv *StandbyVolume) error {
+	sv.lock.Lock()
+	defer sv.lock.Unlock()
+
+	if s.findVolume(sv.Id) != nil {
+		return fmt.Errorf("volume %d already exists", sv.Id)
+	}
+	sv.volume.Close()
+
+	dataBaseFileName, indexBaseFileName := standbyBaseFileNames(sv.location, sv.Collection, sv.Id)
+	targetDataBaseFileName := VolumeFileName(sv.location.Directory, sv.Collection, int(sv.Id))
+	targetIndexBaseFileName := VolumeFileName(sv.location.IdxDirectory, sv.Collection, int(sv.Id))
+	for _, rename := range [][2]string{
+		{dataBaseFileName + ".dat", targetDataBaseFileName + ".dat"},
+		{indexBaseFileName + ".idx", targetIndexBaseFileName + ".idx"},
+		{dataBaseFileName + ".vif", targetDataBaseFileName + ".vif"},
+	} {
+		if !util.FileExists(rename[0]) {
+			continue
+		}
+		if err := os.Rename(rename[0], rename[1]); err != nil {
+			return fmt.Errorf("move standby volume %d file: %v", sv.Id, err)
+		}
+	}
+	removeVolumeFiles(dataBaseFileName)
+	removeVolumeFiles(indexBaseFileName)
+	os.Remove(dataBaseFileName + standbyNoteExt)
+
+	s.removeStandbyVolume(sv.Id)
+	return s.MountVolume(sv.Id)
+}
+
+// DeleteStandbyVolume removes the standby volume files.
+func (s *Store) DeleteStandbyVolume(sv *StandbyVolume) {
+	sv.lock.Lock()
+	defer sv.lock.Unlock()
+
+	sv.volume.Close()
+	dataBaseFileName, indexBaseFileName := standbyBaseFileNames(sv.location, sv.Collection, sv.Id)
+	removeVolumeFiles(dataBaseFileName)
+	removeVolumeFiles(indexBaseFileName)
+	os.Remove(dataBaseFileName + standbyNoteExt)
+
+	s.removeStandbyVolume(sv.Id)
+}
+
+func (s *Store) closeStandbyVolumes() {
+	for _, sv := range s.StandbyVolumes() {
+		sv.lock.Lock()
+		if err := sv.saveNote(); err != nil {
+			glog.Warningf("save standby volume %d note: %v", sv.Id, err)
+		}
+		sv.volume.Close()
+		sv.lock.Unlock()
+		s.removeStandbyVolume(sv.Id)
+	}
+}
+
+func (s *Store) removeStandbyVolume(vid needle.VolumeId) {
+	s.standbyVolumesLock.Lock()
+	defer s.standbyVolumesLock.Unlock()
+	delete(s.standbyVolumes, vid)
+}
+
+// ApplyNeedle writes or deletes the needle tailed from the source volume.
+func (sv *StandbyVolume) ApplyNeedle(n *needle.Needle) (err error) {
+	sv.lock.Lock()
+	defer sv.lock.Unlock()
+
+	appendAtNs := n.AppendAtNs
+	if n.Size > 0 && n.Size.IsValid() {
+		_, _, _, err = sv.volume.writeNeedle2(n, false)
+	} else {
+		_, err = sv.volume.deleteNeedle2(n)
+	}
+	if err != nil {
+		return fmt.Errorf("apply needle %s to standby volume %d: %v", n.Id, sv.Id, err)
+	}
+	if appendAtNs > sv.sinceNs {
+		sv.sinceNs = appendAtNs
+	}
+	if time.Since(sv.noteSavedAt) > standbyNoteSaveInterval {
+		if err = sv.saveNote(); err != nil {
+			glog.Warningf("save standby volume %d note: %v", sv.Id, err)
+		}
+	}
+	return nil
+}
+
+// SinceNs is the timestamp of the last write tailed from the source volume.
+func (sv *StandbyVolume) SinceNs() uint64 {
+	sv.lock.Lock()
+	defer sv.lock.Unlock()
+	return sv.sinceNs
+}
+
+func (sv *StandbyVolume) DatFileSize() uint64 {
+	sv.lock.Lock()
+	defer sv.lock.Unlock()
+	if sv.volume.DataBackend == nil {
+		return 0
+	}
+	datFileSize, _, _ := sv.volume.DataBackend.GetStat()
+	return uint64(datFileSize)
+}
+
+// SaveNote records the tailing progress, to resume after restarts.
+func (sv *StandbyVolume) SaveNote() error {
+	sv.lock.Lock()
+	defer sv.lock.Unlock()
+	return sv.saveNote()
+}
+
+func (sv *StandbyVolume) saveNote() error {
+	data, err := json.Marshal(&standbyNote{Source: sv.Source, SinceNs: sv.sinceNs})
+	if err != nil {
+		return err
+	}
+	dataBaseFileName, _ := standbyBaseFileNames(sv.location, sv.Collection, sv.Id)
+	if err = ioutil.WriteFile(dataBaseFileName+standbyNoteExt, data, 0644); err != nil {
+		return err
+	}
+	sv.noteSavedAt = time.Now()
+	return nil
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+	"github.com/chrislusf/seaweedfs/weed/storage/super_block"
+	. "github.com/chrislusf/seaweedfs/weed/storage/types"
+)
+
+func TestStandbyVolume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "standby")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the volume files copied from the source
+	v, err := NewVolume(dir, dir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0)
+	if err != nil {
+		t.Fatalf("volume creation: %v", err)
+	}
+	for i := 1; i <= 3; i++ {
+		if _, _, _, err := v.writeNeedle2(newRandomNeedle(uint64(i)), false); err != nil {
+			t.Fatalf("write file %d: %v", i, err)
+		}
+	}
+	defer v.Close()
+
+	targetDir := path.Join(dir, "target")
+	os.Mkdir(targetDir, 0755)
+	s := NewStore(nil, 8080, "localhost", "", []string{targetDir}, []int{2}, []float32{0}, "", NeedleMapInMemory, []DiskType{HardDriveType})
+	defer s.Close()
+
+	location, dataBaseFileName, indexBaseFileName, err := s.NewStandbyFileNames(1, "", HardDriveType)
+	if err != nil {
+		t.Fatalf("new standby file names: %v", err)
+	}
+	for _, copying := range [][2]string{{path.Join(dir, "1.dat"), dataBaseFileName + ".dat"}, {path.Join(dir, "1.idx"), indexBaseFileName + ".idx"}} {
+		data, _ := ioutil.ReadFile(copying[0])
+		if err = ioutil.WriteFile(copying[1], data, 0644); err != nil {
+			t.Fatalf("copy volume file: %v", err)
+		}
+	}
+
+	sv, err := s.OpenStandbyVolume(location, 1, "", "source:8080", 100)
+	if err != nil {
+		t.Fatalf("open standby volume: %v", err)
+	}
+	if _, _, _, err = s.NewStandbyFileNames(1, "", HardDriveType); err == nil {
+		t.Fatalf("the volume should be in standby already")
+	}
+
+	// the tailed writes
+	n := newRandomNeedle(4)
+	if _, _, _, err = v.writeNeedle2(n, false); err != nil {
+		t.Fatalf("write file 4: %v", err)
+	}
+	n.AppendAtNs = 200
+	if err = sv.ApplyNeedle(n); err != nil {
+		t.Fatalf("apply write: %v", err)
+	}
+	if err = sv.ApplyNeedle(&needle.Needle{Id: Uint64ToNeedleId(2), AppendAtNs: 300}); err != nil {
+		t.Fatalf("apply delete: %v", err)
+	}
+	if sv.SinceNs() != 300 {
+		t.Fatalf("unexpected since ns %d", sv.SinceNs())
+	}
+	if s.GetVolume(1) != nil {
+		t.Fatalf("standby volume should not be mounted")
+	}
+
+	// the standby volume is loaded again after restarts
+	s.closeStandbyVolumes()
+	standbyVolumes := s.LoadStandbyVolumes()
+	if len(standbyVolumes) != 1 || standbyVolumes[0].Source != "source:8080" || standbyVolumes[0].SinceNs() != 300 {
+		t.Fatalf("unexpected loaded standby volumes %+v", standbyVolumes)
+	}
+	sv = standbyVolumes[0]
+
+	if err = s.PromoteStandbyVolume(sv); err != nil {
+		t.Fatalf("promote standby volume: %v", err)
+	}
+	<-s.NewVolumesChan
+	if s.FindStandbyVolume(1) != nil {
+		t.Fatalf("promoted volume should not be in standby")
+	}
+	for i, expected := range []error{nil, ErrorDeleted, nil, nil} {
+		if _, err = s.ReadVolumeNeedle(1, &needle.Needle{Id: Uint64ToNeedleId(uint64(i + 1))}, nil); err != expected {
+			t.Errorf("read needle %d: expecting %v, but got %v", i+1, expected, err)
+		}
+	}
+	if fileInfos, _ := ioutil.ReadDir(path.Join(targetDir, StandbyDirectory)); len(fileInfos) != 0 {
+		t.Errorf("unexpected standby files left %d", len(fileInfos))
+	}
+}
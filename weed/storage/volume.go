@@ -29,6 +29,7 @@ type Volume struct {
 	noWriteOrDelete    bool // if readonly, either noWriteOrDelete or noWriteCanDelete
 	noWriteCanDelete   bool // if readonly, either noWriteOrDelete or noWriteCanDelete
 	noWriteLock        sync.RWMutex
+	isLiveCopy         bool // read only and not reported to the master, until marked writable
 	hasRemoteFile      bool // if the volume has a remote file
 	MemoryMapMaxSizeMb uint32
 
@@ -42,12 +43,19 @@ type Volume struct {
 	lastCompactIndexOffset uint64
 	lastCompactRevision    uint16
 
+	lastCompactSoftDeletion *softDeletion
+	softDeletion            *softDeletion
+
 	isCompacting bool
 
+	readHeat readHeat
+
 	volumeInfo *volume_server_pb.VolumeInfo
 	location   *DiskLocation
 
 	lastIoError error
+
+	cipherKey []byte // for at rest encryption
 }
 
 func NewVolume(dirname string, dirIdx string, collection string, id needle.VolumeId, needleMapKind NeedleMapKind, replicaPlacement *super_block.ReplicaPlacement, ttl *needle.TTL, preallocate int64, memoryMapMaxSizeMb uint32) (v *Volume, e error) {
@@ -87,7 +95,7 @@ func (v *Volume) IndexFileName() (fileName string) {
 
 func (v *Volume) FileName(ext string) (fileName string) {
 	switch ext {
-	case ".idx", ".cpx", ".ldb":
+	case ".idx", ".cpx", ".ldb", ".rdb", ".ckp":
 		return VolumeFileName(v.dirIdx, v.Collection, int(v.Id)) + ext
 	}
 	// .dat, .cpd, .vif
@@ -180,6 +188,9 @@ func (v *Volume) Close() {
 	v.dataFileAccessLock.Lock()
 	defer v.dataFileAccessLock.Unlock()
 	if v.nm != nil {
+		if indexCheckpointInterval > 0 {
+			v.saveIndexCheckpointLocked()
+		}
 		v.nm.Close()
 		v.nm = nil
 	}
@@ -279,6 +290,9 @@ func (v *Volume) ToVolumeInformationMessage() (types.NeedleId, *master_pb.Volume
 		DiskType:         string(v.location.DiskType),
 	}
 
+	readsPerDay, trackedSince := v.readHeat.readsPerDay(time.Now())
+	volumeInfo.ReadsPerDay, volumeInfo.ReadsTrackedSinceSecond = readsPerDay, trackedSince.Unix()
+
 	volumeInfo.RemoteStorageName, volumeInfo.RemoteStorageKey = v.RemoteStorageNameKey()
 
 	return maxFileKey, volumeInfo
@@ -299,3 +313,10 @@ func (v *Volume) IsReadOnly() bool {
 	defer v.noWriteLock.RUnlock()
 	return v.noWriteOrDelete || v.noWriteCanDelete || v.location.isDiskSpaceLow
 }
+
+// IsLiveCopy checks whether the volume is a live copy still catching up with its source
+func (v *Volume) IsLiveCopy() bool {
+	v.noWriteLock.RLock()
+	defer v.noWriteLock.RUnlock()
+	return v.isLiveCopy
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+)
+
+/*
+At rest encryption encrypts the needle data in the volume .dat files, so the disks do not expose the data.
+
+Each collection has one data key, which is stored wrapped by a KMS in security.toml.
+Each volume encrypts with its own key, derived from the collection data key and the volume id,
+so the replicas, the compacted copies and the erasure coded shards of a volume share the same key.
+
+Only the needle data is encrypted. The needle file name, mime type and pairs are stored as is.
+*/
+
+var (
+	atRestDataKeys     map[string][]byte
+	atRestDataKeysLock sync.RWMutex
+)
+
+const AllCollectionsDataKey = "*"
+
+// SetAtRestDataKeys sets the data key for each collection. The "*" key applies to collections not listed.
+func SetAtRestDataKeys(dataKeys map[string][]byte) {
+	atRestDataKeysLock.Lock()
+	defer atRestDataKeysLock.Unlock()
+	atRestDataKeys = dataKeys
+}
+
+func atRestVolumeKey(collection string, vid needle.VolumeId) []byte {
+	atRestDataKeysLock.RLock()
+	defer atRestDataKeysLock.RUnlock()
+
+	dataKey, found := atRestDataKeys[strings.ToLower(collection)]
+	if !found {
+		dataKey, found = atRestDataKeys[AllCollectionsDataKey]
+	}
+	if !found {
+		return nil
+	}
+	mac := hmac.New(sha256.New, dataKey)
+	mac.Write([]byte(fmt.Sprintf("volume %d", vid)))
+	return mac.Sum(nil)
+}
+
+// encryptNeedle returns the needle to store, which is an encrypted copy if the volume is encrypted.
+func (v *Volume) encryptNeedle(n *needle.Needle) (*needle.Needle, error) {
+	if len(v.cipherKey) == 0 || len(n.Data) == 0 || n.IsEncrypted() || v.Version() == needle.Version1 {
+		return n, nil
+	}
+	stored := *n
+	if err := stored.Encrypt(v.cipherKey); err != nil {
+		return nil, fmt.Errorf("encrypt needle %s: %v", n.String(), err)
+	}
+	return &stored, nil
+}
+
+func (v *Volume) decryptNeedle(n *needle.Needle) error {
+	if !n.IsEncrypted() {
+		return nil
+	}
+	return n.Decrypt(v.cipherKey)
+}
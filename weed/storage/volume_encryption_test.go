@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+	"github.com/chrislusf/seaweedfs/weed/storage/super_block"
+	"github.com/chrislusf/seaweedfs/weed/storage/types"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func TestEncryptedVolumeReadWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "encrypted")
+	if err != nil {
+		t.Fatalf("temp dir creation: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	SetAtRestDataKeys(map[string][]byte{"secret": util.GenCipherKey()})
+	defer SetAtRestDataKeys(nil)
+
+	if bytes.Equal(atRestVolumeKey("secret", 1), atRestVolumeKey("secret", 2)) {
+		t.Fatalf("volumes should have different keys")
+	}
+	if atRestVolumeKey("other", 1) != nil {
+		t.Fatalf("collection other should not be encrypted")
+	}
+
+	v, err := NewVolume(dir, dir, "secret", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0)
+	if err != nil {
+		t.Fatalf("volume creation: %v", err)
+	}
+	defer v.Close()
+
+	n := newRandomNeedle(1)
+	n.Data = append(n.Data, []byte("some plain text")...)
+	n.Checksum = needle.NewCRC(n.Data)
+	data := append([]byte{}, n.Data...)
+	if _, size, _, err := v.writeNeedle2(n, false); err != nil || size != types.Size(len(data)) {
+		t.Fatalf("write: size %d, %v", size, err)
+	}
+
+	blob, _, err := v.ReadNeedleBlobById(n.Id)
+	if err != nil {
+		t.Fatalf("read needle blob: %v", err)
+	}
+	if bytes.Contains(blob, []byte("some plain text")) {
+		t.Fatalf("needle data is stored in plain text")
+	}
+
+	if !v.isFileUnchanged(n) {
+		t.Fatalf("rewriting the same needle should be unchanged")
+	}
+
+	r := newEmptyNeedle(1)
+	if _, err = v.readNeedle(r, nil); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !bytes.Equal(r.Data, data) || r.Checksum != needle.NewCRC(data) {
+		t.Fatalf("unexpected data read")
+	}
+}
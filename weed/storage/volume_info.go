@@ -25,6 +25,9 @@ type VolumeInfo struct {
 	ModifiedAtSecond  int64
 	RemoteStorageName string
 	RemoteStorageKey  string
+
+	ReadsPerDay             uint64
+	ReadsTrackedSinceSecond int64
 }
 
 func NewVolumeInfo(m *master_pb.VolumeInformationMessage) (vi VolumeInfo, err error) {
@@ -42,6 +45,9 @@ func NewVolumeInfo(m *master_pb.VolumeInformationMessage) (vi VolumeInfo, err er
 		RemoteStorageName: m.RemoteStorageName,
 		RemoteStorageKey:  m.RemoteStorageKey,
 		DiskType:          m.DiskType,
+
+		ReadsPerDay:             m.ReadsPerDay,
+		ReadsTrackedSinceSecond: m.ReadsTrackedSinceSecond,
 	}
 	rp, e := super_block.NewReplicaPlacementFromByte(byte(m.ReplicaPlacement))
 	if e != nil {
@@ -56,6 +62,7 @@ func NewVolumeInfoFromShort(m *master_pb.VolumeShortInformationMessage) (vi Volu
 	vi = VolumeInfo{
 		Id:         needle.VolumeId(m.Id),
 		Collection: m.Collection,
+		ReadOnly:   m.ReadOnly,
 		Version:    needle.Version(m.Version),
 	}
 	rp, e := super_block.NewReplicaPlacementFromByte(byte(m.ReplicaPlacement))
@@ -94,6 +101,9 @@ func (vi VolumeInfo) ToVolumeInformationMessage() *master_pb.VolumeInformationMe
 		RemoteStorageName: vi.RemoteStorageName,
 		RemoteStorageKey:  vi.RemoteStorageKey,
 		DiskType:          vi.DiskType,
+
+		ReadsPerDay:             vi.ReadsPerDay,
+		ReadsTrackedSinceSecond: vi.ReadsTrackedSinceSecond,
 	}
 }
 
@@ -39,7 +39,10 @@ func (v *Volume) load(alsoLoadIndex bool, createDatIfMissing bool, needleMapKind
 		}
 	}()
 
+	v.cipherKey = atRestVolumeKey(v.Collection, v.Id)
+
 	hasVolumeInfoFile := v.maybeLoadVolumeInfo()
+	v.loadSoftDeletion()
 
 	if v.HasRemoteFile() {
 		v.noWriteCanDelete = true
@@ -104,7 +107,7 @@ func (v *Volume) load(alsoLoadIndex bool, createDatIfMissing bool, needleMapKind
 		}
 		// check volume idx files
 		if err := v.checkIdxFile(); err != nil {
-			glog.Fatalf("check volume idx file %s: %v", v.FileName(".idx"), err)
+			return fmt.Errorf("check volume idx file %s: %v", v.FileName(".idx"), err)
 		}
 		var indexFile *os.File
 		if v.noWriteOrDelete {
@@ -131,7 +134,7 @@ func (v *Volume) load(alsoLoadIndex bool, createDatIfMissing bool, needleMapKind
 			switch needleMapKind {
 			case NeedleMapInMemory:
 				glog.V(0).Infoln("loading index", v.FileName(".idx"), "to memory")
-				if v.nm, err = LoadCompactNeedleMap(indexFile); err != nil {
+				if v.nm, err = LoadCompactNeedleMapFromCheckpoint(indexFile, v.FileName(".ckp")); err != nil {
 					glog.V(0).Infof("loading index %s to memory error: %v", v.FileName(".idx"), err)
 				}
 			case NeedleMapLevelDb:
@@ -164,6 +167,11 @@ func (v *Volume) load(alsoLoadIndex bool, createDatIfMissing bool, needleMapKind
 				if v.nm, err = NewLevelDbNeedleMap(v.FileName(".ldb"), indexFile, opts); err != nil {
 					glog.V(0).Infof("loading leveldb %s error: %v", v.FileName(".ldb"), err)
 				}
+			case NeedleMapRocksDb:
+				glog.V(0).Infoln("loading rocksdb", v.FileName(".rdb"))
+				if v.nm, err = NewRocksDbNeedleMap(v.FileName(".rdb"), indexFile); err != nil {
+					glog.V(0).Infof("loading rocksdb %s error: %v", v.FileName(".rdb"), err)
+				}
 			}
 		}
 	}
@@ -17,7 +17,7 @@ func (v *Volume) readNeedle(n *needle.Needle, readOption *ReadOption) (int, erro
 	v.dataFileAccessLock.RLock()
 	defer v.dataFileAccessLock.RUnlock()
 
-	nv, ok := v.nm.Get(n.Id)
+	nv, needleBlob, ok := v.getNeedleValue(n.Id)
 	if !ok || nv.Offset.IsZero() {
 		return -1, ErrorNotFound
 	}
@@ -30,10 +30,16 @@ func (v *Volume) readNeedle(n *needle.Needle, readOption *ReadOption) (int, erro
 			return -1, ErrorDeleted
 		}
 	}
+	v.readHeat.countRead()
 	if readSize == 0 {
 		return 0, nil
 	}
-	err := n.ReadData(v.DataBackend, nv.Offset.ToActualOffset(), readSize, v.Version())
+	var err error
+	if len(needleBlob) > 0 {
+		err = n.ReadBytes(needleBlob, nv.Offset.ToActualOffset(), readSize, v.Version())
+	} else {
+		err = n.ReadData(v.DataBackend, nv.Offset.ToActualOffset(), readSize, v.Version())
+	}
 	if err == needle.ErrorSizeMismatch && OffsetSize == 4 {
 		err = n.ReadData(v.DataBackend, nv.Offset.ToActualOffset()+int64(MaxPossibleVolumeSize), readSize, v.Version())
 	}
@@ -41,21 +47,27 @@ func (v *Volume) readNeedle(n *needle.Needle, readOption *ReadOption) (int, erro
 	if err != nil {
 		return 0, err
 	}
-	bytesRead := len(n.Data)
+	if err = v.decryptNeedle(n); err != nil {
+		return 0, err
+	}
+	if isNeedleExpired(n) {
+		return -1, ErrorNotFound
+	}
+	return len(n.Data), nil
+}
+
+func isNeedleExpired(n *needle.Needle) bool {
 	if !n.HasTtl() {
-		return bytesRead, nil
+		return false
 	}
 	ttlMinutes := n.Ttl.Minutes()
 	if ttlMinutes == 0 {
-		return bytesRead, nil
+		return false
 	}
 	if !n.HasLastModifiedDate() {
-		return bytesRead, nil
-	}
-	if time.Now().Before(time.Unix(0, int64(n.AppendAtNs)).Add(time.Duration(ttlMinutes) * time.Minute)) {
-		return bytesRead, nil
+		return false
 	}
-	return -1, ErrorNotFound
+	return !time.Now().Before(time.Unix(0, int64(n.AppendAtNs)).Add(time.Duration(ttlMinutes) * time.Minute))
 }
 
 // read fills in Needle content by looking up n.Id from NeedleMapper
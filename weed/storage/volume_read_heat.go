@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// readHeatHalfLife is how fast the past reads stop counting for the read heat of a volume
+const readHeatHalfLife = 24 * time.Hour
+
+// readHeat tracks how often a volume is read, as an exponentially decayed read count.
+// It is kept in memory only, and tracked again from zero after the volume server restarts.
+type readHeat struct {
+	pendingReads uint64 // accessed atomically by the readers
+
+	sync.Mutex
+	heat         float64
+	updatedAt    time.Time
+	trackedSince time.Time
+}
+
+func (h *readHeat) countRead() {
+	atomic.AddUint64(&h.pendingReads, 1)
+}
+
+// readsPerDay decays the read heat to now, and estimates the recent reads per day.
+func (h *readHeat) readsPerDay(now time.Time) (readsPerDay uint64, trackedSince time.Time) {
+	h.Lock()
+	defer h.Unlock()
+
+	if h.trackedSince.IsZero() {
+		h.trackedSince, h.updatedAt = now, now
+	}
+	if elapsed := now.Sub(h.updatedAt); elapsed > 0 {
+		h.heat *= math.Exp2(-float64(elapsed) / float64(readHeatHalfLife))
+		h.updatedAt = now
+	}
+	h.heat += float64(atomic.SwapUint64(&h.pendingReads, 0))
+
+	// with a steady read rate, the heat converges to rate * halfLife / ln2
+	readsPerDay = uint64(h.heat * math.Ln2 * float64(24*time.Hour) / float64(readHeatHalfLife))
+	return readsPerDay, h.trackedSince
+}
@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReadHeat(t *testing.T) {
+	h := &readHeat{}
+	now := time.Now()
+	if readsPerDay, trackedSince := h.readsPerDay(now); readsPerDay != 0 || !trackedSince.Equal(now) {
+		t.Fatalf("unexpected initial heat %d since %v", readsPerDay, trackedSince)
+	}
+
+	// read 100 times per hour for a week
+	for i := 0; i < 7*24; i++ {
+		for j := 0; j < 100; j++ {
+			h.countRead()
+		}
+		now = now.Add(time.Hour)
+		h.readsPerDay(now)
+	}
+	if readsPerDay, _ := h.readsPerDay(now); readsPerDay < 2200 || readsPerDay > 2600 {
+		t.Errorf("unexpected steady heat %d, expecting about 2400 reads per day", readsPerDay)
+	}
+
+	// no reads for a week
+	now = now.Add(7 * readHeatHalfLife)
+	if readsPerDay, _ := h.readsPerDay(now); readsPerDay > 20 {
+		t.Errorf("unexpected heat %d after a week without reads", readsPerDay)
+	}
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+	. "github.com/chrislusf/seaweedfs/weed/storage/types"
+)
+
+// scrubIndexEntry verifies the needle of the n-th .idx entry, if it is still the live copy.
+// It returns the needle id and size, and a non nil err if the needle is corrupted.
+func (v *Volume) scrubIndexEntry(n int64) (key NeedleId, size Size, checked bool, err error) {
+	v.dataFileAccessLock.RLock()
+	defer v.dataFileAccessLock.RUnlock()
+
+	if v.nm == nil {
+		return 0, 0, false, fmt.Errorf("volume %d is closed", v.Id)
+	}
+
+	key, offset, size, readErr := v.nm.ReadIndexEntry(n)
+	if readErr != nil {
+		return 0, 0, false, fmt.Errorf("read index entry %d: %v", n, readErr)
+	}
+
+	// skip deleted or overwritten entries
+	nv, ok := v.nm.Get(key)
+	if !ok || nv.Offset != offset || nv.Size != size || size.IsDeleted() || size == 0 {
+		return key, 0, false, nil
+	}
+
+	nd := new(needle.Needle)
+	err = nd.ReadData(v.DataBackend, offset.ToActualOffset(), size, v.Version())
+	if err == needle.ErrorSizeMismatch && OffsetSize == 4 {
+		err = nd.ReadData(v.DataBackend, offset.ToActualOffset()+int64(MaxPossibleVolumeSize), size, v.Version())
+	}
+	if err == nil && nd.Id != key {
+		err = fmt.Errorf("index key %s does not match needle id %s", key, nd.Id)
+	}
+	return key, size, true, err
+}
+
+// scrubIndexEntryCount is the number of .idx entries to scrub, and the compaction revision they belong to.
+func (v *Volume) scrubIndexEntryCount() (count int64, compactionRevision uint16) {
+	v.dataFileAccessLock.RLock()
+	defer v.dataFileAccessLock.RUnlock()
+	if v.nm == nil {
+		return 0, 0
+	}
+	return int64(v.nm.IndexFileSize()) / NeedleMapEntrySize, v.SuperBlock.CompactionRevision
+}
+
+// repairNeedle appends a good copy of the needle, read from another replica.
+// The corrupted copy becomes garbage to be vacuumed.
+func (v *Volume) repairNeedle(key NeedleId, needleBlob []byte) error {
+	if len(needleBlob) < NeedleHeaderSize {
+		return fmt.Errorf("needle %s blob has only %d bytes", key, len(needleBlob))
+	}
+	n := new(needle.Needle)
+	n.ParseNeedleHeader(needleBlob)
+	if n.Id != key {
+		return fmt.Errorf("needle blob id %s does not match %s", n.Id, key)
+	}
+	if int64(len(needleBlob)) < needle.GetActualSize(n.Size, v.Version()) {
+		return fmt.Errorf("needle %s blob has %d bytes, less than %d bytes", key, len(needleBlob), needle.GetActualSize(n.Size, v.Version()))
+	}
+	if err := n.ReadBytes(needleBlob, 0, n.Size, v.Version()); err != nil {
+		return fmt.Errorf("verify needle %s blob: %v", key, err)
+	}
+	return v.WriteNeedleBlob(key, needleBlob, n.Size)
+}
+
+// ReadNeedleBlobById reads the needle blob by looking up the needle id in the needle map.
+func (v *Volume) ReadNeedleBlobById(key NeedleId) ([]byte, Size, error) {
+	v.dataFileAccessLock.RLock()
+	defer v.dataFileAccessLock.RUnlock()
+
+	if v.nm == nil {
+		return nil, 0, fmt.Errorf("volume %d is closed", v.Id)
+	}
+	nv, ok := v.nm.Get(key)
+	if !ok || nv.Offset.IsZero() {
+		return nil, 0, ErrorNotFound
+	}
+	if nv.Size.IsDeleted() {
+		return nil, 0, ErrorDeleted
+	}
+	blob, err := needle.ReadNeedleBlob(v.DataBackend, nv.Offset.ToActualOffset(), nv.Size, v.Version())
+	return blob, nv.Size, err
+}
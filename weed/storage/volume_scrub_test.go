@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+	"github.com/chrislusf/seaweedfs/weed/storage/super_block"
+	"github.com/chrislusf/seaweedfs/weed/storage/types"
+)
+
+func TestScrubAndRepairNeedle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scrub")
+	if err != nil {
+		t.Fatalf("temp dir creation: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	v, err := NewVolume(dir, dir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0)
+	if err != nil {
+		t.Fatalf("volume creation: %v", err)
+	}
+	defer v.Close()
+
+	for i := 1; i <= 10; i++ {
+		n := newRandomNeedle(uint64(i))
+		n.Data = append(n.Data, make([]byte, 16)...)
+		n.Checksum = needle.NewCRC(n.Data)
+		if _, _, _, err := v.writeNeedle2(n, false); err != nil {
+			t.Fatalf("write file %d: %v", i, err)
+		}
+	}
+
+	corruptedKey := types.Uint64ToNeedleId(5)
+	goodBlob, _, err := v.ReadNeedleBlobById(corruptedKey)
+	if err != nil {
+		t.Fatalf("read needle blob: %v", err)
+	}
+
+	// flip one data byte
+	nv, _ := v.nm.Get(corruptedKey)
+	dataOffset := nv.Offset.ToActualOffset() + types.NeedleHeaderSize + 4
+	b := make([]byte, 1)
+	v.DataBackend.ReadAt(b, dataOffset)
+	b[0] ^= 0xff
+	v.DataBackend.WriteAt(b, dataOffset)
+
+	if corrupted := scrubAll(t, v); len(corrupted) != 1 || corrupted[0] != corruptedKey {
+		t.Fatalf("expected corrupted needle %s, found %v", corruptedKey, corrupted)
+	}
+
+	if err = v.repairNeedle(corruptedKey, goodBlob); err != nil {
+		t.Fatalf("repair: %v", err)
+	}
+
+	if corrupted := scrubAll(t, v); len(corrupted) != 0 {
+		t.Fatalf("unexpected corrupted needles after repair: %v", corrupted)
+	}
+}
+
+func scrubAll(t *testing.T, v *Volume) (corrupted []types.NeedleId) {
+	count, _ := v.scrubIndexEntryCount()
+	for n := int64(0); n < count; n++ {
+		key, _, _, err := v.scrubIndexEntry(n)
+		if err != nil {
+			t.Logf("needle %s: %v", key, err)
+			corrupted = append(corrupted, key)
+		}
+	}
+	return
+}
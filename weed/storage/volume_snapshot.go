@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+A volume snapshot keeps the volume content at a point in time, for consistent backups without pausing the writes.
+
+The .dat and .idx files are only appended, or replaced as a whole by the compaction. So a snapshot
+hard links the .dat and .idx files, and remembers their sizes. The later writes are appended beyond
+the sizes, and the compaction writes new files, while the snapshot still links to the old ones.
+The snapshot content is the files up to the sizes, which can be cloned to another volume server.
+
+	<volume>.snapshots/<name>.dat   hard link to the .dat file, in the data directory
+	<volume>.snapshots/<name>.idx   hard link to the .idx file, in the index directory
+	<volume>.snapshots/<name>.vif   copy of the .vif file, if any
+	<volume>.snapshots/<name>.snap  the snapshot information with the file sizes
+
+A cloned snapshot has exactly the snapshot content, and is a plain volume copy to export or restore.
+The snapshots are deleted together with the volume. Until then, the snapshot of a compacted volume
+still takes the disk space of the old files.
+*/
+
+const snapshotDirSuffix = ".snapshots"
+
+var snapshotNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]{0,127}$`)
+
+func ValidateSnapshotName(name string) error {
+	if !snapshotNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid snapshot name %q, expecting letters, digits, '.', '_' or '-'", name)
+	}
+	return nil
+}
+
+func SnapshotDirectory(baseFileName string) string {
+	return baseFileName + snapshotDirSuffix
+}
+
+func SnapshotFileName(baseFileName, name, ext string) string {
+	return path.Join(SnapshotDirectory(baseFileName), name+ext)
+}
+
+// CreateSnapshot links the volume files, while the writes are blocked for a moment.
+func (v *Volume) CreateSnapshot(name string) (snapshot *volume_server_pb.VolumeSnapshot, err error) {
+
+	if err = ValidateSnapshotName(name); err != nil {
+		return nil, err
+	}
+	if v.HasRemoteFile() {
+		return nil, fmt.Errorf("volume %d is tiered to remote storage", v.Id)
+	}
+
+	v.dataFileAccessLock.Lock()
+	defer v.dataFileAccessLock.Unlock()
+
+	if v.DataBackend == nil || v.nm == nil {
+		return nil, fmt.Errorf("volume %d is not loaded", v.Id)
+	}
+	snapFileName := SnapshotFileName(v.DataFileName(), name, ".snap")
+	if util.FileExists(snapFileName) {
+		return nil, fmt.Errorf("snapshot %s of volume %d already exists", name, v.Id)
+	}
+
+	datFileSize, _, err := v.DataBackend.GetStat()
+	if err != nil {
+		return nil, fmt.Errorf("stat volume %d: %v", v.Id, err)
+	}
+	if err = v.DataBackend.Sync(); err != nil {
+		return nil, fmt.Errorf("sync volume %d: %v", v.Id, err)
+	}
+	if err = v.nm.Sync(); err != nil {
+		return nil, fmt.Errorf("sync volume %d index: %v", v.Id, err)
+	}
+	snapshot = &volume_server_pb.VolumeSnapshot{
+		Name:        name,
+		VolumeId:    uint32(v.Id),
+		Collection:  v.Collection,
+		DatFileSize: uint64(datFileSize),
+		IdxFileSize: v.nm.IndexFileSize(),
+		CreatedAtNs: time.Now().UnixNano(),
+	}
+
+	defer func() {
+		if err != nil {
+			deleteSnapshotFiles(v.DataFileName(), v.IndexFileName(), name)
+		}
+	}()
+
+	for _, baseFileName := range []string{v.DataFileName(), v.IndexFileName()} {
+		if err = os.MkdirAll(SnapshotDirectory(baseFileName), 0755); err != nil {
+			return nil, fmt.Errorf("create snapshot directory: %v", err)
+		}
+	}
+	if err = os.Link(v.FileName(".dat"), SnapshotFileName(v.DataFileName(), name, ".dat")); err != nil {
+		return nil, fmt.Errorf("link .dat file: %v", err)
+	}
+	if err = os.Link(v.FileName(".idx"), SnapshotFileName(v.IndexFileName(), name, ".idx")); err != nil {
+		return nil, fmt.Errorf("link .idx file: %v", err)
+	}
+	if vifData, readErr := ioutil.ReadFile(v.FileName(".vif")); readErr == nil {
+		if err = ioutil.WriteFile(SnapshotFileName(v.DataFileName(), name, ".vif"), vifData, 0644); err != nil {
+			return nil, fmt.Errorf("copy .vif file: %v", err)
+		}
+	}
+	if err = SaveSnapshotInfo(snapFileName, snapshot); err != nil {
+		return nil, err
+	}
+
+	glog.V(0).Infof("created snapshot %s of volume %d: %d bytes .dat %d bytes .idx", name, v.Id, snapshot.DatFileSize, snapshot.IdxFileSize)
+	return snapshot, nil
+}
+
+func SaveSnapshotInfo(fileName string, snapshot *volume_server_pb.VolumeSnapshot) error {
+	m := jsonpb.Marshaler{
+		EmitDefaults: true,
+		Indent:       "  ",
+	}
+	text, err := m.MarshalToString(snapshot)
+	if err != nil {
+		return fmt.Errorf("marshal to %s: %v", fileName, err)
+	}
+	if err = ioutil.WriteFile(fileName, []byte(text), 0644); err != nil {
+		return fmt.Errorf("fail to write %s : %v", fileName, err)
+	}
+	return nil
+}
+
+// ListSnapshots lists the snapshots of the volume files, in the order of the creation time
+func ListSnapshots(dataBaseFileName string) (snapshots []*volume_server_pb.VolumeSnapshot, err error) {
+	files, err := ioutil.ReadDir(SnapshotDirectory(dataBaseFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".snap") {
+			continue
+		}
+		data, readErr := ioutil.ReadFile(path.Join(SnapshotDirectory(dataBaseFileName), file.Name()))
+		if readErr != nil {
+			return nil, readErr
+		}
+		snapshot := &volume_server_pb.VolumeSnapshot{}
+		if err = jsonpb.Unmarshal(bytes.NewReader(data), snapshot); err != nil {
+			return nil, fmt.Errorf("unmarshal %s: %v", file.Name(), err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAtNs < snapshots[j].CreatedAtNs
+	})
+	return
+}
+
+func deleteSnapshotFiles(dataBaseFileName, indexBaseFileName, name string) {
+	os.Remove(SnapshotFileName(dataBaseFileName, name, ".snap"))
+	os.Remove(SnapshotFileName(dataBaseFileName, name, ".dat"))
+	os.Remove(SnapshotFileName(dataBaseFileName, name, ".vif"))
+	os.Remove(SnapshotFileName(indexBaseFileName, name, ".idx"))
+	// only removed if empty
+	os.Remove(SnapshotDirectory(dataBaseFileName))
+	os.Remove(SnapshotDirectory(indexBaseFileName))
+}
+
+// FindSnapshot finds the disk location of the snapshot on this server,
+// either created from a local volume, or cloned from another server.
+func (s *Store) FindSnapshot(collection string, vid needle.VolumeId, name string) (location *DiskLocation, found bool) {
+	for _, location := range s.Locations {
+		if util.FileExists(SnapshotFileName(VolumeFileName(location.Directory, collection, int(vid)), name, ".snap")) {
+			return location, true
+		}
+	}
+	return nil, false
+}
+
+func (s *Store) ListSnapshots(collection string, vid needle.VolumeId) (snapshots []*volume_server_pb.VolumeSnapshot, err error) {
+	for _, location := range s.Locations {
+		locationSnapshots, listErr := ListSnapshots(VolumeFileName(location.Directory, collection, int(vid)))
+		if listErr != nil {
+			return nil, fmt.Errorf("list snapshots of volume %d in %s: %v", vid, location.Directory, listErr)
+		}
+		snapshots = append(snapshots, locationSnapshots...)
+	}
+	return
+}
+
+func (s *Store) DeleteSnapshot(collection string, vid needle.VolumeId, name string) error {
+	if err := ValidateSnapshotName(name); err != nil {
+		return err
+	}
+	location, found := s.FindSnapshot(collection, vid, name)
+	if !found {
+		return fmt.Errorf("snapshot %s of volume %d not found", name, vid)
+	}
+	deleteSnapshotFiles(VolumeFileName(location.Directory, collection, int(vid)), VolumeFileName(location.IdxDirectory, collection, int(vid)), name)
+	return nil
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+	"github.com/chrislusf/seaweedfs/weed/storage/super_block"
+)
+
+func TestVolumeSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatalf("temp dir creation: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	v, err := NewVolume(dir, dir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0)
+	if err != nil {
+		t.Fatalf("volume creation: %v", err)
+	}
+
+	beforeSnapshotFileCount, afterSnapshotFileCount := 100, 100
+	infos := make([]*needleInfo, beforeSnapshotFileCount+afterSnapshotFileCount)
+	for i := 1; i <= beforeSnapshotFileCount; i++ {
+		doSomeWritesDeletes(i, v, t, infos)
+	}
+
+	if _, err = v.CreateSnapshot("../s1"); err == nil {
+		t.Errorf("expecting invalid snapshot name")
+	}
+	snapshot, err := v.CreateSnapshot("s1")
+	if err != nil {
+		t.Fatalf("create snapshot: %v", err)
+	}
+	if _, err = v.CreateSnapshot("s1"); err == nil {
+		t.Errorf("expecting existing snapshot")
+	}
+
+	// the later writes and the compaction do not change the snapshot
+	for i := 1; i <= afterSnapshotFileCount; i++ {
+		doSomeWritesDeletes(i+beforeSnapshotFileCount, v, t, infos)
+	}
+	if err = v.Compact2(0, 0); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	if err = v.CommitCompact(); err != nil {
+		t.Fatalf("commit compact: %v", err)
+	}
+
+	snapshots, err := ListSnapshots(v.DataFileName())
+	if err != nil || len(snapshots) != 1 || snapshots[0].DatFileSize != snapshot.DatFileSize || snapshots[0].IdxFileSize != snapshot.IdxFileSize {
+		t.Fatalf("list snapshots %v: %v", snapshots, err)
+	}
+
+	// load the snapshot content as a volume
+	cloneDir := path.Join(dir, "clone")
+	os.MkdirAll(cloneDir, 0755)
+	for ext, size := range map[string]uint64{".dat": snapshot.DatFileSize, ".idx": snapshot.IdxFileSize} {
+		data, readErr := ioutil.ReadFile(SnapshotFileName(v.DataFileName(), "s1", ext))
+		if readErr != nil || uint64(len(data)) < size {
+			t.Fatalf("read snapshot %s file: %v", ext, readErr)
+		}
+		ioutil.WriteFile(path.Join(cloneDir, "1"+ext), data[:size], 0644)
+	}
+	clone, err := NewVolume(cloneDir, cloneDir, "", 1, NeedleMapInMemory, nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("load snapshot: %v", err)
+	}
+	defer clone.Close()
+	if clone.FileCount() > uint64(beforeSnapshotFileCount) {
+		t.Errorf("snapshot file count %d", clone.FileCount())
+	}
+	for i := 1; i <= beforeSnapshotFileCount; i++ {
+		if infos[i-1].size == 0 {
+			continue
+		}
+		n := newEmptyNeedle(uint64(i))
+		if _, err = clone.readNeedle(n, nil); err != nil || n.Checksum != infos[i-1].crc {
+			t.Fatalf("read file %d from snapshot: %v", i, err)
+		}
+		break
+	}
+	if _, err = clone.readNeedle(newEmptyNeedle(uint64(beforeSnapshotFileCount+1)), nil); err == nil {
+		t.Errorf("unexpected file written after the snapshot")
+	}
+
+	v.Destroy()
+	if _, err = os.Stat(SnapshotDirectory(v.DataFileName())); !os.IsNotExist(err) {
+		t.Errorf("snapshots not deleted with the volume: %v", err)
+	}
+}
@@ -0,0 +1,276 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/storage/backend"
+	"github.com/chrislusf/seaweedfs/weed/storage/idx"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle_map"
+	. "github.com/chrislusf/seaweedfs/weed/storage/types"
+)
+
+/*
+Soft deletion keeps the deleted needles for a grace period, to recover from accidental deletions.
+
+A needle is still deleted right away, with a tombstone appended to the .dat file and the .idx file.
+Only the vacuum defers reclaiming the space. The needles deleted within the grace period of the collection
+are copied to the compacted volume together with their tombstones, and can be undeleted by the file id,
+until a later vacuum after the grace period.
+
+The deletion time is the append time of the tombstone, so only the volumes of version 3 keep the deleted needles.
+The offline "weed compact" reclaims all the deleted needles.
+
+The size and the earliest expiry of the kept deleted needles are saved in the .vif file,
+so the vacuum does not compact the volume again only for them after a restart.
+*/
+
+const softDeleteAllCollections = "*"
+
+var (
+	softDeleteGracePeriods     map[string]time.Duration
+	softDeleteGracePeriodsLock sync.RWMutex
+)
+
+// SetSoftDeleteGracePeriods sets the grace period for each collection. The "*" key applies to collections not listed.
+func SetSoftDeleteGracePeriods(gracePeriods map[string]time.Duration) {
+	softDeleteGracePeriodsLock.Lock()
+	defer softDeleteGracePeriodsLock.Unlock()
+	softDeleteGracePeriods = gracePeriods
+}
+
+// ParseSoftDeleteGracePeriods parses the comma separated <collection>:<hours>, e.g. "*:24,tmp:0"
+func ParseSoftDeleteGracePeriods(s string) (map[string]time.Duration, error) {
+	gracePeriods := make(map[string]time.Duration)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		i := strings.LastIndex(part, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("expecting <collection>:<hours>, but got %q", part)
+		}
+		hours, err := strconv.Atoi(part[i+1:])
+		if err != nil || hours < 0 {
+			return nil, fmt.Errorf("invalid hours in %q", part)
+		}
+		gracePeriods[strings.ToLower(part[:i])] = time.Duration(hours) * time.Hour
+	}
+	return gracePeriods, nil
+}
+
+func softDeleteGracePeriod(collection string) time.Duration {
+	softDeleteGracePeriodsLock.RLock()
+	defer softDeleteGracePeriodsLock.RUnlock()
+
+	if gracePeriod, found := softDeleteGracePeriods[strings.ToLower(collection)]; found {
+		return gracePeriod
+	}
+	return softDeleteGracePeriods[softDeleteAllCollections]
+}
+
+// softDeletion is the deleted needles kept by the last compaction
+type softDeletion struct {
+	size      uint64
+	expiresAt time.Time
+}
+
+// reclaimableSize excludes the kept deleted needles from the deleted size, until the earliest one expires,
+// so the vacuum does not compact the volume again only for them.
+func (sd *softDeletion) reclaimableSize(deletedSize uint64, now time.Time) uint64 {
+	if sd == nil || now.After(sd.expiresAt) || deletedSize < sd.size {
+		return deletedSize
+	}
+	return deletedSize - sd.size
+}
+
+// loadSoftDeletion restores the deleted needles kept by the last compaction from the volume info
+func (v *Volume) loadSoftDeletion() {
+	if v.volumeInfo == nil || v.volumeInfo.SoftDeletedSize == 0 {
+		v.softDeletion = nil
+		return
+	}
+	v.softDeletion = &softDeletion{
+		size:      v.volumeInfo.SoftDeletedSize,
+		expiresAt: time.Unix(0, v.volumeInfo.SoftDeletionExpiresAtNs),
+	}
+}
+
+// saveSoftDeletion saves the deleted needles kept by the compaction in the .vif file
+func (v *Volume) saveSoftDeletion(sd *softDeletion) error {
+	v.softDeletion = sd
+	if sd == nil && v.volumeInfo.SoftDeletedSize == 0 {
+		return nil
+	}
+	v.volumeInfo.SoftDeletedSize, v.volumeInfo.SoftDeletionExpiresAtNs = 0, 0
+	if sd != nil {
+		v.volumeInfo.SoftDeletedSize, v.volumeInfo.SoftDeletionExpiresAtNs = sd.size, sd.expiresAt.UnixNano()
+	}
+	return v.SaveVolumeInfo()
+}
+
+type softDeletedNeedle struct {
+	key             NeedleId
+	offset          Offset
+	size            Size
+	tombstoneOffset Offset
+	deletedAtNs     uint64
+}
+
+// findSoftDeletedNeedles finds the needles deleted in the end, and deleted after the cutoff time.
+func findSoftDeletedNeedles(idxFile *os.File, datBackend backend.BackendStorageFile, version needle.Version, cutoffNs uint64) (needles []*softDeletedNeedle, err error) {
+
+	deleted := make(map[NeedleId]*softDeletedNeedle)
+	if err = idx.WalkIndexFile(idxFile, func(key NeedleId, offset Offset, size Size) error {
+		if !offset.IsZero() && size.IsValid() {
+			delete(deleted, key)
+		} else if !offset.IsZero() {
+			deleted[key] = &softDeletedNeedle{key: key, tombstoneOffset: offset}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if len(deleted) == 0 {
+		return nil, nil
+	}
+
+	// the last put of a needle deleted in the end is its content
+	if err = idx.WalkIndexFile(idxFile, func(key NeedleId, offset Offset, size Size) error {
+		if d, found := deleted[key]; found && !offset.IsZero() && size.IsValid() {
+			d.offset, d.size = offset, size
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	for _, d := range deleted {
+		if d.offset.IsZero() {
+			continue
+		}
+		tombstone := new(needle.Needle)
+		if readErr := tombstone.ReadData(datBackend, d.tombstoneOffset.ToActualOffset(), 0, version); readErr != nil {
+			glog.V(1).Infof("read tombstone of needle %s: %v", d.key, readErr)
+			continue
+		}
+		if tombstone.AppendAtNs < cutoffNs {
+			continue
+		}
+		d.deletedAtNs = tombstone.AppendAtNs
+		needles = append(needles, d)
+	}
+	sort.Slice(needles, func(i, j int) bool {
+		return needles[i].key < needles[j].key
+	})
+	return needles, nil
+}
+
+// copySoftDeletedNeedles appends the needles and their tombstones to the compacted .dat file,
+// and returns the index entries to append to the compacted .idx file.
+func copySoftDeletedNeedles(needles []*softDeletedNeedle, srcDatBackend, dstDatBackend backend.BackendStorageFile, version needle.Version, gracePeriod time.Duration) (indexEntries []byte, sd *softDeletion, err error) {
+
+	sd = &softDeletion{}
+	for _, d := range needles {
+		blob, readErr := needle.ReadNeedleBlob(srcDatBackend, d.offset.ToActualOffset(), d.size, version)
+		if readErr != nil {
+			return nil, nil, readErr
+		}
+		n := new(needle.Needle)
+		if parseErr := n.ReadBytes(blob, d.offset.ToActualOffset(), d.size, version); parseErr != nil {
+			glog.V(0).Infof("skip deleted needle %s: %v", d.key, parseErr)
+			continue
+		}
+		offset, writeErr := needle.WriteNeedleBlob(dstDatBackend, blob, d.size, n.AppendAtNs, version)
+		if writeErr != nil {
+			return nil, nil, fmt.Errorf("copy deleted needle %s: %v", d.key, writeErr)
+		}
+		tombstone := &needle.Needle{Id: d.key, Cookie: n.Cookie, AppendAtNs: d.deletedAtNs}
+		tombstoneOffset, _, _, appendErr := tombstone.Append(dstDatBackend, version)
+		if appendErr != nil {
+			return nil, nil, fmt.Errorf("copy tombstone of needle %s: %v", d.key, appendErr)
+		}
+		indexEntries = append(indexEntries, needle_map.ToBytes(d.key, ToOffset(int64(offset)), d.size)...)
+		indexEntries = append(indexEntries, needle_map.ToBytes(d.key, ToOffset(int64(tombstoneOffset)), TombstoneFileSize)...)
+
+		sd.size += uint64(d.size)
+		expiresAt := time.Unix(0, int64(d.deletedAtNs)).Add(gracePeriod)
+		if sd.expiresAt.IsZero() || expiresAt.Before(sd.expiresAt) {
+			sd.expiresAt = expiresAt
+		}
+	}
+	return
+}
+
+func (s *Store) UndeleteVolumeNeedle(vid needle.VolumeId, key NeedleId, cookie Cookie) (Size, error) {
+	if v := s.findVolume(vid); v != nil {
+		if v.IsReadOnly() {
+			return 0, fmt.Errorf("volume %d is read only", vid)
+		}
+		return v.UndeleteNeedle(key, cookie)
+	}
+	return 0, fmt.Errorf("volume %d not found on %s:%d", vid, s.Ip, s.Port)
+}
+
+// UndeleteNeedle restores the deleted needle, if the vacuum has not reclaimed it yet.
+func (v *Volume) UndeleteNeedle(key NeedleId, cookie Cookie) (Size, error) {
+
+	v.dataFileAccessLock.Lock()
+	defer v.dataFileAccessLock.Unlock()
+
+	if nv, ok := v.nm.Get(key); ok && !nv.Offset.IsZero() && nv.Size.IsValid() {
+		return 0, fmt.Errorf("needle %s is not deleted", key)
+	}
+
+	idxFile, err := os.Open(v.FileName(".idx"))
+	if err != nil {
+		return 0, err
+	}
+	defer idxFile.Close()
+	var offset Offset
+	var size Size
+	if err = idx.WalkIndexFile(idxFile, func(k NeedleId, o Offset, s Size) error {
+		if k == key && !o.IsZero() && s.IsValid() {
+			offset, size = o, s
+		}
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	if offset.IsZero() {
+		return 0, ErrorNotFound
+	}
+
+	blob, err := needle.ReadNeedleBlob(v.DataBackend, offset.ToActualOffset(), size, v.Version())
+	if err != nil {
+		return 0, err
+	}
+	n := new(needle.Needle)
+	if err = n.ReadBytes(blob, offset.ToActualOffset(), size, v.Version()); err != nil {
+		return 0, fmt.Errorf("read deleted needle %s: %v", key, err)
+	}
+	if n.Cookie != cookie {
+		return 0, fmt.Errorf("needle %s cookie %x mismatch", key, n.Cookie)
+	}
+
+	appendAtNs := uint64(time.Now().UnixNano())
+	newOffset, err := needle.WriteNeedleBlob(v.DataBackend, blob, size, appendAtNs, v.Version())
+	v.checkReadWriteError(err)
+	if err != nil {
+		return 0, err
+	}
+	v.lastAppendAtNs = appendAtNs
+	if err = v.nm.Put(key, ToOffset(int64(newOffset)), size); err != nil {
+		return 0, err
+	}
+	glog.V(0).Infof("undeleted needle %s of volume %d", key, v.Id)
+	return size, nil
+}
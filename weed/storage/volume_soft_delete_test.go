@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+	"github.com/chrislusf/seaweedfs/weed/storage/super_block"
+	"github.com/chrislusf/seaweedfs/weed/storage/types"
+)
+
+func TestSoftDelete(t *testing.T) {
+	dir, err := ioutil.TempDir("", "example")
+	if err != nil {
+		t.Fatalf("temp dir creation: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	v, err := NewVolume(dir, dir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0)
+	if err != nil {
+		t.Fatalf("volume creation: %v", err)
+	}
+	defer func() { v.Close() }()
+
+	for i := 1; i <= 10; i++ {
+		n := newEmptyNeedle(uint64(i))
+		n.Cookie = types.Cookie(i)
+		n.Data = bytes.Repeat([]byte{byte(i)}, 100*i)
+		n.Checksum = needle.NewCRC(n.Data)
+		if _, _, _, err = v.writeNeedle2(n, false); err != nil {
+			t.Fatalf("write needle %d: %v", i, err)
+		}
+	}
+	for _, i := range []int{3, 5} {
+		n := newEmptyNeedle(uint64(i))
+		n.Cookie = types.Cookie(i)
+		if _, err = v.deleteNeedle2(n); err != nil {
+			t.Fatalf("delete needle %d: %v", i, err)
+		}
+	}
+
+	SetSoftDeleteGracePeriods(map[string]time.Duration{softDeleteAllCollections: time.Hour})
+	defer SetSoftDeleteGracePeriods(nil)
+	if err = v.Compact2(0, 0); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	if err = v.CommitCompact(); err != nil {
+		t.Fatalf("commit compact: %v", err)
+	}
+	if level := v.garbageLevel(); level != 0 {
+		t.Errorf("unexpected garbage level %f of the kept deleted needles", level)
+	}
+
+	// the kept deleted needles are still not garbage after a restart
+	v.Close()
+	if v, err = NewVolume(dir, dir, "", 1, NeedleMapInMemory, nil, nil, 0, 0); err != nil {
+		t.Fatalf("volume reloading: %v", err)
+	}
+	if level := v.garbageLevel(); level != 0 {
+		t.Errorf("unexpected garbage level %f of the kept deleted needles after a restart", level)
+	}
+
+	if _, err = v.readNeedle(newEmptyNeedle(3), nil); err != ErrorDeleted {
+		t.Fatalf("read deleted needle 3: %v", err)
+	}
+	if _, err = v.UndeleteNeedle(types.Uint64ToNeedleId(3), types.Cookie(4)); err == nil {
+		t.Errorf("undeleted needle 3 with a wrong cookie")
+	}
+	if _, err = v.UndeleteNeedle(types.Uint64ToNeedleId(4), types.Cookie(4)); err == nil {
+		t.Errorf("undeleted needle 4 which is not deleted")
+	}
+	if _, err = v.UndeleteNeedle(types.Uint64ToNeedleId(3), types.Cookie(3)); err != nil {
+		t.Fatalf("undelete needle 3: %v", err)
+	}
+	n := newEmptyNeedle(3)
+	if _, err = v.readNeedle(n, nil); err != nil {
+		t.Fatalf("read undeleted needle 3: %v", err)
+	}
+	if !bytes.Equal(n.Data, bytes.Repeat([]byte{3}, 300)) {
+		t.Errorf("undeleted needle 3 content mismatch")
+	}
+
+	// without the grace period, the vacuum reclaims the deleted needles
+	SetSoftDeleteGracePeriods(nil)
+	if err = v.Compact2(0, 0); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	if err = v.CommitCompact(); err != nil {
+		t.Fatalf("commit compact: %v", err)
+	}
+	if _, err = v.UndeleteNeedle(types.Uint64ToNeedleId(5), types.Cookie(5)); err != ErrorNotFound {
+		t.Errorf("undelete reclaimed needle 5: %v", err)
+	}
+	if _, err = v.readNeedle(newEmptyNeedle(3), nil); err != nil {
+		t.Errorf("read undeleted needle 3 after compaction: %v", err)
+	}
+}
+
+func TestParseSoftDeleteGracePeriods(t *testing.T) {
+	gracePeriods, err := ParseSoftDeleteGracePeriods("*:24, Logs:0")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	SetSoftDeleteGracePeriods(gracePeriods)
+	defer SetSoftDeleteGracePeriods(nil)
+	if d := softDeleteGracePeriod("images"); d != 24*time.Hour {
+		t.Errorf("unexpected grace period %v", d)
+	}
+	if d := softDeleteGracePeriod("logs"); d != 0 {
+		t.Errorf("unexpected grace period %v for logs", d)
+	}
+	if _, err = ParseSoftDeleteGracePeriods("logs"); err == nil {
+		t.Errorf("missing hours is not rejected")
+	}
+}
@@ -15,6 +15,10 @@ import (
 
 func (v *Volume) StreamWrite(n *needle.Needle, data io.Reader, dataSize uint32) (err error) {
 
+	if len(v.cipherKey) > 0 {
+		return fmt.Errorf("volume %d is encrypted", v.Id)
+	}
+
 	v.dataFileAccessLock.Lock()
 	defer v.dataFileAccessLock.Unlock()
 
@@ -63,6 +67,10 @@ func (v *Volume) StreamWrite(n *needle.Needle, data io.Reader, dataSize uint32)
 
 func (v *Volume) StreamRead(n *needle.Needle, writer io.Writer) (err error) {
 
+	if len(v.cipherKey) > 0 {
+		return fmt.Errorf("volume %d is encrypted", v.Id)
+	}
+
 	v.dataFileAccessLock.Lock()
 	defer v.dataFileAccessLock.Unlock()
 
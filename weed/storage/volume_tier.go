@@ -5,6 +5,8 @@ import (
 	"github.com/chrislusf/seaweedfs/weed/pb"
 	"github.com/chrislusf/seaweedfs/weed/pb/volume_server_pb"
 	"github.com/chrislusf/seaweedfs/weed/storage/backend"
+	_ "github.com/chrislusf/seaweedfs/weed/storage/backend/azure_backend"
+	_ "github.com/chrislusf/seaweedfs/weed/storage/backend/gcs_backend"
 	_ "github.com/chrislusf/seaweedfs/weed/storage/backend/s3_backend"
 	"github.com/chrislusf/seaweedfs/weed/storage/needle"
 )
@@ -48,7 +50,7 @@ func (v *Volume) LoadRemoteFile() error {
 		v.DataBackend.Close()
 	}
 
-	v.DataBackend = backendStorage.NewStorageFile(tierFile.Key, v.volumeInfo)
+	v.DataBackend = newCachedRemoteFile(backendStorage.NewStorageFile(tierFile.Key, v.volumeInfo), v.Id, tierFile.Key)
 	return nil
 }
 
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"hash/crc32"
+	"io"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/storage/backend"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+)
+
+const RemoteTierCacheBlockSize = 1024 * 1024
+
+// RemoteTierCache is usually a chunk_cache.TieredChunkCache, which can not be imported here.
+type RemoteTierCache interface {
+	GetChunk(fileId string, minSize uint64) (data []byte)
+	SetChunk(fileId string, data []byte)
+}
+
+var remoteTierCache RemoteTierCache
+
+// SetRemoteTierCache caches the blocks read from the remote tier volume files.
+func SetRemoteTierCache(cache RemoteTierCache) {
+	remoteTierCache = cache
+}
+
+// cachedRemoteFile reads the remote volume file by blocks, going to the remote storage only if the block is not cached.
+type cachedRemoteFile struct {
+	backend.BackendStorageFile
+	vid      needle.VolumeId
+	keyHash  uint64
+	cache    RemoteTierCache
+	fileSize int64
+}
+
+func newCachedRemoteFile(remoteFile backend.BackendStorageFile, vid needle.VolumeId, key string) backend.BackendStorageFile {
+	if remoteTierCache == nil {
+		return remoteFile
+	}
+	fileSize, _, err := remoteFile.GetStat()
+	if err != nil {
+		glog.Warningf("volume %d remote file %s is not cached: %v", vid, key, err)
+		return remoteFile
+	}
+	return &cachedRemoteFile{
+		BackendStorageFile: remoteFile,
+		vid:                vid,
+		// the remote key changes if the volume is uploaded again, so the old blocks are not used
+		keyHash:  uint64(crc32.ChecksumIEEE([]byte(key))) << 32,
+		cache:    remoteTierCache,
+		fileSize: fileSize,
+	}
+}
+
+func (f *cachedRemoteFile) ReadAt(p []byte, off int64) (n int, err error) {
+	for n < len(p) {
+		blockIndex := (off + int64(n)) / RemoteTierCacheBlockSize
+		block, readErr := f.readBlock(blockIndex)
+		if readErr != nil {
+			return n, readErr
+		}
+		start := off + int64(n) - blockIndex*RemoteTierCacheBlockSize
+		if start >= int64(len(block)) {
+			return n, io.EOF
+		}
+		n += copy(p[n:], block[start:])
+	}
+	return n, nil
+}
+
+func (f *cachedRemoteFile) readBlock(blockIndex int64) ([]byte, error) {
+	blockStart := blockIndex * RemoteTierCacheBlockSize
+	blockSize := f.fileSize - blockStart
+	if blockSize <= 0 {
+		return nil, io.EOF
+	}
+	if blockSize > RemoteTierCacheBlockSize {
+		blockSize = RemoteTierCacheBlockSize
+	}
+
+	blockId := needle.NewFileId(f.vid, f.keyHash|uint64(blockIndex), 0).String()
+	if data := f.cache.GetChunk(blockId, uint64(blockSize)); len(data) >= int(blockSize) {
+		return data[:blockSize], nil
+	}
+
+	data := make([]byte, blockSize)
+	if _, err := f.BackendStorageFile.ReadAt(data, blockStart); err != nil && err != io.EOF {
+		return nil, err
+	}
+	f.cache.SetChunk(blockId, data)
+	return data, nil
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/storage/backend"
+)
+
+type testTierCache map[string][]byte
+
+func (c testTierCache) GetChunk(fileId string, minSize uint64) []byte {
+	return c[fileId]
+}
+
+func (c testTierCache) SetChunk(fileId string, data []byte) {
+	c[fileId] = data
+}
+
+type countingFile struct {
+	backend.BackendStorageFile
+	readCount int
+}
+
+func (f *countingFile) ReadAt(p []byte, off int64) (int, error) {
+	f.readCount++
+	return f.BackendStorageFile.ReadAt(p, off)
+}
+
+func TestCachedRemoteFile(t *testing.T) {
+	tempFile, err := ioutil.TempFile("", "remote")
+	if err != nil {
+		t.Fatalf("temp file: %v", err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	content := make([]byte, 2*RemoteTierCacheBlockSize+104) // aligned to the needle padding
+	for i := range content {
+		content[i] = byte(i)
+	}
+	tempFile.Write(content)
+
+	remote := &countingFile{BackendStorageFile: backend.NewDiskFile(tempFile)}
+	defer remote.Close()
+
+	SetRemoteTierCache(testTierCache{})
+	defer SetRemoteTierCache(nil)
+
+	f := newCachedRemoteFile(remote, 1, "key")
+
+	p := make([]byte, 200)
+	off := int64(RemoteTierCacheBlockSize - 100)
+	if n, err := f.ReadAt(p, off); err != nil || n != len(p) || !bytes.Equal(p, content[off:off+200]) {
+		t.Fatalf("read across blocks: %d %v", n, err)
+	}
+	if remote.readCount != 2 {
+		t.Fatalf("expected 2 remote reads, got %d", remote.readCount)
+	}
+	if _, err := f.ReadAt(p, off); err != nil || remote.readCount != 2 {
+		t.Fatalf("cached blocks should not be read again: %d %v", remote.readCount, err)
+	}
+
+	off = int64(len(content) - 50)
+	if n, err := f.ReadAt(p, off); err != io.EOF || n != 50 || !bytes.Equal(p[:n], content[off:]) {
+		t.Fatalf("read past the end: %d %v", n, err)
+	}
+}
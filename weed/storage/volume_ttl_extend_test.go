@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+	"github.com/chrislusf/seaweedfs/weed/storage/super_block"
+	"github.com/chrislusf/seaweedfs/weed/storage/types"
+)
+
+func TestExtendNeedleTtl(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ttl")
+	if err != nil {
+		t.Fatalf("temp dir creation: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ttl, _ := needle.ReadTTL("5m")
+	v, err := NewVolume(dir, dir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, ttl, 0, 0)
+	if err != nil {
+		t.Fatalf("volume creation: %v", err)
+	}
+	defer v.Close()
+
+	n := &needle.Needle{Id: types.Uint64ToNeedleId(1), Cookie: 0x12345678, Data: []byte("hot object")}
+	n.Checksum = needle.NewCRC(n.Data)
+	n.Ttl = ttl
+	n.SetHasTtl()
+	n.LastModified = uint64(time.Now().Add(-time.Minute).Unix())
+	n.SetHasLastModifiedDate()
+	if _, _, _, err = v.writeNeedle2(n, false); err != nil {
+		t.Fatalf("write needle: %v", err)
+	}
+	firstAppendAtNs := n.AppendAtNs
+
+	if _, err = v.extendNeedleTtl(&needle.Needle{Id: n.Id, Cookie: 0x1}); err == nil {
+		t.Fatalf("expecting cookie mismatch")
+	}
+	extendedTtl, err := v.extendNeedleTtl(&needle.Needle{Id: n.Id, Cookie: n.Cookie})
+	if err != nil {
+		t.Fatalf("extend ttl: %v", err)
+	}
+	if extendedTtl.Minutes() != 5 {
+		t.Errorf("unexpected ttl %v", extendedTtl)
+	}
+
+	read := &needle.Needle{Id: n.Id}
+	if _, err = v.readNeedle(read, nil); err != nil {
+		t.Fatalf("read needle: %v", err)
+	}
+	if string(read.Data) != "hot object" || read.AppendAtNs <= firstAppendAtNs {
+		t.Errorf("unexpected needle %q appended at %d, first at %d", read.Data, read.AppendAtNs, firstAppendAtNs)
+	}
+	if read.LastModified < uint64(time.Now().Add(-time.Second).Unix()) {
+		t.Errorf("last modified %d is not renewed", read.LastModified)
+	}
+
+	if _, err = v.deleteNeedle2(&needle.Needle{Id: n.Id, Cookie: n.Cookie}); err != nil {
+		t.Fatalf("delete needle: %v", err)
+	}
+	if _, err = v.extendNeedleTtl(&needle.Needle{Id: n.Id, Cookie: n.Cookie}); err != ErrorDeleted {
+		t.Errorf("expecting deleted needle, but got %v", err)
+	}
+}
@@ -30,6 +30,7 @@ func (v *Volume) garbageLevel() float64 {
 		deletedSize = datFileSize - fileSize - super_block.SuperBlockSize
 		fileSize = datFileSize
 	}
+	deletedSize = v.softDeletion.reclaimableSize(deletedSize, time.Now())
 	return float64(deletedSize) / float64(fileSize)
 }
 
@@ -83,7 +84,9 @@ func (v *Volume) Compact2(preallocate int64, compactionBytePerSecond int64) erro
 	if err := v.nm.Sync(); err != nil {
 		glog.V(0).Infof("compact2 fail to sync volume idx %d: %v", v.Id, err)
 	}
-	return copyDataBasedOnIndexFile(v.FileName(".dat"), v.FileName(".idx"), v.FileName(".cpd"), v.FileName(".cpx"), v.SuperBlock, v.Version(), preallocate, compactionBytePerSecond)
+	sd, err := copyDataBasedOnIndexFile(v.FileName(".dat"), v.FileName(".idx"), v.FileName(".cpd"), v.FileName(".cpx"), v.SuperBlock, v.Version(), preallocate, v.location.backgroundThrottler(compactionBytePerSecond, BackgroundReadWrite), softDeleteGracePeriod(v.Collection))
+	v.lastCompactSoftDeletion = sd
+	return err
 }
 
 func (v *Volume) CommitCompact() error {
@@ -113,6 +116,8 @@ func (v *Volume) CommitCompact() error {
 	var e error
 	if e = v.makeupDiff(v.FileName(".cpd"), v.FileName(".cpx"), v.FileName(".dat"), v.FileName(".idx")); e != nil {
 		glog.V(0).Infof("makeupDiff in CommitCompact volume %d failed %v", v.Id, e)
+		// the volume is not compacted, so the previously kept deleted needles are still the same
+		v.lastCompactSoftDeletion = v.softDeletion
 		e = os.Remove(v.FileName(".cpd"))
 		if e != nil {
 			return e
@@ -133,9 +138,18 @@ func (v *Volume) CommitCompact() error {
 			}
 		}
 		var e error
+		var replacedDatFile *os.File
+		if backend.IsZonedFile(v.FileName(".dat")) {
+			if replacedDatFile, e = os.OpenFile(v.FileName(".dat"), os.O_RDWR, 0644); e == nil {
+				defer replacedDatFile.Close()
+			}
+		}
 		if e = os.Rename(v.FileName(".cpd"), v.FileName(".dat")); e != nil {
 			return fmt.Errorf("rename %s: %v", v.FileName(".cpd"), e)
 		}
+		if replacedDatFile != nil {
+			backend.ReleaseZones(replacedDatFile)
+		}
 		if e = os.Rename(v.FileName(".cpx"), v.FileName(".idx")); e != nil {
 			return fmt.Errorf("rename %s: %v", v.FileName(".cpx"), e)
 		}
@@ -145,11 +159,16 @@ func (v *Volume) CommitCompact() error {
 	//time.Sleep(20 * time.Second)
 
 	os.RemoveAll(v.FileName(".ldb"))
+	os.RemoveAll(v.FileName(".rdb"))
+	os.Remove(v.FileName(".ckp"))
 
 	glog.V(3).Infof("Loading volume %d commit file...", v.Id)
 	if e = v.load(true, false, v.needleMapKind, 0); e != nil {
 		return e
 	}
+	if e = v.saveSoftDeletion(v.lastCompactSoftDeletion); e != nil {
+		glog.V(0).Infof("save kept deleted needles of volume %d: %v", v.Id, e)
+	}
 	return nil
 }
 
@@ -371,7 +390,7 @@ func (v *Volume) copyDataAndGenerateIndexFile(dstName, idxName string, prealloca
 		now:            uint64(time.Now().Unix()),
 		nm:             nm,
 		dstBackend:     dst,
-		writeThrottler: util.NewWriteThrottler(compactionBytePerSecond),
+		writeThrottler: v.location.backgroundThrottler(compactionBytePerSecond, BackgroundReadWrite),
 	}
 	err = ScanVolumeFile(v.dir, v.Collection, v.Id, v.needleMapKind, scanner)
 	if err != nil {
@@ -382,7 +401,7 @@ func (v *Volume) copyDataAndGenerateIndexFile(dstName, idxName string, prealloca
 	return
 }
 
-func copyDataBasedOnIndexFile(srcDatName, srcIdxName, dstDatName, datIdxName string, sb super_block.SuperBlock, version needle.Version, preallocate int64, compactionBytePerSecond int64) (err error) {
+func copyDataBasedOnIndexFile(srcDatName, srcIdxName, dstDatName, datIdxName string, sb super_block.SuperBlock, version needle.Version, preallocate int64, writeThrottler *util.WriteThrottler, softDeleteGracePeriod time.Duration) (sd *softDeletion, err error) {
 	var (
 		srcDatBackend, dstDatBackend backend.BackendStorageFile
 		dataFile                     *os.File
@@ -400,7 +419,7 @@ func copyDataBasedOnIndexFile(srcDatName, srcIdxName, dstDatName, datIdxName str
 		return
 	}
 	if dataFile, err = os.Open(srcDatName); err != nil {
-		return err
+		return nil, err
 	}
 	srcDatBackend = backend.NewDiskFile(dataFile)
 	defer srcDatBackend.Close()
@@ -411,8 +430,6 @@ func copyDataBasedOnIndexFile(srcDatName, srcIdxName, dstDatName, datIdxName str
 	dstDatBackend.WriteAt(sb.Bytes(), 0)
 	newOffset := int64(sb.BlockSize())
 
-	writeThrottler := util.NewWriteThrottler(compactionBytePerSecond)
-
 	oldNm.AscendingVisit(func(value needle_map.NeedleValue) error {
 
 		offset, size := value.Offset, value.Size
@@ -447,5 +464,39 @@ func copyDataBasedOnIndexFile(srcDatName, srcIdxName, dstDatName, datIdxName str
 
 	newNm.SaveToIdx(datIdxName)
 
+	if softDeleteGracePeriod > 0 && version == needle.Version3 {
+		sd, err = copySoftDeletedFiles(srcIdxName, srcDatBackend, dstDatBackend, datIdxName, version, softDeleteGracePeriod)
+	}
+
 	return
 }
+
+// copySoftDeletedFiles keeps the needles deleted within the grace period in the compacted volume
+func copySoftDeletedFiles(srcIdxName string, srcDatBackend, dstDatBackend backend.BackendStorageFile, dstIdxName string, version needle.Version, gracePeriod time.Duration) (*softDeletion, error) {
+	srcIdxFile, err := os.Open(srcIdxName)
+	if err != nil {
+		return nil, err
+	}
+	defer srcIdxFile.Close()
+
+	cutoffNs := uint64(time.Now().Add(-gracePeriod).UnixNano())
+	needles, err := findSoftDeletedNeedles(srcIdxFile, srcDatBackend, version, cutoffNs)
+	if err != nil || len(needles) == 0 {
+		return nil, err
+	}
+	indexEntries, sd, err := copySoftDeletedNeedles(needles, srcDatBackend, dstDatBackend, version, gracePeriod)
+	if err != nil {
+		return nil, err
+	}
+
+	dstIdxFile, err := os.OpenFile(dstIdxName, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer dstIdxFile.Close()
+	if _, err = dstIdxFile.Write(indexEntries); err != nil {
+		return nil, fmt.Errorf("append to %s: %v", dstIdxName, err)
+	}
+	glog.V(0).Infof("kept %d deleted needles in %s, %d bytes", len(needles), dstIdxName, sd.size)
+	return sd, nil
+}
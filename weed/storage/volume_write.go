@@ -27,6 +27,7 @@ func (v *Volume) checkReadWriteError(err error) {
 	if err.Error() == "input/output error" {
 		v.lastIoError = err
 	}
+	v.location.checkIoError(err)
 }
 
 // isFileUnchanged checks whether this needle to write is same as last one.
@@ -44,6 +45,10 @@ func (v *Volume) isFileUnchanged(n *needle.Needle) bool {
 			glog.V(0).Infof("Failed to check updated file at offset %d size %d: %v", nv.Offset.ToActualOffset(), nv.Size, err)
 			return false
 		}
+		if err = v.decryptNeedle(oldNeedle); err != nil {
+			glog.V(0).Infof("Failed to check updated file at offset %d size %d: %v", nv.Offset.ToActualOffset(), nv.Size, err)
+			return false
+		}
 		if oldNeedle.Cookie == n.Cookie && oldNeedle.Checksum == n.Checksum && bytes.Equal(oldNeedle.Data, n.Data) {
 			n.DataSize = oldNeedle.DataSize
 			return true
@@ -81,8 +86,16 @@ func removeVolumeFiles(filename string) {
 	// compaction
 	os.Remove(filename + ".cpd")
 	os.Remove(filename + ".cpx")
+	// interrupted erasure coding
+	os.Remove(filename + ".ecp")
+	// snapshots
+	os.RemoveAll(SnapshotDirectory(filename))
+	// index checkpoint
+	os.Remove(filename + ".ckp")
 	// level db indx file
 	os.RemoveAll(filename + ".ldb")
+	// rocksdb indx file
+	os.RemoveAll(filename + ".rdb")
 	// marker for damaged or incomplete volume
 	os.Remove(filename + ".note")
 }
@@ -153,16 +166,23 @@ func (v *Volume) doWriteRequest(n *needle.Needle) (offset uint64, size Size, isU
 
 	// append to dat file
 	n.AppendAtNs = uint64(time.Now().UnixNano())
-	offset, size, _, err = n.Append(v.DataBackend, v.Version())
+	stored, err := v.encryptNeedle(n)
+	if err != nil {
+		return
+	}
+	offset, size, _, needleBlob, err := stored.AppendWithBlob(v.DataBackend, v.Version())
 	v.checkReadWriteError(err)
 	if err != nil {
 		return
 	}
+	if stored != n {
+		size = Size(len(n.Data))
+	}
 	v.lastAppendAtNs = n.AppendAtNs
 
 	// add to needle map
 	if !ok || uint64(nv.Offset.ToActualOffset()) < offset {
-		if err = v.nm.Put(n.Id, ToOffset(int64(offset)), n.Size); err != nil {
+		if err = v.putNeedleValue(n.Id, ToOffset(int64(offset)), stored.Size, needleBlob); err != nil {
 			glog.V(4).Infof("failed to save in needle map %d: %v", n.Id, err)
 		}
 	}
@@ -172,6 +192,51 @@ func (v *Volume) doWriteRequest(n *needle.Needle) (offset uint64, size Size, isU
 	return
 }
 
+// extendNeedleTtl appends the needle again, so its ttl counts from now on, and the ttl volume is kept alive.
+func (v *Volume) extendNeedleTtl(n *needle.Needle) (ttl *needle.TTL, err error) {
+	v.dataFileAccessLock.Lock()
+	defer v.dataFileAccessLock.Unlock()
+
+	nv, ok := v.nm.Get(n.Id)
+	if !ok || nv.Offset.IsZero() {
+		return nil, ErrorNotFound
+	}
+	if nv.Size.IsDeleted() {
+		return nil, ErrorDeleted
+	}
+	existingNeedle := new(needle.Needle)
+	if err = existingNeedle.ReadData(v.DataBackend, nv.Offset.ToActualOffset(), nv.Size, v.Version()); err != nil {
+		return nil, err
+	}
+	if err = v.decryptNeedle(existingNeedle); err != nil {
+		return nil, err
+	}
+	if existingNeedle.Cookie != n.Cookie {
+		return nil, fmt.Errorf("mismatching cookie %x", n.Cookie)
+	}
+	if !existingNeedle.HasTtl() || existingNeedle.Ttl.Minutes() == 0 {
+		return needle.EMPTY_TTL, nil
+	}
+	if isNeedleExpired(existingNeedle) {
+		return nil, ErrorNotFound
+	}
+	if v.Ttl.Minutes() == 0 {
+		// the unchanged needle would be skipped
+		return nil, fmt.Errorf("volume %d has no ttl", v.Id)
+	}
+
+	actualSize := needle.GetActualSize(nv.Size, v.Version())
+	if MaxPossibleVolumeSize < v.nm.ContentSize()+uint64(actualSize) {
+		return nil, fmt.Errorf("volume size limit %d exceeded! current size is %d", MaxPossibleVolumeSize, v.nm.ContentSize())
+	}
+	existingNeedle.LastModified = uint64(time.Now().Unix())
+	existingNeedle.SetHasLastModifiedDate()
+	if _, _, _, err = v.doWriteRequest(existingNeedle); err != nil {
+		return nil, err
+	}
+	return existingNeedle.Ttl, nil
+}
+
 func (v *Volume) syncDelete(n *needle.Needle) (Size, error) {
 	// glog.V(4).Infof("delete needle %s", needle.NewFileIdFromNeedle(v.Id, n).String())
 	actualSize := needle.GetActualSize(0, v.Version())
@@ -319,7 +384,7 @@ func (v *Volume) WriteNeedleBlob(needleId NeedleId, needleBlob []byte, size Size
 	v.lastAppendAtNs = appendAtNs
 
 	// add to needle map
-	if err = v.nm.Put(needleId, ToOffset(int64(offset)), size); err != nil {
+	if err = v.putNeedleValue(needleId, ToOffset(int64(offset)), size, needleBlob); err != nil {
 		glog.V(4).Infof("failed to put in needle map %d: %v", needleId, err)
 	}
 
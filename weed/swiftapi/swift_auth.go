@@ -0,0 +1,281 @@
+package swiftapi
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/s3api"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	tokenPrefix     = "AUTH_tk"
+	defaultTokenTtl = 24 * time.Hour
+	defaultAccount  = "AUTH_seaweedfs"
+
+	AccountTempUrlKey    = "X-Account-Meta-Temp-Url-Key"
+	AccountTempUrlKey2   = "X-Account-Meta-Temp-Url-Key-2"
+	ContainerTempUrlKey  = "X-Container-Meta-Temp-Url-Key"
+	ContainerTempUrlKey2 = "X-Container-Meta-Temp-Url-Key-2"
+)
+
+var (
+	errInvalidToken   = errors.New("invalid token")
+	errExpiredToken   = errors.New("expired token")
+	errInvalidTempUrl = errors.New("invalid temp url")
+)
+
+// AuthHandler issues a token for the X-Auth-User access key and the X-Auth-Key secret key,
+// with the storage url of the account.
+func (ss *SwiftServer) AuthHandler(w http.ResponseWriter, r *http.Request) {
+
+	user := headerValue(r, "X-Auth-User", "X-Storage-User")
+	key := headerValue(r, "X-Auth-Key", "X-Storage-Pass")
+
+	account, token := defaultAccount, tokenPrefix+"_anonymous"
+	tokenTtl := ss.option.TokenTtl
+	if tokenTtl <= 0 {
+		tokenTtl = defaultTokenTtl
+	}
+	expiresAt := time.Now().Add(tokenTtl)
+
+	if ss.iam.IsEnabled() {
+		identity, cred, found := ss.iam.LookupByAccessKey(user)
+		if !found && strings.Contains(user, ":") {
+			// the tempauth style account:user
+			identity, cred, found = ss.iam.LookupByAccessKey(user[strings.Index(user, ":")+1:])
+		}
+		if !found || subtle.ConstantTimeCompare([]byte(cred.SecretKey), []byte(key)) != 1 {
+			glog.V(1).Infof("swift auth %s failed", user)
+			writeError(w, http.StatusUnauthorized, "invalid user or key")
+			return
+		}
+		account = "AUTH_" + identity.Name
+		token = genToken(cred.AccessKey, cred.SecretKey, expiresAt)
+	}
+
+	storageUrl := fmt.Sprintf("%s://%s/v1/%s", requestScheme(r), r.Host, account)
+	w.Header().Set("X-Storage-Url", storageUrl)
+	w.Header().Set("X-Auth-Token", token)
+	w.Header().Set("X-Storage-Token", token)
+	w.Header().Set("X-Auth-Token-Expires", strconv.FormatInt(int64(time.Until(expiresAt).Seconds()), 10))
+	writeJson(w, http.StatusOK, map[string]interface{}{
+		"storage": map[string]string{"default": "local", "local": storageUrl},
+	})
+}
+
+// InfoHandler lists the capabilities, which some clients check before using the large objects or the temp urls.
+func (ss *SwiftServer) InfoHandler(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, http.StatusOK, map[string]interface{}{
+		"swift": map[string]interface{}{
+			"max_file_size":           maxObjectSize,
+			"container_listing_limit": maxListingLimit,
+		},
+		"tempurl": map[string]interface{}{
+			"methods": []string{"GET", "HEAD", "PUT"},
+		},
+		"slo": map[string]interface{}{
+			"max_manifest_segments": maxManifestSegments,
+			"max_manifest_size":     maxManifestSize,
+		},
+	})
+}
+
+// auth allows the requests with a valid token of an identity allowed to do the action,
+// or, for the objects, with a valid temp url.
+func (ss *SwiftServer) auth(f http.HandlerFunc, action s3api.Action) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !ss.iam.IsEnabled() {
+			f(w, r)
+			return
+		}
+
+		vars := mux.Vars(r)
+		if token := headerValue(r, "X-Auth-Token", "X-Storage-Token"); token != "" {
+			identity, err := ss.verifyToken(token, time.Now())
+			if err != nil {
+				writeError(w, http.StatusUnauthorized, err.Error())
+				return
+			}
+			if !identity.CanDo(action, vars["container"]) {
+				writeError(w, http.StatusForbidden, "access denied")
+				return
+			}
+			f(w, r.WithContext(context.WithValue(r.Context(), identityContextKey{}, identity)))
+			return
+		}
+
+		if r.URL.Query().Get("temp_url_sig") != "" && vars["object"] != "" {
+			if err := ss.verifyTempUrl(r, time.Now()); err != nil {
+				writeError(w, http.StatusUnauthorized, err.Error())
+				return
+			}
+			f(w, r)
+			return
+		}
+
+		writeError(w, http.StatusUnauthorized, "missing token")
+	}
+}
+
+type identityContextKey struct{}
+
+// identityOf returns the identity of the token, or nil without the auth or with a temp url.
+func identityOf(r *http.Request) *s3api.Identity {
+	identity, _ := r.Context().Value(identityContextKey{}).(*s3api.Identity)
+	return identity
+}
+
+func genToken(accessKey, secretKey string, expiresAt time.Time) string {
+	payload := accessKey + "\n" + strconv.FormatInt(expiresAt.Unix(), 10)
+	return tokenPrefix + base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + signHex(sha256.New, secretKey, payload)
+}
+
+func (ss *SwiftServer) verifyToken(token string, now time.Time) (*s3api.Identity, error) {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return nil, errInvalidToken
+	}
+	parts := strings.SplitN(token[len(tokenPrefix):], ".", 2)
+	if len(parts) != 2 {
+		return nil, errInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errInvalidToken
+	}
+	fields := strings.SplitN(string(payload), "\n", 2)
+	if len(fields) != 2 {
+		return nil, errInvalidToken
+	}
+	expiresAt, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil, errInvalidToken
+	}
+
+	identity, cred, found := ss.iam.LookupByAccessKey(fields[0])
+	if !found || !hmac.Equal([]byte(signHex(sha256.New, cred.SecretKey, string(payload))), []byte(parts[1])) {
+		return nil, errInvalidToken
+	}
+	if now.Unix() > expiresAt {
+		return nil, errExpiredToken
+	}
+	return identity, nil
+}
+
+// verifyTempUrl checks the temp_url_sig, the hmac of "<method>\n<temp_url_expires>\n<path>",
+// with any of the temp url keys of the account or the container.
+// The HEAD requests are also allowed by the signatures for GET or PUT.
+func (ss *SwiftServer) verifyTempUrl(r *http.Request, now time.Time) error {
+	query := r.URL.Query()
+	sig, expires := query.Get("temp_url_sig"), query.Get("temp_url_expires")
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return errInvalidTempUrl
+	}
+	if now.Unix() > expiresAt {
+		return errExpiredToken
+	}
+
+	methods := []string{r.Method}
+	switch r.Method {
+	case "GET", "PUT":
+	case "HEAD":
+		methods = append(methods, "GET", "PUT")
+	default:
+		return errInvalidTempUrl
+	}
+
+	keys, err := ss.getTempUrlKeys(mux.Vars(r)["container"])
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		for _, method := range methods {
+			if tempUrlSigMatches(sig, key, method, expires, r.URL.Path) {
+				return nil
+			}
+		}
+	}
+	return errInvalidTempUrl
+}
+
+func tempUrlSigMatches(sig, key, method, expires, path string) bool {
+	var h func() hash.Hash
+	switch len(sig) {
+	case sha1.Size * 2:
+		h = sha1.New
+	case sha256.Size * 2:
+		h = sha256.New
+	case sha512.Size * 2:
+		h = sha512.New
+	default:
+		return false
+	}
+	expected := signHex(h, key, method+"\n"+expires+"\n"+path)
+	return hmac.Equal([]byte(expected), []byte(strings.ToLower(sig)))
+}
+
+func (ss *SwiftServer) getTempUrlKeys(container string) (keys []string, err error) {
+	accountEntry, err := ss.getEntry(ss.accountDir())
+	if err != nil {
+		return nil, err
+	}
+	containerEntry, err := ss.getEntry(ss.containerDir(container))
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range [...]struct {
+		extended map[string][]byte
+		names    []string
+	}{
+		{accountEntry.GetExtended(), []string{AccountTempUrlKey, AccountTempUrlKey2}},
+		{containerEntry.GetExtended(), []string{ContainerTempUrlKey, ContainerTempUrlKey2}},
+	} {
+		for _, name := range entry.names {
+			if key := string(entry.extended[name]); key != "" {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys, nil
+}
+
+func signHex(h func() hash.Hash, key, message string) string {
+	mac := hmac.New(h, []byte(key))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func headerValue(r *http.Request, names ...string) string {
+	for _, name := range names {
+		if value := r.Header.Get(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func requestScheme(r *http.Request) string {
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		return proto
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
@@ -0,0 +1,126 @@
+package swiftapi
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/s3api"
+	. "github.com/chrislusf/seaweedfs/weed/s3api/s3_constants"
+)
+
+const testIdentities = `{
+  "identities": [
+    {
+      "name": "tester",
+      "credentials": [{"accessKey": "tester_key", "secretKey": "tester_secret"}],
+      "actions": ["Read", "Write"]
+    }
+  ]
+}`
+
+func newTestSwiftServer(t *testing.T) *SwiftServer {
+	dir, err := ioutil.TempDir("", "swift")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	config := filepath.Join(dir, "identities.json")
+	if err = ioutil.WriteFile(config, []byte(testIdentities), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return &SwiftServer{
+		option: &SwiftServerOption{},
+		iam:    s3api.NewIdentityAccessManagement(&s3api.S3ApiServerOption{Config: config}),
+	}
+}
+
+func TestVerifyToken(t *testing.T) {
+	ss := newTestSwiftServer(t)
+	now := time.Now()
+
+	token := genToken("tester_key", "tester_secret", now.Add(time.Hour))
+	identity, err := ss.verifyToken(token, now)
+	if err != nil || identity.Name != "tester" {
+		t.Fatalf("verify token: %v %v", identity, err)
+	}
+
+	if _, err = ss.verifyToken(token, now.Add(2*time.Hour)); err != errExpiredToken {
+		t.Errorf("expired token: %v", err)
+	}
+	if _, err = ss.verifyToken(genToken("tester_key", "wrong_secret", now.Add(time.Hour)), now); err != errInvalidToken {
+		t.Errorf("wrong secret: %v", err)
+	}
+	if _, err = ss.verifyToken(genToken("unknown_key", "tester_secret", now.Add(time.Hour)), now); err != errInvalidToken {
+		t.Errorf("unknown access key: %v", err)
+	}
+	for _, invalid := range []string{"", "AUTH_tk", "AUTH_tk!!!.abc", tokenPrefix + "_anonymous", token + "0"} {
+		if _, err = ss.verifyToken(invalid, now); err != errInvalidToken {
+			t.Errorf("invalid token %q: %v", invalid, err)
+		}
+	}
+}
+
+func TestTempUrlSigMatches(t *testing.T) {
+	// the example in the swift tempurl documentation
+	key, method, expires, path := "mykey", "GET", "1323479485", "/v1/AUTH_account/container/object"
+	mac := hmac.New(sha1.New, []byte(key))
+	mac.Write([]byte(method + "\n" + expires + "\n" + path))
+	sig := hex.EncodeToString(mac.Sum(nil))
+
+	if !tempUrlSigMatches(sig, key, method, expires, path) {
+		t.Errorf("sha1 signature should match")
+	}
+	if !tempUrlSigMatches(signHex(sha1.New, key, method+"\n"+expires+"\n"+path), key, method, expires, path) {
+		t.Errorf("signHex should match")
+	}
+	if tempUrlSigMatches(sig, key, "PUT", expires, path) {
+		t.Errorf("signature for GET should not match PUT")
+	}
+	if tempUrlSigMatches(sig, "otherkey", method, expires, path) {
+		t.Errorf("signature should not match another key")
+	}
+	if tempUrlSigMatches(sig[:10], key, method, expires, path) {
+		t.Errorf("truncated signature should not match")
+	}
+}
+
+func TestHideTempUrlKeys(t *testing.T) {
+	ss := newTestSwiftServer(t)
+	now := time.Now()
+	identity, err := ss.verifyToken(genToken("tester_key", "tester_secret", now.Add(time.Hour)), now)
+	if err != nil {
+		t.Fatalf("verify token: %v", err)
+	}
+	entry := &filer_pb.Entry{Extended: map[string][]byte{
+		AccountTempUrlKey:          []byte("key"),
+		AccountTempUrlKey2:         []byte("key2"),
+		accountMetaPrefix + "Name": []byte("value"),
+	}}
+
+	r := httptest.NewRequest("HEAD", "/v1/AUTH_test", nil)
+	r = r.WithContext(context.WithValue(r.Context(), identityContextKey{}, identity))
+	w := httptest.NewRecorder()
+	ss.setAccountHeaders(w, r, entry, 0)
+	if w.Header().Get(AccountTempUrlKey) != "" || w.Header().Get(AccountTempUrlKey2) != "" {
+		t.Errorf("temp url keys shown to a non-admin identity: %v", w.Header())
+	}
+	if w.Header().Get(accountMetaPrefix+"Name") != "value" {
+		t.Errorf("metadata not shown: %v", w.Header())
+	}
+
+	identity.Actions = append(identity.Actions, ACTION_ADMIN)
+	w = httptest.NewRecorder()
+	ss.setAccountHeaders(w, r, entry, 0)
+	if w.Header().Get(AccountTempUrlKey) != "key" || w.Header().Get(AccountTempUrlKey2) != "key2" {
+		t.Errorf("temp url keys hidden from an admin identity: %v", w.Header())
+	}
+}
@@ -0,0 +1,265 @@
+package swiftapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	. "github.com/chrislusf/seaweedfs/weed/s3api/s3_constants"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	accountMetaPrefix   = "X-Account-Meta-"
+	containerMetaPrefix = "X-Container-Meta-"
+	maxContainerName    = 256
+)
+
+// The object counts and the bytes used are not tracked, and reported as 0.
+
+func (ss *SwiftServer) HeadAccountHandler(w http.ResponseWriter, r *http.Request) {
+	entry, containers, ok := ss.getAccount(w, r)
+	if !ok {
+		return
+	}
+	ss.setAccountHeaders(w, r, entry, len(containers))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (ss *SwiftServer) ListContainersHandler(w http.ResponseWriter, r *http.Request) {
+	option, err := parseListOption(r)
+	if err != nil {
+		writeError(w, http.StatusPreconditionFailed, "invalid limit")
+		return
+	}
+	entry, containers, ok := ss.getAccount(w, r)
+	if !ok {
+		return
+	}
+	items, err := ss.listContainers(option)
+	if err != nil {
+		glog.Errorf("list containers: %v", err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	ss.setAccountHeaders(w, r, entry, len(containers))
+	writeListing(w, r, items, true)
+}
+
+func (ss *SwiftServer) PostAccountHandler(w http.ResponseWriter, r *http.Request) {
+	entry, err := ss.getEntry(ss.accountDir())
+	if err != nil || entry == nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("read %s: %v", ss.accountDir(), err))
+		return
+	}
+	parent, _ := ss.accountDir().DirAndName()
+	if err = ss.updateEntryMetadata(r, parent, entry, accountMetaPrefix); err != nil {
+		glog.Errorf("update account metadata: %v", err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (ss *SwiftServer) getAccount(w http.ResponseWriter, r *http.Request) (entry *filer_pb.Entry, containers []*listItem, ok bool) {
+	entry, err := ss.getEntry(ss.accountDir())
+	if err == nil {
+		containers, err = ss.listContainers(&listOption{limit: maxListingLimit})
+	}
+	if err != nil {
+		glog.Errorf("read account: %v", err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return nil, nil, false
+	}
+	return entry, containers, true
+}
+
+// setAccountHeaders hides the temp url keys unless the identity is an admin of the account.
+func (ss *SwiftServer) setAccountHeaders(w http.ResponseWriter, r *http.Request, entry *filer_pb.Entry, containerCount int) {
+	w.Header().Set("X-Account-Container-Count", strconv.Itoa(containerCount))
+	w.Header().Set("X-Account-Object-Count", "0")
+	w.Header().Set("X-Account-Bytes-Used", "0")
+	if canAdmin(r, "") {
+		setMetadataHeaders(w, entry.GetExtended(), accountMetaPrefix)
+	} else {
+		setMetadataHeaders(w, entry.GetExtended(), accountMetaPrefix, AccountTempUrlKey, AccountTempUrlKey2)
+	}
+}
+
+func (ss *SwiftServer) HeadContainerHandler(w http.ResponseWriter, r *http.Request) {
+	entry, ok := ss.getContainer(w, r)
+	if !ok {
+		return
+	}
+	setContainerHeaders(w, r, entry)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (ss *SwiftServer) ListObjectsHandler(w http.ResponseWriter, r *http.Request) {
+	option, err := parseListOption(r)
+	if err != nil {
+		writeError(w, http.StatusPreconditionFailed, "invalid limit")
+		return
+	}
+	entry, ok := ss.getContainer(w, r)
+	if !ok {
+		return
+	}
+	items, err := ss.listObjects(entry.Name, option)
+	if err != nil {
+		glog.Errorf("list container %s: %v", entry.Name, err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	setContainerHeaders(w, r, entry)
+	writeListing(w, r, items, false)
+}
+
+// PutContainerHandler creates the container, or updates the metadata of an existing container.
+func (ss *SwiftServer) PutContainerHandler(w http.ResponseWriter, r *http.Request) {
+	container := mux.Vars(r)["container"]
+	if len(container) > maxContainerName {
+		writeError(w, http.StatusBadRequest, "container name too long")
+		return
+	}
+
+	entry, err := ss.getEntry(ss.containerDir(container))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if entry != nil {
+		if !entry.IsDirectory {
+			writeError(w, http.StatusConflict, container+" is not a container")
+			return
+		}
+		if err = ss.updateEntryMetadata(r, string(ss.accountDir()), entry, containerMetaPrefix); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	// create the folder for the container, but lazily create actual collection, the same as the S3 buckets
+	err = filer_pb.Mkdir(ss, string(ss.accountDir()), container, func(entry *filer_pb.Entry) {
+		entry.Extended = make(map[string][]byte)
+		updateMetadata(r, entry.Extended, containerMetaPrefix)
+	})
+	if err != nil {
+		glog.Errorf("create container %s: %v", container, err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (ss *SwiftServer) PostContainerHandler(w http.ResponseWriter, r *http.Request) {
+	entry, ok := ss.getContainer(w, r)
+	if !ok {
+		return
+	}
+	if err := ss.updateEntryMetadata(r, string(ss.accountDir()), entry, containerMetaPrefix); err != nil {
+		glog.Errorf("update container %s metadata: %v", entry.Name, err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteContainerHandler deletes an empty container, and its collection.
+func (ss *SwiftServer) DeleteContainerHandler(w http.ResponseWriter, r *http.Request) {
+	entry, ok := ss.getContainer(w, r)
+	if !ok {
+		return
+	}
+	container := entry.Name
+
+	isEmpty := true
+	if err := ss.eachEntry(ss.containerDir(container), "", "", func(entry *filer_pb.Entry) (bool, error) {
+		isEmpty = false
+		return false, nil
+	}); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !isEmpty {
+		writeError(w, http.StatusConflict, "the container is not empty")
+		return
+	}
+
+	err := ss.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+		if _, err := client.DeleteCollection(context.Background(), &filer_pb.DeleteCollectionRequest{
+			Collection: container,
+		}); err != nil {
+			return fmt.Errorf("delete collection %s: %v", container, err)
+		}
+		return nil
+	})
+	if err == nil {
+		err = filer_pb.Remove(ss, string(ss.accountDir()), container, false, true, true, false, nil)
+	}
+	if err != nil {
+		glog.Errorf("delete container %s: %v", container, err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getContainer responds 404 if the container is not found.
+func (ss *SwiftServer) getContainer(w http.ResponseWriter, r *http.Request) (entry *filer_pb.Entry, ok bool) {
+	container := mux.Vars(r)["container"]
+	entry, err := ss.getEntry(ss.containerDir(container))
+	if err != nil {
+		glog.Errorf("read container %s: %v", container, err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	if entry == nil || !entry.IsDirectory {
+		writeError(w, http.StatusNotFound, "container "+container+" not found")
+		return nil, false
+	}
+	return entry, true
+}
+
+// setContainerHeaders hides the temp url keys unless the identity is an admin of the container.
+func setContainerHeaders(w http.ResponseWriter, r *http.Request, entry *filer_pb.Entry) {
+	w.Header().Set("X-Container-Object-Count", "0")
+	w.Header().Set("X-Container-Bytes-Used", "0")
+	w.Header().Set("X-Timestamp", strconv.FormatInt(entry.Attributes.GetCrtime(), 10))
+	if canAdmin(r, mux.Vars(r)["container"]) {
+		setMetadataHeaders(w, entry.GetExtended(), containerMetaPrefix)
+	} else {
+		setMetadataHeaders(w, entry.GetExtended(), containerMetaPrefix, ContainerTempUrlKey, ContainerTempUrlKey2)
+	}
+}
+
+// canAdmin checks the identity of the token is an admin of the container, or of the account with an empty container,
+// if authenticated by a token.
+func canAdmin(r *http.Request, container string) bool {
+	if identity := identityOf(r); identity != nil {
+		return identity.CanDo(ACTION_ADMIN, container)
+	}
+	return true
+}
+
+// updateEntryMetadata applies the metadata headers to the entry in the parent folder.
+func (ss *SwiftServer) updateEntryMetadata(r *http.Request, parent string, entry *filer_pb.Entry, prefix string) error {
+	if entry.Extended == nil {
+		entry.Extended = make(map[string][]byte)
+	}
+	if !updateMetadata(r, entry.Extended, prefix) {
+		return nil
+	}
+	return ss.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+		return filer_pb.UpdateEntry(client, &filer_pb.UpdateEntryRequest{
+			Directory: parent,
+			Entry:     entry,
+		})
+	})
+}
@@ -0,0 +1,433 @@
+package swiftapi
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	xhttp "github.com/chrislusf/seaweedfs/weed/s3api/http"
+	. "github.com/chrislusf/seaweedfs/weed/s3api/s3_constants"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+The large objects are read by concatenating their segments, which are normal objects:
+
+	dynamic  a manifest object with the X-Object-Manifest: <container>/<prefix> header,
+	         for all the objects with the prefix in the container, in name order
+	static   a manifest object uploaded with ?multipart-manifest=put, listing the segments,
+	         which are checked on upload, and saved as the content of the manifest object
+
+The segments are not changed by the manifests, and are deleted separately,
+or, for the static large objects, with ?multipart-manifest=delete.
+*/
+
+const (
+	dynamicManifestKey   = "X-Object-Manifest"
+	staticLargeObjectKey = "X-Static-Large-Object"
+	sloSizeKey           = "X-Object-Sysmeta-Slo-Size"
+	sloEtagKey           = "X-Object-Sysmeta-Slo-Etag"
+	maxManifestSegments  = 1000
+	maxManifestSize      = 8 * 1024 * 1024
+)
+
+// the uploaded static manifest
+type manifestSegment struct {
+	Path      string  `json:"path"`
+	Etag      *string `json:"etag"`
+	SizeBytes *int64  `json:"size_bytes"`
+}
+
+// the saved static manifest, in the same format as ?multipart-manifest=get
+type segment struct {
+	Name         string `json:"name"`
+	Hash         string `json:"hash"`
+	Bytes        int64  `json:"bytes"`
+	ContentType  string `json:"content_type"`
+	LastModified string `json:"last_modified"`
+}
+
+func (s *segment) containerAndObject() (container, object string, err error) {
+	return splitSegmentPath(s.Name)
+}
+
+func isLargeObject(entry *filer_pb.Entry) bool {
+	return len(entry.Extended[dynamicManifestKey]) > 0 || isStaticLargeObject(entry)
+}
+
+func isStaticLargeObject(entry *filer_pb.Entry) bool {
+	return string(entry.Extended[staticLargeObjectKey]) == "True"
+}
+
+// splitSegmentPath splits "/container/object", or "container/prefix" of the dynamic manifests.
+func splitSegmentPath(path string) (container, object string, err error) {
+	path = strings.TrimPrefix(path, "/")
+	i := strings.Index(path, "/")
+	if i <= 0 {
+		return "", "", fmt.Errorf("invalid segment path %s", path)
+	}
+	return path[:i], path[i+1:], nil
+}
+
+func (ss *SwiftServer) putDynamicLargeObject(w http.ResponseWriter, r *http.Request, container, object, manifest string) {
+	manifest, err := url.PathUnescape(manifest)
+	if err == nil {
+		_, _, err = splitSegmentPath(manifest)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid "+dynamicManifestKey)
+		return
+	}
+	if _, err = io.Copy(ioutil.Discard, r.Body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err = ss.createManifestObject(r, container, object, nil, map[string][]byte{
+		dynamicManifestKey: []byte(manifest),
+	}); err != nil {
+		glog.Errorf("create dynamic large object %s: %v", r.URL.Path, err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	emptyMd5 := md5.Sum(nil)
+	w.Header().Set("ETag", hex.EncodeToString(emptyMd5[:]))
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (ss *SwiftServer) putStaticLargeObject(w http.ResponseWriter, r *http.Request, container, object string) {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxManifestSize+1))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(body) > maxManifestSize {
+		writeError(w, http.StatusRequestEntityTooLarge, "manifest too large")
+		return
+	}
+	var uploaded []*manifestSegment
+	if err = json.Unmarshal(body, &uploaded); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid manifest: "+err.Error())
+		return
+	}
+	if len(uploaded) == 0 || len(uploaded) > maxManifestSegments {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("the manifest needs 1 to %d segments", maxManifestSegments))
+		return
+	}
+
+	segments, err := ss.checkSegments(r, uploaded)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	content, err := json.Marshal(segments)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	size, etag := segmentsSizeAndEtag(segments)
+
+	if err = ss.createManifestObject(r, container, object, content, map[string][]byte{
+		staticLargeObjectKey: []byte("True"),
+		sloSizeKey:           []byte(strconv.FormatInt(size, 10)),
+		sloEtagKey:           []byte(etag),
+	}); err != nil {
+		glog.Errorf("create static large object %s: %v", r.URL.Path, err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("ETag", "\""+etag+"\"")
+	w.WriteHeader(http.StatusCreated)
+}
+
+// checkSegments looks up the segments, which should be readable normal objects, with the expected etags and sizes.
+func (ss *SwiftServer) checkSegments(r *http.Request, uploaded []*manifestSegment) (segments []*segment, err error) {
+	for i, s := range uploaded {
+		container, object, err := splitSegmentPath(s.Path)
+		if err != nil {
+			return nil, fmt.Errorf("segment %d: %v", i, err)
+		}
+		if !canRead(r, container) {
+			return nil, fmt.Errorf("segment %d %s: access denied", i, s.Path)
+		}
+		entry, err := ss.getEntry(ss.objectPath(container, object))
+		if err != nil {
+			return nil, fmt.Errorf("segment %d %s: %v", i, s.Path, err)
+		}
+		if entry == nil || entry.IsDirectory {
+			return nil, fmt.Errorf("segment %d %s: not found", i, s.Path)
+		}
+		if isLargeObject(entry) {
+			return nil, fmt.Errorf("segment %d %s: nested large objects are not supported", i, s.Path)
+		}
+		seg := &segment{
+			Name:         "/" + container + "/" + object,
+			Hash:         objectETag(entry),
+			Bytes:        objectSize(entry),
+			ContentType:  objectContentType(entry),
+			LastModified: time.Unix(entry.Attributes.GetMtime(), 0).UTC().Format(lastModifiedFmt),
+		}
+		if s.Etag != nil && *s.Etag != "" && !strings.EqualFold(strings.Trim(*s.Etag, "\""), seg.Hash) {
+			return nil, fmt.Errorf("segment %d %s: etag %s does not match %s", i, s.Path, seg.Hash, *s.Etag)
+		}
+		if s.SizeBytes != nil && *s.SizeBytes != seg.Bytes {
+			return nil, fmt.Errorf("segment %d %s: size %d does not match %d", i, s.Path, seg.Bytes, *s.SizeBytes)
+		}
+		segments = append(segments, seg)
+	}
+	return segments, nil
+}
+
+// segmentsSizeAndEtag returns the total size, and the md5 of the segment etags.
+func segmentsSizeAndEtag(segments []*segment) (size int64, etag string) {
+	h := md5.New()
+	for _, s := range segments {
+		size += s.Bytes
+		h.Write([]byte(s.Hash))
+	}
+	return size, hex.EncodeToString(h.Sum(nil))
+}
+
+func (ss *SwiftServer) createManifestObject(r *http.Request, container, object string, content []byte, extended map[string][]byte) error {
+	for header, values := range r.Header {
+		if strings.HasPrefix(header, objectMetaPrefix) && len(values) > 0 && values[0] != "" {
+			extended[xhttp.AmzUserMetaPrefix+header[len(objectMetaPrefix):]] = []byte(values[0])
+		}
+	}
+	now := time.Now().Unix()
+	dir, name := ss.objectPath(container, object).DirAndName()
+	entry := &filer_pb.Entry{
+		Name: name,
+		Attributes: &filer_pb.FuseAttributes{
+			Mtime:    now,
+			Crtime:   now,
+			FileMode: uint32(0644),
+			Uid:      filer_pb.OS_UID,
+			Gid:      filer_pb.OS_GID,
+			Mime:     r.Header.Get("Content-Type"),
+			FileSize: uint64(len(content)),
+		},
+		Content:  content,
+		Extended: extended,
+	}
+	return ss.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+		return filer_pb.CreateEntry(client, &filer_pb.CreateEntryRequest{
+			Directory: dir,
+			Entry:     entry,
+		})
+	})
+}
+
+// largeObjectSegments lists the segments of the static manifest, or of the dynamic manifest prefix.
+func (ss *SwiftServer) largeObjectSegments(r *http.Request, entry *filer_pb.Entry) (segments []*segment, err error) {
+	if isStaticLargeObject(entry) {
+		if err = json.Unmarshal(entry.Content, &segments); err != nil {
+			return nil, fmt.Errorf("invalid manifest: %v", err)
+		}
+		for _, s := range segments {
+			container, _, err := s.containerAndObject()
+			if err != nil {
+				return nil, err
+			}
+			if !canRead(r, container) {
+				return nil, fmt.Errorf("segment %s: access denied", s.Name)
+			}
+		}
+		return segments, nil
+	}
+
+	container, prefix, err := splitSegmentPath(string(entry.Extended[dynamicManifestKey]))
+	if err != nil {
+		return nil, err
+	}
+	if !canRead(r, container) {
+		return nil, fmt.Errorf("segments %s: access denied", container)
+	}
+	items, err := ss.listObjects(container, &listOption{prefix: prefix, limit: math.MaxInt32})
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if isLargeObject(item.entry) {
+			continue
+		}
+		segments = append(segments, &segment{
+			Name:  "/" + container + "/" + item.name,
+			Hash:  objectETag(item.entry),
+			Bytes: objectSize(item.entry),
+		})
+	}
+	return segments, nil
+}
+
+// serveLargeObject streams the segments in the requested range, one by one.
+func (ss *SwiftServer) serveLargeObject(w http.ResponseWriter, r *http.Request, entry *filer_pb.Entry) {
+	segments, err := ss.largeObjectSegments(r, entry)
+	if err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	totalSize, etag := segmentsSizeAndEtag(segments)
+
+	w.Header().Set("Content-Type", objectContentType(entry))
+	w.Header().Set("ETag", "\""+etag+"\"")
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Last-Modified", time.Unix(entry.Attributes.GetMtime(), 0).UTC().Format(http.TimeFormat))
+	setObjectHeaders(w, entry)
+
+	offset, size, err := parseRange(r.Header.Get("Range"), totalSize)
+	if err != nil {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", totalSize))
+		writeError(w, http.StatusRequestedRangeNotSatisfiable, err.Error())
+		return
+	}
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	if size != totalSize {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+size-1, totalSize))
+		w.WriteHeader(http.StatusPartialContent)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	if r.Method == "HEAD" {
+		return
+	}
+
+	if err = ss.streamSegments(w, segments, offset, size); err != nil {
+		// the response is already started
+		glog.Errorf("read large object %s: %v", r.URL.Path, err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+func (ss *SwiftServer) streamSegments(w io.Writer, segments []*segment, offset, size int64) error {
+	var segmentStart int64
+	for _, s := range segments {
+		segmentStop := segmentStart + s.Bytes
+		start, stop := offset, offset+size
+		if start < segmentStart {
+			start = segmentStart
+		}
+		if stop > segmentStop {
+			stop = segmentStop
+		}
+		if start < stop {
+			if err := ss.readSegment(w, s, start-segmentStart, stop-start); err != nil {
+				return fmt.Errorf("segment %s: %v", s.Name, err)
+			}
+		}
+		segmentStart = segmentStop
+	}
+	return nil
+}
+
+func (ss *SwiftServer) readSegment(w io.Writer, s *segment, offset, size int64) error {
+	container, object, err := s.containerAndObject()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("GET", ss.filerUrl(container, object), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+size-1))
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer util.CloseResponse(resp)
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("read: %s", resp.Status)
+	}
+	n, err := io.CopyN(w, resp.Body, size)
+	if err == io.EOF {
+		return fmt.Errorf("read %d of %d bytes, the segment is changed", n, size)
+	}
+	return err
+}
+
+func (ss *SwiftServer) deleteSegments(entry *filer_pb.Entry) error {
+	var segments []*segment
+	if err := json.Unmarshal(entry.Content, &segments); err != nil {
+		return fmt.Errorf("invalid manifest: %v", err)
+	}
+	for _, s := range segments {
+		container, object, err := s.containerAndObject()
+		if err != nil {
+			return err
+		}
+		segmentEntry, err := ss.getEntry(ss.objectPath(container, object))
+		if err != nil {
+			return err
+		}
+		if segmentEntry == nil {
+			continue
+		}
+		if err = ss.deleteObject(container, object); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var errInvalidRange = errors.New("invalid range")
+
+// parseRange parses one range of the Range header, or the whole content without the header.
+// The multiple ranges are served as the whole content.
+func parseRange(rangeHeader string, totalSize int64) (offset, size int64, err error) {
+	if !strings.HasPrefix(rangeHeader, "bytes=") || strings.Contains(rangeHeader, ",") {
+		return 0, totalSize, nil
+	}
+	spec := strings.TrimSpace(rangeHeader[len("bytes="):])
+	i := strings.Index(spec, "-")
+	if i < 0 {
+		return 0, 0, errInvalidRange
+	}
+	startText, stopText := strings.TrimSpace(spec[:i]), strings.TrimSpace(spec[i+1:])
+	if startText == "" {
+		// the suffix range
+		n, err := strconv.ParseInt(stopText, 10, 64)
+		if err != nil || n <= 0 || totalSize == 0 {
+			return 0, 0, errInvalidRange
+		}
+		if n > totalSize {
+			n = totalSize
+		}
+		return totalSize - n, n, nil
+	}
+	start, err := strconv.ParseInt(startText, 10, 64)
+	if err != nil || start < 0 || start >= totalSize {
+		return 0, 0, errInvalidRange
+	}
+	stop := totalSize - 1
+	if stopText != "" {
+		if stop, err = strconv.ParseInt(stopText, 10, 64); err != nil || stop < start {
+			return 0, 0, errInvalidRange
+		}
+		if stop >= totalSize {
+			stop = totalSize - 1
+		}
+	}
+	return start, stop - start + 1, nil
+}
+
+// canRead checks the identity of the token can read the container, if authenticated by a token.
+func canRead(r *http.Request, container string) bool {
+	if identity := identityOf(r); identity != nil {
+		return identity.CanDo(ACTION_READ, container)
+	}
+	return true
+}
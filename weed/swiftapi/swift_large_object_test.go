@@ -0,0 +1,38 @@
+package swiftapi
+
+import (
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		header       string
+		total        int64
+		offset, size int64
+		invalid      bool
+	}{
+		{"", 100, 0, 100, false},
+		{"bytes=0-9", 100, 0, 10, false},
+		{"bytes=90-", 100, 90, 10, false},
+		{"bytes=90-200", 100, 90, 10, false},
+		{"bytes=-10", 100, 90, 10, false},
+		{"bytes=-200", 100, 0, 100, false},
+		{"bytes=0-9,20-29", 100, 0, 100, false},
+		{"bytes=100-", 100, 0, 0, true},
+		{"bytes=9-0", 100, 0, 0, true},
+		{"bytes=-0", 100, 0, 0, true},
+		{"bytes=a-b", 100, 0, 0, true},
+	}
+	for _, tt := range tests {
+		offset, size, err := parseRange(tt.header, tt.total)
+		if tt.invalid {
+			if err == nil {
+				t.Errorf("%q should be invalid", tt.header)
+			}
+			continue
+		}
+		if err != nil || offset != tt.offset || size != tt.size {
+			t.Errorf("%q: got %d,%d %v, expected %d,%d", tt.header, offset, size, err, tt.offset, tt.size)
+		}
+	}
+}
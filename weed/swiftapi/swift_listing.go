@@ -0,0 +1,240 @@
+package swiftapi
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+const (
+	maxListingLimit = 10000
+	listPageSize    = 1024
+	lastModifiedFmt = "2006-01-02T15:04:05.000000"
+)
+
+type listOption struct {
+	prefix    string
+	delimiter string
+	marker    string
+	endMarker string
+	limit     int
+}
+
+// listItem is either an object, or a subdir rolled up by the delimiter.
+type listItem struct {
+	name   string
+	subdir bool
+	entry  *filer_pb.Entry
+}
+
+func parseListOption(r *http.Request) (*listOption, error) {
+	query := r.URL.Query()
+	option := &listOption{
+		prefix:    query.Get("prefix"),
+		delimiter: query.Get("delimiter"),
+		marker:    query.Get("marker"),
+		endMarker: query.Get("end_marker"),
+		limit:     maxListingLimit,
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 || n > maxListingLimit {
+			return nil, strconv.ErrRange
+		}
+		option.limit = n
+	}
+	return option, nil
+}
+
+// inRange checks the name is after the marker and before the end marker.
+func (option *listOption) inRange(name string) bool {
+	return name > option.marker && (option.endMarker == "" || name < option.endMarker)
+}
+
+// eachEntry lists the folder page by page from startFrom, until fn returns false.
+func (ss *SwiftServer) eachEntry(dir util.FullPath, prefix, startFrom string, fn func(entry *filer_pb.Entry) (bool, error)) error {
+	inclusive := true
+	for {
+		var entries []*filer_pb.Entry
+		err := filer_pb.List(ss, string(dir), prefix, func(entry *filer_pb.Entry, isLast bool) error {
+			entries = append(entries, entry)
+			return nil
+		}, startFrom, inclusive, listPageSize)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if more, err := fn(entry); err != nil || !more {
+				return err
+			}
+		}
+		if len(entries) < listPageSize {
+			return nil
+		}
+		startFrom, inclusive = entries[len(entries)-1].Name, false
+	}
+}
+
+// listContainers lists the folders in the buckets folder.
+func (ss *SwiftServer) listContainers(option *listOption) (items []*listItem, err error) {
+	err = ss.eachEntry(ss.accountDir(), option.prefix, option.marker, func(entry *filer_pb.Entry) (bool, error) {
+		if len(items) >= option.limit {
+			return false, nil
+		}
+		if entry.IsDirectory && option.inRange(entry.Name) {
+			items = append(items, &listItem{name: entry.Name, entry: entry})
+		}
+		return true, nil
+	})
+	return
+}
+
+// listObjects walks the container folders in name order, for the objects in the listing range.
+// With the delimiter, the names containing the delimiter after the prefix are rolled up into the subdirs.
+func (ss *SwiftServer) listObjects(container string, option *listOption) (items []*listItem, err error) {
+	if option.limit == 0 {
+		return nil, nil
+	}
+	_, err = ss.doListObjects(ss.containerDir(container), "", option, &items)
+	return
+}
+
+func (ss *SwiftServer) doListObjects(dir util.FullPath, keyPrefix string, option *listOption, items *[]*listItem) (more bool, err error) {
+
+	// only list the names which can match the prefix
+	namePrefix := ""
+	if len(option.prefix) > len(keyPrefix) {
+		namePrefix = option.prefix[len(keyPrefix):]
+		if i := strings.Index(namePrefix, "/"); i >= 0 {
+			namePrefix = namePrefix[:i]
+		}
+	}
+	// start from the folder or the object of the marker
+	startFrom := ""
+	if strings.HasPrefix(option.marker, keyPrefix) {
+		startFrom = option.marker[len(keyPrefix):]
+		if i := strings.Index(startFrom, "/"); i >= 0 {
+			startFrom = startFrom[:i]
+		}
+	}
+
+	more = true
+	err = ss.eachEntry(dir, namePrefix, startFrom, func(entry *filer_pb.Entry) (bool, error) {
+		key := keyPrefix + entry.Name
+		if !entry.IsDirectory {
+			if strings.HasPrefix(key, option.prefix) && option.inRange(key) {
+				more = option.add(items, key, entry)
+			}
+			return more, nil
+		}
+
+		dirKey := key + "/"
+		if !strings.HasPrefix(dirKey, option.prefix) && !strings.HasPrefix(option.prefix, dirKey) {
+			return true, nil
+		}
+		if dirKey <= option.marker && !strings.HasPrefix(option.marker, dirKey) {
+			// all the names in the folder are before the marker
+			return true, nil
+		}
+		if option.endMarker != "" && dirKey >= option.endMarker {
+			return true, nil
+		}
+		if option.delimiter != "" && strings.HasPrefix(dirKey, option.prefix) && strings.Contains(dirKey[len(option.prefix):], option.delimiter) {
+			// all the names in the folder are rolled up
+			more = option.add(items, dirKey, nil)
+			return more, nil
+		}
+		var err error
+		more, err = ss.doListObjects(dir.Child(entry.Name), dirKey, option, items)
+		return more, err
+	})
+	return
+}
+
+// add an object, or its subdir with the delimiter, returning false after the limit.
+func (option *listOption) add(items *[]*listItem, key string, entry *filer_pb.Entry) bool {
+	item := &listItem{name: key, entry: entry}
+	if option.delimiter != "" {
+		rest := key[len(option.prefix):]
+		if i := strings.Index(rest, option.delimiter); i >= 0 {
+			item = &listItem{name: option.prefix + rest[:i+len(option.delimiter)], subdir: true}
+		}
+	}
+	if item.subdir {
+		if !option.inRange(item.name) {
+			return true
+		}
+		if n := len(*items); n > 0 && (*items)[n-1].subdir && (*items)[n-1].name == item.name {
+			return true
+		}
+	}
+	*items = append(*items, item)
+	return len(*items) < option.limit
+}
+
+// writeListing responds the names in plain text, or the details in json.
+func writeListing(w http.ResponseWriter, r *http.Request, items []*listItem, isContainers bool) {
+	format := r.URL.Query().Get("format")
+	if format == "" && strings.Contains(r.Header.Get("Accept"), "application/json") {
+		format = "json"
+	}
+
+	switch format {
+	case "json":
+		list := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			list = append(list, toJsonItem(item, isContainers))
+		}
+		writeJson(w, http.StatusOK, list)
+	case "", "plain":
+		if len(items) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		var sb strings.Builder
+		for _, item := range items {
+			sb.WriteString(item.name)
+			sb.WriteString("\n")
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Length", strconv.Itoa(sb.Len()))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(sb.String()))
+	default:
+		writeError(w, http.StatusNotAcceptable, "unsupported format "+format)
+	}
+}
+
+func toJsonItem(item *listItem, isContainers bool) interface{} {
+	if item.subdir {
+		return map[string]interface{}{"subdir": item.name}
+	}
+	entry := item.entry
+	lastModified := time.Unix(entry.Attributes.GetMtime(), 0).UTC().Format(lastModifiedFmt)
+	if isContainers {
+		return map[string]interface{}{
+			"name":          item.name,
+			"count":         0,
+			"bytes":         0,
+			"last_modified": lastModified,
+		}
+	}
+	return map[string]interface{}{
+		"name":          item.name,
+		"hash":          objectETag(entry),
+		"bytes":         objectSize(entry),
+		"content_type":  objectContentType(entry),
+		"last_modified": lastModified,
+	}
+}
+
+func objectContentType(entry *filer_pb.Entry) string {
+	if mime := entry.Attributes.GetMime(); mime != "" {
+		return mime
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,29 @@
+package swiftapi
+
+import (
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+)
+
+func TestListOptionAdd(t *testing.T) {
+	option := &listOption{prefix: "photos/", delimiter: "/", limit: 3}
+	var items []*listItem
+	for _, key := range []string{"photos/a.jpg", "photos/2020/x.jpg", "photos/2020/y.jpg", "photos/2021/z.jpg", "photos/b.jpg"} {
+		if !option.add(&items, key, &filer_pb.Entry{}) {
+			break
+		}
+	}
+	if len(items) != 3 {
+		t.Fatalf("expected 3 items, got %d", len(items))
+	}
+	expected := []struct {
+		name   string
+		subdir bool
+	}{{"photos/a.jpg", false}, {"photos/2020/", true}, {"photos/2021/", true}}
+	for i, e := range expected {
+		if items[i].name != e.name || items[i].subdir != e.subdir {
+			t.Errorf("item %d: got %s %v, expected %s %v", i, items[i].name, items[i].subdir, e.name, e.subdir)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package swiftapi
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	xhttp "github.com/chrislusf/seaweedfs/weed/s3api/http"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+	"github.com/chrislusf/seaweedfs/weed/util"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	objectMetaPrefix = "X-Object-Meta-"
+	maxObjectSize    = 5 * 1024 * 1024 * 1024
+)
+
+var (
+	client *http.Client
+
+	// the request headers passed on to the filer
+	proxiedRequestHeaders = []string{
+		"Content-Type", "Content-Encoding", "Content-Disposition",
+		"Range", "If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since",
+	}
+	// the filer response headers passed back
+	proxiedResponseHeaders = []string{
+		"Content-Type", "Content-Length", "Content-Range", "Content-Encoding", "Content-Disposition",
+		"Accept-Ranges", "Last-Modified",
+	}
+)
+
+func init() {
+	client = &http.Client{Transport: &http.Transport{
+		MaxIdleConns:        1024,
+		MaxIdleConnsPerHost: 1024,
+	}}
+}
+
+func (ss *SwiftServer) HeadObjectHandler(w http.ResponseWriter, r *http.Request) {
+	ss.GetObjectHandler(w, r)
+}
+
+func (ss *SwiftServer) GetObjectHandler(w http.ResponseWriter, r *http.Request) {
+	entry, ok := ss.getObject(w, r)
+	if !ok {
+		return
+	}
+	if isLargeObject(entry) && r.URL.Query().Get("multipart-manifest") != "get" {
+		ss.serveLargeObject(w, r, entry)
+		return
+	}
+
+	vars := mux.Vars(r)
+	proxyReq, err := http.NewRequest(r.Method, ss.filerUrl(vars["container"], vars["object"]), nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	copyRequestHeaders(proxyReq, r)
+
+	resp, err := client.Do(proxyReq)
+	if err != nil {
+		glog.Errorf("swift read %s: %v", r.URL.Path, err)
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	defer util.CloseResponse(resp)
+
+	for _, header := range proxiedResponseHeaders {
+		if value := resp.Header.Get(header); value != "" {
+			w.Header().Set(header, value)
+		}
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		w.Header().Set("ETag", strings.Trim(etag, "\""))
+	}
+	setObjectHeaders(w, entry)
+	w.WriteHeader(resp.StatusCode)
+	if r.Method != "HEAD" {
+		if _, err = io.Copy(w, resp.Body); err != nil {
+			glog.V(1).Infof("swift read %s: %v", r.URL.Path, err)
+		}
+	}
+}
+
+// PutObjectHandler uploads the object to the filer, or creates a large object manifest.
+func (ss *SwiftServer) PutObjectHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	container, object := vars["container"], vars["object"]
+
+	if _, ok := ss.getContainer(w, r); !ok {
+		return
+	}
+	if r.Header.Get("X-Copy-From") != "" {
+		writeError(w, http.StatusNotImplemented, "server side copy is not supported")
+		return
+	}
+	if r.ContentLength > maxObjectSize {
+		writeError(w, http.StatusRequestEntityTooLarge, "use the large objects for the objects over 5GB")
+		return
+	}
+	if r.URL.Query().Get("multipart-manifest") == "put" {
+		ss.putStaticLargeObject(w, r, container, object)
+		return
+	}
+	if manifest := r.Header.Get(dynamicManifestKey); manifest != "" {
+		ss.putDynamicLargeObject(w, r, container, object, manifest)
+		return
+	}
+
+	uploadUrl := ss.filerUrl(container, object)
+	if deleteAfter, err := objectTtl(r, time.Now()); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if deleteAfter != "" {
+		uploadUrl += "?ttl=" + deleteAfter
+	}
+
+	hash := md5.New()
+	proxyReq, err := http.NewRequest("PUT", uploadUrl, io.TeeReader(r.Body, hash))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	proxyReq.ContentLength = r.ContentLength
+	copyRequestHeaders(proxyReq, r)
+	for header, values := range r.Header {
+		if strings.HasPrefix(header, objectMetaPrefix) && len(values) > 0 {
+			proxyReq.Header.Set(xhttp.AmzUserMetaPrefix+header[len(objectMetaPrefix):], values[0])
+		}
+	}
+
+	resp, err := client.Do(proxyReq)
+	if err != nil {
+		glog.Errorf("swift write %s: %v", r.URL.Path, err)
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	util.CloseResponse(resp)
+	if resp.StatusCode >= 300 {
+		glog.Errorf("swift write %s: %d %s", r.URL.Path, resp.StatusCode, string(respBody))
+		writeError(w, resp.StatusCode, string(respBody))
+		return
+	}
+
+	etag := hex.EncodeToString(hash.Sum(nil))
+	if expected := strings.Trim(r.Header.Get("ETag"), "\""); expected != "" && !strings.EqualFold(expected, etag) {
+		if err = ss.deleteObject(container, object); err != nil {
+			glog.Errorf("delete %s with unmatched etag: %v", r.URL.Path, err)
+		}
+		writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("etag %s does not match %s", etag, expected))
+		return
+	}
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// PostObjectHandler replaces the object metadata.
+func (ss *SwiftServer) PostObjectHandler(w http.ResponseWriter, r *http.Request) {
+	entry, ok := ss.getObject(w, r)
+	if !ok {
+		return
+	}
+
+	for k := range entry.Extended {
+		if strings.HasPrefix(k, xhttp.AmzUserMetaPrefix) {
+			delete(entry.Extended, k)
+		}
+	}
+	if entry.Extended == nil {
+		entry.Extended = make(map[string][]byte)
+	}
+	for header, values := range r.Header {
+		if strings.HasPrefix(header, objectMetaPrefix) && len(values) > 0 && values[0] != "" {
+			entry.Extended[xhttp.AmzUserMetaPrefix+header[len(objectMetaPrefix):]] = []byte(values[0])
+		}
+	}
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		entry.Attributes.Mime = contentType
+	}
+
+	vars := mux.Vars(r)
+	dir, _ := ss.objectPath(vars["container"], vars["object"]).DirAndName()
+	err := ss.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+		return filer_pb.UpdateEntry(client, &filer_pb.UpdateEntryRequest{
+			Directory: dir,
+			Entry:     entry,
+		})
+	})
+	if err != nil {
+		glog.Errorf("update %s metadata: %v", r.URL.Path, err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// DeleteObjectHandler deletes the object, and with ?multipart-manifest=delete, the segments of the static large object.
+func (ss *SwiftServer) DeleteObjectHandler(w http.ResponseWriter, r *http.Request) {
+	entry, ok := ss.getObject(w, r)
+	if !ok {
+		return
+	}
+
+	if r.URL.Query().Get("multipart-manifest") == "delete" && isStaticLargeObject(entry) {
+		if err := ss.deleteSegments(entry); err != nil {
+			glog.Errorf("delete %s segments: %v", r.URL.Path, err)
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	vars := mux.Vars(r)
+	if err := ss.deleteObject(vars["container"], vars["object"]); err != nil {
+		glog.Errorf("delete %s: %v", r.URL.Path, err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (ss *SwiftServer) deleteObject(container, object string) error {
+	dir, name := ss.objectPath(container, object).DirAndName()
+	return filer_pb.Remove(ss, dir, name, true, false, false, false, nil)
+}
+
+// getObject responds 404 if the object is not found.
+func (ss *SwiftServer) getObject(w http.ResponseWriter, r *http.Request) (entry *filer_pb.Entry, ok bool) {
+	vars := mux.Vars(r)
+	entry, err := ss.getEntry(ss.objectPath(vars["container"], vars["object"]))
+	if err != nil {
+		glog.Errorf("read %s: %v", r.URL.Path, err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	if entry == nil || entry.IsDirectory {
+		writeError(w, http.StatusNotFound, "object "+vars["object"]+" not found")
+		return nil, false
+	}
+	return entry, true
+}
+
+func (ss *SwiftServer) filerUrl(container, object string) string {
+	u := url.URL{Scheme: "http", Host: ss.option.Filer, Path: string(ss.objectPath(container, object))}
+	return u.String()
+}
+
+func copyRequestHeaders(proxyReq *http.Request, r *http.Request) {
+	proxyReq.Header.Set("X-Forwarded-For", r.RemoteAddr)
+	for _, header := range proxiedRequestHeaders {
+		if value := r.Header.Get(header); value != "" {
+			proxyReq.Header.Set(header, value)
+		}
+	}
+	// e.g. the Seaweed-Replication
+	for header, values := range r.Header {
+		if strings.HasPrefix(header, "Seaweed-") && len(values) > 0 {
+			proxyReq.Header.Set(header, values[0])
+		}
+	}
+}
+
+// setObjectHeaders sets the object metadata headers, shared with the S3 user metadata.
+func setObjectHeaders(w http.ResponseWriter, entry *filer_pb.Entry) {
+	for k, v := range entry.Extended {
+		if strings.HasPrefix(k, xhttp.AmzUserMetaPrefix) {
+			w.Header().Set(objectMetaPrefix+k[len(xhttp.AmzUserMetaPrefix):], string(v))
+		}
+	}
+	w.Header().Set("X-Timestamp", strconv.FormatInt(entry.Attributes.GetMtime(), 10))
+	if manifest := entry.Extended[dynamicManifestKey]; len(manifest) > 0 {
+		w.Header().Set(dynamicManifestKey, string(manifest))
+	}
+	if isStaticLargeObject(entry) {
+		w.Header().Set(staticLargeObjectKey, "True")
+	}
+}
+
+// objectTtl converts the X-Delete-After or X-Delete-At headers to the filer ttl, in the volume ttl units.
+// Each different ttl uses its own volumes.
+func objectTtl(r *http.Request, now time.Time) (string, error) {
+	var seconds int64
+	if deleteAfter := r.Header.Get("X-Delete-After"); deleteAfter != "" {
+		n, err := strconv.ParseInt(deleteAfter, 10, 64)
+		if err != nil || n <= 0 {
+			return "", fmt.Errorf("invalid X-Delete-After %s", deleteAfter)
+		}
+		seconds = n
+	} else if deleteAt := r.Header.Get("X-Delete-At"); deleteAt != "" {
+		n, err := strconv.ParseInt(deleteAt, 10, 64)
+		if err != nil || n <= now.Unix() {
+			return "", fmt.Errorf("invalid X-Delete-At %s", deleteAt)
+		}
+		seconds = n - now.Unix()
+	} else {
+		return "", nil
+	}
+	// round up to minutes
+	seconds = (seconds + 59) / 60 * 60
+	if seconds > math.MaxInt32 {
+		return "", fmt.Errorf("delete after %ds is too long", seconds)
+	}
+	return needle.SecondsToTTL(int32(seconds)), nil
+}
+
+func objectETag(entry *filer_pb.Entry) string {
+	if etag := entry.Extended[sloEtagKey]; len(etag) > 0 {
+		return string(etag)
+	}
+	return filer.ETag(entry)
+}
+
+func objectSize(entry *filer_pb.Entry) int64 {
+	if size := entry.Extended[sloSizeKey]; len(size) > 0 {
+		n, _ := strconv.ParseInt(string(size), 10, 64)
+		return n
+	}
+	return int64(filer.FileSize(entry))
+}
@@ -0,0 +1,41 @@
+package swiftapi
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestObjectTtl(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	tests := []struct {
+		header, value string
+		ttl           string
+		invalid       bool
+	}{
+		{"", "", "", false},
+		{"X-Delete-After", "30", "1m", false},
+		{"X-Delete-After", "3600", "1h", false},
+		{"X-Delete-After", "86400", "1d", false},
+		{"X-Delete-At", "1600000120", "2m", false},
+		{"X-Delete-After", "-1", "", true},
+		{"X-Delete-After", "abc", "", true},
+		{"X-Delete-At", "1500000000", "", true},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest("PUT", "/v1/AUTH_test/container/object", nil)
+		if tt.header != "" {
+			r.Header.Set(tt.header, tt.value)
+		}
+		ttl, err := objectTtl(r, now)
+		if tt.invalid {
+			if err == nil {
+				t.Errorf("%s: %s should be invalid", tt.header, tt.value)
+			}
+			continue
+		}
+		if err != nil || ttl != tt.ttl {
+			t.Errorf("%s: %s got %s %v, expected %s", tt.header, tt.value, ttl, err, tt.ttl)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package swiftapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func writeError(w http.ResponseWriter, httpStatus int, message string) {
+	body := []byte(http.StatusText(httpStatus) + ": " + message + "\n")
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(httpStatus)
+	if _, err := w.Write(body); err != nil {
+		glog.V(1).Infof("write error response: %v", err)
+	}
+}
+
+func writeJson(w http.ResponseWriter, httpStatus int, obj interface{}) {
+	body, err := json.Marshal(obj)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(httpStatus)
+	if _, err = w.Write(body); err != nil {
+		glog.V(1).Infof("write json response: %v", err)
+	}
+}
+
+func (ss *SwiftServer) accountDir() util.FullPath {
+	return util.FullPath(ss.option.BucketsPath)
+}
+
+func (ss *SwiftServer) containerDir(container string) util.FullPath {
+	return util.FullPath(ss.option.BucketsPath).Child(container)
+}
+
+func (ss *SwiftServer) objectPath(container, object string) util.FullPath {
+	return util.FullPath(ss.option.BucketsPath + "/" + container + "/" + object)
+}
+
+// getEntry returns nil if the entry is not found.
+func (ss *SwiftServer) getEntry(fullpath util.FullPath) (*filer_pb.Entry, error) {
+	return filer_pb.GetEntry(ss, fullpath)
+}
+
+// setMetadataHeaders sets the headers of the metadata with the prefix, e.g. X-Container-Meta-, except the hidden ones.
+func setMetadataHeaders(w http.ResponseWriter, extended map[string][]byte, prefix string, hidden ...string) {
+	for k, v := range extended {
+		if strings.HasPrefix(k, prefix) && !isHiddenHeader(k, hidden) {
+			w.Header().Set(k, string(v))
+		}
+	}
+}
+
+func isHiddenHeader(header string, hidden []string) bool {
+	for _, h := range hidden {
+		if header == h {
+			return true
+		}
+	}
+	return false
+}
+
+// updateMetadata applies the metadata headers of a POST or PUT request, with the prefix, e.g. X-Container-Meta-.
+// The empty values, and the X-Remove- prefixed headers, remove the metadata.
+func updateMetadata(r *http.Request, extended map[string][]byte, prefix string) (changed bool) {
+	removePrefix := "X-Remove-" + strings.TrimPrefix(prefix, "X-")
+	for header, values := range r.Header {
+		switch {
+		case strings.HasPrefix(header, prefix):
+			if len(values) == 0 || values[0] == "" {
+				delete(extended, header)
+			} else {
+				extended[header] = []byte(values[0])
+			}
+			changed = true
+		case strings.HasPrefix(header, removePrefix):
+			delete(extended, prefix+header[len(removePrefix):])
+			changed = true
+		}
+	}
+	return
+}
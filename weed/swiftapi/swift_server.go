@@ -0,0 +1,123 @@
+package swiftapi
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/s3api"
+	. "github.com/chrislusf/seaweedfs/weed/s3api/s3_constants"
+	"github.com/chrislusf/seaweedfs/weed/util"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc"
+)
+
+/*
+The swift gateway serves the OpenStack Swift object storage API on top of the filer,
+for the users migrating off Swift clusters whose tooling can not switch to S3.
+
+The containers are the same buckets as the S3 gateway, the folders under the filer buckets folder,
+so the objects written with one API can be read with the other. All accounts share the same containers.
+
+The users are the S3 identities: the X-Auth-User is an access key, and the X-Auth-Key its secret key,
+with the same actions allowed as in S3. Without identities, all requests are allowed.
+
+Supported:
+	auth         tempauth style GET /auth/v1.0, with the tokens signed by the secret keys
+	account      HEAD, GET to list the containers, POST to set the account metadata
+	container    HEAD, GET to list the objects, PUT, POST to set the container metadata, DELETE if empty
+	object       HEAD, GET with ranges, PUT, POST to set the object metadata, DELETE
+	temp urls    with the X-Account-Meta-Temp-URL-Key(-2) keys, for GET, HEAD and PUT
+	large object the dynamic X-Object-Manifest, and the static ?multipart-manifest=put manifests
+*/
+
+type SwiftServerOption struct {
+	Filer            string
+	Port             int
+	FilerGrpcAddress string
+	Config           string
+	BucketsPath      string
+	GrpcDialOption   grpc.DialOption
+	TokenTtl         time.Duration
+}
+
+type SwiftServer struct {
+	option *SwiftServerOption
+	iam    *s3api.IdentityAccessManagement
+}
+
+func NewSwiftServer(router *mux.Router, option *SwiftServerOption) (swiftServer *SwiftServer, err error) {
+	swiftServer = &SwiftServer{
+		option: option,
+		iam: s3api.NewIdentityAccessManagement(&s3api.S3ApiServerOption{
+			Filer:  option.Filer,
+			Config: option.Config,
+		}),
+	}
+
+	swiftServer.registerRouter(router)
+
+	return swiftServer, nil
+}
+
+func (ss *SwiftServer) registerRouter(router *mux.Router) {
+
+	router.Methods("GET").Path("/auth/v1.0").HandlerFunc(track(ss.AuthHandler))
+	router.Methods("GET").Path("/info").HandlerFunc(track(ss.InfoHandler))
+
+	account := router.PathPrefix("/v1/{account}").Subrouter()
+
+	// objects
+	account.Methods("HEAD").Path("/{container}/{object:.+}").HandlerFunc(track(ss.auth(ss.HeadObjectHandler, ACTION_READ)))
+	account.Methods("GET").Path("/{container}/{object:.+}").HandlerFunc(track(ss.auth(ss.GetObjectHandler, ACTION_READ)))
+	account.Methods("PUT").Path("/{container}/{object:.+}").HandlerFunc(track(ss.auth(ss.PutObjectHandler, ACTION_WRITE)))
+	account.Methods("POST").Path("/{container}/{object:.+}").HandlerFunc(track(ss.auth(ss.PostObjectHandler, ACTION_WRITE)))
+	account.Methods("DELETE").Path("/{container}/{object:.+}").HandlerFunc(track(ss.auth(ss.DeleteObjectHandler, ACTION_WRITE)))
+
+	// containers, with or without the trailing slash
+	for _, containerPath := range []string{"/{container}", "/{container}/"} {
+		account.Methods("HEAD").Path(containerPath).HandlerFunc(track(ss.auth(ss.HeadContainerHandler, ACTION_LIST)))
+		account.Methods("GET").Path(containerPath).HandlerFunc(track(ss.auth(ss.ListObjectsHandler, ACTION_LIST)))
+		account.Methods("PUT").Path(containerPath).HandlerFunc(track(ss.auth(ss.PutContainerHandler, ACTION_ADMIN)))
+		account.Methods("POST").Path(containerPath).HandlerFunc(track(ss.auth(ss.PostContainerHandler, ACTION_ADMIN)))
+		account.Methods("DELETE").Path(containerPath).HandlerFunc(track(ss.auth(ss.DeleteContainerHandler, ACTION_ADMIN)))
+	}
+
+	// account
+	account.Methods("HEAD").HandlerFunc(track(ss.auth(ss.HeadAccountHandler, ACTION_LIST)))
+	account.Methods("GET").HandlerFunc(track(ss.auth(ss.ListContainersHandler, ACTION_LIST)))
+	account.Methods("POST").HandlerFunc(track(ss.auth(ss.PostAccountHandler, ACTION_ADMIN)))
+
+	router.NotFoundHandler = http.HandlerFunc(notFoundHandler)
+	router.MethodNotAllowedHandler = http.HandlerFunc(notFoundHandler)
+}
+
+func (ss *SwiftServer) WithFilerClient(fn func(filer_pb.SeaweedFilerClient) error) error {
+
+	return pb.WithCachedGrpcClient(func(grpcConnection *grpc.ClientConn) error {
+		client := filer_pb.NewSeaweedFilerClient(grpcConnection)
+		return fn(client)
+	}, ss.option.FilerGrpcAddress, ss.option.GrpcDialOption)
+
+}
+
+func (ss *SwiftServer) AdjustedUrl(location *filer_pb.Location) string {
+	return location.Url
+}
+
+func track(f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "SeaweedFS Swift "+util.VERSION)
+		w.Header().Set("X-Trans-Id", fmt.Sprintf("tx%x", util.RandomBytes(8)))
+		f(w, r)
+	}
+}
+
+func notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	glog.V(0).Infof("unsupported %s %s", r.Method, r.RequestURI)
+	writeError(w, http.StatusMethodNotAllowed, "unsupported "+r.Method+" "+r.URL.Path)
+}
@@ -29,3 +29,50 @@ func (c *MaxVolumeIdCommand) Apply(server raft.Server) (interface{}, error) {
 
 	return nil, nil
 }
+
+// CollectionDefaultsCommand sets or deletes the defaults of a collection
+type CollectionDefaultsCommand struct {
+	Defaults *CollectionDefaults `json:"defaults"`
+	Delete   bool                `json:"delete,omitempty"`
+}
+
+func (c *CollectionDefaultsCommand) CommandName() string {
+	return "CollectionDefaults"
+}
+
+func (c *CollectionDefaultsCommand) Apply(server raft.Server) (interface{}, error) {
+	topo := server.Context().(*Topology)
+	topo.applyCollectionDefaults(c)
+	return nil, nil
+}
+
+// MaintenanceCommand puts a scope into maintenance, or takes it out of maintenance
+type MaintenanceCommand struct {
+	Scope *MaintenanceScope `json:"scope"`
+	Exit  bool              `json:"exit,omitempty"`
+}
+
+func (c *MaintenanceCommand) CommandName() string {
+	return "Maintenance"
+}
+
+func (c *MaintenanceCommand) Apply(server raft.Server) (interface{}, error) {
+	topo := server.Context().(*Topology)
+	topo.applyMaintenance(c)
+	return nil, nil
+}
+
+// ClusterReadOnlyCommand freezes or unfreezes the writes to the cluster
+type ClusterReadOnlyCommand struct {
+	ReadOnly ClusterReadOnly `json:"readOnly"`
+}
+
+func (c *ClusterReadOnlyCommand) CommandName() string {
+	return "ClusterReadOnly"
+}
+
+func (c *ClusterReadOnlyCommand) Apply(server raft.Server) (interface{}, error) {
+	topo := server.Context().(*Topology)
+	topo.applyClusterReadOnly(c.ReadOnly)
+	return nil, nil
+}
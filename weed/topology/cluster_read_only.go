@@ -0,0 +1,79 @@
+package topology
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+)
+
+/*
+The cluster can be switched to read only, e.g. during a migration or when the free space is critically low.
+The master then rejects all file id assignments and volume growths, and the filers poll the master
+and reject all writes, so the writes from S3 and mount also fail with ErrClusterReadOnly.
+The switch is replicated to all masters with raft, and kept in the raft snapshots.
+*/
+
+var ErrClusterReadOnly = errors.New("cluster is read only")
+
+type ClusterReadOnly struct {
+	ReadOnly bool      `json:"readOnly"`
+	Reason   string    `json:"reason,omitempty"`
+	Since    time.Time `json:"since,omitempty"`
+}
+
+func (r ClusterReadOnly) Err() error {
+	if !r.ReadOnly {
+		return nil
+	}
+	if r.Reason == "" {
+		return ErrClusterReadOnly
+	}
+	return fmt.Errorf("%v: %s", ErrClusterReadOnly, r.Reason)
+}
+
+func (t *Topology) GetClusterReadOnly() ClusterReadOnly {
+	t.readOnlyLock.RLock()
+	defer t.readOnlyLock.RUnlock()
+	return t.readOnly
+}
+
+// CheckClusterWritable returns ErrClusterReadOnly with the reason if the cluster is read only
+func (t *Topology) CheckClusterWritable() error {
+	return t.GetClusterReadOnly().Err()
+}
+
+// SetClusterReadOnly switches the cluster to read only or back to writable on all masters.
+func (t *Topology) SetClusterReadOnly(readOnly bool, reason string) (ClusterReadOnly, error) {
+	state := ClusterReadOnly{}
+	if readOnly {
+		state = ClusterReadOnly{ReadOnly: true, Reason: reason, Since: time.Now()}
+	}
+	if t.RaftServer == nil {
+		t.applyClusterReadOnly(state)
+		return state, nil
+	}
+	if _, err := t.RaftServer.Do(&ClusterReadOnlyCommand{ReadOnly: state}); err != nil {
+		return state, fmt.Errorf("set cluster read only %v: %v", readOnly, err)
+	}
+	return state, nil
+}
+
+func (t *Topology) applyClusterReadOnly(state ClusterReadOnly) {
+	t.readOnlyLock.Lock()
+	t.readOnly = state
+	t.readOnlyLock.Unlock()
+	if state.ReadOnly {
+		glog.V(0).Infof("cluster is read only since %v: %s", state.Since, state.Reason)
+	} else {
+		glog.V(0).Infof("cluster is writable")
+	}
+}
+
+// RecoverClusterReadOnly restores the switch from a raft snapshot
+func (t *Topology) RecoverClusterReadOnly(state ClusterReadOnly) {
+	t.readOnlyLock.Lock()
+	defer t.readOnlyLock.Unlock()
+	t.readOnly = state
+}
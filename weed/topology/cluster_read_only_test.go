@@ -0,0 +1,37 @@
+package topology
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClusterReadOnly(t *testing.T) {
+	topo, _, _ := setupMaintenance(t)
+
+	if err := topo.CheckClusterWritable(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	state, err := topo.SetClusterReadOnly(true, "migrating")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.ReadOnly || state.Since.IsZero() {
+		t.Errorf("unexpected state %+v", state)
+	}
+	if err = topo.CheckClusterWritable(); err == nil || err.Error() != "cluster is read only: migrating" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	vg := NewDefaultVolumeGrowth()
+	count, err := vg.GrowByCountAndType(nil, 1, &VolumeGrowOption{}, topo)
+	if count != 0 || err == nil || !strings.Contains(err.Error(), ErrClusterReadOnly.Error()) {
+		t.Errorf("expecting no volume growth when read only, but got %d volumes: %v", count, err)
+	}
+
+	if _, err = topo.SetClusterReadOnly(false, ""); err != nil {
+		t.Fatal(err)
+	}
+	if topo.GetClusterReadOnly().ReadOnly || topo.CheckClusterWritable() != nil {
+		t.Errorf("the cluster should be writable")
+	}
+}
@@ -0,0 +1,142 @@
+package topology
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/storage/erasure_coding"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+	"github.com/chrislusf/seaweedfs/weed/storage/super_block"
+)
+
+// CollectionDefaults are the settings of a collection registered at the master,
+// used when the assign and grow requests leave them empty. A zero MaxVolumeCount is unlimited.
+// The defaults are replicated to all masters with raft, and kept in the raft snapshots.
+type CollectionDefaults struct {
+	Collection     string `json:"collection"`
+	Replication    string `json:"replication,omitempty"`
+	Ttl            string `json:"ttl,omitempty"`
+	DiskType       string `json:"diskType,omitempty"`
+	EcLayout       string `json:"ecLayout,omitempty"`
+	MaxVolumeCount int    `json:"maxVolumeCount,omitempty"`
+}
+
+func (d *CollectionDefaults) Validate() error {
+	if d.Replication != "" {
+		if _, err := super_block.NewReplicaPlacementFromString(d.Replication); err != nil {
+			return fmt.Errorf("collection %s replication %s: %v", d.Collection, d.Replication, err)
+		}
+	}
+	if _, err := needle.ReadTTL(d.Ttl); err != nil {
+		return fmt.Errorf("collection %s ttl %s: %v", d.Collection, d.Ttl, err)
+	}
+	if _, err := erasure_coding.ParseEcLayout(d.EcLayout); err != nil {
+		return fmt.Errorf("collection %s ec layout %s: %v", d.Collection, d.EcLayout, err)
+	}
+	if d.MaxVolumeCount < 0 {
+		return fmt.Errorf("collection %s max volume count %d is negative", d.Collection, d.MaxVolumeCount)
+	}
+	return nil
+}
+
+// GetCollectionDefaults returns a copy of the defaults of the collection, empty if not registered.
+func (t *Topology) GetCollectionDefaults(collection string) (defaults CollectionDefaults, found bool) {
+	t.collectionDefaultsLock.RLock()
+	defer t.collectionDefaultsLock.RUnlock()
+	if d, ok := t.collectionDefaults[collection]; ok {
+		return *d, true
+	}
+	return CollectionDefaults{Collection: collection}, false
+}
+
+// ListCollectionDefaults returns the defaults of all collections, sorted by the collection name.
+func (t *Topology) ListCollectionDefaults() (list []*CollectionDefaults) {
+	t.collectionDefaultsLock.RLock()
+	for _, d := range t.collectionDefaults {
+		copied := *d
+		list = append(list, &copied)
+	}
+	t.collectionDefaultsLock.RUnlock()
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Collection < list[j].Collection
+	})
+	return
+}
+
+// SetCollectionDefaults replaces the defaults of the collection on all masters.
+func (t *Topology) SetCollectionDefaults(defaults *CollectionDefaults) error {
+	if err := defaults.Validate(); err != nil {
+		return err
+	}
+	return t.doCollectionDefaultsCommand(&CollectionDefaultsCommand{Defaults: defaults})
+}
+
+// DeleteCollectionDefaults removes the defaults of the collection on all masters.
+func (t *Topology) DeleteCollectionDefaults(collection string) error {
+	return t.doCollectionDefaultsCommand(&CollectionDefaultsCommand{Defaults: &CollectionDefaults{Collection: collection}, Delete: true})
+}
+
+func (t *Topology) doCollectionDefaultsCommand(c *CollectionDefaultsCommand) error {
+	if t.RaftServer == nil {
+		t.applyCollectionDefaults(c)
+		return nil
+	}
+	if _, err := t.RaftServer.Do(c); err != nil {
+		return fmt.Errorf("collection %s defaults: %v", c.Defaults.Collection, err)
+	}
+	return nil
+}
+
+func (t *Topology) applyCollectionDefaults(c *CollectionDefaultsCommand) {
+	t.collectionDefaultsLock.Lock()
+	defer t.collectionDefaultsLock.Unlock()
+	if c.Delete {
+		delete(t.collectionDefaults, c.Defaults.Collection)
+		glog.V(0).Infof("collection %s defaults removed", c.Defaults.Collection)
+		return
+	}
+	copied := *c.Defaults
+	t.collectionDefaults[copied.Collection] = &copied
+	glog.V(0).Infof("collection %s defaults: %+v", copied.Collection, copied)
+}
+
+// RecoverCollectionDefaults replaces the defaults of all collections, from a raft snapshot.
+func (t *Topology) RecoverCollectionDefaults(list []*CollectionDefaults) {
+	t.collectionDefaultsLock.Lock()
+	defer t.collectionDefaultsLock.Unlock()
+	t.collectionDefaults = make(map[string]*CollectionDefaults)
+	for _, d := range list {
+		copied := *d
+		t.collectionDefaults[copied.Collection] = &copied
+	}
+}
+
+// checkCollectionVolumeLimit fails if the collection already has the max volume count of its defaults
+func (t *Topology) checkCollectionVolumeLimit(collection string) error {
+	defaults, found := t.GetCollectionDefaults(collection)
+	if !found || defaults.MaxVolumeCount == 0 {
+		return nil
+	}
+	count := 0
+	if c, found := t.FindCollection(collection); found {
+		count = c.volumeCount()
+	}
+	if count >= defaults.MaxVolumeCount {
+		return fmt.Errorf("collection %s has %d volumes, reaching the max volume count %d", collection, count, defaults.MaxVolumeCount)
+	}
+	return nil
+}
+
+func (c *Collection) volumeCount() (count int) {
+	for _, layout := range c.storageType2VolumeLayout.Items() {
+		if layout == nil {
+			continue
+		}
+		vl := layout.(*VolumeLayout)
+		vl.accessLock.RLock()
+		count += len(vl.vid2location)
+		vl.accessLock.RUnlock()
+	}
+	return
+}
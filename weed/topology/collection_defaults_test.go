@@ -0,0 +1,76 @@
+package topology
+
+import (
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/sequence"
+)
+
+func TestCollectionDefaults(t *testing.T) {
+	topo := NewTopology("weedfs", sequence.NewMemorySequencer(), 32*1024, 5, false)
+
+	if defaults, found := topo.GetCollectionDefaults("logs"); found || defaults.Collection != "logs" {
+		t.Errorf("unexpected defaults %+v", defaults)
+	}
+
+	for _, invalid := range []*CollectionDefaults{
+		{Collection: "logs", Replication: "030"},
+		{Collection: "logs", Ttl: "xd"},
+		{Collection: "logs", EcLayout: "10"},
+		{Collection: "logs", MaxVolumeCount: -1},
+	} {
+		if err := topo.SetCollectionDefaults(invalid); err == nil {
+			t.Errorf("expecting error for %+v", invalid)
+		}
+	}
+
+	if err := topo.SetCollectionDefaults(&CollectionDefaults{Collection: "logs", Replication: "010", Ttl: "7d", EcLayout: "6+3"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := topo.SetCollectionDefaults(&CollectionDefaults{Collection: "", DiskType: "ssd"}); err != nil {
+		t.Fatal(err)
+	}
+	defaults, found := topo.GetCollectionDefaults("logs")
+	if !found || defaults.Replication != "010" || defaults.Ttl != "7d" || defaults.EcLayout != "6+3" {
+		t.Errorf("unexpected defaults %+v", defaults)
+	}
+	list := topo.ListCollectionDefaults()
+	if len(list) != 2 || list[0].Collection != "" || list[1].Collection != "logs" {
+		t.Errorf("unexpected list %+v", list)
+	}
+
+	if err := topo.DeleteCollectionDefaults("logs"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found = topo.GetCollectionDefaults("logs"); found {
+		t.Errorf("the defaults of logs should be removed")
+	}
+
+	topo.RecoverCollectionDefaults([]*CollectionDefaults{{Collection: "photos", MaxVolumeCount: 3}})
+	if list = topo.ListCollectionDefaults(); len(list) != 1 || list[0].Collection != "photos" {
+		t.Errorf("unexpected recovered list %+v", list)
+	}
+}
+
+func TestCollectionVolumeLimit(t *testing.T) {
+	topo, _, _ := setupMaintenance(t)
+
+	if err := topo.checkCollectionVolumeLimit(""); err != nil {
+		t.Errorf("unexpected error without the defaults: %v", err)
+	}
+	if err := topo.SetCollectionDefaults(&CollectionDefaults{Collection: "", MaxVolumeCount: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if err := topo.checkCollectionVolumeLimit(""); err != nil {
+		t.Errorf("3 volumes should be within the max volume count 4: %v", err)
+	}
+	if err := topo.SetCollectionDefaults(&CollectionDefaults{Collection: "", MaxVolumeCount: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := topo.checkCollectionVolumeLimit(""); err == nil {
+		t.Errorf("expecting error reaching the max volume count 3")
+	}
+	if err := topo.checkCollectionVolumeLimit("photos"); err != nil {
+		t.Errorf("unexpected error for another collection: %v", err)
+	}
+}
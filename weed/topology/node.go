@@ -137,6 +137,20 @@ func (n *NodeImpl) getOrCreateDisk(diskType types.DiskType) *DiskUsageCounts {
 	return n.diskUsages.getOrCreateDisk(diskType)
 }
 func (n *NodeImpl) AvailableSpaceFor(option *VolumeGrowOption) int64 {
+	inMaintenance, maintenanceBelow := n.maintenanceState()
+	if inMaintenance {
+		return 0
+	}
+	if maintenanceBelow {
+		// the free slots of the children in maintenance are not available
+		var freeVolumeSlotCount int64
+		for _, c := range n.Children() {
+			if free := c.AvailableSpaceFor(option); free > 0 {
+				freeVolumeSlotCount += free
+			}
+		}
+		return freeVolumeSlotCount
+	}
 	t := n.getOrCreateDisk(option.DiskType)
 	freeVolumeSlotCount := t.maxVolumeCount + t.remoteVolumeCount - t.volumeCount
 	if t.ecShardCount > 0 {
@@ -0,0 +1,348 @@
+package topology
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/storage/super_block"
+)
+
+/*
+The placement strategies pick the data nodes for the copies of a new volume. The number of copies is
+always from the replica placement of the volume, e.g. 3 copies for "002" or "110".
+
+With the default "replication" strategy, the replica placement also tells where the copies are,
+e.g. "110" for one copy on another data center and one copy on another rack. The other strategies only
+apply to the replica placements their copies satisfy, since "volume.fix.replication" and "volume.balance"
+keep the copies where the replica placement tells, and the other replica placements use the "replication" strategy:
+
+	spread_by_rack                           "0x0", each copy on a different rack of one data center
+	spread_by_dc                             "x00", each copy on a different data center
+	pin_to_dc_with_remote_copy:<data center> "1x0", one copy on another data center, and the other copies
+	                                         on different racks of the pinned data center
+
+The strategy is selected per collection in master.toml:
+
+	[master.placement]
+	default = "replication"
+	collections = [
+	  "logs=spread_by_rack",
+	  "important=pin_to_dc_with_remote_copy:dc1",
+	]
+*/
+
+const DefaultPlacementStrategy = "replication"
+
+// PlacementStrategy picks the distinct data nodes for all copies of one volume, each with a free slot
+// of the disk type. The preferred data center, rack or data node in the option is the first copy.
+type PlacementStrategy interface {
+	// Satisfies checks the copies placed by the strategy satisfy the replica placement
+	Satisfies(rp *super_block.ReplicaPlacement) bool
+	PickNodes(topo *Topology, option *VolumeGrowOption) ([]*DataNode, error)
+}
+
+// NewPlacementStrategyFunc creates a placement strategy, with the argument after ":" in the configuration
+type NewPlacementStrategyFunc func(arg string) (PlacementStrategy, error)
+
+var placementStrategies = map[string]NewPlacementStrategyFunc{
+	"replication": func(arg string) (PlacementStrategy, error) {
+		return &replicationPlacement{}, nil
+	},
+	"spread_by_rack": func(arg string) (PlacementStrategy, error) {
+		return &spreadByRackPlacement{}, nil
+	},
+	"spread_by_dc": func(arg string) (PlacementStrategy, error) {
+		return &spreadByDataCenterPlacement{}, nil
+	},
+	"pin_to_dc_with_remote_copy": func(arg string) (PlacementStrategy, error) {
+		if arg == "" {
+			return nil, fmt.Errorf("missing the data center, e.g. pin_to_dc_with_remote_copy:dc1")
+		}
+		return &pinToDataCenterPlacement{dataCenter: NodeId(arg)}, nil
+	},
+}
+
+// RegisterPlacementStrategy adds a custom placement strategy, before the master starts
+func RegisterPlacementStrategy(name string, fn NewPlacementStrategyFunc) {
+	placementStrategies[name] = fn
+}
+
+// NewPlacementStrategy creates the placement strategy by the configuration "name" or "name:arg"
+func NewPlacementStrategy(spec string) (PlacementStrategy, error) {
+	name, arg := spec, ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		name, arg = spec[:i], spec[i+1:]
+	}
+	fn, found := placementStrategies[name]
+	if !found {
+		return nil, fmt.Errorf("unknown placement strategy %s", name)
+	}
+	return fn(arg)
+}
+
+// replicationPlacement places the copies by the replica placement, on the other data centers, racks and data nodes
+type replicationPlacement struct {
+}
+
+func (p *replicationPlacement) Satisfies(rp *super_block.ReplicaPlacement) bool {
+	return true
+}
+
+// 1. find the main data node
+// 1.1 collect all data nodes that have 1 slots
+// 2.2 collect all racks that have rp.SameRackCount+1
+// 2.2 collect all data centers that have DiffRackCount+rp.SameRackCount+1
+// 2. find rest data nodes
+func (p *replicationPlacement) PickNodes(topo *Topology, option *VolumeGrowOption) (servers []*DataNode, err error) {
+	//find main datacenter and other data centers
+	rp := option.ReplicaPlacement
+	mainDataCenter, otherDataCenters, dc_err := topo.PickNodesByWeight(rp.DiffDataCenterCount+1, option, func(node Node) error {
+		if option.DataCenter != "" && node.IsDataCenter() && node.Id() != NodeId(option.DataCenter) {
+			return fmt.Errorf("Not matching preferred data center:%s", option.DataCenter)
+		}
+		if len(node.Children()) < rp.DiffRackCount+1 {
+			return fmt.Errorf("Only has %d racks, not enough for %d.", len(node.Children()), rp.DiffRackCount+1)
+		}
+		if node.AvailableSpaceFor(option) < int64(rp.DiffRackCount+rp.SameRackCount+1) {
+			return fmt.Errorf("Free:%d < Expected:%d", node.AvailableSpaceFor(option), rp.DiffRackCount+rp.SameRackCount+1)
+		}
+		possibleRacksCount := 0
+		for _, rack := range node.Children() {
+			possibleDataNodesCount := 0
+			for _, n := range rack.Children() {
+				if n.AvailableSpaceFor(option) >= 1 {
+					possibleDataNodesCount++
+				}
+			}
+			if possibleDataNodesCount >= rp.SameRackCount+1 {
+				possibleRacksCount++
+			}
+		}
+		if possibleRacksCount < rp.DiffRackCount+1 {
+			return fmt.Errorf("Only has %d racks with more than %d free data nodes, not enough for %d.", possibleRacksCount, rp.SameRackCount+1, rp.DiffRackCount+1)
+		}
+		return nil
+	})
+	if dc_err != nil {
+		return nil, dc_err
+	}
+
+	//find main rack and other racks
+	mainRack, otherRacks, rackErr := mainDataCenter.(*DataCenter).PickNodesByWeight(rp.DiffRackCount+1, option, func(node Node) error {
+		if option.Rack != "" && node.IsRack() && node.Id() != NodeId(option.Rack) {
+			return fmt.Errorf("Not matching preferred rack:%s", option.Rack)
+		}
+		if node.AvailableSpaceFor(option) < int64(rp.SameRackCount+1) {
+			return fmt.Errorf("Free:%d < Expected:%d", node.AvailableSpaceFor(option), rp.SameRackCount+1)
+		}
+		if len(node.Children()) < rp.SameRackCount+1 {
+			// a bit faster way to test free racks
+			return fmt.Errorf("Only has %d data nodes, not enough for %d.", len(node.Children()), rp.SameRackCount+1)
+		}
+		possibleDataNodesCount := 0
+		for _, n := range node.Children() {
+			if n.AvailableSpaceFor(option) >= 1 {
+				possibleDataNodesCount++
+			}
+		}
+		if possibleDataNodesCount < rp.SameRackCount+1 {
+			return fmt.Errorf("Only has %d data nodes with a slot, not enough for %d.", possibleDataNodesCount, rp.SameRackCount+1)
+		}
+		return nil
+	})
+	if rackErr != nil {
+		return nil, rackErr
+	}
+
+	//find main rack and other racks
+	mainServer, otherServers, serverErr := mainRack.(*Rack).PickNodesByWeight(rp.SameRackCount+1, option, func(node Node) error {
+		if option.DataNode != "" && node.IsDataNode() && node.Id() != NodeId(option.DataNode) {
+			return fmt.Errorf("Not matching preferred data node:%s", option.DataNode)
+		}
+		if node.AvailableSpaceFor(option) < 1 {
+			return fmt.Errorf("Free:%d < Expected:%d", node.AvailableSpaceFor(option), 1)
+		}
+		return nil
+	})
+	if serverErr != nil {
+		return nil, serverErr
+	}
+
+	servers = append(servers, mainServer.(*DataNode))
+	for _, server := range otherServers {
+		servers = append(servers, server.(*DataNode))
+	}
+	for _, rack := range otherRacks {
+		r := rand.Int63n(rack.AvailableSpaceFor(option))
+		if server, e := rack.ReserveOneVolume(r, option); e == nil {
+			servers = append(servers, server)
+		} else {
+			return servers, e
+		}
+	}
+	for _, datacenter := range otherDataCenters {
+		r := rand.Int63n(datacenter.AvailableSpaceFor(option))
+		if server, e := datacenter.ReserveOneVolume(r, option); e == nil {
+			servers = append(servers, server)
+		} else {
+			return servers, e
+		}
+	}
+	return
+}
+
+// spreadByRackPlacement places each copy on a different rack of one data center
+type spreadByRackPlacement struct {
+}
+
+func (p *spreadByRackPlacement) Satisfies(rp *super_block.ReplicaPlacement) bool {
+	return rp.DiffDataCenterCount == 0 && rp.SameRackCount == 0
+}
+
+func (p *spreadByRackPlacement) PickNodes(topo *Topology, option *VolumeGrowOption) ([]*DataNode, error) {
+	err := fmt.Errorf("no data center with a free slot")
+	for _, dc := range sortByWeight(topo.Children(), option) {
+		if option.DataCenter != "" && dc.Id() != NodeId(option.DataCenter) {
+			continue
+		}
+		servers, pickErr := pickOneDataNodeEach(dc.Children(), option.ReplicaPlacement.GetCopyCount(), option)
+		if pickErr == nil {
+			return servers, nil
+		}
+		err = fmt.Errorf("data center %s: %v", dc.Id(), pickErr)
+	}
+	return nil, err
+}
+
+// spreadByDataCenterPlacement places each copy on a different data center
+type spreadByDataCenterPlacement struct {
+}
+
+func (p *spreadByDataCenterPlacement) Satisfies(rp *super_block.ReplicaPlacement) bool {
+	return rp.DiffRackCount == 0 && rp.SameRackCount == 0
+}
+
+func (p *spreadByDataCenterPlacement) PickNodes(topo *Topology, option *VolumeGrowOption) ([]*DataNode, error) {
+	return pickOneDataNodeEach(topo.Children(), option.ReplicaPlacement.GetCopyCount(), option)
+}
+
+// pinToDataCenterPlacement places one copy on another data center, and the other copies on different racks
+// of the pinned data center
+type pinToDataCenterPlacement struct {
+	dataCenter NodeId
+}
+
+func (p *pinToDataCenterPlacement) Satisfies(rp *super_block.ReplicaPlacement) bool {
+	return rp.DiffDataCenterCount == 1 && rp.SameRackCount == 0
+}
+
+func (p *pinToDataCenterPlacement) PickNodes(topo *Topology, option *VolumeGrowOption) ([]*DataNode, error) {
+	copyCount := option.ReplicaPlacement.GetCopyCount()
+	if copyCount < 2 {
+		return nil, fmt.Errorf("replication %s has no remote copy", option.ReplicaPlacement)
+	}
+	if option.DataCenter != "" && NodeId(option.DataCenter) != p.dataCenter {
+		return nil, fmt.Errorf("Not matching pinned data center:%s", p.dataCenter)
+	}
+	var pinned Node
+	var others []Node
+	for _, dc := range topo.Children() {
+		if dc.Id() == p.dataCenter {
+			pinned = dc
+		} else {
+			others = append(others, dc)
+		}
+	}
+	if pinned == nil {
+		return nil, fmt.Errorf("pinned data center %s not found", p.dataCenter)
+	}
+
+	servers, err := pickOneDataNodeEach(pinned.Children(), copyCount-1, option)
+	if err != nil {
+		return nil, fmt.Errorf("data center %s: %v", p.dataCenter, err)
+	}
+
+	remote, err := pickOneDataNodeEach(others, 1, &VolumeGrowOption{DiskType: option.DiskType})
+	if err != nil {
+		return nil, fmt.Errorf("remote copy: %v", err)
+	}
+	return append(servers, remote...), nil
+}
+
+// pickOneDataNodeEach picks count nodes by their free slots, and one data node under each of them.
+// The preferred data node, if any, is the first copy.
+func pickOneDataNodeEach(nodes []Node, count int, option *VolumeGrowOption) (servers []*DataNode, err error) {
+	candidates := sortByWeight(nodes, option)
+	var preferred *DataNode
+	if hasPreferredLocation(option) {
+		for i, node := range candidates {
+			if preferred = pickPreferredDataNode(node, option); preferred != nil {
+				candidates[0], candidates[i] = candidates[i], candidates[0]
+				break
+			}
+		}
+		if preferred == nil {
+			return nil, fmt.Errorf("Not matching preferred data center:%s rack:%s data node:%s", option.DataCenter, option.Rack, option.DataNode)
+		}
+		servers = append(servers, preferred)
+	}
+	if len(candidates) < count {
+		return nil, fmt.Errorf("Only has %d nodes with a free slot, not enough for %d.", len(candidates), count)
+	}
+	for _, node := range candidates[len(servers):count] {
+		server, err := node.ReserveOneVolume(rand.Int63n(node.AvailableSpaceFor(option)), option)
+		if err != nil {
+			return nil, err
+		}
+		servers = append(servers, server)
+	}
+	return servers, nil
+}
+
+// sortByWeight shuffles the nodes with a free slot, the nodes with more free slots are more likely earlier
+func sortByWeight(nodes []Node, option *VolumeGrowOption) []Node {
+	type weighted struct {
+		node Node
+		key  float64
+	}
+	var candidates []weighted
+	for _, node := range nodes {
+		if free := node.AvailableSpaceFor(option); free > 0 {
+			// the weighted random sampling, with the key of rand^(1/weight)
+			candidates = append(candidates, weighted{node, -rand.ExpFloat64() / float64(free)})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].key > candidates[j].key
+	})
+	sorted := make([]Node, 0, len(candidates))
+	for _, c := range candidates {
+		sorted = append(sorted, c.node)
+	}
+	return sorted
+}
+
+func hasPreferredLocation(option *VolumeGrowOption) bool {
+	return option.DataCenter != "" || option.Rack != "" || option.DataNode != ""
+}
+
+// pickPreferredDataNode picks a data node with a free slot under the node, in the preferred data center, rack and data node
+func pickPreferredDataNode(node Node, option *VolumeGrowOption) *DataNode {
+	if node.IsDataNode() {
+		dn := node.(*DataNode)
+		if option.DataNode != "" && dn.Id() != NodeId(option.DataNode) ||
+			option.Rack != "" && dn.GetRack().Id() != NodeId(option.Rack) ||
+			option.DataCenter != "" && dn.GetDataCenter().Id() != NodeId(option.DataCenter) {
+			return nil
+		}
+		return dn
+	}
+	for _, child := range sortByWeight(node.Children(), option) {
+		if dn := pickPreferredDataNode(child, option); dn != nil {
+			return dn
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package topology
+
+import (
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/storage/super_block"
+)
+
+func pickNodes(t *testing.T, spec string, replication string, option *VolumeGrowOption) ([]*DataNode, error) {
+	strategy, err := NewPlacementStrategy(spec)
+	if err != nil {
+		t.Fatalf("placement strategy %s: %v", spec, err)
+	}
+	option.ReplicaPlacement, _ = super_block.NewReplicaPlacementFromString(replication)
+	return strategy.PickNodes(setup(topologyLayout), option)
+}
+
+func TestSpreadByRackPlacement(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		servers, err := pickNodes(t, "spread_by_rack", "010", &VolumeGrowOption{})
+		if err != nil {
+			t.Fatalf("pick nodes: %v", err)
+		}
+		if len(servers) != 2 || servers[0].GetRack() == servers[1].GetRack() ||
+			servers[0].GetDataCenter().Id() != "dc1" || servers[1].GetDataCenter().Id() != "dc1" {
+			t.Errorf("expecting 2 copies on 2 racks of dc1, but got %v", servers)
+		}
+	}
+
+	if _, err := pickNodes(t, "spread_by_rack", "020", &VolumeGrowOption{}); err == nil {
+		t.Errorf("3 copies should not fit in the racks of one data center")
+	}
+}
+
+func TestSpreadByDataCenterPlacement(t *testing.T) {
+	servers, err := pickNodes(t, "spread_by_dc", "100", &VolumeGrowOption{DataNode: "server321"})
+	if err != nil {
+		t.Fatalf("pick nodes: %v", err)
+	}
+	if len(servers) != 2 || servers[0].Id() != "server321" || servers[1].GetDataCenter().Id() != "dc1" {
+		t.Errorf("unexpected copies %v", servers)
+	}
+
+	// dc2 has no data nodes
+	if _, err := pickNodes(t, "spread_by_dc", "200", &VolumeGrowOption{}); err == nil {
+		t.Errorf("3 copies should not fit in 2 data centers")
+	}
+}
+
+func TestPinToDataCenterPlacement(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		servers, err := pickNodes(t, "pin_to_dc_with_remote_copy:dc1", "110", &VolumeGrowOption{})
+		if err != nil {
+			t.Fatalf("pick nodes: %v", err)
+		}
+		if len(servers) != 3 {
+			t.Fatalf("expecting 3 copies, but got %v", servers)
+		}
+		if servers[0].GetDataCenter().Id() != "dc1" || servers[1].GetDataCenter().Id() != "dc1" ||
+			servers[0].GetRack() == servers[1].GetRack() {
+			t.Errorf("expecting 2 copies on different racks of dc1, but got %v", servers)
+		}
+		if servers[2].Id() != "server321" {
+			t.Errorf("expecting the remote copy on dc3, but got %v", servers[2])
+		}
+	}
+
+	servers, err := pickNodes(t, "pin_to_dc_with_remote_copy:dc1", "100", &VolumeGrowOption{Rack: "rack1"})
+	if err != nil {
+		t.Fatalf("pick nodes: %v", err)
+	}
+	if servers[0].Id() != "server112" {
+		t.Errorf("expecting the first copy on the only free data node of rack1, but got %v", servers[0])
+	}
+
+	if _, err := pickNodes(t, "pin_to_dc_with_remote_copy:dc1", "120", &VolumeGrowOption{}); err == nil {
+		t.Errorf("3 copies should not fit in the racks of dc1")
+	}
+}
+
+func TestPlacementSatisfies(t *testing.T) {
+	for spec, replications := range map[string]map[string]bool{
+		"replication":                    {"000": true, "002": true, "110": true},
+		"spread_by_rack":                 {"000": true, "020": true, "002": false, "110": false},
+		"spread_by_dc":                   {"000": true, "200": true, "010": false, "101": false},
+		"pin_to_dc_with_remote_copy:dc1": {"100": true, "120": true, "000": false, "200": false, "101": false},
+	} {
+		strategy, err := NewPlacementStrategy(spec)
+		if err != nil {
+			t.Fatalf("placement strategy %s: %v", spec, err)
+		}
+		for replication, expected := range replications {
+			rp, _ := super_block.NewReplicaPlacementFromString(replication)
+			if strategy.Satisfies(rp) != expected {
+				t.Errorf("placement strategy %s satisfies %s: %v", spec, replication, !expected)
+			}
+		}
+	}
+
+	// the replication strategy places the copies on the same rack
+	vg := &VolumeGrowth{placements: map[string]PlacementStrategy{"logs": &spreadByRackPlacement{}}}
+	option := &VolumeGrowOption{Collection: "logs"}
+	option.ReplicaPlacement, _ = super_block.NewReplicaPlacementFromString("001")
+	servers, err := vg.findEmptySlotsForOneVolume(setup(topologyLayout), option)
+	if err != nil {
+		t.Fatalf("pick nodes: %v", err)
+	}
+	if len(servers) != 2 || servers[0].GetRack() != servers[1].GetRack() {
+		t.Errorf("expecting 2 copies on the same rack, but got %v", servers)
+	}
+}
+
+func TestNewPlacementStrategy(t *testing.T) {
+	for _, spec := range []string{"unknown", "pin_to_dc_with_remote_copy", "pin_to_dc_with_remote_copy:"} {
+		if _, err := NewPlacementStrategy(spec); err == nil {
+			t.Errorf("placement strategy %s should be invalid", spec)
+		}
+	}
+}
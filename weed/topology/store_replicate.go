@@ -8,10 +8,12 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/operation"
 	"github.com/chrislusf/seaweedfs/weed/security"
+	"github.com/chrislusf/seaweedfs/weed/stats"
 	"github.com/chrislusf/seaweedfs/weed/storage"
 	"github.com/chrislusf/seaweedfs/weed/storage/needle"
 	"github.com/chrislusf/seaweedfs/weed/storage/types"
@@ -40,8 +42,11 @@ func ReplicatedWrite(masterFn operation.GetMasterFn, s *storage.Store, volumeId
 		fsync = true
 	}
 
+	stages := stats.UploadStagesFromContext(r.Context())
 	if s.GetVolume(volumeId) != nil {
+		start := time.Now()
 		isUnchanged, err = s.WriteVolumeNeedle(volumeId, n, fsync)
+		stages.Since(stats.UploadStageDisk, start)
 		if err != nil {
 			err = fmt.Errorf("failed to write to local disk: %v", err)
 			glog.V(0).Infoln(err)
@@ -50,6 +55,8 @@ func ReplicatedWrite(masterFn operation.GetMasterFn, s *storage.Store, volumeId
 	}
 
 	if len(remoteLocations) > 0 { //send to other replica locations
+		start := time.Now()
+		defer stages.Since(stats.UploadStageReplicate, start)
 		if err = distributedOperation(remoteLocations, s, func(location operation.Location) error {
 			u := url.URL{
 				Scheme: "http",
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"github.com/chrislusf/seaweedfs/weed/storage/types"
 	"math/rand"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/chrislusf/raft"
@@ -21,12 +23,22 @@ import (
 
 type Topology struct {
 	vacuumLockCounter int64
+	vacuumSchedule    VacuumSchedule
 	NodeImpl
 
 	collectionMap  *util.ConcurrentReadMap
 	ecShardMap     map[needle.VolumeId]*EcShardLocations
 	ecShardMapLock sync.RWMutex
 
+	maintenanceScopes map[string]*MaintenanceScope
+	maintenanceLock   sync.RWMutex
+
+	collectionDefaults     map[string]*CollectionDefaults
+	collectionDefaultsLock sync.RWMutex
+
+	readOnly     ClusterReadOnly
+	readOnlyLock sync.RWMutex
+
 	pulse int64
 
 	volumeSizeLimit  uint64
@@ -38,7 +50,8 @@ type Topology struct {
 
 	Configuration *Configuration
 
-	RaftServer raft.Server
+	RaftServer       raft.Server
+	leaderLeaseUntil int64
 }
 
 func NewTopology(id string, seq sequence.Sequencer, volumeSizeLimit uint64, pulse int, replicationAsMin bool) *Topology {
@@ -50,6 +63,8 @@ func NewTopology(id string, seq sequence.Sequencer, volumeSizeLimit uint64, puls
 	t.children = make(map[NodeId]Node)
 	t.collectionMap = util.NewConcurrentReadMap()
 	t.ecShardMap = make(map[needle.VolumeId]*EcShardLocations)
+	t.maintenanceScopes = make(map[string]*MaintenanceScope)
+	t.collectionDefaults = make(map[string]*CollectionDefaults)
 	t.pulse = int64(pulse)
 	t.volumeSizeLimit = volumeSizeLimit
 	t.replicationAsMin = replicationAsMin
@@ -63,30 +78,69 @@ func NewTopology(id string, seq sequence.Sequencer, volumeSizeLimit uint64, puls
 	return t
 }
 
+// IsLeader is true while this master is the raft leader and holds the leader lease.
 func (t *Topology) IsLeader() bool {
 	if t.RaftServer != nil {
 		if t.RaftServer.State() == raft.Leader {
-			return true
+			return time.Now().UnixNano() < atomic.LoadInt64(&t.leaderLeaseUntil)
 		}
 	}
 	return false
 }
 
+// LoopRenewingLeaderLease renews the leader lease once per raft heartbeat interval,
+// so the assign and heartbeat requests checking IsLeader do not contend for the raft server lock.
+func (t *Topology) LoopRenewingLeaderLease() {
+	for {
+		t.renewLeaderLease()
+		time.Sleep(t.RaftServer.HeartbeatInterval())
+	}
+}
+
+// renewLeaderLease keeps the lease until half of the election timeout after a majority of the masters,
+// counting this one, has responded to the leader. A leader cut off from the other masters stops serving
+// before they can elect a new leader, so the clients move to the new leader instead of waiting for the old one.
+func (t *Topology) renewLeaderLease() {
+	lease := t.RaftServer.ElectionTimeout() / 2
+	now := time.Now()
+	activities := []time.Time{now}
+	for _, peer := range t.RaftServer.Peers() {
+		activities = append(activities, peer.LastActivity())
+	}
+	sort.Slice(activities, func(i, j int) bool {
+		return activities[i].After(activities[j])
+	})
+	// the latest time a majority has responded
+	majority := activities[len(activities)/2]
+	atomic.StoreInt64(&t.leaderLeaseUntil, majority.Add(lease).UnixNano())
+}
+
+// Leader returns the current leader, waiting up to the election timeout if it is not known yet.
+// A leader without the leader lease is not returned.
 func (t *Topology) Leader() (string, error) {
-	l := ""
-	for count := 0; count < 3; count++ {
-		if t.RaftServer != nil {
-			l = t.RaftServer.Leader()
-		} else {
-			return "", errors.New("Raft Server not ready yet!")
-		}
-		if l != "" {
-			break
-		} else {
-			time.Sleep(time.Duration(5+count) * time.Second)
+	if t.RaftServer == nil {
+		return "", errors.New("Raft Server not ready yet!")
+	}
+	deadline := time.Now().Add(t.RaftServer.ElectionTimeout())
+	for {
+		l := t.LeaderHint()
+		if l != "" || time.Now().After(deadline) {
+			return l, nil
 		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// LeaderHint returns the leader known by this master without waiting, or empty if not known.
+func (t *Topology) LeaderHint() string {
+	if t.RaftServer == nil {
+		return ""
+	}
+	l := t.RaftServer.Leader()
+	if l == t.RaftServer.Name() && !t.IsLeader() {
+		return ""
 	}
-	return l, nil
+	return l
 }
 
 func (t *Topology) Lookup(collection string, vid needle.VolumeId) (dataNodes []*DataNode) {
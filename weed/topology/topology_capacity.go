@@ -0,0 +1,101 @@
+package topology
+
+import (
+	"sort"
+
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+)
+
+// Capacity is the space used by the volumes, from the latest heartbeats.
+// PhysicalSize counts all replicas, while LogicalSize counts the live data once.
+// ReclaimableSize is taken by the deleted files in all replicas, and is freed by the vacuum.
+// The erasure coded volumes are not included, since the shard sizes are not reported to the master.
+type Capacity struct {
+	Collection      string  `json:"collection"`
+	VolumeCount     int     `json:"volumeCount"`
+	ReplicaCount    int     `json:"replicaCount"`
+	FileCount       uint64  `json:"fileCount"`
+	DeletedCount    uint64  `json:"deletedCount"`
+	LogicalSize     uint64  `json:"logicalSize"`
+	PhysicalSize    uint64  `json:"physicalSize"`
+	ReclaimableSize uint64  `json:"reclaimableSize"`
+	GarbageRatio    float64 `json:"garbageRatio"` // the share of the physical size freed by the vacuum
+}
+
+func (c *Capacity) add(o *Capacity) {
+	c.VolumeCount += o.VolumeCount
+	c.ReplicaCount += o.ReplicaCount
+	c.FileCount += o.FileCount
+	c.DeletedCount += o.DeletedCount
+	c.LogicalSize += o.LogicalSize
+	c.PhysicalSize += o.PhysicalSize
+	c.ReclaimableSize += o.ReclaimableSize
+}
+
+func (c *Capacity) setGarbageRatio() {
+	if c.PhysicalSize > 0 {
+		c.GarbageRatio = float64(c.ReclaimableSize) / float64(c.PhysicalSize)
+	}
+}
+
+// ToCapacity sums up the capacity of the whole cluster, and of each collection ordered by name.
+func (t *Topology) ToCapacity() (total *Capacity, collections []*Capacity) {
+
+	type volumeKey struct {
+		collection string
+		vid        needle.VolumeId
+	}
+	volumes := make(map[volumeKey]*Capacity)
+
+	for _, c := range t.Children() {
+		for _, r := range c.(*DataCenter).Children() {
+			for _, d := range r.(*Rack).Children() {
+				for _, v := range d.(*DataNode).GetVolumes() {
+					liveSize := uint64(0)
+					if v.Size > v.DeletedByteCount {
+						liveSize = v.Size - v.DeletedByteCount
+					}
+					key := volumeKey{v.Collection, v.Id}
+					capacity, found := volumes[key]
+					if !found {
+						capacity = &Capacity{Collection: v.Collection, VolumeCount: 1}
+						volumes[key] = capacity
+					}
+					capacity.ReplicaCount++
+					capacity.PhysicalSize += v.Size
+					capacity.ReclaimableSize += v.DeletedByteCount
+					// the replicas may be slightly out of sync, use the one with the most live data
+					if liveSize >= capacity.LogicalSize {
+						capacity.LogicalSize = liveSize
+						capacity.FileCount = 0
+						if v.FileCount > v.DeleteCount {
+							capacity.FileCount = uint64(v.FileCount - v.DeleteCount)
+						}
+						capacity.DeletedCount = uint64(v.DeleteCount)
+					}
+				}
+			}
+		}
+	}
+
+	total = &Capacity{}
+	byCollection := make(map[string]*Capacity)
+	for _, volume := range volumes {
+		collection, found := byCollection[volume.Collection]
+		if !found {
+			collection = &Capacity{Collection: volume.Collection}
+			byCollection[volume.Collection] = collection
+			collections = append(collections, collection)
+		}
+		collection.add(volume)
+		total.add(volume)
+	}
+	total.setGarbageRatio()
+	for _, collection := range collections {
+		collection.setGarbageRatio()
+	}
+	sort.Slice(collections, func(i, j int) bool {
+		return collections[i].Collection < collections[j].Collection
+	})
+	return total, collections
+}
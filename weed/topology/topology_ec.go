@@ -9,7 +9,7 @@ import (
 
 type EcShardLocations struct {
 	Collection string
-	Locations  [erasure_coding.TotalShardsCount][]*DataNode
+	Locations  [erasure_coding.MaxShardsCount][]*DataNode
 }
 
 func (t *Topology) SyncDataNodeEcShards(shardInfos []*master_pb.VolumeEcShardInformationMessage, dn *DataNode) (newShards, deletedShards []*erasure_coding.EcVolumeInfo) {
@@ -21,7 +21,8 @@ func (t *Topology) SyncDataNodeEcShards(shardInfos []*master_pb.VolumeEcShardInf
 				shardInfo.DiskType,
 				shardInfo.Collection,
 				needle.VolumeId(shardInfo.Id),
-				erasure_coding.ShardBits(shardInfo.EcIndexBits)))
+				erasure_coding.ShardBits(shardInfo.EcIndexBits),
+				erasure_coding.ToEcLayout(shardInfo.DataShards, shardInfo.ParityShards)))
 	}
 	// find out the delta volumes
 	newShards, deletedShards = dn.UpdateEcShards(shards)
@@ -43,7 +44,8 @@ func (t *Topology) IncrementalSyncDataNodeEcShards(newEcShards, deletedEcShards
 				shardInfo.DiskType,
 				shardInfo.Collection,
 				needle.VolumeId(shardInfo.Id),
-				erasure_coding.ShardBits(shardInfo.EcIndexBits)))
+				erasure_coding.ShardBits(shardInfo.EcIndexBits),
+				erasure_coding.ToEcLayout(shardInfo.DataShards, shardInfo.ParityShards)))
 	}
 	for _, shardInfo := range deletedEcShards {
 		deletedShards = append(deletedShards,
@@ -51,7 +53,8 @@ func (t *Topology) IncrementalSyncDataNodeEcShards(newEcShards, deletedEcShards
 				shardInfo.DiskType,
 				shardInfo.Collection,
 				needle.VolumeId(shardInfo.Id),
-				erasure_coding.ShardBits(shardInfo.EcIndexBits)))
+				erasure_coding.ShardBits(shardInfo.EcIndexBits),
+				erasure_coding.ToEcLayout(shardInfo.DataShards, shardInfo.ParityShards)))
 	}
 
 	dn.DeltaUpdateEcShards(newShards, deletedShards)
@@ -21,10 +21,17 @@ func (t *Topology) StartRefreshWritableVolumes(grpcDialOption grpc.DialOption, g
 		}
 	}()
 	go func(garbageThreshold float64) {
-		c := time.Tick(15 * time.Minute)
-		for _ = range c {
+		// check every minute, to not miss a short vacuum window, but vacuum at most every 15 minutes
+		lastVacuumTime := time.Now()
+		c := time.Tick(time.Minute)
+		for now := range c {
+			windows := t.GetVacuumSchedule().Windows
+			if now.Sub(lastVacuumTime) < 15*time.Minute || !windows.Contains(now) {
+				continue
+			}
 			if t.IsLeader() {
-				t.Vacuum(grpcDialOption, garbageThreshold, preallocate)
+				lastVacuumTime = now
+				t.vacuum(grpcDialOption, garbageThreshold, preallocate, windows)
 			}
 		}
 	}(garbageThreshold)
@@ -0,0 +1,478 @@
+package topology
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/master_pb"
+	"github.com/chrislusf/seaweedfs/weed/storage/erasure_coding"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+	"github.com/chrislusf/seaweedfs/weed/storage/super_block"
+)
+
+// the health status of the cluster, the nodes, the volumes and the erasure coded volumes
+const (
+	HealthOk       = "ok"
+	HealthWarning  = "warning"
+	HealthCritical = "critical"
+)
+
+// Health is the status with the problems found, the worse status of its parts for the parent nodes
+type Health struct {
+	Status   string   `json:"status"`
+	Problems []string `json:"problems,omitempty"`
+}
+
+func (h *Health) add(status string, format string, args ...interface{}) {
+	h.Problems = append(h.Problems, fmt.Sprintf(format, args...))
+	h.worsen(status)
+}
+
+func (h *Health) worsen(status string) {
+	if status == HealthCritical || status == HealthWarning && h.Status != HealthCritical {
+		h.Status = status
+	}
+}
+
+func newHealth() Health {
+	return Health{Status: HealthOk}
+}
+
+// ClusterTopology is the topology from the latest heartbeats, with the health of each part.
+type ClusterTopology struct {
+	Max             int64                 `json:"max"`
+	Free            int64                 `json:"free"`
+	VolumeSizeLimit uint64                `json:"volumeSizeLimit"`
+	Health          Health                `json:"health"`
+	DataCenters     []*DataCenterHealth   `json:"dataCenters"`
+	Layouts         []*VolumeLayoutHealth `json:"layouts"`
+	EcVolumes       []*EcVolumeHealth     `json:"ecVolumes"`
+}
+
+type DataCenterHealth struct {
+	Id     string        `json:"id"`
+	Max    int64         `json:"max"`
+	Free   int64         `json:"free"`
+	Health Health        `json:"health"`
+	Racks  []*RackHealth `json:"racks"`
+}
+
+type RackHealth struct {
+	Id        string            `json:"id"`
+	Max       int64             `json:"max"`
+	Free      int64             `json:"free"`
+	Health    Health            `json:"health"`
+	DataNodes []*DataNodeHealth `json:"dataNodes"`
+}
+
+type DataNodeHealth struct {
+	Id        string        `json:"id"`
+	Url       string        `json:"url"`
+	PublicUrl string        `json:"publicUrl"`
+	Max       int64         `json:"max"`
+	Free      int64         `json:"free"`
+	Health    Health        `json:"health"`
+	Disks     []*DiskHealth `json:"disks"`
+}
+
+type DiskHealth struct {
+	Type     string            `json:"type"`
+	Max      int64             `json:"max"`
+	Free     int64             `json:"free"`
+	Volumes  []*VolumeHealth   `json:"volumes"`
+	EcShards []*EcShardsOnDisk `json:"ecShards,omitempty"`
+}
+
+type VolumeHealth struct {
+	Id                needle.VolumeId `json:"id"`
+	Collection        string          `json:"collection"`
+	Size              uint64          `json:"size"`
+	FileCount         int             `json:"fileCount"`
+	DeleteCount       int             `json:"deleteCount"`
+	DeletedByteCount  uint64          `json:"deletedByteCount"`
+	ReadOnly          bool            `json:"readOnly"`
+	Replication       string          `json:"replication"`
+	Ttl               string          `json:"ttl,omitempty"`
+	RemoteStorageName string          `json:"remoteStorageName,omitempty"`
+	Copies            int             `json:"copies"`
+	Health            Health          `json:"health"`
+}
+
+type EcShardsOnDisk struct {
+	Id         needle.VolumeId `json:"id"`
+	Collection string          `json:"collection"`
+	ShardIds   []uint32        `json:"shardIds"`
+}
+
+type VolumeLayoutHealth struct {
+	Collection    string `json:"collection"`
+	Replication   string `json:"replication"`
+	Ttl           string `json:"ttl,omitempty"`
+	DiskType      string `json:"diskType,omitempty"`
+	VolumeCount   int    `json:"volumeCount"`
+	WritableCount int    `json:"writableCount"`
+	Health        Health `json:"health"`
+}
+
+// EcVolumeHealth has the shards of one erasure coded volume on all data nodes
+type EcVolumeHealth struct {
+	Id              needle.VolumeId `json:"id"`
+	Collection      string          `json:"collection"`
+	Layout          string          `json:"layout"`
+	ShardCount      int             `json:"shardCount"`
+	MissingShardIds []uint32        `json:"missingShardIds,omitempty"`
+	Health          Health          `json:"health"`
+}
+
+// ToClusterTopology walks the whole topology, and annotates the health: the volumes with fewer or more copies
+// than the replication, unless the replication is treated as minimums, the erasure coded volumes missing shards,
+// the nodes without free volume slots, and the volume layouts without writable volumes.
+func (t *Topology) ToClusterTopology() *ClusterTopology {
+	ct := &ClusterTopology{
+		Max:             t.diskUsages.GetMaxVolumeCount(),
+		Free:            t.diskUsages.FreeSpace(),
+		VolumeSizeLimit: t.volumeSizeLimit,
+		Health:          newHealth(),
+	}
+
+	copies := make(map[needle.VolumeId]int)
+	ecVolumes := make(map[needle.VolumeId]*erasure_coding.EcVolumeInfo)
+	for _, c := range t.Children() {
+		for _, r := range c.Children() {
+			for _, n := range r.Children() {
+				for _, v := range n.(*DataNode).GetVolumes() {
+					copies[v.Id]++
+				}
+				for _, d := range n.Children() {
+					for _, ecShards := range d.(*Disk).GetEcShards() {
+						if ecVolume, found := ecVolumes[ecShards.VolumeId]; found {
+							ecVolume.ShardBits = ecVolume.ShardBits.Plus(ecShards.ShardBits)
+						} else {
+							ecVolumes[ecShards.VolumeId] = erasure_coding.NewEcVolumeInfo(ecShards.DiskType, ecShards.Collection,
+								ecShards.VolumeId, ecShards.ShardBits, ecShards.Layout)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	for _, c := range t.Children() {
+		dc := c.(*DataCenter)
+		dch := &DataCenterHealth{
+			Id:     string(dc.Id()),
+			Max:    dc.diskUsages.GetMaxVolumeCount(),
+			Free:   dc.diskUsages.FreeSpace(),
+			Health: newHealth(),
+		}
+		for _, r := range dc.Children() {
+			rack := r.(*Rack)
+			rh := &RackHealth{
+				Id:     string(rack.Id()),
+				Max:    rack.diskUsages.GetMaxVolumeCount(),
+				Free:   rack.diskUsages.FreeSpace(),
+				Health: newHealth(),
+			}
+			for _, n := range rack.Children() {
+				dnh := n.(*DataNode).toDataNodeHealth(copies, t.replicationAsMin)
+				rh.Health.worsen(dnh.Health.Status)
+				rh.DataNodes = append(rh.DataNodes, dnh)
+			}
+			sort.Slice(rh.DataNodes, func(i, j int) bool {
+				return rh.DataNodes[i].Id < rh.DataNodes[j].Id
+			})
+			dch.Health.worsen(rh.Health.Status)
+			dch.Racks = append(dch.Racks, rh)
+		}
+		sort.Slice(dch.Racks, func(i, j int) bool {
+			return dch.Racks[i].Id < dch.Racks[j].Id
+		})
+		ct.Health.worsen(dch.Health.Status)
+		ct.DataCenters = append(ct.DataCenters, dch)
+	}
+	sort.Slice(ct.DataCenters, func(i, j int) bool {
+		return ct.DataCenters[i].Id < ct.DataCenters[j].Id
+	})
+
+	for _, col := range t.collectionMap.Items() {
+		c := col.(*Collection)
+		for _, layout := range c.storageType2VolumeLayout.Items() {
+			if layout == nil {
+				continue
+			}
+			lh := layout.(*VolumeLayout).toVolumeLayoutHealth(c.Name)
+			ct.Health.worsen(lh.Health.Status)
+			ct.Layouts = append(ct.Layouts, lh)
+		}
+	}
+	sort.Slice(ct.Layouts, func(i, j int) bool {
+		if ct.Layouts[i].Collection != ct.Layouts[j].Collection {
+			return ct.Layouts[i].Collection < ct.Layouts[j].Collection
+		}
+		return ct.Layouts[i].Replication < ct.Layouts[j].Replication
+	})
+
+	for _, ecVolume := range ecVolumes {
+		eh := toEcVolumeHealth(ecVolume)
+		ct.Health.worsen(eh.Health.Status)
+		ct.EcVolumes = append(ct.EcVolumes, eh)
+	}
+	sort.Slice(ct.EcVolumes, func(i, j int) bool {
+		return ct.EcVolumes[i].Id < ct.EcVolumes[j].Id
+	})
+
+	if ct.Max > 0 && ct.Free <= 0 {
+		ct.Health.add(HealthCritical, "no free volume slots in the cluster")
+	}
+	return ct
+}
+
+func (dn *DataNode) toDataNodeHealth(copies map[needle.VolumeId]int, replicationAsMin bool) *DataNodeHealth {
+	dnh := &DataNodeHealth{
+		Id:        string(dn.Id()),
+		Url:       dn.Url(),
+		PublicUrl: dn.PublicUrl,
+		Max:       dn.diskUsages.GetMaxVolumeCount(),
+		Free:      dn.diskUsages.FreeSpace(),
+		Health:    newHealth(),
+	}
+	for _, c := range dn.Children() {
+		disk := c.(*Disk)
+		dh := &DiskHealth{
+			Type: string(disk.Id()),
+			Max:  disk.diskUsages.GetMaxVolumeCount(),
+			Free: disk.FreeSpace(),
+		}
+		for _, v := range disk.GetVolumes() {
+			rp := v.ReplicaPlacement
+			if rp == nil {
+				rp = &super_block.ReplicaPlacement{}
+			}
+			vh := &VolumeHealth{
+				Id:                v.Id,
+				Collection:        v.Collection,
+				Size:              v.Size,
+				FileCount:         v.FileCount,
+				DeleteCount:       v.DeleteCount,
+				DeletedByteCount:  v.DeletedByteCount,
+				ReadOnly:          v.ReadOnly,
+				Replication:       rp.String(),
+				RemoteStorageName: v.RemoteStorageName,
+				Copies:            copies[v.Id],
+				Health:            newHealth(),
+			}
+			if v.Ttl != nil {
+				vh.Ttl = v.Ttl.String()
+			}
+			if expected := rp.GetCopyCount(); vh.Copies < expected {
+				vh.Health.add(HealthWarning, "under replicated: %d of %d copies", vh.Copies, expected)
+			} else if vh.Copies > expected && !replicationAsMin {
+				vh.Health.add(HealthWarning, "over replicated: %d of %d copies", vh.Copies, expected)
+			}
+			dnh.Health.worsen(vh.Health.Status)
+			dh.Volumes = append(dh.Volumes, vh)
+		}
+		sort.Slice(dh.Volumes, func(i, j int) bool {
+			return dh.Volumes[i].Id < dh.Volumes[j].Id
+		})
+		for _, ecShards := range disk.GetEcShards() {
+			dh.EcShards = append(dh.EcShards, &EcShardsOnDisk{
+				Id:         ecShards.VolumeId,
+				Collection: ecShards.Collection,
+				ShardIds:   ecShards.ShardBits.ToUint32Slice(),
+			})
+		}
+		sort.Slice(dh.EcShards, func(i, j int) bool {
+			return dh.EcShards[i].Id < dh.EcShards[j].Id
+		})
+		dnh.Disks = append(dnh.Disks, dh)
+	}
+	sort.Slice(dnh.Disks, func(i, j int) bool {
+		return dnh.Disks[i].Type < dnh.Disks[j].Type
+	})
+	if dnh.Max > 0 && dnh.Free <= 0 {
+		dnh.Health.add(HealthWarning, "no free volume slots")
+	}
+	return dnh
+}
+
+func (vl *VolumeLayout) toVolumeLayoutHealth(collection string) *VolumeLayoutHealth {
+	vl.accessLock.RLock()
+	defer vl.accessLock.RUnlock()
+	lh := &VolumeLayoutHealth{
+		Collection:    collection,
+		Replication:   vl.rp.String(),
+		DiskType:      string(vl.diskType),
+		VolumeCount:   len(vl.vid2location),
+		WritableCount: len(vl.writables),
+		Health:        newHealth(),
+	}
+	if vl.ttl != nil {
+		lh.Ttl = vl.ttl.String()
+	}
+	if lh.VolumeCount > 0 && lh.WritableCount == 0 {
+		lh.Health.add(HealthWarning, "no writable volumes")
+	}
+	return lh
+}
+
+// toEcVolumeHealth is critical if the volume can not be read any more, or a warning if any shard is missing
+func toEcVolumeHealth(ecVolume *erasure_coding.EcVolumeInfo) *EcVolumeHealth {
+	layout := ecVolume.Layout
+	if layout.DataShards == 0 {
+		layout = erasure_coding.DefaultEcLayout
+	}
+	eh := &EcVolumeHealth{
+		Id:         ecVolume.VolumeId,
+		Collection: ecVolume.Collection,
+		Layout:     layout.String(),
+		ShardCount: ecVolume.ShardBits.ShardIdCount(),
+		Health:     newHealth(),
+	}
+	for shardId := 0; shardId < layout.TotalShards(); shardId++ {
+		if !ecVolume.ShardBits.HasShardId(erasure_coding.ShardId(shardId)) {
+			eh.MissingShardIds = append(eh.MissingShardIds, uint32(shardId))
+		}
+	}
+	if eh.ShardCount < layout.DataShards {
+		eh.Health.add(HealthCritical, "only %d shards, not enough for %d data shards", eh.ShardCount, layout.DataShards)
+	} else if len(eh.MissingShardIds) > 0 {
+		eh.Health.add(HealthWarning, "missing %d of %d shards", len(eh.MissingShardIds), layout.TotalShards())
+	}
+	return eh
+}
+
+// AllProblems lists the problems of all parts, prefixed by where they are
+func (ct *ClusterTopology) AllProblems() (problems []string) {
+	problems = append(problems, ct.Health.Problems...)
+	for _, dc := range ct.DataCenters {
+		for _, rack := range dc.Racks {
+			for _, dn := range rack.DataNodes {
+				for _, problem := range dn.Health.Problems {
+					problems = append(problems, fmt.Sprintf("data node %s: %s", dn.Id, problem))
+				}
+				for _, disk := range dn.Disks {
+					for _, v := range disk.Volumes {
+						for _, problem := range v.Health.Problems {
+							problems = append(problems, fmt.Sprintf("volume %d on %s: %s", v.Id, dn.Id, problem))
+						}
+					}
+				}
+			}
+		}
+	}
+	for _, layout := range ct.Layouts {
+		for _, problem := range layout.Health.Problems {
+			problems = append(problems, fmt.Sprintf("collection %q replication %s: %s", layout.Collection, layout.Replication, problem))
+		}
+	}
+	for _, ecVolume := range ct.EcVolumes {
+		for _, problem := range ecVolume.Health.Problems {
+			problems = append(problems, fmt.Sprintf("ec volume %d: %s", ecVolume.Id, problem))
+		}
+	}
+	return
+}
+
+// ToClusterTopologyInfo converts the health annotated topology for the master_pb ClusterTopology rpc
+func (ct *ClusterTopology) ToClusterTopologyInfo() *master_pb.ClusterTopologyInfo {
+	info := &master_pb.ClusterTopologyInfo{
+		Max:             ct.Max,
+		Free:            ct.Free,
+		VolumeSizeLimit: ct.VolumeSizeLimit,
+		Health:          ct.Health.toHealthPb(),
+	}
+	for _, dc := range ct.DataCenters {
+		dcInfo := &master_pb.DataCenterHealth{
+			Id:     dc.Id,
+			Max:    dc.Max,
+			Free:   dc.Free,
+			Health: dc.Health.toHealthPb(),
+		}
+		for _, rack := range dc.Racks {
+			rackInfo := &master_pb.RackHealth{
+				Id:     rack.Id,
+				Max:    rack.Max,
+				Free:   rack.Free,
+				Health: rack.Health.toHealthPb(),
+			}
+			for _, dn := range rack.DataNodes {
+				rackInfo.DataNodes = append(rackInfo.DataNodes, dn.toDataNodeHealthPb())
+			}
+			dcInfo.Racks = append(dcInfo.Racks, rackInfo)
+		}
+		info.DataCenters = append(info.DataCenters, dcInfo)
+	}
+	for _, layout := range ct.Layouts {
+		info.Layouts = append(info.Layouts, &master_pb.VolumeLayoutHealth{
+			Collection:    layout.Collection,
+			Replication:   layout.Replication,
+			Ttl:           layout.Ttl,
+			DiskType:      layout.DiskType,
+			VolumeCount:   uint32(layout.VolumeCount),
+			WritableCount: uint32(layout.WritableCount),
+			Health:        layout.Health.toHealthPb(),
+		})
+	}
+	for _, ecVolume := range ct.EcVolumes {
+		info.EcVolumes = append(info.EcVolumes, &master_pb.EcVolumeHealth{
+			Id:              uint32(ecVolume.Id),
+			Collection:      ecVolume.Collection,
+			Layout:          ecVolume.Layout,
+			ShardCount:      uint32(ecVolume.ShardCount),
+			MissingShardIds: ecVolume.MissingShardIds,
+			Health:          ecVolume.Health.toHealthPb(),
+		})
+	}
+	return info
+}
+
+func (dn *DataNodeHealth) toDataNodeHealthPb() *master_pb.DataNodeHealth {
+	dnInfo := &master_pb.DataNodeHealth{
+		Id:        dn.Id,
+		Url:       dn.Url,
+		PublicUrl: dn.PublicUrl,
+		Max:       dn.Max,
+		Free:      dn.Free,
+		Health:    dn.Health.toHealthPb(),
+	}
+	for _, disk := range dn.Disks {
+		diskInfo := &master_pb.DiskHealth{
+			Type: disk.Type,
+			Max:  disk.Max,
+			Free: disk.Free,
+		}
+		for _, v := range disk.Volumes {
+			diskInfo.Volumes = append(diskInfo.Volumes, &master_pb.VolumeHealth{
+				Id:                uint32(v.Id),
+				Collection:        v.Collection,
+				Size:              v.Size,
+				FileCount:         uint64(v.FileCount),
+				DeleteCount:       uint64(v.DeleteCount),
+				DeletedByteCount:  v.DeletedByteCount,
+				ReadOnly:          v.ReadOnly,
+				Replication:       v.Replication,
+				Ttl:               v.Ttl,
+				RemoteStorageName: v.RemoteStorageName,
+				Copies:            uint32(v.Copies),
+				Health:            v.Health.toHealthPb(),
+			})
+		}
+		for _, ecShards := range disk.EcShards {
+			diskInfo.EcShards = append(diskInfo.EcShards, &master_pb.EcShardsOnDisk{
+				Id:         uint32(ecShards.Id),
+				Collection: ecShards.Collection,
+				ShardIds:   ecShards.ShardIds,
+			})
+		}
+		dnInfo.Disks = append(dnInfo.Disks, diskInfo)
+	}
+	return dnInfo
+}
+
+func (h *Health) toHealthPb() *master_pb.Health {
+	return &master_pb.Health{
+		Status:   h.Status,
+		Problems: h.Problems,
+	}
+}
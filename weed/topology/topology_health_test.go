@@ -0,0 +1,104 @@
+package topology
+
+import (
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/storage/erasure_coding"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+)
+
+func TestToClusterTopology(t *testing.T) {
+	topo := setup(topologyLayout)
+
+	var shardBits, fewShardBits erasure_coding.ShardBits
+	for shardId := 0; shardId < erasure_coding.DataShardsCount; shardId++ {
+		shardBits = shardBits.AddShardId(erasure_coding.ShardId(shardId))
+		if shardId < 5 {
+			fewShardBits = fewShardBits.AddShardId(erasure_coding.ShardId(shardId))
+		}
+	}
+	var disk *Disk
+	for _, n := range topo.GetOrCreateDataCenter("dc1").GetOrCreateRack("rack1").Children() {
+		disk = n.(*DataNode).getOrCreateDisk("")
+	}
+	disk.AddOrUpdateEcShard(erasure_coding.NewEcVolumeInfo("", "", 10, shardBits, erasure_coding.DefaultEcLayout))
+	disk.AddOrUpdateEcShard(erasure_coding.NewEcVolumeInfo("", "", 11, fewShardBits, erasure_coding.DefaultEcLayout))
+
+	ct := topo.ToClusterTopology()
+	if ct.Health.Status != HealthCritical {
+		t.Errorf("expecting critical health with the unreadable ec volume, but got %+v", ct.Health)
+	}
+	if len(ct.DataCenters) != 3 || ct.DataCenters[0].Id != "dc1" || len(ct.DataCenters[0].Racks) != 2 {
+		t.Fatalf("unexpected data centers %+v", ct.DataCenters)
+	}
+
+	// the volumes have one copy each, but some are on several data nodes
+	for _, dc := range ct.DataCenters {
+		for _, rack := range dc.Racks {
+			for _, dn := range rack.DataNodes {
+				for _, v := range dn.Disks[0].Volumes {
+					if v.Id == 6 && (v.Copies != 2 || v.Health.Status != HealthWarning) {
+						t.Errorf("volume 6 on %s: expecting over replicated, but got %+v", dn.Id, v)
+					}
+				}
+			}
+		}
+	}
+
+	if len(ct.EcVolumes) != 2 {
+		t.Fatalf("unexpected ec volumes %+v", ct.EcVolumes)
+	}
+	if ct.EcVolumes[0].Id != needle.VolumeId(10) || ct.EcVolumes[0].Health.Status != HealthWarning ||
+		len(ct.EcVolumes[0].MissingShardIds) != erasure_coding.ParityShardsCount {
+		t.Errorf("unexpected ec volume %+v", ct.EcVolumes[0])
+	}
+	if ct.EcVolumes[1].Health.Status != HealthCritical {
+		t.Errorf("unexpected ec volume %+v", ct.EcVolumes[1])
+	}
+
+	if problems := ct.AllProblems(); len(problems) == 0 {
+		t.Errorf("expecting the problems listed")
+	}
+}
+
+func TestToClusterTopologyInfo(t *testing.T) {
+	topo := setup(topologyLayout)
+
+	ct := topo.ToClusterTopology()
+	info := ct.ToClusterTopologyInfo()
+	if info.Health.Status != ct.Health.Status || info.Max != ct.Max || info.Free != ct.Free {
+		t.Errorf("unexpected cluster %+v, expecting %+v", info, ct)
+	}
+	if len(info.DataCenters) != len(ct.DataCenters) || len(info.Layouts) != len(ct.Layouts) {
+		t.Fatalf("unexpected data centers %d or layouts %d", len(info.DataCenters), len(info.Layouts))
+	}
+
+	volumeCount, pbVolumeCount := 0, 0
+	for i, dc := range ct.DataCenters {
+		dcInfo := info.DataCenters[i]
+		if dcInfo.Id != dc.Id || len(dcInfo.Racks) != len(dc.Racks) {
+			t.Fatalf("unexpected data center %+v, expecting %+v", dcInfo, dc)
+		}
+		for j, rack := range dc.Racks {
+			for k, dn := range rack.DataNodes {
+				dnInfo := dcInfo.Racks[j].DataNodes[k]
+				if dnInfo.Id != dn.Id || dnInfo.Health.Status != dn.Health.Status {
+					t.Errorf("unexpected data node %+v, expecting %+v", dnInfo, dn)
+				}
+				for d, disk := range dn.Disks {
+					volumeCount += len(disk.Volumes)
+					pbVolumeCount += len(dnInfo.Disks[d].Volumes)
+					for v, vh := range disk.Volumes {
+						vInfo := dnInfo.Disks[d].Volumes[v]
+						if vInfo.Id != uint32(vh.Id) || int(vInfo.Copies) != vh.Copies || vInfo.Health.Status != vh.Health.Status {
+							t.Errorf("unexpected volume %+v, expecting %+v", vInfo, vh)
+						}
+					}
+				}
+			}
+		}
+	}
+	if volumeCount == 0 || volumeCount != pbVolumeCount {
+		t.Errorf("converted %d of %d volumes", pbVolumeCount, volumeCount)
+	}
+}
@@ -0,0 +1,72 @@
+package topology
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chrislusf/raft"
+
+	"github.com/chrislusf/seaweedfs/weed/sequence"
+)
+
+type leaseRaftServer struct {
+	raft.Server
+	name   string
+	state  string
+	leader string
+	peers  map[string]*raft.Peer
+}
+
+func (s *leaseRaftServer) Name() string                   { return s.name }
+func (s *leaseRaftServer) State() string                  { return s.state }
+func (s *leaseRaftServer) Leader() string                 { return s.leader }
+func (s *leaseRaftServer) ElectionTimeout() time.Duration { return 200 * time.Millisecond }
+func (s *leaseRaftServer) Peers() map[string]*raft.Peer   { return s.peers }
+
+func TestLeaderLease(t *testing.T) {
+	topo := NewTopology("weedfs", sequence.NewMemorySequencer(), 32*1024, 5, false)
+
+	// a single master always holds the lease
+	topo.RaftServer = &leaseRaftServer{name: "m1", state: raft.Leader, leader: "m1", peers: map[string]*raft.Peer{}}
+	topo.renewLeaderLease()
+	if !topo.IsLeader() || topo.LeaderHint() != "m1" {
+		t.Errorf("the single master should be the leader")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if topo.IsLeader() {
+		t.Errorf("the lease should expire without renewing")
+	}
+	topo.renewLeaderLease()
+
+	// the leader not heard from the other masters loses the lease
+	topo.RaftServer = &leaseRaftServer{name: "m1", state: raft.Leader, leader: "m1", peers: map[string]*raft.Peer{
+		"m2": {Name: "m2"},
+		"m3": {Name: "m3"},
+	}}
+	topo.renewLeaderLease()
+	if topo.IsLeader() {
+		t.Errorf("the leader without the lease should not serve")
+	}
+	if hint := topo.LeaderHint(); hint != "" {
+		t.Errorf("the leader without the lease should not be hinted, but got %s", hint)
+	}
+	start := time.Now()
+	if leader, err := topo.Leader(); err != nil || leader != "" {
+		t.Errorf("unexpected leader %s: %v", leader, err)
+	}
+	if time.Since(start) < 200*time.Millisecond {
+		t.Errorf("expecting to wait for the election timeout")
+	}
+
+	// the followers hint to the leader
+	topo.RaftServer = &leaseRaftServer{name: "m2", state: raft.Follower, leader: "m1", peers: map[string]*raft.Peer{
+		"m1": {Name: "m1"},
+		"m3": {Name: "m3"},
+	}}
+	if topo.IsLeader() {
+		t.Errorf("the follower should not be the leader")
+	}
+	if leader, err := topo.Leader(); err != nil || leader != "m1" || topo.LeaderHint() != "m1" {
+		t.Errorf("unexpected leader %s: %v", leader, err)
+	}
+}
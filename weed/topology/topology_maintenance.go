@@ -0,0 +1,419 @@
+package topology
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+)
+
+/*
+A data center, a rack, or a data node can be put into maintenance before powering it down.
+
+The volume slots in maintenance are not available for new volumes, and the volumes with any replica
+in maintenance are not writable, so no new writes are assigned there. The reads are not affected.
+It is safe to power down when every volume with a replica in maintenance has enough copies elsewhere,
+and every erasure coded shard in maintenance also has a copy elsewhere.
+
+The scopes in maintenance are replicated to all masters with raft, and kept in the raft snapshots,
+so they are kept when the leadership changes.
+*/
+
+// MaintenanceScope is a data center, a rack of a data center, or a data node of a rack in maintenance.
+type MaintenanceScope struct {
+	DataCenter string `json:"dataCenter"`
+	Rack       string `json:"rack,omitempty"`
+	DataNode   string `json:"dataNode,omitempty"`
+	Since      int64  `json:"since"`
+}
+
+func (s *MaintenanceScope) path() string {
+	p := s.DataCenter
+	if s.Rack != "" {
+		p += "/" + s.Rack
+		if s.DataNode != "" {
+			p += "/" + s.DataNode
+		}
+	}
+	return p
+}
+
+func (s *MaintenanceScope) String() string {
+	if s.DataNode != "" {
+		return fmt.Sprintf("data node %s", s.DataNode)
+	}
+	if s.Rack != "" {
+		return fmt.Sprintf("rack %s of data center %s", s.Rack, s.DataCenter)
+	}
+	return fmt.Sprintf("data center %s", s.DataCenter)
+}
+
+func (s *MaintenanceScope) contains(n Node) bool {
+	p := s.path()
+	nodePath := pathOf(n)
+	return nodePath == p || strings.HasPrefix(nodePath, p+"/")
+}
+
+// pathOf is the node ids from the data center down to the node, joined by "/"
+func pathOf(n Node) string {
+	var ids []string
+	for ; n != nil && n.Parent() != nil; n = n.Parent() {
+		ids = append([]string{string(n.Id())}, ids...)
+	}
+	return strings.Join(ids, "/")
+}
+
+// IsInMaintenance checks whether the node or any of its parents is in maintenance.
+func (n *NodeImpl) IsInMaintenance() bool {
+	inMaintenance, _ := n.maintenanceState()
+	return inMaintenance
+}
+
+func (n *NodeImpl) maintenanceState() (inMaintenance, maintenanceBelow bool) {
+	var root Node = n
+	for root.Parent() != nil {
+		root = root.Parent()
+	}
+	t, ok := root.GetValue().(*Topology)
+	if !ok {
+		return false, false
+	}
+	return t.maintenanceOf(pathOf(n))
+}
+
+func (t *Topology) maintenanceOf(nodePath string) (inMaintenance, maintenanceBelow bool) {
+	t.maintenanceLock.RLock()
+	defer t.maintenanceLock.RUnlock()
+	for p := range t.maintenanceScopes {
+		if nodePath == p || strings.HasPrefix(nodePath, p+"/") {
+			inMaintenance = true
+		} else if nodePath == "" || strings.HasPrefix(p, nodePath+"/") {
+			maintenanceBelow = true
+		}
+	}
+	return
+}
+
+// IsDataNodeInMaintenance checks whether the data node, its rack, or its data center is in maintenance.
+func (t *Topology) IsDataNodeInMaintenance(dataCenter, rack, dataNode string) bool {
+	inMaintenance, _ := t.maintenanceOf(dataCenter + "/" + rack + "/" + dataNode)
+	return inMaintenance
+}
+
+// EnterMaintenance puts the data center, the rack of the data center, or the data node into maintenance.
+// The data center and the rack of a data node are looked up if not specified.
+func (t *Topology) EnterMaintenance(dataCenter, rack, dataNode string) (*MaintenanceScope, error) {
+	scope, err := t.findMaintenanceScope(dataCenter, rack, dataNode)
+	if err != nil {
+		return nil, err
+	}
+
+	t.maintenanceLock.RLock()
+	existing, found := t.maintenanceScopes[scope.path()]
+	t.maintenanceLock.RUnlock()
+	if found {
+		return existing, nil
+	}
+
+	scope.Since = time.Now().Unix()
+	if err = t.doMaintenanceCommand(&MaintenanceCommand{Scope: scope}); err != nil {
+		return nil, err
+	}
+	return scope, nil
+}
+
+// ExitMaintenance takes the data center, the rack of the data center, or the data node out of maintenance,
+// and the volumes with all replicas writable become writable again.
+func (t *Topology) ExitMaintenance(dataCenter, rack, dataNode string) (*MaintenanceScope, error) {
+	scope, err := t.findMaintenanceScope(dataCenter, rack, dataNode)
+	if err != nil {
+		return nil, err
+	}
+
+	t.maintenanceLock.RLock()
+	existing, found := t.maintenanceScopes[scope.path()]
+	t.maintenanceLock.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("%s is not in maintenance", scope)
+	}
+
+	if err = t.doMaintenanceCommand(&MaintenanceCommand{Scope: existing, Exit: true}); err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+func (t *Topology) doMaintenanceCommand(c *MaintenanceCommand) error {
+	if t.RaftServer == nil {
+		t.applyMaintenance(c)
+		return nil
+	}
+	if _, err := t.RaftServer.Do(c); err != nil {
+		return fmt.Errorf("%s maintenance: %v", c.Scope, err)
+	}
+	return nil
+}
+
+func (t *Topology) applyMaintenance(c *MaintenanceCommand) {
+	scope := *c.Scope
+	t.maintenanceLock.Lock()
+	if c.Exit {
+		delete(t.maintenanceScopes, scope.path())
+	} else {
+		t.maintenanceScopes[scope.path()] = &scope
+	}
+	t.maintenanceLock.Unlock()
+
+	if c.Exit {
+		glog.V(0).Infof("%s exits maintenance", &scope)
+	} else {
+		glog.V(0).Infof("%s enters maintenance", &scope)
+	}
+	t.ensureCorrectWritablesIn(&scope)
+}
+
+// ListMaintenanceScopes returns all the scopes in maintenance, sorted by the data center, the rack and the data node.
+func (t *Topology) ListMaintenanceScopes() (scopes []*MaintenanceScope) {
+	t.maintenanceLock.RLock()
+	for _, scope := range t.maintenanceScopes {
+		copied := *scope
+		scopes = append(scopes, &copied)
+	}
+	t.maintenanceLock.RUnlock()
+	sort.Slice(scopes, func(i, j int) bool {
+		return scopes[i].path() < scopes[j].path()
+	})
+	return
+}
+
+// RecoverMaintenanceScopes replaces all the scopes in maintenance, from a raft snapshot.
+func (t *Topology) RecoverMaintenanceScopes(scopes []*MaintenanceScope) {
+	t.maintenanceLock.Lock()
+	t.maintenanceScopes = make(map[string]*MaintenanceScope)
+	for _, scope := range scopes {
+		copied := *scope
+		t.maintenanceScopes[copied.path()] = &copied
+	}
+	t.maintenanceLock.Unlock()
+}
+
+func (t *Topology) findMaintenanceScope(dataCenter, rack, dataNode string) (*MaintenanceScope, error) {
+	if dataNode != "" {
+		for _, c := range t.Children() {
+			for _, r := range c.Children() {
+				for _, n := range r.Children() {
+					if string(n.Id()) != dataNode {
+						continue
+					}
+					if dataCenter != "" && string(c.Id()) != dataCenter || rack != "" && string(r.Id()) != rack {
+						return nil, fmt.Errorf("data node %s is on rack %s of data center %s", dataNode, r.Id(), c.Id())
+					}
+					return &MaintenanceScope{DataCenter: string(c.Id()), Rack: string(r.Id()), DataNode: dataNode}, nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("data node %s not found", dataNode)
+	}
+
+	if dataCenter == "" {
+		return nil, fmt.Errorf("missing the data center, the rack, or the data node")
+	}
+	for _, c := range t.Children() {
+		if string(c.Id()) != dataCenter {
+			continue
+		}
+		if rack == "" {
+			return &MaintenanceScope{DataCenter: dataCenter}, nil
+		}
+		for _, r := range c.Children() {
+			if string(r.Id()) == rack {
+				return &MaintenanceScope{DataCenter: dataCenter, Rack: rack}, nil
+			}
+		}
+		return nil, fmt.Errorf("rack %s not found in data center %s", rack, dataCenter)
+	}
+	return nil, fmt.Errorf("data center %s not found", dataCenter)
+}
+
+// ensureCorrectWritablesIn checks the writables of the volumes with any replica in the scope
+func (t *Topology) ensureCorrectWritablesIn(scope *MaintenanceScope) {
+	for _, col := range t.collectionMap.Items() {
+		for _, layout := range col.(*Collection).storageType2VolumeLayout.Items() {
+			if layout == nil {
+				continue
+			}
+			vl := layout.(*VolumeLayout)
+			vl.accessLock.Lock()
+			for vid, locations := range vl.vid2location {
+				for _, dn := range locations.list {
+					if scope.contains(dn) {
+						vl.ensureCorrectWritables(vid)
+						break
+					}
+				}
+			}
+			vl.accessLock.Unlock()
+		}
+	}
+}
+
+// MaintenanceVolume is a volume with a replica in maintenance, and the copies outside of the maintenance.
+type MaintenanceVolume struct {
+	Id             needle.VolumeId `json:"id"`
+	Collection     string          `json:"collection"`
+	Replication    string          `json:"replication"`
+	Copies         int             `json:"copies"`
+	CopiesOutside  int             `json:"copiesOutside"`
+	ExpectedCopies int             `json:"expectedCopies"`
+}
+
+// MaintenanceEcVolume is an erasure coded volume with the shards only found in maintenance.
+type MaintenanceEcVolume struct {
+	Id         needle.VolumeId `json:"id"`
+	Collection string          `json:"collection"`
+	ShardIds   []uint32        `json:"shardIds"`
+}
+
+// MaintenanceStatus is a scope in maintenance, with the volumes not safe to power down yet.
+type MaintenanceStatus struct {
+	MaintenanceScope
+	DataNodes       []string               `json:"dataNodes"`
+	VolumeCount     int                    `json:"volumeCount"`
+	EcVolumeCount   int                    `json:"ecVolumeCount"`
+	UnsafeVolumes   []*MaintenanceVolume   `json:"unsafeVolumes,omitempty"`
+	UnsafeEcVolumes []*MaintenanceEcVolume `json:"unsafeEcVolumes,omitempty"`
+	SafeToPowerDown bool                   `json:"safeToPowerDown"`
+}
+
+// MaintenanceStatus reports all the scopes in maintenance, sorted by the data center, the rack and the data node.
+func (t *Topology) MaintenanceStatus() (statuses []*MaintenanceStatus) {
+	for _, scope := range t.ListMaintenanceScopes() {
+		statuses = append(statuses, t.maintenanceStatusOf(scope))
+	}
+	return
+}
+
+// MaintenanceStatusOf reports the maintenance of one data center, rack, or data node.
+func (t *Topology) MaintenanceStatusOf(dataCenter, rack, dataNode string) (*MaintenanceStatus, error) {
+	scope, err := t.findMaintenanceScope(dataCenter, rack, dataNode)
+	if err != nil {
+		return nil, err
+	}
+	t.maintenanceLock.RLock()
+	existing, found := t.maintenanceScopes[scope.path()]
+	t.maintenanceLock.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("%s is not in maintenance", scope)
+	}
+	return t.maintenanceStatusOf(existing), nil
+}
+
+func (t *Topology) maintenanceStatusOf(scope *MaintenanceScope) *MaintenanceStatus {
+	status := &MaintenanceStatus{
+		MaintenanceScope: *scope,
+		DataNodes:        []string{},
+	}
+	for _, c := range t.Children() {
+		for _, r := range c.Children() {
+			for _, n := range r.Children() {
+				if scope.contains(n) {
+					status.DataNodes = append(status.DataNodes, string(n.Id()))
+				}
+			}
+		}
+	}
+	sort.Strings(status.DataNodes)
+
+	for _, col := range t.collectionMap.Items() {
+		for _, layout := range col.(*Collection).storageType2VolumeLayout.Items() {
+			if layout == nil {
+				continue
+			}
+			count, unsafe := layout.(*VolumeLayout).maintenanceVolumes(scope)
+			status.VolumeCount += count
+			status.UnsafeVolumes = append(status.UnsafeVolumes, unsafe...)
+		}
+	}
+	sort.Slice(status.UnsafeVolumes, func(i, j int) bool {
+		return status.UnsafeVolumes[i].Id < status.UnsafeVolumes[j].Id
+	})
+
+	t.ecShardMapLock.RLock()
+	for vid, locations := range t.ecShardMap {
+		hasShardsInScope := false
+		var shardIds []uint32
+		for shardId, dataNodes := range locations.Locations {
+			inScope, outside := 0, 0
+			for _, dn := range dataNodes {
+				if scope.contains(dn) {
+					inScope++
+				} else {
+					outside++
+				}
+			}
+			if inScope > 0 {
+				hasShardsInScope = true
+				if outside == 0 {
+					shardIds = append(shardIds, uint32(shardId))
+				}
+			}
+		}
+		if !hasShardsInScope {
+			continue
+		}
+		status.EcVolumeCount++
+		if len(shardIds) > 0 {
+			status.UnsafeEcVolumes = append(status.UnsafeEcVolumes, &MaintenanceEcVolume{
+				Id:         vid,
+				Collection: locations.Collection,
+				ShardIds:   shardIds,
+			})
+		}
+	}
+	t.ecShardMapLock.RUnlock()
+	sort.Slice(status.UnsafeEcVolumes, func(i, j int) bool {
+		return status.UnsafeEcVolumes[i].Id < status.UnsafeEcVolumes[j].Id
+	})
+
+	status.SafeToPowerDown = len(status.UnsafeVolumes) == 0 && len(status.UnsafeEcVolumes) == 0
+	return status
+}
+
+// maintenanceVolumes counts the volumes with any replica in the scope,
+// and finds the volumes without enough copies outside of the scope
+func (vl *VolumeLayout) maintenanceVolumes(scope *MaintenanceScope) (count int, unsafe []*MaintenanceVolume) {
+	vl.accessLock.RLock()
+	defer vl.accessLock.RUnlock()
+	for vid, locations := range vl.vid2location {
+		inScope, outside := 0, 0
+		collection := ""
+		for _, dn := range locations.list {
+			if v, err := dn.GetVolumesById(vid); err == nil {
+				collection = v.Collection
+			}
+			if scope.contains(dn) {
+				inScope++
+			} else {
+				outside++
+			}
+		}
+		if inScope == 0 {
+			continue
+		}
+		count++
+		if outside < vl.rp.GetCopyCount() {
+			unsafe = append(unsafe, &MaintenanceVolume{
+				Id:             vid,
+				Collection:     collection,
+				Replication:    vl.rp.String(),
+				Copies:         locations.Length(),
+				CopiesOutside:  outside,
+				ExpectedCopies: vl.rp.GetCopyCount(),
+			})
+		}
+	}
+	return
+}
@@ -0,0 +1,223 @@
+package topology
+
+import (
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/storage"
+	"github.com/chrislusf/seaweedfs/weed/storage/erasure_coding"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+	"github.com/chrislusf/seaweedfs/weed/storage/super_block"
+)
+
+var maintenanceLayout = `
+{
+  "dc1":{
+    "rack1":{
+      "server111":{
+        "volumes":[
+          {"id":1, "size":12312},
+          {"id":2, "size":12312}
+        ],
+        "limit":5
+      },
+      "server112":{
+        "volumes":[
+          {"id":1, "size":12312},
+          {"id":3, "size":12312}
+        ],
+        "limit":5
+      }
+    },
+    "rack2":{
+      "server121":{
+        "volumes":[
+          {"id":2, "size":12312}
+        ],
+        "limit":5
+      }
+    }
+  },
+  "dc2":{
+    "rack1":{
+      "server211":{
+        "volumes":[
+          {"id":3, "size":12312}
+        ],
+        "limit":5
+      }
+    }
+  }
+}
+`
+
+func setupMaintenance(t *testing.T) (topo *Topology, layouts map[needle.VolumeId]*VolumeLayout, dataNodes map[string]*DataNode) {
+	topo = setup(maintenanceLayout)
+	replications := map[needle.VolumeId]string{1: "001", 2: "010", 3: "100"}
+	layouts = make(map[needle.VolumeId]*VolumeLayout)
+	dataNodes = make(map[string]*DataNode)
+	port := 8080
+	for _, c := range topo.Children() {
+		for _, r := range c.Children() {
+			for _, n := range r.Children() {
+				dn := n.(*DataNode)
+				// the volume locations tell the data nodes apart by the address
+				dn.Ip, dn.Port = "localhost", port
+				port++
+				dataNodes[string(dn.Id())] = dn
+				for _, v := range dn.GetVolumes() {
+					rp, err := super_block.NewReplicaPlacementFromString(replications[v.Id])
+					if err != nil {
+						t.Fatal(err)
+					}
+					v.ReplicaPlacement = rp
+					dn.AddOrUpdateVolume(v)
+					topo.RegisterVolumeLayout(v, dn)
+					layouts[v.Id] = topo.GetVolumeLayout("", rp, v.Ttl, "")
+				}
+			}
+		}
+	}
+	return
+}
+
+func isWritableVolume(vl *VolumeLayout, vid needle.VolumeId) bool {
+	for _, writable := range vl.writables {
+		if writable == vid {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDataNodeMaintenance(t *testing.T) {
+	topo, layouts, dataNodes := setupMaintenance(t)
+	for vid, vl := range layouts {
+		if !isWritableVolume(vl, vid) {
+			t.Fatalf("volume %d should be writable before the maintenance", vid)
+		}
+	}
+
+	scope, err := topo.EnterMaintenance("", "", "server112")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scope.DataCenter != "dc1" || scope.Rack != "rack1" || scope.DataNode != "server112" {
+		t.Errorf("unexpected scope %+v", scope)
+	}
+
+	if !dataNodes["server112"].IsInMaintenance() || dataNodes["server111"].IsInMaintenance() {
+		t.Errorf("only server112 should be in maintenance")
+	}
+	option := &VolumeGrowOption{}
+	if free := dataNodes["server112"].AvailableSpaceFor(option); free != 0 {
+		t.Errorf("server112 in maintenance has %d free slots", free)
+	}
+	if free := topo.GetOrCreateDataCenter("dc1").GetOrCreateRack("rack1").AvailableSpaceFor(option); free != 3 {
+		t.Errorf("expecting 3 free slots on rack1 of dc1, but got %d", free)
+	}
+	if free := topo.GetOrCreateDataCenter("dc1").AvailableSpaceFor(option); free != 7 {
+		t.Errorf("expecting 7 free slots on dc1, but got %d", free)
+	}
+
+	if isWritableVolume(layouts[1], 1) || isWritableVolume(layouts[3], 3) {
+		t.Errorf("the volumes with a replica in maintenance should not be writable")
+	}
+	if !isWritableVolume(layouts[2], 2) {
+		t.Errorf("volume 2 should still be writable")
+	}
+
+	status, err := topo.MaintenanceStatusOf("dc1", "rack1", "server112")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.VolumeCount != 2 || len(status.UnsafeVolumes) != 2 || status.SafeToPowerDown {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if len(status.DataNodes) != 1 || status.DataNodes[0] != "server112" {
+		t.Errorf("unexpected data nodes %+v", status.DataNodes)
+	}
+
+	if _, err = topo.ExitMaintenance("", "", "server112"); err != nil {
+		t.Fatal(err)
+	}
+	for vid, vl := range layouts {
+		if !isWritableVolume(vl, vid) {
+			t.Errorf("volume %d should be writable after the maintenance", vid)
+		}
+	}
+	if len(topo.MaintenanceStatus()) != 0 {
+		t.Errorf("expecting no maintenance")
+	}
+	if _, err = topo.ExitMaintenance("", "", "server112"); err == nil {
+		t.Errorf("expecting error to exit the maintenance twice")
+	}
+}
+
+func TestRackMaintenance(t *testing.T) {
+	topo, layouts, dataNodes := setupMaintenance(t)
+
+	if _, err := topo.EnterMaintenance("", "rack1", ""); err == nil {
+		t.Errorf("expecting error for the rack without the data center")
+	}
+	if _, err := topo.EnterMaintenance("dc1", "rack3", ""); err == nil {
+		t.Errorf("expecting error for the unknown rack")
+	}
+	if _, err := topo.EnterMaintenance("dc1", "rack1", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// the rack of the same name in another data center is not affected
+	if dataNodes["server211"].IsInMaintenance() || !dataNodes["server111"].IsInMaintenance() {
+		t.Errorf("only rack1 of dc1 should be in maintenance")
+	}
+	if free := topo.AvailableSpaceFor(&VolumeGrowOption{}); free != 8 {
+		t.Errorf("expecting 8 free slots, but got %d", free)
+	}
+	for vid, vl := range layouts {
+		if isWritableVolume(vl, vid) {
+			t.Errorf("volume %d with a replica in maintenance should not be writable", vid)
+		}
+	}
+
+	// the heartbeats do not make the volumes writable again
+	v, _ := dataNodes["server111"].GetVolumesById(2)
+	topo.RegisterVolumeLayout(v, dataNodes["server111"])
+	if isWritableVolume(layouts[2], 2) {
+		t.Errorf("volume 2 should not be writable after the heartbeat")
+	}
+
+	// volume 2 has enough copies outside of rack1 after copying it to server211
+	v.ReplicaPlacement, _ = super_block.NewReplicaPlacementFromString("010")
+	dataNodes["server211"].AddOrUpdateVolume(v)
+	layouts[2].RegisterVolume(&v, dataNodes["server211"])
+
+	var shardBits erasure_coding.ShardBits
+	for shardId := 0; shardId < erasure_coding.TotalShardsCount; shardId++ {
+		shardBits = shardBits.AddShardId(erasure_coding.ShardId(shardId))
+	}
+	topo.RegisterEcShards(erasure_coding.NewEcVolumeInfo("", "", 10, shardBits, erasure_coding.DefaultEcLayout), dataNodes["server111"])
+
+	statuses := topo.MaintenanceStatus()
+	if len(statuses) != 1 {
+		t.Fatalf("unexpected statuses %+v", statuses)
+	}
+	status := statuses[0]
+	if status.VolumeCount != 3 || len(status.UnsafeVolumes) != 2 || status.UnsafeVolumes[0].Id != 1 || status.UnsafeVolumes[1].Id != 3 {
+		t.Errorf("unexpected unsafe volumes %+v", status.UnsafeVolumes)
+	}
+	if status.EcVolumeCount != 1 || len(status.UnsafeEcVolumes) != 1 || len(status.UnsafeEcVolumes[0].ShardIds) != erasure_coding.TotalShardsCount {
+		t.Errorf("unexpected unsafe ec volumes %+v", status.UnsafeEcVolumes)
+	}
+
+	topo.RegisterEcShards(erasure_coding.NewEcVolumeInfo("", "", 10, shardBits, erasure_coding.DefaultEcLayout), dataNodes["server121"])
+	for _, vid := range []needle.VolumeId{1, 3} {
+		for _, dn := range []*DataNode{dataNodes["server121"], dataNodes["server211"]} {
+			v := storage.VolumeInfo{Id: vid, Size: 12312, Version: needle.CurrentVersion, ReplicaPlacement: layouts[vid].rp}
+			dn.AddOrUpdateVolume(v)
+			layouts[vid].RegisterVolume(&v, dn)
+		}
+	}
+	if status = topo.MaintenanceStatus()[0]; !status.SafeToPowerDown {
+		t.Errorf("expecting safe to power down, but got %+v", status)
+	}
+}
@@ -0,0 +1,226 @@
+package topology
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/chrislusf/seaweedfs/weed/storage/super_block"
+	"github.com/chrislusf/seaweedfs/weed/storage/types"
+)
+
+/*
+The capacity planner forecasts the volume slots used by the expected growth of the collections.
+
+The data written to a collection first fills the room left in its writable volumes, then needs new volumes,
+each taking one slot per copy on the disk type of the collection. For each disk type, the forecast is
+when the free slots run out, and how many slots are missing within the planning horizon.
+The recommended volumes to grow now for each collection are the volumes needed within the horizon,
+scaled down if the disk type does not have enough free slots for all collections.
+*/
+
+// CollectionGrowth is the expected bytes written to a collection per day, with the replication and the disk type.
+type CollectionGrowth struct {
+	Collection  string `json:"collection"`
+	Replication string `json:"replication"`
+	DiskType    string `json:"diskType"`
+	BytesPerDay uint64 `json:"bytesPerDay"`
+}
+
+// ParseCollectionGrowth parses "<collection>:<replication>:<disk type>:<size per day>", e.g. "logs:010:hdd:50GiB".
+// An empty replication is the default replication, and an empty disk type is the hard drive.
+func ParseCollectionGrowth(spec string, defaultReplication string) (*CollectionGrowth, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("expecting <collection>:<replication>:<disk type>:<size per day>, but got %s", spec)
+	}
+	g := &CollectionGrowth{
+		Collection:  parts[0],
+		Replication: parts[1],
+		DiskType:    string(types.ToDiskType(parts[2])),
+	}
+	if g.Replication == "" {
+		g.Replication = defaultReplication
+	}
+	if _, err := super_block.NewReplicaPlacementFromString(g.Replication); err != nil {
+		return nil, fmt.Errorf("growth %s: %v", spec, err)
+	}
+	bytesPerDay, err := humanize.ParseBytes(parts[3])
+	if err != nil {
+		return nil, fmt.Errorf("growth %s: %v", spec, err)
+	}
+	g.BytesPerDay = bytesPerDay
+	return g, nil
+}
+
+// CollectionPlan is the forecast of one collection growth.
+// DaysUntilNewVolumes is how long the room in the writable volumes lasts, -1 if the collection does not grow.
+type CollectionPlan struct {
+	CollectionGrowth
+	Copies              int     `json:"copies"`
+	WritableVolumes     int     `json:"writableVolumes"`
+	WritableRoom        uint64  `json:"writableRoom"`
+	DaysUntilNewVolumes float64 `json:"daysUntilNewVolumes"`
+	VolumesPerDay       float64 `json:"volumesPerDay"`
+	VolumesNeeded       int64   `json:"volumesNeeded"`
+	RecommendedVolumes  int64   `json:"recommendedVolumes"`
+}
+
+// TierPlan is the forecast of the volume slots of one disk type.
+// DaysUntilFull is when the free slots run out, -1 if not within 100 years.
+type TierPlan struct {
+	DiskType      string  `json:"diskType"`
+	FreeSlots     int64   `json:"freeSlots"`
+	SlotsPerDay   float64 `json:"slotsPerDay"`
+	SlotsNeeded   int64   `json:"slotsNeeded"`
+	DaysUntilFull int     `json:"daysUntilFull"`
+	Shortage      int64   `json:"shortage"`
+}
+
+type CapacityPlan struct {
+	HorizonDays     int               `json:"horizonDays"`
+	VolumeSizeLimit uint64            `json:"volumeSizeLimit"`
+	Tiers           []*TierPlan       `json:"tiers"`
+	Collections     []*CollectionPlan `json:"collections"`
+}
+
+// PlanCapacity forecasts the growths with the free slots and the writable volumes from the latest heartbeats.
+func (t *Topology) PlanCapacity(growths []*CollectionGrowth, horizonDays int) (*CapacityPlan, error) {
+	freeSlots := make(map[string]int64)
+	var collections []*CollectionPlan
+	for _, g := range growths {
+		rp, err := super_block.NewReplicaPlacementFromString(g.Replication)
+		if err != nil {
+			return nil, err
+		}
+		cp := &CollectionPlan{
+			CollectionGrowth: *g,
+			Copies:           rp.GetCopyCount(),
+		}
+		if c, found := t.FindCollection(g.Collection); found {
+			for _, layout := range c.storageType2VolumeLayout.Items() {
+				if vl, ok := layout.(*VolumeLayout); ok && vl.rp.String() == rp.String() && string(vl.diskType) == g.DiskType {
+					count, room := vl.writableRoom()
+					cp.WritableVolumes += count
+					cp.WritableRoom += room
+				}
+			}
+		}
+		if _, found := freeSlots[g.DiskType]; !found {
+			freeSlots[g.DiskType] = t.AvailableSpaceFor(&VolumeGrowOption{DiskType: types.DiskType(g.DiskType)})
+		}
+		collections = append(collections, cp)
+	}
+	return planCapacity(t.volumeSizeLimit, freeSlots, collections, horizonDays), nil
+}
+
+// writableRoom is the number of the writable volumes, and the bytes left in them before reaching the size limit
+func (vl *VolumeLayout) writableRoom() (count int, room uint64) {
+	vl.accessLock.RLock()
+	defer vl.accessLock.RUnlock()
+	for _, vid := range vl.writables {
+		locations, found := vl.vid2location[vid]
+		if !found || locations.Length() == 0 {
+			continue
+		}
+		v, err := locations.Head().GetVolumesById(vid)
+		if err != nil {
+			continue
+		}
+		count++
+		if v.Size < vl.volumeSizeLimit {
+			room += vl.volumeSizeLimit - v.Size
+		}
+	}
+	return
+}
+
+const planMaxDays = 36500
+
+func planCapacity(volumeSizeLimit uint64, freeSlots map[string]int64, collections []*CollectionPlan, horizonDays int) *CapacityPlan {
+	plan := &CapacityPlan{
+		HorizonDays:     horizonDays,
+		VolumeSizeLimit: volumeSizeLimit,
+		Collections:     collections,
+	}
+
+	tiers := make(map[string]*TierPlan)
+	for _, cp := range collections {
+		cp.DaysUntilNewVolumes = -1
+		if cp.BytesPerDay > 0 {
+			cp.DaysUntilNewVolumes = float64(cp.WritableRoom) / float64(cp.BytesPerDay)
+		}
+		cp.VolumesPerDay = float64(cp.BytesPerDay) / float64(volumeSizeLimit)
+		cp.VolumesNeeded = cp.volumesAfter(float64(horizonDays), volumeSizeLimit)
+
+		tier, found := tiers[cp.DiskType]
+		if !found {
+			tier = &TierPlan{DiskType: cp.DiskType, FreeSlots: freeSlots[cp.DiskType]}
+			tiers[cp.DiskType] = tier
+			plan.Tiers = append(plan.Tiers, tier)
+		}
+		tier.SlotsPerDay += cp.VolumesPerDay * float64(cp.Copies)
+		tier.SlotsNeeded += cp.VolumesNeeded * int64(cp.Copies)
+	}
+
+	for _, tier := range plan.Tiers {
+		if tier.SlotsNeeded > tier.FreeSlots {
+			tier.Shortage = tier.SlotsNeeded - tier.FreeSlots
+		}
+		var tierCollections []*CollectionPlan
+		for _, cp := range collections {
+			if cp.DiskType == tier.DiskType {
+				tierCollections = append(tierCollections, cp)
+			}
+		}
+		tier.DaysUntilFull = daysUntilFull(tier.FreeSlots, tierCollections, volumeSizeLimit)
+		for _, cp := range tierCollections {
+			cp.RecommendedVolumes = cp.VolumesNeeded
+			if tier.Shortage > 0 && tier.FreeSlots > 0 {
+				cp.RecommendedVolumes = cp.VolumesNeeded * tier.FreeSlots / tier.SlotsNeeded
+			} else if tier.Shortage > 0 {
+				cp.RecommendedVolumes = 0
+			}
+		}
+	}
+
+	sort.Slice(plan.Tiers, func(i, j int) bool {
+		return plan.Tiers[i].DiskType < plan.Tiers[j].DiskType
+	})
+	return plan
+}
+
+// volumesAfter is the number of new volumes needed for the growth after the days
+func (cp *CollectionPlan) volumesAfter(days float64, volumeSizeLimit uint64) int64 {
+	overflow := float64(cp.BytesPerDay)*days - float64(cp.WritableRoom)
+	if overflow <= 0 {
+		return 0
+	}
+	return int64(math.Ceil(overflow / float64(volumeSizeLimit)))
+}
+
+// daysUntilFull searches the first day when the new volumes of the collections need more slots than the free slots
+func daysUntilFull(freeSlots int64, collections []*CollectionPlan, volumeSizeLimit uint64) int {
+	slotsAfter := func(days float64) (slots int64) {
+		for _, cp := range collections {
+			slots += cp.volumesAfter(days, volumeSizeLimit) * int64(cp.Copies)
+		}
+		return
+	}
+	if slotsAfter(planMaxDays) <= freeSlots {
+		return -1
+	}
+	low, high := 0, planMaxDays
+	for low < high {
+		mid := (low + high) / 2
+		if slotsAfter(float64(mid)) > freeSlots {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+	return low
+}
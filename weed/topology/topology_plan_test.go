@@ -0,0 +1,53 @@
+package topology
+
+import (
+	"testing"
+)
+
+func TestParseCollectionGrowth(t *testing.T) {
+	g, err := ParseCollectionGrowth("logs::hdd:1KiB", "010")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if g.Collection != "logs" || g.Replication != "010" || g.DiskType != "" || g.BytesPerDay != 1024 {
+		t.Errorf("unexpected growth %+v", g)
+	}
+	for _, spec := range []string{"logs:010:hdd", "logs:030::1GiB", "logs:010::many"} {
+		if _, err := ParseCollectionGrowth(spec, "000"); err == nil {
+			t.Errorf("growth %s should be invalid", spec)
+		}
+	}
+}
+
+func TestPlanCapacity(t *testing.T) {
+	collections := []*CollectionPlan{
+		{CollectionGrowth: CollectionGrowth{Collection: "a", BytesPerDay: 100}, Copies: 2, WritableRoom: 50},
+		{CollectionGrowth: CollectionGrowth{Collection: "b", DiskType: "ssd", BytesPerDay: 10}, Copies: 1, WritableRoom: 1000},
+		{CollectionGrowth: CollectionGrowth{Collection: "c", DiskType: "ssd"}, Copies: 1},
+	}
+	plan := planCapacity(100, map[string]int64{"": 10, "ssd": 5}, collections, 10)
+
+	if len(plan.Tiers) != 2 {
+		t.Fatalf("unexpected tiers %+v", plan.Tiers)
+	}
+	hdd, ssd := plan.Tiers[0], plan.Tiers[1]
+	// 950 bytes need 10 new volumes with 2 copies
+	if hdd.SlotsNeeded != 20 || hdd.Shortage != 10 || hdd.SlotsPerDay != 2 {
+		t.Errorf("unexpected hdd tier %+v", hdd)
+	}
+	// 2 * 6 slots after 550 bytes over the room on day 6
+	if hdd.DaysUntilFull != 6 {
+		t.Errorf("expecting hdd full in 6 days, but got %d", hdd.DaysUntilFull)
+	}
+	if collections[0].VolumesNeeded != 10 || collections[0].RecommendedVolumes != 5 || collections[0].DaysUntilNewVolumes != 0.5 {
+		t.Errorf("unexpected collection plan %+v", collections[0])
+	}
+
+	// the room lasts for 100 days, then 1 slot per 10 days
+	if ssd.SlotsNeeded != 0 || ssd.Shortage != 0 || ssd.DaysUntilFull != 151 {
+		t.Errorf("unexpected ssd tier %+v", ssd)
+	}
+	if collections[1].RecommendedVolumes != 0 || collections[2].DaysUntilNewVolumes != -1 {
+		t.Errorf("unexpected collection plans %+v %+v", collections[1], collections[2])
+	}
+}
@@ -208,3 +208,46 @@ func TestAddRemoveVolume(t *testing.T) {
 	}
 
 }
+
+func TestTopologyCapacity(t *testing.T) {
+	topo := NewTopology("weedfs", sequence.NewMemorySequencer(), 32*1024, 5, false)
+	rack := topo.GetOrCreateDataCenter("dc1").GetOrCreateRack("rack1")
+	maxVolumeCounts := map[string]uint32{"": 25}
+	dn1 := rack.GetOrCreateDataNode("127.0.0.1", 34534, "127.0.0.1", maxVolumeCounts)
+	dn2 := rack.GetOrCreateDataNode("127.0.0.2", 34534, "127.0.0.2", maxVolumeCounts)
+
+	volumeMessage := func(id uint32, collection string, size, deletedBytes uint64, fileCount, deleteCount uint64) *master_pb.VolumeInformationMessage {
+		return &master_pb.VolumeInformationMessage{
+			Id:               id,
+			Collection:       collection,
+			Size:             size,
+			DeletedByteCount: deletedBytes,
+			FileCount:        fileCount,
+			DeleteCount:      deleteCount,
+			ReplicaPlacement: uint32(1),
+			Version:          uint32(needle.CurrentVersion),
+		}
+	}
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{
+		volumeMessage(1, "", 1000, 200, 10, 2),
+		volumeMessage(2, "pictures", 5000, 0, 50, 0),
+	}, dn1)
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{
+		volumeMessage(1, "", 1000, 100, 10, 1),
+		volumeMessage(2, "pictures", 5000, 0, 50, 0),
+	}, dn2)
+
+	total, collections := topo.ToCapacity()
+
+	assert(t, "volumeCount", total.VolumeCount, 2)
+	assert(t, "replicaCount", total.ReplicaCount, 4)
+	assert(t, "physicalSize", int(total.PhysicalSize), 12000)
+	assert(t, "reclaimableSize", int(total.ReclaimableSize), 300)
+	assert(t, "logicalSize", int(total.LogicalSize), 900+5000)
+	assert(t, "fileCount", int(total.FileCount), 9+50)
+
+	assert(t, "collections", len(collections), 2)
+	assert(t, "default logicalSize", int(collections[0].LogicalSize), 900)
+	assert(t, "pictures physicalSize", int(collections[1].PhysicalSize), 10000)
+	assert(t, "pictures reclaimableSize", int(collections[1].ReclaimableSize), 0)
+}
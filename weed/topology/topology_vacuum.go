@@ -12,8 +12,24 @@ import (
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/operation"
 	"github.com/chrislusf/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
 )
 
+// VacuumSchedule limits the automatic vacuum to the maintenance windows, and throttles the compaction.
+type VacuumSchedule struct {
+	Windows                 util.TimeWindows // empty to vacuum at any time
+	CompactionBytePerSecond int64            // 0 to use the limit of each volume server
+}
+
+// SetVacuumSchedule should be called before StartRefreshWritableVolumes
+func (t *Topology) SetVacuumSchedule(schedule VacuumSchedule) {
+	t.vacuumSchedule = schedule
+}
+
+func (t *Topology) GetVacuumSchedule() VacuumSchedule {
+	return t.vacuumSchedule
+}
+
 func (t *Topology) batchVacuumVolumeCheck(grpcDialOption grpc.DialOption, vid needle.VolumeId,
 	locationlist *VolumeLocationList, garbageThreshold float64) (*VolumeLocationList, bool) {
 	ch := make(chan int, locationlist.Length())
@@ -59,7 +75,7 @@ func (t *Topology) batchVacuumVolumeCheck(grpcDialOption grpc.DialOption, vid ne
 	return vacuumLocationList, errCount == 0 && len(vacuumLocationList.list) > 0
 }
 func (t *Topology) batchVacuumVolumeCompact(grpcDialOption grpc.DialOption, vl *VolumeLayout, vid needle.VolumeId,
-	locationlist *VolumeLocationList, preallocate int64) bool {
+	locationlist *VolumeLocationList, preallocate int64, compactionBytePerSecond int64) bool {
 	vl.accessLock.Lock()
 	vl.removeFromWritable(vid)
 	vl.accessLock.Unlock()
@@ -70,8 +86,9 @@ func (t *Topology) batchVacuumVolumeCompact(grpcDialOption grpc.DialOption, vl *
 			glog.V(0).Infoln(index, "Start vacuuming", vid, "on", url)
 			err := operation.WithVolumeServerClient(url, grpcDialOption, func(volumeServerClient volume_server_pb.VolumeServerClient) error {
 				_, err := volumeServerClient.VacuumVolumeCompact(context.Background(), &volume_server_pb.VacuumVolumeCompactRequest{
-					VolumeId:    uint32(vid),
-					Preallocate: preallocate,
+					VolumeId:                uint32(vid),
+					Preallocate:             preallocate,
+					CompactionBytePerSecond: compactionBytePerSecond,
 				})
 				return err
 			})
@@ -144,7 +161,13 @@ func (t *Topology) batchVacuumVolumeCleanup(grpcDialOption grpc.DialOption, vl *
 	}
 }
 
+// Vacuum compacts the volumes over the garbageThreshold now, regardless of the vacuum windows.
 func (t *Topology) Vacuum(grpcDialOption grpc.DialOption, garbageThreshold float64, preallocate int64) {
+	t.vacuum(grpcDialOption, garbageThreshold, preallocate, nil)
+}
+
+// vacuum stops vacuuming more volumes once out of the windows. The volume being vacuumed is always completed.
+func (t *Topology) vacuum(grpcDialOption grpc.DialOption, garbageThreshold float64, preallocate int64, windows util.TimeWindows) {
 
 	// if there is vacuum going on, return immediately
 	swapped := atomic.CompareAndSwapInt64(&t.vacuumLockCounter, 0, 1)
@@ -161,13 +184,16 @@ func (t *Topology) Vacuum(grpcDialOption grpc.DialOption, garbageThreshold float
 		for _, vl := range c.storageType2VolumeLayout.Items() {
 			if vl != nil {
 				volumeLayout := vl.(*VolumeLayout)
-				t.vacuumOneVolumeLayout(grpcDialOption, volumeLayout, c, garbageThreshold, preallocate)
+				if !t.vacuumOneVolumeLayout(grpcDialOption, volumeLayout, c, garbageThreshold, preallocate, windows) {
+					glog.V(0).Infof("Stop vacuum out of the vacuum windows %s", windows)
+					return
+				}
 			}
 		}
 	}
 }
 
-func (t *Topology) vacuumOneVolumeLayout(grpcDialOption grpc.DialOption, volumeLayout *VolumeLayout, c *Collection, garbageThreshold float64, preallocate int64) {
+func (t *Topology) vacuumOneVolumeLayout(grpcDialOption grpc.DialOption, volumeLayout *VolumeLayout, c *Collection, garbageThreshold float64, preallocate int64, windows util.TimeWindows) bool {
 
 	volumeLayout.accessLock.RLock()
 	tmpMap := make(map[needle.VolumeId]*VolumeLocationList)
@@ -176,8 +202,14 @@ func (t *Topology) vacuumOneVolumeLayout(grpcDialOption grpc.DialOption, volumeL
 	}
 	volumeLayout.accessLock.RUnlock()
 
+	compactionBytePerSecond := t.GetVacuumSchedule().CompactionBytePerSecond
+
 	for vid, locationList := range tmpMap {
 
+		if !windows.Contains(time.Now()) {
+			return false
+		}
+
 		volumeLayout.accessLock.RLock()
 		isReadOnly := volumeLayout.readonlyVolumes.IsTrue(vid)
 		volumeLayout.accessLock.RUnlock()
@@ -188,11 +220,12 @@ func (t *Topology) vacuumOneVolumeLayout(grpcDialOption grpc.DialOption, volumeL
 
 		glog.V(2).Infof("check vacuum on collection:%s volume:%d", c.Name, vid)
 		if vacuumLocationList, needVacuum := t.batchVacuumVolumeCheck(grpcDialOption, vid, locationList, garbageThreshold); needVacuum {
-			if t.batchVacuumVolumeCompact(grpcDialOption, volumeLayout, vid, vacuumLocationList, preallocate) {
+			if t.batchVacuumVolumeCompact(grpcDialOption, volumeLayout, vid, vacuumLocationList, preallocate, compactionBytePerSecond) {
 				t.batchVacuumVolumeCommit(grpcDialOption, volumeLayout, vid, vacuumLocationList)
 			} else {
 				t.batchVacuumVolumeCleanup(grpcDialOption, volumeLayout, vid, vacuumLocationList)
 			}
 		}
 	}
+	return true
 }
@@ -4,7 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/chrislusf/seaweedfs/weed/storage/types"
-	"math/rand"
+	"strings"
 	"sync"
 
 	"github.com/chrislusf/seaweedfs/weed/storage/needle"
@@ -38,7 +38,9 @@ type VolumeGrowOption struct {
 }
 
 type VolumeGrowth struct {
-	accessLock sync.Mutex
+	accessLock       sync.Mutex
+	defaultPlacement PlacementStrategy
+	placements       map[string]PlacementStrategy
 }
 
 func (o *VolumeGrowOption) String() string {
@@ -85,7 +87,14 @@ func (vg *VolumeGrowth) GrowByCountAndType(grpcDialOption grpc.DialOption, targe
 	vg.accessLock.Lock()
 	defer vg.accessLock.Unlock()
 
+	if err = topo.CheckClusterWritable(); err != nil {
+		return 0, err
+	}
 	for i := 0; i < targetCount; i++ {
+		if e := topo.checkCollectionVolumeLimit(option.Collection); e != nil {
+			glog.V(0).Infof("create %d volume, created %d: %v", targetCount, counter, e)
+			return counter, e
+		}
 		if c, e := vg.findAndGrow(grpcDialOption, topo, option); e == nil {
 			counter += c
 		} else {
@@ -109,107 +118,48 @@ func (vg *VolumeGrowth) findAndGrow(grpcDialOption grpc.DialOption, topo *Topolo
 	return len(servers), err
 }
 
-// 1. find the main data node
-// 1.1 collect all data nodes that have 1 slots
-// 2.2 collect all racks that have rp.SameRackCount+1
-// 2.2 collect all data centers that have DiffRackCount+rp.SameRackCount+1
-// 2. find rest data nodes
+// findEmptySlotsForOneVolume picks the data nodes by the placement strategy of the collection,
+// or by the replication if the strategy does not satisfy the replica placement
 func (vg *VolumeGrowth) findEmptySlotsForOneVolume(topo *Topology, option *VolumeGrowOption) (servers []*DataNode, err error) {
-	//find main datacenter and other data centers
-	rp := option.ReplicaPlacement
-	mainDataCenter, otherDataCenters, dc_err := topo.PickNodesByWeight(rp.DiffDataCenterCount+1, option, func(node Node) error {
-		if option.DataCenter != "" && node.IsDataCenter() && node.Id() != NodeId(option.DataCenter) {
-			return fmt.Errorf("Not matching preferred data center:%s", option.DataCenter)
-		}
-		if len(node.Children()) < rp.DiffRackCount+1 {
-			return fmt.Errorf("Only has %d racks, not enough for %d.", len(node.Children()), rp.DiffRackCount+1)
-		}
-		if node.AvailableSpaceFor(option) < int64(rp.DiffRackCount+rp.SameRackCount+1) {
-			return fmt.Errorf("Free:%d < Expected:%d", node.AvailableSpaceFor(option), rp.DiffRackCount+rp.SameRackCount+1)
-		}
-		possibleRacksCount := 0
-		for _, rack := range node.Children() {
-			possibleDataNodesCount := 0
-			for _, n := range rack.Children() {
-				if n.AvailableSpaceFor(option) >= 1 {
-					possibleDataNodesCount++
-				}
-			}
-			if possibleDataNodesCount >= rp.SameRackCount+1 {
-				possibleRacksCount++
-			}
-		}
-		if possibleRacksCount < rp.DiffRackCount+1 {
-			return fmt.Errorf("Only has %d racks with more than %d free data nodes, not enough for %d.", possibleRacksCount, rp.SameRackCount+1, rp.DiffRackCount+1)
-		}
-		return nil
-	})
-	if dc_err != nil {
-		return nil, dc_err
+	strategy := vg.placementStrategyOf(option.Collection)
+	if !strategy.Satisfies(option.ReplicaPlacement) {
+		strategy = &replicationPlacement{}
 	}
+	return strategy.PickNodes(topo, option)
+}
 
-	//find main rack and other racks
-	mainRack, otherRacks, rackErr := mainDataCenter.(*DataCenter).PickNodesByWeight(rp.DiffRackCount+1, option, func(node Node) error {
-		if option.Rack != "" && node.IsRack() && node.Id() != NodeId(option.Rack) {
-			return fmt.Errorf("Not matching preferred rack:%s", option.Rack)
-		}
-		if node.AvailableSpaceFor(option) < int64(rp.SameRackCount+1) {
-			return fmt.Errorf("Free:%d < Expected:%d", node.AvailableSpaceFor(option), rp.SameRackCount+1)
-		}
-		if len(node.Children()) < rp.SameRackCount+1 {
-			// a bit faster way to test free racks
-			return fmt.Errorf("Only has %d data nodes, not enough for %d.", len(node.Children()), rp.SameRackCount+1)
-		}
-		possibleDataNodesCount := 0
-		for _, n := range node.Children() {
-			if n.AvailableSpaceFor(option) >= 1 {
-				possibleDataNodesCount++
-			}
-		}
-		if possibleDataNodesCount < rp.SameRackCount+1 {
-			return fmt.Errorf("Only has %d data nodes with a slot, not enough for %d.", possibleDataNodesCount, rp.SameRackCount+1)
-		}
-		return nil
-	})
-	if rackErr != nil {
-		return nil, rackErr
+// LoadPlacementStrategies reads the placement strategies of the collections from the "master.placement" configuration
+func (vg *VolumeGrowth) LoadPlacementStrategies() error {
+	v := util.GetViper()
+	v.SetDefault("master.placement.default", DefaultPlacementStrategy)
+	defaultPlacement, err := NewPlacementStrategy(v.GetString("master.placement.default"))
+	if err != nil {
+		return fmt.Errorf("master.placement.default: %v", err)
 	}
-
-	//find main rack and other racks
-	mainServer, otherServers, serverErr := mainRack.(*Rack).PickNodesByWeight(rp.SameRackCount+1, option, func(node Node) error {
-		if option.DataNode != "" && node.IsDataNode() && node.Id() != NodeId(option.DataNode) {
-			return fmt.Errorf("Not matching preferred data node:%s", option.DataNode)
+	placements := make(map[string]PlacementStrategy)
+	for _, collectionSpec := range v.GetStringSlice("master.placement.collections") {
+		parts := strings.SplitN(collectionSpec, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("master.placement.collections: expecting <collection>=<strategy>, but got %s", collectionSpec)
 		}
-		if node.AvailableSpaceFor(option) < 1 {
-			return fmt.Errorf("Free:%d < Expected:%d", node.AvailableSpaceFor(option), 1)
+		collection, spec := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if placements[collection], err = NewPlacementStrategy(spec); err != nil {
+			return fmt.Errorf("placement of collection %s: %v", collection, err)
 		}
-		return nil
-	})
-	if serverErr != nil {
-		return nil, serverErr
+		glog.V(0).Infof("collection %s placement strategy: %s", collection, spec)
 	}
+	vg.defaultPlacement, vg.placements = defaultPlacement, placements
+	return nil
+}
 
-	servers = append(servers, mainServer.(*DataNode))
-	for _, server := range otherServers {
-		servers = append(servers, server.(*DataNode))
+func (vg *VolumeGrowth) placementStrategyOf(collection string) PlacementStrategy {
+	if placement, found := vg.placements[collection]; found {
+		return placement
 	}
-	for _, rack := range otherRacks {
-		r := rand.Int63n(rack.AvailableSpaceFor(option))
-		if server, e := rack.ReserveOneVolume(r, option); e == nil {
-			servers = append(servers, server)
-		} else {
-			return servers, e
-		}
+	if vg.defaultPlacement != nil {
+		return vg.defaultPlacement
 	}
-	for _, datacenter := range otherDataCenters {
-		r := rand.Int63n(datacenter.AvailableSpaceFor(option))
-		if server, e := datacenter.ReserveOneVolume(r, option); e == nil {
-			servers = append(servers, server)
-		} else {
-			return servers, e
-		}
-	}
-	return
+	return &replicationPlacement{}
 }
 
 func (vg *VolumeGrowth) grow(grpcDialOption grpc.DialOption, topo *Topology, vid needle.VolumeId, option *VolumeGrowOption, servers ...*DataNode) error {
@@ -220,6 +220,9 @@ func (vl *VolumeLayout) ensureCorrectWritables(vid needle.VolumeId) {
 }
 
 func (vl *VolumeLayout) isAllWritable(vid needle.VolumeId) bool {
+	if vl.hasReplicaInMaintenance(vid) {
+		return false
+	}
 	for _, dn := range vl.vid2location[vid].list {
 		if v, getError := dn.GetVolumesById(vid); getError == nil {
 			if v.ReadOnly {
@@ -230,6 +233,15 @@ func (vl *VolumeLayout) isAllWritable(vid needle.VolumeId) bool {
 	return true
 }
 
+func (vl *VolumeLayout) hasReplicaInMaintenance(vid needle.VolumeId) bool {
+	for _, dn := range vl.vid2location[vid].list {
+		if dn.IsInMaintenance() {
+			return true
+		}
+	}
+	return false
+}
+
 func (vl *VolumeLayout) isOversized(v *storage.VolumeInfo) bool {
 	return uint64(v.Size) >= vl.volumeSizeLimit
 }
@@ -388,7 +400,7 @@ func (vl *VolumeLayout) SetVolumeAvailable(dn *DataNode, vid needle.VolumeId, is
 		return false
 	}
 
-	if vl.enoughCopies(vid) {
+	if vl.enoughCopies(vid) && !vl.hasReplicaInMaintenance(vid) {
 		return vl.setVolumeWritable(vid)
 	}
 	return false
@@ -0,0 +1,125 @@
+package chunker
+
+import (
+	"io"
+	"math/bits"
+)
+
+/*
+Content defined chunking splits a stream by its content, so the identical content is split into identical chunks,
+even if shifted by the bytes inserted or removed before it, e.g. in VM images or backup archives.
+
+The boundaries are found with a gear rolling hash over the last 64 bytes. A chunk ends where the top bits
+of the hash are all zero, but not before the minimum size, and at the latest at the maximum size.
+The gear table is generated from a fixed seed, since the same content must be split the same way
+by all versions for the chunks to be deduped.
+*/
+
+type Option struct {
+	MinSize int
+	AvgSize int
+	MaxSize int
+}
+
+// DefaultOption splits the content into chunks of 1/4 of the max size on average, and at least 1/16 of the max size.
+func DefaultOption(maxSize int) Option {
+	return Option{
+		MinSize: maxSize / 16,
+		AvgSize: maxSize / 4,
+		MaxSize: maxSize,
+	}
+}
+
+type Chunker struct {
+	reader     io.Reader
+	option     Option
+	maskShift  uint
+	buf        []byte
+	start, end int // the data not returned yet
+	isEOF      bool
+}
+
+func New(reader io.Reader, option Option) *Chunker {
+	if option.MaxSize < 1 {
+		option.MaxSize = 1
+	}
+	if option.MinSize < 0 || option.MinSize > option.MaxSize {
+		option.MinSize = 0
+	}
+	if option.AvgSize <= option.MinSize {
+		option.AvgSize = option.MinSize + 1
+	}
+	// on average, one boundary every 2^maskBits bytes after the minimum size
+	maskBits := bits.Len(uint(option.AvgSize-option.MinSize)) - 1
+	if maskBits < 1 {
+		maskBits = 1
+	}
+	return &Chunker{
+		reader:    reader,
+		option:    option,
+		maskShift: uint(64 - maskBits),
+		buf:       make([]byte, option.MaxSize),
+	}
+}
+
+// Next returns the next chunk, which is only valid until the next call, or io.EOF after the last chunk.
+func (c *Chunker) Next() ([]byte, error) {
+	if err := c.fill(); err != nil {
+		return nil, err
+	}
+	if c.start == c.end {
+		return nil, io.EOF
+	}
+	n := c.boundary(c.buf[c.start:c.end])
+	chunk := c.buf[c.start : c.start+n]
+	c.start += n
+	return chunk, nil
+}
+
+// IsEOF checks whether the last chunk is returned
+func (c *Chunker) IsEOF() bool {
+	return c.isEOF && c.start == c.end
+}
+
+func (c *Chunker) fill() error {
+	if c.isEOF || c.end-c.start >= c.option.MaxSize {
+		return nil
+	}
+	c.end = copy(c.buf, c.buf[c.start:c.end])
+	c.start = 0
+	n, err := io.ReadFull(c.reader, c.buf[c.end:])
+	c.end += n
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		c.isEOF = true
+		return nil
+	}
+	return err
+}
+
+func (c *Chunker) boundary(data []byte) int {
+	if len(data) <= c.option.MinSize {
+		return len(data)
+	}
+	var h uint64
+	for i := c.option.MinSize; i < len(data); i++ {
+		h = (h << 1) + gear[data[i]]
+		if h>>c.maskShift == 0 {
+			return i + 1
+		}
+	}
+	return len(data)
+}
+
+var gear [256]uint64
+
+func init() {
+	// splitmix64
+	seed := uint64(0x5eaf00d5eed)
+	for i := range gear {
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		gear[i] = z ^ (z >> 31)
+	}
+}
@@ -0,0 +1,75 @@
+package chunker
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func split(t *testing.T, data []byte, option Option) (chunks [][32]byte) {
+	c := New(bytes.NewReader(data), option)
+	var joined []byte
+	for {
+		chunk, err := c.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next chunk: %v", err)
+		}
+		if len(chunk) > option.MaxSize {
+			t.Errorf("chunk size %d over max size %d", len(chunk), option.MaxSize)
+		}
+		joined = append(joined, chunk...)
+		chunks = append(chunks, sha256.Sum256(chunk))
+	}
+	if !c.IsEOF() {
+		t.Errorf("expecting eof")
+	}
+	if !bytes.Equal(joined, data) {
+		t.Errorf("chunks do not add up to the data")
+	}
+	return
+}
+
+func TestChunkerShiftedContent(t *testing.T) {
+	option := DefaultOption(64 * 1024)
+	data := make([]byte, 1024*1024)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	original := split(t, data, option)
+	if len(original) < 32 || len(original) > 128 {
+		t.Errorf("unexpected %d chunks of the average size %d", len(original), option.AvgSize)
+	}
+
+	shifted := split(t, append([]byte("inserted at the start"), data...), option)
+	known := make(map[[32]byte]bool)
+	for _, chunk := range original {
+		known[chunk] = true
+	}
+	same := 0
+	for _, chunk := range shifted {
+		if known[chunk] {
+			same++
+		}
+	}
+	if same < len(original)-2 {
+		t.Errorf("only %d of %d chunks are the same after shifting the content", same, len(original))
+	}
+}
+
+func TestChunkerSmallContent(t *testing.T) {
+	option := DefaultOption(64 * 1024)
+	if chunks := split(t, []byte("hello"), option); len(chunks) != 1 {
+		t.Errorf("unexpected %d chunks", len(chunks))
+	}
+	if chunks := split(t, nil, option); len(chunks) != 0 {
+		t.Errorf("unexpected %d chunks of empty content", len(chunks))
+	}
+	// the same bytes never match the boundary, and are split at the max size
+	if chunks := split(t, make([]byte, 200*1024), option); len(chunks) != 4 || chunks[0] != chunks[1] {
+		t.Errorf("unexpected %d chunks of zeros", len(chunks))
+	}
+}
@@ -0,0 +1,120 @@
+package codec
+
+import (
+	"fmt"
+	"plugin"
+	"strings"
+	"sync"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+)
+
+/*
+A codec transforms the content of file chunks, e.g. a custom compression, encryption, or dedup hashing.
+
+The filer encodes each chunk with the codec before uploading it to the volume servers,
+and saves the codec name in the chunk. Any process reading the chunk decodes it with the codec of the same name,
+so the codec must be loaded by all the filers and mounts reading the files.
+
+A codec can be compiled into the binary by calling Register() in an init() function,
+or built separately as a Go plugin with "go build -buildmode=plugin", exporting
+
+	func NewCodec() codec.Codec
+
+The plugin must be built with the same Go version and the same versions of the shared packages as the binary.
+Go plugins are only supported on Linux, FreeBSD and macOS. WASM modules are not supported yet, since there is no WASM runtime
+in the dependencies; a WASM codec can be wrapped in a Go plugin with a runtime of choice.
+*/
+
+// Codec transforms the content of a chunk. The encoded content is stored as is by the volume servers.
+type Codec interface {
+	// Name identifies the codec in the chunk metadata, and should not change once files are written.
+	Name() string
+	Encode(data []byte) ([]byte, error)
+	Decode(data []byte) ([]byte, error)
+}
+
+const NewCodecSymbol = "NewCodec"
+
+var (
+	codecs     = make(map[string]Codec)
+	codecsLock sync.RWMutex
+)
+
+func Register(c Codec) error {
+	codecsLock.Lock()
+	defer codecsLock.Unlock()
+	if c.Name() == "" {
+		return fmt.Errorf("codec name is empty")
+	}
+	if _, found := codecs[c.Name()]; found {
+		return fmt.Errorf("codec %s is already registered", c.Name())
+	}
+	codecs[c.Name()] = c
+	return nil
+}
+
+func Get(name string) (Codec, error) {
+	codecsLock.RLock()
+	defer codecsLock.RUnlock()
+	if c, found := codecs[name]; found {
+		return c, nil
+	}
+	return nil, fmt.Errorf("codec %s is not loaded", name)
+}
+
+// Encode encodes the data with the named codec, or returns the data as is if the name is empty.
+func Encode(name string, data []byte) ([]byte, error) {
+	if name == "" {
+		return data, nil
+	}
+	c, err := Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return c.Encode(data)
+}
+
+// Decode decodes the data with the named codec, or returns the data as is if the name is empty.
+func Decode(name string, data []byte) ([]byte, error) {
+	if name == "" {
+		return data, nil
+	}
+	c, err := Get(name)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := c.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("decode with codec %s: %v", name, err)
+	}
+	return decoded, nil
+}
+
+// LoadPlugins loads and registers the codecs from the comma separated Go plugin files.
+func LoadPlugins(paths string) error {
+	for _, path := range strings.Split(paths, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		p, err := plugin.Open(path)
+		if err != nil {
+			return fmt.Errorf("open codec plugin %s: %v", path, err)
+		}
+		symbol, err := p.Lookup(NewCodecSymbol)
+		if err != nil {
+			return fmt.Errorf("codec plugin %s: %v", path, err)
+		}
+		newCodec, ok := symbol.(func() Codec)
+		if !ok {
+			return fmt.Errorf("codec plugin %s: %s is %T, expecting func() codec.Codec", path, NewCodecSymbol, symbol)
+		}
+		c := newCodec()
+		if err = Register(c); err != nil {
+			return fmt.Errorf("codec plugin %s: %v", path, err)
+		}
+		glog.V(0).Infof("loaded codec %s from %s", c.Name(), path)
+	}
+	return nil
+}
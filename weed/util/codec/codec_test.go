@@ -0,0 +1,53 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+)
+
+type reverseCodec struct{}
+
+func (c *reverseCodec) Name() string { return "reverse" }
+
+func (c *reverseCodec) Encode(data []byte) ([]byte, error) {
+	encoded := make([]byte, len(data))
+	for i, b := range data {
+		encoded[len(data)-1-i] = b
+	}
+	return encoded, nil
+}
+
+func (c *reverseCodec) Decode(data []byte) ([]byte, error) {
+	return c.Encode(data)
+}
+
+func TestCodec(t *testing.T) {
+
+	data := []byte("hello codec")
+	if encoded, err := Encode("", data); err != nil || !bytes.Equal(encoded, data) {
+		t.Fatalf("empty codec name should not change the data")
+	}
+	if _, err := Decode("reverse", data); err == nil {
+		t.Fatalf("decoding with a codec not loaded should fail")
+	}
+
+	if err := Register(&reverseCodec{}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := Register(&reverseCodec{}); err == nil {
+		t.Errorf("duplicated codec is registered")
+	}
+
+	encoded, err := Encode("reverse", data)
+	if err != nil || string(encoded) != "cedoc olleh" {
+		t.Fatalf("encode: %s, %v", encoded, err)
+	}
+	decoded, err := Decode("reverse", encoded)
+	if err != nil || !bytes.Equal(decoded, data) {
+		t.Fatalf("decode: %s, %v", decoded, err)
+	}
+
+	if err = LoadPlugins("/not/exist.so"); err == nil {
+		t.Errorf("loading a missing plugin should fail")
+	}
+}
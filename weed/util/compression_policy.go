@@ -0,0 +1,95 @@
+package util
+
+import (
+	"mime"
+	"strings"
+)
+
+// CompressionPolicy overrides IsCompressableFileType by the file name extensions and the mime types.
+// The mime types can be "type/*" to match any subtype. The raw rules win over the compress rules,
+// and the extensions win over the mime types.
+type CompressionPolicy struct {
+	CompressMimeTypes  []string
+	CompressExtensions []string
+	RawMimeTypes       []string
+	RawExtensions      []string
+}
+
+var (
+	compressionPolicy = &CompressionPolicy{}
+)
+
+// SetCompressionPolicy sets the policy used by all the chunk uploads of this process, usually once on startup.
+func SetCompressionPolicy(policy *CompressionPolicy) {
+	if policy == nil {
+		policy = &CompressionPolicy{}
+	}
+	compressionPolicy = policy
+}
+
+func GetCompressionPolicy() *CompressionPolicy {
+	return compressionPolicy
+}
+
+func (policy *CompressionPolicy) IsEmpty() bool {
+	return len(policy.CompressMimeTypes) == 0 && len(policy.CompressExtensions) == 0 &&
+		len(policy.RawMimeTypes) == 0 && len(policy.RawExtensions) == 0
+}
+
+// ShouldCompressFileType checks the compression policy of this process, then the built-in rules.
+func ShouldCompressFileType(ext, mtype string) (shouldBeCompressed, iAmSure bool) {
+	return compressionPolicy.IsCompressableFileType(ext, mtype)
+}
+
+func (policy *CompressionPolicy) IsCompressableFileType(ext, mtype string) (shouldBeCompressed, iAmSure bool) {
+	ext = strings.ToLower(ext)
+	if isExtensionMatched(policy.RawExtensions, ext) {
+		return false, true
+	}
+	if isExtensionMatched(policy.CompressExtensions, ext) {
+		return true, true
+	}
+	mediaType := mtype
+	if parsed, _, err := mime.ParseMediaType(mtype); err == nil {
+		mediaType = parsed
+	}
+	if isMimeTypeMatched(policy.RawMimeTypes, mediaType) {
+		return false, true
+	}
+	if isMimeTypeMatched(policy.CompressMimeTypes, mediaType) {
+		return true, true
+	}
+	return IsCompressableFileType(ext, mtype)
+}
+
+func isExtensionMatched(extensions []string, ext string) bool {
+	if ext == "" {
+		return false
+	}
+	for _, e := range extensions {
+		if !strings.HasPrefix(e, ".") {
+			e = "." + e
+		}
+		if strings.ToLower(e) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+func isMimeTypeMatched(patterns []string, mediaType string) bool {
+	if mediaType == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if strings.HasSuffix(pattern, "/*") {
+			if strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		} else if mediaType == pattern {
+			return true
+		}
+	}
+	return false
+}
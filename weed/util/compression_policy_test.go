@@ -0,0 +1,39 @@
+package util
+
+import "testing"
+
+func TestCompressionPolicy(t *testing.T) {
+	policy := &CompressionPolicy{
+		CompressMimeTypes:  []string{"application/x-ndjson", "video/*"},
+		CompressExtensions: []string{".log", "csv"},
+		RawMimeTypes:       []string{"text/*"},
+		RawExtensions:      []string{".json"},
+	}
+
+	tests := []struct {
+		ext, mtype         string
+		shouldBeCompressed bool
+		iAmSure            bool
+	}{
+		{".log", "", true, true},
+		{".CSV", "", true, true},
+		{".json", "application/json", false, true},
+		{".log", "text/plain", true, true}, // the extensions win over the mime types
+		{"", "text/plain; charset=utf-8", false, true},
+		{"", "application/x-ndjson", true, true},
+		{"", "video/mp4", true, true},
+		{".txt", "", true, true}, // falls back to the built-in rules
+		{".png", "", false, true},
+		{"", "", false, false},
+	}
+	for _, tt := range tests {
+		shouldBeCompressed, iAmSure := policy.IsCompressableFileType(tt.ext, tt.mtype)
+		if shouldBeCompressed != tt.shouldBeCompressed || iAmSure != tt.iAmSure {
+			t.Errorf("%q %q: got %v %v, expected %v %v", tt.ext, tt.mtype, shouldBeCompressed, iAmSure, tt.shouldBeCompressed, tt.iAmSure)
+		}
+	}
+
+	if shouldBeCompressed, _ := (&CompressionPolicy{}).IsCompressableFileType("", "text/plain"); !shouldBeCompressed {
+		t.Errorf("empty policy should use the built-in rules")
+	}
+}
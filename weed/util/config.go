@@ -80,12 +80,24 @@ func (vp *ViperProxy) GetInt(key string) int {
 	return vp.Viper.GetInt(key)
 }
 
+func (vp *ViperProxy) GetFloat64(key string) float64 {
+	vp.Lock()
+	defer vp.Unlock()
+	return vp.Viper.GetFloat64(key)
+}
+
 func (vp *ViperProxy) GetStringSlice(key string) []string {
 	vp.Lock()
 	defer vp.Unlock()
 	return vp.Viper.GetStringSlice(key)
 }
 
+func (vp *ViperProxy) GetStringMapString(key string) map[string]string {
+	vp.Lock()
+	defer vp.Unlock()
+	return vp.Viper.GetStringMapString(key)
+}
+
 func GetViper() *ViperProxy {
 	vp.Lock()
 	defer vp.Unlock()
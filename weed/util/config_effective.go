@@ -0,0 +1,156 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+Each option in the *.toml files can be overridden by an environment variable,
+named by prefixing "WEED_", uppercasing the option, and replacing '.' with '_',
+e.g. "mysql.password" by "WEED_MYSQL_PASSWORD".
+
+The precedence, from high to low, is:
+	the environment variable,
+	the *.toml file, the first one found in ".", "$HOME/.seaweedfs", "/usr/local/etc/seaweedfs/", "/etc/seaweedfs/",
+	the default value in the code.
+The command line flags are separated from the *.toml options, and are not affected.
+*/
+
+const (
+	ConfigSourceEnv     = "env"
+	ConfigSourceFile    = "file"
+	ConfigSourceDefault = "default"
+
+	configEnvPrefix = "WEED_"
+	redactedValue   = "<redacted>"
+)
+
+// ConfigOption is the effective value of one *.toml option, and where the value comes from.
+type ConfigOption struct {
+	Key    string
+	Value  interface{}
+	Source string
+	Env    string `json:",omitempty"`
+}
+
+var (
+	knownConfigKeys     = make(map[string]interface{})
+	knownConfigKeysLock sync.Mutex
+)
+
+// AddKnownConfigKeys registers the options of the *.toml files with their example values,
+// to check the environment variables, and to list the options only set by the environment variables.
+func AddKnownConfigKeys(keys map[string]interface{}) {
+	knownConfigKeysLock.Lock()
+	defer knownConfigKeysLock.Unlock()
+	for key, value := range keys {
+		knownConfigKeys[strings.ToLower(key)] = value
+	}
+}
+
+func ConfigEnvName(key string) string {
+	return configEnvPrefix + strings.ToUpper(strings.Replace(key, ".", "_", -1))
+}
+
+// EffectiveConfiguration lists the options set by the *.toml files, the defaults, or the environment variables,
+// ordered by key. The values of the passwords, secrets, keys and tokens are redacted.
+func (vp *ViperProxy) EffectiveConfiguration() (options []ConfigOption) {
+	vp.Lock()
+	defer vp.Unlock()
+
+	keys := make(map[string]bool)
+	for _, key := range vp.Viper.AllKeys() {
+		keys[key] = true
+	}
+	knownConfigKeysLock.Lock()
+	for key := range knownConfigKeys {
+		if isEnvSet(ConfigEnvName(key)) {
+			keys[key] = true
+		}
+	}
+	knownConfigKeysLock.Unlock()
+
+	for key := range keys {
+		option := ConfigOption{
+			Key:    key,
+			Value:  vp.Viper.Get(key),
+			Source: ConfigSourceDefault,
+		}
+		if env := ConfigEnvName(key); isEnvSet(env) {
+			option.Source, option.Env = ConfigSourceEnv, env
+		} else if vp.Viper.InConfig(key) {
+			option.Source = ConfigSourceFile
+		}
+		if isSecretConfigKey(key) && fmt.Sprint(option.Value) != "" {
+			option.Value = redactedValue
+		}
+		options = append(options, option)
+	}
+	sort.Slice(options, func(i, j int) bool {
+		return options[i].Key < options[j].Key
+	})
+	return
+}
+
+func isEnvSet(env string) bool {
+	_, found := os.LookupEnv(env)
+	return found
+}
+
+// isSecretConfigKey errs on the side of redacting, e.g. the connection uris may have the passwords,
+// and the key files or the access keys are also hidden.
+func isSecretConfigKey(key string) bool {
+	name := strings.ToLower(key[strings.LastIndex(key, ".")+1:])
+	for _, word := range []string{"key", "uri", "url", "dsn", "password", "passwd", "secret", "token", "credential"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckEnvOverrides returns the problems of the "WEED_" environment variables in the environ,
+// as not matching any known option, or not matching the type of the option.
+func CheckEnvOverrides(environ []string) (problems []string) {
+	knownConfigKeysLock.Lock()
+	defer knownConfigKeysLock.Unlock()
+
+	envKeys := make(map[string]string)
+	for key := range knownConfigKeys {
+		envKeys[ConfigEnvName(key)] = key
+	}
+
+	for _, kv := range environ {
+		if !strings.HasPrefix(kv, configEnvPrefix) {
+			continue
+		}
+		env, value := kv, ""
+		if i := strings.Index(kv, "="); i >= 0 {
+			env, value = kv[:i], kv[i+1:]
+		}
+		key, found := envKeys[env]
+		if !found {
+			problems = append(problems, fmt.Sprintf("%s does not match any option in the *.toml files", env))
+			continue
+		}
+		var err error
+		switch knownConfigKeys[key].(type) {
+		case bool:
+			_, err = strconv.ParseBool(value)
+		case int, int64:
+			_, err = strconv.ParseInt(value, 10, 64)
+		case float64:
+			_, err = strconv.ParseFloat(value, 64)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s=%s is not a valid %T for %s", env, value, knownConfigKeys[key], key))
+		}
+	}
+	sort.Strings(problems)
+	return
+}
@@ -0,0 +1,56 @@
+package util
+
+import (
+	"testing"
+)
+
+func TestCheckEnvOverrides(t *testing.T) {
+	AddKnownConfigKeys(map[string]interface{}{
+		"mysql.enabled":                    false,
+		"mysql.port":                       int64(3306),
+		"mysql.password":                   "",
+		"master.maintenance.sleep_minutes": int64(17),
+	})
+
+	problems := CheckEnvOverrides([]string{
+		"HOME=/root",
+		"WEED_MYSQL_ENABLED=true",
+		"WEED_MYSQL_PASSWORD=some_password",
+		"WEED_MASTER_MAINTENANCE_SLEEP_MINUTES=17",
+		"WEED_MYSQL_PORT=abc",
+		"WEED_MYSQL_PASWORD=typo",
+	})
+	if len(problems) != 2 {
+		t.Fatalf("expecting 2 problems, but got %v", problems)
+	}
+	if problems[0] != "WEED_MYSQL_PASWORD does not match any option in the *.toml files" {
+		t.Errorf("unexpected problem: %s", problems[0])
+	}
+	if problems[1] != "WEED_MYSQL_PORT=abc is not a valid int64 for mysql.port" {
+		t.Errorf("unexpected problem: %s", problems[1])
+	}
+}
+
+func TestIsSecretConfigKey(t *testing.T) {
+	for key, expected := range map[string]bool{
+		"mysql.password":                     true,
+		"jwt.signing.key":                    true,
+		"s3.aws_secret_access_key":           true,
+		"grpc.master.key":                    true,
+		"etcd.token":                         true,
+		"mongodb.uri":                        true,
+		"arangodb.url":                       true,
+		"cassandra.keyspace":                 true,
+		"s3.aws_access_key_id":               true,
+		"postgres2.connection_dsn":           true,
+		"gcs.google_application_credentials": true,
+		"mysql.username":                     false,
+		"etcd.servers":                       false,
+		"filer.options.recursive_delete":     false,
+		"jwt.signing.expires_after_seconds":  false,
+	} {
+		if isSecretConfigKey(key) != expected {
+			t.Errorf("%s secret: expecting %v", key, expected)
+		}
+	}
+}
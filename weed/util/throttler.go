@@ -1,11 +1,15 @@
 package util
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
 type WriteThrottler struct {
 	compactionBytePerSecond int64
 	lastSizeCounter         int64
 	lastSizeCheckTime       time.Time
+	limiter                 func(delta int64)
 }
 
 func NewWriteThrottler(bytesPerSecond int64) *WriteThrottler {
@@ -15,7 +19,16 @@ func NewWriteThrottler(bytesPerSecond int64) *WriteThrottler {
 	}
 }
 
+// WithLimiter also calls the limiter on each slowdown, e.g. to share the io limits of a disk.
+func (wt *WriteThrottler) WithLimiter(limiter func(delta int64)) *WriteThrottler {
+	wt.limiter = limiter
+	return wt
+}
+
 func (wt *WriteThrottler) MaybeSlowdown(delta int64) {
+	if wt.limiter != nil {
+		wt.limiter(delta)
+	}
 	if wt.compactionBytePerSecond > 0 {
 		wt.lastSizeCounter += delta
 		now := time.Now()
@@ -32,3 +45,45 @@ func (wt *WriteThrottler) MaybeSlowdown(delta int64) {
 		}
 	}
 }
+
+// RateLimiter is a token bucket shared by concurrent callers, with a burst of one second.
+// A nil *RateLimiter has no limit.
+type RateLimiter struct {
+	sync.Mutex
+	ratePerSecond  float64
+	tokens         float64
+	lastRefillTime time.Time
+}
+
+// NewRateLimiter returns nil if ratePerSecond is not positive.
+func NewRateLimiter(ratePerSecond int64) *RateLimiter {
+	if ratePerSecond <= 0 {
+		return nil
+	}
+	return &RateLimiter{
+		ratePerSecond:  float64(ratePerSecond),
+		tokens:         float64(ratePerSecond),
+		lastRefillTime: time.Now(),
+	}
+}
+
+// Wait takes n tokens, and sleeps until the tokens taken over the limit are refilled.
+func (rl *RateLimiter) Wait(n int64) {
+	if rl == nil || n <= 0 {
+		return
+	}
+	rl.Lock()
+	now := time.Now()
+	rl.tokens += now.Sub(rl.lastRefillTime).Seconds() * rl.ratePerSecond
+	if rl.tokens > rl.ratePerSecond {
+		rl.tokens = rl.ratePerSecond
+	}
+	rl.lastRefillTime = now
+	rl.tokens -= float64(n)
+	var sleepTime time.Duration
+	if rl.tokens < 0 {
+		sleepTime = time.Duration(-rl.tokens / rl.ratePerSecond * float64(time.Second))
+	}
+	rl.Unlock()
+	time.Sleep(sleepTime)
+}
@@ -0,0 +1,27 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+
+	var unlimited *RateLimiter
+	unlimited.Wait(1000)
+	if NewRateLimiter(0) != nil {
+		t.Fatalf("zero rate should not be limited")
+	}
+
+	rl := NewRateLimiter(1000)
+	start := time.Now()
+	rl.Wait(1000) // the burst
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("burst waited %v", elapsed)
+	}
+	rl.Wait(100)
+	rl.Wait(100)
+	if elapsed := time.Since(start); elapsed < 180*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Errorf("expected to wait about 200ms, but waited %v", elapsed)
+	}
+}
@@ -0,0 +1,73 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type timeWindow struct {
+	start, stop time.Duration // since midnight
+}
+
+// TimeWindows are the daily time ranges in the local time, e.g. "01:00-05:00,22:30-23:30".
+// A range can cross midnight, e.g. "22:00-06:00". Empty TimeWindows contain any time.
+type TimeWindows []timeWindow
+
+func ParseTimeWindows(s string) (windows TimeWindows, err error) {
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		startStop := strings.Split(part, "-")
+		if len(startStop) != 2 {
+			return nil, fmt.Errorf("expecting <HH:MM>-<HH:MM>, but got %q", part)
+		}
+		var w timeWindow
+		if w.start, err = parseTimeOfDay(startStop[0]); err != nil {
+			return nil, fmt.Errorf("time window %q: %v", part, err)
+		}
+		if w.stop, err = parseTimeOfDay(startStop[1]); err != nil {
+			return nil, fmt.Errorf("time window %q: %v", part, err)
+		}
+		if w.start == w.stop {
+			return nil, fmt.Errorf("time window %q is empty", part)
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (windows TimeWindows) Contains(t time.Time) bool {
+	if len(windows) == 0 {
+		return true
+	}
+	sinceMidnight := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	for _, w := range windows {
+		if w.start < w.stop {
+			if w.start <= sinceMidnight && sinceMidnight < w.stop {
+				return true
+			}
+		} else if sinceMidnight >= w.start || sinceMidnight < w.stop {
+			return true
+		}
+	}
+	return false
+}
+
+func (windows TimeWindows) String() string {
+	var parts []string
+	for _, w := range windows {
+		parts = append(parts, fmt.Sprintf("%02d:%02d-%02d:%02d", int(w.start.Hours()), int(w.start.Minutes())%60, int(w.stop.Hours()), int(w.stop.Minutes())%60))
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,49 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeWindows(t *testing.T) {
+	windows, err := ParseTimeWindows("01:00-05:00, 22:30-00:30")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if windows.String() != "01:00-05:00,22:30-00:30" {
+		t.Errorf("unexpected %s", windows.String())
+	}
+
+	at := func(hour, minute int) time.Time {
+		return time.Date(2021, 3, 1, hour, minute, 0, 0, time.Local)
+	}
+	for _, tc := range []struct {
+		t        time.Time
+		expected bool
+	}{
+		{at(0, 59), false},
+		{at(1, 0), true},
+		{at(4, 59), true},
+		{at(5, 0), false},
+		{at(12, 0), false},
+		{at(22, 30), true},
+		{at(23, 59), true},
+		{at(0, 15), true},
+		{at(0, 30), false},
+	} {
+		if windows.Contains(tc.t) != tc.expected {
+			t.Errorf("%v in %s: expected %v", tc.t, windows, tc.expected)
+		}
+	}
+
+	var noWindows TimeWindows
+	if !noWindows.Contains(at(12, 0)) {
+		t.Errorf("empty time windows should contain any time")
+	}
+
+	for _, s := range []string{"01:00", "25:00-02:00", "01:00-01:00"} {
+		if _, err := ParseTimeWindows(s); err == nil {
+			t.Errorf("expected parse error for %q", s)
+		}
+	}
+}
@@ -2,7 +2,10 @@ package wdclient
 
 import (
 	"context"
+	"fmt"
 	"math/rand"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/chrislusf/seaweedfs/weed/util"
@@ -13,34 +16,114 @@ import (
 	"github.com/chrislusf/seaweedfs/weed/pb/master_pb"
 )
 
+const (
+	// MasterKeepAliveInterval is how often the leader sends an empty message to the connected clients
+	MasterKeepAliveInterval = time.Second
+	// the clients seeing the keep alive messages reconnect if the leader is silent for this long
+	masterIdleTimeout = 3 * MasterKeepAliveInterval
+	// how long to wait for the masters to answer for the leader hint
+	leaderHintTimeout = 500 * time.Millisecond
+)
+
 type MasterClient struct {
 	clientType     string
 	clientHost     string
 	grpcPort       uint32
-	currentMaster  string
+	currentMaster  atomic.Value // the leader connected to, or empty
 	masters        []string
 	grpcDialOption grpc.DialOption
+	lookupIndex    uint32
 
 	vidMap
 }
 
 func NewMasterClient(grpcDialOption grpc.DialOption, clientType string, clientHost string, clientGrpcPort uint32, clientDataCenter string, masters []string) *MasterClient {
-	return &MasterClient{
+	mc := &MasterClient{
 		clientType:     clientType,
 		clientHost:     clientHost,
 		grpcPort:       clientGrpcPort,
 		masters:        masters,
 		grpcDialOption: grpcDialOption,
-		vidMap:         newVidMap(clientDataCenter),
 	}
+	mc.resetVidMap(clientDataCenter)
+	return mc
 }
 
+// GetMaster returns the leader, which should be used for writes, e.g., assigning file ids.
 func (mc *MasterClient) GetMaster() string {
-	return mc.currentMaster
+	master, _ := mc.currentMaster.Load().(string)
+	return master
+}
+
+func (mc *MasterClient) setCurrentMaster(master string) {
+	mc.currentMaster.Store(master)
+}
+
+// GetLookupMaster returns one of the masters in turn, to spread the lookups over all masters.
+func (mc *MasterClient) GetLookupMaster() string {
+	if len(mc.masters) == 0 {
+		return mc.GetMaster()
+	}
+	return mc.masters[int(atomic.AddUint32(&mc.lookupIndex, 1)%uint32(len(mc.masters)))]
+}
+
+func (mc *MasterClient) resetVidMap(dataCenter string) {
+	mc.vidMap = newVidMap(dataCenter)
+	// the masters only learn the volume locations from the leader,
+	// and should not look up the missing volumes from each other
+	if mc.clientType != "master" {
+		mc.vidMap.lookupMissFn = mc.lookupFromMasters
+	}
+}
+
+// lookupFromMasters looks up a volume not known from the leader yet, from one of the masters, and then from the leader.
+func (mc *MasterClient) lookupFromMasters(vid uint32) (locations []Location, found bool) {
+	vidString := fmt.Sprintf("%d", vid)
+
+	masters := []string{mc.GetLookupMaster()}
+	if leader := mc.GetMaster(); leader != "" && leader != masters[0] {
+		masters = append(masters, leader)
+	}
+
+	for _, master := range masters {
+		if master == "" {
+			continue
+		}
+		err := pb.WithMasterClient(master, mc.grpcDialOption, func(client master_pb.SeaweedClient) error {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			resp, err := client.LookupVolume(ctx, &master_pb.LookupVolumeRequest{VolumeIds: []string{vidString}})
+			if err != nil {
+				return err
+			}
+			for _, vidLocations := range resp.VolumeIdLocations {
+				if vidLocations.VolumeId != vidString || vidLocations.Error != "" {
+					continue
+				}
+				for _, loc := range vidLocations.Locations {
+					location := Location{
+						Url:       loc.Url,
+						PublicUrl: loc.PublicUrl,
+					}
+					locations = append(locations, location)
+					mc.addLocation(vid, location)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			glog.V(1).Infof("%s masterClient lookup volume %d from %s: %v", mc.clientType, vid, master, err)
+			continue
+		}
+		if len(locations) > 0 {
+			return locations, true
+		}
+	}
+	return nil, false
 }
 
 func (mc *MasterClient) WaitUntilConnected() {
-	for mc.currentMaster == "" {
+	for mc.GetMaster() == "" {
 		time.Sleep(time.Duration(rand.Int31n(200)) * time.Millisecond)
 	}
 }
@@ -88,11 +171,67 @@ func (mc *MasterClient) tryAllMasters() {
 			nextHintedLeader = mc.tryConnectToMaster(nextHintedLeader)
 		}
 
-		mc.currentMaster = ""
-		mc.vidMap = newVidMap("")
+		mc.setCurrentMaster("")
+		mc.resetVidMap("")
+
+		// go to the new leader directly, instead of trying the masters in turn
+		for lastMaster := master; ; {
+			leader := FindLeaderHint(mc.masters, mc.grpcDialOption, lastMaster)
+			if leader == "" {
+				break
+			}
+			glog.V(0).Infof("%s masterClient hinted to leader %s", mc.clientType, leader)
+			nextHintedLeader = mc.tryConnectToMaster(leader)
+			for nextHintedLeader != "" {
+				nextHintedLeader = mc.tryConnectToMaster(nextHintedLeader)
+			}
+			mc.setCurrentMaster("")
+			mc.resetVidMap("")
+			lastMaster = leader
+		}
 	}
 }
 
+// FindLeaderHint asks the masters at the same time for the leader they know, and returns the leader known
+// by most of them, except the excluded one, e.g. the leader just gone. It is empty if no master knows the leader.
+func FindLeaderHint(masters []string, grpcDialOption grpc.DialOption, excluded string) (leader string) {
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	votes := make(map[string]int)
+	for _, master := range masters {
+		if master == excluded {
+			continue
+		}
+		wg.Add(1)
+		go func(master string) {
+			defer wg.Done()
+			if grpcErr := pb.WithMasterClient(master, grpcDialOption, func(client master_pb.SeaweedClient) error {
+				ctx, cancel := context.WithTimeout(context.Background(), leaderHintTimeout)
+				defer cancel()
+				resp, err := client.GetMasterConfiguration(ctx, &master_pb.GetMasterConfigurationRequest{})
+				if err != nil {
+					return err
+				}
+				if resp.Leader != "" && resp.Leader != excluded {
+					lock.Lock()
+					votes[resp.Leader]++
+					lock.Unlock()
+				}
+				return nil
+			}); grpcErr != nil {
+				glog.V(1).Infof("leader hint from %s: %v", master, grpcErr)
+			}
+		}(master)
+	}
+	wg.Wait()
+	for l, count := range votes {
+		if count > votes[leader] || count == votes[leader] && l < leader {
+			leader = l
+		}
+	}
+	return
+}
+
 func (mc *MasterClient) tryConnectToMaster(master string) (nextHintedLeader string) {
 	glog.V(1).Infof("%s masterClient Connecting to master %v", mc.clientType, master)
 	gprcErr := pb.WithMasterClient(master, mc.grpcDialOption, func(client master_pb.SeaweedClient) error {
@@ -112,7 +251,15 @@ func (mc *MasterClient) tryConnectToMaster(master string) (nextHintedLeader stri
 		}
 
 		glog.V(1).Infof("%s masterClient Connected to %v", mc.clientType, master)
-		mc.currentMaster = master
+		mc.setCurrentMaster(master)
+
+		// only the masters sending the keep alive messages are timed out
+		var idleTimer *time.Timer
+		defer func() {
+			if idleTimer != nil {
+				idleTimer.Stop()
+			}
+		}()
 
 		for {
 			volumeLocation, err := stream.Recv()
@@ -121,6 +268,19 @@ func (mc *MasterClient) tryConnectToMaster(master string) (nextHintedLeader stri
 				return err
 			}
 
+			if idleTimer != nil {
+				idleTimer.Reset(masterIdleTimeout)
+			}
+			if isKeepAlive(volumeLocation) {
+				if idleTimer == nil {
+					idleTimer = time.AfterFunc(masterIdleTimeout, func() {
+						glog.V(0).Infof("%s masterClient: no message from %s in %v", mc.clientType, master, masterIdleTimeout)
+						cancel()
+					})
+				}
+				continue
+			}
+
 			// maybe the leader is changed
 			if volumeLocation.Leader != "" {
 				glog.V(0).Infof("redirected to leader %v", volumeLocation.Leader)
@@ -151,12 +311,16 @@ func (mc *MasterClient) tryConnectToMaster(master string) (nextHintedLeader stri
 	return
 }
 
+func isKeepAlive(volumeLocation *master_pb.VolumeLocation) bool {
+	return volumeLocation.Url == "" && volumeLocation.Leader == "" && len(volumeLocation.NewVids) == 0 && len(volumeLocation.DeletedVids) == 0
+}
+
 func (mc *MasterClient) WithClient(fn func(client master_pb.SeaweedClient) error) error {
 	return util.Retry("master grpc", func() error {
-		for mc.currentMaster == "" {
+		for mc.GetMaster() == "" {
 			time.Sleep(3 * time.Second)
 		}
-		return pb.WithMasterClient(mc.currentMaster, mc.grpcDialOption, func(client master_pb.SeaweedClient) error {
+		return pb.WithMasterClient(mc.GetMaster(), mc.grpcDialOption, func(client master_pb.SeaweedClient) error {
 			return fn(client)
 		})
 	})
@@ -7,12 +7,17 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/chrislusf/seaweedfs/weed/glog"
 )
 
 const (
 	maxCursorIndex = 4096
+	// the volumes not found by lookupMissFn are not looked up again for a while
+	lookupMissTtl = 3 * time.Second
+	// the cached misses are dropped all together beyond this count
+	maxLookupMisses = 1024
 )
 
 type HasLookupFileIdFunction interface {
@@ -32,11 +37,16 @@ type vidMap struct {
 	vid2Locations map[uint32][]Location
 	DataCenter    string
 	cursor        int32
+
+	// optional, to look up volumes not in the cache
+	lookupMissFn func(vid uint32) (locations []Location, found bool)
+	lookupMisses map[uint32]time.Time
 }
 
 func newVidMap(dataCenter string) vidMap {
 	return vidMap{
 		vid2Locations: make(map[uint32][]Location),
+		lookupMisses:  make(map[uint32]time.Time),
 		DataCenter:    dataCenter,
 		cursor:        -1,
 	}
@@ -107,9 +117,21 @@ func (vc *vidMap) GetVidLocations(vid string) (locations []Location, err error)
 
 func (vc *vidMap) GetLocations(vid uint32) (locations []Location, found bool) {
 	vc.RLock()
-	defer vc.RUnlock()
-
 	locations, found = vc.vid2Locations[vid]
+	missedAt, missed := vc.lookupMisses[vid]
+	vc.RUnlock()
+
+	if found || vc.lookupMissFn == nil || missed && time.Since(missedAt) < lookupMissTtl {
+		return
+	}
+	if locations, found = vc.lookupMissFn(vid); !found {
+		vc.Lock()
+		if vc.lookupMisses == nil || len(vc.lookupMisses) >= maxLookupMisses {
+			vc.lookupMisses = make(map[uint32]time.Time)
+		}
+		vc.lookupMisses[vid] = time.Now()
+		vc.Unlock()
+	}
 	return
 }
 
@@ -117,6 +139,8 @@ func (vc *vidMap) addLocation(vid uint32, location Location) {
 	vc.Lock()
 	defer vc.Unlock()
 
+	delete(vc.lookupMisses, vid)
+
 	locations, found := vc.vid2Locations[vid]
 	if !found {
 		vc.vid2Locations[vid] = []Location{location}
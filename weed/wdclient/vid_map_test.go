@@ -3,6 +3,7 @@ package wdclient
 import (
 	"fmt"
 	"testing"
+	"time"
 )
 
 func TestLocationIndex(t *testing.T) {
@@ -74,3 +75,40 @@ func BenchmarkLocationIndex(b *testing.B) {
 		}
 	})
 }
+
+func TestLookupMiss(t *testing.T) {
+	vm := newVidMap("")
+	vm.addLocation(1, Location{Url: "a:8080"})
+
+	missCount := 0
+	vm.lookupMissFn = func(vid uint32) ([]Location, bool) {
+		missCount++
+		if vid == 2 {
+			return []Location{{Url: "b:8080"}}, true
+		}
+		return nil, false
+	}
+
+	if locations, found := vm.GetLocations(1); !found || locations[0].Url != "a:8080" || missCount != 0 {
+		t.Errorf("unexpected lookup of cached volume 1: %v %v", locations, found)
+	}
+	if urls, err := vm.LookupVolumeServerUrl("2"); err != nil || len(urls) != 1 || urls[0] != "b:8080" {
+		t.Errorf("unexpected lookup of volume 2: %v %v", urls, err)
+	}
+	if _, found := vm.GetLocations(3); found || missCount != 2 {
+		t.Errorf("volume 3 should not be found")
+	}
+
+	// the miss is cached for a while, until the volume is added
+	if _, found := vm.GetLocations(3); found || missCount != 2 {
+		t.Errorf("volume 3 should not be looked up again: %d lookups", missCount)
+	}
+	vm.addLocation(3, Location{Url: "c:8080"})
+	if locations, found := vm.GetLocations(3); !found || locations[0].Url != "c:8080" {
+		t.Errorf("unexpected lookup of added volume 3: %v %v", locations, found)
+	}
+	vm.lookupMisses[4] = time.Now().Add(-lookupMissTtl)
+	if _, found := vm.GetLocations(4); found || missCount != 3 {
+		t.Errorf("expired miss of volume 4 should be looked up again: %d lookups", missCount)
+	}
+}